@@ -0,0 +1,130 @@
+// Command bench measures how many operations a second ContextDB processes end
+// to end, through the operation DAG, a store on disk, the document and the
+// broadcast to clients, for each combination of the document sizes and
+// client counts given. Each run starts from an empty store. Results are
+// printed as a table, or a JSON object per line with -json for comparing
+// runs over time.
+package main
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/logging"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func main() {
+	sizes := flag.String("sizes", "0,16384,262144", "comma-separated document sizes in bytes")
+	clients := flag.String("clients", "0,10,100", "comma-separated numbers of clients following the document")
+	writers := flag.Int("writers", 1, "operations processed at the same time")
+	duration := flag.Duration("duration", 5*time.Second, "how long each run lasts")
+	ops := flag.Int("ops", 0, "operations in each run, instead of -duration")
+	dir := flag.String("dir", "", "directory for the stores, a temporary one when empty")
+	asJSON := flag.Bool("json", false, "print each result as a JSON object")
+	flag.Parse()
+
+	if err := run(*sizes, *clients, *writers, *duration, *ops, *dir, *asJSON); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(sizeList, clientList string, writers int, duration time.Duration, ops int, dir string, asJSON bool) error {
+	sizes, err := parseInts(sizeList)
+	if err != nil {
+		return fmt.Errorf("invalid -sizes: %w", err)
+	}
+	clientCounts, err := parseInts(clientList)
+	if err != nil {
+		return fmt.Errorf("invalid -clients: %w", err)
+	}
+	if dir == "" {
+		if dir, err = os.MkdirTemp("", "contextdb-bench-*"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+	}
+
+	// Clients connecting and leaving aren't news here
+	logging.SetGlobalLevel(logging.WARN)
+
+	ctx, stop := signal.NotifyContext(gocontext.Background(), os.Interrupt)
+	defer stop()
+
+	// Rows are printed as each run ends, so the columns are a fixed width
+	const row = "%9v %8v %8v %8v %10v %10v %10v %10v %12v\n"
+	if !asJSON {
+		fmt.Printf(row, "size", "clients", "writers", "ops", "ops/s", "p50", "p99", "max", "undelivered")
+	}
+	if ops > 0 {
+		duration = 0
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	for _, size := range sizes {
+		for _, clients := range clientCounts {
+			result, err := measure(ctx, filepath.Join(dir, fmt.Sprintf("size-%d-clients-%d", size, clients)), collaboration.ThroughputConfig{
+				DocumentSize: size,
+				Clients:      clients,
+				Writers:      writers,
+				Operations:   ops,
+				Duration:     duration,
+			})
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				if err := encoder.Encode(result); err != nil {
+					return err
+				}
+				continue
+			}
+			fmt.Printf(row, size, clients, result.Config.Writers, result.Operations, fmt.Sprintf("%.1f", result.OpsPerSecond),
+				roundLatency(result.P50), roundLatency(result.P99), roundLatency(result.Max), result.Undelivered)
+		}
+	}
+	return nil
+}
+
+// measure makes one run against a new store in path
+func measure(ctx gocontext.Context, path string, config collaboration.ThroughputConfig) (*collaboration.ThroughputResult, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	store, err := storage.NewContextStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %w", err)
+	}
+	engine := collaboration.NewCollaborationEngine(store)
+
+	result, err := engine.MeasureThroughput(ctx, "bench.go", config)
+	if shutdownErr := engine.Shutdown(gocontext.Background()); err == nil && shutdownErr != nil {
+		err = fmt.Errorf("failed to close store: %w", shutdownErr)
+	}
+	return result, err
+}
+
+func parseInts(list string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(list, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("%q is not a count", field)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
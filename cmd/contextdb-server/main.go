@@ -0,0 +1,109 @@
+// Command contextdb-server runs the ContextDB HTTP API as a long-lived
+// service configured from a YAML file. SIGHUP reloads the file; SIGINT and
+// SIGTERM shut down gracefully. The -tls-cert and -tls-key flags serve HTTPS
+// and WSS without a config file, and take precedence over one.
+package main
+
+import (
+	gocontext "context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jeremytregunna/contextdb/internal/logging"
+	"github.com/jeremytregunna/contextdb/internal/server"
+)
+
+func main() {
+	configPath := flag.String("config", "", "path to a YAML config file, defaults are used when empty")
+	var tls server.TLSConfig
+	flag.StringVar(&tls.CertFile, "tls-cert", "", "path to a PEM certificate, serves HTTPS and WSS with -tls-key")
+	flag.StringVar(&tls.KeyFile, "tls-key", "", "path to the PEM private key for -tls-cert")
+	flag.Parse()
+
+	if err := run(*configPath, tls); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(configPath string, tls server.TLSConfig) error {
+	config, err := loadConfig(configPath, tls)
+	if err != nil {
+		return err
+	}
+
+	srv, err := server.New(config)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(gocontext.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	go reloadOnHangup(ctx, srv, configPath, tls, hangups)
+
+	return srv.Run(ctx)
+}
+
+// loadConfig reads the config file, if there is one, with TLS from the
+// command line in place of the file's
+func loadConfig(path string, tls server.TLSConfig) (server.Config, error) {
+	config := server.DefaultConfig()
+	if path != "" {
+		var err error
+		if config, err = server.LoadConfig(path); err != nil {
+			return config, err
+		}
+	}
+
+	if tls.Enabled() {
+		config.TLS = tls
+		if err := config.Validate(); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+// reloadOnHangup re-reads the config file on every SIGHUP. A bad file is
+// logged and the running configuration is kept.
+func reloadOnHangup(ctx gocontext.Context, srv *server.Server, configPath string, tls server.TLSConfig, hangups <-chan os.Signal) {
+	logger := logging.NewLogger("contextdb-server")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+		}
+
+		if configPath == "" {
+			logger.Warn("Ignoring SIGHUP, no config file to reload")
+			continue
+		}
+
+		config, err := loadConfig(configPath, tls)
+		if err == nil {
+			err = srv.Reload(config)
+		}
+
+		switch {
+		case errors.Is(err, server.ErrRestartRequired):
+			logger.Warn("Some config changes need a restart to take effect", map[string]interface{}{
+				"error": err.Error(),
+			})
+		case err != nil:
+			logger.Error("Config reload failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+}
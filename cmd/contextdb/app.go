@@ -0,0 +1,78 @@
+package main
+
+import (
+	gocontext "context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/server"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// app holds the local .context store and the engine built on top of it for
+// the duration of one command
+type app struct {
+	basePath string
+	store    *storage.ContextStore
+	engine   *collaboration.CollaborationEngine
+	auth     *auth.AuthManager
+}
+
+func openApp(basePath string) (*app, error) {
+	store, err := storage.NewContextStore(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open context store: %w", err)
+	}
+
+	authManager, err := auth.NewAuthManager(basePath)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to open auth config: %w", err)
+	}
+
+	a := &app{
+		basePath: basePath,
+		store:    store,
+		engine:   collaboration.NewCollaborationEngine(store),
+		auth:     authManager,
+	}
+
+	if err := a.engine.LoadIntentTaxonomy(gocontext.Background()); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to load intent taxonomy: %w", err)
+	}
+	if err := a.engine.LoadAuthorAliases(gocontext.Background()); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to load author aliases: %w", err)
+	}
+	if err := a.loadConversations(); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// close persists conversations, which otherwise only live in memory, and closes the store
+func (a *app) close() error {
+	saveErr := a.saveConversations()
+	closeErr := a.store.Close()
+	if saveErr != nil {
+		return saveErr
+	}
+	return closeErr
+}
+
+func (a *app) contextPath() string {
+	return filepath.Join(a.basePath, storage.ContextDir)
+}
+
+func (a *app) loadConversations() error {
+	return server.LoadConversations(server.ConversationsPath(a.basePath), a.engine.ConversationManager())
+}
+
+func (a *app) saveConversations() error {
+	return server.SaveConversations(server.ConversationsPath(a.basePath), a.engine.ConversationManager())
+}
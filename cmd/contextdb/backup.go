@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/backup"
+	"github.com/spf13/cobra"
+)
+
+func newBackupCommand(basePath *string, withApp appRunner) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Back up and restore the .context store",
+	}
+
+	var generations int
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Back up the store to .context/backups",
+		Long: `Create copies the database with SQLite's online backup API, so it is safe to
+run while a server is using the store, along with the JSON files in .context.
+Backups beyond the newest --generations are removed.`,
+		Args: cobra.NoArgs,
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			manager := backup.NewManager(a.basePath, a.store,
+				backup.WithGenerations(generations),
+				backup.WithBeforeBackup(a.saveConversations),
+			)
+
+			generation, err := manager.Create(cmd.Context())
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "created backup %s (%d bytes)\n", generation.Name, generation.Size)
+			return nil
+		}),
+	}
+	create.Flags().IntVar(&generations, "generations", backup.DefaultGenerations, "number of backups to keep")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List backups, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			generations, err := backup.List(*basePath)
+			if err != nil {
+				return err
+			}
+
+			out := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			defer out.Flush()
+
+			for _, generation := range generations {
+				fmt.Fprintf(out, "%s\t%s\t%d bytes\n", generation.Name,
+					generation.CreatedAt.Local().Format(time.RFC3339), generation.Size)
+			}
+			return nil
+		},
+	}
+
+	restore := &cobra.Command{
+		Use:   "restore <name>",
+		Short: "Replace the store with a backup",
+		Long: `Restore replaces the database and JSON files in .context with those of the
+named backup. Stop any server using the store first. The replaced files are
+kept under .context/backups/replaced-<time>.`,
+		Args: cobra.ExactArgs(1),
+		// The store must stay closed while its files are swapped, so no withApp
+		RunE: func(cmd *cobra.Command, args []string) error {
+			replaced, err := backup.Restore(*basePath, args[0])
+			if err != nil {
+				return err
+			}
+
+			// Make sure what was restored opens before reporting success
+			a, err := openApp(*basePath)
+			if err != nil {
+				return fmt.Errorf("restored %s but it failed to open, the previous store is in %s: %w", args[0], replaced, err)
+			}
+			if err := a.close(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "restored %s, the previous store is in %s\n", args[0], replaced)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(create, list, restore)
+	return cmd
+}
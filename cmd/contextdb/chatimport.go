@@ -0,0 +1,96 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/chatimport"
+	"github.com/spf13/cobra"
+)
+
+func newConversationImportCommand(withApp appRunner) *cobra.Command {
+	var source, repository string
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "import <export>",
+		Short: "Import conversations from a Slack or Discord export",
+		Long: `Import turns the threads in a chat export into conversations. Each thread is
+anchored to the first tracked file it links to with a GitHub or GitLab
+permalink, mentions as path/to/file.go:10-20 or attaches. Threads that
+reference no tracked file are skipped unless --all is given.
+
+A Slack export is the workspace export directory or zip file. A Discord export
+is DiscordChatExporter JSON, one channel file or a directory of them.
+Importing a newer export of the same channels adds only the new messages.`,
+		Args: cobra.ExactArgs(1),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			threads, err := readChatExport(source, args[0])
+			if err != nil {
+				return err
+			}
+
+			if repository == "" {
+				root, err := filepath.Abs(a.basePath)
+				if err != nil {
+					return err
+				}
+				repository = filepath.Base(root)
+			}
+			opts := []chatimport.Option{chatimport.WithRepository(addressing.RepositoryID(repository))}
+			if all {
+				opts = append(opts, chatimport.WithUnanchored())
+			}
+
+			report, err := chatimport.NewImporter(a.engine, a.store, opts...).Import(cmd.Context(), threads)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "threads: %d imported, %d updated, %d skipped\nmessages: %d\n",
+				report.Imported, report.Updated, len(report.Skipped), report.Messages)
+			for _, skipped := range report.Skipped {
+				fmt.Fprintf(cmd.ErrOrStderr(), "skipped %s thread %s in #%s: %s\n", skipped.Source, skipped.ID, skipped.Channel, skipped.Reason)
+			}
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&source, "from", chatimport.SourceSlack, "export format, slack or discord")
+	cmd.Flags().StringVar(&repository, "repository", "", "repository name in conversation addresses, the directory name by default")
+	cmd.Flags().BoolVar(&all, "all", false, "import threads that reference no tracked file too, unanchored")
+	return cmd
+}
+
+// readChatExport reads the export at path, a directory, a zip file or, for
+// Discord, a single channel file
+func readChatExport(source, path string) ([]chatimport.Thread, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return chatimport.Read(source, os.DirFS(path))
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+		return chatimport.Read(source, archive)
+	}
+
+	if source != chatimport.SourceDiscord {
+		return nil, fmt.Errorf("%s export %s must be a directory or zip file", source, path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return chatimport.ReadDiscordFile(file)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/spf13/cobra"
+)
+
+func newDecisionCommand(withApp appRunner) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "decision",
+		Short: "Audit the decisions recorded in conversations",
+	}
+
+	var document string
+	var current bool
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List decisions, newest first",
+		Args:  cobra.NoArgs,
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			filter := context.DecisionFilter{Current: current}
+
+			var decisions []*context.Decision
+			if document != "" {
+				decisions = a.engine.DecisionsInDocument(cmd.Context(), document, filter)
+			} else {
+				decisions = a.engine.ConversationManager().Decisions(filter)
+			}
+
+			out := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			defer out.Flush()
+
+			for _, decision := range decisions {
+				state := "current"
+				if decision.SupersededBy != "" {
+					state = "superseded by " + string(decision.SupersededBy)
+				}
+				fmt.Fprintf(out, "%s\t%s\t%s\t%s\n",
+					decision.ID, decision.CreatedAt.Format(time.RFC3339), decision.Title, state)
+			}
+			return nil
+		}),
+	}
+	list.Flags().StringVar(&document, "document", "", "only decisions anchored in this document")
+	list.Flags().BoolVar(&current, "current", false, "leave out superseded decisions")
+
+	show := &cobra.Command{
+		Use:   "show <decision-id>",
+		Short: "Show a decision and what it replaced",
+		Args:  cobra.ExactArgs(1),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			decision, err := a.engine.ConversationManager().GetDecision(context.MessageID(args[0]))
+			if err != nil {
+				return err
+			}
+
+			w := cmd.OutOrStdout()
+			fmt.Fprintf(w, "%s\n", decision.Title)
+			fmt.Fprintf(w, "Decided by %s on %s in conversation %s\n",
+				shortID(string(decision.AuthorID)), decision.CreatedAt.Format(time.RFC3339), decision.ThreadID)
+			fmt.Fprintf(w, "Anchored to operation %s\n", decision.Address.OperationID)
+			for _, id := range decision.Supersedes {
+				fmt.Fprintf(w, "Supersedes %s\n", id)
+			}
+			if decision.SupersededBy != "" {
+				fmt.Fprintf(w, "Superseded by %s\n", decision.SupersededBy)
+			}
+			fmt.Fprintf(w, "\n  %s\n", decision.Content)
+			return nil
+		}),
+	}
+
+	supersede := &cobra.Command{
+		Use:   "supersede <decision-id> <replacement-id>",
+		Short: "Mark a decision as replaced by a later one",
+		Args:  cobra.ExactArgs(2),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			_, err := a.engine.ConversationManager().SupersedeDecision(context.MessageID(args[0]), context.MessageID(args[1]))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s is superseded by %s\n", args[0], args[1])
+			return nil
+		}),
+	}
+
+	cmd.AddCommand(list, show, supersede)
+	return cmd
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func newFsckCommand(withApp appRunner) *cobra.Command {
+	var repair bool
+
+	cmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Verify the consistency of the store",
+		Long: `Fsck checks that operation parents exist, that constructs belong to a document
+and were created and modified by operations in the store, that each document's
+content hash matches its rendered content, and that the manifest and schema
+are what this version expects.
+
+With --repair, orphans are removed: parent references to missing operations,
+constructs of missing documents and content blobs no operation refers to.
+Other problems are only reported. Exits with an error while any remain.`,
+		Args: cobra.NoArgs,
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			report, err := a.engine.CheckIntegrity(cmd.Context(), repair)
+			if err != nil {
+				return err
+			}
+
+			remaining := 0
+			out := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			for _, problem := range report.Problems {
+				status := "repaired"
+				if !problem.Repaired {
+					status = ""
+					remaining++
+				}
+				fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", problem.Kind, problem.Subject, problem.Message, status)
+			}
+			out.Flush()
+
+			fmt.Fprintf(cmd.OutOrStdout(), "checked %d operations, %d documents, %d constructs: %d problems\n",
+				report.Operations, report.Documents, report.Constructs, len(report.Problems))
+			if remaining > 0 {
+				return fmt.Errorf("%d problems remain", remaining)
+			}
+			return nil
+		}),
+	}
+	cmd.Flags().BoolVar(&repair, "repair", false, "remove orphaned references, constructs and blobs")
+
+	return cmd
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// fileOrigin describes who last touched a file, taken from git when available
+type fileOrigin struct {
+	author    string
+	timestamp time.Time
+	commit    string
+	message   string
+}
+
+func newIngestCommand(withApp appRunner) *cobra.Command {
+	var fromGit, ndjson, stopOnError bool
+	var authorName, errorsPath string
+
+	cmd := &cobra.Command{
+		Use:   "ingest [paths...]",
+		Short: "Record files as operations, one document per file",
+		Long: `Ingest records the current content of each file as an insert operation on a
+document named after its path. With --git the file list comes from git ls-files
+and each operation is attributed to the author and commit that last changed the
+file. Paths are relative to --path. Files whose document already exists are
+skipped.
+
+With --ndjson the arguments are instead files of operations, one JSON object
+per line as the API takes them, read from stdin when none or "-" is given.
+Each is validated and applied in order; author, timestamp and id may be left
+out. Rejected records are written to --errors with the reason, and the
+command fails once the rest are in if any were.`,
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			if ndjson {
+				return ingestOperations(cmd, a, args, authorName, errorsPath, stopOnError)
+			}
+			if errorsPath != "" || stopOnError {
+				return fmt.Errorf("--errors and --stop-on-error need --ndjson")
+			}
+
+			root := a.basePath
+			if len(args) == 0 {
+				args = []string{"."}
+			}
+
+			var files []string
+			var err error
+			if fromGit {
+				files, err = gitFiles(root)
+			} else {
+				files, err = walkFiles(root, args)
+			}
+			if err != nil {
+				return err
+			}
+
+			defaultAuthor := authorName
+			if defaultAuthor == "" {
+				defaultAuthor = "local-dev"
+			}
+
+			ingested := 0
+			for _, file := range files {
+				origin := fileOrigin{author: defaultAuthor, timestamp: time.Now()}
+				if fromGit {
+					if gitOrigin, err := lastCommit(root, file); err == nil {
+						origin = gitOrigin
+					}
+				}
+
+				ok, err := ingestFile(cmd, a, root, file, origin)
+				if err != nil {
+					return fmt.Errorf("failed to ingest %s: %w", file, err)
+				}
+				if ok {
+					ingested++
+				}
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Ingested %d of %d files\n", ingested, len(files))
+			return nil
+		}),
+	}
+	cmd.Flags().BoolVar(&fromGit, "git", false, "ingest files tracked by git with their last commit as origin")
+	cmd.Flags().StringVar(&authorName, "author", "", "author name for files without git history, or operations naming none")
+	cmd.Flags().BoolVar(&ndjson, "ndjson", false, "read newline-delimited JSON operations instead of files")
+	cmd.Flags().StringVar(&errorsPath, "errors", "", "with --ndjson, file to write rejected records to as JSON lines")
+	cmd.Flags().BoolVar(&stopOnError, "stop-on-error", false, "with --ndjson, stop at the first rejected record")
+
+	return cmd
+}
+
+// ingestOperations applies the operations in each of files, or stdin,
+// reporting progress on stderr as it goes
+func ingestOperations(cmd *cobra.Command, a *app, files []string, authorName, errorsPath string, stopOnError bool) error {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	opts := collaboration.IngestOptions{
+		Author:      operations.NewAuthorID(authorName),
+		StopOnError: stopOnError,
+	}
+	if authorName == "" {
+		opts.Author = operations.NewAuthorID("local-dev")
+	}
+	if errorsPath != "" {
+		file, err := os.Create(errorsPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		opts.Rejected = file
+	}
+
+	var total collaboration.IngestResult
+	for _, name := range files {
+		var r io.Reader = cmd.InOrStdin()
+		label := "stdin"
+		if name != "-" {
+			label = name
+			file, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			r = file
+		}
+
+		opts.Progress = func(progress collaboration.IngestResult) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: %d records, %d applied, %d skipped, %d rejected\n",
+				label, progress.Records, progress.Applied, progress.Skipped, progress.Rejected)
+		}
+		result, err := a.engine.IngestOperations(cmd.Context(), r, opts)
+		total.Records += result.Records
+		total.Applied += result.Applied
+		total.Skipped += result.Skipped
+		total.Rejected += result.Rejected
+		if err != nil {
+			return fmt.Errorf("failed to ingest %s: %w", label, err)
+		}
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Ingested %d of %d operations, %d already stored\n", total.Applied, total.Records, total.Skipped)
+	if total.Rejected > 0 && errorsPath != "" {
+		return fmt.Errorf("%d records rejected, see %s", total.Rejected, errorsPath)
+	} else if total.Rejected > 0 {
+		return fmt.Errorf("%d records rejected", total.Rejected)
+	}
+	return nil
+}
+
+func ingestFile(cmd *cobra.Command, a *app, root, file string, origin fileOrigin) (bool, error) {
+	documentID := filepath.ToSlash(file)
+	if _, err := a.store.GetDocument(cmd.Context(), documentID); err == nil {
+		return false, nil
+	} else if err != storage.ErrDocumentNotFound {
+		return false, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, file))
+	if err != nil {
+		return false, err
+	}
+	if len(data) == 0 {
+		return false, nil
+	}
+
+	content, contentType := string(data), operations.ContentTypeText
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		content, contentType = operations.EncodeBinary(data), operations.ContentTypeBinary
+	}
+
+	author := operations.NewAuthorID(origin.author)
+	op := &operations.Operation{
+		Type:        operations.OpInsert,
+		Position:    operations.GeneratePosition(operations.LogootPosition{}, operations.LogootPosition{}, author),
+		Content:     content,
+		ContentType: contentType,
+		Length:      len(data),
+		Author:      author,
+		Timestamp:   origin.timestamp,
+		Parents:     []operations.OperationID{},
+		Metadata: operations.OperationMeta{
+			SessionID:  "ingest",
+			DocumentID: documentID,
+			Context: map[string]string{
+				"source": "ingest",
+			},
+		},
+	}
+	if origin.commit != "" {
+		op.Metadata.Context["commit"] = origin.commit
+		op.Metadata.Context["commit_message"] = origin.message
+	}
+	op.ID = operations.ComputeID(op)
+
+	if err := a.engine.ProcessOperation(cmd.Context(), op, collaboration.ClientID("cli")); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func walkFiles(root string, paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		start := filepath.Join(root, path)
+		err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				// Skip the store itself and other hidden directories such as .git
+				if name := d.Name(); p != start && strings.HasPrefix(name, ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func gitFiles(root string) ([]string, error) {
+	out, err := exec.Command("git", "-C", root, "ls-files", "-z").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files failed: %w", err)
+	}
+
+	var files []string
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+func lastCommit(root, file string) (fileOrigin, error) {
+	out, err := exec.Command("git", "-C", root, "log", "-1", "--format=%H%x00%an%x00%ct%x00%s", "--", file).Output()
+	if err != nil {
+		return fileOrigin{}, err
+	}
+
+	fields := strings.SplitN(strings.TrimSpace(string(out)), "\x00", 4)
+	if len(fields) != 4 {
+		return fileOrigin{}, fmt.Errorf("no commits for %s", file)
+	}
+
+	unix, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return fileOrigin{}, err
+	}
+
+	return fileOrigin{
+		commit:    fields[0],
+		author:    fields[1],
+		timestamp: time.Unix(unix, 0),
+		message:   fields[3],
+	}, nil
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+	"github.com/jeremytregunna/contextdb/internal/summaries"
+	"github.com/spf13/cobra"
+)
+
+const shortIDLength = 12
+
+func newSearchCommand(withApp appRunner) *cobra.Command {
+	var searchType, author string
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Search operations, conversations and documents",
+		Args:  cobra.ExactArgs(1),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			query := strings.ToLower(args[0])
+			out := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			defer out.Flush()
+
+			if searchType == "" || searchType == "operation" {
+				found := 0
+				err := a.store.ForEachOperationSince(cmd.Context(), time.Time{}, func(op *operations.Operation) error {
+					if found >= limit {
+						return storage.ErrStopIteration
+					}
+					if author != "" && op.Author != operations.NewAuthorID(author) && string(op.Author) != author {
+						return nil
+					}
+					if operations.NormalizeContentType(op.ContentType) == operations.ContentTypeBinary ||
+						!strings.Contains(strings.ToLower(op.Content), query) {
+						return nil
+					}
+
+					found++
+					fmt.Fprintf(out, "operation\t%s\t%s\t%s\n", shortID(string(op.ID)), op.Metadata.DocumentID, firstLine(op.Content))
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			if searchType == "" || searchType == "conversation" {
+				threads, err := a.engine.ConversationManager().SearchConversations(query)
+				if err != nil {
+					return err
+				}
+				for i, thread := range threads {
+					if i >= limit {
+						break
+					}
+					fmt.Fprintf(out, "conversation\t%s\t%s\t%d messages\n", thread.ID, thread.Title, len(thread.Messages))
+				}
+			}
+
+			if searchType == "" || searchType == "code" {
+				documents, err := a.store.ListDocuments(cmd.Context(), false)
+				if err != nil {
+					return err
+				}
+
+				found := 0
+				for _, documentID := range documents {
+					if found >= limit {
+						break
+					}
+					doc, err := a.store.GetDocument(cmd.Context(), documentID)
+					if err != nil {
+						return err
+					}
+					content, _ := doc.RenderContentTypes(operations.ContentTypeText, operations.ContentTypeJSON)
+					for number, line := range strings.Split(content, "\n") {
+						if strings.Contains(strings.ToLower(line), query) {
+							found++
+							fmt.Fprintf(out, "code\t%s:%d\t\t%s\n", documentID, number+1, strings.TrimSpace(line))
+							break
+						}
+					}
+				}
+			}
+
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&searchType, "type", "", "restrict to operation, conversation or code")
+	cmd.Flags().StringVar(&author, "author", "", "only operations by this author name or ID")
+	cmd.Flags().IntVar(&limit, "limit", 20, "maximum results per type")
+
+	return cmd
+}
+
+func newBlameCommand(withApp appRunner) *cobra.Command {
+	return &cobra.Command{
+		Use:   "blame <document>",
+		Short: "Show which operation and author produced each line of a document",
+		Args:  cobra.ExactArgs(1),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			doc, err := a.store.GetDocument(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			// Content lines can contain tabs, so this output uses fixed columns rather than a tabwriter
+			out := cmd.OutOrStdout()
+
+			// Constructs are not line aligned, so carry attribution across the lines each one spans
+			authors := make(map[operations.OperationID]*operations.Operation)
+			for _, pos := range doc.Positions() {
+				construct, err := doc.GetConstruct(pos)
+				if err != nil {
+					continue
+				}
+
+				op, seen := authors[construct.ModifiedBy]
+				if !seen {
+					op, _ = a.store.GetOperation(cmd.Context(), construct.ModifiedBy)
+					authors[construct.ModifiedBy] = op
+				}
+
+				origin := fmt.Sprintf("%-*s %-10s", shortIDLength, "unknown", "")
+				if op != nil {
+					origin = fmt.Sprintf("%-*s %s", shortIDLength, shortID(string(op.Author)), op.Timestamp.Format("2006-01-02"))
+				}
+
+				content := construct.Content
+				if construct.Metadata.ContentType == operations.ContentTypeBinary {
+					content = fmt.Sprintf("<binary, %d bytes base64>", len(content))
+				}
+				for _, line := range strings.SplitAfter(content, "\n") {
+					if line == "" {
+						continue
+					}
+					fmt.Fprintf(out, "%s %s  %s\n", shortID(string(construct.ModifiedBy)), origin, strings.TrimRight(line, "\n"))
+				}
+			}
+			return nil
+		}),
+	}
+}
+
+func newConversationCommand(withApp appRunner) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "conversation",
+		Aliases: []string{"conv"},
+		Short:   "Inspect conversation threads",
+	}
+
+	var status string
+	var tags, labels []string
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List conversation threads, most recently updated first",
+		Args:  cobra.NoArgs,
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			threads, err := a.engine.ConversationManager().ListConversations(context.ConversationFilter{
+				Tags:   tags,
+				Labels: labels,
+				Status: context.ThreadStatus(status),
+			})
+			if err != nil {
+				return err
+			}
+
+			out := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			defer out.Flush()
+
+			for _, thread := range threads {
+				fmt.Fprintf(out, "%s\t%s\t%s\t%d messages\t%s\t%s\n",
+					thread.ID, thread.Status, thread.Title, len(thread.Messages), thread.UpdatedAt.Format(time.RFC3339), strings.Join(thread.Tags, ","))
+			}
+			return nil
+		}),
+	}
+	list.Flags().StringVar(&status, "status", "", "only threads with this status (open, resolved, archived, pinned)")
+	list.Flags().StringSliceVar(&tags, "tag", nil, "only threads with all of these tags")
+	list.Flags().StringSliceVar(&labels, "label", nil, "only threads with all of these labels")
+
+	var asLabels bool
+	tag := &cobra.Command{
+		Use:   "tag <thread-id> <tag>...",
+		Short: "Tag a conversation thread",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			manager := a.engine.ConversationManager()
+			add := manager.AddTags
+			if asLabels {
+				add = manager.AddLabels
+			}
+
+			thread, err := add(context.ThreadID(args[0]), args[1:]...)
+			if err != nil {
+				return err
+			}
+			printTags(cmd, thread)
+			return nil
+		}),
+	}
+	tag.Flags().BoolVar(&asLabels, "label", false, "add labels instead of tags")
+
+	untag := &cobra.Command{
+		Use:   "untag <thread-id> <tag>...",
+		Short: "Remove tags from a conversation thread",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			manager := a.engine.ConversationManager()
+			remove := manager.RemoveTags
+			if asLabels {
+				remove = manager.RemoveLabels
+			}
+
+			thread, err := remove(context.ThreadID(args[0]), args[1:]...)
+			if err != nil {
+				return err
+			}
+			printTags(cmd, thread)
+			return nil
+		}),
+	}
+	untag.Flags().BoolVar(&asLabels, "label", false, "remove labels instead of tags")
+
+	tagCounts := &cobra.Command{
+		Use:   "tags",
+		Short: "List tags and labels with how many threads carry each",
+		Args:  cobra.NoArgs,
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			out := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			defer out.Flush()
+
+			manager := a.engine.ConversationManager()
+			for _, count := range manager.TagCounts() {
+				fmt.Fprintf(out, "tag\t%s\t%d\n", count.Name, count.Count)
+			}
+			for _, count := range manager.LabelCounts() {
+				fmt.Fprintf(out, "label\t%s\t%d\n", count.Name, count.Count)
+			}
+			return nil
+		}),
+	}
+
+	show := &cobra.Command{
+		Use:   "show <thread-id>",
+		Short: "Show a conversation thread and its messages",
+		Args:  cobra.ExactArgs(1),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			thread, err := a.engine.ConversationManager().GetConversation(context.ThreadID(args[0]))
+			if err != nil {
+				return err
+			}
+
+			w := cmd.OutOrStdout()
+			fmt.Fprintf(w, "%s [%s]\n", thread.Title, thread.Status)
+			fmt.Fprintf(w, "Anchored to operation %s\n", thread.AnchorAddress.OperationID)
+			printTags(cmd, thread)
+			printSummary(cmd, thread)
+			fmt.Fprintln(w)
+			for _, msg := range thread.Messages {
+				fmt.Fprintf(w, "%s  %s (%s)\n  %s\n\n",
+					msg.Timestamp.Format(time.RFC3339), shortID(string(msg.AuthorID)), msg.MessageType, msg.Content)
+			}
+			return nil
+		}),
+	}
+
+	var sentences int
+	summarize := &cobra.Command{
+		Use:   "summarize <thread-id>",
+		Short: "Summarize a conversation thread with its most telling sentences",
+		Long: `Summarize a conversation thread by picking its most telling sentences, and
+keep the summary on the thread, replacing any it had. A server configured with
+a summaries provider also summarizes threads as they are resolved, and may use
+a language model instead.`,
+		Args: cobra.ExactArgs(1),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			service := summaries.NewService(summaries.NewExtractiveSummarizer(sentences), a.engine.ConversationManager())
+			thread, err := service.Summarize(cmd.Context(), context.ThreadID(args[0]))
+			if err != nil {
+				return err
+			}
+			printSummary(cmd, thread)
+			return nil
+		}),
+	}
+	summarize.Flags().IntVar(&sentences, "sentences", summaries.DefaultSentences, "how many sentences to pick")
+
+	cmd.AddCommand(list, show, tag, untag, tagCounts, summarize, newConversationImportCommand(withApp))
+	return cmd
+}
+
+func printSummary(cmd *cobra.Command, thread *context.ConversationThread) {
+	if thread.Summary == nil {
+		return
+	}
+	stale := ""
+	if thread.SummaryStale() {
+		stale = ", older than the latest messages"
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Summary (%s%s): %s\n", thread.Summary.Summarizer, stale, thread.Summary.Text)
+}
+
+func printTags(cmd *cobra.Command, thread *context.ConversationThread) {
+	w := cmd.OutOrStdout()
+	if len(thread.Tags) > 0 {
+		fmt.Fprintf(w, "Tags: %s\n", strings.Join(thread.Tags, ", "))
+	}
+	if len(thread.Metadata.Labels) > 0 {
+		fmt.Fprintf(w, "Labels: %s\n", strings.Join(thread.Metadata.Labels, ", "))
+	}
+}
+
+func shortID(id string) string {
+	if len(id) > shortIDLength {
+		return id[:shortIDLength]
+	}
+	return id
+}
+
+func firstLine(content string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	return line
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+func newKeysCommand(withApp appRunner) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage API keys and whether the API requires them",
+	}
+
+	var author string
+	var permissions []string
+	var expiresIn time.Duration
+	create := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create an API key and print it once",
+		Args:  cobra.ExactArgs(1),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			perms := make([]auth.Permission, len(permissions))
+			for i, perm := range permissions {
+				perms[i] = auth.Permission(perm)
+			}
+
+			authorID := operations.NewAuthorID(args[0])
+			if author != "" {
+				authorID = operations.NewAuthorID(author)
+			}
+
+			var expiry *time.Duration
+			if expiresIn > 0 {
+				expiry = &expiresIn
+			}
+
+			key, err := a.auth.CreateAPIKey(args[0], authorID, perms, expiry)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), key)
+			fmt.Fprintln(cmd.ErrOrStderr(), "Store this key securely - it won't be shown again.")
+			return nil
+		}),
+	}
+	create.Flags().StringVar(&author, "author", "", "author name operations made with this key are attributed to, defaults to the key name")
+	create.Flags().StringSliceVar(&permissions, "permission", []string{string(auth.PermissionAll)}, "permission to grant, repeatable")
+	create.Flags().DurationVar(&expiresIn, "expires-in", 0, "key lifetime, e.g. 720h, zero never expires")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List API keys",
+		Args:  cobra.NoArgs,
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			out := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			defer out.Flush()
+
+			fmt.Fprintf(out, "auth required: %t\n", a.auth.IsAuthRequired())
+			for _, key := range a.auth.ListAPIKeys() {
+				perms := make([]string, len(key.Permissions))
+				for i, perm := range key.Permissions {
+					perms[i] = string(perm)
+				}
+				fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", key.ID, key.Name, strings.Join(perms, ","), key.CreatedAt.Format(time.RFC3339))
+			}
+			return nil
+		}),
+	}
+
+	revoke := &cobra.Command{
+		Use:   "revoke <key-id>",
+		Short: "Revoke an API key",
+		Args:  cobra.ExactArgs(1),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			return a.auth.RevokeAPIKey(args[0])
+		}),
+	}
+
+	var grace time.Duration
+	rotate := &cobra.Command{
+		Use:   "rotate <key-id>",
+		Short: "Replace an API key's secret and print the new one once",
+		Long: `Rotate replaces an API key's secret, keeping its ID, author and permissions.
+The old secret stops working at once unless --grace keeps it working for a
+while, at most a week, so clients can switch to the new one without downtime.`,
+		Args: cobra.ExactArgs(1),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			key, rotation, err := a.auth.RotateAPIKey(args[0], grace, "")
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), key)
+			fmt.Fprintln(cmd.ErrOrStderr(), "Store this key securely - it won't be shown again.")
+			if grace > 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "The old secret works until %s.\n", rotation.PreviousExpiresAt.Format(time.RFC3339))
+			}
+			return nil
+		}),
+	}
+	rotate.Flags().DurationVar(&grace, "grace", 0, "how long the old secret keeps working, e.g. 24h")
+
+	require := &cobra.Command{
+		Use:       "require <on|off>",
+		Short:     "Turn API key authentication on or off",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"on", "off"},
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			switch args[0] {
+			case "on":
+				return a.auth.EnableAuth()
+			case "off":
+				return a.auth.DisableAuth()
+			default:
+				return fmt.Errorf("expected on or off, got %q", args[0])
+			}
+		}),
+	}
+
+	cmd.AddCommand(create, list, rotate, revoke, require)
+	return cmd
+}
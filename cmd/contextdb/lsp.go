@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jeremytregunna/contextdb/internal/lsp"
+	"github.com/spf13/cobra"
+)
+
+func newLSPCommand(basePath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "lsp",
+		Short: "Run a language server showing operations and conversations in your editor",
+		Long: `Lsp speaks the Language Server Protocol over stdin and stdout. Hovering a line
+shows the operation that created it, its intent and the conversations anchored
+to it, and code lenses mark where each operation's lines and each conversation
+start. Files are matched to documents by their path relative to --path, as
+ingest names them.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := openApp(*basePath)
+			if err != nil {
+				return err
+			}
+			// The server only reads, so closing without saving keeps it from
+			// overwriting conversations a running API server saved meanwhile
+			defer a.store.Close()
+
+			server, err := lsp.NewServer(a.basePath, a.engine, a.store, lsp.WithRefresh(a.loadConversations))
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return server.Serve(ctx, cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+}
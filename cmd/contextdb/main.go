@@ -0,0 +1,69 @@
+// Command contextdb works with a local .context store: serving the API,
+// ingesting files, searching and inspecting history without a running server.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	var basePath string
+
+	root := &cobra.Command{
+		Use:           "contextdb",
+		Short:         "Semantic, operation-based history for code and the conversations around it",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	root.PersistentFlags().StringVarP(&basePath, "path", "C", ".", "directory containing the .context store")
+
+	// withApp opens the store for commands that need it and always closes it afterwards
+	var withApp appRunner = func(run func(cmd *cobra.Command, a *app, args []string) error) func(*cobra.Command, []string) error {
+		return func(cmd *cobra.Command, args []string) error {
+			a, err := openApp(basePath)
+			if err != nil {
+				return err
+			}
+
+			runErr := run(cmd, a, args)
+			if closeErr := a.close(); runErr == nil {
+				runErr = closeErr
+			}
+			return runErr
+		}
+	}
+
+	root.AddCommand(
+		newInitCommand(&basePath),
+		newServeCommand(&basePath),
+		newLSPCommand(&basePath),
+		newMCPCommand(&basePath),
+		newIngestCommand(withApp),
+		newSearchCommand(withApp),
+		newBlameCommand(withApp),
+		newConversationCommand(withApp),
+		newDecisionCommand(withApp),
+		newExportCommand(withApp),
+		newImportCommand(withApp),
+		newCheckoutCommand(withApp),
+		newKeysCommand(withApp),
+		newReviewCommand(withApp),
+		newPeersCommand(withApp),
+		newBackupCommand(&basePath, withApp),
+		newFsckCommand(withApp),
+	)
+
+	return root
+}
+
+type appRunner func(run func(cmd *cobra.Command, a *app, args []string) error) func(*cobra.Command, []string) error
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/mcp"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+func newMCPCommand(basePath *string) *cobra.Command {
+	var repository, author string
+
+	cmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Serve the store to AI agents over the Model Context Protocol",
+		Long: `Mcp speaks the Model Context Protocol over stdin and stdout, offering the tools
+search_context, get_document_history, get_operation_context and
+create_conversation. Register it with an MCP client as the command
+"contextdb mcp -C <repository>".`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := openApp(*basePath)
+			if err != nil {
+				return err
+			}
+			// Conversations are saved after each change instead of on close so a
+			// long session doesn't overwrite what a running API server saved meanwhile
+			defer a.store.Close()
+
+			if repository == "" {
+				root, err := filepath.Abs(a.basePath)
+				if err != nil {
+					return err
+				}
+				repository = filepath.Base(root)
+			}
+
+			server := mcp.NewServer(a.engine, a.store,
+				mcp.WithRepository(addressing.RepositoryID(repository)),
+				mcp.WithAuthor(operations.AuthorID(author)),
+				mcp.WithRefresh(a.loadConversations),
+				mcp.WithSave(a.saveConversations),
+			)
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return server.Serve(ctx, cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVar(&repository, "repository", "", "repository name in conversation addresses, the directory name by default")
+	cmd.Flags().StringVar(&author, "author", "mcp", "author of conversations whose tool call doesn't name one")
+
+	return cmd
+}
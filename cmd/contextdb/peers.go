@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/replication"
+	"github.com/spf13/cobra"
+)
+
+func newPeersCommand(withApp appRunner) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "peers",
+		Short: "Replicate the operation log with other ContextDB nodes",
+	}
+
+	var apiKey string
+	sync := &cobra.Command{
+		Use:   "sync <url>",
+		Short: "Exchange operations with the node served at url",
+		Long: `Sync pulls the operations the peer has and this store lacks, applying them as
+if they had been made here, then pushes the operations the peer lacks. Both
+sides remember the other's heads and how many operations were exchanged.
+
+The peer's API key needs the replicate permission and defaults to
+$CONTEXTDB_API_KEY.`,
+		Args: cobra.ExactArgs(1),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			if apiKey == "" {
+				apiKey = os.Getenv("CONTEXTDB_API_KEY")
+			}
+			peer, err := replication.NewHTTPPeer(args[0], apiKey)
+			if err != nil {
+				return err
+			}
+			node, err := replication.NewNode(a.basePath, a.engine, a.store)
+			if err != nil {
+				return err
+			}
+
+			report, err := node.Sync(cmd.Context(), peer, args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "synced with %s: %d received, %d sent\n", report.NodeID, report.Received, report.Sent)
+			return nil
+		}),
+	}
+	sync.Flags().StringVar(&apiKey, "api-key", "", "API key for the peer")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List the nodes this store has synced with",
+		Args:  cobra.NoArgs,
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			node, err := replication.NewNode(a.basePath, a.engine, a.store)
+			if err != nil {
+				return err
+			}
+
+			out := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			defer out.Flush()
+
+			fmt.Fprintf(out, "node: %s\n", node.ID())
+			for _, peer := range node.Peers() {
+				address := peer.Address
+				if address == "" {
+					address = "-"
+				}
+				fmt.Fprintf(out, "%s\t%s\t%s\t%d received\t%d sent\t%s\n", peer.NodeID, address,
+					peer.LastSync.Format(time.RFC3339), peer.Received, peer.Sent, peer.LastError)
+			}
+			return nil
+		}),
+	}
+
+	cmd.AddCommand(sync, list)
+	return cmd
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/reviewsync"
+	"github.com/spf13/cobra"
+)
+
+func newReviewCommand(withApp appRunner) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "review",
+		Short: "Mirror conversations into pull request review comments",
+	}
+
+	var provider, token, apiURL string
+	sync := &cobra.Command{
+		Use:   "sync <repository> <number>",
+		Short: "Sync conversations with a GitHub pull request or GitLab merge request",
+		Long: `Sync copies review threads and replies on the pull request into conversations,
+then starts review threads for open conversations anchored to files the pull
+request changes and posts their new messages as replies. Resolving a thread on
+either side resolves it on the other where the provider allows it.
+
+The repository is owner/name on GitHub and the project path or ID on GitLab.
+The token defaults to $GITHUB_TOKEN or $GITLAB_TOKEN.`,
+		Args: cobra.ExactArgs(2),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			number, err := strconv.Atoi(args[1])
+			if err != nil || number <= 0 {
+				return fmt.Errorf("invalid pull request number %q", args[1])
+			}
+
+			if token == "" {
+				token = os.Getenv(strings.ToUpper(provider) + "_TOKEN")
+			}
+			if token == "" {
+				return fmt.Errorf("no token given, use --token or $%s_TOKEN", strings.ToUpper(provider))
+			}
+
+			client, err := reviewsync.NewProvider(provider, token, apiURL)
+			if err != nil {
+				return err
+			}
+			syncer, err := reviewsync.NewSyncer(a.basePath, client, a.engine, a.store)
+			if err != nil {
+				return err
+			}
+
+			report, err := syncer.Sync(cmd.Context(), reviewsync.PullRequest{Repository: args[0], Number: number})
+			if err != nil {
+				return err
+			}
+
+			w := cmd.OutOrStdout()
+			fmt.Fprintf(w, "threads: %d pushed, %d pulled, %d resolved\n", report.ThreadsPushed, report.ThreadsPulled, report.ThreadsResolved)
+			fmt.Fprintf(w, "replies: %d pushed, %d pulled\n", report.RepliesPushed, report.RepliesPulled)
+			for _, skipped := range report.Skipped {
+				id := string(skipped.ThreadID)
+				if id == "" {
+					id = provider + " thread " + skipped.RemoteThreadID
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "skipped %s: %s\n", id, skipped.Reason)
+			}
+			return nil
+		}),
+	}
+	sync.Flags().StringVar(&provider, "provider", "github", "review host, github or gitlab")
+	sync.Flags().StringVar(&token, "token", "", "API token with permission to read and write review comments")
+	sync.Flags().StringVar(&apiURL, "api-url", "", "API root for GitHub Enterprise or self-hosted GitLab")
+
+	cmd.AddCommand(sync)
+	return cmd
+}
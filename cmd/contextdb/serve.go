@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jeremytregunna/contextdb/internal/server"
+	"github.com/spf13/cobra"
+)
+
+func newInitCommand(basePath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "init",
+		Short: "Create a .context store in the target directory",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			a, err := openApp(*basePath)
+			if err != nil {
+				return err
+			}
+			defer a.close()
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Initialized ContextDB store in %s\n", a.contextPath())
+			return nil
+		},
+	}
+}
+
+func newServeCommand(basePath *string) *cobra.Command {
+	var addr string
+	var readOnly bool
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the HTTP API for the local store",
+		Long:  "Serve the HTTP API for the local store. Use contextdb-server for TLS, CORS and config file support.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config := server.DefaultConfig()
+			config.Listen = addr
+			config.Storage.Path = *basePath
+			config.Storage.ReadOnly = readOnly
+
+			srv, err := server.New(config)
+			if err != nil {
+				return err
+			}
+
+			// Run saves conversations and closes the store once the signal arrives
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			fmt.Fprintf(cmd.OutOrStdout(), "ContextDB listening on %s\n", addr)
+			return srv.Run(ctx)
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "localhost:8080", "address to listen on")
+	cmd.Flags().BoolVar(&readOnly, "read-only", false, "serve the store without changing it, refusing writes")
+
+	return cmd
+}
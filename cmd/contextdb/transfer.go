@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/spf13/cobra"
+)
+
+// exportRecord is one line of an export file. Operations come first in
+// timestamp order so an import can replay them to rebuild documents.
+type exportRecord struct {
+	Kind         string                      `json:"kind"`
+	Operation    *operations.Operation       `json:"operation,omitempty"`
+	Conversation *context.ConversationThread `json:"conversation,omitempty"`
+}
+
+const (
+	recordOperation    = "operation"
+	recordConversation = "conversation"
+)
+
+func newExportCommand(withApp appRunner) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write operations and conversations as JSON lines",
+		Args:  cobra.NoArgs,
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			w := cmd.OutOrStdout()
+			if output != "" && output != "-" {
+				file, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				w = file
+			}
+
+			buffered := bufio.NewWriter(w)
+			encoder := json.NewEncoder(buffered)
+
+			count := 0
+			err := a.store.ForEachOperationSince(cmd.Context(), time.Time{}, func(op *operations.Operation) error {
+				count++
+				return encoder.Encode(exportRecord{Kind: recordOperation, Operation: op})
+			})
+			if err != nil {
+				return err
+			}
+
+			threads := a.engine.ConversationManager().Snapshot()
+			for _, thread := range threads {
+				if err := encoder.Encode(exportRecord{Kind: recordConversation, Conversation: thread}); err != nil {
+					return err
+				}
+			}
+
+			if err := buffered.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d operations and %d conversations\n", count, len(threads))
+			return nil
+		}),
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write, defaults to stdout")
+
+	return cmd
+}
+
+func newImportCommand(withApp appRunner) *cobra.Command {
+	return &cobra.Command{
+		Use:   "import <file>",
+		Short: "Replay an export into the local store",
+		Long: `Import replays exported operations through the collaboration engine, so
+documents are rebuilt as they were, and restores conversations. Importing the
+same file twice is safe: operations and threads are keyed by ID.`,
+		Args: cobra.ExactArgs(1),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			var r io.Reader = cmd.InOrStdin()
+			if args[0] != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				r = file
+			}
+
+			decoder := json.NewDecoder(bufio.NewReader(r))
+			var threads []*context.ConversationThread
+			opCount := 0
+
+			for {
+				var record exportRecord
+				if err := decoder.Decode(&record); err == io.EOF {
+					break
+				} else if err != nil {
+					return fmt.Errorf("failed to read record: %w", err)
+				}
+
+				switch record.Kind {
+				case recordOperation:
+					if record.Operation == nil {
+						return fmt.Errorf("operation record without operation")
+					}
+					if err := a.engine.ProcessOperation(cmd.Context(), record.Operation, collaboration.ClientID("cli")); err != nil {
+						return fmt.Errorf("failed to import operation %s: %w", record.Operation.ID, err)
+					}
+					opCount++
+				case recordConversation:
+					if record.Conversation != nil {
+						threads = append(threads, record.Conversation)
+					}
+				default:
+					return fmt.Errorf("unknown record kind %q", record.Kind)
+				}
+			}
+
+			a.engine.ConversationManager().Restore(threads)
+			fmt.Fprintf(cmd.OutOrStdout(), "Imported %d operations and %d conversations\n", opCount, len(threads))
+			return nil
+		}),
+	}
+}
+
+func newCheckoutCommand(withApp appRunner) *cobra.Command {
+	var branch, at, prefix string
+	var version uint64
+
+	cmd := &cobra.Command{
+		Use:   "checkout <dir>",
+		Short: "Write documents out as plain files",
+		Long: `Checkout renders every document into dir as a plain file at its path, so the
+store becomes a normal source tree again. With --at or --version documents are
+rendered as they stood then; documents with no content at that point are left
+out, as are documents whose paths would land outside dir.`,
+		Args: cobra.ExactArgs(1),
+		RunE: withApp(func(cmd *cobra.Command, a *app, args []string) error {
+			opts := collaboration.ExportOptions{Branch: branch, Prefix: prefix, Version: version}
+			if at != "" {
+				t, err := time.Parse(time.RFC3339, at)
+				if err != nil {
+					return fmt.Errorf("invalid --at: %w", err)
+				}
+				opts.At = t
+			}
+
+			export, err := a.engine.ExportFiles(cmd.Context(), args[0], opts)
+			if err != nil {
+				return err
+			}
+			for _, skipped := range export.Skipped {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Skipped %s: %s\n", skipped.DocumentID, skipped.Reason)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote %d files to %s\n", len(export.Files), args[0])
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&branch, "branch", "", "branch to render, defaults to main")
+	cmd.Flags().StringVar(&at, "at", "", "render documents as of this RFC 3339 time")
+	cmd.Flags().Uint64Var(&version, "version", 0, "render each document at no later than this version")
+	cmd.Flags().StringVar(&prefix, "prefix", "", "only write documents whose paths start with this")
+
+	return cmd
+}
@@ -0,0 +1,97 @@
+// Command agents walks through two AI agents and a human collaborating on a
+// single document through the collaboration engine: the agents write code,
+// one asks a question anchored to the other's change, the human answers and
+// records a decision, and both agents read back the context before editing.
+package main
+
+import (
+	gocontext "context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/examples/agents/toolkit"
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const documentID = "billing/invoice.go"
+
+func main() {
+	ctx := gocontext.Background()
+
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		log.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	engine := collaboration.NewCollaborationEngine(store)
+	repo := addressing.RepositoryID("example")
+
+	planner := toolkit.NewAgent("planner-agent", engine, repo)
+	reviewer := toolkit.NewAgent("reviewer-agent", engine, repo)
+	human := toolkit.NewAgent("alice", engine, repo)
+
+	// 1. The planner agent writes the first version of the function
+	signature, err := planner.Append(ctx, documentID, "func InvoiceTotal(items []Item) int {\n", "feature")
+	must(err)
+	_, err = planner.Append(ctx, documentID, "\treturn sum(items)\n}\n", "feature")
+	must(err)
+	fmt.Printf("planner wrote %s\n", short(signature.ID))
+
+	// 2. The reviewer reads the context behind that change and asks about it
+	opContext, err := reviewer.FetchContext(signature.ID)
+	must(err)
+	fmt.Printf("reviewer sees: %s\n", opContext.Summary)
+
+	thread, err := reviewer.Ask(signature, "Currency handling", "Should totals be in cents to avoid float rounding?")
+	must(err)
+	fmt.Printf("reviewer opened thread %s\n", thread.ID)
+
+	// 3. The human answers and records the decision on the same anchor
+	_, err = human.Reply(thread.ID, "Yes, store cents as int64 everywhere.", context.MsgAnswer)
+	must(err)
+	_, err = human.Reply(thread.ID, "Decision: monetary amounts are int64 cents.", context.MsgDecision)
+	must(err)
+
+	// 4. The planner checks discussions on its change before editing again,
+	// and applies the fix through SafeEdit so a concurrent edit would abort it
+	discussions, err := planner.Discussions(signature)
+	must(err)
+	for _, d := range discussions {
+		for _, msg := range d.GetMessagesByType(context.MsgDecision) {
+			fmt.Printf("planner found decision: %s\n", msg.Content)
+		}
+	}
+
+	_, err = planner.SafeEdit(ctx, documentID, func(content string, positions []operations.LogootPosition) ([]*operations.Operation, error) {
+		if !strings.Contains(content, "int {") {
+			return nil, nil
+		}
+		return []*operations.Operation{
+			planner.NewDelete(documentID, signature.Position, "refactor"),
+			planner.NewInsert(documentID, operations.LogootPosition{}, positions[0], "func InvoiceTotal(items []Item) int64 {\n", "refactor"),
+		}, nil
+	})
+	must(err)
+
+	doc, err := engine.GetDocumentState(ctx, documentID)
+	must(err)
+	rendered, err := doc.Render()
+	must(err)
+	fmt.Printf("\nfinal %s:\n%s", documentID, rendered)
+}
+
+func must(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func short(id operations.OperationID) string {
+	return string(id)[:12]
+}
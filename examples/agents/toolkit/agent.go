@@ -0,0 +1,159 @@
+// Package toolkit wraps the collaboration engine with the handful of calls an
+// AI agent needs: submitting edits, reading the context behind existing code
+// and holding anchored Q&A threads with other participants.
+package toolkit
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/ids"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+type Agent struct {
+	Name      string
+	AuthorID  operations.AuthorID
+	SessionID string
+	engine    *collaboration.CollaborationEngine
+	repo      addressing.RepositoryID
+}
+
+func NewAgent(name string, engine *collaboration.CollaborationEngine, repo addressing.RepositoryID) *Agent {
+	return &Agent{
+		Name:      name,
+		AuthorID:  operations.NewAuthorID(name),
+		SessionID: ids.NewWithPrefix("session"),
+		engine:    engine,
+		repo:      repo,
+	}
+}
+
+// Append inserts content at the end of a document and returns the applied operation
+func (a *Agent) Append(ctx gocontext.Context, documentID, content, intent string) (*operations.Operation, error) {
+	doc, err := a.engine.GetDocumentState(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var left operations.LogootPosition
+	if positions := doc.Positions(); len(positions) > 0 {
+		left = positions[len(positions)-1]
+	}
+
+	op := a.newOperation(documentID, operations.OpInsert, operations.GeneratePosition(left, operations.LogootPosition{}, a.AuthorID), content, intent)
+	if _, err := a.engine.ProcessOperationAt(ctx, op, collaboration.ClientID(a.SessionID), nil, collaboration.AssignParents()); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// SafeEdit reads the document, lets plan produce operations against that
+// snapshot and only submits them if nobody else changed the document meanwhile.
+// Agents should retry on ErrDocumentChanged with a fresh plan. If another
+// edit lands part way through, the operations already applied are returned
+// alongside the error.
+func (a *Agent) SafeEdit(ctx gocontext.Context, documentID string, plan func(content string, positions []operations.LogootPosition) ([]*operations.Operation, error)) ([]*operations.Operation, error) {
+	doc, err := a.engine.GetDocumentState(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	version := doc.CurrentVersion()
+	content, err := doc.Render()
+	if err != nil {
+		return nil, err
+	}
+
+	ops, err := plan(content, doc.Positions())
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, ErrEmptyEdit
+	}
+
+	// Each operation is conditional on the version the previous one produced,
+	// so nobody else's edit can land in the middle of the plan
+	for i, op := range ops {
+		version, err = a.engine.ProcessOperationAt(ctx, op, collaboration.ClientID(a.SessionID), &version, collaboration.AssignParents())
+		if errors.Is(err, collaboration.ErrVersionConflict) {
+			return ops[:i], fmt.Errorf("%w: %w", ErrDocumentChanged, err)
+		}
+		if err != nil {
+			return ops[:i], fmt.Errorf("failed to apply planned operation: %w", err)
+		}
+	}
+	return ops, nil
+}
+
+// NewInsert builds an insert operation for use inside a SafeEdit plan
+func (a *Agent) NewInsert(documentID string, left, right operations.LogootPosition, content, intent string) *operations.Operation {
+	return a.newOperation(documentID, operations.OpInsert, operations.GeneratePosition(left, right, a.AuthorID), content, intent)
+}
+
+// NewDelete builds a delete operation for use inside a SafeEdit plan
+func (a *Agent) NewDelete(documentID string, pos operations.LogootPosition, intent string) *operations.Operation {
+	return a.newOperation(documentID, operations.OpDelete, pos, "", intent)
+}
+
+// FetchContext returns everything known about why an operation happened
+func (a *Agent) FetchContext(opID operations.OperationID) (*context.OperationContext, error) {
+	return a.engine.GetOperationContext(opID)
+}
+
+// Ask opens a question thread anchored to the content created by op
+func (a *Agent) Ask(op *operations.Operation, title, question string) (*context.ConversationThread, error) {
+	addr, err := a.engine.CreateStableAddress(a.repo, op.ID, addressing.PositionRange{Start: op.Position, End: op.Position})
+	if err != nil {
+		return nil, err
+	}
+
+	thread, err := a.engine.CreateConversation(addr, a.AuthorID, title, question)
+	if err != nil {
+		return nil, err
+	}
+
+	// The opening message is a comment by default, mark it as the question it is
+	if _, err := a.engine.AddMessageToConversation(thread.ID, a.AuthorID, question, context.MsgQuestion); err != nil {
+		return nil, err
+	}
+	return a.engine.GetConversation(thread.ID)
+}
+
+// Reply adds a message of the given type to an existing thread
+func (a *Agent) Reply(threadID context.ThreadID, content string, msgType context.MessageType) (*context.Message, error) {
+	return a.engine.AddMessageToConversation(threadID, a.AuthorID, content, msgType)
+}
+
+// Discussions returns the threads anchored to the content created by op
+func (a *Agent) Discussions(op *operations.Operation) ([]*context.ConversationThread, error) {
+	addr := addressing.NewStableAddress(a.repo, op.ID, addressing.PositionRange{Start: op.Position, End: op.Position})
+	return a.engine.GetConversationsByAddress(addr)
+}
+
+func (a *Agent) newOperation(documentID string, opType operations.OperationType, pos operations.LogootPosition, content, intent string) *operations.Operation {
+	now := time.Now()
+	op := &operations.Operation{
+		Type:      opType,
+		Position:  pos,
+		Content:   content,
+		Author:    a.AuthorID,
+		Timestamp: now,
+		Metadata: operations.OperationMeta{
+			SessionID:  a.SessionID,
+			Intent:     intent,
+			DocumentID: documentID,
+			Context: map[string]string{
+				"agent": a.Name,
+			},
+		},
+	}
+	op.ID = operations.ComputeID(op)
+	return op
+}
@@ -0,0 +1,8 @@
+package toolkit
+
+import "errors"
+
+var (
+	ErrDocumentChanged = errors.New("document changed since it was read")
+	ErrEmptyEdit       = errors.New("edit produced no operations")
+)
@@ -35,7 +35,7 @@ func (addr StableAddress) String() string {
 	return fmt.Sprintf("%s://%s/%x/%s-%s",
 		addr.Scheme,
 		addr.Repository,
-		addr.OperationID[:8], // Show first 8 bytes for readability
+		prefix(string(addr.OperationID), 8), // Show first 8 bytes for readability
 		addr.PositionRange.Start.String(),
 		addr.PositionRange.End.String(),
 	)
@@ -46,13 +46,22 @@ func (addr StableAddress) Key() AddressKey {
 	startKey := addr.PositionRange.Start.Key()
 	endKey := addr.PositionRange.End.Key()
 	key := fmt.Sprintf("%x:%x:%x",
-		addr.OperationID[:16],
-		startKey[:8],
-		endKey[:8],
+		prefix(string(addr.OperationID), 16),
+		prefix(string(startKey), 8),
+		prefix(string(endKey), 8),
 	)
 	return AddressKey(key)
 }
 
+// prefix returns up to the first n bytes of s, so addresses with short or
+// missing IDs still produce a key instead of panicking
+func prefix(s string, n int) string {
+	if len(s) < n {
+		return s
+	}
+	return s[:n]
+}
+
 func (addr StableAddress) IsValid() bool {
 	return addr.Scheme == "contextdb" &&
 		addr.Repository != "" &&
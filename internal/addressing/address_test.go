@@ -1,6 +1,7 @@
 package addressing
 
 import (
+	"encoding/json"
 	"math/big"
 	"testing"
 
@@ -144,3 +145,46 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestStableAddress_FragmentLines(t *testing.T) {
+	tests := []struct {
+		fragment    string
+		first, last int
+		ok          bool
+	}{
+		{LineFragment(3, 5), 3, 5, true},
+		{"lines:2-2", 2, 2, true},
+		{"lines:5-3", 0, 0, false},
+		{"lines:0-1", 0, 0, false},
+		{"section-2", 0, 0, false},
+		{"", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		addr := StableAddress{Fragment: tt.fragment}
+		first, last, ok := addr.FragmentLines()
+		if first != tt.first || last != tt.last || ok != tt.ok {
+			t.Errorf("Fragment %q: expected %d-%d %v, got %d-%d %v", tt.fragment, tt.first, tt.last, tt.ok, first, last, ok)
+		}
+	}
+}
+
+// FuzzStableAddress_UnmarshalJSON feeds the addresses API requests carry,
+// checking what decodes can be checked, keyed and written without panicking
+func FuzzStableAddress_UnmarshalJSON(f *testing.F) {
+	f.Add([]byte(`{"scheme":"contextdb","repository":"repo","operation_id":"abcdef0123456789","position_range":{"start":{"segments":[{"value":1,"author":"a"}]},"end":{"segments":[{"value":2,"author":"a"}]}}}`))
+	f.Add([]byte(`{"scheme":"contextdb","operation_id":"ab","position_range":{"start":{},"end":{"segments":null}},"fragment":"function:main"}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var addr StableAddress
+		if err := json.Unmarshal(data, &addr); err != nil {
+			return
+		}
+		addr.IsValid()
+		addr.Key()
+		if addr.String() == "" {
+			t.Errorf("Expected %+v to format as something", addr)
+		}
+		addr.PositionRange.IsEmpty()
+		addr.PositionRange.Overlaps(addr.PositionRange)
+	})
+}
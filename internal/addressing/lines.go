@@ -0,0 +1,77 @@
+package addressing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+// lineFragmentPrefix marks a fragment holding lines relative to the start of
+// the address's range. A document ingested as one construct per file would
+// otherwise only ever resolve to the whole file.
+const lineFragmentPrefix = "lines:"
+
+// LineFragment narrows an address to lines first to last, counted from 1 at
+// the first line of its range
+func LineFragment(first, last int) string {
+	return fmt.Sprintf("%s%d-%d", lineFragmentPrefix, first, last)
+}
+
+// FragmentLines returns the relative lines recorded with LineFragment
+func (addr StableAddress) FragmentLines() (int, int, bool) {
+	if !strings.HasPrefix(addr.Fragment, lineFragmentPrefix) {
+		return 0, 0, false
+	}
+
+	var first, last int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(addr.Fragment, lineFragmentPrefix), "%d-%d", &first, &last); err != nil {
+		return 0, 0, false
+	}
+	if first < 1 || last < first {
+		return 0, 0, false
+	}
+	return first, last, true
+}
+
+// LinesIn returns the 1-based lines of doc that posRange, usually the
+// address's current range, covers, narrowed by the address's line fragment
+func (addr StableAddress) LinesIn(doc *positioning.Document, posRange PositionRange) (int, int, error) {
+	first, last, err := doc.LineRange(posRange.Start, posRange.End)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if relFirst, relLast, ok := addr.FragmentLines(); ok {
+		base := first
+		first = min(base+relFirst-1, last)
+		last = min(base+relLast-1, last)
+	}
+	return first, last, nil
+}
+
+// AddressForLines anchors lines first to last of doc. The address covers the
+// constructs spanning those lines and a line fragment narrows it to them.
+func (r *AddressResolver) AddressForLines(repo RepositoryID, doc *positioning.Document, first, last int) (StableAddress, error) {
+	start, end, rangeStart, err := doc.PositionsForLines(first, last)
+	if err != nil {
+		return StableAddress{}, fmt.Errorf("lines %d-%d of %s: %w", first, last, doc.FilePath, err)
+	}
+	construct, err := doc.GetConstruct(start)
+	if err != nil {
+		return StableAddress{}, err
+	}
+
+	posRange := PositionRange{Start: start, End: end}
+	addr, err := r.CreateAddress(repo, construct.CreatedBy, posRange)
+	if errors.Is(err, ErrOperationNotFound) {
+		// The resolver only indexes operations seen since startup
+		addr = NewStableAddress(repo, construct.CreatedBy, posRange)
+	} else if err != nil {
+		return StableAddress{}, err
+	}
+
+	addr.Fragment = LineFragment(first-rangeStart+1, last-rangeStart+1)
+	return addr, nil
+}
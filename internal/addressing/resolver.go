@@ -1,9 +1,11 @@
 package addressing
 
 import (
+	gocontext "context"
 	"sync"
 	"time"
 
+	"github.com/jeremytregunna/contextdb/internal/events"
 	"github.com/jeremytregunna/contextdb/internal/operations"
 	"github.com/jeremytregunna/contextdb/internal/positioning"
 )
@@ -14,9 +16,16 @@ type AddressResolver struct {
 	addressIndex    map[AddressKey]*ResolvedAddress
 	forwardingTable map[AddressKey]AddressKey // Handle content movement
 	documents       map[string]*positioning.Document
+	events          *events.Bus
+	store           ConstructStore
 	mutex           sync.RWMutex
 }
 
+// ConstructStore reads the constructs of a document in a range of positions
+type ConstructStore interface {
+	GetConstructsInRange(ctx gocontext.Context, filePath string, start, end operations.LogootPosition) ([]*positioning.Construct, error)
+}
+
 type ResolvedAddress struct {
 	Address         StableAddress            `json:"address"`
 	CurrentRange    PositionRange            `json:"current_range"`
@@ -68,7 +77,7 @@ func (r *AddressResolver) CreateAddress(repo RepositoryID, creationOpID operatio
 	address := NewStableAddress(repo, creationOpID, posRange)
 
 	// Find constructs in the range
-	constructs := r.getConstructsInRange(posRange)
+	constructs := r.getConstructsInRange(creationOp, posRange)
 
 	// Create resolved address
 	resolved := &ResolvedAddress{
@@ -137,7 +146,7 @@ func (r *AddressResolver) UpdateAddressLocation(addr StableAddress, newRange Pos
 	resolved.LastModified = time.Now()
 
 	// Update constructs in new range
-	resolved.Constructs = r.getConstructsInRange(newRange)
+	resolved.Constructs = r.getConstructsInRange(resolved.CreationOp, newRange)
 
 	// Validate the new location
 	resolved.IsValid = !newRange.IsEmpty() && len(resolved.Constructs) > 0
@@ -164,6 +173,29 @@ func (r *AddressResolver) GetAddressHistory(addr StableAddress) ([]MovementRecor
 	return history, nil
 }
 
+// SetConstructStore reads the constructs at an address from store, within the
+// document its creating operation was made in
+func (r *AddressResolver) SetConstructStore(store ConstructStore) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.store = store
+}
+
+// SetEventBus publishes address.invalidated to bus
+func (r *AddressResolver) SetEventBus(bus *events.Bus) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.events = bus
+}
+
+// AddressInvalidation is the payload of an address.invalidated event
+type AddressInvalidation struct {
+	Address StableAddress  `json:"address"`
+	Reason  MovementReason `json:"reason"`
+}
+
 func (r *AddressResolver) InvalidateAddress(addr StableAddress, reason MovementReason) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
@@ -184,6 +216,7 @@ func (r *AddressResolver) InvalidateAddress(addr StableAddress, reason MovementR
 		Reason:    reason,
 	}
 	resolved.MovementHistory = append(resolved.MovementHistory, movement)
+	r.events.Publish(events.AddressInvalidated, AddressInvalidation{Address: resolved.Address, Reason: reason})
 
 	return nil
 }
@@ -203,14 +236,38 @@ func (r *AddressResolver) IndexDocument(doc *positioning.Document) error {
 	r.documents[doc.FilePath] = doc
 
 	// Index all constructs
-	for posKey, construct := range doc.Constructs {
-		r.constructIndex[posKey] = construct
-	}
+	doc.ForEachConstruct(func(construct *positioning.Construct) bool {
+		r.constructIndex[construct.Position.Key()] = construct
+		return true
+	})
 
 	return nil
 }
 
-func (r *AddressResolver) getConstructsInRange(posRange PositionRange) []*positioning.Construct {
+// getConstructsInRange finds the constructs in posRange within the document
+// creationOp was made in, from the store when there is one. Operations
+// without a document are matched against every indexed construct.
+func (r *AddressResolver) getConstructsInRange(creationOp *operations.Operation, posRange PositionRange) []*positioning.Construct {
+	// The empty range of a deleted address holds nothing
+	if len(posRange.End.Segments) == 0 {
+		return nil
+	}
+
+	documentID := ""
+	if creationOp != nil {
+		documentID = creationOp.Metadata.DocumentID
+	}
+	if documentID != "" && r.store != nil {
+		constructs, err := r.store.GetConstructsInRange(gocontext.Background(), documentID, posRange.Start, posRange.End)
+		if err == nil {
+			return constructs
+		}
+	}
+	if doc, exists := r.documents[documentID]; exists {
+		constructs, _ := doc.GetConstructsInRange(posRange.Start, posRange.End)
+		return constructs
+	}
+
 	var constructs []*positioning.Construct
 
 	for _, construct := range r.constructIndex {
@@ -248,9 +305,8 @@ func (r *AddressResolver) constructBelongsToDocument(construct *positioning.Cons
 	}
 
 	// Check if the construct exists in the document
-	posKey := construct.Position.Key()
-	docConstruct, exists := doc.Constructs[posKey]
-	if !exists {
+	docConstruct, err := doc.GetConstruct(construct.Position)
+	if err != nil {
 		return false
 	}
 
@@ -318,5 +374,5 @@ func (r *AddressResolver) updateAddressForOperation(op *operations.Operation, re
 	resolved.LastModified = time.Now()
 
 	// Update constructs to reflect current state
-	resolved.Constructs = r.getConstructsInRange(newRange)
+	resolved.Constructs = r.getConstructsInRange(resolved.CreationOp, newRange)
 }
@@ -1,11 +1,15 @@
 package addressing
 
 import (
+	gocontext "context"
+	"errors"
+	"fmt"
 	"math/big"
 	"testing"
 	"time"
 
 	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
 )
 
 func TestAddressResolver_CreateAndResolve(t *testing.T) {
@@ -233,3 +237,55 @@ func TestAddressResolver_ProcessOperation(t *testing.T) {
 		t.Error("Expected movement history from operation processing")
 	}
 }
+
+// rangeStore answers range queries from one document's constructs
+type rangeStore struct {
+	doc     *positioning.Document
+	queries int
+}
+
+func (s *rangeStore) GetConstructsInRange(ctx gocontext.Context, filePath string, start, end operations.LogootPosition) ([]*positioning.Construct, error) {
+	s.queries++
+	if filePath != s.doc.FilePath {
+		return nil, errors.New("document not found")
+	}
+	return s.doc.GetConstructsInRange(start, end)
+}
+
+func TestAddressResolver_ConstructStore(t *testing.T) {
+	resolver := NewAddressResolver()
+	position := func(value int64) operations.LogootPosition {
+		return operations.NewLogootPosition([]operations.PositionSegment{{Value: big.NewInt(value), AuthorID: "author1"}})
+	}
+
+	// Another document with constructs at the same positions isn't matched
+	doc := positioning.NewDocument("main.go")
+	other := positioning.NewDocument("other.go")
+	for _, value := range []int64{1, 2, 3} {
+		doc.InsertConstruct(&positioning.Construct{ID: positioning.ConstructID(fmt.Sprintf("main%d", value)), Position: position(value)})
+		other.InsertConstruct(&positioning.Construct{ID: positioning.ConstructID(fmt.Sprintf("other%d", value)), Position: position(value)})
+	}
+	resolver.IndexDocument(other)
+	store := &rangeStore{doc: doc}
+	resolver.SetConstructStore(store)
+
+	op := &operations.Operation{
+		ID:       operations.NewOperationID([]byte("op")),
+		Type:     operations.OpInsert,
+		Position: position(1),
+		Metadata: operations.OperationMeta{DocumentID: "main.go"},
+	}
+	resolver.IndexOperation(op)
+	addr, err := resolver.CreateAddress("repo", op.ID, PositionRange{Start: position(1), End: position(2)})
+	if err != nil {
+		t.Fatalf("Failed to create address: %v", err)
+	}
+
+	resolved, err := resolver.ResolveAddress(addr)
+	if err != nil {
+		t.Fatalf("Failed to resolve address: %v", err)
+	}
+	if store.queries != 1 || len(resolved.Constructs) != 2 || resolved.Constructs[0].ID != "main1" || resolved.Constructs[1].ID != "main2" {
+		t.Errorf("Expected main1 and main2 from the store, got %+v after %d queries", resolved.Constructs, store.queries)
+	}
+}
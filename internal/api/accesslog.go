@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bufio"
+	gocontext "context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/logging"
+)
+
+// WithAccessLog logs every request to logger once it is answered. sample
+// maps routes, as the OpenAPI document names them such as "GET
+// /api/v1/operations" or probes such as "GET /healthz", to the fraction of
+// their requests logged, for endpoints called too often to log each time.
+// Requests that fail are always logged.
+func WithAccessLog(logger *logging.Logger, sample map[string]float64) ServerOption {
+	return func(s *APIServer) {
+		rates := make(map[string]float64, len(sample))
+		for route, rate := range sample {
+			rates[route] = rate
+		}
+		s.accessLog = &accessLog{logger: logger, sample: rates}
+	}
+}
+
+type accessLog struct {
+	logger *logging.Logger
+	sample map[string]float64
+}
+
+// accessEntry gathers what is only known deeper in the server, found by the
+// key accessEntryKey in the request's context
+type accessEntry struct {
+	auth    *auth.AuthContext
+	pattern string
+}
+
+type accessEntryKey struct{}
+
+func accessEntryFrom(ctx gocontext.Context) *accessEntry {
+	entry, _ := ctx.Value(accessEntryKey{}).(*accessEntry)
+	return entry
+}
+
+// handle serves the request through next and logs it. A handler that panics
+// to cut its response off is logged as aborted on the way out.
+func (l *accessLog) handle(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	entry := &accessEntry{}
+	lw := &loggedWriter{ResponseWriter: w}
+	start := time.Now()
+	completed := false
+	defer func() {
+		l.log(r, entry, lw, time.Since(start), !completed)
+	}()
+
+	next(lw, r.WithContext(gocontext.WithValue(r.Context(), accessEntryKey{}, entry)))
+	completed = true
+}
+
+func (l *accessLog) log(r *http.Request, entry *accessEntry, lw *loggedWriter, latency time.Duration, aborted bool) {
+	status := lw.status
+	switch {
+	case lw.hijacked:
+		status = http.StatusSwitchingProtocols
+	case status == 0:
+		// Nothing was written, so the server sent its own 200
+		status = http.StatusOK
+	}
+	if status < 400 && !aborted && !l.sampled(entry.pattern) {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"method":     r.Method,
+		"path":       r.URL.Path,
+		"status":     status,
+		"latency_ms": math.Round(float64(latency.Microseconds())) / 1000,
+		"bytes":      lw.bytes,
+	}
+	if entry.auth != nil {
+		if entry.auth.AuthorID != "" {
+			fields["author"] = string(entry.auth.AuthorID)
+		}
+		if entry.auth.APIKeyID != "" {
+			fields["key_id"] = entry.auth.APIKeyID
+		}
+	}
+	if aborted {
+		fields["aborted"] = true
+	}
+	l.logger.Info(fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, status), fields)
+}
+
+// sampled picks whether to log a request that succeeded on the route with
+// pattern, which v1 and v2 share the rate of
+func (l *accessLog) sampled(pattern string) bool {
+	rate, ok := l.sample[strings.Replace(pattern, "/api/v2/", "/api/v1/", 1)]
+	if !ok {
+		return true
+	}
+	return rand.Float64() < rate
+}
+
+// loggedWriter notes the status and how many bytes of body a response had
+type loggedWriter struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	hijacked bool
+}
+
+func (lw *loggedWriter) WriteHeader(status int) {
+	// Informational responses come ahead of the one logged
+	if lw.status == 0 && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		lw.status = status
+	}
+	lw.ResponseWriter.WriteHeader(status)
+}
+
+func (lw *loggedWriter) Write(p []byte) (int, error) {
+	if lw.status == 0 {
+		lw.status = http.StatusOK
+	}
+	n, err := lw.ResponseWriter.Write(p)
+	lw.bytes += int64(n)
+	return n, err
+}
+
+func (lw *loggedWriter) Flush() {
+	lw.FlushError()
+}
+
+func (lw *loggedWriter) FlushError() error {
+	if lw.status == 0 {
+		lw.status = http.StatusOK
+	}
+	return http.NewResponseController(lw.ResponseWriter).Flush()
+}
+
+func (lw *loggedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(lw.ResponseWriter).Hijack()
+	if err == nil {
+		lw.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (lw *loggedWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/logging"
+)
+
+func TestAccessLog(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	flags := log.Flags()
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	}()
+
+	s, insert := setupTestServer(t)
+	logger := logging.NewLogger("access")
+	logger.SetJSONFormat(true)
+	WithAccessLog(logger, map[string]float64{"GET /api/v1/health": 0, "GET /healthz": 0})(s)
+	insert(1, "package main\n")
+
+	entries := func(paths ...string) []logging.LogEntry {
+		t.Helper()
+		output.Reset()
+		for _, path := range paths {
+			s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+		var logged []logging.LogEntry
+		for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry logging.LogEntry
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("Failed to decode log line %q: %v", line, err)
+			}
+			if entry.Component == "access" {
+				logged = append(logged, entry)
+			}
+		}
+		return logged
+	}
+
+	logged := entries("/api/v2/documents/main.go")
+	if len(logged) != 1 || logged[0].Message != "GET /api/v2/documents/main.go 200" {
+		t.Fatalf("Expected the request logged, got %+v", logged)
+	}
+	fields := logged[0].Fields
+	if fields["method"] != "GET" || fields["status"] != float64(200) || fields["author"] == nil {
+		t.Errorf("Expected the method, status and author logged, got %v", fields)
+	}
+	if bytes, _ := fields["bytes"].(float64); bytes <= 0 {
+		t.Errorf("Expected the body's size logged, got %v", fields["bytes"])
+	}
+	if _, ok := fields["latency_ms"].(float64); !ok {
+		t.Errorf("Expected the latency logged, got %v", fields["latency_ms"])
+	}
+
+	// Sampled out routes are skipped in both versions, but failures aren't
+	if logged := entries("/api/v1/health", "/api/v2/health", "/healthz"); len(logged) != 0 {
+		t.Errorf("Expected sampled out routes skipped, got %+v", logged)
+	}
+	if logged := entries("/api/v2/documents/missing.go"); len(logged) != 1 || logged[0].Fields["status"] != float64(404) {
+		t.Errorf("Expected a failed request logged, got %+v", logged)
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+)
+
+// defaultChurnWindow is how far back churn is counted without since
+const defaultChurnWindow = 7 * 24 * time.Hour
+
+// getChurn ranks the documents and ranges of lines that changed the most in a window
+func (s *APIServer) getChurn(w http.ResponseWriter, r *http.Request) {
+	since, until, fieldErr := parseWindow(r, defaultChurnWindow)
+	if fieldErr != nil {
+		s.writeError(w, r, validationError("Invalid query parameter", *fieldErr))
+		return
+	}
+
+	query := r.URL.Query()
+	churnQuery := collaboration.ChurnQuery{Since: since, Until: until, Sort: collaboration.ChurnSort(query.Get("sort"))}
+	if churnQuery.Sort == "" {
+		churnQuery.Sort = collaboration.ChurnByOperations
+	}
+	if !churnQuery.Sort.IsValid() {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "sort", Message: "must be operations, authors or delete_ratio"}))
+		return
+	}
+
+	churnQuery.Limit = collaboration.DefaultChurnResults
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > collaboration.MaxChurnResults {
+			message := fmt.Sprintf("must be between 1 and %d", collaboration.MaxChurnResults)
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "limit", Message: message}))
+			return
+		}
+		churnQuery.Limit = parsed
+	}
+
+	report, err := s.engine.Churn(r.Context(), churnQuery)
+	if err != nil {
+		s.internalError(w, r, "Failed to compute churn", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: report}, http.StatusOK)
+}
+
+// parseWindow reads the since and until query parameters as RFC 3339
+// timestamps. until defaults to now and since to span before it.
+func parseWindow(r *http.Request, span time.Duration) (time.Time, time.Time, *FieldError) {
+	query := r.URL.Query()
+	since, until := time.Time{}, time.Now()
+	bounds := []struct {
+		field string
+		value *time.Time
+	}{{"since", &since}, {"until", &until}}
+	for _, bound := range bounds {
+		value := query.Get(bound.field)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return since, until, &FieldError{Field: bound.field, Message: "must be an RFC 3339 timestamp"}
+		}
+		*bound.value = parsed
+	}
+	if since.IsZero() {
+		since = until.Add(-span)
+	}
+	if !since.Before(until) {
+		return since, until, &FieldError{Field: "since", Message: "must be before until"}
+	}
+	return since, until, nil
+}
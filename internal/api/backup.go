@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/backup"
+)
+
+// WithBackups enables the /admin/backup endpoints
+func WithBackups(manager *backup.Manager) ServerOption {
+	return func(s *APIServer) {
+		s.backups = manager
+	}
+}
+
+// requireBackups wraps a handler that needs backups to be configured
+func (s *APIServer) requireBackups(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.backups == nil {
+			s.jsonError(w, r, "Backups are not enabled on this server", http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *APIServer) createBackup(w http.ResponseWriter, r *http.Request) {
+	generation, err := s.backups.Create(r.Context())
+	if err != nil && generation == nil {
+		s.internalError(w, r, "Failed to create backup", err)
+		return
+	}
+	if err != nil {
+		// The backup was taken, only removing old ones failed
+		s.logger.Warn("Failed to rotate backups", map[string]interface{}{"error": err.Error()})
+	}
+
+	s.respond(w, r, SuccessResponse{Data: generation, Message: "Backup created"}, http.StatusCreated)
+}
+
+func (s *APIServer) listBackups(w http.ResponseWriter, r *http.Request) {
+	generations, err := s.backups.List()
+	if err != nil {
+		s.internalError(w, r, "Failed to list backups", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data: generations,
+		Meta: &ResponseMeta{Total: len(generations)},
+	}, http.StatusOK)
+}
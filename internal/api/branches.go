@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+type CreateBranchRequest struct {
+	Name string `json:"name"`
+	// Base is the branch to start from, main by default
+	Base        string              `json:"base,omitempty"`
+	Description string              `json:"description,omitempty"`
+	AuthorID    operations.AuthorID `json:"author_id,omitempty"`
+}
+
+type MergeBranchRequest struct {
+	// Into is the branch to merge into, the branch's base by default
+	Into string `json:"into,omitempty"`
+}
+
+func (s *APIServer) listBranches(w http.ResponseWriter, r *http.Request) {
+	branches, err := s.engine.ListBranches(r.Context(), r.URL.Query().Get("include_merged") == "true")
+	if err != nil {
+		s.internalError(w, r, "Failed to list branches", err)
+		return
+	}
+
+	branches, meta := page(r, branches)
+	s.respond(w, r, SuccessResponse{Data: branches, Meta: meta}, http.StatusOK)
+}
+
+func (s *APIServer) createBranch(w http.ResponseWriter, r *http.Request) {
+	var req CreateBranchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	branch, err := s.engine.CreateBranch(r.Context(), req.Name, req.Base, req.Description, req.AuthorID)
+	switch {
+	case errors.Is(err, collaboration.ErrInvalidBranch):
+		s.writeError(w, r, validationError("Invalid branch", FieldError{Field: "name", Message: err.Error()}))
+		return
+	case errors.Is(err, storage.ErrBranchNotFound), errors.Is(err, storage.ErrBranchMerged):
+		s.writeError(w, r, validationError("Invalid branch", FieldError{Field: "base", Message: err.Error()}))
+		return
+	case errors.Is(err, storage.ErrBranchExists):
+		s.jsonError(w, r, "Branch already exists", http.StatusConflict)
+		return
+	case err != nil:
+		s.internalError(w, r, "Failed to create branch", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: branch, Message: "Branch created successfully"}, http.StatusCreated)
+}
+
+func (s *APIServer) getBranch(w http.ResponseWriter, r *http.Request) {
+	branch, err := s.engine.GetBranch(r.Context(), r.PathValue("name"))
+	if err != nil {
+		s.lookupError(w, r, "Branch", err)
+		return
+	}
+	s.respond(w, r, SuccessResponse{Data: branch}, http.StatusOK)
+}
+
+// mergeBranch applies a branch's operations to the branch it is merged
+// into and closes it
+func (s *APIServer) mergeBranch(w http.ResponseWriter, r *http.Request) {
+	var req MergeBranchRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	name := r.PathValue("name")
+	if _, err := s.engine.GetBranch(r.Context(), name); err != nil {
+		s.lookupError(w, r, "Branch", err)
+		return
+	}
+
+	merge, err := s.engine.MergeBranch(r.Context(), name, req.Into)
+	if err != nil {
+		switch {
+		case errors.Is(err, collaboration.ErrInvalidBranch), errors.Is(err, storage.ErrBranchNotFound):
+			s.writeError(w, r, validationError("Invalid merge", FieldError{Field: "into", Message: err.Error()}))
+		case errors.Is(err, storage.ErrBranchMerged):
+			s.jsonError(w, r, "Branch is already merged: "+err.Error(), http.StatusConflict)
+		default:
+			if errResp := operationError(err); errResp != nil {
+				s.writeError(w, r, errResp)
+				return
+			}
+			s.internalError(w, r, "Failed to merge branch", err)
+		}
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: merge, Message: "Branch merged successfully"}, http.StatusOK)
+}
+
+// mergeDocument merges one document between branches, leaving the branch
+// it comes from open, and reports the regions both sides changed
+func (s *APIServer) mergeDocument(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	if from == "" {
+		s.writeError(w, r, validationError("Invalid merge", FieldError{Field: "from", Message: "is required"}))
+		return
+	}
+	for _, side := range []struct{ field, branch string }{{"from", from}, {"to", to}} {
+		if operations.IsMainBranch(side.branch) {
+			continue
+		}
+		if _, err := s.engine.GetBranch(r.Context(), side.branch); err != nil {
+			if isNotFound(err) {
+				s.writeError(w, r, validationError("Invalid merge", FieldError{Field: side.field, Message: "no such branch"}))
+			} else {
+				s.internalError(w, r, "Failed to load branch", err)
+			}
+			return
+		}
+	}
+
+	report, err := s.engine.MergeDocument(r.Context(), r.PathValue("path"), from, to, query.Get("dry_run") == "true")
+	if err != nil {
+		switch {
+		case errors.Is(err, collaboration.ErrInvalidBranch):
+			s.writeError(w, r, validationError("Invalid merge", FieldError{Field: "to", Message: err.Error()}))
+		case errors.Is(err, storage.ErrBranchMerged):
+			s.jsonError(w, r, "Branch is already merged: "+err.Error(), http.StatusConflict)
+		default:
+			if errResp := operationError(err); errResp != nil {
+				s.writeError(w, r, errResp)
+				return
+			}
+			s.internalError(w, r, "Failed to merge document", err)
+		}
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: report}, http.StatusOK)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func (s *APIServer) listChangeSets(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := collaboration.ChangeSetFilter{
+		Author:     operations.AuthorID(query.Get("author")),
+		DocumentID: query.Get("document"),
+	}
+	for field, bound := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := query.Get(field)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: field, Message: "must be an RFC 3339 timestamp"}))
+			return
+		}
+		*bound = parsed
+	}
+
+	changeSets, err := s.engine.ChangeSets(r.Context(), filter)
+	if err != nil {
+		s.internalError(w, r, "Failed to list change sets", err)
+		return
+	}
+
+	changeSets, meta := page(r, changeSets)
+	s.respond(w, r, SuccessResponse{Data: changeSets, Meta: meta}, http.StatusOK)
+}
+
+func (s *APIServer) getChangeSet(w http.ResponseWriter, r *http.Request) {
+	id := context.ChangeSetID(r.PathValue("id"))
+	changeSet, err := s.engine.GetChangeSet(r.Context(), id)
+	if err != nil {
+		s.lookupError(w, r, "Change set", err)
+		return
+	}
+
+	conversations, err := s.contextManager.ListConversations(context.ConversationFilter{ChangeSet: id, Viewer: conversationViewer(r)})
+	if err != nil {
+		s.internalError(w, r, "Failed to list conversations", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data: ChangeSetDetails{
+			ChangeSet:     changeSet,
+			Conversations: conversations,
+			Reviews:       s.contextManager.Reviews(context.ReviewFilter{ChangeSet: id, Viewer: conversationViewer(r)}),
+		},
+	}, http.StatusOK)
+}
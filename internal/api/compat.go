@@ -0,0 +1,35 @@
+package api
+
+import "net/http"
+
+// The compatibility shim keeps /api/v1 response bodies byte-for-byte what
+// existing clients were built against. Handlers only ever describe a response
+// once, as a SuccessResponse, and the shim picks the v1 rendering here.
+
+// legacySuccessResponse is the v1 shape of SuccessResponse, which never carried meta
+type legacySuccessResponse struct {
+	Data    interface{} `json:"data"`
+	Message string      `json:"message,omitempty"`
+}
+
+func legacySuccess(resp SuccessResponse) legacySuccessResponse {
+	return legacySuccessResponse{Data: resp.Data, Message: resp.Message}
+}
+
+func legacyError(message string) map[string]string {
+	return map[string]string{"error": message}
+}
+
+// respondLegacy is for endpoints whose v1 body predates SuccessResponse.
+// v1 clients receive legacy verbatim, v2 clients get the standard envelope.
+func (s *APIServer) respondLegacy(w http.ResponseWriter, r *http.Request, resp SuccessResponse, legacy interface{}, statusCode int) {
+	if requestAPIVersion(r) == APIv1 {
+		body, ok := s.selectFields(w, r, legacy)
+		if !ok {
+			return
+		}
+		s.writeJSON(w, body, statusCode)
+		return
+	}
+	s.respond(w, r, resp, statusCode)
+}
@@ -0,0 +1,308 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultCompressionMinSize is the smallest response body compressed, in
+// bytes. Below about a kilobyte compression saves less than it costs.
+const DefaultCompressionMinSize = 1024
+
+// WithCompression compresses responses of at least minSize bytes with zstd
+// or gzip for clients that accept either, zero for DefaultCompressionMinSize
+func WithCompression(minSize int) ServerOption {
+	return func(s *APIServer) {
+		if minSize <= 0 {
+			minSize = DefaultCompressionMinSize
+		}
+		s.compression = &compression{minSize: minSize}
+	}
+}
+
+// compression negotiates response encodings, with a pool of encoders for
+// each, as zstd encoders in particular are expensive to create
+type compression struct {
+	minSize int
+	gzip    sync.Pool
+	zstd    sync.Pool
+}
+
+// The encodings offered, preferred in this order when a client accepts more
+// than one as much
+const (
+	encodingZstd = "zstd"
+	encodingGzip = "gzip"
+)
+
+// encoder is a compressor that can be reused for another response
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func (c *compression) encoder(encoding string, w io.Writer) encoder {
+	pool := &c.gzip
+	if encoding == encodingZstd {
+		pool = &c.zstd
+	}
+	if pooled, ok := pool.Get().(encoder); ok {
+		pooled.Reset(w)
+		return pooled
+	}
+	if encoding == encodingZstd {
+		// One goroutine per response, rather than one per CPU
+		zw, _ := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		return zw
+	}
+	return gzip.NewWriter(w)
+}
+
+func (c *compression) release(encoding string, e encoder) {
+	e.Reset(io.Discard)
+	if encoding == encodingZstd {
+		c.zstd.Put(e)
+	} else {
+		c.gzip.Put(e)
+	}
+}
+
+// handle serves the request through next, compressing the response when the
+// client accepts it. WebSocket upgrades are passed through untouched, as the
+// connection stops being HTTP.
+func (c *compression) handle(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if r.Header.Get("Upgrade") != "" {
+		next(w, r)
+		return
+	}
+	// Whether the response is compressed depends on the header
+	w.Header().Add("Vary", "Accept-Encoding")
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if encoding == "" || r.Method == http.MethodHead {
+		next(w, r)
+		return
+	}
+
+	cw := &compressWriter{ResponseWriter: w, compression: c, encoding: encoding, status: http.StatusOK}
+	next(cw, r)
+	// A handler that panics, such as to cut a response off, mustn't have
+	// its response ended cleanly, so this only runs when it returns
+	cw.close()
+}
+
+// negotiateEncoding picks the encoding the Accept-Encoding header values
+// most, zstd on a tie, or "" when it accepts neither
+func negotiateEncoding(header string) string {
+	qualities := make(map[string]float64)
+	for _, accepted := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(accepted), ";")
+		q := 1.0
+		if _, value, found := strings.Cut(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		qualities[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{encodingZstd, encodingGzip} {
+		q, listed := qualities[encoding]
+		if !listed {
+			q, listed = qualities["*"]
+		}
+		if listed && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressedTypes are media types whose content is compressed already, so
+// compressing it again only costs time
+var compressedTypes = map[string]bool{
+	"application/gzip":            true,
+	"application/x-gzip":          true,
+	"application/zip":             true,
+	"application/zstd":            true,
+	"application/x-bzip2":         true,
+	"application/x-xz":            true,
+	"application/x-7z-compressed": true,
+	"application/vnd.rar":         true,
+	"font/woff":                   true,
+	"font/woff2":                  true,
+	"image/avif":                  true,
+	"image/gif":                   true,
+	"image/jpeg":                  true,
+	"image/png":                   true,
+	"image/webp":                  true,
+}
+
+func alreadyCompressed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return compressedTypes[mediaType] || strings.HasPrefix(mediaType, "video/") || strings.HasPrefix(mediaType, "audio/")
+}
+
+// compressWriter holds a response back until it has minSize bytes of body,
+// or is flushed, then sends it compressed if it can be. A shorter response
+// is sent as it is when the handler returns.
+type compressWriter struct {
+	http.ResponseWriter
+	compression *compression
+	encoding    string
+
+	status      int
+	wroteHeader bool
+	buffer      bytes.Buffer
+	// decided is set once the response has started, and encoder once it
+	// has started compressed
+	decided  bool
+	encoder  encoder
+	hijacked bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	// Informational responses go straight out, ahead of the real one
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.encoder != nil {
+			return cw.encoder.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buffer.Write(p)
+	if cw.buffer.Len() >= cw.compression.minSize {
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// start sends the status and what has been written so far, compressed when
+// compress is set and the response suits it
+func (cw *compressWriter) start(compress bool) error {
+	cw.decided = true
+	header := cw.Header()
+	if header.Get("Content-Type") == "" && cw.buffer.Len() > 0 {
+		// Sniffed here, as the compressed body would be sniffed otherwise
+		header.Set("Content-Type", http.DetectContentType(cw.buffer.Bytes()))
+	}
+	compress = compress && cw.buffer.Len() > 0 &&
+		cw.status != http.StatusNoContent && cw.status != http.StatusNotModified &&
+		header.Get("Content-Encoding") == "" && !alreadyCompressed(header.Get("Content-Type"))
+
+	if compress {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		cw.encoder = cw.compression.encoder(cw.encoding, cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	pending := cw.buffer.Bytes()
+	cw.buffer = bytes.Buffer{}
+	if len(pending) == 0 {
+		return nil
+	}
+	if cw.encoder != nil {
+		_, err := cw.encoder.Write(pending)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(pending)
+	return err
+}
+
+// Flush sends what has been written so far. A response flushed before it
+// reaches minSize is a stream, so it is compressed from then on.
+func (cw *compressWriter) Flush() {
+	cw.FlushError()
+}
+
+func (cw *compressWriter) FlushError() error {
+	if cw.hijacked {
+		return nil
+	}
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		if err := cw.start(true); err != nil {
+			return err
+		}
+	}
+	if cw.encoder != nil {
+		if err := cw.encoder.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Hijack hands over the connection, for handlers that take it over before
+// writing anything
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if cw.decided || cw.buffer.Len() > 0 {
+		return nil, nil, errors.New("response already started")
+	}
+	conn, rw, err := http.NewResponseController(cw.ResponseWriter).Hijack()
+	if err == nil {
+		cw.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the connection's deadlines
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close ends the response, sending a short one as it is
+func (cw *compressWriter) close() {
+	if cw.hijacked {
+		return
+	}
+	if !cw.decided {
+		if !cw.wroteHeader {
+			// Nothing was written, so the server sends its own 200
+			return
+		}
+		cw.start(false)
+	}
+	if cw.encoder != nil {
+		cw.encoder.Close()
+		cw.compression.release(cw.encoding, cw.encoder)
+		cw.encoder = nil
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                        "",
+		"identity":                "",
+		"gzip":                    "gzip",
+		"gzip, deflate, br, zstd": "zstd",
+		"zstd;q=0.5, gzip":        "gzip",
+		"GZIP;q=0.8":              "gzip",
+		"gzip;q=0":                "",
+		"*":                       "zstd",
+		"*, zstd;q=0":             "gzip",
+		"gzip;q=nope":             "",
+	}
+	for header, expected := range tests {
+		if encoding := negotiateEncoding(header); encoding != expected {
+			t.Errorf("Expected %q to negotiate %q, got %q", header, expected, encoding)
+		}
+	}
+}
+
+// decompress reads a response body in the encoding it says it has
+func decompress(t *testing.T, recorder *httptest.ResponseRecorder) string {
+	t.Helper()
+	var reader io.Reader = recorder.Body
+	switch recorder.Header().Get("Content-Encoding") {
+	case "gzip":
+		gr, err := gzip.NewReader(recorder.Body)
+		if err != nil {
+			t.Fatalf("Failed to read gzip: %v", err)
+		}
+		reader = gr
+	case "zstd":
+		zr, err := zstd.NewReader(recorder.Body)
+		if err != nil {
+			t.Fatalf("Failed to read zstd: %v", err)
+		}
+		defer zr.Close()
+		reader = zr
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to decompress body: %v", err)
+	}
+	return string(body)
+}
+
+func TestCompression(t *testing.T) {
+	c := &compression{minSize: 64}
+	large := strings.Repeat(`{"value":"1","author_id":"alice"},`, 20)
+	serve := func(method, acceptEncoding string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		recorder := httptest.NewRecorder()
+		c.handle(recorder, req, handler)
+		return recorder
+	}
+	write := func(contentType, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Length", "999")
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, body)
+		}
+	}
+
+	for _, encoding := range []string{"gzip", "zstd"} {
+		recorder := serve(http.MethodGet, encoding, write("application/json", large))
+		if recorder.Code != http.StatusCreated || recorder.Header().Get("Content-Encoding") != encoding {
+			t.Fatalf("Expected a %s response, got %d %q", encoding, recorder.Code, recorder.Header().Get("Content-Encoding"))
+		}
+		if recorder.Header().Get("Content-Length") != "" || recorder.Body.Len() >= len(large) {
+			t.Errorf("Expected %s to shrink the body without a length, got %d bytes", encoding, recorder.Body.Len())
+		}
+		if body := decompress(t, recorder); body != large {
+			t.Errorf("Expected the body back from %s, got %q", encoding, body)
+		}
+	}
+
+	// Each of these is sent as it is
+	uncompressed := map[string]*httptest.ResponseRecorder{
+		"small":      serve(http.MethodGet, "gzip", write("application/json", `{"ok":true}`)),
+		"image":      serve(http.MethodGet, "gzip", write("image/png", large)),
+		"unaccepted": serve(http.MethodGet, "identity", write("application/json", large)),
+		"head":       serve(http.MethodHead, "gzip", write("application/json", large)),
+	}
+	for name, recorder := range uncompressed {
+		if recorder.Header().Get("Content-Encoding") != "" || recorder.Code != http.StatusCreated {
+			t.Errorf("Expected the %s response uncompressed, got %d %q", name, recorder.Code, recorder.Header().Get("Content-Encoding"))
+		}
+		if recorder.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Expected the %s response to vary by Accept-Encoding, got %q", name, recorder.Header().Get("Vary"))
+		}
+	}
+	if small := uncompressed["small"]; small.Body.String() != `{"ok":true}` {
+		t.Errorf("Expected the small body untouched, got %q", small.Body)
+	}
+
+	// A flush streams what has been written compressed, however little
+	streamed := serve(http.MethodGet, "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ndjsonContentType)
+		io.WriteString(w, "{}\n")
+		http.NewResponseController(w).Flush()
+		io.WriteString(w, "{}\n")
+	})
+	if !streamed.Flushed || streamed.Header().Get("Content-Encoding") != "gzip" || decompress(t, streamed) != "{}\n{}\n" {
+		t.Errorf("Expected a flushed stream compressed, got %q", streamed.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestCompression_Server(t *testing.T) {
+	s, insert := setupTestServer(t)
+	WithCompression(0)(s)
+	insert(1, strings.Repeat("fmt.Println(\"hello\")\n", 100))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/documents/main.go", nil)
+	req.Header.Set("Accept-Encoding", "gzip, zstd")
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Encoding") != "zstd" {
+		t.Fatalf("Expected a zstd document, got %d %q", recorder.Code, recorder.Header().Get("Content-Encoding"))
+	}
+
+	var response SuccessResponse
+	if err := json.Unmarshal([]byte(decompress(t, recorder)), &response); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	if response.Data == nil {
+		t.Error("Expected the document in the response")
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// contentTypes are the media types operation content is served as
+var contentTypes = map[string]string{
+	operations.ContentTypeText:   "text/plain; charset=utf-8",
+	operations.ContentTypeJSON:   "application/json",
+	operations.ContentTypeBinary: "application/octet-stream",
+}
+
+// errChunkMissing ends a content stream when a chunk is no longer stored,
+// such as when retention removed it
+var errChunkMissing = errors.New("chunk missing")
+
+// getOperationContent serves an operation's content outside the envelope,
+// binary content decoded. The content of an insert split into chunks is
+// put back together from them, streamed a chunk at a time, whichever of
+// them the ID names.
+func (s *APIServer) getOperationContent(w http.ResponseWriter, r *http.Request) {
+	op, err := s.store.GetOperation(r.Context(), operations.OperationID(r.PathValue("id")))
+	if err != nil {
+		s.lookupError(w, r, "Operation", err)
+		return
+	}
+
+	if notModified(w, r, operationETag(op.ID)) {
+		return
+	}
+
+	contentType := operations.NormalizeContentType(op.ContentType)
+	if op.Metadata.Chunk == nil {
+		content := []byte(op.Content)
+		if contentType == operations.ContentTypeBinary {
+			if content, err = operations.DecodeBinary(op); err != nil {
+				s.internalError(w, r, "Failed to decode content", err)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", contentTypes[contentType])
+		w.WriteHeader(http.StatusOK)
+		w.Write(content)
+		return
+	}
+
+	chunk := op.Metadata.Chunk
+	next := 0
+	err = s.store.ForEachOperationChunk(r.Context(), chunk.Of, func(piece *operations.Operation) error {
+		if piece.Metadata.Chunk.Index != next {
+			return fmt.Errorf("%w: %d of %d", errChunkMissing, next+1, chunk.Count)
+		}
+		if next == 0 {
+			w.Header().Set("Content-Type", contentTypes[contentType])
+			w.WriteHeader(http.StatusOK)
+		}
+		next++
+		_, err := w.Write([]byte(piece.Content))
+		return err
+	})
+	if err == nil && next != chunk.Count {
+		err = fmt.Errorf("%w: %d of %d", errChunkMissing, next+1, chunk.Count)
+	}
+	if err == nil {
+		return
+	}
+	if next == 0 {
+		s.internalError(w, r, "Failed to read content", err)
+		return
+	}
+	s.abortResponse(r, "Failed to read content", err)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+// ContextPackRequest picks the code a context pack is about: the lines an
+// address covers, or lines of a document, the whole of it when they're left out
+type ContextPackRequest struct {
+	Address    *addressing.StableAddress `json:"address,omitempty"`
+	DocumentID string                    `json:"document_id,omitempty"`
+	FirstLine  int                       `json:"first_line,omitempty"`
+	LastLine   int                       `json:"last_line,omitempty"`
+	// Template names the template to render with, markdown by default
+	Template string `json:"template,omitempty"`
+	// Budget is the tokens the rendered pack should fit in, the server's
+	// default when left out
+	Budget int `json:"budget,omitempty"`
+}
+
+// buildContextPack assembles code with its history, conversations, decisions
+// and intent, rendered to fit a token budget. format=text returns only the
+// rendered pack, outside the envelope.
+func (s *APIServer) buildContextPack(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "text" {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "format", Message: "must be json or text"}))
+		return
+	}
+
+	var req ContextPackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	var fields []FieldError
+	query := collaboration.ContextPackQuery{
+		DocumentID: req.DocumentID,
+		FirstLine:  req.FirstLine,
+		LastLine:   req.LastLine,
+		Template:   req.Template,
+		Budget:     req.Budget,
+		Viewer:     conversationViewer(r),
+	}
+	switch {
+	case req.Address != nil:
+		if !req.Address.IsValid() || req.Address.OperationID == "" {
+			fields = append(fields, FieldError{Field: "address", Message: "must be a valid address with an operation ID"})
+		}
+		query.Address = *req.Address
+	case req.DocumentID == "":
+		fields = append(fields, FieldError{Field: "document_id", Message: "is required without an address"})
+	}
+	if req.FirstLine < 0 || req.LastLine < 0 {
+		fields = append(fields, FieldError{Field: "first_line", Message: "lines must not be negative"})
+	}
+	if req.Budget < 0 {
+		fields = append(fields, FieldError{Field: "budget", Message: "must not be negative"})
+	}
+	if len(fields) > 0 {
+		s.writeError(w, r, validationError("Invalid context pack request", fields...))
+		return
+	}
+
+	pack, err := s.engine.ContextPack(r.Context(), query)
+	if err != nil {
+		switch {
+		case errors.Is(err, collaboration.ErrUnknownTemplate):
+			message := "must be one of " + strings.Join(s.engine.ContextPackTemplateNames(), ", ")
+			s.writeError(w, r, validationError("Invalid context pack request", FieldError{Field: "template", Message: message}))
+		case errors.Is(err, positioning.ErrInvalidRange):
+			s.writeError(w, r, validationError("Invalid context pack request", FieldError{Field: "first_line", Message: err.Error()}))
+		case req.Address != nil:
+			s.lookupError(w, r, "Address", err)
+		default:
+			s.lookupError(w, r, "Document", err)
+		}
+		return
+	}
+
+	if format == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(pack.Content))
+		return
+	}
+	s.respond(w, r, SuccessResponse{Data: pack}, http.StatusOK)
+}
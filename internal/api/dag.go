@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func (s *APIServer) getCausalOrder(w http.ResponseWriter, r *http.Request) {
+	documentID := r.URL.Query().Get("document")
+	if documentID == "" {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "document", Message: "is required"}))
+		return
+	}
+	if _, err := s.engine.GetDocumentState(r.Context(), documentID); err != nil {
+		s.lookupError(w, r, "Document", err)
+		return
+	}
+
+	ordered, err := s.engine.CausalOrder(r.Context(), documentID)
+	if err != nil {
+		s.internalError(w, r, "Failed to order operations", err)
+		return
+	}
+
+	ordered, meta := page(r, ordered)
+	s.respond(w, r, SuccessResponse{Data: ordered, Meta: meta}, http.StatusOK)
+}
+
+func (s *APIServer) getMergeBase(w http.ResponseWriter, r *http.Request) {
+	a, b, ok := s.operationPair(w, r, "a", "b")
+	if !ok {
+		return
+	}
+
+	bases, err := s.engine.MergeBase(r.Context(), a, b)
+	if err != nil {
+		s.lookupError(w, r, "Operation", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: MergeBase{A: a, B: b, Bases: bases}}, http.StatusOK)
+}
+
+func (s *APIServer) getAncestry(w http.ResponseWriter, r *http.Request) {
+	ancestor, descendant, ok := s.operationPair(w, r, "ancestor", "descendant")
+	if !ok {
+		return
+	}
+
+	isAncestor, err := s.engine.IsAncestor(r.Context(), ancestor, descendant)
+	if err != nil {
+		s.lookupError(w, r, "Operation", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: Ancestry{
+		Ancestor:   ancestor,
+		Descendant: descendant,
+		IsAncestor: isAncestor,
+	}}, http.StatusOK)
+}
+
+// operationPair reads two required operation IDs from the query
+func (s *APIServer) operationPair(w http.ResponseWriter, r *http.Request, first, second string) (operations.OperationID, operations.OperationID, bool) {
+	query := r.URL.Query()
+	var fields []FieldError
+	for _, name := range []string{first, second} {
+		if query.Get(name) == "" {
+			fields = append(fields, FieldError{Field: name, Message: "is required"})
+		}
+	}
+	if len(fields) > 0 {
+		s.writeError(w, r, validationError("Invalid query parameter", fields...))
+		return "", "", false
+	}
+	return operations.OperationID(query.Get(first)), operations.OperationID(query.Get(second)), true
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+type CreateDecisionRequest struct {
+	AnchorAddress addressing.StableAddress `json:"anchor_address"`
+	AuthorID      operations.AuthorID      `json:"author_id"`
+	Title         string                   `json:"title"`
+	Content       string                   `json:"content"`
+	// Supersedes lists earlier decisions this one replaces
+	Supersedes []context.MessageID `json:"supersedes,omitempty"`
+}
+
+type SupersedeDecisionRequest struct {
+	SupersededBy context.MessageID `json:"superseded_by"`
+}
+
+func (s *APIServer) listDecisions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := context.DecisionFilter{
+		ThreadID: context.ThreadID(query.Get("thread")),
+		AuthorID: operations.AuthorID(query.Get("author")),
+		Current:  query.Get("current") == "true",
+		Viewer:   conversationViewer(r),
+	}
+
+	var decisions []*context.Decision
+	if document := query.Get("document"); document != "" {
+		decisions = s.engine.DecisionsInDocument(r.Context(), document, filter)
+	} else {
+		decisions = s.contextManager.Decisions(filter)
+	}
+
+	decisions, meta := page(r, decisions)
+	s.respond(w, r, SuccessResponse{Data: decisions, Meta: meta}, http.StatusOK)
+}
+
+func (s *APIServer) createDecision(w http.ResponseWriter, r *http.Request) {
+	var req CreateDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	var fields []FieldError
+	if req.Title == "" {
+		fields = append(fields, FieldError{Field: "title", Message: "is required"})
+	}
+	if req.Content == "" {
+		fields = append(fields, FieldError{Field: "content", Message: "is required"})
+	}
+	for _, id := range req.Supersedes {
+		if _, err := s.contextManager.GetDecision(id); err != nil {
+			fields = append(fields, FieldError{Field: "supersedes", Message: "unknown decision " + string(id)})
+		}
+	}
+	if len(fields) > 0 {
+		s.writeError(w, r, validationError("Invalid decision", fields...))
+		return
+	}
+
+	decision, err := s.contextManager.CreateDecision(req.AnchorAddress, req.AuthorID, req.Title, req.Content)
+	if err != nil {
+		s.internalError(w, r, "Failed to create decision", err)
+		return
+	}
+	for _, id := range req.Supersedes {
+		if _, err := s.contextManager.SupersedeDecision(id, decision.ID); err != nil {
+			s.internalError(w, r, "Failed to supersede decision", err)
+			return
+		}
+	}
+
+	// Reload so Supersedes reflects the links just made
+	if decision, err = s.contextManager.GetDecision(decision.ID); err != nil {
+		s.lookupError(w, r, "Decision", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data:    decision,
+		Message: "Decision recorded successfully",
+	}, http.StatusCreated)
+}
+
+func (s *APIServer) getDecision(w http.ResponseWriter, r *http.Request) {
+	decision, err := s.contextManager.GetDecision(context.MessageID(r.PathValue("id")))
+	if err == nil && !s.canViewConversation(r, decision.ThreadID) {
+		err = context.ErrDecisionNotFound
+	}
+	if err != nil {
+		s.lookupError(w, r, "Decision", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: decision}, http.StatusOK)
+}
+
+func (s *APIServer) supersedeDecision(w http.ResponseWriter, r *http.Request) {
+	var req SupersedeDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	id := context.MessageID(r.PathValue("id"))
+	current, err := s.contextManager.GetDecision(id)
+	if err == nil && !s.canViewConversation(r, current.ThreadID) {
+		err = context.ErrDecisionNotFound
+	}
+	if err != nil {
+		s.lookupError(w, r, "Decision", err)
+		return
+	}
+	if req.SupersededBy == "" {
+		s.writeError(w, r, validationError("Invalid supersession", FieldError{Field: "superseded_by", Message: "is required"}))
+		return
+	}
+
+	decision, err := s.contextManager.SupersedeDecision(id, req.SupersededBy)
+	if errors.Is(err, context.ErrDecisionNotFound) || errors.Is(err, context.ErrInvalidSupersession) {
+		s.writeError(w, r, validationError("Invalid supersession", FieldError{Field: "superseded_by", Message: err.Error()}))
+		return
+	}
+	if err != nil {
+		s.internalError(w, r, "Failed to supersede decision", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: decision}, http.StatusOK)
+}
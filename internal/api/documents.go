@@ -0,0 +1,259 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// DocumentWithStats is a document summed up with its stats, as getDocument
+// responds when asked for them
+type DocumentWithStats struct {
+	positioning.DocumentSnapshot
+	Stats *collaboration.DocumentStats `json:"stats"`
+}
+
+// listDocuments lists the documents below prefix as a tree of directories,
+// depth levels deep, with each document's stats when asked for them
+func (s *APIServer) listDocuments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	treeQuery := collaboration.DocumentTreeQuery{
+		Prefix:         query.Get("prefix"),
+		Depth:          collaboration.DefaultTreeDepth,
+		IncludeDeleted: query.Get("include_deleted") == "true",
+		Stats:          query.Get("stats") == "true",
+	}
+	if depthStr := query.Get("depth"); depthStr != "" {
+		depth, err := strconv.Atoi(depthStr)
+		if err != nil || depth < 0 {
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "depth", Message: "must be a non-negative integer"}))
+			return
+		}
+		treeQuery.Depth = depth
+	}
+
+	tree, err := s.engine.DocumentTree(r.Context(), treeQuery)
+	if err != nil {
+		s.internalError(w, r, "Failed to list documents", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: tree}, http.StatusOK)
+}
+
+// deleteDocument marks a document deleted. Its history is kept, so it can
+// be restored.
+func (s *APIServer) deleteDocument(w http.ResponseWriter, r *http.Request) {
+	filePath := r.PathValue("path")
+	if filePath == "" {
+		s.jsonError(w, r, "Document path is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.engine.DeleteDocument(r.Context(), filePath); err != nil {
+		s.lookupError(w, r, "Document", err)
+		return
+	}
+
+	s.respondMessage(w, r, "Document deleted successfully", http.StatusOK)
+}
+
+func (s *APIServer) restoreDocument(w http.ResponseWriter, r *http.Request) {
+	filePath := r.PathValue("path")
+	if filePath == "" {
+		s.jsonError(w, r, "Document path is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.engine.RestoreDocument(r.Context(), filePath); err != nil {
+		if errors.Is(err, storage.ErrDocumentNotDeleted) {
+			s.jsonError(w, r, "Document is not deleted", http.StatusConflict)
+			return
+		}
+		s.lookupError(w, r, "Document", err)
+		return
+	}
+
+	doc, err := s.documentStore.GetDocument(r.Context(), filePath)
+	if err != nil {
+		s.lookupError(w, r, "Document", err)
+		return
+	}
+
+	w.Header().Set("ETag", versionETag(doc.Version))
+	s.respond(w, r, SuccessResponse{Data: doc}, http.StatusOK)
+}
+
+// getDocumentConstructs lists a document's constructs between the start and
+// end positions, as the store reads them without loading the whole document
+func (s *APIServer) getDocumentConstructs(w http.ResponseWriter, r *http.Request) {
+	filePath := r.PathValue("path")
+	if filePath == "" {
+		s.jsonError(w, r, "Document path is required", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	positions := make(map[string]operations.LogootPosition)
+	for _, field := range []string{"start", "end"} {
+		value := query.Get(field)
+		if value == "" {
+			continue
+		}
+		position, err := operations.ParsePosition(value)
+		if err != nil {
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: field, Message: "must be a position such as 5:alice.12:bob"}))
+			return
+		}
+		positions[field] = position
+	}
+	start, end := positions["start"], positions["end"]
+	if len(end.Segments) > 0 && start.Compare(end) > 0 {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "start", Message: "must not be after end"}))
+		return
+	}
+
+	constructs, err := s.documentStore.GetConstructsInRange(r.Context(), filePath, start, end)
+	if err != nil {
+		s.lookupError(w, r, "Document", err)
+		return
+	}
+
+	constructs, meta := page(r, constructs)
+	s.respond(w, r, SuccessResponse{Data: constructs, Meta: meta}, http.StatusOK)
+}
+
+// maxImportSize caps the files importDocument takes
+const maxImportSize = 32 << 20
+
+// importDocument brings an existing file in as a document. The body is the
+// file's raw content.
+func (s *APIServer) importDocument(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	author := operations.AuthorID(query.Get("author"))
+	opts := collaboration.FileImportOptions{
+		Chunking: positioning.ChunkMode(query.Get("chunking")),
+		Language: query.Get("language"),
+		Branch:   query.Get("branch"),
+	}
+	if opts.Chunking == "" {
+		opts.Chunking = positioning.ChunkLines
+	}
+
+	var fields []FieldError
+	if author == "" {
+		fields = append(fields, FieldError{Field: "author", Message: "is required"})
+	}
+	if !opts.Chunking.IsValid() {
+		fields = append(fields, FieldError{Field: "chunking", Message: "must be line or block"})
+	}
+	if len(fields) > 0 {
+		s.writeError(w, r, validationError("Invalid query parameter", fields...))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.jsonError(w, r, fmt.Sprintf("File exceeds the %d byte import limit", maxImportSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		s.jsonError(w, r, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+	if !utf8.Valid(body) {
+		s.writeError(w, r, validationError("Invalid file", FieldError{Field: "body", Message: "must be UTF-8 text"}))
+		return
+	}
+	content := string(body)
+	chunks := positioning.Chunk(content, opts.Chunking)
+	for _, chunk := range chunks {
+		if len(chunk) > s.maxContentSize {
+			s.writeError(w, r, validationError("Invalid file", FieldError{Field: "chunking", Message: fmt.Sprintf("makes a construct over the %d byte limit", s.maxContentSize)}))
+			return
+		}
+	}
+
+	imported, err := s.engine.ImportFile(r.Context(), r.PathValue("path"), content, author, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, collaboration.ErrDocumentNotEmpty):
+			s.jsonError(w, r, "Document already has content", http.StatusConflict)
+		case errors.Is(err, storage.ErrBranchNotFound):
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "branch", Message: err.Error()}))
+		default:
+			if errResp := operationError(err); errResp != nil {
+				s.writeError(w, r, errResp)
+				return
+			}
+			s.internalError(w, r, "Failed to import file", err)
+		}
+		return
+	}
+
+	s.recordUsage(r, func(usage *auth.UsageTracker, keyID string) error {
+		for _, chunk := range chunks {
+			if err := usage.RecordOperation(keyID, len(chunk)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if operations.IsMainBranch(opts.Branch) {
+		w.Header().Set("ETag", versionETag(imported.Version))
+	}
+	s.respond(w, r, SuccessResponse{
+		Data:    imported,
+		Message: "File imported successfully",
+	}, http.StatusCreated)
+}
+
+// exportDocumentConversations gathers the conversations anchored in a
+// document with the code they are anchored to. format=markdown returns them as
+// a transcript outside the envelope, and accepting NDJSON one per line.
+func (s *APIServer) exportDocumentConversations(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "markdown" {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "format", Message: "must be json or markdown"}))
+		return
+	}
+
+	transcript, err := s.engine.ConversationTranscript(r.Context(), r.PathValue("path"), conversationViewer(r))
+	if err != nil {
+		s.lookupError(w, r, "Document", err)
+		return
+	}
+
+	if format == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(transcript.Markdown()))
+		return
+	}
+	if wantsNDJSON(r) {
+		stream, ok := s.streamNDJSON(w, r)
+		if !ok {
+			return
+		}
+		var err error
+		for _, thread := range transcript.Threads {
+			if err = stream.write(thread); err != nil {
+				break
+			}
+		}
+		stream.finish("Failed to export conversations", err)
+		return
+	}
+	s.respond(w, r, SuccessResponse{Data: transcript}, http.StatusOK)
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/jobs"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+	"github.com/jeremytregunna/contextdb/internal/webhooks"
+)
+
+// ErrorCode identifies a class of failure. Messages may be reworded between
+// releases, codes are stable and safe for clients to branch on.
+type ErrorCode string
+
+const (
+	ErrCodeBadRequest       ErrorCode = "bad_request"
+	ErrCodeValidationFailed ErrorCode = "validation_failed"
+	ErrCodeUnauthorized     ErrorCode = "unauthorized"
+	ErrCodeForbidden        ErrorCode = "forbidden"
+	ErrCodeNotFound         ErrorCode = "not_found"
+	ErrCodeMethodNotAllowed ErrorCode = "method_not_allowed"
+	ErrCodeConflict         ErrorCode = "conflict"
+	ErrCodeVersionConflict  ErrorCode = "version_conflict"
+	ErrCodeInternal         ErrorCode = "internal_error"
+	ErrCodeUnavailable      ErrorCode = "unavailable"
+	// ErrCodeReadOnly refuses changes to a server opened read-only
+	ErrCodeReadOnly ErrorCode = "read_only"
+)
+
+// ErrorResponse is the error object of the v2 envelope
+type ErrorResponse struct {
+	Code    ErrorCode    `json:"code"`
+	Message string       `json:"message"`
+	Status  int          `json:"status"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	// Details carries code-specific data, such as the missing operations of a version_conflict
+	Details interface{} `json:"details,omitempty"`
+}
+
+// VersionConflictDetails tells a client how far behind its write was
+type VersionConflictDetails struct {
+	DocumentID        string                  `json:"document_id"`
+	ExpectedVersion   uint64                  `json:"expected_version"`
+	CurrentVersion    uint64                  `json:"current_version"`
+	MissingOperations []*operations.Operation `json:"missing_operations"`
+	Truncated         bool                    `json:"truncated,omitempty"`
+}
+
+// FieldError points at the part of a request that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func newErrorResponse(status int, code ErrorCode, message string) *ErrorResponse {
+	return &ErrorResponse{Code: code, Message: message, Status: status}
+}
+
+func validationError(message string, fields ...FieldError) *ErrorResponse {
+	return &ErrorResponse{
+		Code:    ErrCodeValidationFailed,
+		Message: message,
+		Status:  http.StatusUnprocessableEntity,
+		Fields:  fields,
+	}
+}
+
+func codeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrCodeBadRequest
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrCodeMethodNotAllowed
+	case http.StatusConflict:
+		return ErrCodeConflict
+	case http.StatusUnprocessableEntity:
+		return ErrCodeValidationFailed
+	case http.StatusServiceUnavailable:
+		return ErrCodeUnavailable
+	default:
+		return ErrCodeInternal
+	}
+}
+
+var notFoundErrors = []error{
+	storage.ErrOperationNotFound,
+	storage.ErrDocumentNotFound,
+	storage.ErrBranchNotFound,
+	storage.ErrAnalysisNotFound,
+	storage.ErrAliasNotFound,
+	operations.ErrOperationNotFound,
+	addressing.ErrAddressNotFound,
+	addressing.ErrOperationNotFound,
+	context.ErrConversationNotFound,
+	context.ErrMessageNotFound,
+	context.ErrDecisionNotFound,
+	context.ErrChangeSetNotFound,
+	context.ErrReviewNotFound,
+	collaboration.ErrGraphRootNotFound,
+	auth.ErrAPIKeyNotFound,
+	auth.ErrLockoutNotFound,
+	webhooks.ErrWebhookNotFound,
+	jobs.ErrJobNotFound,
+}
+
+func isNotFound(err error) bool {
+	for _, target := range notFoundErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Operation failures the client can fix by changing the named field
+var operationFieldErrors = []struct {
+	err   error
+	field string
+}{
+	{operations.ErrInvalidOperation, "operation"},
+	{operations.ErrInvalidAuthor, "author"},
+	{operations.ErrInvalidOperationType, "type"},
+	{operations.ErrInvalidContentType, "content_type"},
+	{operations.ErrInvalidContent, "content"},
+	{operations.ErrInvalidPatch, "content"},
+	{operations.ErrClockDrift, "clock"},
+	{storage.ErrBranchNotFound, "metadata.branch"},
+}
+
+// Operation failures caused by the current document state rather than the request itself
+var operationConflictErrors = []error{
+	operations.ErrPositionConflict,
+	operations.ErrCausalityViolation,
+	operations.ErrContentTypeMismatch,
+	operations.ErrPatchPathNotFound,
+	operations.ErrPatchTestFailed,
+	positioning.ErrConstructNotFound,
+	storage.ErrDocumentDeleted,
+	collaboration.ErrOperationReplayed,
+	storage.ErrBranchMerged,
+}
+
+// Operations refused because they can't be shown to come from their author
+var operationSignatureErrors = []error{
+	auth.ErrSignatureRequired,
+	auth.ErrInvalidSignature,
+	auth.ErrSignatureExpired,
+}
+
+// operationError classifies an error from validating or applying an
+// operation, returning nil for anything that isn't the client's doing
+func operationError(err error) *ErrorResponse {
+	if errors.Is(err, collaboration.ErrEngineShutdown) {
+		return newErrorResponse(http.StatusServiceUnavailable, ErrCodeUnavailable, "Server is shutting down")
+	}
+	if errors.Is(err, storage.ErrReadOnly) {
+		return readOnlyError()
+	}
+
+	var conflict *collaboration.VersionConflictError
+	if errors.As(err, &conflict) {
+		e := newErrorResponse(http.StatusConflict, ErrCodeVersionConflict, "Document has changed since the expected version")
+		e.Details = VersionConflictDetails{
+			DocumentID:        conflict.DocumentID,
+			ExpectedVersion:   conflict.ExpectedVersion,
+			CurrentVersion:    conflict.CurrentVersion,
+			MissingOperations: conflict.Missing,
+			Truncated:         conflict.Truncated,
+		}
+		return e
+	}
+
+	for _, fe := range operationFieldErrors {
+		if errors.Is(err, fe.err) {
+			return validationError("Invalid operation", FieldError{Field: fe.field, Message: fe.err.Error()})
+		}
+	}
+
+	for _, target := range operationSignatureErrors {
+		if errors.Is(err, target) {
+			return newErrorResponse(http.StatusForbidden, ErrCodeForbidden, err.Error())
+		}
+	}
+
+	for _, target := range operationConflictErrors {
+		if errors.Is(err, target) {
+			return newErrorResponse(http.StatusConflict, ErrCodeConflict, "Operation conflicts with the document: "+target.Error())
+		}
+	}
+
+	return nil
+}
+
+// writeError renders e in the shape expected by the request's API version
+func (s *APIServer) writeError(w http.ResponseWriter, r *http.Request, e *ErrorResponse) {
+	if requestAPIVersion(r) == APIv1 {
+		s.writeJSON(w, legacyError(e.Message), e.Status)
+		return
+	}
+
+	s.writeJSON(w, APIResponse{Success: false, Error: e}, e.Status)
+}
+
+// jsonError reports a failure whose code follows from its status. It matches
+// auth.ErrorWriter so authentication failures render the same way.
+func (s *APIServer) jsonError(w http.ResponseWriter, r *http.Request, message string, statusCode int) {
+	s.writeError(w, r, newErrorResponse(statusCode, codeForStatus(statusCode), message))
+}
+
+// internalError logs err and responds with message alone, so storage and
+// other internal details never reach the client
+func (s *APIServer) internalError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	s.logger.Error(message, map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"error":  err.Error(),
+	})
+	s.writeError(w, r, newErrorResponse(http.StatusInternalServerError, ErrCodeInternal, message))
+}
+
+// lookupError responds not_found when err says the resource named by what
+// doesn't exist, and internal_error for anything else
+func (s *APIServer) lookupError(w http.ResponseWriter, r *http.Request, what string, err error) {
+	if isNotFound(err) {
+		s.writeError(w, r, newErrorResponse(http.StatusNotFound, ErrCodeNotFound, what+" not found"))
+		return
+	}
+	s.internalError(w, r, "Failed to load "+strings.ToLower(what), err)
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestCodeForStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		code   ErrorCode
+	}{
+		{http.StatusBadRequest, ErrCodeBadRequest},
+		{http.StatusUnauthorized, ErrCodeUnauthorized},
+		{http.StatusForbidden, ErrCodeForbidden},
+		{http.StatusNotFound, ErrCodeNotFound},
+		{http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed},
+		{http.StatusConflict, ErrCodeConflict},
+		{http.StatusUnprocessableEntity, ErrCodeValidationFailed},
+		{http.StatusServiceUnavailable, ErrCodeUnavailable},
+		{http.StatusInternalServerError, ErrCodeInternal},
+		{http.StatusTeapot, ErrCodeInternal},
+	}
+	for _, tt := range tests {
+		if code := codeForStatus(tt.status); code != tt.code {
+			t.Errorf("Expected %d to map to %s, got %s", tt.status, tt.code, code)
+		}
+	}
+}
+
+func TestOperationError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   ErrorCode
+		field  string
+	}{
+		{collaboration.ErrEngineShutdown, http.StatusServiceUnavailable, ErrCodeUnavailable, ""},
+		{storage.ErrReadOnly, http.StatusForbidden, ErrCodeReadOnly, ""},
+		{&collaboration.VersionConflictError{DocumentID: "main.go", ExpectedVersion: 1, CurrentVersion: 3}, http.StatusConflict, ErrCodeVersionConflict, ""},
+		{operations.ErrInvalidAuthor, http.StatusUnprocessableEntity, ErrCodeValidationFailed, "author"},
+		{operations.ErrClockDrift, http.StatusUnprocessableEntity, ErrCodeValidationFailed, "clock"},
+		{storage.ErrBranchNotFound, http.StatusUnprocessableEntity, ErrCodeValidationFailed, "metadata.branch"},
+		{auth.ErrInvalidSignature, http.StatusForbidden, ErrCodeForbidden, ""},
+		{operations.ErrCausalityViolation, http.StatusConflict, ErrCodeConflict, ""},
+		{collaboration.ErrOperationReplayed, http.StatusConflict, ErrCodeConflict, ""},
+	}
+	for _, tt := range tests {
+		// Classification must see through the wrapping the engine adds
+		e := operationError(fmt.Errorf("failed to apply operation: %w", tt.err))
+		if e == nil {
+			t.Errorf("Expected %v classified as %s, got nil", tt.err, tt.code)
+			continue
+		}
+		if e.Status != tt.status || e.Code != tt.code {
+			t.Errorf("Expected %v to be %d %s, got %d %s", tt.err, tt.status, tt.code, e.Status, e.Code)
+		}
+		if tt.field != "" && (len(e.Fields) != 1 || e.Fields[0].Field != tt.field) {
+			t.Errorf("Expected %v to point at %s, got %+v", tt.err, tt.field, e.Fields)
+		}
+	}
+
+	if e := operationError(errors.New("disk full")); e != nil {
+		t.Errorf("Expected an internal failure left unclassified, got %+v", e)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	s := &APIServer{}
+	e := validationError("Invalid operation", FieldError{Field: "author", Message: "invalid author"})
+
+	recorder := httptest.NewRecorder()
+	s.writeError(recorder, httptest.NewRequest(http.MethodPost, "/api/v2/operations", nil), e)
+	var v2 struct {
+		Success bool          `json:"success"`
+		Error   ErrorResponse `json:"error"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &v2); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if recorder.Code != http.StatusUnprocessableEntity || v2.Success || v2.Error.Code != ErrCodeValidationFailed ||
+		v2.Error.Status != http.StatusUnprocessableEntity || len(v2.Error.Fields) != 1 || v2.Error.Fields[0].Field != "author" {
+		t.Errorf("Expected a v2 validation error on author, got %d %s", recorder.Code, recorder.Body)
+	}
+
+	recorder = httptest.NewRecorder()
+	s.writeError(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/operations", nil), e)
+	var v1 map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &v1); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if recorder.Code != http.StatusUnprocessableEntity || len(v1) != 1 || v1["error"] != "Invalid operation" {
+		t.Errorf("Expected the v1 error body with only its message, got %d %s", recorder.Code, recorder.Body)
+	}
+}
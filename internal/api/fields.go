@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// fieldSelection is a parsed fields query parameter. Each name picked maps
+// to the fields picked within it, or to nil when it is picked whole.
+type fieldSelection map[string]fieldSelection
+
+// parseFields reads a comma separated list of field names, with dots to pick
+// fields within fields, such as "id,metadata.document_id"
+func parseFields(param string) (fieldSelection, error) {
+	selection := fieldSelection{}
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		names := strings.Split(field, ".")
+		within := selection
+		for i, name := range names {
+			if name == "" {
+				return nil, fmt.Errorf("%q has an empty name in it", field)
+			}
+			if i == len(names)-1 {
+				// Picking a field whole outranks picking fields within it
+				within[name] = nil
+				break
+			}
+			next, picked := within[name]
+			if picked && next == nil {
+				break
+			}
+			if !picked {
+				next = fieldSelection{}
+				within[name] = next
+			}
+			within = next
+		}
+	}
+	if len(selection) == 0 {
+		return nil, fmt.Errorf("must name at least one field")
+	}
+	return selection, nil
+}
+
+// project keeps only the selected fields of a decoded JSON value. The
+// selection applies to each element of an array, and to nothing else.
+func (selection fieldSelection) project(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		projected := make(map[string]interface{}, len(selection))
+		for name, within := range selection {
+			field, exists := value[name]
+			if !exists {
+				continue
+			}
+			if within == nil {
+				projected[name] = field
+			} else {
+				projected[name] = within.project(field)
+			}
+		}
+		return projected
+	case []interface{}:
+		projected := make([]interface{}, len(value))
+		for i, element := range value {
+			projected[i] = selection.project(element)
+		}
+		return projected
+	default:
+		return value
+	}
+}
+
+// requestedFields reads the fields query parameter of a GET, returning nil
+// when the whole response is wanted
+func requestedFields(r *http.Request) (fieldSelection, *ErrorResponse) {
+	param := r.URL.Query().Get("fields")
+	if param == "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return nil, nil
+	}
+	selection, err := parseFields(param)
+	if err != nil {
+		return nil, validationError("Invalid query parameter", FieldError{Field: "fields", Message: err.Error()})
+	}
+	return selection, nil
+}
+
+// apply projects data as it is encoded in JSON rather than as a Go value, so
+// custom encodings are kept, with numbers kept as written so large ones stay
+// exact. Fields that aren't there are left out rather than refused, as the
+// elements of a list need not all have the same ones.
+func (selection fieldSelection) apply(data interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return selection.project(decoded), nil
+}
+
+// selectFields projects the data a response carries onto the fields asked
+// for, writing the error and reporting false when it can't
+func (s *APIServer) selectFields(w http.ResponseWriter, r *http.Request, data interface{}) (interface{}, bool) {
+	selection, errResp := requestedFields(r)
+	if errResp != nil {
+		s.writeError(w, r, errResp)
+		return nil, false
+	}
+	if selection == nil || data == nil {
+		return data, true
+	}
+	projected, err := selection.apply(data)
+	if err != nil {
+		s.internalError(w, r, "Failed to select fields", err)
+		return nil, false
+	}
+	return projected, true
+}
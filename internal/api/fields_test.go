@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFieldSelection(t *testing.T) {
+	selection, err := parseFields("id, metadata.document_id,metadata.branch,position,position.segments")
+	if err != nil {
+		t.Fatalf("Failed to parse fields: %v", err)
+	}
+
+	var value interface{}
+	data := `[
+		{"id": "a", "content": "x", "position": {"segments": [1]}, "metadata": {"document_id": "main.go", "tool": "vim"}},
+		{"id": "b", "clock": 4611686018427387905}
+	]`
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		t.Fatalf("Failed to decode data: %v", err)
+	}
+	projected, err := json.Marshal(selection.project(value))
+	if err != nil {
+		t.Fatalf("Failed to encode projection: %v", err)
+	}
+	expected := `[{"id":"a","metadata":{"document_id":"main.go"},"position":{"segments":[1]}},{"id":"b"}]`
+	if string(projected) != expected {
+		t.Errorf("Expected %s, got %s", expected, projected)
+	}
+
+	// Large numbers survive projection exactly
+	applied, err := fieldSelection{"clock": nil}.apply(json.RawMessage(`{"clock": 4611686018427387905, "id": "b"}`))
+	if err != nil {
+		t.Fatalf("Failed to apply fields: %v", err)
+	}
+	if encoded, _ := json.Marshal(applied); string(encoded) != `{"clock":4611686018427387905}` {
+		t.Errorf("Expected the clock exactly, got %s", encoded)
+	}
+
+	for _, invalid := range []string{",", "metadata..branch", ".id"} {
+		if _, err := parseFields(invalid); err == nil {
+			t.Errorf("Expected %q to be refused", invalid)
+		}
+	}
+}
+
+func TestFieldSelection_Responses(t *testing.T) {
+	s := newRoutedServer()
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		s.respond(recorder, httptest.NewRequest(http.MethodGet, path, nil), SuccessResponse{
+			Data: map[string]interface{}{"id": "a", "content": "x"},
+			Meta: &ResponseMeta{Total: 1},
+		}, http.StatusOK)
+		return recorder
+	}
+
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+		Meta *ResponseMeta          `json:"meta"`
+	}
+	recorder := get("/api/v2/operations/a?fields=id")
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Data) != 1 || resp.Data["id"] != "a" || resp.Meta == nil || resp.Meta.Total != 1 {
+		t.Errorf("Expected only the id, with meta kept, got %s", recorder.Body)
+	}
+
+	if recorder := get("/api/v2/operations/a?fields=a..b"); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for invalid fields, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+)
+
+func (s *APIServer) getReferenceGraph(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	root := query.Get("root")
+	if root == "" {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "root", Message: "is required"}))
+		return
+	}
+
+	depth := collaboration.DefaultGraphDepth
+	if depthStr := query.Get("depth"); depthStr != "" {
+		parsed, err := strconv.Atoi(depthStr)
+		if err != nil || parsed < 1 || parsed > collaboration.MaxGraphDepth {
+			message := fmt.Sprintf("must be between 1 and %d", collaboration.MaxGraphDepth)
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "depth", Message: message}))
+			return
+		}
+		depth = parsed
+	}
+
+	graph, err := s.engine.ReferenceGraph(r.Context(), root, depth, conversationViewer(r))
+	if errors.Is(err, collaboration.ErrInvalidGraphRoot) {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "root", Message: err.Error()}))
+		return
+	}
+	if err != nil {
+		s.lookupError(w, r, "Graph root", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: graph}, http.StatusOK)
+}
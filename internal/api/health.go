@@ -0,0 +1,185 @@
+package api
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Health checks report pass, warn or fail. Only a failing check makes the
+// server unhealthy; warnings say it is working but needs attention.
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+const (
+	// healthCheckTimeout bounds each probe, so a stuck database fails the
+	// check instead of hanging it
+	healthCheckTimeout = 2 * time.Second
+	// WAL backlogs past these sizes mean checkpoints aren't keeping up
+	walBacklogWarning = 64 << 20
+	walBacklogFailure = 1 << 30
+	// jobLagWarning is how far past its scheduled start a job may get
+	jobLagWarning = 15 * time.Minute
+)
+
+type HealthCheck struct {
+	Name    string                 `json:"name"`
+	Status  CheckStatus            `json:"status"`
+	Message string                 `json:"message,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// HealthReport is the answer to /healthz and /readyz. Status is the worst of
+// the checks.
+type HealthReport struct {
+	Status    CheckStatus   `json:"status"`
+	Timestamp time.Time     `json:"timestamp"`
+	Checks    []HealthCheck `json:"checks"`
+}
+
+func newHealthReport(checks ...HealthCheck) HealthReport {
+	report := HealthReport{Status: CheckPass, Timestamp: time.Now(), Checks: checks}
+	for _, check := range checks {
+		switch {
+		case check.Status == CheckFail:
+			report.Status = CheckFail
+		case check.Status == CheckWarn && report.Status == CheckPass:
+			report.Status = CheckWarn
+		}
+	}
+	return report
+}
+
+// statusCode answers 503 when a check failed so probes needn't read the body
+func (h HealthReport) statusCode() int {
+	if h.Status == CheckFail {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// liveness runs the checks that only a restart would fix
+func (s *APIServer) liveness(ctx gocontext.Context) HealthReport {
+	return newHealthReport(s.checkDatabase(ctx), s.checkBroadcaster())
+}
+
+// readiness adds the checks saying whether the server is keeping up
+func (s *APIServer) readiness(ctx gocontext.Context) HealthReport {
+	checks := []HealthCheck{s.checkDatabase(ctx), s.checkWAL(ctx), s.checkBroadcaster()}
+	if s.jobs != nil {
+		checks = append(checks, s.checkJobs())
+	}
+	return newHealthReport(checks...)
+}
+
+func (s *APIServer) checkDatabase(ctx gocontext.Context) HealthCheck {
+	ctx, cancel := gocontext.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	check := HealthCheck{Name: "sqlite", Status: CheckPass}
+	health, err := s.engine.StoreHealth(ctx)
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = err.Error()
+		return check
+	}
+	check.Details = map[string]interface{}{
+		"latency":      health.Latency.String(),
+		"journal_mode": health.JournalMode,
+		"read_only":    s.engine.ReadOnly(),
+	}
+	return check
+}
+
+func (s *APIServer) checkWAL(ctx gocontext.Context) HealthCheck {
+	ctx, cancel := gocontext.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	check := HealthCheck{Name: "wal", Status: CheckPass}
+	health, err := s.engine.StoreHealth(ctx)
+	if err != nil {
+		check.Status = CheckFail
+		check.Message = err.Error()
+		return check
+	}
+	check.Details = map[string]interface{}{"backlog_bytes": health.WALBacklog}
+	switch {
+	case health.WALBacklog > walBacklogFailure:
+		check.Status = CheckFail
+		check.Message = "Write-ahead log is not being checkpointed"
+	case health.WALBacklog > walBacklogWarning:
+		check.Status = CheckWarn
+		check.Message = "Write-ahead log checkpoints are falling behind"
+	}
+	return check
+}
+
+func (s *APIServer) checkBroadcaster() HealthCheck {
+	stats := s.engine.BroadcasterStats()
+	check := HealthCheck{
+		Name:   "broadcaster",
+		Status: CheckPass,
+		Details: map[string]interface{}{
+			"clients":      stats.Clients,
+			"saturated":    stats.Saturated,
+			"dropped":      stats.Dropped,
+			"evicted":      stats.Evicted,
+			"relay_errors": stats.RelayErrors,
+		},
+	}
+	if stats.Saturated > 0 {
+		check.Status = CheckWarn
+		check.Message = fmt.Sprintf("%d of %d clients are not keeping up with updates", stats.Saturated, stats.Clients)
+	}
+	return check
+}
+
+func (s *APIServer) checkJobs() HealthCheck {
+	check := HealthCheck{Name: "jobs", Status: CheckPass}
+	job, lag := s.jobs.Overdue()
+	if lag <= 0 {
+		return check
+	}
+	check.Details = map[string]interface{}{"job": job, "lag": lag.Round(time.Second).String()}
+	if lag > jobLagWarning {
+		check.Status = CheckWarn
+		check.Message = fmt.Sprintf("Job %s is %s behind schedule", job, lag.Round(time.Second))
+	}
+	return check
+}
+
+// healthz answers liveness probes
+func (s *APIServer) healthz(w http.ResponseWriter, r *http.Request) {
+	report := s.liveness(r.Context())
+	s.writeJSON(w, report, report.statusCode())
+}
+
+// readyz answers readiness probes
+func (s *APIServer) readyz(w http.ResponseWriter, r *http.Request) {
+	report := s.readiness(r.Context())
+	s.writeJSON(w, report, report.statusCode())
+}
+
+func (s *APIServer) healthCheck(w http.ResponseWriter, r *http.Request) {
+	report := s.readiness(r.Context())
+	health := HealthStatus{
+		Status:    "healthy",
+		Timestamp: report.Timestamp,
+		Version:   "1.0.0-mvp",
+		Checks:    report.Checks,
+	}
+	switch report.Status {
+	case CheckWarn:
+		health.Status = "degraded"
+	case CheckFail:
+		health.Status = "unhealthy"
+	}
+
+	s.respondLegacy(w, r, SuccessResponse{Data: health}, health, report.statusCode())
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestHealthReport_Status(t *testing.T) {
+	pass := HealthCheck{Name: "a", Status: CheckPass}
+	warn := HealthCheck{Name: "b", Status: CheckWarn}
+	fail := HealthCheck{Name: "c", Status: CheckFail}
+
+	cases := []struct {
+		checks   []HealthCheck
+		expected CheckStatus
+		code     int
+	}{
+		{[]HealthCheck{pass}, CheckPass, http.StatusOK},
+		{[]HealthCheck{pass, warn}, CheckWarn, http.StatusOK},
+		{[]HealthCheck{fail, warn, pass}, CheckFail, http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		report := newHealthReport(c.checks...)
+		if report.Status != c.expected || report.statusCode() != c.code {
+			t.Errorf("Expected %s (%d) for %+v, got %s (%d)", c.expected, c.code, c.checks, report.Status, report.statusCode())
+		}
+	}
+}
+
+func TestProbes(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	authManager, err := auth.NewAuthManager(dir)
+	if err != nil {
+		t.Fatalf("Failed to create auth manager: %v", err)
+	}
+	// Probes must answer without an API key
+	if err := authManager.EnableAuth(); err != nil {
+		t.Fatalf("Failed to enable auth: %v", err)
+	}
+
+	engine := collaboration.NewCollaborationEngine(store)
+	s := NewAPIServer(engine, store, store, engine.AddressResolver(), engine.ConversationManager(), engine.ContextAnalyzer(), authManager)
+
+	probe := func(path string) (int, HealthReport) {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		var report HealthReport
+		if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+			t.Fatalf("Failed to decode %s: %v", path, err)
+		}
+		return recorder.Code, report
+	}
+
+	code, live := probe("/healthz")
+	if code != http.StatusOK || live.Status != CheckPass || len(live.Checks) != 2 {
+		t.Errorf("Expected a passing liveness report, got %d %+v", code, live)
+	}
+	code, ready := probe("/readyz")
+	if code != http.StatusOK || ready.Status != CheckPass || len(ready.Checks) != 3 || ready.Checks[1].Name != "wal" {
+		t.Errorf("Expected a passing readiness report, got %d %+v", code, ready)
+	}
+
+	// The rest of the API still needs a key
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v2/health", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the API to need a key, got %d", recorder.Code)
+	}
+
+	// A closed store fails both probes
+	store.Close()
+	code, live = probe("/healthz")
+	if code != http.StatusServiceUnavailable || live.Status != CheckFail || live.Checks[0].Status != CheckFail {
+		t.Errorf("Expected a failing liveness report, got %d %+v", code, live)
+	}
+	code, ready = probe("/readyz")
+	if code != http.StatusServiceUnavailable || ready.Status != CheckFail {
+		t.Errorf("Expected a failing readiness report, got %d %+v", code, ready)
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// MergeIdentityRequest declares aliases to be other IDs of author
+type MergeIdentityRequest struct {
+	Author  operations.AuthorID   `json:"author"`
+	Aliases []operations.AuthorID `json:"aliases"`
+}
+
+func (s *APIServer) listIdentities(w http.ResponseWriter, r *http.Request) {
+	identities, meta := page(r, s.engine.AuthorAliases().Identities())
+	s.respond(w, r, SuccessResponse{Data: identities, Meta: meta}, http.StatusOK)
+}
+
+func (s *APIServer) getIdentity(w http.ResponseWriter, r *http.Request) {
+	identity := s.engine.AuthorAliases().Identity(operations.AuthorID(r.PathValue("author")))
+	s.respond(w, r, SuccessResponse{Data: identity}, http.StatusOK)
+}
+
+func (s *APIServer) mergeIdentity(w http.ResponseWriter, r *http.Request) {
+	var req MergeIdentityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	var fields []FieldError
+	if req.Author == "" {
+		fields = append(fields, FieldError{Field: "author", Message: "is required"})
+	}
+	if len(req.Aliases) == 0 {
+		fields = append(fields, FieldError{Field: "aliases", Message: "must name at least one author ID"})
+	}
+	if len(fields) > 0 {
+		s.writeError(w, r, validationError("Invalid identity", fields...))
+		return
+	}
+
+	identity, err := s.engine.MergeAuthors(r.Context(), req.Author, req.Aliases...)
+	if errors.Is(err, operations.ErrInvalidAuthor) {
+		s.writeError(w, r, validationError("Invalid identity", FieldError{Field: "aliases", Message: err.Error()}))
+		return
+	}
+	if err != nil {
+		s.internalError(w, r, "Failed to merge authors", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data:    identity,
+		Message: "Authors merged",
+	}, http.StatusOK)
+}
+
+func (s *APIServer) splitIdentity(w http.ResponseWriter, r *http.Request) {
+	if err := s.engine.SplitAuthor(r.Context(), operations.AuthorID(r.PathValue("alias"))); err != nil {
+		s.lookupError(w, r, "Alias", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Message: "Alias split from its identity"}, http.StatusOK)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+	"github.com/jeremytregunna/contextdb/internal/telemetry"
+)
+
+// importEditJournal turns an editor extension's journal of offset edits into
+// operations
+func (s *APIServer) importEditJournal(w http.ResponseWriter, r *http.Request) {
+	journal, err := telemetry.ParseJournal(r.Body)
+	var fieldErr *telemetry.FieldError
+	switch {
+	case errors.As(err, &fieldErr):
+		s.writeError(w, r, validationError("Invalid edit journal", FieldError{Field: fieldErr.Field, Message: fieldErr.Message}))
+		return
+	case err != nil:
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	imported, err := s.engine.ImportEditJournal(r.Context(), journal)
+	if err != nil {
+		switch {
+		case errors.Is(err, telemetry.ErrOffsetOutOfRange), errors.Is(err, telemetry.ErrNotText):
+			s.writeError(w, r, validationError("Invalid edit journal", FieldError{Field: "events", Message: err.Error()}))
+		case errors.Is(err, storage.ErrBranchNotFound):
+			s.writeError(w, r, validationError("Invalid edit journal", FieldError{Field: "branch", Message: err.Error()}))
+		default:
+			if errResp := operationError(err); errResp != nil {
+				s.writeError(w, r, errResp)
+				return
+			}
+			s.internalError(w, r, "Failed to import edit journal", err)
+		}
+		return
+	}
+
+	s.recordUsage(r, func(usage *auth.UsageTracker, keyID string) error {
+		for _, event := range journal.Events {
+			for _, change := range event.Changes {
+				if err := usage.RecordOperation(keyID, len(change.Text)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+
+	s.respond(w, r, SuccessResponse{
+		Data:    imported,
+		Message: "Edit journal imported successfully",
+	}, http.StatusCreated)
+}
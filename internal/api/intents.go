@@ -0,0 +1,97 @@
+package api
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/jobs"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func (s *APIServer) getIntentTaxonomy(w http.ResponseWriter, r *http.Request) {
+	s.respond(w, r, SuccessResponse{Data: s.engine.IntentTaxonomy()}, http.StatusOK)
+}
+
+func (s *APIServer) setIntentTaxonomy(w http.ResponseWriter, r *http.Request) {
+	var taxonomy context.IntentTaxonomy
+	if err := json.NewDecoder(r.Body).Decode(&taxonomy); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := s.engine.SetIntentTaxonomy(r.Context(), taxonomy)
+	if errors.Is(err, context.ErrInvalidTaxonomy) {
+		s.writeError(w, r, validationError("Invalid intent taxonomy", FieldError{Field: "categories", Message: err.Error()}))
+		return
+	}
+	if err != nil {
+		s.internalError(w, r, "Failed to save intent taxonomy", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data:    saved,
+		Message: "Intent taxonomy updated",
+	}, http.StatusOK)
+}
+
+// ReanalysisJob is the job POST /admin/analysis/reanalyze starts. The endpoint
+// needs it registered with the server's scheduler.
+const ReanalysisJob = "reanalysis"
+
+// reanalyzeIntents starts the reanalysis job over the operations the query
+// selects. Progress and the outcome show in the job's status.
+func (s *APIServer) reanalyzeIntents(w http.ResponseWriter, r *http.Request) {
+	var query collaboration.ReanalysisQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if !query.Since.IsZero() && !query.Until.IsZero() && !query.Until.After(query.Since) {
+		s.writeError(w, r, validationError("Invalid time range", FieldError{Field: "until", Message: "must be after since"}))
+		return
+	}
+
+	err := s.jobs.TriggerWith(ReanalysisJob, func(ctx gocontext.Context) error {
+		query.Progress = func(done, total int) { jobs.ReportProgress(ctx, done, total) }
+		_, err := s.engine.ReanalyzeIntents(ctx, query)
+		return err
+	})
+	switch {
+	case errors.Is(err, jobs.ErrJobRunning):
+		s.jsonError(w, r, "Reanalysis is already running", http.StatusConflict)
+		return
+	case errors.Is(err, jobs.ErrSchedulerStopped):
+		s.jsonError(w, r, "Background jobs are not running", http.StatusServiceUnavailable)
+		return
+	case errors.Is(err, jobs.ErrJobNotFound):
+		s.jsonError(w, r, "Reanalysis is not enabled on this server", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		s.internalError(w, r, "Failed to start reanalysis", err)
+		return
+	}
+
+	status, err := s.jobs.Status(r.Context(), ReanalysisJob)
+	if err != nil {
+		s.internalError(w, r, "Failed to load job", err)
+		return
+	}
+	s.respond(w, r, SuccessResponse{Data: status, Message: "Reanalysis started"}, http.StatusAccepted)
+}
+
+// getOperationAnalysis returns the stored result of the last reanalysis of an
+// operation's intent
+func (s *APIServer) getOperationAnalysis(w http.ResponseWriter, r *http.Request) {
+	record, err := s.engine.IntentAnalysis(r.Context(), operations.OperationID(r.PathValue("id")))
+	if err != nil {
+		s.lookupError(w, r, "Analysis", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: record}, http.StatusOK)
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/jobs"
+)
+
+// WithJobs enables the /admin/jobs endpoints
+func WithJobs(scheduler *jobs.Scheduler) ServerOption {
+	return func(s *APIServer) {
+		s.jobs = scheduler
+	}
+}
+
+// requireJobs wraps a handler that needs a job scheduler
+func (s *APIServer) requireJobs(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.jobs == nil {
+			s.jsonError(w, r, "Background jobs are not enabled on this server", http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *APIServer) listJobs(w http.ResponseWriter, r *http.Request) {
+	statuses, err := s.jobs.Jobs(r.Context())
+	if err != nil {
+		s.internalError(w, r, "Failed to list jobs", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data: statuses,
+		Meta: &ResponseMeta{Total: len(statuses)},
+	}, http.StatusOK)
+}
+
+func (s *APIServer) getJob(w http.ResponseWriter, r *http.Request) {
+	status, err := s.jobs.Status(r.Context(), r.PathValue("name"))
+	if err != nil {
+		s.lookupError(w, r, "Job", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: status}, http.StatusOK)
+}
+
+// runJob starts a job now. It runs in the background; its outcome shows in
+// the job's history.
+func (s *APIServer) runJob(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	err := s.jobs.Trigger(name)
+	switch {
+	case errors.Is(err, jobs.ErrJobRunning):
+		s.jsonError(w, r, "Job is already running", http.StatusConflict)
+		return
+	case errors.Is(err, jobs.ErrSchedulerStopped):
+		s.jsonError(w, r, "Background jobs are not running", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		s.lookupError(w, r, "Job", err)
+		return
+	}
+
+	status, err := s.jobs.Status(r.Context(), name)
+	if err != nil {
+		s.internalError(w, r, "Failed to load job", err)
+		return
+	}
+	s.respond(w, r, SuccessResponse{Data: status, Message: "Job started"}, http.StatusAccepted)
+}
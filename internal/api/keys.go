@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// RotateAPIKeyRequest says how long the secret being replaced keeps working
+type RotateAPIKeyRequest struct {
+	// GracePeriod is a duration like 24h, at most a week. Without one the old
+	// secret stops working at once.
+	GracePeriod storage.Duration `json:"grace_period,omitempty"`
+}
+
+// RotatedAPIKey is a key's new secret, shown only once, and the rotation
+// that issued it
+type RotatedAPIKey struct {
+	APIKey   string           `json:"api_key"`
+	Rotation auth.KeyRotation `json:"rotation"`
+}
+
+// rotateAPIKey rotates any author's key
+func (s *APIServer) rotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	s.rotateKey(w, r, r.PathValue("id"))
+}
+
+// rotateKey issues a new secret for the key, keeping the old one working for
+// the grace period the request asks for
+func (s *APIServer) rotateKey(w http.ResponseWriter, r *http.Request, keyID string) {
+	var req RotateAPIKeyRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var rotatedBy operations.AuthorID
+	if authContext := auth.GetAuthContext(r.Context()); authContext != nil {
+		rotatedBy = authContext.AuthorID
+	}
+
+	keyString, rotation, err := s.authManager.RotateAPIKey(keyID, req.GracePeriod.Duration(), rotatedBy)
+	switch {
+	case errors.Is(err, auth.ErrInvalidGracePeriod):
+		s.writeError(w, r, validationError("Invalid rotation", FieldError{Field: "grace_period", Message: err.Error()}))
+		return
+	case err != nil:
+		s.lookupError(w, r, "API key", err)
+		return
+	}
+
+	message := "API key rotated. Store this key securely - it won't be shown again. The previous key no longer works."
+	if rotation.PreviousExpiresAt.After(rotation.RotatedAt) {
+		message = "API key rotated. Store this key securely - it won't be shown again. The previous key works until " +
+			rotation.PreviousExpiresAt.UTC().Format(time.RFC3339) + "."
+	}
+	s.respond(w, r, SuccessResponse{
+		Data:    RotatedAPIKey{APIKey: keyString, Rotation: *rotation},
+		Message: message,
+	}, http.StatusOK)
+}
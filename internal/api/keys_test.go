@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestRotateAPIKey(t *testing.T) {
+	s, authManager := setupAuthTestServer(t)
+	alice, err := authManager.CreateAPIKey("plugin", "alice", []auth.Permission{auth.PermissionReadDocuments}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	admin, err := authManager.CreateAPIKey("admin", "admin", []auth.Permission{auth.PermissionAdmin}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	keyID := authManager.APIKeysOf("alice")[0].ID
+
+	do := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+		return recorder
+	}
+	rotate := func(body string) RotatedAPIKey {
+		recorder := do(admin, http.MethodPost, "/api/v2/auth/keys/"+keyID+"/rotate", body)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Failed to rotate key: %d %s", recorder.Code, recorder.Body)
+		}
+		var resp struct {
+			Data RotatedAPIKey `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp.Data
+	}
+	works := func(key string) bool {
+		return do(key, http.MethodGet, "/api/v2/auth/status", "").Code == http.StatusOK
+	}
+
+	if recorder := do(alice, http.MethodPost, "/api/v2/auth/keys/"+keyID+"/rotate", ""); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 rotating through the admin route without admin, got %d", recorder.Code)
+	}
+	if recorder := do(admin, http.MethodPost, "/api/v2/auth/keys/"+keyID+"/rotate", `{"grace_period":"720h"}`); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a grace period over a week, got %d", recorder.Code)
+	}
+
+	// Both secrets work during the grace period
+	first := rotate(`{"grace_period":"1h"}`)
+	if !works(alice) || !works(first.APIKey) {
+		t.Fatalf("Expected the old and new secrets to work during the grace period")
+	}
+	if first.Rotation.RotatedBy != "admin" || first.Rotation.PreviousExpiresAt.Sub(first.Rotation.RotatedAt) != time.Hour {
+		t.Errorf("Expected the rotation by admin with an hour's grace, got %+v", first.Rotation)
+	}
+
+	// Rotating without a grace period cuts off every earlier secret
+	second := rotate("")
+	if works(alice) || works(first.APIKey) {
+		t.Errorf("Expected earlier secrets to stop working")
+	}
+	if !works(second.APIKey) {
+		t.Errorf("Expected the newest secret to work")
+	}
+
+	key, err := authManager.GetAPIKey(keyID)
+	if err != nil {
+		t.Fatalf("Failed to get API key: %v", err)
+	}
+	if len(key.Rotations) != 2 || !key.Rotations[1].PreviousExpiresAt.Equal(key.Rotations[1].RotatedAt) {
+		t.Errorf("Expected both rotations recorded, got %+v", key.Rotations)
+	}
+}
+
+// Two auth managers on one directory stand in for two processes sharing it
+func TestAPIKeysSharedBetweenProcesses(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	ours, err := auth.NewAuthManager(dir)
+	if err != nil {
+		t.Fatalf("Failed to create auth manager: %v", err)
+	}
+	theirs, err := auth.NewAuthManager(dir)
+	if err != nil {
+		t.Fatalf("Failed to create auth manager: %v", err)
+	}
+	engine := collaboration.NewCollaborationEngine(store)
+	s := NewAPIServer(engine, store, store, engine.AddressResolver(), engine.ConversationManager(), engine.ContextAnalyzer(), ours)
+
+	status := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v2/auth/status", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// Settings and keys the other process saves take effect here
+	if err := theirs.EnableAuth(); err != nil {
+		t.Fatalf("Failed to enable auth: %v", err)
+	}
+	if !ours.IsAuthRequired() {
+		t.Fatal("Expected auth enabled by the other process to be required")
+	}
+	key, err := theirs.CreateAPIKey("plugin", "alice", []auth.Permission{auth.PermissionReadDocuments}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	if code := status(key); code != http.StatusOK {
+		t.Fatalf("Expected a key created by the other process to work, got %d", code)
+	}
+
+	// Neither process loses keys the other creates at the same time
+	var wg sync.WaitGroup
+	for _, manager := range []*auth.AuthManager{ours, theirs} {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := manager.CreateAPIKey("concurrent", "bob", nil, nil); err != nil {
+					t.Errorf("Failed to create API key: %v", err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	reopened, err := auth.NewAuthManager(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen auth manager: %v", err)
+	}
+	if keys := reopened.APIKeysOf("bob"); len(keys) != 20 {
+		t.Errorf("Expected all 20 keys saved, got %d", len(keys))
+	}
+
+	if err := theirs.RevokeAPIKey(reopened.APIKeysOf("alice")[0].ID); err != nil {
+		t.Fatalf("Failed to revoke API key: %v", err)
+	}
+	if code := status(key); code != http.StatusUnauthorized {
+		t.Errorf("Expected a key revoked by the other process to stop working, got %d", code)
+	}
+}
@@ -0,0 +1,15 @@
+package api
+
+import "net/http"
+
+func (s *APIServer) getAuthAttempts(w http.ResponseWriter, r *http.Request) {
+	s.respond(w, r, SuccessResponse{Data: s.authManager.Attempts().Stats()}, http.StatusOK)
+}
+
+func (s *APIServer) unlockAuthSource(w http.ResponseWriter, r *http.Request) {
+	if err := s.authManager.Attempts().Unlock(r.PathValue("source")); err != nil {
+		s.lookupError(w, r, "Lockout", err)
+		return
+	}
+	s.respondMessage(w, r, "Lockout lifted", http.StatusOK)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+)
+
+func TestAuthLockout(t *testing.T) {
+	s, authManager := setupAuthTestServer(t)
+	authManager.Attempts().SetPolicy(auth.LockoutPolicy{MaxFailures: 3, Window: time.Minute, Duration: time.Hour})
+	alice, err := authManager.CreateAPIKey("alice", "alice", []auth.Permission{auth.PermissionReadDocuments}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	admin, err := authManager.CreateAPIKey("admin", "admin", []auth.Permission{auth.PermissionAdmin}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+
+	do := func(key, remoteAddr, method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("Authorization", "Bearer "+key)
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	for i := 0; i < 3; i++ {
+		if recorder := do("guessed-key", "192.0.2.1:1234", http.MethodGet, "/api/v2/auth/status"); recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected 401 for a wrong key, got %d", recorder.Code)
+		}
+	}
+	recorder := do("guessed-key", "192.0.2.1:1234", http.MethodGet, "/api/v2/auth/status")
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected 429 with Retry-After once locked out, got %d %v", recorder.Code, recorder.Header())
+	}
+	// The key isn't tried, so a locked out address can't keep guessing
+	if recorder := do(alice, "192.0.2.1:1234", http.MethodGet, "/api/v2/auth/status"); recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected even a valid key refused from a locked out address, got %d", recorder.Code)
+	}
+	// Keys that start like alice's are counted but don't lock alice out
+	for _, addr := range []string{"203.0.113.9:1234", "203.0.113.10:1234"} {
+		for i := 0; i < 2; i++ {
+			if recorder := do(alice[:8]+"-wrong", addr, http.MethodGet, "/api/v2/auth/status"); recorder.Code != http.StatusUnauthorized {
+				t.Fatalf("Expected 401 for a wrong key, got %d", recorder.Code)
+			}
+		}
+	}
+	if recorder := do(alice, "198.51.100.7:1234", http.MethodGet, "/api/v2/auth/status"); recorder.Code != http.StatusOK {
+		t.Errorf("Expected other addresses to be unaffected, got %d", recorder.Code)
+	}
+
+	recorder = do(admin, "198.51.100.7:1234", http.MethodGet, "/api/v2/admin/auth/attempts")
+	var resp struct {
+		Data auth.AttemptStats `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode attempts: %v", err)
+	}
+	stats := resp.Data
+	if stats.Failures != 7 || stats.FailuresByReason[auth.FailureInvalidKey] != 7 || stats.Refused != 2 {
+		t.Errorf("Expected 7 invalid keys and 2 refusals, got %+v", stats)
+	}
+	if stats.Lockouts != 1 || len(stats.Locked) != 1 || stats.Locked[0].Source != "ip:192.0.2.1" {
+		t.Errorf("Expected only the guessing address locked out, got %+v", stats.Locked)
+	}
+	expected := []auth.KeyFailures{{KeyPrefix: alice[:8], Failures: 4}, {KeyPrefix: "guessed-", Failures: 3}}
+	if len(stats.KeyFailures) != 2 || stats.KeyFailures[0] != expected[0] || stats.KeyFailures[1] != expected[1] {
+		t.Errorf("Expected %+v, got %+v", expected, stats.KeyFailures)
+	}
+
+	if recorder := do(admin, "198.51.100.7:1234", http.MethodDelete, "/api/v2/admin/auth/lockouts/ip:192.0.2.1"); recorder.Code != http.StatusOK {
+		t.Fatalf("Failed to lift lockout: %d %s", recorder.Code, recorder.Body)
+	}
+	if recorder := do(alice, "192.0.2.1:1234", http.MethodGet, "/api/v2/auth/status"); recorder.Code != http.StatusOK {
+		t.Errorf("Expected the address to be let back in, got %d", recorder.Code)
+	}
+	if recorder := do(admin, "198.51.100.7:1234", http.MethodDelete, "/api/v2/admin/auth/lockouts/ip:192.0.2.1"); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 lifting a lockout that isn't there, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/logging"
+)
+
+// LogLevels are the levels the server's components log at
+type LogLevels struct {
+	// Level is what components log at unless Components sets one of their own
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+	// KnownComponents are those that have made a logger so far. Others, such
+	// as websocket before a client connects, can be given levels all the same.
+	KnownComponents []string `json:"known_components"`
+}
+
+// SetLogLevelsRequest changes log levels until the server restarts or its
+// config is reloaded. An empty Level keeps the global level, and a component
+// set to "" goes back to it.
+type SetLogLevelsRequest struct {
+	Level      string            `json:"level,omitempty"`
+	Components map[string]string `json:"components,omitempty"`
+}
+
+func levelName(level logging.LogLevel) string {
+	return strings.ToLower(level.String())
+}
+
+func currentLogLevels() LogLevels {
+	levels := LogLevels{
+		Level:           levelName(logging.GlobalLevel()),
+		Components:      map[string]string{},
+		KnownComponents: logging.Components(),
+	}
+	for component, level := range logging.ComponentLevels() {
+		levels.Components[component] = levelName(level)
+	}
+	return levels
+}
+
+func (s *APIServer) getLogLevels(w http.ResponseWriter, r *http.Request) {
+	s.respond(w, r, SuccessResponse{Data: currentLogLevels()}, http.StatusOK)
+}
+
+func (s *APIServer) setLogLevels(w http.ResponseWriter, r *http.Request) {
+	var req SetLogLevelsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	// Everything is checked before anything changes
+	var fields []FieldError
+	global, err := logging.ParseLevel(req.Level)
+	if req.Level != "" && err != nil {
+		fields = append(fields, FieldError{Field: "level", Message: "must be debug, info, warn or error"})
+	}
+	levels := make(map[string]logging.LogLevel, len(req.Components))
+	components := make([]string, 0, len(req.Components))
+	for component := range req.Components {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	for _, component := range components {
+		name := req.Components[component]
+		if component == "" {
+			fields = append(fields, FieldError{Field: "components", Message: "must not name an empty component"})
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		level, err := logging.ParseLevel(name)
+		if err != nil {
+			fields = append(fields, FieldError{Field: "components." + component, Message: "must be debug, info, warn or error"})
+			continue
+		}
+		levels[component] = level
+	}
+	if len(fields) > 0 {
+		s.writeError(w, r, validationError("Invalid log levels", fields...))
+		return
+	}
+
+	if req.Level != "" {
+		logging.SetGlobalLevel(global)
+	}
+	for _, component := range components {
+		if level, ok := levels[component]; ok {
+			logging.SetComponentLevel(component, level)
+		} else {
+			logging.ResetComponentLevel(component)
+		}
+	}
+	s.logger.Info("Log levels changed", map[string]interface{}{
+		"level":      req.Level,
+		"components": req.Components,
+	})
+
+	s.respond(w, r, SuccessResponse{
+		Data:    currentLogLevels(),
+		Message: "Log levels updated",
+	}, http.StatusOK)
+}
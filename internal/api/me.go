@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+)
+
+// CreateOwnAPIKeyRequest creates a key for the caller's own author
+type CreateOwnAPIKeyRequest struct {
+	Name string `json:"name"`
+	// Permissions default to the caller's, and can't go beyond them
+	Permissions []auth.Permission `json:"permissions,omitempty"`
+	ExpiresIn   *int              `json:"expires_in_hours,omitempty"`
+}
+
+// caller returns the auth context of a request whose key acts as an author,
+// answering the request when it doesn't
+func (s *APIServer) caller(w http.ResponseWriter, r *http.Request) (*auth.AuthContext, bool) {
+	authContext := auth.GetAuthContext(r.Context())
+	if authContext == nil {
+		s.jsonError(w, r, "Authentication required", http.StatusUnauthorized)
+		return nil, false
+	}
+	if authContext.AuthorID == "" {
+		s.jsonError(w, r, "API key has no author to manage keys for", http.StatusForbidden)
+		return nil, false
+	}
+	return authContext, true
+}
+
+// ownAPIKey looks up a key of the caller's author. Other authors' keys are
+// reported missing, as they aren't the caller's to know about.
+func (s *APIServer) ownAPIKey(w http.ResponseWriter, r *http.Request) (*auth.APIKeySummary, bool) {
+	authContext, ok := s.caller(w, r)
+	if !ok {
+		return nil, false
+	}
+	key, err := s.authManager.GetAPIKey(r.PathValue("id"))
+	if err == nil && key.AuthorID != authContext.AuthorID {
+		err = auth.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		s.lookupError(w, r, "API key", err)
+		return nil, false
+	}
+	return key, true
+}
+
+func (s *APIServer) createOwnAPIKey(w http.ResponseWriter, r *http.Request) {
+	authContext, ok := s.caller(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateOwnAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		s.writeError(w, r, validationError("Invalid request", FieldError{Field: "name", Message: "is required"}))
+		return
+	}
+	if len(req.Permissions) == 0 {
+		req.Permissions = authContext.Permissions
+	}
+	for _, perm := range req.Permissions {
+		if !authContext.HasPermission(perm) {
+			s.writeError(w, r, validationError("Invalid request", FieldError{Field: "permissions", Message: "can't include " + string(perm) + ", which the caller doesn't have"}))
+			return
+		}
+	}
+
+	var expiresIn *time.Duration
+	if req.ExpiresIn != nil {
+		duration := time.Duration(*req.ExpiresIn) * time.Hour
+		expiresIn = &duration
+	}
+
+	keyString, err := s.authManager.CreateAPIKey(req.Name, authContext.AuthorID, req.Permissions, expiresIn)
+	if err != nil {
+		s.internalError(w, r, "Failed to create API key", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data:    CreatedAPIKey{APIKey: keyString},
+		Message: "API key created successfully. Store this key securely - it won't be shown again.",
+	}, http.StatusCreated)
+}
+
+func (s *APIServer) listOwnAPIKeys(w http.ResponseWriter, r *http.Request) {
+	authContext, ok := s.caller(w, r)
+	if !ok {
+		return
+	}
+
+	keys, meta := page(r, s.authManager.APIKeysOf(authContext.AuthorID))
+	s.respond(w, r, SuccessResponse{Data: keys, Meta: meta}, http.StatusOK)
+}
+
+func (s *APIServer) rotateOwnAPIKey(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.ownAPIKey(w, r)
+	if !ok {
+		return
+	}
+	s.rotateKey(w, r, key.ID)
+}
+
+func (s *APIServer) revokeOwnAPIKey(w http.ResponseWriter, r *http.Request) {
+	key, ok := s.ownAPIKey(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.authManager.RevokeAPIKey(key.ID); err != nil {
+		s.lookupError(w, r, "API key", err)
+		return
+	}
+	s.respondMessage(w, r, "API key revoked successfully", http.StatusOK)
+}
+
+// listOwnSessions lists the WebSocket clients connected as the caller's author
+func (s *APIServer) listOwnSessions(w http.ResponseWriter, r *http.Request) {
+	authContext, ok := s.caller(w, r)
+	if !ok {
+		return
+	}
+
+	sessions := []collaboration.ClientInfo{}
+	for _, client := range s.engine.GetConnectedClients() {
+		if client.AuthorID == authContext.AuthorID {
+			sessions = append(sessions, client)
+		}
+	}
+	sessions, meta := page(r, sessions)
+	s.respond(w, r, SuccessResponse{Data: sessions, Meta: meta}, http.StatusOK)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// setupAuthTestServer serves an API that requires keys, with none created yet
+func setupAuthTestServer(t *testing.T) (*APIServer, *auth.AuthManager) {
+	dir := t.TempDir()
+	store, err := storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	authManager, err := auth.NewAuthManager(dir)
+	if err != nil {
+		t.Fatalf("Failed to create auth manager: %v", err)
+	}
+	if err := authManager.EnableAuth(); err != nil {
+		t.Fatalf("Failed to enable auth: %v", err)
+	}
+
+	engine := collaboration.NewCollaborationEngine(store)
+	return NewAPIServer(engine, store, store, engine.AddressResolver(), engine.ConversationManager(), engine.ContextAnalyzer(), authManager), authManager
+}
+
+func TestOwnAPIKeys(t *testing.T) {
+	s, authManager := setupAuthTestServer(t)
+	alice, err := authManager.CreateAPIKey("alice", "alice", []auth.Permission{auth.PermissionReadDocuments, auth.PermissionWriteDocuments}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	admin, err := authManager.CreateAPIKey("admin", "admin", []auth.Permission{auth.PermissionAdmin}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+
+	do := func(key, method, path string, body interface{}) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &payload)
+		req.Header.Set("Authorization", "Bearer "+key)
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+		return recorder
+	}
+	decode := func(recorder *httptest.ResponseRecorder, v interface{}) {
+		var resp struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if err := json.Unmarshal(resp.Data, v); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+
+	// Managing every key needs an admin key
+	if recorder := do(alice, http.MethodGet, "/api/v2/auth/keys", nil); recorder.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 listing every key without admin, got %d", recorder.Code)
+	}
+	if recorder := do(admin, http.MethodGet, "/api/v2/auth/keys", nil); recorder.Code != http.StatusOK {
+		t.Errorf("Expected an admin to list every key, got %d", recorder.Code)
+	}
+
+	escalate := CreateOwnAPIKeyRequest{Name: "sneaky", Permissions: []auth.Permission{auth.PermissionAdmin}}
+	if recorder := do(alice, http.MethodPost, "/api/v2/auth/me/keys", escalate); recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422 asking for permissions the caller lacks, got %d %s", recorder.Code, recorder.Body)
+	}
+	recorder := do(alice, http.MethodPost, "/api/v2/auth/me/keys", CreateOwnAPIKeyRequest{Name: "editor"})
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("Failed to create own key: %d %s", recorder.Code, recorder.Body)
+	}
+	var created CreatedAPIKey
+	decode(recorder, &created)
+
+	var keys []auth.APIKeySummary
+	decode(do(created.APIKey, http.MethodGet, "/api/v2/auth/me/keys", nil), &keys)
+	if len(keys) != 2 {
+		t.Fatalf("Expected alice's two keys, got %+v", keys)
+	}
+	var editor auth.APIKeySummary
+	for _, key := range keys {
+		if key.AuthorID != operations.AuthorID("alice") {
+			t.Errorf("Expected only alice's keys, got %+v", key)
+		}
+		if key.Name == "editor" {
+			editor = key
+		}
+	}
+	if len(editor.Permissions) != 2 {
+		t.Errorf("Expected the new key to have alice's permissions, got %v", editor.Permissions)
+	}
+
+	adminKeys := authManager.APIKeysOf("admin")
+	if recorder := do(alice, http.MethodPost, "/api/v2/auth/me/keys/"+adminKeys[0].ID+"/rotate", nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 rotating another author's key, got %d", recorder.Code)
+	}
+
+	recorder = do(alice, http.MethodPost, "/api/v2/auth/me/keys/"+editor.ID+"/rotate", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Failed to rotate own key: %d %s", recorder.Code, recorder.Body)
+	}
+	var rotated CreatedAPIKey
+	decode(recorder, &rotated)
+	if recorder := do(created.APIKey, http.MethodGet, "/api/v2/auth/me/keys", nil); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the old secret to stop working, got %d", recorder.Code)
+	}
+
+	var sessions []collaboration.ClientInfo
+	decode(do(rotated.APIKey, http.MethodGet, "/api/v2/auth/me/sessions", nil), &sessions)
+	if len(sessions) != 0 {
+		t.Errorf("Expected no sessions without WebSocket clients, got %+v", sessions)
+	}
+
+	if recorder := do(rotated.APIKey, http.MethodDelete, "/api/v2/auth/me/keys/"+editor.ID, nil); recorder.Code != http.StatusOK {
+		t.Fatalf("Failed to revoke own key: %d %s", recorder.Code, recorder.Body)
+	}
+	if len(authManager.APIKeysOf("alice")) != 1 {
+		t.Errorf("Expected the revoked key to be gone")
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// ndjsonFlushEvery is how many lines are written between flushes, so a
+// slow listing still reaches the client as it goes without a flush per line
+const ndjsonFlushEvery = 100
+
+// wantsNDJSON reports whether the request's Accept header asks for
+// newline delimited JSON
+func wantsNDJSON(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || mediaType != ndjsonContentType {
+			continue
+		}
+		// A quality of zero refuses it
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// ndjsonStream writes a listing as one JSON value per line, outside the
+// envelope, as its items come rather than once they are all in memory. The
+// status is sent with the first line, so errors before it are answered as
+// usual and errors after it cut the response off.
+type ndjsonStream struct {
+	server     *APIServer
+	w          http.ResponseWriter
+	r          *http.Request
+	encoder    *json.Encoder
+	controller *http.ResponseController
+	selection  fieldSelection
+	lines      int
+}
+
+// streamNDJSON starts a stream for the request, with the fields it selects
+// from each line. It writes the error and returns false when they are invalid.
+func (s *APIServer) streamNDJSON(w http.ResponseWriter, r *http.Request) (*ndjsonStream, bool) {
+	selection, errResp := requestedFields(r)
+	if errResp != nil {
+		s.writeError(w, r, errResp)
+		return nil, false
+	}
+	return &ndjsonStream{
+		server:     s,
+		w:          w,
+		r:          r,
+		encoder:    json.NewEncoder(w),
+		controller: http.NewResponseController(w),
+		selection:  selection,
+	}, true
+}
+
+func (st *ndjsonStream) start() {
+	st.w.Header().Set("Content-Type", ndjsonContentType)
+	st.w.WriteHeader(http.StatusOK)
+}
+
+// write sends item as the next line
+func (st *ndjsonStream) write(item interface{}) error {
+	if st.lines == 0 {
+		st.start()
+	}
+	if st.selection != nil {
+		projected, err := st.selection.apply(item)
+		if err != nil {
+			return err
+		}
+		item = projected
+	}
+	if err := st.encoder.Encode(item); err != nil {
+		return err
+	}
+	st.lines++
+	if st.lines%ndjsonFlushEvery == 0 {
+		// Writers that can't flush send the lines when they fill a buffer
+		st.controller.Flush()
+	}
+	return nil
+}
+
+// finish ends the stream, answering with an error instead if it fails
+// before the first line and cutting the response off if it fails after
+func (st *ndjsonStream) finish(message string, err error) {
+	if err == nil {
+		if st.lines == 0 {
+			st.start()
+		}
+		return
+	}
+	if st.lines == 0 {
+		st.server.internalError(st.w, st.r, message, err)
+		return
+	}
+	if st.r.Context().Err() != nil {
+		// The client went away, so there is no one to tell
+		return
+	}
+	st.server.abortResponse(st.r, message, err)
+}
+
+// abortResponse cuts off a response whose status has gone, so the client
+// sees it's incomplete
+func (s *APIServer) abortResponse(r *http.Request, message string, err error) {
+	s.logger.Error(message, map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"error":  err.Error(),
+	})
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNDJSONStreaming(t *testing.T) {
+	s, insert := setupTestServer(t)
+	var ids []string
+	for i := int64(1); i <= 5; i++ {
+		ids = append(ids, string(insert(i, fmt.Sprintf("line %d\n", i)).ID))
+	}
+
+	stream := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+		return recorder
+	}
+	lines := func(recorder *httptest.ResponseRecorder) []map[string]interface{} {
+		var decoded []map[string]interface{}
+		scanner := bufio.NewScanner(recorder.Body)
+		for scanner.Scan() {
+			var line map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Fatalf("Failed to decode line %q: %v", scanner.Text(), err)
+			}
+			decoded = append(decoded, line)
+		}
+		return decoded
+	}
+
+	recorder := stream("/api/v2/operations?document_id=main.go&offset=1&limit=3&fields=id", "application/json;q=0.5, application/x-ndjson")
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != ndjsonContentType {
+		t.Fatalf("Expected an NDJSON stream, got %d %q", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	got := lines(recorder)
+	if len(got) != 3 {
+		t.Fatalf("Expected the page of 3 operations, got %d lines", len(got))
+	}
+	for i, line := range got {
+		if len(line) != 1 || line["id"] != ids[i+1] {
+			t.Errorf("Expected line %d to be only operation %s's ID, got %v", i, ids[i+1], line)
+		}
+	}
+
+	if recorder := stream("/api/v2/operations?document_id=other.go", ndjsonContentType); recorder.Code != http.StatusOK || recorder.Body.Len() != 0 {
+		t.Errorf("Expected an empty stream with no operations, got %d %q", recorder.Code, recorder.Body)
+	}
+	if recorder := stream("/api/v2/operations?fields=a..b", ndjsonContentType); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for invalid fields before streaming, got %d", recorder.Code)
+	}
+	if recorder := stream("/api/v2/operations?document_id=main.go", "application/x-ndjson;q=0, application/json"); recorder.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the envelope when NDJSON is refused, got %q", recorder.Header().Get("Content-Type"))
+	}
+}
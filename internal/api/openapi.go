@@ -0,0 +1,1098 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/backup"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/events"
+	"github.com/jeremytregunna/contextdb/internal/jobs"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/replication"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+	"github.com/jeremytregunna/contextdb/internal/telemetry"
+	"github.com/jeremytregunna/contextdb/internal/webhooks"
+)
+
+// The OpenAPI document is generated from the routes registered in
+// setupRoutes and the Go types handlers decode and respond with. Only the
+// prose lives here, in endpointDocs, and every route must have an entry.
+
+const openAPIVersion = "3.1.0"
+
+// endpointDoc describes one route. Request and Response are zero values of
+// the body types, Response being the envelope's data.
+type endpointDoc struct {
+	Summary  string
+	Tag      string
+	Request  interface{}
+	Response interface{}
+	Query    []queryParam
+	// Status is the success status, 200 when unset
+	Status int
+	// Paged responses carry meta with totals
+	Paged bool
+	// Permission is the API key permission the route requires beyond authentication
+	Permission auth.Permission
+	// Raw is the content type of responses served outside the envelope
+	Raw string
+	// RawRequest is the content type of request bodies that aren't JSON
+	RawRequest string
+	// Conditional responses carry an ETag and answer If-None-Match with 304
+	Conditional bool
+	// Lines is the type of each line of the NDJSON the response streams
+	// instead when it's accepted
+	Lines interface{}
+}
+
+type queryParam struct {
+	Name        string
+	Description string
+	Type        string
+}
+
+var endpointDocs = map[string]endpointDoc{
+	"GET /api/v1/operations": {
+		Summary: "List operations from the last 24 hours, or those matching the filters given", Tag: "Operations",
+		Response: []*operations.Operation{}, Paged: true, Lines: operations.Operation{},
+		Query: []queryParam{
+			{"since", "RFC 3339 timestamp to list operations from", "string"},
+			{"author", "Only list operations by this author", "string"},
+			{"document_id", "Only list operations on this document", "string"},
+			{"branch", "Only list operations made on this branch", "string"},
+			{"tool", "Only list operations made with this tool", "string"},
+			{"ticket", "Only list operations for this ticket", "string"},
+			{"language", "Only list operations on files in this language", "string"},
+			{"offset", "Number of operations to skip", "integer"},
+			{"limit", "Maximum number of operations to return", "integer"},
+		},
+	},
+	"POST /api/v1/operations": {
+		Summary: "Create an operation", Tag: "Operations",
+		Request: CreateOperationRequest{}, Response: operations.Operation{}, Status: http.StatusCreated,
+	},
+	"GET /api/v1/operations/{id}": {
+		Summary: "Get an operation", Tag: "Operations", Response: operations.Operation{},
+		Conditional: true,
+	},
+	"GET /api/v1/operations/{id}/content": {
+		Summary: "Get an operation's content, put back together if it was split", Tag: "Operations",
+		Raw: "text/plain", Conditional: true,
+	},
+	"GET /api/v1/operations/{id}/context": {
+		Summary: "Get an operation with its inferred intent", Tag: "Analysis", Response: OperationContext{},
+	},
+	"GET /api/v1/operations/{id}/intent": {
+		Summary: "Analyze the intent behind an operation", Tag: "Analysis", Response: OperationIntent{},
+	},
+	"GET /api/v1/operations/{id}/analysis": {
+		Summary: "Get the stored result of the last reanalysis of an operation's intent", Tag: "Analysis",
+		Response: storage.IntentRecord{},
+	},
+	"GET /api/v1/documents": {
+		Summary: "List documents as a tree of directories", Tag: "Documents", Response: collaboration.DocumentTree{},
+		Query: []queryParam{
+			{"prefix", "Directory to list below", "string"},
+			{"depth", "Levels of directories to show, 0 for all; defaults to 1", "integer"},
+			{"include_deleted", "Set to true to include deleted documents", "boolean"},
+			{"stats", "Set to true to include each document's stats", "boolean"},
+		},
+	},
+	"GET /api/v1/documents/{path}": {
+		Summary: "Get a document", Tag: "Documents", Response: positioning.DocumentSnapshot{},
+		Query: []queryParam{
+			{"branch", "Render the document as it is on this branch rather than main", "string"},
+			{"stats", "Set to true to include the document's stats, on main only", "boolean"},
+		},
+		Conditional: true,
+	},
+	"DELETE /api/v1/documents/{path}": {
+		Summary: "Delete a document, keeping its history so it can be restored", Tag: "Documents",
+	},
+	"POST /api/v1/documents/{path}/merge": {
+		Summary: "Merge a document from one branch into another and report the regions both changed", Tag: "Branches",
+		Response: collaboration.MergeReport{},
+		Query: []queryParam{
+			{"from", "Branch to merge the document from", "string"},
+			{"to", "Branch to merge the document into, main by default", "string"},
+			{"dry_run", "Set to true to report the merge without making it", "boolean"},
+		},
+	},
+	"POST /api/v1/documents/{path}/restore": {
+		Summary: "Restore a deleted document", Tag: "Documents", Response: positioning.DocumentSnapshot{},
+	},
+	"GET /api/v1/documents/{path}/history": {
+		Summary: "Get the stable addresses within a document", Tag: "Documents", Response: DocumentHistory{},
+	},
+	"GET /api/v1/documents/{path}/ownership": {
+		Summary: "Get who wrote and who last touched each part of a document", Tag: "Documents",
+		Response: collaboration.Ownership{},
+		Query: []queryParam{
+			{"first_line", "First line to attribute, 1-based", "integer"},
+			{"last_line", "Last line to attribute, inclusive", "integer"},
+		},
+	},
+	"GET /api/v1/documents/{path}/constructs": {
+		Summary: "List the constructs of a document between two positions, in order", Tag: "Documents",
+		Response: []*positioning.Construct{}, Paged: true,
+		Query: []queryParam{
+			{"start", "First position to include, such as 5:alice.12:bob; open when left out", "string"},
+			{"end", "Last position to include; open when left out", "string"},
+		},
+	},
+	"GET /api/v1/documents/{path}/conversations/export": {
+		Summary: "Export the conversations anchored in a document, with the code each is anchored to", Tag: "Documents",
+		Response: collaboration.ConversationTranscript{}, Lines: collaboration.TranscriptEntry{},
+		Query: []queryParam{
+			{"format", "json, the default, or markdown for a Markdown transcript outside the envelope", "string"},
+		},
+	},
+
+	"POST /api/v1/addresses/resolve": {
+		Summary: "Resolve a stable address to its current location", Tag: "Addresses",
+		Request: ResolveAddressRequest{}, Response: addressing.ResolvedAddress{},
+	},
+	"GET /api/v1/addresses/{address}/history": {
+		Summary: "Get the movement history of a JSON-encoded stable address", Tag: "Addresses",
+		Response: []addressing.MovementRecord{},
+	},
+	"POST /api/v1/analyze/intent": {
+		Summary: "Analyze the collective intent of stored operations", Tag: "Analysis",
+		Request: BatchIntentRequest{}, Response: BatchIntentAnalysis{},
+	},
+	"POST /api/v1/auth/keys": {
+		Summary: "Create an API key for any author, shown only once", Tag: "Authentication",
+		Request: CreateAPIKeyRequest{}, Response: CreatedAPIKey{}, Status: http.StatusCreated, Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/auth/keys": {
+		Summary: "List every author's API keys", Tag: "Authentication", Response: []auth.APIKeySummary{}, Paged: true, Permission: auth.PermissionAdmin,
+	},
+	"DELETE /api/v1/auth/keys/{id}": {
+		Summary: "Revoke any API key", Tag: "Authentication", Permission: auth.PermissionAdmin,
+	},
+	"POST /api/v1/auth/keys/{id}/rotate": {
+		Summary: "Replace the secret of any API key, keeping the old one working for a grace period", Tag: "Authentication",
+		Request: RotateAPIKeyRequest{}, Response: RotatedAPIKey{}, Permission: auth.PermissionAdmin,
+	},
+	"POST /api/v1/auth/me/keys": {
+		Summary: "Create an API key for the caller's author, shown only once", Tag: "Authentication",
+		Request: CreateOwnAPIKeyRequest{}, Response: CreatedAPIKey{}, Status: http.StatusCreated,
+	},
+	"GET /api/v1/auth/me/keys": {
+		Summary: "List the caller's author's API keys", Tag: "Authentication", Response: []auth.APIKeySummary{}, Paged: true,
+	},
+	"POST /api/v1/auth/me/keys/{id}/rotate": {
+		Summary: "Replace the secret of one of the caller's keys, shown only once", Tag: "Authentication",
+		Request: RotateAPIKeyRequest{}, Response: RotatedAPIKey{},
+	},
+	"DELETE /api/v1/auth/me/keys/{id}": {
+		Summary: "Revoke one of the caller's keys", Tag: "Authentication",
+	},
+	"GET /api/v1/auth/me/sessions": {
+		Summary: "List the WebSocket sessions connected as the caller's author", Tag: "Authentication",
+		Response: []collaboration.ClientInfo{}, Paged: true,
+	},
+	"GET /api/v1/auth/status": {
+		Summary: "Describe the caller's authentication", Tag: "Authentication", Response: AuthStatus{},
+	},
+	"POST /api/v1/auth/enable": {
+		Summary: "Require API keys for every request", Tag: "Authentication",
+	},
+	"POST /api/v1/auth/disable": {
+		Summary: "Stop requiring API keys", Tag: "Authentication",
+	},
+	"POST /api/v1/auth/signing-keys": {
+		Summary: "Register an Ed25519 key that signs operations as an author", Tag: "Authentication",
+		Request: RegisterSigningKeyRequest{}, Response: auth.SigningKey{}, Status: http.StatusCreated,
+	},
+	"GET /api/v1/auth/signing-keys": {
+		Summary: "List registered signing keys", Tag: "Authentication", Response: []auth.SigningKey{}, Paged: true,
+		Query: []queryParam{{"author", "Only keys of this author", "string"}},
+	},
+	"DELETE /api/v1/auth/signing-keys/{id}": {
+		Summary: "Revoke a signing key", Tag: "Authentication",
+	},
+	"POST /api/v1/auth/signatures/require": {
+		Summary: "Refuse operations not signed by their author", Tag: "Authentication", Permission: auth.PermissionAdmin,
+	},
+	"POST /api/v1/auth/signatures/allow-unsigned": {
+		Summary: "Accept unsigned operations again", Tag: "Authentication", Permission: auth.PermissionAdmin,
+	},
+	"POST /api/v1/conversations": {
+		Summary: "Start a conversation anchored to code", Tag: "Conversations",
+		Request: CreateConversationRequest{}, Response: context.ConversationThread{}, Status: http.StatusCreated,
+	},
+	"GET /api/v1/conversations": {
+		Summary: "List conversations, most recently updated first", Tag: "Conversations",
+		Response: []*context.ConversationThread{}, Paged: true,
+		Query: []queryParam{
+			{"tag", "Only list conversations with this tag, repeat to require several", "string"},
+			{"label", "Only list conversations with this label, repeat to require several", "string"},
+			{"status", "Only list open, resolved, archived or pinned conversations", "string"},
+			{"changeset", "Only list conversations anchored to this change set", "string"},
+			{"branch", "Only list conversations about this branch", "string"},
+			{"offset", "Number of conversations to skip", "integer"},
+			{"limit", "Maximum number of conversations to return", "integer"},
+		},
+	},
+	"GET /api/v1/conversations/{id}": {
+		Summary: "Get a conversation", Tag: "Conversations", Response: context.ConversationThread{},
+		Query: []queryParam{
+			{"limit", "Return only the latest messages, at most this many", "integer"},
+			{"before", "Return only messages older than this message ID", "string"},
+		},
+	},
+	"POST /api/v1/conversations/{id}/messages": {
+		Summary: "Add a message to a conversation", Tag: "Conversations",
+		Request: AddMessageRequest{}, Response: context.Message{}, Status: http.StatusCreated,
+	},
+	"POST /api/v1/conversations/{id}/resolve": {
+		Summary: "Resolve a conversation", Tag: "Conversations",
+		Request: ResolveConversationRequest{}, Response: context.ConversationThread{},
+	},
+	"POST /api/v1/conversations/{id}/summarize": {
+		Summary: "Summarize a conversation, replacing its summary", Tag: "Conversations", Response: context.ConversationThread{},
+	},
+	"POST /api/v1/conversations/{id}/visibility": {
+		Summary: "Change who may read a conversation", Tag: "Conversations",
+		Request: SetVisibilityRequest{}, Response: context.ConversationThread{},
+	},
+	"POST /api/v1/conversations/{id}/tags": {
+		Summary: "Tag a conversation", Tag: "Conversations",
+		Request: TagsRequest{}, Response: context.ConversationThread{},
+	},
+	"DELETE /api/v1/conversations/{id}/tags/{tag}": {
+		Summary: "Remove a tag from a conversation", Tag: "Conversations", Response: context.ConversationThread{},
+	},
+	"POST /api/v1/conversations/{id}/labels": {
+		Summary: "Label a conversation", Tag: "Conversations",
+		Request: LabelsRequest{}, Response: context.ConversationThread{},
+	},
+	"DELETE /api/v1/conversations/{id}/labels/{label}": {
+		Summary: "Remove a label from a conversation", Tag: "Conversations", Response: context.ConversationThread{},
+	},
+	"GET /api/v1/tags": {
+		Summary: "List conversation tags with how many conversations carry each", Tag: "Conversations",
+		Response: []context.TagCount{}, Paged: true,
+	},
+	"GET /api/v1/labels": {
+		Summary: "List conversation labels with how many conversations carry each", Tag: "Conversations",
+		Response: []context.TagCount{}, Paged: true,
+	},
+	"GET /api/v1/decisions": {
+		Summary: "List decisions, newest first", Tag: "Decisions",
+		Response: []*context.Decision{}, Paged: true,
+		Query: []queryParam{
+			{"document", "Only list decisions anchored in this document", "string"},
+			{"thread", "Only list decisions made in this conversation", "string"},
+			{"author", "Only list decisions by this author", "string"},
+			{"current", "Set to true to leave out superseded decisions", "boolean"},
+			{"offset", "Number of decisions to skip", "integer"},
+			{"limit", "Maximum number of decisions to return", "integer"},
+		},
+	},
+	"POST /api/v1/decisions": {
+		Summary: "Record a decision anchored to code", Tag: "Decisions",
+		Request: CreateDecisionRequest{}, Response: context.Decision{}, Status: http.StatusCreated,
+	},
+	"GET /api/v1/decisions/{id}": {
+		Summary: "Get a decision", Tag: "Decisions", Response: context.Decision{},
+	},
+	"POST /api/v1/decisions/{id}/supersede": {
+		Summary: "Mark a decision as replaced by a later one", Tag: "Decisions",
+		Request: SupersedeDecisionRequest{}, Response: context.Decision{},
+	},
+	"POST /api/v1/documents/{path}/import": {
+		Summary: "Import an existing file as a new document, split into constructs", Tag: "Documents",
+		RawRequest: "text/plain", Response: collaboration.FileImport{}, Status: http.StatusCreated,
+		Query: []queryParam{
+			{"author", "Author of the imported operations", "string"},
+			{"chunking", "How to split the file into constructs: line (the default) or block", "string"},
+			{"language", "Language of the file, such as go", "string"},
+			{"branch", "Branch to import the file on, main by default", "string"},
+		},
+	},
+	"POST /api/v1/import/editor": {
+		Summary: "Import an editor extension's journal of offset edits as operations", Tag: "Imports",
+		Request: telemetry.Journal{}, Response: collaboration.JournalImport{}, Status: http.StatusCreated,
+	},
+	"GET /api/v1/reviews": {
+		Summary: "List reviews, most recently updated first", Tag: "Reviews",
+		Response: []*context.Review{}, Paged: true,
+		Query: []queryParam{
+			{"document", "Only list reviews of code in this document", "string"},
+			{"changeset", "Only list reviews of this change set", "string"},
+			{"author", "Only list reviews requested by this author", "string"},
+			{"reviewer", "Only list reviews this author was asked to review", "string"},
+			{"status", "Only list reviews in this status: pending, approved or changes_requested", "string"},
+			{"open", "Set to true to leave out resolved and archived reviews", "boolean"},
+			{"offset", "Number of reviews to skip", "integer"},
+			{"limit", "Maximum number of reviews to return", "integer"},
+		},
+	},
+	"POST /api/v1/reviews": {
+		Summary: "Request a review of a change set or range of code", Tag: "Reviews",
+		Request: CreateReviewRequest{}, Response: context.Review{}, Status: http.StatusCreated,
+	},
+	"GET /api/v1/reviews/{id}": {
+		Summary: "Get a review with each reviewer's verdict", Tag: "Reviews", Response: context.Review{},
+	},
+	"POST /api/v1/reviews/{id}/reviewers": {
+		Summary: "Request more reviewers", Tag: "Reviews",
+		Request: RequestReviewersRequest{}, Response: context.Review{},
+	},
+	"POST /api/v1/reviews/{id}/approve": {
+		Summary: "Approve a review", Tag: "Reviews",
+		Request: ReviewVerdictRequest{}, Response: context.Review{},
+	},
+	"POST /api/v1/reviews/{id}/request-changes": {
+		Summary: "Request changes on a review", Tag: "Reviews",
+		Request: ReviewVerdictRequest{}, Response: context.Review{},
+	},
+	"GET /api/v1/branches": {
+		Summary: "List branches by name", Tag: "Branches",
+		Response: []*storage.Branch{}, Paged: true,
+		Query: []queryParam{
+			{"include_merged", "Set to true to include merged branches", "boolean"},
+			{"offset", "Number of branches to skip", "integer"},
+			{"limit", "Maximum number of branches to return", "integer"},
+		},
+	},
+	"POST /api/v1/branches": {
+		Summary: "Create a branch for a line of work apart from main", Tag: "Branches",
+		Request: CreateBranchRequest{}, Response: storage.Branch{}, Status: http.StatusCreated,
+	},
+	"GET /api/v1/branches/{name}": {
+		Summary: "Get a branch", Tag: "Branches", Response: storage.Branch{},
+	},
+	"POST /api/v1/branches/{name}/merge": {
+		Summary: "Merge a branch into its base or another branch, closing it", Tag: "Branches",
+		Request: MergeBranchRequest{}, Response: collaboration.BranchMerge{},
+	},
+	"GET /api/v1/changesets": {
+		Summary: "List change sets, the operations clustered into edits one author made to one place, oldest first", Tag: "Change Sets",
+		Response: []*context.ChangeSet{}, Paged: true,
+		Query: []queryParam{
+			{"author", "Only list change sets by this author", "string"},
+			{"document", "Only list change sets in this document", "string"},
+			{"since", "RFC 3339 timestamp, only list change sets ending after it", "string"},
+			{"until", "RFC 3339 timestamp, only list change sets starting before it", "string"},
+			{"offset", "Number of change sets to skip", "integer"},
+			{"limit", "Maximum number of change sets to return", "integer"},
+		},
+	},
+	"GET /api/v1/changesets/{id}": {
+		Summary: "Get a change set with the conversations and reviews anchored to it", Tag: "Change Sets", Response: ChangeSetDetails{},
+	},
+	"GET /api/v1/tickets/{key}": {
+		Summary: "Get the operations, change sets and conversations connected to a Jira or Linear issue", Tag: "Tickets",
+		Response: collaboration.Ticket{},
+	},
+	"GET /api/v1/graph": {
+		Summary: "Get the operations, conversations and addresses connected to a node", Tag: "Graph",
+		Response: collaboration.ReferenceGraph{},
+		Query: []queryParam{
+			{"root", "Conversation ID, operation ID or JSON encoded stable address to start from", "string"},
+			{"depth", "Number of edges to follow from the root, 1 to 5, default 2", "integer"},
+		},
+	},
+	"POST /api/v1/query": {
+		Summary: "Find operations by metadata, causal history and conversations, within depth and cost limits", Tag: "Graph",
+		Request: collaboration.OperationQuery{}, Response: collaboration.QueryResult{},
+	},
+	"GET /api/v1/dag/order": {
+		Summary: "List a document's operations with each after its parents", Tag: "Graph",
+		Response: []*operations.Operation{}, Paged: true,
+		Query: []queryParam{
+			{"document", "Document path", "string"},
+			{"offset", "Number of operations to skip", "integer"},
+			{"limit", "Maximum number of operations to return", "integer"},
+		},
+	},
+	"GET /api/v1/dag/merge-base": {
+		Summary: "Get the best common ancestors of two operations", Tag: "Graph",
+		Response: MergeBase{},
+		Query: []queryParam{
+			{"a", "Operation ID", "string"},
+			{"b", "Operation ID", "string"},
+		},
+	},
+	"GET /api/v1/dag/is-ancestor": {
+		Summary: "Check whether one operation is in another's causal history", Tag: "Graph",
+		Response: Ancestry{},
+		Query: []queryParam{
+			{"ancestor", "Operation ID of the possible ancestor", "string"},
+			{"descendant", "Operation ID of the possible descendant", "string"},
+		},
+	},
+	"GET /api/v1/analysis/context/{operation_id}": {
+		Summary: "Get an operation with its inferred intent", Tag: "Analysis", Response: OperationContext{},
+	},
+	"POST /api/v1/analysis/intent": {
+		Summary: "Analyze the intent of a set of operations", Tag: "Analysis",
+		Request: AnalyzeIntentRequest{}, Response: IntentAnalysis{},
+	},
+	"GET /api/v1/analysis/intents": {
+		Summary: "Get the intent taxonomy operations are classified into", Tag: "Analysis", Response: context.IntentTaxonomy{},
+	},
+	"POST /api/v1/analysis/context-pack": {
+		Summary: "Gather code with its history, conversations, decisions and intent, rendered to fit a token budget", Tag: "Analysis",
+		Request: ContextPackRequest{}, Response: collaboration.ContextPack{},
+		Query: []queryParam{
+			{"format", "json, the default, or text for only the rendered pack outside the envelope", "string"},
+		},
+	},
+	"GET /api/v1/authors/{author}/identity": {
+		Summary: "Get the identity an author ID belongs to, with its aliases", Tag: "Reports", Response: context.Identity{},
+	},
+	"GET /api/v1/reports/activity": {
+		Summary: "Report on activity per author and across the repository, with patterns and hot spots", Tag: "Reports",
+		Response: context.ActivityReport{},
+		Query: []queryParam{
+			{"since", "RFC 3339 timestamp to report from, default a week before until", "string"},
+			{"until", "RFC 3339 timestamp to report to, default now", "string"},
+			{"author", "Only report on this author", "string"},
+			{"format", "json, the default, or markdown for a Markdown document outside the envelope", "string"},
+		},
+	},
+	"GET /api/v1/analytics/churn": {
+		Summary: "Rank the documents and ranges of lines that changed the most", Tag: "Reports",
+		Response: collaboration.ChurnReport{},
+		Query: []queryParam{
+			{"since", "RFC 3339 timestamp to count from, default a week before until", "string"},
+			{"until", "RFC 3339 timestamp to count to, default now", "string"},
+			{"sort", "operations, the default, authors or delete_ratio", "string"},
+			{"limit", "How many documents and ranges to return, default 10, at most 100", "integer"},
+		},
+	},
+	"GET /api/v1/search": {
+		Summary: "Search conversations, operations and code", Tag: "Search", Response: SearchResults{}, Paged: true,
+		Query: []queryParam{
+			{"q", "Words to search for, each matching the start of a word (required)", "string"},
+			{"type", "Restrict results to conversation, operation or code", "string"},
+			{"author", "Only match this author", "string"},
+			{"content_type", "Only match operations and documents of this content type", "string"},
+			{"document_id", "Only match operations on this document", "string"},
+			{"branch", "Only match operations made on this branch", "string"},
+			{"tool", "Only match operations made with this tool", "string"},
+			{"ticket", "Only match operations for this ticket", "string"},
+			{"language", "Only match operations on files in this language", "string"},
+			{"tag", "Only match conversations with this tag, repeat to require several", "string"},
+			{"label", "Only match conversations with this label, repeat to require several", "string"},
+			{"status", "Only match conversations with this status", "string"},
+			{"message_type", "Only match conversations holding a message of this type", "string"},
+			{"limit", "Maximum number of results, up to 1000", "integer"},
+		},
+	},
+	"GET /api/v1/search/similar": {
+		Summary: "Find operations or messages similar in meaning to one operation or message", Tag: "Search",
+		Response: SimilarResults{},
+		Query: []queryParam{
+			{"operation_id", "Operation to compare with, required unless message_id is given", "string"},
+			{"message_id", "Conversation message to compare with, required unless operation_id is given", "string"},
+			{"kind", "Find operation or message results, default operation", "string"},
+			{"limit", "Maximum number of results, up to 100, default 10", "integer"},
+		},
+	},
+	"GET /api/v1/health": {
+		Summary: "Check the server is up", Tag: "Health", Response: HealthStatus{},
+	},
+	"GET /api/v1/ws": {
+		Summary: "Open a WebSocket to sync documents and exchange operations and presence live", Tag: "Collaboration",
+		Status: http.StatusSwitchingProtocols,
+		Query:  []queryParam{{"resume_token", "Token from a dropped connection's session message, to resume that session", "string"}},
+	},
+	"GET /api/v1/admin/usage": {
+		Summary: "Get usage for every API key", Tag: "Admin", Response: []auth.KeyUsage{}, Paged: true, Permission: auth.PermissionAdmin,
+		Query: []queryParam{{"since", "Date like 2006-01-02 to count usage from", "string"}},
+	},
+	"GET /api/v1/admin/usage/{key_id}": {
+		Summary: "Get usage for one API key", Tag: "Admin", Response: auth.KeyUsage{}, Permission: auth.PermissionAdmin,
+		Query: []queryParam{{"since", "Date like 2006-01-02 to count usage from", "string"}},
+	},
+	"GET /api/v1/admin/auth/attempts": {
+		Summary: "Count authentication attempts since startup and list the sources locked out", Tag: "Admin",
+		Response: auth.AttemptStats{}, Permission: auth.PermissionAdmin,
+	},
+	"DELETE /api/v1/admin/auth/lockouts/{source}": {
+		Summary: "Lift the lockout of a client address (ip:...)", Tag: "Admin", Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/admin/log-levels": {
+		Summary: "List the global log level, the levels set for components and the components known", Tag: "Admin",
+		Response: LogLevels{}, Permission: auth.PermissionAdmin,
+	},
+	"PUT /api/v1/admin/log-levels": {
+		Summary: "Change the global log level or those of components until restart or reload", Tag: "Admin",
+		Request: SetLogLevelsRequest{}, Response: LogLevels{}, Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/admin/retention": {
+		Summary: "Get the retention policy", Tag: "Admin", Response: storage.RetentionPolicy{}, Permission: auth.PermissionAdmin,
+	},
+	"PUT /api/v1/admin/retention": {
+		Summary: "Replace the retention policy", Tag: "Admin",
+		Request: storage.RetentionPolicy{}, Response: storage.RetentionPolicy{}, Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/admin/retention/preview": {
+		Summary: "Report what enforcing the retention policy would remove", Tag: "Admin",
+		Response: collaboration.RetentionReport{}, Permission: auth.PermissionAdmin,
+	},
+	"POST /api/v1/admin/retention/enforce": {
+		Summary: "Enforce the retention policy now", Tag: "Admin",
+		Response: collaboration.RetentionReport{}, Permission: auth.PermissionAdmin,
+	},
+	"PUT /api/v1/admin/intents": {
+		Summary: "Replace the intent taxonomy operations are classified into", Tag: "Admin",
+		Request: context.IntentTaxonomy{}, Response: context.IntentTaxonomy{}, Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/admin/identities": {
+		Summary: "List the identities author IDs were merged into", Tag: "Admin",
+		Response: []context.Identity{}, Paged: true, Permission: auth.PermissionAdmin,
+		Query: []queryParam{
+			{"offset", "Number of identities to skip", "integer"},
+			{"limit", "Maximum number of identities to return", "integer"},
+		},
+	},
+	"POST /api/v1/admin/identities": {
+		Summary: "Merge author IDs into one identity, so author filters match all of them", Tag: "Admin",
+		Request: MergeIdentityRequest{}, Response: context.Identity{}, Permission: auth.PermissionAdmin,
+	},
+	"DELETE /api/v1/admin/identities/{alias}": {
+		Summary: "Split an alias out of its identity", Tag: "Admin", Permission: auth.PermissionAdmin,
+	},
+	"POST /api/v1/admin/analysis/reanalyze": {
+		Summary: "Start analyzing the intent of past operations again, storing the results apart from them", Tag: "Admin",
+		Request: collaboration.ReanalysisQuery{}, Response: jobs.Status{}, Status: http.StatusAccepted, Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/admin/stats": {
+		Summary: "Count operations, documents, conversations and clients and size the store", Tag: "Admin",
+		Response: ServerStats{}, Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/admin/fsck": {
+		Summary: "Check parent references, constructs, content hashes and the schema", Tag: "Admin",
+		Response: storage.CheckReport{}, Permission: auth.PermissionAdmin,
+	},
+	"POST /api/v1/admin/fsck/repair": {
+		Summary: "Check the store and remove orphaned references, constructs and blobs", Tag: "Admin",
+		Response: storage.CheckReport{}, Permission: auth.PermissionAdmin,
+	},
+	"POST /api/v1/admin/webhooks": {
+		Summary: "Register a webhook, its secret is shown only once", Tag: "Webhooks",
+		Request: CreateWebhookRequest{}, Response: webhooks.Webhook{}, Status: http.StatusCreated, Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/admin/webhooks": {
+		Summary: "List webhooks", Tag: "Webhooks", Response: []webhooks.Webhook{}, Paged: true, Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/admin/webhooks/{id}": {
+		Summary: "Get a webhook", Tag: "Webhooks", Response: webhooks.Webhook{}, Permission: auth.PermissionAdmin,
+	},
+	"PATCH /api/v1/admin/webhooks/{id}": {
+		Summary: "Pause or resume a webhook", Tag: "Webhooks",
+		Request: UpdateWebhookRequest{}, Response: webhooks.Webhook{}, Permission: auth.PermissionAdmin,
+	},
+	"DELETE /api/v1/admin/webhooks/{id}": {
+		Summary: "Delete a webhook", Tag: "Webhooks", Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/admin/webhooks/{id}/deliveries": {
+		Summary: "List a webhook's recent deliveries", Tag: "Webhooks", Response: []webhooks.Delivery{}, Paged: true, Permission: auth.PermissionAdmin,
+		Query: []queryParam{{"limit", "Maximum number of deliveries, 50 by default", "integer"}},
+	},
+	"POST /api/v1/admin/backup": {
+		Summary: "Back up the store now", Tag: "Admin",
+		Response: backup.Generation{}, Status: http.StatusCreated, Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/admin/backups": {
+		Summary: "List backups, newest first", Tag: "Admin", Response: []backup.Generation{}, Paged: true, Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/admin/jobs": {
+		Summary: "List background jobs with their last run", Tag: "Admin", Response: []jobs.Status{}, Paged: true, Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/admin/jobs/{name}": {
+		Summary: "Get a background job with its latest runs", Tag: "Admin", Response: jobs.Status{}, Permission: auth.PermissionAdmin,
+	},
+	"POST /api/v1/admin/jobs/{name}/run": {
+		Summary: "Start a background job now", Tag: "Admin",
+		Response: jobs.Status{}, Status: http.StatusAccepted, Permission: auth.PermissionAdmin,
+	},
+	"GET /api/v1/replication/status": {
+		Summary: "Get this node's ID, heads and the peers it has synced with", Tag: "Replication",
+		Response: replication.Status{}, Permission: auth.PermissionReplicate,
+	},
+	"POST /api/v1/replication/pull": {
+		Summary: "Get operations missing from the history ending at the given heads", Tag: "Replication",
+		Request: replication.PullQuery{}, Response: replication.Batch{}, Permission: auth.PermissionReplicate,
+	},
+	"POST /api/v1/replication/push": {
+		Summary: "Apply operations from another node", Tag: "Replication",
+		Request: replication.Batch{}, Response: replication.PushResult{}, Permission: auth.PermissionReplicate,
+	},
+	"GET /api/v1/permalink/{operation_id}": {
+		Summary: "Resolve a permalink, as HTML when the client accepts text/html", Tag: "Operations", Response: Permalink{},
+	},
+	"GET /api/v1/openapi.json": {
+		Summary: "Get this OpenAPI document", Tag: "Meta", Raw: "application/json",
+	},
+	"GET /api/v1/docs": {
+		Summary: "Browse this API with Swagger UI", Tag: "Meta", Raw: "text/html",
+	},
+}
+
+// OpenAPI returns the OpenAPI document for the v2 API
+func (s *APIServer) OpenAPI() ([]byte, error) {
+	s.openAPIOnce.Do(func() {
+		s.openAPISpec, s.openAPIErr = json.MarshalIndent(buildOpenAPI(s.routes), "", "  ")
+	})
+	return s.openAPISpec, s.openAPIErr
+}
+
+func buildOpenAPI(routes []string) map[string]interface{} {
+	schemas := newSchemaRegistry()
+	paths := map[string]map[string]interface{}{}
+
+	// This package's types keep their plain names, whichever route first
+	// mentions a same named type from another package
+	var own []reflect.Type
+	for _, doc := range endpointDocs {
+		own = append(own, schemas.reserve(doc.Request)...)
+		own = append(own, schemas.reserve(doc.Response)...)
+	}
+	for _, t := range own {
+		schemas.schemas[schemas.names[t]] = schemas.structSchema(t)
+	}
+
+	sorted := append([]string(nil), routes...)
+	sort.Strings(sorted)
+	for _, route := range sorted {
+		doc, documented := endpointDocs[route]
+		if !documented {
+			continue
+		}
+		method, pattern, _ := strings.Cut(route, " ")
+		path := strings.TrimPrefix(pattern, "/api/v1")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(method)] = doc.operation(method, path, schemas)
+	}
+
+	schemas.define("ResponseMeta", ResponseMeta{})
+	schemas.define("ErrorResponse", ErrorResponse{})
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   "ContextDB API",
+			"version": "2",
+			"description": "Every response is wrapped in an envelope with success, data, message, error and meta. " +
+				"The same routes are served under the deprecated /api/v1 prefix with their legacy response shapes.",
+		},
+		"servers": []interface{}{map[string]string{"url": "/api/v2"}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth":  map[string]string{"type": "http", "scheme": "bearer"},
+				"apiKeyQuery": map[string]string{"type": "apiKey", "in": "query", "name": "api_key"},
+			},
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "The request failed",
+					"content": jsonContent(map[string]interface{}{
+						"type":     "object",
+						"required": []string{"success", "error"},
+						"properties": map[string]interface{}{
+							"success": map[string]interface{}{"type": "boolean", "const": false},
+							"error":   schemaRef("ErrorResponse"),
+						},
+					}),
+				},
+			},
+		},
+		// Keys are only needed once auth has been enabled
+		"security": []interface{}{
+			map[string][]string{"bearerAuth": {}},
+			map[string][]string{"apiKeyQuery": {}},
+			map[string][]string{},
+		},
+	}
+}
+
+func (doc endpointDoc) operation(method, path string, schemas *schemaRegistry) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": operationID(method, path),
+		"summary":     doc.Summary,
+		"tags":        []string{doc.Tag},
+	}
+	if doc.Permission != "" {
+		op["description"] = fmt.Sprintf("Requires an API key with the %s permission.", doc.Permission)
+	}
+
+	var params []interface{}
+	for _, name := range pathParams(path) {
+		params = append(params, map[string]interface{}{
+			"name": name, "in": "path", "required": true,
+			"schema": map[string]string{"type": "string"},
+		})
+	}
+	for _, q := range doc.Query {
+		params = append(params, map[string]interface{}{
+			"name": q.Name, "in": "query", "description": q.Description,
+			"schema": map[string]string{"type": q.Type},
+		})
+	}
+	if method == http.MethodGet && doc.Raw == "" && doc.Status == 0 {
+		params = append(params, map[string]interface{}{
+			"name": "fields", "in": "query", "description": "Comma separated fields of the data to respond with, dotted to pick fields within fields",
+			"schema": map[string]string{"type": "string"},
+		})
+	}
+	if doc.Conditional {
+		params = append(params, map[string]interface{}{
+			"name": "If-None-Match", "in": "header", "description": "ETags of copies already held",
+			"schema": map[string]string{"type": "string"},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	switch {
+	case doc.RawRequest != "":
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{doc.RawRequest: map[string]interface{}{"schema": map[string]string{"type": "string"}}},
+		}
+	case doc.Request != nil:
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(schemas.schemaFor(reflect.TypeOf(doc.Request))),
+		}
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	var content map[string]interface{}
+	switch {
+	case status == http.StatusSwitchingProtocols:
+		// The connection stops being HTTP, so there is no body to describe
+	case doc.Raw != "":
+		content = map[string]interface{}{doc.Raw: map[string]interface{}{}}
+	default:
+		properties := map[string]interface{}{
+			"success": map[string]interface{}{"type": "boolean", "const": true},
+			"message": map[string]string{"type": "string"},
+		}
+		if doc.Response != nil {
+			properties["data"] = schemas.schemaFor(reflect.TypeOf(doc.Response))
+		}
+		if doc.Paged {
+			properties["meta"] = schemaRef("ResponseMeta")
+		}
+		content = jsonContent(map[string]interface{}{
+			"type":       "object",
+			"required":   []string{"success"},
+			"properties": properties,
+		})
+	}
+
+	if doc.Lines != nil {
+		content[ndjsonContentType] = map[string]interface{}{"schema": schemas.schemaFor(reflect.TypeOf(doc.Lines))}
+	}
+
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if content != nil {
+		success["content"] = content
+	}
+	responses := map[string]interface{}{
+		strconv.Itoa(status): success,
+		"default":            schemaRefTo("#/components/responses/Error"),
+	}
+	if doc.Conditional {
+		etag := map[string]interface{}{"schema": map[string]string{"type": "string"}}
+		success["headers"] = map[string]interface{}{"ETag": etag}
+		responses[strconv.Itoa(http.StatusNotModified)] = map[string]interface{}{
+			"description": "The copy held is current",
+			"headers":     map[string]interface{}{"ETag": etag},
+		}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// operationID derives a stable identifier for SDK generators, e.g.
+// getOperationsByIdIntent for GET /operations/{id}/intent
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if strings.HasPrefix(segment, "{") {
+			b.WriteString("By")
+			segment = strings.Trim(segment, "{}")
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, strings.TrimSuffix(strings.Trim(segment, "{}"), "..."))
+		}
+	}
+	return names
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+func schemaRef(name string) map[string]string {
+	return schemaRefTo("#/components/schemas/" + name)
+}
+
+func schemaRefTo(ref string) map[string]string {
+	return map[string]string{"$ref": ref}
+}
+
+// schemaRegistry turns Go types into JSON Schemas following encoding/json's
+// rules. Named structs become components so recursive types terminate.
+type schemaRegistry struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	bigIntType   = reflect.TypeOf(big.Int{})
+	rawJSONType  = reflect.TypeOf(json.RawMessage{})
+	durationType = reflect.TypeOf(storage.Duration(0))
+)
+
+// enums lists the values of string types clients are expected to send
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(operations.OperationType("")): {
+		string(operations.OpInsert), string(operations.OpDelete), string(operations.OpMove), string(operations.OpPatch),
+	},
+	reflect.TypeOf(context.MessageType("")): {
+		string(context.MsgComment), string(context.MsgQuestion), string(context.MsgAnswer),
+		string(context.MsgDecision), string(context.MsgSuggestion), string(context.MsgReview),
+		string(context.MsgApprove), string(context.MsgRequestChanges),
+	},
+	reflect.TypeOf(auth.Permission("")): {
+		string(auth.PermissionReadOperations), string(auth.PermissionWriteOperations),
+		string(auth.PermissionReadDocuments), string(auth.PermissionWriteDocuments),
+		string(auth.PermissionAnalyze), string(auth.PermissionSearch),
+		string(auth.PermissionReplicate), string(auth.PermissionAdmin), string(auth.PermissionAll),
+	},
+	reflect.TypeOf(events.Type("")): eventTypeNames(),
+}
+
+func eventTypeNames() []string {
+	var names []string
+	for _, t := range events.Types {
+		names = append(names, string(t))
+	}
+	return names
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		schemas: map[string]interface{}{},
+		names:   map[reflect.Type]string{},
+	}
+}
+
+// define registers a schema component under a fixed name
+func (sr *schemaRegistry) define(name string, v interface{}) {
+	t := reflect.TypeOf(v)
+	sr.names[t] = name
+	sr.schemas[name] = sr.structSchema(t)
+}
+
+// reserve claims the name of v's type when it's a struct from this package,
+// returning the type if its schema is still to be built
+func (sr *schemaRegistry) reserve(v interface{}) []reflect.Type {
+	if v == nil {
+		return nil
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t.PkgPath() != reflect.TypeOf(APIServer{}).PkgPath() {
+		return nil
+	}
+	if _, taken := sr.names[t]; taken {
+		return nil
+	}
+	sr.names[t] = t.Name()
+	sr.schemas[t.Name()] = nil
+	return []reflect.Type{t}
+}
+
+func (sr *schemaRegistry) schemaFor(t reflect.Type) interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]string{"type": "string", "format": "date-time"}
+	case bigIntType:
+		return map[string]string{"type": "integer"}
+	case rawJSONType:
+		return map[string]interface{}{}
+	case durationType:
+		return map[string]string{"type": "string", "description": "Go duration such as 720h"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		schema := map[string]interface{}{"type": "string"}
+		if values, ok := enums[t]; ok {
+			schema["enum"] = values
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]string{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]string{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]string{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		// Only byte slices are base64, fixed size byte arrays encode as numbers
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]string{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": sr.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": sr.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sr.structSchema(t)
+		}
+		if name, ok := sr.names[t]; ok {
+			return schemaRef(name)
+		}
+		name := sr.componentName(t)
+		sr.names[t] = name
+		sr.schemas[name] = sr.structSchema(t)
+		return schemaRef(name)
+	}
+	// interface{} holds anything
+	return map[string]interface{}{}
+}
+
+// componentName is the type's name, qualified by its package when another
+// package already took it
+func (sr *schemaRegistry) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := sr.schemas[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + name
+}
+
+func (sr *schemaRegistry) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	sr.addFields(t, properties, &required)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (sr *schemaRegistry) addFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				sr.addFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = sr.schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <title>ContextDB API</title>
+    <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+    <div id="swagger-ui"></div>
+    <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+    <script>
+        // Pass ?api_key=... through so the document loads once auth is enabled
+        window.ui = SwaggerUIBundle({
+            url: "openapi.json" + window.location.search,
+            dom_id: "#swagger-ui"
+        });
+    </script>
+</body>
+</html>
+`
+
+func (s *APIServer) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	spec, err := s.OpenAPI()
+	if err != nil {
+		s.internalError(w, r, "Failed to generate OpenAPI document", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+func (s *APIServer) getAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage))
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newRoutedServer() *APIServer {
+	s := &APIServer{mux: http.NewServeMux(), probes: http.NewServeMux()}
+	s.setupRoutes()
+	return s
+}
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	s := newRoutedServer()
+
+	registered := map[string]bool{}
+	for _, route := range s.routes {
+		registered[route] = true
+		if _, documented := endpointDocs[route]; !documented {
+			t.Errorf("Route %s has no entry in endpointDocs", route)
+		}
+	}
+	for route := range endpointDocs {
+		if !registered[route] {
+			t.Errorf("endpointDocs describes %s, which isn't registered", route)
+		}
+	}
+}
+
+func TestOpenAPI_Document(t *testing.T) {
+	s := newRoutedServer()
+
+	data, err := s.OpenAPI()
+	if err != nil {
+		t.Fatalf("Failed to generate OpenAPI document: %v", err)
+	}
+
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			RequestBody *json.RawMessage `json:"requestBody"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+				Required   []string                   `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("Failed to decode OpenAPI document: %v", err)
+	}
+
+	if spec.OpenAPI != openAPIVersion {
+		t.Errorf("Expected OpenAPI %s, got %q", openAPIVersion, spec.OpenAPI)
+	}
+
+	intent := spec.Paths["/operations/{id}/intent"]["get"]
+	if intent.OperationID != "getOperationsByIdIntent" {
+		t.Errorf("Unexpected operationId %q", intent.OperationID)
+	}
+	if len(intent.Parameters) != 2 || intent.Parameters[0].Name != "id" || intent.Parameters[0].In != "path" {
+		t.Errorf("Expected the id path parameter, got %+v", intent.Parameters)
+	}
+	if len(intent.Parameters) == 2 && (intent.Parameters[1].Name != "fields" || intent.Parameters[1].In != "query") {
+		t.Errorf("Expected GETs to take fields, got %+v", intent.Parameters)
+	}
+	if spec.Paths["/operations"]["post"].RequestBody == nil {
+		t.Error("Expected creating an operation to take a request body")
+	}
+
+	// Schemas follow json tags, including omitempty
+	request, exists := spec.Components.Schemas["CreateOperationRequest"]
+	if !exists {
+		t.Fatal("Expected a CreateOperationRequest schema")
+	}
+	if _, ok := request.Properties["expected_version"]; !ok {
+		t.Errorf("Expected json tag names as properties, got %v", request.Properties)
+	}
+	if !strings.Contains(strings.Join(request.Required, ","), "document_id") || strings.Contains(strings.Join(request.Required, ","), "content_type") {
+		t.Errorf("Unexpected required fields %v", request.Required)
+	}
+
+	// Types sharing a name across packages are both described
+	if _, exists := spec.Components.Schemas["ContextIntentAnalysis"]; !exists {
+		t.Errorf("Expected context.IntentAnalysis to be qualified, got %d schemas", len(spec.Components.Schemas))
+	}
+}
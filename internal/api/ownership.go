@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// getDocumentOwnership attributes a document, or the lines between first_line
+// and last_line, to the authors who wrote and last touched it
+func (s *APIServer) getDocumentOwnership(w http.ResponseWriter, r *http.Request) {
+	filePath := r.PathValue("path")
+	if filePath == "" {
+		s.jsonError(w, r, "Document path is required", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	lines := make(map[string]int)
+	for _, field := range []string{"first_line", "last_line"} {
+		value := query.Get(field)
+		if value == "" {
+			continue
+		}
+		line, err := strconv.Atoi(value)
+		if err != nil || line < 1 {
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: field, Message: "must be a positive line number"}))
+			return
+		}
+		lines[field] = line
+	}
+	first, last := lines["first_line"], lines["last_line"]
+	if last > 0 && first > last {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "first_line", Message: "must not be after last_line"}))
+		return
+	}
+
+	ownership, err := s.engine.DocumentOwnership(r.Context(), filePath, first, last)
+	if err != nil {
+		s.lookupError(w, r, "Document", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: ownership}, http.StatusOK)
+}
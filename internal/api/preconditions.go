@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// A document's ETag is its version, quoted. Clients send it back in If-Match
+// to write only against the version they last saw.
+func versionETag(version uint64) string {
+	return strconv.Quote(strconv.FormatUint(version, 10))
+}
+
+// An operation never changes once stored, so its ETag is its ID
+func operationETag(id operations.OperationID) string {
+	return strconv.Quote(string(id))
+}
+
+// contentETag is a branch document's ETag, its content hash. It is weak, as
+// documents with the same content can differ in version, so it can't be used
+// in If-Match.
+func contentETag(hash [32]byte) string {
+	return `W/"` + hex.EncodeToString(hash[:]) + `"`
+}
+
+// notModified marks a response as one clients may keep as long as they check
+// it is current, tagged etag, and answers a conditional GET whose
+// If-None-Match holds etag with 304 Not Modified. It reports whether it did,
+// in which case nothing more is written.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	// v1 routes answer in v2 shapes when asked to in a header
+	w.Header().Add("Vary", APIVersionHeader)
+
+	if !etagListed(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagListed reports whether an If-None-Match header lists etag, or is *. It
+// uses the weak comparison If-None-Match calls for, ignoring W/ prefixes.
+func etagListed(header, etag string) bool {
+	for _, listed := range strings.Split(header, ",") {
+		listed = strings.TrimSpace(listed)
+		if listed == "*" || (listed != "" && strings.TrimPrefix(listed, "W/") == strings.TrimPrefix(etag, "W/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedDocumentVersion reads the version an operation was written against
+// from the request body or, failing that, the If-Match header. It returns nil
+// when the client made no assertion.
+func expectedDocumentVersion(r *http.Request, fromBody *uint64) (*uint64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return fromBody, nil
+	}
+
+	// Versions are exact, so weak validators can't be honoured
+	if strings.HasPrefix(header, "W/") {
+		return nil, errors.New("If-Match must be a strong ETag")
+	}
+
+	version, err := strconv.ParseUint(strings.Trim(header, `"`), 10, 64)
+	if err != nil {
+		return nil, errors.New("If-Match must be a document version ETag")
+	}
+	if fromBody != nil && *fromBody != version {
+		return nil, errors.New("does not match the If-Match header")
+	}
+	return &version, nil
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	gocontext "context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// setupTestServer serves a fresh store without authentication, with insert
+// applying an insert to main.go at the position value
+func setupTestServer(t *testing.T) (*APIServer, func(value int64, content string) *operations.Operation) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	authManager, err := auth.NewAuthManager(dir)
+	if err != nil {
+		t.Fatalf("Failed to create auth manager: %v", err)
+	}
+	engine := collaboration.NewCollaborationEngine(store)
+	s := NewAPIServer(engine, store, store, engine.AddressResolver(), engine.ConversationManager(), engine.ContextAnalyzer(), authManager)
+
+	insert := func(value int64, content string) *operations.Operation {
+		op := &operations.Operation{
+			Type:      operations.OpInsert,
+			Position:  operations.NewLogootPosition([]operations.PositionSegment{{Value: big.NewInt(value), AuthorID: "alice"}}),
+			Content:   content,
+			Author:    "alice",
+			Timestamp: time.Now(),
+			Metadata:  operations.OperationMeta{DocumentID: "main.go"},
+		}
+		op.ID = operations.ComputeID(op)
+		if err := engine.ProcessOperation(gocontext.Background(), op, ""); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+		return op
+	}
+	return s, insert
+}
+
+func TestConditionalGet(t *testing.T) {
+	s, insert := setupTestServer(t)
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	op := insert(1, "package main\n")
+	first := get("/api/v2/documents/main.go", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag != `"1"` || first.Header().Get("Cache-Control") != "private, no-cache" {
+		t.Fatalf("Expected the document with its version as ETag, got %d %q %q", first.Code, etag, first.Header().Get("Cache-Control"))
+	}
+	unchanged := get("/api/v2/documents/main.go", `"0", W/`+etag)
+	if unchanged.Code != http.StatusNotModified || unchanged.Body.Len() != 0 || unchanged.Header().Get("ETag") != etag {
+		t.Errorf("Expected 304 without a body for an unchanged document, got %d %q", unchanged.Code, unchanged.Body)
+	}
+
+	insert(2, "func main() {}\n")
+	if changed := get("/api/v2/documents/main.go", etag); changed.Code != http.StatusOK || changed.Header().Get("ETag") != `"2"` {
+		t.Errorf("Expected the document again once it changed, got %d %q", changed.Code, changed.Header().Get("ETag"))
+	}
+	if withStats := get("/api/v2/documents/main.go?stats=true", `"2"`); withStats.Code != http.StatusOK {
+		t.Errorf("Expected stats to be sent whatever the ETag, got %d", withStats.Code)
+	}
+
+	opPath := "/api/v2/operations/" + string(op.ID)
+	opETag := get(opPath, "").Header().Get("ETag")
+	if opETag != `"`+string(op.ID)+`"` {
+		t.Errorf("Expected the operation's ID as its ETag, got %q", opETag)
+	}
+	if recorder := get(opPath, opETag); recorder.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for an operation, got %d", recorder.Code)
+	}
+	if recorder := get(opPath+"/content", "*"); recorder.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for operation content, got %d", recorder.Code)
+	}
+}
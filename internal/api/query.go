@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+)
+
+var queryIncludes = []string{collaboration.QueryIncludeConversations, collaboration.QueryIncludeAddresses}
+
+func (s *APIServer) queryOperations(w http.ResponseWriter, r *http.Request) {
+	var query collaboration.OperationQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if fields := validateQuery(query); len(fields) > 0 {
+		s.writeError(w, r, validationError("Invalid query", fields...))
+		return
+	}
+
+	result, err := s.engine.QueryOperations(r.Context(), query, conversationViewer(r))
+	if errors.Is(err, collaboration.ErrQueryTooExpensive) {
+		s.writeError(w, r, validationError("Query is too expensive", FieldError{Field: "where", Message: err.Error() + "; narrow it or lower depth"}))
+		return
+	}
+	if err != nil {
+		s.lookupError(w, r, "Query target", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: result}, http.StatusOK)
+}
+
+func validateQuery(query collaboration.OperationQuery) []FieldError {
+	var fields []FieldError
+	bounded := func(field string, value, max int) {
+		if value < 0 || value > max {
+			fields = append(fields, FieldError{Field: field, Message: fmt.Sprintf("must be between 0, for the default, and %d", max)})
+		}
+	}
+	bounded("limit", query.Limit, collaboration.MaxQueryLimit)
+	bounded("depth", query.Depth, collaboration.MaxQueryDepth)
+	bounded("max_cost", query.MaxCost, collaboration.MaxQueryCost)
+
+	for _, include := range query.Include {
+		if !slices.Contains(queryIncludes, include) {
+			fields = append(fields, FieldError{Field: "include", Message: fmt.Sprintf("%q is not one of %v", include, queryIncludes)})
+		}
+	}
+	where := query.Where
+	if !where.Since.IsZero() && !where.Until.IsZero() && !where.Until.After(where.Since) {
+		fields = append(fields, FieldError{Field: "where.until", Message: "must be after since"})
+	}
+	return fields
+}
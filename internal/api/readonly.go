@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// readOnlyPosts are the POST routes that only read, taking a body for a query
+// too large or structured for the URL. A read-only server serves them along
+// with every GET.
+var readOnlyPosts = map[string]bool{
+	"POST /api/v1/addresses/resolve":     true,
+	"POST /api/v1/analyze/intent":        true,
+	"POST /api/v1/analysis/intent":       true,
+	"POST /api/v1/analysis/context-pack": true,
+	"POST /api/v1/query":                 true,
+	"POST /api/v1/replication/pull":      true,
+}
+
+// processOnly are routes that change only the running server, not its
+// store, so a read-only server serves them too
+var processOnly = map[string]bool{
+	"PUT /api/v1/admin/log-levels": true,
+}
+
+// readsOnly reports whether the route registered as pattern leaves the store
+// unchanged
+func readsOnly(pattern string) bool {
+	method, _, _ := strings.Cut(pattern, " ")
+	return method == http.MethodGet || readOnlyPosts[pattern] || processOnly[pattern]
+}
+
+func readOnlyError() *ErrorResponse {
+	return newErrorResponse(http.StatusForbidden, ErrCodeReadOnly, "Server is read-only")
+}
+
+// rejectReadOnly stands in for the handlers of routes that change the store
+// when it was opened read-only
+func (s *APIServer) rejectReadOnly(w http.ResponseWriter, r *http.Request) {
+	s.writeError(w, r, readOnlyError())
+}
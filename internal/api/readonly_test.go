@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestReadOnlyServer(t *testing.T) {
+	ctx := gocontext.Background()
+	dir := t.TempDir()
+	writer, err := storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	newOp := func(content string) *operations.Operation {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(1), AuthorID: "alice"},
+			}),
+			Content:   content,
+			Author:    "alice",
+			Timestamp: time.Now(),
+			Metadata:  operations.OperationMeta{DocumentID: "main.go"},
+		}
+		op.ID = operations.ComputeID(op)
+		return op
+	}
+	writing := collaboration.NewCollaborationEngine(writer)
+	if err := writing.ProcessOperation(ctx, newOp("package main\n"), ""); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+	if err := writing.Shutdown(ctx); err != nil {
+		t.Fatalf("Failed to shut down engine: %v", err)
+	}
+
+	store, err := storage.OpenContextStoreReadOnly(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store read-only: %v", err)
+	}
+	defer store.Close()
+	engine := collaboration.NewCollaborationEngine(store)
+	if err := engine.ProcessOperation(ctx, newOp("refused\n"), ""); !errors.Is(err, storage.ErrReadOnly) {
+		t.Errorf("Expected the engine to refuse operations with ErrReadOnly, got %v", err)
+	}
+	authManager, err := auth.NewAuthManager(dir)
+	if err != nil {
+		t.Fatalf("Failed to create auth manager: %v", err)
+	}
+	s := NewAPIServer(engine, store, store, engine.AddressResolver(), engine.ConversationManager(), engine.ContextAnalyzer(), authManager)
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, httptest.NewRequest(method, path, &payload))
+		return recorder
+	}
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodPost, "/api/v2/operations"},
+		{http.MethodDelete, "/api/v2/documents/main.go"},
+		{http.MethodPost, "/api/v1/conversations"},
+		{http.MethodPut, "/api/v2/admin/retention"},
+	} {
+		recorder := do(req.method, req.path, map[string]interface{}{})
+		var resp struct {
+			Error ErrorResponse `json:"error"`
+		}
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("Expected %s %s to be forbidden, got %d", req.method, req.path, recorder.Code)
+		} else if req.path[:7] == "/api/v2" && (json.Unmarshal(recorder.Body.Bytes(), &resp) != nil || resp.Error.Code != ErrCodeReadOnly) {
+			t.Errorf("Expected the read_only code, got %s", recorder.Body)
+		}
+	}
+
+	if recorder := do(http.MethodGet, "/api/v2/documents/main.go", nil); recorder.Code != http.StatusOK {
+		t.Errorf("Expected documents to be served, got %d %s", recorder.Code, recorder.Body)
+	}
+	query := map[string]interface{}{"where": map[string]interface{}{"document_id": "main.go"}}
+	if recorder := do(http.MethodPost, "/api/v2/query", query); recorder.Code == http.StatusForbidden {
+		t.Errorf("Expected queries to be served, got %d %s", recorder.Code, recorder.Body)
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+)
+
+// ServerStats is what admin stats report: the engine's along with the API
+// server's own counters
+type ServerStats struct {
+	collaboration.Stats
+	// RecoveredPanics counts handlers that panicked since startup
+	RecoveredPanics uint64 `json:"recovered_panics"`
+}
+
+// InternalErrorDetails names the logged failure behind a 500, for reports
+type InternalErrorDetails struct {
+	ErrorID string `json:"error_id"`
+}
+
+// recoverPanics serves the request through next, answering a panic with a
+// 500 carrying an error ID that is logged with the stack. A response that
+// has started can't be answered, so it is cut off instead, as are those
+// whose handler panicked with http.ErrAbortHandler to cut them off itself.
+func (s *APIServer) recoverPanics(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	// Headers set for the response that failed mustn't reach the error
+	header := w.Header().Clone()
+	sw := &startedWriter{ResponseWriter: w}
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if recovered == http.ErrAbortHandler {
+			panic(recovered)
+		}
+
+		s.panics.Add(1)
+		errorID := newErrorID()
+		s.logger.Error("Handler panicked", map[string]interface{}{
+			"error_id": errorID,
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"panic":    fmt.Sprint(recovered),
+			"stack":    string(debug.Stack()),
+		})
+		if sw.started {
+			panic(http.ErrAbortHandler)
+		}
+
+		clear(w.Header())
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		e := newErrorResponse(http.StatusInternalServerError, ErrCodeInternal, "Internal server error, error ID "+errorID)
+		e.Details = InternalErrorDetails{ErrorID: errorID}
+		s.writeError(w, r, e)
+	}()
+
+	next(sw, r)
+}
+
+func newErrorID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// startedWriter notes whether a response has started, after which its
+// status can no longer change
+type startedWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (sw *startedWriter) WriteHeader(status int) {
+	// Informational responses leave the real one to come
+	if status >= 200 || status == http.StatusSwitchingProtocols {
+		sw.started = true
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *startedWriter) Write(p []byte) (int, error) {
+	sw.started = true
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *startedWriter) Flush() {
+	sw.FlushError()
+}
+
+func (sw *startedWriter) FlushError() error {
+	sw.started = true
+	return http.NewResponseController(sw.ResponseWriter).Flush()
+}
+
+func (sw *startedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	sw.started = true
+	return http.NewResponseController(sw.ResponseWriter).Hijack()
+}
+
+func (sw *startedWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	s, _ := setupTestServer(t)
+	s.mux.HandleFunc("GET /api/v2/panic", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"1"`)
+		panic("boom")
+	})
+	s.mux.HandleFunc("GET /api/v2/panic/streaming", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "{}\n")
+		panic("boom")
+	})
+	s.mux.HandleFunc("GET /api/v2/panic/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	serve := func(path string) (recorder *httptest.ResponseRecorder, recovered interface{}) {
+		recorder = httptest.NewRecorder()
+		defer func() { recovered = recover() }()
+		s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder, nil
+	}
+
+	recorder, recovered := serve("/api/v2/panic")
+	if recovered != nil || recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected a 500 for a panic, got %d and %v", recorder.Code, recovered)
+	}
+	var response struct {
+		Error struct {
+			Message string
+			Details InternalErrorDetails
+		}
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode error: %v", err)
+	}
+	errorID := response.Error.Details.ErrorID
+	if len(errorID) != 16 || !strings.Contains(response.Error.Message, errorID) {
+		t.Errorf("Expected an error ID in the message and details, got %+v", response.Error)
+	}
+	if recorder.Header().Get("ETag") != "" || recorder.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Errorf("Expected the failed response's headers dropped and the server's kept, got %v", recorder.Header())
+	}
+	if !strings.Contains(output.String(), errorID) || !strings.Contains(output.String(), "recover_test.go") {
+		t.Errorf("Expected the error ID logged with the stack, got %q", output.String())
+	}
+
+	// A started response can only be cut off
+	if _, recovered := serve("/api/v2/panic/streaming"); recovered != http.ErrAbortHandler {
+		t.Errorf("Expected a started response to be aborted, got %v", recovered)
+	}
+	if _, recovered := serve("/api/v2/panic/abort"); recovered != http.ErrAbortHandler {
+		t.Errorf("Expected an abort to pass through, got %v", recovered)
+	}
+
+	recorder, _ = serve("/api/v2/admin/stats")
+	var stats struct{ Data ServerStats }
+	if err := json.Unmarshal(recorder.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats.Data.RecoveredPanics != 2 {
+		t.Errorf("Expected 2 recovered panics, not counting the abort, got %d", stats.Data.RecoveredPanics)
+	}
+}
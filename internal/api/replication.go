@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/replication"
+)
+
+// WithReplication enables the /replication endpoints other nodes sync through
+func WithReplication(node *replication.Node) ServerOption {
+	return func(s *APIServer) {
+		s.replication = node
+	}
+}
+
+// requireReplication wraps a handler that needs replication to be configured
+// and a key with the replicate permission
+func (s *APIServer) requireReplication(handler http.HandlerFunc) http.HandlerFunc {
+	return s.requirePermission(auth.PermissionReplicate, func(w http.ResponseWriter, r *http.Request) {
+		if s.replication == nil {
+			s.jsonError(w, r, "Replication is not enabled on this server", http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, r)
+	})
+}
+
+func (s *APIServer) getReplicationStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := s.replication.Status(r.Context())
+	if err != nil {
+		s.internalError(w, r, "Failed to read replication status", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: status}, http.StatusOK)
+}
+
+func (s *APIServer) pullOperations(w http.ResponseWriter, r *http.Request) {
+	var query replication.PullQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	batch, err := s.replication.Pull(r.Context(), query)
+	if err != nil {
+		s.replicationError(w, r, "Failed to pull operations", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: batch}, http.StatusOK)
+}
+
+func (s *APIServer) pushOperations(w http.ResponseWriter, r *http.Request) {
+	var batch replication.Batch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.replication.Push(r.Context(), batch)
+	if err != nil {
+		s.replicationError(w, r, "Failed to apply operations", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: result}, http.StatusOK)
+}
+
+func (s *APIServer) replicationError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	if errors.Is(err, replication.ErrSelfSync) {
+		s.writeError(w, r, validationError("Invalid replication request", FieldError{Field: "node_id", Message: err.Error()}))
+		return
+	}
+	if e := operationError(err); e != nil {
+		s.writeError(w, r, e)
+		return
+	}
+	s.internalError(w, r, message, err)
+}
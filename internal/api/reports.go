@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// getActivityReport reports on the week before now unless since or until say
+// otherwise. format=markdown returns the report as Markdown outside the envelope.
+func (s *APIServer) getActivityReport(w http.ResponseWriter, r *http.Request) {
+	since, until, fieldErr := parseWindow(r, context.ReportPeriod)
+	if fieldErr != nil {
+		s.writeError(w, r, validationError("Invalid query parameter", *fieldErr))
+		return
+	}
+	period := context.TimePeriod{Start: since, End: until}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "markdown" {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "format", Message: "must be json or markdown"}))
+		return
+	}
+
+	report, err := s.engine.ActivityReport(r.Context(), period, operations.AuthorID(query.Get("author")))
+	if err != nil {
+		s.internalError(w, r, "Failed to build activity report", err)
+		return
+	}
+
+	if format == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(report.Markdown()))
+		return
+	}
+	s.respond(w, r, SuccessResponse{Data: report}, http.StatusOK)
+}
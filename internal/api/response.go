@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// APIResponse is the envelope every /api/v2 endpoint responds with
+type APIResponse struct {
+	Success bool           `json:"success"`
+	Data    interface{}    `json:"data,omitempty"`
+	Message string         `json:"message,omitempty"`
+	Error   *ErrorResponse `json:"error,omitempty"`
+	Meta    *ResponseMeta  `json:"meta,omitempty"`
+}
+
+type ResponseMeta struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit,omitempty"`
+	Offset int `json:"offset,omitempty"`
+}
+
+// page applies the request's offset and limit query parameters to items
+// already held in memory
+func page[T any](r *http.Request, items []T) ([]T, *ResponseMeta) {
+	meta := &ResponseMeta{Total: len(items)}
+	if offset, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && offset > 0 {
+		meta.Offset = min(offset, len(items))
+	}
+	items = items[meta.Offset:]
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 {
+		meta.Limit = limit
+		items = items[:min(limit, len(items))]
+	}
+	return items, meta
+}
+
+func (s *APIServer) writeJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+// respond writes a successful response in the shape expected by the request's API version
+func (s *APIServer) respond(w http.ResponseWriter, r *http.Request, resp SuccessResponse, statusCode int) {
+	data, ok := s.selectFields(w, r, resp.Data)
+	if !ok {
+		return
+	}
+	resp.Data = data
+
+	if requestAPIVersion(r) == APIv1 {
+		s.writeJSON(w, legacySuccess(resp), statusCode)
+		return
+	}
+
+	s.writeJSON(w, APIResponse{
+		Success: true,
+		Data:    resp.Data,
+		Message: resp.Message,
+		Meta:    resp.Meta,
+	}, statusCode)
+}
+
+func (s *APIServer) respondMessage(w http.ResponseWriter, r *http.Request, message string, statusCode int) {
+	s.respondLegacy(w, r, SuccessResponse{Message: message}, map[string]string{"message": message}, statusCode)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestVersionedResponses(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	authManager, err := auth.NewAuthManager(dir)
+	if err != nil {
+		t.Fatalf("Failed to create auth manager: %v", err)
+	}
+	engine := collaboration.NewCollaborationEngine(store)
+	manager := engine.ConversationManager()
+	s := NewAPIServer(engine, store, store, engine.AddressResolver(), manager, engine.ContextAnalyzer(), authManager)
+
+	if _, err := manager.CreateConversation(addressing.StableAddress{}, "alice", "Cache size", "Why so small?"); err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+
+	get := func(path, version string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if version != "" {
+			req.Header.Set(APIVersionHeader, version)
+		}
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode %s: %v", path, err)
+		}
+		return recorder, body
+	}
+	keys := func(body map[string]json.RawMessage) []string {
+		var names []string
+		for name := range body {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		status     int
+		keys       []string
+		version    string
+		deprecated bool
+	}{
+		{"v1 list", "/api/v1/conversations?limit=1", "", http.StatusOK, []string{"data"}, "1", true},
+		{"v2 list", "/api/v2/conversations?limit=1", "", http.StatusOK, []string{"data", "meta", "success"}, "2", false},
+		{"v1 opting into v2", "/api/v1/conversations?limit=1", "2", http.StatusOK, []string{"data", "meta", "success"}, "2", true},
+		{"v1 error", "/api/v1/conversations/missing", "", http.StatusNotFound, []string{"error"}, "1", true},
+		{"v2 error", "/api/v2/conversations/missing", "", http.StatusNotFound, []string{"error", "success"}, "2", false},
+	}
+	for _, tt := range tests {
+		recorder, body := get(tt.path, tt.header)
+		if recorder.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.status, recorder.Code)
+		}
+		if got := keys(body); !slices.Equal(got, tt.keys) {
+			t.Errorf("%s: expected the body to have %v, got %v", tt.name, tt.keys, got)
+		}
+		if version := recorder.Header().Get(APIVersionHeader); version != tt.version {
+			t.Errorf("%s: expected %s %s, got %q", tt.name, APIVersionHeader, tt.version, version)
+		}
+		if deprecated := recorder.Header().Get("Deprecation") != ""; deprecated != tt.deprecated {
+			t.Errorf("%s: expected deprecated %v, got headers %v", tt.name, tt.deprecated, recorder.Header())
+		}
+	}
+
+	recorder, body := get("/api/v2/conversations?limit=1", "")
+	var meta ResponseMeta
+	if string(body["success"]) != "true" || json.Unmarshal(body["meta"], &meta) != nil || meta.Total != 1 || meta.Limit != 1 {
+		t.Errorf("Expected a successful v2 envelope with paging meta, got %s", recorder.Body)
+	}
+	_, body = get("/api/v2/conversations/missing", "")
+	var apiErr ErrorResponse
+	if string(body["success"]) != "false" || json.Unmarshal(body["error"], &apiErr) != nil || apiErr.Code != ErrCodeNotFound || apiErr.Status != http.StatusNotFound {
+		t.Errorf("Expected a not_found error in the v2 envelope, got %+v", apiErr)
+	}
+
+	// Endpoints whose v1 body predates the envelope keep it verbatim
+	_, body = get("/api/v1/health", "")
+	if _, ok := body["status"]; !ok {
+		t.Errorf("Expected the bare v1 health report, got %v", keys(body))
+	}
+	_, body = get("/api/v2/health", "")
+	if _, ok := body["data"]; !ok || string(body["success"]) != "true" {
+		t.Errorf("Expected the v2 health report in the envelope, got %v", keys(body))
+	}
+}
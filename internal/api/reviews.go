@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// CreateReviewRequest asks for a review of a change set, a range of code
+// given by its anchor address, or both
+type CreateReviewRequest struct {
+	ChangeSetID   context.ChangeSetID      `json:"changeset_id,omitempty"`
+	AnchorAddress addressing.StableAddress `json:"anchor_address"`
+	AuthorID      operations.AuthorID      `json:"author_id"`
+	Title         string                   `json:"title"`
+	Content       string                   `json:"content"`
+	Reviewers     []operations.AuthorID    `json:"reviewers"`
+	// Visibility limits who may read the review, public by default.
+	// Reviewers may always read it.
+	Visibility context.Visibility `json:"visibility,omitempty"`
+}
+
+type RequestReviewersRequest struct {
+	Reviewers []operations.AuthorID `json:"reviewers"`
+}
+
+type ReviewVerdictRequest struct {
+	AuthorID operations.AuthorID `json:"author_id"`
+	Content  string              `json:"content,omitempty"`
+}
+
+func (s *APIServer) listReviews(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := context.ReviewFilter{
+		ChangeSet: context.ChangeSetID(query.Get("changeset")),
+		Author:    operations.AuthorID(query.Get("author")),
+		Reviewer:  operations.AuthorID(query.Get("reviewer")),
+		Status:    context.ReviewStatus(query.Get("status")),
+		Open:      query.Get("open") == "true",
+		Viewer:    conversationViewer(r),
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "status", Message: "must be pending, approved or changes_requested"}))
+		return
+	}
+
+	var reviews []*context.Review
+	if document := query.Get("document"); document != "" {
+		reviews = s.engine.ReviewsInDocument(r.Context(), document, filter)
+	} else {
+		reviews = s.contextManager.Reviews(filter)
+	}
+
+	reviews, meta := page(r, reviews)
+	s.respond(w, r, SuccessResponse{Data: reviews, Meta: meta}, http.StatusOK)
+}
+
+func (s *APIServer) createReview(w http.ResponseWriter, r *http.Request) {
+	var req CreateReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	if req.Visibility == "" {
+		req.Visibility = context.VisibilityPublic
+	}
+	var fields []FieldError
+	if req.Title == "" {
+		fields = append(fields, FieldError{Field: "title", Message: "is required"})
+	}
+	if req.ChangeSetID == "" && req.AnchorAddress.OperationID == "" {
+		fields = append(fields, FieldError{Field: "changeset_id", Message: "a change set or anchor address is required"})
+	}
+	if len(req.Reviewers) == 0 {
+		fields = append(fields, FieldError{Field: "reviewers", Message: "is required"})
+	}
+	if !req.Visibility.IsValid() {
+		fields = append(fields, FieldError{Field: "visibility", Message: "must be public, participants or private"})
+	}
+	if len(fields) > 0 {
+		s.writeError(w, r, validationError("Invalid review", fields...))
+		return
+	}
+
+	if req.ChangeSetID != "" {
+		if _, err := s.engine.GetChangeSet(r.Context(), req.ChangeSetID); err != nil {
+			if isNotFound(err) {
+				s.writeError(w, r, validationError("Invalid review", FieldError{Field: "changeset_id", Message: "no such change set"}))
+			} else {
+				s.internalError(w, r, "Failed to load change set", err)
+			}
+			return
+		}
+	}
+
+	review, err := s.contextManager.CreateReview(req.ChangeSetID, req.AnchorAddress, req.AuthorID, req.Title, req.Content, req.Reviewers,
+		context.WithVisibility(req.Visibility, req.Reviewers...))
+	if err != nil {
+		s.internalError(w, r, "Failed to create review", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data:    review,
+		Message: "Review requested successfully",
+	}, http.StatusCreated)
+}
+
+func (s *APIServer) getReview(w http.ResponseWriter, r *http.Request) {
+	review, ok := s.viewReview(w, r)
+	if !ok {
+		return
+	}
+	s.respond(w, r, SuccessResponse{Data: review}, http.StatusOK)
+}
+
+func (s *APIServer) requestReviewers(w http.ResponseWriter, r *http.Request) {
+	var req RequestReviewersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if len(req.Reviewers) == 0 {
+		s.writeError(w, r, validationError("Invalid reviewers", FieldError{Field: "reviewers", Message: "is required"}))
+		return
+	}
+
+	review, ok := s.viewReview(w, r)
+	if !ok {
+		return
+	}
+	review, err := s.contextManager.RequestReviewers(review.ID, req.Reviewers...)
+	if err != nil {
+		s.lookupError(w, r, "Review", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: review}, http.StatusOK)
+}
+
+func (s *APIServer) approveReview(w http.ResponseWriter, r *http.Request) {
+	s.submitVerdict(w, r, context.MsgApprove)
+}
+
+func (s *APIServer) requestReviewChanges(w http.ResponseWriter, r *http.Request) {
+	s.submitVerdict(w, r, context.MsgRequestChanges)
+}
+
+// submitVerdict adds a reviewer's verdict to a review as a typed message
+func (s *APIServer) submitVerdict(w http.ResponseWriter, r *http.Request, verdict context.MessageType) {
+	var req ReviewVerdictRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if req.AuthorID == "" {
+		s.writeError(w, r, validationError("Invalid verdict", FieldError{Field: "author_id", Message: "is required"}))
+		return
+	}
+
+	review, ok := s.viewReview(w, r)
+	if !ok {
+		return
+	}
+	review, err := s.contextManager.SubmitVerdict(review.ID, req.AuthorID, verdict, req.Content)
+	if err != nil {
+		if isNotFound(err) {
+			s.lookupError(w, r, "Review", err)
+			return
+		}
+		s.internalError(w, r, "Failed to submit verdict", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: review}, http.StatusOK)
+}
+
+// viewReview looks up the review in the request's path, answering not found
+// when the caller may not read its thread
+func (s *APIServer) viewReview(w http.ResponseWriter, r *http.Request) (*context.Review, bool) {
+	review, err := s.contextManager.GetReview(context.ThreadID(r.PathValue("id")))
+	if err == nil && !s.canViewConversation(r, review.ID) {
+		err = context.ErrReviewNotFound
+	}
+	if err != nil {
+		s.lookupError(w, r, "Review", err)
+		return nil, false
+	}
+	return review, true
+}
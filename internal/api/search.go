@@ -0,0 +1,236 @@
+package api
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// Search ranks matches from the store's full text index. Every word of the
+// query must match the start of a word in a result.
+func (s *APIServer) search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	searchQuery := query.Get("q")
+	searchType := query.Get("type")
+	authorFilter := query.Get("author")
+	contentType := query.Get("content_type")
+	limitStr := query.Get("limit")
+
+	if searchQuery == "" {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "q", Message: "is required"}))
+		return
+	}
+
+	if contentType != "" && !operations.IsValidContentType(contentType) {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "content_type", Message: "unknown content type"}))
+		return
+	}
+
+	filter, errResp := conversationFilter(r)
+	if errResp != nil {
+		s.writeError(w, r, errResp)
+		return
+	}
+	messageType := context.MessageType(query.Get("message_type"))
+	if messageType != "" && !messageType.IsValid() {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "message_type", Message: "unknown message type"}))
+		return
+	}
+	// Only conversations carry tags, labels, statuses and message types
+	if len(filter.Tags) > 0 || len(filter.Labels) > 0 || filter.Status != "" || messageType != "" {
+		if searchType == "" {
+			searchType = "conversation"
+		} else if searchType != "conversation" {
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "type", Message: "tag, label, status and message_type only apply to conversations"}))
+			return
+		}
+	}
+
+	// Only operations carry typed metadata
+	metadata := storage.SearchQuery{
+		DocumentID: query.Get("document_id"),
+		Branch:     query.Get("branch"),
+		Tool:       query.Get("tool"),
+		Ticket:     query.Get("ticket"),
+		Language:   query.Get("language"),
+	}
+	if metadata.DocumentID != "" || metadata.Branch != "" || metadata.Tool != "" || metadata.Ticket != "" || metadata.Language != "" {
+		if searchType == "" {
+			searchType = "operation"
+		} else if searchType != "operation" {
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "type", Message: "document_id, branch, tool, ticket and language only apply to operations"}))
+			return
+		}
+	}
+
+	// Parse limit
+	limit := 50 // Default limit
+	if limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 1000 {
+			limit = parsedLimit
+		}
+	}
+
+	s.recordUsage(r, func(usage *auth.UsageTracker, keyID string) error {
+		return usage.RecordSearch(keyID)
+	})
+
+	kinds := []storage.SearchKind{storage.SearchConversation, storage.SearchOperation, storage.SearchCode}
+	switch kind := storage.SearchKind(searchType); kind {
+	case storage.SearchConversation, storage.SearchOperation, storage.SearchCode:
+		kinds = []storage.SearchKind{kind}
+	}
+
+	results := []SearchResult{}
+	for _, kind := range kinds {
+		kindResults, err := s.searchKind(r.Context(), conversationViewer(r), storage.SearchQuery{
+			Text:        searchQuery,
+			Kind:        kind,
+			Author:      authorFilter,
+			ContentType: contentType,
+			Tags:        filter.Tags,
+			Labels:      filter.Labels,
+			Status:      string(filter.Status),
+			MessageType: string(messageType),
+			DocumentID:  metadata.DocumentID,
+			Branch:      metadata.Branch,
+			Tool:        metadata.Tool,
+			Ticket:      metadata.Ticket,
+			Language:    metadata.Language,
+			Limit:       limit,
+		})
+		if err != nil {
+			s.internalError(w, r, "Search failed", err)
+			return
+		}
+		results = append(results, kindResults...)
+	}
+
+	// Each kind is already ranked, searching all of them interleaves by score
+	if len(kinds) > 1 {
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+		if len(results) > limit {
+			results = results[:limit]
+		}
+	}
+
+	searchResults := SearchResults{
+		Query:       searchQuery,
+		Type:        searchType,
+		Author:      authorFilter,
+		ContentType: contentType,
+		Tags:        filter.Tags,
+		Labels:      filter.Labels,
+		Status:      filter.Status,
+		MessageType: messageType,
+		DocumentID:  metadata.DocumentID,
+		Branch:      metadata.Branch,
+		Tool:        metadata.Tool,
+		Ticket:      metadata.Ticket,
+		Language:    metadata.Language,
+		Results:     results,
+		Total:       len(results),
+		Limit:       limit,
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data: searchResults,
+		Meta: &ResponseMeta{Total: len(results), Limit: limit},
+	}, http.StatusOK)
+}
+
+// searchKind runs one kind of search and fills in each hit from what it
+// refers to. Hits whose subject went away since it was indexed are dropped.
+func (s *APIServer) searchKind(ctx gocontext.Context, viewer context.Viewer, query storage.SearchQuery) ([]SearchResult, error) {
+	// Binary content isn't indexed
+	if query.Kind == storage.SearchCode && operations.NormalizeContentType(query.ContentType) == operations.ContentTypeBinary {
+		return nil, nil
+	}
+
+	hits, err := s.engine.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		result := SearchResult{
+			Type:     string(hit.Kind),
+			ID:       hit.Ref,
+			Score:    hit.Score,
+			Snippet:  highlightedSnippet(hit.Snippets),
+			Snippets: hit.Snippets,
+		}
+
+		switch hit.Kind {
+		case storage.SearchConversation:
+			conv, err := s.contextManager.ViewConversation(context.ThreadID(hit.Ref), viewer)
+			if err != nil {
+				continue
+			}
+			result.Title = conv.Title
+			result.Content = snippetText(hit.Snippets)
+			result.Timestamp = &conv.CreatedAt
+			result.Address = conv.AnchorAddress
+			result.Metadata = map[string]interface{}{"participants": len(conv.Participants), "messages": len(conv.Messages)}
+
+		case storage.SearchOperation:
+			op, err := s.store.GetOperation(ctx, operations.OperationID(hit.Ref))
+			if err != nil {
+				continue
+			}
+			result.ID = fmt.Sprintf("%x", op.ID)
+			result.Content = op.Content
+			result.Author = string(op.Author)
+			result.Timestamp = &op.Timestamp
+			result.Metadata = map[string]interface{}{"type": op.Type, "position": op.Position, "content_type": operations.NormalizeContentType(op.ContentType)}
+
+		case storage.SearchCode:
+			doc, err := s.documentStore.GetDocument(ctx, hit.Ref)
+			if err != nil {
+				continue
+			}
+			result.Title = hit.Ref
+			result.Content = snippetText(hit.Snippets)
+			result.Metadata = map[string]interface{}{"constructs": doc.ConstructCount(), "version": doc.Version}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// snippetText is the text of the best snippet
+func snippetText(snippets []storage.SearchSnippet) string {
+	if len(snippets) == 0 {
+		return ""
+	}
+	return snippets[0].Text
+}
+
+// highlightedSnippet renders the best snippet with its matches in ** markers,
+// for clients that show Snippet as is
+func highlightedSnippet(snippets []storage.SearchSnippet) string {
+	if len(snippets) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	snippet, last := snippets[0], 0
+	for _, h := range snippet.Highlights {
+		b.WriteString(snippet.Text[last:h.Start])
+		b.WriteString("**")
+		b.WriteString(snippet.Text[h.Start:h.End])
+		b.WriteString("**")
+		last = h.End
+	}
+	b.WriteString(snippet.Text[last:])
+	return b.String()
+}
@@ -1,24 +1,35 @@
 package api
 
 import (
+	gocontext "context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
 	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jeremytregunna/contextdb/internal/addressing"
 	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/backup"
 	"github.com/jeremytregunna/contextdb/internal/collaboration"
 	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/embeddings"
+	"github.com/jeremytregunna/contextdb/internal/jobs"
+	"github.com/jeremytregunna/contextdb/internal/logging"
 	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/replication"
 	"github.com/jeremytregunna/contextdb/internal/storage"
+	"github.com/jeremytregunna/contextdb/internal/summaries"
+	"github.com/jeremytregunna/contextdb/internal/webhooks"
 )
 
 type APIServer struct {
 	mux             *http.ServeMux
+	probes          *http.ServeMux
 	engine          *collaboration.CollaborationEngine
 	store           storage.OperationStore
 	documentStore   storage.DocumentStore
@@ -26,6 +37,58 @@ type APIServer struct {
 	contextManager  *context.ConversationManager
 	contextAnalyzer *context.ContextAnalyzer
 	authManager     *auth.AuthManager
+	webhooks        *webhooks.Manager
+	replication     *replication.Node
+	backups         *backup.Manager
+	embeddings      *embeddings.Indexer
+	summaries       *summaries.Service
+	jobs            *jobs.Scheduler
+	logger          *logging.Logger
+	maxContentSize  int
+	maxRequestSize  int
+	corsOrigins     []string
+	corsMutex       sync.RWMutex
+	shuttingDown    bool
+	inflight        sync.WaitGroup
+	lifecycleMutex  sync.RWMutex
+
+	// trustForwardedFor takes the client address authentication attempts
+	// are tracked by from X-Forwarded-For
+	trustForwardedFor atomic.Bool
+
+	// routes are the v1 patterns registered, for the OpenAPI document
+	routes      []string
+	openAPIOnce sync.Once
+	openAPISpec []byte
+	openAPIErr  error
+
+	// compression, when set, compresses responses clients accept compressed
+	compression *compression
+	// accessLog, when set, logs each request once it is answered
+	accessLog *accessLog
+	// panics counts handlers that panicked, for admin stats
+	panics atomic.Uint64
+}
+
+type ServerOption func(*APIServer)
+
+// WithCORSOrigins restricts cross-origin requests to the given origins, "*" allows any
+func WithCORSOrigins(origins []string) ServerOption {
+	return func(s *APIServer) {
+		s.SetCORSOrigins(origins)
+	}
+}
+
+// WithTrustedForwardedFor tracks authentication attempts by the client
+// address a reverse proxy reports in X-Forwarded-For, when trust is set
+func WithTrustedForwardedFor(trust bool) ServerOption {
+	return func(s *APIServer) {
+		s.SetTrustForwardedFor(trust)
+	}
+}
+
+func (s *APIServer) SetTrustForwardedFor(trust bool) {
+	s.trustForwardedFor.Store(trust)
 }
 
 func NewAPIServer(
@@ -36,9 +99,11 @@ func NewAPIServer(
 	contextManager *context.ConversationManager,
 	contextAnalyzer *context.ContextAnalyzer,
 	authManager *auth.AuthManager,
+	opts ...ServerOption,
 ) *APIServer {
 	s := &APIServer{
 		mux:             http.NewServeMux(),
+		probes:          http.NewServeMux(),
 		engine:          engine,
 		store:           store,
 		documentStore:   documentStore,
@@ -46,126 +111,392 @@ func NewAPIServer(
 		contextManager:  contextManager,
 		contextAnalyzer: contextAnalyzer,
 		authManager:     authManager,
+		logger:          logging.NewLogger("api"),
+		maxContentSize:  DefaultMaxContentSize,
+		maxRequestSize:  DefaultMaxRequestSize,
+		corsOrigins:     []string{"*"},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if authManager != nil {
+		engine.SetOperationVerifier(authManager)
 	}
 	s.setupRoutes()
 	return s
 }
 
+// SetCORSOrigins replaces the allowed origins, safe to call while serving
+func (s *APIServer) SetCORSOrigins(origins []string) {
+	s.corsMutex.Lock()
+	defer s.corsMutex.Unlock()
+
+	s.corsOrigins = append([]string(nil), origins...)
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for origin, or
+// "" when the origin is not allowed
+func (s *APIServer) allowedOrigin(origin string) string {
+	s.corsMutex.RLock()
+	defer s.corsMutex.RUnlock()
+
+	for _, allowed := range s.corsOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && allowed == origin {
+			return origin
+		}
+	}
+	return ""
+}
+
 func (s *APIServer) setupRoutes() {
 	// Operation endpoints
-	s.mux.HandleFunc("GET /api/v1/operations", s.listOperations)
-	s.mux.HandleFunc("POST /api/v1/operations", s.createOperation)
-	s.mux.HandleFunc("GET /api/v1/operations/{id}", s.getOperation)
+	s.route("GET /api/v1/operations", s.listOperations)
+	s.route("POST /api/v1/operations", s.createOperation)
+	s.route("GET /api/v1/operations/{id}", s.getOperation)
+	s.route("GET /api/v1/operations/{id}/content", s.getOperationContent)
 
 	// Document endpoints
-	s.mux.HandleFunc("GET /api/v1/documents/{path}", s.getDocument)
-	s.mux.HandleFunc("GET /api/v1/documents/{path}/history", s.getDocumentHistory)
+	s.route("GET /api/v1/documents", s.listDocuments)
+	s.route("GET /api/v1/documents/{path}", s.getDocument)
+	s.route("DELETE /api/v1/documents/{path}", s.deleteDocument)
+	s.route("POST /api/v1/documents/{path}/restore", s.restoreDocument)
+	s.route("GET /api/v1/documents/{path}/history", s.getDocumentHistory)
+	s.route("GET /api/v1/documents/{path}/ownership", s.getDocumentOwnership)
+	s.route("GET /api/v1/documents/{path}/constructs", s.getDocumentConstructs)
+	s.route("GET /api/v1/documents/{path}/conversations/export", s.exportDocumentConversations)
+	s.route("POST /api/v1/documents/{path}/merge", s.mergeDocument)
+	s.route("POST /api/v1/documents/{path}/import", s.importDocument)
+
+	// Imports
+	s.route("POST /api/v1/import/editor", s.importEditJournal)
+
+	// Branch endpoints
+	s.route("GET /api/v1/branches", s.listBranches)
+	s.route("POST /api/v1/branches", s.createBranch)
+	s.route("GET /api/v1/branches/{name}", s.getBranch)
+	s.route("POST /api/v1/branches/{name}/merge", s.mergeBranch)
 
 	// Address endpoints
-	s.mux.HandleFunc("POST /api/v1/addresses/resolve", s.resolveAddress)
-	s.mux.HandleFunc("GET /api/v1/addresses/{address}/history", s.getAddressHistory)
+	s.route("POST /api/v1/addresses/resolve", s.resolveAddress)
+	s.route("GET /api/v1/addresses/{address}/history", s.getAddressHistory)
 
 	// Operation analysis endpoints
-	s.mux.HandleFunc("GET /api/v1/operations/{id}/context", s.getOperationContext)
-	s.mux.HandleFunc("GET /api/v1/operations/{id}/intent", s.getOperationIntent)
-	s.mux.HandleFunc("POST /api/v1/analyze/intent", s.analyzeBatchIntent)
+	s.route("GET /api/v1/operations/{id}/context", s.getOperationContext)
+	s.route("GET /api/v1/operations/{id}/intent", s.getOperationIntent)
+	s.route("GET /api/v1/operations/{id}/analysis", s.getOperationAnalysis)
+	s.route("POST /api/v1/analyze/intent", s.analyzeBatchIntent)
 
 	// Authentication endpoints
-	s.mux.HandleFunc("POST /api/v1/auth/keys", s.createAPIKey)
-	s.mux.HandleFunc("GET /api/v1/auth/keys", s.listAPIKeys)
-	s.mux.HandleFunc("DELETE /api/v1/auth/keys/{id}", s.revokeAPIKey)
-	s.mux.HandleFunc("GET /api/v1/auth/status", s.getAuthStatus)
-	s.mux.HandleFunc("POST /api/v1/auth/enable", s.enableAuth)
-	s.mux.HandleFunc("POST /api/v1/auth/disable", s.disableAuth)
+	s.route("POST /api/v1/auth/keys", s.requireAdmin(s.createAPIKey))
+	s.route("GET /api/v1/auth/keys", s.requireAdmin(s.listAPIKeys))
+	s.route("DELETE /api/v1/auth/keys/{id}", s.requireAdmin(s.revokeAPIKey))
+	s.route("POST /api/v1/auth/keys/{id}/rotate", s.requireAdmin(s.rotateAPIKey))
+	s.route("POST /api/v1/auth/me/keys", s.createOwnAPIKey)
+	s.route("GET /api/v1/auth/me/keys", s.listOwnAPIKeys)
+	s.route("POST /api/v1/auth/me/keys/{id}/rotate", s.rotateOwnAPIKey)
+	s.route("DELETE /api/v1/auth/me/keys/{id}", s.revokeOwnAPIKey)
+	s.route("GET /api/v1/auth/me/sessions", s.listOwnSessions)
+	s.route("GET /api/v1/auth/status", s.getAuthStatus)
+	s.route("POST /api/v1/auth/enable", s.enableAuth)
+	s.route("POST /api/v1/auth/disable", s.disableAuth)
+	s.route("POST /api/v1/auth/signing-keys", s.registerSigningKey)
+	s.route("GET /api/v1/auth/signing-keys", s.listSigningKeys)
+	s.route("DELETE /api/v1/auth/signing-keys/{id}", s.revokeSigningKey)
+	s.route("POST /api/v1/auth/signatures/require", s.requireAdmin(s.requireSignatures))
+	s.route("POST /api/v1/auth/signatures/allow-unsigned", s.requireAdmin(s.allowUnsignedOperations))
 
 	// Conversation endpoints
-	s.mux.HandleFunc("POST /api/v1/conversations", s.createConversation)
-	s.mux.HandleFunc("GET /api/v1/conversations/{id}", s.getConversation)
-	s.mux.HandleFunc("POST /api/v1/conversations/{id}/messages", s.addMessage)
+	s.route("POST /api/v1/conversations", s.createConversation)
+	s.route("GET /api/v1/conversations", s.listConversations)
+	s.route("GET /api/v1/conversations/{id}", s.getConversation)
+	s.route("POST /api/v1/conversations/{id}/messages", s.addMessage)
+	s.route("POST /api/v1/conversations/{id}/resolve", s.resolveConversation)
+	s.route("POST /api/v1/conversations/{id}/summarize", s.requireSummaries(s.summarizeConversation))
+	s.route("POST /api/v1/conversations/{id}/visibility", s.setConversationVisibility)
+	s.route("POST /api/v1/conversations/{id}/tags", s.addConversationTags)
+	s.route("DELETE /api/v1/conversations/{id}/tags/{tag}", s.removeConversationTag)
+	s.route("POST /api/v1/conversations/{id}/labels", s.addConversationLabels)
+	s.route("DELETE /api/v1/conversations/{id}/labels/{label}", s.removeConversationLabel)
+	s.route("GET /api/v1/tags", s.listTags)
+	s.route("GET /api/v1/labels", s.listLabels)
+
+	// Decision endpoints
+	s.route("GET /api/v1/decisions", s.listDecisions)
+	s.route("POST /api/v1/decisions", s.createDecision)
+	s.route("GET /api/v1/decisions/{id}", s.getDecision)
+	s.route("POST /api/v1/decisions/{id}/supersede", s.supersedeDecision)
+
+	// Review endpoints
+	s.route("GET /api/v1/reviews", s.listReviews)
+	s.route("POST /api/v1/reviews", s.createReview)
+	s.route("GET /api/v1/reviews/{id}", s.getReview)
+	s.route("POST /api/v1/reviews/{id}/reviewers", s.requestReviewers)
+	s.route("POST /api/v1/reviews/{id}/approve", s.approveReview)
+	s.route("POST /api/v1/reviews/{id}/request-changes", s.requestReviewChanges)
+
+	// Change sets
+	s.route("GET /api/v1/changesets", s.listChangeSets)
+	s.route("GET /api/v1/changesets/{id}", s.getChangeSet)
+
+	// Tickets
+	s.route("GET /api/v1/tickets/{key}", s.getTicket)
+
+	// Reference graph
+	s.route("GET /api/v1/graph", s.getReferenceGraph)
+	s.route("GET /api/v1/dag/order", s.getCausalOrder)
+	s.route("GET /api/v1/dag/merge-base", s.getMergeBase)
+	s.route("GET /api/v1/dag/is-ancestor", s.getAncestry)
+	s.route("POST /api/v1/query", s.queryOperations)
 
 	// Analysis endpoints
-	s.mux.HandleFunc("GET /api/v1/analysis/context/{operation_id}", s.getOperationContext)
-	s.mux.HandleFunc("POST /api/v1/analysis/intent", s.analyzeIntent)
+	s.route("GET /api/v1/analysis/context/{operation_id}", s.getOperationContext)
+	s.route("POST /api/v1/analysis/intent", s.analyzeIntent)
+	s.route("POST /api/v1/analysis/context-pack", s.buildContextPack)
+	s.route("GET /api/v1/analysis/intents", s.getIntentTaxonomy)
+
+	// Reports and analytics
+	s.route("GET /api/v1/reports/activity", s.getActivityReport)
+	s.route("GET /api/v1/authors/{author}/identity", s.getIdentity)
+	s.route("GET /api/v1/analytics/churn", s.getChurn)
 
 	// Search endpoints
-	s.mux.HandleFunc("GET /api/v1/search", s.search)
+	s.route("GET /api/v1/search", s.search)
+	s.route("GET /api/v1/search/similar", s.requireEmbeddings(s.similar))
 
 	// Health check
-	s.mux.HandleFunc("GET /api/v1/health", s.healthCheck)
+	s.route("GET /api/v1/health", s.healthCheck)
+	s.probes.HandleFunc("GET /healthz", s.healthz)
+	s.probes.HandleFunc("GET /readyz", s.readyz)
+
+	// Live collaboration
+	s.route("GET /api/v1/ws", s.connectWebSocket)
+
+	// Admin endpoints
+	s.route("GET /api/v1/admin/stats", s.requireAdmin(s.getStats))
+	s.route("GET /api/v1/admin/usage", s.requireAdmin(s.listUsage))
+	s.route("GET /api/v1/admin/usage/{key_id}", s.requireAdmin(s.getKeyUsage))
+	s.route("GET /api/v1/admin/auth/attempts", s.requireAdmin(s.getAuthAttempts))
+	s.route("GET /api/v1/admin/log-levels", s.requireAdmin(s.getLogLevels))
+	s.route("PUT /api/v1/admin/log-levels", s.requireAdmin(s.setLogLevels))
+	s.route("DELETE /api/v1/admin/auth/lockouts/{source}", s.requireAdmin(s.unlockAuthSource))
+	s.route("GET /api/v1/admin/retention", s.requireAdmin(s.getRetentionPolicy))
+	s.route("PUT /api/v1/admin/retention", s.requireAdmin(s.setRetentionPolicy))
+	s.route("GET /api/v1/admin/retention/preview", s.requireAdmin(s.previewRetention))
+	s.route("POST /api/v1/admin/retention/enforce", s.requireAdmin(s.enforceRetention))
+	s.route("PUT /api/v1/admin/intents", s.requireAdmin(s.setIntentTaxonomy))
+	s.route("GET /api/v1/admin/identities", s.requireAdmin(s.listIdentities))
+	s.route("POST /api/v1/admin/identities", s.requireAdmin(s.mergeIdentity))
+	s.route("DELETE /api/v1/admin/identities/{alias}", s.requireAdmin(s.splitIdentity))
+	s.route("POST /api/v1/admin/analysis/reanalyze", s.requireAdmin(s.requireJobs(s.reanalyzeIntents)))
+	s.route("GET /api/v1/admin/fsck", s.requireAdmin(s.checkIntegrity))
+	s.route("POST /api/v1/admin/fsck/repair", s.requireAdmin(s.repairIntegrity))
+	s.route("POST /api/v1/admin/webhooks", s.requireAdmin(s.requireWebhooks(s.createWebhook)))
+	s.route("GET /api/v1/admin/webhooks", s.requireAdmin(s.requireWebhooks(s.listWebhooks)))
+	s.route("GET /api/v1/admin/webhooks/{id}", s.requireAdmin(s.requireWebhooks(s.getWebhook)))
+	s.route("PATCH /api/v1/admin/webhooks/{id}", s.requireAdmin(s.requireWebhooks(s.updateWebhook)))
+	s.route("DELETE /api/v1/admin/webhooks/{id}", s.requireAdmin(s.requireWebhooks(s.deleteWebhook)))
+	s.route("GET /api/v1/admin/webhooks/{id}/deliveries", s.requireAdmin(s.requireWebhooks(s.listWebhookDeliveries)))
+	s.route("POST /api/v1/admin/backup", s.requireAdmin(s.requireBackups(s.createBackup)))
+	s.route("GET /api/v1/admin/backups", s.requireAdmin(s.requireBackups(s.listBackups)))
+	s.route("GET /api/v1/admin/jobs", s.requireAdmin(s.requireJobs(s.listJobs)))
+	s.route("GET /api/v1/admin/jobs/{name}", s.requireAdmin(s.requireJobs(s.getJob)))
+	s.route("POST /api/v1/admin/jobs/{name}/run", s.requireAdmin(s.requireJobs(s.runJob)))
+
+	// Replication between nodes
+	s.route("GET /api/v1/replication/status", s.requireReplication(s.getReplicationStatus))
+	s.route("POST /api/v1/replication/pull", s.requireReplication(s.pullOperations))
+	s.route("POST /api/v1/replication/push", s.requireReplication(s.pushOperations))
 
 	// Permalink endpoint
-	s.mux.HandleFunc("GET /api/v1/permalink/{operation_id}", s.resolvePermalink)
+	s.route("GET /api/v1/permalink/{operation_id}", s.resolvePermalink)
+
+	// API description
+	s.route("GET /api/v1/openapi.json", s.getOpenAPI)
+	s.route("GET /api/v1/docs", s.getAPIDocs)
 }
 
 func (s *APIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The access log sees the response as sent, after compression and
+	// with any panic answered
+	if s.accessLog != nil {
+		s.accessLog.handle(w, r, s.guarded)
+		return
+	}
+	s.guarded(w, r)
+}
+
+// guarded sets the CORS headers, which even the 500 a panic is answered
+// with needs for browsers to read it, then serves the request
+func (s *APIServer) guarded(w http.ResponseWriter, r *http.Request) {
 	// Set CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	if allowed := s.allowedOrigin(r.Header.Get("Origin")); allowed != "" {
+		w.Header().Set("Access-Control-Allow-Origin", allowed)
+		if allowed != "*" {
+			w.Header().Add("Vary", "Origin")
+		}
+	}
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match, "+APIVersionHeader)
+	w.Header().Set("Access-Control-Expose-Headers", "Deprecation, Sunset, Link, ETag, "+APIVersionHeader)
+
+	s.recoverPanics(w, r, s.compress)
+}
+
+func (s *APIServer) compress(w http.ResponseWriter, r *http.Request) {
+	if s.compression != nil {
+		s.compression.handle(w, r, s.serve)
+		return
+	}
+	s.serve(w, r)
+}
 
+func (s *APIServer) serve(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
+	if !s.beginRequest() {
+		w.Header().Set("Connection", "close")
+		s.jsonError(w, r, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.inflight.Done()
+
+	// Probes answer without an API key so orchestrators can call them
+	if handler, pattern := s.probes.Handler(r); pattern != "" {
+		if entry := accessEntryFrom(r.Context()); entry != nil {
+			entry.pattern = pattern
+		}
+		handler.ServeHTTP(w, r)
+		return
+	}
+
 	// Apply auth middleware
-	authMiddleware := auth.AuthMiddleware(s.authManager)
-	authMiddleware(s.mux).ServeHTTP(w, r)
+	authOptions := []auth.MiddlewareOption{auth.WithErrorWriter(s.jsonError)}
+	if s.trustForwardedFor.Load() {
+		authOptions = append(authOptions, auth.WithTrustedForwardedFor())
+	}
+	authMiddleware := auth.AuthMiddleware(s.authManager, authOptions...)
+	authMiddleware(http.HandlerFunc(s.dispatch)).ServeHTTP(w, r)
+}
+
+// dispatch routes an authenticated request, noting who made it and the
+// route it took for the access log
+func (s *APIServer) dispatch(w http.ResponseWriter, r *http.Request) {
+	entry := accessEntryFrom(r.Context())
+	if entry != nil {
+		entry.auth = auth.GetAuthContext(r.Context())
+	}
+	s.mux.ServeHTTP(w, r)
+	if entry != nil {
+		entry.pattern = r.Pattern
+	}
 }
 
-// Helper methods for JSON responses
-func (s *APIServer) jsonResponse(w http.ResponseWriter, data interface{}, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(data)
+func (s *APIServer) beginRequest() bool {
+	s.lifecycleMutex.RLock()
+	defer s.lifecycleMutex.RUnlock()
+
+	if s.shuttingDown {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
 }
 
-func (s *APIServer) jsonError(w http.ResponseWriter, message string, statusCode int) {
-	s.jsonResponse(w, map[string]string{"error": message}, statusCode)
+// Shutdown rejects new requests with 503, waits for those in progress and
+// then shuts down the collaboration engine, which closes storage. Callers
+// running an http.Server should shut that down first so idle connections
+// are closed too.
+func (s *APIServer) Shutdown(ctx gocontext.Context) error {
+	s.lifecycleMutex.Lock()
+	s.shuttingDown = true
+	s.lifecycleMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	// On timeout the engine still stops taking work; it reports ctx's error
+	// and leaves storage open for the requests that are still running
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	return s.engine.Shutdown(ctx)
 }
 
-func (s *APIServer) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
-	s.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (s *APIServer) requireAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	return s.requirePermission(auth.PermissionAdmin, handler)
 }
 
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Code    int    `json:"code"`
-	Details string `json:"details,omitempty"`
+func (s *APIServer) requirePermission(perm auth.Permission, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authContext := auth.GetAuthContext(r.Context())
+		if authContext == nil {
+			s.jsonError(w, r, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		if !authContext.HasPermission(perm) {
+			s.jsonError(w, r, "Insufficient permissions", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// recordUsage attributes metered work to the API key that made the request
+func (s *APIServer) recordUsage(r *http.Request, record func(usage *auth.UsageTracker, keyID string) error) {
+	authContext := auth.GetAuthContext(r.Context())
+	if authContext == nil || authContext.APIKeyID == "" {
+		return
+	}
+	// Accounting is best effort and must not fail the request
+	record(s.authManager.Usage(), authContext.APIKeyID)
+}
+
+func (s *APIServer) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	s.jsonError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 }
 
 type SuccessResponse struct {
-	Data    interface{} `json:"data"`
-	Message string      `json:"message,omitempty"`
+	Data    interface{}   `json:"data"`
+	Message string        `json:"message,omitempty"`
+	Meta    *ResponseMeta `json:"meta,omitempty"`
 }
 
 // API endpoint handlers
 
 // Operation endpoints
 func (s *APIServer) createOperation(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Type        operations.OperationType  `json:"type"`
-		Position    operations.LogootPosition `json:"position"`
-		Content     string                    `json:"content"`
-		ContentType string                    `json:"content_type,omitempty"`
-		Length      int                       `json:"length,omitempty"`
-		Author      operations.AuthorID       `json:"author"`
-		Parents     []operations.OperationID  `json:"parents,omitempty"`
-		Metadata    operations.OperationMeta  `json:"metadata,omitempty"`
-		DocumentID  string                    `json:"document_id"`
+	var req CreateOperationRequest
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, int64(s.maxRequestSize))).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.jsonError(w, r, fmt.Sprintf("Operation exceeds the %d byte request limit", s.maxRequestSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.jsonError(w, "Invalid JSON payload", http.StatusBadRequest)
+	expectedVersion, err := expectedDocumentVersion(r, req.ExpectedVersion)
+	if err != nil {
+		s.writeError(w, r, validationError("Invalid precondition", FieldError{Field: "expected_version", Message: err.Error()}))
 		return
 	}
 
 	// Ensure metadata has the required context
-	if req.Metadata.Context == nil {
-		req.Metadata.Context = make(map[string]string)
-	}
 	if req.DocumentID != "" {
-		req.Metadata.Context["document_id"] = req.DocumentID
+		req.Metadata.DocumentID = req.DocumentID
 	}
 
 	op := &operations.Operation{
@@ -180,183 +511,370 @@ func (s *APIServer) createOperation(w http.ResponseWriter, r *http.Request) {
 		Metadata:    req.Metadata,
 	}
 
-	op.ID = operations.NewOperationID([]byte(fmt.Sprintf("%s-%s-%d",
-		req.Author, req.Content, op.Timestamp.UnixNano())))
+	// An ID or signature covers when the operation was made, so either needs
+	// the client's timestamp
+	if req.Timestamp != nil {
+		op.Timestamp = *req.Timestamp
+	} else if req.ID != "" || req.Metadata.Signature != "" {
+		s.writeError(w, r, validationError("Invalid operation", FieldError{Field: "timestamp", Message: "is required with an id or signature"}))
+		return
+	}
+
+	// Operations identified by the server follow on from the document's heads
+	// when the client doesn't know them
+	var process []collaboration.ProcessOption
+	op.ID = req.ID
+	if op.ID == "" {
+		op.ID = operations.ComputeID(op)
+		if op.Metadata.Signature == "" {
+			process = append(process, collaboration.AssignParents())
+		}
+	}
 
-	if err := s.engine.ProcessOperation(op, collaboration.ClientID(req.Author)); err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to process operation: %v", err), http.StatusInternalServerError)
+	split := splittable(op, req.ID != "")
+	fields, err := s.validateOperation(r.Context(), op, split)
+	if err != nil {
+		s.internalError(w, r, "Failed to validate operation", err)
+		return
+	}
+	if len(fields) > 0 {
+		s.writeError(w, r, validationError("Invalid operation", fields...))
+		return
+	}
+
+	if err := s.engine.VerifyOperation(r.Context(), op); err != nil {
+		if e := operationError(err); e != nil {
+			s.writeError(w, r, e)
+			return
+		}
+		s.internalError(w, r, "Failed to verify operation", err)
+		return
+	}
+
+	// Text inserts too large for one operation are split, and the first of
+	// the chunks stands for them in the response
+	size := len(op.Content)
+	message := "Operation created successfully"
+	var version uint64
+	if split && size > s.maxContentSize {
+		var chunks []*operations.Operation
+		version, chunks, err = s.engine.ProcessSplitInsert(r.Context(), op, collaboration.ClientID(req.Author), expectedVersion, s.maxContentSize, process...)
+		if err == nil {
+			op = chunks[0]
+			message = fmt.Sprintf("Operation created in %d chunks", len(chunks))
+		}
+	} else {
+		version, err = s.engine.ProcessOperationAt(r.Context(), op, collaboration.ClientID(req.Author), expectedVersion, process...)
+	}
+	if err != nil {
+		if e := operationError(err); e != nil {
+			s.writeError(w, r, e)
+			return
+		}
+		s.internalError(w, r, "Failed to process operation", err)
 		return
 	}
 
-	s.jsonResponse(w, SuccessResponse{
+	s.recordUsage(r, func(usage *auth.UsageTracker, keyID string) error {
+		return usage.RecordOperation(keyID, size)
+	})
+
+	w.Header().Set("ETag", versionETag(version))
+
+	s.respond(w, r, SuccessResponse{
 		Data:    op,
-		Message: "Operation created successfully",
+		Message: message,
 	}, http.StatusCreated)
 }
 
 func (s *APIServer) getOperation(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	if idStr == "" {
-		s.jsonError(w, "Operation ID is required", http.StatusBadRequest)
+		s.jsonError(w, r, "Operation ID is required", http.StatusBadRequest)
 		return
 	}
 
 	opID := operations.OperationID(idStr)
 
-	op, err := s.store.GetOperation(opID)
+	op, err := s.store.GetOperation(r.Context(), opID)
 	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Operation not found: %v", err), http.StatusNotFound)
+		s.lookupError(w, r, "Operation", err)
 		return
 	}
 
-	s.jsonResponse(w, SuccessResponse{Data: op}, http.StatusOK)
+	if notModified(w, r, operationETag(op.ID)) {
+		return
+	}
+	s.respond(w, r, SuccessResponse{Data: op}, http.StatusOK)
 }
 
 func (s *APIServer) listOperations(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
+	meta := &ResponseMeta{}
+
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		if offset, parseErr := strconv.Atoi(offsetStr); parseErr == nil && offset > 0 {
+			meta.Offset = offset
+		}
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if limit, parseErr := strconv.Atoi(limitStr); parseErr == nil && limit > 0 {
+			meta.Limit = limit
+		}
+	}
 
+	// Stream the history and only keep the requested page, counting the rest for the total
 	var ops []*operations.Operation
-	var err error
+	collect := func(op *operations.Operation) error {
+		if meta.Total >= meta.Offset && (meta.Limit == 0 || len(ops) < meta.Limit) {
+			ops = append(ops, op)
+		}
+		meta.Total++
+		return nil
+	}
 
+	filter := storage.OperationFilter{
+		Author:     operations.AuthorID(query.Get("author")),
+		DocumentID: query.Get("document_id"),
+		Branch:     query.Get("branch"),
+		Tool:       query.Get("tool"),
+		Ticket:     query.Get("ticket"),
+		Language:   query.Get("language"),
+	}
 	if sinceStr := query.Get("since"); sinceStr != "" {
 		since, parseErr := time.Parse(time.RFC3339, sinceStr)
 		if parseErr != nil {
-			s.jsonError(w, "Invalid 'since' timestamp format", http.StatusBadRequest)
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "since", Message: "must be an RFC 3339 timestamp"}))
 			return
 		}
-		ops, err = s.store.GetOperationsSince(since)
-	} else if author := query.Get("author"); author != "" {
-		ops, err = s.store.GetOperationsByAuthor(operations.AuthorID(author))
-	} else {
+		filter.Since = since
+	} else if filter.IsZero() {
 		// Get recent operations (last 24 hours by default)
-		since := time.Now().Add(-24 * time.Hour)
-		ops, err = s.store.GetOperationsSince(since)
+		filter.Since = time.Now().Add(-24 * time.Hour)
+	}
+
+	if wantsNDJSON(r) {
+		s.streamOperations(w, r, filter, meta.Offset, meta.Limit)
+		return
 	}
 
+	err := s.store.ForEachOperationMatching(r.Context(), filter, collect)
 	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to retrieve operations: %v", err), http.StatusInternalServerError)
+		s.internalError(w, r, "Failed to retrieve operations", err)
 		return
 	}
 
-	// Apply limit if specified
-	if limitStr := query.Get("limit"); limitStr != "" {
-		if limit, parseErr := strconv.Atoi(limitStr); parseErr == nil && limit > 0 && limit < len(ops) {
-			ops = ops[:limit]
-		}
+	if meta.Offset > meta.Total {
+		meta.Offset = meta.Total
 	}
 
-	s.jsonResponse(w, SuccessResponse{Data: ops}, http.StatusOK)
+	s.respond(w, r, SuccessResponse{Data: ops, Meta: meta}, http.StatusOK)
+}
+
+// streamOperations writes the operations filter matches as NDJSON as they
+// are read from the store, skipping offset of them and stopping after limit
+// unless it's zero. There is no total, as that would mean reading to the end.
+func (s *APIServer) streamOperations(w http.ResponseWriter, r *http.Request, filter storage.OperationFilter, offset, limit int) {
+	stream, ok := s.streamNDJSON(w, r)
+	if !ok {
+		return
+	}
+
+	read := 0
+	err := s.store.ForEachOperationMatching(r.Context(), filter, func(op *operations.Operation) error {
+		read++
+		if read <= offset {
+			return nil
+		}
+		if limit > 0 && read > offset+limit {
+			return storage.ErrStopIteration
+		}
+		return stream.write(op)
+	})
+	stream.finish("Failed to retrieve operations", err)
 }
 
 // Document endpoints
 func (s *APIServer) getDocument(w http.ResponseWriter, r *http.Request) {
 	filePath := r.PathValue("path")
 	if filePath == "" {
-		s.jsonError(w, "Document path is required", http.StatusBadRequest)
+		s.jsonError(w, r, "Document path is required", http.StatusBadRequest)
 		return
 	}
 
-	doc, err := s.documentStore.GetDocument(filePath)
+	// A branch's document is rendered from its base and its own operations
+	withStats := r.URL.Query().Get("stats") == "true"
+	if branch := r.URL.Query().Get("branch"); !operations.IsMainBranch(branch) {
+		if withStats {
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "stats", Message: "is only available on the main branch"}))
+			return
+		}
+		doc, err := s.engine.BranchDocument(r.Context(), filePath, branch)
+		if err != nil {
+			s.lookupError(w, r, "Document", err)
+			return
+		}
+		if notModified(w, r, contentETag(doc.ContentHash)) {
+			return
+		}
+		s.respond(w, r, SuccessResponse{Data: doc}, http.StatusOK)
+		return
+	}
+
+	doc, err := s.documentStore.GetDocument(r.Context(), filePath)
 	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Document not found: %v", err), http.StatusNotFound)
+		s.lookupError(w, r, "Document", err)
+		return
+	}
+
+	if !withStats {
+		if notModified(w, r, versionETag(doc.Version)) {
+			return
+		}
+		s.respond(w, r, SuccessResponse{Data: doc}, http.StatusOK)
 		return
 	}
 
-	s.jsonResponse(w, SuccessResponse{Data: doc}, http.StatusOK)
+	// Stats change with conversations as well as operations, so they are
+	// always sent whole, with the version the document was read at
+	w.Header().Set("ETag", versionETag(doc.Version))
+	w.Header().Set("Cache-Control", "no-store")
+
+	stats, err := s.engine.DocumentStats(r.Context(), filePath)
+	if err != nil {
+		s.lookupError(w, r, "Document", err)
+		return
+	}
+	s.respond(w, r, SuccessResponse{Data: DocumentWithStats{DocumentSnapshot: doc.Snapshot(), Stats: stats}}, http.StatusOK)
 }
 
 func (s *APIServer) getDocumentHistory(w http.ResponseWriter, r *http.Request) {
 	filePath := r.PathValue("path")
 	if filePath == "" {
-		s.jsonError(w, "Document path is required", http.StatusBadRequest)
+		s.jsonError(w, r, "Document path is required", http.StatusBadRequest)
 		return
 	}
 
 	// Get all addresses for this document
 	addresses, err := s.resolver.GetAddressesByDocument(filePath)
 	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to get document addresses: %v", err), http.StatusInternalServerError)
+		s.internalError(w, r, "Failed to get document addresses", err)
 		return
 	}
 
-	type DocumentHistory struct {
-		FilePath   string                     `json:"file_path"`
-		Addresses  []addressing.StableAddress `json:"addresses"`
-		Operations []*operations.Operation    `json:"operations,omitempty"`
-	}
-
 	history := DocumentHistory{
 		FilePath:  filePath,
 		Addresses: addresses,
 	}
 
-	s.jsonResponse(w, SuccessResponse{Data: history}, http.StatusOK)
+	s.respond(w, r, SuccessResponse{Data: history}, http.StatusOK)
 }
 
 // Address endpoints
 func (s *APIServer) resolveAddress(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Address addressing.StableAddress `json:"address"`
-	}
+	var req ResolveAddressRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.jsonError(w, "Invalid JSON payload", http.StatusBadRequest)
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
 		return
 	}
 
 	resolved, err := s.resolver.ResolveAddress(req.Address)
 	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to resolve address: %v", err), http.StatusNotFound)
+		s.lookupError(w, r, "Address", err)
 		return
 	}
 
-	s.jsonResponse(w, SuccessResponse{Data: resolved}, http.StatusOK)
+	s.respond(w, r, SuccessResponse{Data: resolved}, http.StatusOK)
 }
 
 func (s *APIServer) getAddressHistory(w http.ResponseWriter, r *http.Request) {
 	addressStr := r.PathValue("address")
 	if addressStr == "" {
-		s.jsonError(w, "Address is required", http.StatusBadRequest)
+		s.jsonError(w, r, "Address is required", http.StatusBadRequest)
 		return
 	}
 
 	// Parse address string - simplified for MVP
 	var addr addressing.StableAddress
 	if err := json.Unmarshal([]byte(addressStr), &addr); err != nil {
-		s.jsonError(w, "Invalid address format", http.StatusBadRequest)
+		s.jsonError(w, r, "Invalid address format", http.StatusBadRequest)
 		return
 	}
 
 	history, err := s.resolver.GetAddressHistory(addr)
 	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to get address history: %v", err), http.StatusNotFound)
+		s.lookupError(w, r, "Address", err)
 		return
 	}
 
-	s.jsonResponse(w, SuccessResponse{Data: history}, http.StatusOK)
+	s.respond(w, r, SuccessResponse{Data: history}, http.StatusOK)
 }
 
 // Conversation endpoints
 func (s *APIServer) createConversation(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		AnchorAddress addressing.StableAddress `json:"anchor_address"`
-		AuthorID      operations.AuthorID      `json:"author_id"`
-		Title         string                   `json:"title"`
-		Content       string                   `json:"content"`
-	}
+	var req CreateConversationRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.jsonError(w, "Invalid JSON payload", http.StatusBadRequest)
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
 		return
 	}
 
-	thread, err := s.contextManager.CreateConversation(req.AnchorAddress, req.AuthorID, req.Title, req.Content)
+	if req.Visibility == "" {
+		req.Visibility = context.VisibilityPublic
+	}
+	if !req.Visibility.IsValid() {
+		s.writeError(w, r, validationError("Invalid conversation", FieldError{Field: "visibility", Message: "must be public, participants or private"}))
+		return
+	}
+	opts := []context.ThreadOption{context.WithVisibility(req.Visibility, req.Participants...)}
+	if req.LinkedIssue != "" {
+		if _, err := context.ParseIssueLink(req.LinkedIssue); err != nil {
+			s.writeError(w, r, validationError("Invalid conversation", FieldError{Field: "linked_issue", Message: "must be an issue key like ENG-42 or a Jira or Linear issue URL"}))
+			return
+		}
+		opts = append(opts, context.WithLinkedIssue(req.LinkedIssue))
+	}
+
+	if !operations.IsMainBranch(req.Branch) {
+		if _, lookupErr := s.engine.GetBranch(r.Context(), req.Branch); lookupErr != nil {
+			if isNotFound(lookupErr) {
+				s.writeError(w, r, validationError("Invalid conversation", FieldError{Field: "branch", Message: "no such branch"}))
+			} else {
+				s.internalError(w, r, "Failed to load branch", lookupErr)
+			}
+			return
+		}
+		opts = append(opts, context.WithBranch(req.Branch))
+	}
+
+	var thread *context.ConversationThread
+	var err error
+	if req.ChangeSetID != "" {
+		if _, lookupErr := s.engine.GetChangeSet(r.Context(), req.ChangeSetID); lookupErr != nil {
+			if isNotFound(lookupErr) {
+				s.writeError(w, r, validationError("Invalid conversation", FieldError{Field: "changeset_id", Message: "no such change set"}))
+			} else {
+				s.internalError(w, r, "Failed to load change set", lookupErr)
+			}
+			return
+		}
+		thread, err = s.contextManager.CreateChangeSetConversation(req.ChangeSetID, req.AnchorAddress, req.AuthorID, req.Title, req.Content, opts...)
+	} else {
+		thread, err = s.contextManager.CreateConversation(req.AnchorAddress, req.AuthorID, req.Title, req.Content, opts...)
+	}
 	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to create conversation: %v", err), http.StatusInternalServerError)
+		s.internalError(w, r, "Failed to create conversation", err)
 		return
 	}
+	if thread.Metadata.LinkedIssue != "" {
+		if thread, err = s.engine.LinkTicketOperations(r.Context(), thread.ID); err != nil {
+			s.internalError(w, r, "Failed to link operations", err)
+			return
+		}
+	}
 
-	s.jsonResponse(w, SuccessResponse{
+	s.respond(w, r, SuccessResponse{
 		Data:    thread,
 		Message: "Conversation created successfully",
 	}, http.StatusCreated)
@@ -365,496 +883,169 @@ func (s *APIServer) createConversation(w http.ResponseWriter, r *http.Request) {
 func (s *APIServer) getConversation(w http.ResponseWriter, r *http.Request) {
 	threadIDStr := r.PathValue("id")
 	if threadIDStr == "" {
-		s.jsonError(w, "Conversation ID is required", http.StatusBadRequest)
+		s.jsonError(w, r, "Conversation ID is required", http.StatusBadRequest)
 		return
 	}
 
-	threadID := context.ThreadID(threadIDStr)
-	thread, err := s.contextManager.GetConversation(threadID)
-	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Conversation not found: %v", err), http.StatusNotFound)
+	// Long threads are read a page of messages at a time, newest first
+	query := r.URL.Query()
+	var opts []context.MessageOption
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "limit", Message: "must be a positive integer"}))
+			return
+		}
+		opts = append(opts, context.WithMessageLimit(limit))
+	}
+	if before := query.Get("before"); before != "" {
+		opts = append(opts, context.WithMessagesBefore(context.MessageID(before)))
+	}
+
+	thread, ok := s.viewConversation(w, r, context.ThreadID(threadIDStr), opts...)
+	if !ok {
 		return
 	}
 
-	s.jsonResponse(w, SuccessResponse{Data: thread}, http.StatusOK)
+	s.respond(w, r, SuccessResponse{Data: thread}, http.StatusOK)
 }
 
 func (s *APIServer) addMessage(w http.ResponseWriter, r *http.Request) {
 	threadIDStr := r.PathValue("id")
 	if threadIDStr == "" {
-		s.jsonError(w, "Conversation ID is required", http.StatusBadRequest)
+		s.jsonError(w, r, "Conversation ID is required", http.StatusBadRequest)
 		return
 	}
 
 	threadID := context.ThreadID(threadIDStr)
 
-	var req struct {
-		AuthorID    operations.AuthorID `json:"author_id"`
-		Content     string              `json:"content"`
-		MessageType context.MessageType `json:"message_type"`
-	}
+	var req AddMessageRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.jsonError(w, "Invalid JSON payload", http.StatusBadRequest)
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := s.viewConversation(w, r, threadID); !ok {
 		return
 	}
 
-	message, err := s.contextManager.AddMessage(threadID, req.AuthorID, req.Content, req.MessageType)
+	message, err := s.contextManager.AddMessage(threadID, req.AuthorID, req.Content, req.MessageType, req.References...)
 	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to add message: %v", err), http.StatusInternalServerError)
+		if isNotFound(err) {
+			s.lookupError(w, r, "Conversation", err)
+			return
+		}
+		if errors.Is(err, context.ErrNotReview) {
+			s.writeError(w, r, validationError("Invalid message", FieldError{Field: "message_type", Message: err.Error()}))
+			return
+		}
+		s.internalError(w, r, "Failed to add message", err)
 		return
 	}
 
-	s.jsonResponse(w, SuccessResponse{
+	s.respond(w, r, SuccessResponse{
 		Data:    message,
 		Message: "Message added successfully",
 	}, http.StatusCreated)
 }
 
-// Analysis endpoints (basic implementation for MVP)
-func (s *APIServer) getOperationContext(w http.ResponseWriter, r *http.Request) {
-	opIDStr := r.PathValue("id")
-	if opIDStr == "" {
-		s.jsonError(w, "Operation ID is required", http.StatusBadRequest)
-		return
-	}
-
-	opID := operations.OperationID(opIDStr)
-
-	op, err := s.store.GetOperation(opID)
-	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Operation not found: %v", err), http.StatusNotFound)
-		return
-	}
-
-	// Basic context analysis
-	contextInfo := struct {
-		Operation  *operations.Operation `json:"operation"`
-		Intent     string                `json:"intent"`
-		Confidence float64               `json:"confidence"`
-	}{
-		Operation:  op,
-		Intent:     s.analyzeBasicIntent(op),
-		Confidence: 0.7, // Basic confidence for MVP
-	}
+func (s *APIServer) resolveConversation(w http.ResponseWriter, r *http.Request) {
+	threadID := context.ThreadID(r.PathValue("id"))
 
-	s.jsonResponse(w, SuccessResponse{Data: contextInfo}, http.StatusOK)
-}
-
-func (s *APIServer) analyzeIntent(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Operations []*operations.Operation `json:"operations"`
-	}
+	var req ResolveConversationRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.jsonError(w, "Invalid JSON payload", http.StatusBadRequest)
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
 		return
 	}
 
-	analysis := struct {
-		PrimaryIntent string   `json:"primary_intent"`
-		Confidence    float64  `json:"confidence"`
-		Evidence      []string `json:"evidence"`
-		Category      string   `json:"category"`
-	}{
-		PrimaryIntent: "code_change",
-		Confidence:    0.8,
-		Evidence:      []string{"operation_type_analysis"},
-		Category:      "development",
-	}
-
-	s.jsonResponse(w, SuccessResponse{Data: analysis}, http.StatusOK)
-}
-
-// Search endpoint with enhanced functionality
-func (s *APIServer) search(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	searchQuery := query.Get("q")
-	searchType := query.Get("type")
-	authorFilter := query.Get("author")
-	limitStr := query.Get("limit")
-
-	if searchQuery == "" {
-		s.jsonError(w, "Search query 'q' parameter is required", http.StatusBadRequest)
+	if _, ok := s.viewConversation(w, r, threadID); !ok {
 		return
 	}
 
-	// Parse limit
-	limit := 50 // Default limit
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 && parsedLimit <= 1000 {
-			limit = parsedLimit
-		}
-	}
-
-	var results []SearchResult
-
-	// Enhanced search implementation
-	switch searchType {
-	case "conversation":
-		results = s.searchConversations(searchQuery, authorFilter, limit)
-	case "operation":
-		results = s.searchOperations(searchQuery, authorFilter, limit)
-	case "code":
-		results = s.searchCode(searchQuery, limit)
-	default:
-		// Search all types
-		conversationResults := s.searchConversations(searchQuery, authorFilter, limit/3)
-		operationResults := s.searchOperations(searchQuery, authorFilter, limit/3)
-		codeResults := s.searchCode(searchQuery, limit/3)
-
-		results = append(results, conversationResults...)
-		results = append(results, operationResults...)
-		results = append(results, codeResults...)
-
-		// Sort by relevance score (descending)
-		s.sortResultsByScore(results)
-
-		// Apply final limit
-		if len(results) > limit {
-			results = results[:limit]
-		}
-	}
-
-	searchResults := struct {
-		Query   string         `json:"query"`
-		Type    string         `json:"type"`
-		Author  string         `json:"author,omitempty"`
-		Results []SearchResult `json:"results"`
-		Total   int            `json:"total"`
-		Limit   int            `json:"limit"`
-	}{
-		Query:   searchQuery,
-		Type:    searchType,
-		Author:  authorFilter,
-		Results: results,
-		Total:   len(results),
-		Limit:   limit,
+	if err := s.contextManager.ResolveConversation(threadID, req.AuthorID); err != nil {
+		s.lookupError(w, r, "Conversation", err)
+		return
 	}
 
-	s.jsonResponse(w, SuccessResponse{Data: searchResults}, http.StatusOK)
-}
-
-type SearchResult struct {
-	Type      string      `json:"type"` // "conversation", "operation", "code"
-	ID        string      `json:"id"`
-	Title     string      `json:"title,omitempty"`
-	Content   string      `json:"content"`
-	Author    string      `json:"author,omitempty"`
-	Score     float64     `json:"score"`
-	Snippet   string      `json:"snippet"`
-	Timestamp *time.Time  `json:"timestamp,omitempty"`
-	Address   interface{} `json:"address,omitempty"`
-	Metadata  interface{} `json:"metadata,omitempty"`
-}
-
-func (s *APIServer) searchConversations(query, authorFilter string, limit int) []SearchResult {
-	var results []SearchResult
-
-	conversations, err := s.contextManager.SearchConversations(query)
+	thread, err := s.contextManager.GetConversation(threadID)
 	if err != nil {
-		return results
-	}
-
-	for i, conv := range conversations {
-		if i >= limit {
-			break
-		}
-
-		// Apply author filter if specified
-		if authorFilter != "" {
-			found := false
-			for _, participant := range conv.Participants {
-				if string(participant) == authorFilter {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
-			}
-		}
-
-		// Calculate basic relevance score
-		score := s.calculateConversationScore(conv, query)
-
-		// Create snippet from first message
-		snippet := ""
-		if len(conv.Messages) > 0 {
-			content := conv.Messages[0].Content
-			if len(content) > 200 {
-				content = content[:200] + "..."
-			}
-			snippet = content
-		}
-
-		results = append(results, SearchResult{
-			Type:      "conversation",
-			ID:        string(conv.ID),
-			Title:     conv.Title,
-			Content:   snippet,
-			Score:     score,
-			Snippet:   snippet,
-			Timestamp: &conv.CreatedAt,
-			Address:   conv.AnchorAddress,
-			Metadata:  map[string]interface{}{"participants": len(conv.Participants), "messages": len(conv.Messages)},
-		})
+		s.lookupError(w, r, "Conversation", err)
+		return
 	}
 
-	return results
+	s.respond(w, r, SuccessResponse{
+		Data:    thread,
+		Message: "Conversation resolved",
+	}, http.StatusOK)
 }
 
-func (s *APIServer) searchOperations(query, authorFilter string, limit int) []SearchResult {
-	var results []SearchResult
-
-	// Get recent operations (last week)
-	since := time.Now().Add(-7 * 24 * time.Hour)
-	operations, err := s.store.GetOperationsSince(since)
-	if err != nil {
-		return results
-	}
-
-	count := 0
-	for _, op := range operations {
-		if count >= limit {
-			break
-		}
-
-		// Apply author filter if specified
-		if authorFilter != "" && string(op.Author) != authorFilter {
-			continue
-		}
-
-		// Check if operation content matches query
-		if !s.matchesQuery(op.Content, query) && !s.matchesQuery(string(op.Author), query) {
-			continue
-		}
-
-		// Calculate relevance score
-		score := s.calculateOperationScore(op, query)
-
-		// Create snippet
-		snippet := op.Content
-		if len(snippet) > 150 {
-			snippet = snippet[:150] + "..."
-		}
-
-		results = append(results, SearchResult{
-			Type:      "operation",
-			ID:        fmt.Sprintf("%x", op.ID),
-			Content:   op.Content,
-			Author:    string(op.Author),
-			Score:     score,
-			Snippet:   snippet,
-			Timestamp: &op.Timestamp,
-			Metadata:  map[string]interface{}{"type": op.Type, "position": op.Position},
-		})
-		count++
+// Analysis endpoints (basic implementation for MVP)
+func (s *APIServer) getOperationContext(w http.ResponseWriter, r *http.Request) {
+	opIDStr := r.PathValue("id")
+	if opIDStr == "" {
+		s.jsonError(w, r, "Operation ID is required", http.StatusBadRequest)
+		return
 	}
 
-	return results
-}
-
-func (s *APIServer) searchCode(query string, limit int) []SearchResult {
-	var results []SearchResult
+	opID := operations.OperationID(opIDStr)
 
-	// Basic code search - search through stored documents
-	// This is a simplified implementation for MVP
-	documents, err := s.documentStore.ListDocuments()
+	op, err := s.store.GetOperation(r.Context(), opID)
 	if err != nil {
-		return results
-	}
-
-	count := 0
-	for _, docPath := range documents {
-		if count >= limit {
-			break
-		}
-
-		doc, err := s.documentStore.GetDocument(docPath)
-		if err != nil {
-			continue
-		}
-
-		// Render document content
-		content, err := doc.Render()
-		if err != nil {
-			continue
-		}
-
-		// Check if content matches query
-		if !s.matchesQuery(content, query) && !s.matchesQuery(docPath, query) {
-			continue
-		}
-
-		// Calculate relevance score
-		score := s.calculateCodeScore(content, docPath, query)
-
-		// Create snippet with context around match
-		snippet := s.createCodeSnippet(content, query)
-
-		results = append(results, SearchResult{
-			Type:     "code",
-			ID:       docPath,
-			Title:    docPath,
-			Content:  snippet,
-			Score:    score,
-			Snippet:  snippet,
-			Metadata: map[string]interface{}{"constructs": len(doc.Constructs), "version": doc.Version},
-		})
-		count++
-	}
-
-	return results
-}
-
-// Helper functions for search scoring and matching
-func (s *APIServer) matchesQuery(text, query string) bool {
-	return strings.Contains(strings.ToLower(text), strings.ToLower(query))
-}
-
-func (s *APIServer) calculateConversationScore(conv *context.ConversationThread, query string) float64 {
-	score := 0.0
-	queryLower := strings.ToLower(query)
-
-	// Title match gets higher score
-	if strings.Contains(strings.ToLower(conv.Title), queryLower) {
-		score += 2.0
+		s.lookupError(w, r, "Operation", err)
+		return
 	}
 
-	// Message content matches
-	for _, msg := range conv.Messages {
-		if strings.Contains(strings.ToLower(msg.Content), queryLower) {
-			score += 1.0
+	// Basic context analysis
+	contextInfo := OperationContext{
+		Operation:   op,
+		Intent:      s.analyzeBasicIntent(op),
+		Confidence:  0.7, // Basic confidence for MVP
+		Discussions: []*context.ConversationThread{},
+	}
+	viewer := conversationViewer(r)
+	for _, thread := range s.contextManager.GetConversationsByOperation(opID) {
+		if viewer.CanView(thread) {
+			contextInfo.Discussions = append(contextInfo.Discussions, thread)
 		}
 	}
 
-	// Recent conversations get slight boost
-	age := time.Since(conv.UpdatedAt).Hours()
-	if age < 24 {
-		score += 0.5
-	} else if age < 168 { // 1 week
-		score += 0.2
-	}
-
-	return score
+	s.respond(w, r, SuccessResponse{Data: contextInfo}, http.StatusOK)
 }
 
-func (s *APIServer) calculateOperationScore(op *operations.Operation, query string) float64 {
-	score := 0.0
-	queryLower := strings.ToLower(query)
-
-	// Content match
-	if strings.Contains(strings.ToLower(op.Content), queryLower) {
-		score += 1.0
-	}
-
-	// Author match
-	if strings.Contains(strings.ToLower(string(op.Author)), queryLower) {
-		score += 0.5
-	}
-
-	// Recent operations get boost
-	age := time.Since(op.Timestamp).Hours()
-	if age < 1 {
-		score += 1.0
-	} else if age < 24 {
-		score += 0.5
-	} else if age < 168 { // 1 week
-		score += 0.2
-	}
-
-	return score
-}
-
-func (s *APIServer) calculateCodeScore(content, path, query string) float64 {
-	score := 0.0
-	queryLower := strings.ToLower(query)
+func (s *APIServer) analyzeIntent(w http.ResponseWriter, r *http.Request) {
+	var req AnalyzeIntentRequest
 
-	// Path/filename match gets higher score
-	if strings.Contains(strings.ToLower(path), queryLower) {
-		score += 1.5
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
 	}
 
-	// Content matches
-	contentLower := strings.ToLower(content)
-	matches := strings.Count(contentLower, queryLower)
-	score += float64(matches) * 0.5
-
-	return score
-}
-
-func (s *APIServer) createCodeSnippet(content, query string) string {
-	lines := strings.Split(content, "\n")
-	queryLower := strings.ToLower(query)
-
-	// Find first line containing the query
-	for i, line := range lines {
-		if strings.Contains(strings.ToLower(line), queryLower) {
-			// Return context around the match (3 lines before and after)
-			start := i - 3
-			if start < 0 {
-				start = 0
-			}
-			end := i + 4
-			if end > len(lines) {
-				end = len(lines)
-			}
-
-			snippet := strings.Join(lines[start:end], "\n")
-			if len(snippet) > 300 {
-				snippet = snippet[:300] + "..."
-			}
-			return snippet
-		}
+	analysis := IntentAnalysis{
+		PrimaryIntent: "code_change",
+		Confidence:    0.8,
+		Evidence:      []string{"operation_type_analysis"},
+		Category:      "development",
 	}
 
-	// If no match found, return first 200 chars
-	if len(content) > 200 {
-		return content[:200] + "..."
-	}
-	return content
-}
-
-func (s *APIServer) sortResultsByScore(results []SearchResult) {
-	// Simple bubble sort by score (descending)
-	for i := 0; i < len(results)-1; i++ {
-		for j := 0; j < len(results)-i-1; j++ {
-			if results[j].Score < results[j+1].Score {
-				results[j], results[j+1] = results[j+1], results[j]
-			}
-		}
-	}
+	s.respond(w, r, SuccessResponse{Data: analysis}, http.StatusOK)
 }
 
 // Health check endpoint
-func (s *APIServer) healthCheck(w http.ResponseWriter, r *http.Request) {
-	health := struct {
-		Status    string    `json:"status"`
-		Timestamp time.Time `json:"timestamp"`
-		Version   string    `json:"version"`
-	}{
-		Status:    "healthy",
-		Timestamp: time.Now(),
-		Version:   "1.0.0-mvp",
-	}
-
-	s.jsonResponse(w, health, http.StatusOK)
-}
-
 // Operation intent analysis endpoint
 func (s *APIServer) getOperationIntent(w http.ResponseWriter, r *http.Request) {
 	opIDStr := r.PathValue("id")
 	if opIDStr == "" {
-		s.jsonError(w, "Operation ID is required", http.StatusBadRequest)
+		s.jsonError(w, r, "Operation ID is required", http.StatusBadRequest)
 		return
 	}
 
 	opID := operations.OperationID(opIDStr)
 
-	op, err := s.store.GetOperation(opID)
+	op, err := s.store.GetOperation(r.Context(), opID)
 	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Operation not found: %v", err), http.StatusNotFound)
+		s.lookupError(w, r, "Operation", err)
 		return
 	}
 
@@ -862,89 +1053,81 @@ func (s *APIServer) getOperationIntent(w http.ResponseWriter, r *http.Request) {
 	context, err := s.contextAnalyzer.GetOperationContext(opID)
 	if err != nil {
 		// Fallback to basic analysis
-		response := map[string]interface{}{
-			"operation_id": opID,
-			"basic_intent": s.analyzeBasicIntent(op),
-			"confidence":   0.5,
+		response := OperationIntent{
+			OperationID: opID,
+			BasicIntent: s.analyzeBasicIntent(op),
+			Confidence:  0.5,
 		}
-		s.jsonResponse(w, response, http.StatusOK)
+		s.respondLegacy(w, r, SuccessResponse{Data: response}, response, http.StatusOK)
 		return
 	}
 
-	response := map[string]interface{}{
-		"operation_id": opID,
-		"intent":       context.Intent,
-		"basic_intent": s.analyzeBasicIntent(op),
-		"confidence":   0.8,
-		"summary":      context.Summary,
+	response := OperationIntent{
+		OperationID: opID,
+		Intent:      context.Intent,
+		BasicIntent: s.analyzeBasicIntent(op),
+		Confidence:  0.8,
+		Summary:     context.Summary,
 	}
 
-	s.jsonResponse(w, response, http.StatusOK)
+	s.respondLegacy(w, r, SuccessResponse{Data: response}, response, http.StatusOK)
 }
 
 func (s *APIServer) analyzeBatchIntent(w http.ResponseWriter, r *http.Request) {
-	var request struct {
-		Operations []operations.OperationID `json:"operations"`
-	}
+	var request BatchIntentRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		s.jsonError(w, "Invalid request body", http.StatusBadRequest)
+		s.jsonError(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
 	if len(request.Operations) == 0 {
-		s.jsonError(w, "At least one operation ID is required", http.StatusBadRequest)
+		s.writeError(w, r, validationError("Invalid request", FieldError{Field: "operations", Message: "at least one operation ID is required"}))
 		return
 	}
 
 	// Get operations from store
-	ops, err := s.store.GetOperations(request.Operations)
+	ops, err := s.store.GetOperations(r.Context(), request.Operations)
 	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to retrieve operations: %v", err), http.StatusInternalServerError)
+		s.internalError(w, r, "Failed to retrieve operations", err)
 		return
 	}
 
 	// Analyze collective intent
 	analysis, err := s.contextAnalyzer.AnalyzeChangeIntent(ops)
 	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to analyze intent: %v", err), http.StatusInternalServerError)
+		s.internalError(w, r, "Failed to analyze intent", err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"operations_count":   len(ops),
-		"collective_intent":  analysis,
-		"individual_intents": make([]map[string]interface{}, 0, len(ops)),
+	response := BatchIntentAnalysis{
+		OperationsCount:   len(ops),
+		CollectiveIntent:  analysis,
+		IndividualIntents: make([]IndividualIntent, 0, len(ops)),
 	}
 
 	// Add individual analysis for each operation
 	for _, op := range ops {
-		individual := map[string]interface{}{
-			"operation_id": op.ID,
-			"basic_intent": s.analyzeBasicIntent(op),
-		}
-		response["individual_intents"] = append(response["individual_intents"].([]map[string]interface{}), individual)
+		response.IndividualIntents = append(response.IndividualIntents, IndividualIntent{
+			OperationID: op.ID,
+			BasicIntent: s.analyzeBasicIntent(op),
+		})
 	}
 
-	s.jsonResponse(w, response, http.StatusOK)
+	s.respondLegacy(w, r, SuccessResponse{Data: response}, response, http.StatusOK)
 }
 
 // Authentication endpoints
 func (s *APIServer) createAPIKey(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Name        string              `json:"name"`
-		AuthorID    operations.AuthorID `json:"author_id"`
-		Permissions []auth.Permission   `json:"permissions"`
-		ExpiresIn   *int                `json:"expires_in_hours,omitempty"`
-	}
+	var req CreateAPIKeyRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.jsonError(w, "Invalid JSON payload", http.StatusBadRequest)
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
 		return
 	}
 
 	if req.Name == "" {
-		s.jsonError(w, "Name is required", http.StatusBadRequest)
+		s.writeError(w, r, validationError("Invalid request", FieldError{Field: "name", Message: "is required"}))
 		return
 	}
 
@@ -956,104 +1139,226 @@ func (s *APIServer) createAPIKey(w http.ResponseWriter, r *http.Request) {
 
 	keyString, err := s.authManager.CreateAPIKey(req.Name, req.AuthorID, req.Permissions, expiresIn)
 	if err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to create API key: %v", err), http.StatusInternalServerError)
+		s.internalError(w, r, "Failed to create API key", err)
 		return
 	}
 
+	message := "API key created successfully. Store this key securely - it won't be shown again."
 	response := map[string]interface{}{
 		"api_key": keyString,
-		"message": "API key created successfully. Store this key securely - it won't be shown again.",
+		"message": message,
 	}
 
-	s.jsonResponse(w, response, http.StatusCreated)
+	s.respondLegacy(w, r, SuccessResponse{
+		Data:    CreatedAPIKey{APIKey: keyString},
+		Message: message,
+	}, response, http.StatusCreated)
 }
 
 func (s *APIServer) listAPIKeys(w http.ResponseWriter, r *http.Request) {
 	keys := s.authManager.ListAPIKeys()
-	s.jsonResponse(w, map[string]interface{}{"keys": keys}, http.StatusOK)
+	s.respondLegacy(w, r, SuccessResponse{
+		Data: keys,
+		Meta: &ResponseMeta{Total: len(keys)},
+	}, map[string]interface{}{"keys": keys}, http.StatusOK)
 }
 
 func (s *APIServer) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
 	keyID := r.PathValue("id")
 	if keyID == "" {
-		s.jsonError(w, "Key ID is required", http.StatusBadRequest)
+		s.jsonError(w, r, "Key ID is required", http.StatusBadRequest)
 		return
 	}
 
 	if err := s.authManager.RevokeAPIKey(keyID); err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to revoke key: %v", err), http.StatusNotFound)
+		s.lookupError(w, r, "API key", err)
 		return
 	}
 
-	s.jsonResponse(w, map[string]string{"message": "API key revoked successfully"}, http.StatusOK)
+	s.respondMessage(w, r, "API key revoked successfully", http.StatusOK)
 }
 
 func (s *APIServer) getAuthStatus(w http.ResponseWriter, r *http.Request) {
 	authContext := auth.GetAuthContext(r.Context())
 
-	status := map[string]interface{}{
-		"auth_required": s.authManager.IsAuthRequired(),
-		"authenticated": authContext != nil && authContext.Authenticated,
-		"author_id":     "",
-		"permissions":   []string{},
+	status := AuthStatus{
+		AuthRequired:       s.authManager.IsAuthRequired(),
+		SignaturesRequired: s.authManager.SignaturesRequired(),
+		Authenticated:      authContext != nil && authContext.Authenticated,
+		Permissions:        []auth.Permission{},
 	}
 
 	if authContext != nil {
-		status["author_id"] = authContext.AuthorID
-		status["permissions"] = authContext.Permissions
+		status.AuthorID = authContext.AuthorID
+		status.Permissions = authContext.Permissions
 	}
 
-	s.jsonResponse(w, status, http.StatusOK)
+	s.respondLegacy(w, r, SuccessResponse{Data: status}, status, http.StatusOK)
 }
 
 func (s *APIServer) enableAuth(w http.ResponseWriter, r *http.Request) {
 	if err := s.authManager.EnableAuth(); err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to enable auth: %v", err), http.StatusInternalServerError)
+		s.internalError(w, r, "Failed to enable auth", err)
 		return
 	}
 
-	s.jsonResponse(w, map[string]string{"message": "Authentication enabled"}, http.StatusOK)
+	s.respondMessage(w, r, "Authentication enabled", http.StatusOK)
 }
 
 func (s *APIServer) disableAuth(w http.ResponseWriter, r *http.Request) {
 	if err := s.authManager.DisableAuth(); err != nil {
-		s.jsonError(w, fmt.Sprintf("Failed to disable auth: %v", err), http.StatusInternalServerError)
+		s.internalError(w, r, "Failed to disable auth", err)
+		return
+	}
+
+	s.respondMessage(w, r, "Authentication disabled", http.StatusOK)
+}
+
+// Admin usage endpoints
+func (s *APIServer) listUsage(w http.ResponseWriter, r *http.Request) {
+	since, err := parseUsageSince(r)
+	if err != nil {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "since", Message: "must be a date like 2006-01-02"}))
+		return
+	}
+
+	usage := s.authManager.Usage().GetAllUsage(since)
+	s.respond(w, r, SuccessResponse{
+		Data: usage,
+		Meta: &ResponseMeta{Total: len(usage)},
+	}, http.StatusOK)
+}
+
+func (s *APIServer) getKeyUsage(w http.ResponseWriter, r *http.Request) {
+	keyID := r.PathValue("key_id")
+	if keyID == "" {
+		s.jsonError(w, r, "Key ID is required", http.StatusBadRequest)
 		return
 	}
 
-	s.jsonResponse(w, map[string]string{"message": "Authentication disabled"}, http.StatusOK)
+	since, err := parseUsageSince(r)
+	if err != nil {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "since", Message: "must be a date like 2006-01-02"}))
+		return
+	}
+
+	usage := s.authManager.Usage().GetUsage(keyID, since)
+	s.respond(w, r, SuccessResponse{Data: usage}, http.StatusOK)
+}
+
+// Admin retention endpoints
+func (s *APIServer) getRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, err := s.engine.GetRetentionPolicy()
+	if err != nil {
+		s.internalError(w, r, "Failed to load retention policy", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: policy}, http.StatusOK)
+}
+
+func (s *APIServer) setRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy storage.RetentionPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		s.writeError(w, r, validationError("Invalid retention policy", FieldError{Field: "policy", Message: "durations must look like \"720h\""}))
+		return
+	}
+
+	if err := s.engine.SetRetentionPolicy(policy); err != nil {
+		s.internalError(w, r, "Failed to save retention policy", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data:    policy,
+		Message: "Retention policy updated",
+	}, http.StatusOK)
+}
+
+func (s *APIServer) previewRetention(w http.ResponseWriter, r *http.Request) {
+	report, err := s.engine.EnforceRetention(r.Context(), true)
+	if err != nil {
+		s.internalError(w, r, "Failed to preview retention", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: report}, http.StatusOK)
+}
+
+func (s *APIServer) enforceRetention(w http.ResponseWriter, r *http.Request) {
+	report, err := s.engine.EnforceRetention(r.Context(), false)
+	if err != nil {
+		s.internalError(w, r, "Failed to enforce retention", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data:    report,
+		Message: "Retention policy enforced",
+	}, http.StatusOK)
+}
+
+func (s *APIServer) getStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.engine.Stats(r.Context())
+	if err != nil {
+		s.internalError(w, r, "Failed to gather statistics", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: ServerStats{Stats: *stats, RecoveredPanics: s.panics.Load()}}, http.StatusOK)
+}
+
+func (s *APIServer) checkIntegrity(w http.ResponseWriter, r *http.Request) {
+	report, err := s.engine.CheckIntegrity(r.Context(), false)
+	if err != nil {
+		s.internalError(w, r, "Failed to check store integrity", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: report}, http.StatusOK)
+}
+
+func (s *APIServer) repairIntegrity(w http.ResponseWriter, r *http.Request) {
+	report, err := s.engine.CheckIntegrity(r.Context(), true)
+	if err != nil {
+		s.internalError(w, r, "Failed to repair store", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data:    report,
+		Message: "Orphaned records repaired",
+	}, http.StatusOK)
+}
+
+func parseUsageSince(r *http.Request) (time.Time, error) {
+	sinceStr := r.URL.Query().Get("since")
+	if sinceStr == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", sinceStr)
 }
 
 // Permalink endpoint - resolves operation IDs to their context
 func (s *APIServer) resolvePermalink(w http.ResponseWriter, r *http.Request) {
 	operationID := r.PathValue("operation_id")
 	if operationID == "" {
-		s.jsonError(w, "Operation ID required", http.StatusBadRequest)
+		s.jsonError(w, r, "Operation ID required", http.StatusBadRequest)
 		return
 	}
 
 	// Get the operation
-	op, err := s.store.GetOperation(operations.OperationID(operationID))
+	op, err := s.store.GetOperation(r.Context(), operations.OperationID(operationID))
 	if err != nil {
-		s.jsonError(w, "Operation not found", http.StatusNotFound)
+		s.lookupError(w, r, "Operation", err)
 		return
 	}
 
 	// Build permalink response with operation details and context
-	permalinkData := struct {
-		OperationID  string                `json:"operation_id"`
-		Operation    *operations.Operation `json:"operation"`
-		DocumentPath string                `json:"document_path"`
-		LineNumber   string                `json:"line_number,omitempty"`
-		Column       string                `json:"column,omitempty"`
-		Context      map[string]string     `json:"context,omitempty"`
-		CreatedAt    string                `json:"created_at"`
-		Author       string                `json:"author"`
-		Permalink    bool                  `json:"is_permalink"`
-	}{
+	permalinkData := Permalink{
 		OperationID:  operationID,
 		Operation:    op,
-		DocumentPath: op.Metadata.Context["document_id"],
+		DocumentPath: op.Metadata.DocumentID,
 		LineNumber:   op.Metadata.Context["line_number"],
 		Column:       op.Metadata.Context["column"],
 		Context:      op.Metadata.Context,
@@ -1069,7 +1374,7 @@ func (s *APIServer) resolvePermalink(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return JSON response for API clients
-	s.jsonResponse(w, permalinkData, http.StatusOK)
+	s.respondLegacy(w, r, SuccessResponse{Data: permalinkData}, permalinkData, http.StatusOK)
 }
 
 // Render HTML page for permalink viewing
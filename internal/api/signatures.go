@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+type RegisterSigningKeyRequest struct {
+	Name string `json:"name,omitempty"`
+	// AuthorID defaults to the caller's author; only admins register keys for others
+	AuthorID operations.AuthorID `json:"author_id,omitempty"`
+	// PublicKey is a base64 Ed25519 public key
+	PublicKey string `json:"public_key"`
+}
+
+// canManageSigningKeys reports whether the request may register or revoke
+// keys that sign as author
+func canManageSigningKeys(r *http.Request, author operations.AuthorID) bool {
+	authContext := auth.GetAuthContext(r.Context())
+	if authContext == nil {
+		return false
+	}
+	return authContext.HasPermission(auth.PermissionAdmin) || authContext.AuthorID == author
+}
+
+func (s *APIServer) registerSigningKey(w http.ResponseWriter, r *http.Request) {
+	var req RegisterSigningKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	if req.AuthorID == "" {
+		if authContext := auth.GetAuthContext(r.Context()); authContext != nil {
+			req.AuthorID = authContext.AuthorID
+		}
+	}
+	if !canManageSigningKeys(r, req.AuthorID) {
+		s.jsonError(w, r, "Only admins may register keys for other authors", http.StatusForbidden)
+		return
+	}
+
+	key, err := s.authManager.RegisterSigningKey(req.Name, req.AuthorID, req.PublicKey)
+	switch {
+	case errors.Is(err, auth.ErrInvalidSigningKey):
+		s.writeError(w, r, validationError("Invalid signing key", FieldError{Field: "public_key", Message: err.Error()}))
+		return
+	case errors.Is(err, operations.ErrInvalidAuthor):
+		s.writeError(w, r, validationError("Invalid signing key", FieldError{Field: "author_id", Message: "is required"}))
+		return
+	case errors.Is(err, auth.ErrSigningKeyExists):
+		s.jsonError(w, r, "Signing key is already registered", http.StatusConflict)
+		return
+	case err != nil:
+		s.internalError(w, r, "Failed to register signing key", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: key, Message: "Signing key registered"}, http.StatusCreated)
+}
+
+func (s *APIServer) listSigningKeys(w http.ResponseWriter, r *http.Request) {
+	keys := s.authManager.SigningKeys(operations.AuthorID(r.URL.Query().Get("author")))
+	s.respond(w, r, SuccessResponse{
+		Data: keys,
+		Meta: &ResponseMeta{Total: len(keys)},
+	}, http.StatusOK)
+}
+
+func (s *APIServer) revokeSigningKey(w http.ResponseWriter, r *http.Request) {
+	key, err := s.authManager.GetSigningKey(r.PathValue("id"))
+	if err != nil {
+		s.lookupError(w, r, "Signing key", err)
+		return
+	}
+	if !canManageSigningKeys(r, key.AuthorID) {
+		s.jsonError(w, r, "Only admins may revoke other authors' keys", http.StatusForbidden)
+		return
+	}
+
+	if err := s.authManager.RevokeSigningKey(key.ID); err != nil {
+		s.lookupError(w, r, "Signing key", err)
+		return
+	}
+	s.respondMessage(w, r, "Signing key revoked", http.StatusOK)
+}
+
+func (s *APIServer) requireSignatures(w http.ResponseWriter, r *http.Request) {
+	if err := s.authManager.RequireSignatures(); err != nil {
+		s.internalError(w, r, "Failed to require signatures", err)
+		return
+	}
+	s.respondMessage(w, r, "Operations must now be signed by their author", http.StatusOK)
+}
+
+func (s *APIServer) allowUnsignedOperations(w http.ResponseWriter, r *http.Request) {
+	if err := s.authManager.AllowUnsignedOperations(); err != nil {
+		s.internalError(w, r, "Failed to stop requiring signatures", err)
+		return
+	}
+	s.respondMessage(w, r, "Unsigned operations are accepted", http.StatusOK)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/embeddings"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const (
+	defaultSimilarLimit = 10
+	maxSimilarLimit     = 100
+)
+
+// WithEmbeddings enables similarity search with vectors from indexer
+func WithEmbeddings(indexer *embeddings.Indexer) ServerOption {
+	return func(s *APIServer) {
+		s.embeddings = indexer
+	}
+}
+
+// requireEmbeddings wraps a handler that needs an embeddings provider to be configured
+func (s *APIServer) requireEmbeddings(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.embeddings == nil {
+			s.jsonError(w, r, "Embeddings are not enabled on this server", http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// similar finds the operations or messages closest in meaning to one
+// operation or message, by the cosine similarity of their embeddings.
+func (s *APIServer) similar(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	operationID, messageID := query.Get("operation_id"), query.Get("message_id")
+
+	var source storage.VectorKind
+	var ref, field string
+	switch {
+	case operationID != "" && messageID != "":
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "message_id", Message: "can't be given with operation_id"}))
+		return
+	case operationID != "":
+		source, ref, field = storage.VectorOperation, operationID, "operation_id"
+	case messageID != "":
+		source, ref, field = storage.VectorMessage, messageID, "message_id"
+	default:
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "operation_id", Message: "is required unless message_id is given"}))
+		return
+	}
+
+	kind := storage.VectorKind(query.Get("kind"))
+	switch kind {
+	case "":
+		kind = storage.VectorOperation
+	case storage.VectorOperation, storage.VectorMessage:
+	default:
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "kind", Message: "must be operation or message"}))
+		return
+	}
+
+	limit := defaultSimilarLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > maxSimilarLimit {
+			message := fmt.Sprintf("must be between 1 and %d", maxSimilarLimit)
+			s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "limit", Message: message}))
+			return
+		}
+		limit = parsed
+	}
+
+	matches, err := s.embeddings.Similar(r.Context(), source, ref, []storage.VectorKind{kind}, limit)
+	switch {
+	case errors.Is(err, embeddings.ErrNothingToEmbed):
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: field, Message: "has no text content to compare"}))
+		return
+	case errors.Is(err, embeddings.ErrProviderFailed):
+		s.logger.Error("Embedding provider failed", map[string]interface{}{"error": err.Error()})
+		s.jsonError(w, r, "Embedding provider is unavailable", http.StatusServiceUnavailable)
+		return
+	case err != nil && source == storage.VectorMessage:
+		s.lookupError(w, r, "Message", err)
+		return
+	case err != nil:
+		s.lookupError(w, r, "Operation", err)
+		return
+	}
+
+	results := make([]SimilarResult, 0, len(matches))
+	for _, match := range matches {
+		result := SimilarResult{Kind: string(match.Kind), ID: match.Ref, Similarity: match.Similarity}
+
+		switch match.Kind {
+		case storage.VectorOperation:
+			op, err := s.store.GetOperation(r.Context(), operations.OperationID(match.Ref))
+			if err != nil {
+				continue
+			}
+			result.Author = string(op.Author)
+			result.Content = op.Content
+			result.Timestamp = &op.Timestamp
+
+		case storage.VectorMessage:
+			conv, err := s.contextManager.ViewConversation(context.ThreadID(match.Parent), conversationViewer(r))
+			if err != nil {
+				continue
+			}
+			message := findMessage(conv, context.MessageID(match.Ref))
+			if message == nil {
+				continue
+			}
+			result.ThreadID = match.Parent
+			result.Author = string(message.AuthorID)
+			result.Content = message.Content
+			result.Timestamp = &message.Timestamp
+		}
+		results = append(results, result)
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data: SimilarResults{
+			SourceKind: string(source),
+			SourceID:   ref,
+			Model:      s.embeddings.Model(),
+			Results:    results,
+		},
+		Meta: &ResponseMeta{Total: len(results), Limit: limit},
+	}, http.StatusOK)
+}
+
+func findMessage(conv *context.ConversationThread, id context.MessageID) *context.Message {
+	for i := range conv.Messages {
+		if conv.Messages[i].ID == id {
+			return &conv.Messages[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/summaries"
+)
+
+// WithSummaries enables summarizing conversations on request with service
+func WithSummaries(service *summaries.Service) ServerOption {
+	return func(s *APIServer) {
+		s.summaries = service
+	}
+}
+
+// requireSummaries wraps a handler that needs a summarizer to be configured
+func (s *APIServer) requireSummaries(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.summaries == nil {
+			s.jsonError(w, r, "Summaries are not enabled on this server", http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// summarizeConversation sums up a thread as it stands, replacing the summary
+// it was given when it was resolved or last summarized
+func (s *APIServer) summarizeConversation(w http.ResponseWriter, r *http.Request) {
+	threadID := context.ThreadID(r.PathValue("id"))
+	if _, ok := s.viewConversation(w, r, threadID); !ok {
+		return
+	}
+
+	_, err := s.summaries.Summarize(r.Context(), threadID)
+	switch {
+	case errors.Is(err, summaries.ErrNothingToSummarize):
+		s.jsonError(w, r, "Conversation has nothing to summarize", http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, summaries.ErrSummarizerFailed):
+		s.logger.Error("Summarizer failed", map[string]interface{}{"thread_id": string(threadID), "error": err.Error()})
+		s.jsonError(w, r, "Summarizer is unavailable", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		s.lookupError(w, r, "Conversation", err)
+		return
+	}
+
+	thread, ok := s.viewConversation(w, r, threadID)
+	if !ok {
+		return
+	}
+	s.respond(w, r, SuccessResponse{
+		Data:    thread,
+		Message: "Conversation summarized",
+	}, http.StatusOK)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+	"github.com/jeremytregunna/contextdb/internal/summaries"
+)
+
+func TestSummarizeConversation(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	authManager, err := auth.NewAuthManager(dir)
+	if err != nil {
+		t.Fatalf("Failed to create auth manager: %v", err)
+	}
+
+	engine := collaboration.NewCollaborationEngine(store)
+	manager := engine.ConversationManager()
+	newServer := func(opts ...ServerOption) *APIServer {
+		return NewAPIServer(engine, store, store, engine.AddressResolver(), manager, engine.ContextAnalyzer(), authManager, opts...)
+	}
+	summarize := func(s *APIServer, threadID context.ThreadID) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/conversations/"+string(threadID)+"/summarize", nil)
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	thread, err := manager.CreateConversation(addressing.StableAddress{}, "alice", "Cache size", "The cache evicts too often under load.")
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if recorder := summarize(newServer(), thread.ID); recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without a summarizer, got %d", recorder.Code)
+	}
+
+	s := newServer(WithSummaries(summaries.NewService(summaries.NewExtractiveSummarizer(1), manager)))
+	recorder := summarize(s, thread.ID)
+	var resp struct {
+		Data context.ConversationThread `json:"data"`
+	}
+	if recorder.Code != http.StatusOK || json.Unmarshal(recorder.Body.Bytes(), &resp) != nil {
+		t.Fatalf("Failed to summarize: %d %s", recorder.Code, recorder.Body)
+	}
+	if resp.Data.Summary == nil || resp.Data.Summary.Text != "The cache evicts too often under load." {
+		t.Errorf("Expected the opening sentence as the summary, got %+v", resp.Data.Summary)
+	}
+
+	code, err := manager.CreateConversation(addressing.StableAddress{}, "alice", "Snippet", "```\nevict()\n```")
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if recorder := summarize(s, code.ID); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a thread with nothing to summarize, got %d", recorder.Code)
+	}
+	if recorder := summarize(s, "missing"); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing thread, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+)
+
+type TagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type LabelsRequest struct {
+	Labels []string `json:"labels"`
+}
+
+// conversationFilter reads the tag, label and status query parameters
+func conversationFilter(r *http.Request) (context.ConversationFilter, *ErrorResponse) {
+	filter, errResp := tagFilter(r)
+	if errResp != nil {
+		return filter, errResp
+	}
+
+	filter.Status = context.ThreadStatus(r.URL.Query().Get("status"))
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return filter, validationError("Invalid query parameter", FieldError{Field: "status", Message: "must be open, resolved, archived or pinned"})
+	}
+	return filter, nil
+}
+
+// tagFilter reads the tag and label query parameters. Both may repeat, a
+// thread must carry all of them to match.
+func tagFilter(r *http.Request) (context.ConversationFilter, *ErrorResponse) {
+	query := r.URL.Query()
+	filter := context.ConversationFilter{Tags: query["tag"], Labels: query["label"]}
+
+	for field, tags := range map[string][]string{"tag": filter.Tags, "label": filter.Labels} {
+		for _, tag := range tags {
+			if _, err := context.NormalizeTag(tag); err != nil {
+				return filter, validationError("Invalid query parameter", FieldError{Field: field, Message: err.Error()})
+			}
+		}
+	}
+	return filter, nil
+}
+
+func (s *APIServer) listConversations(w http.ResponseWriter, r *http.Request) {
+	filter, errResp := conversationFilter(r)
+	if errResp != nil {
+		s.writeError(w, r, errResp)
+		return
+	}
+	filter.ChangeSet = context.ChangeSetID(r.URL.Query().Get("changeset"))
+	filter.Branch = r.URL.Query().Get("branch")
+	filter.Viewer = conversationViewer(r)
+
+	threads, err := s.contextManager.ListConversations(filter)
+	if err != nil {
+		s.internalError(w, r, "Failed to list conversations", err)
+		return
+	}
+
+	threads, meta := page(r, threads)
+	s.respond(w, r, SuccessResponse{Data: threads, Meta: meta}, http.StatusOK)
+}
+
+func (s *APIServer) addConversationTags(w http.ResponseWriter, r *http.Request) {
+	var req TagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if len(req.Tags) == 0 {
+		s.writeError(w, r, validationError("Invalid tags", FieldError{Field: "tags", Message: "is required"}))
+		return
+	}
+
+	threadID := context.ThreadID(r.PathValue("id"))
+	if _, ok := s.viewConversation(w, r, threadID); !ok {
+		return
+	}
+	thread, err := s.contextManager.AddTags(threadID, req.Tags...)
+	s.respondTagged(w, r, thread, "tags", err)
+}
+
+func (s *APIServer) removeConversationTag(w http.ResponseWriter, r *http.Request) {
+	threadID := context.ThreadID(r.PathValue("id"))
+	if _, ok := s.viewConversation(w, r, threadID); !ok {
+		return
+	}
+	thread, err := s.contextManager.RemoveTags(threadID, r.PathValue("tag"))
+	s.respondTagged(w, r, thread, "tag", err)
+}
+
+func (s *APIServer) addConversationLabels(w http.ResponseWriter, r *http.Request) {
+	var req LabelsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if len(req.Labels) == 0 {
+		s.writeError(w, r, validationError("Invalid labels", FieldError{Field: "labels", Message: "is required"}))
+		return
+	}
+
+	threadID := context.ThreadID(r.PathValue("id"))
+	if _, ok := s.viewConversation(w, r, threadID); !ok {
+		return
+	}
+	thread, err := s.contextManager.AddLabels(threadID, req.Labels...)
+	s.respondTagged(w, r, thread, "labels", err)
+}
+
+func (s *APIServer) removeConversationLabel(w http.ResponseWriter, r *http.Request) {
+	threadID := context.ThreadID(r.PathValue("id"))
+	if _, ok := s.viewConversation(w, r, threadID); !ok {
+		return
+	}
+	thread, err := s.contextManager.RemoveLabels(threadID, r.PathValue("label"))
+	s.respondTagged(w, r, thread, "label", err)
+}
+
+func (s *APIServer) respondTagged(w http.ResponseWriter, r *http.Request, thread *context.ConversationThread, field string, err error) {
+	if errors.Is(err, context.ErrInvalidTag) {
+		s.writeError(w, r, validationError("Invalid "+field, FieldError{Field: field, Message: err.Error()}))
+		return
+	}
+	if err != nil {
+		s.lookupError(w, r, "Conversation", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: thread}, http.StatusOK)
+}
+
+func (s *APIServer) listTags(w http.ResponseWriter, r *http.Request) {
+	tags := s.contextManager.TagCounts()
+	s.respond(w, r, SuccessResponse{Data: tags, Meta: &ResponseMeta{Total: len(tags)}}, http.StatusOK)
+}
+
+func (s *APIServer) listLabels(w http.ResponseWriter, r *http.Request) {
+	labels := s.contextManager.LabelCounts()
+	s.respond(w, r, SuccessResponse{Data: labels, Meta: &ResponseMeta{Total: len(labels)}}, http.StatusOK)
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+func (s *APIServer) getTicket(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSpace(r.PathValue("key"))
+	if key == "" {
+		s.writeError(w, r, validationError("Invalid ticket", FieldError{Field: "key", Message: "is required"}))
+		return
+	}
+
+	ticket, err := s.engine.Ticket(r.Context(), key, conversationViewer(r))
+	if err != nil {
+		s.internalError(w, r, "Failed to load ticket", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: ticket}, http.StatusOK)
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/events"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// Request and response bodies. Handlers decode and encode these types
+// directly, and the OpenAPI document is generated from them, so the published
+// schema can't drift from what the server actually does.
+
+type CreateOperationRequest struct {
+	// ID is computed by the server when left out; one given must be what
+	// operations.ComputeID makes of the rest of the request
+	ID          operations.OperationID    `json:"id,omitempty"`
+	Type        operations.OperationType  `json:"type"`
+	Position    operations.LogootPosition `json:"position"`
+	Content     string                    `json:"content"`
+	ContentType string                    `json:"content_type,omitempty"`
+	Length      int                       `json:"length,omitempty"`
+	Author      operations.AuthorID       `json:"author"`
+	Parents     []operations.OperationID  `json:"parents,omitempty"`
+	Metadata    operations.OperationMeta  `json:"metadata,omitempty"`
+	DocumentID  string                    `json:"document_id"`
+	// ExpectedVersion applies the operation only if the document is still at this version
+	ExpectedVersion *uint64 `json:"expected_version,omitempty"`
+	// Timestamp is when the operation was made, now if left out. It is
+	// required with an ID or signature, which both cover it.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+type ResolveAddressRequest struct {
+	Address addressing.StableAddress `json:"address"`
+}
+
+type CreateConversationRequest struct {
+	AnchorAddress addressing.StableAddress `json:"anchor_address"`
+	AuthorID      operations.AuthorID      `json:"author_id"`
+	Title         string                   `json:"title"`
+	Content       string                   `json:"content"`
+	// ChangeSetID anchors the conversation to a change set, with or without an address
+	ChangeSetID context.ChangeSetID `json:"changeset_id,omitempty"`
+	// Visibility defaults to public. Participants may read a participants-only
+	// thread before writing in it.
+	Visibility   context.Visibility    `json:"visibility,omitempty"`
+	Participants []operations.AuthorID `json:"participants,omitempty"`
+	// Branch is the branch the conversation is about, main by default
+	Branch string `json:"branch,omitempty"`
+	// LinkedIssue is the ticket the conversation tracks, an issue key or a
+	// Jira or Linear issue URL. Operations with the issue's key as their
+	// ticket are linked to it, those already written included.
+	LinkedIssue string `json:"linked_issue,omitempty"`
+}
+
+type AddMessageRequest struct {
+	AuthorID    operations.AuthorID `json:"author_id"`
+	Content     string              `json:"content"`
+	MessageType context.MessageType `json:"message_type"`
+	// References link the message to other code
+	References []addressing.StableAddress `json:"references,omitempty"`
+}
+
+type ResolveConversationRequest struct {
+	AuthorID operations.AuthorID `json:"author_id"`
+}
+
+type AnalyzeIntentRequest struct {
+	Operations []*operations.Operation `json:"operations"`
+}
+
+type BatchIntentRequest struct {
+	Operations []operations.OperationID `json:"operations"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name        string              `json:"name"`
+	AuthorID    operations.AuthorID `json:"author_id"`
+	Permissions []auth.Permission   `json:"permissions"`
+	ExpiresIn   *int                `json:"expires_in_hours,omitempty"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string        `json:"url"`
+	Secret string        `json:"secret"`
+	Events []events.Type `json:"events"`
+}
+
+type UpdateWebhookRequest struct {
+	Active *bool `json:"active"`
+}
+
+type DocumentHistory struct {
+	FilePath   string                     `json:"file_path"`
+	Addresses  []addressing.StableAddress `json:"addresses"`
+	Operations []*operations.Operation    `json:"operations,omitempty"`
+}
+
+// MergeBase holds the best common ancestors of operations A and B, none
+// when their histories never met
+type MergeBase struct {
+	A     operations.OperationID  `json:"a"`
+	B     operations.OperationID  `json:"b"`
+	Bases []*operations.Operation `json:"bases"`
+}
+
+type Ancestry struct {
+	Ancestor   operations.OperationID `json:"ancestor"`
+	Descendant operations.OperationID `json:"descendant"`
+	IsAncestor bool                   `json:"is_ancestor"`
+}
+
+type OperationContext struct {
+	Operation  *operations.Operation `json:"operation"`
+	Intent     string                `json:"intent"`
+	Confidence float64               `json:"confidence"`
+	// Discussions are the conversations anchored to the operation's code,
+	// referencing it, or linked to it by its ticket or thread metadata
+	Discussions []*context.ConversationThread `json:"discussions"`
+}
+
+type IntentAnalysis struct {
+	PrimaryIntent string   `json:"primary_intent"`
+	Confidence    float64  `json:"confidence"`
+	Evidence      []string `json:"evidence"`
+	Category      string   `json:"category"`
+}
+
+// The fields of OperationIntent, BatchIntentAnalysis and AuthStatus are in
+// alphabetical order because their v1 bodies were maps, which encode that way.
+
+type OperationIntent struct {
+	BasicIntent string                 `json:"basic_intent"`
+	Confidence  float64                `json:"confidence"`
+	Intent      string                 `json:"intent,omitempty"`
+	OperationID operations.OperationID `json:"operation_id"`
+	Summary     string                 `json:"summary,omitempty"`
+}
+
+type BatchIntentAnalysis struct {
+	CollectiveIntent  *context.IntentAnalysis `json:"collective_intent"`
+	IndividualIntents []IndividualIntent      `json:"individual_intents"`
+	OperationsCount   int                     `json:"operations_count"`
+}
+
+type IndividualIntent struct {
+	BasicIntent string                 `json:"basic_intent"`
+	OperationID operations.OperationID `json:"operation_id"`
+}
+
+type CreatedAPIKey struct {
+	APIKey string `json:"api_key"`
+}
+
+type AuthStatus struct {
+	AuthRequired bool `json:"auth_required"`
+	// SignaturesRequired refuses operations not signed by their author
+	SignaturesRequired bool                `json:"signatures_required"`
+	AuthorID           operations.AuthorID `json:"author_id"`
+	Authenticated      bool                `json:"authenticated"`
+	Permissions        []auth.Permission   `json:"permissions"`
+}
+
+type SearchResults struct {
+	Query       string               `json:"query"`
+	Type        string               `json:"type"`
+	Author      string               `json:"author,omitempty"`
+	ContentType string               `json:"content_type,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Labels      []string             `json:"labels,omitempty"`
+	Status      context.ThreadStatus `json:"status,omitempty"`
+	MessageType context.MessageType  `json:"message_type,omitempty"`
+	DocumentID  string               `json:"document_id,omitempty"`
+	Branch      string               `json:"branch,omitempty"`
+	Tool        string               `json:"tool,omitempty"`
+	Ticket      string               `json:"ticket,omitempty"`
+	Language    string               `json:"language,omitempty"`
+	Results     []SearchResult       `json:"results"`
+	Total       int                  `json:"total"`
+	Limit       int                  `json:"limit"`
+}
+
+type SearchResult struct {
+	Type    string  `json:"type"` // "conversation", "operation", "code"
+	ID      string  `json:"id"`
+	Title   string  `json:"title,omitempty"`
+	Content string  `json:"content"`
+	Author  string  `json:"author,omitempty"`
+	Score   float64 `json:"score"`
+	// Snippet is the best of Snippets with its matches marked **like this**
+	Snippet   string                  `json:"snippet"`
+	Snippets  []storage.SearchSnippet `json:"snippets"`
+	Timestamp *time.Time              `json:"timestamp,omitempty"`
+	Address   interface{}             `json:"address,omitempty"`
+	Metadata  interface{}             `json:"metadata,omitempty"`
+}
+
+// SimilarResults are the operations or messages closest in meaning to a
+// source, most similar first
+type SimilarResults struct {
+	SourceKind string          `json:"source_kind"`
+	SourceID   string          `json:"source_id"`
+	Model      string          `json:"model"`
+	Results    []SimilarResult `json:"results"`
+}
+
+type SimilarResult struct {
+	Kind       string     `json:"kind"` // "operation", "message"
+	ID         string     `json:"id"`
+	ThreadID   string     `json:"thread_id,omitempty"`
+	Similarity float64    `json:"similarity"`
+	Author     string     `json:"author,omitempty"`
+	Content    string     `json:"content"`
+	Timestamp  *time.Time `json:"timestamp,omitempty"`
+}
+
+// ChangeSetDetails is a change set with the conversations and reviews
+// anchored to it
+type ChangeSetDetails struct {
+	ChangeSet     *context.ChangeSet            `json:"changeset"`
+	Conversations []*context.ConversationThread `json:"conversations"`
+	Reviews       []*context.Review             `json:"reviews"`
+}
+
+// HealthStatus is healthy, degraded when a check warns or unhealthy when
+// one fails
+type HealthStatus struct {
+	Status    string        `json:"status"`
+	Timestamp time.Time     `json:"timestamp"`
+	Version   string        `json:"version"`
+	Checks    []HealthCheck `json:"checks"`
+}
+
+type Permalink struct {
+	OperationID  string                `json:"operation_id"`
+	Operation    *operations.Operation `json:"operation"`
+	DocumentPath string                `json:"document_path"`
+	LineNumber   string                `json:"line_number,omitempty"`
+	Column       string                `json:"column,omitempty"`
+	Context      map[string]string     `json:"context,omitempty"`
+	CreatedAt    string                `json:"created_at"`
+	Author       string                `json:"author"`
+	Permalink    bool                  `json:"is_permalink"`
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	gocontext "context"
+	"fmt"
+	"sort"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// DefaultMaxContentSize caps operation content at 1 MiB unless overridden with WithMaxContentSize
+const DefaultMaxContentSize = 1 << 20
+
+// DefaultMaxRequestSize caps operation requests at 16 MiB unless overridden
+// with WithMaxRequestSize
+const DefaultMaxRequestSize = 16 << 20
+
+// Structural limits on operation payloads. They are generous for anything an
+// editor or agent produces and exist to reject malformed or abusive requests
+// before they reach the engine.
+const (
+	maxPositionDepth       = 64
+	maxParents             = 64
+	maxSessionIDLength     = 256
+	maxIntentLength        = 1024
+	maxMetadataEntries     = 64
+	maxMetadataKeyLength   = 128
+	maxMetadataValueLength = 4096
+)
+
+// WithMaxContentSize limits the size in bytes of an operation's content
+func WithMaxContentSize(size int) ServerOption {
+	return func(s *APIServer) {
+		if size > 0 {
+			s.maxContentSize = size
+		}
+	}
+}
+
+// WithMaxRequestSize limits the size in bytes of the body of a request to
+// create an operation. Text inserts with more content than one operation
+// holds are split into several, so this bounds them instead.
+func WithMaxRequestSize(size int) ServerOption {
+	return func(s *APIServer) {
+		if size > 0 {
+			s.maxRequestSize = size
+		}
+	}
+}
+
+// splittable reports whether op may be split into chunks when its content
+// is over the limit. Only text inserts split without changing what their
+// content means, and only those the server identifies, as an ID or
+// signature the client made covers all of it.
+func splittable(op *operations.Operation, clientID bool) bool {
+	return op.Type == operations.OpInsert && !clientID && op.Metadata.Signature == "" &&
+		operations.NormalizeContentType(op.ContentType) == operations.ContentTypeText
+}
+
+// validateOperation checks an operation built from a request before it is
+// handed to the engine, so problems are reported per field instead of as a
+// failure deep inside it. Content over the limit is allowed when split says
+// it will be split. The error is only set when the check itself fails.
+func (s *APIServer) validateOperation(ctx gocontext.Context, op *operations.Operation, split bool) ([]FieldError, error) {
+	var fields []FieldError
+	invalid := func(field, format string, args ...interface{}) {
+		fields = append(fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch op.Type {
+	case "":
+		invalid("type", "is required")
+	case operations.OpInsert, operations.OpDelete, operations.OpPatch:
+	default:
+		invalid("type", "must be one of insert, delete or patch")
+	}
+
+	if op.Author == "" {
+		invalid("author", "is required")
+	}
+
+	if op.Metadata.DocumentID == "" {
+		invalid("document_id", "is required")
+	}
+
+	validatePosition(op.Position, invalid)
+
+	if len(op.Content) > s.maxContentSize && !split {
+		invalid("content", "exceeds the %d byte limit", s.maxContentSize)
+	} else if err := operations.ValidateContent(op); err != nil {
+		invalid("content", "%v", err)
+	}
+
+	if op.Length < 0 {
+		invalid("length", "must not be negative")
+	}
+
+	validateMetadata(op.Metadata, invalid)
+	if err := s.engine.ValidateIntent(op.Metadata.Intent); err != nil {
+		invalid("metadata.intent", "%v", err)
+	}
+
+	if err := operations.ValidateID(op); err != nil {
+		invalid("id", "%v", err)
+	}
+
+	// Parents are only looked up once the rest of the request is sound
+	if len(fields) > 0 {
+		return fields, nil
+	}
+	return s.validateParents(ctx, op.Parents)
+}
+
+func validatePosition(pos operations.LogootPosition, invalid func(field, format string, args ...interface{})) {
+	if len(pos.Segments) == 0 {
+		invalid("position.segments", "at least one segment is required")
+		return
+	}
+	if len(pos.Segments) > maxPositionDepth {
+		invalid("position.segments", "must have at most %d segments", maxPositionDepth)
+		return
+	}
+
+	for i, segment := range pos.Segments {
+		field := fmt.Sprintf("position.segments[%d]", i)
+		if segment.Value == nil {
+			invalid(field+".value", "is required")
+		}
+		if segment.AuthorID == "" {
+			invalid(field+".author", "is required")
+		}
+	}
+}
+
+func validateMetadata(meta operations.OperationMeta, invalid func(field, format string, args ...interface{})) {
+	if len(meta.SessionID) > maxSessionIDLength {
+		invalid("metadata.session_id", "must be at most %d bytes", maxSessionIDLength)
+	}
+	if len(meta.Intent) > maxIntentLength {
+		invalid("metadata.intent", "must be at most %d bytes", maxIntentLength)
+	}
+	if meta.Chunk != nil {
+		invalid("metadata.chunk", "is set by the server")
+	}
+	for field, value := range map[string]string{
+		"metadata.branch":   meta.Branch,
+		"metadata.tool":     meta.Tool,
+		"metadata.ticket":   meta.Ticket,
+		"metadata.language": meta.Language,
+	} {
+		if len(value) > maxMetadataValueLength {
+			invalid(field, "must be at most %d bytes", maxMetadataValueLength)
+		}
+	}
+
+	if len(meta.Context) > maxMetadataEntries {
+		invalid("metadata.context", "must have at most %d entries", maxMetadataEntries)
+		return
+	}
+	keys := make([]string, 0, len(meta.Context))
+	for key := range meta.Context {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := meta.Context[key]
+		switch {
+		case key == "":
+			invalid("metadata.context", "keys must not be empty")
+		case len(key) > maxMetadataKeyLength:
+			invalid("metadata.context", "keys must be at most %d bytes", maxMetadataKeyLength)
+		case len(value) > maxMetadataValueLength:
+			invalid("metadata.context."+key, "must be at most %d bytes", maxMetadataValueLength)
+		}
+	}
+}
+
+func (s *APIServer) validateParents(ctx gocontext.Context, parents []operations.OperationID) ([]FieldError, error) {
+	if len(parents) == 0 {
+		return nil, nil
+	}
+	if len(parents) > maxParents {
+		return []FieldError{{Field: "parents", Message: fmt.Sprintf("must have at most %d entries", maxParents)}}, nil
+	}
+
+	found, err := s.store.GetOperations(ctx, parents)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[operations.OperationID]bool, len(found))
+	for _, op := range found {
+		exists[op.ID] = true
+	}
+
+	var fields []FieldError
+	seen := make(map[operations.OperationID]bool, len(parents))
+	for i, parent := range parents {
+		field := fmt.Sprintf("parents[%d]", i)
+		switch {
+		case seen[parent]:
+			fields = append(fields, FieldError{Field: field, Message: "is listed more than once"})
+		case !exists[parent]:
+			fields = append(fields, FieldError{Field: field, Message: "operation not found"})
+		}
+		seen[parent] = true
+	}
+	return fields, nil
+}
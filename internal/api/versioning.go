@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type APIVersion int
+
+const (
+	APIv1 APIVersion = 1
+	APIv2 APIVersion = 2
+)
+
+// APIVersionHeader lets /api/v1 clients opt into v2 response shapes while migrating
+const APIVersionHeader = "X-API-Version"
+
+// /api/v1 is deprecated as of the v2 release and will be removed at sunset.
+// Both dates are in UTC and advertised on every v1 response.
+const (
+	V1DeprecationDate = "2025-10-01"
+	V1SunsetDate      = "2026-12-31"
+)
+
+var (
+	v1DeprecatedAt = mustParseDate(V1DeprecationDate)
+	v1SunsetAt     = mustParseDate(V1SunsetDate)
+)
+
+type versionContextKey struct{}
+
+// route registers a handler under both /api/v1 and /api/v2. The pattern is
+// written against /api/v1, matching how the routes have always been declared.
+func (s *APIServer) route(pattern string, handler http.HandlerFunc) {
+	s.routes = append(s.routes, pattern)
+	if s.engine != nil && s.engine.ReadOnly() && !readsOnly(pattern) {
+		handler = s.rejectReadOnly
+	}
+	s.mux.HandleFunc(pattern, deprecatedV1(withAPIVersion(APIv1, handler)))
+	s.mux.HandleFunc(strings.Replace(pattern, "/api/v1/", "/api/v2/", 1), withAPIVersion(APIv2, handler))
+}
+
+func withAPIVersion(version APIVersion, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if version == APIv1 && r.Header.Get(APIVersionHeader) == "2" {
+			version = APIv2
+		}
+		w.Header().Set(APIVersionHeader, versionString(version))
+
+		ctx := context.WithValue(r.Context(), versionContextKey{}, version)
+		handler(w, r.WithContext(ctx))
+	}
+}
+
+// deprecatedV1 advertises the v1 deprecation (RFC 9745) and sunset (RFC 8594)
+// along with a link to the equivalent v2 resource.
+func deprecatedV1(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		successor := strings.Replace(r.URL.Path, "/api/v1/", "/api/v2/", 1)
+		if r.URL.RawQuery != "" {
+			successor += "?" + r.URL.RawQuery
+		}
+
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(v1DeprecatedAt.Unix(), 10))
+		w.Header().Set("Sunset", v1SunsetAt.Format(http.TimeFormat))
+		w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+		handler(w, r)
+	}
+}
+
+func requestAPIVersion(r *http.Request) APIVersion {
+	if version, ok := r.Context().Value(versionContextKey{}).(APIVersion); ok {
+		return version
+	}
+	// Requests rejected before routing (e.g. by auth) only have the path to go on
+	if strings.HasPrefix(r.URL.Path, "/api/v2/") || r.Header.Get(APIVersionHeader) == "2" {
+		return APIv2
+	}
+	return APIv1
+}
+
+func mustParseDate(date string) time.Time {
+	t, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func versionString(version APIVersion) string {
+	if version == APIv2 {
+		return "2"
+	}
+	return "1"
+}
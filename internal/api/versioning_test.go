@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeprecationHeaders(t *testing.T) {
+	s := &APIServer{mux: http.NewServeMux()}
+	s.route("GET /api/v1/things", func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, r, SuccessResponse{Data: []string{}}, http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/things?limit=5", nil))
+	expected := map[string]string{
+		"Deprecation": "@1759276800",
+		"Sunset":      "Thu, 31 Dec 2026 00:00:00 GMT",
+		"Link":        `</api/v2/things?limit=5>; rel="successor-version"`,
+	}
+	for name, value := range expected {
+		if got := recorder.Header().Get(name); got != value {
+			t.Errorf("Expected %s %q, got %q", name, value, got)
+		}
+	}
+
+	recorder = httptest.NewRecorder()
+	s.mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v2/things", nil))
+	for name := range expected {
+		if got := recorder.Header().Get(name); got != "" {
+			t.Errorf("Expected no %s on v2, got %q", name, got)
+		}
+	}
+}
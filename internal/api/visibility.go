@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+type SetVisibilityRequest struct {
+	Visibility context.Visibility `json:"visibility"`
+	// Participants may read a participants-only thread before writing in it
+	Participants []operations.AuthorID `json:"participants,omitempty"`
+}
+
+// conversationViewer is who a request reads conversations for. Admin keys,
+// and every request while authentication is off, read every conversation;
+// other keys read public ones and those their author may see.
+func conversationViewer(r *http.Request) context.Viewer {
+	authContext := auth.GetAuthContext(r.Context())
+	if authContext == nil {
+		return context.ViewerFor("")
+	}
+	if authContext.HasPermission(auth.PermissionAdmin) {
+		return context.Viewer{}
+	}
+	return context.ViewerFor(authContext.AuthorID)
+}
+
+// viewConversation loads a thread the request may read. Threads it may not
+// read are reported as not found, the same as threads that don't exist.
+func (s *APIServer) viewConversation(w http.ResponseWriter, r *http.Request, threadID context.ThreadID, opts ...context.MessageOption) (*context.ConversationThread, bool) {
+	thread, err := s.contextManager.ViewConversation(threadID, conversationViewer(r), opts...)
+	if errors.Is(err, context.ErrMessageNotFound) {
+		s.writeError(w, r, validationError("Invalid query parameter", FieldError{Field: "before", Message: "must be a message in the conversation"}))
+		return nil, false
+	}
+	if err != nil {
+		s.lookupError(w, r, "Conversation", err)
+		return nil, false
+	}
+	return thread, true
+}
+
+func (s *APIServer) canViewConversation(r *http.Request, threadID context.ThreadID) bool {
+	_, err := s.contextManager.ViewConversation(threadID, conversationViewer(r))
+	return err == nil
+}
+
+func (s *APIServer) setConversationVisibility(w http.ResponseWriter, r *http.Request) {
+	var req SetVisibilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if !req.Visibility.IsValid() {
+		s.writeError(w, r, validationError("Invalid visibility", FieldError{Field: "visibility", Message: "must be public, participants or private"}))
+		return
+	}
+
+	threadID := context.ThreadID(r.PathValue("id"))
+	if _, ok := s.viewConversation(w, r, threadID); !ok {
+		return
+	}
+
+	thread, err := s.contextManager.SetVisibility(threadID, req.Visibility, req.Participants...)
+	if err != nil {
+		s.lookupError(w, r, "Conversation", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: thread}, http.StatusOK)
+}
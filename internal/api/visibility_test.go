@@ -0,0 +1,123 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestConversationVisibility(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	authManager, err := auth.NewAuthManager(dir)
+	if err != nil {
+		t.Fatalf("Failed to create auth manager: %v", err)
+	}
+	if err := authManager.EnableAuth(); err != nil {
+		t.Fatalf("Failed to enable auth: %v", err)
+	}
+	keys := make(map[string]string)
+	for author, permissions := range map[string][]auth.Permission{
+		"alice": {auth.PermissionReadDocuments, auth.PermissionWriteDocuments},
+		"bob":   {auth.PermissionReadDocuments, auth.PermissionWriteDocuments},
+		"carol": {auth.PermissionReadDocuments},
+		"admin": {auth.PermissionAdmin},
+	} {
+		if keys[author], err = authManager.CreateAPIKey(author, operations.AuthorID(author), permissions, nil); err != nil {
+			t.Fatalf("Failed to create API key: %v", err)
+		}
+	}
+
+	engine := collaboration.NewCollaborationEngine(store)
+	s := NewAPIServer(engine, store, store, engine.AddressResolver(), engine.ConversationManager(), engine.ContextAnalyzer(), authManager)
+
+	do := func(author, method, path string, body interface{}) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&payload).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &payload)
+		req.Header.Set("Authorization", "Bearer "+keys[author])
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, req)
+		return recorder
+	}
+	decode := func(recorder *httptest.ResponseRecorder, v interface{}) error {
+		var resp struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			return err
+		}
+		return json.Unmarshal(resp.Data, v)
+	}
+	create := func(visibility context.Visibility, participants ...string) context.ThreadID {
+		req := map[string]interface{}{"author_id": "alice", "title": "Salary bands", "content": "Sensitive", "visibility": visibility, "participants": participants}
+		recorder := do("alice", http.MethodPost, "/api/v2/conversations", req)
+		var thread context.ConversationThread
+		if recorder.Code != http.StatusCreated || decode(recorder, &thread) != nil {
+			t.Fatalf("Failed to create conversation: %d %s", recorder.Code, recorder.Body)
+		}
+		return thread.ID
+	}
+
+	public := create(context.VisibilityPublic)
+	shared := create(context.VisibilityParticipants, "bob")
+	private := create(context.VisibilityPrivate)
+
+	cases := []struct {
+		author string
+		thread context.ThreadID
+		code   int
+	}{
+		{"carol", public, http.StatusOK},
+		{"bob", shared, http.StatusOK},
+		{"carol", shared, http.StatusNotFound},
+		{"alice", private, http.StatusOK},
+		{"bob", private, http.StatusNotFound},
+		{"admin", private, http.StatusOK},
+	}
+	for _, c := range cases {
+		if recorder := do(c.author, http.MethodGet, "/api/v2/conversations/"+string(c.thread), nil); recorder.Code != c.code {
+			t.Errorf("Expected %d for %s reading %s, got %d", c.code, c.author, c.thread, recorder.Code)
+		}
+	}
+
+	var threads []context.ConversationThread
+	if err := decode(do("carol", http.MethodGet, "/api/v2/conversations", nil), &threads); err != nil {
+		t.Fatalf("Failed to decode conversations: %v", err)
+	}
+	if len(threads) != 1 || threads[0].ID != public {
+		t.Errorf("Expected carol to list only the public conversation, got %+v", threads)
+	}
+
+	// Threads that can't be read can't be written either
+	message := map[string]interface{}{"author_id": "bob", "content": "Me too", "message_type": "comment"}
+	if recorder := do("bob", http.MethodPost, "/api/v2/conversations/"+string(private)+"/messages", message); recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected bob to be refused on a private thread, got %d", recorder.Code)
+	}
+
+	// Opening a thread up makes it readable
+	opened := do("alice", http.MethodPost, "/api/v2/conversations/"+string(private)+"/visibility", SetVisibilityRequest{Visibility: context.VisibilityPublic})
+	if opened.Code != http.StatusOK {
+		t.Fatalf("Failed to change visibility: %d %s", opened.Code, opened.Body)
+	}
+	if recorder := do("bob", http.MethodGet, "/api/v2/conversations/"+string(private), nil); recorder.Code != http.StatusOK {
+		t.Errorf("Expected bob to read the opened thread, got %d", recorder.Code)
+	}
+	if recorder := do("alice", http.MethodPost, "/api/v2/conversations/"+string(public)+"/visibility", SetVisibilityRequest{Visibility: "secret"}); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected an unknown visibility to be refused, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/jeremytregunna/contextdb/internal/webhooks"
+)
+
+// WithWebhooks enables the /admin/webhooks endpoints. The caller is
+// responsible for subscribing the manager to the engine's events.
+func WithWebhooks(manager *webhooks.Manager) ServerOption {
+	return func(s *APIServer) {
+		s.webhooks = manager
+	}
+}
+
+// requireWebhooks wraps a handler that needs webhooks to be configured
+func (s *APIServer) requireWebhooks(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.webhooks == nil {
+			s.jsonError(w, r, "Webhooks are not enabled on this server", http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *APIServer) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req CreateWebhookRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := s.webhooks.Create(req.URL, req.Secret, req.Events)
+	switch {
+	case errors.Is(err, webhooks.ErrInvalidURL):
+		s.writeError(w, r, validationError("Invalid webhook", FieldError{Field: "url", Message: "must be an absolute http or https url"}))
+		return
+	case errors.Is(err, webhooks.ErrUnknownEvent):
+		s.writeError(w, r, validationError("Invalid webhook", FieldError{Field: "events", Message: err.Error()}))
+		return
+	case err != nil:
+		s.internalError(w, r, "Failed to create webhook", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data:    webhook,
+		Message: "Webhook created. Store the secret securely - it won't be shown again.",
+	}, http.StatusCreated)
+}
+
+func (s *APIServer) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks := s.webhooks.List()
+	s.respond(w, r, SuccessResponse{
+		Data: hooks,
+		Meta: &ResponseMeta{Total: len(hooks)},
+	}, http.StatusOK)
+}
+
+func (s *APIServer) getWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := s.webhooks.Get(r.PathValue("id"))
+	if err != nil {
+		s.lookupError(w, r, "Webhook", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: webhook}, http.StatusOK)
+}
+
+func (s *APIServer) updateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req UpdateWebhookRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.jsonError(w, r, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if req.Active == nil {
+		s.writeError(w, r, validationError("Invalid request", FieldError{Field: "active", Message: "is required"}))
+		return
+	}
+
+	webhook, err := s.webhooks.SetActive(r.PathValue("id"), *req.Active)
+	if err != nil {
+		s.lookupError(w, r, "Webhook", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{Data: webhook, Message: "Webhook updated"}, http.StatusOK)
+}
+
+func (s *APIServer) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := s.webhooks.Delete(r.PathValue("id")); err != nil {
+		s.lookupError(w, r, "Webhook", err)
+		return
+	}
+
+	s.respondMessage(w, r, "Webhook deleted", http.StatusOK)
+}
+
+func (s *APIServer) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			s.writeError(w, r, validationError("Invalid query", FieldError{Field: "limit", Message: "must be a positive integer"}))
+			return
+		}
+		limit = parsed
+	}
+
+	deliveries, err := s.webhooks.Deliveries(r.PathValue("id"), limit)
+	if err != nil {
+		s.lookupError(w, r, "Webhook", err)
+		return
+	}
+
+	s.respond(w, r, SuccessResponse{
+		Data: deliveries,
+		Meta: &ResponseMeta{Total: len(deliveries), Limit: limit},
+	}, http.StatusOK)
+}
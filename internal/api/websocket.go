@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// connectWebSocket hands the connection to the collaboration engine, which
+// attributes the client to the author of its API key and resumes the session
+// named by resume_token when there is one
+func (s *APIServer) connectWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		s.jsonError(w, r, "WebSocket upgrade required", http.StatusBadRequest)
+		return
+	}
+
+	var authorID operations.AuthorID
+	if authContext := auth.GetAuthContext(r.Context()); authContext != nil {
+		authorID = authContext.AuthorID
+	}
+
+	if _, err := s.engine.Connect(w, r, authorID, r.URL.Query().Get("resume_token")); err != nil {
+		// The upgrade has either answered the request or taken the connection
+		s.logger.Warn("WebSocket connection failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reasons an authentication attempt fails
+const (
+	FailureMissingKey = "missing_key"
+	FailureInvalidKey = "invalid_key"
+	FailureExpiredKey = "expired_key"
+)
+
+// keySourcePrefix marks the key prefixes among the sources, which are counted
+// but never locked out
+const keySourcePrefix = "key:"
+
+// maxTrackedSources bounds the sources remembered between lockouts. Past it,
+// sources with no recent failures are forgotten.
+const maxTrackedSources = 10000
+
+// LockoutPolicy locks a source out once it fails MaxFailures times within
+// Window, refusing it for Duration. A zero MaxFailures never locks out.
+type LockoutPolicy struct {
+	MaxFailures int
+	Window      time.Duration
+	Duration    time.Duration
+}
+
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		MaxFailures: 10,
+		Window:      5 * time.Minute,
+		Duration:    15 * time.Minute,
+	}
+}
+
+// AttemptTracker counts failed authentication attempts by source, the client
+// address they came from, and locks out sources that fail too often so keys
+// can't be guessed. It also counts the failures with each key prefix, which
+// show a key being guessed at but never lock it out: anyone could do that to
+// a key they don't hold.
+type AttemptTracker struct {
+	policy  LockoutPolicy
+	sources map[string]*sourceAttempts
+	stats   AttemptStats
+	mutex   sync.Mutex
+}
+
+type sourceAttempts struct {
+	failures    []time.Time
+	lockedUntil time.Time
+}
+
+// AttemptStats counts authentication attempts since startup and lists the
+// sources locked out now
+type AttemptStats struct {
+	Successes        uint64            `json:"successes"`
+	Failures         uint64            `json:"failures"`
+	FailuresByReason map[string]uint64 `json:"failures_by_reason"`
+	// Lockouts counts sources locked out, and Refused the requests turned
+	// away while their source was
+	Lockouts uint64         `json:"lockouts"`
+	Refused  uint64         `json:"refused"`
+	Locked   []LockedSource `json:"locked"`
+	// KeyFailures lists the key prefixes tried and failed within the window
+	KeyFailures []KeyFailures `json:"key_failures"`
+}
+
+// KeyFailures is how many times keys starting with KeyPrefix failed recently
+type KeyFailures struct {
+	KeyPrefix string `json:"key_prefix"`
+	Failures  int    `json:"failures"`
+}
+
+// LockedSource is a source refused until Until
+type LockedSource struct {
+	Source string    `json:"source"`
+	Until  time.Time `json:"until"`
+}
+
+func NewAttemptTracker(policy LockoutPolicy) *AttemptTracker {
+	return &AttemptTracker{
+		policy:  policy,
+		sources: make(map[string]*sourceAttempts),
+		stats:   AttemptStats{FailuresByReason: make(map[string]uint64)},
+	}
+}
+
+func (t *AttemptTracker) SetPolicy(policy LockoutPolicy) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.policy = policy
+}
+
+// Locked reports whether any of sources is locked out, and for how much
+// longer. A request from a locked source counts as refused.
+func (t *AttemptTracker) Locked(sources ...string) (time.Duration, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, source := range sources {
+		if attempts, ok := t.sources[source]; ok && now.Before(attempts.lockedUntil) {
+			retryAfter = max(retryAfter, attempts.lockedUntil.Sub(now))
+		}
+	}
+	if retryAfter == 0 {
+		return 0, false
+	}
+	t.stats.Refused++
+	return retryAfter, true
+}
+
+// Fail records a failed attempt from sources and returns those it locked out
+func (t *AttemptTracker) Fail(reason string, sources ...string) []LockedSource {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.stats.Failures++
+	t.stats.FailuresByReason[reason]++
+	if t.policy.MaxFailures <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if len(t.sources) >= maxTrackedSources {
+		t.forgetStale(now)
+	}
+
+	var locked []LockedSource
+	for _, source := range sources {
+		attempts, ok := t.sources[source]
+		if !ok {
+			attempts = &sourceAttempts{}
+			t.sources[source] = attempts
+		}
+		attempts.failures = append(recentFailures(attempts.failures, now.Add(-t.policy.Window)), now)
+		if len(attempts.failures) >= t.policy.MaxFailures {
+			attempts.failures = nil
+			attempts.lockedUntil = now.Add(t.policy.Duration)
+			t.stats.Lockouts++
+			locked = append(locked, LockedSource{Source: source, Until: attempts.lockedUntil})
+		}
+	}
+	return locked
+}
+
+// FailKey counts a failed attempt with a key starting with keyPrefix and
+// returns how many there were within the window. It never locks anything out.
+func (t *AttemptTracker) FailKey(keyPrefix string) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.policy.MaxFailures <= 0 {
+		return 0
+	}
+	now := time.Now()
+	if len(t.sources) >= maxTrackedSources {
+		t.forgetStale(now)
+	}
+
+	source := keySourcePrefix + keyPrefix
+	attempts, ok := t.sources[source]
+	if !ok {
+		attempts = &sourceAttempts{}
+		t.sources[source] = attempts
+	}
+	attempts.failures = append(recentFailures(attempts.failures, now.Add(-t.policy.Window)), now)
+	return len(attempts.failures)
+}
+
+// Succeed records a successful attempt. It doesn't forgive earlier failures,
+// or a client holding one valid key could guess others between using it.
+func (t *AttemptTracker) Succeed() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.stats.Successes++
+}
+
+// Unlock lifts the lockout of source, forgetting its failures
+func (t *AttemptTracker) Unlock(source string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	attempts, ok := t.sources[source]
+	if !ok || !time.Now().Before(attempts.lockedUntil) {
+		return ErrLockoutNotFound
+	}
+	delete(t.sources, source)
+	return nil
+}
+
+func (t *AttemptTracker) Stats() AttemptStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stats := t.stats
+	stats.FailuresByReason = make(map[string]uint64, len(t.stats.FailuresByReason))
+	for reason, count := range t.stats.FailuresByReason {
+		stats.FailuresByReason[reason] = count
+	}
+
+	now := time.Now()
+	since := now.Add(-t.policy.Window)
+	stats.Locked = []LockedSource{}
+	stats.KeyFailures = []KeyFailures{}
+	for source, attempts := range t.sources {
+		if now.Before(attempts.lockedUntil) {
+			stats.Locked = append(stats.Locked, LockedSource{Source: source, Until: attempts.lockedUntil})
+		}
+		if keyPrefix, ok := strings.CutPrefix(source, keySourcePrefix); ok {
+			if failures := len(recentFailures(attempts.failures, since)); failures > 0 {
+				stats.KeyFailures = append(stats.KeyFailures, KeyFailures{KeyPrefix: keyPrefix, Failures: failures})
+			}
+		}
+	}
+	sort.Slice(stats.Locked, func(i, j int) bool { return stats.Locked[i].Source < stats.Locked[j].Source })
+	sort.Slice(stats.KeyFailures, func(i, j int) bool { return stats.KeyFailures[i].KeyPrefix < stats.KeyFailures[j].KeyPrefix })
+	return stats
+}
+
+// forgetStale drops the sources neither locked out nor failing recently
+func (t *AttemptTracker) forgetStale(now time.Time) {
+	since := now.Add(-t.policy.Window)
+	for source, attempts := range t.sources {
+		if !now.Before(attempts.lockedUntil) && len(recentFailures(attempts.failures, since)) == 0 {
+			delete(t.sources, source)
+		}
+	}
+}
+
+// recentFailures keeps the failures after since, which are in time order
+func recentFailures(failures []time.Time, since time.Time) []time.Time {
+	i := sort.Search(len(failures), func(i int) bool { return failures[i].After(since) })
+	return failures[i:]
+}
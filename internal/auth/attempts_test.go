@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAttemptTracker_Lockout(t *testing.T) {
+	tracker := NewAttemptTracker(LockoutPolicy{MaxFailures: 3, Window: time.Minute, Duration: time.Hour})
+
+	for i := 0; i < 2; i++ {
+		if locked := tracker.Fail(FailureInvalidKey, "ip:192.0.2.1"); len(locked) != 0 {
+			t.Fatalf("Expected no lockout before the third failure, got %+v", locked)
+		}
+	}
+	if _, locked := tracker.Locked("ip:192.0.2.1"); locked {
+		t.Fatal("Expected the address not locked out yet")
+	}
+	// Succeeding doesn't forgive the failures so far
+	tracker.Succeed()
+	locked := tracker.Fail(FailureExpiredKey, "ip:192.0.2.1")
+	if len(locked) != 1 || locked[0].Source != "ip:192.0.2.1" {
+		t.Fatalf("Expected the address locked out on the third failure, got %+v", locked)
+	}
+
+	retryAfter, isLocked := tracker.Locked("ip:198.51.100.7", "ip:192.0.2.1")
+	if !isLocked || retryAfter <= 59*time.Minute || retryAfter > time.Hour {
+		t.Errorf("Expected about an hour left on the lockout, got %v, %v", retryAfter, isLocked)
+	}
+	if _, isLocked := tracker.Locked("ip:198.51.100.7"); isLocked {
+		t.Error("Expected other addresses unaffected")
+	}
+
+	stats := tracker.Stats()
+	if stats.Successes != 1 || stats.Failures != 3 || stats.FailuresByReason[FailureExpiredKey] != 1 ||
+		stats.Lockouts != 1 || stats.Refused != 1 || len(stats.Locked) != 1 {
+		t.Errorf("Expected 3 failures, 1 lockout and 1 refusal, got %+v", stats)
+	}
+}
+
+func TestAttemptTracker_Window(t *testing.T) {
+	tracker := NewAttemptTracker(LockoutPolicy{MaxFailures: 2, Window: 20 * time.Millisecond, Duration: time.Hour})
+
+	tracker.Fail(FailureInvalidKey, "ip:192.0.2.1")
+	time.Sleep(30 * time.Millisecond)
+	if locked := tracker.Fail(FailureInvalidKey, "ip:192.0.2.1"); len(locked) != 0 {
+		t.Errorf("Expected failures outside the window to age out, got %+v", locked)
+	}
+
+	tracker.SetPolicy(LockoutPolicy{})
+	for i := 0; i < 10; i++ {
+		tracker.Fail(FailureInvalidKey, "ip:198.51.100.7")
+	}
+	if _, locked := tracker.Locked("ip:198.51.100.7"); locked {
+		t.Error("Expected a zero MaxFailures never to lock out")
+	}
+}
+
+func TestAttemptTracker_Unlock(t *testing.T) {
+	tracker := NewAttemptTracker(LockoutPolicy{MaxFailures: 1, Window: time.Minute, Duration: time.Hour})
+
+	if err := tracker.Unlock("ip:192.0.2.1"); !errors.Is(err, ErrLockoutNotFound) {
+		t.Errorf("Expected ErrLockoutNotFound for an address never locked out, got %v", err)
+	}
+	tracker.Fail(FailureInvalidKey, "ip:192.0.2.1")
+	if err := tracker.Unlock("ip:192.0.2.1"); err != nil {
+		t.Fatalf("Failed to unlock: %v", err)
+	}
+	if _, locked := tracker.Locked("ip:192.0.2.1"); locked {
+		t.Error("Expected the address let back in")
+	}
+	if err := tracker.Unlock("ip:192.0.2.1"); !errors.Is(err, ErrLockoutNotFound) {
+		t.Errorf("Expected ErrLockoutNotFound once unlocked, got %v", err)
+	}
+	if stats := tracker.Stats(); len(stats.Locked) != 0 {
+		t.Errorf("Expected nothing locked out, got %+v", stats.Locked)
+	}
+}
+
+func TestAttemptTracker_FailKey(t *testing.T) {
+	tracker := NewAttemptTracker(LockoutPolicy{MaxFailures: 2, Window: time.Minute, Duration: time.Hour})
+
+	for i := 1; i <= 5; i++ {
+		if failures := tracker.FailKey("abcd1234"); failures != i {
+			t.Errorf("Expected %d failures counted, got %d", i, failures)
+		}
+	}
+	if _, locked := tracker.Locked("key:abcd1234"); locked {
+		t.Error("Expected a key prefix never locked out")
+	}
+	stats := tracker.Stats()
+	if stats.Lockouts != 0 || len(stats.Locked) != 0 || len(stats.KeyFailures) != 1 || stats.KeyFailures[0] != (KeyFailures{KeyPrefix: "abcd1234", Failures: 5}) {
+		t.Errorf("Expected 5 failures with the prefix and no lockout, got %+v", stats)
+	}
+}
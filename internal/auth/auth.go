@@ -1,30 +1,61 @@
 package auth
 
 import (
+	gocontext "context"
 	"crypto/rand"
-	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/jeremytregunna/contextdb/internal/filelock"
 	"github.com/jeremytregunna/contextdb/internal/operations"
 	"golang.org/x/crypto/sha3"
 )
 
+// AuthManager keeps auth.json, which other processes sharing the .context
+// directory may update too. Updates hold the file's lock and reload it first
+// if it changed; reads reload it when it has.
 type AuthManager struct {
 	configPath string
-	config     *AuthConfig
+	// config is replaced whole by each update and never modified, so callers
+	// may read one without holding mutex. configVersion is what it was read
+	// from or saved as.
+	config        *AuthConfig
+	configVersion filelock.Version
+	usage         *UsageTracker
+	attempts      *AttemptTracker
+	// keyIndex finds the key with a secret hash, including secrets still
+	// retiring after a rotation
+	keyIndex map[string]int
+	mutex    sync.RWMutex
+	// lastUsed is when keys were last used by ID, kept here rather than
+	// saved on every request until Flush finds it LastUsedPrecision later
+	// than the time saved
+	lastUsed      map[string]time.Time
+	lastUsedMutex sync.Mutex
 }
 
+// DefaultFlushInterval is how often a running AuthManager saves what it keeps
+// in memory
+const DefaultFlushInterval = 30 * time.Second
+
+// LastUsedPrecision is how far a key's last use moves on before it is saved
+const LastUsedPrecision = time.Minute
+
 type AuthConfig struct {
 	APIKeys       []APIKey            `json:"api_keys"`
 	DefaultAuthor operations.AuthorID `json:"default_author"`
 	RequireAuth   bool                `json:"require_auth"`
-	CreatedAt     time.Time           `json:"created_at"`
-	LastModified  time.Time           `json:"last_modified"`
+	// RequireSignatures refuses operations not signed by their author
+	RequireSignatures bool         `json:"require_signatures,omitempty"`
+	SigningKeys       []SigningKey `json:"signing_keys,omitempty"`
+	CreatedAt         time.Time    `json:"created_at"`
+	LastModified      time.Time    `json:"last_modified"`
 }
 
 type APIKey struct {
@@ -36,6 +67,10 @@ type APIKey struct {
 	CreatedAt   time.Time           `json:"created_at"`
 	LastUsed    *time.Time          `json:"last_used,omitempty"`
 	ExpiresAt   *time.Time          `json:"expires_at,omitempty"`
+	// RetiringSecrets are secrets replaced by a rotation that still work
+	// until their grace period ends
+	RetiringSecrets []RetiringSecret `json:"retiring_secrets,omitempty"`
+	Rotations       []KeyRotation    `json:"rotations,omitempty"`
 }
 
 type Permission string
@@ -47,6 +82,7 @@ const (
 	PermissionWriteDocuments  Permission = "write:documents"
 	PermissionAnalyze         Permission = "analyze"
 	PermissionSearch          Permission = "search"
+	PermissionReplicate       Permission = "replicate"
 	PermissionAdmin           Permission = "admin"
 	PermissionAll             Permission = "*"
 )
@@ -61,6 +97,18 @@ type AuthContext struct {
 func NewAuthManager(basePath string) (*AuthManager, error) {
 	configPath := filepath.Join(basePath, ".context", "auth.json")
 
+	// Ensure directory exists
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create auth directory: %w", err)
+	}
+
+	// Another process starting at the same time mustn't create it as well
+	lock, err := filelock.Acquire(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock auth config: %w", err)
+	}
+	defer lock.Unlock()
+
 	// Check if auth config exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		// Create default config
@@ -83,42 +131,45 @@ func createDefaultAuthConfig(configPath string) (*AuthManager, error) {
 		LastModified:  time.Now(),
 	}
 
-	// Ensure directory exists
-	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
-		return nil, fmt.Errorf("failed to create auth directory: %w", err)
+	usage, err := NewUsageTracker(usagePathFor(configPath))
+	if err != nil {
+		return nil, err
 	}
 
-	// Save config
-	if err := writeJSON(configPath, config); err != nil {
+	am := newAuthManager(configPath, usage)
+	if err := am.saveConfig(config); err != nil {
 		return nil, fmt.Errorf("failed to save auth config: %w", err)
 	}
-
-	return &AuthManager{
-		configPath: configPath,
-		config:     config,
-	}, nil
+	return am, nil
 }
 
 func loadAuthConfig(configPath string) (*AuthManager, error) {
-	var config AuthConfig
-	if err := readJSON(configPath, &config); err != nil {
+	usage, err := NewUsageTracker(usagePathFor(configPath))
+	if err != nil {
+		return nil, err
+	}
+
+	am := newAuthManager(configPath, usage)
+	if err := am.reload(); err != nil {
 		return nil, fmt.Errorf("failed to load auth config: %w", err)
 	}
+	return am, nil
+}
 
+func newAuthManager(configPath string, usage *UsageTracker) *AuthManager {
 	return &AuthManager{
 		configPath: configPath,
-		config:     &config,
-	}, nil
+		usage:      usage,
+		attempts:   NewAttemptTracker(DefaultLockoutPolicy()),
+		lastUsed:   make(map[string]time.Time),
+	}
 }
 
 func (am *AuthManager) CreateAPIKey(name string, authorID operations.AuthorID, permissions []Permission, expiresIn *time.Duration) (string, error) {
-	// Generate random key
-	keyBytes := make([]byte, 32)
-	if _, err := rand.Read(keyBytes); err != nil {
-		return "", fmt.Errorf("failed to generate key: %w", err)
+	keyString, err := generateKey()
+	if err != nil {
+		return "", err
 	}
-
-	keyString := hex.EncodeToString(keyBytes)
 	keyHash := hashKey(keyString)
 
 	// Create expiration if specified
@@ -138,50 +189,66 @@ func (am *AuthManager) CreateAPIKey(name string, authorID operations.AuthorID, p
 		ExpiresAt:   expiresAt,
 	}
 
-	am.config.APIKeys = append(am.config.APIKeys, apiKey)
-	am.config.LastModified = time.Now()
-
-	if err := am.saveConfig(); err != nil {
+	err = am.update(func(config *AuthConfig) error {
+		config.APIKeys = append(config.APIKeys, apiKey)
+		return nil
+	})
+	if err != nil {
 		return "", err
 	}
 
 	return keyString, nil
 }
 
+// ValidateAPIKey finds the key presented as keyString. It only reads
+// auth.json: when the key was used is saved by Flush.
 func (am *AuthManager) ValidateAPIKey(keyString string) (*AuthContext, error) {
 	keyHash := hashKey(keyString)
 
-	for i := range am.config.APIKeys {
-		key := &am.config.APIKeys[i]
-
-		// Constant-time comparison
-		if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(keyHash)) == 1 {
-			// Check if expired
-			if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
-				return nil, fmt.Errorf("API key expired")
-			}
+	// Looking up the hash rather than comparing it with each key in constant
+	// time leaks nothing: timing can only tell about the hash of the guess
+	key, ok := am.lookupKey(keyHash)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.KeyHash != keyHash && !key.retiringSecret(keyHash) {
+		return nil, ErrInvalidAPIKey
+	}
 
-			// Update last used
-			now := time.Now()
-			key.LastUsed = &now
-			am.config.LastModified = time.Now()
-			am.saveConfig() // Best effort, don't fail validation if this fails
-
-			return &AuthContext{
-				AuthorID:      key.AuthorID,
-				APIKeyID:      key.ID,
-				Permissions:   key.Permissions,
-				Authenticated: true,
-			}, nil
-		}
+	// Check if expired
+	now := time.Now()
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
 	}
 
-	return nil, fmt.Errorf("invalid API key")
+	am.lastUsedMutex.Lock()
+	am.lastUsed[key.ID] = now
+	am.lastUsedMutex.Unlock()
+
+	return &AuthContext{
+		AuthorID:      key.AuthorID,
+		APIKeyID:      key.ID,
+		Permissions:   key.Permissions,
+		Authenticated: true,
+	}, nil
+}
+
+// lookupKey finds the key with a secret hash in the current config
+func (am *AuthManager) lookupKey(keyHash string) (APIKey, bool) {
+	am.current()
+
+	am.mutex.RLock()
+	defer am.mutex.RUnlock()
+	i, ok := am.keyIndex[keyHash]
+	if !ok {
+		return APIKey{}, false
+	}
+	return am.config.APIKeys[i], true
 }
 
 func (am *AuthManager) GetAnonymousContext() *AuthContext {
 	return &AuthContext{
-		AuthorID:      am.config.DefaultAuthor,
+		AuthorID:      am.current().DefaultAuthor,
 		APIKeyID:      "",
 		Permissions:   []Permission{PermissionAll}, // Anonymous gets all permissions when auth disabled
 		Authenticated: false,
@@ -189,37 +256,52 @@ func (am *AuthManager) GetAnonymousContext() *AuthContext {
 }
 
 func (am *AuthManager) IsAuthRequired() bool {
-	return am.config.RequireAuth
+	return am.current().RequireAuth
 }
 
 func (am *AuthManager) EnableAuth() error {
-	am.config.RequireAuth = true
-	am.config.LastModified = time.Now()
-	return am.saveConfig()
+	return am.update(func(config *AuthConfig) error {
+		config.RequireAuth = true
+		return nil
+	})
 }
 
 func (am *AuthManager) DisableAuth() error {
-	am.config.RequireAuth = false
-	am.config.LastModified = time.Now()
-	return am.saveConfig()
+	return am.update(func(config *AuthConfig) error {
+		config.RequireAuth = false
+		return nil
+	})
 }
 
 func (am *AuthManager) ListAPIKeys() []APIKeySummary {
 	var summaries []APIKeySummary
-	for _, key := range am.config.APIKeys {
-		summaries = append(summaries, APIKeySummary{
-			ID:          key.ID,
-			Name:        key.Name,
-			AuthorID:    key.AuthorID,
-			Permissions: key.Permissions,
-			CreatedAt:   key.CreatedAt,
-			LastUsed:    key.LastUsed,
-			ExpiresAt:   key.ExpiresAt,
-		})
+	for _, key := range am.current().APIKeys {
+		summaries = append(summaries, am.summary(key))
+	}
+	return summaries
+}
+
+// APIKeysOf lists the keys that authenticate as author
+func (am *AuthManager) APIKeysOf(authorID operations.AuthorID) []APIKeySummary {
+	summaries := []APIKeySummary{}
+	for _, key := range am.current().APIKeys {
+		if key.AuthorID == authorID {
+			summaries = append(summaries, am.summary(key))
+		}
 	}
 	return summaries
 }
 
+func (am *AuthManager) GetAPIKey(keyID string) (*APIKeySummary, error) {
+	for _, key := range am.current().APIKeys {
+		if key.ID == keyID {
+			summary := am.summary(key)
+			return &summary, nil
+		}
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
 type APIKeySummary struct {
 	ID          string              `json:"id"`
 	Name        string              `json:"name"`
@@ -228,18 +310,140 @@ type APIKeySummary struct {
 	CreatedAt   time.Time           `json:"created_at"`
 	LastUsed    *time.Time          `json:"last_used,omitempty"`
 	ExpiresAt   *time.Time          `json:"expires_at,omitempty"`
+	Rotations   []KeyRotation       `json:"rotations,omitempty"`
+}
+
+func (key APIKey) summary() APIKeySummary {
+	return APIKeySummary{
+		ID:          key.ID,
+		Name:        key.Name,
+		AuthorID:    key.AuthorID,
+		Permissions: key.Permissions,
+		CreatedAt:   key.CreatedAt,
+		LastUsed:    key.LastUsed,
+		ExpiresAt:   key.ExpiresAt,
+		Rotations:   key.Rotations,
+	}
+}
+
+// summary describes key, including a use not saved yet
+func (am *AuthManager) summary(key APIKey) APIKeySummary {
+	summary := key.summary()
+	am.lastUsedMutex.Lock()
+	defer am.lastUsedMutex.Unlock()
+	if used, ok := am.lastUsed[key.ID]; ok && (summary.LastUsed == nil || used.After(*summary.LastUsed)) {
+		summary.LastUsed = &used
+	}
+	return summary
 }
 
 func (am *AuthManager) RevokeAPIKey(keyID string) error {
+	return am.update(func(config *AuthConfig) error {
+		for i, key := range config.APIKeys {
+			if key.ID == keyID {
+				// Remove key by slicing
+				config.APIKeys = append(config.APIKeys[:i], config.APIKeys[i+1:]...)
+				return nil
+			}
+		}
+		return ErrAPIKeyNotFound
+	})
+}
+
+func (am *AuthManager) Usage() *UsageTracker {
+	return am.usage
+}
+
+// Run flushes what is kept in memory every interval until ctx is cancelled.
+// The caller flushes once more when it is done with the manager.
+func (am *AuthManager) Run(ctx gocontext.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := am.Flush(); err != nil {
+			logger.Error("Failed to flush auth data", map[string]interface{}{"error": err.Error()})
+		}
+	}
+}
+
+// Flush saves the usage counted since the last flush, and when keys were
+// last used where that moved on by LastUsedPrecision
+func (am *AuthManager) Flush() error {
+	return errors.Join(am.flushLastUsed(), am.usage.Flush())
+}
+
+func (am *AuthManager) flushLastUsed() error {
+	am.lastUsedMutex.Lock()
+	used := make(map[string]time.Time, len(am.lastUsed))
+	for keyID, at := range am.lastUsed {
+		used[keyID] = at
+	}
+	am.lastUsedMutex.Unlock()
+
+	// Rewriting auth.json is only worth it once a time saved is out by more
+	// than its precision
+	due := false
+	keys := make(map[string]bool)
+	for _, key := range am.current().APIKeys {
+		keys[key.ID] = true
+		if at, ok := used[key.ID]; ok && (key.LastUsed == nil || at.Sub(*key.LastUsed) >= LastUsedPrecision) {
+			due = true
+		}
+	}
+	if due {
+		err := am.update(func(config *AuthConfig) error {
+			for i := range config.APIKeys {
+				key := &config.APIKeys[i]
+				if at, ok := used[key.ID]; ok && (key.LastUsed == nil || at.After(*key.LastUsed)) {
+					key.LastUsed = &at
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save when keys were last used: %w", err)
+		}
+	}
+
+	// Forget uses now saved, unless the key was used again since, and those
+	// of keys revoked
+	am.lastUsedMutex.Lock()
+	defer am.lastUsedMutex.Unlock()
+	for keyID, at := range used {
+		if (due || !keys[keyID]) && am.lastUsed[keyID].Equal(at) {
+			delete(am.lastUsed, keyID)
+		}
+	}
+	return nil
+}
+
+// forgetUsed drops a use of keyID not saved yet
+func (am *AuthManager) forgetUsed(keyID string) {
+	am.lastUsedMutex.Lock()
+	defer am.lastUsedMutex.Unlock()
+	delete(am.lastUsed, keyID)
+}
+
+// Attempts tracks failed authentication attempts and locks out their sources
+func (am *AuthManager) Attempts() *AttemptTracker {
+	return am.attempts
+}
+
+// indexKeys rebuilds keyIndex after the keys change
+func (am *AuthManager) indexKeys() {
+	am.keyIndex = make(map[string]int, len(am.config.APIKeys))
 	for i, key := range am.config.APIKeys {
-		if key.ID == keyID {
-			// Remove key by slicing
-			am.config.APIKeys = append(am.config.APIKeys[:i], am.config.APIKeys[i+1:]...)
-			am.config.LastModified = time.Now()
-			return am.saveConfig()
+		am.keyIndex[key.KeyHash] = i
+		for _, secret := range key.RetiringSecrets {
+			am.keyIndex[secret.KeyHash] = i
 		}
 	}
-	return fmt.Errorf("API key not found")
 }
 
 func (ac *AuthContext) HasPermission(perm Permission) bool {
@@ -251,32 +455,130 @@ func (ac *AuthContext) HasPermission(perm Permission) bool {
 	return false
 }
 
-func (am *AuthManager) saveConfig() error {
-	return writeJSON(am.configPath, am.config)
+// current returns the config, first reloading it if another process has
+// saved it since it was read. Callers must not modify it.
+func (am *AuthManager) current() *AuthConfig {
+	am.mutex.RLock()
+	config, changed := am.config, am.configVersion.Changed(am.configPath)
+	am.mutex.RUnlock()
+	if !changed {
+		return config
+	}
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+	if am.configVersion.Changed(am.configPath) {
+		am.reload() // Keep the config already loaded if the file can't be read
+	}
+	return am.config
+}
+
+// update applies change to a copy of the config and saves it. It holds the
+// lock on auth.json throughout, reloading the file first if another process
+// saved it since, so neither process overwrites the other's update. Nothing
+// is saved if change fails.
+func (am *AuthManager) update(change func(config *AuthConfig) error) error {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	lock, err := filelock.Acquire(am.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to lock auth config: %w", err)
+	}
+	defer lock.Unlock()
+
+	if am.configVersion.Changed(am.configPath) {
+		if err := am.reload(); err != nil {
+			return fmt.Errorf("failed to reload auth config: %w", err)
+		}
+	}
+
+	config := am.config.clone()
+	if err := change(config); err != nil {
+		return err
+	}
+	config.LastModified = time.Now()
+	return am.saveConfig(config)
+}
+
+// reload reads auth.json, noting its version first so a save racing the
+// read is caught by the next check rather than missed
+func (am *AuthManager) reload() error {
+	version, err := filelock.Stat(am.configPath)
+	if err != nil {
+		return err
+	}
+	var config AuthConfig
+	if err := readJSON(am.configPath, &config); err != nil {
+		return err
+	}
+	am.config = &config
+	am.configVersion = version
+	am.indexKeys()
+	return nil
+}
+
+// saveConfig writes config to auth.json and makes it the current one
+func (am *AuthManager) saveConfig(config *AuthConfig) error {
+	if err := writeJSON(am.configPath, config); err != nil {
+		return err
+	}
+	version, err := filelock.Stat(am.configPath)
+	if err != nil {
+		return err
+	}
+	am.config = config
+	am.configVersion = version
+	am.indexKeys()
+	return nil
+}
+
+// clone copies the config deeply enough for an update to change the copy
+// while the original is read
+func (config *AuthConfig) clone() *AuthConfig {
+	copied := *config
+	copied.APIKeys = make([]APIKey, len(config.APIKeys))
+	for i, key := range config.APIKeys {
+		key.RetiringSecrets = append([]RetiringSecret(nil), key.RetiringSecrets...)
+		key.Rotations = append([]KeyRotation(nil), key.Rotations...)
+		copied.APIKeys[i] = key
+	}
+	copied.SigningKeys = append([]SigningKey(nil), config.SigningKeys...)
+	return &copied
 }
 
 // Helper functions
+func usagePathFor(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "usage.json")
+}
+
 func hashKey(key string) string {
 	hash := sha3.Sum256([]byte(key))
 	return hex.EncodeToString(hash[:])
 }
 
+// generateKey makes the random secret an API key is presented as
+func generateKey() (string, error) {
+	keyBytes := make([]byte, 32)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return hex.EncodeToString(keyBytes), nil
+}
+
 func generateKeyID() string {
 	bytes := make([]byte, 8)
 	rand.Read(bytes)
 	return hex.EncodeToString(bytes)
 }
 
+// writeJSON replaces filePath atomically, so no reader sees it half written
 func writeJSON(filePath string, data interface{}) error {
-	file, err := os.Create(filePath)
+	encoded, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(data)
+	return filelock.WriteFile(filePath, append(encoded, '\n'), 0644)
 }
 
 func readJSON(filePath string, data interface{}) error {
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidateAPIKey(t *testing.T) {
+	dir := t.TempDir()
+	authManager, err := NewAuthManager(dir)
+	if err != nil {
+		t.Fatalf("Failed to create auth manager: %v", err)
+	}
+	key, err := authManager.CreateAPIKey("alice", "alice", []Permission{PermissionReadDocuments}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	expiresIn := -time.Minute
+	expired, err := authManager.CreateAPIKey("old", "alice", []Permission{PermissionReadDocuments}, &expiresIn)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+
+	if _, err := authManager.ValidateAPIKey("wrong"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey, got %v", err)
+	}
+	if _, err := authManager.ValidateAPIKey(expired); !errors.Is(err, ErrAPIKeyExpired) {
+		t.Errorf("Expected ErrAPIKeyExpired, got %v", err)
+	}
+
+	configPath := filepath.Join(dir, ".context", "auth.json")
+	saved, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read auth config: %v", err)
+	}
+	ctx, err := authManager.ValidateAPIKey(key)
+	if err != nil {
+		t.Fatalf("Failed to validate API key: %v", err)
+	}
+	if ctx.AuthorID != "alice" || !ctx.Authenticated || !ctx.HasPermission(PermissionReadDocuments) {
+		t.Errorf("Expected alice with read:documents, got %+v", ctx)
+	}
+
+	// The use is known at once but only saved by a flush
+	if current, err := os.ReadFile(configPath); err != nil || string(current) != string(saved) {
+		t.Errorf("Expected validating a key not to write auth.json, got %v", err)
+	}
+	summary, err := authManager.GetAPIKey(ctx.APIKeyID)
+	if err != nil {
+		t.Fatalf("Failed to get API key: %v", err)
+	}
+	if summary.LastUsed == nil {
+		t.Error("Expected the key's use known before it is saved")
+	}
+	if err := authManager.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+	reopened, err := NewAuthManager(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen auth manager: %v", err)
+	}
+	if summary, err := reopened.GetAPIKey(ctx.APIKeyID); err != nil || summary.LastUsed == nil {
+		t.Errorf("Expected the key's use saved, got %+v, %v", summary, err)
+	}
+
+	// A use within LastUsedPrecision of the one saved isn't worth a rewrite
+	saved, err = os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read auth config: %v", err)
+	}
+	if _, err := authManager.ValidateAPIKey(key); err != nil {
+		t.Fatalf("Failed to validate API key: %v", err)
+	}
+	if err := authManager.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+	if current, err := os.ReadFile(configPath); err != nil || string(current) != string(saved) {
+		t.Errorf("Expected a use within %s not to be saved, got %v", LastUsedPrecision, err)
+	}
+
+	// A rotated key hasn't been used yet
+	if _, _, err := authManager.RotateAPIKey(ctx.APIKeyID, 0, "admin"); err != nil {
+		t.Fatalf("Failed to rotate API key: %v", err)
+	}
+	if summary, err := authManager.GetAPIKey(ctx.APIKeyID); err != nil || summary.LastUsed != nil {
+		t.Errorf("Expected a rotated key unused, got %+v, %v", summary, err)
+	}
+	if _, err := authManager.ValidateAPIKey(key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected the replaced secret refused, got %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package auth
+
+import "errors"
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrAPIKeyExpired  = errors.New("API key expired")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+
+	ErrInvalidGracePeriod = errors.New("invalid grace period")
+	ErrLockoutNotFound    = errors.New("source is not locked out")
+
+	ErrSigningKeyNotFound = errors.New("signing key not found")
+	ErrSigningKeyExists   = errors.New("signing key already registered")
+	ErrInvalidSigningKey  = errors.New("invalid signing key")
+	ErrSignatureRequired  = errors.New("operation must be signed")
+	ErrInvalidSignature   = errors.New("invalid operation signature")
+	ErrSignatureExpired   = errors.New("operation signature expired")
+)
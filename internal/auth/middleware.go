@@ -2,16 +2,67 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/logging"
 )
 
 type contextKey string
 
 const AuthContextKey contextKey = "auth_context"
 
-// AuthMiddleware provides authentication for HTTP requests
-func AuthMiddleware(authManager *AuthManager) func(http.Handler) http.Handler {
+// ErrorWriter renders an authentication failure for the caller
+type ErrorWriter func(w http.ResponseWriter, r *http.Request, message string, statusCode int)
+
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	writeError        ErrorWriter
+	trustForwardedFor bool
+}
+
+// WithErrorWriter lets the API layer render auth failures in its own response format
+func WithErrorWriter(writer ErrorWriter) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.writeError = writer
+	}
+}
+
+// WithTrustedForwardedFor takes a request's client address from the
+// X-Forwarded-For header a reverse proxy sets, rather than the connection
+func WithTrustedForwardedFor() MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.trustForwardedFor = true
+	}
+}
+
+// keyPrefixLength is how much of a key tried is counted and logged, enough to
+// tell attempts on one key apart without revealing it
+const keyPrefixLength = 8
+
+var logger = logging.NewLogger("auth")
+
+// AuthMiddleware provides authentication for HTTP requests. Failed attempts
+// count against the client address, and an address that fails too often is
+// refused with 429 until its lockout ends, without its keys being tried.
+// Failures are counted against the prefix of the key tried as well, but that
+// never locks a key out, or a client could lock out a key it doesn't hold.
+func AuthMiddleware(authManager *AuthManager, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	cfg := &middlewareConfig{
+		writeError: func(w http.ResponseWriter, r *http.Request, message string, statusCode int) {
+			writeAuthError(w, message, statusCode)
+		},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			var authContext *AuthContext
@@ -22,18 +73,35 @@ func AuthMiddleware(authManager *AuthManager) func(http.Handler) http.Handler {
 				authContext = authManager.GetAnonymousContext()
 			} else {
 				// Try to authenticate
+				clientIP := clientAddress(r, cfg.trustForwardedFor)
 				apiKey := extractAPIKey(r)
-				if apiKey != "" {
-					ctx, err := authManager.ValidateAPIKey(apiKey)
-					if err != nil {
-						writeAuthError(w, "Invalid API key", http.StatusUnauthorized)
-						return
+				if apiKey == "" {
+					authManager.Attempts().Fail(FailureMissingKey)
+					cfg.writeError(w, r, "API key required", http.StatusUnauthorized)
+					return
+				}
+
+				source := "ip:" + clientIP
+				if retryAfter, locked := authManager.Attempts().Locked(source); locked {
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+					cfg.writeError(w, r, "Too many failed authentication attempts", http.StatusTooManyRequests)
+					return
+				}
+
+				ctx, err := authManager.ValidateAPIKey(apiKey)
+				if err != nil {
+					reason := FailureInvalidKey
+					if errors.Is(err, ErrAPIKeyExpired) {
+						reason = FailureExpiredKey
 					}
-					authContext = ctx
-				} else {
-					writeAuthError(w, "API key required", http.StatusUnauthorized)
+					locked := authManager.Attempts().Fail(reason, source)
+					keyFailures := authManager.Attempts().FailKey(keyPrefix(apiKey))
+					logFailure(r, clientIP, apiKey, reason, keyFailures, locked)
+					cfg.writeError(w, r, "Invalid API key", http.StatusUnauthorized)
 					return
 				}
+				authManager.Attempts().Succeed()
+				authContext = ctx
 			}
 
 			// Add auth context to request
@@ -88,6 +156,51 @@ func extractAPIKey(r *http.Request) string {
 	return r.URL.Query().Get("api_key")
 }
 
+// clientAddress is the IP address a request came from
+func clientAddress(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		// The proxy appends the address it saw, so the last hop is the one
+		// a client can't forge
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if hop := strings.TrimSpace(hops[len(hops)-1]); hop != "" {
+				return hop
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func keyPrefix(key string) string {
+	if len(key) > keyPrefixLength {
+		return key[:keyPrefixLength]
+	}
+	return key
+}
+
+func logFailure(r *http.Request, clientIP, apiKey, reason string, keyFailures int, locked []LockedSource) {
+	fields := map[string]interface{}{
+		"client_ip":    clientIP,
+		"key_prefix":   keyPrefix(apiKey),
+		"key_failures": keyFailures,
+		"reason":       reason,
+		"method":       r.Method,
+		"path":         r.URL.Path,
+	}
+	logger.Warn("Authentication failed", fields)
+	for _, lockout := range locked {
+		logger.Warn("Authentication source locked out", map[string]interface{}{
+			"source": lockout.Source,
+			"reason": reason,
+			"until":  lockout.Until.Format(time.RFC3339),
+		})
+	}
+}
+
 func writeAuthError(w http.ResponseWriter, message string, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
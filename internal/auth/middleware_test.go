@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	authManager, err := NewAuthManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create auth manager: %v", err)
+	}
+	if err := authManager.EnableAuth(); err != nil {
+		t.Fatalf("Failed to enable auth: %v", err)
+	}
+	authManager.Attempts().SetPolicy(LockoutPolicy{MaxFailures: 2, Window: time.Minute, Duration: time.Hour})
+
+	valid, err := authManager.CreateAPIKey("alice", "alice", []Permission{PermissionReadDocuments}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	expiresIn := -time.Minute
+	expired, err := authManager.CreateAPIKey("old", "alice", []Permission{PermissionReadDocuments}, &expiresIn)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+
+	handler := AuthMiddleware(authManager)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ctx := GetAuthContext(r.Context()); ctx == nil || ctx.AuthorID != "alice" {
+			t.Errorf("Expected alice authenticated, got %+v", ctx)
+		}
+	}))
+	do := func(key, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v2/documents", nil)
+		req.RemoteAddr = remoteAddr
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if recorder := do("", "192.0.2.1:1234"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a key, got %d", recorder.Code)
+	}
+	if recorder := do(expired, "192.0.2.1:1234"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an expired key, got %d", recorder.Code)
+	}
+	if recorder := do(valid[:8]+"-wrong", "192.0.2.1:1234"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong key, got %d", recorder.Code)
+	}
+
+	// The address is locked out, and its keys aren't tried
+	recorder := do(expired, "192.0.2.1:1234")
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After once locked out, got %d %v", recorder.Code, recorder.Header())
+	}
+	if recorder := do(valid, "192.0.2.1:1234"); recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected a valid key refused from a locked out address, got %d", recorder.Code)
+	}
+
+	// Guesses at the valid key's prefix from elsewhere are counted, but the
+	// key isn't locked out
+	for _, addr := range []string{"203.0.113.1:1234", "203.0.113.2:1234", "203.0.113.3:1234"} {
+		if recorder := do(valid[:8]+"-wrong", addr); recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for a wrong key, got %d", recorder.Code)
+		}
+	}
+	if recorder := do(valid, "198.51.100.7:1234"); recorder.Code != http.StatusOK {
+		t.Errorf("Expected the key let in from another address, got %d", recorder.Code)
+	}
+
+	stats := authManager.Attempts().Stats()
+	if stats.FailuresByReason[FailureMissingKey] != 1 || stats.FailuresByReason[FailureExpiredKey] != 1 ||
+		stats.FailuresByReason[FailureInvalidKey] != 4 || stats.Successes != 1 || stats.Refused != 2 {
+		t.Errorf("Expected the failures, a success and two refusals, got %+v", stats)
+	}
+	if len(stats.Locked) != 1 || stats.Locked[0].Source != "ip:192.0.2.1" {
+		t.Errorf("Expected only the address locked out, got %+v", stats.Locked)
+	}
+	// The expired key's prefix failed once too
+	if len(stats.KeyFailures) != 2 || !slices.Contains(stats.KeyFailures, KeyFailures{KeyPrefix: valid[:8], Failures: 4}) {
+		t.Errorf("Expected 4 failures with the key's prefix, got %+v", stats.KeyFailures)
+	}
+	if err := authManager.Attempts().Unlock("key:" + valid[:8]); !errors.Is(err, ErrLockoutNotFound) {
+		t.Errorf("Expected a key prefix never locked out, got %v", err)
+	}
+
+	if err := authManager.Attempts().Unlock("ip:192.0.2.1"); err != nil {
+		t.Fatalf("Failed to unlock: %v", err)
+	}
+	if recorder := do(expired, "192.0.2.1:1234"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 again once unlocked, got %d", recorder.Code)
+	}
+}
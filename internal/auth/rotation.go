@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// MaxRotationGracePeriod is the longest a rotated secret keeps working
+// alongside the new one
+const MaxRotationGracePeriod = 7 * 24 * time.Hour
+
+// maxRotationHistory is how many rotations a key remembers
+const maxRotationHistory = 20
+
+// RetiringSecret is a secret a rotation replaced, still accepted until
+// ExpiresAt so clients holding it can switch over
+type RetiringSecret struct {
+	KeyHash   string    `json:"key_hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// KeyRotation records a key's secret being replaced, by whom, and until when
+// the secret it replaced kept working
+type KeyRotation struct {
+	RotatedAt time.Time           `json:"rotated_at"`
+	RotatedBy operations.AuthorID `json:"rotated_by,omitempty"`
+	// PreviousExpiresAt is when the replaced secret stops working, the
+	// rotation itself when there was no grace period
+	PreviousExpiresAt time.Time `json:"previous_expires_at"`
+}
+
+// RotateAPIKey replaces the secret of a key, keeping its ID, author and
+// permissions. The old secret keeps working for grace, at most
+// MaxRotationGracePeriod, and stops at once when grace is zero.
+func (am *AuthManager) RotateAPIKey(keyID string, grace time.Duration, rotatedBy operations.AuthorID) (string, *KeyRotation, error) {
+	if grace < 0 || grace > MaxRotationGracePeriod {
+		return "", nil, fmt.Errorf("%w: must be between 0 and %s", ErrInvalidGracePeriod, MaxRotationGracePeriod)
+	}
+
+	var keyString string
+	var rotation KeyRotation
+	err := am.update(func(config *AuthConfig) error {
+		for i := range config.APIKeys {
+			key := &config.APIKeys[i]
+			if key.ID != keyID {
+				continue
+			}
+
+			var err error
+			if keyString, err = generateKey(); err != nil {
+				return err
+			}
+
+			now := time.Now()
+			rotation = KeyRotation{RotatedAt: now, RotatedBy: rotatedBy, PreviousExpiresAt: now.Add(grace)}
+
+			// Earlier secrets still retiring work no longer than the one just
+			// replaced, so rotating without a grace period cuts off all of them
+			retiring := []RetiringSecret{}
+			for _, secret := range key.RetiringSecrets {
+				secret.ExpiresAt = minTime(secret.ExpiresAt, rotation.PreviousExpiresAt)
+				if secret.ExpiresAt.After(now) {
+					retiring = append(retiring, secret)
+				}
+			}
+			if grace > 0 {
+				retiring = append(retiring, RetiringSecret{KeyHash: key.KeyHash, ExpiresAt: rotation.PreviousExpiresAt})
+			}
+			key.RetiringSecrets = retiring
+
+			key.KeyHash = hashKey(keyString)
+			key.LastUsed = nil
+			key.Rotations = append(key.Rotations, rotation)
+			if len(key.Rotations) > maxRotationHistory {
+				key.Rotations = key.Rotations[len(key.Rotations)-maxRotationHistory:]
+			}
+			return nil
+		}
+		return ErrAPIKeyNotFound
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	// The new secret hasn't been used
+	am.forgetUsed(keyID)
+	return keyString, &rotation, nil
+}
+
+// retiringSecret reports whether keyHash is a rotated secret of the key still
+// in its grace period
+func (key *APIKey) retiringSecret(keyHash string) bool {
+	now := time.Now()
+	for _, secret := range key.RetiringSecrets {
+		if now.Before(secret.ExpiresAt) && subtle.ConstantTimeCompare([]byte(secret.KeyHash), []byte(keyHash)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
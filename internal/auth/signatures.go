@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// MaxSignatureAge is how far a signed operation's timestamp may be from now.
+// Older signed operations are refused, so one captured in transit can't be
+// replayed after retention has removed the original.
+const MaxSignatureAge = 5 * time.Minute
+
+// SigningKey is an Ed25519 public key an author signs operations with
+type SigningKey struct {
+	ID        string              `json:"id"`
+	Name      string              `json:"name,omitempty"`
+	AuthorID  operations.AuthorID `json:"author_id"`
+	PublicKey string              `json:"public_key"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// RegisterSigningKey lets author sign operations with the private half of
+// publicKey, which is base64 encoded
+func (am *AuthManager) RegisterSigningKey(name string, authorID operations.AuthorID, publicKey string) (*SigningKey, error) {
+	if authorID == "" {
+		return nil, operations.ErrInvalidAuthor
+	}
+	if _, err := decodePublicKey(publicKey); err != nil {
+		return nil, err
+	}
+
+	key := SigningKey{
+		ID:        generateKeyID(),
+		Name:      name,
+		AuthorID:  authorID,
+		PublicKey: publicKey,
+		CreatedAt: time.Now(),
+	}
+	err := am.update(func(config *AuthConfig) error {
+		for _, existing := range config.SigningKeys {
+			if existing.PublicKey == publicKey {
+				return ErrSigningKeyExists
+			}
+		}
+		config.SigningKeys = append(config.SigningKeys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// SigningKeys lists the registered keys, only author's unless author is empty
+func (am *AuthManager) SigningKeys(authorID operations.AuthorID) []SigningKey {
+	keys := []SigningKey{}
+	for _, key := range am.current().SigningKeys {
+		if authorID == "" || key.AuthorID == authorID {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (am *AuthManager) GetSigningKey(keyID string) (*SigningKey, error) {
+	for _, key := range am.current().SigningKeys {
+		if key.ID == keyID {
+			return &key, nil
+		}
+	}
+	return nil, ErrSigningKeyNotFound
+}
+
+func (am *AuthManager) RevokeSigningKey(keyID string) error {
+	return am.update(func(config *AuthConfig) error {
+		for i, key := range config.SigningKeys {
+			if key.ID == keyID {
+				config.SigningKeys = append(config.SigningKeys[:i], config.SigningKeys[i+1:]...)
+				return nil
+			}
+		}
+		return ErrSigningKeyNotFound
+	})
+}
+
+func (am *AuthManager) SignaturesRequired() bool {
+	return am.current().RequireSignatures
+}
+
+// RequireSignatures refuses unsigned operations, so no one can write as an
+// author without that author's private key
+func (am *AuthManager) RequireSignatures() error {
+	return am.update(func(config *AuthConfig) error {
+		config.RequireSignatures = true
+		return nil
+	})
+}
+
+func (am *AuthManager) AllowUnsignedOperations() error {
+	return am.update(func(config *AuthConfig) error {
+		config.RequireSignatures = false
+		return nil
+	})
+}
+
+// VerifyOperation checks a signed operation against its author's keys. Unsigned
+// operations pass unless signatures are required.
+func (am *AuthManager) VerifyOperation(op *operations.Operation) error {
+	config := am.current()
+	if op.Metadata.Signature == "" {
+		if config.RequireSignatures {
+			return ErrSignatureRequired
+		}
+		return nil
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(op.Metadata.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: not a base64 Ed25519 signature", ErrInvalidSignature)
+	}
+	if age := time.Since(op.Timestamp); age > MaxSignatureAge || age < -MaxSignatureAge {
+		return fmt.Errorf("%w: timestamp %s is not within %s of now", ErrSignatureExpired, op.Timestamp.Format(time.RFC3339), MaxSignatureAge)
+	}
+
+	payload, err := operations.SigningPayload(op)
+	if err != nil {
+		return err
+	}
+	for _, key := range config.SigningKeys {
+		if key.AuthorID != op.Author {
+			continue
+		}
+		publicKey, err := decodePublicKey(key.PublicKey)
+		if err == nil && ed25519.Verify(publicKey, payload, signature) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no key registered for %s signed this operation", ErrInvalidSignature, op.Author)
+}
+
+func decodePublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: not a base64 Ed25519 public key", ErrInvalidSigningKey)
+	}
+	return ed25519.PublicKey(key), nil
+}
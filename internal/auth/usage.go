@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/filelock"
+)
+
+const usageDateFormat = "2006-01-02"
+
+// UsageTracker counts usage in memory and adds it to usage.json when flushed,
+// so metered requests never wait on the disk. Other processes sharing the
+// .context directory add theirs to the same file.
+type UsageTracker struct {
+	usagePath string
+	usage     map[string]map[string]*DailyUsage // API key ID -> day -> usage
+	// pending is the usage counted since the last flush, which usage
+	// includes but usage.json doesn't yet
+	pending map[string]map[string]*DailyUsage
+	mutex   sync.Mutex
+}
+
+type DailyUsage struct {
+	Date              string `json:"date"`
+	OperationsWritten int64  `json:"operations_written"`
+	BytesStored       int64  `json:"bytes_stored"`
+	SearchesRun       int64  `json:"searches_run"`
+}
+
+type KeyUsage struct {
+	APIKeyID string       `json:"api_key_id"`
+	Totals   DailyUsage   `json:"totals"`
+	Daily    []DailyUsage `json:"daily"`
+}
+
+func NewUsageTracker(usagePath string) (*UsageTracker, error) {
+	tracker := &UsageTracker{
+		usagePath: usagePath,
+		pending:   make(map[string]map[string]*DailyUsage),
+	}
+
+	usage, err := loadUsage(usagePath)
+	if err != nil {
+		return nil, err
+	}
+	tracker.usage = usage
+	return tracker, nil
+}
+
+func (ut *UsageTracker) RecordOperation(keyID string, bytes int) error {
+	return ut.record(keyID, func(day *DailyUsage) {
+		day.OperationsWritten++
+		day.BytesStored += int64(bytes)
+	})
+}
+
+func (ut *UsageTracker) RecordSearch(keyID string) error {
+	return ut.record(keyID, func(day *DailyUsage) {
+		day.SearchesRun++
+	})
+}
+
+func (ut *UsageTracker) GetUsage(keyID string, since time.Time) KeyUsage {
+	ut.mutex.Lock()
+	defer ut.mutex.Unlock()
+
+	return ut.summarize(keyID, since)
+}
+
+func (ut *UsageTracker) GetAllUsage(since time.Time) []KeyUsage {
+	ut.mutex.Lock()
+	defer ut.mutex.Unlock()
+
+	keyIDs := make([]string, 0, len(ut.usage))
+	for keyID := range ut.usage {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+
+	result := make([]KeyUsage, 0, len(keyIDs))
+	for _, keyID := range keyIDs {
+		result = append(result, ut.summarize(keyID, since))
+	}
+	return result
+}
+
+func (ut *UsageTracker) record(keyID string, apply func(day *DailyUsage)) error {
+	// Unauthenticated requests have no key to bill against
+	if keyID == "" {
+		return nil
+	}
+
+	ut.mutex.Lock()
+	defer ut.mutex.Unlock()
+
+	date := time.Now().UTC().Format(usageDateFormat)
+	apply(usageOn(ut.usage, keyID, date))
+	apply(usageOn(ut.pending, keyID, date))
+	return nil
+}
+
+// Flush adds the usage counted since the last flush to usage.json, holding
+// its lock so usage other processes add at the same time isn't lost, and
+// picks up what they added since
+func (ut *UsageTracker) Flush() error {
+	ut.mutex.Lock()
+	defer ut.mutex.Unlock()
+
+	lock, err := filelock.Acquire(ut.usagePath)
+	if err != nil {
+		return fmt.Errorf("failed to lock usage data: %w", err)
+	}
+	defer lock.Unlock()
+
+	usage, err := loadUsage(ut.usagePath)
+	if err != nil {
+		return err
+	}
+	for keyID, days := range ut.pending {
+		for date, pending := range days {
+			day := usageOn(usage, keyID, date)
+			day.OperationsWritten += pending.OperationsWritten
+			day.BytesStored += pending.BytesStored
+			day.SearchesRun += pending.SearchesRun
+		}
+	}
+	if len(ut.pending) > 0 {
+		if err := saveUsage(ut.usagePath, usage); err != nil {
+			return fmt.Errorf("failed to save usage data: %w", err)
+		}
+	}
+	ut.usage = usage
+	ut.pending = make(map[string]map[string]*DailyUsage)
+	return nil
+}
+
+func (ut *UsageTracker) summarize(keyID string, since time.Time) KeyUsage {
+	sinceDate := since.UTC().Format(usageDateFormat)
+	summary := KeyUsage{
+		APIKeyID: keyID,
+		Daily:    []DailyUsage{},
+	}
+
+	for date, day := range ut.usage[keyID] {
+		if !since.IsZero() && date < sinceDate {
+			continue
+		}
+		summary.Daily = append(summary.Daily, *day)
+		summary.Totals.OperationsWritten += day.OperationsWritten
+		summary.Totals.BytesStored += day.BytesStored
+		summary.Totals.SearchesRun += day.SearchesRun
+	}
+
+	sort.Slice(summary.Daily, func(i, j int) bool {
+		return summary.Daily[i].Date < summary.Daily[j].Date
+	})
+
+	return summary
+}
+
+// usageOn returns the usage of keyID on date, adding it if there is none
+func usageOn(usage map[string]map[string]*DailyUsage, keyID, date string) *DailyUsage {
+	days, exists := usage[keyID]
+	if !exists {
+		days = make(map[string]*DailyUsage)
+		usage[keyID] = days
+	}
+
+	day, exists := days[date]
+	if !exists {
+		day = &DailyUsage{Date: date}
+		days[date] = day
+	}
+	return day
+}
+
+func loadUsage(usagePath string) (map[string]map[string]*DailyUsage, error) {
+	usage := make(map[string]map[string]*DailyUsage)
+	if _, err := os.Stat(usagePath); os.IsNotExist(err) {
+		return usage, nil
+	}
+
+	var stored map[string][]DailyUsage
+	if err := readJSON(usagePath, &stored); err != nil {
+		return nil, fmt.Errorf("failed to load usage data: %w", err)
+	}
+
+	for keyID, days := range stored {
+		usage[keyID] = make(map[string]*DailyUsage, len(days))
+		for i := range days {
+			day := days[i]
+			usage[keyID][day.Date] = &day
+		}
+	}
+	return usage, nil
+}
+
+func saveUsage(usagePath string, usage map[string]map[string]*DailyUsage) error {
+	stored := make(map[string][]DailyUsage, len(usage))
+	for keyID, days := range usage {
+		for _, day := range days {
+			stored[keyID] = append(stored[keyID], *day)
+		}
+	}
+	return writeJSON(usagePath, stored)
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUsageTracker(t *testing.T) {
+	usagePath := filepath.Join(t.TempDir(), "usage.json")
+	tracker, err := NewUsageTracker(usagePath)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+
+	tracker.RecordOperation("key1", 10)
+	tracker.RecordOperation("key1", 5)
+	tracker.RecordSearch("key1")
+	tracker.RecordSearch("key2")
+	// Unauthenticated requests aren't counted
+	tracker.RecordOperation("", 100)
+
+	usage := tracker.GetUsage("key1", time.Time{})
+	today := time.Now().UTC().Format(usageDateFormat)
+	if usage.Totals.OperationsWritten != 2 || usage.Totals.BytesStored != 15 || usage.Totals.SearchesRun != 1 {
+		t.Errorf("Expected 2 operations of 15 bytes and 1 search, got %+v", usage.Totals)
+	}
+	if len(usage.Daily) != 1 || usage.Daily[0].Date != today {
+		t.Errorf("Expected today's usage alone, got %+v", usage.Daily)
+	}
+	if all := tracker.GetAllUsage(time.Time{}); len(all) != 2 || all[0].APIKeyID != "key1" || all[1].APIKeyID != "key2" {
+		t.Errorf("Expected both keys in order, got %+v", all)
+	}
+	if usage := tracker.GetUsage("key1", time.Now().Add(48*time.Hour)); len(usage.Daily) != 0 || usage.Totals.OperationsWritten != 0 {
+		t.Errorf("Expected nothing since the day after tomorrow, got %+v", usage)
+	}
+
+	// Usage is kept in memory until flushed
+	if _, err := os.Stat(usagePath); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing written before a flush, got %v", err)
+	}
+	if err := tracker.Flush(); err != nil {
+		t.Fatalf("Failed to flush usage: %v", err)
+	}
+	reopened, err := NewUsageTracker(usagePath)
+	if err != nil {
+		t.Fatalf("Failed to reopen usage tracker: %v", err)
+	}
+	if usage := reopened.GetUsage("key1", time.Time{}); usage.Totals.OperationsWritten != 2 || usage.Totals.BytesStored != 15 {
+		t.Errorf("Expected the flushed usage loaded, got %+v", usage.Totals)
+	}
+}
+
+func TestUsageTracker_FlushAdds(t *testing.T) {
+	usagePath := filepath.Join(t.TempDir(), "usage.json")
+	first, err := NewUsageTracker(usagePath)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+	second, err := NewUsageTracker(usagePath)
+	if err != nil {
+		t.Fatalf("Failed to create usage tracker: %v", err)
+	}
+
+	// Two processes sharing usage.json each add what they counted
+	first.RecordOperation("key1", 10)
+	second.RecordOperation("key1", 20)
+	second.RecordSearch("key1")
+	for _, tracker := range []*UsageTracker{first, second, first} {
+		if err := tracker.Flush(); err != nil {
+			t.Fatalf("Failed to flush usage: %v", err)
+		}
+	}
+
+	for _, tracker := range []*UsageTracker{first, second} {
+		usage := tracker.GetUsage("key1", time.Time{})
+		if usage.Totals.OperationsWritten != 2 || usage.Totals.BytesStored != 30 || usage.Totals.SearchesRun != 1 {
+			t.Errorf("Expected the usage of both, got %+v", usage.Totals)
+		}
+	}
+	first.RecordOperation("key1", 1)
+	if usage := first.GetUsage("key1", time.Time{}); usage.Totals.OperationsWritten != 3 {
+		t.Errorf("Expected unflushed usage counted, got %+v", usage.Totals)
+	}
+}
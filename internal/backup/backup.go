@@ -0,0 +1,285 @@
+// Package backup takes consistent snapshots of a .context store on demand or
+// on a schedule, keeps the newest few, and restores them. Each snapshot is a
+// directory under .context/backups holding a copy of the database made with
+// SQLite's online backup API and the store's JSON files.
+package backup
+
+import (
+	gocontext "context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/logging"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const (
+	// DefaultGenerations is how many backups are kept when not configured
+	DefaultGenerations = 7
+
+	dirName    = "backups"
+	nameLayout = "20060102T150405.000Z"
+)
+
+// Database is a store that can copy itself while in use
+type Database interface {
+	BackupTo(ctx gocontext.Context, destPath string) error
+}
+
+// Generation is one backup
+type Generation struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	// Size is the total size of the backup's files in bytes
+	Size int64 `json:"size"`
+}
+
+type Manager struct {
+	contextPath string
+	db          Database
+	generations int
+	beforeFunc  func() error
+	logger      *logging.Logger
+	mutex       sync.Mutex
+}
+
+type Option func(*Manager)
+
+// WithGenerations keeps the newest n backups, removing older ones after each backup
+func WithGenerations(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.generations = n
+		}
+	}
+}
+
+// WithBeforeBackup runs fn before each backup, e.g. to flush state kept in
+// memory to the JSON files that are copied
+func WithBeforeBackup(fn func() error) Option {
+	return func(m *Manager) {
+		m.beforeFunc = fn
+	}
+}
+
+// NewManager backs up db and the JSON files of the store in basePath
+func NewManager(basePath string, db Database, opts ...Option) *Manager {
+	m := &Manager{
+		contextPath: filepath.Join(basePath, storage.ContextDir),
+		db:          db,
+		generations: DefaultGenerations,
+		logger:      logging.NewLogger("backup"),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func backupsPath(contextPath string) string {
+	return filepath.Join(contextPath, dirName)
+}
+
+// Create takes a backup now, then removes backups beyond the generations kept
+func (m *Manager) Create(ctx gocontext.Context) (*Generation, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.beforeFunc != nil {
+		if err := m.beforeFunc(); err != nil {
+			return nil, fmt.Errorf("failed to prepare backup: %w", err)
+		}
+	}
+
+	createdAt := time.Now().UTC()
+	name := createdAt.Format(nameLayout)
+	dir := filepath.Join(backupsPath(m.contextPath), name)
+	// Work in a temporary directory so a failed backup never looks like a generation
+	tmp := dir + ".partial"
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := m.db.BackupTo(ctx, filepath.Join(tmp, storage.DatabaseFile)); err != nil {
+		return nil, err
+	}
+	if err := copyJSONFiles(m.contextPath, tmp); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return nil, fmt.Errorf("failed to finish backup: %w", err)
+	}
+
+	generation := &Generation{Name: name, CreatedAt: createdAt, Size: dirSize(dir)}
+	m.logger.Info("Backup created", map[string]interface{}{"name": name, "size": generation.Size})
+
+	if err := m.rotate(); err != nil {
+		return generation, err
+	}
+	return generation, nil
+}
+
+// List returns the backups in basePath's store, newest first
+func (m *Manager) List() ([]Generation, error) {
+	return List(filepath.Dir(m.contextPath))
+}
+
+// List returns the backups of the store in basePath, newest first
+func List(basePath string) ([]Generation, error) {
+	entries, err := os.ReadDir(backupsPath(filepath.Join(basePath, storage.ContextDir)))
+	if os.IsNotExist(err) {
+		return []Generation{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	generations := make([]Generation, 0, len(entries))
+	for _, entry := range entries {
+		createdAt, err := time.Parse(nameLayout, entry.Name())
+		if !entry.IsDir() || err != nil {
+			continue
+		}
+		dir := filepath.Join(backupsPath(filepath.Join(basePath, storage.ContextDir)), entry.Name())
+		generations = append(generations, Generation{Name: entry.Name(), CreatedAt: createdAt, Size: dirSize(dir)})
+	}
+
+	sort.Slice(generations, func(i, j int) bool {
+		return generations[i].CreatedAt.After(generations[j].CreatedAt)
+	})
+	return generations, nil
+}
+
+func (m *Manager) rotate() error {
+	generations, err := m.List()
+	if err != nil {
+		return err
+	}
+
+	for _, old := range generations[min(len(generations), m.generations):] {
+		if err := os.RemoveAll(filepath.Join(backupsPath(m.contextPath), old.Name)); err != nil {
+			return fmt.Errorf("failed to remove old backup %s: %w", old.Name, err)
+		}
+	}
+	return nil
+}
+
+// Restore replaces the store in basePath with the backup called name. The
+// store must not be open, and Restore fails with storage.ErrStoreInUse while
+// a server has claimed it. The files it replaces are moved to
+// .context/backups/replaced-<time> rather than deleted.
+func Restore(basePath, name string) (string, error) {
+	contextPath := filepath.Join(basePath, storage.ContextDir)
+	source := filepath.Join(backupsPath(contextPath), name)
+	if _, err := time.Parse(nameLayout, name); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrBackupNotFound, name)
+	}
+	if _, err := os.Stat(filepath.Join(source, storage.DatabaseFile)); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrBackupNotFound, name)
+	}
+
+	claim, err := storage.ClaimStore(basePath)
+	if err != nil {
+		return "", err
+	}
+	defer claim.Unlock()
+
+	replaced := filepath.Join(backupsPath(contextPath), "replaced-"+time.Now().UTC().Format(nameLayout))
+	if err := os.MkdirAll(replaced, 0755); err != nil {
+		return "", fmt.Errorf("failed to set aside the current store: %w", err)
+	}
+
+	current, err := storeFiles(contextPath)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range current {
+		if err := os.Rename(filepath.Join(contextPath, file), filepath.Join(replaced, file)); err != nil {
+			return "", fmt.Errorf("failed to set aside %s: %w", file, err)
+		}
+	}
+
+	restored, err := storeFiles(source)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range restored {
+		if err := copyFile(filepath.Join(source, file), filepath.Join(contextPath, file)); err != nil {
+			return "", fmt.Errorf("failed to restore %s: %w", file, err)
+		}
+	}
+	return replaced, nil
+}
+
+// storeFiles lists the database, its journals and the JSON files in dir
+func storeFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(name, storage.DatabaseFile) || strings.HasSuffix(name, ".json") {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+func copyJSONFiles(from, to string) error {
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", from, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		if err := copyFile(filepath.Join(from, entry.Name()), filepath.Join(to, entry.Name())); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+	}
+	return size
+}
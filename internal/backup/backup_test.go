@@ -0,0 +1,132 @@
+package backup
+
+import (
+	gocontext "context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func storeOperation(t *testing.T, store *storage.ContextStore, content string) *operations.Operation {
+	t.Helper()
+
+	op := &operations.Operation{
+		ID:   operations.NewOperationID([]byte(content)),
+		Type: operations.OpInsert,
+		Position: operations.NewLogootPosition([]operations.PositionSegment{
+			{Value: big.NewInt(time.Now().UnixNano()), AuthorID: "alice"},
+		}),
+		Content:   content,
+		Author:    "alice",
+		Timestamp: time.Now(),
+	}
+	if err := store.StoreOperation(gocontext.Background(), op); err != nil {
+		t.Fatalf("Failed to store operation: %v", err)
+	}
+	return op
+}
+
+func TestManager_CreateAndRestore(t *testing.T) {
+	ctx := gocontext.Background()
+	dir := t.TempDir()
+	store, err := storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	kept := storeOperation(t, store, "package main\n")
+	authPath := filepath.Join(dir, storage.ContextDir, "auth.json")
+	if err := os.WriteFile(authPath, []byte(`{"require_auth":true}`), 0644); err != nil {
+		t.Fatalf("Failed to write auth config: %v", err)
+	}
+
+	prepared := false
+	manager := NewManager(dir, store, WithBeforeBackup(func() error {
+		prepared = true
+		return nil
+	}))
+	generation, err := manager.Create(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	if !prepared || generation.Size == 0 {
+		t.Errorf("Expected a prepared, non-empty backup, got %+v", generation)
+	}
+
+	// Changes after the backup are undone by restoring it
+	lost := storeOperation(t, store, "func main() {}\n")
+	if err := os.WriteFile(authPath, []byte(`{"require_auth":false}`), 0644); err != nil {
+		t.Fatalf("Failed to write auth config: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	replaced, err := Restore(dir, generation.Name)
+	if err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(replaced, storage.DatabaseFile)); err != nil {
+		t.Errorf("Expected the replaced database to be kept: %v", err)
+	}
+
+	restored, err := storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open restored store: %v", err)
+	}
+	defer restored.Close()
+
+	if _, err := restored.GetOperation(ctx, kept.ID); err != nil {
+		t.Errorf("Expected the backed up operation: %v", err)
+	}
+	if _, err := restored.GetOperation(ctx, lost.ID); !errors.Is(err, storage.ErrOperationNotFound) {
+		t.Errorf("Expected the later operation to be gone, got %v", err)
+	}
+	if data, _ := os.ReadFile(authPath); string(data) != `{"require_auth":true}` {
+		t.Errorf("Expected the backed up auth config, got %s", data)
+	}
+}
+
+func TestManager_Rotates(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	manager := NewManager(dir, store, WithGenerations(2))
+	var names []string
+	for i := 0; i < 3; i++ {
+		generation, err := manager.Create(gocontext.Background())
+		if err != nil {
+			t.Fatalf("Failed to create backup: %v", err)
+		}
+		names = append(names, generation.Name)
+		// Backup names have millisecond precision
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	generations, err := manager.List()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(generations) != 2 || generations[0].Name != names[2] || generations[1].Name != names[1] {
+		t.Errorf("Expected the newest two backups %v, got %+v", names[1:], generations)
+	}
+}
+
+func TestRestore_Unknown(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"20250101T000000.000Z", "../../etc"} {
+		if _, err := Restore(dir, name); !errors.Is(err, ErrBackupNotFound) {
+			t.Errorf("Expected ErrBackupNotFound for %s, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+package backup
+
+import "errors"
+
+var ErrBackupNotFound = errors.New("backup not found")
@@ -0,0 +1,284 @@
+// Package chatimport brings discussions about code held in Slack and Discord
+// exports into conversation threads. Threads are anchored to the first file
+// they link to or mention that resolves to a tracked document, and keep their
+// original authors and timestamps.
+package chatimport
+
+import (
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const (
+	SourceSlack   = "slack"
+	SourceDiscord = "discord"
+
+	maxTitleLength = 80
+)
+
+// Thread is a chat thread read from an export, with its messages oldest first
+type Thread struct {
+	// Source is the chat service the thread came from, "slack" or "discord"
+	Source  string
+	Channel string
+	// ID identifies the thread within its channel
+	ID string
+	// Private threads come from direct messages or private channels
+	Private  bool
+	Messages []Message
+}
+
+type Message struct {
+	ID        string
+	Author    string
+	Text      string
+	Timestamp time.Time
+	// Files are the names of files attached to the message
+	Files []string
+}
+
+// Read parses the export of source held in fsys
+func Read(source string, fsys fs.FS) ([]Thread, error) {
+	switch source {
+	case SourceSlack:
+		return ReadSlack(fsys)
+	case SourceDiscord:
+		return ReadDiscord(fsys)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSource, source)
+	}
+}
+
+type Importer struct {
+	conversations *context.ConversationManager
+	resolver      *addressing.AddressResolver
+	store         storage.Store
+	repository    addressing.RepositoryID
+	unanchored    bool
+
+	documents []string
+}
+
+type Option func(*Importer)
+
+// WithRepository sets the repository imported threads are anchored in
+func WithRepository(repo addressing.RepositoryID) Option {
+	return func(i *Importer) {
+		i.repository = repo
+	}
+}
+
+// WithUnanchored imports threads that don't reference a tracked file too,
+// leaving them without an anchor
+func WithUnanchored() Option {
+	return func(i *Importer) {
+		i.unanchored = true
+	}
+}
+
+// Report summarizes one Import. Threads that weren't imported are listed in
+// Skipped.
+type Report struct {
+	Imported int             `json:"imported"`
+	Updated  int             `json:"updated"`
+	Messages int             `json:"messages"`
+	Skipped  []SkippedThread `json:"skipped,omitempty"`
+}
+
+type SkippedThread struct {
+	Source  string `json:"source"`
+	Channel string `json:"channel"`
+	ID      string `json:"id"`
+	Reason  string `json:"reason"`
+}
+
+// NewImporter imports threads into the engine's conversations, resolving
+// references against the documents in store
+func NewImporter(engine *collaboration.CollaborationEngine, store storage.Store, opts ...Option) *Importer {
+	i := &Importer{
+		conversations: engine.ConversationManager(),
+		resolver:      engine.AddressResolver(),
+		store:         store,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Import adds each thread as a conversation. Conversation and message IDs are
+// derived from the export, so importing a later export of the same channels
+// only adds the messages posted since; threads already imported keep their
+// anchor, status and tags.
+func (i *Importer) Import(ctx gocontext.Context, threads []Thread) (*Report, error) {
+	documents, err := i.store.ListDocuments(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	i.documents = documents
+
+	report := &Report{}
+	for _, thread := range threads {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		i.importThread(ctx, thread, report)
+	}
+	return report, nil
+}
+
+func (i *Importer) importThread(ctx gocontext.Context, thread Thread, report *Report) {
+	skip := func(reason string) {
+		report.Skipped = append(report.Skipped, SkippedThread{Source: thread.Source, Channel: thread.Channel, ID: thread.ID, Reason: reason})
+	}
+	if len(thread.Messages) == 0 {
+		return
+	}
+
+	threadID := context.ThreadID("thread_" + thread.Source + "_" + digest(thread.Source, thread.Channel, thread.ID))
+	existing, err := i.conversations.GetConversation(threadID)
+	if err != nil && !errors.Is(err, context.ErrConversationNotFound) {
+		skip(err.Error())
+		return
+	}
+
+	var anchor *addressing.StableAddress
+	messages := make([]context.Message, 0, len(thread.Messages))
+	for _, msg := range thread.Messages {
+		message := context.Message{
+			ID:          context.MessageID("msg_" + thread.Source + "_" + digest(thread.Source, thread.Channel, msg.ID)),
+			AuthorID:    author(thread.Source, msg.Author),
+			Content:     msg.Text,
+			MessageType: context.MsgComment,
+			References:  i.references(ctx, msg),
+			Timestamp:   msg.Timestamp,
+		}
+		if anchor == nil && len(message.References) > 0 {
+			anchor = &message.References[0]
+		}
+		messages = append(messages, message)
+	}
+	sort.SliceStable(messages, func(a, b int) bool {
+		return messages[a].Timestamp.Before(messages[b].Timestamp)
+	})
+
+	if existing != nil {
+		added := mergeMessages(existing, messages)
+		if added == 0 {
+			return
+		}
+		i.conversations.Restore([]*context.ConversationThread{existing})
+		report.Updated++
+		report.Messages += added
+		return
+	}
+
+	if anchor == nil && !i.unanchored {
+		skip("no reference to a tracked file")
+		return
+	}
+
+	imported := &context.ConversationThread{
+		ID:         threadID,
+		Title:      titleFor(thread, messages[0].Content),
+		Messages:   messages,
+		Status:     context.StatusOpen,
+		Visibility: context.VisibilityPublic,
+		CreatedAt:  messages[0].Timestamp,
+		UpdatedAt:  messages[len(messages)-1].Timestamp,
+		Tags:       []string{thread.Source},
+	}
+	if anchor != nil {
+		imported.AnchorAddress = *anchor
+	}
+	if thread.Private {
+		imported.Visibility = context.VisibilityParticipants
+	}
+	for _, message := range messages {
+		if !containsAuthor(imported.Participants, message.AuthorID) {
+			imported.Participants = append(imported.Participants, message.AuthorID)
+		}
+	}
+
+	i.conversations.Restore([]*context.ConversationThread{imported})
+	report.Imported++
+	report.Messages += len(messages)
+}
+
+// mergeMessages adds the messages thread doesn't have yet, returning how many
+func mergeMessages(thread *context.ConversationThread, messages []context.Message) int {
+	known := make(map[context.MessageID]bool, len(thread.Messages))
+	for _, message := range thread.Messages {
+		known[message.ID] = true
+	}
+
+	added := 0
+	for _, message := range messages {
+		if known[message.ID] {
+			continue
+		}
+		thread.Messages = append(thread.Messages, message)
+		if !containsAuthor(thread.Participants, message.AuthorID) {
+			thread.Participants = append(thread.Participants, message.AuthorID)
+		}
+		if message.Timestamp.After(thread.UpdatedAt) {
+			thread.UpdatedAt = message.Timestamp
+		}
+		added++
+	}
+	if added > 0 {
+		sort.SliceStable(thread.Messages, func(a, b int) bool {
+			return thread.Messages[a].Timestamp.Before(thread.Messages[b].Timestamp)
+		})
+	}
+	return added
+}
+
+func author(source, name string) operations.AuthorID {
+	if name == "" {
+		return operations.AuthorID(source)
+	}
+	return operations.AuthorID(source + ":" + name)
+}
+
+func containsAuthor(authors []operations.AuthorID, author operations.AuthorID) bool {
+	for _, a := range authors {
+		if a == author {
+			return true
+		}
+	}
+	return false
+}
+
+// titleFor uses the first line of the opening message, or the channel name
+// when it has no text
+func titleFor(thread Thread, body string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(body), "\n")
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "#" + thread.Channel
+	}
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = strings.TrimSpace(string(runes[:maxTitleLength])) + "..."
+	}
+	return title
+}
+
+// digest derives a stable ID from parts of the export
+func digest(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
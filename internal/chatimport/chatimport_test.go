@@ -0,0 +1,182 @@
+package chatimport
+
+import (
+	gocontext "context"
+	"math/big"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func newTestImporter(t *testing.T, opts ...Option) (*Importer, *context.ConversationManager) {
+	t.Helper()
+	ctx := gocontext.Background()
+
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test storage: %v", err)
+	}
+	engine := collaboration.NewCollaborationEngine(store)
+
+	op := &operations.Operation{
+		ID:   operations.NewOperationID([]byte("cmd/app/main.go")),
+		Type: operations.OpInsert,
+		Position: operations.NewLogootPosition([]operations.PositionSegment{
+			{Value: big.NewInt(1), AuthorID: "alice"},
+		}),
+		Content:   "package main\n\nfunc main() {\n\tprintln(1)\n}\n",
+		Author:    "alice",
+		Timestamp: time.Now(),
+		Metadata:  operations.OperationMeta{Context: map[string]string{"document_id": "cmd/app/main.go"}},
+	}
+	if err := engine.ProcessOperation(ctx, op, ""); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+
+	return NewImporter(engine, store, append([]Option{WithRepository("acme/app")}, opts...)...), engine.ConversationManager()
+}
+
+var slackExport = fstest.MapFS{
+	"users.json": {Data: []byte(`[
+		{"id": "U1", "name": "alice", "profile": {"display_name": "Alice"}},
+		{"id": "U2", "name": "bob", "profile": {}}
+	]`)},
+	"channels.json": {Data: []byte(`[{"id": "C1", "name": "dev"}]`)},
+	"groups.json":   {Data: []byte(`[{"id": "G1", "name": "core"}]`)},
+	"dev/2024-03-01.json": {Data: []byte(`[
+		{"type": "message", "subtype": "channel_join", "ts": "1709280000.000100", "user": "U2", "text": "<@U2> has joined the channel"},
+		{"type": "message", "ts": "1709290000.000200", "thread_ts": "1709290000.000200", "user": "U1",
+		 "text": "Why does <https://github.com/acme/app/blob/feature/x/cmd/app/main.go#L3-L5|main> print 1?"},
+		{"type": "message", "ts": "1709290100.000300", "thread_ts": "1709290000.000200", "user": "U2", "text": "<@U1> it's a placeholder"},
+		{"type": "message", "ts": "1709295000.000400", "user": "U2", "text": "lunch?"}
+	]`)},
+	"core/2024-03-02.json": {Data: []byte(`[
+		{"type": "message", "ts": "1709380000.000100", "user": "U2", "text": "see main.go:4 &amp; tell me"}
+	]`)},
+}
+
+func TestImporter_Slack(t *testing.T) {
+	importer, manager := newTestImporter(t)
+
+	threads, err := ReadSlack(slackExport)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	if len(threads) != 3 {
+		t.Fatalf("Expected 3 threads, got %d", len(threads))
+	}
+	if got := threads[0].Messages[1].Text; got != "@Alice it's a placeholder" {
+		t.Errorf("Expected mentions to be named, got %q", got)
+	}
+
+	report, err := importer.Import(gocontext.Background(), threads)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if report.Imported != 2 || report.Messages != 3 || len(report.Skipped) != 1 {
+		t.Fatalf("Expected two threads imported and the lunch thread skipped, got %+v", report)
+	}
+
+	conversations := manager.Snapshot()
+	if len(conversations) != 2 {
+		t.Fatalf("Expected 2 conversations, got %d", len(conversations))
+	}
+	linked := conversations[0]
+	if linked.AnchorAddress.Fragment != "lines:3-5" || linked.Visibility != context.VisibilityPublic {
+		t.Errorf("Expected the permalinked lines to anchor a public thread, got %q %s", linked.AnchorAddress.Fragment, linked.Visibility)
+	}
+	if linked.Messages[0].AuthorID != "slack:Alice" || !linked.CreatedAt.Equal(time.Unix(1709290000, 200000)) {
+		t.Errorf("Expected authorship and timestamps to be kept, got %s at %s", linked.Messages[0].AuthorID, linked.CreatedAt)
+	}
+	if !strings.Contains(linked.Title, "Why does main") {
+		t.Errorf("Unexpected title %q", linked.Title)
+	}
+
+	mentioned := conversations[1]
+	if mentioned.Visibility != context.VisibilityParticipants || mentioned.AnchorAddress.Fragment != "lines:4-4" {
+		t.Errorf("Expected the private mention to anchor line 4 for participants, got %q %s", mentioned.AnchorAddress.Fragment, mentioned.Visibility)
+	}
+	if mentioned.Messages[0].Content != "see main.go:4 & tell me" {
+		t.Errorf("Expected entities to be unescaped, got %q", mentioned.Messages[0].Content)
+	}
+
+	// A later export adds only the new reply
+	later := fstest.MapFS{}
+	for name, file := range slackExport {
+		later[name] = file
+	}
+	later["dev/2024-03-02.json"] = &fstest.MapFile{Data: []byte(`[
+		{"type": "message", "ts": "1709370000.000100", "thread_ts": "1709290000.000200", "user": "U1", "text": "Thanks"}
+	]`)}
+	threads, err = ReadSlack(later)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	report, err = importer.Import(gocontext.Background(), threads)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if report.Imported != 0 || report.Updated != 1 || report.Messages != 1 {
+		t.Fatalf("Expected one thread updated with one message, got %+v", report)
+	}
+	updated, err := manager.GetConversation(linked.ID)
+	if err != nil {
+		t.Fatalf("Failed to get conversation: %v", err)
+	}
+	if len(updated.Messages) != 3 || updated.AnchorAddress.Fragment != "lines:3-5" {
+		t.Errorf("Expected the reply appended to the anchored thread, got %d messages", len(updated.Messages))
+	}
+}
+
+func TestImporter_Discord(t *testing.T) {
+	importer, manager := newTestImporter(t, WithUnanchored())
+
+	export := `{
+		"channel": {"id": "100", "type": "GuildTextChat", "name": "general"},
+		"messages": [
+			{"id": "1", "type": "Default", "timestamp": "2024-03-01T10:00:00+01:00", "content": "Crash on startup",
+			 "author": {"name": "carol"}, "attachments": [{"fileName": "main.go"}]},
+			{"id": "2", "type": "Default", "timestamp": "2024-03-01T10:05:00+01:00", "content": "unrelated", "author": {"name": "dave"}},
+			{"id": "3", "type": "Reply", "timestamp": "2024-03-01T10:10:00+01:00", "content": "fixed", "author": {"name": "dave", "nickname": "Dave"},
+			 "reference": {"messageId": "1"}},
+			{"id": "4", "type": "Reply", "timestamp": "2024-03-01T10:15:00+01:00", "content": "thanks", "author": {"name": "carol"},
+			 "reference": {"messageId": "3"}},
+			{"id": "5", "type": "ChannelPinnedMessage", "timestamp": "2024-03-01T10:20:00+01:00", "author": {"name": "carol"}}
+		]
+	}`
+	threads, err := ReadDiscord(fstest.MapFS{"general.json": {Data: []byte(export)}})
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	if len(threads) != 2 || len(threads[0].Messages) != 3 {
+		t.Fatalf("Expected replies grouped under the first message, got %+v", threads)
+	}
+
+	report, err := importer.Import(gocontext.Background(), threads)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if report.Imported != 2 || len(report.Skipped) != 0 {
+		t.Fatalf("Expected both threads imported, got %+v", report)
+	}
+
+	thread, err := manager.GetConversation(context.ThreadID("thread_discord_" + digest(SourceDiscord, "general", "1")))
+	if err != nil {
+		t.Fatalf("Failed to get conversation: %v", err)
+	}
+	if thread.AnchorAddress.OperationID == "" || thread.AnchorAddress.Fragment != "lines:1-5" {
+		t.Errorf("Expected the attachment to anchor the whole document, got %+v", thread.AnchorAddress)
+	}
+	if len(thread.Participants) != 2 || thread.Participants[1] != "discord:Dave" {
+		t.Errorf("Expected carol and Dave as participants, got %v", thread.Participants)
+	}
+	if !thread.Messages[0].Timestamp.Equal(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the original timestamp, got %s", thread.Messages[0].Timestamp)
+	}
+}
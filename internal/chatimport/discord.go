@@ -0,0 +1,134 @@
+package chatimport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+// discordExport is a channel exported by DiscordChatExporter as JSON
+type discordExport struct {
+	Channel struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Name string `json:"name"`
+	} `json:"channel"`
+	Messages []discordMessage `json:"messages"`
+}
+
+type discordMessage struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Content   string    `json:"content"`
+	Author    struct {
+		Name     string `json:"name"`
+		Nickname string `json:"nickname"`
+	} `json:"author"`
+	Attachments []struct {
+		FileName string `json:"fileName"`
+	} `json:"attachments"`
+	Reference *struct {
+		MessageID string `json:"messageId"`
+	} `json:"reference"`
+}
+
+// ReadDiscord parses every channel exported as JSON by DiscordChatExporter
+// under fsys
+func ReadDiscord(fsys fs.FS) ([]Thread, error) {
+	var threads []Thread
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			return err
+		}
+
+		file, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		channelThreads, err := ReadDiscordFile(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		threads = append(threads, channelThreads...)
+		return nil
+	})
+	return threads, err
+}
+
+// ReadDiscordFile parses one exported channel. A Discord thread becomes one
+// thread; other channels are split into threads along reply chains, with
+// messages that aren't replies starting a thread of their own.
+func ReadDiscordFile(r io.Reader) ([]Thread, error) {
+	var export discordExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	channelType := export.Channel.Type
+	isThread := strings.HasSuffix(channelType, "Thread")
+	private := strings.HasPrefix(channelType, "Direct") || channelType == "GuildPrivateThread"
+
+	parents := make(map[string]string, len(export.Messages))
+	for _, msg := range export.Messages {
+		if msg.Reference != nil {
+			parents[msg.ID] = msg.Reference.MessageID
+		}
+	}
+	// rootOf follows replies back to the message that started the chain
+	rootOf := func(id string) string {
+		for hops := 0; hops < len(parents); hops++ {
+			parent, ok := parents[id]
+			if !ok {
+				break
+			}
+			id = parent
+		}
+		return id
+	}
+
+	byRoot := make(map[string]*Thread)
+	var order []string
+	for _, msg := range export.Messages {
+		if msg.Type != "" && msg.Type != "Default" && msg.Type != "Reply" {
+			continue
+		}
+
+		root := export.Channel.ID
+		if !isThread {
+			root = rootOf(msg.ID)
+		}
+		thread, exists := byRoot[root]
+		if !exists {
+			thread = &Thread{Source: SourceDiscord, Channel: export.Channel.Name, ID: root, Private: private}
+			byRoot[root] = thread
+			order = append(order, root)
+		}
+
+		message := Message{
+			ID:        msg.ID,
+			Author:    firstNonEmpty(msg.Author.Nickname, msg.Author.Name),
+			Text:      msg.Content,
+			Timestamp: msg.Timestamp.UTC(),
+		}
+		for _, attachment := range msg.Attachments {
+			message.Files = append(message.Files, attachment.FileName)
+		}
+		thread.Messages = append(thread.Messages, message)
+	}
+
+	threads := make([]Thread, 0, len(order))
+	for _, root := range order {
+		thread := byRoot[root]
+		sort.SliceStable(thread.Messages, func(i, j int) bool {
+			return thread.Messages[i].Timestamp.Before(thread.Messages[j].Timestamp)
+		})
+		threads = append(threads, *thread)
+	}
+	return threads, nil
+}
@@ -0,0 +1,8 @@
+package chatimport
+
+import "errors"
+
+var (
+	ErrUnknownSource = errors.New("unknown chat export source")
+	ErrInvalidExport = errors.New("invalid chat export")
+)
@@ -0,0 +1,117 @@
+package chatimport
+
+import (
+	gocontext "context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+)
+
+var (
+	// permalinkPattern matches GitHub and GitLab blob links, capturing the
+	// ref and path together since branch names may contain slashes
+	permalinkPattern = regexp.MustCompile(`https?://[^\s<>|()]+?/(?:-/)?blob/([^\s#<>|()?]+)(?:\?[^\s#<>|()]*)?(?:#L(\d+)(?:-L?(\d+))?)?`)
+	urlPattern       = regexp.MustCompile(`https?://[^\s<>|()]+`)
+	// filePattern matches paths with an extension, optionally followed by
+	// :line or :first-last
+	filePattern = regexp.MustCompile(`(?:^|[\s(\x60'"])((?:[\w.-]+/)*[\w-][\w.-]*\.[A-Za-z0-9]+)(?::(\d+)(?:-(\d+))?)?`)
+)
+
+type reference struct {
+	path        string
+	first, last int
+}
+
+// references resolves the files a message links to, mentions or attaches to
+// addresses, skipping any that aren't tracked
+func (i *Importer) references(ctx gocontext.Context, msg Message) []addressing.StableAddress {
+	var refs []reference
+	for _, match := range permalinkPattern.FindAllStringSubmatch(msg.Text, -1) {
+		// Try dropping leading segments until what's left is a tracked path
+		segments := strings.Split(match[1], "/")
+		for n := 1; n < len(segments); n++ {
+			if path := i.trackedPath(strings.Join(segments[n:], "/"), true); path != "" {
+				refs = append(refs, newReference(path, match[2], match[3]))
+				break
+			}
+		}
+	}
+
+	text := urlPattern.ReplaceAllString(msg.Text, " ")
+	for _, match := range filePattern.FindAllStringSubmatch(text, -1) {
+		if path := i.trackedPath(match[1], false); path != "" {
+			refs = append(refs, newReference(path, match[2], match[3]))
+		}
+	}
+	for _, file := range msg.Files {
+		if path := i.trackedPath(file, false); path != "" {
+			refs = append(refs, reference{path: path})
+		}
+	}
+
+	var addrs []addressing.StableAddress
+	seen := make(map[reference]bool)
+	for _, ref := range refs {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		if addr, ok := i.anchor(ctx, ref); ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func newReference(path, first, last string) reference {
+	ref := reference{path: path}
+	ref.first, _ = strconv.Atoi(first)
+	ref.last, _ = strconv.Atoi(last)
+	if ref.last < ref.first {
+		ref.last = ref.first
+	}
+	return ref
+}
+
+// trackedPath finds the document name refers to. Short names match a
+// document whose path ends with them when only one does, unless exact is set.
+func (i *Importer) trackedPath(name string, exact bool) string {
+	name = strings.TrimPrefix(name, "./")
+	var match string
+	for _, path := range i.documents {
+		if path == name {
+			return path
+		}
+		if !exact && strings.HasSuffix(path, "/"+name) {
+			if match != "" {
+				return ""
+			}
+			match = path
+		}
+	}
+	return match
+}
+
+// anchor addresses the referenced lines, or the whole document when no lines
+// were given or they no longer exist
+func (i *Importer) anchor(ctx gocontext.Context, ref reference) (addressing.StableAddress, bool) {
+	doc, err := i.store.GetDocument(ctx, ref.path)
+	if err != nil {
+		return addressing.StableAddress{}, false
+	}
+
+	if ref.first > 0 {
+		if addr, err := i.resolver.AddressForLines(i.repository, doc, ref.first, ref.last); err == nil {
+			return addr, true
+		}
+	}
+
+	spans := doc.LineSpans()
+	if len(spans) == 0 {
+		return addressing.StableAddress{}, false
+	}
+	addr, err := i.resolver.AddressForLines(i.repository, doc, 1, spans[len(spans)-1].LastLine)
+	return addr, err == nil
+}
@@ -0,0 +1,237 @@
+package chatimport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type slackUser struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Profile struct {
+		DisplayName string `json:"display_name"`
+		RealName    string `json:"real_name"`
+	} `json:"profile"`
+}
+
+type slackChannel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type slackMessage struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	TS          string `json:"ts"`
+	ThreadTS    string `json:"thread_ts"`
+	User        string `json:"user"`
+	Username    string `json:"username"`
+	Text        string `json:"text"`
+	UserProfile struct {
+		DisplayName string `json:"display_name"`
+		Name        string `json:"name"`
+	} `json:"user_profile"`
+	Files []struct {
+		Name string `json:"name"`
+	} `json:"files"`
+}
+
+// slackSkippedSubtypes are channel housekeeping messages, not discussion
+var slackSkippedSubtypes = map[string]bool{
+	"channel_join": true, "channel_leave": true, "channel_topic": true,
+	"channel_purpose": true, "channel_name": true, "channel_archive": true,
+	"channel_unarchive": true, "group_join": true, "group_leave": true,
+	"group_topic": true, "group_purpose": true, "group_name": true,
+	"pinned_item": true, "tombstone": true,
+}
+
+var (
+	slackMentionPattern = regexp.MustCompile(`<@([A-Z0-9]+)(?:\|([^>]*))?>`)
+	slackLinkPattern    = regexp.MustCompile(`<([^@>][^>|]*)(?:\|([^>]*))?>`)
+)
+
+// ReadSlack parses a Slack workspace export, as unzipped or as the zip file
+// itself. Each channel's messages are grouped into threads by thread_ts;
+// messages outside a thread are threads of their own.
+func ReadSlack(fsys fs.FS) ([]Thread, error) {
+	var users []slackUser
+	if err := readSlackJSON(fsys, "users.json", &users); err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(users))
+	for _, user := range users {
+		names[user.ID] = firstNonEmpty(user.Profile.DisplayName, user.Name, user.Profile.RealName)
+	}
+
+	type channel struct {
+		dir, name string
+		private   bool
+	}
+	var channels []channel
+	for _, file := range []string{"channels.json", "groups.json", "mpims.json", "dms.json"} {
+		var listed []slackChannel
+		if err := readSlackJSON(fsys, file, &listed); err != nil {
+			return nil, err
+		}
+		for _, c := range listed {
+			// Direct message folders are named by ID
+			dir := firstNonEmpty(c.Name, c.ID)
+			channels = append(channels, channel{dir: dir, name: dir, private: file != "channels.json"})
+		}
+	}
+	if len(channels) == 0 {
+		entries, err := fs.ReadDir(fsys, ".")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				channels = append(channels, channel{dir: entry.Name(), name: entry.Name()})
+			}
+		}
+	}
+
+	var threads []Thread
+	for _, c := range channels {
+		channelThreads, err := readSlackChannel(fsys, c.dir, names)
+		if err != nil {
+			return nil, err
+		}
+		for i := range channelThreads {
+			channelThreads[i].Channel = c.name
+			channelThreads[i].Private = c.private
+		}
+		threads = append(threads, channelThreads...)
+	}
+	return threads, nil
+}
+
+func readSlackChannel(fsys fs.FS, dir string, names map[string]string) ([]Thread, error) {
+	days, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(days)
+
+	byTS := make(map[string]*Thread)
+	var order []string
+	for _, day := range days {
+		var messages []slackMessage
+		if err := readSlackJSON(fsys, day, &messages); err != nil {
+			return nil, err
+		}
+
+		for _, msg := range messages {
+			if msg.Type != "message" || slackSkippedSubtypes[msg.Subtype] {
+				continue
+			}
+			timestamp, err := slackTime(msg.TS)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidExport, day, err)
+			}
+
+			root := firstNonEmpty(msg.ThreadTS, msg.TS)
+			thread, exists := byTS[root]
+			if !exists {
+				thread = &Thread{Source: SourceSlack, ID: root}
+				byTS[root] = thread
+				order = append(order, root)
+			}
+
+			message := Message{
+				ID:        msg.TS,
+				Author:    firstNonEmpty(names[msg.User], msg.UserProfile.DisplayName, msg.UserProfile.Name, msg.Username, msg.User),
+				Text:      slackText(msg.Text, names),
+				Timestamp: timestamp,
+			}
+			for _, file := range msg.Files {
+				message.Files = append(message.Files, file.Name)
+			}
+			thread.Messages = append(thread.Messages, message)
+		}
+	}
+
+	threads := make([]Thread, 0, len(order))
+	for _, root := range order {
+		thread := byTS[root]
+		sort.SliceStable(thread.Messages, func(i, j int) bool {
+			return thread.Messages[i].Timestamp.Before(thread.Messages[j].Timestamp)
+		})
+		threads = append(threads, *thread)
+	}
+	return threads, nil
+}
+
+// readSlackJSON decodes the file at name into v, leaving v alone when the
+// export has no such file
+func readSlackJSON(fsys fs.FS, name string, v any) error {
+	data, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidExport, name, err)
+	}
+	return nil
+}
+
+// slackTime parses a message timestamp, seconds and microseconds since the
+// epoch separated by a dot
+func slackTime(ts string) (time.Time, error) {
+	secs, micros, _ := strings.Cut(ts, ".")
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", ts)
+	}
+	var usec int64
+	if micros != "" {
+		if usec, err = strconv.ParseInt(micros, 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", ts)
+		}
+	}
+	return time.Unix(sec, usec*int64(time.Microsecond)).UTC(), nil
+}
+
+// slackText turns Slack's markup back into plain text, naming mentioned users
+// and keeping link targets so permalinks can be resolved
+func slackText(text string, names map[string]string) string {
+	text = slackMentionPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := slackMentionPattern.FindStringSubmatch(match)
+		return "@" + firstNonEmpty(names[groups[1]], groups[2], groups[1])
+	})
+	text = slackLinkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := slackLinkPattern.FindStringSubmatch(match)
+		target, label := groups[1], groups[2]
+		switch {
+		case strings.HasPrefix(target, "#"):
+			return "#" + firstNonEmpty(label, strings.TrimPrefix(target, "#"))
+		case strings.HasPrefix(target, "!"):
+			return "@" + firstNonEmpty(label, strings.TrimPrefix(target, "!"))
+		case label == "" || label == target:
+			return target
+		default:
+			return label + " (" + target + ")"
+		}
+	})
+	return html.UnescapeString(text)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
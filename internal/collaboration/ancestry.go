@@ -0,0 +1,185 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"sort"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// historyBatchSize is how many parents are read from the store at once while
+// loading a causal history
+const historyBatchSize = 500
+
+// IsAncestor reports whether ancestor is in descendant's causal history. An
+// operation counts as its own ancestor.
+func (ce *CollaborationEngine) IsAncestor(ctx gocontext.Context, ancestor, descendant operations.OperationID) (bool, error) {
+	dag, err := ce.loadHistory(ctx, ancestor, descendant)
+	if err != nil {
+		return false, err
+	}
+	return dag.IsAncestor(ancestor, descendant)
+}
+
+// MergeBase returns the best common ancestors of two operations, where a
+// three-way merge of their histories starts from
+func (ce *CollaborationEngine) MergeBase(ctx gocontext.Context, a, b operations.OperationID) ([]*operations.Operation, error) {
+	dag, err := ce.loadHistory(ctx, a, b)
+	if err != nil {
+		return nil, err
+	}
+	return dag.MergeBase(a, b)
+}
+
+// CausalOrder returns a document's operations with each after its parents,
+// as OperationDAG.TopoSort orders them
+func (ce *CollaborationEngine) CausalOrder(ctx gocontext.Context, documentID string) ([]*operations.Operation, error) {
+	heads, err := ce.DocumentHeads(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	dag, err := ce.loadHistory(ctx, heads...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Histories can run through other documents
+	ordered := make([]*operations.Operation, 0)
+	for _, op := range dag.TopoSort() {
+		if op.Metadata.DocumentID == documentID {
+			ordered = append(ordered, op)
+		}
+	}
+	return ordered, nil
+}
+
+// resolveParents checks that op's parents were applied before it. The
+// engine's DAG only holds what it applied since it started, so parents it
+// lacks are looked up in the store, or among those retention purged from
+// it, and declared to it as external. Parents found in neither are a
+// causality violation.
+func (ce *CollaborationEngine) resolveParents(ctx gocontext.Context, op *operations.Operation) error {
+	var unknown []operations.OperationID
+	for _, parent := range op.Parents {
+		if !ce.operationDAG.Contains(parent) {
+			unknown = append(unknown, parent)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	stored, err := ce.store.GetOperations(ctx, unknown)
+	if err != nil {
+		return fmt.Errorf("failed to look up parents: %w", err)
+	}
+	found := make(map[operations.OperationID]bool, len(stored))
+	for _, parent := range stored {
+		found[parent.ID] = true
+	}
+	var missing []operations.OperationID
+	for _, parent := range unknown {
+		if !found[parent] {
+			missing = append(missing, parent)
+		}
+	}
+	if len(missing) > 0 {
+		purged, err := ce.store.PurgedOperations(ctx, missing)
+		if err != nil {
+			return fmt.Errorf("failed to look up parents: %w", err)
+		}
+		for _, parent := range purged {
+			found[parent] = true
+		}
+		for _, parent := range missing {
+			if !found[parent] {
+				return fmt.Errorf("%w: parent %s has not been applied", operations.ErrCausalityViolation, parent)
+			}
+		}
+	}
+	ce.operationDAG.AddExternal(unknown...)
+	return nil
+}
+
+// stampClock gives op its place in clock order. An operation made here is
+// stamped now; one that already carries a clock, as those replicated from
+// another node do, keeps it and moves this node's clock past it.
+func (ce *CollaborationEngine) stampClock(op *operations.Operation) error {
+	if op.Clock != 0 {
+		return ce.clock.Observe(op.Clock)
+	}
+	op.Clock = ce.clock.Now()
+	return nil
+}
+
+// loadHistory reads the causal histories of the given operations from the
+// store into a DAG. The engine's own DAG only holds what it applied since it
+// started, so it can't answer for older history. Parents that are no longer
+// stored, such as those removed by retention, end the history there.
+func (ce *CollaborationEngine) loadHistory(ctx gocontext.Context, ids ...operations.OperationID) (*operations.OperationDAG, error) {
+	loaded := make(map[operations.OperationID]*operations.Operation)
+	for _, id := range ids {
+		if _, seen := loaded[id]; seen {
+			continue
+		}
+		op, err := ce.store.GetOperation(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		loaded[id] = op
+	}
+
+	pending := make([]operations.OperationID, 0)
+	for _, op := range loaded {
+		pending = append(pending, op.Parents...)
+	}
+	requested := make(map[operations.OperationID]bool)
+	for len(pending) > 0 {
+		var batch []operations.OperationID
+		for len(pending) > 0 && len(batch) < historyBatchSize {
+			id := pending[0]
+			pending = pending[1:]
+			if _, seen := loaded[id]; !seen && !requested[id] {
+				requested[id] = true
+				batch = append(batch, id)
+			}
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		ops, err := ce.store.GetOperations(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, op := range ops {
+			loaded[op.ID] = op
+			pending = append(pending, op.Parents...)
+		}
+	}
+
+	// Oldest first, so parents are usually added before their children
+	history := make([]*operations.Operation, 0, len(loaded))
+	for _, op := range loaded {
+		history = append(history, op)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Precedes(history[j])
+	})
+
+	dag := operations.NewOperationDAG()
+	for _, op := range history {
+		for _, parent := range op.Parents {
+			if _, stored := loaded[parent]; !stored {
+				dag.AddExternal(parent)
+			}
+		}
+	}
+	for _, op := range history {
+		if err := dag.AddOperation(op); err != nil {
+			return nil, err
+		}
+	}
+	return dag, nil
+}
@@ -0,0 +1,62 @@
+package collaboration
+
+import (
+	gocontext "context"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+// AnchoredConversation is a conversation with the 1-based lines of a document
+// its anchor currently covers
+type AnchoredConversation struct {
+	Thread    *context.ConversationThread `json:"thread"`
+	FirstLine int                         `json:"first_line"`
+	LastLine  int                         `json:"last_line"`
+}
+
+// ConversationsInDocument finds the unarchived conversations anchored in doc,
+// in creation order. Conversations whose anchor no longer resolves to any
+// line are left out.
+func (ce *CollaborationEngine) ConversationsInDocument(ctx gocontext.Context, doc *positioning.Document) []AnchoredConversation {
+	var anchored []AnchoredConversation
+	documentOf := make(map[operations.OperationID]string)
+
+	for _, thread := range ce.conversationManager.Snapshot() {
+		addr := thread.AnchorAddress
+		if thread.Status == context.StatusArchived || !addr.IsValid() || addr.OperationID == "" {
+			continue
+		}
+
+		// Positions are ordered across documents, so only the anchor's operation says which one it is in
+		documentID, seen := documentOf[addr.OperationID]
+		if !seen {
+			if op, err := ce.store.GetOperation(ctx, addr.OperationID); err == nil {
+				documentID = op.Metadata.DocumentID
+			}
+			documentOf[addr.OperationID] = documentID
+		}
+		if documentID != doc.FilePath {
+			continue
+		}
+
+		first, last, err := ce.anchorLines(addr, doc)
+		if err != nil {
+			continue
+		}
+		anchored = append(anchored, AnchoredConversation{Thread: thread, FirstLine: first, LastLine: last})
+	}
+
+	return anchored
+}
+
+// anchorLines finds the lines of doc an address currently covers
+func (ce *CollaborationEngine) anchorLines(addr addressing.StableAddress, doc *positioning.Document) (int, int, error) {
+	posRange := addr.PositionRange
+	if resolved, err := ce.addressResolver.ResolveAddress(addr); err == nil {
+		posRange = resolved.CurrentRange
+	}
+	return addr.LinesIn(doc, posRange)
+}
@@ -0,0 +1,99 @@
+package collaboration
+
+import (
+	"sync"
+	"time"
+)
+
+const slowClientReason = "client too slow"
+
+// Backpressure is how far a client has fallen behind reading what it is sent.
+// Presence is dropped first, once the send buffer is three quarters full;
+// anything else is dropped only when it is full, at which point the client is
+// saturated.
+type Backpressure struct {
+	Queued          int        `json:"queued"`
+	Capacity        int        `json:"capacity"`
+	DroppedPresence uint64     `json:"dropped_presence"`
+	DroppedMessages uint64     `json:"dropped_messages"`
+	SaturatedSince  *time.Time `json:"saturated_since,omitempty"`
+}
+
+// clientPressure totals the backpressure of the connected clients
+type clientPressure struct {
+	clients         int
+	saturated       int
+	droppedMessages uint64
+	droppedPresence uint64
+}
+
+func (ce *CollaborationEngine) clientBackpressure() clientPressure {
+	ce.mutex.RLock()
+	defer ce.mutex.RUnlock()
+
+	total := clientPressure{clients: len(ce.clients)}
+	for _, client := range ce.clients {
+		pressure := client.Backpressure()
+		if pressure.SaturatedSince != nil {
+			total.saturated++
+		}
+		total.droppedMessages += pressure.DroppedMessages
+		total.droppedPresence += pressure.DroppedPresence
+	}
+	return total
+}
+
+type pressureGauge struct {
+	droppedPresence uint64
+	droppedMessages uint64
+	saturatedSince  time.Time
+	mutex           sync.Mutex
+}
+
+// highWaterMark is how many queued messages start presence being dropped
+func highWaterMark(capacity int) int {
+	return capacity * 3 / 4
+}
+
+func (g *pressureGauge) dropPresence() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.droppedPresence++
+}
+
+// relieve records that a message was queued, so the client is keeping up
+func (g *pressureGauge) relieve() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.saturatedSince = time.Time{}
+}
+
+// saturate records a message dropped on a full buffer and reports whether the
+// buffer has been full for longer than timeout
+func (g *pressureGauge) saturate(now time.Time, timeout time.Duration) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.droppedMessages++
+	if g.saturatedSince.IsZero() {
+		g.saturatedSince = now
+	}
+	return timeout > 0 && now.Sub(g.saturatedSince) >= timeout
+}
+
+func (g *pressureGauge) snapshot(queued, capacity int) Backpressure {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	pressure := Backpressure{
+		Queued:          queued,
+		Capacity:        capacity,
+		DroppedPresence: g.droppedPresence,
+		DroppedMessages: g.droppedMessages,
+	}
+	if !g.saturatedSince.IsZero() {
+		since := g.saturatedSince
+		pressure.SaturatedSince = &since
+	}
+	return pressure
+}
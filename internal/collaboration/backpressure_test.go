@@ -0,0 +1,95 @@
+package collaboration
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestClient(bufferSize int, slowClientTimeout time.Duration) *ClientConnection {
+	return &ClientConnection{
+		ID:        "client-1",
+		AuthorID:  "alice",
+		Documents: make(map[string]bool),
+		LastSeen:  time.Now(),
+		sendChan:  make(chan *Message, bufferSize),
+		closeChan: make(chan struct{}),
+		evicted:   make(chan struct{}),
+		config:    WebSocketConfig{SlowClientTimeout: slowClientTimeout},
+	}
+}
+
+func TestClientConnection_DropsPresenceFirst(t *testing.T) {
+	client := newTestClient(4, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if err := client.SendMessage(&Message{Type: MsgOperation}); err != nil {
+			t.Fatalf("Failed to send operation %d: %v", i, err)
+		}
+	}
+
+	// Three of four queued is past the high water mark for presence only
+	if err := client.SendMessage(&Message{Type: MsgPresence}); !errors.Is(err, ErrSendBufferFull) {
+		t.Fatalf("Expected presence to be dropped, got %v", err)
+	}
+	if err := client.SendMessage(&Message{Type: MsgOperation}); err != nil {
+		t.Fatalf("Failed to send operation into the last slot: %v", err)
+	}
+	if err := client.SendMessage(&Message{Type: MsgOperation}); !errors.Is(err, ErrSendBufferFull) {
+		t.Fatalf("Expected a full buffer, got %v", err)
+	}
+
+	pressure := client.Backpressure()
+	if pressure.Queued != 4 || pressure.Capacity != 4 {
+		t.Errorf("Expected 4 of 4 queued, got %d of %d", pressure.Queued, pressure.Capacity)
+	}
+	if pressure.DroppedPresence != 1 || pressure.DroppedMessages != 1 {
+		t.Errorf("Expected one presence and one message dropped, got %+v", pressure)
+	}
+	if pressure.SaturatedSince == nil {
+		t.Fatal("Expected the client to be saturated")
+	}
+
+	// Catching up clears saturation
+	<-client.sendChan
+	if err := client.SendMessage(&Message{Type: MsgOperation}); err != nil {
+		t.Fatalf("Failed to send after catching up: %v", err)
+	}
+	if client.Backpressure().SaturatedSince != nil {
+		t.Error("Expected saturation to clear once a message was queued")
+	}
+}
+
+func TestClientConnection_EvictsSaturatedClient(t *testing.T) {
+	client := newTestClient(1, 20*time.Millisecond)
+	evictions := 0
+	client.onEvict = func() { evictions++ }
+
+	if err := client.SendMessage(&Message{Type: MsgOperation}); err != nil {
+		t.Fatalf("Failed to send operation: %v", err)
+	}
+	if err := client.SendMessage(&Message{Type: MsgOperation}); !errors.Is(err, ErrSendBufferFull) {
+		t.Fatalf("Expected a full buffer before the timeout, got %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := client.SendMessage(&Message{Type: MsgOperation}); !errors.Is(err, ErrSlowClient) {
+		t.Fatalf("Expected the client to be evicted, got %v", err)
+	}
+	if err := client.SendMessage(&Message{Type: MsgOperation}); !errors.Is(err, ErrSlowClient) {
+		t.Fatalf("Expected an evicted client to refuse messages, got %v", err)
+	}
+	if evictions != 1 {
+		t.Errorf("Expected one eviction, got %d", evictions)
+	}
+}
+
+func TestClientConnection_NoEvictionWithoutTimeout(t *testing.T) {
+	client := newTestClient(1, 0)
+	client.SendMessage(&Message{Type: MsgOperation})
+
+	time.Sleep(5 * time.Millisecond)
+	if err := client.SendMessage(&Message{Type: MsgOperation}); !errors.Is(err, ErrSendBufferFull) {
+		t.Fatalf("Expected a full buffer without eviction, got %v", err)
+	}
+}
@@ -0,0 +1,580 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jeremytregunna/contextdb/internal/events"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// Branches are lines of work apart from main. A branch sees its base's
+// documents as they are now with its own operations applied on top, and
+// operations made on it reach its base only when it is merged.
+
+// MaxBranchNameLength bounds branch names, which also tag operations
+const MaxBranchNameLength = 100
+
+// BranchMerge describes what merging a branch changed
+type BranchMerge struct {
+	Branch *storage.Branch `json:"branch"`
+	// Documents are the documents the branch's operations were applied to,
+	// by path, with their versions on the branch merged into
+	Documents []MergedDocument `json:"documents"`
+}
+
+// MergedDocument is one document a merge changed
+type MergedDocument struct {
+	DocumentID string `json:"document_id"`
+	Version    uint64 `json:"version"`
+	Operations int    `json:"operations"`
+}
+
+// ValidateBranchName checks a name for a new branch
+func ValidateBranchName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: name is empty", ErrInvalidBranch)
+	case operations.IsMainBranch(name):
+		return fmt.Errorf("%w: %s always exists", ErrInvalidBranch, operations.MainBranch)
+	case len(name) > MaxBranchNameLength:
+		return fmt.Errorf("%w: name is longer than %d bytes", ErrInvalidBranch, MaxBranchNameLength)
+	case strings.ContainsFunc(name, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }):
+		return fmt.Errorf("%w: name contains whitespace", ErrInvalidBranch)
+	}
+	return nil
+}
+
+// CreateBranch starts a branch from base, main when base is empty
+func (ce *CollaborationEngine) CreateBranch(ctx gocontext.Context, name, base, description string, createdBy operations.AuthorID) (*storage.Branch, error) {
+	if err := ValidateBranchName(name); err != nil {
+		return nil, err
+	}
+	base = branchName(base)
+	if !operations.IsMainBranch(base) {
+		if _, err := ce.openBranch(ctx, base); err != nil {
+			return nil, err
+		}
+	}
+
+	branch := &storage.Branch{
+		Name:        name,
+		Base:        base,
+		Description: description,
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now(),
+	}
+	if err := ce.store.CreateBranch(ctx, branch); err != nil {
+		return nil, err
+	}
+
+	ce.events.Publish(events.BranchCreated, branch)
+	return branch, nil
+}
+
+func (ce *CollaborationEngine) GetBranch(ctx gocontext.Context, name string) (*storage.Branch, error) {
+	return ce.store.GetBranch(ctx, name)
+}
+
+// ListBranches lists branches by name, with merged ones when includeMerged is set
+func (ce *CollaborationEngine) ListBranches(ctx gocontext.Context, includeMerged bool) ([]*storage.Branch, error) {
+	branches, err := ce.store.ListBranches(ctx)
+	if err != nil || includeMerged {
+		return branches, err
+	}
+
+	open := branches[:0]
+	for _, branch := range branches {
+		if !branch.IsMerged() {
+			open = append(open, branch)
+		}
+	}
+	return open, nil
+}
+
+// BranchDocument returns a document as it is on a branch. Main's documents
+// are the engine's own; other branches' are rendered from their base and
+// their operations, and are copies.
+func (ce *CollaborationEngine) BranchDocument(ctx gocontext.Context, documentID, branch string) (*positioning.Document, error) {
+	if operations.IsMainBranch(branch) {
+		return ce.getOrLoadDocument(ctx, documentID)
+	}
+
+	b, err := ce.store.GetBranch(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
+	return ce.renderBranch(ctx, documentID, b)
+}
+
+// MergeBranch applies a branch's operations to the branch it is merged
+// into, its base when into is empty, and closes it. Merging into main
+// applies them to the main documents, merging into another branch adds them
+// to what that branch renders.
+func (ce *CollaborationEngine) MergeBranch(ctx gocontext.Context, name, into string) (*BranchMerge, error) {
+	if operations.IsMainBranch(name) {
+		return nil, fmt.Errorf("%w: %s can't be merged", ErrInvalidBranch, operations.MainBranch)
+	}
+
+	// Operations on the branch wait until it's merged, then find it closed
+	lock := ce.documentLock(branchLockKey(name))
+	lock.Lock()
+	defer lock.Unlock()
+
+	branch, err := ce.openBranch(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if into == "" {
+		into = branch.Base
+	}
+	into = branchName(into)
+	if into == name {
+		return nil, fmt.Errorf("%w: %s can't be merged into itself", ErrInvalidBranch, name)
+	}
+	if !operations.IsMainBranch(into) {
+		if _, err := ce.openBranch(ctx, into); err != nil {
+			return nil, err
+		}
+	}
+
+	byDocument, err := ce.lineOperations(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	documentIDs := make([]string, 0, len(byDocument))
+	for documentID := range byDocument {
+		documentIDs = append(documentIDs, documentID)
+	}
+	sort.Strings(documentIDs)
+
+	// Try the operations on copies first, so a merge that can't apply
+	// leaves everything as it was
+	if operations.IsMainBranch(into) {
+		for _, documentID := range documentIDs {
+			doc, err := ce.getOrLoadDocument(ctx, documentID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load document: %w", err)
+			}
+			trial, err := positioning.RestoreDocument(doc.Snapshot())
+			if err != nil {
+				return nil, err
+			}
+			for _, op := range byDocument[documentID] {
+				if err := trial.ApplyOperation(op); err != nil {
+					return nil, fmt.Errorf("failed to merge %s into %s: %w", op.ID, documentID, err)
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	if err := ce.store.MergeBranch(ctx, name, into, now); err != nil {
+		return nil, err
+	}
+	branch.MergedInto = into
+	branch.MergedAt = &now
+
+	merge := &BranchMerge{Branch: branch, Documents: make([]MergedDocument, 0, len(documentIDs))}
+	for _, documentID := range documentIDs {
+		ops := byDocument[documentID]
+		var version uint64
+		if operations.IsMainBranch(into) {
+			version, err = ce.mergeIntoMain(ctx, documentID, ops)
+		} else {
+			version, err = ce.mergeIntoBranch(ctx, documentID, into)
+		}
+		if err != nil {
+			return nil, err
+		}
+		merge.Documents = append(merge.Documents, MergedDocument{DocumentID: documentID, Version: version, Operations: len(ops)})
+	}
+
+	ce.events.Publish(events.BranchMerged, merge)
+	return merge, nil
+}
+
+// mergeIntoMain applies a merged branch's operations on a document to the
+// main document, so main's clients see them as they would new operations
+func (ce *CollaborationEngine) mergeIntoMain(ctx gocontext.Context, documentID string, ops []*operations.Operation) (uint64, error) {
+	lock := ce.documentLock(documentID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	doc, err := ce.getOrLoadDocument(ctx, documentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load document: %w", err)
+	}
+	for _, op := range ops {
+		if err := doc.ApplyOperation(op); err != nil {
+			return 0, fmt.Errorf("failed to apply operation to document: %w", err)
+		}
+		ce.addressResolver.ProcessOperation(op)
+	}
+
+	ce.documents.update(documentID, true)
+	if err := ce.store.StoreDocument(ctx, doc); err != nil {
+		return 0, fmt.Errorf("failed to store updated document: %w", err)
+	}
+	ce.documents.update(documentID, false)
+	ce.addressResolver.IndexDocument(doc)
+
+	// Main's heads now take in the branch's
+	ce.mutex.Lock()
+	delete(ce.heads, lineKey(documentID, operations.MainBranch))
+	ce.mutex.Unlock()
+
+	version := doc.CurrentVersion()
+	ce.events.Publish(events.DocumentUpdated, DocumentUpdate{
+		DocumentID:  documentID,
+		Version:     version,
+		OperationID: ops[len(ops)-1].ID,
+	})
+	for _, op := range ops {
+		if err := ce.BroadcastOperation(op, documentID, ""); err != nil {
+			return 0, err
+		}
+	}
+	return version, nil
+}
+
+// mergeIntoBranch brings a document on into up to date with a branch just
+// merged into it, returning its version there
+func (ce *CollaborationEngine) mergeIntoBranch(ctx gocontext.Context, documentID, into string) (uint64, error) {
+	ce.mutex.Lock()
+	delete(ce.heads, lineKey(documentID, into))
+	ce.mutex.Unlock()
+
+	doc, err := ce.BranchDocument(ctx, documentID, into)
+	if err != nil {
+		return 0, err
+	}
+	return doc.CurrentVersion(), nil
+}
+
+// processBranchOperation stores an operation made on a branch other than
+// main and applies it to the branch's rendering of its document, returning
+// the document's version there
+func (ce *CollaborationEngine) processBranchOperation(ctx gocontext.Context, op *operations.Operation, documentID string, expectedVersion *uint64, options processOptions) (uint64, error) {
+	lock := ce.documentLock(branchLockKey(op.Metadata.Branch))
+	lock.Lock()
+	defer lock.Unlock()
+
+	branch, err := ce.openBranch(ctx, op.Metadata.Branch)
+	if err != nil {
+		return 0, err
+	}
+	doc, err := ce.renderBranch(ctx, documentID, branch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load document: %w", err)
+	}
+
+	if expectedVersion != nil {
+		if err := ce.checkVersion(ctx, doc, branch.Name, *expectedVersion); err != nil {
+			return 0, err
+		}
+	}
+	return ce.applyBranchOperation(ctx, doc, branch, documentID, op, options)
+}
+
+// applyBranchOperation applies op to doc, documentID as branch renders it,
+// holding the branch's lock
+func (ce *CollaborationEngine) applyBranchOperation(ctx gocontext.Context, doc *positioning.Document, branch *storage.Branch, documentID string, op *operations.Operation, options processOptions) (uint64, error) {
+	heads, err := ce.documentHeads(ctx, documentID, branch.Name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load document heads: %w", err)
+	}
+	if options.assignParents && len(op.Parents) == 0 && len(heads) > 0 {
+		op.Parents = append([]operations.OperationID(nil), heads...)
+		op.ID = operations.ComputeID(op)
+	}
+
+	if err := ce.resolveParents(ctx, op); err != nil {
+		return 0, err
+	}
+	if err := ce.stampClock(op); err != nil {
+		return 0, fmt.Errorf("invalid operation: %w", err)
+	}
+	// Applied before storing, the rendering is a copy and an operation that
+	// can't apply isn't kept
+	if err := doc.ApplyOperation(op); err != nil {
+		return 0, fmt.Errorf("failed to apply operation to document: %w", err)
+	}
+	if err := ce.operationDAG.AddOperation(op); err != nil {
+		return 0, fmt.Errorf("failed to add operation to DAG: %w", err)
+	}
+
+	if err := ce.store.StoreOperation(ctx, op); err != nil {
+		return 0, fmt.Errorf("failed to store operation: %w", err)
+	}
+	if err := ce.advanceHeads(ctx, documentID, branch.Name, op); err != nil {
+		return 0, fmt.Errorf("failed to update document heads: %w", err)
+	}
+	ce.conversationManager.LinkOperation(op)
+
+	version := doc.CurrentVersion()
+	ce.events.Publish(events.OperationCreated, op)
+	ce.events.Publish(events.DocumentUpdated, DocumentUpdate{
+		DocumentID:  documentID,
+		Branch:      branch.Name,
+		Version:     version,
+		OperationID: op.ID,
+	})
+	return version, nil
+}
+
+// renderBranch builds a document as branch sees it: its base's document
+// with the operations on the branch, and on branches merged into it, applied
+// in timestamp order
+func (ce *CollaborationEngine) renderBranch(ctx gocontext.Context, documentID string, branch *storage.Branch) (*positioning.Document, error) {
+	base, err := ce.BranchDocument(ctx, documentID, branch.Base)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := positioning.RestoreDocument(base.Snapshot())
+	if err != nil {
+		return nil, err
+	}
+
+	line, err := ce.branchLine(ctx, branch.Name, documentID)
+	if err != nil {
+		return nil, err
+	}
+	err = ce.store.ForEachOperationMatching(ctx, storage.OperationFilter{DocumentID: documentID}, func(op *operations.Operation) error {
+		if !line.holds(op) {
+			return nil
+		}
+		return doc.ApplyOperation(op)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// lineOperations returns the operations on a branch and the branches merged
+// into it, by document, in timestamp order
+func (ce *CollaborationEngine) lineOperations(ctx gocontext.Context, branch string) (map[string][]*operations.Operation, error) {
+	graph, err := ce.loadBranchGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	line := graph.line(branch, "")
+	byDocument := make(map[string][]*operations.Operation)
+	for name := range line {
+		err := ce.store.ForEachOperationMatching(ctx, storage.OperationFilter{Branch: name}, func(op *operations.Operation) error {
+			byDocument[op.Metadata.DocumentID] = append(byDocument[op.Metadata.DocumentID], op)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Documents merged on their own can take in more branches
+	for documentID := range graph.documentMerges {
+		documentLine := graph.line(branch, documentID)
+		if len(documentLine) == len(line) {
+			continue
+		}
+		var ops []*operations.Operation
+		err := ce.store.ForEachOperationMatching(ctx, storage.OperationFilter{DocumentID: documentID}, func(op *operations.Operation) error {
+			if documentLine.holds(op) {
+				ops = append(ops, op)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(ops) > 0 {
+			byDocument[documentID] = ops
+		}
+	}
+
+	for _, ops := range byDocument {
+		sort.SliceStable(ops, func(i, j int) bool { return ops[i].Precedes(ops[j]) })
+	}
+	return byDocument, nil
+}
+
+// branchLine names the branches whose operations in a document a branch holds
+func (ce *CollaborationEngine) branchLine(ctx gocontext.Context, branch, documentID string) (branchSet, error) {
+	graph, err := ce.loadBranchGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return graph.line(branch, documentID), nil
+}
+
+// branchView names the branches whose operations a branch's document shows
+func (ce *CollaborationEngine) branchView(ctx gocontext.Context, branch, documentID string) (branchSet, error) {
+	graph, err := ce.loadBranchGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return graph.view(branch, documentID), nil
+}
+
+// branchSet names branches along with how far through their operations each
+// is taken. A zero time takes all of them; a branch whose document was merged
+// on its own is taken through the latest operation merged.
+type branchSet map[string]time.Time
+
+// holds reports whether op is among the operations the set takes
+func (s branchSet) holds(op *operations.Operation) bool {
+	through, ok := s[branchName(op.Metadata.Branch)]
+	return ok && (through.IsZero() || !op.Timestamp.After(through))
+}
+
+// add takes a branch through a time, unless the set already takes more of it
+func (s branchSet) add(name string, through time.Time) bool {
+	if current, ok := s[name]; ok && (current.IsZero() || (!through.IsZero() && !through.After(current))) {
+		return false
+	}
+	s[name] = through
+	return true
+}
+
+// branchGraph is how branches were made from and merged into each other,
+// whole or one document at a time
+type branchGraph struct {
+	bases      map[string]string
+	created    map[string]time.Time
+	mergedInto map[string][]string
+	// documentMerges are the merges into each branch, by document
+	documentMerges map[string]map[string][]storage.DocumentMerge
+}
+
+func (ce *CollaborationEngine) loadBranchGraph(ctx gocontext.Context) (*branchGraph, error) {
+	branches, err := ce.store.ListBranches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	merges, err := ce.store.ListDocumentMerges(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &branchGraph{
+		bases:          make(map[string]string, len(branches)),
+		created:        make(map[string]time.Time, len(branches)),
+		mergedInto:     make(map[string][]string),
+		documentMerges: make(map[string]map[string][]storage.DocumentMerge),
+	}
+	for _, b := range branches {
+		graph.bases[b.Name] = branchName(b.Base)
+		graph.created[b.Name] = b.CreatedAt
+		if b.IsMerged() {
+			into := branchName(b.MergedInto)
+			graph.mergedInto[into] = append(graph.mergedInto[into], b.Name)
+		}
+	}
+	for _, merge := range merges {
+		byInto, ok := graph.documentMerges[merge.DocumentID]
+		if !ok {
+			byInto = make(map[string][]storage.DocumentMerge)
+			graph.documentMerges[merge.DocumentID] = byInto
+		}
+		into := branchName(merge.MergedInto)
+		byInto[into] = append(byInto[into], merge)
+	}
+	return graph, nil
+}
+
+// line names the branches whose operations a branch holds: the branch itself
+// and those merged into it, and into those, in turn. Branches whose
+// documentID alone was merged into one of them count up to that merge.
+func (g *branchGraph) line(branch, documentID string) branchSet {
+	type visit struct {
+		name    string
+		through time.Time
+	}
+
+	line := make(branchSet)
+	pending := []visit{{name: branchName(branch)}}
+	for len(pending) > 0 {
+		next := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if !line.add(next.name, next.through) {
+			continue
+		}
+		for _, merged := range g.mergedInto[next.name] {
+			pending = append(pending, visit{merged, next.through})
+		}
+		for _, merge := range g.documentMerges[documentID][next.name] {
+			pending = append(pending, visit{merge.Branch, earliest(next.through, merge.Through)})
+		}
+	}
+	return line
+}
+
+// view names the branches whose operations a branch's documents show: its
+// own line and its base's view
+func (g *branchGraph) view(branch, documentID string) branchSet {
+	view := make(branchSet)
+	for name := branchName(branch); ; {
+		for member, through := range g.line(name, documentID) {
+			view.add(member, through)
+		}
+		if operations.IsMainBranch(name) {
+			return view
+		}
+		// A branch that isn't known follows main
+		base, ok := g.bases[name]
+		if !ok {
+			base = operations.MainBranch
+		}
+		name = base
+	}
+}
+
+// earliest is the earlier of two cutoffs, where a zero time is no cutoff
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// openBranch looks up a branch that can still take operations
+func (ce *CollaborationEngine) openBranch(ctx gocontext.Context, name string) (*storage.Branch, error) {
+	branch, err := ce.store.GetBranch(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if branch.IsMerged() {
+		return nil, fmt.Errorf("%w: %s was merged into %s", storage.ErrBranchMerged, name, branch.MergedInto)
+	}
+	return branch, nil
+}
+
+// branchName names main the same way however an operation spells it
+func branchName(branch string) string {
+	if operations.IsMainBranch(branch) {
+		return operations.MainBranch
+	}
+	return branch
+}
+
+// lineKey keys a document's heads on a branch. Main's are keyed by the
+// document alone.
+func lineKey(documentID, branch string) string {
+	if operations.IsMainBranch(branch) {
+		return documentID
+	}
+	return documentID + "\x00" + branch
+}
+
+// branchLockKey is the document lock that serializes a branch's operations
+// and its merge. It starts with a NUL to stay clear of document paths.
+func branchLockKey(branch string) string {
+	return "\x00branch\x00" + branch
+}
@@ -0,0 +1,211 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestCollaborationEngine_Branches(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+	authorID := operations.AuthorID("test_author")
+
+	insert := func(branch string, value int64) error {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: authorID},
+			}),
+			Content:   "line",
+			Author:    authorID,
+			Timestamp: time.Now(),
+			Metadata:  operations.OperationMeta{DocumentID: "main.go", Branch: branch},
+		}
+		op.ID = operations.ComputeID(op)
+		return engine.ProcessOperation(ctx, op, "client")
+	}
+	constructs := func(branch string) int {
+		doc, err := engine.BranchDocument(ctx, "main.go", branch)
+		if err != nil {
+			t.Fatalf("Failed to get document on %q: %v", branch, err)
+		}
+		return doc.ConstructCount()
+	}
+
+	if err := insert("", 1); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+	if _, err := engine.CreateBranch(ctx, "main", "", "", authorID); !errors.Is(err, ErrInvalidBranch) {
+		t.Errorf("Expected main to be refused as a branch name, got %v", err)
+	}
+	if err := insert("feature", 2); !errors.Is(err, storage.ErrBranchNotFound) {
+		t.Errorf("Expected an operation on a missing branch to be refused, got %v", err)
+	}
+	if _, err := engine.CreateBranch(ctx, "feature", "", "Try something", authorID); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	if _, err := engine.CreateBranch(ctx, "feature", "", "", authorID); !errors.Is(err, storage.ErrBranchExists) {
+		t.Errorf("Expected a second feature branch to be refused, got %v", err)
+	}
+
+	// Branch operations render on the branch only
+	if err := insert("feature", 2); err != nil {
+		t.Fatalf("Failed to process branch operation: %v", err)
+	}
+	if got := constructs(operations.MainBranch); got != 1 {
+		t.Errorf("Expected main to be untouched by the branch, got %d constructs", got)
+	}
+	if got := constructs("feature"); got != 2 {
+		t.Errorf("Expected the branch to show main and its own operation, got %d constructs", got)
+	}
+
+	// A branch off the branch sees both, and merging it back adds its work to feature
+	if _, err := engine.CreateBranch(ctx, "feature-fix", "feature", "", authorID); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	if err := insert("feature-fix", 3); err != nil {
+		t.Fatalf("Failed to process branch operation: %v", err)
+	}
+	if got := constructs("feature-fix"); got != 3 {
+		t.Errorf("Expected the nested branch to show three constructs, got %d", got)
+	}
+	if _, err := engine.MergeBranch(ctx, "feature-fix", ""); err != nil {
+		t.Fatalf("Failed to merge branch: %v", err)
+	}
+	if got := constructs("feature"); got != 3 {
+		t.Errorf("Expected feature to include the merged branch, got %d constructs", got)
+	}
+	if err := insert("feature-fix", 4); !errors.Is(err, storage.ErrBranchMerged) {
+		t.Errorf("Expected an operation on a merged branch to be refused, got %v", err)
+	}
+
+	// Merging into main applies the branch's line to main
+	merge, err := engine.MergeBranch(ctx, "feature", "")
+	if err != nil {
+		t.Fatalf("Failed to merge branch: %v", err)
+	}
+	if merge.Branch.MergedInto != operations.MainBranch || len(merge.Documents) != 1 || merge.Documents[0].Operations != 2 {
+		t.Errorf("Expected two operations merged into main.go on main, got %+v", merge)
+	}
+	if got := constructs(operations.MainBranch); got != 3 {
+		t.Errorf("Expected main to include the merged work, got %d constructs", got)
+	}
+	if _, err := engine.MergeBranch(ctx, "feature", ""); !errors.Is(err, storage.ErrBranchMerged) {
+		t.Errorf("Expected a second merge to be refused, got %v", err)
+	}
+
+	open, err := engine.ListBranches(ctx, false)
+	if err != nil {
+		t.Fatalf("Failed to list branches: %v", err)
+	}
+	all, err := engine.ListBranches(ctx, true)
+	if err != nil {
+		t.Fatalf("Failed to list branches: %v", err)
+	}
+	if len(open) != 0 || len(all) != 2 {
+		t.Errorf("Expected no open branches of two, got %d of %d", len(open), len(all))
+	}
+}
+
+func TestCollaborationEngine_MergeDocument(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+	authorID := operations.AuthorID("test_author")
+
+	insert := func(branch string, value int64, at time.Time) *operations.Operation {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: authorID},
+			}),
+			Content:   "line",
+			Author:    authorID,
+			Timestamp: at,
+			Metadata:  operations.OperationMeta{DocumentID: "main.go", Branch: branch},
+		}
+		op.ID = operations.ComputeID(op)
+		if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+		return op
+	}
+	constructs := func(branch string) int {
+		doc, err := engine.BranchDocument(ctx, "main.go", branch)
+		if err != nil {
+			t.Fatalf("Failed to get document on %q: %v", branch, err)
+		}
+		return doc.ConstructCount()
+	}
+
+	// Operations are stored to the second, so those from before the branch are well before it
+	for _, value := range []int64{10, 30, 40, 50} {
+		insert("", value, time.Now().Add(-time.Hour))
+	}
+	if _, err := engine.CreateBranch(ctx, "feature", "", "", authorID); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	ours := insert("feature", 20, time.Now())
+	theirs := insert("", 21, time.Now())
+	insert("", 60, time.Now())
+
+	// A dry run reports the conflict next to the branch's change and leaves main alone
+	report, err := engine.MergeDocument(ctx, "main.go", "feature", "", true)
+	if err != nil {
+		t.Fatalf("Failed to merge document: %v", err)
+	}
+	if len(report.Applied) != 1 || report.Applied[0] != ours.ID || report.Document.ConstructCount() != 7 {
+		t.Errorf("Expected the branch's operation applied to a copy of main, got %+v", report)
+	}
+	if len(report.Conflicts) != 1 {
+		t.Fatalf("Expected one conflict, got %+v", report.Conflicts)
+	}
+	conflict := report.Conflicts[0]
+	if len(conflict.From) != 1 || conflict.From[0] != ours.ID || len(conflict.To) != 1 || conflict.To[0] != theirs.ID {
+		t.Errorf("Expected the conflict between the adjacent inserts, got %+v", conflict)
+	}
+	if got := constructs(operations.MainBranch); got != 6 {
+		t.Errorf("Expected a dry run to leave main alone, got %d constructs", got)
+	}
+
+	report, err = engine.MergeDocument(ctx, "main.go", "feature", "", false)
+	if err != nil {
+		t.Fatalf("Failed to merge document: %v", err)
+	}
+	if len(report.Conflicts) != 1 || report.Version != report.Document.CurrentVersion() {
+		t.Errorf("Expected the merge to report the same conflict, got %+v", report)
+	}
+	if got := constructs(operations.MainBranch); got != 7 {
+		t.Errorf("Expected main to include the merged operation, got %d constructs", got)
+	}
+
+	// The branch stays open, and only what it does next is merged next time
+	report, err = engine.MergeDocument(ctx, "main.go", "feature", "", false)
+	if err != nil {
+		t.Fatalf("Failed to merge document: %v", err)
+	}
+	if len(report.Applied) != 0 || len(report.Conflicts) != 0 {
+		t.Errorf("Expected nothing left to merge, got %+v", report)
+	}
+	// A second on, past the operations already merged
+	later := insert("feature", 70, time.Now().Add(time.Second))
+	if got := constructs(operations.MainBranch); got != 7 {
+		t.Errorf("Expected main to leave out the branch's later operation, got %d constructs", got)
+	}
+	report, err = engine.MergeDocument(ctx, "main.go", "feature", "", false)
+	if err != nil {
+		t.Fatalf("Failed to merge document: %v", err)
+	}
+	if len(report.Applied) != 1 || report.Applied[0] != later.ID || constructs("feature") != 8 {
+		t.Errorf("Expected only the later operation merged, got %+v", report)
+	}
+
+	if _, err := engine.MergeDocument(ctx, "main.go", "main", "", false); !errors.Is(err, ErrInvalidBranch) {
+		t.Errorf("Expected merging main into itself to be refused, got %v", err)
+	}
+}
@@ -2,11 +2,27 @@ package collaboration
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
 type MessageBroadcaster struct {
 	channels map[string]chan *Message
-	mutex    sync.RWMutex
+	// dropped counts messages skipped because a client's buffer was full
+	dropped atomic.Int64
+	mutex   sync.RWMutex
+}
+
+// BroadcasterStats describes the clients subscribed to broadcasts. Saturated
+// clients have full buffers and are missing messages until they catch up.
+type BroadcasterStats struct {
+	Clients   int   `json:"clients"`
+	Saturated int   `json:"saturated"`
+	Dropped   int64 `json:"dropped"`
+	// Evicted counts WebSocket clients disconnected for staying saturated
+	Evicted uint64 `json:"evicted"`
+	// RelayErrors counts broadcasts that failed to reach, or arrived garbled
+	// from, other instances on the event bus
+	RelayErrors uint64 `json:"relay_errors"`
 }
 
 func NewMessageBroadcaster() *MessageBroadcaster {
@@ -52,6 +68,7 @@ func (mb *MessageBroadcaster) Broadcast(msg *Message, excludeChannels ...string)
 		case ch <- msg:
 		default:
 			// Channel buffer is full, skip
+			mb.dropped.Add(1)
 		}
 	}
 }
@@ -72,3 +89,16 @@ func (mb *MessageBroadcaster) Send(channelID string, msg *Message) bool {
 		return false
 	}
 }
+
+func (mb *MessageBroadcaster) Stats() BroadcasterStats {
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	stats := BroadcasterStats{Clients: len(mb.channels), Dropped: mb.dropped.Load()}
+	for _, ch := range mb.channels {
+		if cap(ch) > 0 && len(ch) == cap(ch) {
+			stats.Saturated++
+		}
+	}
+	return stats
+}
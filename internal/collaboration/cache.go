@@ -0,0 +1,346 @@
+package collaboration
+
+import (
+	"container/list"
+	gocontext "context"
+	"fmt"
+	"sync"
+
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+const (
+	DefaultCachedDocuments    = 1000
+	DefaultDocumentCacheBytes = 256 << 20
+)
+
+// DocumentCacheConfig bounds the documents the engine keeps in memory. The
+// least recently used are evicted first once either limit is passed. Zero
+// leaves a limit off.
+type DocumentCacheConfig struct {
+	MaxDocuments int
+	// MaxBytes limits the estimated size of the cached documents
+	MaxBytes int64
+}
+
+func DefaultDocumentCacheConfig() DocumentCacheConfig {
+	return DocumentCacheConfig{
+		MaxDocuments: DefaultCachedDocuments,
+		MaxBytes:     DefaultDocumentCacheBytes,
+	}
+}
+
+// DocumentCacheStats describes the document cache since startup
+type DocumentCacheStats struct {
+	Documents int   `json:"documents"`
+	Bytes     int64 `json:"bytes"`
+	// Dirty documents have changes the store doesn't have yet, after a
+	// failed write
+	Dirty     int     `json:"dirty"`
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	HitRate   float64 `json:"hit_rate"`
+	Evictions uint64  `json:"evictions"`
+	// Flushes counts dirty documents written to the store on eviction or
+	// shutdown, and FlushErrors the writes that failed
+	Flushes     uint64 `json:"flushes"`
+	FlushErrors uint64 `json:"flush_errors"`
+}
+
+// documentCache holds documents in least recently used order. A document is
+// only evicted when tryLock gets its lock, so documents in use are kept.
+// Dirty ones are written with store first, outside the cache's own lock so
+// lookups don't wait on the write, and kept if the write fails.
+type documentCache struct {
+	config  DocumentCacheConfig
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	bytes   int64
+	tryLock func(id string) (unlock func(), ok bool)
+	store   func(id string, doc *positioning.Document) error
+	stats   DocumentCacheStats
+	mutex   sync.Mutex
+}
+
+type cachedDocument struct {
+	id    string
+	doc   *positioning.Document
+	size  int64
+	dirty bool
+	// flushing is set while eviction writes the document out
+	flushing bool
+}
+
+func newDocumentCache(config DocumentCacheConfig, tryLock func(string) (func(), bool), store func(string, *positioning.Document) error) *documentCache {
+	return &documentCache{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		tryLock: tryLock,
+		store:   store,
+	}
+}
+
+func (c *documentCache) get(id string) (*positioning.Document, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, exists := c.entries[id]
+	if !exists {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*cachedDocument).doc, true
+}
+
+// add caches doc unless id is cached already, and returns the cached one
+func (c *documentCache) add(id string, doc *positioning.Document) *positioning.Document {
+	c.mutex.Lock()
+	if element, exists := c.entries[id]; exists {
+		c.order.MoveToFront(element)
+		c.mutex.Unlock()
+		return element.Value.(*cachedDocument).doc
+	}
+
+	entry := &cachedDocument{id: id, doc: doc, size: doc.Size()}
+	c.entries[id] = c.order.PushFront(entry)
+	c.bytes += entry.size
+	flushes := c.evict()
+	c.mutex.Unlock()
+
+	c.flush(flushes)
+	return doc
+}
+
+// update records a change to a cached document: its new size, and whether
+// the store is now behind it
+func (c *documentCache) update(id string, dirty bool) {
+	c.mutex.Lock()
+	element, exists := c.entries[id]
+	if !exists {
+		c.mutex.Unlock()
+		return
+	}
+	entry := element.Value.(*cachedDocument)
+	size := entry.doc.Size()
+	c.bytes += size - entry.size
+	entry.size = size
+	entry.dirty = dirty
+	flushes := c.evict()
+	c.mutex.Unlock()
+
+	c.flush(flushes)
+}
+
+// flushed records a write of a dirty document to the store
+func (c *documentCache) flushed(id string, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err != nil {
+		c.stats.FlushErrors++
+		return
+	}
+	c.stats.Flushes++
+	if element, exists := c.entries[id]; exists {
+		element.Value.(*cachedDocument).dirty = false
+	}
+}
+
+func (c *documentCache) remove(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, exists := c.entries[id]; exists {
+		c.bytes -= element.Value.(*cachedDocument).size
+		c.order.Remove(element)
+		delete(c.entries, id)
+	}
+}
+
+// invalidate removes a document that changed elsewhere, unless the store has
+// yet to take what changed here
+func (c *documentCache) invalidate(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, exists := c.entries[id]; exists && !element.Value.(*cachedDocument).dirty {
+		c.bytes -= element.Value.(*cachedDocument).size
+		c.order.Remove(element)
+		delete(c.entries, id)
+	}
+}
+
+func (c *documentCache) setConfig(config DocumentCacheConfig) {
+	c.mutex.Lock()
+	c.config = config
+	flushes := c.evict()
+	c.mutex.Unlock()
+
+	c.flush(flushes)
+}
+
+// evict drops least recently used documents until the cache is within its
+// limits, passing over those in use. Dirty documents are returned for flush
+// to write and drop instead, counted as gone so no more are evicted than
+// needed. The caller holds the lock.
+func (c *documentCache) evict() []*cachedDocument {
+	var flushes []*cachedDocument
+	documents, bytes := c.order.Len(), c.bytes
+	element := c.order.Back()
+	for element != nil && c.overLimit(documents, bytes) {
+		previous := element.Prev()
+		entry := element.Value.(*cachedDocument)
+		switch {
+		case entry.flushing:
+			documents--
+			bytes -= entry.size
+		case entry.dirty:
+			entry.flushing = true
+			flushes = append(flushes, entry)
+			documents--
+			bytes -= entry.size
+		default:
+			if unlock, ok := c.tryLock(entry.id); ok {
+				c.drop(element)
+				documents--
+				bytes -= entry.size
+				unlock()
+			}
+		}
+		element = previous
+	}
+	return flushes
+}
+
+// flush writes out the dirty documents evict picked and drops them, keeping
+// those in use or whose write failed. The caller must not hold the lock.
+func (c *documentCache) flush(entries []*cachedDocument) {
+	for _, entry := range entries {
+		unlock, ok := c.tryLock(entry.id)
+		if !ok {
+			c.mutex.Lock()
+			entry.flushing = false
+			c.mutex.Unlock()
+			continue
+		}
+		c.flushEntry(entry)
+		unlock()
+	}
+}
+
+// flushEntry writes out and drops one document. The caller holds the
+// document's lock, so nothing changes it meanwhile, but it may have been
+// written or removed since evict picked it.
+func (c *documentCache) flushEntry(entry *cachedDocument) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	defer func() { entry.flushing = false }()
+
+	element, exists := c.entries[entry.id]
+	if !exists || element.Value != entry {
+		return
+	}
+	if entry.dirty {
+		c.mutex.Unlock()
+		err := c.store(entry.id, entry.doc)
+		c.mutex.Lock()
+		if err != nil {
+			c.stats.FlushErrors++
+			return
+		}
+		c.stats.Flushes++
+	}
+	c.drop(element)
+}
+
+// drop evicts a cached document. The caller holds the lock.
+func (c *documentCache) drop(element *list.Element) {
+	entry := element.Value.(*cachedDocument)
+	c.bytes -= entry.size
+	c.order.Remove(element)
+	delete(c.entries, entry.id)
+	c.stats.Evictions++
+}
+
+func (c *documentCache) overLimit(documents int, bytes int64) bool {
+	return (c.config.MaxDocuments > 0 && documents > c.config.MaxDocuments) ||
+		(c.config.MaxBytes > 0 && bytes > c.config.MaxBytes)
+}
+
+// dirty lists the documents the store is behind on
+func (c *documentCache) dirty() map[string]*positioning.Document {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	dirty := make(map[string]*positioning.Document)
+	for id, element := range c.entries {
+		if entry := element.Value.(*cachedDocument); entry.dirty {
+			dirty[id] = entry.doc
+		}
+	}
+	return dirty
+}
+
+func (c *documentCache) snapshot() DocumentCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := c.stats
+	stats.Documents = c.order.Len()
+	stats.Bytes = c.bytes
+	for _, element := range c.entries {
+		if element.Value.(*cachedDocument).dirty {
+			stats.Dirty++
+		}
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// SetDocumentCacheConfig changes the cache limits, evicting documents
+// straight away if the cache is now over them
+func (ce *CollaborationEngine) SetDocumentCacheConfig(config DocumentCacheConfig) {
+	ce.documents.setConfig(config)
+}
+
+func (ce *CollaborationEngine) DocumentCacheStats() DocumentCacheStats {
+	return ce.documents.snapshot()
+}
+
+// FlushDocuments writes the cached documents the store is behind on
+func (ce *CollaborationEngine) FlushDocuments(ctx gocontext.Context) error {
+	for id, doc := range ce.documents.dirty() {
+		lock := ce.documentLock(id)
+		lock.Lock()
+		err := ce.store.StoreDocument(ctx, doc)
+		ce.documents.flushed(id, err)
+		lock.Unlock()
+		if err != nil {
+			return fmt.Errorf("failed to flush document %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// tryLockDocument lets the cache evict a document nobody is working on
+func (ce *CollaborationEngine) tryLockDocument(id string) (func(), bool) {
+	lock := ce.documentLock(id)
+	if !lock.TryLock() {
+		return nil, false
+	}
+	return lock.Unlock, true
+}
+
+// storeEvictedDocument writes a dirty document out before the cache evicts it
+func (ce *CollaborationEngine) storeEvictedDocument(id string, doc *positioning.Document) error {
+	if err := ce.store.StoreDocument(gocontext.Background(), doc); err != nil {
+		ce.logger.Error("Failed to flush evicted document", map[string]interface{}{"document_id": id, "error": err.Error()})
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// failingStore fails the next StoreDocument while fail is set
+type failingStore struct {
+	storage.Store
+	fail bool
+}
+
+func (s *failingStore) StoreDocument(ctx gocontext.Context, doc *positioning.Document) error {
+	if s.fail {
+		s.fail = false
+		return errors.New("disk full")
+	}
+	return s.Store.StoreDocument(ctx, doc)
+}
+
+func TestCollaborationEngine_DocumentCache(t *testing.T) {
+	ctx := gocontext.Background()
+	store := &failingStore{Store: setupTestStorage(t)}
+	engine := NewCollaborationEngine(store)
+	engine.SetDocumentCacheConfig(DocumentCacheConfig{MaxDocuments: 2})
+	authorID := operations.AuthorID("test_author")
+
+	insert := func(documentID, content string, value int64) error {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: authorID},
+			}),
+			Content:   content,
+			Author:    authorID,
+			Timestamp: time.Now(),
+			Metadata: operations.OperationMeta{
+				Context: map[string]string{"document_id": documentID},
+			},
+		}
+		op.ID = operations.ComputeID(op)
+		return engine.ProcessOperation(ctx, op, "client")
+	}
+
+	for i, documentID := range []string{"a.go", "b.go", "c.go"} {
+		if err := insert(documentID, "first", int64(i+1)); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+	}
+	stats := engine.DocumentCacheStats()
+	if stats.Documents != 2 || stats.Evictions != 1 || stats.Misses != 3 {
+		t.Fatalf("Expected a.go to be evicted after three misses, got %+v", stats)
+	}
+
+	// An evicted document is loaded back from the store
+	if err := insert("a.go", "second", 10); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+	doc, err := store.GetDocument(ctx, "a.go")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if doc.ConstructCount() != 2 {
+		t.Errorf("Expected a.go to keep its first construct, got %d constructs", doc.ConstructCount())
+	}
+	if err := insert("a.go", "third", 11); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+	if stats = engine.DocumentCacheStats(); stats.Hits != 1 || stats.HitRate != 0.2 {
+		t.Errorf("Expected one hit in five lookups, got %+v", stats)
+	}
+
+	// A document whose write failed is written when it is evicted
+	store.fail = true
+	if err := insert("c.go", "second", 12); err == nil {
+		t.Fatal("Expected the failed write to be returned")
+	}
+	if stats = engine.DocumentCacheStats(); stats.Dirty != 1 {
+		t.Fatalf("Expected c.go to be dirty, got %+v", stats)
+	}
+	for i, documentID := range []string{"d.go", "e.go"} {
+		if err := insert(documentID, "first", int64(20+i)); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+	}
+	stats = engine.DocumentCacheStats()
+	if stats.Dirty != 0 || stats.Flushes != 1 {
+		t.Errorf("Expected c.go to be flushed, got %+v", stats)
+	}
+	doc, err = store.GetDocument(ctx, "c.go")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if doc.ConstructCount() != 2 {
+		t.Errorf("Expected the flushed c.go to have 2 constructs, got %d", doc.ConstructCount())
+	}
+}
+
+func TestDocumentCache_FlushOutsideLock(t *testing.T) {
+	writing, release := make(chan struct{}), make(chan struct{})
+	var stored []string
+	cache := newDocumentCache(DocumentCacheConfig{MaxDocuments: 1},
+		func(string) (func(), bool) { return func() {}, true },
+		func(id string, doc *positioning.Document) error {
+			close(writing)
+			<-release
+			stored = append(stored, id)
+			return nil
+		})
+
+	cache.add("a.go", positioning.NewDocument("a.go"))
+	cache.update("a.go", true)
+	done := make(chan struct{})
+	go func() {
+		cache.add("b.go", positioning.NewDocument("b.go"))
+		close(done)
+	}()
+
+	// Lookups go on while the dirty document is written out
+	<-writing
+	if _, cached := cache.get("b.go"); !cached {
+		t.Error("Expected b.go cached while a.go is written")
+	}
+	if stats := cache.snapshot(); stats.Documents != 2 || stats.Dirty != 1 {
+		t.Errorf("Expected a.go kept until written, got %+v", stats)
+	}
+	close(release)
+	<-done
+
+	stats := cache.snapshot()
+	if len(stored) != 1 || stats.Documents != 1 || stats.Flushes != 1 || stats.Evictions != 1 {
+		t.Errorf("Expected a.go written once and evicted, got %v %+v", stored, stats)
+	}
+	if _, cached := cache.get("a.go"); cached {
+		t.Error("Expected a.go evicted")
+	}
+}
@@ -0,0 +1,121 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// ChangeSetFilter narrows the change sets returned. Since and Until keep
+// those overlapping the period, zero leaves that end open.
+type ChangeSetFilter struct {
+	Author     operations.AuthorID
+	DocumentID string
+	Since      time.Time
+	Until      time.Time
+}
+
+func (f ChangeSetFilter) matches(cs *context.ChangeSet) bool {
+	if f.DocumentID != "" && cs.DocumentID != f.DocumentID {
+		return false
+	}
+	if !f.Since.IsZero() && cs.End.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && cs.Start.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// ChangeSets clusters stored operations into change sets, oldest first.
+// Clustering always starts from an author's first operation, so a change set
+// is the same however the filter cuts the history.
+func (ce *CollaborationEngine) ChangeSets(ctx gocontext.Context, filter ChangeSetFilter) ([]*context.ChangeSet, error) {
+	all, err := ce.clusterChangeSets(ctx, filter.Author)
+	if err != nil {
+		return nil, err
+	}
+
+	changeSets := []*context.ChangeSet{}
+	for _, cs := range all {
+		if filter.matches(cs) {
+			changeSets = append(changeSets, cs)
+		}
+	}
+	return changeSets, nil
+}
+
+func (ce *CollaborationEngine) GetChangeSet(ctx gocontext.Context, id context.ChangeSetID) (*context.ChangeSet, error) {
+	opID, ok := id.FirstOperation()
+	if !ok {
+		return nil, context.ErrChangeSetNotFound
+	}
+	first, err := ce.store.GetOperation(ctx, opID)
+	if err != nil {
+		return nil, context.ErrChangeSetNotFound
+	}
+
+	changeSets, err := ce.clusterChangeSets(ctx, first.Author)
+	if err != nil {
+		return nil, err
+	}
+	for _, cs := range changeSets {
+		if cs.ID == id {
+			return cs, nil
+		}
+	}
+	// The operation is part of a change set that started earlier
+	return nil, context.ErrChangeSetNotFound
+}
+
+// clusterChangeSets clusters the operations of author, or of everyone when
+// author is empty
+func (ce *CollaborationEngine) clusterChangeSets(ctx gocontext.Context, author operations.AuthorID) ([]*context.ChangeSet, error) {
+	clusterer := ce.contextAnalyzer.NewChangeSetClusterer(context.DefaultChangeSetOptions(), ce.positionIndex(ctx))
+	add := func(op *operations.Operation) error {
+		clusterer.Add(op)
+		return nil
+	}
+
+	var err error
+	if author != "" {
+		err = ce.store.ForEachOperationByAuthor(ctx, author, add)
+	} else {
+		err = ce.store.ForEachOperationSince(ctx, time.Time{}, add)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changeSets := clusterer.ChangeSets()
+	statuses := ce.conversationManager.ReviewStatusByChangeSet()
+	for _, cs := range changeSets {
+		cs.ReviewStatus = statuses[cs.ID]
+	}
+	return changeSets, nil
+}
+
+// positionIndex orders the current positions of each document, loading each
+// one from the store the first time it's asked for
+func (ce *CollaborationEngine) positionIndex(ctx gocontext.Context) context.PositionIndex {
+	indexes := make(map[string]map[operations.PositionKey]int)
+	return func(documentID string) map[operations.PositionKey]int {
+		index, seen := indexes[documentID]
+		if seen {
+			return index
+		}
+
+		if doc, err := ce.store.GetDocument(ctx, documentID); err == nil {
+			positions := doc.Positions()
+			index = make(map[operations.PositionKey]int, len(positions))
+			for i, pos := range positions {
+				index[pos.Key()] = i
+			}
+		}
+		indexes[documentID] = index
+		return index
+	}
+}
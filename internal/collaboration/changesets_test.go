@@ -0,0 +1,73 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestCollaborationEngine_ChangeSets(t *testing.T) {
+	ctx := gocontext.Background()
+	store := setupTestStorage(t)
+	engine := NewCollaborationEngine(store)
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	storeOp := func(content string, author operations.AuthorID, at time.Duration) *operations.Operation {
+		op := &operations.Operation{
+			ID:   operations.NewOperationID([]byte(content)),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(int64(at)), AuthorID: author},
+			}),
+			Content:   content,
+			Author:    author,
+			Timestamp: start.Add(at),
+			Metadata:  operations.OperationMeta{Context: map[string]string{"document_id": "main.go"}},
+		}
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+		return op
+	}
+
+	first := storeOp("func retry() {", "alice", 0)
+	second := storeOp("\treturn nil", "alice", time.Minute)
+	storeOp("// later", "alice", 30*time.Minute)
+	storeOp("// bob's", "bob", time.Minute)
+
+	changeSets, err := engine.ChangeSets(ctx, ChangeSetFilter{Author: "alice"})
+	if err != nil {
+		t.Fatalf("Failed to list change sets: %v", err)
+	}
+	if len(changeSets) != 2 || len(changeSets[0].Operations) != 2 || changeSets[0].Operations[1] != second.ID {
+		t.Fatalf("Expected alice's first two operations together, got %+v", changeSets)
+	}
+
+	// Filters keep change sets overlapping the period, whole
+	changeSets, _ = engine.ChangeSets(ctx, ChangeSetFilter{Since: start.Add(30 * time.Second), Until: start.Add(2 * time.Minute)})
+	if len(changeSets) != 2 || len(changeSets[0].Operations) != 2 {
+		t.Errorf("Expected alice's first and bob's change sets, got %+v", changeSets)
+	}
+
+	id := context.ChangeSetID("cs_" + string(first.ID))
+	changeSet, err := engine.GetChangeSet(ctx, id)
+	if err != nil {
+		t.Fatalf("Failed to get change set: %v", err)
+	}
+	if changeSet.ID != id || len(changeSet.Operations) != 2 {
+		t.Errorf("Unexpected change set %+v", changeSet)
+	}
+
+	// The second operation doesn't start a change set
+	if _, err := engine.GetChangeSet(ctx, context.ChangeSetID("cs_"+string(second.ID))); !errors.Is(err, context.ErrChangeSetNotFound) {
+		t.Errorf("Expected ErrChangeSetNotFound, got %v", err)
+	}
+	if _, err := engine.GetChangeSet(ctx, "missing"); !errors.Is(err, context.ErrChangeSetNotFound) {
+		t.Errorf("Expected ErrChangeSetNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// ProcessSplitInsert applies a text insert whose content is over size bytes
+// as a run of inserts of at most size bytes each, marked with
+// operations.Chunk. The first takes op's position and parents; each later
+// one goes after the one before, before whatever followed op's position,
+// with the one before as its parent. An insert that fits is processed as it
+// is. It returns the document's version after the last chunk with the
+// chunks applied.
+//
+// The chunks are all applied under one hold of the document's lock, so no
+// other write comes between them. expectedVersion is checked once, before
+// the first, and every chunk is validated before any is applied.
+func (ce *CollaborationEngine) ProcessSplitInsert(ctx gocontext.Context, op *operations.Operation, fromClient ClientID, expectedVersion *uint64, size int, opts ...ProcessOption) (uint64, []*operations.Operation, error) {
+	pieces := operations.SplitContent(op.Content, size)
+	if len(pieces) == 1 {
+		version, err := ce.ProcessOperationAt(ctx, op, fromClient, expectedVersion, opts...)
+		if err != nil {
+			return 0, nil, err
+		}
+		return version, []*operations.Operation{op}, nil
+	}
+
+	var options processOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if !ce.beginOperation() {
+		return 0, nil, ErrEngineShutdown
+	}
+	defer ce.inflight.Done()
+	if ce.readOnly {
+		return 0, nil, storage.ErrReadOnly
+	}
+
+	documentID, err := ce.validateTarget(op)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Chunks on a branch are applied to the branch's rendering of the
+	// document, under the branch's lock
+	var branch *storage.Branch
+	lockKey, branchName := documentID, operations.MainBranch
+	if !operations.IsMainBranch(op.Metadata.Branch) {
+		lockKey = branchLockKey(op.Metadata.Branch)
+	}
+	lock := ce.documentLock(lockKey)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var doc *positioning.Document
+	if lockKey == documentID {
+		doc, err = ce.getOrLoadDocument(ctx, documentID)
+	} else if branch, err = ce.openBranch(ctx, op.Metadata.Branch); err == nil {
+		branchName = branch.Name
+		doc, err = ce.renderBranch(ctx, documentID, branch)
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to load document: %w", err)
+	}
+	if expectedVersion != nil {
+		if err := ce.checkVersion(ctx, doc, branchName, *expectedVersion); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	// The first chunk is named in all of them, so its parents are settled
+	// before its ID is
+	parents := op.Parents
+	if options.assignParents && len(parents) == 0 {
+		heads, err := ce.documentHeads(ctx, documentID, branchName)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to load document heads: %w", err)
+		}
+		parents = slices.Clone(heads)
+	}
+
+	next := positionAfter(doc.Positions(), op.Position)
+	chunks := make([]*operations.Operation, 0, len(pieces))
+	position := op.Position
+	var first operations.OperationID
+	for i, piece := range pieces {
+		chunk := *op
+		chunk.Content = piece
+		chunk.Position = position
+		chunk.Parents = parents
+		chunk.Metadata.Context = maps.Clone(op.Metadata.Context)
+		chunk.ID = operations.ComputeID(&chunk)
+		if i == 0 {
+			first = chunk.ID
+		}
+		chunk.Metadata.Chunk = &operations.Chunk{Of: first, Index: i, Count: len(pieces)}
+		if err := ce.operationDAG.ValidateOperation(&chunk); err != nil {
+			return 0, nil, fmt.Errorf("invalid chunk %d of %d: %w", i+1, len(pieces), err)
+		}
+
+		chunks = append(chunks, &chunk)
+		parents = []operations.OperationID{chunk.ID}
+		position = operations.GeneratePosition(chunk.Position, next, op.Author)
+	}
+
+	var version uint64
+	for i, chunk := range chunks {
+		if branch == nil {
+			version, err = ce.applyOperation(ctx, doc, documentID, chunk, fromClient, processOptions{})
+		} else {
+			version, err = ce.applyBranchOperation(ctx, doc, branch, documentID, chunk, processOptions{})
+		}
+		if err != nil {
+			return 0, chunks[:i], fmt.Errorf("failed to apply chunk %d of %d: %w", i+1, len(pieces), err)
+		}
+	}
+	return version, chunks, nil
+}
+
+// positionAfter returns the first of the ordered positions after pos, or
+// the zero position for the end of the document
+func positionAfter(positions []operations.LogootPosition, pos operations.LogootPosition) operations.LogootPosition {
+	i := sort.Search(len(positions), func(i int) bool { return positions[i].Compare(pos) > 0 })
+	if i == len(positions) {
+		return operations.LogootPosition{}
+	}
+	return positions[i]
+}
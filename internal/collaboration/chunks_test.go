@@ -0,0 +1,93 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestCollaborationEngine_SplitInsertConcurrentWrite(t *testing.T) {
+	ctx := gocontext.Background()
+	store := setupTestStorage(t)
+	engine := NewCollaborationEngine(store)
+	authorID := operations.AuthorID("test_author")
+
+	newInsert := func(content string, value int64) *operations.Operation {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: authorID},
+			}),
+			Content:   content,
+			Author:    authorID,
+			Timestamp: time.Now(),
+			Metadata:  operations.OperationMeta{DocumentID: "test.go"},
+		}
+		op.ID = operations.ComputeID(op)
+		return op
+	}
+
+	// Another client keeps writing ahead of the insert until it's done
+	var done atomic.Bool
+	started := make(chan struct{})
+	writes := make(chan error, 1)
+	go func() {
+		var err error
+		for i := int64(1); !done.Load(); i++ {
+			if err = engine.ProcessOperation(ctx, newInsert(fmt.Sprintf("// %d\n", i), i), "other"); err != nil {
+				break
+			}
+			if i == 1 {
+				close(started)
+			}
+		}
+		writes <- err
+	}()
+	<-started
+
+	var content strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&content, "line %03d\n", i)
+	}
+	version, chunks, err := engine.ProcessSplitInsert(ctx, newInsert(content.String(), 1_000_000), "client", nil, 20)
+	done.Store(true)
+	if err != nil {
+		t.Fatalf("Failed to process split insert alongside another write: %v", err)
+	}
+	if err := <-writes; err != nil {
+		t.Fatalf("Failed to process concurrent write: %v", err)
+	}
+	if len(chunks) != 100 || version == 0 {
+		t.Fatalf("Expected 100 chunks applied, got %d at version %d", len(chunks), version)
+	}
+
+	// No other write came between the chunks
+	doc, err := engine.GetDocumentState(ctx, "test.go")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	rendered, err := doc.Render()
+	if err != nil {
+		t.Fatalf("Failed to render document: %v", err)
+	}
+	if !strings.HasSuffix(rendered, content.String()) {
+		t.Errorf("Expected the insert's content whole at the end of the document, got %q", rendered[max(0, len(rendered)-200):])
+	}
+
+	// An expected version is only checked before the first chunk
+	stale := version - 1
+	if _, _, err := engine.ProcessSplitInsert(ctx, newInsert(content.String(), 2_000_000), "client", &stale, 20); err == nil {
+		t.Error("Expected a stale version to be refused")
+	}
+	current := doc.CurrentVersion()
+	version, chunks, err = engine.ProcessSplitInsert(ctx, newInsert(content.String(), 2_000_000), "client", &current, 20)
+	if err != nil || len(chunks) != 100 || version != current+100 {
+		t.Errorf("Expected 100 chunks applied after the current version, got %d at %d: %v", len(chunks), version, err)
+	}
+}
@@ -0,0 +1,229 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// Churn ranks at most MaxChurnResults documents and ranges. Positions within
+// ChurnRangeGap lines of each other count as one range.
+const (
+	DefaultChurnResults = 10
+	MaxChurnResults     = 100
+	ChurnRangeGap       = 3
+)
+
+type ChurnSort string
+
+const (
+	ChurnByOperations  ChurnSort = "operations"
+	ChurnByAuthors     ChurnSort = "authors"
+	ChurnByDeleteRatio ChurnSort = "delete_ratio"
+)
+
+func (s ChurnSort) IsValid() bool {
+	switch s {
+	case ChurnByOperations, ChurnByAuthors, ChurnByDeleteRatio:
+		return true
+	}
+	return false
+}
+
+// ChurnQuery picks the window churn is counted over and how it's ranked. Zero
+// times leave the window open.
+type ChurnQuery struct {
+	Since time.Time
+	Until time.Time
+	Sort  ChurnSort
+	Limit int
+}
+
+// Churn is how much a document, or a range of its lines, changed. DeleteRatio
+// is deletions per insertion, or the deletions when nothing was inserted.
+type Churn struct {
+	Operations  int     `json:"operations"`
+	Inserts     int     `json:"inserts"`
+	Deletes     int     `json:"deletes"`
+	Authors     int     `json:"authors"`
+	DeleteRatio float64 `json:"delete_ratio"`
+}
+
+type DocumentChurn struct {
+	DocumentID string `json:"document_id"`
+	Churn
+}
+
+// RangeChurn is churn within the lines of a document as it is now. Deleted
+// text counts toward the line that took its place.
+type RangeChurn struct {
+	DocumentID string `json:"document_id"`
+	FirstLine  int    `json:"first_line"`
+	LastLine   int    `json:"last_line"`
+	Churn
+}
+
+type ChurnReport struct {
+	Since     time.Time       `json:"since"`
+	Until     time.Time       `json:"until"`
+	Sort      ChurnSort       `json:"sort"`
+	Documents []DocumentChurn `json:"documents"`
+	Ranges    []RangeChurn    `json:"ranges"`
+}
+
+func newChurn(ops, inserts, deletes, authors int) Churn {
+	return Churn{
+		Operations:  ops,
+		Inserts:     inserts,
+		Deletes:     deletes,
+		Authors:     authors,
+		DeleteRatio: float64(deletes) / float64(max(inserts, 1)),
+	}
+}
+
+// Churn ranks the documents with the most churn in a window from the rollups
+// kept as operations are written, then the ranges of lines within the top
+// documents
+func (ce *CollaborationEngine) Churn(ctx gocontext.Context, query ChurnQuery) (*ChurnReport, error) {
+	if !query.Sort.IsValid() {
+		query.Sort = ChurnByOperations
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultChurnResults
+	}
+	query.Limit = min(query.Limit, MaxChurnResults)
+	if query.Until.IsZero() {
+		query.Until = time.Now()
+	}
+
+	documents, err := ce.store.DocumentChurn(ctx, query.Since, query.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ChurnReport{
+		Since:     query.Since,
+		Until:     query.Until,
+		Sort:      query.Sort,
+		Documents: make([]DocumentChurn, len(documents)),
+		Ranges:    []RangeChurn{},
+	}
+	for i, d := range documents {
+		report.Documents[i] = DocumentChurn{DocumentID: d.DocumentID, Churn: newChurn(d.Operations, d.Inserts, d.Deletes, d.Authors)}
+	}
+	sort.SliceStable(report.Documents, func(i, j int) bool {
+		return query.Sort.less(report.Documents[i].Churn, report.Documents[j].Churn)
+	})
+	if len(report.Documents) > query.Limit {
+		report.Documents = report.Documents[:query.Limit]
+	}
+
+	for _, d := range report.Documents {
+		ranges, err := ce.rangeChurn(ctx, d.DocumentID, query)
+		if err != nil {
+			return nil, err
+		}
+		report.Ranges = append(report.Ranges, ranges...)
+	}
+	sort.SliceStable(report.Ranges, func(i, j int) bool {
+		return query.Sort.less(report.Ranges[i].Churn, report.Ranges[j].Churn)
+	})
+	if len(report.Ranges) > query.Limit {
+		report.Ranges = report.Ranges[:query.Limit]
+	}
+
+	return report, nil
+}
+
+// less orders churn by the sort, most first, falling back on operations
+func (s ChurnSort) less(a, b Churn) bool {
+	switch s {
+	case ChurnByAuthors:
+		if a.Authors != b.Authors {
+			return a.Authors > b.Authors
+		}
+	case ChurnByDeleteRatio:
+		if a.DeleteRatio != b.DeleteRatio {
+			return a.DeleteRatio > b.DeleteRatio
+		}
+	}
+	return a.Operations > b.Operations
+}
+
+// rangeChurn places the churn at each position of a document on the line it
+// is at now and groups nearby lines into ranges. Documents no longer stored
+// have no ranges.
+func (ce *CollaborationEngine) rangeChurn(ctx gocontext.Context, documentID string, query ChurnQuery) ([]RangeChurn, error) {
+	positions, err := ce.store.PositionChurn(ctx, documentID, query.Since, query.Until)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := ce.store.GetDocument(ctx, documentID)
+	if errors.Is(err, storage.ErrDocumentNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	spans := doc.LineSpans()
+	if len(spans) == 0 {
+		return nil, nil
+	}
+
+	type placed struct {
+		first, last int
+		churn       storage.PositionChurn
+	}
+	var lines []placed
+	for _, p := range positions {
+		first, last := lineAt(spans, p.Position)
+		lines = append(lines, placed{first: first, last: last, churn: p})
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].first < lines[j].first })
+
+	var ranges []RangeChurn
+	var authors map[operations.AuthorID]bool
+	var ops, inserts, deletes int
+	flush := func() {
+		if n := len(ranges); n > 0 {
+			ranges[n-1].Churn = newChurn(ops, inserts, deletes, len(authors))
+		}
+	}
+	for _, line := range lines {
+		if n := len(ranges); n == 0 || line.first > ranges[n-1].LastLine+ChurnRangeGap {
+			flush()
+			ranges = append(ranges, RangeChurn{DocumentID: documentID, FirstLine: line.first, LastLine: line.last})
+			authors = make(map[operations.AuthorID]bool)
+			ops, inserts, deletes = 0, 0, 0
+		}
+		r := &ranges[len(ranges)-1]
+		r.LastLine = max(r.LastLine, line.last)
+		ops += line.churn.Operations
+		inserts += line.churn.Inserts
+		deletes += line.churn.Deletes
+		for _, author := range line.churn.Authors {
+			authors[author] = true
+		}
+	}
+	flush()
+	return ranges, nil
+}
+
+// lineAt finds the lines of the construct at pos, or for a position that's
+// gone, the line of the construct now after it
+func lineAt(spans []positioning.LineSpan, pos operations.LogootPosition) (int, int) {
+	i := sort.Search(len(spans), func(i int) bool { return spans[i].Position.Compare(pos) >= 0 })
+	if i == len(spans) {
+		last := spans[len(spans)-1].LastLine
+		return last, last
+	}
+	if spans[i].Position.Compare(pos) == 0 {
+		return spans[i].FirstLine, spans[i].LastLine
+	}
+	return spans[i].FirstLine, spans[i].FirstLine
+}
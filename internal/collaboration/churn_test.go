@@ -0,0 +1,76 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestCollaborationEngine_Churn(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+
+	process := func(opType operations.OperationType, author operations.AuthorID, document string, value int64) {
+		content := fmt.Sprintf("line %d by %s\n", value, author)
+		op := &operations.Operation{
+			ID:   operations.NewOperationID([]byte(string(opType) + content + document)),
+			Type: opType,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: "alice"},
+			}),
+			Content:   content,
+			Author:    author,
+			Timestamp: time.Now(),
+			Metadata:  operations.OperationMeta{Context: map[string]string{"document_id": document}},
+		}
+		if opType == operations.OpDelete {
+			op.Content, op.Length = "", 1
+		}
+		if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+	}
+
+	// main.go has twenty lines, with bob rewriting the last two
+	for i := int64(1); i <= 20; i++ {
+		process(operations.OpInsert, "alice", "main.go", i*10)
+	}
+	for _, value := range []int64{190, 200} {
+		process(operations.OpDelete, "bob", "main.go", value)
+		process(operations.OpInsert, "bob", "main.go", value+1)
+	}
+	process(operations.OpInsert, "carol", "util.go", 10)
+	process(operations.OpDelete, "carol", "util.go", 10)
+
+	report, err := engine.Churn(ctx, ChurnQuery{})
+	if err != nil {
+		t.Fatalf("Failed to get churn: %v", err)
+	}
+	if len(report.Documents) != 2 || report.Documents[0].DocumentID != "main.go" || report.Documents[0].Operations != 24 || report.Documents[0].Authors != 2 {
+		t.Fatalf("Expected main.go first with 24 operations by 2 authors, got %+v", report.Documents)
+	}
+	if len(report.Ranges) != 1 || report.Ranges[0].FirstLine != 1 || report.Ranges[0].LastLine != 20 {
+		t.Errorf("Expected main.go's edits to make one range, got %+v", report.Ranges)
+	}
+
+	report, err = engine.Churn(ctx, ChurnQuery{Sort: ChurnByDeleteRatio})
+	if err != nil {
+		t.Fatalf("Failed to get churn by delete ratio: %v", err)
+	}
+	if report.Documents[0].DocumentID != "util.go" || report.Documents[0].DeleteRatio != 1 {
+		t.Errorf("Expected util.go first with a delete ratio of 1, got %+v", report.Documents)
+	}
+
+	// Only bob's rewrite is in a window after alice's edits
+	report, err = engine.Churn(ctx, ChurnQuery{Since: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to get churn for a later window: %v", err)
+	}
+	if len(report.Documents) != 0 || len(report.Ranges) != 0 {
+		t.Errorf("Expected no churn in a later window, got %+v", report)
+	}
+}
@@ -1,8 +1,10 @@
 package collaboration
 
 import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
@@ -22,43 +24,42 @@ type ClientConnection struct {
 	Presence  PresencePayload     `json:"presence"`
 	sendChan  chan *Message       `json:"-"`
 	closeChan chan struct{}       `json:"-"`
+	draining  bool                `json:"-"`
+	reason    string              `json:"-"`
+	handler   func(*Message)      `json:"-"`
+	onClose   func()              `json:"-"`
+	onEvict   func()              `json:"-"`
 	logger    *logging.Logger     `json:"-"`
-	mutex     sync.RWMutex        `json:"-"`
+	config    WebSocketConfig     `json:"-"`
+	pressure  pressureGauge       `json:"-"`
+	evicted   chan struct{}       `json:"-"`
+	evictOnce sync.Once           `json:"-"`
+	// resumeToken names the session for resuming it once the connection drops
+	resumeToken string `json:"-"`
+	// unsent holds what was sent after the connection closed, for a resumed
+	// session to be sent instead
+	unsent      []*Message   `json:"-"`
+	unsentMutex sync.Mutex   `json:"-"`
+	mutex       sync.RWMutex `json:"-"`
+	// unwritten is the message the write pump was writing when the
+	// connection closed, which a resumed session is sent first. written is
+	// closed once the write pump stops.
+	unwritten *Message      `json:"-"`
+	written   chan struct{} `json:"-"`
 }
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		origin := r.Header.Get("Origin")
-		if origin == "" {
-			return true // Same origin requests don't have Origin header
-		}
-
-		// Allow localhost and same-origin requests for development
-		allowed := []string{
-			"http://localhost",
-			"https://localhost",
-			"http://127.0.0.1",
-			"https://127.0.0.1",
-		}
-
-		for _, allowedOrigin := range allowed {
-			if strings.HasPrefix(origin, allowedOrigin) {
-				return true
-			}
-		}
-
-		// In production, this should be configured via environment variables
-		return false
-	},
-}
-
-func NewClientConnection(clientID ClientID, authorID operations.AuthorID, w http.ResponseWriter, r *http.Request) (*ClientConnection, error) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+// NewClientConnection upgrades the request to a WebSocket set up as config says
+func NewClientConnection(clientID ClientID, authorID operations.AuthorID, w http.ResponseWriter, r *http.Request, config WebSocketConfig) (*ClientConnection, error) {
+	conn, err := config.upgrader().Upgrade(w, r, nil)
 	if err != nil {
 		return nil, err
 	}
+	if config.MaxMessageSize > 0 {
+		conn.SetReadLimit(config.MaxMessageSize)
+	}
+	if config.SendBufferSize <= 0 {
+		config.SendBufferSize = DefaultSendBufferSize
+	}
 
 	client := &ClientConnection{
 		ID:        clientID,
@@ -66,9 +67,11 @@ func NewClientConnection(clientID ClientID, authorID operations.AuthorID, w http
 		WebSocket: conn,
 		Documents: make(map[string]bool),
 		LastSeen:  time.Now(),
-		sendChan:  make(chan *Message, 256),
+		sendChan:  make(chan *Message, config.SendBufferSize),
 		closeChan: make(chan struct{}),
 		logger:    logging.NewLogger("websocket"),
+		config:    config,
+		evicted:   make(chan struct{}),
 	}
 
 	client.Presence = PresencePayload{
@@ -81,6 +84,7 @@ func NewClientConnection(clientID ClientID, authorID operations.AuthorID, w http
 }
 
 func (c *ClientConnection) Start() {
+	c.written = make(chan struct{})
 	go c.writePump()
 	go c.readPump()
 }
@@ -94,7 +98,9 @@ func (c *ClientConnection) Close() error {
 		return nil // Already closed
 	default:
 		close(c.closeChan)
-		close(c.sendChan)
+		if !c.draining {
+			close(c.sendChan)
+		}
 		if c.WebSocket != nil {
 			return c.WebSocket.Close()
 		}
@@ -102,18 +108,166 @@ func (c *ClientConnection) Close() error {
 	}
 }
 
+// Drain stops accepting messages and lets the write pump flush those already
+// queued, followed by a close frame carrying reason. It returns once the
+// connection is closed, or closes it outright when ctx ends first.
+func (c *ClientConnection) Drain(ctx gocontext.Context, reason string) error {
+	c.mutex.Lock()
+	select {
+	case <-c.closeChan:
+		c.mutex.Unlock()
+		return nil
+	default:
+	}
+	if !c.draining {
+		c.draining = true
+		c.reason = reason
+		close(c.sendChan)
+	}
+	c.mutex.Unlock()
+
+	// Nothing runs a write pump without a socket, so there is nothing to flush to
+	if c.WebSocket == nil {
+		return c.Close()
+	}
+
+	select {
+	case <-c.closeChan:
+		return nil
+	case <-ctx.Done():
+		c.Close()
+		return ctx.Err()
+	}
+}
+
+// SendMessage queues msg for the client without waiting for it to be read. A
+// client falling behind loses presence updates first; one whose buffer stays
+// full for longer than its SlowClientTimeout is evicted.
 func (c *ClientConnection) SendMessage(msg *Message) error {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
+	if c.draining {
+		return ErrConnectionClosed
+	}
 	select {
-	case c.sendChan <- msg:
-		return nil
 	case <-c.closeChan:
+		c.keepUnsent(msg)
 		return ErrConnectionClosed
+	case <-c.evicted:
+		return ErrSlowClient
 	default:
+	}
+
+	// Each presence update replaces the last, so they are the cheapest to lose
+	if msg.Type == MsgPresence && len(c.sendChan) >= highWaterMark(cap(c.sendChan)) {
+		c.pressure.dropPresence()
 		return ErrSendBufferFull
 	}
+
+	select {
+	case c.sendChan <- msg:
+		c.pressure.relieve()
+		return nil
+	default:
+	}
+
+	if c.pressure.saturate(time.Now(), c.config.SlowClientTimeout) {
+		c.evict()
+		return ErrSlowClient
+	}
+	return ErrSendBufferFull
+}
+
+// keepUnsent holds msg for a session that may be resumed, as many messages as
+// the send buffer would
+func (c *ClientConnection) keepUnsent(msg *Message) {
+	if c.resumeToken == "" {
+		return
+	}
+	c.unsentMutex.Lock()
+	defer c.unsentMutex.Unlock()
+	if len(c.unsent) <= cap(c.sendChan) {
+		c.unsent = append(c.unsent, msg)
+	}
+}
+
+// park collects a closed connection's subscriptions and the messages it was
+// never sent, for its session to resume with until expires. It reports false
+// when the connection isn't closed or left more than a send buffer holds.
+func (c *ClientConnection) park(expires time.Time) (*parkedSession, bool) {
+	c.mutex.RLock()
+	session := &parkedSession{
+		authorID:  c.AuthorID,
+		documents: c.getDocumentList(),
+		// Room is left for the session message ahead of the backlog
+		limit:   cap(c.sendChan) - 1,
+		expires: expires,
+	}
+	draining := c.draining
+	c.mutex.RUnlock()
+
+	select {
+	case <-c.closeChan:
+	default:
+		return nil, false
+	}
+	if draining {
+		return nil, false
+	}
+
+	// The write pump may still be failing to write a message
+	if c.written != nil {
+		<-c.written
+	}
+	c.unsentMutex.Lock()
+	defer c.unsentMutex.Unlock()
+	if c.unwritten != nil && !session.queue(c.unwritten) {
+		return nil, false
+	}
+	// Close has closed the send buffer too, so this stops at its end
+	for msg := range c.sendChan {
+		if !session.queue(msg) {
+			return nil, false
+		}
+	}
+	for _, msg := range c.unsent {
+		if !session.queue(msg) {
+			return nil, false
+		}
+	}
+	return session, true
+}
+
+func (c *ClientConnection) wasEvicted() bool {
+	if c.evicted == nil {
+		return false
+	}
+	select {
+	case <-c.evicted:
+		return true
+	default:
+		return false
+	}
+}
+
+// evict has the write pump tell the client why and disconnect it, without
+// waiting for the messages it hasn't read
+func (c *ClientConnection) evict() {
+	if c.evicted == nil {
+		return
+	}
+	c.evictOnce.Do(func() {
+		close(c.evicted)
+		if c.onEvict != nil {
+			c.onEvict()
+		}
+	})
+}
+
+// Backpressure reports how far the client has fallen behind
+func (c *ClientConnection) Backpressure() Backpressure {
+	return c.pressure.snapshot(len(c.sendChan), cap(c.sendChan))
 }
 
 func (c *ClientConnection) SubscribeToDocument(documentID string) {
@@ -152,12 +306,19 @@ func (c *ClientConnection) UpdatePresence(presence PresencePayload) {
 func (c *ClientConnection) readPump() {
 	defer func() {
 		c.Close()
+		if c.onClose != nil {
+			c.onClose()
+		}
 	}()
 
-	c.WebSocket.SetReadDeadline(time.Now().Add(60 * time.Second))
+	// Anything the client sends, pongs included, shows it is still there
+	timeout := 2 * c.heartbeatInterval()
+	c.WebSocket.SetReadDeadline(time.Now().Add(timeout))
 	c.WebSocket.SetPongHandler(func(string) error {
-		c.WebSocket.SetReadDeadline(time.Now().Add(60 * time.Second))
-		return nil
+		c.mutex.Lock()
+		c.LastSeen = time.Now()
+		c.mutex.Unlock()
+		return c.WebSocket.SetReadDeadline(time.Now().Add(timeout))
 	})
 
 	for {
@@ -167,10 +328,9 @@ func (c *ClientConnection) readPump() {
 		default:
 		}
 
-		var msg Message
-		err := c.WebSocket.ReadJSON(&msg)
+		msg, err := c.readMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				c.logger.LogWebSocketError(string(c.ID), err)
 			}
 			return
@@ -179,29 +339,85 @@ func (c *ClientConnection) readPump() {
 		c.mutex.Lock()
 		c.LastSeen = time.Now()
 		c.mutex.Unlock()
+		c.WebSocket.SetReadDeadline(time.Now().Add(timeout))
+
+		if c.handler != nil {
+			c.handler(msg)
+		}
+	}
+}
 
-		// Process message through collaboration engine
-		// This will be handled by the engine when we implement it
+func (c *ClientConnection) heartbeatInterval() time.Duration {
+	if c.config.HeartbeatInterval > 0 {
+		return c.config.HeartbeatInterval
 	}
+	return DefaultHeartbeatInterval
+}
+
+// readMessage reads the next message, keeping numbers in its payload as
+// written. Positions are integers too large for a float64, and a signed
+// operation must decode to exactly what was signed.
+func (c *ClientConnection) readMessage() (*Message, error) {
+	_, r, err := c.WebSocket.NextReader()
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var msg Message
+	if err := decoder.Decode(&msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
 }
 
 func (c *ClientConnection) writePump() {
-	ticker := time.NewTicker(54 * time.Second)
+	ticker := time.NewTicker(c.heartbeatInterval())
 	defer func() {
 		ticker.Stop()
 		c.Close()
+		close(c.written)
 	}()
 
 	for {
+		// An evicted client isn't reading, so what's queued would only delay it
 		select {
+		case <-c.evicted:
+			c.writeEviction()
+			return
+		default:
+		}
+
+		select {
+		case <-c.evicted:
+			c.writeEviction()
+			return
+
 		case msg, ok := <-c.sendChan:
 			c.WebSocket.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if !ok {
-				c.WebSocket.WriteMessage(websocket.CloseMessage, []byte{})
+				c.mutex.RLock()
+				reason := c.reason
+				c.mutex.RUnlock()
+
+				code := websocket.CloseNormalClosure
+				if reason != "" {
+					code = websocket.CloseGoingAway
+				}
+				c.WebSocket.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
 				return
 			}
 
 			if err := c.WebSocket.WriteJSON(msg); err != nil {
+				c.unsentMutex.Lock()
+				c.unwritten = msg
+				c.unsentMutex.Unlock()
+				// Writes fail once the connection is closing, which the
+				// read pump has heard about
+				if errors.Is(err, websocket.ErrCloseSent) {
+					return
+				}
 				c.logger.WithFields(map[string]interface{}{
 					"client_id": string(c.ID),
 					"error":     err.Error(),
@@ -221,16 +437,45 @@ func (c *ClientConnection) writePump() {
 	}
 }
 
+// writeEviction sends an evicted client an error saying why, then closes the
+// connection asking it to reconnect later and sync what it missed
+func (c *ClientConnection) writeEviction() {
+	pressure := c.Backpressure()
+	c.logger.Warn("Evicting slow client", map[string]interface{}{
+		"client_id":        string(c.ID),
+		"dropped_messages": pressure.DroppedMessages,
+		"dropped_presence": pressure.DroppedPresence,
+	})
+
+	c.WebSocket.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	c.WebSocket.WriteJSON(&Message{
+		Type: MsgError,
+		Payload: ErrorPayload{
+			Code:    ErrorCodeSlowClient,
+			Message: ErrSlowClient.Error(),
+			Details: map[string]interface{}{
+				"dropped_messages": pressure.DroppedMessages,
+				"dropped_presence": pressure.DroppedPresence,
+			},
+		},
+		MessageID: generateMessageID(),
+		Timestamp: time.Now(),
+	})
+	c.WebSocket.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseTryAgainLater, slowClientReason))
+}
+
 func (c *ClientConnection) GetInfo() ClientInfo {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
 	return ClientInfo{
-		ID:        c.ID,
-		AuthorID:  c.AuthorID,
-		Documents: c.getDocumentList(),
-		LastSeen:  c.LastSeen,
-		Presence:  c.Presence,
+		ID:           c.ID,
+		AuthorID:     c.AuthorID,
+		Documents:    c.getDocumentList(),
+		LastSeen:     c.LastSeen,
+		Presence:     c.Presence,
+		Backpressure: c.Backpressure(),
 	}
 }
 
@@ -243,9 +488,10 @@ func (c *ClientConnection) getDocumentList() []string {
 }
 
 type ClientInfo struct {
-	ID        ClientID            `json:"id"`
-	AuthorID  operations.AuthorID `json:"author_id"`
-	Documents []string            `json:"documents"`
-	LastSeen  time.Time           `json:"last_seen"`
-	Presence  PresencePayload     `json:"presence"`
+	ID           ClientID            `json:"id"`
+	AuthorID     operations.AuthorID `json:"author_id"`
+	Documents    []string            `json:"documents"`
+	LastSeen     time.Time           `json:"last_seen"`
+	Presence     PresencePayload     `json:"presence"`
+	Backpressure Backpressure        `json:"backpressure"`
 }
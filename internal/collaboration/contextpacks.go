@@ -0,0 +1,476 @@
+package collaboration
+
+import (
+	"bytes"
+	gocontext "context"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const (
+	// DefaultContextPackTemplate renders packs as Markdown
+	DefaultContextPackTemplate = "markdown"
+	// DefaultContextPackBudget is the tokens a pack is fitted to when its
+	// query doesn't say
+	DefaultContextPackBudget = 4000
+	// maxPackHistory bounds the operations a pack lists before fitting it
+	maxPackHistory = 100
+)
+
+// ContextPackConfig sets how context packs are rendered. Templates are
+// text/template sources by name, added to the built-in markdown and xml
+// templates or replacing them.
+type ContextPackConfig struct {
+	Budget    int
+	Templates map[string]string
+}
+
+func DefaultContextPackConfig() ContextPackConfig {
+	return ContextPackConfig{Budget: DefaultContextPackBudget}
+}
+
+// ContextPackQuery picks the code a pack is about: the lines an address
+// covers, or lines of a document, the whole of it when they're left out
+type ContextPackQuery struct {
+	Address    addressing.StableAddress
+	DocumentID string
+	FirstLine  int
+	LastLine   int
+	// Template names the template to render with, markdown by default
+	Template string
+	// Budget is the tokens the rendered pack should fit in, the configured
+	// budget when zero
+	Budget int
+	// Viewer leaves out conversations and decisions it may not read
+	Viewer context.Viewer
+}
+
+// ContextPack gathers what someone, or a model, needs to work on some code:
+// the code itself, the operations that wrote it, the conversations and
+// decisions anchored to it and what the changes were for. Content is the
+// pack rendered by its template, trimmed to fit the budget; what was left
+// out to fit is counted in Omitted.
+type ContextPack struct {
+	DocumentID    string                  `json:"document_id"`
+	Version       uint64                  `json:"version"`
+	FirstLine     int                     `json:"first_line"`
+	LastLine      int                     `json:"last_line"`
+	Code          string                  `json:"code"`
+	History       []PackOperation         `json:"history"`
+	Conversations []AnchoredConversation  `json:"conversations"`
+	Decisions     []*context.Decision     `json:"decisions"`
+	Intent        *context.IntentAnalysis `json:"intent"`
+	Template      string                  `json:"template"`
+	Budget        int                     `json:"budget"`
+	Tokens        int                     `json:"tokens"`
+	Omitted       PackOmissions           `json:"omitted"`
+	Content       string                  `json:"content"`
+}
+
+// PackOperation is an operation that created or last changed code in a pack.
+// Intent is the one recorded with it, or failing that, the one inferred.
+type PackOperation struct {
+	ID        operations.OperationID   `json:"id"`
+	Type      operations.OperationType `json:"type"`
+	Author    operations.AuthorID      `json:"author"`
+	Timestamp time.Time                `json:"timestamp"`
+	Intent    string                   `json:"intent,omitempty"`
+}
+
+// PackOmissions counts what was left out of a pack's content to fit its
+// budget. The oldest operations go first, then the least recently updated
+// conversations, the oldest decisions and finally code from the end.
+type PackOmissions struct {
+	Operations    int `json:"operations,omitempty"`
+	Conversations int `json:"conversations,omitempty"`
+	Decisions     int `json:"decisions,omitempty"`
+	CodeLines     int `json:"code_lines,omitempty"`
+}
+
+// EstimateTokens approximates how many tokens a model reads text as, at
+// about four characters a token
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// ContextPackTemplates are the templates packs can be rendered with
+type ContextPackTemplates map[string]*template.Template
+
+// ParseContextPackTemplates parses sources over the built-in templates
+func ParseContextPackTemplates(sources map[string]string) (ContextPackTemplates, error) {
+	all := map[string]string{
+		"markdown": markdownPackTemplate,
+		"xml":      xmlPackTemplate,
+	}
+	maps.Copy(all, sources)
+
+	templates := make(ContextPackTemplates, len(all))
+	for name, source := range all {
+		if name == "" {
+			return nil, fmt.Errorf("%w: a template needs a name", ErrInvalidTemplate)
+		}
+		tmpl, err := template.New(name).Funcs(packFuncs).Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+		templates[name] = tmpl
+	}
+	return templates, nil
+}
+
+// Names lists the templates in order
+func (t ContextPackTemplates) Names() []string {
+	return slices.Sorted(maps.Keys(t))
+}
+
+// SetContextPackConfig replaces the budget and templates packs are rendered
+// with, leaving them as they were when a template doesn't parse
+func (ce *CollaborationEngine) SetContextPackConfig(config ContextPackConfig) error {
+	templates, err := ParseContextPackTemplates(config.Templates)
+	if err != nil {
+		return err
+	}
+	if config.Budget <= 0 {
+		config.Budget = DefaultContextPackBudget
+	}
+
+	ce.mutex.Lock()
+	defer ce.mutex.Unlock()
+	ce.contextPacks = config
+	ce.packTemplates = templates
+	return nil
+}
+
+func (ce *CollaborationEngine) contextPackConfig() (ContextPackConfig, ContextPackTemplates) {
+	ce.mutex.RLock()
+	defer ce.mutex.RUnlock()
+	return ce.contextPacks, ce.packTemplates
+}
+
+// ContextPackTemplateNames lists the templates packs can be rendered with
+func (ce *CollaborationEngine) ContextPackTemplateNames() []string {
+	_, templates := ce.contextPackConfig()
+	return templates.Names()
+}
+
+// ContextPack assembles and renders a pack about the code query picks, on main
+func (ce *CollaborationEngine) ContextPack(ctx gocontext.Context, query ContextPackQuery) (*ContextPack, error) {
+	config, templates := ce.contextPackConfig()
+	if query.Template == "" {
+		query.Template = DefaultContextPackTemplate
+	}
+	tmpl, ok := templates[query.Template]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTemplate, query.Template)
+	}
+	if query.Budget <= 0 {
+		query.Budget = config.Budget
+	}
+
+	doc, first, last, err := ce.packRange(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	content, err := doc.Render()
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.SplitAfter(content, "\n")
+
+	pack := &ContextPack{
+		DocumentID:    doc.FilePath,
+		Version:       doc.CurrentVersion(),
+		FirstLine:     first,
+		LastLine:      last,
+		Code:          strings.Join(lines[first-1:min(last, len(lines))], ""),
+		History:       []PackOperation{},
+		Conversations: []AnchoredConversation{},
+		Decisions:     []*context.Decision{},
+		Template:      query.Template,
+		Budget:        query.Budget,
+	}
+	overlaps := func(from, to int) bool { return from <= last && to >= first }
+
+	history, err := ce.packHistory(ctx, doc, overlaps)
+	if err != nil {
+		return nil, err
+	}
+	if pack.Intent, err = ce.contextAnalyzer.AnalyzeChangeIntent(history); err != nil {
+		return nil, err
+	}
+	for _, op := range history {
+		entry := PackOperation{ID: op.ID, Type: op.Type, Author: op.Author, Timestamp: op.Timestamp, Intent: op.Metadata.Intent}
+		if entry.Intent == "" {
+			if intent, err := ce.contextAnalyzer.AnalyzeChangeIntent([]*operations.Operation{op}); err == nil && intent.Category != context.IntentUnknown {
+				entry.Intent = intent.PrimaryIntent
+			}
+		}
+		pack.History = append(pack.History, entry)
+	}
+
+	for _, anchored := range ce.ConversationsInDocument(ctx, doc) {
+		if query.Viewer.CanView(anchored.Thread) && overlaps(anchored.FirstLine, anchored.LastLine) {
+			pack.Conversations = append(pack.Conversations, anchored)
+		}
+	}
+	sort.SliceStable(pack.Conversations, func(i, j int) bool {
+		return pack.Conversations[i].Thread.UpdatedAt.After(pack.Conversations[j].Thread.UpdatedAt)
+	})
+
+	for _, decision := range ce.DecisionsInDocument(ctx, doc.FilePath, context.DecisionFilter{Current: true, Viewer: query.Viewer}) {
+		if from, to, err := ce.anchorLines(decision.Address, doc); err == nil && overlaps(from, to) {
+			pack.Decisions = append(pack.Decisions, decision)
+		}
+	}
+
+	if err := pack.fit(tmpl); err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+// packRange finds the document and lines a query is about
+func (ce *CollaborationEngine) packRange(ctx gocontext.Context, query ContextPackQuery) (*positioning.Document, int, int, error) {
+	documentID, first, last := query.DocumentID, query.FirstLine, query.LastLine
+	if query.Address.OperationID != "" {
+		op, err := ce.store.GetOperation(ctx, query.Address.OperationID)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		documentID = op.Metadata.DocumentID
+	}
+
+	doc, err := ce.getOrLoadDocument(ctx, documentID)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if doc.CurrentVersion() == 0 {
+		return nil, 0, 0, storage.ErrDocumentNotFound
+	}
+
+	lineCount := 0
+	if spans := doc.LineSpans(); len(spans) > 0 {
+		lineCount = spans[len(spans)-1].LastLine
+	}
+	switch {
+	case query.Address.OperationID != "":
+		if first, last, err = ce.anchorLines(query.Address, doc); err != nil {
+			return nil, 0, 0, fmt.Errorf("%w: the address no longer covers any line", positioning.ErrInvalidRange)
+		}
+	case first == 0 && last == 0:
+		first, last = 1, max(lineCount, 1)
+	case last == 0:
+		last = first
+	}
+	if first < 1 || last < first || last > max(lineCount, 1) {
+		return nil, 0, 0, fmt.Errorf("%w: lines %d-%d of %s, which has %d", positioning.ErrInvalidRange, first, last, documentID, lineCount)
+	}
+	return doc, first, last, nil
+}
+
+// packHistory loads the operations that created or last changed the
+// constructs on the lines overlaps accepts, newest first
+func (ce *CollaborationEngine) packHistory(ctx gocontext.Context, doc *positioning.Document, overlaps func(int, int) bool) ([]*operations.Operation, error) {
+	seen := make(map[operations.OperationID]bool)
+	var history []*operations.Operation
+	for _, span := range doc.LineSpans() {
+		if !overlaps(span.FirstLine, span.LastLine) {
+			continue
+		}
+		for _, id := range []operations.OperationID{span.Construct.CreatedBy, span.Construct.ModifiedBy} {
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			op, err := ce.store.GetOperation(ctx, id)
+			if errors.Is(err, storage.ErrOperationNotFound) {
+				// Purged by a retention policy
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			history = append(history, op)
+		}
+	}
+
+	sort.SliceStable(history, func(i, j int) bool { return history[j].Precedes(history[i]) })
+	if len(history) > maxPackHistory {
+		history = history[:maxPackHistory]
+	}
+	return history, nil
+}
+
+// fit renders the pack, leaving out what it must to come in under its
+// budget. Each kind of content is cut back in turn, keeping as much of it as
+// fits with everything after it still in.
+func (p *ContextPack) fit(tmpl *template.Template) error {
+	full := *p
+	codeLines := strings.SplitAfter(full.Code, "\n")
+	if codeLines[len(codeLines)-1] == "" {
+		codeLines = codeLines[:len(codeLines)-1]
+	}
+
+	view := full
+	render := func() (string, error) {
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, &view); err != nil {
+			return "", fmt.Errorf("failed to render template %s: %w", tmpl.Name(), err)
+		}
+		return b.String(), nil
+	}
+	var renderErr error
+	fits := func() bool {
+		content, err := render()
+		if err != nil {
+			renderErr = err
+			return true
+		}
+		return EstimateTokens(content) <= p.Budget
+	}
+
+	// keep finds the most of n items that fit, given apply to show k of them
+	keep := func(n int, apply func(k int)) {
+		k := sort.Search(n+1, func(k int) bool {
+			apply(k)
+			return !fits()
+		})
+		apply(max(k-1, 0))
+	}
+
+	if !fits() {
+		keep(len(full.History), func(k int) {
+			view.History = full.History[:k]
+			view.Omitted.Operations = len(full.History) - k
+		})
+	}
+	if !fits() {
+		keep(len(full.Conversations), func(k int) {
+			view.Conversations = full.Conversations[:k]
+			view.Omitted.Conversations = len(full.Conversations) - k
+		})
+	}
+	if !fits() {
+		keep(len(full.Decisions), func(k int) {
+			view.Decisions = full.Decisions[:k]
+			view.Omitted.Decisions = len(full.Decisions) - k
+		})
+	}
+	if !fits() {
+		keep(len(codeLines), func(k int) {
+			view.Code = strings.Join(codeLines[:k], "")
+			view.Omitted.CodeLines = len(codeLines) - k
+		})
+	}
+	if renderErr != nil {
+		return renderErr
+	}
+
+	content, err := render()
+	if err != nil {
+		return err
+	}
+	p.Omitted = view.Omitted
+	p.Content = content
+	p.Tokens = EstimateTokens(content)
+	return nil
+}
+
+var packFuncs = template.FuncMap{
+	"codeblock": func(documentID, code string) string {
+		language := strings.TrimPrefix(path.Ext(documentID), ".")
+		fence := codeFence(code)
+		if code != "" && !strings.HasSuffix(code, "\n") {
+			code += "\n"
+		}
+		return fence + language + "\n" + code + fence
+	},
+	"date": func(t time.Time) string {
+		return t.UTC().Format(time.DateTime)
+	},
+	"indent": func(prefix, text string) string {
+		return prefix + strings.ReplaceAll(strings.TrimRight(text, "\n"), "\n", "\n"+prefix)
+	},
+}
+
+const markdownPackTemplate = `# {{.DocumentID}}, lines {{.FirstLine}}-{{.LastLine}}
+
+{{codeblock .DocumentID .Code}}
+{{- if .Omitted.CodeLines}}
+{{.Omitted.CodeLines}} more lines left out.
+{{- end}}
+{{with .Intent}}{{if ne .PrimaryIntent "unknown"}}
+## Intent
+
+Mostly {{.PrimaryIntent}}{{if ne .Category "unknown"}} ({{.Category}}){{end}}.
+{{end}}{{end}}
+{{- if .Decisions}}
+## Decisions
+{{range .Decisions}}
+- **{{.Title}}** ({{.AuthorID}}, {{date .CreatedAt}}): {{.Content}}
+{{- end}}
+{{end}}
+{{- if .Conversations}}
+## Conversations
+{{range .Conversations}}
+### {{.Thread.Title}} (lines {{.FirstLine}}-{{.LastLine}}, {{.Thread.Status}})
+{{range .Thread.Messages}}
+**{{.AuthorID}}** ({{.MessageType}}):
+{{indent "> " .Content}}
+{{end}}{{end}}{{end}}
+{{- if .History}}
+## History
+{{range .History}}
+- {{date .Timestamp}} {{.Author}} {{.Type}}{{with .Intent}}: {{.}}{{end}}
+{{- end}}
+{{end}}
+{{- with .Omitted}}{{if or .Operations .Conversations .Decisions}}
+Left out to fit the budget: operations {{.Operations}}, conversations {{.Conversations}}, decisions {{.Decisions}}.
+{{end}}{{end}}`
+
+const xmlPackTemplate = `<context document="{{html .DocumentID}}" lines="{{.FirstLine}}-{{.LastLine}}" version="{{.Version}}">
+<code{{if .Omitted.CodeLines}} omitted_lines="{{.Omitted.CodeLines}}"{{end}}>
+{{html .Code}}</code>
+{{- with .Intent}}{{if ne .PrimaryIntent "unknown"}}
+<intent category="{{.Category}}">{{html .PrimaryIntent}}</intent>
+{{- end}}{{end}}
+{{- if .Decisions}}
+<decisions>
+{{- range .Decisions}}
+<decision title="{{html .Title}}" author="{{html .AuthorID}}" at="{{date .CreatedAt}}">{{html .Content}}</decision>
+{{- end}}
+</decisions>
+{{- end}}
+{{- if .Conversations}}
+<conversations>
+{{- range .Conversations}}
+<conversation title="{{html .Thread.Title}}" lines="{{.FirstLine}}-{{.LastLine}}" status="{{.Thread.Status}}">
+{{- range .Thread.Messages}}
+<message author="{{html .AuthorID}}" type="{{.MessageType}}">{{html .Content}}</message>
+{{- end}}
+</conversation>
+{{- end}}
+</conversations>
+{{- end}}
+{{- if .History}}
+<history>
+{{- range .History}}
+<operation id="{{.ID}}" author="{{html .Author}}" type="{{.Type}}" at="{{date .Timestamp}}"{{with .Intent}} intent="{{html .}}"{{end}}/>
+{{- end}}
+</history>
+{{- end}}
+</context>
+`
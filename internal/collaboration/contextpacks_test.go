@@ -0,0 +1,114 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+func TestCollaborationEngine_ContextPack(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+
+	insert := func(value int64, content, intent string) *operations.Operation {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: "alice"},
+			}),
+			Content:   content,
+			Author:    "alice",
+			Timestamp: time.Now().Add(time.Duration(value) * time.Second),
+			Metadata:  operations.OperationMeta{DocumentID: "retry.go", Intent: intent},
+		}
+		op.ID = operations.ComputeID(op)
+		if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+		return op
+	}
+	insert(10, "package retry\n\n", "")
+	backoff := insert(20, "func backoff(attempt int) time.Duration {\n\treturn time.Second << attempt\n}\n", "fix retry storm")
+	insert(30, "\nfunc unrelated() {}\n", "")
+
+	anchor := addressing.NewStableAddress("repo", backoff.ID, addressing.PositionRange{Start: backoff.Position, End: backoff.Position})
+	if _, err := engine.CreateConversation(anchor, "bob", "Cap the backoff", "Should this stop growing at some point?"); err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if _, err := engine.ConversationManager().CreateDecision(anchor, "alice", "Exponential backoff", "Double the wait on each attempt"); err != nil {
+		t.Fatalf("Failed to record decision: %v", err)
+	}
+
+	pack, err := engine.ContextPack(ctx, ContextPackQuery{Address: anchor})
+	if err != nil {
+		t.Fatalf("Failed to build context pack: %v", err)
+	}
+	if pack.DocumentID != "retry.go" || pack.FirstLine != 3 || pack.LastLine != 5 || !strings.HasPrefix(pack.Code, "func backoff") {
+		t.Fatalf("Expected the lines the address covers, got %d-%d %q", pack.FirstLine, pack.LastLine, pack.Code)
+	}
+	if len(pack.History) != 1 || pack.History[0].ID != backoff.ID || pack.History[0].Intent != "fix retry storm" {
+		t.Errorf("Expected the operation that wrote the lines, got %+v", pack.History)
+	}
+	// A decision recorded on its own starts a thread of its own
+	if len(pack.Conversations) != 2 || len(pack.Decisions) != 1 {
+		t.Errorf("Expected the anchored conversations and decision, got %d and %d", len(pack.Conversations), len(pack.Decisions))
+	}
+	for _, want := range []string{"# retry.go, lines 3-5", "```go\nfunc backoff", "**Exponential backoff**", "### Cap the backoff", "> Should this stop growing", "fix retry storm"} {
+		if !strings.Contains(pack.Content, want) {
+			t.Errorf("Expected the rendered pack to contain %q, got:\n%s", want, pack.Content)
+		}
+	}
+	if pack.Tokens != EstimateTokens(pack.Content) || pack.Tokens > pack.Budget {
+		t.Errorf("Expected the pack within its budget, got %d of %d tokens", pack.Tokens, pack.Budget)
+	}
+
+	// Lines outside the anchor leave its conversation and decision out
+	pack, err = engine.ContextPack(ctx, ContextPackQuery{DocumentID: "retry.go", FirstLine: 7, LastLine: 7, Template: "xml"})
+	if err != nil {
+		t.Fatalf("Failed to build context pack for lines: %v", err)
+	}
+	if len(pack.Conversations) != 0 || len(pack.Decisions) != 0 || !strings.HasPrefix(pack.Content, `<context document="retry.go" lines="7-7"`) {
+		t.Errorf("Expected only the unrelated line, got %+v", pack)
+	}
+
+	// A tight budget drops history and conversations before cutting code
+	pack, err = engine.ContextPack(ctx, ContextPackQuery{DocumentID: "retry.go", Budget: 90})
+	if err != nil {
+		t.Fatalf("Failed to build context pack on a budget: %v", err)
+	}
+	if pack.Omitted != (PackOmissions{Operations: 3, Conversations: 2}) || pack.Tokens > 90 {
+		t.Errorf("Expected history and conversations left out to fit, got %+v in %d tokens", pack.Omitted, pack.Tokens)
+	}
+	if !strings.Contains(pack.Content, "func unrelated") || !strings.Contains(pack.Content, "**Exponential backoff**") {
+		t.Errorf("Expected the code and decision kept, got:\n%s", pack.Content)
+	}
+
+	// Configured templates are added to the built-in ones
+	if err := engine.SetContextPackConfig(ContextPackConfig{Templates: map[string]string{"brief": "{{.DocumentID}}:{{.FirstLine}}"}}); err != nil {
+		t.Fatalf("Failed to set templates: %v", err)
+	}
+	pack, err = engine.ContextPack(ctx, ContextPackQuery{DocumentID: "retry.go", FirstLine: 2, Template: "brief"})
+	if err != nil || pack.Content != "retry.go:2" {
+		t.Errorf("Expected the configured template used, got %v %+v", err, pack)
+	}
+	if err := engine.SetContextPackConfig(ContextPackConfig{Templates: map[string]string{"broken": "{{.Nope"}}); !errors.Is(err, ErrInvalidTemplate) {
+		t.Errorf("Expected ErrInvalidTemplate, got %v", err)
+	}
+	if _, err := engine.ContextPack(ctx, ContextPackQuery{DocumentID: "retry.go", Template: "broken"}); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("Expected a template that didn't parse left unset, got %v", err)
+	}
+	if _, err := engine.ContextPack(ctx, ContextPackQuery{DocumentID: "retry.go", FirstLine: 40}); !errors.Is(err, positioning.ErrInvalidRange) {
+		t.Errorf("Expected ErrInvalidRange for lines past the end, got %v", err)
+	}
+	if _, err := engine.ContextPack(ctx, ContextPackQuery{DocumentID: "retry.go", Viewer: context.ViewerFor("carol")}); err != nil {
+		t.Errorf("Failed to build context pack for a restricted viewer: %v", err)
+	}
+}
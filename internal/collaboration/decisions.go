@@ -0,0 +1,34 @@
+package collaboration
+
+import (
+	gocontext "context"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// DecisionsInDocument returns the decisions matching filter whose anchor was
+// created in the document at path, newest first
+func (ce *CollaborationEngine) DecisionsInDocument(ctx gocontext.Context, path string, filter context.DecisionFilter) []*context.Decision {
+	documentOf := make(map[operations.OperationID]string)
+
+	var decisions []*context.Decision
+	for _, decision := range ce.conversationManager.Decisions(filter) {
+		opID := decision.Address.OperationID
+		if opID == "" {
+			continue
+		}
+
+		documentID, seen := documentOf[opID]
+		if !seen {
+			if op, err := ce.store.GetOperation(ctx, opID); err == nil {
+				documentID = op.Metadata.DocumentID
+			}
+			documentOf[opID] = documentID
+		}
+		if documentID == path {
+			decisions = append(decisions, decision)
+		}
+	}
+	return decisions
+}
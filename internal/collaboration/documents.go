@@ -0,0 +1,49 @@
+package collaboration
+
+import (
+	gocontext "context"
+
+	"github.com/jeremytregunna/contextdb/internal/events"
+)
+
+// DocumentChange is the payload of document.deleted and document.restored events
+type DocumentChange struct {
+	DocumentID string `json:"document_id"`
+}
+
+// DeleteDocument marks a document deleted. Its operations and constructs are
+// kept so RestoreDocument can bring it back, but until then it can't be read
+// or written.
+func (ce *CollaborationEngine) DeleteDocument(ctx gocontext.Context, documentID string) error {
+	lock := ce.documentLock(documentID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := ce.store.DeleteDocument(ctx, documentID); err != nil {
+		return err
+	}
+
+	ce.documents.remove(documentID)
+
+	ce.events.Publish(events.DocumentDeleted, DocumentChange{DocumentID: documentID})
+	return nil
+}
+
+// RestoreDocument undoes DeleteDocument
+func (ce *CollaborationEngine) RestoreDocument(ctx gocontext.Context, documentID string) error {
+	lock := ce.documentLock(documentID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := ce.store.RestoreDocument(ctx, documentID); err != nil {
+		return err
+	}
+
+	ce.events.Publish(events.DocumentRestored, DocumentChange{DocumentID: documentID})
+	return nil
+}
+
+// ListDocuments lists document paths in order, with deleted ones when includeDeleted is set
+func (ce *CollaborationEngine) ListDocuments(ctx gocontext.Context, includeDeleted bool) ([]string, error) {
+	return ce.store.ListDocuments(ctx, includeDeleted)
+}
@@ -1,12 +1,17 @@
 package collaboration
 
 import (
+	gocontext "context"
 	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jeremytregunna/contextdb/internal/addressing"
 	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/events"
+	"github.com/jeremytregunna/contextdb/internal/ids"
 	"github.com/jeremytregunna/contextdb/internal/logging"
 	"github.com/jeremytregunna/contextdb/internal/operations"
 	"github.com/jeremytregunna/contextdb/internal/positioning"
@@ -14,16 +19,35 @@ import (
 )
 
 type CollaborationEngine struct {
-	documents           map[string]*positioning.Document
+	documents           *documentCache
 	operationDAG        *operations.OperationDAG
+	clock               *operations.HybridClock
 	clients             map[ClientID]*ClientConnection
+	sessions            map[string]*parkedSession
+	sessionsMutex       sync.Mutex
 	store               storage.Store
 	broadcaster         *MessageBroadcaster
 	presenceTracker     *PresenceTracker
 	addressResolver     *addressing.AddressResolver
 	conversationManager *context.ConversationManager
 	contextAnalyzer     *context.ContextAnalyzer
+	events              *events.Bus
+	webSocket           WebSocketConfig
+	contextPacks        ContextPackConfig
+	packTemplates       ContextPackTemplates
+	verifier            OperationVerifier
+	slowClientEvictions atomic.Uint64
+	relay               *relay
+	relayErrors         atomic.Uint64
 	logger              *logging.Logger
+	documentLocks       map[string]*sync.Mutex
+	heads               map[string][]operations.OperationID
+	searchMutex         sync.Mutex
+	readOnly            bool
+	shuttingDown        bool
+	inflight            sync.WaitGroup
+	shutdownOnce        sync.Once
+	shutdownErr         error
 	mutex               sync.RWMutex
 }
 
@@ -38,24 +62,56 @@ func NewCollaborationEngine(store storage.Store) *CollaborationEngine {
 		conversationManager,
 	)
 
-	return &CollaborationEngine{
-		documents:           make(map[string]*positioning.Document),
+	bus := events.NewBus()
+	addressResolver.SetEventBus(bus)
+	addressResolver.SetConstructStore(store)
+	conversationManager.SetEventBus(bus)
+
+	ce := &CollaborationEngine{
+		documentLocks:       make(map[string]*sync.Mutex),
+		heads:               make(map[string][]operations.OperationID),
 		operationDAG:        operationDAG,
+		clock:               operations.NewHybridClock(),
 		clients:             make(map[ClientID]*ClientConnection),
+		sessions:            make(map[string]*parkedSession),
 		store:               store,
 		broadcaster:         NewMessageBroadcaster(),
 		presenceTracker:     NewPresenceTracker(),
 		addressResolver:     addressResolver,
 		conversationManager: conversationManager,
 		contextAnalyzer:     contextAnalyzer,
+		events:              bus,
+		webSocket:           DefaultWebSocketConfig(),
 		logger:              logging.NewLogger("collaboration"),
 	}
+	ce.documents = newDocumentCache(DefaultDocumentCacheConfig(), ce.tryLockDocument, ce.storeEvictedDocument)
+	if readOnly, ok := store.(interface{ ReadOnly() bool }); ok {
+		ce.readOnly = readOnly.ReadOnly()
+	}
+	// The clock starts past every stored operation, even if the wall clock
+	// has gone back since they were made
+	if clocked, ok := store.(interface {
+		MaxClock(gocontext.Context) (operations.HLC, error)
+	}); ok {
+		if latest, err := clocked.MaxClock(gocontext.Background()); err == nil {
+			ce.clock.Advance(latest)
+		}
+	}
+	// The built-in templates always parse
+	if err := ce.SetContextPackConfig(DefaultContextPackConfig()); err != nil {
+		panic(err)
+	}
+	return ce
 }
 
 func (ce *CollaborationEngine) AddClient(client *ClientConnection) error {
 	ce.mutex.Lock()
 	defer ce.mutex.Unlock()
 
+	if ce.shuttingDown {
+		return ErrEngineShutdown
+	}
+
 	ce.clients[client.ID] = client
 	ce.presenceTracker.AddClient(client.ID, client.AuthorID)
 
@@ -81,27 +137,70 @@ func (ce *CollaborationEngine) RemoveClient(clientID ClientID) error {
 	return nil
 }
 
-func (ce *CollaborationEngine) ProcessOperation(op *operations.Operation, fromClient ClientID) error {
-	// Validate the operation
-	if err := ce.operationDAG.ValidateOperation(op); err != nil {
-		return fmt.Errorf("invalid operation: %w", err)
+func (ce *CollaborationEngine) ProcessOperation(ctx gocontext.Context, op *operations.Operation, fromClient ClientID) error {
+	_, err := ce.ProcessOperationAt(ctx, op, fromClient, nil)
+	return err
+}
+
+// ProcessOperationAt is ProcessOperation that also returns the document's
+// version after op. When expectedVersion is set, op is only applied if the
+// document is still at that version; otherwise nothing is stored and a
+// *VersionConflictError lists the operations the caller hasn't seen.
+// Every operation moves its document's heads on; see AssignParents for
+// operations that should be filled in with them.
+func (ce *CollaborationEngine) ProcessOperationAt(ctx gocontext.Context, op *operations.Operation, fromClient ClientID, expectedVersion *uint64, opts ...ProcessOption) (uint64, error) {
+	var options processOptions
+	for _, opt := range opts {
+		opt(&options)
 	}
 
-	// Add to operation DAG
-	if err := ce.operationDAG.AddOperation(op); err != nil {
-		return fmt.Errorf("failed to add operation to DAG: %w", err)
+	if !ce.beginOperation() {
+		return 0, ErrEngineShutdown
+	}
+	defer ce.inflight.Done()
+	if ce.readOnly {
+		return 0, storage.ErrReadOnly
 	}
 
-	// Store the operation
-	if err := ce.store.StoreOperation(op); err != nil {
-		return fmt.Errorf("failed to store operation: %w", err)
+	documentID, err := ce.validateTarget(op)
+	if err != nil {
+		return 0, err
 	}
 
-	// Update address resolver with new operation
-	ce.addressResolver.ProcessOperation(op)
+	// Operations on other branches leave the main documents alone
+	if !operations.IsMainBranch(op.Metadata.Branch) {
+		return ce.processBranchOperation(ctx, op, documentID, expectedVersion, options)
+	}
+
+	// Writes to one document are serialized so a version check can't be
+	// overtaken between checking and applying
+	lock := ce.documentLock(documentID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	doc, err := ce.getOrLoadDocument(ctx, documentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load document: %w", err)
+	}
+
+	if expectedVersion != nil {
+		if err := ce.checkVersion(ctx, doc, operations.MainBranch, *expectedVersion); err != nil {
+			return 0, err
+		}
+	}
+	return ce.applyOperation(ctx, doc, documentID, op, fromClient, options)
+}
+
+// validateTarget validates op and returns the document it affects
+func (ce *CollaborationEngine) validateTarget(op *operations.Operation) (string, error) {
+	// Validate the operation
+	if err := ce.operationDAG.ValidateOperation(op); err != nil {
+		return "", fmt.Errorf("invalid operation: %w", err)
+	}
+	op.Metadata.Normalize()
 
 	// Determine which document this operation affects
-	documentID := op.Metadata.Context["document_id"]
+	documentID := op.Metadata.DocumentID
 	if documentID == "" {
 		// Try to infer document from operation position or context
 		if sessionID := op.Metadata.SessionID; sessionID != "" {
@@ -109,29 +208,78 @@ func (ce *CollaborationEngine) ProcessOperation(op *operations.Operation, fromCl
 			// For now, use a default document if none specified
 			documentID = "default"
 		} else {
-			return fmt.Errorf("operation missing document_id in metadata and cannot infer from context")
+			return "", fmt.Errorf("operation missing document_id in metadata and cannot infer from context")
 		}
 	}
+	return documentID, nil
+}
 
-	doc, err := ce.getOrLoadDocument(documentID)
+// applyOperation applies op to doc, the main document documentID, whose
+// lock the caller holds
+func (ce *CollaborationEngine) applyOperation(ctx gocontext.Context, doc *positioning.Document, documentID string, op *operations.Operation, fromClient ClientID, options processOptions) (uint64, error) {
+	heads, err := ce.documentHeads(ctx, documentID, operations.MainBranch)
 	if err != nil {
-		return fmt.Errorf("failed to load document: %w", err)
+		return 0, fmt.Errorf("failed to load document heads: %w", err)
+	}
+	if options.assignParents && len(op.Parents) == 0 && len(heads) > 0 {
+		op.Parents = slices.Clone(heads)
+		op.ID = operations.ComputeID(op)
 	}
 
+	// Add to operation DAG
+	if err := ce.resolveParents(ctx, op); err != nil {
+		return 0, err
+	}
+	if err := ce.stampClock(op); err != nil {
+		return 0, fmt.Errorf("invalid operation: %w", err)
+	}
+	// An operation that can't apply is refused before it's kept, so the
+	// stored operations and heads never get ahead of the document
+	if err := doc.CheckOperation(op); err != nil {
+		return 0, fmt.Errorf("failed to apply operation to document: %w", err)
+	}
+	if err := ce.operationDAG.AddOperation(op); err != nil {
+		return 0, fmt.Errorf("failed to add operation to DAG: %w", err)
+	}
+
+	// Store the operation
+	if err := ce.store.StoreOperation(ctx, op); err != nil {
+		return 0, fmt.Errorf("failed to store operation: %w", err)
+	}
+	if err := ce.advanceHeads(ctx, documentID, operations.MainBranch, op); err != nil {
+		return 0, fmt.Errorf("failed to update document heads: %w", err)
+	}
+	ce.conversationManager.LinkOperation(op)
+
+	// Update address resolver with new operation
+	ce.addressResolver.ProcessOperation(op)
+
 	if err := doc.ApplyOperation(op); err != nil {
-		return fmt.Errorf("failed to apply operation to document: %w", err)
+		return 0, fmt.Errorf("failed to apply operation to document: %w", err)
 	}
 
-	// Store updated document
-	if err := ce.store.StoreDocument(doc); err != nil {
-		return fmt.Errorf("failed to store updated document: %w", err)
+	// Store updated document. Until that succeeds the cached document is
+	// ahead of the store, and is written again when it is evicted.
+	ce.documents.update(documentID, true)
+	if err := ce.store.StoreDocument(ctx, doc); err != nil {
+		return 0, fmt.Errorf("failed to store updated document: %w", err)
 	}
+	ce.documents.update(documentID, false)
 
 	// Index document with address resolver
 	ce.addressResolver.IndexDocument(doc)
 
+	version := doc.CurrentVersion()
+
+	ce.events.Publish(events.OperationCreated, op)
+	ce.events.Publish(events.DocumentUpdated, DocumentUpdate{
+		DocumentID:  documentID,
+		Version:     version,
+		OperationID: op.ID,
+	})
+
 	// Broadcast to all clients except sender
-	return ce.BroadcastOperation(op, documentID, fromClient)
+	return version, ce.BroadcastOperation(op, documentID, fromClient)
 }
 
 func (ce *CollaborationEngine) BroadcastOperation(op *operations.Operation, documentID string, excludeClient ClientID) error {
@@ -152,22 +300,38 @@ func (ce *CollaborationEngine) BroadcastOperation(op *operations.Operation, docu
 	ce.mutex.RLock()
 	defer ce.mutex.RUnlock()
 
+	ce.deliver(documentID, msg, excludeClient)
+	ce.relayBroadcast(documentID, msg, excludeClient)
+	return nil
+}
+
+// deliver sends msg to the clients following documentID, and keeps it for
+// the parked sessions that do. The caller holds the engine's mutex for
+// reading at least.
+func (ce *CollaborationEngine) deliver(documentID string, msg *Message, excludeClient ClientID) {
 	for clientID, client := range ce.clients {
-		if clientID == excludeClient {
+		if clientID == excludeClient || !client.IsSubscribedTo(documentID) {
 			continue
 		}
-
-		if client.IsSubscribedTo(documentID) {
-			if err := client.SendMessage(msg); err != nil {
+		if err := client.SendMessage(msg); err != nil {
+			if msg.Type == MsgPresence {
+				ce.logger.LogPresenceBroadcastError(string(clientID), err)
+			} else {
 				ce.logger.LogOperationBroadcastError(string(clientID), err)
 			}
 		}
 	}
+	ce.queueForParked(documentID, msg)
+}
 
-	return nil
+func (ce *CollaborationEngine) SyncClient(ctx gocontext.Context, clientID ClientID, documentID string, sinceVersion uint64) error {
+	return ce.SyncClientWith(ctx, clientID, SyncPayload{DocumentID: documentID, SinceVersion: sinceVersion})
 }
 
-func (ce *CollaborationEngine) SyncClient(clientID ClientID, documentID string, sinceVersion uint64) error {
+// SyncClientWith answers a client's sync request, compressed or split into
+// chunks when it accepts them and the answer is large enough
+func (ce *CollaborationEngine) SyncClientWith(ctx gocontext.Context, clientID ClientID, request SyncPayload) error {
+	documentID, sinceVersion := request.DocumentID, request.SinceVersion
 	ce.mutex.RLock()
 	client, exists := ce.clients[clientID]
 	if !exists {
@@ -177,48 +341,48 @@ func (ce *CollaborationEngine) SyncClient(clientID ClientID, documentID string,
 	ce.mutex.RUnlock()
 
 	// Load document
-	doc, err := ce.getOrLoadDocument(documentID)
+	doc, err := ce.getOrLoadDocument(ctx, documentID)
 	if err != nil {
 		return fmt.Errorf("failed to load document: %w", err)
 	}
 
 	// Get operations since version
-	var operations []*operations.Operation
+	var docOps []*operations.Operation
 	if sinceVersion > 0 {
-		// In a proper implementation, we'd track document versions
-		// and get operations since that specific version
-		// For now, get recent operations that affected this document
-		since := time.Now().Add(-1 * time.Hour)
-		allOps, err := ce.store.GetOperationsSince(since)
+		docOps, _, err = ce.operationsSinceVersion(ctx, doc, operations.MainBranch, sinceVersion)
 		if err != nil {
 			return fmt.Errorf("failed to get operations: %w", err)
 		}
-
-		// Filter to operations that affected this document
-		for _, op := range allOps {
-			if opDocID := op.Metadata.Context["document_id"]; opDocID == documentID {
-				operations = append(operations, op)
-			}
-		}
 	}
 
 	payload := &SyncPayload{
 		DocumentID:   documentID,
-		Operations:   operations,
+		Operations:   docOps,
 		CurrentState: doc,
 		SinceVersion: sinceVersion,
 	}
 
-	msg := &Message{
-		Type:      MsgSync,
-		Payload:   payload,
-		MessageID: generateMessageID(),
-		Timestamp: time.Now(),
-		AuthorID:  client.AuthorID,
+	// Following the document before its state is encoded means operations
+	// applied meanwhile are broadcast to the client rather than missed
+	client.SubscribeToDocument(documentID)
+	payloads, err := encodeSync(payload, request, client.config)
+	if err != nil {
+		return err
 	}
 
-	client.SubscribeToDocument(documentID)
-	return client.SendMessage(msg)
+	for _, payload := range payloads {
+		err := client.SendMessage(&Message{
+			Type:      MsgSync,
+			Payload:   payload,
+			MessageID: generateMessageID(),
+			Timestamp: time.Now(),
+			AuthorID:  client.AuthorID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (ce *CollaborationEngine) UpdatePresence(clientID ClientID, presence PresencePayload) error {
@@ -253,53 +417,44 @@ func (ce *CollaborationEngine) broadcastPresence(presence PresencePayload, exclu
 	ce.mutex.RLock()
 	defer ce.mutex.RUnlock()
 
-	for clientID, client := range ce.clients {
-		if clientID == excludeClient {
-			continue
-		}
-
-		if client.IsSubscribedTo(presence.DocumentID) {
-			if err := client.SendMessage(msg); err != nil {
-				ce.logger.LogPresenceBroadcastError(string(clientID), err)
-			}
-		}
-	}
-
+	ce.deliver(presence.DocumentID, msg, excludeClient)
+	ce.relayBroadcast(presence.DocumentID, msg, excludeClient)
 	return nil
 }
 
-func (ce *CollaborationEngine) getOrLoadDocument(documentID string) (*positioning.Document, error) {
-	ce.mutex.RLock()
-	doc, exists := ce.documents[documentID]
-	ce.mutex.RUnlock()
-
-	if exists {
+func (ce *CollaborationEngine) getOrLoadDocument(ctx gocontext.Context, documentID string) (*positioning.Document, error) {
+	// A read-only store may be changed underneath, so its documents are
+	// always read again
+	if ce.readOnly {
+		return ce.loadDocument(ctx, documentID)
+	}
+	if doc, cached := ce.documents.get(documentID); cached {
 		return doc, nil
 	}
 
-	// Load from storage
-	storedDoc, err := ce.store.GetDocument(documentID)
+	doc, err := ce.loadDocument(ctx, documentID)
 	if err != nil {
-		if err == storage.ErrDocumentNotFound {
-			// Create new document
-			doc = positioning.NewDocument(documentID)
-			ce.mutex.Lock()
-			ce.documents[documentID] = doc
-			ce.mutex.Unlock()
-			return doc, nil
-		}
 		return nil, err
 	}
+	return ce.documents.add(documentID, doc), nil
+}
 
-	ce.mutex.Lock()
-	ce.documents[documentID] = storedDoc
-	ce.mutex.Unlock()
+func (ce *CollaborationEngine) loadDocument(ctx gocontext.Context, documentID string) (*positioning.Document, error) {
+	doc, err := ce.store.GetDocument(ctx, documentID)
+	if err == storage.ErrDocumentNotFound {
+		return positioning.NewDocument(documentID), nil
+	}
+	return doc, err
+}
 
-	return storedDoc, nil
+func (ce *CollaborationEngine) GetDocumentState(ctx gocontext.Context, documentID string) (*positioning.Document, error) {
+	return ce.getOrLoadDocument(ctx, documentID)
 }
 
-func (ce *CollaborationEngine) GetDocumentState(documentID string) (*positioning.Document, error) {
-	return ce.getOrLoadDocument(documentID)
+// ReadOnly reports whether the engine's store was opened read-only, which
+// makes it refuse operations with storage.ErrReadOnly
+func (ce *CollaborationEngine) ReadOnly() bool {
+	return ce.readOnly
 }
 
 func (ce *CollaborationEngine) GetConnectedClients() []ClientInfo {
@@ -329,11 +484,41 @@ func (ce *CollaborationEngine) GetDocumentClients(documentID string) []ClientInf
 }
 
 func generateMessageID() string {
-	return fmt.Sprintf("msg_%d", time.Now().UnixNano())
+	return ids.NewWithPrefix("msg")
 }
 
 // Address and Context Methods
 
+// The accessors below expose the engine's shared components so the API and
+// CLI operate on the same state the engine maintains
+
+func (ce *CollaborationEngine) AddressResolver() *addressing.AddressResolver {
+	return ce.addressResolver
+}
+
+func (ce *CollaborationEngine) ConversationManager() *context.ConversationManager {
+	return ce.conversationManager
+}
+
+func (ce *CollaborationEngine) ContextAnalyzer() *context.ContextAnalyzer {
+	return ce.contextAnalyzer
+}
+
+// Events carries operation, document, conversation and address changes to
+// anyone who subscribes
+func (ce *CollaborationEngine) Events() *events.Bus {
+	return ce.events
+}
+
+// DocumentUpdate is the payload of a document.updated event
+type DocumentUpdate struct {
+	DocumentID string `json:"document_id"`
+	// Branch is set for updates to a document on a branch other than main
+	Branch      string                 `json:"branch,omitempty"`
+	Version     uint64                 `json:"version"`
+	OperationID operations.OperationID `json:"operation_id"`
+}
+
 func (ce *CollaborationEngine) CreateStableAddress(repo addressing.RepositoryID, creationOpID operations.OperationID, posRange addressing.PositionRange) (addressing.StableAddress, error) {
 	return ce.addressResolver.CreateAddress(repo, creationOpID, posRange)
 }
@@ -350,16 +535,16 @@ func (ce *CollaborationEngine) CreateConversation(anchorAddr addressing.StableAd
 	return ce.conversationManager.CreateConversation(anchorAddr, authorID, title, content)
 }
 
-func (ce *CollaborationEngine) GetConversation(threadID context.ThreadID) (*context.ConversationThread, error) {
-	return ce.conversationManager.GetConversation(threadID)
+func (ce *CollaborationEngine) GetConversation(threadID context.ThreadID, opts ...context.MessageOption) (*context.ConversationThread, error) {
+	return ce.conversationManager.GetConversation(threadID, opts...)
 }
 
 func (ce *CollaborationEngine) GetConversationsByAddress(addr addressing.StableAddress) ([]*context.ConversationThread, error) {
 	return ce.conversationManager.GetConversationsByAddress(addr)
 }
 
-func (ce *CollaborationEngine) AddMessageToConversation(threadID context.ThreadID, authorID operations.AuthorID, content string, msgType context.MessageType) (*context.Message, error) {
-	return ce.conversationManager.AddMessage(threadID, authorID, content, msgType)
+func (ce *CollaborationEngine) AddMessageToConversation(threadID context.ThreadID, authorID operations.AuthorID, content string, msgType context.MessageType, references ...addressing.StableAddress) (*context.Message, error) {
+	return ce.conversationManager.AddMessage(threadID, authorID, content, msgType, references...)
 }
 
 func (ce *CollaborationEngine) GetOperationContext(opID operations.OperationID) (*context.OperationContext, error) {
@@ -1,6 +1,8 @@
 package collaboration
 
 import (
+	gocontext "context"
+	"errors"
 	"math/big"
 	"testing"
 	"time"
@@ -53,6 +55,7 @@ func TestCollaborationEngine_AddRemoveClient(t *testing.T) {
 }
 
 func TestCollaborationEngine_ProcessOperation(t *testing.T) {
+	ctx := gocontext.Background()
 	store := setupTestStorage(t)
 	engine := NewCollaborationEngine(store)
 
@@ -91,13 +94,13 @@ func TestCollaborationEngine_ProcessOperation(t *testing.T) {
 		},
 	}
 
-	err := engine.ProcessOperation(op, clientID)
+	err := engine.ProcessOperation(ctx, op, clientID)
 	if err != nil {
 		t.Fatalf("Failed to process operation: %v", err)
 	}
 
 	// Verify operation was stored
-	stored, err := store.GetOperation(op.ID)
+	stored, err := store.GetOperation(ctx, op.ID)
 	if err != nil {
 		t.Fatalf("Failed to retrieve stored operation: %v", err)
 	}
@@ -107,7 +110,7 @@ func TestCollaborationEngine_ProcessOperation(t *testing.T) {
 	}
 
 	// Verify document was updated
-	doc, err := engine.GetDocumentState("test.go")
+	doc, err := engine.GetDocumentState(ctx, "test.go")
 	if err != nil {
 		t.Fatalf("Failed to get document state: %v", err)
 	}
@@ -116,12 +119,13 @@ func TestCollaborationEngine_ProcessOperation(t *testing.T) {
 		t.Errorf("Expected document version 1, got %d", doc.Version)
 	}
 
-	if len(doc.Constructs) != 1 {
-		t.Errorf("Expected 1 construct, got %d", len(doc.Constructs))
+	if doc.ConstructCount() != 1 {
+		t.Errorf("Expected 1 construct, got %d", doc.ConstructCount())
 	}
 }
 
 func TestCollaborationEngine_SyncClient(t *testing.T) {
+	ctx := gocontext.Background()
 	store := setupTestStorage(t)
 	engine := NewCollaborationEngine(store)
 
@@ -158,10 +162,10 @@ func TestCollaborationEngine_SyncClient(t *testing.T) {
 	doc.InsertConstruct(construct)
 	doc.Version = 1
 
-	store.StoreDocument(doc)
+	store.StoreDocument(ctx, doc)
 
 	// Test sync
-	err := engine.SyncClient(clientID, "test.go", 0)
+	err := engine.SyncClient(ctx, clientID, "test.go", 0)
 	if err != nil {
 		t.Fatalf("Failed to sync client: %v", err)
 	}
@@ -237,6 +241,298 @@ func TestPresenceTracker(t *testing.T) {
 	}
 }
 
+func TestCollaborationEngine_Shutdown(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+
+	clientID := ClientID("test_client")
+	authorID := operations.AuthorID("test_author")
+
+	mockClient := &ClientConnection{
+		ID:        clientID,
+		AuthorID:  authorID,
+		Documents: map[string]bool{"test.go": true},
+		LastSeen:  time.Now(),
+		sendChan:  make(chan *Message, 10),
+		closeChan: make(chan struct{}),
+	}
+	engine.AddClient(mockClient)
+
+	pos := operations.NewLogootPosition([]operations.PositionSegment{
+		{Value: big.NewInt(1), AuthorID: authorID},
+	})
+	op := &operations.Operation{
+		ID:        operations.NewOperationID([]byte("test_op")),
+		Type:      operations.OpInsert,
+		Position:  pos,
+		Content:   "hello world",
+		Author:    "other_author",
+		Timestamp: time.Now(),
+		Parents:   []operations.OperationID{},
+		Metadata: operations.OperationMeta{
+			Context: map[string]string{"document_id": "test.go"},
+		},
+	}
+	if err := engine.ProcessOperation(ctx, op, "other_client"); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+
+	if err := engine.Shutdown(ctx); err != nil {
+		t.Fatalf("Failed to shut down: %v", err)
+	}
+
+	// The pending broadcast is still delivered, followed by the close notice
+	var received []MessageType
+	for msg := range mockClient.sendChan {
+		received = append(received, msg.Type)
+	}
+	if len(received) != 2 || received[0] != MsgOperation || received[1] != MsgClose {
+		t.Errorf("Expected operation then close messages, got %v", received)
+	}
+
+	if len(engine.GetConnectedClients()) != 0 {
+		t.Error("Expected all clients to be disconnected")
+	}
+
+	op.ID = operations.NewOperationID([]byte("late_op"))
+	if err := engine.ProcessOperation(ctx, op, "other_client"); !errors.Is(err, ErrEngineShutdown) {
+		t.Errorf("Expected ErrEngineShutdown for a late operation, got %v", err)
+	}
+	if err := engine.AddClient(mockClient); !errors.Is(err, ErrEngineShutdown) {
+		t.Errorf("Expected ErrEngineShutdown for a late client, got %v", err)
+	}
+
+	// Later calls report the first result rather than closing the store twice
+	if err := engine.Shutdown(ctx); err != nil {
+		t.Errorf("Expected repeated shutdown to succeed, got %v", err)
+	}
+}
+
+func TestCollaborationEngine_ProcessOperationAt(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+	authorID := operations.AuthorID("test_author")
+
+	newInsert := func(id string, value int64) *operations.Operation {
+		return &operations.Operation{
+			ID:   operations.NewOperationID([]byte(id)),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: authorID},
+			}),
+			Content:   id,
+			Author:    authorID,
+			Timestamp: time.Now(),
+			Metadata: operations.OperationMeta{
+				Context: map[string]string{"document_id": "test.go"},
+			},
+		}
+	}
+
+	expected := uint64(0)
+	version, err := engine.ProcessOperationAt(ctx, newInsert("first", 1), "client", &expected)
+	if err != nil {
+		t.Fatalf("Failed to process operation at version 0: %v", err)
+	}
+	if version != 1 {
+		t.Errorf("Expected version 1, got %d", version)
+	}
+
+	second := newInsert("second", 2)
+	if _, err := engine.ProcessOperationAt(ctx, second, "client", nil); err != nil {
+		t.Fatalf("Failed to process unconditional operation: %v", err)
+	}
+
+	// A writer still at version 1 has missed the second operation
+	stale := newInsert("stale", 3)
+	expected = 1
+	_, err = engine.ProcessOperationAt(ctx, stale, "client", &expected)
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("Expected ErrVersionConflict, got %v", err)
+	}
+
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected a *VersionConflictError, got %T", err)
+	}
+	if conflict.CurrentVersion != 2 || conflict.ExpectedVersion != 1 {
+		t.Errorf("Expected versions 2 and 1, got %d and %d", conflict.CurrentVersion, conflict.ExpectedVersion)
+	}
+	if len(conflict.Missing) != 1 || conflict.Missing[0].ID != second.ID {
+		t.Errorf("Expected the second operation to be missing, got %v", conflict.Missing)
+	}
+
+	// Nothing from the rejected write is kept
+	doc, _ := engine.GetDocumentState(ctx, "test.go")
+	if doc.CurrentVersion() != 2 {
+		t.Errorf("Expected document to stay at version 2, got %d", doc.CurrentVersion())
+	}
+	if _, err := engine.store.GetOperation(ctx, stale.ID); err == nil {
+		t.Error("Expected the rejected operation not to be stored")
+	}
+}
+
+func TestCollaborationEngine_DocumentHeads(t *testing.T) {
+	ctx := gocontext.Background()
+	store := setupTestStorage(t)
+	engine := NewCollaborationEngine(store)
+	authorID := operations.AuthorID("test_author")
+
+	newInsert := func(documentID, content string, value int64) *operations.Operation {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: authorID},
+			}),
+			Content:   content,
+			Author:    authorID,
+			Timestamp: time.Now(),
+			Metadata: operations.OperationMeta{
+				Context: map[string]string{"document_id": documentID},
+			},
+		}
+		op.ID = operations.ComputeID(op)
+		return op
+	}
+
+	// Operations processed as they are keep their parents, even when they have none
+	left := newInsert("a.go", "left", 1)
+	right := newInsert("a.go", "right", 2)
+	other := newInsert("b.go", "other", 1)
+	for _, op := range []*operations.Operation{left, right, other} {
+		if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+	}
+	heads, err := engine.DocumentHeads(ctx, "a.go")
+	if err != nil {
+		t.Fatalf("Failed to get document heads: %v", err)
+	}
+	if len(heads) != 2 || heads[0] != left.ID || heads[1] != right.ID {
+		t.Fatalf("Expected both operations in a.go to be heads, got %v", heads)
+	}
+
+	// An assigned operation merges the heads of its own document only
+	merge := newInsert("a.go", "merge", 3)
+	if _, err := engine.ProcessOperationAt(ctx, merge, "client", nil, AssignParents()); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+	if len(merge.Parents) != 2 || merge.Parents[0] != left.ID || merge.Parents[1] != right.ID {
+		t.Errorf("Expected the heads of a.go as parents, got %v", merge.Parents)
+	}
+	if merge.ID != operations.ComputeID(merge) {
+		t.Errorf("Expected the ID to cover the assigned parents, got %s", merge.ID)
+	}
+
+	// Heads are read back from the store by a new engine
+	heads, err = NewCollaborationEngine(store).DocumentHeads(ctx, "a.go")
+	if err != nil {
+		t.Fatalf("Failed to get document heads: %v", err)
+	}
+	if len(heads) != 1 || heads[0] != merge.ID {
+		t.Errorf("Expected the merge to be the only head, got %v", heads)
+	}
+	heads, _ = engine.DocumentHeads(ctx, "b.go")
+	if len(heads) != 1 || heads[0] != other.ID {
+		t.Errorf("Expected b.go's heads to be unchanged, got %v", heads)
+	}
+}
+
+func TestCollaborationEngine_UnknownParents(t *testing.T) {
+	ctx := gocontext.Background()
+	store := setupTestStorage(t)
+	authorID := operations.AuthorID("test_author")
+
+	newInsert := func(content string, value int64, parents ...operations.OperationID) *operations.Operation {
+		return &operations.Operation{
+			ID:   operations.NewOperationID([]byte(content)),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: authorID},
+			}),
+			Content:   content,
+			Author:    authorID,
+			Timestamp: time.Now(),
+			Parents:   parents,
+			Metadata: operations.OperationMeta{
+				Context: map[string]string{"document_id": "test.go"},
+			},
+		}
+	}
+
+	root := newInsert("root", 1)
+	if err := NewCollaborationEngine(store).ProcessOperation(ctx, root, "client"); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+
+	// A restarted engine finds the parent in the store
+	engine := NewCollaborationEngine(store)
+	if err := engine.ProcessOperation(ctx, newInsert("child", 2, root.ID), "client"); err != nil {
+		t.Fatalf("Failed to process operation with a stored parent: %v", err)
+	}
+
+	orphan := newInsert("orphan", 3, operations.NewOperationID([]byte("never applied")))
+	if err := engine.ProcessOperation(ctx, orphan, "client"); !errors.Is(err, operations.ErrCausalityViolation) {
+		t.Fatalf("Expected ErrCausalityViolation, got %v", err)
+	}
+	if _, err := store.GetOperation(ctx, orphan.ID); err == nil {
+		t.Error("Expected the orphan not to be stored")
+	}
+}
+
+func TestCollaborationEngine_Clock(t *testing.T) {
+	ctx := gocontext.Background()
+	store := setupTestStorage(t)
+	authorID := operations.AuthorID("test_author")
+
+	newInsert := func(content string, value int64) *operations.Operation {
+		return &operations.Operation{
+			ID:   operations.NewOperationID([]byte(content)),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: authorID},
+			}),
+			Content: content,
+			Author:  authorID,
+			// Ahead of the engine's clock, as if the author's clock ran fast
+			Timestamp: time.Now().Add(time.Hour),
+			Metadata: operations.OperationMeta{
+				Context: map[string]string{"document_id": "test.go"},
+			},
+		}
+	}
+
+	// A replicated operation keeps its clock, and later ones follow it
+	replicated := newInsert("replicated", 1)
+	replicated.Clock = operations.HLCFromTime(time.Now().Add(time.Minute))
+	if err := NewCollaborationEngine(store).ProcessOperation(ctx, replicated, "peer"); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+
+	// A restarted engine starts past the stored clocks
+	engine := NewCollaborationEngine(store)
+	previous := replicated.Clock
+	for i, content := range []string{"first", "second", "third"} {
+		op := newInsert(content, int64(i+2))
+		if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+		if op.Clock <= previous {
+			t.Fatalf("Expected %s to be stamped after %d, got %d", content, previous, op.Clock)
+		}
+		previous = op.Clock
+	}
+
+	ahead := newInsert("ahead", 5)
+	ahead.Clock = operations.HLCFromTime(time.Now().Add(operations.MaxClockDrift + time.Hour))
+	if err := engine.ProcessOperation(ctx, ahead, "peer"); !errors.Is(err, operations.ErrClockDrift) {
+		t.Fatalf("Expected ErrClockDrift, got %v", err)
+	}
+	if _, err := store.GetOperation(ctx, ahead.ID); err == nil {
+		t.Error("Expected the operation too far ahead not to be stored")
+	}
+}
+
 func setupTestStorage(t *testing.T) storage.Store {
 	store, err := storage.NewSQLiteStore(":memory:")
 	if err != nil {
@@ -244,3 +540,58 @@ func setupTestStorage(t *testing.T) storage.Store {
 	}
 	return store
 }
+
+func TestCollaborationEngine_UnappliableOperation(t *testing.T) {
+	ctx := gocontext.Background()
+	store := setupTestStorage(t)
+	engine := NewCollaborationEngine(store)
+	authorID := operations.AuthorID("test_author")
+
+	newOp := func(opType operations.OperationType, content string, value int64) *operations.Operation {
+		op := &operations.Operation{
+			Type: opType,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: authorID},
+			}),
+			Content:   content,
+			Author:    authorID,
+			Timestamp: time.Now(),
+			Metadata: operations.OperationMeta{
+				Context: map[string]string{"document_id": "test.go"},
+			},
+		}
+		op.ID = operations.ComputeID(op)
+		return op
+	}
+
+	text := newOp(operations.OpInsert, "package main\n", 1)
+	if err := engine.ProcessOperation(ctx, text, "client"); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+
+	// Patches that can't apply leave the operations and heads as they were
+	patch := `[{"op": "add", "path": "/a", "value": 1}]`
+	for _, op := range []*operations.Operation{
+		newOp(operations.OpPatch, patch, 1),
+		newOp(operations.OpPatch, patch, 2),
+	} {
+		op.ContentType = operations.ContentTypeJSON
+		op.ID = operations.ComputeID(op)
+		if _, err := engine.ProcessOperationAt(ctx, op, "client", nil, AssignParents()); err == nil {
+			t.Fatalf("Expected a patch of %s to fail", op.Position)
+		}
+		if _, err := store.GetOperation(ctx, op.ID); err == nil {
+			t.Errorf("Expected the failed patch of %s not to be stored", op.Position)
+		}
+	}
+	heads, err := NewCollaborationEngine(store).DocumentHeads(ctx, "test.go")
+	if err != nil {
+		t.Fatalf("Failed to get document heads: %v", err)
+	}
+	if len(heads) != 1 || heads[0] != text.ID {
+		t.Errorf("Expected the insert to stay the only head, got %v", heads)
+	}
+	if err := engine.ProcessOperation(ctx, newOp(operations.OpInsert, "func main() {}\n", 2), "client"); err != nil {
+		t.Errorf("Failed to process operation after a failed one: %v", err)
+	}
+}
@@ -5,10 +5,21 @@ import "errors"
 var (
 	ErrConnectionClosed     = errors.New("connection closed")
 	ErrSendBufferFull       = errors.New("send buffer full")
+	ErrSlowClient           = errors.New("client evicted for not keeping up with messages")
 	ErrClientNotFound       = errors.New("client not found")
 	ErrDocumentNotFound     = errors.New("document not found")
 	ErrInvalidMessage       = errors.New("invalid message format")
 	ErrOperationRejected    = errors.New("operation rejected")
 	ErrSyncFailed           = errors.New("synchronization failed")
 	ErrPresenceUpdateFailed = errors.New("presence update failed")
+	ErrEngineShutdown       = errors.New("collaboration engine is shutting down")
+	ErrVersionConflict      = errors.New("document version conflict")
+	ErrOperationReplayed    = errors.New("signed operation already applied")
+	ErrInvalidGraphRoot     = errors.New("invalid graph root")
+	ErrGraphRootNotFound    = errors.New("graph root not found")
+	ErrInvalidBranch        = errors.New("invalid branch")
+	ErrDocumentNotEmpty     = errors.New("document already has content")
+	ErrInvalidTemplate      = errors.New("invalid template")
+	ErrUnknownTemplate      = errors.New("unknown template")
+	ErrQueryTooExpensive    = errors.New("query too expensive")
 )
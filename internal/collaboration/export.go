@@ -0,0 +1,145 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// ExportOptions choose which documents ExportFiles writes and as they stood
+// when. The zero value writes every document on main as it is now.
+type ExportOptions struct {
+	Branch string
+	// Prefix limits the export to documents whose paths start with it
+	Prefix string
+	// At renders each document from its operations up to and including this
+	// time, leaving out documents that had none yet
+	At time.Time
+	// Version renders each document at no later than this version
+	Version uint64
+}
+
+// ExportedFile is one document ExportFiles wrote
+type ExportedFile struct {
+	DocumentID string `json:"document_id"`
+	Path       string `json:"path"`
+	Version    uint64 `json:"version"`
+	Bytes      int    `json:"bytes"`
+}
+
+// SkippedDocument is a document ExportFiles couldn't write, and why
+type SkippedDocument struct {
+	DocumentID string `json:"document_id"`
+	Reason     string `json:"reason"`
+}
+
+// FileExport is what ExportFiles wrote
+type FileExport struct {
+	Dir     string            `json:"dir"`
+	Files   []ExportedFile    `json:"files"`
+	Skipped []SkippedDocument `json:"skipped"`
+}
+
+// ExportFiles renders the stored documents into dir as plain files, each at
+// its path below dir, so the store can be turned back into a source tree.
+// Binary content is written decoded. Documents whose paths would land outside
+// dir are skipped, as are documents with no content at the point exported.
+// Rendering at a time or version replays operations, so history a retention
+// policy purged is missing from it.
+func (ce *CollaborationEngine) ExportFiles(ctx gocontext.Context, dir string, opts ExportOptions) (*FileExport, error) {
+	branch := branchName(opts.Branch)
+	if !operations.IsMainBranch(branch) {
+		if _, err := ce.store.GetBranch(ctx, branch); err != nil {
+			return nil, err
+		}
+	}
+
+	documentIDs, err := ce.store.ListDocuments(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &FileExport{Dir: dir, Files: []ExportedFile{}, Skipped: []SkippedDocument{}}
+	for _, documentID := range documentIDs {
+		if !strings.HasPrefix(documentID, opts.Prefix) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		rel := filepath.FromSlash(documentID)
+		if !filepath.IsLocal(rel) {
+			export.Skipped = append(export.Skipped, SkippedDocument{documentID, "path is outside the export directory"})
+			continue
+		}
+
+		doc, err := ce.DocumentAt(ctx, documentID, branch, opts.At, opts.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", documentID, err)
+		}
+		if doc.CurrentVersion() == 0 {
+			continue
+		}
+		content, err := doc.Bytes()
+		if err != nil {
+			export.Skipped = append(export.Skipped, SkippedDocument{documentID, err.Error()})
+			continue
+		}
+
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return nil, err
+		}
+		export.Files = append(export.Files, ExportedFile{
+			DocumentID: documentID,
+			Path:       path,
+			Version:    doc.CurrentVersion(),
+			Bytes:      len(content),
+		})
+	}
+	return export, nil
+}
+
+// DocumentAt renders a document on a branch as it stood at a time, at a
+// version, or at whichever of the two comes first. With neither it is the
+// document as it is now. The result is a copy the caller may change.
+func (ce *CollaborationEngine) DocumentAt(ctx gocontext.Context, documentID, branch string, at time.Time, version uint64) (*positioning.Document, error) {
+	if at.IsZero() && version == 0 {
+		current, err := ce.BranchDocument(ctx, documentID, branch)
+		if err != nil {
+			return nil, err
+		}
+		return positioning.RestoreDocument(current.Snapshot())
+	}
+
+	view, err := ce.branchView(ctx, branch, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := positioning.NewDocument(documentID)
+	err = ce.store.ForEachOperationMatching(ctx, storage.OperationFilter{DocumentID: documentID}, func(op *operations.Operation) error {
+		if !view.holds(op) || (!at.IsZero() && op.Timestamp.After(at)) {
+			return nil
+		}
+		if version != 0 && doc.CurrentVersion() >= version {
+			return nil
+		}
+		return doc.ApplyOperation(op)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
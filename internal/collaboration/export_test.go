@@ -0,0 +1,109 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestCollaborationEngine_ExportFiles(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+	authorID := operations.AuthorID("test_author")
+
+	insert := func(documentID string, value int64, content, contentType string, at time.Time) {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: authorID},
+			}),
+			Content:     content,
+			ContentType: contentType,
+			Author:      authorID,
+			Timestamp:   at,
+			Metadata:    operations.OperationMeta{DocumentID: documentID},
+		}
+		op.ID = operations.ComputeID(op)
+		if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+	}
+
+	// Operations are stored to the second, so the cutoff sits between whole seconds
+	earlier := time.Now().Add(-time.Hour).Truncate(time.Second)
+	insert("cmd/main.go", 10, "package main\n", "", earlier)
+	insert("cmd/main.go", 20, "func main() {}\n", "", earlier.Add(time.Minute))
+	insert("assets/logo.png", 10, operations.EncodeBinary([]byte{0x89, 'P', 'N', 'G', 0}), operations.ContentTypeBinary, earlier)
+	insert("README.md", 10, "# Export\n", "", earlier.Add(2*time.Minute))
+
+	read := func(dir, path string) string {
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		return string(data)
+	}
+
+	dir := t.TempDir()
+	export, err := engine.ExportFiles(ctx, dir, ExportOptions{})
+	if err != nil {
+		t.Fatalf("Failed to export files: %v", err)
+	}
+	if len(export.Files) != 3 || len(export.Skipped) != 0 {
+		t.Fatalf("Expected every document written, got %+v", export)
+	}
+	if got := read(dir, "cmd/main.go"); got != "package main\nfunc main() {}\n" {
+		t.Errorf("Expected the document rendered at its path, got %q", got)
+	}
+	if got := read(dir, "assets/logo.png"); got != "\x89PNG\x00" {
+		t.Errorf("Expected binary content decoded, got %q", got)
+	}
+
+	// At a time, documents stand as they did then and later ones are left out
+	dir = t.TempDir()
+	export, err = engine.ExportFiles(ctx, dir, ExportOptions{At: earlier.Add(30 * time.Second)})
+	if err != nil {
+		t.Fatalf("Failed to export files at a time: %v", err)
+	}
+	if len(export.Files) != 2 {
+		t.Fatalf("Expected the two documents that existed then, got %+v", export.Files)
+	}
+	if got := read(dir, "cmd/main.go"); got != "package main\n" {
+		t.Errorf("Expected the document as it stood then, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file for a document created later, got %v", err)
+	}
+
+	// At a version, with a prefix
+	dir = t.TempDir()
+	export, err = engine.ExportFiles(ctx, dir, ExportOptions{Version: 1, Prefix: "cmd/"})
+	if err != nil {
+		t.Fatalf("Failed to export files at a version: %v", err)
+	}
+	if len(export.Files) != 1 || export.Files[0].Version != 1 {
+		t.Fatalf("Expected only cmd/main.go at version 1, got %+v", export.Files)
+	}
+	if got := read(dir, "cmd/main.go"); got != "package main\n" {
+		t.Errorf("Expected the document at version 1, got %q", got)
+	}
+
+	// Paths that would escape the directory are never written
+	insert("../escape.txt", 10, "nope\n", "", earlier)
+	dir = t.TempDir()
+	export, err = engine.ExportFiles(ctx, filepath.Join(dir, "out"), ExportOptions{})
+	if err != nil {
+		t.Fatalf("Failed to export files: %v", err)
+	}
+	if len(export.Skipped) != 1 || export.Skipped[0].DocumentID != "../escape.txt" {
+		t.Errorf("Expected the escaping document skipped, got %+v", export.Skipped)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing written outside the directory, got %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// Reference graphs are walked breadth first from their root. Depth is counted
+// in edges and capped, as is the number of nodes, so a graph stays small
+// enough to draw.
+const (
+	DefaultGraphDepth = 2
+	MaxGraphDepth     = 5
+	MaxGraphNodes     = 500
+)
+
+type GraphNodeKind string
+
+const (
+	GraphNodeOperation GraphNodeKind = "operation"
+	GraphNodeThread    GraphNodeKind = "thread"
+	GraphNodeAddress   GraphNodeKind = "address"
+)
+
+type GraphEdgeKind string
+
+const (
+	GraphEdgeAnchoredTo GraphEdgeKind = "anchored_to" // thread -> address
+	GraphEdgeReferences GraphEdgeKind = "references"  // thread -> address
+	GraphEdgeCreatedBy  GraphEdgeKind = "created_by"  // address -> operation
+	GraphEdgeParent     GraphEdgeKind = "parent"      // operation -> parent operation
+)
+
+// GraphNode is an operation, thread or address. Address nodes carry the
+// address so clients can resolve it; their ID is the address's key.
+type GraphNode struct {
+	ID      string                    `json:"id"`
+	Kind    GraphNodeKind             `json:"kind"`
+	Label   string                    `json:"label"`
+	Depth   int                       `json:"depth"`
+	Address *addressing.StableAddress `json:"address,omitempty"`
+}
+
+type GraphEdge struct {
+	From string        `json:"from"`
+	To   string        `json:"to"`
+	Kind GraphEdgeKind `json:"kind"`
+}
+
+// ReferenceGraph is what is connected to a root node within some depth.
+// Truncated is set when MaxGraphNodes cut the walk short.
+type ReferenceGraph struct {
+	Root      string      `json:"root"`
+	Depth     int         `json:"depth"`
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+// ReferenceGraph walks the links between operations, the addresses they
+// created and the threads anchored to or referencing those addresses. root is
+// a thread ID, an operation ID or a JSON encoded stable address. Threads
+// viewer may not read are left out.
+func (ce *CollaborationEngine) ReferenceGraph(ctx gocontext.Context, root string, depth int, viewer context.Viewer) (*ReferenceGraph, error) {
+	if depth <= 0 {
+		depth = DefaultGraphDepth
+	}
+	depth = min(depth, MaxGraphDepth)
+
+	g := &graphBuilder{
+		ctx:    ctx,
+		engine: ce,
+		viewer: viewer,
+		graph:  &ReferenceGraph{Depth: depth, Nodes: []GraphNode{}, Edges: []GraphEdge{}},
+		nodes:  make(map[string]bool),
+		edges:  make(map[GraphEdge]bool),
+		ops:    make(map[operations.OperationID]*operations.Operation),
+	}
+
+	rootID, err := g.addRoot(root)
+	if err != nil {
+		return nil, err
+	}
+	g.graph.Root = rootID
+
+	// Nodes are appended as they are found, so walking the slice is breadth first
+	for i := 0; i < len(g.graph.Nodes); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if node := g.graph.Nodes[i]; node.Depth < depth {
+			if err := g.expand(node); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return g.graph, nil
+}
+
+type graphBuilder struct {
+	ctx    gocontext.Context
+	engine *CollaborationEngine
+	viewer context.Viewer
+	graph  *ReferenceGraph
+	nodes  map[string]bool
+	edges  map[GraphEdge]bool
+	ops    map[operations.OperationID]*operations.Operation
+}
+
+func (g *graphBuilder) addRoot(root string) (string, error) {
+	if strings.HasPrefix(root, "{") {
+		var addr addressing.StableAddress
+		if err := json.Unmarshal([]byte(root), &addr); err != nil || addr.OperationID == "" {
+			return "", fmt.Errorf("%w: not a stable address", ErrInvalidGraphRoot)
+		}
+		return g.addAddress(addr, 0), nil
+	}
+
+	if thread, err := g.engine.conversationManager.ViewConversation(context.ThreadID(root), g.viewer); err == nil {
+		return g.addThread(thread, 0), nil
+	}
+
+	opID := operations.OperationID(root)
+	op, err := g.operation(opID)
+	if err != nil {
+		return "", err
+	}
+	if op == nil {
+		return "", fmt.Errorf("%w: %s", ErrGraphRootNotFound, root)
+	}
+	return g.addOperation(opID, 0), nil
+}
+
+// operation loads an operation once per graph. A missing operation is nil
+// rather than an error, since parents may have been purged.
+func (g *graphBuilder) operation(id operations.OperationID) (*operations.Operation, error) {
+	if op, seen := g.ops[id]; seen {
+		return op, nil
+	}
+
+	op, err := g.engine.store.GetOperation(g.ctx, id)
+	if errors.Is(err, storage.ErrOperationNotFound) {
+		op, err = nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load operation %s: %w", id, err)
+	}
+	g.ops[id] = op
+	return op, nil
+}
+
+// add records a node unless it is already known or the graph is full
+func (g *graphBuilder) add(node GraphNode) {
+	if g.nodes[node.ID] {
+		return
+	}
+	if len(g.graph.Nodes) >= MaxGraphNodes {
+		g.graph.Truncated = true
+		return
+	}
+
+	g.nodes[node.ID] = true
+	g.graph.Nodes = append(g.graph.Nodes, node)
+}
+
+// link adds an edge between two nodes, unless either was left out of a full graph
+func (g *graphBuilder) link(from, to string, kind GraphEdgeKind) {
+	if !g.nodes[from] || !g.nodes[to] {
+		return
+	}
+
+	edge := GraphEdge{From: from, To: to, Kind: kind}
+	if !g.edges[edge] {
+		g.edges[edge] = true
+		g.graph.Edges = append(g.graph.Edges, edge)
+	}
+}
+
+func (g *graphBuilder) addThread(thread *context.ConversationThread, depth int) string {
+	g.add(GraphNode{ID: string(thread.ID), Kind: GraphNodeThread, Label: thread.Title, Depth: depth})
+	return string(thread.ID)
+}
+
+func (g *graphBuilder) addAddress(addr addressing.StableAddress, depth int) string {
+	id := string(addr.Key())
+	label := addr.Fragment
+	if label == "" {
+		label = "code from operation " + shortOperationID(addr.OperationID)
+	}
+	g.add(GraphNode{ID: id, Kind: GraphNodeAddress, Label: label, Depth: depth, Address: &addr})
+	return id
+}
+
+func (g *graphBuilder) addOperation(id operations.OperationID, depth int) string {
+	label := "missing operation " + shortOperationID(id)
+	if op := g.ops[id]; op != nil {
+		label = fmt.Sprintf("%s by %s", op.Type, op.Author)
+		if document := op.Metadata.DocumentID; document != "" {
+			label += " in " + document
+		}
+	}
+	g.add(GraphNode{ID: string(id), Kind: GraphNodeOperation, Label: label, Depth: depth})
+	return string(id)
+}
+
+func (g *graphBuilder) expand(node GraphNode) error {
+	next := node.Depth + 1
+
+	switch node.Kind {
+	case GraphNodeThread:
+		thread, err := g.engine.conversationManager.ViewConversation(context.ThreadID(node.ID), g.viewer)
+		if err != nil {
+			return nil
+		}
+		g.linkThread(thread, next, func(addressing.StableAddress) bool { return true })
+
+	case GraphNodeAddress:
+		addr := *node.Address
+		if _, err := g.operation(addr.OperationID); err != nil {
+			return err
+		}
+		g.link(node.ID, g.addOperation(addr.OperationID, next), GraphEdgeCreatedBy)
+
+		key := addr.Key()
+		for _, thread := range g.engine.conversationManager.GetConversationsByOperation(addr.OperationID) {
+			if g.viewer.CanView(thread) && threadMentions(thread, key) {
+				g.linkThread(thread, next, func(ref addressing.StableAddress) bool { return ref.Key() == key })
+			}
+		}
+
+	case GraphNodeOperation:
+		opID := operations.OperationID(node.ID)
+		if op := g.ops[opID]; op != nil {
+			for _, parent := range op.Parents {
+				if _, err := g.operation(parent); err != nil {
+					return err
+				}
+				g.link(node.ID, g.addOperation(parent, next), GraphEdgeParent)
+			}
+		}
+
+		// The code this operation created is reached through the threads that point at it
+		for _, thread := range g.engine.conversationManager.GetConversationsByOperation(opID) {
+			if !g.viewer.CanView(thread) {
+				continue
+			}
+			forEachAddress(thread, func(addr addressing.StableAddress, _ GraphEdgeKind) {
+				if addr.OperationID == opID {
+					g.link(g.addAddress(addr, next), node.ID, GraphEdgeCreatedBy)
+				}
+			})
+		}
+	}
+	return nil
+}
+
+// linkThread adds thread and the addresses it points at that match want,
+// linking them
+func (g *graphBuilder) linkThread(thread *context.ConversationThread, depth int, want func(addressing.StableAddress) bool) {
+	threadID := g.addThread(thread, depth)
+	forEachAddress(thread, func(addr addressing.StableAddress, kind GraphEdgeKind) {
+		if want(addr) {
+			g.link(threadID, g.addAddress(addr, depth), kind)
+		}
+	})
+}
+
+// forEachAddress calls fn with a thread's anchor and every address its
+// messages reference, skipping addresses without an operation
+func forEachAddress(thread *context.ConversationThread, fn func(addressing.StableAddress, GraphEdgeKind)) {
+	if thread.AnchorAddress.OperationID != "" {
+		fn(thread.AnchorAddress, GraphEdgeAnchoredTo)
+	}
+	for _, message := range thread.Messages {
+		for _, ref := range message.References {
+			if ref.OperationID != "" {
+				fn(ref, GraphEdgeReferences)
+			}
+		}
+	}
+}
+
+func threadMentions(thread *context.ConversationThread, key addressing.AddressKey) bool {
+	found := false
+	forEachAddress(thread, func(addr addressing.StableAddress, _ GraphEdgeKind) {
+		found = found || addr.Key() == key
+	})
+	return found
+}
+
+func shortOperationID(id operations.OperationID) string {
+	if len(id) > 12 {
+		return string(id[:12])
+	}
+	return string(id)
+}
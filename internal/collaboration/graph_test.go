@@ -0,0 +1,115 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestCollaborationEngine_ReferenceGraph(t *testing.T) {
+	ctx := gocontext.Background()
+	store := setupTestStorage(t)
+	engine := NewCollaborationEngine(store)
+
+	storeOp := func(content string, parents ...operations.OperationID) addressing.StableAddress {
+		pos := operations.NewLogootPosition([]operations.PositionSegment{
+			{Value: big.NewInt(int64(len(content))), AuthorID: "alice"},
+		})
+		op := &operations.Operation{
+			ID:        operations.NewOperationID([]byte(content)),
+			Type:      operations.OpInsert,
+			Position:  pos,
+			Content:   content,
+			Author:    "alice",
+			Timestamp: time.Now(),
+			Parents:   parents,
+		}
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+		return addressing.NewStableAddress("repo", op.ID, addressing.PositionRange{Start: pos, End: pos})
+	}
+
+	base := storeOp("package main\n")
+	child := storeOp("func main() {}\n", base.OperationID)
+	other := storeOp("// unrelated\n")
+
+	thread, _ := engine.CreateConversation(child, "alice", "Entry point", "Why is main empty?")
+	if _, err := engine.AddMessageToConversation(thread.ID, "bob", "See the package clause", context.MsgAnswer, base); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	engine.CreateConversation(other, "bob", "Unrelated", "Nothing to see")
+
+	graph, err := engine.ReferenceGraph(ctx, string(thread.ID), 2, context.Viewer{})
+	if err != nil {
+		t.Fatalf("Failed to build graph: %v", err)
+	}
+	kinds := make(map[string]GraphNodeKind)
+	for _, node := range graph.Nodes {
+		kinds[node.ID] = node.Kind
+	}
+	expected := map[string]GraphNodeKind{
+		string(thread.ID):         GraphNodeThread,
+		string(child.Key()):       GraphNodeAddress,
+		string(base.Key()):        GraphNodeAddress,
+		string(child.OperationID): GraphNodeOperation,
+		string(base.OperationID):  GraphNodeOperation,
+	}
+	if len(kinds) != len(expected) {
+		t.Errorf("Expected %d nodes, got %+v", len(expected), graph.Nodes)
+	}
+	for id, kind := range expected {
+		if kinds[id] != kind {
+			t.Errorf("Expected %s to be a %s node, got %q", id, kind, kinds[id])
+		}
+	}
+
+	edges := make(map[GraphEdge]bool)
+	for _, edge := range graph.Edges {
+		edges[edge] = true
+	}
+	for _, edge := range []GraphEdge{
+		{From: string(thread.ID), To: string(child.Key()), Kind: GraphEdgeAnchoredTo},
+		{From: string(thread.ID), To: string(base.Key()), Kind: GraphEdgeReferences},
+		{From: string(child.Key()), To: string(child.OperationID), Kind: GraphEdgeCreatedBy},
+	} {
+		if !edges[edge] {
+			t.Errorf("Expected edge %+v, got %+v", edge, graph.Edges)
+		}
+	}
+
+	// From an operation the walk reaches the conversation through its code, and its parent
+	graph, err = engine.ReferenceGraph(ctx, string(child.OperationID), 2, context.Viewer{})
+	if err != nil {
+		t.Fatalf("Failed to build graph: %v", err)
+	}
+	found := make(map[string]bool)
+	for _, node := range graph.Nodes {
+		found[node.ID] = true
+	}
+	if !found[string(thread.ID)] || !found[string(base.OperationID)] || found[string(other.OperationID)] {
+		t.Errorf("Expected the thread and parent but not the unrelated operation, got %+v", graph.Nodes)
+	}
+
+	root, _ := json.Marshal(base)
+	if graph, err = engine.ReferenceGraph(ctx, string(root), 1, context.Viewer{}); err != nil {
+		t.Fatalf("Failed to build graph from an address: %v", err)
+	}
+	if len(graph.Nodes) != 3 {
+		t.Errorf("Expected the address, its operation and the referencing thread, got %+v", graph.Nodes)
+	}
+
+	if _, err := engine.ReferenceGraph(ctx, "missing", 2, context.Viewer{}); !errors.Is(err, ErrGraphRootNotFound) {
+		t.Errorf("Expected ErrGraphRootNotFound, got %v", err)
+	}
+	if _, err := engine.ReferenceGraph(ctx, "{not json", 2, context.Viewer{}); !errors.Is(err, ErrInvalidGraphRoot) {
+		t.Errorf("Expected ErrInvalidGraphRoot, got %v", err)
+	}
+}
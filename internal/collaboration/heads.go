@@ -0,0 +1,117 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"slices"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// ProcessOption changes how ProcessOperationAt applies an operation
+type ProcessOption func(*processOptions)
+
+type processOptions struct {
+	assignParents bool
+}
+
+// AssignParents makes an operation without parents follow on from the
+// current heads of its document, and derives its ID again to cover them. It
+// is for operations whose ID the server chose; an operation that arrives with
+// its own ID or a signature keeps the parents it was made with.
+func AssignParents() ProcessOption {
+	return func(o *processOptions) {
+		o.assignParents = true
+	}
+}
+
+// DocumentHeads returns the operations in a document that no other operation
+// in it builds on, oldest first
+func (ce *CollaborationEngine) DocumentHeads(ctx gocontext.Context, documentID string) ([]operations.OperationID, error) {
+	lock := ce.documentLock(documentID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	heads, err := ce.documentHeads(ctx, documentID, operations.MainBranch)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(heads), nil
+}
+
+// documentHeads returns the heads of a document on a branch, reading them
+// from its stored operations the first time. A branch without operations of
+// its own in the document follows on from its base. The caller holds the
+// document's lock, or the branch's for other branches.
+func (ce *CollaborationEngine) documentHeads(ctx gocontext.Context, documentID, branch string) ([]operations.OperationID, error) {
+	key := lineKey(documentID, branch)
+	ce.mutex.RLock()
+	heads, loaded := ce.heads[key]
+	ce.mutex.RUnlock()
+	if loaded {
+		return heads, nil
+	}
+
+	line, err := ce.branchLine(ctx, branch, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []operations.OperationID
+	hasChild := make(map[operations.OperationID]bool)
+	err = ce.store.ForEachOperationMatching(ctx, storage.OperationFilter{DocumentID: documentID}, func(op *operations.Operation) error {
+		if line.holds(op) {
+			ids = append(ids, op.ID)
+			for _, parent := range op.Parents {
+				hasChild[parent] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Left uncached, so the branch keeps following its base until it has operations
+	if len(ids) == 0 && !operations.IsMainBranch(branch) {
+		b, err := ce.store.GetBranch(ctx, branch)
+		if err != nil {
+			return nil, err
+		}
+		return ce.documentHeads(ctx, documentID, b.Base)
+	}
+
+	heads = make([]operations.OperationID, 0)
+	for _, id := range ids {
+		if !hasChild[id] {
+			heads = append(heads, id)
+		}
+	}
+
+	ce.mutex.Lock()
+	ce.heads[key] = heads
+	ce.mutex.Unlock()
+	return heads, nil
+}
+
+// advanceHeads replaces the heads op builds on with op itself. The caller
+// holds the lock documentHeads needs.
+func (ce *CollaborationEngine) advanceHeads(ctx gocontext.Context, documentID, branch string, op *operations.Operation) error {
+	heads, err := ce.documentHeads(ctx, documentID, branch)
+	if err != nil {
+		return err
+	}
+
+	next := make([]operations.OperationID, 0, len(heads)+1)
+	for _, head := range heads {
+		if head != op.ID && !slices.Contains(op.Parents, head) {
+			next = append(next, head)
+		}
+	}
+	next = append(next, op.ID)
+
+	ce.mutex.Lock()
+	ce.heads[lineKey(documentID, branch)] = next
+	ce.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,25 @@
+package collaboration
+
+import (
+	gocontext "context"
+
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// StoreHealth probes the store for health checks
+func (ce *CollaborationEngine) StoreHealth(ctx gocontext.Context) (*storage.StoreHealth, error) {
+	return ce.store.Health(ctx)
+}
+
+// BroadcasterStats reports on the clients receiving live updates, WebSocket
+// clients included
+func (ce *CollaborationEngine) BroadcasterStats() BroadcasterStats {
+	stats := ce.broadcaster.Stats()
+	pressure := ce.clientBackpressure()
+	stats.Clients += pressure.clients
+	stats.Saturated += pressure.saturated
+	stats.Dropped += int64(pressure.droppedMessages + pressure.droppedPresence)
+	stats.Evicted = ce.slowClientEvictions.Load()
+	stats.RelayErrors = ce.relayErrors.Load()
+	return stats
+}
@@ -0,0 +1,59 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// LoadAuthorAliases indexes conversations by the identities saved in the
+// store. Queries the store answers resolve aliases by themselves.
+func (ce *CollaborationEngine) LoadAuthorAliases(ctx gocontext.Context) error {
+	aliases, err := ce.store.AuthorAliases(ctx)
+	if err != nil {
+		return err
+	}
+	ce.conversationManager.SetAuthorAliases(aliases)
+	return nil
+}
+
+// AuthorAliases returns the author IDs merged into others
+func (ce *CollaborationEngine) AuthorAliases() context.AuthorAliases {
+	return ce.conversationManager.AuthorAliases()
+}
+
+// MergeAuthors declares aliases to be other IDs of author, so queries for
+// any of them find the work of all. An author that is already an alias merges
+// into its identity. It returns the identity as merged.
+func (ce *CollaborationEngine) MergeAuthors(ctx gocontext.Context, author operations.AuthorID, aliases ...operations.AuthorID) (context.Identity, error) {
+	if strings.TrimSpace(string(author)) == "" {
+		return context.Identity{}, fmt.Errorf("%w: author is empty", operations.ErrInvalidAuthor)
+	}
+	if len(aliases) == 0 {
+		return context.Identity{}, fmt.Errorf("%w: no aliases to merge", operations.ErrInvalidAuthor)
+	}
+	for _, alias := range aliases {
+		if strings.TrimSpace(string(alias)) == "" {
+			return context.Identity{}, fmt.Errorf("%w: alias is empty", operations.ErrInvalidAuthor)
+		}
+	}
+
+	if err := ce.store.MergeAuthors(ctx, author, aliases); err != nil {
+		return context.Identity{}, err
+	}
+	if err := ce.LoadAuthorAliases(ctx); err != nil {
+		return context.Identity{}, err
+	}
+	return ce.AuthorAliases().Identity(author), nil
+}
+
+// SplitAuthor makes alias an author of its own again
+func (ce *CollaborationEngine) SplitAuthor(ctx gocontext.Context, alias operations.AuthorID) error {
+	if err := ce.store.RemoveAuthorAlias(ctx, alias); err != nil {
+		return err
+	}
+	return ce.LoadAuthorAliases(ctx)
+}
@@ -0,0 +1,101 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestCollaborationEngine_MergeAuthors(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+	start := time.Now()
+
+	var root *operations.Operation
+	for i, author := range []operations.AuthorID{"alice", "alice@laptop", "bob"} {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(int64(i + 1)), AuthorID: author},
+			}),
+			Content:   "x",
+			Author:    author,
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Metadata:  operations.OperationMeta{DocumentID: "main.go"},
+		}
+		if root != nil {
+			op.Parents = []operations.OperationID{root.ID}
+		}
+		op.ID = operations.ComputeID(op)
+		if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+		if root == nil {
+			root = op
+		}
+	}
+	if _, err := engine.ConversationManager().CreateConversation(addressing.StableAddress{}, "alice@laptop", "From the laptop", "Is this right?"); err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+
+	if _, err := engine.MergeAuthors(ctx, "alice"); !errors.Is(err, operations.ErrInvalidAuthor) {
+		t.Errorf("Expected ErrInvalidAuthor without aliases, got %v", err)
+	}
+	identity, err := engine.MergeAuthors(ctx, "alice", "alice@laptop")
+	if err != nil {
+		t.Fatalf("Failed to merge authors: %v", err)
+	}
+	if identity.Author != "alice" || !slices.Equal(identity.Aliases, []operations.AuthorID{"alice@laptop"}) {
+		t.Fatalf("Expected alice with one alias, got %+v", identity)
+	}
+
+	threads, err := engine.ConversationManager().GetConversationsByAuthor("alice")
+	if err != nil || len(threads) != 1 {
+		t.Fatalf("Expected the alias's conversation for alice, got %d (%v)", len(threads), err)
+	}
+
+	result, err := engine.QueryOperations(ctx, OperationQuery{Where: OperationPredicate{Author: "alice@laptop"}}, context.Viewer{})
+	if err != nil {
+		t.Fatalf("Failed to query operations: %v", err)
+	}
+	if len(result.Operations) != 2 {
+		t.Errorf("Expected both of alice's operations from either ID, got %d", len(result.Operations))
+	}
+	result, err = engine.QueryOperations(ctx, OperationQuery{Where: OperationPredicate{DescendsFrom: root.ID, Author: "alice"}}, context.Viewer{})
+	if err != nil {
+		t.Fatalf("Failed to query operations: %v", err)
+	}
+	if len(result.Operations) != 2 || result.Operations[1].Operation.Author != "alice@laptop" {
+		t.Errorf("Expected root and the alias's descendant for alice, got %d", len(result.Operations))
+	}
+
+	period := context.TimePeriod{Start: start.Add(-time.Minute), End: start.Add(time.Minute)}
+	report, err := engine.ActivityReport(ctx, period, "alice@laptop")
+	if err != nil {
+		t.Fatalf("Failed to build activity report: %v", err)
+	}
+	if len(report.Authors) != 1 || report.Authors[0].AuthorID != "alice" {
+		t.Fatalf("Expected one report under alice, got %+v", report.Authors)
+	}
+	if report.Authors[0].Summary.TotalOperations != 2 || report.Summary.Conversations != 1 {
+		t.Errorf("Expected both operations and the conversation, got %+v", report.Authors[0].Summary)
+	}
+
+	if err := engine.SplitAuthor(ctx, "alice@laptop"); err != nil {
+		t.Fatalf("Failed to split author: %v", err)
+	}
+	if threads, _ := engine.ConversationManager().GetConversationsByAuthor("alice"); len(threads) != 0 {
+		t.Errorf("Expected no conversations for alice after splitting, got %d", len(threads))
+	}
+	if err := engine.SplitAuthor(ctx, "alice@laptop"); !errors.Is(err, storage.ErrAliasNotFound) {
+		t.Errorf("Expected ErrAliasNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/ids"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+// ImportTool is recorded in the metadata of operations made by importing a file
+const ImportTool = "import"
+
+// FileImportOptions shape how a file is imported. The zero value imports it
+// on main, a construct per line.
+type FileImportOptions struct {
+	Chunking positioning.ChunkMode
+	// Language is the file's language, such as "go"
+	Language string
+	Branch   string
+}
+
+// FileImport is what importing a file created
+type FileImport struct {
+	DocumentID string                `json:"document_id"`
+	SessionID  string                `json:"session_id"`
+	Chunking   positioning.ChunkMode `json:"chunking"`
+	Operations int                   `json:"operations"`
+	Version    uint64                `json:"version"`
+}
+
+// ImportFile brings an existing file's content in as a document, splitting
+// it into constructs and inserting each under one import session. Only a
+// document without content can be imported into; each insert is applied at
+// the version the one before left, so a concurrent write stops the import
+// with a *VersionConflictError.
+func (ce *CollaborationEngine) ImportFile(ctx gocontext.Context, documentID, content string, author operations.AuthorID, opts FileImportOptions) (*FileImport, error) {
+	if opts.Chunking == "" {
+		opts.Chunking = positioning.ChunkLines
+	}
+	if !opts.Chunking.IsValid() {
+		return nil, fmt.Errorf("unknown chunking %q", opts.Chunking)
+	}
+
+	doc, err := ce.BranchDocument(ctx, documentID, opts.Branch)
+	if err != nil {
+		return nil, err
+	}
+	if doc.ConstructCount() > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDocumentNotEmpty, documentID)
+	}
+
+	imported := &FileImport{
+		DocumentID: documentID,
+		SessionID:  ids.NewWithPrefix("import"),
+		Chunking:   opts.Chunking,
+		Version:    doc.CurrentVersion(),
+	}
+	now := time.Now()
+	var previous operations.LogootPosition
+	for _, chunk := range positioning.Chunk(content, opts.Chunking) {
+		op := &operations.Operation{
+			Type:      operations.OpInsert,
+			Position:  operations.GeneratePosition(previous, operations.LogootPosition{}, author),
+			Content:   chunk,
+			Author:    author,
+			Timestamp: now,
+			Metadata: operations.OperationMeta{
+				SessionID:  imported.SessionID,
+				DocumentID: documentID,
+				Branch:     opts.Branch,
+				Tool:       ImportTool,
+				Language:   opts.Language,
+			},
+		}
+		op.ID = operations.ComputeID(op)
+		if err := ce.VerifyOperation(ctx, op); err != nil {
+			return nil, err
+		}
+
+		expected := imported.Version
+		if imported.Version, err = ce.ProcessOperationAt(ctx, op, ClientID(author), &expected, AssignParents()); err != nil {
+			return nil, fmt.Errorf("failed to import %s: %w", documentID, err)
+		}
+		imported.Operations++
+		previous = op.Position
+	}
+	return imported, nil
+}
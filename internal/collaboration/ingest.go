@@ -0,0 +1,146 @@
+package collaboration
+
+import (
+	"bufio"
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const (
+	// IngestClient is the client ingested operations are applied as
+	IngestClient ClientID = "ingest"
+	// DefaultIngestProgressInterval is how many records go by between
+	// progress reports
+	DefaultIngestProgressInterval = 1000
+)
+
+// IngestOptions shape how a stream of operations is ingested. The zero value
+// keeps going past rejected records and reports no progress.
+type IngestOptions struct {
+	// Author is given to operations that name none
+	Author operations.AuthorID
+	// Rejected receives a RejectedRecord line for each record not applied
+	Rejected io.Writer
+	// Progress is called every ProgressInterval records and once at the end
+	Progress         func(IngestResult)
+	ProgressInterval int
+	// StopOnError ends the ingest at the first rejected record, returning
+	// its error
+	StopOnError bool
+}
+
+// IngestResult counts the records read so far. Skipped records name the ID of
+// an operation already stored, so a stream of operations with IDs can be
+// ingested again safely.
+type IngestResult struct {
+	Records  int `json:"records"`
+	Applied  int `json:"applied"`
+	Skipped  int `json:"skipped"`
+	Rejected int `json:"rejected"`
+}
+
+// RejectedRecord is a record that couldn't be applied, as written to
+// IngestOptions.Rejected. Line counts from 1.
+type RejectedRecord struct {
+	Line   int    `json:"line"`
+	Error  string `json:"error"`
+	Record string `json:"record"`
+}
+
+// IngestOperations applies newline-delimited JSON operations read from r, one
+// per line, in order. Each is filled in and validated as an operation sent to
+// the API would be: author, timestamp and ID may be left out, and operations
+// without an ID follow on from their document's heads. Blank lines are
+// ignored. A record that fails is rejected and the rest still ingested,
+// unless opts.StopOnError says otherwise; failing to read r or to write a
+// rejection ends the ingest with that error.
+func (ce *CollaborationEngine) IngestOperations(ctx gocontext.Context, r io.Reader, opts IngestOptions) (*IngestResult, error) {
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = DefaultIngestProgressInterval
+	}
+	var rejected *json.Encoder
+	if opts.Rejected != nil {
+		rejected = json.NewEncoder(opts.Rejected)
+	}
+
+	result := &IngestResult{}
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		record, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return result, fmt.Errorf("failed to read line %d: %w", line, readErr)
+		}
+
+		if record = bytes.TrimSpace(record); len(record) > 0 {
+			result.Records++
+			applied, err := ce.ingestRecord(ctx, record, opts.Author)
+			switch {
+			case err != nil:
+				result.Rejected++
+				if rejected != nil {
+					if writeErr := rejected.Encode(RejectedRecord{Line: line, Error: err.Error(), Record: string(record)}); writeErr != nil {
+						return result, fmt.Errorf("failed to record rejection: %w", writeErr)
+					}
+				}
+				if opts.StopOnError {
+					return result, fmt.Errorf("line %d: %w", line, err)
+				}
+			case applied:
+				result.Applied++
+			default:
+				result.Skipped++
+			}
+
+			if opts.Progress != nil && result.Records%opts.ProgressInterval == 0 {
+				opts.Progress(*result)
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if opts.Progress != nil && (result.Records == 0 || result.Records%opts.ProgressInterval != 0) {
+		opts.Progress(*result)
+	}
+	return result, nil
+}
+
+// ingestRecord applies one record, reporting false when its operation was
+// already stored
+func (ce *CollaborationEngine) ingestRecord(ctx gocontext.Context, record []byte, author operations.AuthorID) (bool, error) {
+	var op operations.Operation
+	if err := json.Unmarshal(record, &op); err != nil {
+		return false, fmt.Errorf("%w: %v", operations.ErrInvalidOperation, err)
+	}
+
+	opts, err := ce.prepareOperation(ctx, &op, author)
+	if err != nil {
+		return false, err
+	}
+	// Without an ID an operation follows on from the heads as they are now,
+	// so it is always a new one
+	if len(opts) == 0 {
+		if _, err := ce.store.GetOperation(ctx, op.ID); err == nil {
+			return false, nil
+		} else if !errors.Is(err, storage.ErrOperationNotFound) {
+			return false, err
+		}
+	}
+
+	if _, err := ce.ProcessOperationAt(ctx, &op, IngestClient, nil, opts...); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,92 @@
+package collaboration
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestCollaborationEngine_IngestOperations(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+
+	identified := &operations.Operation{
+		Type: operations.OpInsert,
+		Position: operations.NewLogootPosition([]operations.PositionSegment{
+			{Value: big.NewInt(1), AuthorID: "alice"},
+		}),
+		Content:   "package main\n",
+		Author:    "alice",
+		Timestamp: time.Now().Add(-time.Hour).Truncate(time.Second),
+		Metadata:  operations.OperationMeta{DocumentID: "main.go"},
+	}
+	identified.ID = operations.ComputeID(identified)
+	first, err := json.Marshal(identified)
+	if err != nil {
+		t.Fatalf("Failed to encode operation: %v", err)
+	}
+	second := `{"type":"insert","position":{"segments":[{"value":2,"author_id":"bob"}]},"content":"func main() {}\n","metadata":{"document_id":"main.go"}}`
+
+	stream := strings.Join([]string{
+		string(first),
+		"",
+		second,
+		"not json",
+		`{"type":"insert","content":"lost\n"}`,
+		string(first),
+	}, "\n")
+
+	var rejected bytes.Buffer
+	var progress []IngestResult
+	result, err := engine.IngestOperations(ctx, strings.NewReader(stream), IngestOptions{
+		Author:           "etl",
+		Rejected:         &rejected,
+		Progress:         func(r IngestResult) { progress = append(progress, r) },
+		ProgressInterval: 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to ingest operations: %v", err)
+	}
+	if *result != (IngestResult{Records: 5, Applied: 2, Skipped: 1, Rejected: 2}) {
+		t.Errorf("Expected 2 applied, 1 skipped and 2 rejected of 5, got %+v", result)
+	}
+	if len(progress) != 3 || progress[2] != *result {
+		t.Errorf("Expected progress every 2 records and at the end, got %+v", progress)
+	}
+
+	var lines []RejectedRecord
+	decoder := json.NewDecoder(&rejected)
+	for decoder.More() {
+		var record RejectedRecord
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("Failed to decode rejection: %v", err)
+		}
+		lines = append(lines, record)
+	}
+	if len(lines) != 2 || lines[0].Line != 4 || lines[0].Record != "not json" || lines[1].Line != 5 || lines[1].Error == "" {
+		t.Errorf("Expected lines 4 and 5 rejected with their records, got %+v", lines)
+	}
+
+	doc, err := engine.GetDocumentState(ctx, "main.go")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if content, _ := doc.Render(); content != "package main\nfunc main() {}\n" {
+		t.Errorf("Expected both operations applied, got %q", content)
+	}
+	heads, _ := engine.DocumentHeads(ctx, "main.go")
+	if len(heads) != 1 || heads[0] == identified.ID {
+		t.Errorf("Expected the operation without an ID to follow on from the other, got heads %v", heads)
+	}
+
+	_, err = engine.IngestOperations(ctx, strings.NewReader("not json\n"+second), IngestOptions{StopOnError: true})
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected the ingest to stop at line 1, got %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+package collaboration
+
+import (
+	gocontext "context"
+
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// CheckIntegrity verifies the store's references, content hashes and schema.
+// With repair set, orphaned records are removed; see storage.IntegrityStore.
+func (ce *CollaborationEngine) CheckIntegrity(ctx gocontext.Context, repair bool) (*storage.CheckReport, error) {
+	return ce.store.Check(ctx, repair)
+}
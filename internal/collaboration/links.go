@@ -0,0 +1,110 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"slices"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// LinkTicketOperations links a thread to the operations already written with
+// its linked issue as their ticket, for threads created after the work they
+// track. Operations written later are linked as they are processed.
+func (ce *CollaborationEngine) LinkTicketOperations(ctx gocontext.Context, threadID context.ThreadID) (*context.ConversationThread, error) {
+	thread, err := ce.conversationManager.GetConversation(threadID)
+	if err != nil {
+		return nil, err
+	}
+	if thread.Metadata.LinkedIssue == "" {
+		return thread, nil
+	}
+
+	filter := storage.OperationFilter{Ticket: thread.Metadata.LinkedIssue}
+	if err := ce.store.ForEachOperationMatching(ctx, filter, func(op *operations.Operation) error {
+		ce.conversationManager.LinkOperation(op)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ce.conversationManager.GetConversation(threadID)
+}
+
+// Ticket is everything connected to an issue: the operations written for it,
+// the change sets they are part of and the conversations tracking the issue
+// or linked to its operations
+type Ticket struct {
+	Key           string                        `json:"key"`
+	URL           string                        `json:"url,omitempty"`
+	Operations    []*operations.Operation       `json:"operations"`
+	ChangeSets    []*context.ChangeSet          `json:"changesets"`
+	Conversations []*context.ConversationThread `json:"conversations"`
+}
+
+// Ticket gathers what is connected to the issue key, which may also be given
+// as a Jira or Linear URL. Operations and change sets are oldest first,
+// conversations most recently updated first, and those viewer may not read
+// are left out.
+func (ce *CollaborationEngine) Ticket(ctx gocontext.Context, key string, viewer context.Viewer) (*Ticket, error) {
+	ticket := &Ticket{Key: strings.TrimSpace(key)}
+	if link, err := context.ParseIssueLink(key); err == nil {
+		ticket.Key, ticket.URL = link.Key, link.URL
+	}
+
+	ticket.Operations = []*operations.Operation{}
+	opIDs := make(map[operations.OperationID]bool)
+	var authors []operations.AuthorID
+	if err := ce.store.ForEachOperationMatching(ctx, storage.OperationFilter{Ticket: ticket.Key}, func(op *operations.Operation) error {
+		ticket.Operations = append(ticket.Operations, op)
+		opIDs[op.ID] = true
+		if !slices.Contains(authors, op.Author) {
+			authors = append(authors, op.Author)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	ticket.ChangeSets = []*context.ChangeSet{}
+	for _, author := range authors {
+		changeSets, err := ce.clusterChangeSets(ctx, author)
+		if err != nil {
+			return nil, err
+		}
+		for _, cs := range changeSets {
+			if slices.ContainsFunc(cs.Operations, func(id operations.OperationID) bool { return opIDs[id] }) {
+				ticket.ChangeSets = append(ticket.ChangeSets, cs)
+			}
+		}
+	}
+	slices.SortStableFunc(ticket.ChangeSets, func(a, b *context.ChangeSet) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	conversations, err := ce.conversationManager.ListConversations(context.ConversationFilter{Issue: ticket.Key, Viewer: viewer})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[context.ThreadID]bool, len(conversations))
+	for _, thread := range conversations {
+		seen[thread.ID] = true
+		if ticket.URL == "" {
+			ticket.URL = thread.Metadata.IssueURL
+		}
+	}
+	for _, op := range ticket.Operations {
+		for _, thread := range ce.conversationManager.GetConversationsByOperation(op.ID) {
+			if !seen[thread.ID] && viewer.CanView(thread) && slices.Contains(thread.LinkedOperations, op.ID) {
+				seen[thread.ID] = true
+				conversations = append(conversations, thread)
+			}
+		}
+	}
+	slices.SortStableFunc(conversations, func(a, b *context.ConversationThread) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	ticket.Conversations = conversations
+	return ticket, nil
+}
@@ -0,0 +1,92 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestCollaborationEngine_LinkTicketOperations(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+
+	insert := func(value int64, ticket string) *operations.Operation {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: "alice"},
+			}),
+			Content:   "x",
+			Author:    "alice",
+			Timestamp: time.Now().Add(time.Duration(value) * time.Second),
+			Metadata:  operations.OperationMeta{DocumentID: "retry.go", Ticket: ticket},
+		}
+		op.ID = operations.ComputeID(op)
+		if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+		return op
+	}
+
+	// Written before anyone started discussing the ticket
+	before := insert(1, "ENG-42")
+	thread, err := engine.ConversationManager().CreateConversation(addressing.StableAddress{}, "bob", "Retry storm", "Why now?", context.WithLinkedIssue("ENG-42"))
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if thread, err = engine.LinkTicketOperations(ctx, thread.ID); err != nil {
+		t.Fatalf("Failed to link operations: %v", err)
+	}
+	if !slices.Equal(thread.LinkedOperations, []operations.OperationID{before.ID}) {
+		t.Errorf("Expected the earlier operation linked, got %v", thread.LinkedOperations)
+	}
+
+	after := insert(2, "ENG-42")
+	insert(3, "ENG-7")
+	if thread, err = engine.GetConversation(thread.ID); err != nil {
+		t.Fatalf("Failed to get conversation: %v", err)
+	}
+	if !slices.Equal(thread.LinkedOperations, []operations.OperationID{before.ID, after.ID}) {
+		t.Errorf("Expected operations linked as they are processed, got %v", thread.LinkedOperations)
+	}
+
+	opContext, err := engine.GetOperationContext(after.ID)
+	if err != nil {
+		t.Fatalf("Failed to get operation context: %v", err)
+	}
+	if len(opContext.Discussions) != 1 || opContext.Discussions[0].ID != thread.ID {
+		t.Errorf("Expected the linked thread among the operation's discussions, got %+v", opContext.Discussions)
+	}
+
+	private, err := engine.ConversationManager().CreateConversation(addressing.StableAddress{}, "carol", "Postmortem", "Draft",
+		context.WithLinkedIssue("https://acme.atlassian.net/browse/eng-42"), context.WithVisibility(context.VisibilityPrivate))
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if private.Metadata.LinkedIssue != "ENG-42" || private.Metadata.IssueURL != "https://acme.atlassian.net/browse/eng-42" {
+		t.Errorf("Expected the issue URL reduced to its key, got %+v", private.Metadata)
+	}
+
+	ticket, err := engine.Ticket(ctx, "eng-42", context.ViewerFor("bob"))
+	if err != nil {
+		t.Fatalf("Failed to get ticket: %v", err)
+	}
+	if ticket.Key != "ENG-42" || len(ticket.Operations) != 2 || ticket.Operations[0].ID != before.ID {
+		t.Errorf("Expected the ticket's operations oldest first, got %+v", ticket)
+	}
+	if len(ticket.ChangeSets) != 1 || len(ticket.ChangeSets[0].Operations) != 3 {
+		t.Errorf("Expected the change set holding the ticket's operations, got %+v", ticket.ChangeSets)
+	}
+	if len(ticket.Conversations) != 1 || ticket.Conversations[0].ID != thread.ID {
+		t.Errorf("Expected only the conversation bob may read, got %+v", ticket.Conversations)
+	}
+	if ticket, err = engine.Ticket(ctx, "ENG-42", context.Viewer{}); err != nil || len(ticket.Conversations) != 2 || ticket.URL == "" {
+		t.Errorf("Expected both conversations and the issue URL, got %+v, %v", ticket, err)
+	}
+}
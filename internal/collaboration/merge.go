@@ -0,0 +1,324 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/events"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// MergeConflictDistance is how many positions apart changes from either side
+// of a merge can land and still be in the same region
+const MergeConflictDistance = 2
+
+// MergeReport is what merging one document between branches did, or would do
+type MergeReport struct {
+	DocumentID string `json:"document_id"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	// Applied are the operations on From that To lacked, in the order they
+	// were applied
+	Applied []operations.OperationID `json:"applied"`
+	// Version is the document's version on To after the merge
+	Version  uint64                `json:"version"`
+	Document *positioning.Document `json:"document"`
+	// Conflicts are the regions both branches changed since they diverged
+	Conflicts []MergeConflict `json:"conflicts"`
+	DryRun    bool            `json:"dry_run,omitempty"`
+}
+
+// MergeConflict is a region of a document where both sides of a merge
+// changed the same kind of construct. The CRDT keeps both changes, but
+// together they may not make sense, so someone should look.
+type MergeConflict struct {
+	ConstructType positioning.ConstructType `json:"construct_type"`
+	// Start and End are the first and last positions changed in the region
+	Start operations.LogootPosition `json:"start"`
+	End   operations.LogootPosition `json:"end"`
+	// From and To are each side's operations in the region
+	From []operations.OperationID `json:"from"`
+	To   []operations.OperationID `json:"to"`
+}
+
+// MergeDocument merges one document from branch from into branch to, main
+// when to is empty, leaving from open. The operations to lacks are applied
+// in timestamp order; where they land near changes to made since the two
+// diverged, the report lists a conflict. A dry run reports without merging.
+func (ce *CollaborationEngine) MergeDocument(ctx gocontext.Context, documentID, from, to string, dryRun bool) (*MergeReport, error) {
+	from, to = branchName(from), branchName(to)
+	if from == to {
+		return nil, fmt.Errorf("%w: %s can't be merged into itself", ErrInvalidBranch, from)
+	}
+	if !operations.IsMainBranch(from) {
+		if _, err := ce.store.GetBranch(ctx, from); err != nil {
+			return nil, err
+		}
+	}
+	if !operations.IsMainBranch(to) {
+		if _, err := ce.openBranch(ctx, to); err != nil {
+			return nil, err
+		}
+	}
+
+	fromDoc, err := ce.BranchDocument(ctx, documentID, from)
+	if err != nil {
+		return nil, err
+	}
+	toDoc, err := ce.BranchDocument(ctx, documentID, to)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := ce.loadBranchGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fromView, toView := graph.view(from, documentID), graph.view(to, documentID)
+
+	// to's changes are those from's history doesn't hold, made since the two
+	// diverged
+	heads, err := ce.lockedHeads(ctx, documentID, from)
+	if err != nil {
+		return nil, err
+	}
+	history, err := ce.loadHistory(ctx, heads...)
+	if err != nil {
+		return nil, err
+	}
+	fork := graph.fork(fromView, toView)
+
+	applied := make(map[operations.OperationID]bool)
+	for _, id := range toDoc.Snapshot().AppliedOps {
+		applied[id] = true
+	}
+	var missing, changed []*operations.Operation
+	err = ce.store.ForEachOperationMatching(ctx, storage.OperationFilter{DocumentID: documentID}, func(op *operations.Operation) error {
+		if fromView.holds(op) && !applied[op.ID] {
+			missing = append(missing, op)
+		} else if toView.holds(op) && !fork.IsZero() && !op.Timestamp.Before(fork) && !history.Contains(op.ID) {
+			changed = append(changed, op)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(missing, func(i, j int) bool { return missing[i].Precedes(missing[j]) })
+
+	merged, err := positioning.RestoreDocument(toDoc.Snapshot())
+	if err != nil {
+		return nil, err
+	}
+	report := &MergeReport{
+		DocumentID: documentID,
+		From:       from,
+		To:         to,
+		Applied:    make([]operations.OperationID, 0, len(missing)),
+		DryRun:     dryRun,
+	}
+	for _, op := range missing {
+		if err := merged.ApplyOperation(op); err != nil {
+			return nil, fmt.Errorf("failed to merge %s into %s: %w", op.ID, documentID, err)
+		}
+		report.Applied = append(report.Applied, op.ID)
+	}
+	report.Conflicts = mergeConflicts(missing, changed, merged, toDoc, fromDoc)
+
+	if dryRun || len(missing) == 0 {
+		report.Version = merged.CurrentVersion()
+		report.Document = merged
+		return report, nil
+	}
+
+	if err := ce.recordDocumentMerge(ctx, documentID, to, missing); err != nil {
+		return nil, err
+	}
+	if operations.IsMainBranch(to) {
+		report.Version, err = ce.mergeIntoMain(ctx, documentID, missing)
+	} else {
+		report.Version, err = ce.mergeIntoBranch(ctx, documentID, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if report.Document, err = ce.BranchDocument(ctx, documentID, to); err != nil {
+		return nil, err
+	}
+
+	ce.events.Publish(events.DocumentMerged, report)
+	return report, nil
+}
+
+// recordDocumentMerge records the branches ops came from as merged into
+// branch to in the document, through the latest of their operations merged
+func (ce *CollaborationEngine) recordDocumentMerge(ctx gocontext.Context, documentID, to string, ops []*operations.Operation) error {
+	through := make(map[string]time.Time)
+	for _, op := range ops {
+		name := branchName(op.Metadata.Branch)
+		if op.Timestamp.After(through[name]) {
+			through[name] = op.Timestamp
+		}
+	}
+	delete(through, operations.MainBranch)
+
+	now := time.Now()
+	merges := make([]storage.DocumentMerge, 0, len(through))
+	for name, at := range through {
+		merges = append(merges, storage.DocumentMerge{DocumentID: documentID, Branch: name, MergedInto: to, Through: at, MergedAt: now})
+	}
+	if len(merges) == 0 {
+		return nil
+	}
+
+	// Operations on a branch wait for the merge, so none lands before its heads are read again
+	if !operations.IsMainBranch(to) {
+		lock := ce.documentLock(branchLockKey(to))
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	return ce.store.RecordDocumentMerges(ctx, merges)
+}
+
+// lockedHeads copies a document's heads on a branch, holding the lock
+// documentHeads needs
+func (ce *CollaborationEngine) lockedHeads(ctx gocontext.Context, documentID, branch string) ([]operations.OperationID, error) {
+	key := documentID
+	if !operations.IsMainBranch(branch) {
+		key = branchLockKey(branch)
+	}
+	lock := ce.documentLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	heads, err := ce.documentHeads(ctx, documentID, branch)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(heads), nil
+}
+
+// fork is when two views of branches stopped holding the same operations:
+// when the first branch one holds and the other doesn't was created, or when
+// one stopped taking in a branch the other still does. It is zero when they
+// hold the same.
+func (g *branchGraph) fork(a, b branchSet) time.Time {
+	var fork time.Time
+	note := func(at time.Time) {
+		if !at.IsZero() && (fork.IsZero() || at.Before(fork)) {
+			fork = at
+		}
+	}
+	for _, sides := range [][2]branchSet{{a, b}, {b, a}} {
+		for name, through := range sides[0] {
+			other, shared := sides[1][name]
+			switch {
+			case !shared:
+				// Stored operations keep their timestamps to the second
+				note(g.created[name].Truncate(time.Second))
+			case !other.Equal(through):
+				if other.After(through) {
+					through = other
+				}
+				note(through)
+			}
+		}
+	}
+	return fork
+}
+
+// mergeChange is one operation on either side of a merge, placed in the
+// merged document
+type mergeChange struct {
+	op            *operations.Operation
+	rank          int
+	constructType positioning.ConstructType
+	from          bool
+}
+
+// mergeConflicts finds the regions where the operations merged in land
+// within MergeConflictDistance positions of changes on the branch merged
+// into, touching the same kind of construct. Positions are ranked among
+// those of the merged document and every change, so deletions have a place.
+func mergeConflicts(fromOps, toOps []*operations.Operation, docs ...*positioning.Document) []MergeConflict {
+	if len(fromOps) == 0 || len(toOps) == 0 {
+		return []MergeConflict{}
+	}
+
+	positions := docs[0].Positions()
+	for _, op := range slices.Concat(fromOps, toOps) {
+		positions = append(positions, op.Position)
+	}
+	slices.SortFunc(positions, operations.LogootPosition.Compare)
+	rank := make(map[operations.PositionKey]int, len(positions))
+	for _, pos := range positions {
+		if _, ok := rank[pos.Key()]; !ok {
+			rank[pos.Key()] = len(rank)
+		}
+	}
+
+	// A deleted construct is found in a document from before the merge
+	constructType := func(pos operations.LogootPosition) positioning.ConstructType {
+		for _, doc := range docs {
+			if construct, err := doc.GetConstruct(pos); err == nil {
+				return construct.Type
+			}
+		}
+		return positioning.ConstructContent
+	}
+
+	changes := make([]mergeChange, 0, len(fromOps)+len(toOps))
+	for _, op := range fromOps {
+		changes = append(changes, mergeChange{op, rank[op.Position.Key()], constructType(op.Position), true})
+	}
+	for _, op := range toOps {
+		changes = append(changes, mergeChange{op, rank[op.Position.Key()], constructType(op.Position), false})
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].constructType != changes[j].constructType {
+			return changes[i].constructType < changes[j].constructType
+		}
+		return changes[i].rank < changes[j].rank
+	})
+
+	// Sweep each construct type's changes into regions, keeping those both
+	// sides changed
+	conflicts := []MergeConflict{}
+	var region []mergeChange
+	flush := func() {
+		var conflict MergeConflict
+		for _, change := range region {
+			if change.from {
+				conflict.From = append(conflict.From, change.op.ID)
+			} else {
+				conflict.To = append(conflict.To, change.op.ID)
+			}
+		}
+		if len(conflict.From) > 0 && len(conflict.To) > 0 {
+			conflict.ConstructType = region[0].constructType
+			conflict.Start = region[0].op.Position
+			conflict.End = region[len(region)-1].op.Position
+			conflicts = append(conflicts, conflict)
+		}
+		region = region[:0]
+	}
+	for _, change := range changes {
+		if len(region) > 0 {
+			last := region[len(region)-1]
+			if last.constructType != change.constructType || change.rank-last.rank > MergeConflictDistance {
+				flush()
+			}
+		}
+		region = append(region, change)
+	}
+	flush()
+
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return rank[conflicts[i].Start.Key()] < rank[conflicts[j].Start.Key()]
+	})
+	return conflicts
+}
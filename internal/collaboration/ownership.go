@@ -0,0 +1,181 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"sort"
+	"unicode"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+// Ownership says who wrote and who last touched the lines of a document.
+// Shares are fractions of the non-whitespace characters in the lines
+// covered; Unattributed is the share whose operations are no longer stored.
+// LastLine is zero when the document has no lines from FirstLine on.
+type Ownership struct {
+	DocumentID   string            `json:"document_id"`
+	FirstLine    int               `json:"first_line"`
+	LastLine     int               `json:"last_line"`
+	Authors      []AuthorOwnership `json:"authors"`
+	Ranges       []OwnershipRange  `json:"ranges"`
+	Unattributed float64           `json:"unattributed,omitempty"`
+}
+
+// AuthorOwnership is one author's share of a document. Wrote counts the
+// characters they created, LastTouched those they were the last to change.
+type AuthorOwnership struct {
+	Author      operations.AuthorID `json:"author"`
+	Wrote       float64             `json:"wrote"`
+	LastTouched float64             `json:"last_touched"`
+	Lines       int                 `json:"lines"`
+}
+
+// OwnershipRange is a run of lines written by one author and last touched by
+// another, or the same one. Neighbouring ranges share a line when it holds
+// text from both.
+type OwnershipRange struct {
+	FirstLine     int                 `json:"first_line"`
+	LastLine      int                 `json:"last_line"`
+	Author        operations.AuthorID `json:"author"`
+	LastTouchedBy operations.AuthorID `json:"last_touched_by"`
+}
+
+// DocumentOwnership attributes the lines first to last of a document, both
+// 1-based and inclusive, to the authors of the operations that created and
+// last modified them. Zero for either leaves that end open.
+func (ce *CollaborationEngine) DocumentOwnership(ctx gocontext.Context, documentID string, first, last int) (*Ownership, error) {
+	doc, err := ce.store.GetDocument(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	authorOf := ce.authorLookup(ctx)
+	ownership := &Ownership{
+		DocumentID: documentID,
+		FirstLine:  max(first, 1),
+		Authors:    []AuthorOwnership{},
+		Ranges:     []OwnershipRange{},
+	}
+	byAuthor := make(map[operations.AuthorID]*AuthorOwnership)
+	share := func(author operations.AuthorID) *AuthorOwnership {
+		owner, exists := byAuthor[author]
+		if !exists {
+			owner = &AuthorOwnership{Author: author}
+			byAuthor[author] = owner
+		}
+		return owner
+	}
+
+	total, unattributed := 0, 0
+	for _, span := range doc.LineSpans() {
+		if span.LastLine < ownership.FirstLine || (last > 0 && span.FirstLine > last) {
+			continue
+		}
+		ownership.LastLine = max(ownership.LastLine, span.LastLine)
+
+		weight := significantChars(span.Construct)
+		if weight == 0 {
+			continue
+		}
+		total += weight
+
+		writer, wrote := authorOf(span.Construct.CreatedBy)
+		toucher, touched := authorOf(span.Construct.ModifiedBy)
+		if !touched {
+			toucher = writer
+		}
+		if !wrote {
+			unattributed += weight
+			continue
+		}
+
+		owner := share(writer)
+		owner.Wrote += float64(weight)
+		lastLine := span.LastLine
+		if last > 0 {
+			lastLine = min(lastLine, last)
+		}
+		owner.Lines += lastLine - max(span.FirstLine, ownership.FirstLine) + 1
+		share(toucher).LastTouched += float64(weight)
+
+		ownership.addRange(span, writer, toucher)
+	}
+
+	if last > 0 {
+		ownership.LastLine = min(ownership.LastLine, last)
+	}
+	if len(ownership.Ranges) > 0 {
+		ownership.Ranges[0].FirstLine = max(ownership.Ranges[0].FirstLine, ownership.FirstLine)
+		end := &ownership.Ranges[len(ownership.Ranges)-1]
+		end.LastLine = min(end.LastLine, ownership.LastLine)
+	}
+
+	for _, owner := range byAuthor {
+		owner.Wrote /= float64(total)
+		owner.LastTouched /= float64(total)
+		ownership.Authors = append(ownership.Authors, *owner)
+	}
+	sort.Slice(ownership.Authors, func(i, j int) bool {
+		a, b := ownership.Authors[i], ownership.Authors[j]
+		if a.Wrote != b.Wrote {
+			return a.Wrote > b.Wrote
+		}
+		if a.LastTouched != b.LastTouched {
+			return a.LastTouched > b.LastTouched
+		}
+		return a.Author < b.Author
+	})
+	if total > 0 {
+		ownership.Unattributed = float64(unattributed) / float64(total)
+	}
+	return ownership, nil
+}
+
+// addRange extends the last range when span continues it, or starts another
+func (o *Ownership) addRange(span positioning.LineSpan, writer, toucher operations.AuthorID) {
+	if n := len(o.Ranges); n > 0 {
+		previous := &o.Ranges[n-1]
+		if previous.Author == writer && previous.LastTouchedBy == toucher {
+			previous.LastLine = max(previous.LastLine, span.LastLine)
+			return
+		}
+	}
+	o.Ranges = append(o.Ranges, OwnershipRange{
+		FirstLine:     span.FirstLine,
+		LastLine:      span.LastLine,
+		Author:        writer,
+		LastTouchedBy: toucher,
+	})
+}
+
+// authorLookup finds the author of an operation, caching lookups for the
+// call. It reports false for operations the store no longer has.
+func (ce *CollaborationEngine) authorLookup(ctx gocontext.Context) func(operations.OperationID) (operations.AuthorID, bool) {
+	authors := make(map[operations.OperationID]operations.AuthorID)
+	return func(opID operations.OperationID) (operations.AuthorID, bool) {
+		if opID == "" {
+			return "", false
+		}
+		author, seen := authors[opID]
+		if !seen {
+			if op, err := ce.store.GetOperation(ctx, opID); err == nil {
+				author = op.Author
+			}
+			authors[opID] = author
+		}
+		return author, author != ""
+	}
+}
+
+// significantChars counts the characters of a construct that aren't
+// whitespace, so indentation and blank lines don't decide who owns a document
+func significantChars(construct *positioning.Construct) int {
+	count := 0
+	for _, r := range construct.Content {
+		if !unicode.IsSpace(r) {
+			count++
+		}
+	}
+	return count
+}
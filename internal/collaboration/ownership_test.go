@@ -0,0 +1,101 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestCollaborationEngine_DocumentOwnership(t *testing.T) {
+	ctx := gocontext.Background()
+	store := setupTestStorage(t)
+	engine := NewCollaborationEngine(store)
+
+	insert := func(content string, author operations.AuthorID, value int64) *operations.Operation {
+		op := &operations.Operation{
+			ID:   operations.NewOperationID([]byte(content)),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: author},
+			}),
+			Content:   content,
+			Author:    author,
+			Timestamp: time.Now(),
+			Metadata:  operations.OperationMeta{Context: map[string]string{"document_id": "main.go"}},
+		}
+		if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+		return op
+	}
+
+	insert("func retry() {\n", "alice", 1)
+	body := insert("\treturn nil\n", "alice", 2)
+	insert("}\n", "bob", 3)
+	fix := insert("// fixed\n", "bob", 4)
+
+	// Bob last touched alice's body
+	doc, err := store.GetDocument(ctx, "main.go")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	construct, err := doc.GetConstruct(body.Position)
+	if err != nil {
+		t.Fatalf("Failed to get construct: %v", err)
+	}
+	construct.ModifiedBy = fix.ID
+	if err := store.StoreDocument(ctx, doc); err != nil {
+		t.Fatalf("Failed to store document: %v", err)
+	}
+
+	ownership, err := engine.DocumentOwnership(ctx, "main.go", 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to get ownership: %v", err)
+	}
+	if ownership.FirstLine != 1 || ownership.LastLine != 4 {
+		t.Errorf("Expected lines 1 to 4, got %d to %d", ownership.FirstLine, ownership.LastLine)
+	}
+	if len(ownership.Authors) != 2 || ownership.Authors[0].Author != "alice" || ownership.Authors[0].Lines != 2 {
+		t.Fatalf("Expected alice to own two lines, got %+v", ownership.Authors)
+	}
+	// alice wrote 12 + 9 of the 29 non-whitespace characters and last touched 12
+	alice, bob := ownership.Authors[0], ownership.Authors[1]
+	if alice.Wrote != 21.0/29 || alice.LastTouched != 12.0/29 || bob.LastTouched != 17.0/29 {
+		t.Errorf("Unexpected shares: %+v", ownership.Authors)
+	}
+
+	expected := []OwnershipRange{
+		{FirstLine: 1, LastLine: 1, Author: "alice", LastTouchedBy: "alice"},
+		{FirstLine: 2, LastLine: 2, Author: "alice", LastTouchedBy: "bob"},
+		{FirstLine: 3, LastLine: 4, Author: "bob", LastTouchedBy: "bob"},
+	}
+	if len(ownership.Ranges) != len(expected) {
+		t.Fatalf("Expected %d ranges, got %+v", len(expected), ownership.Ranges)
+	}
+	for i, r := range expected {
+		if ownership.Ranges[i] != r {
+			t.Errorf("Expected range %d to be %+v, got %+v", i, r, ownership.Ranges[i])
+		}
+	}
+
+	// A line range only attributes those lines
+	ownership, err = engine.DocumentOwnership(ctx, "main.go", 3, 3)
+	if err != nil {
+		t.Fatalf("Failed to get ownership of a range: %v", err)
+	}
+	if len(ownership.Authors) != 1 || ownership.Authors[0].Author != "bob" || ownership.Authors[0].Wrote != 1 {
+		t.Errorf("Expected bob to own line 3, got %+v", ownership.Authors)
+	}
+	if len(ownership.Ranges) != 1 || ownership.Ranges[0].FirstLine != 3 || ownership.Ranges[0].LastLine != 3 {
+		t.Errorf("Expected the range cut to line 3, got %+v", ownership.Ranges)
+	}
+
+	if _, err := engine.DocumentOwnership(ctx, "missing.go", 0, 0); !errors.Is(err, storage.ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+}
@@ -132,3 +132,21 @@ func (pt *PresenceTracker) CleanupStale(timeout time.Duration) {
 		}
 	}
 }
+
+// PurgeBefore drops presence entries that have not been updated since cutoff
+func (pt *PresenceTracker) PurgeBefore(cutoff time.Time, dryRun bool) []ClientID {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+
+	var purged []ClientID
+	for clientID, info := range pt.clients {
+		if info.LastUpdate.Before(cutoff) {
+			purged = append(purged, clientID)
+			if !dryRun {
+				delete(pt.clients, clientID)
+			}
+		}
+	}
+
+	return purged
+}
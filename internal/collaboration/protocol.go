@@ -1,6 +1,11 @@
 package collaboration
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/jeremytregunna/contextdb/internal/operations"
@@ -16,6 +21,8 @@ const (
 	MsgAcknowledgment MessageType = "ack"
 	MsgError          MessageType = "error"
 	MsgComment        MessageType = "comment"
+	MsgClose          MessageType = "close"
+	MsgSession        MessageType = "session"
 )
 
 type Message struct {
@@ -59,14 +66,162 @@ type SyncPayload struct {
 	Operations   []*operations.Operation `json:"operations"`
 	CurrentState *positioning.Document   `json:"current_state,omitempty"`
 	SinceVersion uint64                  `json:"since_version,omitempty"`
+	// AcceptEncoding, sent with a request, lets the server gzip a large
+	// answer. SyncEncodingGzip is the only one supported.
+	AcceptEncoding string `json:"accept_encoding,omitempty"`
+	// Chunked, sent with a request, lets the server split a large answer
+	// across several sync messages
+	Chunked bool `json:"chunked,omitempty"`
+	// Encoding is set when the operations and current state are in Data
+	// instead, as a SyncState encoded that way. Chunk places a piece of Data
+	// when it is split.
+	Encoding string     `json:"encoding,omitempty"`
+	Data     []byte     `json:"data,omitempty"`
+	Chunk    *SyncChunk `json:"chunk,omitempty"`
 }
 
+// Encodings of a sync's Data
+const (
+	SyncEncodingJSON = "json"
+	SyncEncodingGzip = "gzip"
+)
+
+// SyncChunk places one of the messages a sync was split across. Joined in
+// index order, their Data is the whole state; every one but the last has More
+// set.
+type SyncChunk struct {
+	SyncID string `json:"sync_id"`
+	Index  int    `json:"index"`
+	Count  int    `json:"count"`
+	More   bool   `json:"more"`
+}
+
+// SyncState is what a sync's Data decodes to
+type SyncState struct {
+	Operations   []*operations.Operation `json:"operations"`
+	CurrentState *positioning.Document   `json:"current_state,omitempty"`
+}
+
+// Decode returns the operations and current state of a sync, moving them out
+// of Data when they were encoded. Data must be whole, joined from all its
+// chunks.
+func (p *SyncPayload) Decode() error {
+	if p.Encoding == "" {
+		return nil
+	}
+
+	var r io.Reader = bytes.NewReader(p.Data)
+	switch p.Encoding {
+	case SyncEncodingJSON:
+	case SyncEncodingGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+		}
+		defer gz.Close()
+		r = gz
+	default:
+		return fmt.Errorf("%w: unknown sync encoding %q", ErrInvalidMessage, p.Encoding)
+	}
+
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var state SyncState
+	if err := decoder.Decode(&state); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	p.Operations, p.CurrentState = state.Operations, state.CurrentState
+	p.Encoding, p.Data, p.Chunk = "", nil, nil
+	return nil
+}
+
+// encodeSync prepares the answer to a sync request as the request and config
+// allow: gzipped from the compression threshold, and split once larger than a
+// chunk. Answers that need neither go out as they are.
+func encodeSync(answer *SyncPayload, request SyncPayload, config WebSocketConfig) ([]*SyncPayload, error) {
+	compress := request.AcceptEncoding == SyncEncodingGzip && config.CompressionThreshold > 0
+	chunkSize := 0
+	if request.Chunked {
+		chunkSize = config.SyncChunkSize
+	}
+	if !compress && chunkSize <= 0 {
+		return []*SyncPayload{answer}, nil
+	}
+
+	data, err := json.Marshal(SyncState{Operations: answer.Operations, CurrentState: answer.CurrentState})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode sync: %w", err)
+	}
+	encoding := SyncEncodingJSON
+	if compress && len(data) >= config.CompressionThreshold {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to compress sync: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress sync: %w", err)
+		}
+		data, encoding = buf.Bytes(), SyncEncodingGzip
+	}
+	split := chunkSize > 0 && len(data) > chunkSize
+	if encoding == SyncEncodingJSON && !split {
+		return []*SyncPayload{answer}, nil
+	}
+
+	header := SyncPayload{DocumentID: answer.DocumentID, SinceVersion: answer.SinceVersion, Encoding: encoding}
+	if !split {
+		header.Data = data
+		return []*SyncPayload{&header}, nil
+	}
+
+	count := (len(data) + chunkSize - 1) / chunkSize
+	syncID := generateMessageID()
+	chunks := make([]*SyncPayload, count)
+	for i := range chunks {
+		chunk := header
+		chunk.Data = data[i*chunkSize : min((i+1)*chunkSize, len(data))]
+		chunk.Chunk = &SyncChunk{SyncID: syncID, Index: i, Count: count, More: i < count-1}
+		chunks[i] = &chunk
+	}
+	return chunks, nil
+}
+
+// AckPayload answers an operation. On success it carries the ID the
+// operation was stored under and its parents, which the server fills in when
+// the client sends none.
 type AckPayload struct {
-	MessageID string `json:"message_id"`
-	Success   bool   `json:"success"`
-	Error     string `json:"error,omitempty"`
+	MessageID   string                   `json:"message_id"`
+	Success     bool                     `json:"success"`
+	Error       string                   `json:"error,omitempty"`
+	OperationID operations.OperationID   `json:"operation_id,omitempty"`
+	Parents     []operations.OperationID `json:"parents,omitempty"`
 }
 
+// ClosePayload is the last message a client receives before the server
+// closes its connection
+type ClosePayload struct {
+	Reason string `json:"reason"`
+}
+
+// SessionPayload is the first message on every connection. A client that
+// drops may reconnect within ResumeTTLMs with ResumeToken to resume the
+// session, which is then Resumed: it follows the same Documents, and the
+// Replayed messages it missed come next. A session that isn't resumed starts
+// afresh, so the client syncs its documents again.
+type SessionPayload struct {
+	ClientID    ClientID `json:"client_id"`
+	ResumeToken string   `json:"resume_token,omitempty"`
+	ResumeTTLMs int64    `json:"resume_ttl_ms,omitempty"`
+	Resumed     bool     `json:"resumed"`
+	Documents   []string `json:"documents,omitempty"`
+	Replayed    int      `json:"replayed,omitempty"`
+}
+
+// ErrorCodeSlowClient is sent to a client, before it is disconnected, whose
+// send buffer stayed full for too long. It should reconnect and sync.
+const ErrorCodeSlowClient = "slow_client"
+
 type ErrorPayload struct {
 	Code    string                 `json:"code"`
 	Message string                 `json:"message"`
@@ -0,0 +1,412 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// Queries are bounded so one can't walk the whole history. Limit caps the
+// operations returned, depth the parent edges followed, and cost the
+// operations read or visited while answering.
+const (
+	DefaultQueryLimit = 50
+	MaxQueryLimit     = 500
+	DefaultQueryDepth = 10
+	MaxQueryDepth     = 100
+	MaxQueryCost      = 10000
+)
+
+// What a query can add to the operations it returns
+const (
+	QueryIncludeConversations = "conversations"
+	QueryIncludeAddresses     = "addresses"
+)
+
+// OperationQuery picks operations by their metadata, their place in the
+// operation DAG and the conversations about them
+type OperationQuery struct {
+	Where   OperationPredicate `json:"where"`
+	Include []string           `json:"include,omitempty"`
+	Limit   int                `json:"limit,omitempty"`
+	// Depth caps the parent edges ancestor_of and descends_from follow
+	Depth int `json:"depth,omitempty"`
+	// MaxCost lowers the cost the query may reach before it is refused
+	MaxCost int `json:"max_cost,omitempty"`
+}
+
+// OperationPredicate is what every operation a query returns satisfies. Empty
+// fields match every operation.
+type OperationPredicate struct {
+	Author     operations.AuthorID        `json:"author,omitempty"`
+	DocumentID string                     `json:"document_id,omitempty"`
+	Branch     string                     `json:"branch,omitempty"`
+	Tool       string                     `json:"tool,omitempty"`
+	Ticket     string                     `json:"ticket,omitempty"`
+	Language   string                     `json:"language,omitempty"`
+	Types      []operations.OperationType `json:"types,omitempty"`
+	// Since and Until bound the operations' timestamps, Until exclusively
+	Since time.Time `json:"since,omitempty"`
+	Until time.Time `json:"until,omitempty"`
+	// AncestorOf keeps the operation and its causal history
+	AncestorOf operations.OperationID `json:"ancestor_of,omitempty"`
+	// DescendsFrom keeps operations whose causal history includes it, and it
+	DescendsFrom operations.OperationID `json:"descends_from,omitempty"`
+	// Thread keeps the operations a thread is anchored to, references or links
+	Thread context.ThreadID `json:"thread,omitempty"`
+	// Discussed keeps operations with a conversation about them, or with
+	// false those without
+	Discussed *bool `json:"discussed,omitempty"`
+}
+
+func (p OperationPredicate) filter() storage.OperationFilter {
+	return storage.OperationFilter{
+		Since:      p.Since,
+		Until:      p.Until,
+		Author:     p.Author,
+		DocumentID: p.DocumentID,
+		Branch:     p.Branch,
+		Tool:       p.Tool,
+		Ticket:     p.Ticket,
+		Language:   p.Language,
+	}
+}
+
+// QueryMatch is an operation a query returned, with what it asked to include.
+// Distance is how many parent edges separate it from ancestor_of.
+type QueryMatch struct {
+	Operation     *operations.Operation         `json:"operation"`
+	Distance      int                           `json:"distance,omitempty"`
+	Conversations []*context.ConversationThread `json:"conversations,omitempty"`
+	Addresses     []addressing.StableAddress    `json:"addresses,omitempty"`
+}
+
+// QueryResult is what a query matched. Operations come oldest first, or
+// nearest first with ancestor_of. Truncated is set when more matched than the
+// limit.
+type QueryResult struct {
+	Operations []QueryMatch `json:"operations"`
+	Cost       int          `json:"cost"`
+	Truncated  bool         `json:"truncated,omitempty"`
+}
+
+// errQueryDone stops a scan once a query has all the operations it needs
+var errQueryDone = errors.New("query done")
+
+// QueryOperations answers query. Without ancestor_of, operations are scanned
+// from the store by their metadata; with it, the operation's history is walked
+// instead. Reading or visiting an operation costs one, and a query that goes
+// over its cost fails with ErrQueryTooExpensive. Threads viewer may not read
+// are neither matched nor included.
+func (ce *CollaborationEngine) QueryOperations(ctx gocontext.Context, query OperationQuery, viewer context.Viewer) (*QueryResult, error) {
+	q := &queryRunner{
+		ctx:     ctx,
+		engine:  ce,
+		viewer:  viewer,
+		aliases: ce.AuthorAliases(),
+		where:   query.Where,
+		filter:  query.Where.filter(),
+		limit:   query.Limit,
+		depth:   query.Depth,
+		budget:  query.MaxCost,
+		ops:     make(map[operations.OperationID]*operations.Operation),
+		result:  &QueryResult{Operations: []QueryMatch{}},
+	}
+	if q.limit <= 0 {
+		q.limit = DefaultQueryLimit
+	}
+	q.limit = min(q.limit, MaxQueryLimit)
+	if q.depth <= 0 {
+		q.depth = DefaultQueryDepth
+	}
+	q.depth = min(q.depth, MaxQueryDepth)
+	if q.budget <= 0 || q.budget > MaxQueryCost {
+		q.budget = MaxQueryCost
+	}
+
+	if query.Where.Thread != "" {
+		if _, err := ce.conversationManager.ViewConversation(query.Where.Thread, viewer); err != nil {
+			return nil, err
+		}
+	}
+	if query.Where.DescendsFrom != "" {
+		if _, err := ce.store.GetOperation(ctx, query.Where.DescendsFrom); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if query.Where.AncestorOf != "" {
+		err = q.walkHistory(query.Where.AncestorOf)
+	} else {
+		err = q.scan()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(q.result.Operations) > q.limit {
+		q.result.Operations = q.result.Operations[:q.limit]
+		q.result.Truncated = true
+	}
+	for i := range q.result.Operations {
+		q.include(&q.result.Operations[i], query.Include)
+	}
+	return q.result, nil
+}
+
+type queryRunner struct {
+	ctx    gocontext.Context
+	engine *CollaborationEngine
+	viewer context.Viewer
+	// aliases compare authors by identity
+	aliases context.AuthorAliases
+	where   OperationPredicate
+	filter  storage.OperationFilter
+	limit   int
+	depth   int
+	budget  int
+	// ops caches what was read from the store, nil for purged operations
+	ops    map[operations.OperationID]*operations.Operation
+	result *QueryResult
+}
+
+// spend charges the query for n operations
+func (q *queryRunner) spend(n int) error {
+	q.result.Cost += n
+	if q.result.Cost > q.budget {
+		return fmt.Errorf("%w: it reads more than %d operations", ErrQueryTooExpensive, q.budget)
+	}
+	return q.ctx.Err()
+}
+
+// done reports whether the query found one more match than its limit, which
+// tells it that it was truncated
+func (q *queryRunner) done() bool {
+	return len(q.result.Operations) > q.limit
+}
+
+// scan reads the operations matching the query's metadata, then checks the
+// rest of the predicate. Checking ancestry reads from the store, so it waits
+// until the scan is over.
+func (q *queryRunner) scan() error {
+	var candidates []*operations.Operation
+	err := q.engine.store.ForEachOperationMatching(q.ctx, q.filter, func(op *operations.Operation) error {
+		if err := q.spend(1); err != nil {
+			return err
+		}
+		q.ops[op.ID] = op
+		if !q.matchesLocally(op) {
+			return nil
+		}
+		candidates = append(candidates, op)
+		if q.where.DescendsFrom == "" && len(candidates) > q.limit {
+			return errQueryDone
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errQueryDone) {
+		return err
+	}
+
+	for _, op := range candidates {
+		if q.done() {
+			break
+		}
+		if err := q.consider(op, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkHistory visits the causal history of root breadth first, nearest
+// operations first
+func (q *queryRunner) walkHistory(root operations.OperationID) error {
+	op, err := q.engine.store.GetOperation(q.ctx, root)
+	if err != nil {
+		return err
+	}
+	if err := q.spend(1); err != nil {
+		return err
+	}
+	q.ops[root] = op
+
+	seen := map[operations.OperationID]bool{root: true}
+	level := []*operations.Operation{op}
+	for distance := 0; len(level) > 0 && !q.done(); distance++ {
+		var parents []operations.OperationID
+		for _, op := range level {
+			if q.matchesLocally(op) {
+				if err := q.consider(op, distance); err != nil {
+					return err
+				}
+				if q.done() {
+					return nil
+				}
+			}
+			for _, parent := range op.Parents {
+				if !seen[parent] {
+					seen[parent] = true
+					parents = append(parents, parent)
+				}
+			}
+		}
+		if distance == q.depth {
+			break
+		}
+		if level, err = q.operations(parents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchesLocally checks the parts of the predicate that don't need the store
+func (q *queryRunner) matchesLocally(op *operations.Operation) bool {
+	// Matches compares authors exactly, so they are compared by identity here
+	filter := q.filter
+	filter.Author = ""
+	if !filter.Matches(op) {
+		return false
+	}
+	if q.where.Author != "" && !q.aliases.Same(op.Author, q.where.Author) {
+		return false
+	}
+	if len(q.where.Types) > 0 && !slices.Contains(q.where.Types, op.Type) {
+		return false
+	}
+	if q.where.Thread == "" && q.where.Discussed == nil {
+		return true
+	}
+
+	threads := q.threads(op.ID)
+	if q.where.Discussed != nil && *q.where.Discussed != (len(threads) > 0) {
+		return false
+	}
+	return q.where.Thread == "" || slices.ContainsFunc(threads, func(thread *context.ConversationThread) bool {
+		return thread.ID == q.where.Thread
+	})
+}
+
+// consider adds op to the result if it descends from what the query asks
+func (q *queryRunner) consider(op *operations.Operation, distance int) error {
+	if q.where.DescendsFrom != "" {
+		descends, err := q.descendsFrom(op)
+		if err != nil || !descends {
+			return err
+		}
+	}
+	q.result.Operations = append(q.result.Operations, QueryMatch{Operation: op, Distance: distance})
+	return nil
+}
+
+// descendsFrom looks for the query's descends_from within depth parent edges
+// of op
+func (q *queryRunner) descendsFrom(op *operations.Operation) (bool, error) {
+	seen := map[operations.OperationID]bool{op.ID: true}
+	level := []*operations.Operation{op}
+	for distance := 0; len(level) > 0; distance++ {
+		if err := q.spend(len(level)); err != nil {
+			return false, err
+		}
+		var parents []operations.OperationID
+		for _, op := range level {
+			if op.ID == q.where.DescendsFrom {
+				return true, nil
+			}
+			for _, parent := range op.Parents {
+				if !seen[parent] {
+					seen[parent] = true
+					parents = append(parents, parent)
+				}
+			}
+		}
+		if distance == q.depth {
+			break
+		}
+
+		var err error
+		if level, err = q.operations(parents); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// operations returns the operations with the given IDs, reading those it
+// hasn't seen from the store. Operations no longer stored are left out.
+func (q *queryRunner) operations(ids []operations.OperationID) ([]*operations.Operation, error) {
+	var missing []operations.OperationID
+	for _, id := range ids {
+		if _, seen := q.ops[id]; !seen {
+			missing = append(missing, id)
+		}
+	}
+	for start := 0; start < len(missing); start += historyBatchSize {
+		batch := missing[start:min(start+historyBatchSize, len(missing))]
+		if err := q.spend(len(batch)); err != nil {
+			return nil, err
+		}
+		loaded, err := q.engine.store.GetOperations(q.ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range batch {
+			q.ops[id] = nil
+		}
+		for _, op := range loaded {
+			q.ops[op.ID] = op
+		}
+	}
+
+	found := make([]*operations.Operation, 0, len(ids))
+	for _, id := range ids {
+		if op := q.ops[id]; op != nil {
+			found = append(found, op)
+		}
+	}
+	return found, nil
+}
+
+// threads returns the threads about an operation the viewer may read
+func (q *queryRunner) threads(id operations.OperationID) []*context.ConversationThread {
+	var visible []*context.ConversationThread
+	for _, thread := range q.engine.conversationManager.GetConversationsByOperation(id) {
+		if q.viewer.CanView(thread) {
+			visible = append(visible, thread)
+		}
+	}
+	return visible
+}
+
+func (q *queryRunner) include(match *QueryMatch, include []string) {
+	wantThreads := slices.Contains(include, QueryIncludeConversations)
+	wantAddresses := slices.Contains(include, QueryIncludeAddresses)
+	if !wantThreads && !wantAddresses {
+		return
+	}
+
+	threads := q.threads(match.Operation.ID)
+	if wantThreads {
+		match.Conversations = threads
+	}
+	if wantAddresses {
+		seen := make(map[addressing.AddressKey]bool)
+		for _, thread := range threads {
+			forEachAddress(thread, func(addr addressing.StableAddress, _ GraphEdgeKind) {
+				if addr.OperationID == match.Operation.ID && !seen[addr.Key()] {
+					seen[addr.Key()] = true
+					match.Addresses = append(match.Addresses, addr)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestCollaborationEngine_QueryOperations(t *testing.T) {
+	ctx := gocontext.Background()
+	store := setupTestStorage(t)
+	engine := NewCollaborationEngine(store)
+	start := time.Now()
+
+	var count int64
+	storeOp := func(author operations.AuthorID, document string, parents ...operations.OperationID) *operations.Operation {
+		count++
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(count), AuthorID: author},
+			}),
+			Content:   "x",
+			Author:    author,
+			Timestamp: start.Add(time.Duration(count) * time.Minute),
+			Parents:   parents,
+			Metadata:  operations.OperationMeta{DocumentID: document},
+		}
+		op.ID = operations.ComputeID(op)
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+		return op
+	}
+	ids := func(result *QueryResult) []operations.OperationID {
+		var matched []operations.OperationID
+		for _, match := range result.Operations {
+			matched = append(matched, match.Operation.ID)
+		}
+		return matched
+	}
+
+	// root <- fix <- follow <- tip, with an unrelated operation by alice beside them
+	root := storeOp("bob", "retry.go")
+	fix := storeOp("alice", "retry.go", root.ID)
+	follow := storeOp("bob", "retry.go", fix.ID)
+	tip := storeOp("alice", "retry.go", follow.ID)
+	unrelated := storeOp("alice", "retry.go")
+	storeOp("alice", "backoff.go", tip.ID)
+
+	// Alice's operations on retry.go whose history includes fix
+	result, err := engine.QueryOperations(ctx, OperationQuery{Where: OperationPredicate{
+		Author: "alice", DocumentID: "retry.go", DescendsFrom: fix.ID,
+	}}, context.Viewer{})
+	if err != nil {
+		t.Fatalf("Failed to query operations: %v", err)
+	}
+	if matched := ids(result); !slices.Equal(matched, []operations.OperationID{fix.ID, tip.ID}) {
+		t.Errorf("Expected fix and tip, got %v", matched)
+	}
+	if result.Cost == 0 {
+		t.Error("Expected the query to report its cost")
+	}
+
+	// Depth bounds how far back the history is searched
+	result, err = engine.QueryOperations(ctx, OperationQuery{Where: OperationPredicate{DescendsFrom: root.ID}, Depth: 2}, context.Viewer{})
+	if err != nil {
+		t.Fatalf("Failed to query operations: %v", err)
+	}
+	if matched := ids(result); !slices.Equal(matched, []operations.OperationID{root.ID, fix.ID, follow.ID}) {
+		t.Errorf("Expected operations within 2 edges of root, got %v", matched)
+	}
+
+	// The history of tip, nearest first, and the limit
+	result, err = engine.QueryOperations(ctx, OperationQuery{Where: OperationPredicate{AncestorOf: tip.ID, Author: "bob"}, Limit: 1}, context.Viewer{})
+	if err != nil {
+		t.Fatalf("Failed to query operations: %v", err)
+	}
+	if len(result.Operations) != 1 || result.Operations[0].Operation.ID != follow.ID || result.Operations[0].Distance != 1 || !result.Truncated {
+		t.Errorf("Expected follow one edge away and the rest cut off, got %+v", result)
+	}
+
+	// Conversations match and are included
+	addr := addressing.NewStableAddress("repo", unrelated.ID, addressing.PositionRange{Start: unrelated.Position, End: unrelated.Position})
+	thread, err := engine.ConversationManager().CreateConversation(addr, "bob", "Why here?", "This looks unrelated", context.WithVisibility(context.VisibilityPrivate))
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	discussed := true
+	result, err = engine.QueryOperations(ctx, OperationQuery{
+		Where:   OperationPredicate{Discussed: &discussed},
+		Include: []string{QueryIncludeConversations, QueryIncludeAddresses},
+	}, context.Viewer{})
+	if err != nil {
+		t.Fatalf("Failed to query operations: %v", err)
+	}
+	if len(result.Operations) != 1 {
+		t.Fatalf("Expected the discussed operation, got %v", ids(result))
+	}
+	if match := result.Operations[0]; match.Operation.ID != unrelated.ID || len(match.Conversations) != 1 || match.Conversations[0].ID != thread.ID || len(match.Addresses) != 1 {
+		t.Errorf("Expected the operation with its thread and address, got %+v", match)
+	}
+	if _, err := engine.QueryOperations(ctx, OperationQuery{Where: OperationPredicate{Thread: thread.ID}}, context.ViewerFor("carol")); !errors.Is(err, context.ErrConversationNotFound) {
+		t.Errorf("Expected a private thread hidden from others, got %v", err)
+	}
+
+	// Queries that read too much are refused
+	_, err = engine.QueryOperations(ctx, OperationQuery{Where: OperationPredicate{DescendsFrom: root.ID}, MaxCost: 5}, context.Viewer{})
+	if !errors.Is(err, ErrQueryTooExpensive) {
+		t.Errorf("Expected ErrQueryTooExpensive, got %v", err)
+	}
+}
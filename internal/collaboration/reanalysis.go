@@ -0,0 +1,109 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// reanalysisBatch is how many analyses are stored in one transaction, and how
+// often progress is reported
+const reanalysisBatch = 500
+
+// ReanalysisQuery selects the operations whose intent is analyzed again. Zero
+// times leave the range open and no documents select every document.
+type ReanalysisQuery struct {
+	Since       time.Time `json:"since,omitempty"`
+	Until       time.Time `json:"until,omitempty"`
+	DocumentIDs []string  `json:"document_ids,omitempty"`
+	// Progress is told how many of the selected operations are done
+	Progress func(done, total int) `json:"-"`
+}
+
+// ReanalysisReport is what a run of ReanalyzeIntents did
+type ReanalysisReport struct {
+	Analyzed int `json:"analyzed"`
+	// Changed counts operations whose category differs from their last analysis
+	Changed int `json:"changed"`
+}
+
+// ReanalyzeIntents runs intent analysis again over the operations query
+// selects, with the current taxonomy, and stores the results apart from the
+// operations. Results already stored are replaced.
+func (ce *CollaborationEngine) ReanalyzeIntents(ctx gocontext.Context, query ReanalysisQuery) (*ReanalysisReport, error) {
+	filters := []storage.OperationFilter{{Since: query.Since, Until: query.Until}}
+	if len(query.DocumentIDs) > 0 {
+		filters = filters[:0]
+		for _, documentID := range query.DocumentIDs {
+			filters = append(filters, storage.OperationFilter{Since: query.Since, Until: query.Until, DocumentID: documentID})
+		}
+	}
+
+	total := 0
+	for _, filter := range filters {
+		count, err := ce.store.CountOperationsMatching(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		total += count
+	}
+	progress := func(done int) {
+		if query.Progress != nil {
+			query.Progress(done, total)
+		}
+	}
+	progress(0)
+
+	report := &ReanalysisReport{}
+	for _, filter := range filters {
+		// Analyses are stored once the scan is done, since the store may not
+		// take writes while the scan's rows are open
+		var records []storage.IntentRecord
+		err := ce.store.ForEachOperationMatching(ctx, filter, func(op *operations.Operation) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			analysis, err := ce.contextAnalyzer.AnalyzeChangeIntent([]*operations.Operation{op})
+			if err != nil {
+				return err
+			}
+			records = append(records, storage.IntentRecord{
+				OperationID:   op.ID,
+				DocumentID:    op.Metadata.DocumentID,
+				PrimaryIntent: analysis.PrimaryIntent,
+				Category:      string(analysis.Category),
+				Confidence:    analysis.Confidence,
+				Keywords:      analysis.Keywords,
+				AnalyzedAt:    time.Now(),
+			})
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+
+		for len(records) > 0 {
+			batch := records[:min(reanalysisBatch, len(records))]
+			records = records[len(batch):]
+			for _, record := range batch {
+				if previous, err := ce.store.GetIntentRecord(ctx, record.OperationID); err == nil && previous.Category != record.Category {
+					report.Changed++
+				}
+			}
+			if err := ce.store.StoreIntentRecords(ctx, batch); err != nil {
+				return report, err
+			}
+			report.Analyzed += len(batch)
+			progress(report.Analyzed)
+		}
+	}
+	return report, nil
+}
+
+// IntentAnalysis returns the stored result of the last batch analysis of an
+// operation's intent
+func (ce *CollaborationEngine) IntentAnalysis(ctx gocontext.Context, id operations.OperationID) (*storage.IntentRecord, error) {
+	return ce.store.GetIntentRecord(ctx, id)
+}
@@ -0,0 +1,92 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestCollaborationEngine_ReanalyzeIntents(t *testing.T) {
+	ctx := gocontext.Background()
+	store := setupTestStorage(t)
+	engine := NewCollaborationEngine(store)
+	start := time.Now()
+
+	insert := func(value int64, document, content string) *operations.Operation {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: "alice"},
+			}),
+			Content:   content,
+			Author:    "alice",
+			Timestamp: start.Add(time.Duration(value) * time.Minute),
+			Metadata:  operations.OperationMeta{DocumentID: document},
+		}
+		op.ID = operations.ComputeID(op)
+		if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+		return op
+	}
+
+	early := insert(1, "retry.go", "fix the retry storm")
+	late := insert(2, "retry.go", "add jitter")
+	other := insert(3, "backoff.go", "fix the curve")
+
+	var progress [][2]int
+	report, err := engine.ReanalyzeIntents(ctx, ReanalysisQuery{
+		Since:       start.Add(90 * time.Second),
+		DocumentIDs: []string{"retry.go", "backoff.go"},
+		Progress:    func(done, total int) { progress = append(progress, [2]int{done, total}) },
+	})
+	if err != nil {
+		t.Fatalf("Failed to reanalyze intents: %v", err)
+	}
+	if report.Analyzed != 2 || report.Changed != 0 {
+		t.Errorf("Expected 2 operations analyzed for the first time, got %+v", report)
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != [2]int{2, 2} {
+		t.Errorf("Expected progress to finish at 2 of 2, got %v", progress)
+	}
+
+	if _, err := engine.IntentAnalysis(ctx, early.ID); err == nil {
+		t.Error("Expected no analysis for an operation before the range")
+	}
+	record, err := engine.IntentAnalysis(ctx, other.ID)
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if record.Category != string(context.IntentBugfix) || record.DocumentID != "backoff.go" {
+		t.Errorf("Expected a bugfix on backoff.go, got %+v", record)
+	}
+
+	// A new taxonomy reclassifies the same operations
+	taxonomy := context.IntentTaxonomy{Categories: []context.IntentDefinition{
+		{Name: "resilience", Keywords: []string{"jitter", "retry", "curve"}},
+	}}
+	if _, err := engine.SetIntentTaxonomy(ctx, taxonomy); err != nil {
+		t.Fatalf("Failed to set taxonomy: %v", err)
+	}
+	if report, err = engine.ReanalyzeIntents(ctx, ReanalysisQuery{}); err != nil {
+		t.Fatalf("Failed to reanalyze intents: %v", err)
+	}
+	if report.Analyzed != 3 || report.Changed != 2 {
+		t.Errorf("Expected 3 analyzed and 2 reclassified, got %+v", report)
+	}
+	if record, err = engine.IntentAnalysis(ctx, late.ID); err != nil || record.Category != "resilience" {
+		t.Errorf("Expected the new category stored, got %+v, %v", record, err)
+	}
+
+	stored, err := store.GetOperation(ctx, late.ID)
+	if err != nil {
+		t.Fatalf("Failed to get operation: %v", err)
+	}
+	if stored.Metadata.Intent != "" {
+		t.Errorf("Expected the operation left untouched, got intent %q", stored.Metadata.Intent)
+	}
+}
@@ -0,0 +1,161 @@
+package collaboration
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/eventbus"
+	"github.com/jeremytregunna/contextdb/internal/ids"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+const (
+	// relayBufferSize is how many broadcasts may wait to go out on the event
+	// bus before more are dropped
+	relayBufferSize = 1024
+	relayTimeout    = 10 * time.Second
+)
+
+// relay shares broadcasts with the other instances on an event bus. Clients
+// of this instance are sent them directly, so only other instances' are taken
+// from the bus.
+type relay struct {
+	bus      eventbus.Bus
+	instance string
+	outbox   chan []byte
+	closed   bool
+	done     chan struct{}
+}
+
+// relayedMessage is a broadcast as it travels between instances
+type relayedMessage struct {
+	Instance   string   `json:"instance"`
+	DocumentID string   `json:"document_id"`
+	Exclude    ClientID `json:"exclude,omitempty"`
+	Message    *Message `json:"message"`
+}
+
+// SetEventBus shares what the engine broadcasts with the other instances
+// serving its store over bus, and sends what they broadcast to its own
+// clients. Operations from them also drop what the engine cached of their
+// documents. The engine closes bus when it shuts down.
+func (ce *CollaborationEngine) SetEventBus(bus eventbus.Bus) error {
+	r := &relay{
+		bus:      bus,
+		instance: ids.NewWithPrefix("instance"),
+		outbox:   make(chan []byte, relayBufferSize),
+		done:     make(chan struct{}),
+	}
+	if err := bus.Subscribe(func(data []byte) { ce.receiveRelayed(r.instance, data) }); err != nil {
+		return err
+	}
+
+	ce.mutex.Lock()
+	ce.relay = r
+	ce.mutex.Unlock()
+
+	go ce.publishRelayed(r)
+	return nil
+}
+
+// relayBroadcast queues msg for the other instances. The caller holds the
+// engine's mutex for reading at least.
+func (ce *CollaborationEngine) relayBroadcast(documentID string, msg *Message, exclude ClientID) {
+	r := ce.relay
+	if r == nil || r.closed {
+		return
+	}
+
+	data, err := json.Marshal(relayedMessage{Instance: r.instance, DocumentID: documentID, Exclude: exclude, Message: msg})
+	if err != nil {
+		ce.relayErrors.Add(1)
+		ce.logger.Error("Failed to encode broadcast for the event bus", map[string]interface{}{"error": err.Error()})
+		return
+	}
+	select {
+	case r.outbox <- data:
+	default:
+		ce.relayErrors.Add(1)
+	}
+}
+
+func (ce *CollaborationEngine) publishRelayed(r *relay) {
+	defer close(r.done)
+	for data := range r.outbox {
+		ctx, cancel := gocontext.WithTimeout(gocontext.Background(), relayTimeout)
+		err := r.bus.Publish(ctx, data)
+		cancel()
+		if err != nil {
+			ce.relayErrors.Add(1)
+			ce.logger.Warn("Failed to publish to the event bus", map[string]interface{}{"error": err.Error()})
+		}
+	}
+}
+
+// receiveRelayed sends this instance's clients what another instance broadcast
+func (ce *CollaborationEngine) receiveRelayed(instance string, data []byte) {
+	// Positions are integers too large for a float64, so numbers are kept as written
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var relayed relayedMessage
+	if err := decoder.Decode(&relayed); err != nil || relayed.Message == nil {
+		ce.relayErrors.Add(1)
+		return
+	}
+	if relayed.Instance == instance {
+		return
+	}
+
+	if relayed.Message.Type == MsgOperation {
+		var payload OperationPayload
+		if decodePayload(relayed.Message, &payload) == nil && payload.Operation != nil {
+			ce.forgetDocument(relayed.DocumentID, payload.Operation.Metadata.Branch)
+		}
+	}
+
+	ce.mutex.RLock()
+	defer ce.mutex.RUnlock()
+	ce.deliver(relayed.DocumentID, relayed.Message, relayed.Exclude)
+}
+
+// forgetDocument drops what's cached of a document another instance changed,
+// so it is read again from the shared store
+func (ce *CollaborationEngine) forgetDocument(documentID, branch string) {
+	// Only main's documents are cached, other branches are rendered each time
+	key := branchLockKey(branch)
+	if operations.IsMainBranch(branch) {
+		key = documentID
+	}
+	lock := ce.documentLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if operations.IsMainBranch(branch) {
+		ce.documents.invalidate(documentID)
+	}
+	ce.mutex.Lock()
+	delete(ce.heads, lineKey(documentID, branch))
+	ce.mutex.Unlock()
+}
+
+// closeRelay publishes what's still queued, for as long as ctx allows, then
+// closes the event bus
+func (ce *CollaborationEngine) closeRelay(ctx gocontext.Context) error {
+	ce.mutex.Lock()
+	r := ce.relay
+	if r == nil || r.closed {
+		ce.mutex.Unlock()
+		return nil
+	}
+	r.closed = true
+	close(r.outbox)
+	ce.mutex.Unlock()
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+	}
+	return r.bus.Close()
+}
@@ -0,0 +1,90 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/eventbus"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestCollaborationEngine_EventBus(t *testing.T) {
+	ctx := gocontext.Background()
+	store := setupTestStorage(t)
+	bus := eventbus.NewMemory()
+
+	// Two instances serving one store, each with a client following main.go
+	first, second := NewCollaborationEngine(store), NewCollaborationEngine(store)
+	firstClient, secondClient := newTestClient(8, 0), newTestClient(8, 0)
+	secondClient.ID = "client-2"
+	for i, engine := range []*CollaborationEngine{first, second} {
+		if err := engine.SetEventBus(bus); err != nil {
+			t.Fatalf("Failed to set event bus: %v", err)
+		}
+		client := []*ClientConnection{firstClient, secondClient}[i]
+		client.SubscribeToDocument("main.go")
+		if err := engine.AddClient(client); err != nil {
+			t.Fatalf("Failed to add client: %v", err)
+		}
+	}
+
+	insert := func(engine *CollaborationEngine, value int64) *operations.Operation {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: "alice"},
+			}),
+			Content:   "line\n",
+			Author:    "alice",
+			Timestamp: time.Now(),
+			Metadata:  operations.OperationMeta{DocumentID: "main.go"},
+		}
+		op.ID = operations.ComputeID(op)
+		if err := engine.ProcessOperation(ctx, op, ""); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+		return op
+	}
+	receive := func(client *ClientConnection, want *operations.Operation) {
+		t.Helper()
+		select {
+		case msg := <-client.sendChan:
+			var payload OperationPayload
+			if err := decodePayload(msg, &payload); err != nil || payload.Operation.ID != want.ID {
+				t.Fatalf("Expected operation %s, got %+v, %v", want.ID, msg, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected operation %s", want.ID)
+		}
+	}
+
+	// The second instance caches main.go, then the first changes it
+	op := insert(second, 1)
+	receive(secondClient, op)
+	receive(firstClient, op)
+	op = insert(first, 2)
+	receive(firstClient, op)
+	receive(secondClient, op)
+	time.Sleep(50 * time.Millisecond)
+	if len(firstClient.sendChan) != 0 {
+		t.Error("Expected the first instance to ignore its own broadcast coming back")
+	}
+
+	doc, err := second.GetDocumentState(ctx, "main.go")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if doc.ConstructCount() != 2 {
+		t.Errorf("Expected the second instance to read the change from the store, got %d constructs", doc.ConstructCount())
+	}
+
+	if err := first.closeRelay(ctx); err != nil {
+		t.Fatalf("Failed to close relay: %v", err)
+	}
+	insert(second, 3)
+	if err := bus.Publish(ctx, []byte("not json")); err == nil {
+		t.Error("Expected a closed bus to refuse messages")
+	}
+}
@@ -0,0 +1,54 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// ActivityReport reports on the stored operations and the conversations in
+// period. When author is set the report only covers their activity.
+func (ce *CollaborationEngine) ActivityReport(ctx gocontext.Context, period context.TimePeriod, author operations.AuthorID) (*context.ActivityReport, error) {
+	// The store finds the operations of every ID in the author's identity,
+	// and the report is about the ID it is known by
+	aliases := ce.AuthorAliases()
+	author = aliases.Resolve(author)
+
+	var ops []*operations.Operation
+	collect := func(op *operations.Operation) error {
+		if op.Timestamp.Before(period.Start) || op.Timestamp.After(period.End) {
+			return nil
+		}
+		if author == "" || aliases.Same(op.Author, author) {
+			ops = append(ops, op)
+		}
+		return nil
+	}
+
+	var err error
+	if author != "" {
+		err = ce.store.ForEachOperationByAuthor(ctx, author, collect)
+	} else {
+		// Since is exclusive, and operations at the very start belong in the report
+		err = ce.store.ForEachOperationSince(ctx, period.Start.Add(-time.Nanosecond), collect)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := ce.contextAnalyzer.BuildActivityReport(ops, period)
+	if author != "" {
+		authors := []context.AuthorReport{}
+		report.Summary.Conversations = 0
+		for _, authorReport := range report.Authors {
+			if authorReport.AuthorID == author {
+				authors = append(authors, authorReport)
+				report.Summary.Conversations = authorReport.Summary.Conversations
+			}
+		}
+		report.Authors = authors
+	}
+	return report, nil
+}
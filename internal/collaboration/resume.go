@@ -0,0 +1,179 @@
+package collaboration
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// DefaultResumeTTL is how long the session of a client that drops is kept for
+// it to resume
+const DefaultResumeTTL = 2 * time.Minute
+
+// parkedSession is what's kept of a dropped client's session until it
+// resumes or its time runs out: what it followed and what it wasn't sent
+type parkedSession struct {
+	authorID  operations.AuthorID
+	documents []string
+	backlog   []*Message
+	limit     int
+	expires   time.Time
+}
+
+// queue keeps msg for the session, reporting false once the backlog is more
+// than it may hold. Presence is only current while it's fresh, so it's left out.
+func (s *parkedSession) queue(msg *Message) bool {
+	if msg.Type == MsgPresence {
+		return true
+	}
+	s.backlog = append(s.backlog, msg)
+	return len(s.backlog) <= s.limit
+}
+
+func (s *parkedSession) isSubscribedTo(documentID string) bool {
+	for _, document := range s.documents {
+		if document == documentID {
+			return true
+		}
+	}
+	return false
+}
+
+// admitClient adds a newly connected client, resuming the session
+// resumeToken was issued for when it is still parked, and queues the session
+// message ahead of anything else the client is sent
+func (ce *CollaborationEngine) admitClient(client *ClientConnection, resumeToken string) error {
+	ttl := client.config.ResumeTTL
+	session := SessionPayload{ClientID: client.ID}
+	if ttl > 0 {
+		token, err := newResumeToken()
+		if err != nil {
+			return err
+		}
+		client.resumeToken = token
+		session.ResumeToken = token
+		session.ResumeTTLMs = ttl.Milliseconds()
+	}
+
+	ce.mutex.Lock()
+	defer ce.mutex.Unlock()
+
+	if ce.shuttingDown {
+		return ErrEngineShutdown
+	}
+
+	ce.expireSessions(time.Now())
+	var backlog []*Message
+	if parked, ok := ce.sessions[resumeToken]; ok && resumeToken != "" {
+		delete(ce.sessions, resumeToken)
+		// The backlog has to fit behind the session message
+		if parked.authorID == client.AuthorID && len(parked.backlog) < cap(client.sendChan) {
+			for _, documentID := range parked.documents {
+				client.Documents[documentID] = true
+			}
+			session.Resumed = true
+			session.Documents = parked.documents
+			session.Replayed = len(parked.backlog)
+			backlog = parked.backlog
+		}
+	}
+
+	client.sendChan <- &Message{
+		Type:      MsgSession,
+		Payload:   session,
+		MessageID: generateMessageID(),
+		Timestamp: time.Now(),
+		AuthorID:  client.AuthorID,
+	}
+	for _, msg := range backlog {
+		client.sendChan <- msg
+	}
+
+	ce.clients[client.ID] = client
+	ce.presenceTracker.AddClient(client.ID, client.AuthorID)
+	ce.logger.LogClientConnect(string(client.ID), string(client.AuthorID))
+	return nil
+}
+
+// dropClient removes a client whose connection ended. Its session is parked
+// for it to resume, unless it has no token or was evicted for falling behind,
+// in which case it was told to sync instead.
+func (ce *CollaborationEngine) dropClient(client *ClientConnection) {
+	ce.mutex.Lock()
+	if ce.clients[client.ID] != client {
+		ce.mutex.Unlock()
+		return
+	}
+	delete(ce.clients, client.ID)
+
+	if client.resumeToken != "" && !client.wasEvicted() && !ce.shuttingDown {
+		now := time.Now()
+		ce.expireSessions(now)
+		if session, ok := client.park(now.Add(client.config.ResumeTTL)); ok {
+			ce.sessions[client.resumeToken] = session
+		}
+	}
+	ce.mutex.Unlock()
+
+	ce.presenceTracker.RemoveClient(client.ID)
+	ce.logger.LogClientDisconnect(string(client.ID))
+}
+
+// queueForParked keeps msg for the parked sessions following documentID.
+// Sessions whose backlog overflows can no longer be resumed. The caller holds
+// the engine's mutex for reading at least.
+func (ce *CollaborationEngine) queueForParked(documentID string, msg *Message) {
+	ce.sessionsMutex.Lock()
+	defer ce.sessionsMutex.Unlock()
+
+	now := time.Now()
+	for token, session := range ce.sessions {
+		if !session.isSubscribedTo(documentID) {
+			continue
+		}
+		if now.After(session.expires) || !session.queue(msg) {
+			delete(ce.sessions, token)
+		}
+	}
+}
+
+// expireSessions forgets the parked sessions whose time ran out. The caller
+// holds the engine's mutex.
+func (ce *CollaborationEngine) expireSessions(now time.Time) {
+	ce.sessionsMutex.Lock()
+	defer ce.sessionsMutex.Unlock()
+
+	for token, session := range ce.sessions {
+		if now.After(session.expires) {
+			delete(ce.sessions, token)
+		}
+	}
+}
+
+// parkedSessions counts the dropped sessions still waiting to be resumed
+func (ce *CollaborationEngine) parkedSessions() int {
+	ce.mutex.RLock()
+	defer ce.mutex.RUnlock()
+	ce.sessionsMutex.Lock()
+	defer ce.sessionsMutex.Unlock()
+
+	count := 0
+	now := time.Now()
+	for _, session := range ce.sessions {
+		if !now.After(session.expires) {
+			count++
+		}
+	}
+	return count
+}
+
+func newResumeToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate resume token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
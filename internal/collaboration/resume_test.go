@@ -0,0 +1,95 @@
+package collaboration
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestCollaborationEngine_ResumeSession(t *testing.T) {
+	engine := NewCollaborationEngine(setupTestStorage(t))
+
+	connect := func(id ClientID, author operations.AuthorID, token string) (*ClientConnection, SessionPayload) {
+		client := newTestClient(4, 0)
+		client.ID, client.AuthorID = id, author
+		client.config.ResumeTTL = time.Minute
+		if err := engine.admitClient(client, token); err != nil {
+			t.Fatalf("Failed to admit client: %v", err)
+		}
+		msg := <-client.sendChan
+		session, ok := msg.Payload.(SessionPayload)
+		if msg.Type != MsgSession || !ok {
+			t.Fatalf("Expected a session message first, got %+v", msg)
+		}
+		return client, session
+	}
+	broadcast := func(n int) {
+		for i := 0; i < n; i++ {
+			op := &operations.Operation{ID: operations.OperationID(fmt.Sprintf("op-%d", i)), Author: "bob"}
+			engine.BroadcastOperation(op, "main.go", "")
+		}
+	}
+
+	client, session := connect("client-1", "alice", "")
+	if session.ResumeToken == "" || session.Resumed {
+		t.Fatalf("Expected a new session with a token, got %+v", session)
+	}
+	client.SubscribeToDocument("main.go")
+
+	// What's sent as the connection closes and while it's gone is kept, but
+	// not presence
+	client.Close()
+	broadcast(1)
+	engine.dropClient(client)
+	engine.broadcastPresence(PresencePayload{AuthorID: "bob", DocumentID: "main.go"}, "")
+	broadcast(2)
+	if parked := engine.parkedSessions(); parked != 1 {
+		t.Fatalf("Expected the session parked, got %d", parked)
+	}
+
+	resumed, session := connect("client-2", "alice", session.ResumeToken)
+	if !session.Resumed || session.Replayed != 3 || !slices.Equal(session.Documents, []string{"main.go"}) {
+		t.Fatalf("Expected main.go followed and 3 messages replayed, got %+v", session)
+	}
+	if len(resumed.sendChan) != 3 || !resumed.IsSubscribedTo("main.go") {
+		t.Errorf("Expected 3 messages queued for a subscribed client, got %d", len(resumed.sendChan))
+	}
+	if engine.parkedSessions() != 0 {
+		t.Error("Expected a resumed session to stop being parked")
+	}
+
+	// Missing more than a send buffer holds can't be resumed
+	for len(resumed.sendChan) > 0 {
+		<-resumed.sendChan
+	}
+	resumed.Close()
+	engine.dropClient(resumed)
+	broadcast(4)
+	if _, session := connect("client-3", "alice", session.ResumeToken); session.Resumed {
+		t.Error("Expected an overflowed session to start afresh")
+	}
+
+	// Nor can another author resume a session
+	other, session := connect("client-4", "alice", "")
+	other.Close()
+	engine.dropClient(other)
+	if _, session := connect("client-5", "mallory", session.ResumeToken); session.Resumed {
+		t.Error("Expected another author's resume to start afresh")
+	}
+
+	// A message the write pump failed to write as the connection closed is
+	// replayed ahead of those it never got to
+	writing, session := connect("client-6", "alice", "")
+	writing.SubscribeToDocument("main.go")
+	writing.unwritten = &Message{Type: MsgOperation, MessageID: "unwritten"}
+	writing.Close()
+	broadcast(1)
+	engine.dropClient(writing)
+	resumed, session = connect("client-7", "alice", session.ResumeToken)
+	if first := <-resumed.sendChan; session.Replayed != 2 || first.MessageID != "unwritten" {
+		t.Errorf("Expected the unwritten message replayed first of 2, got %+v of %d", first, session.Replayed)
+	}
+}
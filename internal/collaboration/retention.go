@@ -0,0 +1,85 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const defaultRetentionInterval = time.Hour
+
+type RetentionReport struct {
+	DryRun        bool                     `json:"dry_run"`
+	RunAt         time.Time                `json:"run_at"`
+	Policy        storage.RetentionPolicy  `json:"policy"`
+	Operations    []operations.OperationID `json:"operations"`
+	Presence      []ClientID               `json:"presence"`
+	Conversations []context.ThreadID       `json:"conversations"`
+}
+
+func (ce *CollaborationEngine) GetRetentionPolicy() (storage.RetentionPolicy, error) {
+	return ce.store.GetRetentionPolicy()
+}
+
+func (ce *CollaborationEngine) SetRetentionPolicy(policy storage.RetentionPolicy) error {
+	return ce.store.SetRetentionPolicy(policy)
+}
+
+// EnforceRetention purges data older than the configured policy allows.
+// With dryRun set nothing is removed and the report lists what would be purged.
+func (ce *CollaborationEngine) EnforceRetention(ctx gocontext.Context, dryRun bool) (*RetentionReport, error) {
+	policy, err := ce.store.GetRetentionPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load retention policy: %w", err)
+	}
+
+	now := time.Now()
+	report := &RetentionReport{
+		DryRun:        dryRun,
+		RunAt:         now,
+		Policy:        policy,
+		Operations:    []operations.OperationID{},
+		Presence:      []ClientID{},
+		Conversations: []context.ThreadID{},
+	}
+
+	if policy.Operations > 0 {
+		purged, err := ce.store.PurgeOperationsBefore(ctx, now.Add(-policy.Operations.Duration()), dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge operations: %w", err)
+		}
+		report.Operations = append(report.Operations, purged...)
+	}
+
+	if policy.Presence > 0 {
+		purged := ce.presenceTracker.PurgeBefore(now.Add(-policy.Presence.Duration()), dryRun)
+		report.Presence = append(report.Presence, purged...)
+	}
+
+	if policy.ResolvedConversations > 0 {
+		purged := ce.conversationManager.PurgeResolvedBefore(now.Add(-policy.ResolvedConversations.Duration()), dryRun)
+		report.Conversations = append(report.Conversations, purged...)
+	}
+
+	if !dryRun {
+		ce.logger.Info("Retention policy enforced", map[string]interface{}{
+			"operations":    len(report.Operations),
+			"presence":      len(report.Presence),
+			"conversations": len(report.Conversations),
+		})
+	}
+
+	return report, nil
+}
+
+// RetentionInterval is how often the retention policy asks to be enforced
+func (ce *CollaborationEngine) RetentionInterval() time.Duration {
+	if policy, err := ce.store.GetRetentionPolicy(); err == nil && policy.EnforceInterval > 0 {
+		return policy.EnforceInterval.Duration()
+	}
+	return defaultRetentionInterval
+}
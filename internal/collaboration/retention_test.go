@@ -0,0 +1,85 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestCollaborationEngine_PurgedParents(t *testing.T) {
+	ctx := gocontext.Background()
+	dir := t.TempDir()
+	store, err := storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	authorID := operations.AuthorID("test_author")
+
+	newInsert := func(content string, value int64, timestamp time.Time, parents ...operations.OperationID) *operations.Operation {
+		op := &operations.Operation{
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: authorID},
+			}),
+			Content:   content,
+			Author:    authorID,
+			Timestamp: timestamp,
+			Parents:   parents,
+			Metadata:  operations.OperationMeta{DocumentID: "test.go"},
+		}
+		op.ID = operations.ComputeID(op)
+		return op
+	}
+
+	// The old insert is replaced, so no construct holds on to it, but the
+	// replacement still names it as its parent
+	old := newInsert("old", 1, time.Now().Add(-48*time.Hour))
+	replacement := newInsert("new", 1, time.Now(), old.ID)
+	engine := NewCollaborationEngine(store)
+	for _, op := range []*operations.Operation{old, replacement} {
+		if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+	}
+	purged, err := store.PurgeOperationsBefore(ctx, time.Now().Add(-24*time.Hour), false)
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if len(purged) != 1 || purged[0] != old.ID {
+		t.Fatalf("Expected the old insert purged, got %v", purged)
+	}
+	// Shutting down closes the store
+	if err := engine.Shutdown(ctx); err != nil {
+		t.Fatalf("Failed to shut down engine: %v", err)
+	}
+
+	store, err = storage.NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+	report, err := store.Check(ctx, false)
+	if err != nil {
+		t.Fatalf("Failed to check store: %v", err)
+	}
+	for _, problem := range report.Problems {
+		if problem.Kind == storage.ProblemMissingParent {
+			t.Errorf("Expected a purged parent not to be reported missing, got %+v", problem)
+		}
+	}
+
+	// A concurrent child of the purged operation still applies after a restart
+	engine = NewCollaborationEngine(store)
+	child := newInsert("child", 2, time.Now(), old.ID)
+	if err := engine.ProcessOperation(ctx, child, "client"); err != nil {
+		t.Fatalf("Failed to process a child of a purged operation: %v", err)
+	}
+	orphan := newInsert("orphan", 3, time.Now(), operations.NewOperationID([]byte("never applied")))
+	if err := engine.ProcessOperation(ctx, orphan, "client"); err == nil {
+		t.Error("Expected a parent that was never applied to be refused")
+	}
+}
@@ -0,0 +1,38 @@
+package collaboration
+
+import (
+	gocontext "context"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// ReviewsInDocument returns the reviews matching filter of code in the
+// document at path, most recently updated first. A review is in the document
+// its anchor was created in, or without an anchor, its change set's.
+func (ce *CollaborationEngine) ReviewsInDocument(ctx gocontext.Context, path string, filter context.ReviewFilter) []*context.Review {
+	documentOf := make(map[operations.OperationID]string)
+
+	reviews := []*context.Review{}
+	for _, review := range ce.conversationManager.Reviews(filter) {
+		opID := review.Address.OperationID
+		if opID == "" {
+			opID, _ = review.ChangeSetID.FirstOperation()
+		}
+		if opID == "" {
+			continue
+		}
+
+		documentID, seen := documentOf[opID]
+		if !seen {
+			if op, err := ce.store.GetOperation(ctx, opID); err == nil {
+				documentID = op.Metadata.DocumentID
+			}
+			documentOf[opID] = documentID
+		}
+		if documentID == path {
+			reviews = append(reviews, review)
+		}
+	}
+	return reviews
+}
@@ -0,0 +1,89 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// Search ranks the matches for query. Conversations live in memory, so the
+// store's index of them is brought up to date before they are searched.
+func (ce *CollaborationEngine) Search(ctx gocontext.Context, query storage.SearchQuery) ([]storage.SearchHit, error) {
+	if query.Kind == storage.SearchConversation {
+		var err error
+		if query.Tags, err = normalizeTags(query.Tags); err != nil {
+			return nil, err
+		}
+		if query.Labels, err = normalizeTags(query.Labels); err != nil {
+			return nil, err
+		}
+		if err := ce.indexConversations(ctx); err != nil {
+			return nil, fmt.Errorf("failed to index conversations: %w", err)
+		}
+	}
+	return ce.store.Search(ctx, query)
+}
+
+// indexConversations passes every conversation to the store, which only
+// rewrites those that changed. Syncs are serialized so concurrent searches
+// don't race to index the same thread.
+func (ce *CollaborationEngine) indexConversations(ctx gocontext.Context) error {
+	ce.searchMutex.Lock()
+	defer ce.searchMutex.Unlock()
+
+	threads := ce.conversationManager.Snapshot()
+	entries := make([]storage.ConversationEntry, 0, len(threads))
+	for _, thread := range threads {
+		entries = append(entries, conversationEntry(thread))
+	}
+	return ce.store.IndexConversations(ctx, entries)
+}
+
+func conversationEntry(thread *context.ConversationThread) storage.ConversationEntry {
+	messages := make([]string, len(thread.Messages))
+	var messageTypes []string
+	seen := make(map[context.MessageType]bool)
+	for i, message := range thread.Messages {
+		messages[i] = message.Content
+		if !seen[message.MessageType] {
+			seen[message.MessageType] = true
+			messageTypes = append(messageTypes, string(message.MessageType))
+		}
+	}
+	participants := make([]string, len(thread.Participants))
+	for i, participant := range thread.Participants {
+		participants[i] = string(participant)
+	}
+
+	// A summary is searched along with the messages it sums up
+	if thread.Summary != nil {
+		messages = append([]string{thread.Summary.Text}, messages...)
+	}
+
+	return storage.ConversationEntry{
+		ThreadID:     string(thread.ID),
+		Title:        thread.Title,
+		Body:         strings.Join(messages, "\n\n"),
+		Status:       string(thread.Status),
+		Participants: participants,
+		Tags:         thread.Tags,
+		Labels:       thread.Metadata.Labels,
+		MessageTypes: messageTypes,
+		UpdatedAt:    thread.UpdatedAt,
+	}
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := context.NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
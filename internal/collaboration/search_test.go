@@ -0,0 +1,73 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"math/big"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestCollaborationEngine_SearchConversations(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+
+	pos := operations.NewLogootPosition([]operations.PositionSegment{
+		{Value: big.NewInt(1), AuthorID: "alice"},
+	})
+	anchor := addressing.NewStableAddress("repo", operations.NewOperationID([]byte("op")), addressing.PositionRange{Start: pos, End: pos})
+
+	first, _ := engine.CreateConversation(anchor, "alice", "Retry policy", "How many retries before giving up?")
+	engine.CreateConversation(anchor, "bob", "Logging", "Should retries be logged?")
+
+	hits, err := engine.Search(ctx, storage.SearchQuery{Text: "retries", Kind: storage.SearchConversation})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("Expected both conversations, got %+v", hits)
+	}
+
+	// Changes to conversations are picked up by the next search
+	if _, err := engine.AddMessageToConversation(first.ID, "carol", "Five, with backoff", context.MsgAnswer); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	if _, err := engine.ConversationManager().AddTags(first.ID, "config"); err != nil {
+		t.Fatalf("Failed to tag conversation: %v", err)
+	}
+
+	hits, err = engine.Search(ctx, storage.SearchQuery{Text: "backoff", Kind: storage.SearchConversation, Author: "carol", Tags: []string{"Config"}})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Ref != string(first.ID) {
+		t.Fatalf("Expected the updated conversation, got %+v", hits)
+	}
+	if snippet := hits[0].Snippets[0]; snippet.Text[snippet.Highlights[0].Start:snippet.Highlights[0].End] != "backoff" {
+		t.Errorf("Expected backoff highlighted, got %+v", snippet)
+	}
+
+	hits, err = engine.Search(ctx, storage.SearchQuery{Text: "retries", Kind: storage.SearchConversation, MessageType: string(context.MsgAnswer)})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Ref != string(first.ID) {
+		t.Errorf("Expected only the answered conversation, got %+v", hits)
+	}
+
+	// A summary is searched along with the messages
+	summary := context.ThreadSummary{Text: "Settled on exponential backoff.", Summarizer: "test", Messages: 2}
+	if _, err := engine.ConversationManager().SetSummary(first.ID, summary); err != nil {
+		t.Fatalf("Failed to set summary: %v", err)
+	}
+	hits, err = engine.Search(ctx, storage.SearchQuery{Text: "exponential", Kind: storage.SearchConversation})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Ref != string(first.ID) {
+		t.Errorf("Expected the conversation found by its summary, got %+v", hits)
+	}
+}
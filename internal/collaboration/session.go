@@ -0,0 +1,167 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jeremytregunna/contextdb/internal/ids"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// Connect upgrades r to a WebSocket and serves the client until it
+// disconnects. Clients follow documents by sending sync messages, then send
+// operations and presence and receive what others do in those documents.
+// Operations are acknowledged, other failures are answered with an error
+// message. The first message a client receives describes its session; one
+// that drops may reconnect with the session's resume token to follow the same
+// documents and be sent what it missed. When the upgrade fails the response
+// has already been written.
+func (ce *CollaborationEngine) Connect(w http.ResponseWriter, r *http.Request, authorID operations.AuthorID, resumeToken string) (*ClientConnection, error) {
+	client, err := NewClientConnection(ClientID(ids.NewWithPrefix("client")), authorID, w, r, ce.webSocketConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	client.handler = func(msg *Message) {
+		ce.handleMessage(gocontext.Background(), client, msg)
+	}
+	client.onClose = func() {
+		ce.dropClient(client)
+	}
+	client.onEvict = func() {
+		ce.slowClientEvictions.Add(1)
+	}
+
+	if err := ce.admitClient(client, resumeToken); err != nil {
+		client.WebSocket.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, shutdownReason), time.Now().Add(time.Second))
+		client.Close()
+		return nil, err
+	}
+
+	client.Start()
+	return client, nil
+}
+
+func (ce *CollaborationEngine) handleMessage(ctx gocontext.Context, client *ClientConnection, msg *Message) {
+	var err error
+	switch msg.Type {
+	case MsgSync:
+		var payload SyncPayload
+		if err = decodePayload(msg, &payload); err == nil {
+			err = ce.SyncClientWith(ctx, client.ID, payload)
+		}
+
+	case MsgOperation:
+		var payload OperationPayload
+		if err = decodePayload(msg, &payload); err == nil {
+			err = ce.processClientOperation(ctx, client, &payload)
+		}
+
+		ack := AckPayload{MessageID: msg.MessageID, Success: err == nil}
+		if err != nil {
+			ack.Error = err.Error()
+		} else {
+			ack.OperationID = payload.Operation.ID
+			ack.Parents = payload.Operation.Parents
+		}
+		client.SendMessage(&Message{
+			Type:      MsgAcknowledgment,
+			Payload:   ack,
+			MessageID: generateMessageID(),
+			Timestamp: time.Now(),
+			AuthorID:  client.AuthorID,
+		})
+		return
+
+	case MsgPresence:
+		var payload PresencePayload
+		if err = decodePayload(msg, &payload); err == nil {
+			payload.AuthorID = client.AuthorID
+			payload.LastActive = time.Now()
+			err = ce.UpdatePresence(client.ID, payload)
+		}
+
+	default:
+		err = fmt.Errorf("%w: unsupported type %q", ErrInvalidMessage, msg.Type)
+	}
+
+	if err != nil {
+		client.SendMessage(&Message{
+			Type: MsgError,
+			Payload: ErrorPayload{
+				Code:    string(msg.Type),
+				Message: err.Error(),
+				Details: map[string]interface{}{"message_id": msg.MessageID},
+			},
+			MessageID: generateMessageID(),
+			Timestamp: time.Now(),
+			AuthorID:  client.AuthorID,
+		})
+	}
+}
+
+// processClientOperation fills in what a client may leave out of an
+// operation, as the REST API does, and applies it
+func (ce *CollaborationEngine) processClientOperation(ctx gocontext.Context, client *ClientConnection, payload *OperationPayload) error {
+	op := payload.Operation
+	if op == nil {
+		return fmt.Errorf("%w: operation required", ErrInvalidMessage)
+	}
+	if payload.DocumentID != "" {
+		op.Metadata.DocumentID = payload.DocumentID
+	}
+
+	opts, err := ce.prepareOperation(ctx, op, client.AuthorID)
+	if err != nil {
+		return err
+	}
+	_, err = ce.ProcessOperationAt(ctx, op, client.ID, nil, opts...)
+	return err
+}
+
+// prepareOperation fills in the author, timestamp and ID op may leave out,
+// checks it may be applied, and returns how to apply it. Operations without
+// an ID take the document's heads as parents.
+func (ce *CollaborationEngine) prepareOperation(ctx gocontext.Context, op *operations.Operation, author operations.AuthorID) ([]ProcessOption, error) {
+	if op.Author == "" {
+		op.Author = author
+	}
+	if op.Timestamp.IsZero() {
+		op.Timestamp = time.Now()
+	}
+	var opts []ProcessOption
+	if op.ID == "" {
+		op.ID = operations.ComputeID(op)
+		if op.Metadata.Signature == "" {
+			opts = append(opts, AssignParents())
+		}
+	} else if err := operations.ValidateID(op); err != nil {
+		return nil, err
+	}
+
+	if err := ce.ValidateIntent(op.Metadata.Intent); err != nil {
+		return nil, err
+	}
+	if err := ce.VerifyOperation(ctx, op); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// decodePayload converts the generic payload a message was read with into
+// the type its message type calls for
+func decodePayload(msg *Message, payload interface{}) error {
+	data, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	if err := json.Unmarshal(data, payload); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	return nil
+}
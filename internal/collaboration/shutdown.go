@@ -0,0 +1,96 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const shutdownReason = "server shutting down"
+
+// beginOperation registers an in-flight operation so Shutdown can wait for
+// it, and reports false once the engine has stopped accepting operations.
+func (ce *CollaborationEngine) beginOperation() bool {
+	ce.mutex.RLock()
+	defer ce.mutex.RUnlock()
+
+	if ce.shuttingDown {
+		return false
+	}
+	ce.inflight.Add(1)
+	return true
+}
+
+// Shutdown stops accepting operations and clients, waits for operations
+// already being processed, then flushes each client's pending broadcasts
+// behind a close message, and any dirty documents, before closing the store.
+// If ctx ends first, the remaining clients are disconnected immediately.
+// Later calls return the result of the first.
+func (ce *CollaborationEngine) Shutdown(ctx gocontext.Context) error {
+	ce.shutdownOnce.Do(func() {
+		ce.shutdownErr = ce.shutdown(ctx)
+	})
+	return ce.shutdownErr
+}
+
+func (ce *CollaborationEngine) shutdown(ctx gocontext.Context) error {
+	ce.mutex.Lock()
+	ce.shuttingDown = true
+	ce.mutex.Unlock()
+
+	// In-flight operations still broadcast, so they finish before clients go
+	done := make(chan struct{})
+	go func() {
+		ce.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// An operation may still be writing, so leave the store open rather
+		// than pull it out from under it
+		ce.disconnectClients(ctx)
+		ce.closeRelay(ctx)
+		return ctx.Err()
+	}
+
+	ce.disconnectClients(ctx)
+	return errors.Join(ctx.Err(), ce.closeRelay(ctx), ce.FlushDocuments(ctx), ce.store.Close())
+}
+
+func (ce *CollaborationEngine) disconnectClients(ctx gocontext.Context) {
+	ce.mutex.Lock()
+	clients := make([]*ClientConnection, 0, len(ce.clients))
+	for clientID, client := range ce.clients {
+		clients = append(clients, client)
+		delete(ce.clients, clientID)
+	}
+	clear(ce.sessions)
+	ce.mutex.Unlock()
+
+	msg := &Message{
+		Type:      MsgClose,
+		Payload:   &ClosePayload{Reason: shutdownReason},
+		MessageID: generateMessageID(),
+		Timestamp: time.Now(),
+	}
+
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		ce.presenceTracker.RemoveClient(client.ID)
+
+		if err := client.SendMessage(msg); err != nil {
+			ce.logger.LogOperationBroadcastError(string(client.ID), err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Drain(ctx, shutdownReason)
+			ce.logger.LogClientDisconnect(string(client.ID))
+		}()
+	}
+	wg.Wait()
+}
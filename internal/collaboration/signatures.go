@@ -0,0 +1,51 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// OperationVerifier decides whether an operation really comes from its author
+type OperationVerifier interface {
+	VerifyOperation(op *operations.Operation) error
+}
+
+// SetOperationVerifier checks operations clients submit, over the API or a
+// WebSocket, before they are applied. Replicated and imported operations
+// aren't checked.
+func (ce *CollaborationEngine) SetOperationVerifier(verifier OperationVerifier) {
+	ce.mutex.Lock()
+	defer ce.mutex.Unlock()
+	ce.verifier = verifier
+}
+
+// VerifyOperation rejects an operation the verifier refuses, and a signed
+// operation that has already been applied, which can only be a replay
+func (ce *CollaborationEngine) VerifyOperation(ctx gocontext.Context, op *operations.Operation) error {
+	ce.mutex.RLock()
+	verifier := ce.verifier
+	ce.mutex.RUnlock()
+
+	if verifier != nil {
+		if err := verifier.VerifyOperation(op); err != nil {
+			return err
+		}
+	}
+	if op.Metadata.Signature == "" {
+		return nil
+	}
+
+	_, err := ce.store.GetOperation(ctx, op.ID)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: %s", ErrOperationReplayed, op.ID)
+	case errors.Is(err, storage.ErrOperationNotFound):
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,54 @@
+package collaboration
+
+import (
+	gocontext "context"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// Stats sizes everything the engine holds: the store, conversations and the
+// clients connected for live collaboration
+type Stats struct {
+	storage.StoreStats
+	Conversations         int                          `json:"conversations"`
+	ConversationsByStatus map[context.ThreadStatus]int `json:"conversations_by_status"`
+	ConnectedClients      int                          `json:"connected_clients"`
+	// SaturatedClients have a full send buffer and are losing messages
+	SaturatedClients int `json:"saturated_clients"`
+	// DroppedMessages and DroppedPresence count what connected clients have
+	// lost to backpressure
+	DroppedMessages uint64 `json:"dropped_messages"`
+	DroppedPresence uint64 `json:"dropped_presence"`
+	// SlowClientEvictions counts clients disconnected since startup for
+	// staying saturated
+	SlowClientEvictions uint64 `json:"slow_client_evictions"`
+	// ParkedSessions are those of dropped clients waiting to be resumed
+	ParkedSessions int                `json:"parked_sessions"`
+	DocumentCache  DocumentCacheStats `json:"document_cache"`
+}
+
+func (ce *CollaborationEngine) Stats(ctx gocontext.Context) (*Stats, error) {
+	storeStats, err := ce.store.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		StoreStats:            *storeStats,
+		ConversationsByStatus: ce.conversationManager.CountByStatus(),
+	}
+	for _, count := range stats.ConversationsByStatus {
+		stats.Conversations += count
+	}
+
+	pressure := ce.clientBackpressure()
+	stats.ConnectedClients = pressure.clients
+	stats.SaturatedClients = pressure.saturated
+	stats.DroppedMessages = pressure.droppedMessages
+	stats.DroppedPresence = pressure.droppedPresence
+	stats.SlowClientEvictions = ce.slowClientEvictions.Load()
+	stats.ParkedSessions = ce.parkedSessions()
+	stats.DocumentCache = ce.documents.snapshot()
+	return stats, nil
+}
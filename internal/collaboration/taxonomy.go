@@ -0,0 +1,61 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// intentTaxonomySetting is the setting the repository's intent taxonomy is
+// saved under
+const intentTaxonomySetting = "intent_taxonomy"
+
+// LoadIntentTaxonomy classifies operations with the taxonomy saved in the
+// store. Repositories that never saved one keep the default.
+func (ce *CollaborationEngine) LoadIntentTaxonomy(ctx gocontext.Context) error {
+	value, err := ce.store.GetSetting(ctx, intentTaxonomySetting)
+	if errors.Is(err, storage.ErrSettingNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var taxonomy context.IntentTaxonomy
+	if err := json.Unmarshal(value, &taxonomy); err != nil {
+		return fmt.Errorf("%w: %v", context.ErrInvalidTaxonomy, err)
+	}
+	return ce.contextAnalyzer.SetIntentTaxonomy(taxonomy)
+}
+
+// IntentTaxonomy returns the categories operations are classified into
+func (ce *CollaborationEngine) IntentTaxonomy() context.IntentTaxonomy {
+	return ce.contextAnalyzer.IntentTaxonomy()
+}
+
+// SetIntentTaxonomy saves a taxonomy for the repository and classifies with
+// it from then on. It returns the taxonomy as saved, normalized.
+func (ce *CollaborationEngine) SetIntentTaxonomy(ctx gocontext.Context, taxonomy context.IntentTaxonomy) (context.IntentTaxonomy, error) {
+	normalized, err := taxonomy.Normalize()
+	if err != nil {
+		return context.IntentTaxonomy{}, err
+	}
+	value, err := json.Marshal(normalized)
+	if err != nil {
+		return context.IntentTaxonomy{}, err
+	}
+	if err := ce.store.PutSetting(ctx, intentTaxonomySetting, value); err != nil {
+		return context.IntentTaxonomy{}, err
+	}
+	return normalized, ce.contextAnalyzer.SetIntentTaxonomy(normalized)
+}
+
+// ValidateIntent reports context.ErrUnknownIntent for an explicit intent the
+// repository's strict taxonomy doesn't know
+func (ce *CollaborationEngine) ValidateIntent(intent string) error {
+	return ce.IntentTaxonomy().ValidateIntent(intent)
+}
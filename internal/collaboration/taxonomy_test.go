@@ -0,0 +1,41 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+)
+
+func TestCollaborationEngine_IntentTaxonomy(t *testing.T) {
+	ctx := gocontext.Background()
+	store := setupTestStorage(t)
+	engine := NewCollaborationEngine(store)
+
+	if _, err := engine.SetIntentTaxonomy(ctx, context.IntentTaxonomy{}); !errors.Is(err, context.ErrInvalidTaxonomy) {
+		t.Errorf("Expected ErrInvalidTaxonomy for an empty taxonomy, got %v", err)
+	}
+	saved, err := engine.SetIntentTaxonomy(ctx, context.IntentTaxonomy{
+		Categories: []context.IntentDefinition{{Name: "Chore", Keywords: []string{"bump"}}},
+		Strict:     true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to set intent taxonomy: %v", err)
+	}
+	if saved.Categories[0].Name != "chore" {
+		t.Errorf("Expected the saved taxonomy normalized, got %+v", saved)
+	}
+
+	// A new engine over the same store classifies with the saved taxonomy
+	reopened := NewCollaborationEngine(store)
+	if err := reopened.LoadIntentTaxonomy(ctx); err != nil {
+		t.Fatalf("Failed to load intent taxonomy: %v", err)
+	}
+	if err := reopened.ValidateIntent("bump"); err != nil {
+		t.Errorf("Expected the saved keyword accepted, got %v", err)
+	}
+	if err := reopened.ValidateIntent("feature"); !errors.Is(err, context.ErrUnknownIntent) {
+		t.Errorf("Expected the default categories replaced, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/telemetry"
+)
+
+// JournalImport is what importing an editor's edit journal stored
+type JournalImport struct {
+	Operations int                `json:"operations"`
+	Documents  []ImportedDocument `json:"documents"`
+}
+
+// ImportedDocument is the operations an import made in one document, in the
+// order they were applied, and the document's version after them
+type ImportedDocument struct {
+	DocumentID string                   `json:"document_id"`
+	Operations []operations.OperationID `json:"operations"`
+	Version    uint64                   `json:"version"`
+}
+
+// ImportEditJournal replays an editor's edit journal one document at a time,
+// in the order the journal first edits them. A document's operations are
+// each applied at the version the one before left, so when someone else
+// changes the document meanwhile the import stops with a *VersionConflictError
+// rather than placing edits by stale offsets. Documents imported before then
+// keep their operations.
+func (ce *CollaborationEngine) ImportEditJournal(ctx gocontext.Context, journal *telemetry.Journal) (*JournalImport, error) {
+	report := &JournalImport{Documents: []ImportedDocument{}}
+	client := ClientID(journal.Author)
+
+	for _, file := range journal.Files() {
+		doc, err := ce.BranchDocument(ctx, file, journal.Branch)
+		if err != nil {
+			return nil, err
+		}
+		ops, err := journal.Operations(file, doc)
+		if err != nil {
+			return nil, err
+		}
+
+		imported := ImportedDocument{DocumentID: file, Operations: make([]operations.OperationID, 0, len(ops)), Version: doc.CurrentVersion()}
+		for _, op := range ops {
+			if err := ce.VerifyOperation(ctx, op); err != nil {
+				return nil, err
+			}
+			expected := imported.Version
+			if imported.Version, err = ce.ProcessOperationAt(ctx, op, client, &expected, AssignParents()); err != nil {
+				return nil, fmt.Errorf("failed to import edits to %s: %w", file, err)
+			}
+			imported.Operations = append(imported.Operations, op.ID)
+		}
+
+		report.Operations += len(imported.Operations)
+		report.Documents = append(report.Documents, imported)
+	}
+	return report, nil
+}
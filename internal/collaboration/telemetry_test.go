@@ -0,0 +1,63 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/telemetry"
+)
+
+func TestCollaborationEngine_ImportEditJournal(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+
+	at := time.Now().Add(-time.Hour).Truncate(time.Second)
+	journal := &telemetry.Journal{
+		Format: telemetry.FormatVSCode, Version: telemetry.FormatVersion, Author: "alice", SessionID: "s1",
+		Events: []telemetry.EditEvent{
+			{File: "retry.go", Timestamp: at, Changes: []telemetry.ContentChange{{Text: "package net\n"}}},
+			{File: "retry.go", Timestamp: at.Add(time.Second), Changes: []telemetry.ContentChange{{RangeOffset: 8, RangeLength: 3, Text: "retry"}}},
+		},
+	}
+	imported, err := engine.ImportEditJournal(ctx, journal)
+	if err != nil {
+		t.Fatalf("Failed to import edit journal: %v", err)
+	}
+	if len(imported.Documents) != 1 || imported.Documents[0].Version != uint64(imported.Operations) {
+		t.Fatalf("Expected one document at the version of its imported operations, got %+v", imported)
+	}
+	doc, err := engine.GetDocumentState(ctx, "retry.go")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if content, _ := doc.Render(); content != "package retry\n" {
+		t.Errorf("Expected the journal's edits applied, got %q", content)
+	}
+	heads, _ := engine.DocumentHeads(ctx, "retry.go")
+	if last := imported.Documents[0].Operations; len(heads) != 1 || heads[0] != last[len(last)-1] {
+		t.Errorf("Expected the operations chained one after another, got heads %v", heads)
+	}
+
+	// Offsets on a branch are read against the branch's document
+	if _, err := engine.CreateBranch(ctx, "agent", "", "", "alice"); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	journal.Branch = "agent"
+	journal.Events = []telemetry.EditEvent{
+		{File: "retry.go", Timestamp: time.Now(), Changes: []telemetry.ContentChange{{RangeOffset: 14, Text: "\nconst attempts = 3\n"}}},
+	}
+	if _, err := engine.ImportEditJournal(ctx, journal); err != nil {
+		t.Fatalf("Failed to import edit journal on a branch: %v", err)
+	}
+	onBranch, err := engine.BranchDocument(ctx, "retry.go", "agent")
+	if err != nil {
+		t.Fatalf("Failed to get branch document: %v", err)
+	}
+	if content, _ := onBranch.Render(); content != "package retry\n\nconst attempts = 3\n" {
+		t.Errorf("Expected the edit on the branch, got %q", content)
+	}
+	if content, _ := doc.Render(); content != "package retry\n" {
+		t.Errorf("Expected main left alone, got %q", content)
+	}
+}
@@ -0,0 +1,274 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// ThroughputConfig is one run of MeasureThroughput
+type ThroughputConfig struct {
+	// DocumentSize is how many bytes of text the document holds before the
+	// first operation is timed
+	DocumentSize int `json:"document_size"`
+	// Clients follow the document, each sent every operation
+	Clients int `json:"clients"`
+	// Writers process operations at the same time, one after another each
+	Writers int `json:"writers"`
+	// The run ends after Operations, or once Duration has passed when that
+	// is zero
+	Operations int           `json:"operations,omitempty"`
+	Duration   time.Duration `json:"duration,omitempty"`
+}
+
+// ThroughputResult is what a run of MeasureThroughput measured
+type ThroughputResult struct {
+	Config       ThroughputConfig `json:"config"`
+	Operations   int              `json:"operations"`
+	Elapsed      time.Duration    `json:"elapsed_ns"`
+	OpsPerSecond float64          `json:"ops_per_second"`
+	// The latencies are of single calls to ProcessOperation
+	P50 time.Duration `json:"p50_ns"`
+	P99 time.Duration `json:"p99_ns"`
+	Max time.Duration `json:"max_ns"`
+	// Delivered counts the operations clients were sent, and Undelivered
+	// those that didn't fit in a send buffer
+	Delivered   uint64 `json:"delivered"`
+	Undelivered uint64 `json:"undelivered"`
+}
+
+// MeasureThroughput times inserts processed end to end, through the DAG,
+// the store, the document and the broadcast, into documentID once it holds
+// config.DocumentSize bytes and config.Clients follow it. The clients are
+// in-process and encode what they're sent as a WebSocket would, so only the
+// network is left out. documentID must not exist yet.
+func (ce *CollaborationEngine) MeasureThroughput(ctx gocontext.Context, documentID string, config ThroughputConfig) (*ThroughputResult, error) {
+	if config.Writers <= 0 {
+		config.Writers = 1
+	}
+	if config.Operations <= 0 && config.Duration <= 0 {
+		return nil, fmt.Errorf("a throughput run needs a number of operations or a duration")
+	}
+
+	run, err := ce.newThroughputRun(ctx, documentID, config.DocumentSize, config.Clients, config.Writers)
+	if err != nil {
+		return nil, err
+	}
+	defer run.close()
+
+	var deadline time.Time
+	if config.Operations <= 0 {
+		deadline = time.Now().Add(config.Duration)
+	}
+	var remaining atomic.Int64
+	remaining.Store(int64(config.Operations))
+
+	latencies := make([][]time.Duration, config.Writers)
+	errs := make([]error, config.Writers)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for writer := 0; writer < config.Writers; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if deadline.IsZero() && remaining.Add(-1) < 0 {
+					return
+				}
+				if !deadline.IsZero() && !time.Now().Before(deadline) {
+					return
+				}
+				began := time.Now()
+				if err := run.write(ctx, writer); err != nil {
+					errs[writer] = err
+					return
+				}
+				latencies[writer] = append(latencies[writer], time.Since(began))
+			}
+		}(writer)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var all []time.Duration
+	for _, writer := range latencies {
+		all = append(all, writer...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	result := &ThroughputResult{
+		Config:     config,
+		Operations: len(all),
+		Elapsed:    elapsed,
+	}
+	if len(all) > 0 {
+		result.OpsPerSecond = float64(len(all)) / elapsed.Seconds()
+		result.P50 = all[len(all)/2]
+		result.P99 = all[len(all)*99/100]
+		result.Max = all[len(all)-1]
+	}
+	// Clients still encoding what they were sent count once they're done
+	run.close()
+	result.Delivered = run.delivered.Load()
+	result.Undelivered = uint64(len(all)*config.Clients) - result.Delivered
+	return result, nil
+}
+
+// throughputRun is a document set up for timing inserts into, with the
+// clients following it
+type throughputRun struct {
+	ce         *CollaborationEngine
+	documentID string
+	// previous is where each writer inserted last, so each appends to the end
+	// of the document
+	previous  []operations.LogootPosition
+	clients   []*ClientConnection
+	delivered atomic.Uint64
+	reading   sync.WaitGroup
+	closeOnce sync.Once
+}
+
+func (ce *CollaborationEngine) newThroughputRun(ctx gocontext.Context, documentID string, size, clients, writers int) (*throughputRun, error) {
+	run := &throughputRun{
+		ce:         ce,
+		documentID: documentID,
+		previous:   make([]operations.LogootPosition, writers),
+	}
+
+	// The document is filled a few kilobytes at a time; a line at a time
+	// would take minutes for large documents, as each insert stores the
+	// whole document again
+	var previous operations.LogootPosition
+	for _, chunk := range throughputText(size) {
+		var err error
+		if previous, err = run.insert(ctx, "throughput-seed", previous, chunk); err != nil {
+			return nil, fmt.Errorf("failed to fill %s: %w", documentID, err)
+		}
+	}
+	for writer := range run.previous {
+		run.previous[writer] = previous
+	}
+
+	config := ce.webSocketConfig()
+	if config.SendBufferSize <= 0 {
+		config.SendBufferSize = DefaultSendBufferSize
+	}
+	for i := 0; i < clients; i++ {
+		client := &ClientConnection{
+			ID:        ClientID(fmt.Sprintf("throughput-client-%d", i)),
+			AuthorID:  operations.AuthorID(fmt.Sprintf("throughput-reader-%d", i)),
+			Documents: map[string]bool{documentID: true},
+			LastSeen:  time.Now(),
+			sendChan:  make(chan *Message, config.SendBufferSize),
+			closeChan: make(chan struct{}),
+			config:    config,
+			evicted:   make(chan struct{}),
+		}
+		if err := ce.AddClient(client); err != nil {
+			run.close()
+			return nil, err
+		}
+		run.clients = append(run.clients, client)
+		run.reading.Add(1)
+		go run.read(client)
+	}
+	return run, nil
+}
+
+// read encodes each message client is sent, as its write pump would
+func (r *throughputRun) read(client *ClientConnection) {
+	defer r.reading.Done()
+	encoder := json.NewEncoder(io.Discard)
+	for msg := range client.sendChan {
+		encoder.Encode(msg)
+		if msg.Type == MsgOperation {
+			r.delivered.Add(1)
+		}
+	}
+}
+
+// write processes one insert of a line after the last writer inserted
+func (r *throughputRun) write(ctx gocontext.Context, writer int) error {
+	author := operations.AuthorID(fmt.Sprintf("throughput-writer-%d", writer))
+	line := fmt.Sprintf("\twriter %d appended this line at %d\n", writer, time.Now().UnixNano())
+	position, err := r.insert(ctx, author, r.previous[writer], line)
+	if err != nil {
+		return err
+	}
+	r.previous[writer] = position
+	return nil
+}
+
+// insert processes an insert of content after previous, returning where it
+// was inserted
+func (r *throughputRun) insert(ctx gocontext.Context, author operations.AuthorID, previous operations.LogootPosition, content string) (operations.LogootPosition, error) {
+	op := &operations.Operation{
+		Type:      operations.OpInsert,
+		Position:  operations.GeneratePosition(previous, operations.LogootPosition{}, author),
+		Content:   content,
+		Author:    author,
+		Timestamp: time.Now(),
+		Metadata: operations.OperationMeta{
+			SessionID:  "throughput",
+			DocumentID: r.documentID,
+		},
+	}
+	op.ID = operations.ComputeID(op)
+	if _, err := r.ce.ProcessOperationAt(ctx, op, ClientID(author), nil, AssignParents()); err != nil {
+		return operations.LogootPosition{}, fmt.Errorf("failed to process operation: %w", err)
+	}
+	return op.Position, nil
+}
+
+// close disconnects the clients and waits for them to read what they were
+// sent
+func (r *throughputRun) close() {
+	r.closeOnce.Do(func() {
+		for _, client := range r.clients {
+			r.ce.RemoveClient(client.ID)
+		}
+		r.reading.Wait()
+	})
+}
+
+// throughputChunkSize is about how much of a document's text each insert
+// filling it holds
+const throughputChunkSize = 4 << 10
+
+// throughputText is size bytes of source-like text, in chunks of whole
+// functions of about throughputChunkSize bytes, the last cut short
+func throughputText(size int) []string {
+	var chunks []string
+	var chunk strings.Builder
+	for i, total := 0, 0; total < size; i++ {
+		function := fmt.Sprintf("func handler%d(w http.ResponseWriter, r *http.Request) {\n"+
+			"\tif err := process(r.Context()); err != nil {\n"+
+			"\t\thttp.Error(w, err.Error(), http.StatusInternalServerError)\n"+
+			"\t}\n}\n\n", i)
+		if total+len(function) > size {
+			function = function[:size-total]
+		}
+		chunk.WriteString(function)
+		total += len(function)
+		if chunk.Len() >= throughputChunkSize || total == size {
+			chunks = append(chunks, chunk.String())
+			chunk.Reset()
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,84 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestMeasureThroughput(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+
+	result, err := engine.MeasureThroughput(ctx, "bench.go", ThroughputConfig{DocumentSize: 2048, Clients: 3, Writers: 2, Operations: 20})
+	if err != nil {
+		t.Fatalf("Failed to measure throughput: %v", err)
+	}
+	if result.Operations != 20 || result.OpsPerSecond <= 0 {
+		t.Errorf("Expected 20 operations at some rate, got %d at %f", result.Operations, result.OpsPerSecond)
+	}
+	if result.Delivered+result.Undelivered != 60 {
+		t.Errorf("Expected 60 operations sent to clients, got %d delivered and %d not", result.Delivered, result.Undelivered)
+	}
+	if result.P50 > result.P99 || result.P99 > result.Max {
+		t.Errorf("Expected ordered latencies, got p50 %v, p99 %v, max %v", result.P50, result.P99, result.Max)
+	}
+
+	doc, err := engine.getOrLoadDocument(ctx, "bench.go")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	content, err := doc.Render()
+	if err != nil {
+		t.Fatalf("Failed to render document: %v", err)
+	}
+	if !strings.HasPrefix(content, strings.Join(throughputText(2048), "")) || strings.Count(content, "appended this line") != 20 {
+		t.Errorf("Expected the seed text followed by 20 lines, got %q", content)
+	}
+	if len(engine.GetConnectedClients()) != 0 {
+		t.Errorf("Expected the clients to be disconnected after the run")
+	}
+}
+
+// BenchmarkProcessOperation measures single inserts through ProcessOperation
+// into an on-disk store, by document size and the number of clients each
+// is broadcast to
+func BenchmarkProcessOperation(b *testing.B) {
+	for _, size := range []int{0, 16 << 10, 256 << 10} {
+		for _, clients := range []int{0, 10, 100} {
+			b.Run(fmt.Sprintf("size=%d/clients=%d", size, clients), func(b *testing.B) {
+				benchmarkProcessOperation(b, size, clients)
+			})
+		}
+	}
+}
+
+func benchmarkProcessOperation(b *testing.B, size, clients int) {
+	ctx := gocontext.Background()
+	store, err := storage.NewSQLiteStore(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("Failed to create storage: %v", err)
+	}
+	engine := NewCollaborationEngine(store)
+	defer engine.Shutdown(ctx)
+
+	run, err := engine.newThroughputRun(ctx, "bench.go", size, clients, 1)
+	if err != nil {
+		b.Fatalf("Failed to set up the document: %v", err)
+	}
+	defer run.close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := run.write(ctx, 0); err != nil {
+			b.Fatalf("Failed to write: %v", err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ops/s")
+}
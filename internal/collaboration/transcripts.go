@@ -0,0 +1,117 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// ConversationTranscript is every conversation anchored in a document, with
+// the code each is anchored to, for reading outside contextdb
+type ConversationTranscript struct {
+	DocumentID string            `json:"document_id"`
+	Version    uint64            `json:"version"`
+	Threads    []TranscriptEntry `json:"threads"`
+}
+
+// TranscriptEntry is one conversation in a transcript. Excerpt is the text of
+// the lines its anchor covers.
+type TranscriptEntry struct {
+	AnchoredConversation
+	Excerpt string `json:"excerpt"`
+}
+
+// ConversationTranscript gathers the unarchived conversations viewer may read
+// that are anchored in a document on main, in creation order
+func (ce *CollaborationEngine) ConversationTranscript(ctx gocontext.Context, documentID string, viewer context.Viewer) (*ConversationTranscript, error) {
+	doc, err := ce.getOrLoadDocument(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if doc.CurrentVersion() == 0 {
+		return nil, storage.ErrDocumentNotFound
+	}
+	content, err := doc.Render()
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.SplitAfter(content, "\n")
+
+	transcript := &ConversationTranscript{DocumentID: documentID, Version: doc.CurrentVersion(), Threads: []TranscriptEntry{}}
+	for _, anchored := range ce.ConversationsInDocument(ctx, doc) {
+		if !viewer.CanView(anchored.Thread) {
+			continue
+		}
+		entry := TranscriptEntry{AnchoredConversation: anchored}
+		if first, last := anchored.FirstLine, min(anchored.LastLine, len(lines)); first >= 1 && first <= last {
+			entry.Excerpt = strings.Join(lines[first-1:last], "")
+		}
+		transcript.Threads = append(transcript.Threads, entry)
+	}
+	return transcript, nil
+}
+
+// Markdown renders the transcript for pasting into a pull request or docs:
+// each conversation under its title, the code it is anchored to, then its
+// messages in order
+func (t *ConversationTranscript) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Conversations in %s\n\n", t.DocumentID)
+	if len(t.Threads) == 0 {
+		b.WriteString("No conversations are anchored in this document.\n")
+		return b.String()
+	}
+
+	language := strings.TrimPrefix(path.Ext(t.DocumentID), ".")
+	for _, entry := range t.Threads {
+		thread := entry.Thread
+		fmt.Fprintf(&b, "## %s\n\n", thread.Title)
+		if entry.FirstLine == entry.LastLine {
+			fmt.Fprintf(&b, "Line %d, %s", entry.FirstLine, thread.Status)
+		} else {
+			fmt.Fprintf(&b, "Lines %d-%d, %s", entry.FirstLine, entry.LastLine, thread.Status)
+		}
+		if len(thread.Tags) > 0 {
+			fmt.Fprintf(&b, ", tagged %s", strings.Join(thread.Tags, ", "))
+		}
+		b.WriteString("\n\n")
+
+		if entry.Excerpt != "" {
+			fence := codeFence(entry.Excerpt)
+			fmt.Fprintf(&b, "%s%s\n%s", fence, language, entry.Excerpt)
+			if !strings.HasSuffix(entry.Excerpt, "\n") {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%s\n\n", fence)
+		}
+
+		for _, message := range thread.Messages {
+			fmt.Fprintf(&b, "**%s** (%s, %s):\n\n", message.AuthorID, message.MessageType, message.Timestamp.UTC().Format(time.DateTime))
+			for _, line := range strings.Split(strings.TrimRight(message.Content, "\n"), "\n") {
+				fmt.Fprintf(&b, "> %s\n", line)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// codeFence is a run of backticks longer than any in content, so the fence
+// can't be closed early
+func codeFence(content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
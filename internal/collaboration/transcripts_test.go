@@ -0,0 +1,77 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestCollaborationEngine_ConversationTranscript(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+
+	op := &operations.Operation{
+		Type: operations.OpInsert,
+		Position: operations.NewLogootPosition([]operations.PositionSegment{
+			{Value: big.NewInt(1), AuthorID: "alice"},
+		}),
+		Content:   "package main\n\nfunc main() {\n\tprintln(\"```\")\n}\n",
+		Author:    "alice",
+		Timestamp: time.Now(),
+		Metadata:  operations.OperationMeta{DocumentID: "cmd/main.go"},
+	}
+	op.ID = operations.ComputeID(op)
+	if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+
+	anchor := addressing.NewStableAddress("acme/app", op.ID, addressing.PositionRange{Start: op.Position, End: op.Position})
+	anchor.Fragment = addressing.LineFragment(3, 5)
+	thread, err := engine.CreateConversation(anchor, "bob", "Entry point", "Should this exit non-zero?")
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if _, err := engine.ConversationManager().AddMessage(thread.ID, "alice", "Yes, on failure.", context.MsgAnswer); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	if _, err := engine.ConversationManager().CreateConversation(anchor, "carol", "Private", "Just for me", context.WithVisibility(context.VisibilityPrivate)); err != nil {
+		t.Fatalf("Failed to create private conversation: %v", err)
+	}
+
+	transcript, err := engine.ConversationTranscript(ctx, "cmd/main.go", context.ViewerFor("bob"))
+	if err != nil {
+		t.Fatalf("Failed to build transcript: %v", err)
+	}
+	if len(transcript.Threads) != 1 || transcript.Threads[0].Thread.ID != thread.ID {
+		t.Fatalf("Expected only the conversation bob may read, got %+v", transcript.Threads)
+	}
+	entry := transcript.Threads[0]
+	if entry.FirstLine != 3 || entry.LastLine != 5 || entry.Excerpt != "func main() {\n\tprintln(\"```\")\n}\n" {
+		t.Errorf("Expected the anchored lines as the excerpt, got lines %d-%d %q", entry.FirstLine, entry.LastLine, entry.Excerpt)
+	}
+
+	markdown := transcript.Markdown()
+	for _, want := range []string{
+		"# Conversations in cmd/main.go\n",
+		"## Entry point\n\nLines 3-5, open\n",
+		"````go\nfunc main() {\n",
+		"> Should this exit non-zero?\n",
+		"**alice** (answer, ",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("Expected the transcript to contain %q, got:\n%s", want, markdown)
+		}
+	}
+
+	if _, err := engine.ConversationTranscript(ctx, "missing.go", context.Viewer{}); !errors.Is(err, storage.ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound for a missing document, got %v", err)
+	}
+}
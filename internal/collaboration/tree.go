@@ -0,0 +1,241 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// DefaultTreeDepth is how many levels below its prefix a document tree shows
+// unless asked for more
+const DefaultTreeDepth = 1
+
+type TreeNodeType string
+
+const (
+	TreeDirectory TreeNodeType = "directory"
+	TreeFile      TreeNodeType = "file"
+)
+
+// TreeNode is a directory or document in a document tree. A directory's
+// counts and last modified time cover every document below it, including
+// those deeper than the tree goes, whose directories are listed without
+// children.
+type TreeNode struct {
+	Name              string       `json:"name"`
+	Path              string       `json:"path"`
+	Type              TreeNodeType `json:"type"`
+	LastModified      time.Time    `json:"last_modified"`
+	OpenConversations int          `json:"open_conversations"`
+	OpenReviews       int          `json:"open_reviews"`
+	// ReviewStatus sums up a document's open reviews, empty when it has none
+	ReviewStatus context.ReviewStatus `json:"review_status,omitempty"`
+	// Files counts the documents below a directory
+	Files    int         `json:"files,omitempty"`
+	Version  uint64      `json:"version,omitempty"`
+	Deleted  bool        `json:"deleted,omitempty"`
+	Children []*TreeNode `json:"children,omitempty"`
+	// Stats sums up a document when the tree was asked for them
+	Stats *DocumentStats `json:"stats,omitempty"`
+}
+
+// DocumentTree is the documents below Prefix grouped by directory, Depth
+// levels deep. Directories come before files, each in name order.
+type DocumentTree struct {
+	Prefix            string      `json:"prefix"`
+	Depth             int         `json:"depth"`
+	Files             int         `json:"files"`
+	OpenConversations int         `json:"open_conversations"`
+	OpenReviews       int         `json:"open_reviews"`
+	LastModified      *time.Time  `json:"last_modified,omitempty"`
+	Nodes             []*TreeNode `json:"nodes"`
+}
+
+// DocumentTreeQuery picks the directory a tree starts at and how deep it
+// goes. Prefix is a directory, so "src" and "src/" are the same. A Depth of
+// zero or less shows every level.
+type DocumentTreeQuery struct {
+	Prefix         string
+	Depth          int
+	IncludeDeleted bool
+	// Stats sums up each document in the tree with DocumentStats
+	Stats bool
+}
+
+// DocumentTree lists documents as a tree of directories, with when each was
+// last modified and how many open conversations and reviews are anchored in it
+func (ce *CollaborationEngine) DocumentTree(ctx gocontext.Context, query DocumentTreeQuery) (*DocumentTree, error) {
+	prefix := query.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	documents, err := ce.store.ListDocumentInfo(ctx, prefix, query.IncludeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	activity := ce.openActivityByDocument(ctx)
+	var stats map[string]*storage.DocumentStats
+	if query.Stats {
+		if stats, err = ce.store.ListDocumentStats(ctx, prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	tree := &DocumentTree{Prefix: prefix, Depth: query.Depth, Nodes: []*TreeNode{}}
+	directories := make(map[string]*TreeNode)
+	for _, info := range documents {
+		open := activity[info.FilePath]
+		tree.Files++
+		tree.OpenConversations += open.conversations
+		tree.OpenReviews += open.reviews
+		if tree.LastModified == nil || info.UpdatedAt.After(*tree.LastModified) {
+			modified := info.UpdatedAt
+			tree.LastModified = &modified
+		}
+
+		// Directories down to the depth limit, then the file if it is within it
+		segments := strings.Split(strings.TrimPrefix(info.FilePath, prefix), "/")
+		children := &tree.Nodes
+		path := prefix
+		for level, name := range segments[:len(segments)-1] {
+			path += name + "/"
+			dir, exists := directories[path]
+			if !exists {
+				dir = &TreeNode{Name: name, Path: path, Type: TreeDirectory}
+				directories[path] = dir
+				*children = append(*children, dir)
+			}
+			dir.Files++
+			dir.OpenConversations += open.conversations
+			dir.OpenReviews += open.reviews
+			if info.UpdatedAt.After(dir.LastModified) {
+				dir.LastModified = info.UpdatedAt
+			}
+			if query.Depth > 0 && level+1 >= query.Depth {
+				children = nil
+				break
+			}
+			children = &dir.Children
+		}
+		if children == nil {
+			continue
+		}
+
+		node := &TreeNode{
+			Name:              segments[len(segments)-1],
+			Path:              info.FilePath,
+			Type:              TreeFile,
+			LastModified:      info.UpdatedAt,
+			OpenConversations: open.conversations,
+			OpenReviews:       open.reviews,
+			ReviewStatus:      open.reviewStatus,
+			Version:           info.Version,
+			Deleted:           info.DeletedAt != nil,
+		}
+		if query.Stats {
+			node.Stats = documentStats(stats[info.FilePath], open)
+		}
+		*children = append(*children, node)
+	}
+
+	sortTree(tree.Nodes)
+	return tree, nil
+}
+
+func sortTree(nodes []*TreeNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Type != nodes[j].Type {
+			return nodes[i].Type == TreeDirectory
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+	for _, node := range nodes {
+		sortTree(node.Children)
+	}
+}
+
+// DocumentStats sums up a document: its constructs, size, authors and last
+// activity from the store's aggregates, and the conversations open in it
+type DocumentStats struct {
+	storage.DocumentStats
+	OpenConversations int `json:"open_conversations"`
+}
+
+// DocumentStats sums up a document on the main branch without loading it
+func (ce *CollaborationEngine) DocumentStats(ctx gocontext.Context, documentID string) (*DocumentStats, error) {
+	stored, err := ce.store.GetDocumentStats(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	return documentStats(stored, ce.openActivityByDocument(ctx)[documentID]), nil
+}
+
+// documentStats adds a document's open conversations to its stored stats,
+// which are missing for a document with neither constructs nor operations
+func documentStats(stored *storage.DocumentStats, open documentActivity) *DocumentStats {
+	stats := &DocumentStats{OpenConversations: open.conversations}
+	if stored != nil {
+		stats.DocumentStats = *stored
+	} else {
+		stats.Constructs = make(map[positioning.ConstructType]int)
+	}
+	return stats
+}
+
+// documentActivity counts the open conversations and reviews anchored in a
+// document, with the most urgent of the reviews' statuses
+type documentActivity struct {
+	conversations int
+	reviews       int
+	reviewStatus  context.ReviewStatus
+}
+
+// openActivityByDocument counts the open and pinned conversations anchored in
+// each document. A review without an anchor is in its change set's document.
+func (ce *CollaborationEngine) openActivityByDocument(ctx gocontext.Context) map[string]documentActivity {
+	activity := make(map[string]documentActivity)
+	documentOf := make(map[operations.OperationID]string)
+
+	for _, thread := range ce.conversationManager.Snapshot() {
+		if thread.Status != context.StatusOpen && thread.Status != context.StatusPinned {
+			continue
+		}
+		opID := thread.AnchorAddress.OperationID
+		if opID == "" && thread.Review != nil {
+			opID, _ = thread.ChangeSetID.FirstOperation()
+		}
+		if opID == "" {
+			continue
+		}
+
+		// Positions are ordered across documents, so only the anchor's operation says which one it is in
+		documentID, seen := documentOf[opID]
+		if !seen {
+			if op, err := ce.store.GetOperation(ctx, opID); err == nil {
+				documentID = op.Metadata.DocumentID
+			}
+			documentOf[opID] = documentID
+		}
+		if documentID == "" {
+			continue
+		}
+
+		counts := activity[documentID]
+		counts.conversations++
+		if thread.Review != nil {
+			counts.reviews++
+			if status := thread.ReviewStatus(); status.Urgency() > counts.reviewStatus.Urgency() {
+				counts.reviewStatus = status
+			}
+		}
+		activity[documentID] = counts
+	}
+	return activity
+}
@@ -0,0 +1,102 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestCollaborationEngine_DocumentTree(t *testing.T) {
+	ctx := gocontext.Background()
+	engine := NewCollaborationEngine(setupTestStorage(t))
+
+	process := func(document string, value int64) addressing.StableAddress {
+		pos := operations.NewLogootPosition([]operations.PositionSegment{
+			{Value: big.NewInt(value), AuthorID: "alice"},
+		})
+		op := &operations.Operation{
+			ID:        operations.NewOperationID([]byte(document)),
+			Type:      operations.OpInsert,
+			Position:  pos,
+			Content:   "// " + document + "\n",
+			Author:    "alice",
+			Timestamp: time.Now(),
+			Metadata:  operations.OperationMeta{Context: map[string]string{"document_id": document}},
+		}
+		if err := engine.ProcessOperation(ctx, op, "client"); err != nil {
+			t.Fatalf("Failed to process operation: %v", err)
+		}
+		return addressing.NewStableAddress("repo", op.ID, addressing.PositionRange{Start: pos, End: pos})
+	}
+
+	process("README.md", 1)
+	retry := process("src/retry.go", 2)
+	dial := process("src/net/dial.go", 3)
+	process("src/net/listen.go", 4)
+	process("docs/guide.md", 5)
+
+	engine.CreateConversation(retry, "bob", "Backoff", "Should this back off?")
+	resolved, _ := engine.CreateConversation(retry, "bob", "Naming", "Rename?")
+	if err := engine.ConversationManager().ResolveConversation(resolved.ID, "alice"); err != nil {
+		t.Fatalf("Failed to resolve conversation: %v", err)
+	}
+
+	tree, err := engine.DocumentTree(ctx, DocumentTreeQuery{Depth: 1})
+	if err != nil {
+		t.Fatalf("Failed to list document tree: %v", err)
+	}
+	if tree.Files != 5 || tree.OpenConversations != 1 || len(tree.Nodes) != 3 {
+		t.Fatalf("Expected 5 files, one open conversation and 3 nodes, got %+v", tree)
+	}
+	if tree.Nodes[0].Name != "docs" || tree.Nodes[1].Name != "src" || tree.Nodes[2].Path != "README.md" {
+		t.Errorf("Expected directories before files in name order, got %+v", tree.Nodes)
+	}
+	src := tree.Nodes[1]
+	if src.Type != TreeDirectory || src.Files != 3 || src.OpenConversations != 1 || src.Children != nil || src.LastModified.IsZero() {
+		t.Errorf("Expected src to summarise 3 files without children, got %+v", src)
+	}
+
+	tree, err = engine.DocumentTree(ctx, DocumentTreeQuery{Prefix: "src", Depth: 0})
+	if err != nil {
+		t.Fatalf("Failed to list document tree: %v", err)
+	}
+	if tree.Prefix != "src/" || tree.Files != 3 || len(tree.Nodes) != 2 {
+		t.Fatalf("Expected src/ with net/ and retry.go, got %+v", tree)
+	}
+	net := tree.Nodes[0]
+	if net.Path != "src/net/" || len(net.Children) != 2 || net.Children[1].Path != "src/net/listen.go" {
+		t.Errorf("Expected src/net/ with both its files, got %+v", net)
+	}
+	if file := tree.Nodes[1]; file.Type != TreeFile || file.Version != 1 || file.OpenConversations != 1 {
+		t.Errorf("Expected retry.go with one open conversation, got %+v", file)
+	}
+
+	// A review of a change set is in the change set's document
+	changeSet := context.ChangeSetID("cs_" + dial.OperationID)
+	if _, err := engine.ConversationManager().CreateReview(changeSet, addressing.StableAddress{}, "alice", "Dialing", "", []operations.AuthorID{"bob"}); err != nil {
+		t.Fatalf("Failed to create review: %v", err)
+	}
+	tree, _ = engine.DocumentTree(ctx, DocumentTreeQuery{Prefix: "src/", Depth: 0})
+	if tree.OpenReviews != 1 || tree.Nodes[0].OpenReviews != 1 || tree.Nodes[0].OpenConversations != 1 {
+		t.Fatalf("Expected the review counted in src/net/, got %+v", tree)
+	}
+	if file := tree.Nodes[0].Children[0]; file.OpenReviews != 1 || file.ReviewStatus != context.ReviewPending {
+		t.Errorf("Expected dial.go with a pending review, got %+v", file)
+	}
+
+	if err := engine.DeleteDocument(ctx, "src/retry.go"); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+	if tree, _ := engine.DocumentTree(ctx, DocumentTreeQuery{Prefix: "src/"}); tree.Files != 2 {
+		t.Errorf("Expected deleted documents to be left out, got %+v", tree)
+	}
+	tree, _ = engine.DocumentTree(ctx, DocumentTreeQuery{Prefix: "src/", IncludeDeleted: true})
+	if tree.Files != 3 || !tree.Nodes[1].Deleted {
+		t.Errorf("Expected the deleted document marked, got %+v", tree)
+	}
+}
@@ -0,0 +1,117 @@
+package collaboration
+
+import (
+	gocontext "context"
+	"fmt"
+	"sync"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// maxMissingOperations bounds how much history a version conflict or sync
+// carries; clients further behind should reload the document instead
+const maxMissingOperations = 1000
+
+// VersionConflictError reports that a document moved on from the version an
+// operation was written against. It matches ErrVersionConflict.
+type VersionConflictError struct {
+	DocumentID      string
+	ExpectedVersion uint64
+	CurrentVersion  uint64
+	// Missing holds the operations applied since ExpectedVersion, oldest first
+	Missing []*operations.Operation
+	// Truncated is set when Missing doesn't reach back to ExpectedVersion
+	Truncated bool
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%v: document %s is at version %d, expected %d",
+		ErrVersionConflict, e.DocumentID, e.CurrentVersion, e.ExpectedVersion)
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+func (ce *CollaborationEngine) documentLock(documentID string) *sync.Mutex {
+	ce.mutex.Lock()
+	defer ce.mutex.Unlock()
+
+	lock, exists := ce.documentLocks[documentID]
+	if !exists {
+		lock = &sync.Mutex{}
+		ce.documentLocks[documentID] = lock
+	}
+	return lock
+}
+
+func (ce *CollaborationEngine) checkVersion(ctx gocontext.Context, doc *positioning.Document, branch string, expected uint64) error {
+	current := doc.CurrentVersion()
+	if current == expected {
+		return nil
+	}
+
+	conflict := &VersionConflictError{
+		DocumentID:      doc.FilePath,
+		ExpectedVersion: expected,
+		CurrentVersion:  current,
+	}
+
+	// A client claiming a version the document never reached has nothing to catch up on
+	if expected < current {
+		missing, truncated, err := ce.operationsSinceVersion(ctx, doc, branch, expected)
+		if err != nil {
+			return fmt.Errorf("failed to get missing operations: %w", err)
+		}
+		conflict.Missing = missing
+		conflict.Truncated = truncated
+	}
+
+	return conflict
+}
+
+// operationsSinceVersion returns the operations that took doc, as seen on
+// branch, from sinceVersion to its current version, oldest first. Each
+// operation that changes a document advances its version by one, so these
+// are the document's most recent operations, up to maxMissingOperations of
+// them. The result is marked truncated when fewer are available.
+func (ce *CollaborationEngine) operationsSinceVersion(ctx gocontext.Context, doc *positioning.Document, branch string, sinceVersion uint64) ([]*operations.Operation, bool, error) {
+	current := doc.CurrentVersion()
+	if sinceVersion >= current {
+		return nil, false, nil
+	}
+
+	want := current - sinceVersion
+	truncated := false
+	if want > maxMissingOperations {
+		want = maxMissingOperations
+		truncated = true
+	}
+
+	view, err := ce.branchView(ctx, branch, doc.FilePath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Keep a ring of the latest operations for the document
+	ring := make([]*operations.Operation, want)
+	var seen uint64
+	err = ce.store.ForEachOperationMatching(ctx, storage.OperationFilter{DocumentID: doc.FilePath}, func(op *operations.Operation) error {
+		if view.holds(op) {
+			ring[seen%want] = op
+			seen++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if seen < want {
+		return ring[:seen], true, nil
+	}
+	start := seen % want
+	return append(ring[start:], ring[:start]...), truncated, nil
+}
@@ -0,0 +1,127 @@
+package collaboration
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	DefaultWebSocketBufferSize = 1024
+	// DefaultMaxMessageSize leaves room for an operation carrying the API's
+	// largest content, JSON encoded
+	DefaultMaxMessageSize    = 4 << 20
+	DefaultSendBufferSize    = 256
+	DefaultHeartbeatInterval = 30 * time.Second
+	DefaultSlowClientTimeout = 10 * time.Second
+	// Sync state from DefaultCompressionThreshold bytes is gzipped, and sent
+	// in pieces of DefaultSyncChunkSize, for clients that accept it
+	DefaultCompressionThreshold = 16 << 10
+	DefaultSyncChunkSize        = 1 << 20
+)
+
+// WebSocketConfig sets how clients connect over WebSocket
+type WebSocketConfig struct {
+	// AllowedOrigins are the browser origins clients may connect from, such
+	// as https://app.example.com, or "*" for any. Empty allows localhost only.
+	// Clients that send no Origin, which is every client but a browser, can
+	// always connect.
+	AllowedOrigins []string
+	// Compression negotiates per-message deflate with clients that support it
+	Compression bool
+	// CompressionThreshold is the size in bytes from which the state a sync
+	// answers with is gzipped, for clients that accept it. Zero never does.
+	CompressionThreshold int
+	// SyncChunkSize is the most bytes of sync state sent in one message to
+	// clients that accept chunked syncs. Zero never splits a sync.
+	SyncChunkSize   int
+	ReadBufferSize  int
+	WriteBufferSize int
+	// MaxMessageSize is the largest message read from a client, in bytes. A
+	// client sending a larger one is disconnected.
+	MaxMessageSize int64
+	// SendBufferSize is how many messages may wait for a client to read them
+	SendBufferSize int
+	// HeartbeatInterval is how often clients are pinged. One that sends
+	// nothing, pongs included, for two intervals is disconnected.
+	HeartbeatInterval time.Duration
+	// SlowClientTimeout is how long a client's send buffer may stay full
+	// before it is evicted. Zero never evicts.
+	SlowClientTimeout time.Duration
+	// ResumeTTL is how long the session of a client that drops is kept for
+	// it to resume, with what it's sent meanwhile up to a send buffer's
+	// worth. Zero never keeps one.
+	ResumeTTL time.Duration
+}
+
+func DefaultWebSocketConfig() WebSocketConfig {
+	return WebSocketConfig{
+		Compression:          true,
+		CompressionThreshold: DefaultCompressionThreshold,
+		SyncChunkSize:        DefaultSyncChunkSize,
+		ReadBufferSize:       DefaultWebSocketBufferSize,
+		WriteBufferSize:      DefaultWebSocketBufferSize,
+		MaxMessageSize:       DefaultMaxMessageSize,
+		SendBufferSize:       DefaultSendBufferSize,
+		HeartbeatInterval:    DefaultHeartbeatInterval,
+		SlowClientTimeout:    DefaultSlowClientTimeout,
+		ResumeTTL:            DefaultResumeTTL,
+	}
+}
+
+// SetWebSocketConfig applies config to connections made from now on
+func (ce *CollaborationEngine) SetWebSocketConfig(config WebSocketConfig) {
+	ce.mutex.Lock()
+	defer ce.mutex.Unlock()
+	ce.webSocket = config
+}
+
+func (ce *CollaborationEngine) webSocketConfig() WebSocketConfig {
+	ce.mutex.RLock()
+	defer ce.mutex.RUnlock()
+	return ce.webSocket
+}
+
+func (c WebSocketConfig) upgrader() *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:    c.ReadBufferSize,
+		WriteBufferSize:   c.WriteBufferSize,
+		EnableCompression: c.Compression,
+		CheckOrigin:       c.allowsOrigin,
+	}
+}
+
+func (c WebSocketConfig) allowsOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if len(c.AllowedOrigins) == 0 {
+		return isLocalOrigin(origin)
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalOrigin reports whether origin is a page served from this machine
+func isLocalOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
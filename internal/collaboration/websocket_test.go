@@ -0,0 +1,115 @@
+package collaboration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+func TestWebSocketConfig_AllowsOrigin(t *testing.T) {
+	local := DefaultWebSocketConfig()
+	configured := DefaultWebSocketConfig()
+	configured.AllowedOrigins = []string{"https://app.example.com"}
+	wildcard := DefaultWebSocketConfig()
+	wildcard.AllowedOrigins = []string{"*"}
+
+	tests := []struct {
+		name    string
+		config  WebSocketConfig
+		origin  string
+		allowed bool
+	}{
+		{"no origin", configured, "", true},
+		{"localhost", local, "http://localhost:3000", true},
+		{"loopback", local, "http://127.0.0.1:8080", true},
+		{"localhost prefix", local, "http://localhost.evil.com", false},
+		{"remote by default", local, "https://app.example.com", false},
+		{"configured", configured, "https://APP.example.com", true},
+		{"configured excludes localhost", configured, "http://localhost:3000", false},
+		{"other scheme", configured, "http://app.example.com", false},
+		{"any", wildcard, "https://anywhere.example.org", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := tt.config.allowsOrigin(r); got != tt.allowed {
+				t.Errorf("Expected allowed=%v for %q, got %v", tt.allowed, tt.origin, got)
+			}
+		})
+	}
+}
+
+func TestEncodeSync(t *testing.T) {
+	answer := &SyncPayload{DocumentID: "main.go", CurrentState: positioning.NewDocument("main.go")}
+	for i := 0; i < 50; i++ {
+		answer.Operations = append(answer.Operations, &operations.Operation{
+			ID:      operations.OperationID(fmt.Sprintf("op-%d", i)),
+			Type:    operations.OpInsert,
+			Content: strings.Repeat("retry with backoff ", 20),
+		})
+	}
+	config := WebSocketConfig{CompressionThreshold: 1024, SyncChunkSize: 256}
+
+	tests := []struct {
+		name     string
+		request  SyncPayload
+		encoding string
+		chunked  bool
+	}{
+		{"plain", SyncPayload{}, "", false},
+		{"compressed", SyncPayload{AcceptEncoding: SyncEncodingGzip}, SyncEncodingGzip, false},
+		{"chunked", SyncPayload{Chunked: true}, SyncEncodingJSON, true},
+		{"both", SyncPayload{AcceptEncoding: SyncEncodingGzip, Chunked: true}, SyncEncodingGzip, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payloads, err := encodeSync(answer, tt.request, config)
+			if err != nil {
+				t.Fatalf("Failed to encode sync: %v", err)
+			}
+			if tt.chunked != (len(payloads) > 1) {
+				t.Fatalf("Expected chunked=%v, got %d messages", tt.chunked, len(payloads))
+			}
+
+			// Joined over the wire, as a client would
+			var joined SyncPayload
+			for i, payload := range payloads {
+				data, err := json.Marshal(payload)
+				if err != nil {
+					t.Fatalf("Failed to marshal payload: %v", err)
+				}
+				var piece SyncPayload
+				if err := json.Unmarshal(data, &piece); err != nil {
+					t.Fatalf("Failed to unmarshal payload: %v", err)
+				}
+				if piece.Encoding != tt.encoding {
+					t.Errorf("Expected encoding %q, got %q", tt.encoding, piece.Encoding)
+				}
+				if tt.chunked && (piece.Chunk == nil || piece.Chunk.Index != i || piece.Chunk.More != (i < len(payloads)-1)) {
+					t.Errorf("Expected chunk %d of %d, got %+v", i, len(payloads), piece.Chunk)
+				}
+				if i == 0 {
+					joined = piece
+				} else {
+					joined.Data = append(joined.Data, piece.Data...)
+				}
+			}
+			if err := joined.Decode(); err != nil {
+				t.Fatalf("Failed to decode sync: %v", err)
+			}
+			if len(joined.Operations) != len(answer.Operations) || joined.Operations[49].Content != answer.Operations[49].Content ||
+				joined.CurrentState == nil || joined.CurrentState.FilePath != "main.go" {
+				t.Errorf("Expected the answer back, got %d operations and %+v", len(joined.Operations), joined.CurrentState)
+			}
+		})
+	}
+}
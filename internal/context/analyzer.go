@@ -17,6 +17,9 @@ type ContextAnalyzer struct {
 	documents           map[string]*positioning.Document
 	addressResolver     *addressing.AddressResolver
 	conversationManager *ConversationManager
+	thresholds          PatternThresholds
+	intents             *intentClassifier
+	intentMutex         sync.RWMutex
 	mutex               sync.RWMutex
 }
 
@@ -107,6 +110,8 @@ func NewContextAnalyzer(
 		documents:           make(map[string]*positioning.Document),
 		addressResolver:     addressResolver,
 		conversationManager: conversationManager,
+		thresholds:          DefaultPatternThresholds(),
+		intents:             newIntentClassifier(DefaultIntentTaxonomy()),
 	}
 }
 
@@ -191,18 +196,14 @@ func (ca *ContextAnalyzer) GetAuthorActivity(authorID operations.AuthorID, since
 	ca.mutex.RLock()
 	defer ca.mutex.RUnlock()
 
-	ops, err := ca.operationDAG.GetOperationsByAuthor(authorID)
-	if err != nil {
-		return nil, err
-	}
-
-	// Filter by time period
+	// Filter by author and time period in one pass over the DAG
 	var filteredOps []*operations.Operation
-	for _, op := range ops {
-		if op.Timestamp.After(since) {
+	ca.operationDAG.ForEachOperation(func(op *operations.Operation) bool {
+		if op.Author == authorID && op.Timestamp.After(since) {
 			filteredOps = append(filteredOps, op)
 		}
-	}
+		return true
+	})
 
 	if len(filteredOps) == 0 {
 		return &AuthorActivity{
@@ -224,7 +225,7 @@ func (ca *ContextAnalyzer) GetAuthorActivity(authorID operations.AuthorID, since
 	summary := ca.buildActivitySummary(filteredOps)
 
 	// Detect patterns
-	patterns := ca.detectActivityPatterns(filteredOps)
+	patterns := ca.detectActivityPatterns(filteredOps, ca.thresholds)
 
 	return &AuthorActivity{
 		AuthorID:   authorID,
@@ -275,10 +276,11 @@ func (ca *ContextAnalyzer) getConsequences(opID operations.OperationID) []*opera
 
 	// This is an expensive operation - in production we'd want to index this
 	for _, doc := range ca.documents {
-		for _, construct := range doc.Constructs {
+		constructs := doc.Snapshot().Constructs
+		for _, construct := range constructs {
 			if construct.CreatedBy == opID || construct.ModifiedBy == opID {
 				// Find operations that modified this construct after the given operation
-				for _, otherConstruct := range doc.Constructs {
+				for _, otherConstruct := range constructs {
 					if otherConstruct.Position.Compare(construct.Position) == 0 &&
 						otherConstruct.ModifiedBy != opID &&
 						otherConstruct.ModifiedBy != "" {
@@ -295,58 +297,10 @@ func (ca *ContextAnalyzer) getConsequences(opID operations.OperationID) []*opera
 	return consequences
 }
 
+// getRelatedDiscussions returns the threads anchored to the operation's code,
+// referencing it, or linked to it through its metadata
 func (ca *ContextAnalyzer) getRelatedDiscussions(op *operations.Operation) []*ConversationThread {
-	// Find conversations that reference this operation's position or content
-	var discussions []*ConversationThread
-
-	// Create a simple address to search for related conversations
-	// In a real implementation, we'd have proper address resolution
-	addr := addressing.StableAddress{
-		Scheme:      "contextdb",
-		Repository:  addressing.RepositoryID("local"),
-		OperationID: op.ID,
-		PositionRange: addressing.PositionRange{
-			Start: op.Position,
-			End:   op.Position,
-		},
-	}
-
-	// Search for conversations anchored at this position
-	if threads, err := ca.conversationManager.GetConversationsByAddress(addr); err == nil {
-		discussions = append(discussions, threads...)
-	}
-
-	// Also search for conversations that mention the operation content or ID
-	if op.Content != "" && len(op.Content) > 3 {
-		if threads, err := ca.conversationManager.SearchConversations(op.Content); err == nil {
-			discussions = append(discussions, threads...)
-		}
-	}
-
-	// Search by operation ID (shortened)
-	opIDShort := string(op.ID)[:min(len(string(op.ID)), 8)]
-	if threads, err := ca.conversationManager.SearchConversations(opIDShort); err == nil {
-		discussions = append(discussions, threads...)
-	}
-
-	// Remove duplicates
-	seen := make(map[string]bool)
-	var unique []*ConversationThread
-	for _, thread := range discussions {
-		if !seen[string(thread.ID)] {
-			seen[string(thread.ID)] = true
-			unique = append(unique, thread)
-		}
-	}
-
-	return unique
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
+	return ca.conversationManager.GetConversationsByOperation(op.ID)
 }
 
 func (ca *ContextAnalyzer) buildCodeContext(op *operations.Operation) *CodeContext {
@@ -407,22 +361,11 @@ func (ca *ContextAnalyzer) generateOperationSummary(op *operations.Operation, in
 }
 
 func (ca *ContextAnalyzer) extractKeywords(content string) []string {
-	// Simple keyword extraction
-	words := strings.Fields(strings.ToLower(content))
+	// Words that count toward a category of the taxonomy indicate intent
+	classifier := ca.classifier()
 	var keywords []string
-
-	// Common programming keywords that indicate intent
-	intentKeywords := map[string]bool{
-		"fix": true, "bug": true, "error": true, "issue": true,
-		"add": true, "new": true, "feature": true, "implement": true,
-		"refactor": true, "clean": true, "optimize": true, "improve": true,
-		"test": true, "spec": true, "unit": true, "integration": true,
-		"doc": true, "comment": true, "readme": true, "documentation": true,
-		"todo": true, "fixme": true, "hack": true, "temporary": true,
-	}
-
-	for _, word := range words {
-		if intentKeywords[word] {
+	for _, word := range strings.Fields(strings.ToLower(content)) {
+		if _, ok := classifier.words[word]; ok {
 			keywords = append(keywords, word)
 		}
 	}
@@ -443,18 +386,10 @@ func (ca *ContextAnalyzer) classifyIntent(evidence []string, keywords []string)
 	}
 
 	// Score based on keywords
+	classifier := ca.classifier()
 	for _, keyword := range keywords {
-		switch keyword {
-		case "fix", "bug", "error", "issue":
-			intentScores["bugfix"] += 0.5
-		case "add", "new", "feature", "implement":
-			intentScores["feature"] += 0.5
-		case "refactor", "clean", "optimize", "improve":
-			intentScores["refactor"] += 0.5
-		case "test", "spec", "unit", "integration":
-			intentScores["test"] += 0.5
-		case "doc", "comment", "readme", "documentation":
-			intentScores["documentation"] += 0.5
+		if category, ok := classifier.words[keyword]; ok {
+			intentScores[string(category)] += 0.5
 		}
 	}
 
@@ -480,101 +415,7 @@ func (ca *ContextAnalyzer) classifyIntent(evidence []string, keywords []string)
 }
 
 func (ca *ContextAnalyzer) categorizeIntent(intent string) IntentCategory {
-	switch intent {
-	case "feature", "add", "new", "implement":
-		return IntentFeature
-	case "bugfix", "fix", "bug", "error":
-		return IntentBugfix
-	case "refactor", "clean", "optimize", "improve":
-		return IntentRefactor
-	case "test", "spec", "unit", "integration":
-		return IntentTest
-	case "doc", "documentation", "comment":
-		return IntentDoc
-	case "cleanup":
-		return IntentCleanup
-	default:
-		return IntentUnknown
-	}
-}
-
-func (ca *ContextAnalyzer) buildActivitySummary(ops []*operations.Operation) ActivitySummary {
-	summary := ActivitySummary{
-		TotalOperations:   len(ops),
-		OperationTypes:    make(map[string]int),
-		IntentTypes:       make(map[IntentCategory]int),
-		DocumentsModified: []string{},
-	}
-
-	documents := make(map[string]bool)
-
-	for _, op := range ops {
-		summary.OperationTypes[string(op.Type)]++
-
-		if docID, exists := op.Metadata.Context["document_id"]; exists {
-			documents[docID] = true
-		}
-
-		// Analyze intent
-		intent := ca.analyzeOperationIntent(op)
-		summary.IntentTypes[intent.Category]++
-
-		// Count lines (simplified)
-		if op.Type == operations.OpInsert {
-			summary.LinesAdded += strings.Count(op.Content, "\n") + 1
-		} else if op.Type == operations.OpDelete {
-			summary.LinesDeleted += op.Length
-		}
-	}
-
-	// Convert document set to slice
-	for doc := range documents {
-		summary.DocumentsModified = append(summary.DocumentsModified, doc)
-	}
-
-	return summary
-}
-
-func (ca *ContextAnalyzer) detectActivityPatterns(ops []*operations.Operation) []ActivityPattern {
-	var patterns []ActivityPattern
-
-	if len(ops) < 2 {
-		return patterns
-	}
-
-	// Detect bursty pattern (many operations in short time)
-	timeSpan := ops[len(ops)-1].Timestamp.Sub(ops[0].Timestamp)
-	avgRate := float64(len(ops)) / timeSpan.Hours()
-
-	if avgRate > 5.0 { // More than 5 ops per hour
-		patterns = append(patterns, ActivityPattern{
-			Type:        PatternBursty,
-			Description: "High frequency of operations in short time period",
-			Frequency:   avgRate,
-			Confidence:  0.8,
-		})
-	}
-
-	// Detect refactoring pattern
-	refactorCount := 0
-	for _, op := range ops {
-		intent := ca.analyzeOperationIntent(op)
-		if intent.Category == IntentRefactor {
-			refactorCount++
-		}
-	}
-
-	refactorRatio := float64(refactorCount) / float64(len(ops))
-	if refactorRatio > 0.3 {
-		patterns = append(patterns, ActivityPattern{
-			Type:        PatternRefactoring,
-			Description: "High proportion of refactoring operations",
-			Frequency:   refactorRatio,
-			Confidence:  0.7,
-		})
-	}
-
-	return patterns
+	return ca.classifier().taxonomy.Category(intent)
 }
 
 func removeDuplicates(slice []string) []string {
@@ -0,0 +1,181 @@
+package context
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// Operations by one author in one document join the same change set while
+// each follows the last within DefaultChangeSetGap and lands within
+// DefaultChangeSetDistance positions of the ones already in it.
+const (
+	DefaultChangeSetGap      = 5 * time.Minute
+	DefaultChangeSetDistance = 20
+)
+
+type ChangeSetID string
+
+// changeSetPrefix starts every change set ID, which is otherwise the ID of
+// its first operation. Later operations only ever extend a change set, so its
+// ID doesn't change as it grows. Change sets deliberately don't use ids.New:
+// they are clustered again from the operation log on every read, and deriving
+// the ID from the first operation gives the same change set the same ID each
+// time.
+const changeSetPrefix = "cs_"
+
+// FirstOperation is the operation a change set starts with, false if id isn't a change set ID
+func (id ChangeSetID) FirstOperation() (operations.OperationID, bool) {
+	opID, found := strings.CutPrefix(string(id), changeSetPrefix)
+	return operations.OperationID(opID), found && opID != ""
+}
+
+// ChangeSet is a run of edits one author made to one place in a document,
+// clustered from their operations so people can read them as one change
+type ChangeSet struct {
+	ID             ChangeSetID                      `json:"id"`
+	Author         operations.AuthorID              `json:"author"`
+	DocumentID     string                           `json:"document_id"`
+	Operations     []operations.OperationID         `json:"operations"`
+	OperationTypes map[operations.OperationType]int `json:"operation_types"`
+	Start          time.Time                        `json:"start"`
+	End            time.Time                        `json:"end"`
+	LinesAdded     int                              `json:"lines_added"`
+	LinesDeleted   int                              `json:"lines_deleted"`
+	Intent         *IntentAnalysis                  `json:"intent"`
+	// ReviewStatus sums up the open reviews of the change set, empty when
+	// there are none
+	ReviewStatus ReviewStatus `json:"review_status,omitempty"`
+}
+
+type ChangeSetOptions struct {
+	// Gap is the longest pause between operations of one change set
+	Gap time.Duration
+	// Distance is how many positions away from a change set an operation can
+	// land and still join it
+	Distance int
+}
+
+func DefaultChangeSetOptions() ChangeSetOptions {
+	return ChangeSetOptions{Gap: DefaultChangeSetGap, Distance: DefaultChangeSetDistance}
+}
+
+// PositionIndex orders the positions of a document, nil when the document
+// isn't known. Operations whose position isn't in the index, such as
+// deletions, are taken to be adjacent to any change set in their document.
+type PositionIndex func(documentID string) map[operations.PositionKey]int
+
+// ChangeSetClusterer groups operations fed to it in timestamp order into change sets
+type ChangeSetClusterer struct {
+	analyzer  *ContextAnalyzer
+	options   ChangeSetOptions
+	positions PositionIndex
+	open      map[changeSetKey]*pendingChangeSet
+	closed    []*ChangeSet
+}
+
+type changeSetKey struct {
+	author   operations.AuthorID
+	document string
+}
+
+type pendingChangeSet struct {
+	changeSet *ChangeSet
+	ops       []*operations.Operation
+	// first and last bound the positions the change set has touched, when any are known
+	first, last int
+	spanned     bool
+}
+
+func (ca *ContextAnalyzer) NewChangeSetClusterer(options ChangeSetOptions, positions PositionIndex) *ChangeSetClusterer {
+	if options.Gap <= 0 {
+		options.Gap = DefaultChangeSetGap
+	}
+	if options.Distance <= 0 {
+		options.Distance = DefaultChangeSetDistance
+	}
+	if positions == nil {
+		positions = func(string) map[operations.PositionKey]int { return nil }
+	}
+	return &ChangeSetClusterer{
+		analyzer:  ca,
+		options:   options,
+		positions: positions,
+		open:      make(map[changeSetKey]*pendingChangeSet),
+	}
+}
+
+// Add puts op into the change set it continues, or starts a new one
+func (c *ChangeSetClusterer) Add(op *operations.Operation) {
+	key := changeSetKey{author: op.Author, document: op.Metadata.DocumentID}
+	index, known := c.positions(key.document)[op.Position.Key()]
+
+	pending := c.open[key]
+	if pending != nil && !c.continues(pending, op, index, known) {
+		c.close(key)
+		pending = nil
+	}
+	if pending == nil {
+		pending = &pendingChangeSet{changeSet: &ChangeSet{
+			ID:             ChangeSetID(changeSetPrefix + string(op.ID)),
+			Author:         op.Author,
+			DocumentID:     key.document,
+			Operations:     []operations.OperationID{},
+			OperationTypes: make(map[operations.OperationType]int),
+			Start:          op.Timestamp,
+		}}
+		c.open[key] = pending
+	}
+
+	cs := pending.changeSet
+	cs.Operations = append(cs.Operations, op.ID)
+	cs.OperationTypes[op.Type]++
+	cs.End = op.Timestamp
+	added, deleted := lineChanges(op)
+	cs.LinesAdded += added
+	cs.LinesDeleted += deleted
+	pending.ops = append(pending.ops, op)
+
+	if known {
+		if !pending.spanned {
+			pending.first, pending.last, pending.spanned = index, index, true
+		}
+		pending.first = min(pending.first, index)
+		pending.last = max(pending.last, index)
+	}
+}
+
+func (c *ChangeSetClusterer) continues(pending *pendingChangeSet, op *operations.Operation, index int, known bool) bool {
+	if op.Timestamp.Sub(pending.changeSet.End) > c.options.Gap {
+		return false
+	}
+	if !known || !pending.spanned {
+		return true
+	}
+	return index >= pending.first-c.options.Distance && index <= pending.last+c.options.Distance
+}
+
+func (c *ChangeSetClusterer) close(key changeSetKey) {
+	pending := c.open[key]
+	delete(c.open, key)
+
+	pending.changeSet.Intent, _ = c.analyzer.AnalyzeChangeIntent(pending.ops)
+	c.closed = append(c.closed, pending.changeSet)
+}
+
+// ChangeSets ends every change set still open and returns them all, oldest first
+func (c *ChangeSetClusterer) ChangeSets() []*ChangeSet {
+	for key := range c.open {
+		c.close(key)
+	}
+
+	sort.Slice(c.closed, func(i, j int) bool {
+		if !c.closed[i].Start.Equal(c.closed[j].Start) {
+			return c.closed[i].Start.Before(c.closed[j].Start)
+		}
+		return c.closed[i].ID < c.closed[j].ID
+	})
+	return c.closed
+}
@@ -0,0 +1,98 @@
+package context
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestChangeSetClusterer(t *testing.T) {
+	analyzer := NewContextAnalyzer(operations.NewOperationDAG(), nil, NewConversationManager())
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var positions []operations.LogootPosition
+	for i := 0; i < 100; i++ {
+		positions = append(positions, operations.NewLogootPosition([]operations.PositionSegment{{Value: big.NewInt(int64(i)), AuthorID: "alice"}}))
+	}
+	index := make(map[operations.PositionKey]int)
+	for i, pos := range positions {
+		index[pos.Key()] = i
+	}
+	clusterer := analyzer.NewChangeSetClusterer(DefaultChangeSetOptions(), func(documentID string) map[operations.PositionKey]int {
+		if documentID == "main.go" {
+			return index
+		}
+		return nil
+	})
+
+	add := func(name string, author operations.AuthorID, document string, at time.Duration, position int) *operations.Operation {
+		op := &operations.Operation{
+			ID:        operations.OperationID(name),
+			Type:      operations.OpInsert,
+			Position:  positions[position],
+			Content:   "fix the bug",
+			Author:    author,
+			Timestamp: start.Add(at),
+			Metadata:  operations.OperationMeta{DocumentID: document},
+		}
+		clusterer.Add(op)
+		return op
+	}
+
+	add("a1", "alice", "main.go", 0, 10)
+	add("b1", "bob", "main.go", time.Second, 11)      // another author
+	add("a2", "alice", "main.go", time.Minute, 15)    // adjacent and soon after
+	add("a3", "alice", "util.go", 2*time.Minute, 90)  // another document
+	add("a4", "alice", "main.go", 3*time.Minute, 80)  // far from the first edits
+	add("a5", "alice", "main.go", 4*time.Minute, 81)  // continues a4
+	add("a6", "alice", "main.go", 20*time.Minute, 82) // after a pause
+
+	changeSets := clusterer.ChangeSets()
+	var ids [][]operations.OperationID
+	for _, cs := range changeSets {
+		ids = append(ids, cs.Operations)
+	}
+	expected := [][]operations.OperationID{{"a1", "a2"}, {"b1"}, {"a3"}, {"a4", "a5"}, {"a6"}}
+	if len(ids) != len(expected) {
+		t.Fatalf("Expected %d change sets, got %v", len(expected), ids)
+	}
+	for i := range expected {
+		if len(ids[i]) != len(expected[i]) || ids[i][0] != expected[i][0] || ids[i][len(ids[i])-1] != expected[i][len(expected[i])-1] {
+			t.Errorf("Expected change set %d to be %v, got %v", i, expected[i], ids[i])
+		}
+	}
+
+	first := changeSets[0]
+	if first.ID != "cs_a1" || first.Author != "alice" || first.DocumentID != "main.go" {
+		t.Errorf("Unexpected change set %+v", first)
+	}
+	if !first.Start.Equal(start) || !first.End.Equal(start.Add(time.Minute)) || first.OperationTypes[operations.OpInsert] != 2 {
+		t.Errorf("Unexpected span or counts %+v", first)
+	}
+	if first.Intent == nil || first.Intent.Category != IntentBugfix {
+		t.Errorf("Expected a bugfix intent, got %+v", first.Intent)
+	}
+	if opID, ok := first.ID.FirstOperation(); !ok || opID != "a1" {
+		t.Errorf("Expected a1 to start %s, got %s", first.ID, opID)
+	}
+}
+
+func TestConversationManager_ChangeSetConversations(t *testing.T) {
+	cm := NewConversationManager()
+	thread, err := cm.CreateChangeSetConversation("cs_a1", addressing.StableAddress{}, "alice", "Retry loop", "Why three attempts?")
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	cm.CreateConversation(addressing.StableAddress{}, "alice", "Elsewhere", "Unrelated")
+
+	threads, err := cm.ListConversations(ConversationFilter{ChangeSet: "cs_a1"})
+	if err != nil {
+		t.Fatalf("Failed to list conversations: %v", err)
+	}
+	if len(threads) != 1 || threads[0].ID != thread.ID || threads[0].ChangeSetID != "cs_a1" {
+		t.Errorf("Expected only the change set's conversation, got %+v", threads)
+	}
+}
@@ -1,10 +1,10 @@
 package context
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/ids"
 	"github.com/jeremytregunna/contextdb/internal/operations"
 )
 
@@ -12,13 +12,25 @@ type ConversationThread struct {
 	ID            ThreadID                 `json:"id"`
 	Title         string                   `json:"title"`
 	AnchorAddress addressing.StableAddress `json:"anchor_address"`
-	Participants  []operations.AuthorID    `json:"participants"`
-	Messages      []Message                `json:"messages"`
-	Status        ThreadStatus             `json:"status"`
-	CreatedAt     time.Time                `json:"created_at"`
-	UpdatedAt     time.Time                `json:"updated_at"`
-	Tags          []string                 `json:"tags,omitempty"`
-	Metadata      ConversationMeta         `json:"metadata"`
+	// ChangeSetID is the change set the thread discusses, if any
+	ChangeSetID ChangeSetID `json:"changeset_id,omitempty"`
+	// Branch is the branch the thread is about, empty for main
+	Branch string `json:"branch,omitempty"`
+	// Review makes the thread a review, asking its reviewers for a verdict
+	Review       *ReviewRequest        `json:"review,omitempty"`
+	Participants []operations.AuthorID `json:"participants"`
+	Messages     []Message             `json:"messages"`
+	Status       ThreadStatus          `json:"status"`
+	Visibility   Visibility            `json:"visibility,omitempty"`
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
+	Tags         []string              `json:"tags,omitempty"`
+	Metadata     ConversationMeta      `json:"metadata"`
+	// LinkedOperations are the operations whose metadata names the thread or
+	// its linked issue, in the order they were linked
+	LinkedOperations []operations.OperationID `json:"linked_operations,omitempty"`
+	// Summary is the latest summary of the thread, if one has been made
+	Summary *ThreadSummary `json:"summary,omitempty"`
 }
 
 type ThreadID string
@@ -32,6 +44,8 @@ type Message struct {
 	Reactions   []Reaction                 `json:"reactions,omitempty"`
 	Timestamp   time.Time                  `json:"timestamp"`
 	EditHistory []EditRecord               `json:"edit_history,omitempty"`
+	// SupersededBy is the decision that replaced this one, for decision messages
+	SupersededBy MessageID `json:"superseded_by,omitempty"`
 }
 
 type MessageID string
@@ -45,8 +59,19 @@ const (
 	MsgDecision   MessageType = "decision"
 	MsgSuggestion MessageType = "suggestion"
 	MsgReview     MessageType = "review"
+	// Reviewers give their verdict on a review with these
+	MsgApprove        MessageType = "approve"
+	MsgRequestChanges MessageType = "request_changes"
 )
 
+func (t MessageType) IsValid() bool {
+	switch t {
+	case MsgComment, MsgQuestion, MsgAnswer, MsgDecision, MsgSuggestion, MsgReview, MsgApprove, MsgRequestChanges:
+		return true
+	}
+	return false
+}
+
 type Reaction struct {
 	AuthorID  operations.AuthorID `json:"author_id"`
 	Emoji     string              `json:"emoji"`
@@ -68,12 +93,21 @@ const (
 	StatusPinned   ThreadStatus = "pinned"
 )
 
+func (s ThreadStatus) IsValid() bool {
+	switch s {
+	case StatusOpen, StatusResolved, StatusArchived, StatusPinned:
+		return true
+	}
+	return false
+}
+
 type ConversationMeta struct {
 	Priority    Priority            `json:"priority,omitempty"`
 	Labels      []string            `json:"labels,omitempty"`
 	Assignee    operations.AuthorID `json:"assignee,omitempty"`
 	DueDate     *time.Time          `json:"due_date,omitempty"`
 	LinkedIssue string              `json:"linked_issue,omitempty"`
+	IssueURL    string              `json:"issue_url,omitempty"`
 }
 
 type Priority string
@@ -105,13 +139,15 @@ func NewConversationThread(anchorAddr addressing.StableAddress, authorID operati
 		Participants:  []operations.AuthorID{authorID},
 		Messages:      []Message{message},
 		Status:        StatusOpen,
+		Visibility:    VisibilityPublic,
 		CreatedAt:     now,
 		UpdatedAt:     now,
 		Metadata:      ConversationMeta{},
 	}
 }
 
-func (ct *ConversationThread) AddMessage(authorID operations.AuthorID, content string, msgType MessageType) *Message {
+// AddMessage appends a message, optionally referencing other code by address
+func (ct *ConversationThread) AddMessage(authorID operations.AuthorID, content string, msgType MessageType, references ...addressing.StableAddress) *Message {
 	messageID := MessageID(generateMessageID())
 	message := Message{
 		ID:          messageID,
@@ -120,6 +156,9 @@ func (ct *ConversationThread) AddMessage(authorID operations.AuthorID, content s
 		MessageType: msgType,
 		Timestamp:   time.Now(),
 	}
+	if len(references) > 0 {
+		message.References = append([]addressing.StableAddress(nil), references...)
+	}
 
 	ct.Messages = append(ct.Messages, message)
 	ct.UpdatedAt = time.Now()
@@ -144,8 +183,9 @@ func (ct *ConversationThread) EditMessage(messageID MessageID, authorID operatio
 				Reason:      reason,
 			}
 
-			ct.Messages[i].EditHistory = append(ct.Messages[i].EditHistory, editRecord)
-			ct.Messages[i].Content = newContent
+			msg.EditHistory = append(msg.EditHistory[:len(msg.EditHistory):len(msg.EditHistory)], editRecord)
+			msg.Content = newContent
+			ct.replaceMessage(i, msg)
 			ct.UpdatedAt = time.Now()
 			return nil
 		}
@@ -158,7 +198,7 @@ func (ct *ConversationThread) AddReaction(messageID MessageID, authorID operatio
 	for i, msg := range ct.Messages {
 		if msg.ID == messageID {
 			// Remove existing reaction from this author if any
-			ct.Messages[i].Reactions = removeReactionByAuthor(msg.Reactions, authorID)
+			msg.Reactions = removeReactionByAuthor(msg.Reactions, authorID)
 
 			// Add new reaction
 			reaction := Reaction{
@@ -166,7 +206,8 @@ func (ct *ConversationThread) AddReaction(messageID MessageID, authorID operatio
 				Emoji:     emoji,
 				Timestamp: time.Now(),
 			}
-			ct.Messages[i].Reactions = append(ct.Messages[i].Reactions, reaction)
+			msg.Reactions = append(msg.Reactions, reaction)
+			ct.replaceMessage(i, msg)
 			ct.UpdatedAt = time.Now()
 			return nil
 		}
@@ -183,7 +224,8 @@ func (ct *ConversationThread) SetStatus(status ThreadStatus) {
 func (ct *ConversationThread) AddReference(messageID MessageID, address addressing.StableAddress) error {
 	for i, msg := range ct.Messages {
 		if msg.ID == messageID {
-			ct.Messages[i].References = append(ct.Messages[i].References, address)
+			msg.References = append(msg.References[:len(msg.References):len(msg.References)], address)
+			ct.replaceMessage(i, msg)
 			ct.UpdatedAt = time.Now()
 			return nil
 		}
@@ -192,6 +234,16 @@ func (ct *ConversationThread) AddReference(messageID MessageID, address addressi
 	return ErrMessageNotFound
 }
 
+// replaceMessage swaps in a changed message. Copies of the thread share its
+// messages rather than copying them, so a message is never changed in place:
+// the slice is copied and the copy replaces it.
+func (ct *ConversationThread) replaceMessage(i int, message Message) {
+	messages := make([]Message, len(ct.Messages), cap(ct.Messages))
+	copy(messages, ct.Messages)
+	messages[i] = message
+	ct.Messages = messages
+}
+
 func (ct *ConversationThread) GetMessage(messageID MessageID) (*Message, error) {
 	for _, msg := range ct.Messages {
 		if msg.ID == messageID {
@@ -241,13 +293,9 @@ func removeReactionByAuthor(reactions []Reaction, authorID operations.AuthorID)
 }
 
 func generateThreadID() string {
-	return "thread_" + generateID()
+	return ids.NewWithPrefix("thread")
 }
 
 func generateMessageID() string {
-	return "msg_" + generateID()
-}
-
-func generateID() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+	return ids.NewWithPrefix("msg")
 }
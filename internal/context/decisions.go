@@ -0,0 +1,177 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/events"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// Decision is a decision message lifted out of its thread, so why code is the
+// way it is can be audited without reading every conversation. A decision is
+// identified by its message's ID.
+type Decision struct {
+	ID           MessageID                `json:"id"`
+	ThreadID     ThreadID                 `json:"thread_id"`
+	Title        string                   `json:"title"`
+	Content      string                   `json:"content"`
+	AuthorID     operations.AuthorID      `json:"author_id"`
+	Address      addressing.StableAddress `json:"address"`
+	SupersededBy MessageID                `json:"superseded_by,omitempty"`
+	Supersedes   []MessageID              `json:"supersedes,omitempty"`
+	CreatedAt    time.Time                `json:"created_at"`
+}
+
+// DecisionFilter selects decisions. Empty fields match every decision.
+type DecisionFilter struct {
+	ThreadID ThreadID
+	AuthorID operations.AuthorID
+	// Current leaves out decisions that have been superseded
+	Current bool
+	// Viewer leaves out decisions in threads it may not read
+	Viewer Viewer
+}
+
+// CreateDecision records a decision outside of any discussion. It starts a
+// resolved thread whose only message is the decision.
+func (cm *ConversationManager) CreateDecision(anchorAddr addressing.StableAddress, authorID operations.AuthorID, title, content string) (*Decision, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	thread := NewConversationThread(anchorAddr, authorID, title, content)
+	thread.Messages[0].MessageType = MsgDecision
+	thread.Status = StatusResolved
+
+	cm.conversations[thread.ID] = thread
+	cm.indexConversation(thread)
+	cm.events.Publish(events.ConversationCreated, cm.copyThread(thread).Redacted())
+
+	return cm.decision(thread.Messages[0].ID, nil)
+}
+
+func (cm *ConversationManager) GetDecision(id MessageID) (*Decision, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	return cm.decision(id, cm.supersessions())
+}
+
+// SupersedeDecision marks decision id as replaced by decision by and returns
+// the superseded decision. A decision can't supersede itself or any decision
+// that already supersedes it.
+func (cm *ConversationManager) SupersedeDecision(id, by MessageID) (*Decision, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	message := cm.decisionMessage(id)
+	if message == nil || cm.decisionMessage(by) == nil {
+		return nil, ErrDecisionNotFound
+	}
+
+	for next := cm.decisionMessage(by); next != nil; next = cm.decisionMessage(next.SupersededBy) {
+		if next.ID == id {
+			return nil, fmt.Errorf("%w: %s already supersedes %s", ErrInvalidSupersession, id, by)
+		}
+	}
+
+	thread := cm.conversations[cm.decisionIndex[id]]
+	for i := range thread.Messages {
+		if thread.Messages[i].ID == id {
+			superseded := thread.Messages[i]
+			superseded.SupersededBy = by
+			thread.replaceMessage(i, superseded)
+		}
+	}
+	thread.UpdatedAt = time.Now()
+	return cm.decision(id, cm.supersessions())
+}
+
+// Decisions returns the decisions matching filter, newest first
+func (cm *ConversationManager) Decisions(filter DecisionFilter) []*Decision {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	supersessions := cm.supersessions()
+	decisions := []*Decision{}
+	for id, threadID := range cm.decisionIndex {
+		if filter.ThreadID != "" && threadID != filter.ThreadID {
+			continue
+		}
+		if thread := cm.conversations[threadID]; thread == nil || !filter.Viewer.CanView(thread) {
+			continue
+		}
+
+		decision, err := cm.decision(id, supersessions)
+		if err != nil {
+			continue
+		}
+		if filter.AuthorID != "" && !cm.aliases.Same(decision.AuthorID, filter.AuthorID) {
+			continue
+		}
+		if filter.Current && decision.SupersededBy != "" {
+			continue
+		}
+		decisions = append(decisions, decision)
+	}
+
+	sort.Slice(decisions, func(i, j int) bool {
+		if !decisions[i].CreatedAt.Equal(decisions[j].CreatedAt) {
+			return decisions[i].CreatedAt.After(decisions[j].CreatedAt)
+		}
+		return decisions[i].ID < decisions[j].ID
+	})
+	return decisions
+}
+
+// decision builds the record for decision message id. Callers hold the mutex.
+func (cm *ConversationManager) decision(id MessageID, supersessions map[MessageID][]MessageID) (*Decision, error) {
+	message := cm.decisionMessage(id)
+	if message == nil {
+		return nil, ErrDecisionNotFound
+	}
+	thread := cm.conversations[cm.decisionIndex[id]]
+
+	decision := &Decision{
+		ID:           message.ID,
+		ThreadID:     thread.ID,
+		Title:        thread.Title,
+		Content:      message.Content,
+		AuthorID:     message.AuthorID,
+		Address:      thread.AnchorAddress,
+		SupersededBy: message.SupersededBy,
+		Supersedes:   supersessions[id],
+		CreatedAt:    message.Timestamp,
+	}
+	return decision, nil
+}
+
+// supersessions maps each decision to the decisions it superseded
+func (cm *ConversationManager) supersessions() map[MessageID][]MessageID {
+	supersedes := make(map[MessageID][]MessageID)
+	for id := range cm.decisionIndex {
+		if message := cm.decisionMessage(id); message != nil && message.SupersededBy != "" {
+			supersedes[message.SupersededBy] = append(supersedes[message.SupersededBy], id)
+		}
+	}
+	for _, ids := range supersedes {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	return supersedes
+}
+
+// decisionMessage finds the stored decision message id, or nil
+func (cm *ConversationManager) decisionMessage(id MessageID) *Message {
+	thread, exists := cm.conversations[cm.decisionIndex[id]]
+	if !exists {
+		return nil
+	}
+	for i := range thread.Messages {
+		if thread.Messages[i].ID == id && thread.Messages[i].MessageType == MsgDecision {
+			return &thread.Messages[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package context
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestConversationManager_Decisions(t *testing.T) {
+	manager := NewConversationManager()
+
+	opID := operations.NewOperationID([]byte("test-op"))
+	pos := operations.NewLogootPosition([]operations.PositionSegment{
+		{Value: big.NewInt(1), AuthorID: "author1"},
+	})
+	posRange := addressing.PositionRange{Start: pos, End: pos}
+	anchorAddr := addressing.NewStableAddress(addressing.RepositoryID("test-repo"), opID, posRange)
+
+	thread, _ := manager.CreateConversation(anchorAddr, "author1", "Money type", "Floats or cents?")
+	if _, err := manager.AddMessage(thread.ID, "author2", "Use float64", MsgComment); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	first, err := manager.AddMessage(thread.ID, "author2", "Decision: float64", MsgDecision)
+	if err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+
+	decisions := manager.Decisions(DecisionFilter{})
+	if len(decisions) != 1 || decisions[0].ID != first.ID || decisions[0].Title != "Money type" || decisions[0].ThreadID != thread.ID {
+		t.Fatalf("Expected the decision message to be indexed, got %+v", decisions)
+	}
+
+	second, err := manager.CreateDecision(anchorAddr, "author1", "Money type", "Use int64 cents")
+	if err != nil {
+		t.Fatalf("Failed to create decision: %v", err)
+	}
+	created, _ := manager.GetConversation(second.ThreadID)
+	if created.Status != StatusResolved || created.Messages[0].MessageType != MsgDecision {
+		t.Errorf("Expected a resolved thread holding the decision, got %+v", created)
+	}
+
+	superseded, err := manager.SupersedeDecision(first.ID, second.ID)
+	if err != nil {
+		t.Fatalf("Failed to supersede decision: %v", err)
+	}
+	if superseded.SupersededBy != second.ID {
+		t.Errorf("Expected %s to be superseded by %s, got %+v", first.ID, second.ID, superseded)
+	}
+	replacement, _ := manager.GetDecision(second.ID)
+	if len(replacement.Supersedes) != 1 || replacement.Supersedes[0] != first.ID {
+		t.Errorf("Expected %s to supersede %s, got %+v", second.ID, first.ID, replacement.Supersedes)
+	}
+
+	// Supersession can't loop back on itself
+	for _, pair := range [][2]MessageID{{second.ID, first.ID}, {second.ID, second.ID}} {
+		if _, err := manager.SupersedeDecision(pair[0], pair[1]); !errors.Is(err, ErrInvalidSupersession) {
+			t.Errorf("Expected ErrInvalidSupersession for %v, got %v", pair, err)
+		}
+	}
+	if _, err := manager.SupersedeDecision(first.ID, "missing"); !errors.Is(err, ErrDecisionNotFound) {
+		t.Errorf("Expected ErrDecisionNotFound, got %v", err)
+	}
+
+	if current := manager.Decisions(DecisionFilter{Current: true}); len(current) != 1 || current[0].ID != second.ID {
+		t.Errorf("Expected only the current decision, got %+v", current)
+	}
+	if byThread := manager.Decisions(DecisionFilter{ThreadID: thread.ID}); len(byThread) != 1 || byThread[0].ID != first.ID {
+		t.Errorf("Expected only the thread's decision, got %+v", byThread)
+	}
+
+	// Decisions and their links survive a snapshot round trip
+	restored := NewConversationManager()
+	restored.Restore(manager.Snapshot())
+	if decision, err := restored.GetDecision(first.ID); err != nil || decision.SupersededBy != second.ID {
+		t.Errorf("Expected the restored decision to stay superseded, got %+v, %v", decision, err)
+	}
+}
@@ -9,4 +9,14 @@ var (
 	ErrInvalidMessageType   = errors.New("invalid message type")
 	ErrInvalidStatus        = errors.New("invalid thread status")
 	ErrDuplicateReaction    = errors.New("duplicate reaction")
+	ErrInvalidTag           = errors.New("invalid tag")
+	ErrDecisionNotFound     = errors.New("decision not found")
+	ErrInvalidSupersession  = errors.New("invalid supersession")
+	ErrChangeSetNotFound    = errors.New("change set not found")
+	ErrInvalidVisibility    = errors.New("invalid visibility")
+	ErrReviewNotFound       = errors.New("review not found")
+	ErrNotReview            = errors.New("conversation is not a review")
+	ErrInvalidTaxonomy      = errors.New("invalid intent taxonomy")
+	ErrUnknownIntent        = errors.New("unknown intent")
+	ErrInvalidIssue         = errors.New("invalid linked issue")
 )
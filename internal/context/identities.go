@@ -0,0 +1,89 @@
+package context
+
+import (
+	"slices"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// AuthorAliases maps author IDs a person has used, from other machines or
+// under other names, to the one ID they are known by
+type AuthorAliases map[operations.AuthorID]operations.AuthorID
+
+// Identity is the author IDs of one person
+type Identity struct {
+	Author  operations.AuthorID   `json:"author"`
+	Aliases []operations.AuthorID `json:"aliases"`
+}
+
+// Resolve returns the ID author is known by
+func (a AuthorAliases) Resolve(author operations.AuthorID) operations.AuthorID {
+	if canonical, ok := a[author]; ok {
+		return canonical
+	}
+	return author
+}
+
+// Same reports whether two author IDs belong to one identity
+func (a AuthorAliases) Same(x, y operations.AuthorID) bool {
+	return a.Resolve(x) == a.Resolve(y)
+}
+
+// Identity returns the identity author belongs to, which has no aliases
+// when author was never merged with another ID
+func (a AuthorAliases) Identity(author operations.AuthorID) Identity {
+	identity := Identity{Author: a.Resolve(author), Aliases: []operations.AuthorID{}}
+	for alias, canonical := range a {
+		if canonical == identity.Author {
+			identity.Aliases = append(identity.Aliases, alias)
+		}
+	}
+	slices.Sort(identity.Aliases)
+	return identity
+}
+
+// Identities lists every identity with aliases, ordered by author ID
+func (a AuthorAliases) Identities() []Identity {
+	var authors []operations.AuthorID
+	for _, canonical := range a {
+		if !slices.Contains(authors, canonical) {
+			authors = append(authors, canonical)
+		}
+	}
+	slices.Sort(authors)
+
+	identities := make([]Identity, 0, len(authors))
+	for _, author := range authors {
+		identities = append(identities, a.Identity(author))
+	}
+	return identities
+}
+
+// SetAuthorAliases indexes conversations by the identity of their
+// participants, so looking them up by any of its IDs finds the same threads
+func (cm *ConversationManager) SetAuthorAliases(aliases AuthorAliases) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.aliases = make(AuthorAliases, len(aliases))
+	for alias, author := range aliases {
+		cm.aliases[alias] = author
+	}
+
+	cm.authorIndex = make(map[operations.AuthorID][]ThreadID)
+	for _, thread := range cm.conversations {
+		cm.updateAuthorIndex(thread)
+	}
+}
+
+// AuthorAliases returns a copy of the aliases conversations are indexed with
+func (cm *ConversationManager) AuthorAliases() AuthorAliases {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	aliases := make(AuthorAliases, len(cm.aliases))
+	for alias, author := range cm.aliases {
+		aliases[alias] = author
+	}
+	return aliases
+}
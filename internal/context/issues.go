@@ -0,0 +1,74 @@
+package context
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Issue trackers a conversation's linked issue can be in
+const (
+	TrackerJira   = "jira"
+	TrackerLinear = "linear"
+)
+
+// issueKeyPattern matches Jira and Linear issue keys, a project or team key
+// and a number
+var issueKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*-[1-9][0-9]*$`)
+
+// IssueLink is an issue in Jira or Linear, named by its key and, when given
+// as a link, the URL it was given as
+type IssueLink struct {
+	Key     string `json:"key"`
+	Tracker string `json:"tracker,omitempty"`
+	URL     string `json:"url,omitempty"`
+}
+
+// ParseIssueLink reads an issue key like ENG-42, uppercasing it, or a Jira or
+// Linear issue URL, taking the key from its path
+func ParseIssueLink(issue string) (IssueLink, error) {
+	issue = strings.TrimSpace(issue)
+	if issueKeyPattern.MatchString(issue) {
+		return IssueLink{Key: strings.ToUpper(issue)}, nil
+	}
+
+	u, err := url.Parse(issue)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return IssueLink{}, fmt.Errorf("%w: %q is not an issue key or URL", ErrInvalidIssue, issue)
+	}
+	link := IssueLink{Tracker: TrackerJira, URL: u.String()}
+	if host := strings.ToLower(u.Hostname()); host == "linear.app" || strings.HasSuffix(host, ".linear.app") {
+		link.Tracker = TrackerLinear
+	}
+
+	// Jira links to .../browse/ENG-42 or a board with ?selectedIssue=ENG-42,
+	// Linear to /<workspace>/issue/ENG-42/<title>
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if (segments[i] == "browse" || segments[i] == "issue") && issueKeyPattern.MatchString(segments[i+1]) {
+			link.Key = strings.ToUpper(segments[i+1])
+			return link, nil
+		}
+	}
+	if selected := u.Query().Get("selectedIssue"); issueKeyPattern.MatchString(selected) {
+		link.Key = strings.ToUpper(selected)
+		return link, nil
+	}
+	return IssueLink{}, fmt.Errorf("%w: %q doesn't name a Jira or Linear issue", ErrInvalidIssue, issue)
+}
+
+// linkIssue replaces the issue a thread was given with its key, keeping the
+// URL when it was given as a link
+func (thread *ConversationThread) linkIssue() error {
+	if thread.Metadata.LinkedIssue == "" {
+		return nil
+	}
+	link, err := ParseIssueLink(thread.Metadata.LinkedIssue)
+	if err != nil {
+		return err
+	}
+	thread.Metadata.LinkedIssue = link.Key
+	thread.Metadata.IssueURL = link.URL
+	return nil
+}
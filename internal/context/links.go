@@ -0,0 +1,78 @@
+package context
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// WithLinkedIssue records the ticket a new thread tracks, an issue key or a
+// Jira or Linear issue URL, which is kept as IssueURL. Creating the thread
+// fails with ErrInvalidIssue when it is neither. Operations whose ticket is the issue's key are linked
+// to the thread.
+func WithLinkedIssue(issue string) ThreadOption {
+	return func(thread *ConversationThread) {
+		thread.Metadata.LinkedIssue = strings.TrimSpace(issue)
+	}
+}
+
+// LinkOperation links op to the threads its metadata names, by their linked
+// issue or by thread ID, and returns their IDs. Linking the same operation
+// again changes nothing.
+func (cm *ConversationManager) LinkOperation(op *operations.Operation) []ThreadID {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	var linked []ThreadID
+	for _, threadID := range cm.referencedThreads(op.Metadata) {
+		thread := cm.conversations[threadID]
+		if !slices.Contains(thread.LinkedOperations, op.ID) {
+			cm.unindexOperations(thread)
+			thread.LinkedOperations = append(thread.LinkedOperations, op.ID)
+			cm.indexOperations(thread)
+		}
+		linked = append(linked, threadID)
+	}
+	return linked
+}
+
+// referencedThreads finds the threads tracking the metadata's ticket and the
+// thread it names, without repeats
+func (cm *ConversationManager) referencedThreads(meta operations.OperationMeta) []ThreadID {
+	var threadIDs []ThreadID
+	if ticket := strings.TrimSpace(meta.Ticket); ticket != "" {
+		issue := ticket
+		if link, err := ParseIssueLink(ticket); err == nil {
+			issue = link.Key
+		}
+		threadIDs = append(threadIDs, cm.issueIndex[issue]...)
+		if _, exists := cm.conversations[ThreadID(ticket)]; exists {
+			threadIDs = append(threadIDs, ThreadID(ticket))
+		}
+	}
+	if threadID := ThreadID(strings.TrimSpace(meta.Context[operations.MetaThread])); threadID != "" {
+		if _, exists := cm.conversations[threadID]; exists {
+			threadIDs = append(threadIDs, threadID)
+		}
+	}
+	slices.Sort(threadIDs)
+	return slices.Compact(threadIDs)
+}
+
+func (cm *ConversationManager) indexIssue(thread *ConversationThread) {
+	if issue := thread.Metadata.LinkedIssue; issue != "" {
+		cm.issueIndex[issue] = append(cm.issueIndex[issue], thread.ID)
+	}
+}
+
+func (cm *ConversationManager) unindexIssue(thread *ConversationThread) {
+	issue := thread.Metadata.LinkedIssue
+	if issue == "" {
+		return
+	}
+	cm.issueIndex[issue] = removeThreadID(cm.issueIndex[issue], thread.ID)
+	if len(cm.issueIndex[issue]) == 0 {
+		delete(cm.issueIndex, issue)
+	}
+}
@@ -0,0 +1,96 @@
+package context
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestConversationManager_LinkOperation(t *testing.T) {
+	manager := NewConversationManager()
+
+	tracked, err := manager.CreateConversation(addressing.StableAddress{}, "alice", "Retry storm", "Clients retry in lockstep", WithLinkedIssue(" ENG-42 "))
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	other, err := manager.CreateConversation(addressing.StableAddress{}, "bob", "Backoff", "Which curve?")
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if tracked.Metadata.LinkedIssue != "ENG-42" {
+		t.Errorf("Expected the linked issue trimmed, got %q", tracked.Metadata.LinkedIssue)
+	}
+
+	byTicket := &operations.Operation{ID: operations.NewOperationID([]byte("ticket")), Metadata: operations.OperationMeta{Ticket: "ENG-42"}}
+	byThread := &operations.Operation{ID: operations.NewOperationID([]byte("thread")), Metadata: operations.OperationMeta{
+		Ticket:  "ENG-42",
+		Context: map[string]string{operations.MetaThread: string(other.ID)},
+	}}
+	unrelated := &operations.Operation{ID: operations.NewOperationID([]byte("unrelated")), Metadata: operations.OperationMeta{Ticket: "ENG-7"}}
+
+	if linked := manager.LinkOperation(byTicket); !slices.Equal(linked, []ThreadID{tracked.ID}) {
+		t.Errorf("Expected the operation linked by its ticket, got %v", linked)
+	}
+	if linked := manager.LinkOperation(byThread); len(linked) != 2 {
+		t.Errorf("Expected the operation linked by its ticket and thread, got %v", linked)
+	}
+	if linked := manager.LinkOperation(unrelated); len(linked) != 0 {
+		t.Errorf("Expected no links for an unknown ticket, got %v", linked)
+	}
+	// Linking again doesn't repeat the operation
+	manager.LinkOperation(byTicket)
+
+	thread, err := manager.GetConversation(tracked.ID)
+	if err != nil {
+		t.Fatalf("Failed to get conversation: %v", err)
+	}
+	if !slices.Equal(thread.LinkedOperations, []operations.OperationID{byTicket.ID, byThread.ID}) {
+		t.Errorf("Expected both operations linked in order, got %v", thread.LinkedOperations)
+	}
+	if threads := manager.GetConversationsByOperation(byThread.ID); len(threads) != 2 {
+		t.Errorf("Expected the operation's threads found through its links, got %d", len(threads))
+	}
+
+	// Links survive a snapshot, and restoring doesn't index them twice
+	restored := NewConversationManager()
+	restored.Restore(manager.Snapshot())
+	restored.Restore(manager.Snapshot())
+	if threads := restored.GetConversationsByOperation(byTicket.ID); len(threads) != 1 || threads[0].ID != tracked.ID {
+		t.Errorf("Expected the link restored, got %+v", threads)
+	}
+	if linked := restored.LinkOperation(&operations.Operation{ID: "later", Metadata: operations.OperationMeta{Ticket: "ENG-42"}}); len(linked) != 1 {
+		t.Errorf("Expected the restored thread found by its issue, got %v", linked)
+	}
+}
+
+func TestParseIssueLink(t *testing.T) {
+	cases := map[string]IssueLink{
+		"eng-42":    {Key: "ENG-42"},
+		" OPS_2-7 ": {Key: "OPS_2-7"},
+		"https://acme.atlassian.net/browse/ENG-42": {Key: "ENG-42", Tracker: TrackerJira, URL: "https://acme.atlassian.net/browse/ENG-42"},
+		"https://acme.atlassian.net/jira/software/projects/ENG/boards/1?selectedIssue=ENG-9": {
+			Key: "ENG-9", Tracker: TrackerJira, URL: "https://acme.atlassian.net/jira/software/projects/ENG/boards/1?selectedIssue=ENG-9",
+		},
+		"https://linear.app/acme/issue/ENG-42/retry-storm": {Key: "ENG-42", Tracker: TrackerLinear, URL: "https://linear.app/acme/issue/ENG-42/retry-storm"},
+	}
+	for issue, want := range cases {
+		link, err := ParseIssueLink(issue)
+		if err != nil || link != want {
+			t.Errorf("ParseIssueLink(%q) = %+v, %v, expected %+v", issue, link, err, want)
+		}
+	}
+
+	for _, issue := range []string{"", "ENG 42", "ENG-0", "42", "ftp://acme/browse/ENG-1", "https://linear.app/acme/projects"} {
+		if _, err := ParseIssueLink(issue); !errors.Is(err, ErrInvalidIssue) {
+			t.Errorf("Expected %q to be rejected, got %v", issue, err)
+		}
+	}
+
+	manager := NewConversationManager()
+	if _, err := manager.CreateConversation(addressing.StableAddress{}, "alice", "Retry storm", "...", WithLinkedIssue("soon")); !errors.Is(err, ErrInvalidIssue) {
+		t.Errorf("Expected an invalid linked issue to be refused, got %v", err)
+	}
+}
@@ -1,41 +1,84 @@
 package context
 
 import (
+	"fmt"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/events"
 	"github.com/jeremytregunna/contextdb/internal/operations"
 )
 
 type ConversationManager struct {
-	conversations map[ThreadID]*ConversationThread
-	addressIndex  map[addressing.AddressKey][]ThreadID // Address -> Thread IDs
-	authorIndex   map[operations.AuthorID][]ThreadID   // Author -> Thread IDs
-	mutex         sync.RWMutex
+	conversations  map[ThreadID]*ConversationThread
+	addressIndex   map[addressing.AddressKey][]ThreadID  // Address -> Thread IDs
+	authorIndex    map[operations.AuthorID][]ThreadID    // Author -> Thread IDs
+	decisionIndex  map[MessageID]ThreadID                // Decision message -> Thread ID
+	operationIndex map[operations.OperationID][]ThreadID // Anchored, referenced or linked operation -> Thread IDs
+	issueIndex     map[string][]ThreadID                 // Linked issue -> Thread IDs
+	aliases        AuthorAliases                         // Author index keys are resolved through these
+	events         *events.Bus
+	mutex          sync.RWMutex
 }
 
 func NewConversationManager() *ConversationManager {
 	return &ConversationManager{
-		conversations: make(map[ThreadID]*ConversationThread),
-		addressIndex:  make(map[addressing.AddressKey][]ThreadID),
-		authorIndex:   make(map[operations.AuthorID][]ThreadID),
+		conversations:  make(map[ThreadID]*ConversationThread),
+		addressIndex:   make(map[addressing.AddressKey][]ThreadID),
+		authorIndex:    make(map[operations.AuthorID][]ThreadID),
+		decisionIndex:  make(map[MessageID]ThreadID),
+		operationIndex: make(map[operations.OperationID][]ThreadID),
+		issueIndex:     make(map[string][]ThreadID),
 	}
 }
 
-func (cm *ConversationManager) CreateConversation(anchorAddr addressing.StableAddress, authorID operations.AuthorID, title, content string) (*ConversationThread, error) {
+// SetEventBus publishes conversation.created and conversation.resolved to bus
+func (cm *ConversationManager) SetEventBus(bus *events.Bus) {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
 
+	cm.events = bus
+}
+
+func (cm *ConversationManager) CreateConversation(anchorAddr addressing.StableAddress, authorID operations.AuthorID, title, content string, opts ...ThreadOption) (*ConversationThread, error) {
+	return cm.addConversation(NewConversationThread(anchorAddr, authorID, title, content), opts)
+}
+
+// CreateChangeSetConversation starts a thread about a change set. The anchor
+// address is optional and places the thread in the code as well.
+func (cm *ConversationManager) CreateChangeSetConversation(changeSet ChangeSetID, anchorAddr addressing.StableAddress, authorID operations.AuthorID, title, content string, opts ...ThreadOption) (*ConversationThread, error) {
 	thread := NewConversationThread(anchorAddr, authorID, title, content)
+	thread.ChangeSetID = changeSet
+	return cm.addConversation(thread, opts)
+}
+
+func (cm *ConversationManager) addConversation(thread *ConversationThread, opts []ThreadOption) (*ConversationThread, error) {
+	for _, opt := range opts {
+		opt(thread)
+	}
+	if !thread.Visibility.IsValid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidVisibility, thread.Visibility)
+	}
+	if err := thread.linkIssue(); err != nil {
+		return nil, err
+	}
+
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
 
 	cm.conversations[thread.ID] = thread
 	cm.indexConversation(thread)
+	cm.events.Publish(events.ConversationCreated, cm.copyThread(thread).Redacted())
 
 	return thread, nil
 }
 
-func (cm *ConversationManager) GetConversation(threadID ThreadID) (*ConversationThread, error) {
+// GetConversation returns a thread with the page of its messages opts select,
+// or all of them without any
+func (cm *ConversationManager) GetConversation(threadID ThreadID, opts ...MessageOption) (*ConversationThread, error) {
 	cm.mutex.RLock()
 	defer cm.mutex.RUnlock()
 
@@ -45,7 +88,7 @@ func (cm *ConversationManager) GetConversation(threadID ThreadID) (*Conversation
 	}
 
 	// Return a copy to avoid race conditions
-	return cm.copyThread(thread), nil
+	return cm.pageThread(thread, opts)
 }
 
 func (cm *ConversationManager) GetConversationsByAddress(addr addressing.StableAddress) ([]*ConversationThread, error) {
@@ -68,11 +111,27 @@ func (cm *ConversationManager) GetConversationsByAddress(addr addressing.StableA
 	return threads, nil
 }
 
+// GetConversationsByOperation returns the threads anchored to or referencing
+// code created by the operation
+func (cm *ConversationManager) GetConversationsByOperation(opID operations.OperationID) []*ConversationThread {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	threadIDs := cm.operationIndex[opID]
+	threads := make([]*ConversationThread, 0, len(threadIDs))
+	for _, threadID := range threadIDs {
+		if thread, exists := cm.conversations[threadID]; exists {
+			threads = append(threads, cm.copyThread(thread))
+		}
+	}
+	return threads
+}
+
 func (cm *ConversationManager) GetConversationsByAuthor(authorID operations.AuthorID) ([]*ConversationThread, error) {
 	cm.mutex.RLock()
 	defer cm.mutex.RUnlock()
 
-	threadIDs, exists := cm.authorIndex[authorID]
+	threadIDs, exists := cm.authorIndex[cm.aliases.Resolve(authorID)]
 	if !exists {
 		return []*ConversationThread{}, nil
 	}
@@ -87,7 +146,9 @@ func (cm *ConversationManager) GetConversationsByAuthor(authorID operations.Auth
 	return threads, nil
 }
 
-func (cm *ConversationManager) AddMessage(threadID ThreadID, authorID operations.AuthorID, content string, msgType MessageType) (*Message, error) {
+// AddMessage adds a message to a thread. References link the message to other
+// code, and the thread is found by GetConversationsByOperation for each.
+func (cm *ConversationManager) AddMessage(threadID ThreadID, authorID operations.AuthorID, content string, msgType MessageType, references ...addressing.StableAddress) (*Message, error) {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
 
@@ -95,9 +156,20 @@ func (cm *ConversationManager) AddMessage(threadID ThreadID, authorID operations
 	if !exists {
 		return nil, ErrConversationNotFound
 	}
+	if msgType.IsVerdict() {
+		if thread.Review == nil {
+			return nil, fmt.Errorf("%w: %s can only be given on a review", ErrNotReview, msgType)
+		}
+		thread.Review.addReviewers(authorID)
+	}
 
-	message := thread.AddMessage(authorID, content, msgType)
+	cm.unindexOperations(thread)
+	message := thread.AddMessage(authorID, content, msgType, references...)
 	cm.updateAuthorIndex(thread)
+	cm.indexOperations(thread)
+	if msgType == MsgDecision {
+		cm.decisionIndex[message.ID] = thread.ID
+	}
 
 	return message, nil
 }
@@ -126,6 +198,9 @@ func (cm *ConversationManager) AddReaction(threadID ThreadID, messageID MessageI
 	return thread.AddReaction(messageID, authorID, emoji)
 }
 
+// ResolutionMessage is the decision ResolveConversation adds to a thread
+const ResolutionMessage = "Conversation resolved"
+
 func (cm *ConversationManager) ResolveConversation(threadID ThreadID, authorID operations.AuthorID) error {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
@@ -138,7 +213,9 @@ func (cm *ConversationManager) ResolveConversation(threadID ThreadID, authorID o
 	thread.SetStatus(StatusResolved)
 
 	// Add resolution message
-	thread.AddMessage(authorID, "Conversation resolved", MsgDecision)
+	message := thread.AddMessage(authorID, ResolutionMessage, MsgDecision)
+	cm.decisionIndex[message.ID] = thread.ID
+	cm.events.Publish(events.ConversationResolved, cm.copyThread(thread).Redacted())
 
 	return nil
 }
@@ -184,6 +261,18 @@ func (cm *ConversationManager) GetConversationsByStatus(status ThreadStatus) ([]
 	return filtered, nil
 }
 
+// CountByStatus counts the conversations in each status
+func (cm *ConversationManager) CountByStatus() map[ThreadStatus]int {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	counts := make(map[ThreadStatus]int)
+	for _, thread := range cm.conversations {
+		counts[thread.Status]++
+	}
+	return counts
+}
+
 func (cm *ConversationManager) SearchConversations(query string) ([]*ConversationThread, error) {
 	cm.mutex.RLock()
 	defer cm.mutex.RUnlock()
@@ -214,7 +303,9 @@ func (cm *ConversationManager) UpdateAddressLocation(oldAddr, newAddr addressing
 	newKey := newAddr.Key()
 	for _, threadID := range threadIDs {
 		if thread, exists := cm.conversations[threadID]; exists {
+			cm.unindexOperations(thread)
 			thread.AnchorAddress = newAddr
+			cm.indexOperations(thread)
 		}
 	}
 
@@ -225,21 +316,132 @@ func (cm *ConversationManager) UpdateAddressLocation(oldAddr, newAddr addressing
 	return nil
 }
 
+// PurgeResolvedBefore removes resolved and archived threads that have not been
+// updated since cutoff. With dryRun set it only reports what would be removed.
+func (cm *ConversationManager) PurgeResolvedBefore(cutoff time.Time, dryRun bool) []ThreadID {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	var purged []ThreadID
+	for threadID, thread := range cm.conversations {
+		if thread.Status != StatusResolved && thread.Status != StatusArchived {
+			continue
+		}
+		if !thread.UpdatedAt.Before(cutoff) {
+			continue
+		}
+
+		purged = append(purged, threadID)
+		if !dryRun {
+			cm.unindexConversation(thread)
+			delete(cm.conversations, threadID)
+		}
+	}
+
+	return purged
+}
+
 func (cm *ConversationManager) indexConversation(thread *ConversationThread) {
 	// Index by address
 	addressKey := thread.AnchorAddress.Key()
 	cm.addressIndex[addressKey] = append(cm.addressIndex[addressKey], thread.ID)
 
 	// Index by participants
+	cm.updateAuthorIndex(thread)
+
+	for _, message := range thread.Messages {
+		if message.MessageType == MsgDecision {
+			cm.decisionIndex[message.ID] = thread.ID
+		}
+	}
+
+	cm.indexOperations(thread)
+	cm.indexIssue(thread)
+}
+
+func (cm *ConversationManager) unindexConversation(thread *ConversationThread) {
+	addressKey := thread.AnchorAddress.Key()
+	cm.addressIndex[addressKey] = removeThreadID(cm.addressIndex[addressKey], thread.ID)
+	if len(cm.addressIndex[addressKey]) == 0 {
+		delete(cm.addressIndex, addressKey)
+	}
+
 	for _, participant := range thread.Participants {
-		cm.authorIndex[participant] = append(cm.authorIndex[participant], thread.ID)
+		author := cm.aliases.Resolve(participant)
+		cm.authorIndex[author] = removeThreadID(cm.authorIndex[author], thread.ID)
+		if len(cm.authorIndex[author]) == 0 {
+			delete(cm.authorIndex, author)
+		}
+	}
+
+	for _, message := range thread.Messages {
+		if message.MessageType == MsgDecision {
+			delete(cm.decisionIndex, message.ID)
+		}
+	}
+
+	cm.unindexOperations(thread)
+	cm.unindexIssue(thread)
+}
+
+// threadOperations lists the operations a thread's anchor and message
+// references point at and those linked to it, without repeats
+func threadOperations(thread *ConversationThread) []operations.OperationID {
+	var opIDs []operations.OperationID
+	add := func(addr addressing.StableAddress) {
+		if addr.OperationID == "" {
+			return
+		}
+		for _, opID := range opIDs {
+			if opID == addr.OperationID {
+				return
+			}
+		}
+		opIDs = append(opIDs, addr.OperationID)
+	}
+
+	add(thread.AnchorAddress)
+	for _, message := range thread.Messages {
+		for _, ref := range message.References {
+			add(ref)
+		}
+	}
+	for _, opID := range thread.LinkedOperations {
+		add(addressing.StableAddress{OperationID: opID})
 	}
+	return opIDs
+}
+
+func (cm *ConversationManager) indexOperations(thread *ConversationThread) {
+	for _, opID := range threadOperations(thread) {
+		cm.operationIndex[opID] = append(cm.operationIndex[opID], thread.ID)
+	}
+}
+
+func (cm *ConversationManager) unindexOperations(thread *ConversationThread) {
+	for _, opID := range threadOperations(thread) {
+		cm.operationIndex[opID] = removeThreadID(cm.operationIndex[opID], thread.ID)
+		if len(cm.operationIndex[opID]) == 0 {
+			delete(cm.operationIndex, opID)
+		}
+	}
+}
+
+func removeThreadID(threadIDs []ThreadID, threadID ThreadID) []ThreadID {
+	filtered := make([]ThreadID, 0, len(threadIDs))
+	for _, id := range threadIDs {
+		if id != threadID {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered
 }
 
 func (cm *ConversationManager) updateAuthorIndex(thread *ConversationThread) {
-	// Rebuild author index for this thread
+	// Rebuild author index for this thread, under each participant's identity
 	for _, participant := range thread.Participants {
-		threadIDs := cm.authorIndex[participant]
+		author := cm.aliases.Resolve(participant)
+		threadIDs := cm.authorIndex[author]
 
 		// Check if thread is already indexed
 		found := false
@@ -251,29 +453,39 @@ func (cm *ConversationManager) updateAuthorIndex(thread *ConversationThread) {
 		}
 
 		if !found {
-			cm.authorIndex[participant] = append(cm.authorIndex[participant], thread.ID)
+			cm.authorIndex[author] = append(cm.authorIndex[author], thread.ID)
 		}
 	}
 }
 
+// copyThread copies a thread for callers outside the lock. Messages are
+// shared, since they're never changed in place, and capped so appending to
+// the copy's messages can't write into the thread's.
 func (cm *ConversationManager) copyThread(thread *ConversationThread) *ConversationThread {
-	// Create a deep copy to prevent race conditions
 	copyThread := &ConversationThread{
 		ID:            thread.ID,
 		Title:         thread.Title,
 		AnchorAddress: thread.AnchorAddress,
+		ChangeSetID:   thread.ChangeSetID,
+		Branch:        thread.Branch,
 		Participants:  make([]operations.AuthorID, len(thread.Participants)),
-		Messages:      make([]Message, len(thread.Messages)),
+		Messages:      thread.Messages[:len(thread.Messages):len(thread.Messages)],
 		Status:        thread.Status,
+		Visibility:    thread.Visibility,
 		CreatedAt:     thread.CreatedAt,
 		UpdatedAt:     thread.UpdatedAt,
 		Tags:          make([]string, len(thread.Tags)),
 		Metadata:      thread.Metadata,
 	}
 
+	if thread.Review != nil {
+		copyThread.Review = &ReviewRequest{Reviewers: slices.Clone(thread.Review.Reviewers)}
+	}
 	copy(copyThread.Participants, thread.Participants)
-	copy(copyThread.Messages, thread.Messages)
 	copy(copyThread.Tags, thread.Tags)
+	copyThread.Metadata.Labels = append([]string(nil), thread.Metadata.Labels...)
+	copyThread.LinkedOperations = slices.Clone(thread.LinkedOperations)
+	copyThread.Summary = thread.Summary
 
 	return copyThread
 }
@@ -284,6 +496,10 @@ func (cm *ConversationManager) threadMatchesQuery(thread *ConversationThread, qu
 		return true
 	}
 
+	if thread.Summary != nil && strings.Contains(strings.ToLower(thread.Summary.Text), queryLower) {
+		return true
+	}
+
 	// Search in messages
 	for _, msg := range thread.Messages {
 		if strings.Contains(strings.ToLower(msg.Content), queryLower) {
@@ -1,7 +1,9 @@
 package context
 
 import (
+	"errors"
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/jeremytregunna/contextdb/internal/addressing"
@@ -42,6 +44,17 @@ func TestConversationManager_CreateAndGet(t *testing.T) {
 	if retrieved.AnchorAddress.Repository != anchorAddr.Repository {
 		t.Errorf("Expected repository %s, got %s", anchorAddr.Repository, retrieved.AnchorAddress.Repository)
 	}
+
+	onBranch, err := manager.CreateConversation(anchorAddr, authorID, title, content, WithBranch("feature"))
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if retrieved, err = manager.GetConversation(onBranch.ID); err != nil {
+		t.Fatalf("Failed to get conversation: %v", err)
+	}
+	if retrieved.Branch != "feature" {
+		t.Errorf("Expected the conversation to be about feature, got %q", retrieved.Branch)
+	}
 }
 
 func TestConversationManager_GetByAddress(t *testing.T) {
@@ -245,6 +258,22 @@ func TestConversationManager_SearchConversations(t *testing.T) {
 	if len(results) != 2 {
 		t.Errorf("Expected 2 results for 'code', got %d", len(results))
 	}
+
+	// A thread's summary is searched too
+	summarized, _ := manager.CreateConversation(anchorAddr, "author5", "Flaky test", "It fails on CI")
+	if _, err := manager.SetSummary(summarized.ID, ThreadSummary{Text: "Caused by a race in the scheduler", Messages: 1}); err != nil {
+		t.Fatalf("Failed to set summary: %v", err)
+	}
+	results, err = manager.SearchConversations("scheduler")
+	if err != nil {
+		t.Fatalf("Failed to search conversations: %v", err)
+	}
+	if len(results) != 1 || results[0].Summary == nil || results[0].SummaryStale() {
+		t.Errorf("Expected the summarized thread with its summary, got %+v", results)
+	}
+	if _, err := manager.SetSummary("missing", ThreadSummary{}); err != ErrConversationNotFound {
+		t.Errorf("Expected ErrConversationNotFound, got %v", err)
+	}
 }
 
 func TestConversationManager_GetByStatus(t *testing.T) {
@@ -335,3 +364,104 @@ func TestConversationManager_UpdateAddressLocation(t *testing.T) {
 		t.Errorf("Expected 1 conversation for new address, got %d", len(newAddrConversations))
 	}
 }
+
+func TestConversationManager_SnapshotRestore(t *testing.T) {
+	manager := NewConversationManager()
+
+	opID := operations.NewOperationID([]byte("snapshot-op"))
+	pos := operations.NewLogootPosition([]operations.PositionSegment{
+		{Value: big.NewInt(1), AuthorID: "author1"},
+	})
+	anchorAddr := addressing.NewStableAddress(addressing.RepositoryID("test-repo"), opID, addressing.PositionRange{Start: pos, End: pos})
+
+	thread, _ := manager.CreateConversation(anchorAddr, "author1", "Snapshot", "First")
+	manager.AddMessage(thread.ID, "author2", "Reply", MsgAnswer)
+
+	snapshot := manager.Snapshot()
+	if len(snapshot) != 1 || len(snapshot[0].Messages) != 2 {
+		t.Fatalf("Expected one thread with two messages in snapshot, got %+v", snapshot)
+	}
+
+	restored := NewConversationManager()
+	restored.Restore(snapshot)
+	restored.Restore(snapshot)
+
+	byAddress, err := restored.GetConversationsByAddress(anchorAddr)
+	if err != nil || len(byAddress) != 1 {
+		t.Errorf("Expected restored thread to be indexed once by address, got %d (%v)", len(byAddress), err)
+	}
+
+	byAuthor, _ := restored.GetConversationsByAuthor("author2")
+	if len(byAuthor) != 1 {
+		t.Errorf("Expected restored thread to be indexed by participant, got %d", len(byAuthor))
+	}
+}
+
+func TestConversationManager_MessagePages(t *testing.T) {
+	manager := NewConversationManager()
+	anchorAddr := addressing.NewStableAddress("test-repo", operations.NewOperationID([]byte("test-op")), addressing.PositionRange{})
+
+	thread, _ := manager.CreateConversation(anchorAddr, "author1", "Discussion", "0")
+	for _, content := range []string{"1", "2", "3", "4"} {
+		if _, err := manager.AddMessage(thread.ID, "author1", content, MsgComment); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	// Reading back page by page from the newest message covers the thread once
+	var contents []string
+	var before MessageID
+	for {
+		page, err := manager.GetConversation(thread.ID, WithMessageLimit(2), WithMessagesBefore(before))
+		if err != nil {
+			t.Fatalf("Failed to get conversation: %v", err)
+		}
+		for i := len(page.Messages) - 1; i >= 0; i-- {
+			contents = append(contents, page.Messages[i].Content)
+		}
+		if len(page.Messages) < 2 {
+			break
+		}
+		before = page.Messages[0].ID
+	}
+	if strings.Join(contents, "") != "43210" {
+		t.Errorf("Expected messages 4 to 0, got %v", contents)
+	}
+
+	if _, err := manager.GetConversation(thread.ID, WithMessagesBefore("unknown")); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+}
+
+func TestConversationManager_ReadsShareMessages(t *testing.T) {
+	manager := NewConversationManager()
+	anchorAddr := addressing.NewStableAddress("test-repo", operations.NewOperationID([]byte("test-op")), addressing.PositionRange{})
+
+	thread, _ := manager.CreateConversation(anchorAddr, "author1", "Discussion", "Initial")
+	messageID := thread.Messages[0].ID
+	read, _ := manager.GetConversation(thread.ID)
+
+	// Changes after a read don't show up in what was read
+	if err := manager.EditMessage(thread.ID, messageID, "author1", "Edited", ""); err != nil {
+		t.Fatalf("Failed to edit message: %v", err)
+	}
+	if err := manager.AddReaction(thread.ID, messageID, "author2", "+1"); err != nil {
+		t.Fatalf("Failed to add reaction: %v", err)
+	}
+	if read.Messages[0].Content != "Initial" || len(read.Messages[0].Reactions) != 0 {
+		t.Errorf("Expected the read message to be unchanged, got %+v", read.Messages[0])
+	}
+
+	// Nor do changes to what was read show up in the thread
+	read.Messages = append(read.Messages, Message{Content: "Appended"})
+	if _, err := manager.AddMessage(thread.ID, "author2", "Reply", MsgComment); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	current, _ := manager.GetConversation(thread.ID)
+	if len(current.Messages) != 2 || current.Messages[0].Content != "Edited" || current.Messages[1].Content != "Reply" {
+		t.Errorf("Expected the edited message and the reply, got %+v", current.Messages)
+	}
+	if read.Messages[1].Content != "Appended" {
+		t.Errorf("Expected the appended message to be kept, got %q", read.Messages[1].Content)
+	}
+}
@@ -0,0 +1,59 @@
+package context
+
+import "fmt"
+
+// MessageOption selects a page of a thread's messages. Pages run from the
+// newest message back, so a client reads a long thread by passing the first
+// message of each page as the next page's before.
+type MessageOption func(*messagePage)
+
+type messagePage struct {
+	limit  int
+	before MessageID
+}
+
+// WithMessageLimit keeps the latest limit messages of the page. Zero or less
+// keeps them all.
+func WithMessageLimit(limit int) MessageOption {
+	return func(p *messagePage) {
+		p.limit = limit
+	}
+}
+
+// WithMessagesBefore keeps only the messages older than before
+func WithMessagesBefore(before MessageID) MessageOption {
+	return func(p *messagePage) {
+		p.before = before
+	}
+}
+
+// pageThread copies a thread with only the page of messages opts select. The
+// caller holds the lock.
+func (cm *ConversationManager) pageThread(thread *ConversationThread, opts []MessageOption) (*ConversationThread, error) {
+	var page messagePage
+	for _, opt := range opts {
+		opt(&page)
+	}
+
+	end := len(thread.Messages)
+	if page.before != "" {
+		end = -1
+		for i, message := range thread.Messages {
+			if message.ID == page.before {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, page.before)
+		}
+	}
+	start := 0
+	if page.limit > 0 && end > page.limit {
+		start = end - page.limit
+	}
+
+	copied := cm.copyThread(thread)
+	copied.Messages = thread.Messages[start:end:end]
+	return copied, nil
+}
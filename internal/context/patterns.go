@@ -0,0 +1,258 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// PatternThresholds decide when a run of operations shows an activity pattern
+// and which documents are hot spots
+type PatternThresholds struct {
+	// BurstyRate is the operations per hour above which activity is bursty
+	BurstyRate float64 `json:"bursty_rate"`
+	// SteadyActiveRatio is the share of days in the period with activity at
+	// which it is steady, given at least SteadyMinDays active days
+	SteadyActiveRatio float64 `json:"steady_active_ratio"`
+	SteadyMinDays     int     `json:"steady_min_days"`
+	// RefactoringRatio and BugfixingRatio are the shares of operations with
+	// that intent above which activity is refactoring or bug fixing
+	RefactoringRatio float64 `json:"refactoring_ratio"`
+	BugfixingRatio   float64 `json:"bugfixing_ratio"`
+	// HotSpotMinOperations is the fewest operations that make a document a
+	// hot spot, and MaxHotSpots how many are reported
+	HotSpotMinOperations int `json:"hot_spot_min_operations"`
+	MaxHotSpots          int `json:"max_hot_spots"`
+}
+
+func DefaultPatternThresholds() PatternThresholds {
+	return PatternThresholds{
+		BurstyRate:           5.0,
+		SteadyActiveRatio:    0.5,
+		SteadyMinDays:        3,
+		RefactoringRatio:     0.3,
+		BugfixingRatio:       0.3,
+		HotSpotMinOperations: 10,
+		MaxHotSpots:          10,
+	}
+}
+
+// SetPatternThresholds replaces the thresholds activity patterns and hot
+// spots are detected with. Zero fields keep their defaults.
+func (ca *ContextAnalyzer) SetPatternThresholds(thresholds PatternThresholds) {
+	defaults := DefaultPatternThresholds()
+	if thresholds.BurstyRate <= 0 {
+		thresholds.BurstyRate = defaults.BurstyRate
+	}
+	if thresholds.SteadyActiveRatio <= 0 {
+		thresholds.SteadyActiveRatio = defaults.SteadyActiveRatio
+	}
+	if thresholds.SteadyMinDays <= 0 {
+		thresholds.SteadyMinDays = defaults.SteadyMinDays
+	}
+	if thresholds.RefactoringRatio <= 0 {
+		thresholds.RefactoringRatio = defaults.RefactoringRatio
+	}
+	if thresholds.BugfixingRatio <= 0 {
+		thresholds.BugfixingRatio = defaults.BugfixingRatio
+	}
+	if thresholds.HotSpotMinOperations <= 0 {
+		thresholds.HotSpotMinOperations = defaults.HotSpotMinOperations
+	}
+	if thresholds.MaxHotSpots <= 0 {
+		thresholds.MaxHotSpots = defaults.MaxHotSpots
+	}
+
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	ca.thresholds = thresholds
+}
+
+func (ca *ContextAnalyzer) PatternThresholds() PatternThresholds {
+	ca.mutex.RLock()
+	defer ca.mutex.RUnlock()
+
+	return ca.thresholds
+}
+
+// HotSpot is a document that saw a lot of the activity in a period. Share is
+// its fraction of all the operations considered.
+type HotSpot struct {
+	DocumentID   string                `json:"document_id"`
+	Operations   int                   `json:"operations"`
+	Authors      []operations.AuthorID `json:"authors"`
+	LinesAdded   int                   `json:"lines_added"`
+	LinesDeleted int                   `json:"lines_deleted"`
+	Share        float64               `json:"share"`
+}
+
+func (ca *ContextAnalyzer) buildActivitySummary(ops []*operations.Operation) ActivitySummary {
+	summary := ActivitySummary{
+		TotalOperations:   len(ops),
+		OperationTypes:    make(map[string]int),
+		IntentTypes:       make(map[IntentCategory]int),
+		DocumentsModified: []string{},
+	}
+
+	documents := make(map[string]bool)
+
+	for _, op := range ops {
+		summary.OperationTypes[string(op.Type)]++
+
+		if docID := op.Metadata.DocumentID; docID != "" {
+			documents[docID] = true
+		}
+
+		// Analyze intent
+		intent := ca.analyzeOperationIntent(op)
+		summary.IntentTypes[intent.Category]++
+
+		added, deleted := lineChanges(op)
+		summary.LinesAdded += added
+		summary.LinesDeleted += deleted
+	}
+
+	// Convert document set to slice
+	for doc := range documents {
+		summary.DocumentsModified = append(summary.DocumentsModified, doc)
+	}
+	sort.Strings(summary.DocumentsModified)
+
+	return summary
+}
+
+// lineChanges counts the lines op added and deleted, roughly
+func lineChanges(op *operations.Operation) (int, int) {
+	switch op.Type {
+	case operations.OpInsert:
+		return strings.Count(op.Content, "\n") + 1, 0
+	case operations.OpDelete:
+		return 0, op.Length
+	}
+	return 0, 0
+}
+
+func (ca *ContextAnalyzer) detectActivityPatterns(ops []*operations.Operation, thresholds PatternThresholds) []ActivityPattern {
+	var patterns []ActivityPattern
+
+	if len(ops) < 2 {
+		return patterns
+	}
+
+	first, last := ops[0].Timestamp, ops[0].Timestamp
+	days := make(map[string]bool)
+	intents := make(map[IntentCategory]int)
+	for _, op := range ops {
+		if op.Timestamp.Before(first) {
+			first = op.Timestamp
+		}
+		if op.Timestamp.After(last) {
+			last = op.Timestamp
+		}
+		days[op.Timestamp.UTC().Format(time.DateOnly)] = true
+		intents[ca.analyzeOperationIntent(op).Category]++
+	}
+
+	// Detect bursty pattern (many operations in short time). Spans under a
+	// minute count as a minute so a handful of operations isn't a burst.
+	timeSpan := max(last.Sub(first), time.Minute)
+	avgRate := float64(len(ops)) / timeSpan.Hours()
+
+	if avgRate > thresholds.BurstyRate {
+		patterns = append(patterns, ActivityPattern{
+			Type:        PatternBursty,
+			Description: "High frequency of operations in short time period",
+			Frequency:   avgRate,
+			Confidence:  0.8,
+		})
+	}
+
+	// Detect steady pattern (activity on most days of the period)
+	spanDays := int(last.UTC().Truncate(24*time.Hour).Sub(first.UTC().Truncate(24*time.Hour)).Hours()/24) + 1
+	activeRatio := float64(len(days)) / float64(spanDays)
+	if len(days) >= thresholds.SteadyMinDays && activeRatio >= thresholds.SteadyActiveRatio {
+		patterns = append(patterns, ActivityPattern{
+			Type:        PatternSteady,
+			Description: fmt.Sprintf("Activity on %d of %d days", len(days), spanDays),
+			Frequency:   activeRatio,
+			Confidence:  0.7,
+		})
+	}
+
+	// Detect refactoring pattern
+	refactorRatio := float64(intents[IntentRefactor]) / float64(len(ops))
+	if refactorRatio > thresholds.RefactoringRatio {
+		patterns = append(patterns, ActivityPattern{
+			Type:        PatternRefactoring,
+			Description: "High proportion of refactoring operations",
+			Frequency:   refactorRatio,
+			Confidence:  0.7,
+		})
+	}
+
+	// Detect bug fixing pattern
+	bugfixRatio := float64(intents[IntentBugfix]) / float64(len(ops))
+	if bugfixRatio > thresholds.BugfixingRatio {
+		patterns = append(patterns, ActivityPattern{
+			Type:        PatternBugfixing,
+			Description: "High proportion of bug fixing operations",
+			Frequency:   bugfixRatio,
+			Confidence:  0.7,
+		})
+	}
+
+	return patterns
+}
+
+// hotSpots ranks the documents ops touched by how many operations they saw,
+// keeping those with at least the threshold's minimum
+func hotSpots(ops []*operations.Operation, thresholds PatternThresholds) []HotSpot {
+	byDocument := make(map[string]*HotSpot)
+	authors := make(map[string]map[operations.AuthorID]bool)
+	for _, op := range ops {
+		documentID := op.Metadata.DocumentID
+		if documentID == "" {
+			continue
+		}
+
+		spot, exists := byDocument[documentID]
+		if !exists {
+			spot = &HotSpot{DocumentID: documentID}
+			byDocument[documentID] = spot
+			authors[documentID] = make(map[operations.AuthorID]bool)
+		}
+		spot.Operations++
+		added, deleted := lineChanges(op)
+		spot.LinesAdded += added
+		spot.LinesDeleted += deleted
+		if !authors[documentID][op.Author] {
+			authors[documentID][op.Author] = true
+			spot.Authors = append(spot.Authors, op.Author)
+		}
+	}
+
+	spots := []HotSpot{}
+	for _, spot := range byDocument {
+		if spot.Operations < thresholds.HotSpotMinOperations {
+			continue
+		}
+		spot.Share = float64(spot.Operations) / float64(len(ops))
+		sort.Slice(spot.Authors, func(i, j int) bool { return spot.Authors[i] < spot.Authors[j] })
+		spots = append(spots, *spot)
+	}
+
+	sort.Slice(spots, func(i, j int) bool {
+		if spots[i].Operations != spots[j].Operations {
+			return spots[i].Operations > spots[j].Operations
+		}
+		return spots[i].DocumentID < spots[j].DocumentID
+	})
+	if len(spots) > thresholds.MaxHotSpots {
+		spots = spots[:thresholds.MaxHotSpots]
+	}
+	return spots
+}
@@ -0,0 +1,136 @@
+package context
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func patternOp(author operations.AuthorID, document, content string, at time.Time) *operations.Operation {
+	return &operations.Operation{
+		ID:        operations.OperationID(string(author) + at.Format(time.RFC3339Nano)),
+		Type:      operations.OpInsert,
+		Content:   content,
+		Author:    author,
+		Timestamp: at,
+		Metadata:  operations.OperationMeta{DocumentID: document},
+	}
+}
+
+func hasPattern(patterns []ActivityPattern, patternType PatternType) bool {
+	for _, pattern := range patterns {
+		if pattern.Type == patternType {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDetectActivityPatterns(t *testing.T) {
+	analyzer := NewContextAnalyzer(operations.NewOperationDAG(), nil, NewConversationManager())
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	// One bug fix a day for five days: steady, not bursty
+	var steady []*operations.Operation
+	for day := 0; day < 5; day++ {
+		steady = append(steady, patternOp("alice", "retry.go", "fix the bug", start.Add(time.Duration(day)*24*time.Hour)))
+	}
+	patterns := analyzer.detectActivityPatterns(steady, analyzer.PatternThresholds())
+	if !hasPattern(patterns, PatternSteady) || !hasPattern(patterns, PatternBugfixing) {
+		t.Errorf("Expected steady bug fixing, got %+v", patterns)
+	}
+	if hasPattern(patterns, PatternBursty) || hasPattern(patterns, PatternRefactoring) {
+		t.Errorf("Expected no bursty or refactoring pattern, got %+v", patterns)
+	}
+
+	// Twenty refactors in twenty minutes: bursty, not steady
+	var burst []*operations.Operation
+	for i := 0; i < 20; i++ {
+		burst = append(burst, patternOp("bob", "util.go", "refactor and clean up", start.Add(time.Duration(i)*time.Minute)))
+	}
+	patterns = analyzer.detectActivityPatterns(burst, analyzer.PatternThresholds())
+	if !hasPattern(patterns, PatternBursty) || !hasPattern(patterns, PatternRefactoring) {
+		t.Errorf("Expected bursty refactoring, got %+v", patterns)
+	}
+	if hasPattern(patterns, PatternSteady) {
+		t.Errorf("Expected no steady pattern, got %+v", patterns)
+	}
+
+	// Raising the thresholds turns the patterns off
+	analyzer.SetPatternThresholds(PatternThresholds{BurstyRate: 100, RefactoringRatio: 1})
+	if patterns := analyzer.detectActivityPatterns(burst, analyzer.PatternThresholds()); len(patterns) != 0 {
+		t.Errorf("Expected no patterns over the raised thresholds, got %+v", patterns)
+	}
+	if thresholds := analyzer.PatternThresholds(); thresholds.SteadyMinDays != DefaultPatternThresholds().SteadyMinDays {
+		t.Errorf("Expected zero thresholds to keep their defaults, got %+v", thresholds)
+	}
+}
+
+func TestHotSpots(t *testing.T) {
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	var ops []*operations.Operation
+	add := func(author operations.AuthorID, document string, count int) {
+		for i := 0; i < count; i++ {
+			ops = append(ops, patternOp(author, document, "line", start.Add(time.Duration(len(ops))*time.Second)))
+		}
+	}
+	add("alice", "busy.go", 8)
+	add("bob", "busy.go", 4)
+	add("alice", "warm.go", 6)
+	add("bob", "quiet.go", 2)
+
+	spots := hotSpots(ops, PatternThresholds{HotSpotMinOperations: 5, MaxHotSpots: 10})
+	if len(spots) != 2 || spots[0].DocumentID != "busy.go" || spots[1].DocumentID != "warm.go" {
+		t.Fatalf("Expected busy.go then warm.go, got %+v", spots)
+	}
+	if spots[0].Operations != 12 || spots[0].Share != 0.6 || spots[0].LinesAdded != 12 {
+		t.Errorf("Expected busy.go to have 12 operations and 60%% of them, got %+v", spots[0])
+	}
+	if len(spots[0].Authors) != 2 || spots[0].Authors[0] != "alice" || spots[0].Authors[1] != "bob" {
+		t.Errorf("Expected both authors on busy.go, got %v", spots[0].Authors)
+	}
+
+	if spots := hotSpots(ops, PatternThresholds{HotSpotMinOperations: 5, MaxHotSpots: 1}); len(spots) != 1 {
+		t.Errorf("Expected hot spots capped at 1, got %+v", spots)
+	}
+}
+
+func TestBuildActivityReport(t *testing.T) {
+	conversations := NewConversationManager()
+	analyzer := NewContextAnalyzer(operations.NewOperationDAG(), nil, conversations)
+	// Conversations are timestamped now, so the report covers the last week
+	if _, err := conversations.CreateConversation(addressing.StableAddress{}, "carol", "Retries", "Why three?"); err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	end := time.Now().UTC()
+	start := end.Add(-ReportPeriod)
+	period := TimePeriod{Start: start, End: end}
+
+	var ops []*operations.Operation
+	for day := 0; day < 4; day++ {
+		ops = append(ops, patternOp("alice", "retry.go", "fix the bug", start.Add(time.Duration(day)*24*time.Hour)))
+	}
+	ops = append(ops, patternOp("bob", "retry.go", "add a feature", start.Add(time.Hour)))
+
+	report := analyzer.BuildActivityReport(ops, period)
+	if report.Summary.TotalOperations != 5 || report.Summary.Conversations != 1 {
+		t.Errorf("Expected 5 operations and 1 conversation, got %+v", report.Summary)
+	}
+	if len(report.Authors) != 3 || report.Authors[0].AuthorID != "alice" || report.Authors[1].AuthorID != "bob" || report.Authors[2].AuthorID != "carol" {
+		t.Fatalf("Expected alice, bob then carol, got %+v", report.Authors)
+	}
+	if !hasPattern(report.Authors[0].Patterns, PatternSteady) || report.Authors[2].Summary.Conversations != 1 {
+		t.Errorf("Expected steady alice and carol's conversation, got %+v", report.Authors)
+	}
+
+	markdown := report.Markdown()
+	for _, want := range []string{"# Activity report", start.Format(time.DateOnly) + " to " + end.Format(time.DateOnly), "## alice", "- Patterns: steady", "## carol", "- 1 conversations"} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("Expected the Markdown report to contain %q, got:\n%s", want, markdown)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// ReportPeriod is the length of the period activity reports cover by default
+const ReportPeriod = 7 * 24 * time.Hour
+
+// ActivityReport summarizes what happened in a period, across the repository
+// and for each author who did something in it
+type ActivityReport struct {
+	Period   TimePeriod        `json:"period"`
+	Summary  ActivitySummary   `json:"summary"`
+	Patterns []ActivityPattern `json:"patterns"`
+	HotSpots []HotSpot         `json:"hot_spots"`
+	Authors  []AuthorReport    `json:"authors"`
+}
+
+type AuthorReport struct {
+	AuthorID operations.AuthorID `json:"author_id"`
+	Summary  ActivitySummary     `json:"summary"`
+	Patterns []ActivityPattern   `json:"patterns"`
+	HotSpots []HotSpot           `json:"hot_spots"`
+}
+
+// BuildActivityReport reports on ops, which should all fall in period.
+// Conversations count the threads with messages in the period, in total and
+// by each author. Authors are ordered by how much they did, most first.
+func (ca *ContextAnalyzer) BuildActivityReport(ops []*operations.Operation, period TimePeriod) *ActivityReport {
+	thresholds := ca.PatternThresholds()
+
+	// Authors are reported under the ID their identity is known by
+	aliases := ca.conversationManager.AuthorAliases()
+	byAuthor := make(map[operations.AuthorID][]*operations.Operation)
+	for _, op := range ops {
+		author := aliases.Resolve(op.Author)
+		byAuthor[author] = append(byAuthor[author], op)
+	}
+	threads, authorThreads := ca.conversationActivity(period, aliases)
+
+	report := &ActivityReport{
+		Period:   period,
+		Summary:  ca.buildActivitySummary(ops),
+		Patterns: nonNilPatterns(ca.detectActivityPatterns(ops, thresholds)),
+		HotSpots: hotSpots(ops, thresholds),
+		Authors:  []AuthorReport{},
+	}
+	report.Summary.Conversations = threads
+
+	for author := range authorThreads {
+		if _, active := byAuthor[author]; !active {
+			byAuthor[author] = nil
+		}
+	}
+	for author, authorOps := range byAuthor {
+		summary := ca.buildActivitySummary(authorOps)
+		summary.Conversations = authorThreads[author]
+		report.Authors = append(report.Authors, AuthorReport{
+			AuthorID: author,
+			Summary:  summary,
+			Patterns: nonNilPatterns(ca.detectActivityPatterns(authorOps, thresholds)),
+			HotSpots: hotSpots(authorOps, thresholds),
+		})
+	}
+	sort.Slice(report.Authors, func(i, j int) bool {
+		a, b := report.Authors[i], report.Authors[j]
+		if a.Summary.TotalOperations != b.Summary.TotalOperations {
+			return a.Summary.TotalOperations > b.Summary.TotalOperations
+		}
+		if a.Summary.Conversations != b.Summary.Conversations {
+			return a.Summary.Conversations > b.Summary.Conversations
+		}
+		return a.AuthorID < b.AuthorID
+	})
+
+	return report
+}
+
+// conversationActivity counts the threads with messages in period, and for
+// each author the threads they wrote in
+func (ca *ContextAnalyzer) conversationActivity(period TimePeriod, aliases AuthorAliases) (int, map[operations.AuthorID]int) {
+	total := 0
+	byAuthor := make(map[operations.AuthorID]int)
+	for _, thread := range ca.conversationManager.Snapshot() {
+		authors := make(map[operations.AuthorID]bool)
+		for _, message := range thread.Messages {
+			if !message.Timestamp.Before(period.Start) && !message.Timestamp.After(period.End) {
+				authors[aliases.Resolve(message.AuthorID)] = true
+			}
+		}
+		if len(authors) > 0 {
+			total++
+		}
+		for author := range authors {
+			byAuthor[author]++
+		}
+	}
+	return total, byAuthor
+}
+
+func nonNilPatterns(patterns []ActivityPattern) []ActivityPattern {
+	if patterns == nil {
+		return []ActivityPattern{}
+	}
+	return patterns
+}
+
+// Markdown renders the report for people, a section for the repository then one per author
+func (r *ActivityReport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Activity report\n\n%s to %s\n\n", r.Period.Start.UTC().Format(time.DateOnly), r.Period.End.UTC().Format(time.DateOnly))
+
+	b.WriteString("## Repository\n\n")
+	writeSummary(&b, r.Summary, r.Patterns)
+	writeHotSpots(&b, r.HotSpots, true)
+
+	for _, author := range r.Authors {
+		fmt.Fprintf(&b, "## %s\n\n", author.AuthorID)
+		writeSummary(&b, author.Summary, author.Patterns)
+		writeHotSpots(&b, author.HotSpots, false)
+	}
+	return b.String()
+}
+
+func writeSummary(b *strings.Builder, summary ActivitySummary, patterns []ActivityPattern) {
+	fmt.Fprintf(b, "- %d operations in %d documents", summary.TotalOperations, len(summary.DocumentsModified))
+	if types := countList(summary.OperationTypes); types != "" {
+		fmt.Fprintf(b, ": %s", types)
+	}
+	fmt.Fprintf(b, "\n- +%d / -%d lines\n", summary.LinesAdded, summary.LinesDeleted)
+	fmt.Fprintf(b, "- %d conversations\n", summary.Conversations)
+
+	intents := make(map[string]int)
+	for category, count := range summary.IntentTypes {
+		if category != IntentUnknown {
+			intents[string(category)] = count
+		}
+	}
+	if list := countList(intents); list != "" {
+		fmt.Fprintf(b, "- Intents: %s\n", list)
+	}
+
+	if len(patterns) > 0 {
+		names := make([]string, len(patterns))
+		for i, pattern := range patterns {
+			names[i] = string(pattern.Type)
+		}
+		fmt.Fprintf(b, "- Patterns: %s\n", strings.Join(names, ", "))
+	}
+	b.WriteString("\n")
+}
+
+func writeHotSpots(b *strings.Builder, spots []HotSpot, withAuthors bool) {
+	if len(spots) == 0 {
+		return
+	}
+
+	b.WriteString("### Hot spots\n\n")
+	if withAuthors {
+		b.WriteString("| Document | Operations | Share | Lines | Authors |\n|---|---|---|---|---|\n")
+	} else {
+		b.WriteString("| Document | Operations | Share | Lines |\n|---|---|---|---|\n")
+	}
+	for _, spot := range spots {
+		fmt.Fprintf(b, "| %s | %d | %.0f%% | +%d / -%d |", spot.DocumentID, spot.Operations, spot.Share*100, spot.LinesAdded, spot.LinesDeleted)
+		if withAuthors {
+			authors := make([]string, len(spot.Authors))
+			for i, author := range spot.Authors {
+				authors[i] = string(author)
+			}
+			fmt.Fprintf(b, " %s |", strings.Join(authors, ", "))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+}
+
+// countList renders counts like "12 insert, 3 delete", largest first
+func countList(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for key, count := range counts {
+		if count > 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%d %s", counts[key], key)
+	}
+	return strings.Join(parts, ", ")
+}
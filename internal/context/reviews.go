@@ -0,0 +1,273 @@
+package context
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// ReviewRequest is what makes a thread a review: the reviewers asked to
+// approve the code it is anchored to or request changes. Anyone who gives a
+// verdict joins them.
+type ReviewRequest struct {
+	Reviewers []operations.AuthorID `json:"reviewers"`
+}
+
+func (rr *ReviewRequest) addReviewers(reviewers ...operations.AuthorID) {
+	for _, reviewer := range reviewers {
+		if reviewer != "" && !slices.Contains(rr.Reviewers, reviewer) {
+			rr.Reviewers = append(rr.Reviewers, reviewer)
+		}
+	}
+}
+
+// IsVerdict reports whether messages of this type give a reviewer's verdict
+func (t MessageType) IsVerdict() bool {
+	return t == MsgApprove || t == MsgRequestChanges
+}
+
+type ReviewStatus string
+
+const (
+	// ReviewPending waits on a reviewer who hasn't approved
+	ReviewPending          ReviewStatus = "pending"
+	ReviewApproved         ReviewStatus = "approved"
+	ReviewChangesRequested ReviewStatus = "changes_requested"
+)
+
+func (s ReviewStatus) IsValid() bool {
+	switch s {
+	case ReviewPending, ReviewApproved, ReviewChangesRequested:
+		return true
+	}
+	return false
+}
+
+// Urgency orders statuses by how much they need someone's attention, so the
+// status of several reviews is the most urgent of them
+func (s ReviewStatus) Urgency() int {
+	switch s {
+	case ReviewChangesRequested:
+		return 3
+	case ReviewPending:
+		return 2
+	case ReviewApproved:
+		return 1
+	}
+	return 0
+}
+
+// ReviewerVerdict is where one reviewer stands on a review. Verdict is the
+// type of their latest approve or request_changes message, empty until they
+// give one.
+type ReviewerVerdict struct {
+	Reviewer  operations.AuthorID `json:"reviewer"`
+	Verdict   MessageType         `json:"verdict,omitempty"`
+	MessageID MessageID           `json:"message_id,omitempty"`
+	At        *time.Time          `json:"at,omitempty"`
+}
+
+// Review is a review thread summed up. Its status is changes_requested while
+// any reviewer's verdict asks for changes, approved once every reviewer has
+// approved, and pending otherwise. A review is identified by its thread's ID.
+type Review struct {
+	ID           ThreadID                 `json:"id"`
+	Title        string                   `json:"title"`
+	AuthorID     operations.AuthorID      `json:"author_id"`
+	Address      addressing.StableAddress `json:"address"`
+	ChangeSetID  ChangeSetID              `json:"changeset_id,omitempty"`
+	Branch       string                   `json:"branch,omitempty"`
+	Status       ReviewStatus             `json:"status"`
+	Reviewers    []ReviewerVerdict        `json:"reviewers"`
+	ThreadStatus ThreadStatus             `json:"thread_status"`
+	CreatedAt    time.Time                `json:"created_at"`
+	UpdatedAt    time.Time                `json:"updated_at"`
+}
+
+// ReviewFilter selects reviews. Empty fields match every review.
+type ReviewFilter struct {
+	ChangeSet ChangeSetID
+	Author    operations.AuthorID
+	Reviewer  operations.AuthorID
+	Status    ReviewStatus
+	// Open leaves out reviews whose threads are resolved or archived
+	Open bool
+	// Viewer leaves out reviews in threads it may not read
+	Viewer Viewer
+}
+
+// matches compares authors and reviewers by identity
+func (f ReviewFilter) matches(review *Review, aliases AuthorAliases) bool {
+	if f.ChangeSet != "" && review.ChangeSetID != f.ChangeSet {
+		return false
+	}
+	if f.Author != "" && !aliases.Same(review.AuthorID, f.Author) {
+		return false
+	}
+	if f.Reviewer != "" && !slices.ContainsFunc(review.Reviewers, func(v ReviewerVerdict) bool { return aliases.Same(v.Reviewer, f.Reviewer) }) {
+		return false
+	}
+	if f.Status != "" && review.Status != f.Status {
+		return false
+	}
+	if f.Open && review.ThreadStatus != StatusOpen && review.ThreadStatus != StatusPinned {
+		return false
+	}
+	return true
+}
+
+// CreateReview asks reviewers to review a change set, a range of code, or
+// both. Its opening message is a review message.
+func (cm *ConversationManager) CreateReview(changeSet ChangeSetID, anchorAddr addressing.StableAddress, authorID operations.AuthorID, title, content string, reviewers []operations.AuthorID, opts ...ThreadOption) (*Review, error) {
+	thread := NewConversationThread(anchorAddr, authorID, title, content)
+	thread.ChangeSetID = changeSet
+	thread.Messages[0].MessageType = MsgReview
+	thread.Review = &ReviewRequest{Reviewers: []operations.AuthorID{}}
+	thread.Review.addReviewers(reviewers...)
+
+	if _, err := cm.addConversation(thread, opts); err != nil {
+		return nil, err
+	}
+
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return reviewOf(thread), nil
+}
+
+func (cm *ConversationManager) GetReview(id ThreadID) (*Review, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	thread, exists := cm.conversations[id]
+	if !exists || thread.Review == nil {
+		return nil, ErrReviewNotFound
+	}
+	return reviewOf(thread), nil
+}
+
+// RequestReviewers asks more reviewers for their verdict on review id
+func (cm *ConversationManager) RequestReviewers(id ThreadID, reviewers ...operations.AuthorID) (*Review, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	thread, exists := cm.conversations[id]
+	if !exists || thread.Review == nil {
+		return nil, ErrReviewNotFound
+	}
+	thread.Review.addReviewers(reviewers...)
+	for _, reviewer := range reviewers {
+		thread.addParticipant(reviewer)
+	}
+	cm.updateAuthorIndex(thread)
+	thread.UpdatedAt = time.Now()
+	return reviewOf(thread), nil
+}
+
+// SubmitVerdict records a reviewer approving review id or requesting changes
+func (cm *ConversationManager) SubmitVerdict(id ThreadID, reviewer operations.AuthorID, verdict MessageType, content string) (*Review, error) {
+	if !verdict.IsVerdict() {
+		return nil, fmt.Errorf("%w: %s is not a verdict", ErrInvalidMessageType, verdict)
+	}
+	if _, err := cm.GetReview(id); err != nil {
+		return nil, err
+	}
+	if _, err := cm.AddMessage(id, reviewer, content, verdict); err != nil {
+		return nil, err
+	}
+	return cm.GetReview(id)
+}
+
+// Reviews returns the reviews matching filter, most recently updated first
+func (cm *ConversationManager) Reviews(filter ReviewFilter) []*Review {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	reviews := []*Review{}
+	for _, thread := range cm.conversations {
+		if thread.Review == nil || !filter.Viewer.CanView(thread) {
+			continue
+		}
+		if review := reviewOf(thread); filter.matches(review, cm.aliases) {
+			reviews = append(reviews, review)
+		}
+	}
+
+	sort.Slice(reviews, func(i, j int) bool {
+		if !reviews[i].UpdatedAt.Equal(reviews[j].UpdatedAt) {
+			return reviews[i].UpdatedAt.After(reviews[j].UpdatedAt)
+		}
+		return reviews[i].ID < reviews[j].ID
+	})
+	return reviews
+}
+
+// ReviewStatusByChangeSet sums up the open reviews of each change set as the
+// most urgent of their statuses
+func (cm *ConversationManager) ReviewStatusByChangeSet() map[ChangeSetID]ReviewStatus {
+	statuses := make(map[ChangeSetID]ReviewStatus)
+	for _, review := range cm.Reviews(ReviewFilter{Open: true}) {
+		if review.ChangeSetID != "" && review.Status.Urgency() > statuses[review.ChangeSetID].Urgency() {
+			statuses[review.ChangeSetID] = review.Status
+		}
+	}
+	return statuses
+}
+
+// ReviewStatus is where the review in a thread stands, empty when the thread
+// isn't a review
+func (ct *ConversationThread) ReviewStatus() ReviewStatus {
+	if ct.Review == nil {
+		return ""
+	}
+	return reviewOf(ct).Status
+}
+
+// reviewOf sums up a review thread. Callers hold the mutex.
+func reviewOf(thread *ConversationThread) *Review {
+	review := &Review{
+		ID:           thread.ID,
+		Title:        thread.Title,
+		Address:      thread.AnchorAddress,
+		ChangeSetID:  thread.ChangeSetID,
+		Branch:       thread.Branch,
+		Reviewers:    make([]ReviewerVerdict, 0, len(thread.Review.Reviewers)),
+		ThreadStatus: thread.Status,
+		CreatedAt:    thread.CreatedAt,
+		UpdatedAt:    thread.UpdatedAt,
+	}
+	if len(thread.Messages) > 0 {
+		review.AuthorID = thread.Messages[0].AuthorID
+	}
+
+	latest := make(map[operations.AuthorID]*Message)
+	for i := range thread.Messages {
+		if message := &thread.Messages[i]; message.MessageType.IsVerdict() {
+			latest[message.AuthorID] = message
+		}
+	}
+
+	review.Status = ReviewApproved
+	for _, reviewer := range thread.Review.Reviewers {
+		verdict := ReviewerVerdict{Reviewer: reviewer}
+		if message := latest[reviewer]; message != nil {
+			at := message.Timestamp
+			verdict.Verdict, verdict.MessageID, verdict.At = message.MessageType, message.ID, &at
+		}
+		review.Reviewers = append(review.Reviewers, verdict)
+
+		switch {
+		case verdict.Verdict == MsgRequestChanges:
+			review.Status = ReviewChangesRequested
+		case verdict.Verdict != MsgApprove && review.Status == ReviewApproved:
+			review.Status = ReviewPending
+		}
+	}
+	if len(review.Reviewers) == 0 {
+		review.Status = ReviewPending
+	}
+	return review
+}
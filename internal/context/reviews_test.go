@@ -0,0 +1,79 @@
+package context
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestConversationManager_Reviews(t *testing.T) {
+	manager := NewConversationManager()
+	changeSet := ChangeSetID("cs_op1")
+
+	review, err := manager.CreateReview(changeSet, addressing.StableAddress{}, "alice", "Retry backoff", "Please review", []operations.AuthorID{"bob", "carol", "bob"})
+	if err != nil {
+		t.Fatalf("Failed to create review: %v", err)
+	}
+	if review.Status != ReviewPending || len(review.Reviewers) != 2 || review.AuthorID != "alice" || review.ChangeSetID != changeSet {
+		t.Fatalf("Expected a pending review with two reviewers, got %+v", review)
+	}
+	thread, _ := manager.GetConversation(review.ID)
+	if thread.Messages[0].MessageType != MsgReview {
+		t.Errorf("Expected the review to open with a review message, got %s", thread.Messages[0].MessageType)
+	}
+
+	if review, err = manager.SubmitVerdict(review.ID, "bob", MsgApprove, "LGTM"); err != nil {
+		t.Fatalf("Failed to approve review: %v", err)
+	}
+	if review.Status != ReviewPending || review.Reviewers[0].Verdict != MsgApprove || review.Reviewers[0].At == nil {
+		t.Errorf("Expected bob's approval with carol still pending, got %+v", review)
+	}
+
+	if review, err = manager.SubmitVerdict(review.ID, "carol", MsgRequestChanges, "Cap the delay"); err != nil {
+		t.Fatalf("Failed to request changes: %v", err)
+	}
+	if review.Status != ReviewChangesRequested {
+		t.Errorf("Expected changes requested, got %s", review.Status)
+	}
+	if statuses := manager.ReviewStatusByChangeSet(); statuses[changeSet] != ReviewChangesRequested {
+		t.Errorf("Expected the change set's status to follow its review, got %v", statuses)
+	}
+
+	// A later verdict replaces an earlier one
+	if _, err := manager.AddMessage(review.ID, "carol", "Fixed, thanks", MsgApprove); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	if review, _ = manager.GetReview(review.ID); review.Status != ReviewApproved {
+		t.Errorf("Expected the review approved, got %+v", review)
+	}
+
+	if review, err = manager.RequestReviewers(review.ID, "dave"); err != nil {
+		t.Fatalf("Failed to request reviewers: %v", err)
+	}
+	if review.Status != ReviewPending || len(review.Reviewers) != 3 {
+		t.Errorf("Expected a new reviewer to make the review pending, got %+v", review)
+	}
+	if reviews := manager.Reviews(ReviewFilter{Reviewer: "dave"}); len(reviews) != 1 || reviews[0].ID != review.ID {
+		t.Errorf("Expected the review listed for dave, got %+v", reviews)
+	}
+	if reviews := manager.Reviews(ReviewFilter{Status: ReviewApproved}); len(reviews) != 0 {
+		t.Errorf("Expected no approved reviews, got %+v", reviews)
+	}
+
+	plain, _ := manager.CreateConversation(addressing.StableAddress{}, "alice", "Question", "Why?")
+	if _, err := manager.AddMessage(plain.ID, "bob", "Approved", MsgApprove); !errors.Is(err, ErrNotReview) {
+		t.Errorf("Expected verdicts refused outside reviews, got %v", err)
+	}
+	if _, err := manager.GetReview(plain.ID); !errors.Is(err, ErrReviewNotFound) {
+		t.Errorf("Expected a plain conversation not to be a review, got %v", err)
+	}
+
+	copies := manager.Snapshot()
+	restored := NewConversationManager()
+	restored.Restore(copies)
+	if review, err := restored.GetReview(review.ID); err != nil || len(review.Reviewers) != 3 {
+		t.Errorf("Expected the review to survive a snapshot, got %+v, %v", review, err)
+	}
+}
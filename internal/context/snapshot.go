@@ -0,0 +1,41 @@
+package context
+
+import (
+	"sort"
+)
+
+// Snapshot returns copies of every conversation ordered by creation time, for
+// persisting or exporting the manager's state
+func (cm *ConversationManager) Snapshot() []*ConversationThread {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	threads := make([]*ConversationThread, 0, len(cm.conversations))
+	for _, thread := range cm.conversations {
+		threads = append(threads, cm.copyThread(thread))
+	}
+
+	sort.Slice(threads, func(i, j int) bool {
+		return threads[i].CreatedAt.Before(threads[j].CreatedAt)
+	})
+	return threads
+}
+
+// Restore loads conversations from a snapshot. Threads already present with
+// the same ID are replaced, so restoring the same snapshot twice is harmless.
+func (cm *ConversationManager) Restore(threads []*ConversationThread) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	for _, thread := range threads {
+		if existing, exists := cm.conversations[thread.ID]; exists {
+			cm.unindexConversation(existing)
+		}
+
+		// The restored thread owns its messages from here on
+		restored := cm.copyThread(thread)
+		restored.Messages = append([]Message(nil), thread.Messages...)
+		cm.conversations[restored.ID] = restored
+		cm.indexConversation(restored)
+	}
+}
@@ -0,0 +1,37 @@
+package context
+
+import "time"
+
+// ThreadSummary is a short account of a thread, made by a summarizer when the
+// thread is resolved or on request. A summary is never changed in place; a
+// new one replaces it.
+type ThreadSummary struct {
+	Text string `json:"text"`
+	// Summarizer names what made the summary and how it was configured
+	Summarizer string `json:"summarizer"`
+	// Messages is how many of the thread's messages the summary covers, so a
+	// summary older than the latest messages can be told apart
+	Messages  int       `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SummaryStale reports whether the thread has messages its summary doesn't cover
+func (ct *ConversationThread) SummaryStale() bool {
+	return ct.Summary != nil && ct.Summary.Messages < len(ct.Messages)
+}
+
+// SetSummary replaces a thread's summary and returns the updated thread. The
+// thread counts as updated, so it is indexed for search again.
+func (cm *ConversationManager) SetSummary(threadID ThreadID, summary ThreadSummary) (*ConversationThread, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	thread, exists := cm.conversations[threadID]
+	if !exists {
+		return nil, ErrConversationNotFound
+	}
+
+	thread.Summary = &summary
+	thread.UpdatedAt = time.Now()
+	return cm.copyThread(thread), nil
+}
@@ -0,0 +1,252 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxTagLength is the longest tag or label accepted, in bytes
+const MaxTagLength = 64
+
+// TagCount is how many conversations carry a tag or label
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ConversationFilter selects conversations. Empty fields match every thread.
+type ConversationFilter struct {
+	// Tags and Labels must all be on a thread for it to match
+	Tags   []string
+	Labels []string
+	Status ThreadStatus
+	// ChangeSet matches threads about that change set
+	ChangeSet ChangeSetID
+	// Branch matches threads about that branch
+	Branch string
+	// Issue matches threads linked to the issue with that key
+	Issue string
+	// Viewer leaves out threads it may not read
+	Viewer Viewer
+}
+
+// NormalizeTag lowercases and trims a tag or label. Tags can't be empty or
+// contain whitespace or commas.
+func NormalizeTag(tag string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(tag))
+	if normalized == "" {
+		return "", fmt.Errorf("%w: tag is empty", ErrInvalidTag)
+	}
+	if len(normalized) > MaxTagLength {
+		return "", fmt.Errorf("%w: %q is longer than %d bytes", ErrInvalidTag, tag, MaxTagLength)
+	}
+	if strings.ContainsFunc(normalized, func(r rune) bool { return unicode.IsSpace(r) || r == ',' }) {
+		return "", fmt.Errorf("%w: %q contains whitespace or a comma", ErrInvalidTag, tag)
+	}
+	return normalized, nil
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		n, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, n)
+	}
+	return normalized, nil
+}
+
+// addTags appends the tags missing from existing, reporting whether any were
+func addTags(existing, tags []string) ([]string, bool) {
+	changed := false
+	for _, tag := range tags {
+		if !containsTag(existing, tag) {
+			existing = append(existing, tag)
+			changed = true
+		}
+	}
+	return existing, changed
+}
+
+func removeTags(existing, tags []string) ([]string, bool) {
+	kept := existing[:0:0]
+	for _, tag := range existing {
+		if !containsTag(tags, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	return kept, len(kept) != len(existing)
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (ct *ConversationThread) AddTags(tags ...string) {
+	var changed bool
+	if ct.Tags, changed = addTags(ct.Tags, tags); changed {
+		ct.UpdatedAt = time.Now()
+	}
+}
+
+func (ct *ConversationThread) RemoveTags(tags ...string) {
+	var changed bool
+	if ct.Tags, changed = removeTags(ct.Tags, tags); changed {
+		ct.UpdatedAt = time.Now()
+	}
+}
+
+func (ct *ConversationThread) AddLabels(labels ...string) {
+	var changed bool
+	if ct.Metadata.Labels, changed = addTags(ct.Metadata.Labels, labels); changed {
+		ct.UpdatedAt = time.Now()
+	}
+}
+
+func (ct *ConversationThread) RemoveLabels(labels ...string) {
+	var changed bool
+	if ct.Metadata.Labels, changed = removeTags(ct.Metadata.Labels, labels); changed {
+		ct.UpdatedAt = time.Now()
+	}
+}
+
+// Matches reports whether thread passes every condition of the filter
+func (f ConversationFilter) Matches(thread *ConversationThread) bool {
+	if !f.Viewer.CanView(thread) {
+		return false
+	}
+	if f.Status != "" && thread.Status != f.Status {
+		return false
+	}
+	if f.ChangeSet != "" && thread.ChangeSetID != f.ChangeSet {
+		return false
+	}
+	if f.Branch != "" && thread.Branch != f.Branch {
+		return false
+	}
+	if f.Issue != "" && thread.Metadata.LinkedIssue != f.Issue {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !containsTag(thread.Tags, tag) {
+			return false
+		}
+	}
+	for _, label := range f.Labels {
+		if !containsTag(thread.Metadata.Labels, label) {
+			return false
+		}
+	}
+	return true
+}
+
+// AddTags tags a conversation, ignoring tags it already has, and returns the updated thread
+func (cm *ConversationManager) AddTags(threadID ThreadID, tags ...string) (*ConversationThread, error) {
+	return cm.updateTags(threadID, tags, (*ConversationThread).AddTags)
+}
+
+func (cm *ConversationManager) RemoveTags(threadID ThreadID, tags ...string) (*ConversationThread, error) {
+	return cm.updateTags(threadID, tags, (*ConversationThread).RemoveTags)
+}
+
+// AddLabels labels a conversation, ignoring labels it already has, and returns the updated thread
+func (cm *ConversationManager) AddLabels(threadID ThreadID, labels ...string) (*ConversationThread, error) {
+	return cm.updateTags(threadID, labels, (*ConversationThread).AddLabels)
+}
+
+func (cm *ConversationManager) RemoveLabels(threadID ThreadID, labels ...string) (*ConversationThread, error) {
+	return cm.updateTags(threadID, labels, (*ConversationThread).RemoveLabels)
+}
+
+func (cm *ConversationManager) updateTags(threadID ThreadID, tags []string, update func(*ConversationThread, ...string)) (*ConversationThread, error) {
+	normalized, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	thread, exists := cm.conversations[threadID]
+	if !exists {
+		return nil, ErrConversationNotFound
+	}
+
+	update(thread, normalized...)
+	return cm.copyThread(thread), nil
+}
+
+// ListConversations returns the conversations matching filter, most recently updated first
+func (cm *ConversationManager) ListConversations(filter ConversationFilter) ([]*ConversationThread, error) {
+	var err error
+	if filter.Tags, err = normalizeTags(filter.Tags); err != nil {
+		return nil, err
+	}
+	if filter.Labels, err = normalizeTags(filter.Labels); err != nil {
+		return nil, err
+	}
+
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	threads := []*ConversationThread{}
+	for _, thread := range cm.conversations {
+		if filter.Matches(thread) {
+			threads = append(threads, cm.copyThread(thread))
+		}
+	}
+
+	sort.Slice(threads, func(i, j int) bool {
+		if !threads[i].UpdatedAt.Equal(threads[j].UpdatedAt) {
+			return threads[i].UpdatedAt.After(threads[j].UpdatedAt)
+		}
+		return threads[i].ID < threads[j].ID
+	})
+	return threads, nil
+}
+
+// TagCounts returns every tag in use with how many conversations carry it,
+// most used first
+func (cm *ConversationManager) TagCounts() []TagCount {
+	return cm.countTags(func(thread *ConversationThread) []string { return thread.Tags })
+}
+
+// LabelCounts returns every label in use with how many conversations carry
+// it, most used first
+func (cm *ConversationManager) LabelCounts() []TagCount {
+	return cm.countTags(func(thread *ConversationThread) []string { return thread.Metadata.Labels })
+}
+
+func (cm *ConversationManager) countTags(tagsOf func(*ConversationThread) []string) []TagCount {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	counts := make(map[string]int)
+	for _, thread := range cm.conversations {
+		for _, tag := range tagsOf(thread) {
+			counts[tag]++
+		}
+	}
+
+	result := make([]TagCount, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, TagCount{Name: name, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
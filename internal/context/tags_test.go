@@ -0,0 +1,99 @@
+package context
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	if tag, err := NormalizeTag("  Needs-Review "); err != nil || tag != "needs-review" {
+		t.Errorf("Expected needs-review, got %q, %v", tag, err)
+	}
+
+	for _, bad := range []string{"", "   ", "two words", "a,b", strings.Repeat("x", MaxTagLength+1)} {
+		if _, err := NormalizeTag(bad); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("Expected ErrInvalidTag for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestConversationManager_Tags(t *testing.T) {
+	manager := NewConversationManager()
+
+	opID := operations.NewOperationID([]byte("test-op"))
+	pos := operations.NewLogootPosition([]operations.PositionSegment{
+		{Value: big.NewInt(1), AuthorID: "author1"},
+	})
+	posRange := addressing.PositionRange{Start: pos, End: pos}
+	anchorAddr := addressing.NewStableAddress(addressing.RepositoryID("test-repo"), opID, posRange)
+
+	thread1, _ := manager.CreateConversation(anchorAddr, "author1", "Discussion 1", "Message 1")
+	thread2, _ := manager.CreateConversation(anchorAddr, "author2", "Discussion 2", "Message 2")
+	manager.CreateConversation(anchorAddr, "author3", "Discussion 3", "Message 3")
+
+	tagged, err := manager.AddTags(thread1.ID, "Bug", "perf", "bug")
+	if err != nil {
+		t.Fatalf("Failed to add tags: %v", err)
+	}
+	if len(tagged.Tags) != 2 || tagged.Tags[0] != "bug" || tagged.Tags[1] != "perf" {
+		t.Errorf("Expected tags [bug perf], got %v", tagged.Tags)
+	}
+	if _, err := manager.AddTags(thread2.ID, "bug"); err != nil {
+		t.Fatalf("Failed to add tags: %v", err)
+	}
+	if _, err := manager.AddLabels(thread2.ID, "team:core"); err != nil {
+		t.Fatalf("Failed to add labels: %v", err)
+	}
+
+	if _, err := manager.AddTags("missing", "bug"); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("Expected ErrConversationNotFound, got %v", err)
+	}
+	if _, err := manager.AddTags(thread1.ID, "not valid"); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("Expected ErrInvalidTag, got %v", err)
+	}
+
+	// Returned threads are copies
+	tagged.Tags[0] = "changed"
+	if got, _ := manager.GetConversation(thread1.ID); got.Tags[0] != "bug" {
+		t.Errorf("Expected stored tags to be unaffected, got %v", got.Tags)
+	}
+
+	threads, err := manager.ListConversations(ConversationFilter{Tags: []string{"BUG"}})
+	if err != nil {
+		t.Fatalf("Failed to list conversations: %v", err)
+	}
+	if len(threads) != 2 {
+		t.Errorf("Expected 2 threads tagged bug, got %d", len(threads))
+	}
+
+	threads, _ = manager.ListConversations(ConversationFilter{Tags: []string{"bug"}, Labels: []string{"team:core"}})
+	if len(threads) != 1 || threads[0].ID != thread2.ID {
+		t.Errorf("Expected only thread 2, got %v", threads)
+	}
+
+	threads, _ = manager.ListConversations(ConversationFilter{})
+	if len(threads) != 3 {
+		t.Errorf("Expected every thread with an empty filter, got %d", len(threads))
+	}
+
+	counts := manager.TagCounts()
+	if len(counts) != 2 || counts[0] != (TagCount{Name: "bug", Count: 2}) || counts[1] != (TagCount{Name: "perf", Count: 1}) {
+		t.Errorf("Expected bug then perf, got %+v", counts)
+	}
+
+	untagged, err := manager.RemoveTags(thread1.ID, "bug", "unknown")
+	if err != nil {
+		t.Fatalf("Failed to remove tags: %v", err)
+	}
+	if len(untagged.Tags) != 1 || untagged.Tags[0] != "perf" {
+		t.Errorf("Expected tags [perf], got %v", untagged.Tags)
+	}
+	if labels := manager.LabelCounts(); len(labels) != 1 || labels[0].Name != "team:core" {
+		t.Errorf("Expected the team:core label, got %+v", labels)
+	}
+}
@@ -0,0 +1,174 @@
+package context
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// IntentDefinition is one category of an intent taxonomy. An operation whose
+// content or explicit intent uses one of its keywords, or its name, counts
+// toward it.
+type IntentDefinition struct {
+	Name        IntentCategory `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Keywords    []string       `json:"keywords"`
+	// Color is how tools show the category, as "#rrggbb"
+	Color string `json:"color,omitempty"`
+}
+
+// IntentTaxonomy is the set of categories operations are classified into. A
+// strict taxonomy only accepts explicit intents that name a category or one of
+// its keywords.
+type IntentTaxonomy struct {
+	Categories []IntentDefinition `json:"categories"`
+	Strict     bool               `json:"strict,omitempty"`
+}
+
+var intentColor = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// DefaultIntentTaxonomy is the taxonomy repositories start with
+func DefaultIntentTaxonomy() IntentTaxonomy {
+	return IntentTaxonomy{Categories: []IntentDefinition{
+		{Name: IntentFeature, Description: "New behaviour", Keywords: []string{"add", "new", "implement"}, Color: "#2da44e"},
+		{Name: IntentBugfix, Description: "Fixing a defect", Keywords: []string{"fix", "bug", "error", "issue"}, Color: "#cf222e"},
+		{Name: IntentRefactor, Description: "Restructuring without changing behaviour", Keywords: []string{"clean", "optimize", "improve"}, Color: "#8250df"},
+		{Name: IntentCleanup, Description: "Tidying up and paying down debt", Keywords: []string{"todo", "fixme", "hack", "temporary"}, Color: "#9a6700"},
+		{Name: IntentDoc, Description: "Comments and documentation", Keywords: []string{"doc", "comment", "readme"}, Color: "#0969da"},
+		{Name: IntentTest, Description: "Tests", Keywords: []string{"spec", "unit", "integration"}, Color: "#1a7f37"},
+	}}
+}
+
+// Normalize validates the taxonomy and returns it with names, keywords and
+// colors lowercased and trimmed. Names and keywords follow the rules for tags,
+// and a keyword may only belong to one category.
+func (t IntentTaxonomy) Normalize() (IntentTaxonomy, error) {
+	if len(t.Categories) == 0 {
+		return IntentTaxonomy{}, fmt.Errorf("%w: at least one category is required", ErrInvalidTaxonomy)
+	}
+
+	normalized := IntentTaxonomy{Categories: make([]IntentDefinition, 0, len(t.Categories)), Strict: t.Strict}
+	owners := make(map[string]IntentCategory)
+	claim := func(word string, category IntentCategory) error {
+		if owner, taken := owners[word]; taken && owner != category {
+			return fmt.Errorf("%w: %q belongs to both %s and %s", ErrInvalidTaxonomy, word, owner, category)
+		}
+		owners[word] = category
+		return nil
+	}
+
+	for _, definition := range t.Categories {
+		name, err := NormalizeTag(string(definition.Name))
+		if err != nil {
+			return IntentTaxonomy{}, fmt.Errorf("%w: category name: %v", ErrInvalidTaxonomy, err)
+		}
+		category := IntentCategory(name)
+		if category == IntentUnknown {
+			return IntentTaxonomy{}, fmt.Errorf("%w: %q is reserved for operations that match no category", ErrInvalidTaxonomy, name)
+		}
+		if slices.ContainsFunc(normalized.Categories, func(d IntentDefinition) bool { return d.Name == category }) {
+			return IntentTaxonomy{}, fmt.Errorf("%w: %q is defined twice", ErrInvalidTaxonomy, name)
+		}
+		if err := claim(name, category); err != nil {
+			return IntentTaxonomy{}, err
+		}
+
+		keywords, err := normalizeTags(definition.Keywords)
+		if err != nil {
+			return IntentTaxonomy{}, fmt.Errorf("%w: keywords of %s: %v", ErrInvalidTaxonomy, name, err)
+		}
+		keywords = removeDuplicates(keywords)
+		for _, keyword := range keywords {
+			if err := claim(keyword, category); err != nil {
+				return IntentTaxonomy{}, err
+			}
+		}
+
+		color := strings.ToLower(strings.TrimSpace(definition.Color))
+		if color != "" && !intentColor.MatchString(color) {
+			return IntentTaxonomy{}, fmt.Errorf("%w: color of %s must look like #1a7f37, got %q", ErrInvalidTaxonomy, name, definition.Color)
+		}
+
+		normalized.Categories = append(normalized.Categories, IntentDefinition{
+			Name:        category,
+			Description: strings.TrimSpace(definition.Description),
+			Keywords:    append([]string{}, keywords...),
+			Color:       color,
+		})
+	}
+	return normalized, nil
+}
+
+// Category returns the category intent names by its name or a keyword, or
+// IntentUnknown
+func (t IntentTaxonomy) Category(intent string) IntentCategory {
+	word := strings.ToLower(strings.TrimSpace(intent))
+	for _, definition := range t.Categories {
+		if string(definition.Name) == word || slices.Contains(definition.Keywords, word) {
+			return definition.Name
+		}
+	}
+	return IntentUnknown
+}
+
+// ValidateIntent reports ErrUnknownIntent for an explicit intent a strict
+// taxonomy doesn't know. Any intent is accepted otherwise.
+func (t IntentTaxonomy) ValidateIntent(intent string) error {
+	if !t.Strict || intent == "" || t.Category(intent) != IntentUnknown {
+		return nil
+	}
+	names := make([]string, len(t.Categories))
+	for i, definition := range t.Categories {
+		names[i] = string(definition.Name)
+	}
+	return fmt.Errorf("%w: %q is not one of %s or their keywords", ErrUnknownIntent, intent, strings.Join(names, ", "))
+}
+
+// intentClassifier is a taxonomy indexed by the words that count toward each
+// category
+type intentClassifier struct {
+	taxonomy IntentTaxonomy
+	words    map[string]IntentCategory
+}
+
+func newIntentClassifier(taxonomy IntentTaxonomy) *intentClassifier {
+	classifier := &intentClassifier{taxonomy: taxonomy, words: make(map[string]IntentCategory)}
+	for _, definition := range taxonomy.Categories {
+		classifier.words[string(definition.Name)] = definition.Name
+		for _, keyword := range definition.Keywords {
+			classifier.words[keyword] = definition.Name
+		}
+	}
+	return classifier
+}
+
+// SetIntentTaxonomy replaces the categories operations are classified into
+func (ca *ContextAnalyzer) SetIntentTaxonomy(taxonomy IntentTaxonomy) error {
+	normalized, err := taxonomy.Normalize()
+	if err != nil {
+		return err
+	}
+
+	ca.intentMutex.Lock()
+	defer ca.intentMutex.Unlock()
+
+	ca.intents = newIntentClassifier(normalized)
+	return nil
+}
+
+// IntentTaxonomy returns the categories operations are classified into
+func (ca *ContextAnalyzer) IntentTaxonomy() IntentTaxonomy {
+	taxonomy := ca.classifier().taxonomy
+	taxonomy.Categories = slices.Clone(taxonomy.Categories)
+	return taxonomy
+}
+
+// classifier has its own lock, since intents are analyzed while ca.mutex is
+// already held
+func (ca *ContextAnalyzer) classifier() *intentClassifier {
+	ca.intentMutex.RLock()
+	defer ca.intentMutex.RUnlock()
+
+	return ca.intents
+}
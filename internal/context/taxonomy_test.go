@@ -0,0 +1,77 @@
+package context
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestIntentTaxonomy_Normalize(t *testing.T) {
+	defaults := DefaultIntentTaxonomy()
+	if normalized, err := defaults.Normalize(); err != nil || !reflect.DeepEqual(normalized, defaults) {
+		t.Errorf("Expected the default taxonomy already normalized, got %v %+v", err, normalized)
+	}
+
+	normalized, err := IntentTaxonomy{Categories: []IntentDefinition{
+		{Name: " Security ", Keywords: []string{"CVE", "vuln", "cve"}, Color: "#B60205"},
+	}}.Normalize()
+	if err != nil {
+		t.Fatalf("Failed to normalize taxonomy: %v", err)
+	}
+	want := IntentDefinition{Name: "security", Keywords: []string{"cve", "vuln"}, Color: "#b60205"}
+	if !reflect.DeepEqual(normalized.Categories, []IntentDefinition{want}) {
+		t.Errorf("Expected %+v, got %+v", want, normalized.Categories)
+	}
+
+	for name, taxonomy := range map[string]IntentTaxonomy{
+		"empty":          {},
+		"unnamed":        {Categories: []IntentDefinition{{Name: ""}}},
+		"reserved":       {Categories: []IntentDefinition{{Name: IntentUnknown}}},
+		"duplicate":      {Categories: []IntentDefinition{{Name: "perf"}, {Name: "Perf"}}},
+		"shared keyword": {Categories: []IntentDefinition{{Name: "perf", Keywords: []string{"fast"}}, {Name: "speed", Keywords: []string{"fast"}}}},
+		"keyword a name": {Categories: []IntentDefinition{{Name: "perf"}, {Name: "speed", Keywords: []string{"perf"}}}},
+		"spaced keyword": {Categories: []IntentDefinition{{Name: "perf", Keywords: []string{"make faster"}}}},
+		"bad color":      {Categories: []IntentDefinition{{Name: "perf", Color: "red"}}},
+	} {
+		if _, err := taxonomy.Normalize(); !errors.Is(err, ErrInvalidTaxonomy) {
+			t.Errorf("%s: expected ErrInvalidTaxonomy, got %v", name, err)
+		}
+	}
+}
+
+func TestContextAnalyzer_IntentTaxonomy(t *testing.T) {
+	analyzer := NewContextAnalyzer(operations.NewOperationDAG(), nil, NewConversationManager())
+
+	op := &operations.Operation{Content: "patch the cve in the parser"}
+	if analysis, _ := analyzer.AnalyzeChangeIntent([]*operations.Operation{op}); analysis.Category != IntentUnknown {
+		t.Errorf("Expected no category under the default taxonomy, got %q", analysis.Category)
+	}
+
+	taxonomy := DefaultIntentTaxonomy()
+	taxonomy.Categories = append(taxonomy.Categories, IntentDefinition{Name: "security", Keywords: []string{"cve"}})
+	taxonomy.Strict = true
+	if err := analyzer.SetIntentTaxonomy(taxonomy); err != nil {
+		t.Fatalf("Failed to set taxonomy: %v", err)
+	}
+	analysis, err := analyzer.AnalyzeChangeIntent([]*operations.Operation{op})
+	if err != nil {
+		t.Fatalf("Failed to analyze intent: %v", err)
+	}
+	if analysis.Category != "security" || analysis.PrimaryIntent != "security" {
+		t.Errorf("Expected the configured category, got %+v", analysis)
+	}
+
+	current := analyzer.IntentTaxonomy()
+	if err := current.ValidateIntent("CVE"); err != nil {
+		t.Errorf("Expected a keyword accepted as an intent, got %v", err)
+	}
+	if err := current.ValidateIntent("because"); !errors.Is(err, ErrUnknownIntent) {
+		t.Errorf("Expected ErrUnknownIntent from a strict taxonomy, got %v", err)
+	}
+	current.Strict = false
+	if err := current.ValidateIntent("because"); err != nil {
+		t.Errorf("Expected any intent accepted when not strict, got %v", err)
+	}
+}
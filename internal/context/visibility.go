@@ -0,0 +1,138 @@
+package context
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// Visibility is who may read a conversation. Threads from before visibility
+// existed have none and are public.
+type Visibility string
+
+const (
+	VisibilityPublic Visibility = "public"
+	// VisibilityParticipants limits a thread to its participants and assignee
+	VisibilityParticipants Visibility = "participants"
+	// VisibilityPrivate limits a thread to the author who started it
+	VisibilityPrivate Visibility = "private"
+)
+
+func (v Visibility) IsValid() bool {
+	switch v {
+	case VisibilityPublic, VisibilityParticipants, VisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+func (ct *ConversationThread) IsPublic() bool {
+	return ct.Visibility == "" || ct.Visibility == VisibilityPublic
+}
+
+// VisibleTo reports whether author may read the thread
+func (ct *ConversationThread) VisibleTo(author operations.AuthorID) bool {
+	switch ct.Visibility {
+	case VisibilityParticipants:
+		return author != "" && (slices.Contains(ct.Participants, author) || ct.Metadata.Assignee == author)
+	case VisibilityPrivate:
+		return author != "" && len(ct.Participants) > 0 && ct.Participants[0] == author
+	default:
+		return true
+	}
+}
+
+// Redacted is what may be said about a thread to anyone: that it exists and
+// its status, without its title, anchor, participants or messages. Public
+// threads are returned whole.
+func (ct *ConversationThread) Redacted() *ConversationThread {
+	if ct.IsPublic() {
+		return ct
+	}
+	return &ConversationThread{
+		ID:           ct.ID,
+		ChangeSetID:  ct.ChangeSetID,
+		Branch:       ct.Branch,
+		Participants: []operations.AuthorID{},
+		Messages:     []Message{},
+		Status:       ct.Status,
+		Visibility:   ct.Visibility,
+		CreatedAt:    ct.CreatedAt,
+		UpdatedAt:    ct.UpdatedAt,
+	}
+}
+
+// Viewer is who conversations are read for. The zero Viewer reads every
+// conversation, as the server's own components do; a restricted Viewer only
+// reads the public ones and those its author may see.
+type Viewer struct {
+	AuthorID   operations.AuthorID
+	Restricted bool
+}
+
+// ViewerFor restricts reads to what author may see
+func ViewerFor(author operations.AuthorID) Viewer {
+	return Viewer{AuthorID: author, Restricted: true}
+}
+
+func (v Viewer) CanView(thread *ConversationThread) bool {
+	return !v.Restricted || thread.VisibleTo(v.AuthorID)
+}
+
+// ThreadOption sets up a conversation before it is created
+type ThreadOption func(*ConversationThread)
+
+// WithVisibility limits who may read a new thread. Participants are added
+// after its author, so they can read a participants-only thread before
+// writing in it.
+func WithVisibility(visibility Visibility, participants ...operations.AuthorID) ThreadOption {
+	return func(thread *ConversationThread) {
+		thread.Visibility = visibility
+		for _, participant := range participants {
+			if participant != "" && !slices.Contains(thread.Participants, participant) {
+				thread.Participants = append(thread.Participants, participant)
+			}
+		}
+	}
+}
+
+// WithBranch makes a new thread about work on a branch
+func WithBranch(branch string) ThreadOption {
+	return func(thread *ConversationThread) {
+		thread.Branch = branch
+	}
+}
+
+// ViewConversation returns a thread if viewer may read it. Threads it may not
+// read are reported as not found, so their existence isn't revealed. opts
+// select a page of its messages as they do for GetConversation.
+func (cm *ConversationManager) ViewConversation(threadID ThreadID, viewer Viewer, opts ...MessageOption) (*ConversationThread, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	thread, exists := cm.conversations[threadID]
+	if !exists || !viewer.CanView(thread) {
+		return nil, ErrConversationNotFound
+	}
+	return cm.pageThread(thread, opts)
+}
+
+// SetVisibility changes who may read a thread, adding participants as
+// WithVisibility does, and returns the updated thread
+func (cm *ConversationManager) SetVisibility(threadID ThreadID, visibility Visibility, participants ...operations.AuthorID) (*ConversationThread, error) {
+	if !visibility.IsValid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidVisibility, visibility)
+	}
+
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	thread, exists := cm.conversations[threadID]
+	if !exists {
+		return nil, ErrConversationNotFound
+	}
+	WithVisibility(visibility, participants...)(thread)
+	cm.updateAuthorIndex(thread)
+	return cm.copyThread(thread), nil
+}
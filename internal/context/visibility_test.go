@@ -0,0 +1,52 @@
+package context
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/events"
+)
+
+func TestConversationManager_Visibility(t *testing.T) {
+	manager := NewConversationManager()
+	bus := events.NewBus()
+	manager.SetEventBus(bus)
+	var published []*ConversationThread
+	bus.Subscribe(func(event events.Event) {
+		published = append(published, event.Data.(*ConversationThread))
+	})
+
+	shared, err := manager.CreateConversation(addressing.StableAddress{}, "alice", "Review", "Thoughts?", WithVisibility(VisibilityParticipants, "bob"))
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	private, _ := manager.CreateConversation(addressing.StableAddress{}, "alice", "Notes", "Just for me", WithVisibility(VisibilityPrivate))
+	manager.AddMessage(private.ID, "alice", "Decision: rename it", MsgDecision)
+	if _, err := manager.CreateConversation(addressing.StableAddress{}, "alice", "Bad", "x", WithVisibility("secret")); !errors.Is(err, ErrInvalidVisibility) {
+		t.Errorf("Expected ErrInvalidVisibility, got %v", err)
+	}
+
+	if _, err := manager.ViewConversation(shared.ID, ViewerFor("bob")); err != nil {
+		t.Errorf("Expected bob to read a thread he participates in, got %v", err)
+	}
+	if _, err := manager.ViewConversation(private.ID, ViewerFor("bob")); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("Expected a private thread to be hidden from bob, got %v", err)
+	}
+	if _, err := manager.ViewConversation(private.ID, Viewer{}); err != nil {
+		t.Errorf("Expected the zero viewer to read every thread, got %v", err)
+	}
+
+	threads, _ := manager.ListConversations(ConversationFilter{Viewer: ViewerFor("carol")})
+	if len(threads) != 0 {
+		t.Errorf("Expected carol to see no threads, got %d", len(threads))
+	}
+	if decisions := manager.Decisions(DecisionFilter{Viewer: ViewerFor("bob")}); len(decisions) != 0 {
+		t.Errorf("Expected decisions in a private thread to be hidden, got %+v", decisions)
+	}
+
+	// Events are seen by every subscriber, so they don't carry what the thread says
+	if len(published) != 2 || published[1].Title != "" || len(published[1].Messages) != 0 || published[1].Visibility != VisibilityPrivate {
+		t.Errorf("Expected redacted events for restricted threads, got %+v", published)
+	}
+}
@@ -0,0 +1,303 @@
+// Package embeddings computes vector embeddings of operation content and
+// conversation messages with a pluggable provider and keeps them in the
+// store, so changes can be found by what they mean rather than the words they
+// share. Embedding is optional and off unless a provider is configured.
+package embeddings
+
+import (
+	gocontext "context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/logging"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const (
+	// DefaultBatchSize is how many texts are sent to the provider at once
+	DefaultBatchSize = 32
+	// DefaultInterval is how often pending content is embedded when nothing prompts it sooner
+	DefaultInterval = time.Minute
+	// MaxTextLength caps the bytes of one text sent to the provider, longer
+	// content is embedded from its start
+	MaxTextLength = 8192
+)
+
+// Provider turns texts into vectors. Every vector a provider returns has the
+// same length, and Model names the provider and its settings so vectors from
+// different models are never compared.
+type Provider interface {
+	Model() string
+	Embed(ctx gocontext.Context, texts []string) ([][]float32, error)
+}
+
+// Indexer embeds whatever in the store and conversations hasn't been embedded
+// by its provider's model yet, and answers similarity queries.
+type Indexer struct {
+	provider      Provider
+	store         storage.Store
+	conversations *context.ConversationManager
+	batchSize     int
+	wake          chan struct{}
+	logger        *logging.Logger
+	// mutex keeps passes from embedding the same content twice
+	mutex sync.Mutex
+}
+
+type Option func(*Indexer)
+
+// WithBatchSize sends at most n texts to the provider per request
+func WithBatchSize(n int) Option {
+	return func(ix *Indexer) {
+		if n > 0 {
+			ix.batchSize = n
+		}
+	}
+}
+
+func NewIndexer(provider Provider, store storage.Store, conversations *context.ConversationManager, opts ...Option) *Indexer {
+	ix := &Indexer{
+		provider:      provider,
+		store:         store,
+		conversations: conversations,
+		batchSize:     DefaultBatchSize,
+		wake:          make(chan struct{}, 1),
+		logger:        logging.NewLogger("embeddings"),
+	}
+	for _, opt := range opts {
+		opt(ix)
+	}
+	return ix
+}
+
+func (ix *Indexer) Model() string {
+	return ix.provider.Model()
+}
+
+// Notify asks a running indexer to look for new content now rather than at
+// its next interval. It never blocks.
+func (ix *Indexer) Notify() {
+	select {
+	case ix.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run embeds pending content every interval, or sooner when notified, until ctx is cancelled
+func (ix *Indexer) Run(ctx gocontext.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := ix.IndexPending(ctx); err != nil && ctx.Err() == nil {
+			ix.logger.Error("Embedding failed", map[string]interface{}{"error": err.Error()})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-ix.wake:
+		}
+	}
+}
+
+// IndexPending embeds operations without a vector and messages that are new
+// or edited since they were embedded, and drops the vectors of messages that
+// no longer exist. It returns how many vectors it stored.
+func (ix *Indexer) IndexPending(ctx gocontext.Context) (int, error) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	operationCount, err := ix.indexOperations(ctx)
+	if err != nil {
+		return operationCount, err
+	}
+	messageCount, err := ix.indexMessages(ctx)
+	return operationCount + messageCount, err
+}
+
+func (ix *Indexer) indexOperations(ctx gocontext.Context) (int, error) {
+	stored := 0
+	for {
+		ops, err := ix.store.OperationsWithoutVectors(ctx, ix.Model(), ix.batchSize)
+		if err != nil {
+			return stored, fmt.Errorf("failed to find operations to embed: %w", err)
+		}
+
+		pending := make([]storage.Vector, len(ops))
+		texts := make([]string, len(ops))
+		for i, op := range ops {
+			pending[i] = storage.Vector{Kind: storage.VectorOperation, Ref: string(op.ID)}
+			texts[i] = op.Content
+		}
+		if err := ix.embed(ctx, pending, texts); err != nil {
+			return stored, err
+		}
+		stored += len(pending)
+
+		if len(ops) < ix.batchSize {
+			return stored, nil
+		}
+	}
+}
+
+func (ix *Indexer) indexMessages(ctx gocontext.Context) (int, error) {
+	embedded, err := ix.store.VectorHashes(ctx, storage.VectorMessage, ix.Model())
+	if err != nil {
+		return 0, fmt.Errorf("failed to load message vectors: %w", err)
+	}
+
+	var pending []storage.Vector
+	var texts []string
+	for _, thread := range ix.conversations.Snapshot() {
+		for _, message := range thread.Messages {
+			hash, seen := embedded[string(message.ID)]
+			delete(embedded, string(message.ID))
+			if message.Content == "" || (seen && hash == contentHash(message.Content)) {
+				continue
+			}
+
+			pending = append(pending, storage.Vector{Kind: storage.VectorMessage, Ref: string(message.ID), Parent: string(thread.ID)})
+			texts = append(texts, message.Content)
+		}
+	}
+
+	stored := 0
+	for start := 0; start < len(pending); start += ix.batchSize {
+		end := min(start+ix.batchSize, len(pending))
+		if err := ix.embed(ctx, pending[start:end], texts[start:end]); err != nil {
+			return stored, err
+		}
+		stored += end - start
+	}
+
+	// What's left was embedded from messages that have since been deleted
+	if len(embedded) > 0 {
+		gone := make([]string, 0, len(embedded))
+		for ref := range embedded {
+			gone = append(gone, ref)
+		}
+		if err := ix.store.DeleteVectors(ctx, storage.VectorMessage, ix.Model(), gone); err != nil {
+			return stored, fmt.Errorf("failed to delete message vectors: %w", err)
+		}
+	}
+	return stored, nil
+}
+
+// embed fills in and stores vectors for texts, which line up with pending
+func (ix *Indexer) embed(ctx gocontext.Context, pending []storage.Vector, texts []string) error {
+	if len(pending) == 0 {
+		return nil
+	}
+
+	inputs := make([]string, len(texts))
+	for i, text := range texts {
+		inputs[i] = truncate(text)
+	}
+	values, err := ix.provider.Embed(ctx, inputs)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProviderFailed, err)
+	}
+	if len(values) != len(inputs) {
+		return fmt.Errorf("%w: asked for %d vectors, got %d", ErrProviderFailed, len(inputs), len(values))
+	}
+
+	for i := range pending {
+		pending[i].Model = ix.Model()
+		pending[i].ContentHash = contentHash(texts[i])
+		pending[i].Values = values[i]
+	}
+	return ix.store.StoreVectors(ctx, pending)
+}
+
+// Similar finds the operations and messages whose vectors are closest to the
+// one for ref. Content that hasn't been embedded yet is embedded first.
+func (ix *Indexer) Similar(ctx gocontext.Context, kind storage.VectorKind, ref string, kinds []storage.VectorKind, limit int) ([]storage.VectorMatch, error) {
+	source, err := ix.vector(ctx, kind, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return ix.store.SimilarVectors(ctx, storage.VectorQuery{
+		Model:   ix.Model(),
+		Values:  source.Values,
+		Kinds:   kinds,
+		Exclude: ref,
+		Limit:   limit,
+	})
+}
+
+func (ix *Indexer) vector(ctx gocontext.Context, kind storage.VectorKind, ref string) (*storage.Vector, error) {
+	v, err := ix.store.GetVector(ctx, kind, ref, ix.Model())
+	if !errors.Is(err, storage.ErrVectorNotFound) {
+		return v, err
+	}
+
+	pending := storage.Vector{Kind: kind, Ref: ref}
+	var text string
+	switch kind {
+	case storage.VectorOperation:
+		op, err := ix.store.GetOperation(ctx, operations.OperationID(ref))
+		if err != nil {
+			return nil, err
+		}
+		if operations.NormalizeContentType(op.ContentType) == operations.ContentTypeBinary {
+			return nil, fmt.Errorf("%w: operation %s has binary content", ErrNothingToEmbed, ref)
+		}
+		text = op.Content
+
+	case storage.VectorMessage:
+		threadID, message := ix.message(context.MessageID(ref))
+		if message == nil {
+			return nil, fmt.Errorf("%w: %s", context.ErrMessageNotFound, ref)
+		}
+		pending.Parent = string(threadID)
+		text = message.Content
+
+	default:
+		return nil, fmt.Errorf("unknown vector kind %q", kind)
+	}
+
+	if text == "" {
+		return nil, fmt.Errorf("%w: %s %s is empty", ErrNothingToEmbed, kind, ref)
+	}
+	vectors := []storage.Vector{pending}
+	if err := ix.embed(ctx, vectors, []string{text}); err != nil {
+		return nil, err
+	}
+	return &vectors[0], nil
+}
+
+func (ix *Indexer) message(id context.MessageID) (context.ThreadID, *context.Message) {
+	for _, thread := range ix.conversations.Snapshot() {
+		for i := range thread.Messages {
+			if thread.Messages[i].ID == id {
+				return thread.ID, &thread.Messages[i]
+			}
+		}
+	}
+	return "", nil
+}
+
+func contentHash(text string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(text)))
+}
+
+// truncate cuts text to MaxTextLength without splitting a UTF-8 sequence
+func truncate(text string) string {
+	if len(text) <= MaxTextLength {
+		return text
+	}
+	end := MaxTextLength
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end]
+}
@@ -0,0 +1,153 @@
+package embeddings
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestWords(t *testing.T) {
+	got := words("func calculateTotal(items) // HTTP_status")
+	expected := []string{"func", "calculate", "total", "items", "http", "status"}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, got)
+			break
+		}
+	}
+}
+
+func TestIndexer(t *testing.T) {
+	ctx := gocontext.Background()
+	store, err := storage.NewContextStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	storeOp := func(content, contentType string, value int64) *operations.Operation {
+		op := &operations.Operation{
+			ID:   operations.NewOperationID([]byte(content)),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: "alice"},
+			}),
+			Content:     content,
+			ContentType: contentType,
+			Author:      "alice",
+			Timestamp:   time.Now(),
+			Parents:     []operations.OperationID{},
+		}
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+		return op
+	}
+
+	retry := storeOp("func retryRequest(attempts int) error { return retry(attempts) }", "", 1)
+	similar := storeOp("// retry the request with backoff between attempts", "", 2)
+	storeOp("type Config struct { Name string }", "", 3)
+	binary := storeOp("aGVsbG8=", operations.ContentTypeBinary, 4)
+
+	manager := context.NewConversationManager()
+	pos := retry.Position
+	anchor := addressing.NewStableAddress("repo", retry.ID, addressing.PositionRange{Start: pos, End: pos})
+	thread, _ := manager.CreateConversation(anchor, "bob", "Retries", "How many retry attempts should a request get?")
+
+	indexer := NewIndexer(NewHashProvider(64), store, manager, WithBatchSize(2))
+	stored, err := indexer.IndexPending(ctx)
+	if err != nil {
+		t.Fatalf("Failed to index: %v", err)
+	}
+	if stored != 4 {
+		t.Errorf("Expected 3 operations and 1 message embedded, got %d", stored)
+	}
+	if stored, _ := indexer.IndexPending(ctx); stored != 0 {
+		t.Errorf("Expected nothing left to embed, got %d", stored)
+	}
+
+	matches, err := indexer.Similar(ctx, storage.VectorOperation, string(retry.ID), nil, 2)
+	if err != nil {
+		t.Fatalf("Failed to find similar: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %+v", matches)
+	}
+	for _, match := range matches {
+		if match.Ref == string(retry.ID) {
+			t.Error("Expected the source to be left out")
+		}
+	}
+	if matches[0].Kind != storage.VectorMessage && matches[0].Ref != string(similar.ID) {
+		t.Errorf("Expected a retry related match first, got %+v", matches[0])
+	}
+
+	matches, _ = indexer.Similar(ctx, storage.VectorOperation, string(retry.ID), []storage.VectorKind{storage.VectorMessage}, 5)
+	if len(matches) != 1 || matches[0].Parent != string(thread.ID) {
+		t.Errorf("Expected the message with its thread, got %+v", matches)
+	}
+
+	if _, err := indexer.Similar(ctx, storage.VectorOperation, string(binary.ID), nil, 5); !errors.Is(err, ErrNothingToEmbed) {
+		t.Errorf("Expected ErrNothingToEmbed for binary content, got %v", err)
+	}
+
+	// Edited messages are embedded again
+	message := thread.Messages[0]
+	if err := manager.EditMessage(thread.ID, message.ID, "bob", "Should requests back off?", "clarify"); err != nil {
+		t.Fatalf("Failed to edit message: %v", err)
+	}
+	if stored, _ := indexer.IndexPending(ctx); stored != 1 {
+		t.Errorf("Expected the edited message to be embedded again, got %d", stored)
+	}
+
+	// Deleted operations take their vectors with them
+	if err := store.DeleteOperation(ctx, similar.ID); err != nil {
+		t.Fatalf("Failed to delete operation: %v", err)
+	}
+	if _, err := store.GetVector(ctx, storage.VectorOperation, string(similar.ID), indexer.Model()); !errors.Is(err, storage.ErrVectorNotFound) {
+		t.Errorf("Expected the vector to be deleted, got %v", err)
+	}
+}
+
+func TestHTTPProvider(t *testing.T) {
+	var got embeddingRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		// Out of order, as some servers return them
+		w.Write([]byte(`{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewHTTPProvider(server.URL, "test-model", "secret")
+	vectors, err := provider.Embed(gocontext.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Failed to embed: %v", err)
+	}
+	if got.Model != "test-model" || len(got.Input) != 2 {
+		t.Errorf("Expected the model and both inputs to be sent, got %+v", got)
+	}
+	if vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Errorf("Expected vectors in input order, got %v", vectors)
+	}
+
+	if _, err := NewHTTPProvider(server.URL, "test-model", "").Embed(gocontext.Background(), []string{"a"}); err == nil {
+		t.Error("Expected an error for a rejected request")
+	}
+}
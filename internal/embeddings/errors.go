@@ -0,0 +1,8 @@
+package embeddings
+
+import "errors"
+
+var (
+	ErrProviderFailed = errors.New("embedding provider failed")
+	ErrNothingToEmbed = errors.New("nothing to embed")
+)
@@ -0,0 +1,91 @@
+package embeddings
+
+import (
+	gocontext "context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// DefaultHashDimensions is the length of HashProvider vectors when not configured
+const DefaultHashDimensions = 256
+
+// HashProvider embeds text locally by hashing its words into a fixed number
+// of dimensions. It needs no model or network, so it suits tests and small
+// installs, but it only captures shared vocabulary, not meaning.
+type HashProvider struct {
+	dimensions int
+}
+
+func NewHashProvider(dimensions int) *HashProvider {
+	if dimensions <= 0 {
+		dimensions = DefaultHashDimensions
+	}
+	return &HashProvider{dimensions: dimensions}
+}
+
+func (p *HashProvider) Model() string {
+	return fmt.Sprintf("hash-%d", p.dimensions)
+}
+
+func (p *HashProvider) Embed(ctx gocontext.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vectors[i] = p.embed(text)
+	}
+	return vectors, nil
+}
+
+// embed adds each word to the dimension its hash picks, with a sign from the
+// hash so collisions tend to cancel rather than pile up, and normalizes the result
+func (p *HashProvider) embed(text string) []float32 {
+	vector := make([]float32, p.dimensions)
+	for _, word := range words(text) {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+
+		weight := float32(1)
+		if sum&(1<<63) != 0 {
+			weight = -1
+		}
+		vector[sum%uint64(p.dimensions)] += weight
+	}
+
+	length := 0.0
+	for _, value := range vector {
+		length += float64(value) * float64(value)
+	}
+	if length > 0 {
+		scale := float32(1 / math.Sqrt(length))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector
+}
+
+// words splits text into lower case words, breaking identifiers at
+// underscores and case changes so calculateTotal shares words with "total"
+func words(text string) []string {
+	var result []string
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		start := 0
+		runes := []rune(field)
+		for i := 1; i < len(runes); i++ {
+			if unicode.IsUpper(runes[i]) && !unicode.IsUpper(runes[i-1]) {
+				result = append(result, strings.ToLower(string(runes[start:i])))
+				start = i
+			}
+		}
+		result = append(result, strings.ToLower(string(runes[start:])))
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package embeddings
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPProvider calls an embeddings endpoint that speaks the widely used
+// OpenAI request format: a POST of {"model", "input"} answered with
+// {"data": [{"index", "embedding"}]}. Most hosted and self-hosted embedding
+// servers accept it.
+type HTTPProvider struct {
+	url    string
+	model  string
+	apiKey string
+	http   *http.Client
+}
+
+func NewHTTPProvider(url, model, apiKey string) *HTTPProvider {
+	return &HTTPProvider{
+		url:    url,
+		model:  model,
+		apiKey: apiKey,
+		http:   &http.Client{Timeout: time.Minute},
+	}
+}
+
+func (p *HTTPProvider) Model() string {
+	return p.model
+}
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (p *HTTPProvider) Embed(ctx gocontext.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingRequest{Model: p.model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s returned %s: %s", p.url, resp.Status, bytes.TrimSpace(detail))
+	}
+
+	var decoded embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings: %w", err)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range decoded.Data {
+		if item.Index < 0 || item.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, fmt.Errorf("no embedding returned for input %d", i)
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,11 @@
+package eventbus
+
+import "errors"
+
+var (
+	ErrClosed          = errors.New("event bus closed")
+	ErrNotConnected    = errors.New("event bus not connected")
+	ErrInvalidURL      = errors.New("invalid event bus url")
+	ErrMessageTooLarge = errors.New("message too large for the event bus")
+	ErrProtocol        = errors.New("unexpected reply from the event bus")
+)
@@ -0,0 +1,169 @@
+// Package eventbus carries live updates between the instances serving one
+// store, so a client connected to any of them sees what is done through the
+// others. Memory connects instances in one process, Redis and NATS connect
+// them over the network and reconnect on their own when it fails.
+package eventbus
+
+import (
+	gocontext "context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/logging"
+)
+
+const (
+	// DefaultChannel is the Redis channel or NATS subject updates go out on
+	DefaultChannel = "contextdb"
+
+	dialTimeout  = 10 * time.Second
+	writeTimeout = 10 * time.Second
+	// Connections are pinged this often, and dropped when nothing arrives
+	// for two intervals
+	pingInterval = 30 * time.Second
+	// Reconnecting waits minBackoff at first, doubling up to maxBackoff
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+// Handler receives what any instance published, this one's included. It runs
+// on the bus's own goroutine and must not keep data.
+type Handler func(data []byte)
+
+// Bus publishes to every instance subscribed to it
+type Bus interface {
+	Publish(ctx gocontext.Context, data []byte) error
+	// Subscribe hands handler everything published from now until the bus
+	// is closed
+	Subscribe(handler Handler) error
+	Close() error
+}
+
+// Memory is a Bus for instances in one process. Publish delivers before it
+// returns.
+type Memory struct {
+	handlers []Handler
+	closed   bool
+	mutex    sync.RWMutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Publish(ctx gocontext.Context, data []byte) error {
+	m.mutex.RLock()
+	if m.closed {
+		m.mutex.RUnlock()
+		return ErrClosed
+	}
+	handlers := m.handlers
+	m.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(data)
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(handler Handler) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+	m.handlers = append(m.handlers[:len(m.handlers):len(m.handlers)], handler)
+	return nil
+}
+
+func (m *Memory) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.closed = true
+	m.handlers = nil
+	return nil
+}
+
+// endpoint is the server a networked bus connects to, as its URL says
+type endpoint struct {
+	address  string
+	tls      *tls.Config
+	username string
+	password string
+}
+
+// parseEndpoint reads a URL of the form scheme://[user[:password]@]host[:port].
+// The plain and secure schemes name the bus's protocol without and with TLS.
+func parseEndpoint(rawURL, plain, secure, defaultPort string) (endpoint, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return endpoint{}, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if u.Scheme != plain && u.Scheme != secure {
+		return endpoint{}, fmt.Errorf("%w: %q must start with %s:// or %s://", ErrInvalidURL, rawURL, plain, secure)
+	}
+	if u.Hostname() == "" {
+		return endpoint{}, fmt.Errorf("%w: %q has no host", ErrInvalidURL, rawURL)
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	e := endpoint{address: net.JoinHostPort(u.Hostname(), port)}
+	if u.Scheme == secure {
+		e.tls = &tls.Config{ServerName: u.Hostname()}
+	}
+	if u.User != nil {
+		e.username = u.User.Username()
+		e.password, _ = u.User.Password()
+	}
+	return e, nil
+}
+
+// reconnect runs session again whenever it ends, waiting longer after each
+// failure in a row, until done is closed
+func reconnect(done <-chan struct{}, logger *logging.Logger, session func() error) {
+	backoff := minBackoff
+	for {
+		started := time.Now()
+		err := session()
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		// A session that lasted was a success, however it ended
+		if time.Since(started) > maxBackoff {
+			backoff = minBackoff
+		}
+		logger.Warn("Event bus disconnected", map[string]interface{}{
+			"error":    fmt.Sprint(err),
+			"retry_in": backoff.String(),
+		})
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// deadline is when a write made for ctx gives up
+func deadline(ctx gocontext.Context) time.Time {
+	if d, ok := ctx.Deadline(); ok {
+		return d
+	}
+	return time.Now().Add(writeTimeout)
+}
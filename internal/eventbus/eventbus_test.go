@@ -0,0 +1,269 @@
+package eventbus
+
+import (
+	"bufio"
+	gocontext "context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer accepts connections and serves each with handle until the test ends
+func fakeServer(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func receive(t *testing.T, received <-chan string) string {
+	t.Helper()
+	select {
+	case data := <-received:
+		return data
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a message")
+		return ""
+	}
+}
+
+func TestMemory(t *testing.T) {
+	bus := NewMemory()
+	received := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		if err := bus.Subscribe(func(data []byte) { received <- string(data) }); err != nil {
+			t.Fatalf("Failed to subscribe: %v", err)
+		}
+	}
+
+	if err := bus.Publish(gocontext.Background(), []byte("hello")); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	if len(received) != 2 {
+		t.Errorf("Expected both subscribers to have the message, got %d", len(received))
+	}
+
+	bus.Close()
+	if err := bus.Publish(gocontext.Background(), []byte("hello")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestRedis(t *testing.T) {
+	var mutex sync.Mutex
+	subscribers := make(map[net.Conn]bool)
+	var publishers []net.Conn
+	address := fakeServer(t, func(conn net.Conn) {
+		c := &respConn{Conn: conn, reader: bufio.NewReader(conn)}
+		for {
+			reply, err := c.read()
+			if err != nil {
+				mutex.Lock()
+				delete(subscribers, conn)
+				mutex.Unlock()
+				return
+			}
+			args := reply.([]interface{})
+			switch command := string(args[0].([]byte)); command {
+			case "AUTH":
+				if string(args[len(args)-1].([]byte)) != "secret" {
+					io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+					continue
+				}
+				io.WriteString(conn, "+OK\r\n")
+			case "SUBSCRIBE":
+				mutex.Lock()
+				subscribers[conn] = true
+				mutex.Unlock()
+				fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(args[1].([]byte)), args[1])
+			case "PUBLISH":
+				channel, data := args[1].([]byte), args[2].([]byte)
+				mutex.Lock()
+				publishers = append(publishers, conn)
+				for sub := range subscribers {
+					fmt.Fprintf(sub, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(channel), channel, len(data), data)
+				}
+				fmt.Fprintf(conn, ":%d\r\n", len(subscribers))
+				mutex.Unlock()
+			}
+		}
+	})
+
+	if _, err := NewRedis("http://"+address, ""); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("Expected ErrInvalidURL, got %v", err)
+	}
+	wrong, err := NewRedis("redis://:wrong@"+address, "")
+	if err != nil {
+		t.Fatalf("Failed to create bus: %v", err)
+	}
+	if err := wrong.Publish(gocontext.Background(), []byte("hello")); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Expected the password refused, got %v", err)
+	}
+	wrong.Close()
+
+	bus, err := NewRedis("redis://:secret@"+address, "updates")
+	if err != nil {
+		t.Fatalf("Failed to create bus: %v", err)
+	}
+	defer bus.Close()
+	received := make(chan string, 4)
+	if err := bus.Subscribe(func(data []byte) { received <- string(data) }); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		mutex.Lock()
+		subscribed := len(subscribers) == 1
+		mutex.Unlock()
+		if subscribed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the bus to subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx := gocontext.Background()
+	if err := bus.Publish(ctx, []byte("hello\r\nworld")); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	if data := receive(t, received); data != "hello\r\nworld" {
+		t.Errorf("Expected the message as published, got %q", data)
+	}
+
+	// A publishing connection the server dropped is replaced
+	mutex.Lock()
+	publishers[0].Close()
+	mutex.Unlock()
+	if err := bus.Publish(ctx, []byte("again")); err != nil {
+		t.Fatalf("Failed to publish after the connection dropped: %v", err)
+	}
+	if data := receive(t, received); data != "again" {
+		t.Errorf("Expected the message after reconnecting, got %q", data)
+	}
+
+	bus.Close()
+	if err := bus.Publish(ctx, []byte("late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestNATS(t *testing.T) {
+	var mutex sync.Mutex
+	subscribers := make(map[net.Conn]string)
+	var connects []string
+	address := fakeServer(t, func(conn net.Conn) {
+		defer func() {
+			mutex.Lock()
+			delete(subscribers, conn)
+			mutex.Unlock()
+		}()
+		io.WriteString(conn, `INFO {"server_id":"fake","max_payload":64}`+"\r\n")
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := readLine(reader)
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "CONNECT":
+				mutex.Lock()
+				connects = append(connects, strings.TrimPrefix(line, "CONNECT "))
+				mutex.Unlock()
+			case "SUB":
+				mutex.Lock()
+				subscribers[conn] = fields[2]
+				mutex.Unlock()
+			case "PING":
+				io.WriteString(conn, "PONG\r\n")
+			case "PUB":
+				size, _ := strconv.Atoi(fields[2])
+				data := make([]byte, size+2)
+				if _, err := io.ReadFull(reader, data); err != nil {
+					return
+				}
+				mutex.Lock()
+				for sub, sid := range subscribers {
+					fmt.Fprintf(sub, "MSG %s %s %d\r\n%s", fields[1], sid, size, data)
+				}
+				mutex.Unlock()
+			}
+		}
+	})
+
+	bus, err := NewNATS("nats://s3cret@"+address, "updates")
+	if err != nil {
+		t.Fatalf("Failed to create bus: %v", err)
+	}
+	defer bus.Close()
+	received := make(chan string, 4)
+	if err := bus.Subscribe(func(data []byte) { received <- string(data) }); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), 5*time.Second)
+	defer cancel()
+	if err := bus.Publish(ctx, []byte("hello")); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	if data := receive(t, received); data != "hello" {
+		t.Errorf("Expected the message as published, got %q", data)
+	}
+	mutex.Lock()
+	if len(connects) != 1 || !strings.Contains(connects[0], `"auth_token":"s3cret"`) {
+		t.Errorf("Expected to connect with the token, got %v", connects)
+	}
+	mutex.Unlock()
+
+	if err := bus.Publish(ctx, make([]byte, 65)); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Expected ErrMessageTooLarge past the server's max_payload, got %v", err)
+	}
+
+	// Dropped connections are made again, subscription and all
+	mutex.Lock()
+	for sub := range subscribers {
+		sub.Close()
+	}
+	mutex.Unlock()
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		mutex.Lock()
+		reconnected := len(connects) == 2
+		mutex.Unlock()
+		if reconnected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the bus to reconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := bus.Publish(ctx, []byte("again")); err != nil {
+		t.Fatalf("Failed to publish after reconnecting: %v", err)
+	}
+	if data := receive(t, received); data != "again" {
+		t.Errorf("Expected the message after reconnecting, got %q", data)
+	}
+}
@@ -0,0 +1,307 @@
+package eventbus
+
+import (
+	"bufio"
+	gocontext "context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/logging"
+)
+
+// NATS publishes on a NATS subject. Its URL is
+// nats://[user:password@]host[:port], nats://token@host[:port] for token
+// authentication, or tls:// for TLS. Core NATS keeps nothing for subscribers,
+// so what is published while the connection is down is lost to it.
+type NATS struct {
+	endpoint endpoint
+	subject  string
+	logger   *logging.Logger
+	handlers []Handler
+	// conn is the live connection, nil while reconnecting. changed is
+	// closed, and replaced, whenever it comes or goes.
+	conn       net.Conn
+	changed    chan struct{}
+	maxPayload int
+	// mutex guards the fields above and serializes writes to conn
+	mutex   sync.Mutex
+	started sync.Once
+	done    chan struct{}
+	closing sync.Once
+	wg      sync.WaitGroup
+}
+
+// natsInfo is what the server says about itself on connecting
+type natsInfo struct {
+	MaxPayload  int  `json:"max_payload"`
+	TLSRequired bool `json:"tls_required"`
+}
+
+type natsConnect struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Protocol  int    `json:"protocol"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+	AuthToken string `json:"auth_token,omitempty"`
+}
+
+func NewNATS(rawURL, subject string) (*NATS, error) {
+	e, err := parseEndpoint(rawURL, "nats", "tls", "4222")
+	if err != nil {
+		return nil, err
+	}
+	if subject == "" {
+		subject = DefaultChannel
+	}
+	return &NATS{
+		endpoint: e,
+		subject:  subject,
+		logger:   logging.NewLogger("eventbus"),
+		changed:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// start connects the first time the bus is used, and keeps it connected
+func (n *NATS) start() {
+	n.started.Do(func() {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			reconnect(n.done, n.logger, n.session)
+		}()
+	})
+}
+
+// Publish waits for a connection for as long as ctx allows
+func (n *NATS) Publish(ctx gocontext.Context, data []byte) error {
+	select {
+	case <-n.done:
+		return ErrClosed
+	default:
+	}
+	n.start()
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for n.conn == nil {
+		changed := n.changed
+		n.mutex.Unlock()
+		select {
+		case <-changed:
+		case <-n.done:
+			n.mutex.Lock()
+			return ErrClosed
+		case <-ctx.Done():
+			n.mutex.Lock()
+			return fmt.Errorf("%w: %v", ErrNotConnected, ctx.Err())
+		}
+		n.mutex.Lock()
+	}
+	if n.maxPayload > 0 && len(data) > n.maxPayload {
+		return fmt.Errorf("%w: %d bytes, the server takes %d", ErrMessageTooLarge, len(data), n.maxPayload)
+	}
+
+	msg := make([]byte, 0, len(data)+len(n.subject)+32)
+	msg = fmt.Appendf(msg, "PUB %s %d\r\n", n.subject, len(data))
+	msg = append(msg, data...)
+	msg = append(msg, "\r\n"...)
+	n.conn.SetWriteDeadline(deadline(ctx))
+	if _, err := n.conn.Write(msg); err != nil {
+		// The session notices and reconnects
+		n.conn.Close()
+		return err
+	}
+	return nil
+}
+
+func (n *NATS) Subscribe(handler Handler) error {
+	n.mutex.Lock()
+	select {
+	case <-n.done:
+		n.mutex.Unlock()
+		return ErrClosed
+	default:
+	}
+	n.handlers = append(n.handlers[:len(n.handlers):len(n.handlers)], handler)
+	n.mutex.Unlock()
+
+	n.start()
+	return nil
+}
+
+// session connects, subscribes and hands on messages until the connection fails
+func (n *NATS) session() error {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.Dial("tcp", n.endpoint.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	reader := bufio.NewReader(conn)
+	line, err := readLine(reader)
+	if err != nil {
+		return err
+	}
+	var info natsInfo
+	payload, found := strings.CutPrefix(line, "INFO ")
+	if !found || json.Unmarshal([]byte(payload), &info) != nil {
+		return fmt.Errorf("%w: %q", ErrProtocol, line)
+	}
+
+	// The server greets in the clear, then TLS takes over
+	if n.endpoint.tls != nil {
+		secure := tls.Client(conn, n.endpoint.tls)
+		if err := secure.Handshake(); err != nil {
+			return fmt.Errorf("failed to start tls with nats: %w", err)
+		}
+		conn, reader = secure, bufio.NewReader(secure)
+		defer conn.Close()
+	} else if info.TLSRequired {
+		return fmt.Errorf("%w: the server requires tls, use a tls:// url", ErrProtocol)
+	}
+
+	connect := natsConnect{Name: "contextdb", Lang: "go", Protocol: 1, User: n.endpoint.username, Pass: n.endpoint.password}
+	if connect.Pass == "" {
+		connect.User, connect.AuthToken = "", n.endpoint.username
+	}
+	options, err := json.Marshal(connect)
+	if err != nil {
+		return err
+	}
+	// The pong says the server took the connect and subscription
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nSUB %s 1\r\nPING\r\n", options, n.subject); err != nil {
+		return err
+	}
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			return err
+		}
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+	conn.SetDeadline(time.Time{})
+
+	if !n.setConn(conn, info.MaxPayload) {
+		return ErrClosed
+	}
+	defer n.setConn(nil, 0)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n.write(conn, "PING\r\n") != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+		line, err := readLine(reader)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case line == "PING":
+			if err := n.write(conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		case strings.HasPrefix(line, "MSG "):
+			// MSG <subject> <sid> [reply-to] <size>
+			fields := strings.Fields(line)
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || size < 0 {
+				return fmt.Errorf("%w: %q", ErrProtocol, line)
+			}
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return err
+			}
+
+			n.mutex.Lock()
+			handlers := n.handlers
+			n.mutex.Unlock()
+			for _, handler := range handlers {
+				handler(data[:size])
+			}
+		}
+	}
+}
+
+// setConn makes conn the live connection, reporting false when the bus
+// closed while it was connecting
+func (n *NATS) setConn(conn net.Conn, maxPayload int) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	select {
+	case <-n.done:
+		if conn != nil {
+			return false
+		}
+	default:
+	}
+	n.conn, n.maxPayload = conn, maxPayload
+	close(n.changed)
+	n.changed = make(chan struct{})
+	return true
+}
+
+func (n *NATS) write(conn net.Conn, command string) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := io.WriteString(conn, command)
+	return err
+}
+
+func (n *NATS) Close() error {
+	n.closing.Do(func() {
+		n.mutex.Lock()
+		close(n.done)
+		if n.conn != nil {
+			n.conn.Close()
+		}
+		n.mutex.Unlock()
+	})
+	n.wg.Wait()
+	return nil
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
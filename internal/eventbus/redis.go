@@ -0,0 +1,297 @@
+package eventbus
+
+import (
+	"bufio"
+	"bytes"
+	gocontext "context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/logging"
+)
+
+// Redis publishes on a Redis pub/sub channel. Its URL is
+// redis://[[user]:password@]host[:port], or rediss:// for TLS. Messages
+// published while a subscriber is disconnected are lost to it, as Redis
+// keeps nothing for subscribers.
+type Redis struct {
+	endpoint endpoint
+	channel  string
+	logger   *logging.Logger
+	// A subscribed connection can't publish, so publishing has its own
+	pub      *respConn
+	pubMutex sync.Mutex
+	subs     map[*respConn]bool
+	subMutex sync.Mutex
+	done     chan struct{}
+	closing  sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewRedis(rawURL, channel string) (*Redis, error) {
+	e, err := parseEndpoint(rawURL, "redis", "rediss", "6379")
+	if err != nil {
+		return nil, err
+	}
+	if channel == "" {
+		channel = DefaultChannel
+	}
+	return &Redis{
+		endpoint: e,
+		channel:  channel,
+		logger:   logging.NewLogger("eventbus"),
+		subs:     make(map[*respConn]bool),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+func (r *Redis) Publish(ctx gocontext.Context, data []byte) error {
+	select {
+	case <-r.done:
+		return ErrClosed
+	default:
+	}
+
+	r.pubMutex.Lock()
+	defer r.pubMutex.Unlock()
+
+	// A connection that went stale since the last publish gets one fresh try
+	for {
+		fresh := r.pub == nil
+		if fresh {
+			conn, err := r.connect(ctx)
+			if err != nil {
+				return err
+			}
+			r.pub = conn
+		}
+
+		_, err := r.pub.do(deadline(ctx), []byte("PUBLISH"), []byte(r.channel), data)
+		if err == nil {
+			return nil
+		}
+		r.pub.Close()
+		r.pub = nil
+		if fresh || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+func (r *Redis) Subscribe(handler Handler) error {
+	select {
+	case <-r.done:
+		return ErrClosed
+	default:
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		reconnect(r.done, r.logger, func() error { return r.subscribe(handler) })
+	}()
+	return nil
+}
+
+// subscribe hands handler the messages on one connection until it fails
+func (r *Redis) subscribe(handler Handler) error {
+	ctx, cancel := gocontext.WithTimeout(gocontext.Background(), dialTimeout)
+	conn, err := r.connect(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	r.subMutex.Lock()
+	select {
+	case <-r.done:
+		r.subMutex.Unlock()
+		conn.Close()
+		return ErrClosed
+	default:
+	}
+	r.subs[conn] = true
+	r.subMutex.Unlock()
+	defer func() {
+		r.subMutex.Lock()
+		delete(r.subs, conn)
+		r.subMutex.Unlock()
+		conn.Close()
+	}()
+
+	var writeMutex sync.Mutex
+	write := func(args ...[]byte) error {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		return conn.write(time.Now().Add(writeTimeout), args...)
+	}
+	if err := write([]byte("SUBSCRIBE"), []byte(r.channel)); err != nil {
+		return err
+	}
+
+	// Redis never pings subscribers, so they ping it to notice a dead server
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if write([]byte("PING")) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+		reply, err := conn.read()
+		if err != nil {
+			return err
+		}
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 3 {
+			continue
+		}
+		if kind, _ := items[0].([]byte); string(kind) == "message" {
+			if data, ok := items[2].([]byte); ok {
+				handler(data)
+			}
+		}
+	}
+}
+
+// connect dials the server and authenticates when the URL has a password
+func (r *Redis) connect(ctx gocontext.Context) (*respConn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if r.endpoint.tls != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: r.endpoint.tls}).DialContext(ctx, "tcp", r.endpoint.address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", r.endpoint.address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	c := &respConn{Conn: conn, reader: bufio.NewReader(conn)}
+	if r.endpoint.password != "" {
+		args := [][]byte{[]byte("AUTH"), []byte(r.endpoint.password)}
+		if r.endpoint.username != "" {
+			args = [][]byte{[]byte("AUTH"), []byte(r.endpoint.username), []byte(r.endpoint.password)}
+		}
+		if _, err := c.do(deadline(ctx), args...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (r *Redis) Close() error {
+	r.closing.Do(func() {
+		close(r.done)
+
+		r.subMutex.Lock()
+		for conn := range r.subs {
+			conn.Close()
+		}
+		r.subMutex.Unlock()
+
+		r.pubMutex.Lock()
+		if r.pub != nil {
+			r.pub.Close()
+			r.pub = nil
+		}
+		r.pubMutex.Unlock()
+	})
+	r.wg.Wait()
+	return nil
+}
+
+// respConn speaks RESP, the protocol Redis clients use
+type respConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// do sends a command and reads its reply
+func (c *respConn) do(deadline time.Time, args ...[]byte) (interface{}, error) {
+	if err := c.write(deadline, args...); err != nil {
+		return nil, err
+	}
+	c.SetReadDeadline(deadline)
+	defer c.SetReadDeadline(time.Time{})
+	return c.read()
+}
+
+func (c *respConn) write(deadline time.Time, args ...[]byte) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n", len(arg))
+		buf.Write(arg)
+		buf.WriteString("\r\n")
+	}
+
+	c.SetWriteDeadline(deadline)
+	_, err := c.Write(buf.Bytes())
+	return err
+}
+
+// read returns the next reply: a string for a status, an int64, []byte for a
+// bulk string, nil for a null, or []interface{} for an array. Error replies
+// are returned as errors.
+func (c *respConn) read() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("%w: empty redis reply", ErrProtocol)
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrProtocol, line)
+}
@@ -0,0 +1,112 @@
+// Package events lets components announce changes without knowing who is
+// listening, so integrations such as webhooks can observe the engine,
+// conversations and addresses from one place.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/ids"
+)
+
+type Type string
+
+const (
+	OperationCreated     Type = "operation.created"
+	DocumentUpdated      Type = "document.updated"
+	DocumentDeleted      Type = "document.deleted"
+	DocumentRestored     Type = "document.restored"
+	ConversationCreated  Type = "conversation.created"
+	ConversationResolved Type = "conversation.resolved"
+	AddressInvalidated   Type = "address.invalidated"
+	BranchCreated        Type = "branch.created"
+	BranchMerged         Type = "branch.merged"
+	DocumentMerged       Type = "document.merged"
+)
+
+// Types lists every event that is published, in a stable order
+var Types = []Type{
+	OperationCreated,
+	DocumentUpdated,
+	DocumentDeleted,
+	DocumentRestored,
+	ConversationCreated,
+	ConversationResolved,
+	AddressInvalidated,
+	BranchCreated,
+	BranchMerged,
+	DocumentMerged,
+}
+
+func IsValidType(t Type) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+type Event struct {
+	ID        string      `json:"id"`
+	Type      Type        `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Handler receives published events. Handlers run synchronously on the
+// publisher's goroutine, often while it holds locks, so they must return
+// quickly and must not call back into the publisher.
+type Handler func(Event)
+
+type Bus struct {
+	handlers map[int]Handler
+	nextID   int
+	mutex    sync.RWMutex
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[int]Handler)}
+}
+
+// Subscribe registers handler for every event and returns a function that removes it
+func (b *Bus) Subscribe(handler Handler) func() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+// Publish delivers an event to every subscriber. Publishing to a nil Bus is a
+// no-op, so components work the same whether or not anyone is listening.
+func (b *Bus) Publish(eventType Type, data interface{}) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if len(b.handlers) == 0 {
+		return
+	}
+
+	event := Event{
+		ID:        ids.NewWithPrefix("evt"),
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+}
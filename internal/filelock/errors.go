@@ -0,0 +1,8 @@
+package filelock
+
+import "errors"
+
+var (
+	// ErrLocked is returned by TryAcquire while another holder has the lock
+	ErrLocked = errors.New("file is locked")
+)
@@ -0,0 +1,128 @@
+// Package filelock keeps files under .context consistent when several
+// processes share the directory: advisory locks serialize their updates and
+// atomic writes mean a reader never sees a file half written.
+package filelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LockSuffix names the sidecar file locked in place of the file it guards.
+// Locking a sidecar rather than the file itself keeps the lock valid across
+// the renames WriteFile replaces the file with.
+const LockSuffix = ".lock"
+
+// Lock is an advisory lock on a file, held until Unlock. It excludes other
+// processes that lock the same file, and other Locks in this one.
+type Lock struct {
+	file *os.File
+}
+
+// Acquire blocks until it holds the exclusive lock on path
+func Acquire(path string) (*Lock, error) {
+	return acquire(path, lockFile)
+}
+
+// TryAcquire takes the exclusive lock on path if it's free, and fails with
+// ErrLocked rather than waiting if it isn't. A lock is released when its
+// holder exits, however it exits, so one left by a process that died is free.
+func TryAcquire(path string) (*Lock, error) {
+	return acquire(path, tryLockFile)
+}
+
+func acquire(path string, lock func(*os.File) error) (*Lock, error) {
+	file, err := os.OpenFile(path+LockSuffix, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := lock(file); err != nil {
+		file.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", filepath.Base(path), err)
+	}
+	return &Lock{file: file}, nil
+}
+
+// SetOwner records who holds the lock in the lock file, for Owner to report
+// to those that find it held
+func (l *Lock) SetOwner(owner []byte) error {
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := l.file.WriteAt(owner, 0)
+	return err
+}
+
+// Owner returns what the holder of the lock on path recorded with SetOwner.
+// Where locks are mandatory, as on Windows, it can't be read while held.
+func Owner(path string) ([]byte, error) {
+	return os.ReadFile(path + LockSuffix)
+}
+
+func (l *Lock) Unlock() error {
+	unlockErr := unlockFile(l.file)
+	if err := l.file.Close(); err != nil && unlockErr == nil {
+		return err
+	}
+	return unlockErr
+}
+
+// WriteFile replaces path with data atomically: it writes a temporary file
+// in the same directory, syncs it and renames it over path, so readers see
+// either the old contents or the new, never a mix.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // Gone already once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Version identifies a file's contents as last read or written, so a later
+// Changed can tell whether another process has replaced it since
+type Version struct {
+	info os.FileInfo
+}
+
+// Stat returns the version of path now
+func Stat(path string) (Version, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Version{}, err
+	}
+	return Version{info: info}, nil
+}
+
+// Changed reports whether path is no longer at version v. Every WriteFile
+// makes a new file, so a rename is caught even within the same modification
+// time; size and time catch the file being edited in place.
+func (v Version) Changed(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || v.info == nil {
+		return true
+	}
+	return !os.SameFile(info, v.info) || info.Size() != v.info.Size() || !info.ModTime().Equal(v.info.ModTime())
+}
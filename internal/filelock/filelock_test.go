@@ -0,0 +1,112 @@
+package filelock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestLock_Excludes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	if err := WriteFile(path, []byte("0"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// Each increment reads then writes; unlocked, some would be lost
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := Acquire(path)
+			if err != nil {
+				t.Errorf("Failed to acquire lock: %v", err)
+				return
+			}
+			defer lock.Unlock()
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Errorf("Failed to read file: %v", err)
+				return
+			}
+			n, _ := strconv.Atoi(string(data))
+			if err := WriteFile(path, []byte(strconv.Itoa(n+1)), 0644); err != nil {
+				t.Errorf("Failed to write file: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(data) != "20" {
+		t.Errorf("Expected 20 increments, got %s", data)
+	}
+}
+
+func TestWriteFile_Changed(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.json")
+	if err := WriteFile(path, []byte(`{"a":1}`), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	version, err := Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if version.Changed(path) {
+		t.Error("Expected the file unchanged")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// Same size, likely the same modification time, but a new file
+	if err := WriteFile(path, []byte(`{"a":2}`), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if !version.Changed(path) {
+		t.Error("Expected the rewrite to count as a change")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files left behind, got %d entries", len(entries))
+	}
+}
+
+func TestTryAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instance")
+	lock, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	if err := lock.SetOwner([]byte("pid 1")); err != nil {
+		t.Fatalf("Failed to set owner: %v", err)
+	}
+
+	if _, err := TryAcquire(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected ErrLocked while held, got %v", err)
+	}
+	if owner, err := Owner(path); err != nil || string(owner) != "pid 1" {
+		t.Errorf("Expected the owner recorded, got %q, %v", owner, err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Failed to unlock: %v", err)
+	}
+	again, err := TryAcquire(path)
+	if err != nil {
+		t.Fatalf("Expected the lock free once unlocked, got %v", err)
+	}
+	again.Unlock()
+}
@@ -0,0 +1,7 @@
+package ids
+
+import "errors"
+
+var (
+	ErrInvalidID = errors.New("invalid identifier")
+)
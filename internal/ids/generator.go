@@ -0,0 +1,39 @@
+package ids
+
+import "sync"
+
+type Generator interface {
+	NewID() string
+}
+
+// GeneratorFunc adapts a plain function to the Generator interface
+type GeneratorFunc func() string
+
+func (f GeneratorFunc) NewID() string {
+	return f()
+}
+
+var (
+	defaultGenerator Generator = NewULIDGenerator()
+	defaultMutex     sync.RWMutex
+)
+
+// SetDefault replaces the process-wide generator used by New and NewWithPrefix
+func SetDefault(generator Generator) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+
+	defaultGenerator = generator
+}
+
+func New() string {
+	defaultMutex.RLock()
+	generator := defaultGenerator
+	defaultMutex.RUnlock()
+
+	return generator.NewID()
+}
+
+func NewWithPrefix(prefix string) string {
+	return prefix + "_" + New()
+}
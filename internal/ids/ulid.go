@@ -0,0 +1,126 @@
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+// Crockford's base32 alphabet keeps ULIDs case-insensitive and sortable
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+const ULIDLength = 26
+
+// ULIDGenerator produces lexicographically sortable identifiers made of a
+// 48-bit millisecond timestamp followed by 80 bits of entropy. IDs generated
+// within the same millisecond increment the entropy so ordering is preserved.
+type ULIDGenerator struct {
+	entropy    io.Reader
+	lastMillis uint64
+	lastRandom [10]byte
+	mutex      sync.Mutex
+}
+
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{entropy: rand.Reader}
+}
+
+func (g *ULIDGenerator) NewID() string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	millis := uint64(time.Now().UnixMilli())
+	if millis <= g.lastMillis {
+		// Same (or skewed) millisecond: keep monotonic by incrementing entropy
+		millis = g.lastMillis
+		if !incrementEntropy(&g.lastRandom) {
+			// Entropy space exhausted for this millisecond, borrow the next one
+			millis++
+			g.fillEntropy()
+		}
+	} else {
+		g.fillEntropy()
+	}
+	g.lastMillis = millis
+
+	return encodeULID(millis, g.lastRandom)
+}
+
+func (g *ULIDGenerator) fillEntropy() {
+	if _, err := io.ReadFull(g.entropy, g.lastRandom[:]); err != nil {
+		// Fall back to clock-derived bytes rather than failing ID generation
+		binary.BigEndian.PutUint64(g.lastRandom[2:], uint64(time.Now().UnixNano()))
+	}
+}
+
+func incrementEntropy(random *[10]byte) bool {
+	for i := len(random) - 1; i >= 0; i-- {
+		random[i]++
+		if random[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeULID(millis uint64, random [10]byte) string {
+	var data [16]byte
+	data[0] = byte(millis >> 40)
+	data[1] = byte(millis >> 32)
+	data[2] = byte(millis >> 24)
+	data[3] = byte(millis >> 16)
+	data[4] = byte(millis >> 8)
+	data[5] = byte(millis)
+	copy(data[6:], random[:])
+
+	// 128 bits encode to 26 characters; the leading character carries 3 bits
+	var out [ULIDLength]byte
+	var acc uint32
+	var bits uint
+	pos := ULIDLength - 1
+	for i := len(data) - 1; i >= 0; i-- {
+		acc |= uint32(data[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			out[pos] = crockfordAlphabet[acc&0x1f]
+			pos--
+			acc >>= 5
+			bits -= 5
+		}
+	}
+	out[pos] = crockfordAlphabet[acc&0x1f]
+
+	return string(out[:])
+}
+
+// ULIDTime extracts the millisecond timestamp encoded in a ULID
+func ULIDTime(id string) (time.Time, error) {
+	if len(id) != ULIDLength {
+		return time.Time{}, ErrInvalidID
+	}
+
+	var millis uint64
+	for i := 0; i < 10; i++ {
+		value := decodeChar(id[i])
+		if value < 0 {
+			return time.Time{}, ErrInvalidID
+		}
+		millis = millis<<5 | uint64(value)
+	}
+
+	return time.UnixMilli(int64(millis)), nil
+}
+
+func decodeChar(c byte) int {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	for i := 0; i < len(crockfordAlphabet); i++ {
+		if crockfordAlphabet[i] == c {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,51 @@
+package ids
+
+import (
+	"testing"
+	"time"
+)
+
+func TestULIDGenerator_Monotonic(t *testing.T) {
+	generator := NewULIDGenerator()
+
+	prev := generator.NewID()
+	for i := 0; i < 10000; i++ {
+		next := generator.NewID()
+		if len(next) != ULIDLength {
+			t.Fatalf("Expected ID length %d, got %d", ULIDLength, len(next))
+		}
+		if next <= prev {
+			t.Fatalf("Expected %s to sort after %s", next, prev)
+		}
+		prev = next
+	}
+}
+
+func TestULIDTime(t *testing.T) {
+	before := time.Now().Truncate(time.Millisecond)
+	id := NewULIDGenerator().NewID()
+	after := time.Now()
+
+	ts, err := ULIDTime(id)
+	if err != nil {
+		t.Fatalf("Failed to decode ULID time: %v", err)
+	}
+
+	if ts.Before(before) || ts.After(after) {
+		t.Errorf("Decoded time %v outside of [%v, %v]", ts, before, after)
+	}
+
+	if _, err := ULIDTime("not-a-ulid"); err != ErrInvalidID {
+		t.Errorf("Expected ErrInvalidID, got %v", err)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	defer SetDefault(NewULIDGenerator())
+
+	SetDefault(GeneratorFunc(func() string { return "fixed" }))
+
+	if id := NewWithPrefix("thread"); id != "thread_fixed" {
+		t.Errorf("Expected thread_fixed, got %s", id)
+	}
+}
@@ -0,0 +1,10 @@
+package jobs
+
+import "errors"
+
+var (
+	ErrJobNotFound      = errors.New("job not found")
+	ErrJobExists        = errors.New("job already registered")
+	ErrJobRunning       = errors.New("job is already running")
+	ErrSchedulerStopped = errors.New("job scheduler is not running")
+)
@@ -0,0 +1,377 @@
+// Package jobs runs periodic background work such as backups and retention
+// enforcement. Each job runs on its own interval, spread out by jitter so jobs
+// registered together don't all fire at once, and never overlaps itself:
+// a job started by hand while it is already running is refused. Every run is
+// recorded in the store so admins can see what ran and what failed.
+package jobs
+
+import (
+	gocontext "context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/ids"
+	"github.com/jeremytregunna/contextdb/internal/logging"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const (
+	// DefaultJitter is the fraction of its interval a job's next run is delayed by at most
+	DefaultJitter = 0.1
+	// DefaultHistory is how many of a job's latest runs Status reports
+	DefaultHistory = 10
+)
+
+// Trigger is why a job ran
+type Trigger string
+
+const (
+	TriggerSchedule Trigger = "schedule"
+	TriggerManual   Trigger = "manual"
+)
+
+// Func does one run of a job. It should return promptly once ctx is done.
+type Func func(ctx gocontext.Context) error
+
+// IntervalFunc says how long to wait before a job's next run. Zero or less
+// leaves the job to run only when triggered.
+type IntervalFunc func() time.Duration
+
+// Every is an IntervalFunc for a fixed interval
+func Every(interval time.Duration) IntervalFunc {
+	return func() time.Duration { return interval }
+}
+
+// Status is what a scheduler knows about one job. NextRun is zero for jobs
+// that only run when triggered, or while the scheduler isn't running.
+// Progress is what the latest run reported, if anything.
+type Status struct {
+	Name     string           `json:"name"`
+	Interval string           `json:"interval,omitempty"`
+	Running  bool             `json:"running"`
+	NextRun  *time.Time       `json:"next_run,omitempty"`
+	Progress *Progress        `json:"progress,omitempty"`
+	LastRun  *storage.JobRun  `json:"last_run,omitempty"`
+	History  []storage.JobRun `json:"history,omitempty"`
+}
+
+// Progress is how far a run has got through its work
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+type progressKey struct{}
+
+// ReportProgress records how far the run ctx was given to has got. Outside a
+// run it does nothing.
+func ReportProgress(ctx gocontext.Context, done, total int) {
+	if report, ok := ctx.Value(progressKey{}).(func(Progress)); ok {
+		report(Progress{Done: done, Total: total})
+	}
+}
+
+type job struct {
+	name     string
+	interval IntervalFunc
+	run      Func
+	// lock is held for the whole of a run, so runs never overlap
+	lock     sync.Mutex
+	running  bool
+	nextRun  time.Time
+	progress *Progress
+}
+
+type Scheduler struct {
+	history storage.JobStore
+	jitter  float64
+	logger  *logging.Logger
+	jobs    map[string]*job
+	// ctx is the context runs get while the scheduler is running, nil otherwise
+	ctx   gocontext.Context
+	runs  sync.WaitGroup
+	mutex sync.Mutex
+}
+
+type Option func(*Scheduler)
+
+// WithJitter delays each scheduled run by up to fraction of its interval.
+// Zero runs jobs exactly on their interval.
+func WithJitter(fraction float64) Option {
+	return func(s *Scheduler) {
+		if fraction >= 0 {
+			s.jitter = fraction
+		}
+	}
+}
+
+// NewScheduler records the runs of its jobs in history
+func NewScheduler(history storage.JobStore, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		history: history,
+		jitter:  DefaultJitter,
+		logger:  logging.NewLogger("jobs"),
+		jobs:    make(map[string]*job),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register adds a job. Jobs must be registered before Run.
+func (s *Scheduler) Register(name string, interval IntervalFunc, run Func) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.jobs[name]; exists {
+		return fmt.Errorf("%w: %s", ErrJobExists, name)
+	}
+	s.jobs[name] = &job{name: name, interval: interval, run: run}
+	return nil
+}
+
+// Run schedules every job until ctx is done, then waits for runs in progress
+// to return
+func (s *Scheduler) Run(ctx gocontext.Context) {
+	s.mutex.Lock()
+	s.ctx = ctx
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mutex.Unlock()
+
+	var loops sync.WaitGroup
+	for _, j := range jobs {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.schedule(ctx, j)
+		}()
+	}
+	loops.Wait()
+
+	s.mutex.Lock()
+	s.ctx = nil
+	s.mutex.Unlock()
+	s.runs.Wait()
+}
+
+// schedule runs j on its interval until ctx is done. The interval is asked
+// for again after every run, so it can follow configuration.
+func (s *Scheduler) schedule(ctx gocontext.Context, j *job) {
+	for {
+		wait := s.nextWait(j)
+		if wait <= 0 {
+			// Runs only when triggered; check again in case the interval changes
+			wait = time.Minute
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if j.interval() > 0 {
+			s.runs.Add(1)
+			s.execute(ctx, j, TriggerSchedule)
+		}
+	}
+}
+
+// nextWait picks how long until j's next scheduled run and records when that is
+func (s *Scheduler) nextWait(j *job) time.Duration {
+	interval := j.interval()
+	if interval > 0 && s.jitter > 0 {
+		interval += time.Duration(rand.Float64() * s.jitter * float64(interval))
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	j.nextRun = time.Time{}
+	if interval > 0 {
+		j.nextRun = time.Now().Add(interval)
+	}
+	return interval
+}
+
+// Trigger starts a run of the named job now, in the background. It fails if
+// the job is already running or the scheduler isn't.
+func (s *Scheduler) Trigger(name string) error {
+	return s.TriggerWith(name, nil)
+}
+
+// TriggerWith is Trigger with run done in place of the job's own function, for
+// runs that take input from whoever starts them. The run is recorded as the
+// job's and never overlaps its other runs.
+func (s *Scheduler) TriggerWith(name string, run Func) error {
+	// The run is counted while the scheduler is known to be running, so Run
+	// waits for it when stopping
+	s.mutex.Lock()
+	j, exists := s.jobs[name]
+	ctx := s.ctx
+	if !exists {
+		s.mutex.Unlock()
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	if ctx == nil {
+		s.mutex.Unlock()
+		return ErrSchedulerStopped
+	}
+	if !j.lock.TryLock() {
+		s.mutex.Unlock()
+		return fmt.Errorf("%w: %s", ErrJobRunning, name)
+	}
+	s.runs.Add(1)
+	s.mutex.Unlock()
+
+	if run == nil {
+		run = j.run
+	}
+	go func() {
+		defer j.lock.Unlock()
+		s.executeLocked(ctx, j, TriggerManual, run)
+	}()
+	return nil
+}
+
+// execute runs j unless a triggered run already is, in which case this run is skipped
+func (s *Scheduler) execute(ctx gocontext.Context, j *job, trigger Trigger) {
+	if !j.lock.TryLock() {
+		s.runs.Done()
+		return
+	}
+	defer j.lock.Unlock()
+	s.executeLocked(ctx, j, trigger, j.run)
+}
+
+// executeLocked does one run of j with fn, which the caller has locked, and
+// records it
+func (s *Scheduler) executeLocked(ctx gocontext.Context, j *job, trigger Trigger, fn Func) {
+	defer s.runs.Done()
+
+	s.setRunning(j, true)
+	defer s.setRunning(j, false)
+
+	run := storage.JobRun{ID: ids.NewWithPrefix("run"), Job: j.name, Trigger: string(trigger), StartedAt: time.Now()}
+	err := fn(gocontext.WithValue(ctx, progressKey{}, func(progress Progress) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		j.progress = &progress
+	}))
+	run.FinishedAt = time.Now()
+	if err != nil {
+		run.Error = err.Error()
+		if ctx.Err() == nil {
+			s.logger.Error("Job failed", map[string]interface{}{"job": j.name, "error": err.Error()})
+		}
+	}
+
+	// The run is recorded even when ctx was cancelled part way through it
+	if recordErr := s.history.RecordJobRun(gocontext.WithoutCancel(ctx), run); recordErr != nil {
+		s.logger.Warn("Failed to record job run", map[string]interface{}{"job": j.name, "error": recordErr.Error()})
+	}
+}
+
+// setRunning marks j running or not. Starting a run clears the progress the
+// last one reported.
+func (s *Scheduler) setRunning(j *job, running bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	j.running = running
+	if running {
+		j.progress = nil
+	}
+}
+
+// Jobs reports on every job, in name order, with its last run
+func (s *Scheduler) Jobs(ctx gocontext.Context) ([]Status, error) {
+	s.mutex.Lock()
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	s.mutex.Unlock()
+	sort.Strings(names)
+
+	statuses := make([]Status, 0, len(names))
+	for _, name := range names {
+		status, err := s.status(ctx, name, 1)
+		if err != nil {
+			return nil, err
+		}
+		status.History = nil
+		statuses = append(statuses, *status)
+	}
+	return statuses, nil
+}
+
+// Status reports on the named job with its latest runs, newest first
+func (s *Scheduler) Status(ctx gocontext.Context, name string) (*Status, error) {
+	return s.status(ctx, name, DefaultHistory)
+}
+
+func (s *Scheduler) status(ctx gocontext.Context, name string, history int) (*Status, error) {
+	s.mutex.Lock()
+	j, exists := s.jobs[name]
+	if !exists {
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	status := &Status{Name: name, Running: j.running}
+	if j.progress != nil {
+		progress := *j.progress
+		status.Progress = &progress
+	}
+	if !j.nextRun.IsZero() && s.ctx != nil {
+		next := j.nextRun
+		status.NextRun = &next
+	}
+	s.mutex.Unlock()
+
+	if interval := j.interval(); interval > 0 {
+		status.Interval = interval.String()
+	}
+
+	runs, err := s.history.JobRuns(ctx, name, history)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job runs: %w", err)
+	}
+	status.History = runs
+	if len(runs) > 0 {
+		status.LastRun = &runs[0]
+	}
+	return status, nil
+}
+
+// Overdue finds the job furthest past its scheduled start without having
+// finished that run, and by how long. Jobs held up by a slow run, or a
+// scheduling loop that has stalled, show up here. It reports no job while
+// every job is on time or the scheduler isn't running.
+func (s *Scheduler) Overdue() (string, time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.ctx == nil {
+		return "", 0
+	}
+	now := time.Now()
+	var name string
+	var lag time.Duration
+	for _, j := range s.jobs {
+		if j.nextRun.IsZero() {
+			continue
+		}
+		if late := now.Sub(j.nextRun); late > lag || (late == lag && late > 0 && j.name < name) {
+			name, lag = j.name, late
+		}
+	}
+	return name, lag
+}
@@ -0,0 +1,265 @@
+package jobs
+
+import (
+	gocontext "context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func newTestScheduler(t *testing.T, opts ...Option) *Scheduler {
+	t.Helper()
+	store, err := storage.NewContextStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return NewScheduler(store, opts...)
+}
+
+// startScheduler runs s until the test ends
+func startScheduler(t *testing.T, s *Scheduler) {
+	t.Helper()
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// Run has started once triggers are accepted
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mutex.Lock()
+		running := s.ctx != nil
+		s.mutex.Unlock()
+		if running {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Scheduler did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func waitForRuns(t *testing.T, s *Scheduler, name string, count int) []storage.JobRun {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status, err := s.Status(gocontext.Background(), name)
+		if err != nil {
+			t.Fatalf("Failed to get job status: %v", err)
+		}
+		if len(status.History) >= count && !status.Running {
+			return status.History
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d runs of %s, got %+v", count, name, status.History)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestScheduler_RunsOnInterval(t *testing.T) {
+	s := newTestScheduler(t, WithJitter(0))
+
+	var runs atomic.Int32
+	if err := s.Register("tick", Every(10*time.Millisecond), func(gocontext.Context) error {
+		if runs.Add(1) == 2 {
+			return errors.New("disk full")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to register job: %v", err)
+	}
+	if err := s.Register("tick", Every(time.Second), func(gocontext.Context) error { return nil }); !errors.Is(err, ErrJobExists) {
+		t.Errorf("Expected ErrJobExists, got %v", err)
+	}
+
+	startScheduler(t, s)
+	history := waitForRuns(t, s, "tick", 3)
+
+	// Newest first, so the failed second run is second to last
+	failed := history[len(history)-2]
+	if failed.Error != "disk full" || failed.Trigger != string(TriggerSchedule) {
+		t.Errorf("Expected the second run to record its error, got %+v", failed)
+	}
+	if history[len(history)-1].Error != "" || history[len(history)-1].FinishedAt.Before(history[len(history)-1].StartedAt) {
+		t.Errorf("Expected the first run to succeed, got %+v", history[len(history)-1])
+	}
+
+	status, err := s.Status(gocontext.Background(), "tick")
+	if err != nil {
+		t.Fatalf("Failed to get job status: %v", err)
+	}
+	if status.Interval != "10ms" || status.NextRun == nil || status.LastRun == nil || status.LastRun.ID != history[0].ID {
+		t.Errorf("Expected the interval, next run and last run, got %+v", status)
+	}
+}
+
+func TestScheduler_Trigger(t *testing.T) {
+	s := newTestScheduler(t)
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	if err := s.Register("manual", Every(0), func(ctx gocontext.Context) error {
+		started <- struct{}{}
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to register job: %v", err)
+	}
+
+	if err := s.Trigger("manual"); !errors.Is(err, ErrSchedulerStopped) {
+		t.Errorf("Expected ErrSchedulerStopped before Run, got %v", err)
+	}
+
+	startScheduler(t, s)
+	if err := s.Trigger("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+	if err := s.Trigger("manual"); err != nil {
+		t.Fatalf("Failed to trigger job: %v", err)
+	}
+	<-started
+
+	// The job holds its lock while it runs
+	if err := s.Trigger("manual"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Expected ErrJobRunning while the job runs, got %v", err)
+	}
+	statuses, err := s.Jobs(gocontext.Background())
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(statuses) != 1 || !statuses[0].Running || statuses[0].NextRun != nil || statuses[0].Interval != "" {
+		t.Errorf("Expected a running manual-only job, got %+v", statuses)
+	}
+
+	close(release)
+	history := waitForRuns(t, s, "manual", 1)
+	if len(history) != 1 || history[0].Trigger != string(TriggerManual) {
+		t.Errorf("Expected one manual run, got %+v", history)
+	}
+}
+
+func TestScheduler_StopWaitsForRuns(t *testing.T) {
+	s := newTestScheduler(t)
+
+	var finished atomic.Bool
+	started := make(chan struct{})
+	if err := s.Register("slow", Every(0), func(ctx gocontext.Context) error {
+		close(started)
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		finished.Store(true)
+		return ctx.Err()
+	}); err != nil {
+		t.Fatalf("Failed to register job: %v", err)
+	}
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	for s.Trigger("slow") != nil {
+		time.Sleep(time.Millisecond)
+	}
+	<-started
+	cancel()
+	<-done
+
+	if !finished.Load() {
+		t.Errorf("Expected Run to wait for the job to return")
+	}
+	runs, err := s.history.JobRuns(gocontext.Background(), "slow", 10)
+	if err != nil {
+		t.Fatalf("Failed to load job runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Error != gocontext.Canceled.Error() {
+		t.Errorf("Expected the cancelled run to be recorded, got %+v", runs)
+	}
+}
+
+func TestScheduler_Overdue(t *testing.T) {
+	s := newTestScheduler(t, WithJitter(0))
+
+	release := make(chan struct{})
+	if err := s.Register("slow", Every(10*time.Millisecond), func(ctx gocontext.Context) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to register job: %v", err)
+	}
+	if name, lag := s.Overdue(); name != "" || lag != 0 {
+		t.Errorf("Expected nothing overdue before Run, got %s %v", name, lag)
+	}
+
+	startScheduler(t, s)
+	defer close(release)
+	time.Sleep(50 * time.Millisecond)
+
+	// The first run started on time but hasn't finished
+	if name, lag := s.Overdue(); name != "slow" || lag < 30*time.Millisecond {
+		t.Errorf("Expected slow to be overdue, got %q %v", name, lag)
+	}
+}
+
+func TestScheduler_TriggerWith(t *testing.T) {
+	s := newTestScheduler(t)
+	if err := s.Register("reanalysis", Every(0), func(ctx gocontext.Context) error {
+		return errors.New("the job's own function shouldn't run")
+	}); err != nil {
+		t.Fatalf("Failed to register job: %v", err)
+	}
+	startScheduler(t, s)
+
+	release := make(chan struct{})
+	reported := make(chan struct{})
+	if err := s.TriggerWith("reanalysis", func(ctx gocontext.Context) error {
+		ReportProgress(ctx, 3, 10)
+		close(reported)
+		<-release
+		ReportProgress(ctx, 10, 10)
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to trigger job: %v", err)
+	}
+	<-reported
+
+	status, err := s.Status(gocontext.Background(), "reanalysis")
+	if err != nil {
+		t.Fatalf("Failed to get job status: %v", err)
+	}
+	if !status.Running || status.Progress == nil || *status.Progress != (Progress{Done: 3, Total: 10}) {
+		t.Errorf("Expected the progress reported so far, got %+v", status)
+	}
+	if err := s.TriggerWith("reanalysis", nil); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Expected ErrJobRunning while the job runs, got %v", err)
+	}
+
+	close(release)
+	history := waitForRuns(t, s, "reanalysis", 1)
+	if history[0].Error != "" {
+		t.Errorf("Expected the given function run in place of the job's, got %q", history[0].Error)
+	}
+	if status, err = s.Status(gocontext.Background(), "reanalysis"); err != nil || *status.Progress != (Progress{Done: 10, Total: 10}) {
+		t.Errorf("Expected the finished run's progress kept, got %v %+v", err, status)
+	}
+	ReportProgress(gocontext.Background(), 1, 1)
+}
@@ -2,6 +2,7 @@ package logging
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -34,10 +35,13 @@ func (l LogLevel) String() string {
 	}
 }
 
+// Logger logs for one component, at the level and in the format set for
+// every logger unless it was given its own
 type Logger struct {
-	level      LogLevel
-	component  string
-	jsonFormat bool
+	component string
+	// level and jsonFormat override the shared settings when set
+	level      *LogLevel
+	jsonFormat *bool
 }
 
 type LogEntry struct {
@@ -49,19 +53,29 @@ type LogEntry struct {
 }
 
 func NewLogger(component string) *Logger {
-	return &Logger{
-		level:      INFO,
-		component:  component,
-		jsonFormat: os.Getenv("LOG_FORMAT") == "json",
-	}
+	register(component)
+	return &Logger{component: component}
 }
 
+// SetLevel logs at level whatever level is set for the component
 func (l *Logger) SetLevel(level LogLevel) {
-	l.level = level
+	l.level = &level
+}
+
+// SetJSONFormat writes entries as JSON, or as text, whatever LOG_FORMAT says
+func (l *Logger) SetJSONFormat(jsonFormat bool) {
+	l.jsonFormat = &jsonFormat
 }
 
 func (l *Logger) log(level LogLevel, message string, fields map[string]interface{}) {
-	if level < l.level {
+	minimum, jsonFormat, output := current(l.component)
+	if l.level != nil {
+		minimum = *l.level
+	}
+	if l.jsonFormat != nil {
+		jsonFormat = *l.jsonFormat
+	}
+	if level < minimum {
 		return
 	}
 
@@ -73,10 +87,13 @@ func (l *Logger) log(level LogLevel, message string, fields map[string]interface
 		Fields:    fields,
 	}
 
-	if l.jsonFormat {
-		if data, err := json.Marshal(entry); err == nil {
-			log.Println(string(data))
+	var line string
+	if jsonFormat {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return
 		}
+		line = string(data)
 	} else {
 		fieldsStr := ""
 		if len(fields) > 0 {
@@ -84,8 +101,18 @@ func (l *Logger) log(level LogLevel, message string, fields map[string]interface
 				fieldsStr = " " + string(data)
 			}
 		}
-		log.Printf("[%s] %s: %s%s", entry.Level, entry.Component, entry.Message, fieldsStr)
+		line = fmt.Sprintf("[%s] %s: %s%s", entry.Level, entry.Component, entry.Message, fieldsStr)
+	}
+
+	if output == nil {
+		log.Println(line)
+		return
+	}
+	if !jsonFormat {
+		// The standard logger stamps its lines, other outputs need it here
+		line = entry.Timestamp + " " + line
 	}
+	output.Write(level, line)
 }
 
 func (l *Logger) Debug(message string, fields ...map[string]interface{}) {
@@ -0,0 +1,136 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var ErrSyslogUnsupported = errors.New("syslog is not supported on this platform")
+
+// Output receives each entry logged as a formatted line, with the level it
+// was logged at for outputs that file entries by severity
+type Output interface {
+	Write(level LogLevel, line string) error
+}
+
+// writerOutput writes one line per entry to an io.Writer
+type writerOutput struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+// NewWriterOutput writes entries to w a line each, such as to os.Stdout or
+// a RotatingFile
+func NewWriterOutput(w io.Writer) Output {
+	return &writerOutput{w: w}
+}
+
+func (o *writerOutput) Write(level LogLevel, line string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	_, err := io.WriteString(o.w, line+"\n")
+	return err
+}
+
+// RotatingFile is a log file that is moved aside once it reaches a size,
+// keeping a number of the files before it as path.1, path.2 and so on,
+// the lower the newer
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+// OpenRotatingFile appends to the file at path, rotating it once a write
+// would take it past maxSize bytes. Zero maxSize never rotates, and zero
+// maxBackups keeps no old files.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate moves each kept file one along, dropping the oldest, and starts a
+// new file at path
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	f.file = nil
+
+	if f.maxBackups == 0 {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove log file: %w", err)
+		}
+		return f.open()
+	}
+	// Removed first, as renaming over a file fails on Windows
+	oldest := fmt.Sprintf("%s.%d", f.path, f.maxBackups)
+	if err := os.Remove(oldest); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove old log file: %w", err)
+	}
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", f.path, i)
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", f.path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return f.open()
+}
+
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contextdb.log")
+	file, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	output := NewWriterOutput(file)
+	for _, line := range []string{"first", "second", "third", "fourth"} {
+		if err := output.Write(INFO, line); err != nil {
+			t.Fatalf("Failed to write %s: %v", line, err)
+		}
+	}
+
+	// Each line takes the file past 10 bytes, so each is in a file of its
+	// own, and only two old ones are kept
+	expected := map[string]string{path: "fourth\n", path + ".1": "third\n", path + ".2": "second\n"}
+	for name, content := range expected {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if string(data) != content {
+			t.Errorf("Expected %s to hold %q, got %q", name, content, data)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected no third old file, got %v", err)
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	var lines []string
+	SetOutput(outputFunc(func(level LogLevel, line string) error {
+		lines = append(lines, line)
+		return nil
+	}))
+	defer SetOutput(nil)
+	defer ResetComponentLevel("test")
+	defer SetGlobalLevel(GlobalLevel())
+	SetGlobalLevel(INFO)
+
+	logger := NewLogger("test")
+	SetComponentLevel("test", WARN)
+	logger.Info("hidden")
+	logger.Warn("shown")
+	ResetComponentLevel("test")
+	logger.Info("shown again")
+
+	if len(lines) != 2 || !strings.Contains(lines[0], "[WARN] test: shown") || !strings.Contains(lines[1], "shown again") {
+		t.Errorf("Expected the warning and the later info, got %q", lines)
+	}
+}
+
+type outputFunc func(level LogLevel, line string) error
+
+func (f outputFunc) Write(level LogLevel, line string) error {
+	return f(level, line)
+}
@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrUnknownLevel = errors.New("unknown log level")
+
+// ParseLevel reads a level by name, as "debug", "info", "warn" or "error"
+func ParseLevel(name string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return DEBUG, nil
+	case "info":
+		return INFO, nil
+	case "warn", "warning":
+		return WARN, nil
+	case "error":
+		return ERROR, nil
+	case "fatal":
+		return FATAL, nil
+	default:
+		return INFO, fmt.Errorf("%w: %q", ErrUnknownLevel, name)
+	}
+}
+
+// settings are shared by every logger, so they can be changed while running
+// without reaching each one. The level and format start from the LOG_LEVEL
+// and LOG_FORMAT environment variables.
+var settings = struct {
+	sync.RWMutex
+	level      LogLevel
+	components map[string]LogLevel
+	jsonFormat bool
+	output     Output
+	// known are the components loggers have been made for
+	known map[string]bool
+}{
+	level:      DefaultLevel(),
+	components: map[string]LogLevel{},
+	jsonFormat: DefaultJSONFormat(),
+	known:      map[string]bool{},
+}
+
+// DefaultLevel is the level LOG_LEVEL names, or INFO
+func DefaultLevel() LogLevel {
+	level, err := ParseLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		return INFO
+	}
+	return level
+}
+
+// DefaultJSONFormat reports whether LOG_FORMAT asks for JSON
+func DefaultJSONFormat() bool {
+	return os.Getenv("LOG_FORMAT") == "json"
+}
+
+func register(component string) {
+	settings.Lock()
+	defer settings.Unlock()
+	settings.known[component] = true
+}
+
+// current returns how component logs: its level, whether in JSON, and where,
+// nil for the standard library's logger
+func current(component string) (LogLevel, bool, Output) {
+	settings.RLock()
+	defer settings.RUnlock()
+
+	level, ok := settings.components[component]
+	if !ok {
+		level = settings.level
+	}
+	return level, settings.jsonFormat, settings.output
+}
+
+// SetOutput sends every entry to output, or to the standard library's logger
+// when it is nil
+func SetOutput(output Output) {
+	settings.Lock()
+	defer settings.Unlock()
+	settings.output = output
+}
+
+// UseJSONFormat writes entries as JSON, or as text, for loggers not given a
+// format of their own
+func UseJSONFormat(jsonFormat bool) {
+	settings.Lock()
+	defer settings.Unlock()
+	settings.jsonFormat = jsonFormat
+}
+
+// SetGlobalLevel is the level components without one of their own log at
+func SetGlobalLevel(level LogLevel) {
+	settings.Lock()
+	defer settings.Unlock()
+	settings.level = level
+}
+
+func GlobalLevel() LogLevel {
+	settings.RLock()
+	defer settings.RUnlock()
+	return settings.level
+}
+
+// SetComponentLevel logs component at level, whatever the global level
+func SetComponentLevel(component string, level LogLevel) {
+	settings.Lock()
+	defer settings.Unlock()
+	settings.components[component] = level
+}
+
+// ResetComponentLevel returns component to the global level
+func ResetComponentLevel(component string) {
+	settings.Lock()
+	defer settings.Unlock()
+	delete(settings.components, component)
+}
+
+// ComponentLevels returns the levels components were given of their own
+func ComponentLevels() map[string]LogLevel {
+	settings.RLock()
+	defer settings.RUnlock()
+
+	levels := make(map[string]LogLevel, len(settings.components))
+	for component, level := range settings.components {
+		levels[component] = level
+	}
+	return levels
+}
+
+// Components lists the components loggers have been made for, sorted
+func Components() []string {
+	settings.RLock()
+	defer settings.RUnlock()
+
+	components := make([]string, 0, len(settings.known))
+	for component := range settings.known {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	return components
+}
@@ -0,0 +1,8 @@
+//go:build windows || plan9
+
+package logging
+
+// NewSyslogOutput fails, as there is no syslog here to log to
+func NewSyslogOutput(network, address, tag string) (Output, error) {
+	return nil, ErrSyslogUnsupported
+}
@@ -0,0 +1,43 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// syslogOutput files each entry under the syslog severity of its level
+type syslogOutput struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogOutput logs to the syslog daemon at address over network, such
+// as "udp" and "logs.example.com:514", or to the local one when both are
+// empty, with entries tagged tag
+func NewSyslogOutput(network, address, tag string) (Output, error) {
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &syslogOutput{writer: writer}, nil
+}
+
+func (o *syslogOutput) Write(level LogLevel, line string) error {
+	switch level {
+	case DEBUG:
+		return o.writer.Debug(line)
+	case INFO:
+		return o.writer.Info(line)
+	case WARN:
+		return o.writer.Warning(line)
+	case ERROR:
+		return o.writer.Err(line)
+	default:
+		return o.writer.Crit(line)
+	}
+}
+
+func (o *syslogOutput) Close() error {
+	return o.writer.Close()
+}
@@ -0,0 +1,263 @@
+package lsp
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const (
+	shortIDLength  = 12
+	excerptLength  = 120
+	unknownIntent  = "unknown"
+	dateFormat     = "2006-01-02"
+	maxHoverThread = 5
+)
+
+// fileContext is what the store knows about one open file
+type fileContext struct {
+	documentID    string
+	spans         []positioning.LineSpan
+	conversations []collaboration.AnchoredConversation
+	operations    map[operations.OperationID]*operations.Operation
+}
+
+func (s *Server) hover(ctx gocontext.Context, params TextDocumentPositionParams) (*Hover, error) {
+	fc, err := s.load(ctx, params.TextDocument.URI)
+	if fc == nil || err != nil {
+		return nil, err
+	}
+
+	line := params.Position.Line + 1
+	var span *positioning.LineSpan
+	for i := range fc.spans {
+		if fc.spans[i].FirstLine <= line && line <= fc.spans[i].LastLine {
+			span = &fc.spans[i]
+			break
+		}
+	}
+
+	var threads []collaboration.AnchoredConversation
+	for _, anchored := range fc.conversations {
+		if anchored.FirstLine <= line && line <= anchored.LastLine {
+			threads = append(threads, anchored)
+		}
+	}
+
+	if span == nil && len(threads) == 0 {
+		return nil, nil
+	}
+
+	var value strings.Builder
+	hoverRange := Range{Start: Position{Line: params.Position.Line}, End: Position{Line: line}}
+	if span != nil {
+		hoverRange = Range{Start: Position{Line: span.FirstLine - 1}, End: Position{Line: span.LastLine}}
+		s.writeOrigin(ctx, &value, fc, span.Construct)
+	}
+	if len(threads) > 0 {
+		if value.Len() > 0 {
+			value.WriteString("\n---\n\n")
+		}
+		writeConversations(&value, threads)
+	}
+
+	return &Hover{
+		Contents: MarkupContent{Kind: MarkupMarkdown, Value: value.String()},
+		Range:    &hoverRange,
+	}, nil
+}
+
+// codeLenses puts a lens above each run of lines created by the same
+// operation and above every anchored conversation
+func (s *Server) codeLenses(ctx gocontext.Context, params CodeLensParams) ([]CodeLens, error) {
+	lenses := []CodeLens{}
+
+	fc, err := s.load(ctx, params.TextDocument.URI)
+	if fc == nil || err != nil {
+		return lenses, err
+	}
+
+	var previous operations.OperationID
+	for _, span := range fc.spans {
+		opID := span.Construct.CreatedBy
+		if opID == previous || isOnlyWhitespace(span.Construct.Content) {
+			continue
+		}
+		previous = opID
+
+		title := "created by operation " + shortID(string(opID))
+		if op := s.operation(ctx, fc, opID); op != nil {
+			title = fmt.Sprintf("%s · %s", shortID(string(op.Author)), op.Timestamp.Format(dateFormat))
+			if intent := s.intentOf(op); intent != "" {
+				title += " · " + intent
+			}
+		}
+		lenses = append(lenses, CodeLens{
+			Range:   lineRange(span.FirstLine),
+			Command: &Command{Title: title, Command: CommandShowOperation, Arguments: []interface{}{string(opID)}},
+		})
+	}
+
+	for _, anchored := range fc.conversations {
+		thread := anchored.Thread
+		lenses = append(lenses, CodeLens{
+			Range: lineRange(anchored.FirstLine),
+			Command: &Command{
+				Title:     fmt.Sprintf("%s (%s, %s)", thread.Title, thread.Status, plural(len(thread.Messages), "message")),
+				Command:   CommandShowConversation,
+				Arguments: []interface{}{string(thread.ID)},
+			},
+		})
+	}
+
+	return lenses, nil
+}
+
+// load reads the document behind uri and locates its conversations. It
+// returns nil without error for files the store doesn't track.
+func (s *Server) load(ctx gocontext.Context, uri string) (*fileContext, error) {
+	if s.refresh != nil {
+		if err := s.refresh(); err != nil {
+			s.logger.Warn("Failed to refresh conversations", map[string]interface{}{"error": err.Error()})
+		}
+	}
+
+	documentID, err := s.documentID(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := s.store.GetDocument(ctx, documentID)
+	if errors.Is(err, storage.ErrDocumentNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileContext{
+		documentID:    documentID,
+		spans:         doc.LineSpans(),
+		conversations: s.engine.ConversationsInDocument(ctx, doc),
+		operations:    make(map[operations.OperationID]*operations.Operation),
+	}, nil
+}
+
+// documentID maps a file URI to the path relative to the store's directory
+// that ingest names documents by
+func (s *Server) documentID(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("%s: %s", ErrUnsupportedURI, uri)}
+	}
+
+	rel, err := filepath.Rel(s.root, filepath.FromSlash(u.Path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("%s: %s", ErrUnsupportedURI, uri)}
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// operation fetches an operation once per request, nil when it's gone
+func (s *Server) operation(ctx gocontext.Context, fc *fileContext, id operations.OperationID) *operations.Operation {
+	if op, seen := fc.operations[id]; seen {
+		return op
+	}
+
+	op, err := s.store.GetOperation(ctx, id)
+	if err != nil {
+		op = nil
+	}
+	fc.operations[id] = op
+	return op
+}
+
+// intentOf prefers the intent recorded with the operation and falls back to
+// the analyzer's guess from its content
+func (s *Server) intentOf(op *operations.Operation) string {
+	if op.Metadata.Intent != "" {
+		return op.Metadata.Intent
+	}
+
+	analysis, err := s.engine.AnalyzeChangeIntent([]*operations.Operation{op})
+	if err != nil || analysis.PrimaryIntent == unknownIntent {
+		return ""
+	}
+	return analysis.PrimaryIntent + " (inferred)"
+}
+
+func (s *Server) writeOrigin(ctx gocontext.Context, value *strings.Builder, fc *fileContext, construct *positioning.Construct) {
+	op := s.operation(ctx, fc, construct.CreatedBy)
+	if op == nil {
+		fmt.Fprintf(value, "**Operation** `%s` (no longer in the store)\n", shortID(string(construct.CreatedBy)))
+		return
+	}
+
+	fmt.Fprintf(value, "**Operation** `%s` by `%s` on %s\n", shortID(string(op.ID)), shortID(string(op.Author)), op.Timestamp.Format(dateFormat))
+	if intent := s.intentOf(op); intent != "" {
+		fmt.Fprintf(value, "\n**Intent:** %s\n", intent)
+	}
+	if commit := op.Metadata.Context["commit"]; commit != "" {
+		fmt.Fprintf(value, "\n**Commit** `%s`: %s\n", shortID(commit), excerpt(op.Metadata.Context["commit_message"]))
+	}
+	if construct.ModifiedBy != "" && construct.ModifiedBy != construct.CreatedBy {
+		fmt.Fprintf(value, "\nLast modified by operation `%s`\n", shortID(string(construct.ModifiedBy)))
+	}
+}
+
+func writeConversations(value *strings.Builder, threads []collaboration.AnchoredConversation) {
+	fmt.Fprintf(value, "**Conversations** (%d)\n\n", len(threads))
+	for i, anchored := range threads {
+		if i == maxHoverThread {
+			fmt.Fprintf(value, "- and %d more\n", len(threads)-maxHoverThread)
+			break
+		}
+
+		thread := anchored.Thread
+		fmt.Fprintf(value, "- **%s** (%s, %s)", thread.Title, thread.Status, plural(len(thread.Messages), "message"))
+		if len(thread.Messages) > 0 {
+			last := thread.Messages[len(thread.Messages)-1]
+			fmt.Fprintf(value, "\n  `%s`: %s", shortID(string(last.AuthorID)), excerpt(last.Content))
+		}
+		value.WriteString("\n")
+	}
+}
+
+func lineRange(line int) Range {
+	return Range{Start: Position{Line: line - 1}, End: Position{Line: line - 1}}
+}
+
+func shortID(id string) string {
+	if len(id) > shortIDLength {
+		return id[:shortIDLength]
+	}
+	return id
+}
+
+// excerpt is the first line of content, cut to fit in a hover
+func excerpt(content string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	if runes := []rune(line); len(runes) > excerptLength {
+		line = strings.TrimSpace(string(runes[:excerptLength])) + "..."
+	}
+	return line
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func isOnlyWhitespace(content string) bool {
+	return strings.TrimSpace(content) == ""
+}
@@ -0,0 +1,9 @@
+package lsp
+
+import "errors"
+
+var (
+	ErrInvalidMessage  = errors.New("invalid LSP message")
+	ErrUnsupportedURI  = errors.New("only file URIs inside the store's directory are supported")
+	ErrExitBeforeClose = errors.New("client exited without shutting down the server")
+)
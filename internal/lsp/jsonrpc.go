@@ -0,0 +1,116 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// JSON-RPC error codes used by LSP
+const (
+	codeParseError           = -32700
+	codeInvalidRequest       = -32600
+	codeMethodNotFound       = -32601
+	codeInvalidParams        = -32602
+	codeInternalError        = -32603
+	codeServerNotInitialized = -32002
+)
+
+// message is any JSON-RPC message. Requests carry an ID and a method,
+// notifications only a method and responses only an ID.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+func (m *message) isRequest() bool {
+	return m.ID != nil && m.Method != ""
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+// conn reads and writes messages framed with a Content-Length header
+type conn struct {
+	reader *textproto.Reader
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{
+		reader: textproto.NewReader(bufio.NewReader(r)),
+		writer: w,
+	}
+}
+
+func (c *conn) read() (*message, error) {
+	header, err := c.reader.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("%w: missing or invalid Content-Length", ErrInvalidMessage)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.reader.R, body); err != nil {
+		return nil, err
+	}
+
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		// Still answer the request so the client isn't left waiting on it
+		return &msg, &responseError{Code: codeParseError, Message: err.Error()}
+	}
+	return &msg, nil
+}
+
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.writer.Write(body)
+	return err
+}
+
+func (c *conn) reply(id *json.RawMessage, result interface{}, err error) error {
+	msg := &message{ID: id}
+	if err == nil {
+		// A null result is valid, e.g. no hover, but omitempty would drop the field
+		if result == nil {
+			result = json.RawMessage("null")
+		}
+		msg.Result = result
+	} else if rpcErr, ok := err.(*responseError); ok {
+		msg.Error = rpcErr
+	} else {
+		msg.Error = &responseError{Code: codeInternalError, Message: err.Error()}
+	}
+	return c.write(msg)
+}
@@ -0,0 +1,83 @@
+package lsp
+
+// The subset of the Language Server Protocol the server speaks. Field names
+// follow the specification so the types marshal to what editors expect.
+
+// Position is zero-based, lines and characters alike
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type CodeLensParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type InitializeParams struct {
+	ProcessID *int    `json:"processId"`
+	RootURI   *string `json:"rootUri"`
+}
+
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   ServerInfo         `json:"serverInfo"`
+}
+
+type ServerCapabilities struct {
+	// TextDocumentSync is 0 since content comes from the store, not the editor
+	TextDocumentSync int              `json:"textDocumentSync"`
+	HoverProvider    bool             `json:"hoverProvider"`
+	CodeLensProvider *CodeLensOptions `json:"codeLensProvider,omitempty"`
+}
+
+type CodeLensOptions struct {
+	ResolveProvider bool `json:"resolveProvider"`
+}
+
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+const MarkupMarkdown = "markdown"
+
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+type CodeLens struct {
+	Range   Range    `json:"range"`
+	Command *Command `json:"command,omitempty"`
+}
+
+type Command struct {
+	Title     string        `json:"title"`
+	Command   string        `json:"command"`
+	Arguments []interface{} `json:"arguments,omitempty"`
+}
+
+// Commands attached to code lenses, for editor extensions to handle
+const (
+	CommandShowConversation = "contextdb.showConversation"
+	CommandShowOperation    = "contextdb.showOperation"
+)
@@ -0,0 +1,194 @@
+// Package lsp is a minimal Language Server that shows what a ContextDB store
+// knows about the code under the cursor: the operation that created it, the
+// intent behind it and the conversations anchored to it, as hover content and
+// code lenses in any LSP-capable editor.
+package lsp
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/logging"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const serverName = "contextdb"
+
+// Server answers requests from one editor. Documents are read from the store
+// on every request rather than synced from the editor, so lines refer to the
+// content last recorded in ContextDB.
+type Server struct {
+	root        string
+	engine      *collaboration.CollaborationEngine
+	store       storage.Store
+	logger      *logging.Logger
+	refresh     func() error
+	initialized bool
+	shutdown    bool
+}
+
+type ServerOption func(*Server)
+
+// WithRefresh runs fn before answering each request, e.g. to reload
+// conversations a running API server has saved since the editor connected
+func WithRefresh(fn func() error) ServerOption {
+	return func(s *Server) {
+		s.refresh = fn
+	}
+}
+
+// NewServer serves documents from store, whose IDs are paths relative to basePath
+func NewServer(basePath string, engine *collaboration.CollaborationEngine, store storage.Store, opts ...ServerOption) (*Server, error) {
+	root, err := filepath.Abs(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		root:   root,
+		engine: engine,
+		store:  store,
+		logger: logging.NewLogger("lsp"),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Serve reads requests from r and writes responses to w until the client
+// sends exit, r is closed or ctx is done. Exiting without a shutdown request
+// returns ErrExitBeforeClose, as the protocol asks for a failing exit code.
+func (s *Server) Serve(ctx gocontext.Context, r io.Reader, w io.Writer) error {
+	c := newConn(r, w)
+
+	type incoming struct {
+		msg *message
+		err error
+	}
+	messages := make(chan incoming)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			msg, err := c.read()
+			select {
+			case messages <- incoming{msg, err}:
+			case <-done:
+				return
+			}
+			if msg == nil && err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		var in incoming
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case in = <-messages:
+		}
+
+		if in.err != nil {
+			if rpcErr, ok := in.err.(*responseError); ok {
+				if err := c.reply(nil, nil, rpcErr); err != nil {
+					return err
+				}
+				continue
+			}
+			if errors.Is(in.err, io.EOF) {
+				return nil
+			}
+			return in.err
+		}
+
+		if in.msg.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitBeforeClose
+			}
+			return nil
+		}
+
+		result, err := s.handle(ctx, in.msg)
+		if !in.msg.isRequest() {
+			if err != nil {
+				s.logger.Warn("Notification failed", map[string]interface{}{
+					"method": in.msg.Method,
+					"error":  err.Error(),
+				})
+			}
+			continue
+		}
+		if err := c.reply(in.msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(ctx gocontext.Context, msg *message) (interface{}, error) {
+	if msg.Method == "initialize" {
+		s.initialized = true
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				HoverProvider:    true,
+				CodeLensProvider: &CodeLensOptions{},
+			},
+			ServerInfo: ServerInfo{Name: serverName},
+		}, nil
+	}
+
+	if !s.initialized {
+		if !msg.isRequest() {
+			return nil, nil
+		}
+		return nil, &responseError{Code: codeServerNotInitialized, Message: "server not initialized"}
+	}
+	if s.shutdown {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		hover, err := s.hover(ctx, params)
+		if hover == nil {
+			// Nothing is known about the line, which the protocol answers with null
+			return nil, err
+		}
+		return hover, err
+
+	case "textDocument/codeLens":
+		var params CodeLensParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.codeLenses(ctx, params)
+	}
+
+	if msg.isRequest() {
+		return nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method}
+	}
+	// Document sync and other notifications don't affect what the store knows
+	return nil, nil
+}
+
+func decodeParams(msg *message, params interface{}) error {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package lsp
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// testClient talks to a Server over pipes the way an editor would
+type testClient struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	done   chan error
+	close  func()
+}
+
+func startServer(t *testing.T) (*testClient, string) {
+	t.Helper()
+	ctx := gocontext.Background()
+
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	engine := collaboration.NewCollaborationEngine(store)
+
+	op := &operations.Operation{
+		ID:   operations.NewOperationID([]byte("main.go")),
+		Type: operations.OpInsert,
+		Position: operations.NewLogootPosition([]operations.PositionSegment{
+			{Value: big.NewInt(1), AuthorID: "alice"},
+		}),
+		Content:   "package main\n\nfunc main() {\n\tprintln(1)\n}\n",
+		Author:    "alice",
+		Timestamp: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Metadata: operations.OperationMeta{
+			Intent:  "bootstrap",
+			Context: map[string]string{"document_id": "cmd/main.go"},
+		},
+	}
+	if err := engine.ProcessOperation(ctx, op, ""); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+
+	anchor := addressing.NewStableAddress("acme/app", op.ID, addressing.PositionRange{Start: op.Position, End: op.Position})
+	anchor.Fragment = addressing.LineFragment(3, 5)
+	if _, err := engine.ConversationManager().CreateConversation(anchor, "bob", "Entry point", "Should this exit non-zero?"); err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+
+	root := t.TempDir()
+	server, err := NewServer(root, engine, store)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	client := &testClient{
+		t:    t,
+		conn: newConn(clientReader, clientWriter),
+		done: make(chan error, 1),
+		close: func() {
+			clientWriter.Close()
+			serverWriter.Close()
+		},
+	}
+	go func() {
+		client.done <- server.Serve(ctx, serverReader, serverWriter)
+	}()
+	t.Cleanup(client.close)
+
+	return client, "file://" + filepath.ToSlash(filepath.Join(root, "cmd", "main.go"))
+}
+
+func (c *testClient) notify(method string, params interface{}) {
+	c.t.Helper()
+
+	if err := c.conn.write(&message{Method: method, Params: mustMarshal(params)}); err != nil {
+		c.t.Fatalf("Failed to send %s: %v", method, err)
+	}
+}
+
+func (c *testClient) call(method string, params interface{}, result interface{}) *responseError {
+	c.t.Helper()
+
+	c.nextID++
+	id := mustMarshal(c.nextID)
+	if err := c.conn.write(&message{ID: &id, Method: method, Params: mustMarshal(params)}); err != nil {
+		c.t.Fatalf("Failed to send %s: %v", method, err)
+	}
+
+	response, err := c.conn.read()
+	if err != nil {
+		c.t.Fatalf("Failed to read %s response: %v", method, err)
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+
+	// Result was decoded into an interface, round trip it into the expected type
+	if result != nil {
+		if err := json.Unmarshal(mustMarshal(response.Result), result); err != nil {
+			c.t.Fatalf("Failed to decode %s result: %v", method, err)
+		}
+	}
+	return nil
+}
+
+func (c *testClient) initialize() {
+	c.t.Helper()
+
+	var result InitializeResult
+	if err := c.call("initialize", InitializeParams{}, &result); err != nil {
+		c.t.Fatalf("Failed to initialize: %v", err)
+	}
+	if !result.Capabilities.HoverProvider || result.Capabilities.CodeLensProvider == nil {
+		c.t.Fatalf("Expected hover and code lens capabilities, got %+v", result.Capabilities)
+	}
+	c.notify("initialized", struct{}{})
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestServer_Hover(t *testing.T) {
+	client, uri := startServer(t)
+	client.initialize()
+
+	var hover *Hover
+	if err := client.call("textDocument/hover", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: 3, Character: 2},
+	}, &hover); err != nil {
+		t.Fatalf("Failed to hover: %v", err)
+	}
+	if hover == nil {
+		t.Fatal("Expected hover content")
+	}
+
+	for _, expected := range []string{"bootstrap", "2024-03-01", "Entry point", "Should this exit non-zero?"} {
+		if !strings.Contains(hover.Contents.Value, expected) {
+			t.Errorf("Expected hover to mention %q, got:\n%s", expected, hover.Contents.Value)
+		}
+	}
+
+	// Line 1 is outside the conversation but still has an origin
+	if err := client.call("textDocument/hover", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: 0},
+	}, &hover); err != nil {
+		t.Fatalf("Failed to hover: %v", err)
+	}
+	if hover == nil || strings.Contains(hover.Contents.Value, "Entry point") {
+		t.Errorf("Expected only the origin on line 1, got %+v", hover)
+	}
+
+	// Untracked files have nothing to show
+	hover = &Hover{}
+	if err := client.call("textDocument/hover", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: strings.Replace(uri, "main.go", "other.go", 1)},
+	}, &hover); err != nil {
+		t.Fatalf("Failed to hover: %v", err)
+	}
+	if hover != nil {
+		t.Errorf("Expected no hover for an untracked file, got %+v", hover)
+	}
+}
+
+func TestServer_CodeLens(t *testing.T) {
+	client, uri := startServer(t)
+	client.initialize()
+
+	var lenses []CodeLens
+	if err := client.call("textDocument/codeLens", CodeLensParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &lenses); err != nil {
+		t.Fatalf("Failed to get code lenses: %v", err)
+	}
+	if len(lenses) != 2 {
+		t.Fatalf("Expected an operation and a conversation lens, got %+v", lenses)
+	}
+
+	if lenses[0].Range.Start.Line != 0 || lenses[0].Command.Command != CommandShowOperation || !strings.Contains(lenses[0].Command.Title, "bootstrap") {
+		t.Errorf("Unexpected operation lens %+v", lenses[0])
+	}
+	if lenses[1].Range.Start.Line != 2 || lenses[1].Command.Command != CommandShowConversation || !strings.Contains(lenses[1].Command.Title, "Entry point") {
+		t.Errorf("Unexpected conversation lens %+v", lenses[1])
+	}
+}
+
+func TestServer_Lifecycle(t *testing.T) {
+	client, uri := startServer(t)
+
+	if err := client.call("textDocument/codeLens", CodeLensParams{TextDocument: TextDocumentIdentifier{URI: uri}}, nil); err == nil || err.Code != codeServerNotInitialized {
+		t.Errorf("Expected a not initialized error, got %v", err)
+	}
+
+	client.initialize()
+	if err := client.call("textDocument/definition", TextDocumentPositionParams{}, nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("Expected method not found, got %v", err)
+	}
+	if err := client.call("textDocument/hover", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: "untitled:Untitled-1"},
+	}, nil); err == nil || err.Code != codeInvalidParams {
+		t.Errorf("Expected non-file URIs to be rejected, got %v", err)
+	}
+
+	if err := client.call("shutdown", nil, nil); err != nil {
+		t.Fatalf("Failed to shut down: %v", err)
+	}
+	client.notify("exit", nil)
+
+	select {
+	case err := <-client.done:
+		if err != nil {
+			t.Errorf("Expected a clean exit after shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not exit")
+	}
+}
+
+func TestServer_ExitWithoutShutdown(t *testing.T) {
+	client, _ := startServer(t)
+	client.initialize()
+	client.notify("exit", nil)
+
+	select {
+	case err := <-client.done:
+		if !errors.Is(err, ErrExitBeforeClose) {
+			t.Errorf("Expected ErrExitBeforeClose, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not exit")
+	}
+}
@@ -0,0 +1,8 @@
+package mcp
+
+import "errors"
+
+var (
+	ErrUnknownTool     = errors.New("unknown tool")
+	ErrInvalidArgument = errors.New("invalid argument")
+)
@@ -0,0 +1,101 @@
+package mcp
+
+import (
+	"encoding/json"
+)
+
+// The subset of the Model Context Protocol the server speaks: JSON-RPC 2.0
+// messages, one per line, with the tools capability.
+
+// ProtocolVersion is the newest protocol revision the server implements
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are echoed back when a client asks for one of them
+var supportedVersions = map[string]bool{
+	"2024-11-05": true,
+	"2025-03-26": true,
+	"2025-06-18": true,
+}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+func (m *message) isRequest() bool {
+	return m.ID != nil && m.Method != ""
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+type initializeParams struct {
+	ProtocolVersion string `json:"protocolVersion"`
+}
+
+type initializeResult struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    serverCapabilities `json:"capabilities"`
+	ServerInfo      implementation     `json:"serverInfo"`
+	Instructions    string             `json:"instructions,omitempty"`
+}
+
+type serverCapabilities struct {
+	Tools *toolsCapability `json:"tools,omitempty"`
+}
+
+type toolsCapability struct {
+	ListChanged bool `json:"listChanged"`
+}
+
+type implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Tool describes one tool to the client. InputSchema is a JSON Schema object.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+type listToolsResult struct {
+	Tools []Tool `json:"tools"`
+}
+
+type callToolParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// callToolResult carries a tool's output as text. Failures the model can act
+// on, like a missing document, are results with IsError set rather than
+// protocol errors.
+type callToolResult struct {
+	Content []content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+type content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
@@ -0,0 +1,270 @@
+// Package mcp serves a ContextDB store over the Model Context Protocol, giving
+// LLM agents tools to search history and conversations, read the context
+// behind an operation and start conversations about code.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/logging"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const (
+	serverName    = "contextdb"
+	serverVersion = "1.0.0-mvp"
+
+	// maxMessageSize bounds a single line so a broken client can't exhaust memory
+	maxMessageSize = 4 << 20
+)
+
+const instructions = `ContextDB records code as operations, each with an author, time and often an
+intent, and keeps conversations anchored to ranges of code. Use search_context
+to find operations, conversations and code, get_document_history and
+get_operation_context to learn why code looks the way it does, and
+create_conversation to leave a note or question on specific lines.`
+
+// Server answers one MCP client
+type Server struct {
+	engine     *collaboration.CollaborationEngine
+	store      storage.Store
+	repository addressing.RepositoryID
+	author     operations.AuthorID
+	refresh    func() error
+	save       func() error
+	tools      map[string]tool
+	logger     *logging.Logger
+}
+
+type ServerOption func(*Server)
+
+// WithRepository names the repository in addresses of new conversations
+func WithRepository(repo addressing.RepositoryID) ServerOption {
+	return func(s *Server) {
+		s.repository = repo
+	}
+}
+
+// WithAuthor sets who new conversations are attributed to when the tool call
+// doesn't say
+func WithAuthor(author operations.AuthorID) ServerOption {
+	return func(s *Server) {
+		s.author = author
+	}
+}
+
+// WithRefresh runs fn before each tool call, e.g. to reload conversations a
+// running API server has saved since the client connected
+func WithRefresh(fn func() error) ServerOption {
+	return func(s *Server) {
+		s.refresh = fn
+	}
+}
+
+// WithSave runs fn after a tool call changes conversations so they outlive
+// the server
+func WithSave(fn func() error) ServerOption {
+	return func(s *Server) {
+		s.save = fn
+	}
+}
+
+func NewServer(engine *collaboration.CollaborationEngine, store storage.Store, opts ...ServerOption) *Server {
+	s := &Server{
+		engine:     engine,
+		store:      store,
+		repository: "local",
+		author:     "mcp",
+		logger:     logging.NewLogger("mcp"),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.tools = s.registerTools()
+	return s
+}
+
+// Tools lists the tools the server offers, in a stable order
+func (s *Server) Tools() []Tool {
+	tools := make([]Tool, 0, len(toolOrder))
+	for _, name := range toolOrder {
+		tools = append(tools, s.tools[name].Tool)
+	}
+	return tools
+}
+
+// Serve reads newline-delimited JSON-RPC messages from r and writes responses
+// to w until r is closed or ctx is done
+func (s *Server) Serve(ctx gocontext.Context, r io.Reader, w io.Writer) error {
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-done:
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	var writeMutex sync.Mutex
+	write := func(msg *message) error {
+		msg.JSONRPC = "2.0"
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+
+	for {
+		var line []byte
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case line = <-lines:
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			if err := write(&message{Error: &responseError{Code: codeParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		result, err := s.handle(ctx, &msg)
+		if !msg.isRequest() {
+			if err != nil {
+				s.logger.Warn("Notification failed", map[string]interface{}{
+					"method": msg.Method,
+					"error":  err.Error(),
+				})
+			}
+			continue
+		}
+
+		response := &message{ID: msg.ID, Result: result}
+		if err != nil {
+			response.Result = nil
+			var rpcErr *responseError
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &responseError{Code: codeInternalError, Message: err.Error()}
+			}
+			response.Error = rpcErr
+		}
+		if err := write(response); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(ctx gocontext.Context, msg *message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		var params initializeParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+
+		version := ProtocolVersion
+		if supportedVersions[params.ProtocolVersion] {
+			version = params.ProtocolVersion
+		}
+		return initializeResult{
+			ProtocolVersion: version,
+			Capabilities:    serverCapabilities{Tools: &toolsCapability{}},
+			ServerInfo:      implementation{Name: serverName, Version: serverVersion},
+			Instructions:    instructions,
+		}, nil
+
+	case "ping":
+		return struct{}{}, nil
+
+	case "tools/list":
+		return listToolsResult{Tools: s.Tools()}, nil
+
+	case "tools/call":
+		var params callToolParams
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		t, exists := s.tools[params.Name]
+		if !exists {
+			return nil, &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("%s: %s", ErrUnknownTool, params.Name)}
+		}
+		return s.call(ctx, t, params.Arguments), nil
+	}
+
+	if msg.isRequest() {
+		return nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method}
+	}
+	// notifications/initialized, cancellations and progress need no answer
+	return nil, nil
+}
+
+// call runs a tool, reporting its failure to the model as a tool result
+func (s *Server) call(ctx gocontext.Context, t tool, args json.RawMessage) callToolResult {
+	if s.refresh != nil {
+		if err := s.refresh(); err != nil {
+			s.logger.Warn("Failed to refresh conversations", map[string]interface{}{"error": err.Error()})
+		}
+	}
+
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+
+	output, err := t.run(ctx, args)
+	if err == nil && t.changesConversations && s.save != nil {
+		err = s.save()
+	}
+	if err != nil {
+		return callToolResult{Content: []content{{Type: "text", Text: err.Error()}}, IsError: true}
+	}
+
+	text, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return callToolResult{Content: []content{{Type: "text", Text: err.Error()}}, IsError: true}
+	}
+	return callToolResult{Content: []content{{Type: "text", Text: string(text)}}}
+}
+
+func decodeParams(msg *message, params interface{}) error {
+	if len(msg.Params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package mcp
+
+import (
+	"bufio"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+type testClient struct {
+	t       *testing.T
+	writer  io.Writer
+	scanner *bufio.Scanner
+	nextID  int
+	saves   int
+	engine  *collaboration.CollaborationEngine
+	op      *operations.Operation
+}
+
+func startServer(t *testing.T) *testClient {
+	t.Helper()
+	ctx := gocontext.Background()
+
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	engine := collaboration.NewCollaborationEngine(store)
+
+	op := &operations.Operation{
+		ID:   operations.NewOperationID([]byte("main.go")),
+		Type: operations.OpInsert,
+		Position: operations.NewLogootPosition([]operations.PositionSegment{
+			{Value: big.NewInt(1), AuthorID: "alice"},
+		}),
+		Content:   "package main\n\nfunc main() {\n\tretryConnection()\n}\n",
+		Author:    "alice",
+		Timestamp: time.Now(),
+		Metadata: operations.OperationMeta{
+			Intent:  "resilience",
+			Context: map[string]string{"document_id": "main.go", "commit": "abc123", "commit_message": "Retry dropped connections"},
+		},
+	}
+	if err := engine.ProcessOperation(ctx, op, ""); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+
+	client := &testClient{t: t, engine: engine, op: op}
+	server := NewServer(engine, store,
+		WithRepository("acme/app"),
+		WithSave(func() error {
+			client.saves++
+			return nil
+		}),
+	)
+
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	client.writer = clientWriter
+	client.scanner = bufio.NewScanner(clientReader)
+
+	serveCtx, cancel := gocontext.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		server.Serve(serveCtx, serverReader, serverWriter)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		clientWriter.Close()
+		serverWriter.Close()
+		<-done
+	})
+
+	return client
+}
+
+func (c *testClient) request(method string, params interface{}) message {
+	c.t.Helper()
+
+	c.nextID++
+	data, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+	if _, err := c.writer.Write(append(data, '\n')); err != nil {
+		c.t.Fatalf("Failed to send %s: %v", method, err)
+	}
+
+	if !c.scanner.Scan() {
+		c.t.Fatalf("Failed to read %s response: %v", method, c.scanner.Err())
+	}
+	var response message
+	if err := json.Unmarshal(c.scanner.Bytes(), &response); err != nil {
+		c.t.Fatalf("Failed to decode %s response: %v", method, err)
+	}
+	if string(*response.ID) != fmt.Sprint(c.nextID) {
+		c.t.Fatalf("Expected response to request %d, got %s", c.nextID, *response.ID)
+	}
+	return response
+}
+
+// callTool returns the tool's text output decoded into out, or the error text
+func (c *testClient) callTool(name string, args interface{}, out interface{}) string {
+	c.t.Helper()
+
+	response := c.request("tools/call", map[string]interface{}{"name": name, "arguments": args})
+	if response.Error != nil {
+		c.t.Fatalf("Failed to call %s: %v", name, response.Error)
+	}
+
+	var result callToolResult
+	data, _ := json.Marshal(response.Result)
+	if err := json.Unmarshal(data, &result); err != nil || len(result.Content) != 1 {
+		c.t.Fatalf("Unexpected %s result %s", name, data)
+	}
+	if result.IsError {
+		return result.Content[0].Text
+	}
+	if err := json.Unmarshal([]byte(result.Content[0].Text), out); err != nil {
+		c.t.Fatalf("Failed to decode %s output: %v", name, err)
+	}
+	return ""
+}
+
+func TestServer_InitializeAndList(t *testing.T) {
+	client := startServer(t)
+
+	response := client.request("initialize", map[string]interface{}{"protocolVersion": "2024-11-05", "capabilities": map[string]interface{}{}})
+	result := response.Result.(map[string]interface{})
+	if result["protocolVersion"] != "2024-11-05" {
+		t.Errorf("Expected the client's supported version to be echoed, got %v", result["protocolVersion"])
+	}
+
+	response = client.request("tools/list", nil)
+	data, _ := json.Marshal(response.Result)
+	var tools listToolsResult
+	json.Unmarshal(data, &tools)
+	if len(tools.Tools) != len(toolOrder) {
+		t.Fatalf("Expected %d tools, got %d", len(toolOrder), len(tools.Tools))
+	}
+	for i, tool := range tools.Tools {
+		if tool.Name != toolOrder[i] || !json.Valid(tool.InputSchema) {
+			t.Errorf("Unexpected tool %d: %s with schema %s", i, tool.Name, tool.InputSchema)
+		}
+	}
+
+	if response := client.request("resources/list", nil); response.Error == nil || response.Error.Code != codeMethodNotFound {
+		t.Errorf("Expected method not found, got %+v", response.Error)
+	}
+	if response := client.request("tools/call", map[string]interface{}{"name": "drop_database"}); response.Error == nil || response.Error.Code != codeInvalidParams {
+		t.Errorf("Expected unknown tools to be rejected, got %+v", response.Error)
+	}
+}
+
+func TestServer_SearchAndHistory(t *testing.T) {
+	client := startServer(t)
+
+	var results []searchResult
+	if msg := client.callTool("search_context", map[string]interface{}{"query": "RETRYCONNECTION"}, &results); msg != "" {
+		t.Fatalf("Search failed: %s", msg)
+	}
+	kinds := map[string]bool{}
+	for _, result := range results {
+		kinds[result.Type] = true
+	}
+	if !kinds["operation"] || !kinds["code"] {
+		t.Errorf("Expected operation and code results, got %+v", results)
+	}
+	for _, result := range results {
+		if result.Type == "code" && (result.DocumentID != "main.go" || result.Line != 4) {
+			t.Errorf("Expected main.go line 4, got %+v", result)
+		}
+	}
+
+	if msg := client.callTool("search_context", map[string]interface{}{"query": " "}, &results); !strings.Contains(msg, "query is required") {
+		t.Errorf("Expected an invalid argument result, got %q", msg)
+	}
+
+	var history struct {
+		Operations []operationSummary `json:"operations"`
+	}
+	if msg := client.callTool("get_document_history", map[string]interface{}{"document_id": "main.go"}, &history); msg != "" {
+		t.Fatalf("History failed: %s", msg)
+	}
+	if len(history.Operations) != 1 || history.Operations[0].Intent != "resilience" || history.Operations[0].CommitMessage != "Retry dropped connections" {
+		t.Errorf("Unexpected history %+v", history.Operations)
+	}
+
+	if msg := client.callTool("get_document_history", map[string]interface{}{"document_id": "missing.go"}, &history); !strings.Contains(msg, "not found") {
+		t.Errorf("Expected a not found result, got %q", msg)
+	}
+}
+
+func TestServer_CreateConversationAndContext(t *testing.T) {
+	client := startServer(t)
+
+	var created conversationSummary
+	msg := client.callTool("create_conversation", map[string]interface{}{
+		"document_id": "main.go",
+		"start_line":  3,
+		"end_line":    5,
+		"title":       "Retry budget",
+		"content":     "How many retries before giving up?",
+		"author":      "agent",
+	}, &created)
+	if msg != "" {
+		t.Fatalf("Create failed: %s", msg)
+	}
+	if client.saves != 1 {
+		t.Errorf("Expected conversations to be saved once, got %d", client.saves)
+	}
+
+	thread, err := client.engine.GetConversation(created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get conversation: %v", err)
+	}
+	if thread.AnchorAddress.OperationID != client.op.ID || thread.AnchorAddress.Repository != addressing.RepositoryID("acme/app") {
+		t.Errorf("Unexpected anchor %+v", thread.AnchorAddress)
+	}
+	if first, last, ok := thread.AnchorAddress.FragmentLines(); !ok || first != 3 || last != 5 {
+		t.Errorf("Expected the anchor narrowed to lines 3-5, got %d-%d", first, last)
+	}
+
+	var context struct {
+		Intent        string                `json:"intent"`
+		FirstLine     int                   `json:"first_line"`
+		LastLine      int                   `json:"last_line"`
+		Conversations []conversationSummary `json:"conversations"`
+	}
+	if msg := client.callTool("get_operation_context", map[string]interface{}{"operation_id": string(client.op.ID)}, &context); msg != "" {
+		t.Fatalf("Context failed: %s", msg)
+	}
+	if context.Intent != "resilience" || context.FirstLine != 1 || context.LastLine != 5 {
+		t.Errorf("Unexpected context %+v", context)
+	}
+	if len(context.Conversations) != 1 || context.Conversations[0].FirstLine != 3 || context.Conversations[0].Title != "Retry budget" {
+		t.Errorf("Expected the new conversation on lines 3-5, got %+v", context.Conversations)
+	}
+
+	if msg := client.callTool("create_conversation", map[string]interface{}{"document_id": "main.go", "start_line": 1}, &created); !strings.Contains(msg, "content, title required") {
+		t.Errorf("Expected missing arguments to be reported, got %q", msg)
+	}
+	if msg := client.callTool("create_conversation", map[string]interface{}{
+		"document_id": "main.go", "start_line": 40, "title": "Past the end", "content": "?",
+	}, &created); msg == "" {
+		t.Error("Expected lines past the end of the document to be rejected")
+	}
+}
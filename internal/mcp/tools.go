@@ -0,0 +1,541 @@
+package mcp
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const (
+	defaultLimit     = 20
+	maxLimit         = 100
+	snippetLength    = 200
+	maxContentLength = 4000
+	unknownIntent    = "unknown"
+)
+
+type tool struct {
+	Tool
+	changesConversations bool
+	run                  func(ctx gocontext.Context, args json.RawMessage) (interface{}, error)
+}
+
+var toolOrder = []string{"search_context", "get_document_history", "get_operation_context", "create_conversation"}
+
+func (s *Server) registerTools() map[string]tool {
+	return map[string]tool{
+		"search_context": {
+			Tool: Tool{
+				Name:        "search_context",
+				Description: "Search operation content, conversations and current code for a case-insensitive phrase.",
+				InputSchema: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "query": {"type": "string", "description": "Text to look for"},
+    "type": {"type": "string", "enum": ["operation", "conversation", "code"], "description": "Only search one kind of result"},
+    "limit": {"type": "integer", "minimum": 1, "maximum": 100, "description": "Maximum results per kind, 20 by default"}
+  },
+  "required": ["query"]
+}`),
+			},
+			run: s.searchContext,
+		},
+		"get_document_history": {
+			Tool: Tool{
+				Name:        "get_document_history",
+				Description: "List the operations that shaped a document, newest first, and the conversations anchored in it with the lines they cover.",
+				InputSchema: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "document_id": {"type": "string", "description": "Document path relative to the repository root, e.g. src/main.go"},
+    "limit": {"type": "integer", "minimum": 1, "maximum": 100, "description": "Maximum operations, 20 by default"}
+  },
+  "required": ["document_id"]
+}`),
+			},
+			run: s.getDocumentHistory,
+		},
+		"get_operation_context": {
+			Tool: Tool{
+				Name:        "get_operation_context",
+				Description: "Explain one operation: its author, time, intent, commit, the lines it still accounts for and the conversations anchored to it.",
+				InputSchema: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "operation_id": {"type": "string", "description": "Full operation ID as returned by the other tools"}
+  },
+  "required": ["operation_id"]
+}`),
+			},
+			run: s.getOperationContext,
+		},
+		"create_conversation": {
+			Tool: Tool{
+				Name:        "create_conversation",
+				Description: "Start a conversation anchored to lines of a document. The anchor follows the code as it is edited.",
+				InputSchema: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "document_id": {"type": "string", "description": "Document path relative to the repository root"},
+    "start_line": {"type": "integer", "minimum": 1, "description": "First line, 1-based"},
+    "end_line": {"type": "integer", "minimum": 1, "description": "Last line, start_line by default"},
+    "title": {"type": "string"},
+    "content": {"type": "string", "description": "The first message"},
+    "author": {"type": "string", "description": "Who the conversation is attributed to"}
+  },
+  "required": ["document_id", "start_line", "title", "content"]
+}`),
+			},
+			changesConversations: true,
+			run:                  s.createConversation,
+		},
+	}
+}
+
+type searchResult struct {
+	Type       string     `json:"type"`
+	ID         string     `json:"id,omitempty"`
+	DocumentID string     `json:"document_id,omitempty"`
+	Line       int        `json:"line,omitempty"`
+	Title      string     `json:"title,omitempty"`
+	Status     string     `json:"status,omitempty"`
+	Author     string     `json:"author,omitempty"`
+	Intent     string     `json:"intent,omitempty"`
+	Snippet    string     `json:"snippet"`
+	Timestamp  *time.Time `json:"timestamp,omitempty"`
+}
+
+func (s *Server) searchContext(ctx gocontext.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Query string `json:"query"`
+		Type  string `json:"type"`
+		Limit int    `json:"limit"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	query := strings.ToLower(strings.TrimSpace(args.Query))
+	if query == "" {
+		return nil, fmt.Errorf("%w: query is required", ErrInvalidArgument)
+	}
+	switch args.Type {
+	case "", "operation", "conversation", "code":
+	default:
+		return nil, fmt.Errorf("%w: type must be operation, conversation or code", ErrInvalidArgument)
+	}
+	limit := clampLimit(args.Limit)
+
+	results := []searchResult{}
+
+	if args.Type == "" || args.Type == "operation" {
+		found := 0
+		err := s.store.ForEachOperationSince(ctx, time.Time{}, func(op *operations.Operation) error {
+			if found >= limit {
+				return storage.ErrStopIteration
+			}
+			if operations.NormalizeContentType(op.ContentType) == operations.ContentTypeBinary {
+				return nil
+			}
+			snippet, ok := matchingLine(op.Content, query)
+			if !ok {
+				return nil
+			}
+
+			found++
+			timestamp := op.Timestamp
+			intent, _ := s.intentOf(op)
+			results = append(results, searchResult{
+				Type:       "operation",
+				ID:         string(op.ID),
+				DocumentID: op.Metadata.DocumentID,
+				Author:     string(op.Author),
+				Intent:     intent,
+				Snippet:    snippet,
+				Timestamp:  &timestamp,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if args.Type == "" || args.Type == "conversation" {
+		threads, err := s.engine.ConversationManager().SearchConversations(query)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(threads, func(i, j int) bool {
+			return threads[i].UpdatedAt.After(threads[j].UpdatedAt)
+		})
+
+		for i, thread := range threads {
+			if i >= limit {
+				break
+			}
+			snippet := excerpt(thread.Title)
+			for _, message := range thread.Messages {
+				if line, ok := matchingLine(message.Content, query); ok {
+					snippet = line
+					break
+				}
+			}
+			updated := thread.UpdatedAt
+			results = append(results, searchResult{
+				Type:      "conversation",
+				ID:        string(thread.ID),
+				Title:     thread.Title,
+				Status:    string(thread.Status),
+				Snippet:   snippet,
+				Timestamp: &updated,
+			})
+		}
+	}
+
+	if args.Type == "" || args.Type == "code" {
+		documents, err := s.store.ListDocuments(ctx, false)
+		if err != nil {
+			return nil, err
+		}
+
+		found := 0
+		for _, documentID := range documents {
+			if found >= limit {
+				break
+			}
+			doc, err := s.store.GetDocument(ctx, documentID)
+			if err != nil {
+				return nil, err
+			}
+			rendered, _ := doc.RenderContentTypes(operations.ContentTypeText, operations.ContentTypeJSON)
+			for number, line := range strings.Split(rendered, "\n") {
+				if found >= limit {
+					break
+				}
+				if strings.Contains(strings.ToLower(line), query) {
+					found++
+					results = append(results, searchResult{
+						Type:       "code",
+						DocumentID: documentID,
+						Line:       number + 1,
+						Snippet:    excerpt(line),
+					})
+				}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+type operationSummary struct {
+	ID             operations.OperationID   `json:"id"`
+	Type           operations.OperationType `json:"type"`
+	Author         operations.AuthorID      `json:"author"`
+	Timestamp      time.Time                `json:"timestamp"`
+	Intent         string                   `json:"intent,omitempty"`
+	InferredIntent bool                     `json:"intent_inferred,omitempty"`
+	Commit         string                   `json:"commit,omitempty"`
+	CommitMessage  string                   `json:"commit_message,omitempty"`
+	Summary        string                   `json:"summary"`
+}
+
+type conversationSummary struct {
+	ID          context.ThreadID     `json:"id"`
+	Title       string               `json:"title"`
+	Status      context.ThreadStatus `json:"status"`
+	FirstLine   int                  `json:"first_line"`
+	LastLine    int                  `json:"last_line"`
+	Messages    int                  `json:"messages"`
+	LastMessage string               `json:"last_message,omitempty"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+func (s *Server) getDocumentHistory(ctx gocontext.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		DocumentID string `json:"document_id"`
+		Limit      int    `json:"limit"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.DocumentID == "" {
+		return nil, fmt.Errorf("%w: document_id is required", ErrInvalidArgument)
+	}
+	limit := clampLimit(args.Limit)
+
+	doc, err := s.document(ctx, args.DocumentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []operationSummary
+	total := 0
+	err = s.store.ForEachOperationSince(ctx, time.Time{}, func(op *operations.Operation) error {
+		if op.Metadata.DocumentID != args.DocumentID {
+			return nil
+		}
+		total++
+		ops = append(ops, s.summarize(op))
+		// Only the newest operations are returned, keep memory bounded by the limit
+		if len(ops) > limit {
+			ops = ops[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return struct {
+		DocumentID      string                `json:"document_id"`
+		Version         uint64                `json:"version"`
+		TotalOperations int                   `json:"total_operations"`
+		Operations      []operationSummary    `json:"operations"`
+		Conversations   []conversationSummary `json:"conversations"`
+	}{
+		DocumentID:      args.DocumentID,
+		Version:         doc.CurrentVersion(),
+		TotalOperations: total,
+		Operations:      ops,
+		Conversations:   summarizeConversations(s.engine.ConversationsInDocument(ctx, doc)),
+	}, nil
+}
+
+func (s *Server) getOperationContext(ctx gocontext.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		OperationID string `json:"operation_id"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return nil, err
+	}
+	if args.OperationID == "" {
+		return nil, fmt.Errorf("%w: operation_id is required", ErrInvalidArgument)
+	}
+
+	op, err := s.store.GetOperation(ctx, operations.OperationID(args.OperationID))
+	if errors.Is(err, storage.ErrOperationNotFound) {
+		return nil, fmt.Errorf("operation %s not found", args.OperationID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := struct {
+		operationSummary
+		DocumentID       string                   `json:"document_id,omitempty"`
+		Parents          []operations.OperationID `json:"parents,omitempty"`
+		FirstLine        int                      `json:"first_line,omitempty"`
+		LastLine         int                      `json:"last_line,omitempty"`
+		Content          string                   `json:"content"`
+		ContentTruncated bool                     `json:"content_truncated,omitempty"`
+		Conversations    []conversationSummary    `json:"conversations"`
+	}{
+		operationSummary: s.summarize(op),
+		DocumentID:       op.Metadata.DocumentID,
+		Parents:          op.Parents,
+		Content:          op.Content,
+		Conversations:    []conversationSummary{},
+	}
+	if runes := []rune(op.Content); len(runes) > maxContentLength {
+		result.Content = string(runes[:maxContentLength])
+		result.ContentTruncated = true
+	}
+
+	if result.DocumentID == "" {
+		return result, nil
+	}
+	doc, err := s.store.GetDocument(ctx, result.DocumentID)
+	if errors.Is(err, storage.ErrDocumentNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Lines the operation still accounts for, which later edits may have narrowed
+	for _, span := range doc.LineSpans() {
+		if span.Construct.CreatedBy != op.ID {
+			continue
+		}
+		if result.FirstLine == 0 {
+			result.FirstLine = span.FirstLine
+		}
+		result.LastLine = span.LastLine
+	}
+
+	var anchored []collaboration.AnchoredConversation
+	for _, conversation := range s.engine.ConversationsInDocument(ctx, doc) {
+		if conversation.Thread.AnchorAddress.OperationID == op.ID {
+			anchored = append(anchored, conversation)
+		}
+	}
+	result.Conversations = summarizeConversations(anchored)
+
+	return result, nil
+}
+
+func (s *Server) createConversation(ctx gocontext.Context, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		DocumentID string `json:"document_id"`
+		StartLine  int    `json:"start_line"`
+		EndLine    int    `json:"end_line"`
+		Title      string `json:"title"`
+		Content    string `json:"content"`
+		Author     string `json:"author"`
+	}
+	if err := decodeArgs(raw, &args); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for name, value := range map[string]string{"document_id": args.DocumentID, "title": args.Title, "content": args.Content} {
+		if strings.TrimSpace(value) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%w: %s required", ErrInvalidArgument, strings.Join(missing, ", "))
+	}
+	if args.EndLine == 0 {
+		args.EndLine = args.StartLine
+	}
+	if args.StartLine < 1 || args.EndLine < args.StartLine {
+		return nil, fmt.Errorf("%w: start_line must be at least 1 and end_line no less than it", ErrInvalidArgument)
+	}
+
+	doc, err := s.document(ctx, args.DocumentID)
+	if err != nil {
+		return nil, err
+	}
+	anchor, err := s.engine.AddressResolver().AddressForLines(s.repository, doc, args.StartLine, args.EndLine)
+	if err != nil {
+		return nil, err
+	}
+
+	author := s.author
+	if args.Author != "" {
+		author = operations.AuthorID(args.Author)
+	}
+	thread, err := s.engine.CreateConversation(anchor, author, args.Title, args.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	return summarizeConversations([]collaboration.AnchoredConversation{
+		{Thread: thread, FirstLine: args.StartLine, LastLine: args.EndLine},
+	})[0], nil
+}
+
+func (s *Server) document(ctx gocontext.Context, documentID string) (*positioning.Document, error) {
+	doc, err := s.store.GetDocument(ctx, documentID)
+	if errors.Is(err, storage.ErrDocumentNotFound) {
+		return nil, fmt.Errorf("document %s not found", documentID)
+	}
+	return doc, err
+}
+
+func (s *Server) summarize(op *operations.Operation) operationSummary {
+	intent, inferred := s.intentOf(op)
+	summary := excerpt(op.Content)
+	if operations.NormalizeContentType(op.ContentType) == operations.ContentTypeBinary {
+		summary = fmt.Sprintf("<binary, %d bytes>", op.Length)
+	}
+
+	return operationSummary{
+		ID:             op.ID,
+		Type:           op.Type,
+		Author:         op.Author,
+		Timestamp:      op.Timestamp,
+		Intent:         intent,
+		InferredIntent: inferred,
+		Commit:         op.Metadata.Context["commit"],
+		CommitMessage:  op.Metadata.Context["commit_message"],
+		Summary:        summary,
+	}
+}
+
+// intentOf prefers the intent recorded with the operation and falls back to
+// the analyzer's guess from its content
+func (s *Server) intentOf(op *operations.Operation) (string, bool) {
+	if op.Metadata.Intent != "" {
+		return op.Metadata.Intent, false
+	}
+
+	analysis, err := s.engine.AnalyzeChangeIntent([]*operations.Operation{op})
+	if err != nil || analysis.PrimaryIntent == unknownIntent {
+		return "", false
+	}
+	return analysis.PrimaryIntent, true
+}
+
+func summarizeConversations(anchored []collaboration.AnchoredConversation) []conversationSummary {
+	summaries := make([]conversationSummary, 0, len(anchored))
+	for _, conversation := range anchored {
+		thread := conversation.Thread
+		summary := conversationSummary{
+			ID:        thread.ID,
+			Title:     thread.Title,
+			Status:    thread.Status,
+			FirstLine: conversation.FirstLine,
+			LastLine:  conversation.LastLine,
+			Messages:  len(thread.Messages),
+			UpdatedAt: thread.UpdatedAt,
+		}
+		if len(thread.Messages) > 0 {
+			last := thread.Messages[len(thread.Messages)-1]
+			summary.LastMessage = fmt.Sprintf("%s: %s", last.AuthorID, excerpt(last.Content))
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+func decodeArgs(raw json.RawMessage, args interface{}) error {
+	if err := json.Unmarshal(raw, args); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+	return nil
+}
+
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return defaultLimit
+	}
+	return min(limit, maxLimit)
+}
+
+// matchingLine finds the first line of content containing the lowercase query
+func matchingLine(content, query string) (string, bool) {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.Contains(strings.ToLower(line), query) {
+			return excerpt(line), true
+		}
+	}
+	return "", false
+}
+
+func excerpt(text string) string {
+	text = strings.TrimSpace(text)
+	if line, _, found := strings.Cut(text, "\n"); found {
+		text = strings.TrimSpace(line)
+	}
+	if runes := []rune(text); len(runes) > snippetLength {
+		text = strings.TrimSpace(string(runes[:snippetLength])) + "..."
+	}
+	return text
+}
@@ -0,0 +1,157 @@
+package operations
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// TopoSort returns the operations parents first. Where parents leave the
+// order open, operations come in timestamp order and then by ID, so every
+// node sorts the same history the same way. Parents the DAG doesn't hold are
+// ignored.
+func (dag *OperationDAG) TopoSort() []*Operation {
+	dag.mutex.RLock()
+	defer dag.mutex.RUnlock()
+
+	waiting := make(map[OperationID]int, len(dag.operations))
+	ready := &operationQueue{}
+	for id, op := range dag.operations {
+		// A parent listed twice lists the child twice in children, so it counts twice
+		for _, parent := range op.Parents {
+			if _, known := dag.operations[parent]; known {
+				waiting[id]++
+			}
+		}
+		if waiting[id] == 0 {
+			heap.Push(ready, op)
+		}
+	}
+
+	sorted := make([]*Operation, 0, len(dag.operations))
+	for ready.Len() > 0 {
+		op := heap.Pop(ready).(*Operation)
+		sorted = append(sorted, op)
+		for _, child := range dag.children[op.ID] {
+			if _, known := dag.operations[child]; !known {
+				continue
+			}
+			waiting[child]--
+			if waiting[child] == 0 {
+				heap.Push(ready, dag.operations[child])
+			}
+		}
+	}
+	return sorted
+}
+
+// IsAncestor reports whether ancestor is in descendant's causal history. An
+// operation counts as its own ancestor.
+func (dag *OperationDAG) IsAncestor(ancestor, descendant OperationID) (bool, error) {
+	dag.mutex.RLock()
+	defer dag.mutex.RUnlock()
+
+	if _, exists := dag.operations[ancestor]; !exists {
+		return false, ErrOperationNotFound
+	}
+	if _, exists := dag.operations[descendant]; !exists {
+		return false, ErrOperationNotFound
+	}
+
+	found := false
+	dag.walkAncestors([]OperationID{descendant}, func(id OperationID) bool {
+		found = id == ancestor
+		return !found
+	})
+	return found, nil
+}
+
+// MergeBase returns the best common ancestors of a and b: those in both
+// histories that no other common ancestor descends from. There is usually
+// one, none when the histories never met, and more after criss-cross merges.
+// They are in timestamp order.
+func (dag *OperationDAG) MergeBase(a, b OperationID) ([]*Operation, error) {
+	dag.mutex.RLock()
+	defer dag.mutex.RUnlock()
+
+	if _, exists := dag.operations[a]; !exists {
+		return nil, ErrOperationNotFound
+	}
+	if _, exists := dag.operations[b]; !exists {
+		return nil, ErrOperationNotFound
+	}
+
+	ofA := make(map[OperationID]bool)
+	dag.walkAncestors([]OperationID{a}, func(id OperationID) bool {
+		ofA[id] = true
+		return true
+	})
+
+	var common []OperationID
+	dag.walkAncestors([]OperationID{b}, func(id OperationID) bool {
+		if ofA[id] {
+			common = append(common, id)
+		}
+		return true
+	})
+
+	// A common ancestor below another one isn't the best
+	below := make(map[OperationID]bool)
+	var parents []OperationID
+	for _, id := range common {
+		parents = append(parents, dag.operations[id].Parents...)
+	}
+	dag.walkAncestors(parents, func(id OperationID) bool {
+		below[id] = true
+		return true
+	})
+
+	bases := make([]*Operation, 0)
+	for _, id := range common {
+		if !below[id] {
+			bases = append(bases, dag.operations[id])
+		}
+	}
+	sort.Slice(bases, func(i, j int) bool {
+		return bases[i].Precedes(bases[j])
+	})
+	return bases, nil
+}
+
+// walkAncestors visits each operation reachable from start through parents
+// once, start included, until visit returns false. The caller holds the read
+// lock.
+func (dag *OperationDAG) walkAncestors(start []OperationID, visit func(OperationID) bool) {
+	seen := make(map[OperationID]bool)
+	queue := append([]OperationID(nil), start...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		op, exists := dag.operations[id]
+		if seen[id] || !exists {
+			continue
+		}
+		seen[id] = true
+		if !visit(id) {
+			return
+		}
+		queue = append(queue, op.Parents...)
+	}
+}
+
+// operationQueue is a heap of operations in clock order
+type operationQueue []*Operation
+
+func (q operationQueue) Len() int           { return len(q) }
+func (q operationQueue) Less(i, j int) bool { return q[i].Precedes(q[j]) }
+func (q operationQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *operationQueue) Push(x any) {
+	*q = append(*q, x.(*Operation))
+}
+
+func (q *operationQueue) Pop() any {
+	old := *q
+	op := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return op
+}
@@ -0,0 +1,123 @@
+package operations
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+// buildDAG adds an operation per name with the named parents, a second apart
+// in the given order
+func buildDAG(t *testing.T, edges [][2]string, names ...string) (*OperationDAG, map[string]OperationID) {
+	dag := NewOperationDAG()
+	ids := make(map[string]OperationID)
+	start := time.Now()
+	for i, name := range names {
+		ids[name] = NewOperationID([]byte(name))
+		var parents []OperationID
+		for _, edge := range edges {
+			if edge[0] == name {
+				parents = append(parents, ids[edge[1]])
+			}
+		}
+		op := &Operation{
+			ID:        ids[name],
+			Type:      OpInsert,
+			Position:  NewLogootPosition([]PositionSegment{{Value: big.NewInt(int64(i + 1)), AuthorID: "author1"}}),
+			Content:   name,
+			Author:    "author1",
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Parents:   parents,
+		}
+		if err := dag.AddOperation(op); err != nil {
+			t.Fatalf("Failed to add operation %s: %v", name, err)
+		}
+	}
+	return dag, ids
+}
+
+func TestOperationDAG_TopoSort(t *testing.T) {
+	// left and right both follow root and merge follows both. Nothing
+	// orders left and right but time, and right is older.
+	dag, ids := buildDAG(t, [][2]string{
+		{"left", "root"}, {"right", "root"}, {"merge", "left"}, {"merge", "right"},
+	}, "root", "right", "left", "merge")
+
+	sorted := dag.TopoSort()
+	var names []string
+	for _, op := range sorted {
+		names = append(names, op.Content)
+	}
+	expected := []string{"root", "right", "left", "merge"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, names)
+		}
+	}
+	if sorted[3].ID != ids["merge"] {
+		t.Errorf("Expected the merge last, got %s", sorted[3].ID)
+	}
+}
+
+func TestOperationDAG_IsAncestor(t *testing.T) {
+	dag, ids := buildDAG(t, [][2]string{
+		{"left", "root"}, {"right", "root"},
+	}, "root", "left", "right")
+
+	cases := []struct {
+		ancestor, descendant string
+		expected             bool
+	}{
+		{"root", "left", true},
+		{"left", "root", false},
+		{"left", "right", false},
+		{"left", "left", true},
+	}
+	for _, c := range cases {
+		isAncestor, err := dag.IsAncestor(ids[c.ancestor], ids[c.descendant])
+		if err != nil {
+			t.Fatalf("Failed to check ancestry: %v", err)
+		}
+		if isAncestor != c.expected {
+			t.Errorf("Expected IsAncestor(%s, %s) to be %v", c.ancestor, c.descendant, c.expected)
+		}
+	}
+
+	if _, err := dag.IsAncestor(ids["root"], NewOperationID([]byte("missing"))); err != ErrOperationNotFound {
+		t.Errorf("Expected ErrOperationNotFound, got %v", err)
+	}
+}
+
+func TestOperationDAG_MergeBase(t *testing.T) {
+	// Two branches off base, and a criss-cross where x and y each merge a and b
+	dag, ids := buildDAG(t, [][2]string{
+		{"a", "base"}, {"b", "base"},
+		{"x", "a"}, {"x", "b"}, {"y", "a"}, {"y", "b"},
+	}, "base", "a", "b", "x", "y", "lone")
+
+	bases, err := dag.MergeBase(ids["a"], ids["b"])
+	if err != nil {
+		t.Fatalf("Failed to find merge base: %v", err)
+	}
+	if len(bases) != 1 || bases[0].ID != ids["base"] {
+		t.Errorf("Expected base as the merge base, got %v", bases)
+	}
+
+	bases, _ = dag.MergeBase(ids["x"], ids["y"])
+	if len(bases) != 2 || bases[0].ID != ids["a"] || bases[1].ID != ids["b"] {
+		t.Errorf("Expected a and b as merge bases of the criss-cross, got %v", bases)
+	}
+
+	bases, _ = dag.MergeBase(ids["a"], ids["x"])
+	if len(bases) != 1 || bases[0].ID != ids["a"] {
+		t.Errorf("Expected an ancestor to be its own merge base, got %v", bases)
+	}
+
+	bases, _ = dag.MergeBase(ids["lone"], ids["x"])
+	if len(bases) != 0 {
+		t.Errorf("Expected unrelated histories to have no merge base, got %v", bases)
+	}
+}
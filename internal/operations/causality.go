@@ -0,0 +1,114 @@
+package operations
+
+import (
+	"fmt"
+	"sort"
+)
+
+// AddExternal declares operations that exist outside the DAG, such as those
+// applied before it was built or removed by retention, so operations naming
+// them as parents aren't held pending
+func (dag *OperationDAG) AddExternal(ids ...OperationID) {
+	dag.mutex.Lock()
+	defer dag.mutex.Unlock()
+
+	for _, id := range ids {
+		if _, exists := dag.operations[id]; exists || dag.external[id] {
+			continue
+		}
+		dag.external[id] = true
+		dag.release(id)
+	}
+}
+
+// Contains reports whether operations may name id as a parent without being
+// held pending
+func (dag *OperationDAG) Contains(id OperationID) bool {
+	dag.mutex.RLock()
+	defer dag.mutex.RUnlock()
+
+	_, exists := dag.operations[id]
+	return exists || dag.external[id]
+}
+
+// Pending returns the operations waiting for parents, oldest first
+func (dag *OperationDAG) Pending() []*Operation {
+	dag.mutex.RLock()
+	defer dag.mutex.RUnlock()
+
+	pending := make([]*Operation, 0, len(dag.pending))
+	for _, op := range dag.pending {
+		pending = append(pending, op)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Precedes(pending[j])
+	})
+	return pending
+}
+
+// checkCycle refuses op if one of its parents descends from it, whether
+// through operations already added or those pending on it. The caller holds
+// the write lock.
+func (dag *OperationDAG) checkCycle(op *Operation) error {
+	parents := make(map[OperationID]bool, len(op.Parents))
+	for _, parent := range op.Parents {
+		if parent == op.ID {
+			return fmt.Errorf("%w: %s is its own parent", ErrCausalityViolation, op.ID)
+		}
+		parents[parent] = true
+	}
+
+	seen := map[OperationID]bool{op.ID: true}
+	queue := []OperationID{op.ID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, descendants := range [][]OperationID{dag.children[id], dag.waiting[id]} {
+			for _, descendant := range descendants {
+				if parents[descendant] {
+					return fmt.Errorf("%w: %s would be an ancestor of its parent %s", ErrCausalityViolation, op.ID, descendant)
+				}
+				if !seen[descendant] {
+					seen[descendant] = true
+					queue = append(queue, descendant)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// missingParents lists op's parents the DAG neither holds nor knows to exist.
+// The caller holds the lock.
+func (dag *OperationDAG) missingParents(op *Operation) []OperationID {
+	var missing []OperationID
+	for _, parent := range op.Parents {
+		if _, exists := dag.operations[parent]; exists || dag.external[parent] {
+			continue
+		}
+		missing = append(missing, parent)
+	}
+	return missing
+}
+
+// release adds the pending operations that were only waiting for id, and
+// in turn those waiting for them. The caller holds the write lock.
+func (dag *OperationDAG) release(id OperationID) {
+	queue := []OperationID{id}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		waiting := dag.waiting[parent]
+		delete(dag.waiting, parent)
+		for _, childID := range waiting {
+			child, pending := dag.pending[childID]
+			if !pending || len(dag.missingParents(child)) > 0 {
+				continue
+			}
+			delete(dag.pending, childID)
+			dag.insert(child)
+			queue = append(queue, childID)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package operations
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestOperation(name string, parents ...OperationID) *Operation {
+	return &Operation{
+		ID:        NewOperationID([]byte(name)),
+		Type:      OpInsert,
+		Position:  NewLogootPosition([]PositionSegment{{Value: big.NewInt(1), AuthorID: "author1"}}),
+		Content:   name,
+		Author:    "author1",
+		Timestamp: time.Now(),
+		Parents:   parents,
+	}
+}
+
+func TestOperationDAG_PendingParents(t *testing.T) {
+	dag := NewOperationDAG()
+	root := newTestOperation("root")
+	child := newTestOperation("child", root.ID)
+	grandchild := newTestOperation("grandchild", child.ID)
+
+	// Out of order, both wait for root
+	for _, op := range []*Operation{grandchild, child} {
+		if err := dag.AddOperation(op); err != nil {
+			t.Fatalf("Failed to add operation: %v", err)
+		}
+	}
+	if pending := dag.Pending(); len(pending) != 2 {
+		t.Fatalf("Expected 2 pending operations, got %d", len(pending))
+	}
+	if _, err := dag.GetOperation(child.ID); !errors.Is(err, ErrOperationNotFound) {
+		t.Errorf("Expected a pending operation not to be in the DAG, got %v", err)
+	}
+
+	if err := dag.AddOperation(root); err != nil {
+		t.Fatalf("Failed to add operation: %v", err)
+	}
+	if pending := dag.Pending(); len(pending) != 0 {
+		t.Errorf("Expected root to release the pending operations, got %d left", len(pending))
+	}
+	if len(dag.heads) != 1 || dag.heads[0] != grandchild.ID {
+		t.Errorf("Expected the grandchild to be the only head, got %v", dag.heads)
+	}
+
+	// Parents applied elsewhere are declared instead
+	orphan := newTestOperation("orphan", NewOperationID([]byte("applied before")))
+	if err := dag.AddOperation(orphan); err != nil {
+		t.Fatalf("Failed to add operation: %v", err)
+	}
+	dag.AddExternal(orphan.Parents...)
+	if _, err := dag.GetOperation(orphan.ID); err != nil {
+		t.Errorf("Expected an external parent to release the operation: %v", err)
+	}
+}
+
+func TestOperationDAG_Cycles(t *testing.T) {
+	dag := NewOperationDAG()
+
+	self := newTestOperation("self")
+	self.Parents = []OperationID{self.ID}
+	if err := dag.AddOperation(self); !errors.Is(err, ErrCausalityViolation) {
+		t.Errorf("Expected an operation that is its own parent to be refused, got %v", err)
+	}
+
+	// b waits for a, so a can't name b as its parent
+	a := newTestOperation("a")
+	b := newTestOperation("b", a.ID)
+	if err := dag.AddOperation(b); err != nil {
+		t.Fatalf("Failed to add operation: %v", err)
+	}
+	a.Parents = []OperationID{b.ID}
+	if err := dag.AddOperation(a); !errors.Is(err, ErrCausalityViolation) {
+		t.Errorf("Expected a cycle through a pending operation to be refused, got %v", err)
+	}
+
+	// Nor can an external operation once something builds on it
+	external := NewOperationID([]byte("external"))
+	dag.AddExternal(external)
+	c := newTestOperation("c", external)
+	if err := dag.AddOperation(c); err != nil {
+		t.Fatalf("Failed to add operation: %v", err)
+	}
+	loop := newTestOperation("loop", c.ID)
+	loop.ID = external
+	if err := dag.AddOperation(loop); !errors.Is(err, ErrCausalityViolation) {
+		t.Errorf("Expected a cycle through added operations to be refused, got %v", err)
+	}
+}
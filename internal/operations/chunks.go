@@ -0,0 +1,44 @@
+package operations
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunk places an operation among the inserts a text insert too large for
+// one operation was split into. The inserts follow each other in position
+// and each has the one before as its parent, so the content reads back in
+// order of Index.
+type Chunk struct {
+	// Of is the ID of the first insert
+	Of    OperationID `json:"of"`
+	Index int         `json:"index"`
+	Count int         `json:"count"`
+}
+
+// SplitContent splits text content into pieces of at most size bytes,
+// breaking after the last newline in each where there is one so pieces hold
+// whole lines, and never inside a UTF-8 sequence
+func SplitContent(content string, size int) []string {
+	if size < utf8.UTFMax {
+		size = utf8.UTFMax
+	}
+
+	var pieces []string
+	for len(content) > size {
+		end := size
+		if newline := strings.LastIndexByte(content[:end], '\n'); newline >= 0 {
+			end = newline + 1
+		} else {
+			for end > 0 && !utf8.RuneStart(content[end]) {
+				end--
+			}
+			if end == 0 { // Not UTF-8, so there's no sequence to keep whole
+				end = size
+			}
+		}
+		pieces = append(pieces, content[:end])
+		content = content[end:]
+	}
+	return append(pieces, content)
+}
@@ -0,0 +1,36 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitContent(t *testing.T) {
+	for name, test := range map[string]struct {
+		content  string
+		size     int
+		expected []string
+	}{
+		"fits":         {"short\n", 16, []string{"short\n"}},
+		"after lines":  {"one\ntwo\nthree\n", 9, []string{"one\ntwo\n", "three\n"}},
+		"long line":    {"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		"whole runes":  {"abcéx", 4, []string{"abc", "éx"}},
+		"minimum size": {"日本語", 1, []string{"日", "本", "語"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			pieces := SplitContent(test.content, test.size)
+			if strings.Join(pieces, "") != test.content {
+				t.Fatalf("Expected the pieces to make up the content, got %q", pieces)
+			}
+			if len(pieces) != len(test.expected) {
+				t.Fatalf("Expected %q, got %q", test.expected, pieces)
+			}
+			for i := range pieces {
+				if pieces[i] != test.expected[i] || !utf8.ValidString(pieces[i]) {
+					t.Errorf("Expected %q, got %q", test.expected, pieces)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package operations
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// NormalizeContentType maps the empty content type used by older clients to text
+func NormalizeContentType(contentType string) string {
+	if contentType == "" {
+		return ContentTypeText
+	}
+	return contentType
+}
+
+func IsValidContentType(contentType string) bool {
+	switch NormalizeContentType(contentType) {
+	case ContentTypeText, ContentTypeJSON, ContentTypeBinary:
+		return true
+	}
+	return false
+}
+
+// EncodeBinary produces the Content value for a binary operation. Binary
+// payloads travel and are stored as standard base64 so they survive JSON and
+// the text columns of the store unchanged.
+func EncodeBinary(data []byte) string {
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func DecodeBinary(op *Operation) ([]byte, error) {
+	if NormalizeContentType(op.ContentType) != ContentTypeBinary {
+		return nil, ErrContentTypeMismatch
+	}
+
+	data, err := base64.StdEncoding.DecodeString(op.Content)
+	if err != nil {
+		return nil, ErrInvalidContent
+	}
+	return data, nil
+}
+
+// ValidateContent checks that an operation's content matches its content type:
+// binary content must be base64, structured inserts must be a JSON document
+// and patches must be a well-formed patch against structured content
+func ValidateContent(op *Operation) error {
+	contentType := NormalizeContentType(op.ContentType)
+	if !IsValidContentType(contentType) {
+		return ErrInvalidContentType
+	}
+
+	if op.Type == OpPatch {
+		if contentType != ContentTypeJSON {
+			return ErrContentTypeMismatch
+		}
+		_, err := ParsePatch(op.Content)
+		return err
+	}
+
+	if op.Type != OpInsert {
+		return nil
+	}
+
+	switch contentType {
+	case ContentTypeBinary:
+		if _, err := base64.StdEncoding.DecodeString(op.Content); err != nil {
+			return ErrInvalidContent
+		}
+	case ContentTypeJSON:
+		if !json.Valid([]byte(op.Content)) {
+			return ErrInvalidContent
+		}
+	}
+
+	return nil
+}
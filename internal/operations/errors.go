@@ -8,5 +8,14 @@ var (
 	ErrInvalidAuthor        = errors.New("invalid author")
 	ErrInvalidOperationType = errors.New("invalid operation type")
 	ErrPositionConflict     = errors.New("position conflict")
+	ErrInvalidPosition      = errors.New("invalid position")
 	ErrCausalityViolation   = errors.New("causality violation")
+	ErrInvalidContentType   = errors.New("invalid content type")
+	ErrInvalidContent       = errors.New("content does not match content type")
+	ErrContentTypeMismatch  = errors.New("content type mismatch")
+	ErrInvalidPatch         = errors.New("invalid patch")
+	ErrPatchPathNotFound    = errors.New("patch path not found")
+	ErrPatchTestFailed      = errors.New("patch test failed")
+	ErrOperationIDMismatch  = errors.New("operation id does not match its content")
+	ErrClockDrift           = errors.New("operation clock is too far ahead")
 )
@@ -0,0 +1,132 @@
+package operations
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MaxClockDrift is how far ahead of this node's wall clock an operation's
+// clock may be. Clocks further ahead are refused, so a node whose wall clock
+// is set wrong, or a client, can't drag every clock it reaches into the future.
+const MaxClockDrift = 5 * time.Minute
+
+// logicalBits is the width of the counter in the low bits of an HLC
+const logicalBits = 16
+
+// HLC is a hybrid logical clock reading: wall time in milliseconds since the
+// Unix epoch in the high bits and a counter in the low 16 that orders events
+// within a millisecond. An operation's clock is later than those of every
+// operation its node had made or received when it was made, whatever the wall
+// clocks of the machines involved say, so clocks order operations without
+// ties and consistently with causality. Operations keep their wall-clock
+// timestamp for display.
+//
+// HLCs encode in JSON as decimal strings, as they don't fit in a float64.
+type HLC int64
+
+// HLCFromTime is the earliest clock reading at t
+func HLCFromTime(t time.Time) HLC {
+	return HLC(t.UnixMilli() << logicalBits)
+}
+
+// Time is the wall time of the reading, to the millisecond
+func (h HLC) Time() time.Time {
+	return time.UnixMilli(int64(h) >> logicalBits)
+}
+
+// Logical is the counter ordering readings within the same millisecond
+func (h HLC) Logical() uint16 {
+	return uint16(h & (1<<logicalBits - 1))
+}
+
+func (h HLC) String() string {
+	return strconv.FormatInt(int64(h), 10)
+}
+
+func (h HLC) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+func (h *HLC) UnmarshalText(text []byte) error {
+	value, err := strconv.ParseInt(string(text), 10, 64)
+	if err != nil || value < 0 {
+		return fmt.Errorf("%w: clock %q is not a non-negative integer", ErrInvalidOperation, text)
+	}
+	*h = HLC(value)
+	return nil
+}
+
+// Order is the clock operations are ordered by: Clock, or for operations
+// never given one, the earliest reading at their timestamp
+func (op *Operation) Order() HLC {
+	if op.Clock != 0 {
+		return op.Clock
+	}
+	return HLCFromTime(op.Timestamp)
+}
+
+// Precedes reports whether op comes before other, by clock and then by ID
+func (op *Operation) Precedes(other *Operation) bool {
+	if a, b := op.Order(), other.Order(); a != b {
+		return a < b
+	}
+	return op.ID < other.ID
+}
+
+// HybridClock issues the clock readings of one node. Readings only go
+// forward, past every reading issued or observed, even when the wall clock
+// goes back.
+type HybridClock struct {
+	last  HLC
+	now   func() time.Time
+	mutex sync.Mutex
+}
+
+func NewHybridClock() *HybridClock {
+	return &HybridClock{now: time.Now}
+}
+
+// Now issues a reading later than any issued or observed before
+func (c *HybridClock) Now() HLC {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if wall := HLCFromTime(c.now()); wall > c.last {
+		c.last = wall
+	} else {
+		c.last++
+	}
+	return c.last
+}
+
+// Observe moves the clock past a reading from elsewhere, such as an
+// operation from another node, so readings issued after it are later. A
+// reading more than MaxClockDrift ahead of the wall clock is refused with
+// ErrClockDrift.
+func (c *HybridClock) Observe(remote HLC) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if drift := remote.Time().Sub(c.now()); drift > MaxClockDrift {
+		return fmt.Errorf("%w: clock %s is %s ahead of this node", ErrClockDrift,
+			remote.Time().UTC().Format(time.RFC3339), drift.Round(time.Second))
+	}
+	c.advance(remote)
+	return nil
+}
+
+// Advance moves the clock past a reading however far ahead it is, for
+// readings already accepted, such as those of stored operations
+func (c *HybridClock) Advance(past HLC) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.advance(past)
+}
+
+func (c *HybridClock) advance(past HLC) {
+	if past > c.last {
+		c.last = past
+	}
+}
@@ -0,0 +1,93 @@
+package operations
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHybridClock(t *testing.T) {
+	wall := time.Date(2025, 1, 13, 9, 0, 0, 0, time.UTC)
+	clock := &HybridClock{now: func() time.Time { return wall }}
+
+	first := clock.Now()
+	if first != HLCFromTime(wall) || !first.Time().Equal(wall) {
+		t.Fatalf("Expected the first reading at the wall clock, got %s", first.Time())
+	}
+
+	// Within the same millisecond, and with the wall clock going back,
+	// readings still go forward
+	second := clock.Now()
+	wall = wall.Add(-time.Hour)
+	third := clock.Now()
+	if second != first+1 || third != second+1 || third.Logical() != 2 {
+		t.Errorf("Expected readings to count up, got %d, %d and %d", first, second, third)
+	}
+
+	wall = wall.Add(2 * time.Hour)
+	if later := clock.Now(); later != HLCFromTime(wall) {
+		t.Errorf("Expected the clock to follow the wall clock once it passes, got %s", later.Time())
+	}
+
+	remote := HLCFromTime(wall.Add(time.Minute)) + 5
+	if err := clock.Observe(remote); err != nil {
+		t.Fatalf("Failed to observe a reading: %v", err)
+	}
+	if next := clock.Now(); next != remote+1 {
+		t.Errorf("Expected the next reading after the observed one, got %d", next)
+	}
+
+	if err := clock.Observe(HLCFromTime(wall.Add(MaxClockDrift + time.Minute))); !errors.Is(err, ErrClockDrift) {
+		t.Errorf("Expected a reading too far ahead to be refused, got %v", err)
+	}
+	if next := clock.Now(); next != remote+2 {
+		t.Errorf("Expected a refused reading not to move the clock, got %d", next)
+	}
+
+	ahead := HLCFromTime(wall.Add(time.Hour))
+	clock.Advance(ahead)
+	if next := clock.Now(); next != ahead+1 {
+		t.Errorf("Expected Advance to move the clock however far ahead, got %d", next)
+	}
+}
+
+func TestOperationOrder(t *testing.T) {
+	wall := time.Date(2025, 1, 13, 9, 0, 0, 0, time.UTC)
+	// The same second, but the clock tells them apart
+	early := &Operation{ID: "b", Timestamp: wall, Clock: HLCFromTime(wall) + 1}
+	late := &Operation{ID: "a", Timestamp: wall, Clock: HLCFromTime(wall) + 2}
+	if !early.Precedes(late) || late.Precedes(early) {
+		t.Error("Expected operations to be ordered by clock before ID")
+	}
+
+	unclocked := &Operation{ID: "c", Timestamp: wall}
+	if unclocked.Order() != HLCFromTime(wall) || !unclocked.Precedes(early) {
+		t.Errorf("Expected an operation without a clock to be ordered by its timestamp, got %d", unclocked.Order())
+	}
+
+	tied := &Operation{ID: "d", Timestamp: wall}
+	if !unclocked.Precedes(tied) || tied.Precedes(unclocked) {
+		t.Error("Expected ties to be broken by ID")
+	}
+}
+
+func TestHLC_JSON(t *testing.T) {
+	// Beyond what a float64 holds exactly
+	op := &Operation{ID: "a", Clock: HLC(1<<62 + 1)}
+	data, err := json.Marshal(op)
+	if err != nil {
+		t.Fatalf("Failed to marshal operation: %v", err)
+	}
+	var decoded Operation
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal operation: %v", err)
+	}
+	if decoded.Clock != op.Clock {
+		t.Errorf("Expected clock %d, got %d", op.Clock, decoded.Clock)
+	}
+
+	if err := json.Unmarshal([]byte(`{"clock":"-1"}`), &decoded); !errors.Is(err, ErrInvalidOperation) {
+		t.Errorf("Expected a negative clock to be invalid, got %v", err)
+	}
+}
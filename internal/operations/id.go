@@ -0,0 +1,53 @@
+package operations
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// identity is the canonical form of an operation that its ID hashes. The
+// document is included so the same edit made at the same moment in two
+// documents, whose positions may coincide, gets two IDs.
+type identity struct {
+	Type       OperationType     `json:"type"`
+	Position   []PositionSegment `json:"position"`
+	Content    string            `json:"content"`
+	Parents    []OperationID     `json:"parents"`
+	Author     AuthorID          `json:"author"`
+	Timestamp  string            `json:"timestamp"`
+	DocumentID string            `json:"document_id"`
+}
+
+// ComputeID derives op's ID from its type, position, content, parents,
+// author, timestamp and document. Anyone holding the operation gets the same
+// ID: the encoding is JSON with the keys in that order, parents sorted, and
+// the timestamp in UTC as RFC 3339 with nanoseconds, hashed with SHA3-256.
+func ComputeID(op *Operation) OperationID {
+	parents := slices.Clone(op.Parents)
+	if parents == nil {
+		parents = []OperationID{}
+	}
+	slices.Sort(parents)
+
+	// Nothing in identity can fail to encode
+	data, _ := json.Marshal(identity{
+		Type:       op.Type,
+		Position:   op.Position.Segments,
+		Content:    op.Content,
+		Parents:    parents,
+		Author:     op.Author,
+		Timestamp:  op.Timestamp.UTC().Format(time.RFC3339Nano),
+		DocumentID: op.Metadata.documentID(),
+	})
+	return NewOperationID(data)
+}
+
+// ValidateID checks an ID a client chose against the one op should have
+func ValidateID(op *Operation) error {
+	if expected := ComputeID(op); op.ID != expected {
+		return fmt.Errorf("%w: expected %s", ErrOperationIDMismatch, expected)
+	}
+	return nil
+}
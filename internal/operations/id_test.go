@@ -0,0 +1,62 @@
+package operations
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestComputeID(t *testing.T) {
+	timestamp := time.Date(2025, 1, 13, 9, 0, 0, 123456789, time.UTC)
+	newOp := func() *Operation {
+		return &Operation{
+			Type:      OpInsert,
+			Position:  NewLogootPosition([]PositionSegment{{Value: big.NewInt(1736758800123456789), AuthorID: "alice"}}),
+			Content:   "hello",
+			Author:    "alice",
+			Timestamp: timestamp,
+			Parents:   []OperationID{"b", "a"},
+			Metadata:  OperationMeta{Context: map[string]string{"document_id": "main.go"}},
+		}
+	}
+
+	id := ComputeID(newOp())
+	if ComputeID(newOp()) != id {
+		t.Fatal("Expected the same operation to get the same ID")
+	}
+
+	same := newOp()
+	same.Parents = []OperationID{"a", "b"}
+	same.Timestamp = timestamp.In(time.FixedZone("EST", -5*60*60))
+	same.Metadata.Intent = "greeting"
+	if ComputeID(same) != id {
+		t.Error("Expected parent order, time zone and intent not to change the ID")
+	}
+
+	for name, change := range map[string]func(op *Operation){
+		"type":      func(op *Operation) { op.Type = OpDelete },
+		"position":  func(op *Operation) { op.Position.Segments[0].Value = big.NewInt(1736758800123456790) },
+		"content":   func(op *Operation) { op.Content = "hello!" },
+		"parents":   func(op *Operation) { op.Parents = nil },
+		"author":    func(op *Operation) { op.Author = "bob" },
+		"timestamp": func(op *Operation) { op.Timestamp = timestamp.Add(time.Nanosecond) },
+		"document":  func(op *Operation) { op.Metadata.Context["document_id"] = "other.go" },
+	} {
+		op := newOp()
+		change(op)
+		if ComputeID(op) == id {
+			t.Errorf("Expected changing the %s to change the ID", name)
+		}
+	}
+
+	op := newOp()
+	op.ID = id
+	if err := ValidateID(op); err != nil {
+		t.Errorf("Expected the computed ID to validate: %v", err)
+	}
+	op.ID = "chosen-by-client"
+	if err := ValidateID(op); !errors.Is(err, ErrOperationIDMismatch) {
+		t.Errorf("Expected ErrOperationIDMismatch, got %v", err)
+	}
+}
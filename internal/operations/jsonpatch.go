@@ -0,0 +1,182 @@
+package operations
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchOp is one step of a JSON-patch style edit to structured content. The
+// supported ops are add, remove, replace and test, addressed by JSON pointer.
+type PatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func ParsePatch(content string) ([]PatchOp, error) {
+	var patch []PatchOp
+	if err := json.Unmarshal([]byte(content), &patch); err != nil {
+		return nil, ErrInvalidPatch
+	}
+
+	for _, step := range patch {
+		switch step.Op {
+		case "add", "replace", "test":
+			if len(step.Value) == 0 {
+				return nil, ErrInvalidPatch
+			}
+		case "remove":
+		default:
+			return nil, ErrInvalidPatch
+		}
+
+		if step.Path != "" && !strings.HasPrefix(step.Path, "/") {
+			return nil, ErrInvalidPatch
+		}
+	}
+
+	return patch, nil
+}
+
+// ApplyPatch applies patch to the JSON document and returns the new document.
+// The patch is all or nothing: any failing step leaves document untouched.
+func ApplyPatch(document string, patch []PatchOp) (string, error) {
+	var root interface{}
+	if err := json.Unmarshal([]byte(document), &root); err != nil {
+		return "", ErrInvalidContent
+	}
+
+	for _, step := range patch {
+		var value interface{}
+		if len(step.Value) > 0 {
+			if err := json.Unmarshal(step.Value, &value); err != nil {
+				return "", ErrInvalidPatch
+			}
+		}
+
+		var err error
+		root, err = applyPatchStep(root, splitPointer(step.Path), step.Op, value)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	result, err := json.Marshal(root)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+func splitPointer(path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		token = strings.ReplaceAll(token, "~1", "/")
+		tokens[i] = strings.ReplaceAll(token, "~0", "~")
+	}
+	return tokens
+}
+
+// applyPatchStep walks to the parent of the target and returns the, possibly
+// replaced, node so array growth propagates back up to the caller
+func applyPatchStep(node interface{}, tokens []string, op string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		switch op {
+		case "add", "replace":
+			return value, nil
+		case "test":
+			if !reflect.DeepEqual(node, value) {
+				return nil, ErrPatchTestFailed
+			}
+			return node, nil
+		default:
+			return nil, ErrInvalidPatch
+		}
+	}
+
+	key, rest := tokens[0], tokens[1:]
+
+	switch container := node.(type) {
+	case map[string]interface{}:
+		child, exists := container[key]
+		if len(rest) > 0 {
+			if !exists {
+				return nil, ErrPatchPathNotFound
+			}
+			updated, err := applyPatchStep(child, rest, op, value)
+			if err != nil {
+				return nil, err
+			}
+			container[key] = updated
+			return container, nil
+		}
+
+		switch op {
+		case "add":
+			container[key] = value
+		case "replace":
+			if !exists {
+				return nil, ErrPatchPathNotFound
+			}
+			container[key] = value
+		case "remove":
+			if !exists {
+				return nil, ErrPatchPathNotFound
+			}
+			delete(container, key)
+		case "test":
+			if !exists || !reflect.DeepEqual(child, value) {
+				return nil, ErrPatchTestFailed
+			}
+		}
+		return container, nil
+
+	case []interface{}:
+		// "-" addresses the slot after the last element, only valid for add
+		if key == "-" && len(rest) == 0 && op == "add" {
+			return append(container, value), nil
+		}
+
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index > len(container) {
+			return nil, ErrPatchPathNotFound
+		}
+		if index == len(container) && !(op == "add" && len(rest) == 0) {
+			return nil, ErrPatchPathNotFound
+		}
+
+		if len(rest) > 0 {
+			updated, err := applyPatchStep(container[index], rest, op, value)
+			if err != nil {
+				return nil, err
+			}
+			container[index] = updated
+			return container, nil
+		}
+
+		switch op {
+		case "add":
+			container = append(container, nil)
+			copy(container[index+1:], container[index:])
+			container[index] = value
+		case "replace":
+			container[index] = value
+		case "remove":
+			container = append(container[:index], container[index+1:]...)
+		case "test":
+			if !reflect.DeepEqual(container[index], value) {
+				return nil, ErrPatchTestFailed
+			}
+		}
+		return container, nil
+
+	default:
+		return nil, ErrPatchPathNotFound
+	}
+}
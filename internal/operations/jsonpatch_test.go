@@ -0,0 +1,90 @@
+package operations
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	document := `{"timeout": 10, "tags": ["dev"], "db": {"host": "localhost"}}`
+
+	patch, err := ParsePatch(`[
+		{"op": "test", "path": "/db/host", "value": "localhost"},
+		{"op": "replace", "path": "/timeout", "value": 30},
+		{"op": "add", "path": "/tags/-", "value": "prod"},
+		{"op": "add", "path": "/tags/0", "value": "first"},
+		{"op": "remove", "path": "/db"}
+	]`)
+	if err != nil {
+		t.Fatalf("Failed to parse patch: %v", err)
+	}
+
+	patched, err := ApplyPatch(document, patch)
+	if err != nil {
+		t.Fatalf("Failed to apply patch: %v", err)
+	}
+
+	var got, want interface{}
+	json.Unmarshal([]byte(patched), &got)
+	json.Unmarshal([]byte(`{"timeout": 30, "tags": ["first", "dev", "prod"]}`), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected patch result: %s", patched)
+	}
+}
+
+func TestApplyPatch_Failures(t *testing.T) {
+	document := `{"a": 1, "list": [1, 2]}`
+
+	cases := []struct {
+		patch string
+		err   error
+	}{
+		{`[{"op": "test", "path": "/a", "value": 2}]`, ErrPatchTestFailed},
+		{`[{"op": "replace", "path": "/missing", "value": 2}]`, ErrPatchPathNotFound},
+		{`[{"op": "remove", "path": "/list/5"}]`, ErrPatchPathNotFound},
+		{`[{"op": "add", "path": "/missing/child", "value": 2}]`, ErrPatchPathNotFound},
+	}
+
+	for _, c := range cases {
+		patch, err := ParsePatch(c.patch)
+		if err != nil {
+			t.Fatalf("Failed to parse patch %s: %v", c.patch, err)
+		}
+		if _, err := ApplyPatch(document, patch); err != c.err {
+			t.Errorf("Patch %s: expected %v, got %v", c.patch, c.err, err)
+		}
+	}
+
+	for _, invalid := range []string{`{}`, `[{"op": "move", "path": "/a"}]`, `[{"op": "add", "path": "/a"}]`, `[{"op": "remove", "path": "a"}]`} {
+		if _, err := ParsePatch(invalid); err != ErrInvalidPatch {
+			t.Errorf("Expected %s to be rejected, got %v", invalid, err)
+		}
+	}
+}
+
+func TestValidateContent(t *testing.T) {
+	cases := []struct {
+		op  *Operation
+		err error
+	}{
+		{&Operation{Type: OpInsert, Content: "plain"}, nil},
+		{&Operation{Type: OpInsert, Content: EncodeBinary([]byte{0, 1, 2}), ContentType: ContentTypeBinary}, nil},
+		{&Operation{Type: OpInsert, Content: "not base64!", ContentType: ContentTypeBinary}, ErrInvalidContent},
+		{&Operation{Type: OpInsert, Content: `{"a": 1}`, ContentType: ContentTypeJSON}, nil},
+		{&Operation{Type: OpInsert, Content: `{"a":`, ContentType: ContentTypeJSON}, ErrInvalidContent},
+		{&Operation{Type: OpPatch, Content: `[]`, ContentType: ContentTypeText}, ErrContentTypeMismatch},
+		{&Operation{Type: OpInsert, Content: "x", ContentType: "xml"}, ErrInvalidContentType},
+	}
+
+	for i, c := range cases {
+		if err := ValidateContent(c.op); err != c.err {
+			t.Errorf("Case %d: expected %v, got %v", i, c.err, err)
+		}
+	}
+
+	data, err := DecodeBinary(cases[1].op)
+	if err != nil || !reflect.DeepEqual(data, []byte{0, 1, 2}) {
+		t.Errorf("Expected binary round trip, got %v, %v", data, err)
+	}
+}
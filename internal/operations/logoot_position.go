@@ -1,8 +1,13 @@
 package operations
 
 import (
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"math/big"
+	"math/rand/v2"
+	"strings"
 
 	"golang.org/x/crypto/sha3"
 )
@@ -27,16 +32,42 @@ func NewLogootPosition(segments []PositionSegment) LogootPosition {
 	return pos
 }
 
+// computeHash keys the position by its segments, each value's sign and
+// magnitude and each author written with their lengths, so no two positions
+// share a key
 func (p *LogootPosition) computeHash() {
 	hasher := sha3.New256()
+	var length [binary.MaxVarintLen64]byte
 	for _, segment := range p.Segments {
-		hasher.Write(segment.Value.Bytes())
+		magnitude := segment.Value.Bytes()
+		hasher.Write([]byte{byte(segment.Value.Sign() + 1)})
+		hasher.Write(length[:binary.PutUvarint(length[:], uint64(len(magnitude)))])
+		hasher.Write(magnitude)
+		hasher.Write(length[:binary.PutUvarint(length[:], uint64(len(segment.AuthorID)))])
 		hasher.Write([]byte(segment.AuthorID))
 	}
 	hash := hasher.Sum(nil)
 	p.Hash = PositionKey(hex.EncodeToString(hash))
 }
 
+// UnmarshalJSON reads a position's segments and keys it by them, whatever
+// hash came with them, refusing segments without a value
+func (p *LogootPosition) UnmarshalJSON(data []byte) error {
+	var decoded struct {
+		Segments []PositionSegment `json:"segments"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	for _, segment := range decoded.Segments {
+		if segment.Value == nil {
+			return fmt.Errorf("%w: segment has no value", ErrInvalidPosition)
+		}
+	}
+	*p = NewLogootPosition(decoded.Segments)
+	return nil
+}
+
 func (p LogootPosition) Key() PositionKey {
 	return p.Hash
 }
@@ -80,6 +111,51 @@ func (p LogootPosition) String() string {
 	return result
 }
 
+// ParsePosition reads a position written by String. An author runs up to
+// the next "." that starts another value:author segment.
+func ParsePosition(s string) (LogootPosition, error) {
+	if s == "" {
+		return LogootPosition{}, fmt.Errorf("%w: empty position", ErrInvalidPosition)
+	}
+
+	segments := make([]PositionSegment, 0)
+	for s != "" {
+		colon := strings.IndexByte(s, ':')
+		if colon < 0 {
+			return LogootPosition{}, fmt.Errorf("%w: segment %q has no author", ErrInvalidPosition, s)
+		}
+		value, ok := new(big.Int).SetString(s[:colon], 10)
+		if !ok {
+			return LogootPosition{}, fmt.Errorf("%w: %q is not an integer", ErrInvalidPosition, s[:colon])
+		}
+		s = s[colon+1:]
+
+		end := len(s)
+		for i := 0; i < len(s); i++ {
+			if s[i] == '.' && startsSegment(s[i+1:]) {
+				end = i
+				break
+			}
+		}
+		if end == 0 {
+			return LogootPosition{}, fmt.Errorf("%w: segment %s has no author", ErrInvalidPosition, value)
+		}
+		segments = append(segments, PositionSegment{Value: value, AuthorID: AuthorID(s[:end])})
+		s = strings.TrimPrefix(s[end:], ".")
+	}
+	return NewLogootPosition(segments), nil
+}
+
+// startsSegment reports whether s begins with an integer and a colon
+func startsSegment(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	digits := 0
+	for digits < len(s) && s[digits] >= '0' && s[digits] <= '9' {
+		digits++
+	}
+	return digits > 0 && digits < len(s) && s[digits] == ':'
+}
+
 func (p LogootPosition) IsValid() bool {
 	if len(p.Segments) == 0 {
 		return false
@@ -94,34 +170,141 @@ func (p LogootPosition) IsValid() bool {
 	return true
 }
 
+// Positions are allocated LSEQ style. Each level of a position has room for
+// more values than the one above it, doubling from 2^baseBits, and a new
+// value is placed a random step of at most Boundary from one of its
+// neighbours: the left one on even levels (boundary+) and the right one on
+// odd levels (boundary-). Runs of insertions at the same place then use up a
+// level slowly, whichever way they go, instead of halving the gap each time
+// and adding a level every few insertions.
+const (
+	DefaultBoundary = 10
+	baseBits        = 5
+)
+
+// Allocator places new positions between existing ones
+type Allocator struct {
+	// Boundary caps how far from its neighbour a new value is placed
+	Boundary int64
+	// rand picks steps. When nil the global source is used, which is safe for
+	// concurrent use.
+	rand *rand.Rand
+}
+
+// NewAllocator returns an allocator drawing its steps from source, so
+// allocations can be reproduced. An Allocator with a source is not safe for
+// concurrent use.
+func NewAllocator(source rand.Source) *Allocator {
+	return &Allocator{Boundary: DefaultBoundary, rand: rand.New(source)}
+}
+
+var defaultAllocator = &Allocator{Boundary: DefaultBoundary}
+
+// GeneratePosition returns a position between left and right for authorID.
+// Either may be the zero position, meaning the start or end of the document.
 func GeneratePosition(left, right LogootPosition, authorID AuthorID) LogootPosition {
-	if !left.IsValid() && !right.IsValid() {
-		return NewLogootPosition([]PositionSegment{
-			{Value: big.NewInt(1), AuthorID: authorID},
-		})
-	}
+	return defaultAllocator.Between(left, right, authorID)
+}
 
+// Between returns a position after left and before right. Either may be the
+// zero position, meaning the start or end of the document.
+func (a *Allocator) Between(left, right LogootPosition, authorID AuthorID) LogootPosition {
 	if !left.IsValid() {
-		value := new(big.Int).Sub(right.Segments[0].Value, big.NewInt(1))
-		if value.Cmp(big.NewInt(0)) <= 0 {
-			segments := make([]PositionSegment, len(right.Segments)+1)
-			copy(segments[1:], right.Segments)
-			segments[0] = PositionSegment{Value: big.NewInt(0), AuthorID: authorID}
-			return NewLogootPosition(segments)
+		left = LogootPosition{}
+	}
+	if !right.IsValid() {
+		right = LogootPosition{}
+	}
+
+	// The position follows left's segments while it is level with left, and
+	// right's while level with right. Once it is clear of a neighbour that
+	// side no longer bounds it.
+	var segments []PositionSegment
+	levelLeft, levelRight := true, len(right.Segments) > 0
+	for depth := 0; ; depth++ {
+		low := big.NewInt(0)
+		var leftSegment *PositionSegment
+		if levelLeft && depth < len(left.Segments) {
+			leftSegment = &left.Segments[depth]
+			low = leftSegment.Value
+		}
+
+		if !levelRight {
+			value := a.place(low, new(big.Int).Add(low, levelBase(depth)), depth)
+			return NewLogootPosition(append(segments, PositionSegment{Value: value, AuthorID: authorID}))
+		}
+		if depth >= len(right.Segments) {
+			// Only reachable when left isn't before right
+			return generatePositionBetween(left, right, authorID)
+		}
+
+		rightSegment := right.Segments[depth]
+		high := rightSegment.Value
+		if new(big.Int).Sub(high, low).Cmp(big.NewInt(1)) > 0 {
+			value := a.place(low, new(big.Int).Sub(high, big.NewInt(1)), depth)
+			return NewLogootPosition(append(segments, PositionSegment{Value: value, AuthorID: authorID}))
+		}
+
+		// No room on this level, so go down one below whichever side allows
+		switch {
+		case leftSegment != nil && segmentLess(*leftSegment, rightSegment):
+			segments = append(segments, *leftSegment)
+			levelRight = false
+		case leftSegment != nil:
+			segments = append(segments, *leftSegment)
+		case segmentLess(PositionSegment{Value: big.NewInt(0), AuthorID: authorID}, rightSegment):
+			segments = append(segments, PositionSegment{Value: big.NewInt(0), AuthorID: authorID})
+			levelRight = false
+			levelLeft = false
+		case depth == len(right.Segments)-1:
+			// Right ends in a zero value at or above authorID, which this
+			// allocator never produces. Following it would leave nothing
+			// below right, so the position goes below zero instead.
+			value := a.place(new(big.Int).Sub(high, levelBase(depth)), new(big.Int).Sub(high, big.NewInt(1)), depth)
+			return NewLogootPosition(append(segments, PositionSegment{Value: value, AuthorID: authorID}))
+		default:
+			segments = append(segments, rightSegment)
+			levelLeft = false
 		}
-		return NewLogootPosition([]PositionSegment{
-			{Value: value, AuthorID: authorID},
-		})
 	}
+}
 
-	if !right.IsValid() {
-		value := new(big.Int).Add(left.Segments[0].Value, big.NewInt(1))
-		return NewLogootPosition([]PositionSegment{
-			{Value: value, AuthorID: authorID},
-		})
+// place picks a value above low and at most high, which is above low, a
+// random step away from low on even levels and from high on odd ones
+func (a *Allocator) place(low, high *big.Int, depth int) *big.Int {
+	room := new(big.Int).Sub(high, low)
+	boundary := a.Boundary
+	if boundary <= 0 {
+		boundary = DefaultBoundary
+	}
+	if room.IsInt64() && room.Int64() < boundary {
+		boundary = room.Int64()
 	}
 
-	return generatePositionBetween(left, right, authorID)
+	offset := a.int64N(boundary)
+	if depth%2 == 0 {
+		return new(big.Int).Add(low, big.NewInt(offset+1))
+	}
+	return new(big.Int).Sub(high, big.NewInt(offset))
+}
+
+func (a *Allocator) int64N(n int64) int64 {
+	if a.rand == nil {
+		return rand.Int64N(n)
+	}
+	return a.rand.Int64N(n)
+}
+
+// levelBase is how many values a level has room for below an open bound
+func levelBase(depth int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(baseBits+depth))
+}
+
+func segmentLess(a, b PositionSegment) bool {
+	if cmp := a.Value.Cmp(b.Value); cmp != 0 {
+		return cmp < 0
+	}
+	return a.AuthorID < b.AuthorID
 }
 
 func generatePositionBetween(left, right LogootPosition, authorID AuthorID) LogootPosition {
@@ -0,0 +1,189 @@
+package operations
+
+import (
+	"math/big"
+	"math/rand/v2"
+	"testing"
+)
+
+// densePosition is the allocation GeneratePosition used before it was LSEQ
+// style, halving the gap between neighbours. It is kept as a baseline for
+// the benchmarks.
+func densePosition(left, right LogootPosition, authorID AuthorID) LogootPosition {
+	if !left.IsValid() && !right.IsValid() {
+		return NewLogootPosition([]PositionSegment{{Value: big.NewInt(1), AuthorID: authorID}})
+	}
+	if !left.IsValid() {
+		value := new(big.Int).Sub(right.Segments[0].Value, big.NewInt(1))
+		if value.Sign() <= 0 {
+			segments := make([]PositionSegment, len(right.Segments)+1)
+			copy(segments[1:], right.Segments)
+			segments[0] = PositionSegment{Value: big.NewInt(0), AuthorID: authorID}
+			return NewLogootPosition(segments)
+		}
+		return NewLogootPosition([]PositionSegment{{Value: value, AuthorID: authorID}})
+	}
+	if !right.IsValid() {
+		value := new(big.Int).Add(left.Segments[0].Value, big.NewInt(1))
+		return NewLogootPosition([]PositionSegment{{Value: value, AuthorID: authorID}})
+	}
+	return generatePositionBetween(left, right, authorID)
+}
+
+// Insertion patterns pick where the next position goes in a document of n,
+// given where the last one went. Documents start with a first and last
+// position already in place.
+type insertionPattern func(r *rand.Rand, n, last int) int
+
+var insertionPatterns = map[string]insertionPattern{
+	// Typing forward, each insertion after the last
+	"forward": func(r *rand.Rand, n, last int) int { return last + 1 },
+	// Typing backward, each insertion before the last
+	"backward": func(r *rand.Rand, n, last int) int { return last },
+	"append":   func(r *rand.Rand, n, last int) int { return n },
+	"random":   func(r *rand.Rand, n, last int) int { return 1 + r.IntN(n-1) },
+}
+
+// insertAll inserts count positions where pattern says and returns the
+// document's positions in order
+func insertAll(allocate func(left, right LogootPosition, authorID AuthorID) LogootPosition, pattern insertionPattern, count int) []LogootPosition {
+	r := rand.New(rand.NewPCG(1, 2))
+	first := allocate(LogootPosition{}, LogootPosition{}, "alice")
+	positions := []LogootPosition{first, allocate(first, LogootPosition{}, "alice")}
+	last := 0
+	for i := 0; i < count; i++ {
+		last = pattern(r, len(positions), last)
+		var left, right LogootPosition
+		if last > 0 {
+			left = positions[last-1]
+		}
+		if last < len(positions) {
+			right = positions[last]
+		}
+
+		pos := allocate(left, right, AuthorID([]string{"alice", "bob"}[i%2]))
+		positions = append(positions[:last], append([]LogootPosition{pos}, positions[last:]...)...)
+	}
+	return positions
+}
+
+func averageLength(positions []LogootPosition) float64 {
+	total := 0
+	for _, pos := range positions {
+		total += len(pos.Segments)
+	}
+	return float64(total) / float64(len(positions))
+}
+
+func TestAllocator_Between(t *testing.T) {
+	allocator := NewAllocator(rand.NewPCG(3, 4))
+	for name, pattern := range insertionPatterns {
+		positions := insertAll(allocator.Between, pattern, 2000)
+		for i, pos := range positions {
+			if !pos.IsValid() {
+				t.Fatalf("%s: expected a valid position, got %s", name, pos)
+			}
+			for _, segment := range pos.Segments {
+				if segment.Value.Sign() < 0 {
+					t.Fatalf("%s: expected non-negative values, got %s", name, pos)
+				}
+			}
+			if i > 0 && positions[i-1].Compare(pos) >= 0 {
+				t.Fatalf("%s: expected positions in order, got %s before %s", name, positions[i-1], pos)
+			}
+		}
+	}
+}
+
+func TestAllocator_StaysShort(t *testing.T) {
+	// Halving gaps adds a level with nearly every insertion going backward
+	for name, factor := range map[string]float64{"backward": 10, "random": 1.5} {
+		dense := averageLength(insertAll(densePosition, insertionPatterns[name], 1000))
+		lseq := averageLength(insertAll(NewAllocator(rand.NewPCG(5, 6)).Between, insertionPatterns[name], 1000))
+		if lseq*factor > dense {
+			t.Errorf("%s: expected positions %.1f times shorter than %.1f segments, got %.1f", name, factor, dense, lseq)
+		}
+	}
+}
+
+func TestAllocator_BetweenExisting(t *testing.T) {
+	allocator := NewAllocator(rand.NewPCG(7, 8))
+	cases := []struct{ left, right LogootPosition }{
+		// Adjacent values, and the same value from different authors
+		{NewLogootPosition([]PositionSegment{{Value: big.NewInt(4), AuthorID: "alice"}}), NewLogootPosition([]PositionSegment{{Value: big.NewInt(5), AuthorID: "alice"}})},
+		{NewLogootPosition([]PositionSegment{{Value: big.NewInt(4), AuthorID: "alice"}}), NewLogootPosition([]PositionSegment{{Value: big.NewInt(4), AuthorID: "bob"}})},
+		// Left is a prefix of right, and right starts at zero
+		{NewLogootPosition([]PositionSegment{{Value: big.NewInt(4), AuthorID: "alice"}}), NewLogootPosition([]PositionSegment{{Value: big.NewInt(4), AuthorID: "alice"}, {Value: big.NewInt(1), AuthorID: "bob"}})},
+		{LogootPosition{}, NewLogootPosition([]PositionSegment{{Value: big.NewInt(0), AuthorID: "alice"}, {Value: big.NewInt(3), AuthorID: "bob"}})},
+		// Values far beyond a level's base
+		{NewLogootPosition([]PositionSegment{{Value: big.NewInt(1 << 60), AuthorID: "alice"}}), LogootPosition{}},
+		// Right extends left by a zero value below the author
+		{NewLogootPosition([]PositionSegment{{Value: big.NewInt(2), AuthorID: "alice"}, {Value: big.NewInt(4), AuthorID: "carol"}}),
+			NewLogootPosition([]PositionSegment{{Value: big.NewInt(2), AuthorID: "alice"}, {Value: big.NewInt(4), AuthorID: "carol"}, {Value: big.NewInt(0), AuthorID: "alice"}})},
+	}
+	for _, c := range cases {
+		pos := allocator.Between(c.left, c.right, "carol")
+		if (c.left.IsValid() && pos.Compare(c.left) <= 0) || (c.right.IsValid() && pos.Compare(c.right) >= 0) {
+			t.Errorf("Expected %s to fall between %s and %s", pos, c.left, c.right)
+		}
+	}
+}
+
+// randomPosition makes positions from few values and authors, so that
+// positions often share prefixes or prefix one another
+func randomPosition(r *rand.Rand, maxDepth int) LogootPosition {
+	segments := make([]PositionSegment, 1+r.IntN(maxDepth))
+	for i := range segments {
+		segments[i] = PositionSegment{Value: big.NewInt(r.Int64N(3)), AuthorID: AuthorID([]string{"alice", "bob", "carol"}[r.IntN(3)])}
+	}
+	return NewLogootPosition(segments)
+}
+
+func TestAllocator_BetweenAnyNeighbours(t *testing.T) {
+	r := rand.New(rand.NewPCG(9, 10))
+	allocator := NewAllocator(rand.NewPCG(11, 12))
+	for i := 0; i < 20000; i++ {
+		left, right := randomPosition(r, 4), randomPosition(r, 4)
+		switch i % 3 {
+		case 0:
+			// Right extends left
+			right = NewLogootPosition(append(append([]PositionSegment(nil), left.Segments...), randomPosition(r, 2).Segments...))
+		case 1:
+			// Left extends a prefix of right
+			prefix := right.Segments[:r.IntN(len(right.Segments))]
+			left = NewLogootPosition(append(append([]PositionSegment(nil), prefix...), randomPosition(r, 2).Segments...))
+		}
+		switch cmp := left.Compare(right); {
+		case cmp == 0:
+			continue
+		case cmp > 0:
+			left, right = right, left
+		}
+
+		authorID := AuthorID([]string{"alice", "bob", "carol"}[r.IntN(3)])
+		pos := allocator.Between(left, right, authorID)
+		if !pos.IsValid() || pos.Compare(left) <= 0 || pos.Compare(right) >= 0 {
+			t.Fatalf("Expected %s for %s to fall between %s and %s", pos, authorID, left, right)
+		}
+	}
+}
+
+func benchmarkAllocation(b *testing.B, allocate func(left, right LogootPosition, authorID AuthorID) LogootPosition, pattern string) {
+	var length float64
+	for b.Loop() {
+		length = averageLength(insertAll(allocate, insertionPatterns[pattern], 1000))
+	}
+	b.ReportMetric(length, "segments/position")
+}
+
+// Compare the segments/position of each pattern between the two allocators
+func BenchmarkAllocation(b *testing.B) {
+	for _, pattern := range []string{"append", "forward", "backward", "random"} {
+		b.Run("dense/"+pattern, func(b *testing.B) {
+			benchmarkAllocation(b, densePosition, pattern)
+		})
+		b.Run("lseq/"+pattern, func(b *testing.B) {
+			benchmarkAllocation(b, GeneratePosition, pattern)
+		})
+	}
+}
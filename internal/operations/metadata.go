@@ -0,0 +1,110 @@
+package operations
+
+import (
+	"encoding/json"
+	"maps"
+)
+
+// Keys of the fields that were kept in OperationMeta.Context before they had
+// fields of their own. Operations written then still carry them there.
+const (
+	MetaDocumentID = "document_id"
+	MetaBranch     = "branch"
+	MetaTool       = "tool"
+	MetaTicket     = "ticket"
+	MetaLanguage   = "language"
+)
+
+// MetaThread is the Context key naming the conversation an operation belongs
+// to. The operation is linked to that thread.
+const MetaThread = "thread"
+
+// typedFields pairs each promoted key with its field
+func (m *OperationMeta) typedFields() []struct {
+	key   string
+	field *string
+} {
+	return []struct {
+		key   string
+		field *string
+	}{
+		{MetaDocumentID, &m.DocumentID},
+		{MetaBranch, &m.Branch},
+		{MetaTool, &m.Tool},
+		{MetaTicket, &m.Ticket},
+		{MetaLanguage, &m.Language},
+	}
+}
+
+// Normalize moves promoted keys out of Context into their fields. A field
+// that is already set wins over its key, which is dropped either way.
+func (m *OperationMeta) Normalize() {
+	if len(m.Context) == 0 {
+		return
+	}
+	for _, typed := range m.typedFields() {
+		value, exists := m.Context[typed.key]
+		if !exists {
+			continue
+		}
+		if *typed.field == "" {
+			*typed.field = value
+		}
+		delete(m.Context, typed.key)
+	}
+}
+
+// signedContext is Context with the typed fields folded back in under their
+// keys, the form signatures cover. Operations signed before the fields were
+// promoted keep verifying, and whether a value was sent in Context or in its
+// field makes no difference to the signature.
+func (m *OperationMeta) signedContext() map[string]string {
+	var context map[string]string
+	for _, typed := range m.typedFields() {
+		if *typed.field == "" {
+			continue
+		}
+		if context == nil {
+			context = maps.Clone(m.Context)
+			if context == nil {
+				context = make(map[string]string)
+			}
+		}
+		context[typed.key] = *typed.field
+	}
+	if context == nil {
+		return m.Context
+	}
+	return context
+}
+
+// UnmarshalJSON normalizes the metadata it reads, so operations sent or
+// stored with promoted keys in their context read the same as new ones
+func (m *OperationMeta) UnmarshalJSON(data []byte) error {
+	type plain OperationMeta
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*m = OperationMeta(decoded)
+	m.Normalize()
+	return nil
+}
+
+// documentID is the operation's document whether or not the metadata has
+// been normalized yet
+func (m *OperationMeta) documentID() string {
+	if m.DocumentID != "" {
+		return m.DocumentID
+	}
+	return m.Context[MetaDocumentID]
+}
+
+// MainBranch is the line of work operations without a branch are on
+const MainBranch = "main"
+
+// IsMainBranch reports whether branch names the main branch, which
+// operations leave empty or name outright
+func IsMainBranch(branch string) bool {
+	return branch == "" || branch == MainBranch
+}
@@ -0,0 +1,65 @@
+package operations
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestOperationMeta_Normalize(t *testing.T) {
+	op := &Operation{
+		Type:      OpInsert,
+		Position:  NewLogootPosition([]PositionSegment{{Value: big.NewInt(1), AuthorID: "alice"}}),
+		Content:   "hello",
+		Author:    "alice",
+		Timestamp: time.Unix(100, 0),
+		Metadata: OperationMeta{Context: map[string]string{
+			"document_id": "main.go",
+			"branch":      "feature",
+			"language":    "go",
+			"reviewer":    "bob",
+		}},
+	}
+	id := ComputeID(op)
+	payload, err := SigningPayload(op)
+	if err != nil {
+		t.Fatalf("Failed to encode operation: %v", err)
+	}
+
+	op.Metadata.Normalize()
+	if op.Metadata.DocumentID != "main.go" || op.Metadata.Branch != "feature" || op.Metadata.Language != "go" {
+		t.Errorf("Expected the promoted keys in their fields, got %+v", op.Metadata)
+	}
+	if len(op.Metadata.Context) != 1 || op.Metadata.Context["reviewer"] != "bob" {
+		t.Errorf("Expected only the reviewer left in the context, got %v", op.Metadata.Context)
+	}
+
+	// Operations IDed and signed before normalizing keep their ID and signature
+	if ComputeID(op) != id {
+		t.Error("Expected normalizing to keep the operation's ID")
+	}
+	normalized, err := SigningPayload(op)
+	if err != nil {
+		t.Fatalf("Failed to encode operation: %v", err)
+	}
+	if !bytes.Equal(normalized, payload) {
+		t.Errorf("Expected normalizing to keep the signing payload, got %s for %s", normalized, payload)
+	}
+
+	// Decoding normalizes, and a field already set wins over its key
+	var meta OperationMeta
+	if err := json.Unmarshal([]byte(`{"tool":"vim","context":{"tool":"emacs","ticket":"ENG-1"}}`), &meta); err != nil {
+		t.Fatalf("Failed to decode metadata: %v", err)
+	}
+	if meta.Tool != "vim" || meta.Ticket != "ENG-1" || len(meta.Context) != 0 {
+		t.Errorf("Expected tool vim and ticket ENG-1 with an empty context, got %+v", meta)
+	}
+
+	// Metadata without typed fields signs as it always has
+	plain := OperationMeta{SessionID: "s"}
+	if plain.signedContext() != nil {
+		t.Errorf("Expected no context to sign, got %v", plain.signedContext())
+	}
+}
@@ -26,6 +26,10 @@ type Operation struct {
 	Timestamp   time.Time      `json:"timestamp"`
 	Parents     []OperationID  `json:"parents"`
 	Metadata    OperationMeta  `json:"metadata"`
+	// Clock orders the operation; Timestamp is the wall time for display.
+	// The node that accepts an operation from a client sets it, and nodes
+	// it replicates to keep it. Neither its ID nor its signature covers it.
+	Clock HLC `json:"clock,omitempty"`
 }
 
 type OperationType string
@@ -34,6 +38,8 @@ const (
 	OpInsert OperationType = "insert"
 	OpDelete OperationType = "delete"
 	OpMove   OperationType = "move"
+	// OpPatch edits structured content in place, Content holds a JSON patch
+	OpPatch OperationType = "patch"
 )
 
 // Content type constants
@@ -44,9 +50,21 @@ const (
 )
 
 type OperationMeta struct {
-	SessionID string            `json:"session_id"`
-	Intent    string            `json:"intent,omitempty"`
-	Context   map[string]string `json:"context,omitempty"`
+	SessionID string `json:"session_id"`
+	Intent    string `json:"intent,omitempty"`
+	// DocumentID is the document the operation edits
+	DocumentID string `json:"document_id,omitempty"`
+	Branch     string `json:"branch,omitempty"`
+	Tool       string `json:"tool,omitempty"`
+	Ticket     string `json:"ticket,omitempty"`
+	// Language is the language of the edited file, such as "go"
+	Language string `json:"language,omitempty"`
+	// Context holds anything else about the operation
+	Context map[string]string `json:"context,omitempty"`
+	// Signature is the author's base64 Ed25519 signature of SigningPayload
+	Signature string `json:"signature,omitempty"`
+	// Chunk is set on each of the inserts a large one was split into
+	Chunk *Chunk `json:"chunk,omitempty"`
 }
 
 type AuthorID string
@@ -56,12 +74,20 @@ func NewAuthorID(name string) AuthorID {
 	return AuthorID(hex.EncodeToString(hash[:]))
 }
 
+// OperationDAG holds operations by their causal parents. An operation whose
+// parents it doesn't hold yet is kept pending until they arrive. Parents that
+// exist outside the DAG, such as operations applied before it was built, are
+// declared with AddExternal.
 type OperationDAG struct {
 	operations map[OperationID]*Operation
 	children   map[OperationID][]OperationID
 	roots      []OperationID
 	heads      []OperationID
-	mutex      sync.RWMutex
+	external   map[OperationID]bool
+	pending    map[OperationID]*Operation
+	// waiting lists the pending operations held back by each missing parent
+	waiting map[OperationID][]OperationID
+	mutex   sync.RWMutex
 }
 
 func NewOperationDAG() *OperationDAG {
@@ -70,9 +96,15 @@ func NewOperationDAG() *OperationDAG {
 		children:   make(map[OperationID][]OperationID),
 		roots:      make([]OperationID, 0),
 		heads:      make([]OperationID, 0),
+		external:   make(map[OperationID]bool),
+		pending:    make(map[OperationID]*Operation),
+		waiting:    make(map[OperationID][]OperationID),
 	}
 }
 
+// AddOperation adds op, or holds it pending if some of its parents haven't
+// been added. Adding an operation releases those pending on it. An operation
+// that would be its own ancestor is refused with ErrCausalityViolation.
 func (dag *OperationDAG) AddOperation(op *Operation) error {
 	dag.mutex.Lock()
 	defer dag.mutex.Unlock()
@@ -80,7 +112,27 @@ func (dag *OperationDAG) AddOperation(op *Operation) error {
 	if _, exists := dag.operations[op.ID]; exists {
 		return nil
 	}
+	if _, exists := dag.pending[op.ID]; exists {
+		return nil
+	}
+	if err := dag.checkCycle(op); err != nil {
+		return err
+	}
 
+	if missing := dag.missingParents(op); len(missing) > 0 {
+		dag.pending[op.ID] = op
+		for _, parent := range missing {
+			dag.waiting[parent] = append(dag.waiting[parent], op.ID)
+		}
+		return nil
+	}
+
+	dag.insert(op)
+	dag.release(op.ID)
+	return nil
+}
+
+func (dag *OperationDAG) insert(op *Operation) {
 	dag.operations[op.ID] = op
 
 	if len(op.Parents) == 0 {
@@ -93,7 +145,6 @@ func (dag *OperationDAG) AddOperation(op *Operation) error {
 	}
 
 	dag.heads = append(dag.heads, op.ID)
-	return nil
 }
 
 func (dag *OperationDAG) GetOperation(id OperationID) (*Operation, error) {
@@ -120,6 +171,20 @@ func (dag *OperationDAG) GetOperationsSince(timestamp time.Time) ([]*Operation,
 	return operations, nil
 }
 
+// ForEachOperation visits operations without copying them into a slice, in
+// no particular order. Returning false from fn stops the walk. fn runs under
+// the DAG read lock and must not modify the DAG.
+func (dag *OperationDAG) ForEachOperation(fn func(op *Operation) bool) {
+	dag.mutex.RLock()
+	defer dag.mutex.RUnlock()
+
+	for _, op := range dag.operations {
+		if !fn(op) {
+			return
+		}
+	}
+}
+
 func (dag *OperationDAG) GetOperationsByAuthor(author AuthorID) ([]*Operation, error) {
 	dag.mutex.RLock()
 	defer dag.mutex.RUnlock()
@@ -172,11 +237,11 @@ func (dag *OperationDAG) ValidateOperation(op *Operation) error {
 		return ErrInvalidAuthor
 	}
 
-	if op.Type != OpInsert && op.Type != OpDelete && op.Type != OpMove {
+	if op.Type != OpInsert && op.Type != OpDelete && op.Type != OpMove && op.Type != OpPatch {
 		return ErrInvalidOperationType
 	}
 
-	return nil
+	return ValidateContent(op)
 }
 
 func (dag *OperationDAG) removeFromHeads(id OperationID) {
@@ -1,6 +1,7 @@
 package operations
 
 import (
+	"encoding/json"
 	"math/big"
 	"testing"
 	"time"
@@ -138,3 +139,43 @@ func TestOperationValidation(t *testing.T) {
 		t.Errorf("Should accept valid operation: %v", err)
 	}
 }
+
+// FuzzOperation_UnmarshalJSON feeds the operation decoding the API and
+// WebSocket clients reach, checking what decodes can be validated, given an
+// ID and encoded again without panicking, and reads back the same
+func FuzzOperation_UnmarshalJSON(f *testing.F) {
+	f.Add([]byte(`{"type":"insert","position":{"segments":[{"value":1,"author":"alice"}]},"content":"x","author":"alice"}`))
+	f.Add([]byte(`{"type":"delete","position":{"segments":[{"value":-5,"author":"a"},{"value":null,"author":"b"}],"hash":"forged"},"clock":"12"}`))
+	f.Add([]byte(`{"type":"patch","content":"[{\"op\":\"add\",\"path\":\"/a\",\"value\":1}]","content_type":"json","metadata":{"context":{"document_id":"main.go"}}}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var op Operation
+		if err := json.Unmarshal(data, &op); err != nil {
+			return
+		}
+		NewOperationDAG().ValidateOperation(&op)
+		ComputeID(&op)
+		if len(op.Position.Segments) > 0 && op.Position.Key() != NewLogootPosition(op.Position.Segments).Key() {
+			t.Errorf("Expected the position's key to come from its segments, got %q", op.Position.Key())
+		}
+
+		encoded, err := json.Marshal(&op)
+		if err != nil {
+			t.Fatalf("Failed to encode %s: %v", data, err)
+		}
+		var decoded Operation
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("Failed to decode %s: %v", encoded, err)
+		}
+		if decoded.Position.Compare(op.Position) != 0 || ComputeID(&decoded) != ComputeID(&op) {
+			t.Errorf("Expected %s to read back the same, got %s", encoded, mustMarshal(t, &decoded))
+		}
+	})
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,124 @@
+package operations
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+)
+
+// Positions have a binary encoding whose bytes sort the way Compare orders
+// the positions, so storage can index and range over them. Each segment is
+//
+//	sign     0x01 for negative values, 0x02 otherwise
+//	length   bytes in the magnitude, inverted for negative values
+//	value    the magnitude big-endian without leading zeros, inverted for
+//	         negative values
+//	author   the author with 0x00 escaped as 0x00 0xff, ended by 0x00 0x01
+//
+// A position is its segments back to back, so one that prefixes another
+// sorts first. Every position has exactly one encoding.
+const (
+	signNegative = 0x01
+	signPositive = 0x02
+
+	maxMagnitudeBytes = 255
+)
+
+// MarshalBinary encodes p so that bytes.Compare on encodings agrees with
+// Compare on positions
+func (p LogootPosition) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, segment := range p.Segments {
+		if segment.Value == nil {
+			return nil, fmt.Errorf("%w: segment has no value", ErrInvalidPosition)
+		}
+
+		magnitude := segment.Value.Bytes()
+		if len(magnitude) > maxMagnitudeBytes {
+			return nil, fmt.Errorf("%w: value %s is too large", ErrInvalidPosition, segment.Value)
+		}
+		if segment.Value.Sign() < 0 {
+			buf.WriteByte(signNegative)
+			buf.WriteByte(^byte(len(magnitude)))
+			for _, b := range magnitude {
+				buf.WriteByte(^b)
+			}
+		} else {
+			buf.WriteByte(signPositive)
+			buf.WriteByte(byte(len(magnitude)))
+			buf.Write(magnitude)
+		}
+
+		for _, b := range []byte(segment.AuthorID) {
+			buf.WriteByte(b)
+			if b == 0x00 {
+				buf.WriteByte(0xff)
+			}
+		}
+		buf.Write([]byte{0x00, 0x01})
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a position written by MarshalBinary, refusing any
+// encoding MarshalBinary wouldn't have produced
+func (p *LogootPosition) UnmarshalBinary(data []byte) error {
+	segments := make([]PositionSegment, 0)
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return fmt.Errorf("%w: truncated segment", ErrInvalidPosition)
+		}
+		sign, length := data[0], int(data[1])
+		if sign == signNegative {
+			length = int(^data[1])
+		} else if sign != signPositive {
+			return fmt.Errorf("%w: unknown sign 0x%02x", ErrInvalidPosition, sign)
+		}
+		data = data[2:]
+		if len(data) < length {
+			return fmt.Errorf("%w: truncated value", ErrInvalidPosition)
+		}
+
+		magnitude := make([]byte, length)
+		copy(magnitude, data)
+		data = data[length:]
+		if sign == signNegative {
+			for i := range magnitude {
+				magnitude[i] = ^magnitude[i]
+			}
+		}
+		if (length > 0 && magnitude[0] == 0) || (sign == signNegative && length == 0) {
+			return fmt.Errorf("%w: value is not in its shortest form", ErrInvalidPosition)
+		}
+		value := new(big.Int).SetBytes(magnitude)
+		if sign == signNegative {
+			value.Neg(value)
+		}
+
+		var author []byte
+		for {
+			if len(data) < 2 {
+				return fmt.Errorf("%w: unterminated author", ErrInvalidPosition)
+			}
+			if data[0] != 0x00 {
+				author = append(author, data[0])
+				data = data[1:]
+				continue
+			}
+			escape := data[1]
+			data = data[2:]
+			if escape == 0x01 {
+				break
+			}
+			if escape != 0xff {
+				return fmt.Errorf("%w: bad escape in author", ErrInvalidPosition)
+			}
+			author = append(author, 0x00)
+		}
+
+		segments = append(segments, PositionSegment{Value: value, AuthorID: AuthorID(author)})
+	}
+
+	*p = NewLogootPosition(segments)
+	return nil
+}
@@ -0,0 +1,164 @@
+package operations
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestLogootPosition_BinaryRoundTrip(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	positions := []LogootPosition{
+		NewLogootPosition(nil),
+		NewLogootPosition([]PositionSegment{{Value: big.NewInt(0), AuthorID: "alice"}}),
+		NewLogootPosition([]PositionSegment{{Value: big.NewInt(-1), AuthorID: "a\x00b"}, {Value: huge, AuthorID: "bob"}}),
+		NewLogootPosition([]PositionSegment{{Value: big.NewInt(65536), AuthorID: ""}}),
+	}
+
+	for _, position := range positions {
+		encoded, err := position.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to encode %s: %v", position, err)
+		}
+		var decoded LogootPosition
+		if err := decoded.UnmarshalBinary(encoded); err != nil {
+			t.Fatalf("Failed to decode %s: %v", position, err)
+		}
+		if decoded.Compare(position) != 0 || decoded.Key() != position.Key() {
+			t.Errorf("Expected %s after round trip, got %s", position, decoded)
+		}
+	}
+}
+
+func TestLogootPosition_BinaryOrder(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	authors := []AuthorID{"a", "ab", "a\x00", "b", "\x00"}
+	values := []int64{-70000, -256, -255, -1, 0, 1, 255, 256, 70000}
+	random := func() LogootPosition {
+		segments := make([]PositionSegment, 1+r.IntN(3))
+		for i := range segments {
+			segments[i] = PositionSegment{
+				Value:    big.NewInt(values[r.IntN(len(values))]),
+				AuthorID: authors[r.IntN(len(authors))],
+			}
+		}
+		return NewLogootPosition(segments)
+	}
+
+	for i := 0; i < 5000; i++ {
+		a, b := random(), random()
+		encodedA, err := a.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to encode %s: %v", a, err)
+		}
+		encodedB, err := b.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to encode %s: %v", b, err)
+		}
+		if got, want := bytes.Compare(encodedA, encodedB), a.Compare(b); got != want {
+			t.Fatalf("Expected encodings of %q and %q to compare %d, got %d", a, b, want, got)
+		}
+	}
+}
+
+func TestLogootPosition_UnmarshalBinaryRejects(t *testing.T) {
+	encodings := map[string][]byte{
+		"unknown sign":     {0x03, 0x00, 0x00, 0x01},
+		"truncated value":  {0x02, 0x02, 0x01},
+		"leading zero":     {0x02, 0x02, 0x00, 0x01, 0x00, 0x01},
+		"negative zero":    {0x01, 0xff, 0x00, 0x01},
+		"no terminator":    {0x02, 0x01, 0x01, 'a'},
+		"bad escape":       {0x02, 0x01, 0x01, 0x00, 0x02, 0x00, 0x01},
+		"truncated header": {0x02},
+	}
+	for name, encoding := range encodings {
+		var position LogootPosition
+		if err := position.UnmarshalBinary(encoding); !errors.Is(err, ErrInvalidPosition) {
+			t.Errorf("Expected ErrInvalidPosition for %s, got %v", name, err)
+		}
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	for _, text := range []string{"5:alice", "-3:bob.12:carol", "1:a.b.2:c", "1:a:b", "7:x."} {
+		position, err := ParsePosition(text)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", text, err)
+		}
+		if position.String() != text {
+			t.Errorf("Expected %q to parse back to itself, got %q", text, position.String())
+		}
+	}
+
+	for _, text := range []string{"", "alice", "x:alice", "5:", "5:alice.6:"} {
+		if _, err := ParsePosition(text); !errors.Is(err, ErrInvalidPosition) {
+			t.Errorf("Expected ErrInvalidPosition for %q, got %v", text, err)
+		}
+	}
+}
+
+func FuzzParsePosition(f *testing.F) {
+	for _, text := range []string{"5:alice", "-3:bob.12:carol", "1:a.b.2:c", "1:a:b", "7:x.", "5:alice.6:"} {
+		f.Add(text)
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		position, err := ParsePosition(text)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidPosition) {
+				t.Fatalf("Expected ErrInvalidPosition for %q, got %v", text, err)
+			}
+			return
+		}
+		reparsed, err := ParsePosition(position.String())
+		if err != nil {
+			t.Fatalf("Failed to parse %q, written for %q: %v", position.String(), text, err)
+		}
+		if reparsed.Compare(position) != 0 || reparsed.Key() != position.Key() {
+			t.Errorf("Expected %q to read back as %s, got %s", text, position, reparsed)
+		}
+	})
+}
+
+func FuzzLogootPosition_UnmarshalBinary(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0x02, 0x01, 0x05, 'a', 0x00, 0x01})
+	f.Add([]byte{0x01, 0xfe, 0xfe, 'a', 0x00, 0xff, 'b', 0x00, 0x01, 0x02, 0x00, 'c', 0x00, 0x01})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var position LogootPosition
+		if err := position.UnmarshalBinary(data); err != nil {
+			if !errors.Is(err, ErrInvalidPosition) {
+				t.Fatalf("Expected ErrInvalidPosition for %x, got %v", data, err)
+			}
+			return
+		}
+		// Every position has exactly one encoding
+		encoded, err := position.MarshalBinary()
+		if err != nil {
+			t.Fatalf("Failed to encode %s: %v", position, err)
+		}
+		if !bytes.Equal(encoded, data) {
+			t.Errorf("Expected %s to encode as %x, got %x", position, data, encoded)
+		}
+		if position.Key() != NewLogootPosition(position.Segments).Key() {
+			t.Errorf("Expected %s to have the key of its segments", position)
+		}
+	})
+}
+
+// FuzzPositionKey checks that positions have the same key only when they are
+// the same position
+func FuzzPositionKey(f *testing.F) {
+	f.Add([]byte{0x02, 0x01, 0x05, 'a', 0x00, 0x01}, []byte{0x01, 0xfe, 0x05, 'a', 0x00, 0x01})
+	f.Add([]byte{0x02, 0x01, 0x01, 0x02, 'b', 0x00, 0x01}, []byte{0x02, 0x02, 0x01, 0x02, 'b', 0x00, 0x01})
+	f.Fuzz(func(t *testing.T, a, b []byte) {
+		var first, second LogootPosition
+		if first.UnmarshalBinary(a) != nil || second.UnmarshalBinary(b) != nil {
+			return
+		}
+		if same := first.Compare(second) == 0; same != (first.Key() == second.Key()) {
+			t.Errorf("Expected %s and %s to share a key only if they're the same, same is %v", first, second, same)
+		}
+	})
+}
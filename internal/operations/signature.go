@@ -0,0 +1,52 @@
+package operations
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// signedOperation is what an author signs: everything about an operation but
+// its ID, which servers may derive, and the signature itself
+type signedOperation struct {
+	Type        OperationType     `json:"type"`
+	Position    []PositionSegment `json:"position"`
+	Content     string            `json:"content"`
+	ContentType string            `json:"content_type"`
+	Length      int               `json:"length"`
+	Author      AuthorID          `json:"author"`
+	Timestamp   string            `json:"timestamp"`
+	Parents     []OperationID     `json:"parents"`
+	SessionID   string            `json:"session_id"`
+	Intent      string            `json:"intent"`
+	Context     map[string]string `json:"context"`
+}
+
+// SigningPayload is the canonical encoding of op that its signature covers
+func SigningPayload(op *Operation) ([]byte, error) {
+	return json.Marshal(signedOperation{
+		Type:        op.Type,
+		Position:    op.Position.Segments,
+		Content:     op.Content,
+		ContentType: op.ContentType,
+		Length:      op.Length,
+		Author:      op.Author,
+		Timestamp:   op.Timestamp.UTC().Format(time.RFC3339Nano),
+		Parents:     op.Parents,
+		SessionID:   op.Metadata.SessionID,
+		Intent:      op.Metadata.Intent,
+		Context:     op.Metadata.signedContext(),
+	})
+}
+
+// Sign signs op as its author with key, setting Metadata.Signature. Anything
+// changed afterwards, other than the ID, invalidates the signature.
+func Sign(op *Operation, key ed25519.PrivateKey) error {
+	payload, err := SigningPayload(op)
+	if err != nil {
+		return err
+	}
+	op.Metadata.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	return nil
+}
@@ -0,0 +1,61 @@
+package operations
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	op := &Operation{
+		Type:      OpInsert,
+		Position:  NewLogootPosition([]PositionSegment{{Value: big.NewInt(time.Now().UnixNano()), AuthorID: "alice"}}),
+		Content:   "hello",
+		Author:    "alice",
+		Timestamp: time.Now(),
+		Metadata:  OperationMeta{Context: map[string]string{"document_id": "main.go"}},
+	}
+	if err := Sign(op, privateKey); err != nil {
+		t.Fatalf("Failed to sign operation: %v", err)
+	}
+
+	verify := func() bool {
+		payload, err := SigningPayload(op)
+		if err != nil {
+			t.Fatalf("Failed to encode operation: %v", err)
+		}
+		signature, _ := base64.StdEncoding.DecodeString(op.Metadata.Signature)
+		return ed25519.Verify(publicKey, payload, signature)
+	}
+	if !verify() {
+		t.Fatal("Expected the signature to verify")
+	}
+
+	// The ID isn't signed, and the timestamp is compared in UTC
+	op.ID = "assigned-by-server"
+	op.Timestamp = op.Timestamp.In(time.FixedZone("EST", -5*60*60))
+	if !verify() {
+		t.Error("Expected the signature to survive an assigned ID and another time zone")
+	}
+
+	for name, tamper := range map[string]func(){
+		"content":  func() { op.Content = "goodbye" },
+		"author":   func() { op.Author = "bob" },
+		"document": func() { op.Metadata.Context["document_id"] = "other.go" },
+	} {
+		original := *op
+		original.Metadata.Context = map[string]string{"document_id": "main.go"}
+		tamper()
+		if verify() {
+			t.Errorf("Expected changing the %s to invalidate the signature", name)
+		}
+		*op = original
+	}
+}
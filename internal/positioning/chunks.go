@@ -0,0 +1,58 @@
+package positioning
+
+import "strings"
+
+// ChunkMode is how a file's text is split into constructs when it's imported
+type ChunkMode string
+
+const (
+	// ChunkLines makes each line a construct
+	ChunkLines ChunkMode = "line"
+	// ChunkBlocks makes each top-level block a construct: the lines from one
+	// unindented line after a blank line to the next, like a function or a
+	// paragraph. It looks only at indentation, so it works for any language.
+	ChunkBlocks ChunkMode = "block"
+)
+
+func (m ChunkMode) IsValid() bool {
+	return m == ChunkLines || m == ChunkBlocks
+}
+
+// Chunk splits content into the pieces mode makes constructs of, each with
+// its line breaks. Joined, the pieces are content again.
+func Chunk(content string, mode ChunkMode) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if mode != ChunkBlocks {
+		return lines
+	}
+
+	var chunks []string
+	var block strings.Builder
+	blank := false
+	for _, line := range lines {
+		isBlank := strings.TrimSpace(line) == ""
+		// A block ends at a blank line followed by an unindented one, which
+		// starts the next; a closing bracket still belongs to the block above
+		if blank && !isBlank && block.Len() > 0 && startsBlock(line) {
+			chunks = append(chunks, block.String())
+			block.Reset()
+		}
+		block.WriteString(line)
+		blank = isBlank
+	}
+	if block.Len() > 0 {
+		chunks = append(chunks, block.String())
+	}
+	return chunks
+}
+
+func startsBlock(line string) bool {
+	switch line[0] {
+	case ' ', '\t', '}', ')', ']':
+		return false
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package positioning
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunk(t *testing.T) {
+	content := "package main\n\nfunc main() {\n\tx := 1\n\n\tprint(x)\n}\n\n\n// done\nvar y = 2"
+
+	lines := Chunk(content, ChunkLines)
+	if len(lines) != 11 || lines[0] != "package main\n" || lines[10] != "var y = 2" {
+		t.Errorf("Expected one chunk per line, got %q", lines)
+	}
+
+	blocks := Chunk(content, ChunkBlocks)
+	want := []string{"package main\n\n", "func main() {\n\tx := 1\n\n\tprint(x)\n}\n\n\n", "// done\nvar y = 2"}
+	if len(blocks) != len(want) {
+		t.Fatalf("Expected %d blocks, got %q", len(want), blocks)
+	}
+	for i := range want {
+		if blocks[i] != want[i] {
+			t.Errorf("Expected block %d to be %q, got %q", i, want[i], blocks[i])
+		}
+	}
+
+	for _, mode := range []ChunkMode{ChunkLines, ChunkBlocks} {
+		if joined := strings.Join(Chunk(content, mode), ""); joined != content {
+			t.Errorf("Expected %s chunks to join back into the content, got %q", mode, joined)
+		}
+		if chunks := Chunk("", mode); len(chunks) != 0 {
+			t.Errorf("Expected no %s chunks of nothing, got %q", mode, chunks)
+		}
+	}
+}
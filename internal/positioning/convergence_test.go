@@ -0,0 +1,211 @@
+package positioning
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// The convergence tests simulate authors editing one document at once, each
+// on a replica of their own, and check that every replica ends up rendering
+// the same text whatever order the operations reached it in. Operations are
+// delivered causally, each only after those its author had seen, and carry
+// clocks, as the collaboration engine resolves parents and stamps clocks
+// before applying operations.
+
+// simulatedOp is an operation and the operations its author had applied
+// when making it
+type simulatedOp struct {
+	op   *operations.Operation
+	seen map[operations.OperationID]bool
+}
+
+type replica struct {
+	author    operations.AuthorID
+	doc       *Document
+	applied   map[operations.OperationID]bool
+	allocator *operations.Allocator
+	clock     *operations.HybridClock
+	made      int
+}
+
+func (r *replica) apply(op *operations.Operation) error {
+	if r.clock != nil {
+		if err := r.clock.Observe(op.Clock); err != nil {
+			return err
+		}
+	}
+	if err := r.doc.ApplyOperation(op); err != nil {
+		return fmt.Errorf("%s failed to apply %s: %w", r.author, op.ID, err)
+	}
+	r.applied[op.ID] = true
+	return nil
+}
+
+// edit makes an operation on r's replica: usually an insert between two
+// neighbouring constructs, otherwise a delete of one of them
+func (r *replica) edit(rng *rand.Rand) *simulatedOp {
+	positions := r.doc.Positions()
+	seen := make(map[operations.OperationID]bool, len(r.applied))
+	for id := range r.applied {
+		seen[id] = true
+	}
+	r.made++
+
+	op := &operations.Operation{
+		ID:     operations.NewOperationID([]byte(fmt.Sprintf("%s/%d", r.author, r.made))),
+		Author: r.author,
+		Clock:  r.clock.Now(),
+	}
+	if len(positions) > 0 && rng.IntN(4) == 0 {
+		op.Type = operations.OpDelete
+		op.Position = positions[rng.IntN(len(positions))]
+		return &simulatedOp{op: op, seen: seen}
+	}
+
+	var left, right operations.LogootPosition
+	gap := rng.IntN(len(positions) + 1)
+	if gap > 0 {
+		left = positions[gap-1]
+	}
+	if gap < len(positions) {
+		right = positions[gap]
+	}
+	op.Type = operations.OpInsert
+	op.Position = r.allocator.Between(left, right, r.author)
+	op.Content = fmt.Sprintf("%s%d ", r.author, r.made)
+	return &simulatedOp{op: op, seen: seen}
+}
+
+// deliverable lists the operations r hasn't applied whose causes it has
+func (r *replica) deliverable(log []*simulatedOp) []*simulatedOp {
+	var ready []*simulatedOp
+	for _, candidate := range log {
+		if r.applied[candidate.op.ID] {
+			continue
+		}
+		causal := true
+		for id := range candidate.seen {
+			if !r.applied[id] {
+				causal = false
+				break
+			}
+		}
+		if causal {
+			ready = append(ready, candidate)
+		}
+	}
+	return ready
+}
+
+// simulateEditing has authors make steps edits between them, each delivered
+// to the other replicas at random but causally, and returns every operation
+// made once each replica has applied them all
+func simulateEditing(rng *rand.Rand, authors, steps int) ([]*replica, []*simulatedOp, error) {
+	replicas := make([]*replica, authors)
+	for i := range replicas {
+		replicas[i] = &replica{
+			author:    operations.AuthorID(fmt.Sprintf("author%d", i)),
+			doc:       NewDocument("convergence.txt"),
+			applied:   map[operations.OperationID]bool{},
+			allocator: operations.NewAllocator(rand.NewPCG(rng.Uint64(), rng.Uint64())),
+			clock:     operations.NewHybridClock(),
+		}
+	}
+
+	var log []*simulatedOp
+	for step := 0; step < steps; step++ {
+		r := replicas[rng.IntN(authors)]
+		if ready := r.deliverable(log); len(ready) > 0 && rng.IntN(2) == 0 {
+			if err := r.apply(ready[rng.IntN(len(ready))].op); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		made := r.edit(rng)
+		if err := r.apply(made.op); err != nil {
+			return nil, nil, err
+		}
+		log = append(log, made)
+	}
+
+	for _, r := range replicas {
+		for ready := r.deliverable(log); len(ready) > 0; ready = r.deliverable(log) {
+			if err := r.apply(ready[rng.IntN(len(ready))].op); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return replicas, log, nil
+}
+
+// replay applies log to a new document in a random causal order
+func replay(rng *rand.Rand, log []*simulatedOp) (*replica, error) {
+	r := &replica{author: "replay", doc: NewDocument("convergence.txt"), applied: map[operations.OperationID]bool{}}
+	for ready := r.deliverable(log); len(ready) > 0; ready = r.deliverable(log) {
+		if err := r.apply(ready[rng.IntN(len(ready))].op); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// checkConvergence simulates an editing session from seed and returns what
+// went wrong, if anything
+func checkConvergence(seed1, seed2 uint64, authors, steps int) error {
+	rng := rand.New(rand.NewPCG(seed1, seed2))
+	replicas, log, err := simulateEditing(rng, authors, steps)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 3; i++ {
+		replayed, err := replay(rng, log)
+		if err != nil {
+			return err
+		}
+		replicas = append(replicas, replayed)
+	}
+
+	expected, err := replicas[0].doc.Render()
+	if err != nil {
+		return err
+	}
+	for _, r := range replicas {
+		if len(r.applied) != len(log) {
+			return fmt.Errorf("%s applied %d of %d operations", r.author, len(r.applied), len(log))
+		}
+		rendered, err := r.doc.Render()
+		if err != nil {
+			return err
+		}
+		if rendered != expected {
+			return fmt.Errorf("%s rendered %q, %s rendered %q", r.author, rendered, replicas[0].author, expected)
+		}
+		if positions := r.doc.Positions(); len(positions) != r.doc.ConstructCount() {
+			return fmt.Errorf("%s indexes %d positions for %d constructs", r.author, len(positions), r.doc.ConstructCount())
+		}
+	}
+	return nil
+}
+
+func TestDocument_Convergence(t *testing.T) {
+	for seed := uint64(0); seed < 200; seed++ {
+		if err := checkConvergence(seed, seed*7919, 2+int(seed%4), 100); err != nil {
+			t.Fatalf("Replicas diverged with seed %d: %v", seed, err)
+		}
+	}
+}
+
+// FuzzDocument_Convergence explores more sessions than the test above with
+// go test -fuzz FuzzDocument_Convergence ./internal/positioning
+func FuzzDocument_Convergence(f *testing.F) {
+	f.Add(uint64(1), uint64(2), uint8(3), uint8(100))
+	f.Add(uint64(42), uint64(7), uint8(5), uint8(250))
+	f.Fuzz(func(t *testing.T, seed1, seed2 uint64, authors, steps uint8) {
+		if err := checkConvergence(seed1, seed2, 2+int(authors%6), int(steps)); err != nil {
+			t.Fatalf("Replicas diverged: %v", err)
+		}
+	})
+}
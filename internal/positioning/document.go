@@ -2,6 +2,9 @@ package positioning
 
 import (
 	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/jeremytregunna/contextdb/internal/operations"
@@ -43,28 +46,39 @@ type ConstructMeta struct {
 	References []string          `json:"references,omitempty"`
 	Confidence float64           `json:"confidence,omitempty"`
 	Attributes map[string]string `json:"attributes,omitempty"`
+	// ContentType is the content type of the operation that produced the construct
+	ContentType string `json:"content_type,omitempty"`
 }
 
+// Document is the state of a file built from its operations. Its constructs
+// are only reached through its methods, which hold its lock; Snapshot copies
+// them out for serializing.
 type Document struct {
-	FilePath      string                                               `json:"file_path"`
-	Constructs    map[operations.PositionKey]*Construct                `json:"constructs"`
-	PositionIndex map[operations.PositionKey]operations.LogootPosition `json:"position_index"`
-	PositionIdx   []operations.LogootPosition                          `json:"position_idx"`
-	AppliedOps    map[operations.OperationID]bool                      `json:"applied_ops"`
-	ContentHash   [32]byte                                             `json:"content_hash"`
-	Version       uint64                                               `json:"version"`
-	LastOperation operations.OperationID                               `json:"last_operation"`
+	FilePath      string
+	ContentHash   [32]byte
+	Version       uint64
+	LastOperation operations.OperationID
+	constructs    map[operations.PositionKey]*Construct
+	positions     []operations.LogootPosition // constructs' positions in order
+	appliedOps    map[operations.OperationID]bool
+	writes        map[operations.PositionKey]positionWrite // latest insert or delete at each position
 	mutex         sync.RWMutex
 }
 
+// positionWrite is an operation that inserted or deleted at a position
+type positionWrite struct {
+	clock operations.HLC
+	id    operations.OperationID
+}
+
 func NewDocument(filePath string) *Document {
 	return &Document{
-		FilePath:      filePath,
-		Constructs:    make(map[operations.PositionKey]*Construct),
-		PositionIndex: make(map[operations.PositionKey]operations.LogootPosition),
-		AppliedOps:    make(map[operations.OperationID]bool),
-		PositionIdx:   make([]operations.LogootPosition, 0),
-		Version:       0,
+		FilePath:   filePath,
+		constructs: make(map[operations.PositionKey]*Construct),
+		positions:  make([]operations.LogootPosition, 0),
+		appliedOps: make(map[operations.OperationID]bool),
+		writes:     make(map[operations.PositionKey]positionWrite),
+		Version:    0,
 	}
 }
 
@@ -77,12 +91,11 @@ func (doc *Document) InsertConstruct(construct *Construct) error {
 	}
 
 	posKey := construct.Position.Key()
-	if _, exists := doc.Constructs[posKey]; exists {
+	if _, exists := doc.constructs[posKey]; exists {
 		return ErrPositionOccupied
 	}
 
-	doc.Constructs[posKey] = construct
-	doc.PositionIndex[posKey] = construct.Position
+	doc.constructs[posKey] = construct
 	doc.insertPositionSorted(construct.Position)
 	doc.Version++
 	doc.updateContentHash()
@@ -95,13 +108,12 @@ func (doc *Document) DeleteConstruct(pos operations.LogootPosition) (*Construct,
 	defer doc.mutex.Unlock()
 
 	posKey := pos.Key()
-	construct, exists := doc.Constructs[posKey]
+	construct, exists := doc.constructs[posKey]
 	if !exists {
 		return nil, ErrConstructNotFound
 	}
 
-	delete(doc.Constructs, posKey)
-	delete(doc.PositionIndex, posKey)
+	delete(doc.constructs, posKey)
 	doc.removePositionFromIndex(pos)
 	doc.Version++
 	doc.updateContentHash()
@@ -114,24 +126,30 @@ func (doc *Document) GetConstruct(pos operations.LogootPosition) (*Construct, er
 	defer doc.mutex.RUnlock()
 
 	posKey := pos.Key()
-	construct, exists := doc.Constructs[posKey]
+	construct, exists := doc.constructs[posKey]
 	if !exists {
 		return nil, ErrConstructNotFound
 	}
 	return construct, nil
 }
 
+// GetConstructsInRange returns the constructs from start to end inclusive, in
+// position order. Stores answer the same query without loading the document.
 func (doc *Document) GetConstructsInRange(start, end operations.LogootPosition) ([]*Construct, error) {
 	doc.mutex.RLock()
 	defer doc.mutex.RUnlock()
 
+	first := sort.Search(len(doc.positions), func(i int) bool {
+		return doc.positions[i].Compare(start) >= 0
+	})
+
 	var constructs []*Construct
-	for _, pos := range doc.PositionIdx {
-		if pos.Compare(start) >= 0 && pos.Compare(end) <= 0 {
-			posKey := pos.Key()
-			if construct, exists := doc.Constructs[posKey]; exists {
-				constructs = append(constructs, construct)
-			}
+	for _, pos := range doc.positions[first:] {
+		if pos.Compare(end) > 0 {
+			break
+		}
+		if construct, exists := doc.constructs[pos.Key()]; exists {
+			constructs = append(constructs, construct)
 		}
 	}
 	return constructs, nil
@@ -142,7 +160,7 @@ func (doc *Document) GetConstructsByType(constructType ConstructType) ([]*Constr
 	defer doc.mutex.RUnlock()
 
 	var constructs []*Construct
-	for _, construct := range doc.Constructs {
+	for _, construct := range doc.constructs {
 		if construct.Type == constructType {
 			constructs = append(constructs, construct)
 		}
@@ -158,16 +176,112 @@ func (doc *Document) GetPositionBetween(left, right operations.LogootPosition) (
 	return operations.GeneratePosition(left, right, authorID), nil
 }
 
+// ForEachConstruct calls fn with each construct in position order until fn
+// returns false. The document is locked for reading meanwhile, so fn must not
+// change it.
+func (doc *Document) ForEachConstruct(fn func(*Construct) bool) {
+	doc.mutex.RLock()
+	defer doc.mutex.RUnlock()
+
+	for _, pos := range doc.positions {
+		if construct, exists := doc.constructs[pos.Key()]; exists && !fn(construct) {
+			return
+		}
+	}
+}
+
+// ConstructCount returns the number of constructs in the document
+func (doc *Document) ConstructCount() int {
+	doc.mutex.RLock()
+	defer doc.mutex.RUnlock()
+
+	return len(doc.constructs)
+}
+
+// Positions returns a copy of the ordered position index
+func (doc *Document) Positions() []operations.LogootPosition {
+	doc.mutex.RLock()
+	defer doc.mutex.RUnlock()
+
+	positions := make([]operations.LogootPosition, len(doc.positions))
+	copy(positions, doc.positions)
+	return positions
+}
+
+// constructOverhead approximates the memory a construct takes beyond its
+// content: its position, IDs, metadata and index entries
+const constructOverhead = 256
+
+// Size estimates the bytes the document takes in memory
+func (doc *Document) Size() int64 {
+	doc.mutex.RLock()
+	defer doc.mutex.RUnlock()
+
+	size := int64(0)
+	for _, construct := range doc.constructs {
+		size += int64(len(construct.Content)) + constructOverhead
+	}
+	return size
+}
+
+func (doc *Document) CurrentVersion() uint64 {
+	doc.mutex.RLock()
+	defer doc.mutex.RUnlock()
+
+	return doc.Version
+}
+
 func (doc *Document) Render() (string, error) {
+	return doc.RenderContentTypes()
+}
+
+// RenderContentTypes renders only constructs of the given content types, or
+// every construct when none are given. Binary constructs render as base64.
+func (doc *Document) RenderContentTypes(contentTypes ...string) (string, error) {
 	doc.mutex.RLock()
 	defer doc.mutex.RUnlock()
 
+	allowed := make(map[string]bool, len(contentTypes))
+	for _, contentType := range contentTypes {
+		allowed[operations.NormalizeContentType(contentType)] = true
+	}
+
 	var content string
-	for _, pos := range doc.PositionIdx {
+	for _, pos := range doc.positions {
 		posKey := pos.Key()
-		if construct, exists := doc.Constructs[posKey]; exists {
-			content += construct.Content
+		construct, exists := doc.constructs[posKey]
+		if !exists {
+			continue
+		}
+		if len(allowed) > 0 && !allowed[operations.NormalizeContentType(construct.Metadata.ContentType)] {
+			continue
 		}
+		content += construct.Content
+	}
+	return content, nil
+}
+
+// Bytes renders the document as the file it stands for, decoding binary
+// constructs rather than rendering them as base64
+func (doc *Document) Bytes() ([]byte, error) {
+	doc.mutex.RLock()
+	defer doc.mutex.RUnlock()
+
+	var content []byte
+	for _, pos := range doc.positions {
+		construct, exists := doc.constructs[pos.Key()]
+		if !exists {
+			continue
+		}
+		if operations.NormalizeContentType(construct.Metadata.ContentType) != operations.ContentTypeBinary {
+			content = append(content, construct.Content...)
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(construct.Content)
+		if err != nil {
+			return nil, fmt.Errorf("%w: construct %s", operations.ErrInvalidContent, construct.ID)
+		}
+		content = append(content, data...)
 	}
 	return content, nil
 }
@@ -181,6 +295,8 @@ func (doc *Document) ApplyOperation(op *operations.Operation) error {
 		return doc.applyInsert(op)
 	case operations.OpDelete:
 		return doc.applyDelete(op)
+	case operations.OpPatch:
+		return doc.applyPatch(op)
 	default:
 		return ErrUnsupportedOperation
 	}
@@ -188,14 +304,19 @@ func (doc *Document) ApplyOperation(op *operations.Operation) error {
 
 func (doc *Document) applyInsert(op *operations.Operation) error {
 	// Check for duplicate operations, not duplicate positions
-	if doc.AppliedOps[op.ID] {
+	if doc.appliedOps[op.ID] {
 		// Operation already applied, ignore silently (idempotent)
 		return nil
 	}
 
 	posKey := op.Position.Key()
+	if !doc.supersedes(posKey, op) {
+		doc.appliedOps[op.ID] = true
+		return nil
+	}
 	// Allow multiple operations at the same position - positions can be reused
 	// If there's an existing construct at this position, we replace it
+	_, replacing := doc.constructs[posKey]
 
 	constructType := doc.inferConstructType(op.Content, op.Metadata)
 	construct := &Construct{
@@ -208,10 +329,11 @@ func (doc *Document) applyInsert(op *operations.Operation) error {
 		Metadata:   doc.buildConstructMeta(op),
 	}
 
-	doc.Constructs[posKey] = construct
-	doc.PositionIndex[posKey] = op.Position
-	doc.insertPositionSorted(op.Position)
-	doc.AppliedOps[op.ID] = true // Mark operation as applied
+	doc.constructs[posKey] = construct
+	if !replacing {
+		doc.insertPositionSorted(op.Position)
+	}
+	doc.appliedOps[op.ID] = true // Mark operation as applied
 	doc.LastOperation = op.ID
 	doc.Version++
 	doc.updateContentHash()
@@ -221,23 +343,26 @@ func (doc *Document) applyInsert(op *operations.Operation) error {
 
 func (doc *Document) applyDelete(op *operations.Operation) error {
 	// Check for duplicate operations
-	if doc.AppliedOps[op.ID] {
+	if doc.appliedOps[op.ID] {
 		// Operation already applied, ignore silently (idempotent)
 		return nil
 	}
 
 	posKey := op.Position.Key()
-	construct, exists := doc.Constructs[posKey]
+	if !doc.supersedes(posKey, op) {
+		doc.appliedOps[op.ID] = true
+		return nil
+	}
+	construct, exists := doc.constructs[posKey]
 	if !exists {
 		// Nothing to delete, but mark as applied
-		doc.AppliedOps[op.ID] = true
+		doc.appliedOps[op.ID] = true
 		return nil
 	}
 
-	delete(doc.Constructs, posKey)
-	delete(doc.PositionIndex, posKey)
+	delete(doc.constructs, posKey)
 	doc.removePositionFromIndex(op.Position)
-	doc.AppliedOps[op.ID] = true // Mark operation as applied
+	doc.appliedOps[op.ID] = true // Mark operation as applied
 	doc.LastOperation = op.ID
 	doc.Version++
 	doc.updateContentHash()
@@ -248,13 +373,94 @@ func (doc *Document) applyDelete(op *operations.Operation) error {
 	return nil
 }
 
+// CheckOperation reports the error ApplyOperation would return for op
+// without changing the document, so a caller can refuse op before keeping it
+func (doc *Document) CheckOperation(op *operations.Operation) error {
+	doc.mutex.RLock()
+	defer doc.mutex.RUnlock()
+
+	switch op.Type {
+	case operations.OpInsert, operations.OpDelete:
+		return nil
+	case operations.OpPatch:
+		if doc.appliedOps[op.ID] {
+			return nil
+		}
+		_, _, err := doc.patch(op)
+		return err
+	default:
+		return ErrUnsupportedOperation
+	}
+}
+
+func (doc *Document) applyPatch(op *operations.Operation) error {
+	if doc.appliedOps[op.ID] {
+		return nil
+	}
+
+	construct, patched, err := doc.patch(op)
+	if err != nil {
+		return err
+	}
+
+	construct.Content = patched
+	construct.ModifiedBy = op.ID
+	doc.appliedOps[op.ID] = true
+	doc.LastOperation = op.ID
+	doc.Version++
+	doc.updateContentHash()
+
+	return nil
+}
+
+// patch returns the construct a patch operation changes and its content
+// once patched
+func (doc *Document) patch(op *operations.Operation) (*Construct, string, error) {
+	construct, exists := doc.constructs[op.Position.Key()]
+	if !exists {
+		return nil, "", ErrConstructNotFound
+	}
+	if operations.NormalizeContentType(construct.Metadata.ContentType) != operations.ContentTypeJSON {
+		return nil, "", operations.ErrContentTypeMismatch
+	}
+
+	patch, err := operations.ParsePatch(op.Content)
+	if err != nil {
+		return nil, "", err
+	}
+
+	patched, err := operations.ApplyPatch(construct.Content, patch)
+	if err != nil {
+		return nil, "", err
+	}
+	return construct, patched, nil
+}
+
+// supersedes reports whether op takes effect at posKey, recording it as the
+// latest write there if so. Positions are meant to be used once, but an
+// author inserting where it deleted can be given the same one again, and a
+// delete made concurrently for the first insert must then remove the second
+// on every replica or on none. So the inserts and deletes at a position take
+// effect in clock order, the last one deciding, whatever order they arrive
+// in. Operations without a clock, applied outside the engine, take effect
+// in the order they're applied.
+func (doc *Document) supersedes(posKey operations.PositionKey, op *operations.Operation) bool {
+	if last, ok := doc.writes[posKey]; ok && op.Clock != 0 && last.clock != 0 {
+		if op.Clock < last.clock || op.Clock == last.clock && op.ID < last.id {
+			return false
+		}
+	}
+	doc.writes[posKey] = positionWrite{clock: op.Clock, id: op.ID}
+	return true
+}
+
 func (doc *Document) insertPositionSorted(pos operations.LogootPosition) {
 	// Binary search to find insertion point
-	low, high := 0, len(doc.PositionIdx)
+	low, high := 0, len(doc.positions)
 
 	for low < high {
 		mid := (low + high) / 2
-		if doc.PositionIdx[mid].Compare(pos) < 0 {
+		if doc.positions[mid].Compare(pos) < 0 {
 			low = mid + 1
 		} else {
 			high = mid
@@ -262,15 +468,15 @@ func (doc *Document) insertPositionSorted(pos operations.LogootPosition) {
 	}
 
 	// Insert at the correct position
-	doc.PositionIdx = append(doc.PositionIdx, operations.LogootPosition{})
-	copy(doc.PositionIdx[low+1:], doc.PositionIdx[low:])
-	doc.PositionIdx[low] = pos
+	doc.positions = append(doc.positions, operations.LogootPosition{})
+	copy(doc.positions[low+1:], doc.positions[low:])
+	doc.positions[low] = pos
 }
 
 func (doc *Document) removePositionFromIndex(pos operations.LogootPosition) {
-	for i, p := range doc.PositionIdx {
+	for i, p := range doc.positions {
 		if p.Compare(pos) == 0 {
-			doc.PositionIdx = append(doc.PositionIdx[:i], doc.PositionIdx[i+1:]...)
+			doc.positions = append(doc.positions[:i], doc.positions[i+1:]...)
 			break
 		}
 	}
@@ -279,9 +485,9 @@ func (doc *Document) removePositionFromIndex(pos operations.LogootPosition) {
 func (doc *Document) updateContentHash() {
 	// This method is called from within locked methods, so don't take locks here
 	var content string
-	for _, pos := range doc.PositionIdx {
+	for _, pos := range doc.positions {
 		posKey := pos.Key()
-		if construct, exists := doc.Constructs[posKey]; exists {
+		if construct, exists := doc.constructs[posKey]; exists {
 			content += construct.Content
 		}
 	}
@@ -346,8 +552,9 @@ func (doc *Document) inferConstructType(content string, metadata operations.Oper
 
 func (doc *Document) buildConstructMeta(op *operations.Operation) ConstructMeta {
 	meta := ConstructMeta{
-		Attributes: make(map[string]string),
-		Confidence: 1.0,
+		Attributes:  make(map[string]string),
+		Confidence:  1.0,
+		ContentType: operations.NormalizeContentType(op.ContentType),
 	}
 
 	if intent := op.Metadata.Intent; intent != "" {
@@ -215,3 +215,67 @@ func TestInferConstructType(t *testing.T) {
 		}
 	}
 }
+
+func TestDocument_StructuredContent(t *testing.T) {
+	doc := NewDocument("config")
+
+	jsonPos := operations.NewLogootPosition([]operations.PositionSegment{{Value: big.NewInt(1), AuthorID: "author1"}})
+	binaryPos := operations.NewLogootPosition([]operations.PositionSegment{{Value: big.NewInt(2), AuthorID: "author1"}})
+
+	ops := []*operations.Operation{
+		{ID: "insert-json", Type: operations.OpInsert, Position: jsonPos, Content: `{"timeout":10}`, ContentType: operations.ContentTypeJSON, Author: "author1", Timestamp: time.Now()},
+		{ID: "insert-binary", Type: operations.OpInsert, Position: binaryPos, Content: operations.EncodeBinary([]byte("png")), ContentType: operations.ContentTypeBinary, Author: "author1", Timestamp: time.Now()},
+		{ID: "patch-json", Type: operations.OpPatch, Position: jsonPos, Content: `[{"op":"replace","path":"/timeout","value":30}]`, ContentType: operations.ContentTypeJSON, Author: "author1", Timestamp: time.Now()},
+	}
+	for _, op := range ops {
+		if err := doc.ApplyOperation(op); err != nil {
+			t.Fatalf("Failed to apply %s: %v", op.ID, err)
+		}
+	}
+
+	rendered, _ := doc.RenderContentTypes(operations.ContentTypeJSON)
+	if rendered != `{"timeout":30}` {
+		t.Errorf("Expected patched JSON only, got %q", rendered)
+	}
+
+	construct, _ := doc.GetConstruct(jsonPos)
+	if construct.ModifiedBy != "patch-json" {
+		t.Errorf("Expected patch to mark construct modified, got %s", construct.ModifiedBy)
+	}
+
+	badPatch := &operations.Operation{ID: "patch-binary", Type: operations.OpPatch, Position: binaryPos, Content: `[]`, ContentType: operations.ContentTypeJSON, Author: "author1"}
+	if err := doc.ApplyOperation(badPatch); err != operations.ErrContentTypeMismatch {
+		t.Errorf("Expected patching binary content to fail, got %v", err)
+	}
+}
+
+func TestDocument_ReusedPosition(t *testing.T) {
+	pos := operations.NewLogootPosition([]operations.PositionSegment{{Value: big.NewInt(1), AuthorID: "alice"}})
+	op := func(id string, opType operations.OperationType, content string, clock operations.HLC) *operations.Operation {
+		return &operations.Operation{ID: operations.OperationID(id), Type: opType, Position: pos, Content: content, Author: "alice", Clock: clock}
+	}
+	// Alice inserts, deletes and inserts again at the same position, while
+	// Bob deletes her first insert having seen nothing since
+	first, deleted, second := op("first", operations.OpInsert, "first", 1), op("deleted", operations.OpDelete, "", 2), op("second", operations.OpInsert, "second", 4)
+	for name, bob := range map[string]*operations.Operation{"before": op("bob", operations.OpDelete, "", 3), "after": op("bob", operations.OpDelete, "", 5)} {
+		expected := "second"
+		if name == "after" {
+			expected = ""
+		}
+		for _, order := range [][]*operations.Operation{{first, deleted, second, bob}, {first, bob, deleted, second}} {
+			doc := NewDocument("test.go")
+			for _, o := range order {
+				if err := doc.ApplyOperation(o); err != nil {
+					t.Fatalf("Failed to apply %s: %v", o.ID, err)
+				}
+			}
+			content, err := doc.Render()
+			if err != nil {
+				t.Fatalf("Failed to render: %v", err)
+			}
+			if content != expected || len(doc.Positions()) != doc.ConstructCount() {
+				t.Errorf("Expected %q with Bob's delete %s the second insert, got %q and %d positions", expected, name, content, len(doc.Positions()))
+			}
+		}
+	}
+}
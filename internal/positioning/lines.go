@@ -0,0 +1,112 @@
+package positioning
+
+import (
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// LineRange returns the 1-based first and last lines of the rendered document
+// covered by the constructs between start and end inclusive
+func (doc *Document) LineRange(start, end operations.LogootPosition) (int, int, error) {
+	doc.mutex.RLock()
+	defer doc.mutex.RUnlock()
+
+	line := 1
+	first, last := 0, 0
+	for _, pos := range doc.positions {
+		construct, exists := doc.constructs[pos.Key()]
+		if !exists {
+			continue
+		}
+
+		if pos.Compare(start) >= 0 && pos.Compare(end) <= 0 {
+			if first == 0 {
+				first = line
+			}
+			last = line + spannedLines(construct.Content)
+		}
+		line += strings.Count(construct.Content, "\n")
+	}
+
+	if first == 0 {
+		return 0, 0, ErrConstructNotFound
+	}
+	return first, last, nil
+}
+
+// PositionsForLines finds the constructs covering lines first to last of the
+// rendered document. It returns their positions and the line the first of
+// them starts on, which is before first when a construct spans several lines.
+func (doc *Document) PositionsForLines(first, last int) (operations.LogootPosition, operations.LogootPosition, int, error) {
+	var start, end operations.LogootPosition
+	if first < 1 || last < first {
+		return start, end, 0, ErrInvalidRange
+	}
+
+	doc.mutex.RLock()
+	defer doc.mutex.RUnlock()
+
+	line := 1
+	startLine := 0
+	for _, pos := range doc.positions {
+		construct, exists := doc.constructs[pos.Key()]
+		if !exists {
+			continue
+		}
+
+		constructEnd := line + spannedLines(construct.Content)
+		if startLine == 0 && constructEnd >= first {
+			start, startLine = pos, line
+		}
+		if startLine != 0 && line <= last {
+			end = pos
+		}
+		if constructEnd >= last && startLine != 0 {
+			return start, end, startLine, nil
+		}
+		line += strings.Count(construct.Content, "\n")
+	}
+
+	return start, end, 0, ErrInvalidRange
+}
+
+// LineSpan is the 1-based first and last line of the rendered document a
+// construct covers
+type LineSpan struct {
+	Position  operations.LogootPosition
+	Construct *Construct
+	FirstLine int
+	LastLine  int
+}
+
+// LineSpans lists every construct in document order with the lines it covers.
+// Constructs that don't end in a newline share their last line with the next.
+func (doc *Document) LineSpans() []LineSpan {
+	doc.mutex.RLock()
+	defer doc.mutex.RUnlock()
+
+	spans := make([]LineSpan, 0, len(doc.positions))
+	line := 1
+	for _, pos := range doc.positions {
+		construct, exists := doc.constructs[pos.Key()]
+		if !exists {
+			continue
+		}
+
+		spans = append(spans, LineSpan{
+			Position:  pos,
+			Construct: construct,
+			FirstLine: line,
+			LastLine:  line + spannedLines(construct.Content),
+		})
+		line += strings.Count(construct.Content, "\n")
+	}
+	return spans
+}
+
+// spannedLines counts the line breaks inside content, ignoring a trailing one
+// since it ends the construct's last line rather than starting another
+func spannedLines(content string) int {
+	return strings.Count(strings.TrimSuffix(content, "\n"), "\n")
+}
@@ -0,0 +1,104 @@
+package positioning
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func newLinesDocument(t *testing.T, contents ...string) (*Document, []operations.LogootPosition) {
+	t.Helper()
+
+	doc := NewDocument("lines.go")
+	positions := make([]operations.LogootPosition, len(contents))
+	for i, content := range contents {
+		positions[i] = operations.NewLogootPosition([]operations.PositionSegment{
+			{Value: big.NewInt(int64(i + 1)), AuthorID: "author1"},
+		})
+		err := doc.InsertConstruct(&Construct{
+			ID:        ConstructID(content),
+			Content:   content,
+			Type:      ConstructContent,
+			Position:  positions[i],
+			CreatedBy: operations.NewOperationID([]byte(content)),
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert construct: %v", err)
+		}
+	}
+	return doc, positions
+}
+
+func TestDocument_LineRange(t *testing.T) {
+	doc, positions := newLinesDocument(t, "package main\n\n", "func a() {\n}\n", "func b() {}\n")
+
+	tests := []struct {
+		name        string
+		start, end  int
+		first, last int
+	}{
+		{"first construct", 0, 0, 1, 2},
+		{"multi-line construct", 1, 1, 3, 4},
+		{"last construct", 2, 2, 5, 5},
+		{"whole document", 0, 2, 1, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, last, err := doc.LineRange(positions[tt.start], positions[tt.end])
+			if err != nil {
+				t.Fatalf("Failed to get line range: %v", err)
+			}
+			if first != tt.first || last != tt.last {
+				t.Errorf("Expected lines %d-%d, got %d-%d", tt.first, tt.last, first, last)
+			}
+		})
+	}
+
+	missing := operations.NewLogootPosition([]operations.PositionSegment{{Value: big.NewInt(9), AuthorID: "author1"}})
+	if _, _, err := doc.LineRange(missing, missing); !errors.Is(err, ErrConstructNotFound) {
+		t.Errorf("Expected ErrConstructNotFound, got %v", err)
+	}
+}
+
+func TestDocument_PositionsForLines(t *testing.T) {
+	doc, positions := newLinesDocument(t, "package main\n\n", "func a() {\n}\n", "func b() {}\n")
+
+	start, end, startLine, err := doc.PositionsForLines(4, 5)
+	if err != nil {
+		t.Fatalf("Failed to get positions: %v", err)
+	}
+	if start.Compare(positions[1]) != 0 || end.Compare(positions[2]) != 0 {
+		t.Errorf("Expected the second and third constructs, got %v and %v", start, end)
+	}
+	if startLine != 3 {
+		t.Errorf("Expected the range to start on line 3, got %d", startLine)
+	}
+
+	for _, lines := range [][2]int{{0, 1}, {3, 2}, {5, 6}} {
+		if _, _, _, err := doc.PositionsForLines(lines[0], lines[1]); !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("Expected ErrInvalidRange for lines %d-%d, got %v", lines[0], lines[1], err)
+		}
+	}
+}
+
+func TestDocument_LineSpans(t *testing.T) {
+	doc, positions := newLinesDocument(t, "package main\n\n", "func a() {\n}\n", "var x = ", "1\n")
+
+	spans := doc.LineSpans()
+	if len(spans) != 4 {
+		t.Fatalf("Expected 4 spans, got %d", len(spans))
+	}
+
+	expected := [][2]int{{1, 2}, {3, 4}, {5, 5}, {5, 5}}
+	for i, span := range spans {
+		if span.Position.Compare(positions[i]) != 0 {
+			t.Errorf("Span %d: expected document order", i)
+		}
+		if span.FirstLine != expected[i][0] || span.LastLine != expected[i][1] {
+			t.Errorf("Span %d: expected lines %d-%d, got %d-%d", i, expected[i][0], expected[i][1], span.FirstLine, span.LastLine)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package positioning
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// DocumentSnapshot is a copy of a document's state, taken under its lock. It
+// is the form documents are serialized in, by the stores and as JSON.
+type DocumentSnapshot struct {
+	FilePath string `json:"file_path"`
+	// Constructs are in position order
+	Constructs    []*Construct             `json:"constructs"`
+	AppliedOps    []operations.OperationID `json:"applied_ops,omitempty"`
+	ContentHash   [32]byte                 `json:"content_hash"`
+	Version       uint64                   `json:"version"`
+	LastOperation operations.OperationID   `json:"last_operation"`
+}
+
+// Snapshot copies the document's state. The constructs are copies, so later
+// changes to the document don't show up in them.
+func (doc *Document) Snapshot() DocumentSnapshot {
+	doc.mutex.RLock()
+	defer doc.mutex.RUnlock()
+
+	snapshot := DocumentSnapshot{
+		FilePath:      doc.FilePath,
+		Constructs:    make([]*Construct, 0, len(doc.constructs)),
+		ContentHash:   doc.ContentHash,
+		Version:       doc.Version,
+		LastOperation: doc.LastOperation,
+	}
+	for _, pos := range doc.positions {
+		if construct, exists := doc.constructs[pos.Key()]; exists {
+			copied := *construct
+			snapshot.Constructs = append(snapshot.Constructs, &copied)
+		}
+	}
+	for opID := range doc.appliedOps {
+		snapshot.AppliedOps = append(snapshot.AppliedOps, opID)
+	}
+	sort.Slice(snapshot.AppliedOps, func(i, j int) bool {
+		return snapshot.AppliedOps[i] < snapshot.AppliedOps[j]
+	})
+	return snapshot
+}
+
+// RestoreDocument builds a document from a snapshot. The content hash,
+// version and last operation are taken as they are rather than recomputed,
+// so a store can be checked against its constructs.
+func RestoreDocument(snapshot DocumentSnapshot) (*Document, error) {
+	doc := NewDocument(snapshot.FilePath)
+	doc.ContentHash = snapshot.ContentHash
+	doc.Version = snapshot.Version
+	doc.LastOperation = snapshot.LastOperation
+
+	for _, construct := range snapshot.Constructs {
+		if !construct.Position.IsValid() {
+			return nil, ErrInvalidPosition
+		}
+		posKey := construct.Position.Key()
+		if _, exists := doc.constructs[posKey]; exists {
+			return nil, ErrPositionOccupied
+		}
+		doc.constructs[posKey] = construct
+		doc.positions = append(doc.positions, construct.Position)
+	}
+	sort.Slice(doc.positions, func(i, j int) bool {
+		return doc.positions[i].Compare(doc.positions[j]) < 0
+	})
+
+	for _, opID := range snapshot.AppliedOps {
+		doc.appliedOps[opID] = true
+	}
+	return doc, nil
+}
+
+func (doc *Document) MarshalJSON() ([]byte, error) {
+	return json.Marshal(doc.Snapshot())
+}
+
+func (doc *Document) UnmarshalJSON(data []byte) error {
+	var snapshot DocumentSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	restored, err := RestoreDocument(snapshot)
+	if err != nil {
+		return err
+	}
+
+	doc.mutex.Lock()
+	defer doc.mutex.Unlock()
+
+	doc.FilePath = restored.FilePath
+	doc.ContentHash = restored.ContentHash
+	doc.Version = restored.Version
+	doc.LastOperation = restored.LastOperation
+	doc.constructs = restored.constructs
+	doc.positions = restored.positions
+	doc.appliedOps = restored.appliedOps
+	doc.writes = restored.writes
+	return nil
+}
@@ -0,0 +1,65 @@
+package positioning
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestDocument_SnapshotRestore(t *testing.T) {
+	doc := NewDocument("test.go")
+	for i, content := range []string{"c", "a", "b"} {
+		op := &operations.Operation{
+			ID:   operations.NewOperationID([]byte(content)),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(int64(3 - i)), AuthorID: "author1"},
+			}),
+			Content: content,
+			Author:  "author1",
+		}
+		if err := doc.ApplyOperation(op); err != nil {
+			t.Fatalf("Failed to apply operation: %v", err)
+		}
+	}
+
+	var visited string
+	doc.ForEachConstruct(func(construct *Construct) bool {
+		visited += construct.Content
+		return len(visited) < 2
+	})
+	if visited != "ba" {
+		t.Errorf("Expected to visit b then a and stop, got %q", visited)
+	}
+
+	// The snapshot doesn't follow later changes
+	snapshot := doc.Snapshot()
+	if _, err := doc.DeleteConstruct(snapshot.Constructs[0].Position); err != nil {
+		t.Fatalf("Failed to delete construct: %v", err)
+	}
+	if len(snapshot.Constructs) != 3 || len(snapshot.AppliedOps) != 3 {
+		t.Fatalf("Expected 3 constructs and applied operations, got %+v", snapshot)
+	}
+
+	// Documents round trip through JSON by way of their snapshot
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Failed to marshal document: %v", err)
+	}
+	var decoded Document
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal document: %v", err)
+	}
+	content, _ := decoded.Render()
+	if content != "ac" || decoded.Version != doc.Version || decoded.ContentHash != doc.ContentHash {
+		t.Errorf("Expected ac at version %d, got %q at version %d", doc.Version, content, decoded.Version)
+	}
+
+	snapshot.Constructs = append(snapshot.Constructs, snapshot.Constructs[0])
+	if _, err := RestoreDocument(snapshot); !errors.Is(err, ErrPositionOccupied) {
+		t.Errorf("Expected ErrPositionOccupied, got %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package replication
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrSelfSync       = errors.New("cannot sync a node with itself")
+	ErrInvalidAddress = errors.New("peer address must be an absolute http or https url")
+)
+
+// RequestError is a failed request to a peer, with the message from its error envelope
+type RequestError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Message)
+}
@@ -0,0 +1,185 @@
+package replication
+
+import (
+	gocontext "context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/logging"
+)
+
+const (
+	DefaultGossipInterval = 30 * time.Second
+	// DefaultFanout is how many peers each gossip round syncs with
+	DefaultFanout = 3
+)
+
+// Gossip keeps a node converged with the others on its network by syncing
+// with a few random peers every interval. Peers come from a static list and,
+// when enabled, mDNS.
+type Gossip struct {
+	node     *Node
+	static   []string
+	mdns     *MDNS
+	apiKey   string
+	interval time.Duration
+	fanout   int
+	dial     func(address, apiKey string) (Peer, error)
+	logger   *logging.Logger
+}
+
+type GossipOption func(*Gossip)
+
+// WithStaticPeers gossips with the nodes at these addresses, e.g. http://team:8080
+func WithStaticPeers(addresses ...string) GossipOption {
+	return func(g *Gossip) {
+		g.static = append(g.static, addresses...)
+	}
+}
+
+// WithMDNS advertises the node and discovers peers over multicast DNS
+func WithMDNS(mdns *MDNS) GossipOption {
+	return func(g *Gossip) {
+		g.mdns = mdns
+	}
+}
+
+// WithPeerAPIKey is presented to every peer, which must grant it the replicate permission
+func WithPeerAPIKey(key string) GossipOption {
+	return func(g *Gossip) {
+		g.apiKey = key
+	}
+}
+
+func WithGossipInterval(interval time.Duration) GossipOption {
+	return func(g *Gossip) {
+		g.interval = interval
+	}
+}
+
+func WithFanout(fanout int) GossipOption {
+	return func(g *Gossip) {
+		g.fanout = fanout
+	}
+}
+
+func NewGossip(node *Node, opts ...GossipOption) *Gossip {
+	g := &Gossip{
+		node:     node,
+		interval: DefaultGossipInterval,
+		fanout:   DefaultFanout,
+		dial: func(address, apiKey string) (Peer, error) {
+			return NewHTTPPeer(address, apiKey)
+		},
+		logger: logging.NewLogger("replication"),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Run answers mDNS queries, if enabled, and gossips until ctx is done. Rounds
+// are spread by up to a fifth of the interval so nodes started together
+// don't sync in lockstep.
+func (g *Gossip) Run(ctx gocontext.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	if g.mdns != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := g.mdns.Serve(ctx); err != nil {
+				g.logger.Warn("mDNS responder stopped", map[string]interface{}{"error": err.Error()})
+			}
+		}()
+	}
+
+	for {
+		g.Round(ctx)
+
+		wait := g.interval + time.Duration(rand.Int64N(int64(g.interval)/5+1))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Round syncs with up to fanout peers picked at random and returns the
+// reports of the syncs that succeeded. Failures are logged and recorded
+// against the peer.
+func (g *Gossip) Round(ctx gocontext.Context) []*Report {
+	addresses := g.peers(ctx)
+	rand.Shuffle(len(addresses), func(i, j int) {
+		addresses[i], addresses[j] = addresses[j], addresses[i]
+	})
+	if len(addresses) > g.fanout {
+		addresses = addresses[:g.fanout]
+	}
+
+	var reports []*Report
+	for _, address := range addresses {
+		if ctx.Err() != nil {
+			break
+		}
+
+		peer, err := g.dial(address, g.apiKey)
+		if err != nil {
+			g.logger.Warn("Skipping peer", map[string]interface{}{"peer": address, "error": err.Error()})
+			continue
+		}
+
+		report, err := g.node.Sync(ctx, peer, address)
+		if errors.Is(err, ErrSelfSync) {
+			continue
+		}
+		if err != nil {
+			g.logger.Warn("Gossip sync failed", map[string]interface{}{"peer": address, "error": err.Error()})
+			continue
+		}
+
+		if report.Received > 0 || report.Sent > 0 {
+			g.logger.Info("Gossip sync", map[string]interface{}{
+				"peer":     report.NodeID,
+				"received": report.Received,
+				"sent":     report.Sent,
+			})
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// peers returns the addresses of static and discovered peers, without duplicates
+func (g *Gossip) peers(ctx gocontext.Context) []string {
+	seen := make(map[string]bool)
+	var addresses []string
+	add := func(address string) {
+		if !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+
+	for _, address := range g.static {
+		add(address)
+	}
+
+	if g.mdns != nil {
+		found, err := g.mdns.Discover(ctx)
+		if err != nil {
+			g.logger.Warn("mDNS discovery failed", map[string]interface{}{"error": err.Error()})
+		}
+		for _, address := range found {
+			add(address)
+		}
+	}
+	return addresses
+}
@@ -0,0 +1,102 @@
+package replication
+
+import (
+	gocontext "context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGossip_Round(t *testing.T) {
+	ctx := gocontext.Background()
+	nodes := map[string]*testNode{
+		"http://a": newTestNode(t),
+		"http://b": newTestNode(t),
+		"http://c": newTestNode(t),
+	}
+	for address, node := range nodes {
+		node.insert(t, "// from "+address+"\n", time.Now())
+	}
+	self := newTestNode(t)
+	nodes["http://self"] = self
+
+	gossip := NewGossip(self.Node, WithStaticPeers("http://a", "http://b", "http://c", "http://self", "http://a"), WithFanout(2))
+	gossip.dial = func(address, apiKey string) (Peer, error) {
+		node, ok := nodes[address]
+		if !ok {
+			return nil, fmt.Errorf("no node at %s", address)
+		}
+		return node.Node, nil
+	}
+
+	// Two of the three other nodes per round, duplicates and itself aside
+	reports := gossip.Round(ctx)
+	if len(reports) < 1 || len(reports) > 2 {
+		t.Fatalf("Expected at most two syncs, got %d", len(reports))
+	}
+
+	for i := 0; i < 20; i++ {
+		gossip.Round(ctx)
+	}
+	heads, _ := self.Heads(ctx)
+	if len(heads) != 3 {
+		t.Errorf("Expected gossip to converge on all 3 operations, got %v", heads)
+	}
+	for _, peer := range self.Peers() {
+		if peer.NodeID == self.ID() || peer.LastError != "" {
+			t.Errorf("Unexpected peer state %+v", peer)
+		}
+	}
+}
+
+func TestMDNS_AnswersServiceQueries(t *testing.T) {
+	mdns := NewMDNS(Advertisement{NodeID: "node_laptop", Port: 8080})
+
+	answer := mdns.answer(buildQuery())
+	if answer == nil {
+		t.Fatal("Expected an answer to a service query")
+	}
+	ads := parseAnswer(answer)
+	if len(ads) != 1 || ads[0].NodeID != "node_laptop" || ads[0].Port != 8080 {
+		t.Fatalf("Expected the node's advertisement, got %+v", ads)
+	}
+	if address := ads[0].address(net.IPv4(192, 168, 1, 20)); address != "http://192.168.1.20:8080" {
+		t.Errorf("Expected the answer's source address, got %s", address)
+	}
+
+	withURL := NewMDNS(Advertisement{NodeID: "node_server", Port: 443, TLS: true, URL: "https://team.example.com"})
+	ads = parseAnswer(withURL.answer(buildQuery()))
+	if len(ads) != 1 || ads[0].address(net.IPv4(10, 0, 0, 1)) != "https://team.example.com" {
+		t.Errorf("Expected the advertised url, got %+v", ads)
+	}
+
+	// Queries for other services and answers are ignored
+	other := make([]byte, 12)
+	binary.BigEndian.PutUint16(other[4:], 1)
+	other = append(other, encodeName("_http._tcp.local.")...)
+	other = append(other, 0, dnsTypePTR, 0, dnsClassIN)
+	if mdns.answer(other) != nil || mdns.answer(answer) != nil {
+		t.Error("Expected no answer")
+	}
+}
+
+func TestReadName_FollowsPointers(t *testing.T) {
+	packet := make([]byte, 12)
+	packet = append(packet, encodeName(mdnsService)...)
+	// node_a then a pointer back to the service name
+	pointer := len(packet)
+	packet = append(packet, 6, 'n', 'o', 'd', 'e', '_', 'a', 0xC0, 12)
+
+	name, next, err := readName(packet, pointer)
+	if err != nil || name != "node_a."+mdnsService || next != len(packet) {
+		t.Errorf("Expected node_a.%s ending at %d, got %q at %d, %v", mdnsService, len(packet), name, next, err)
+	}
+
+	// A pointer to itself must not loop forever
+	loop := append(make([]byte, 12), 0xC0, 12)
+	if _, _, err := readName(loop, 12); err == nil {
+		t.Error("Expected a pointer loop to fail")
+	}
+}
@@ -0,0 +1,108 @@
+package replication
+
+import (
+	gocontext "context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// history is the shape of the operation log without its content: enough to
+// find heads and walk ancestry
+type history struct {
+	parents  map[operations.OperationID][]operations.OperationID
+	clocks   map[operations.OperationID]operations.HLC
+	hasChild map[operations.OperationID]bool
+}
+
+func loadHistory(ctx gocontext.Context, store storage.OperationStore) (*history, error) {
+	h := &history{
+		parents:  make(map[operations.OperationID][]operations.OperationID),
+		clocks:   make(map[operations.OperationID]operations.HLC),
+		hasChild: make(map[operations.OperationID]bool),
+	}
+
+	err := store.ForEachOperationSince(ctx, time.Time{}, func(op *operations.Operation) error {
+		h.parents[op.ID] = op.Parents
+		h.clocks[op.ID] = op.Order()
+		for _, parent := range op.Parents {
+			h.hasChild[parent] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read operation history: %w", err)
+	}
+	return h, nil
+}
+
+// heads are the operations nothing else builds on. Operations without
+// parents are all heads, so a log written without them is exchanged as a
+// full list of IDs.
+func (h *history) heads() []operations.OperationID {
+	heads := make([]operations.OperationID, 0)
+	for id := range h.parents {
+		if !h.hasChild[id] {
+			heads = append(heads, id)
+		}
+	}
+	sort.Slice(heads, func(i, j int) bool { return heads[i] < heads[j] })
+	return heads
+}
+
+// missing returns the operations not reachable from heads, parents before
+// children and otherwise oldest first. Heads this history doesn't have are
+// ignored.
+func (h *history) missing(heads []operations.OperationID) []operations.OperationID {
+	known := make(map[operations.OperationID]bool)
+	stack := append([]operations.OperationID(nil), heads...)
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		parents, exists := h.parents[id]
+		if !exists || known[id] {
+			continue
+		}
+		known[id] = true
+		stack = append(stack, parents...)
+	}
+
+	var candidates []operations.OperationID
+	for id := range h.parents {
+		if !known[id] {
+			candidates = append(candidates, id)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := h.clocks[candidates[i]], h.clocks[candidates[j]]
+		if ci != cj {
+			return ci < cj
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	// Emitting each candidate's missing parents before it keeps causal order
+	// even when clocks disagree with it
+	ordered := make([]operations.OperationID, 0, len(candidates))
+	var visit func(id operations.OperationID)
+	visit = func(id operations.OperationID) {
+		if known[id] {
+			return
+		}
+		known[id] = true
+		for _, parent := range h.parents[id] {
+			if _, exists := h.parents[parent]; exists {
+				visit(parent)
+			}
+		}
+		ordered = append(ordered, id)
+	}
+	for _, id := range candidates {
+		visit(id)
+	}
+	return ordered
+}
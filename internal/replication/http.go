@@ -0,0 +1,105 @@
+package replication
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPPeer reaches a node through its server's /api/v2/replication endpoints
+type HTTPPeer struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+// NewHTTPPeer returns a peer served at address, e.g. http://team-server:8080.
+// apiKey needs the replicate permission when the peer requires auth.
+func NewHTTPPeer(address, apiKey string) (*HTTPPeer, error) {
+	parsed, err := url.Parse(address)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAddress, address)
+	}
+
+	return &HTTPPeer{
+		baseURL: strings.TrimSuffix(address, "/") + "/api/v2/replication",
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (p *HTTPPeer) Pull(ctx gocontext.Context, query PullQuery) (*Batch, error) {
+	var batch Batch
+	if err := p.post(ctx, "/pull", query, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (p *HTTPPeer) Push(ctx gocontext.Context, batch Batch) (*PushResult, error) {
+	var result PushResult
+	if err := p.post(ctx, "/push", batch, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// envelope is the part of the API's response envelope replication reads
+type envelope struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *HTTPPeer) post(ctx gocontext.Context, path string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var env envelope
+	decodeErr := json.Unmarshal(raw, &env)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || !env.Success {
+		message := strings.TrimSpace(string(raw))
+		if decodeErr == nil && env.Error != nil {
+			message = env.Error.Message
+		}
+		return &RequestError{Method: http.MethodPost, URL: req.URL.String(), StatusCode: resp.StatusCode, Message: message}
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("failed to decode %s: %w", req.URL, decodeErr)
+	}
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", req.URL, err)
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+package replication
+
+import (
+	gocontext "context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Nodes advertise themselves over multicast DNS as instances of the
+// _contextdb._tcp service. The TXT record names the node and how to reach
+// its API; peers that don't advertise a URL are reached at the address the
+// answer came from.
+
+const (
+	mdnsService = "_contextdb._tcp.local."
+	mdnsTTL     = 120
+
+	dnsTypePTR = 12
+	dnsTypeTXT = 16
+	dnsTypeSRV = 33
+	dnsTypeANY = 255
+	dnsClassIN = 1
+	// dnsCacheFlush marks records only this responder answers for
+	dnsCacheFlush = 0x8000
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Advertisement is how a node describes itself over mDNS
+type Advertisement struct {
+	NodeID string
+	// Port the API listens on
+	Port int
+	// TLS is set when the API is served over https
+	TLS bool
+	// URL overrides the address built from the responder's IP and Port
+	URL string
+}
+
+// MDNS answers queries for this node and browses for others on the local network
+type MDNS struct {
+	ad      Advertisement
+	host    string
+	timeout time.Duration
+}
+
+func NewMDNS(ad Advertisement) *MDNS {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = ad.NodeID
+	}
+	host, _, _ = strings.Cut(host, ".")
+
+	return &MDNS{ad: ad, host: host + ".local.", timeout: 2 * time.Second}
+}
+
+// Serve answers queries for the service until ctx is done
+func (m *MDNS) Serve(ctx gocontext.Context) error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return fmt.Errorf("failed to join mdns group: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read mdns query: %w", err)
+		}
+
+		answer := m.answer(buf[:n])
+		if answer == nil {
+			continue
+		}
+		// Queries from a port other than 5353 come from simple resolvers
+		// that only listen for a direct reply
+		to := mdnsGroup
+		if from.Port != mdnsGroup.Port {
+			to = from
+		}
+		conn.WriteToUDP(answer, to)
+	}
+}
+
+// Discover browses for other nodes for the MDNS timeout and returns their
+// API addresses keyed by node ID
+func (m *MDNS) Discover(ctx gocontext.Context) (map[string]string, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mdns socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteToUDP(buildQuery(), mdnsGroup); err != nil {
+		return nil, fmt.Errorf("failed to send mdns query: %w", err)
+	}
+
+	deadline := time.Now().Add(m.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetReadDeadline(deadline)
+
+	found := make(map[string]string)
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return found, nil
+			}
+			return found, fmt.Errorf("failed to read mdns answer: %w", err)
+		}
+
+		for _, ad := range parseAnswer(buf[:n]) {
+			if ad.NodeID == "" || ad.NodeID == m.ad.NodeID {
+				continue
+			}
+			found[ad.NodeID] = ad.address(from.IP)
+		}
+	}
+}
+
+func (ad Advertisement) address(ip net.IP) string {
+	if ad.URL != "" {
+		return ad.URL
+	}
+	scheme := "http"
+	if ad.TLS {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(ip.String(), strconv.Itoa(ad.Port))
+}
+
+func (ad Advertisement) txt() []string {
+	txt := []string{"node=" + ad.NodeID, "port=" + strconv.Itoa(ad.Port)}
+	if ad.TLS {
+		txt = append(txt, "tls=1")
+	}
+	if ad.URL != "" {
+		txt = append(txt, "url="+ad.URL)
+	}
+	return txt
+}
+
+func parseTXT(txt []string) Advertisement {
+	var ad Advertisement
+	for _, entry := range txt {
+		key, value, _ := strings.Cut(entry, "=")
+		switch key {
+		case "node":
+			ad.NodeID = value
+		case "port":
+			ad.Port, _ = strconv.Atoi(value)
+		case "tls":
+			ad.TLS = value == "1"
+		case "url":
+			ad.URL = value
+		}
+	}
+	return ad
+}
+
+// answer returns the response to query, or nil when it doesn't ask for the service
+func (m *MDNS) answer(query []byte) []byte {
+	msg, err := parseDNS(query)
+	if err != nil || msg.response {
+		return nil
+	}
+
+	asked := false
+	for _, q := range msg.questions {
+		if strings.EqualFold(q.name, mdnsService) && (q.qtype == dnsTypePTR || q.qtype == dnsTypeANY) {
+			asked = true
+		}
+	}
+	if !asked {
+		return nil
+	}
+
+	instance := m.ad.NodeID + "." + mdnsService
+	srv := make([]byte, 6)
+	binary.BigEndian.PutUint16(srv[4:], uint16(m.ad.Port))
+	srv = append(srv, encodeName(m.host)...)
+
+	var txt []byte
+	for _, entry := range m.ad.txt() {
+		txt = append(txt, byte(len(entry)))
+		txt = append(txt, entry...)
+	}
+
+	out := make([]byte, 12)
+	binary.BigEndian.PutUint16(out[0:], msg.id)
+	binary.BigEndian.PutUint16(out[2:], 0x8400) // authoritative response
+	binary.BigEndian.PutUint16(out[6:], 3)
+	out = appendRecord(out, mdnsService, dnsTypePTR, dnsClassIN, encodeName(instance))
+	out = appendRecord(out, instance, dnsTypeSRV, dnsClassIN|dnsCacheFlush, srv)
+	out = appendRecord(out, instance, dnsTypeTXT, dnsClassIN|dnsCacheFlush, txt)
+	return out
+}
+
+func buildQuery() []byte {
+	out := make([]byte, 12)
+	binary.BigEndian.PutUint16(out[4:], 1)
+	out = append(out, encodeName(mdnsService)...)
+	out = binary.BigEndian.AppendUint16(out, dnsTypePTR)
+	return binary.BigEndian.AppendUint16(out, dnsClassIN)
+}
+
+// parseAnswer returns the advertisements in the TXT records of a response
+func parseAnswer(packet []byte) []Advertisement {
+	msg, err := parseDNS(packet)
+	if err != nil || !msg.response {
+		return nil
+	}
+
+	var ads []Advertisement
+	for _, rr := range msg.records {
+		if rr.rtype != dnsTypeTXT || !strings.HasSuffix(strings.ToLower(rr.name), mdnsService) {
+			continue
+		}
+		var txt []string
+		for data := rr.data; len(data) > 0 && int(data[0]) < len(data); data = data[1+int(data[0]):] {
+			txt = append(txt, string(data[1:1+int(data[0])]))
+		}
+		ads = append(ads, parseTXT(txt))
+	}
+	return ads
+}
+
+func appendRecord(out []byte, name string, rtype, class uint16, data []byte) []byte {
+	out = append(out, encodeName(name)...)
+	out = binary.BigEndian.AppendUint16(out, rtype)
+	out = binary.BigEndian.AppendUint16(out, class)
+	out = binary.BigEndian.AppendUint32(out, mdnsTTL)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(data)))
+	return append(out, data...)
+}
+
+func encodeName(name string) []byte {
+	var out []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		out = append(out, byte(len(label)))
+		out = append(out, label...)
+	}
+	return append(out, 0)
+}
+
+var errMalformedDNS = errors.New("malformed dns message")
+
+type dnsQuestion struct {
+	name  string
+	qtype uint16
+}
+
+type dnsRecord struct {
+	name  string
+	rtype uint16
+	data  []byte
+}
+
+type dnsMessage struct {
+	id        uint16
+	response  bool
+	questions []dnsQuestion
+	// records holds answers, authorities and additionals alike
+	records []dnsRecord
+}
+
+func parseDNS(packet []byte) (*dnsMessage, error) {
+	if len(packet) < 12 {
+		return nil, errMalformedDNS
+	}
+	msg := &dnsMessage{
+		id:       binary.BigEndian.Uint16(packet[0:]),
+		response: packet[2]&0x80 != 0,
+	}
+	questions := int(binary.BigEndian.Uint16(packet[4:]))
+	records := int(binary.BigEndian.Uint16(packet[6:])) + int(binary.BigEndian.Uint16(packet[8:])) + int(binary.BigEndian.Uint16(packet[10:]))
+
+	off := 12
+	for i := 0; i < questions; i++ {
+		name, next, err := readName(packet, off)
+		if err != nil || next+4 > len(packet) {
+			return nil, errMalformedDNS
+		}
+		msg.questions = append(msg.questions, dnsQuestion{name: name, qtype: binary.BigEndian.Uint16(packet[next:])})
+		off = next + 4
+	}
+
+	for i := 0; i < records; i++ {
+		name, next, err := readName(packet, off)
+		if err != nil || next+10 > len(packet) {
+			return nil, errMalformedDNS
+		}
+		length := int(binary.BigEndian.Uint16(packet[next+8:]))
+		start := next + 10
+		if start+length > len(packet) {
+			return nil, errMalformedDNS
+		}
+		msg.records = append(msg.records, dnsRecord{
+			name:  name,
+			rtype: binary.BigEndian.Uint16(packet[next:]),
+			data:  packet[start : start+length],
+		})
+		off = start + length
+	}
+	return msg, nil
+}
+
+// readName decodes the name at off, following compression pointers, and
+// returns it with the offset just past it
+func readName(packet []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; jumps < 32; {
+		if off >= len(packet) {
+			return "", 0, errMalformedDNS
+		}
+		length := int(packet[off])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+		case length&0xC0 == 0xC0:
+			if off+1 >= len(packet) {
+				return "", 0, errMalformedDNS
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(packet[off:]) & 0x3FFF)
+			jumps++
+		default:
+			if off+1+length > len(packet) {
+				return "", 0, errMalformedDNS
+			}
+			labels = append(labels, string(packet[off+1:off+1+length]))
+			off += 1 + length
+		}
+	}
+	return "", 0, errMalformedDNS
+}
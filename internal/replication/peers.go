@@ -0,0 +1,126 @@
+package replication
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/ids"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// PeerState is what a node remembers about another. Received and Sent count
+// operations that were new to the receiving side, over every sync.
+type PeerState struct {
+	NodeID string `json:"node_id"`
+	// Address is where this node reached the peer, empty when the peer only
+	// ever connected to this node
+	Address   string                   `json:"address,omitempty"`
+	LastSync  time.Time                `json:"last_sync"`
+	Heads     []operations.OperationID `json:"heads,omitempty"`
+	Received  int                      `json:"received"`
+	Sent      int                      `json:"sent"`
+	LastError string                   `json:"last_error,omitempty"`
+}
+
+type stateFile struct {
+	NodeID string       `json:"node_id"`
+	Peers  []*PeerState `json:"peers"`
+}
+
+// stateStore persists the node ID and peer state in .context/replication.json
+type stateStore struct {
+	path  string
+	state stateFile
+	mutex sync.Mutex
+}
+
+func loadState(basePath string) (*stateStore, error) {
+	store := &stateStore{path: filepath.Join(basePath, ".context", "replication.json")}
+
+	data, err := os.ReadFile(store.path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to load replication state: %w", err)
+	default:
+		if err := json.Unmarshal(data, &store.state); err != nil {
+			return nil, fmt.Errorf("failed to load replication state: %w", err)
+		}
+	}
+
+	if store.state.NodeID == "" {
+		store.state.NodeID = ids.NewWithPrefix("node")
+		if err := store.save(); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+func (s *stateStore) nodeID() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.state.NodeID
+}
+
+func (s *stateStore) peers() []PeerState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	peers := make([]PeerState, len(s.state.Peers))
+	for i, peer := range s.state.Peers {
+		peers[i] = *peer
+	}
+	return peers
+}
+
+// record updates the peer with nodeID, or the one at address when the peer
+// never answered with its ID, and stamps it as synced now
+func (s *stateStore) record(nodeID, address string, update func(peer *PeerState)) {
+	if nodeID == "" && address == "" {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var peer *PeerState
+	for _, candidate := range s.state.Peers {
+		if (nodeID != "" && candidate.NodeID == nodeID) || (nodeID == "" && candidate.Address == address) {
+			peer = candidate
+			break
+		}
+	}
+	if peer == nil {
+		peer = &PeerState{NodeID: nodeID}
+		s.state.Peers = append(s.state.Peers, peer)
+	}
+
+	if address != "" {
+		peer.Address = address
+	}
+	peer.LastSync = time.Now()
+	update(peer)
+}
+
+func (s *stateStore) save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode replication state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create replication state directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save replication state: %w", err)
+	}
+	return nil
+}
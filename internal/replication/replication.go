@@ -0,0 +1,313 @@
+// Package replication exchanges the operation log between ContextDB nodes,
+// such as a laptop and a team server. A sync pulls the operations the peer
+// has and this node lacks, then pushes the reverse. Each side works out what
+// the other is missing from the heads of its history: everything reachable
+// from a head through parents is already there.
+package replication
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const (
+	// DefaultBatchSize is how many operations a pull or push carries when the
+	// request doesn't say
+	DefaultBatchSize = 500
+	// MaxBatchSize caps the operations in one pull response
+	MaxBatchSize = 5000
+)
+
+// PullQuery asks a peer for the operations missing from the history ending at Heads
+type PullQuery struct {
+	NodeID string                   `json:"node_id"`
+	Heads  []operations.OperationID `json:"heads"`
+	Limit  int                      `json:"limit,omitempty"`
+}
+
+// Batch carries operations between nodes in causal order, parents first.
+// Heads are the sender's heads; More is set when the sender has further
+// operations to give.
+type Batch struct {
+	NodeID     string                   `json:"node_id"`
+	Heads      []operations.OperationID `json:"heads"`
+	Operations []*operations.Operation  `json:"operations"`
+	More       bool                     `json:"more,omitempty"`
+}
+
+// PushResult reports how many pushed operations were new to the receiver and
+// its heads once they were applied
+type PushResult struct {
+	NodeID  string                   `json:"node_id"`
+	Applied int                      `json:"applied"`
+	Heads   []operations.OperationID `json:"heads"`
+}
+
+// Status describes a node and every peer it has synced with
+type Status struct {
+	NodeID string                   `json:"node_id"`
+	Heads  []operations.OperationID `json:"heads"`
+	Peers  []PeerState              `json:"peers"`
+}
+
+// Report summarizes one Sync
+type Report struct {
+	NodeID   string `json:"node_id"`
+	Received int    `json:"received"`
+	Sent     int    `json:"sent"`
+}
+
+// Peer is the far side of a sync. Node is a Peer, and NewHTTPPeer reaches
+// one served by another process.
+type Peer interface {
+	Pull(ctx gocontext.Context, query PullQuery) (*Batch, error)
+	Push(ctx gocontext.Context, batch Batch) (*PushResult, error)
+}
+
+// Node is this store's side of replication
+type Node struct {
+	engine *collaboration.CollaborationEngine
+	store  storage.Store
+	state  *stateStore
+}
+
+// NewNode replicates the operations in store, applying those it receives
+// through engine so documents, addresses and subscribers see them. The node
+// ID and peer sync state are kept in basePath/.context/replication.json.
+func NewNode(basePath string, engine *collaboration.CollaborationEngine, store storage.Store) (*Node, error) {
+	state, err := loadState(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Node{engine: engine, store: store, state: state}, nil
+}
+
+func (n *Node) ID() string {
+	return n.state.nodeID()
+}
+
+// Heads returns the operations no other operation names as a parent, sorted
+func (n *Node) Heads(ctx gocontext.Context) ([]operations.OperationID, error) {
+	history, err := loadHistory(ctx, n.store)
+	if err != nil {
+		return nil, err
+	}
+	return history.heads(), nil
+}
+
+func (n *Node) Status(ctx gocontext.Context) (*Status, error) {
+	heads, err := n.Heads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Status{NodeID: n.ID(), Heads: heads, Peers: n.state.peers()}, nil
+}
+
+// Peers returns the sync state of every node this one has exchanged operations with
+func (n *Node) Peers() []PeerState {
+	return n.state.peers()
+}
+
+// Pull answers a peer's PullQuery with up to query.Limit operations it is missing
+func (n *Node) Pull(ctx gocontext.Context, query PullQuery) (*Batch, error) {
+	if query.NodeID == n.ID() {
+		return nil, ErrSelfSync
+	}
+
+	history, err := loadHistory(ctx, n.store)
+	if err != nil {
+		return nil, err
+	}
+
+	ops, more, err := n.missing(ctx, history, query.Heads, batchSize(query.Limit))
+	if err != nil {
+		return nil, err
+	}
+
+	if query.NodeID != "" {
+		n.state.record(query.NodeID, "", func(peer *PeerState) {
+			peer.Heads = query.Heads
+			peer.Sent += len(ops)
+		})
+	}
+
+	return &Batch{NodeID: n.ID(), Heads: history.heads(), Operations: ops, More: more}, n.state.save()
+}
+
+// Push applies a peer's operations that this node doesn't have yet
+func (n *Node) Push(ctx gocontext.Context, batch Batch) (*PushResult, error) {
+	if batch.NodeID == n.ID() {
+		return nil, ErrSelfSync
+	}
+
+	applied, err := n.apply(ctx, batch.NodeID, batch.Operations)
+	if batch.NodeID != "" {
+		n.state.record(batch.NodeID, "", func(peer *PeerState) {
+			peer.Heads = batch.Heads
+			peer.Received += applied
+		})
+	}
+	if err != nil {
+		return nil, errors.Join(err, n.state.save())
+	}
+
+	heads, err := n.Heads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &PushResult{NodeID: n.ID(), Applied: applied, Heads: heads}, n.state.save()
+}
+
+// Sync pulls everything peer has that this node lacks, then pushes
+// everything the peer lacks, and records the outcome against the peer.
+// address is only kept for display, e.g. the peer's URL.
+func (n *Node) Sync(ctx gocontext.Context, peer Peer, address string) (*Report, error) {
+	report := &Report{}
+	syncErr := n.sync(ctx, peer, report)
+	if errors.Is(syncErr, ErrSelfSync) {
+		return report, syncErr
+	}
+
+	n.state.record(report.NodeID, address, func(state *PeerState) {
+		state.Received += report.Received
+		state.Sent += report.Sent
+		state.LastError = ""
+		if syncErr != nil {
+			state.LastError = syncErr.Error()
+		}
+	})
+
+	if err := n.state.save(); syncErr == nil {
+		syncErr = err
+	}
+	return report, syncErr
+}
+
+func (n *Node) sync(ctx gocontext.Context, peer Peer, report *Report) error {
+	var peerHeads []operations.OperationID
+	for {
+		heads, err := n.Heads(ctx)
+		if err != nil {
+			return err
+		}
+
+		batch, err := peer.Pull(ctx, PullQuery{NodeID: n.ID(), Heads: heads, Limit: DefaultBatchSize})
+		if err != nil {
+			return fmt.Errorf("failed to pull: %w", err)
+		}
+		if batch.NodeID == n.ID() {
+			return ErrSelfSync
+		}
+		report.NodeID = batch.NodeID
+		peerHeads = batch.Heads
+
+		applied, err := n.apply(ctx, batch.NodeID, batch.Operations)
+		report.Received += applied
+		if err != nil {
+			return err
+		}
+		if !batch.More || len(batch.Operations) == 0 {
+			break
+		}
+	}
+
+	for {
+		history, err := loadHistory(ctx, n.store)
+		if err != nil {
+			return err
+		}
+		ops, more, err := n.missing(ctx, history, peerHeads, DefaultBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(ops) == 0 {
+			return nil
+		}
+
+		result, err := peer.Push(ctx, Batch{NodeID: n.ID(), Heads: history.heads(), Operations: ops})
+		if err != nil {
+			return fmt.Errorf("failed to push: %w", err)
+		}
+		report.Sent += result.Applied
+		peerHeads = result.Heads
+
+		if !more {
+			return nil
+		}
+	}
+}
+
+// missing returns up to limit operations that aren't reachable from heads,
+// parents first, and whether there were more
+func (n *Node) missing(ctx gocontext.Context, history *history, heads []operations.OperationID, limit int) ([]*operations.Operation, bool, error) {
+	ids := history.missing(heads)
+	more := len(ids) > limit
+	if more {
+		ids = ids[:limit]
+	}
+	if len(ids) == 0 {
+		return nil, false, nil
+	}
+
+	loaded, err := n.store.GetOperations(ctx, ids)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load operations: %w", err)
+	}
+	byID := make(map[operations.OperationID]*operations.Operation, len(loaded))
+	for _, op := range loaded {
+		byID[op.ID] = op
+	}
+
+	// The store returns them by timestamp, put them back in causal order
+	ops := make([]*operations.Operation, 0, len(ids))
+	for _, id := range ids {
+		if op, ok := byID[id]; ok {
+			ops = append(ops, op)
+		}
+	}
+	return ops, more, nil
+}
+
+// apply processes the operations this node doesn't have through the engine,
+// in the order given, and returns how many it applied
+func (n *Node) apply(ctx gocontext.Context, from string, ops []*operations.Operation) (int, error) {
+	client := collaboration.ClientID("replication:" + from)
+
+	applied := 0
+	for _, op := range ops {
+		if op == nil {
+			continue
+		}
+
+		_, err := n.store.GetOperation(ctx, op.ID)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, storage.ErrOperationNotFound) {
+			return applied, err
+		}
+
+		if err := n.engine.ProcessOperation(ctx, op, client); err != nil {
+			return applied, fmt.Errorf("failed to apply operation %s: %w", op.ID, err)
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+func batchSize(limit int) int {
+	switch {
+	case limit <= 0:
+		return DefaultBatchSize
+	case limit > MaxBatchSize:
+		return MaxBatchSize
+	default:
+		return limit
+	}
+}
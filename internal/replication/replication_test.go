@@ -0,0 +1,232 @@
+package replication
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+type testNode struct {
+	*Node
+	engine *collaboration.CollaborationEngine
+	store  storage.Store
+}
+
+func newTestNode(t *testing.T) *testNode {
+	t.Helper()
+
+	dir := t.TempDir()
+	store, err := storage.NewSQLiteStore(filepath.Join(dir, "contextdb.sqlite"))
+	if err != nil {
+		t.Fatalf("Failed to create test storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	engine := collaboration.NewCollaborationEngine(store)
+	node, err := NewNode(dir, engine, store)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	return &testNode{Node: node, engine: engine, store: store}
+}
+
+func (n *testNode) insert(t *testing.T, content string, at time.Time, parents ...operations.OperationID) *operations.Operation {
+	t.Helper()
+
+	op := &operations.Operation{
+		ID:   operations.NewOperationID([]byte(content)),
+		Type: operations.OpInsert,
+		Position: operations.NewLogootPosition([]operations.PositionSegment{
+			{Value: big.NewInt(at.UnixNano()), AuthorID: "alice"},
+		}),
+		Content:   content,
+		Author:    "alice",
+		Timestamp: at,
+		Parents:   parents,
+		Metadata:  operations.OperationMeta{Context: map[string]string{"document_id": "main.go"}},
+	}
+	if err := n.engine.ProcessOperation(gocontext.Background(), op, ""); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+	return op
+}
+
+func TestNode_Sync(t *testing.T) {
+	ctx := gocontext.Background()
+	laptop, server := newTestNode(t), newTestNode(t)
+	start := time.Now().Add(-time.Hour)
+
+	first := laptop.insert(t, "package main\n", start)
+	second := laptop.insert(t, "func main() {}\n", start.Add(time.Minute), first.ID)
+	remote := server.insert(t, "// shared\n", start.Add(2*time.Minute))
+
+	report, err := laptop.Sync(ctx, server, "http://server:8080")
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if report.NodeID != server.ID() || report.Received != 1 || report.Sent != 2 {
+		t.Errorf("Expected 1 received and 2 sent from %s, got %+v", server.ID(), report)
+	}
+
+	for _, node := range []*testNode{laptop, server} {
+		for _, op := range []*operations.Operation{first, second, remote} {
+			if _, err := node.store.GetOperation(ctx, op.ID); err != nil {
+				t.Errorf("Expected %s on node %s: %v", op.Content, node.ID(), err)
+			}
+		}
+
+		doc, err := node.engine.GetDocumentState(ctx, "main.go")
+		if err != nil {
+			t.Fatalf("Failed to get document: %v", err)
+		}
+		if doc.ConstructCount() != 3 {
+			t.Errorf("Expected the document on %s to have all 3 inserts, got %d", node.ID(), doc.ConstructCount())
+		}
+	}
+
+	heads, _ := laptop.Heads(ctx)
+	serverHeads, _ := server.Heads(ctx)
+	if len(heads) != 2 || len(serverHeads) != 2 {
+		t.Errorf("Expected both nodes to share two heads, got %v and %v", heads, serverHeads)
+	}
+
+	// Nothing is exchanged once the nodes have converged
+	report, err = laptop.Sync(ctx, server, "http://server:8080")
+	if err != nil || report.Received != 0 || report.Sent != 0 {
+		t.Errorf("Expected an empty second sync, got %+v, %v", report, err)
+	}
+
+	peers := laptop.Peers()
+	if len(peers) != 1 || peers[0].NodeID != server.ID() || peers[0].Address != "http://server:8080" ||
+		peers[0].Received != 1 || peers[0].Sent != 2 {
+		t.Errorf("Expected the server's sync state on the laptop, got %+v", peers)
+	}
+	if peers := server.Peers(); len(peers) != 1 || peers[0].NodeID != laptop.ID() || peers[0].Received != 2 {
+		t.Errorf("Expected the laptop's sync state on the server, got %+v", peers)
+	}
+
+	if _, err := laptop.Sync(ctx, laptop.Node, ""); !errors.Is(err, ErrSelfSync) {
+		t.Errorf("Expected ErrSelfSync, got %v", err)
+	}
+}
+
+func TestNode_KeepsIDAndPeers(t *testing.T) {
+	dir := t.TempDir()
+	node, err := NewNode(dir, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	node.state.record("node_peer", "http://peer", func(peer *PeerState) { peer.Received = 3 })
+	if err := node.state.save(); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	reopened, err := NewNode(dir, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to reopen node: %v", err)
+	}
+	if reopened.ID() != node.ID() {
+		t.Errorf("Expected node ID %s to persist, got %s", node.ID(), reopened.ID())
+	}
+	if peers := reopened.Peers(); len(peers) != 1 || peers[0].Received != 3 {
+		t.Errorf("Expected the peer to persist, got %+v", peers)
+	}
+}
+
+func TestHistory_MissingInCausalOrder(t *testing.T) {
+	now := time.Now()
+	h := &history{
+		parents: map[operations.OperationID][]operations.OperationID{
+			"root":  nil,
+			"child": {"root"},
+			// Its clock ran behind, it still has to follow its parent
+			"late":  {"child"},
+			"other": nil,
+		},
+		clocks: map[operations.OperationID]operations.HLC{
+			"root":  operations.HLCFromTime(now),
+			"child": operations.HLCFromTime(now.Add(time.Second)),
+			"late":  operations.HLCFromTime(now.Add(-time.Hour)),
+			"other": operations.HLCFromTime(now.Add(time.Minute)),
+		},
+		hasChild: map[operations.OperationID]bool{"root": true, "child": true},
+	}
+
+	if heads := h.heads(); len(heads) != 2 || heads[0] != "late" || heads[1] != "other" {
+		t.Errorf("Expected heads late and other, got %v", heads)
+	}
+
+	missing := h.missing(nil)
+	want := []operations.OperationID{"root", "child", "late", "other"}
+	if len(missing) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, missing)
+	}
+	for i := range want {
+		if missing[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, missing)
+		}
+	}
+
+	// Unknown heads are ignored, known ones cover their ancestry
+	missing = h.missing([]operations.OperationID{"child", "unknown"})
+	if len(missing) != 2 || missing[0] != "late" || missing[1] != "other" {
+		t.Errorf("Expected late and other, got %v", missing)
+	}
+}
+
+func TestHTTPPeer(t *testing.T) {
+	server := newTestNode(t)
+	op := server.insert(t, "package main\n", time.Now())
+
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"success":false,"error":{"code":"forbidden","message":"Insufficient permissions","status":403}}`))
+			return
+		}
+
+		var query PullQuery
+		json.NewDecoder(r.Body).Decode(&query)
+		batch, err := server.Pull(r.Context(), query)
+		if err != nil || r.URL.Path != "/api/v2/replication/pull" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": batch})
+	}))
+	defer httpServer.Close()
+
+	peer, err := NewHTTPPeer(httpServer.URL+"/", "secret")
+	if err != nil {
+		t.Fatalf("Failed to create peer: %v", err)
+	}
+	batch, err := peer.Pull(gocontext.Background(), PullQuery{NodeID: "node_laptop"})
+	if err != nil {
+		t.Fatalf("Failed to pull: %v", err)
+	}
+	if batch.NodeID != server.ID() || len(batch.Operations) != 1 || batch.Operations[0].ID != op.ID {
+		t.Errorf("Expected the server's operation, got %+v", batch)
+	}
+
+	unauthorized, _ := NewHTTPPeer(httpServer.URL, "wrong")
+	var reqErr *RequestError
+	_, err = unauthorized.Pull(gocontext.Background(), PullQuery{})
+	if !errors.As(err, &reqErr) || reqErr.StatusCode != http.StatusForbidden || reqErr.Message != "Insufficient permissions" {
+		t.Errorf("Expected the error envelope's message, got %v", err)
+	}
+
+	if _, err := NewHTTPPeer("server:8080", ""); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Expected ErrInvalidAddress, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package reviewsync
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// pageSize is requested from both providers, a shorter page is the last one
+const pageSize = 100
+
+// apiClient is the JSON-over-HTTP plumbing shared by the providers
+type apiClient struct {
+	baseURL string
+	headers map[string]string
+	http    *http.Client
+}
+
+func newAPIClient(baseURL string, headers map[string]string) *apiClient {
+	return &apiClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		headers: headers,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// do sends body, when not nil, as JSON and decodes the response into out, when not nil
+func (c *apiClient) do(ctx gocontext.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &RequestError{
+			Method:     method,
+			URL:        req.URL.String(),
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(message)),
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s: %w", method, req.URL, err)
+	}
+	return nil
+}
+
+// pages fetches path page by page, calling decode with each page's items
+// until a short page is returned
+func pages[T any](ctx gocontext.Context, c *apiClient, path string, decode func([]T)) error {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	for page := 1; ; page++ {
+		var items []T
+		pagePath := fmt.Sprintf("%s%sper_page=%d&page=%d", path, separator, pageSize, page)
+		if err := c.do(ctx, http.MethodGet, pagePath, nil, &items); err != nil {
+			return err
+		}
+		decode(items)
+		if len(items) < pageSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package reviewsync
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown review provider")
+	ErrNotAnchored     = errors.New("conversation is not anchored to a document")
+)
+
+// RequestError is a non-2xx response from a provider's API
+type RequestError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
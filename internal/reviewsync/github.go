@@ -0,0 +1,158 @@
+package reviewsync
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const DefaultGitHubURL = "https://api.github.com"
+
+// GitHub syncs with pull request review comments through the REST API. The
+// REST API can't resolve review threads, so resolution is only pulled.
+type GitHub struct {
+	client *apiClient
+}
+
+// NewGitHub uses token for every request. baseURL is the API root, which
+// differs on GitHub Enterprise; empty means github.com.
+func NewGitHub(token, baseURL string) *GitHub {
+	if baseURL == "" {
+		baseURL = DefaultGitHubURL
+	}
+	return &GitHub{client: newAPIClient(baseURL, map[string]string{
+		"Authorization":        "Bearer " + token,
+		"Accept":               "application/vnd.github+json",
+		"X-GitHub-Api-Version": "2022-11-28",
+	})}
+}
+
+func (g *GitHub) Name() string {
+	return "github"
+}
+
+type githubComment struct {
+	ID          int64  `json:"id"`
+	InReplyToID int64  `json:"in_reply_to_id"`
+	Path        string `json:"path"`
+	// Line is null once the commented lines are no longer in the diff
+	Line      *int      `json:"line"`
+	StartLine *int      `json:"start_line"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	User      struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+func (c githubComment) remote() RemoteComment {
+	return RemoteComment{
+		ID:        strconv.FormatInt(c.ID, 10),
+		Author:    c.User.Login,
+		Body:      c.Body,
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+func (g *GitHub) ChangedFiles(ctx gocontext.Context, pr PullRequest) ([]string, error) {
+	var files []string
+	err := pages(ctx, g.client, g.pullPath(pr)+"/files", func(page []struct {
+		Filename string `json:"filename"`
+		Status   string `json:"status"`
+	}) {
+		for _, file := range page {
+			if file.Status != "removed" {
+				files = append(files, file.Filename)
+			}
+		}
+	})
+	return files, err
+}
+
+func (g *GitHub) Threads(ctx gocontext.Context, pr PullRequest) ([]RemoteThread, error) {
+	var comments []githubComment
+	err := pages(ctx, g.client, g.pullPath(pr)+"/comments", func(page []githubComment) {
+		comments = append(comments, page...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Replies point at the comment that started the thread, which comes first
+	var threads []RemoteThread
+	index := make(map[int64]int)
+	for _, comment := range comments {
+		if comment.InReplyToID != 0 {
+			if i, exists := index[comment.InReplyToID]; exists {
+				threads[i].Comments = append(threads[i].Comments, comment.remote())
+			}
+			continue
+		}
+
+		thread := RemoteThread{
+			ID:       strconv.FormatInt(comment.ID, 10),
+			Path:     comment.Path,
+			Comments: []RemoteComment{comment.remote()},
+		}
+		if comment.Line != nil {
+			thread.Line, thread.StartLine = *comment.Line, *comment.Line
+		}
+		if comment.StartLine != nil {
+			thread.StartLine = *comment.StartLine
+		}
+		index[comment.ID] = len(threads)
+		threads = append(threads, thread)
+	}
+	return threads, nil
+}
+
+func (g *GitHub) CreateThread(ctx gocontext.Context, pr PullRequest, thread NewThread) (RemoteThread, error) {
+	var pull struct {
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	if err := g.client.do(ctx, http.MethodGet, g.pullPath(pr), nil, &pull); err != nil {
+		return RemoteThread{}, err
+	}
+
+	req := map[string]interface{}{
+		"body":      thread.Body,
+		"commit_id": pull.Head.SHA,
+		"path":      thread.Path,
+		"line":      thread.Line,
+		"side":      "RIGHT",
+	}
+	if thread.StartLine > 0 && thread.StartLine < thread.Line {
+		req["start_line"] = thread.StartLine
+		req["start_side"] = "RIGHT"
+	}
+
+	var created githubComment
+	if err := g.client.do(ctx, http.MethodPost, g.pullPath(pr)+"/comments", req, &created); err != nil {
+		return RemoteThread{}, err
+	}
+
+	return RemoteThread{
+		ID:        strconv.FormatInt(created.ID, 10),
+		Path:      thread.Path,
+		StartLine: thread.StartLine,
+		Line:      thread.Line,
+		Comments:  []RemoteComment{created.remote()},
+	}, nil
+}
+
+func (g *GitHub) Reply(ctx gocontext.Context, pr PullRequest, threadID, body string) (RemoteComment, error) {
+	var created githubComment
+	path := fmt.Sprintf("%s/comments/%s/replies", g.pullPath(pr), threadID)
+	if err := g.client.do(ctx, http.MethodPost, path, map[string]string{"body": body}, &created); err != nil {
+		return RemoteComment{}, err
+	}
+	return created.remote(), nil
+}
+
+func (g *GitHub) pullPath(pr PullRequest) string {
+	return fmt.Sprintf("/repos/%s/pulls/%d", pr.Repository, pr.Number)
+}
@@ -0,0 +1,169 @@
+package reviewsync
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const DefaultGitLabURL = "https://gitlab.com/api/v4"
+
+// GitLab syncs with merge request discussions. GitLab positions a new
+// discussion on a single line, so threads start on the last line of their range.
+type GitLab struct {
+	client *apiClient
+}
+
+// NewGitLab uses token for every request. baseURL is the API root, such as
+// https://gitlab.example.com/api/v4; empty means gitlab.com.
+func NewGitLab(token, baseURL string) *GitLab {
+	if baseURL == "" {
+		baseURL = DefaultGitLabURL
+	}
+	return &GitLab{client: newAPIClient(baseURL, map[string]string{
+		"PRIVATE-TOKEN": token,
+	})}
+}
+
+func (g *GitLab) Name() string {
+	return "gitlab"
+}
+
+type gitlabNote struct {
+	ID        int64     `json:"id"`
+	Body      string    `json:"body"`
+	System    bool      `json:"system"`
+	Resolved  bool      `json:"resolved"`
+	CreatedAt time.Time `json:"created_at"`
+	Author    struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	Position *struct {
+		NewPath   string `json:"new_path"`
+		NewLine   int    `json:"new_line"`
+		LineRange *struct {
+			Start struct {
+				NewLine int `json:"new_line"`
+			} `json:"start"`
+		} `json:"line_range"`
+	} `json:"position"`
+}
+
+func (n gitlabNote) remote() RemoteComment {
+	return RemoteComment{
+		ID:        strconv.FormatInt(n.ID, 10),
+		Author:    n.Author.Username,
+		Body:      n.Body,
+		CreatedAt: n.CreatedAt,
+	}
+}
+
+type gitlabDiscussion struct {
+	ID    string       `json:"id"`
+	Notes []gitlabNote `json:"notes"`
+}
+
+func (d gitlabDiscussion) remote() RemoteThread {
+	first := d.Notes[0]
+	thread := RemoteThread{
+		ID:        d.ID,
+		Path:      first.Position.NewPath,
+		StartLine: first.Position.NewLine,
+		Line:      first.Position.NewLine,
+		Resolved:  first.Resolved,
+	}
+	if first.Position.LineRange != nil && first.Position.LineRange.Start.NewLine > 0 {
+		thread.StartLine = first.Position.LineRange.Start.NewLine
+	}
+	for _, note := range d.Notes {
+		if !note.System {
+			thread.Comments = append(thread.Comments, note.remote())
+		}
+	}
+	return thread
+}
+
+func (g *GitLab) ChangedFiles(ctx gocontext.Context, pr PullRequest) ([]string, error) {
+	var files []string
+	err := pages(ctx, g.client, g.mergeRequestPath(pr)+"/diffs", func(page []struct {
+		NewPath     string `json:"new_path"`
+		DeletedFile bool   `json:"deleted_file"`
+	}) {
+		for _, diff := range page {
+			if !diff.DeletedFile {
+				files = append(files, diff.NewPath)
+			}
+		}
+	})
+	return files, err
+}
+
+func (g *GitLab) Threads(ctx gocontext.Context, pr PullRequest) ([]RemoteThread, error) {
+	var threads []RemoteThread
+	err := pages(ctx, g.client, g.mergeRequestPath(pr)+"/discussions", func(page []gitlabDiscussion) {
+		for _, discussion := range page {
+			// Only diff discussions are anchored to code
+			if len(discussion.Notes) == 0 || discussion.Notes[0].Position == nil || discussion.Notes[0].System {
+				continue
+			}
+			threads = append(threads, discussion.remote())
+		}
+	})
+	return threads, err
+}
+
+func (g *GitLab) CreateThread(ctx gocontext.Context, pr PullRequest, thread NewThread) (RemoteThread, error) {
+	var mergeRequest struct {
+		DiffRefs struct {
+			BaseSHA  string `json:"base_sha"`
+			HeadSHA  string `json:"head_sha"`
+			StartSHA string `json:"start_sha"`
+		} `json:"diff_refs"`
+	}
+	if err := g.client.do(ctx, http.MethodGet, g.mergeRequestPath(pr), nil, &mergeRequest); err != nil {
+		return RemoteThread{}, err
+	}
+
+	req := map[string]interface{}{
+		"body": thread.Body,
+		"position": map[string]interface{}{
+			"position_type": "text",
+			"base_sha":      mergeRequest.DiffRefs.BaseSHA,
+			"head_sha":      mergeRequest.DiffRefs.HeadSHA,
+			"start_sha":     mergeRequest.DiffRefs.StartSHA,
+			"old_path":      thread.Path,
+			"new_path":      thread.Path,
+			"new_line":      thread.Line,
+		},
+	}
+
+	var created gitlabDiscussion
+	if err := g.client.do(ctx, http.MethodPost, g.mergeRequestPath(pr)+"/discussions", req, &created); err != nil {
+		return RemoteThread{}, err
+	}
+	if len(created.Notes) == 0 || created.Notes[0].Position == nil {
+		return RemoteThread{}, fmt.Errorf("gitlab returned discussion %s without a position", created.ID)
+	}
+	return created.remote(), nil
+}
+
+func (g *GitLab) Reply(ctx gocontext.Context, pr PullRequest, threadID, body string) (RemoteComment, error) {
+	var created gitlabNote
+	path := fmt.Sprintf("%s/discussions/%s/notes", g.mergeRequestPath(pr), threadID)
+	if err := g.client.do(ctx, http.MethodPost, path, map[string]string{"body": body}, &created); err != nil {
+		return RemoteComment{}, err
+	}
+	return created.remote(), nil
+}
+
+func (g *GitLab) ResolveThread(ctx gocontext.Context, pr PullRequest, threadID string) error {
+	path := fmt.Sprintf("%s/discussions/%s?resolved=true", g.mergeRequestPath(pr), threadID)
+	return g.client.do(ctx, http.MethodPut, path, nil, nil)
+}
+
+func (g *GitLab) mergeRequestPath(pr PullRequest) string {
+	return fmt.Sprintf("/projects/%s/merge_requests/%d", url.PathEscape(pr.Repository), pr.Number)
+}
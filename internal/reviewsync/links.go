@@ -0,0 +1,90 @@
+package reviewsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+)
+
+// Link ties a conversation thread to the review thread mirroring it
+type Link struct {
+	ThreadID       context.ThreadID `json:"thread_id"`
+	Provider       string           `json:"provider"`
+	PullRequest    PullRequest      `json:"pull_request"`
+	RemoteThreadID string           `json:"remote_thread_id"`
+	// Messages maps each message to the comment it was copied to or from. An
+	// empty comment ID marks a message that is deliberately not mirrored.
+	Messages map[context.MessageID]string `json:"messages"`
+	// Resolved is set once resolution has been mirrored in either direction
+	Resolved bool `json:"resolved,omitempty"`
+}
+
+func (l *Link) hasComment(commentID string) bool {
+	for _, id := range l.Messages {
+		if id == commentID {
+			return true
+		}
+	}
+	return false
+}
+
+// linkStore persists links in .context/review_links.json
+type linkStore struct {
+	path  string
+	links []*Link
+}
+
+func loadLinks(basePath string) (*linkStore, error) {
+	store := &linkStore{path: filepath.Join(basePath, ".context", "review_links.json")}
+
+	data, err := os.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load review links: %w", err)
+	}
+	if err := json.Unmarshal(data, &store.links); err != nil {
+		return nil, fmt.Errorf("failed to load review links: %w", err)
+	}
+	return store, nil
+}
+
+func (s *linkStore) save() error {
+	data, err := json.MarshalIndent(s.links, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode review links: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create review link directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save review links: %w", err)
+	}
+	return nil
+}
+
+func (s *linkStore) add(link *Link) {
+	s.links = append(s.links, link)
+}
+
+func (s *linkStore) byThread(provider string, pr PullRequest, threadID context.ThreadID) *Link {
+	for _, link := range s.links {
+		if link.Provider == provider && link.PullRequest == pr && link.ThreadID == threadID {
+			return link
+		}
+	}
+	return nil
+}
+
+func (s *linkStore) byRemote(provider string, pr PullRequest, remoteThreadID string) *Link {
+	for _, link := range s.links {
+		if link.Provider == provider && link.PullRequest == pr && link.RemoteThreadID == remoteThreadID {
+			return link
+		}
+	}
+	return nil
+}
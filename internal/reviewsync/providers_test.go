@@ -0,0 +1,100 @@
+package reviewsync
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGitHub_ThreadsAndCreate(t *testing.T) {
+	var posted map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/app/pulls/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`[
+			{"id": 1, "path": "main.go", "line": 5, "start_line": 3, "body": "why?", "user": {"login": "carol"}},
+			{"id": 2, "in_reply_to_id": 1, "path": "main.go", "line": 5, "body": "because", "user": {"login": "dave"}},
+			{"id": 3, "path": "old.go", "line": null, "body": "outdated", "user": {"login": "carol"}}
+		]`))
+	})
+	mux.HandleFunc("GET /repos/acme/app/pulls/7", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"head": {"sha": "abc123"}}`))
+	})
+	mux.HandleFunc("POST /repos/acme/app/pulls/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&posted)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 10, "path": "main.go", "line": 9, "body": "hello", "user": {"login": "bot"}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	github := NewGitHub("secret", server.URL)
+	pr := PullRequest{Repository: "acme/app", Number: 7}
+
+	threads, err := github.Threads(gocontext.Background(), pr)
+	if err != nil {
+		t.Fatalf("Failed to list threads: %v", err)
+	}
+	if len(threads) != 2 {
+		t.Fatalf("Expected 2 threads, got %d", len(threads))
+	}
+	if threads[0].ID != "1" || threads[0].StartLine != 3 || threads[0].Line != 5 || len(threads[0].Comments) != 2 {
+		t.Errorf("Unexpected first thread %+v", threads[0])
+	}
+	if threads[1].Line != 0 {
+		t.Errorf("Expected the outdated thread to have no line, got %d", threads[1].Line)
+	}
+
+	created, err := github.CreateThread(gocontext.Background(), pr, NewThread{Path: "main.go", StartLine: 9, Line: 9, Body: "hello"})
+	if err != nil {
+		t.Fatalf("Failed to create thread: %v", err)
+	}
+	if created.ID != "10" || posted["commit_id"] != "abc123" || posted["side"] != "RIGHT" {
+		t.Errorf("Unexpected thread %+v from request %v", created, posted)
+	}
+	if _, multiLine := posted["start_line"]; multiLine {
+		t.Error("Expected a single-line comment without start_line")
+	}
+}
+
+func TestGitLab_Threads(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /projects/{project}/merge_requests/3/discussions", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("project") != "group/app" || r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`[
+			{"id": "d1", "notes": [
+				{"id": 1, "body": "why?", "resolved": true, "author": {"username": "carol"},
+				 "position": {"new_path": "main.go", "new_line": 5, "line_range": {"start": {"new_line": 4}}}},
+				{"id": 2, "body": "changed the line", "system": true, "author": {"username": "carol"}},
+				{"id": 3, "body": "because", "author": {"username": "dave"}}
+			]},
+			{"id": "d2", "notes": [{"id": 4, "body": "general comment", "author": {"username": "erin"}}]}
+		]`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	threads, err := NewGitLab("secret", server.URL).Threads(gocontext.Background(), PullRequest{Repository: "group/app", Number: 3})
+	if err != nil {
+		t.Fatalf("Failed to list threads: %v", err)
+	}
+	if len(threads) != 1 {
+		t.Fatalf("Expected only the diff discussion, got %d threads", len(threads))
+	}
+
+	thread := threads[0]
+	if thread.ID != "d1" || thread.Path != "main.go" || thread.StartLine != 4 || thread.Line != 5 || !thread.Resolved {
+		t.Errorf("Unexpected thread %+v", thread)
+	}
+	if len(thread.Comments) != 2 || thread.Comments[1].Author != "dave" {
+		t.Errorf("Expected system notes to be dropped, got %+v", thread.Comments)
+	}
+}
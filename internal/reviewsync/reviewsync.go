@@ -0,0 +1,72 @@
+// Package reviewsync mirrors conversation threads anchored to code into pull
+// request review comments and back, so a discussion can continue in either
+// place. Stable addresses are mapped to file lines using the current
+// resolution of the document they point into.
+package reviewsync
+
+import (
+	gocontext "context"
+	"fmt"
+	"time"
+)
+
+// PullRequest identifies a GitHub pull request or GitLab merge request
+type PullRequest struct {
+	// Repository is "owner/name" on GitHub and the project path or ID on GitLab
+	Repository string `json:"repository"`
+	Number     int    `json:"number"`
+}
+
+// RemoteThread is a review comment thread on one file, with its comments oldest first
+type RemoteThread struct {
+	ID        string          `json:"id"`
+	Path      string          `json:"path"`
+	StartLine int             `json:"start_line"`
+	Line      int             `json:"line"`
+	Resolved  bool            `json:"resolved"`
+	Comments  []RemoteComment `json:"comments"`
+}
+
+type RemoteComment struct {
+	ID        string    `json:"id"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewThread is a review comment to start on lines StartLine to Line of Path
+type NewThread struct {
+	Path      string
+	StartLine int
+	Line      int
+	Body      string
+}
+
+// Provider talks to a code review host. Line numbers refer to the new side of
+// the pull request's diff.
+type Provider interface {
+	// Name prefixes the author IDs of comments pulled from the provider
+	Name() string
+	ChangedFiles(ctx gocontext.Context, pr PullRequest) ([]string, error)
+	Threads(ctx gocontext.Context, pr PullRequest) ([]RemoteThread, error)
+	// CreateThread returns the new thread with its first comment
+	CreateThread(ctx gocontext.Context, pr PullRequest, thread NewThread) (RemoteThread, error)
+	Reply(ctx gocontext.Context, pr PullRequest, threadID, body string) (RemoteComment, error)
+}
+
+// ThreadResolver is implemented by providers that can mark a thread resolved
+type ThreadResolver interface {
+	ResolveThread(ctx gocontext.Context, pr PullRequest, threadID string) error
+}
+
+// NewProvider returns the provider called name, "github" or "gitlab"
+func NewProvider(name, token, baseURL string) (Provider, error) {
+	switch name {
+	case "github":
+		return NewGitHub(token, baseURL), nil
+	case "gitlab":
+		return NewGitLab(token, baseURL), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+	}
+}
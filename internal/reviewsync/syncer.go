@@ -0,0 +1,319 @@
+package reviewsync
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const maxTitleLength = 80
+
+type Syncer struct {
+	provider      Provider
+	conversations *context.ConversationManager
+	resolver      *addressing.AddressResolver
+	store         storage.Store
+	links         *linkStore
+}
+
+// Report summarizes one Sync. Threads that couldn't be mirrored are listed
+// in Skipped and don't stop the rest of the sync.
+type Report struct {
+	ThreadsPushed   int           `json:"threads_pushed"`
+	ThreadsPulled   int           `json:"threads_pulled"`
+	RepliesPushed   int           `json:"replies_pushed"`
+	RepliesPulled   int           `json:"replies_pulled"`
+	ThreadsResolved int           `json:"threads_resolved"`
+	Skipped         []SkippedItem `json:"skipped,omitempty"`
+}
+
+type SkippedItem struct {
+	ThreadID       context.ThreadID `json:"thread_id,omitempty"`
+	RemoteThreadID string           `json:"remote_thread_id,omitempty"`
+	Reason         string           `json:"reason"`
+}
+
+// NewSyncer mirrors the engine's conversations through provider. Links
+// between threads are kept in basePath/.context/review_links.json so repeated
+// syncs only copy what is new.
+func NewSyncer(basePath string, provider Provider, engine *collaboration.CollaborationEngine, store storage.Store) (*Syncer, error) {
+	links, err := loadLinks(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Syncer{
+		provider:      provider,
+		conversations: engine.ConversationManager(),
+		resolver:      engine.AddressResolver(),
+		store:         store,
+		links:         links,
+	}, nil
+}
+
+// Sync pulls new review threads and replies into conversations, then pushes
+// conversations anchored to files changed by pr, and their new messages, to
+// the review. Resolving a thread on either side resolves it on the other when
+// the provider supports it.
+func (s *Syncer) Sync(ctx gocontext.Context, pr PullRequest) (*Report, error) {
+	report := &Report{}
+
+	files, err := s.provider.ChangedFiles(ctx, pr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+	changed := make(map[string]bool, len(files))
+	for _, file := range files {
+		changed[file] = true
+	}
+
+	remoteThreads, err := s.provider.Threads(ctx, pr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review threads: %w", err)
+	}
+
+	for _, remote := range remoteThreads {
+		s.pull(ctx, pr, remote, report)
+	}
+
+	for _, thread := range s.conversations.Snapshot() {
+		s.push(ctx, pr, thread, changed, report)
+	}
+
+	return report, s.links.save()
+}
+
+func (s *Syncer) pull(ctx gocontext.Context, pr PullRequest, remote RemoteThread, report *Report) {
+	skip := func(reason string) {
+		report.Skipped = append(report.Skipped, SkippedItem{RemoteThreadID: remote.ID, Reason: reason})
+	}
+
+	link := s.links.byRemote(s.provider.Name(), pr, remote.ID)
+	if link == nil {
+		if len(remote.Comments) == 0 {
+			return
+		}
+		if remote.Line == 0 {
+			skip("commented lines are no longer in the diff")
+			return
+		}
+
+		anchor, err := s.anchorForLines(ctx, pr, remote.Path, remote.StartLine, remote.Line)
+		if err != nil {
+			skip(err.Error())
+			return
+		}
+
+		first := remote.Comments[0]
+		thread, err := s.conversations.CreateConversation(anchor, s.author(first.Author), titleFor(first.Body), first.Body)
+		if err != nil {
+			skip(err.Error())
+			return
+		}
+
+		link = &Link{
+			ThreadID:       thread.ID,
+			Provider:       s.provider.Name(),
+			PullRequest:    pr,
+			RemoteThreadID: remote.ID,
+			Messages:       map[context.MessageID]string{thread.Messages[0].ID: first.ID},
+		}
+		s.links.add(link)
+		report.ThreadsPulled++
+	}
+
+	for _, comment := range remote.Comments {
+		if link.hasComment(comment.ID) {
+			continue
+		}
+		message, err := s.conversations.AddMessage(link.ThreadID, s.author(comment.Author), comment.Body, context.MsgComment)
+		if err != nil {
+			skip(err.Error())
+			return
+		}
+		link.Messages[message.ID] = comment.ID
+		report.RepliesPulled++
+	}
+
+	if !remote.Resolved || link.Resolved {
+		return
+	}
+
+	thread, err := s.conversations.GetConversation(link.ThreadID)
+	if err != nil {
+		skip(err.Error())
+		return
+	}
+	if thread.Status != context.StatusResolved {
+		if err := s.conversations.ResolveConversation(link.ThreadID, s.author("")); err != nil {
+			skip(err.Error())
+			return
+		}
+		report.ThreadsResolved++
+
+		// The resolution note only exists locally, the review already shows the thread resolved
+		if thread, err := s.conversations.GetConversation(link.ThreadID); err == nil {
+			link.Messages[thread.Messages[len(thread.Messages)-1].ID] = ""
+		}
+	}
+	link.Resolved = true
+}
+
+func (s *Syncer) push(ctx gocontext.Context, pr PullRequest, thread *context.ConversationThread, changed map[string]bool, report *Report) {
+	skip := func(reason string) {
+		report.Skipped = append(report.Skipped, SkippedItem{ThreadID: thread.ID, Reason: reason})
+	}
+
+	link := s.links.byThread(s.provider.Name(), pr, thread.ID)
+	if link == nil {
+		// Only live discussions about code in this review are worth starting
+		// there, and only public ones may be seen by everyone on the review
+		if thread.Status != context.StatusOpen && thread.Status != context.StatusPinned {
+			return
+		}
+		if !thread.IsPublic() {
+			return
+		}
+		if len(thread.Messages) == 0 {
+			return
+		}
+
+		path, first, last, err := s.locate(ctx, thread.AnchorAddress)
+		if errors.Is(err, ErrNotAnchored) || (err == nil && !changed[path]) {
+			return
+		}
+		if err != nil {
+			skip(err.Error())
+			return
+		}
+
+		root := thread.Messages[0]
+		remote, err := s.provider.CreateThread(ctx, pr, NewThread{
+			Path:      path,
+			StartLine: first,
+			Line:      last,
+			Body:      formatComment(thread.Title, root),
+		})
+		if err != nil {
+			skip(err.Error())
+			return
+		}
+
+		link = &Link{
+			ThreadID:       thread.ID,
+			Provider:       s.provider.Name(),
+			PullRequest:    pr,
+			RemoteThreadID: remote.ID,
+			Messages:       map[context.MessageID]string{root.ID: remote.Comments[0].ID},
+		}
+		s.links.add(link)
+		report.ThreadsPushed++
+	}
+
+	for _, message := range thread.Messages {
+		if _, mirrored := link.Messages[message.ID]; mirrored {
+			continue
+		}
+		comment, err := s.provider.Reply(ctx, pr, link.RemoteThreadID, formatComment("", message))
+		if err != nil {
+			skip(err.Error())
+			return
+		}
+		link.Messages[message.ID] = comment.ID
+		report.RepliesPushed++
+	}
+
+	if thread.Status == context.StatusResolved && !link.Resolved {
+		resolver, ok := s.provider.(ThreadResolver)
+		if !ok {
+			return
+		}
+		if err := resolver.ResolveThread(ctx, pr, link.RemoteThreadID); err != nil {
+			skip(err.Error())
+			return
+		}
+		link.Resolved = true
+		report.ThreadsResolved++
+	}
+}
+
+// locate maps an address to the path and lines its current range covers
+func (s *Syncer) locate(ctx gocontext.Context, addr addressing.StableAddress) (string, int, int, error) {
+	if !addr.IsValid() || addr.OperationID == "" {
+		return "", 0, 0, ErrNotAnchored
+	}
+
+	posRange := addr.PositionRange
+	if resolved, err := s.resolver.ResolveAddress(addr); err == nil {
+		posRange = resolved.CurrentRange
+	}
+
+	op, err := s.store.GetOperation(ctx, addr.OperationID)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("%w: %v", ErrNotAnchored, err)
+	}
+	documentID := op.Metadata.DocumentID
+	if documentID == "" {
+		return "", 0, 0, ErrNotAnchored
+	}
+
+	doc, err := s.store.GetDocument(ctx, documentID)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("failed to load document %s: %w", documentID, err)
+	}
+
+	first, last, err := addr.LinesIn(doc, posRange)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("%w: %v", ErrNotAnchored, err)
+	}
+	return documentID, first, last, nil
+}
+
+// anchorForLines builds an address for lines first to last of the document at path
+func (s *Syncer) anchorForLines(ctx gocontext.Context, pr PullRequest, path string, first, last int) (addressing.StableAddress, error) {
+	if first <= 0 || first > last {
+		first = last
+	}
+
+	doc, err := s.store.GetDocument(ctx, path)
+	if err != nil {
+		return addressing.StableAddress{}, fmt.Errorf("%s is not tracked: %w", path, err)
+	}
+
+	return s.resolver.AddressForLines(addressing.RepositoryID(pr.Repository), doc, first, last)
+}
+
+func (s *Syncer) author(login string) operations.AuthorID {
+	if login == "" {
+		return operations.AuthorID(s.provider.Name())
+	}
+	return operations.AuthorID(s.provider.Name() + ":" + login)
+}
+
+// formatComment renders a message for the review, crediting its author since
+// every comment is posted with the syncing token
+func formatComment(title string, message context.Message) string {
+	var body strings.Builder
+	if title != "" {
+		fmt.Fprintf(&body, "**%s**\n\n", title)
+	}
+	fmt.Fprintf(&body, "%s\n\n_%s via contextdb_", message.Content, message.AuthorID)
+	return body.String()
+}
+
+// titleFor takes a conversation title from the first line of a comment
+func titleFor(body string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(body), "\n")
+	title = strings.TrimSpace(title)
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = strings.TrimSpace(string(runes[:maxTitleLength])) + "..."
+	}
+	return title
+}
@@ -0,0 +1,223 @@
+package reviewsync
+
+import (
+	gocontext "context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+// fakeProvider keeps review threads in memory
+type fakeProvider struct {
+	files    []string
+	threads  []RemoteThread
+	resolved map[string]bool
+	nextID   int
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) ChangedFiles(ctx gocontext.Context, pr PullRequest) ([]string, error) {
+	return p.files, nil
+}
+
+func (p *fakeProvider) Threads(ctx gocontext.Context, pr PullRequest) ([]RemoteThread, error) {
+	threads := make([]RemoteThread, len(p.threads))
+	for i, thread := range p.threads {
+		thread.Comments = append([]RemoteComment(nil), thread.Comments...)
+		threads[i] = thread
+	}
+	return threads, nil
+}
+
+func (p *fakeProvider) CreateThread(ctx gocontext.Context, pr PullRequest, thread NewThread) (RemoteThread, error) {
+	remote := RemoteThread{
+		ID:        p.id(),
+		Path:      thread.Path,
+		StartLine: thread.StartLine,
+		Line:      thread.Line,
+		Comments:  []RemoteComment{{ID: p.id(), Author: "bot", Body: thread.Body}},
+	}
+	p.threads = append(p.threads, remote)
+	return remote, nil
+}
+
+func (p *fakeProvider) Reply(ctx gocontext.Context, pr PullRequest, threadID, body string) (RemoteComment, error) {
+	for i := range p.threads {
+		if p.threads[i].ID == threadID {
+			comment := RemoteComment{ID: p.id(), Author: "bot", Body: body}
+			p.threads[i].Comments = append(p.threads[i].Comments, comment)
+			return comment, nil
+		}
+	}
+	return RemoteComment{}, fmt.Errorf("no thread %s", threadID)
+}
+
+func (p *fakeProvider) ResolveThread(ctx gocontext.Context, pr PullRequest, threadID string) error {
+	p.resolved[threadID] = true
+	return nil
+}
+
+func (p *fakeProvider) id() string {
+	p.nextID++
+	return fmt.Sprintf("c%d", p.nextID)
+}
+
+type syncFixture struct {
+	engine   *collaboration.CollaborationEngine
+	store    storage.Store
+	provider *fakeProvider
+	syncer   *Syncer
+	op       *operations.Operation
+}
+
+func newSyncFixture(t *testing.T) *syncFixture {
+	t.Helper()
+	ctx := gocontext.Background()
+
+	store, err := storage.NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test storage: %v", err)
+	}
+	engine := collaboration.NewCollaborationEngine(store)
+
+	op := &operations.Operation{
+		ID:   operations.NewOperationID([]byte("main.go")),
+		Type: operations.OpInsert,
+		Position: operations.NewLogootPosition([]operations.PositionSegment{
+			{Value: big.NewInt(1), AuthorID: "alice"},
+		}),
+		Content:   "package main\n\nfunc main() {\n\tprintln(1)\n}\n",
+		Author:    "alice",
+		Timestamp: time.Now(),
+		Metadata:  operations.OperationMeta{Context: map[string]string{"document_id": "main.go"}},
+	}
+	if err := engine.ProcessOperation(ctx, op, ""); err != nil {
+		t.Fatalf("Failed to process operation: %v", err)
+	}
+
+	provider := &fakeProvider{files: []string{"main.go"}, resolved: make(map[string]bool)}
+	syncer, err := NewSyncer(t.TempDir(), provider, engine, store)
+	if err != nil {
+		t.Fatalf("Failed to create syncer: %v", err)
+	}
+
+	return &syncFixture{engine: engine, store: store, provider: provider, syncer: syncer, op: op}
+}
+
+func (f *syncFixture) sync(t *testing.T) *Report {
+	t.Helper()
+
+	report, err := f.syncer.Sync(gocontext.Background(), PullRequest{Repository: "acme/app", Number: 7})
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	return report
+}
+
+func TestSyncer_PushesConversations(t *testing.T) {
+	f := newSyncFixture(t)
+	manager := f.engine.ConversationManager()
+
+	anchor := addressing.NewStableAddress("acme/app", f.op.ID, addressing.PositionRange{Start: f.op.Position, End: f.op.Position})
+	anchor.Fragment = "lines:3-5"
+	thread, err := manager.CreateConversation(anchor, "alice", "Entry point", "Should this exit non-zero?")
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+
+	report := f.sync(t)
+	if report.ThreadsPushed != 1 || len(report.Skipped) != 0 {
+		t.Fatalf("Expected one pushed thread, got %+v", report)
+	}
+
+	remote := f.provider.threads[0]
+	if remote.Path != "main.go" || remote.StartLine != 3 || remote.Line != 5 {
+		t.Errorf("Expected main.go lines 3-5, got %s lines %d-%d", remote.Path, remote.StartLine, remote.Line)
+	}
+	if !strings.Contains(remote.Comments[0].Body, "Should this exit non-zero?") {
+		t.Errorf("Expected the first message in the comment, got %q", remote.Comments[0].Body)
+	}
+
+	// A reply on either side is copied to the other, once
+	manager.AddMessage(thread.ID, "bob", "Yes, on error", context.MsgAnswer)
+	f.provider.threads[0].Comments = append(f.provider.threads[0].Comments, RemoteComment{ID: "r1", Author: "carol", Body: "Agreed"})
+
+	report = f.sync(t)
+	if report.RepliesPushed != 1 || report.RepliesPulled != 1 {
+		t.Fatalf("Expected one reply each way, got %+v", report)
+	}
+	if report = f.sync(t); report.RepliesPushed != 0 || report.RepliesPulled != 0 {
+		t.Errorf("Expected nothing new on a second sync, got %+v", report)
+	}
+
+	synced, _ := manager.GetConversation(thread.ID)
+	last := synced.Messages[len(synced.Messages)-1]
+	if last.AuthorID != "fake:carol" || last.Content != "Agreed" {
+		t.Errorf("Expected carol's reply, got %+v", last)
+	}
+
+	manager.ResolveConversation(thread.ID, "alice")
+	if report = f.sync(t); report.ThreadsResolved != 1 || !f.provider.resolved[remote.ID] {
+		t.Errorf("Expected the review thread to be resolved, got %+v", report)
+	}
+}
+
+func TestSyncer_PullsReviewThreads(t *testing.T) {
+	f := newSyncFixture(t)
+	f.provider.threads = []RemoteThread{{
+		ID:        "t1",
+		Path:      "main.go",
+		StartLine: 4,
+		Line:      4,
+		Resolved:  true,
+		Comments:  []RemoteComment{{ID: "r1", Author: "carol", Body: "Use fmt here\nprintln is for debugging"}},
+	}}
+
+	report := f.sync(t)
+	if report.ThreadsPulled != 1 || report.ThreadsResolved != 1 || report.RepliesPushed != 0 {
+		t.Fatalf("Expected one pulled and resolved thread without echoes, got %+v", report)
+	}
+
+	threads := f.engine.ConversationManager().Snapshot()
+	if len(threads) != 1 {
+		t.Fatalf("Expected 1 conversation, got %d", len(threads))
+	}
+	thread := threads[0]
+	if thread.Title != "Use fmt here" || thread.Status != context.StatusResolved {
+		t.Errorf("Unexpected conversation %q [%s]", thread.Title, thread.Status)
+	}
+	if thread.AnchorAddress.OperationID != f.op.ID {
+		t.Errorf("Expected anchor on %s, got %s", f.op.ID, thread.AnchorAddress.OperationID)
+	}
+
+	// The anchor maps back to the commented line
+	path, first, last, err := f.syncer.locate(gocontext.Background(), thread.AnchorAddress)
+	if err != nil {
+		t.Fatalf("Failed to locate anchor: %v", err)
+	}
+	if path != "main.go" || first != 4 || last != 4 {
+		t.Errorf("Expected main.go line 4, got %s lines %d-%d", path, first, last)
+	}
+}
+
+func TestSyncer_SkipsUnchangedFiles(t *testing.T) {
+	f := newSyncFixture(t)
+	f.provider.files = []string{"other.go"}
+
+	anchor := addressing.NewStableAddress("acme/app", f.op.ID, addressing.PositionRange{Start: f.op.Position, End: f.op.Position})
+	f.engine.ConversationManager().CreateConversation(anchor, "alice", "Elsewhere", "Not part of this review")
+	f.engine.ConversationManager().CreateConversation(addressing.StableAddress{}, "alice", "Unanchored", "No code")
+
+	if report := f.sync(t); report.ThreadsPushed != 0 || len(report.Skipped) != 0 {
+		t.Errorf("Expected nothing pushed or skipped, got %+v", report)
+	}
+}
@@ -0,0 +1,711 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/api"
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/backup"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/embeddings"
+	"github.com/jeremytregunna/contextdb/internal/eventbus"
+	"github.com/jeremytregunna/contextdb/internal/logging"
+	"github.com/jeremytregunna/contextdb/internal/replication"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+	"github.com/jeremytregunna/contextdb/internal/summaries"
+	"gopkg.in/yaml.v3"
+)
+
+type AuthMode string
+
+const (
+	// AuthModeDefault leaves the require_auth setting in auth.json untouched
+	AuthModeDefault  AuthMode = ""
+	AuthModeRequired AuthMode = "required"
+	AuthModeOptional AuthMode = "optional"
+)
+
+type Config struct {
+	Listen          string              `yaml:"listen"`
+	TLS             TLSConfig           `yaml:"tls"`
+	CORS            CORSConfig          `yaml:"cors"`
+	Compression     CompressionConfig   `yaml:"compression"`
+	AccessLog       AccessLogConfig     `yaml:"access_log"`
+	Logging         LoggingConfig       `yaml:"logging"`
+	WebSocket       WebSocketConfig     `yaml:"websocket"`
+	EventBus        EventBusConfig      `yaml:"event_bus"`
+	Auth            AuthConfig          `yaml:"auth"`
+	Storage         StorageConfig       `yaml:"storage"`
+	DocumentCache   DocumentCacheConfig `yaml:"document_cache"`
+	Operations      OperationsConfig    `yaml:"operations"`
+	Replication     ReplicationConfig   `yaml:"replication"`
+	Backup          BackupConfig        `yaml:"backup"`
+	Embeddings      EmbeddingsConfig    `yaml:"embeddings"`
+	Summaries       SummariesConfig     `yaml:"summaries"`
+	Analysis        AnalysisConfig      `yaml:"analysis"`
+	ContextPacks    ContextPacksConfig  `yaml:"context_packs"`
+	ShutdownTimeout time.Duration       `yaml:"shutdown_timeout"`
+}
+
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// CompressionConfig sets how API responses are compressed for clients that
+// send Accept-Encoding with gzip or zstd
+type CompressionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MinSize is the smallest response body compressed, in bytes
+	MinSize int `yaml:"min_size"`
+}
+
+type LogFormat string
+
+const (
+	// LogFormatDefault follows logging.format, or for it the LOG_FORMAT
+	// environment variable
+	LogFormatDefault LogFormat = ""
+	LogFormatText    LogFormat = "text"
+	LogFormatJSON    LogFormat = "json"
+)
+
+// AccessLogConfig logs each API request once it is answered
+type AccessLogConfig struct {
+	Enabled bool      `yaml:"enabled"`
+	Format  LogFormat `yaml:"format"`
+	// Sample maps routes, such as "GET /api/v1/operations" or "GET /healthz",
+	// to the fraction of their successful requests logged
+	Sample map[string]float64 `yaml:"sample"`
+}
+
+// Logger builds the access logger in the configured format
+func (c AccessLogConfig) Logger() *logging.Logger {
+	logger := logging.NewLogger("access")
+	if c.Format != LogFormatDefault {
+		logger.SetJSONFormat(c.Format == LogFormatJSON)
+	}
+	return logger
+}
+
+type LogOutput string
+
+const (
+	// LogOutputStderr writes through the standard library's logger
+	LogOutputStderr LogOutput = ""
+	LogOutputStdout LogOutput = "stdout"
+	LogOutputFile   LogOutput = "file"
+	LogOutputSyslog LogOutput = "syslog"
+)
+
+// LoggingConfig sets where every component logs, in what format and from
+// what level
+type LoggingConfig struct {
+	// Level is debug, info, warn or error, empty for LOG_LEVEL or info
+	Level  string        `yaml:"level"`
+	Format LogFormat     `yaml:"format"`
+	Output LogOutput     `yaml:"output"`
+	File   LogFileConfig `yaml:"file"`
+	Syslog SyslogConfig  `yaml:"syslog"`
+	// Components maps components, such as api or websocket, to a level they
+	// log at instead of Level
+	Components map[string]string `yaml:"components"`
+	// SlowQueryThreshold is how long a storage query may take before it is
+	// logged as a warning, zero to log none
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold"`
+}
+
+// LogFileConfig is the file logged to with output file, rotated once it
+// reaches MaxSize bytes keeping MaxBackups old files
+type LogFileConfig struct {
+	Path       string `yaml:"path"`
+	MaxSize    int64  `yaml:"max_size"`
+	MaxBackups int    `yaml:"max_backups"`
+}
+
+// SyslogConfig is the daemon logged to with output syslog, the local one
+// when Network and Address are empty
+type SyslogConfig struct {
+	Network string `yaml:"network"`
+	Address string `yaml:"address"`
+	Tag     string `yaml:"tag"`
+}
+
+// Open connects the configured output, nil for the standard library's
+// logger, along with what closes it when it needs closing
+func (c LoggingConfig) Open() (logging.Output, io.Closer, error) {
+	switch c.Output {
+	case LogOutputStdout:
+		return logging.NewWriterOutput(os.Stdout), nil, nil
+	case LogOutputFile:
+		file, err := logging.OpenRotatingFile(c.File.Path, c.File.MaxSize, c.File.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		return logging.NewWriterOutput(file), file, nil
+	case LogOutputSyslog:
+		output, err := logging.NewSyslogOutput(c.Syslog.Network, c.Syslog.Address, c.Syslog.Tag)
+		if err != nil {
+			return nil, nil, err
+		}
+		closer, _ := output.(io.Closer)
+		return output, closer, nil
+	default:
+		return nil, nil, nil
+	}
+}
+
+// Apply sets the format and levels every logger follows, replacing any
+// levels set for components since, and the slow query threshold
+func (c LoggingConfig) Apply() {
+	level := logging.DefaultLevel()
+	if c.Level != "" {
+		// Validate made sure the levels parse
+		level, _ = logging.ParseLevel(c.Level)
+	}
+	logging.SetGlobalLevel(level)
+
+	jsonFormat := logging.DefaultJSONFormat()
+	if c.Format != LogFormatDefault {
+		jsonFormat = c.Format == LogFormatJSON
+	}
+	logging.UseJSONFormat(jsonFormat)
+
+	for component := range logging.ComponentLevels() {
+		logging.ResetComponentLevel(component)
+	}
+	for component, name := range c.Components {
+		level, _ := logging.ParseLevel(name)
+		logging.SetComponentLevel(component, level)
+	}
+	storage.SetSlowQueryThreshold(c.SlowQueryThreshold)
+}
+
+// WebSocketConfig sets how collaboration clients connect at /ws
+type WebSocketConfig struct {
+	// AllowedOrigins are the browser origins clients may connect from, or "*"
+	// for any. Empty allows pages served from localhost only.
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	// Compression negotiates per-message deflate with clients that support it
+	Compression bool `yaml:"compression"`
+	// CompressionThreshold is the size in bytes from which sync state is
+	// gzipped for clients that ask, zero never gzips
+	CompressionThreshold int `yaml:"compression_threshold"`
+	// SyncChunkSize is the most bytes of sync state sent in one message to
+	// clients that accept chunked syncs, zero never splits one
+	SyncChunkSize   int `yaml:"sync_chunk_size"`
+	ReadBufferSize  int `yaml:"read_buffer_size"`
+	WriteBufferSize int `yaml:"write_buffer_size"`
+	// MaxMessageSize is the largest message accepted from a client, in bytes
+	MaxMessageSize int64 `yaml:"max_message_size"`
+	// SendBufferSize is how many messages may wait for a client to read them
+	SendBufferSize    int           `yaml:"send_buffer_size"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+	// SlowClientTimeout is how long a full send buffer is tolerated before
+	// the client is disconnected, zero never disconnects
+	SlowClientTimeout time.Duration `yaml:"slow_client_timeout"`
+	// ResumeTTL is how long a dropped client's session is kept for it to
+	// resume, zero never keeps one
+	ResumeTTL time.Duration `yaml:"resume_ttl"`
+}
+
+func (c WebSocketConfig) Engine() collaboration.WebSocketConfig {
+	return collaboration.WebSocketConfig(c)
+}
+
+type EventBusBackend string
+
+const (
+	// EventBusNone keeps live updates within this instance
+	EventBusNone  EventBusBackend = ""
+	EventBusRedis EventBusBackend = "redis"
+	EventBusNATS  EventBusBackend = "nats"
+)
+
+// EventBusConfig shares live updates between instances serving one store, so
+// WebSocket clients see the same updates whichever instance they connect to
+type EventBusConfig struct {
+	Backend EventBusBackend `yaml:"backend"`
+	// URL is the Redis or NATS server, as redis://, rediss://, nats:// or tls://
+	URL string `yaml:"url"`
+	// Channel is the Redis channel or NATS subject updates are published on,
+	// shared by every instance serving the store
+	Channel string `yaml:"channel"`
+}
+
+// NewBus builds the configured bus, nil when updates stay within this instance
+func (c EventBusConfig) NewBus() (eventbus.Bus, error) {
+	switch c.Backend {
+	case EventBusRedis:
+		return eventbus.NewRedis(c.URL, c.Channel)
+	case EventBusNATS:
+		return eventbus.NewNATS(c.URL, c.Channel)
+	}
+	return nil, nil
+}
+
+type AuthConfig struct {
+	Mode AuthMode `yaml:"mode"`
+	// Lockout refuses clients that fail to authenticate too often
+	Lockout LockoutConfig `yaml:"lockout"`
+	// TrustForwardedFor tracks failed attempts by the client address in
+	// X-Forwarded-For, for servers behind a reverse proxy that sets it.
+	// Otherwise every client behind the proxy shares its address.
+	TrustForwardedFor bool `yaml:"trust_forwarded_for"`
+}
+
+// LockoutConfig locks out a client address for duration once it
+// fails to authenticate max_failures times within window. Zero max_failures
+// never locks out.
+type LockoutConfig struct {
+	MaxFailures int           `yaml:"max_failures"`
+	Window      time.Duration `yaml:"window"`
+	Duration    time.Duration `yaml:"duration"`
+}
+
+func (c LockoutConfig) Policy() auth.LockoutPolicy {
+	return auth.LockoutPolicy(c)
+}
+
+type StorageConfig struct {
+	// Path is the directory that holds, or will hold, the .context store
+	Path string `yaml:"path"`
+	// ReadOnly serves the store without changing it: operations and every
+	// other write are refused with 403, and nothing runs in the background.
+	// The store may be a copy that another tool keeps current.
+	ReadOnly bool `yaml:"read_only"`
+	// WhenClaimed is what to do when another server already serves the
+	// store. Read-only servers never claim the store, so ignore it.
+	WhenClaimed ClaimPolicy `yaml:"when_claimed"`
+}
+
+// ClaimPolicy decides whether a server may serve a store another live server
+// has claimed. A claim left by a server that died is taken over regardless.
+type ClaimPolicy string
+
+const (
+	// ClaimFail refuses to start, naming the server holding the store
+	ClaimFail ClaimPolicy = ""
+	// ClaimReadOnly starts read-only, as if storage.read_only were set
+	ClaimReadOnly ClaimPolicy = "read_only"
+	// ClaimShare serves the store alongside the other servers, for instances
+	// sharing live updates over event_bus
+	ClaimShare ClaimPolicy = "share"
+)
+
+// DocumentCacheConfig bounds the documents the server keeps in memory. The
+// least recently used are evicted past either limit; 0 leaves it off.
+type DocumentCacheConfig struct {
+	MaxDocuments int `yaml:"max_documents"`
+	// MaxBytes limits the estimated size of the cached documents
+	MaxBytes int64 `yaml:"max_bytes"`
+}
+
+func (c DocumentCacheConfig) Engine() collaboration.DocumentCacheConfig {
+	return collaboration.DocumentCacheConfig(c)
+}
+
+type OperationsConfig struct {
+	// MaxContentSize is the largest operation content accepted, in bytes
+	MaxContentSize int `yaml:"max_content_size"`
+	// MaxRequestSize is the largest request to create an operation, in
+	// bytes. Text inserts with more than MaxContentSize are split into
+	// operations of at most that much, so it limits those instead.
+	MaxRequestSize int `yaml:"max_request_size"`
+}
+
+// ReplicationConfig turns on gossip with other nodes. Without peers or mDNS
+// the node still answers syncs started elsewhere.
+type ReplicationConfig struct {
+	// Peers are addresses of nodes to gossip with, e.g. http://team:8080
+	Peers []string `yaml:"peers"`
+	// MDNS advertises this node and discovers peers on the local network
+	MDNS bool `yaml:"mdns"`
+	// Advertise is the address peers found over mDNS use to reach this node,
+	// by default the address the advertisement came from and the listen port
+	Advertise string        `yaml:"advertise"`
+	Interval  time.Duration `yaml:"interval"`
+	// APIKey is presented to peers, which must grant it the replicate permission
+	APIKey string `yaml:"api_key"`
+}
+
+func (c ReplicationConfig) Enabled() bool {
+	return len(c.Peers) > 0 || c.MDNS
+}
+
+// BackupConfig schedules backups to .context/backups. Backups can always be
+// taken through the API and CLI.
+type BackupConfig struct {
+	// Interval between scheduled backups, 0 disables them
+	Interval time.Duration `yaml:"interval"`
+	// Generations is how many backups to keep
+	Generations int `yaml:"generations"`
+}
+
+type EmbeddingsProvider string
+
+const (
+	EmbeddingsDisabled EmbeddingsProvider = ""
+	// EmbeddingsHash embeds locally by hashing words, which finds changes that
+	// share vocabulary without any external service
+	EmbeddingsHash EmbeddingsProvider = "hash"
+	// EmbeddingsHTTP calls an OpenAI compatible embeddings endpoint
+	EmbeddingsHTTP EmbeddingsProvider = "http"
+)
+
+// EmbeddingsConfig turns on similarity search by embedding operations and
+// conversation messages in the background
+type EmbeddingsConfig struct {
+	Provider EmbeddingsProvider `yaml:"provider"`
+	// URL, Model and APIKey configure the http provider
+	URL    string `yaml:"url"`
+	Model  string `yaml:"model"`
+	APIKey string `yaml:"api_key"`
+	// Dimensions is the length of hash provider vectors
+	Dimensions int           `yaml:"dimensions"`
+	Interval   time.Duration `yaml:"interval"`
+	BatchSize  int           `yaml:"batch_size"`
+}
+
+func (c EmbeddingsConfig) Enabled() bool {
+	return c.Provider != EmbeddingsDisabled
+}
+
+// NewProvider builds the configured provider, nil when embeddings are disabled
+func (c EmbeddingsConfig) NewProvider() embeddings.Provider {
+	switch c.Provider {
+	case EmbeddingsHash:
+		return embeddings.NewHashProvider(c.Dimensions)
+	case EmbeddingsHTTP:
+		return embeddings.NewHTTPProvider(c.URL, c.Model, c.APIKey)
+	}
+	return nil
+}
+
+type SummariesProvider string
+
+const (
+	SummariesDisabled SummariesProvider = ""
+	// SummariesExtractive picks a thread's most telling sentences locally,
+	// without any external service
+	SummariesExtractive SummariesProvider = "extractive"
+	// SummariesHTTP asks a language model through an OpenAI compatible chat
+	// completions endpoint
+	SummariesHTTP SummariesProvider = "http"
+)
+
+// SummariesConfig turns on summarizing conversation threads as they are
+// resolved and on request
+type SummariesConfig struct {
+	Provider SummariesProvider `yaml:"provider"`
+	// URL, Model, APIKey and Prompt configure the http provider. An empty
+	// prompt asks for a few sentences on what was discussed and decided.
+	URL    string `yaml:"url"`
+	Model  string `yaml:"model"`
+	APIKey string `yaml:"api_key"`
+	Prompt string `yaml:"prompt"`
+	// Sentences is how many sentences the extractive provider picks
+	Sentences int `yaml:"sentences"`
+	// MaxLength caps a summary, in bytes
+	MaxLength int `yaml:"max_length"`
+}
+
+func (c SummariesConfig) Enabled() bool {
+	return c.Provider != SummariesDisabled
+}
+
+// NewSummarizer builds the configured summarizer, nil when summaries are disabled
+func (c SummariesConfig) NewSummarizer() summaries.Summarizer {
+	switch c.Provider {
+	case SummariesExtractive:
+		return summaries.NewExtractiveSummarizer(c.Sentences)
+	case SummariesHTTP:
+		return summaries.NewHTTPSummarizer(c.URL, c.Model, c.APIKey, c.Prompt, c.MaxLength)
+	}
+	return nil
+}
+
+// AnalysisConfig sets the thresholds author activity patterns and document
+// hot spots are detected with
+type AnalysisConfig struct {
+	BurstyRate           float64 `yaml:"bursty_rate"`
+	SteadyActiveRatio    float64 `yaml:"steady_active_ratio"`
+	SteadyMinDays        int     `yaml:"steady_min_days"`
+	RefactoringRatio     float64 `yaml:"refactoring_ratio"`
+	BugfixingRatio       float64 `yaml:"bugfixing_ratio"`
+	HotSpotMinOperations int     `yaml:"hot_spot_min_operations"`
+	MaxHotSpots          int     `yaml:"max_hot_spots"`
+}
+
+func (c AnalysisConfig) Thresholds() context.PatternThresholds {
+	return context.PatternThresholds(c)
+}
+
+// ContextPacksConfig sets the token budget context packs are fitted to by
+// default, and templates to render them with by name, added to the built-in
+// markdown and xml templates or replacing them
+type ContextPacksConfig struct {
+	Budget    int               `yaml:"budget"`
+	Templates map[string]string `yaml:"templates"`
+}
+
+func (c ContextPacksConfig) Engine() collaboration.ContextPackConfig {
+	return collaboration.ContextPackConfig(c)
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Listen:          "localhost:8080",
+		CORS:            CORSConfig{AllowedOrigins: []string{"*"}},
+		Compression:     CompressionConfig{Enabled: true, MinSize: api.DefaultCompressionMinSize},
+		AccessLog:       AccessLogConfig{Enabled: true, Sample: map[string]float64{"GET /healthz": 0.01, "GET /readyz": 0.01}},
+		Logging:         LoggingConfig{File: LogFileConfig{MaxSize: 100 << 20, MaxBackups: 5}, Syslog: SyslogConfig{Tag: "contextdb"}, SlowQueryThreshold: storage.DefaultSlowQueryThreshold},
+		WebSocket:       WebSocketConfig(collaboration.DefaultWebSocketConfig()),
+		EventBus:        EventBusConfig{Channel: eventbus.DefaultChannel},
+		Auth:            AuthConfig{Lockout: LockoutConfig(auth.DefaultLockoutPolicy())},
+		Storage:         StorageConfig{Path: "."},
+		DocumentCache:   DocumentCacheConfig(collaboration.DefaultDocumentCacheConfig()),
+		Operations:      OperationsConfig{MaxContentSize: api.DefaultMaxContentSize, MaxRequestSize: api.DefaultMaxRequestSize},
+		Replication:     ReplicationConfig{Interval: replication.DefaultGossipInterval},
+		Backup:          BackupConfig{Generations: backup.DefaultGenerations},
+		Embeddings:      EmbeddingsConfig{Dimensions: embeddings.DefaultHashDimensions, Interval: embeddings.DefaultInterval, BatchSize: embeddings.DefaultBatchSize},
+		Summaries:       SummariesConfig{Sentences: summaries.DefaultSentences, MaxLength: summaries.DefaultMaxLength},
+		Analysis:        AnalysisConfig(context.DefaultPatternThresholds()),
+		ContextPacks:    ContextPacksConfig(collaboration.DefaultContextPackConfig()),
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// LoadConfig reads a YAML config file over the defaults. Unknown keys are
+// rejected so a typo doesn't silently fall back to a default.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	// An empty file decodes as EOF and simply means all defaults
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return config, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	return config, config.Validate()
+}
+
+func (c Config) Validate() error {
+	if c.Listen == "" {
+		return fmt.Errorf("%w: listen address is required", ErrInvalidConfig)
+	}
+	if c.TLS.Enabled() && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		return fmt.Errorf("%w: tls needs both cert_file and key_file", ErrInvalidConfig)
+	}
+	if c.Compression.MinSize <= 0 {
+		return fmt.Errorf("%w: compression.min_size must be positive", ErrInvalidConfig)
+	}
+	switch c.AccessLog.Format {
+	case LogFormatDefault, LogFormatText, LogFormatJSON:
+	default:
+		return fmt.Errorf("%w: unknown access_log format %q", ErrInvalidConfig, c.AccessLog.Format)
+	}
+	for route, rate := range c.AccessLog.Sample {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%w: access_log sample rate for %q must be between 0 and 1", ErrInvalidConfig, route)
+		}
+	}
+	if c.Logging.Level != "" {
+		if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+			return fmt.Errorf("%w: logging.level: %v", ErrInvalidConfig, err)
+		}
+	}
+	for component, level := range c.Logging.Components {
+		if _, err := logging.ParseLevel(level); err != nil {
+			return fmt.Errorf("%w: logging level of %s: %v", ErrInvalidConfig, component, err)
+		}
+	}
+	if c.Logging.SlowQueryThreshold < 0 {
+		return fmt.Errorf("%w: logging.slow_query_threshold must not be negative", ErrInvalidConfig)
+	}
+	switch c.Logging.Format {
+	case LogFormatDefault, LogFormatText, LogFormatJSON:
+	default:
+		return fmt.Errorf("%w: unknown logging format %q", ErrInvalidConfig, c.Logging.Format)
+	}
+	switch c.Logging.Output {
+	case LogOutputStderr, LogOutputStdout, LogOutputSyslog:
+	case LogOutputFile:
+		if c.Logging.File.Path == "" {
+			return fmt.Errorf("%w: logging to a file needs logging.file.path", ErrInvalidConfig)
+		}
+		if c.Logging.File.MaxSize < 0 || c.Logging.File.MaxBackups < 0 {
+			return fmt.Errorf("%w: logging.file.max_size and max_backups must not be negative", ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown logging output %q", ErrInvalidConfig, c.Logging.Output)
+	}
+	if c.WebSocket.ReadBufferSize <= 0 || c.WebSocket.WriteBufferSize <= 0 {
+		return fmt.Errorf("%w: websocket buffer sizes must be positive", ErrInvalidConfig)
+	}
+	if c.WebSocket.MaxMessageSize <= 0 || c.WebSocket.SendBufferSize <= 0 {
+		return fmt.Errorf("%w: websocket.max_message_size and send_buffer_size must be positive", ErrInvalidConfig)
+	}
+	if c.WebSocket.CompressionThreshold < 0 || c.WebSocket.SyncChunkSize < 0 {
+		return fmt.Errorf("%w: websocket.compression_threshold and sync_chunk_size must not be negative", ErrInvalidConfig)
+	}
+	if c.WebSocket.HeartbeatInterval <= 0 || c.WebSocket.SlowClientTimeout < 0 {
+		return fmt.Errorf("%w: websocket.heartbeat_interval must be positive and slow_client_timeout not negative", ErrInvalidConfig)
+	}
+	if c.WebSocket.ResumeTTL < 0 {
+		return fmt.Errorf("%w: websocket.resume_ttl must not be negative", ErrInvalidConfig)
+	}
+	switch c.EventBus.Backend {
+	case EventBusNone:
+	case EventBusRedis, EventBusNATS:
+		if c.EventBus.Channel == "" {
+			return fmt.Errorf("%w: event_bus.channel is required", ErrInvalidConfig)
+		}
+		// Buses connect when used, so this only checks the url
+		if _, err := c.EventBus.NewBus(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+	default:
+		return fmt.Errorf("%w: unknown event_bus backend %q", ErrInvalidConfig, c.EventBus.Backend)
+	}
+	if c.Storage.Path == "" {
+		return fmt.Errorf("%w: storage path is required", ErrInvalidConfig)
+	}
+	if c.DocumentCache.MaxDocuments < 0 || c.DocumentCache.MaxBytes < 0 {
+		return fmt.Errorf("%w: document_cache limits must not be negative", ErrInvalidConfig)
+	}
+	if c.Operations.MaxContentSize <= 0 {
+		return fmt.Errorf("%w: operations.max_content_size must be positive", ErrInvalidConfig)
+	}
+	if c.Operations.MaxRequestSize <= 0 {
+		return fmt.Errorf("%w: operations.max_request_size must be positive", ErrInvalidConfig)
+	}
+	if c.ShutdownTimeout < 0 {
+		return fmt.Errorf("%w: shutdown_timeout must not be negative", ErrInvalidConfig)
+	}
+
+	if c.Replication.Interval <= 0 {
+		return fmt.Errorf("%w: replication.interval must be positive", ErrInvalidConfig)
+	}
+	for _, peer := range c.Replication.Peers {
+		if _, err := replication.NewHTTPPeer(peer, ""); err != nil {
+			return fmt.Errorf("%w: replication peer: %v", ErrInvalidConfig, err)
+		}
+	}
+	if c.Replication.MDNS {
+		if _, err := listenPort(c.Listen); err != nil {
+			return fmt.Errorf("%w: mdns needs a listen port: %v", ErrInvalidConfig, err)
+		}
+	}
+
+	if c.Backup.Interval < 0 {
+		return fmt.Errorf("%w: backup.interval must not be negative", ErrInvalidConfig)
+	}
+	if c.Storage.ReadOnly && (c.Replication.Enabled() || c.Backup.Interval > 0) {
+		return fmt.Errorf("%w: a read-only store can't gossip with replication peers or schedule backups", ErrInvalidConfig)
+	}
+	switch c.Storage.WhenClaimed {
+	case ClaimFail, ClaimShare:
+	case ClaimReadOnly:
+		if c.Replication.Enabled() || c.Backup.Interval > 0 {
+			return fmt.Errorf("%w: storage.when_claimed can't fall back to read-only with replication peers or scheduled backups", ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown storage.when_claimed %q", ErrInvalidConfig, c.Storage.WhenClaimed)
+	}
+	if c.Backup.Generations <= 0 {
+		return fmt.Errorf("%w: backup.generations must be positive", ErrInvalidConfig)
+	}
+
+	switch c.Embeddings.Provider {
+	case EmbeddingsDisabled, EmbeddingsHash:
+	case EmbeddingsHTTP:
+		if c.Embeddings.URL == "" || c.Embeddings.Model == "" {
+			return fmt.Errorf("%w: the http embeddings provider needs a url and a model", ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown embeddings provider %q", ErrInvalidConfig, c.Embeddings.Provider)
+	}
+	if c.Embeddings.Dimensions <= 0 {
+		return fmt.Errorf("%w: embeddings.dimensions must be positive", ErrInvalidConfig)
+	}
+	if c.Embeddings.Interval <= 0 {
+		return fmt.Errorf("%w: embeddings.interval must be positive", ErrInvalidConfig)
+	}
+	if c.Embeddings.BatchSize <= 0 {
+		return fmt.Errorf("%w: embeddings.batch_size must be positive", ErrInvalidConfig)
+	}
+
+	switch c.Summaries.Provider {
+	case SummariesDisabled, SummariesExtractive:
+	case SummariesHTTP:
+		if c.Summaries.URL == "" || c.Summaries.Model == "" {
+			return fmt.Errorf("%w: the http summaries provider needs a url and a model", ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown summaries provider %q", ErrInvalidConfig, c.Summaries.Provider)
+	}
+	if c.Summaries.Sentences <= 0 {
+		return fmt.Errorf("%w: summaries.sentences must be positive", ErrInvalidConfig)
+	}
+	if c.Summaries.MaxLength <= 0 {
+		return fmt.Errorf("%w: summaries.max_length must be positive", ErrInvalidConfig)
+	}
+
+	if c.Analysis.BurstyRate < 0 || c.Analysis.SteadyActiveRatio < 0 || c.Analysis.SteadyMinDays < 0 ||
+		c.Analysis.RefactoringRatio < 0 || c.Analysis.BugfixingRatio < 0 ||
+		c.Analysis.HotSpotMinOperations < 0 || c.Analysis.MaxHotSpots < 0 {
+		return fmt.Errorf("%w: analysis thresholds must not be negative", ErrInvalidConfig)
+	}
+
+	if c.ContextPacks.Budget <= 0 {
+		return fmt.Errorf("%w: context_packs.budget must be positive", ErrInvalidConfig)
+	}
+	if _, err := collaboration.ParseContextPackTemplates(c.ContextPacks.Templates); err != nil {
+		return fmt.Errorf("%w: context_packs: %v", ErrInvalidConfig, err)
+	}
+
+	if c.Auth.Lockout.MaxFailures < 0 {
+		return fmt.Errorf("%w: auth.lockout.max_failures must not be negative", ErrInvalidConfig)
+	}
+	if c.Auth.Lockout.MaxFailures > 0 && (c.Auth.Lockout.Window <= 0 || c.Auth.Lockout.Duration <= 0) {
+		return fmt.Errorf("%w: auth.lockout.window and duration must be positive", ErrInvalidConfig)
+	}
+	switch c.Auth.Mode {
+	case AuthModeDefault, AuthModeRequired, AuthModeOptional:
+	default:
+		return fmt.Errorf("%w: unknown auth mode %q", ErrInvalidConfig, c.Auth.Mode)
+	}
+
+	return nil
+}
+
+func listenPort(listen string) (int, error) {
+	_, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(port)
+}
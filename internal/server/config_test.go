@@ -0,0 +1,148 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "contextdb.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `
+listen: 0.0.0.0:9090
+cors:
+  allowed_origins: [https://example.com]
+websocket:
+  allowed_origins: [https://app.example.com]
+  compression: true
+auth:
+  mode: required
+shutdown_timeout: 5s
+replication:
+  peers: [http://team:8080]
+  mdns: true
+backup:
+  interval: 6h
+embeddings:
+  provider: hash
+summaries:
+  provider: extractive
+analysis:
+  bursty_rate: 20
+`)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if config.Listen != "0.0.0.0:9090" {
+		t.Errorf("Expected listen 0.0.0.0:9090, got %s", config.Listen)
+	}
+	if len(config.CORS.AllowedOrigins) != 1 || config.CORS.AllowedOrigins[0] != "https://example.com" {
+		t.Errorf("Unexpected CORS origins: %v", config.CORS.AllowedOrigins)
+	}
+	if len(config.WebSocket.AllowedOrigins) != 1 || !config.WebSocket.Compression ||
+		config.WebSocket.MaxMessageSize != DefaultConfig().WebSocket.MaxMessageSize {
+		t.Errorf("Expected compressed WebSocket from app.example.com with the default limits, got %+v", config.WebSocket)
+	}
+	if config.Auth.Mode != AuthModeRequired {
+		t.Errorf("Expected auth mode required, got %q", config.Auth.Mode)
+	}
+	if config.ShutdownTimeout != 5*time.Second {
+		t.Errorf("Expected 5s shutdown timeout, got %v", config.ShutdownTimeout)
+	}
+	if !config.Replication.Enabled() || config.Replication.Interval != DefaultConfig().Replication.Interval {
+		t.Errorf("Expected replication with the default interval, got %+v", config.Replication)
+	}
+	if config.Backup.Interval != 6*time.Hour || config.Backup.Generations != DefaultConfig().Backup.Generations {
+		t.Errorf("Expected 6h backups keeping the default generations, got %+v", config.Backup)
+	}
+	if !config.Embeddings.Enabled() || config.Embeddings.Interval != DefaultConfig().Embeddings.Interval {
+		t.Errorf("Expected hash embeddings with the default interval, got %+v", config.Embeddings)
+	}
+	if !config.Summaries.Enabled() || config.Summaries.NewSummarizer().Name() != "extractive-3" {
+		t.Errorf("Expected extractive summaries of the default length, got %+v", config.Summaries)
+	}
+	if config.Analysis.BurstyRate != 20 || config.Analysis.MaxHotSpots != DefaultConfig().Analysis.MaxHotSpots {
+		t.Errorf("Expected a bursty rate of 20 keeping the other thresholds, got %+v", config.Analysis)
+	}
+	// Keys missing from the file keep their defaults
+	if config.Storage.Path != DefaultConfig().Storage.Path {
+		t.Errorf("Expected default storage path, got %s", config.Storage.Path)
+	}
+}
+
+func TestLoadConfig_Empty(t *testing.T) {
+	config, err := LoadConfig(writeConfig(t, ""))
+	if err != nil {
+		t.Fatalf("Failed to load empty config: %v", err)
+	}
+	if config.Listen != DefaultConfig().Listen {
+		t.Errorf("Expected default listen address, got %s", config.Listen)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":       "listne: localhost:8080\n",
+		"unknown auth":      "auth:\n  mode: sometimes\n",
+		"incomplete tls":    "tls:\n  cert_file: server.crt\n",
+		"empty storage":     "storage:\n  path: \"\"\n",
+		"negative timeout":  "shutdown_timeout: -1s\n",
+		"zero compression":  "compression:\n  min_size: 0\n",
+		"xml access log":    "access_log:\n  format: xml\n",
+		"sample over one":   "access_log:\n  sample:\n    GET /healthz: 2\n",
+		"unknown log level": "logging:\n  level: loud\n",
+		"verbose component": "logging:\n  components:\n    api: verbose\n",
+		"unknown output":    "logging:\n  output: printer\n",
+		"file without path": "logging:\n  output: file\n",
+		"negative slow log": "logging:\n  slow_query_threshold: -1s\n",
+		"zero ws buffer":    "websocket:\n  read_buffer_size: 0\n",
+		"zero ws message":   "websocket:\n  max_message_size: 0\n",
+		"zero heartbeat":    "websocket:\n  heartbeat_interval: 0s\n",
+		"negative eviction": "websocket:\n  slow_client_timeout: -1s\n",
+		"negative resume":   "websocket:\n  resume_ttl: -1s\n",
+		"zero content size": "operations:\n  max_content_size: 0\n",
+		"zero request size": "operations:\n  max_request_size: 0\n",
+		"relative peer":     "replication:\n  peers: [team:8080]\n",
+		"zero interval":     "replication:\n  interval: 0s\n",
+		"no generations":    "backup:\n  generations: 0\n",
+		"negative backups":  "backup:\n  interval: -1h\n",
+		"read-only backups": "storage:\n  read_only: true\nbackup:\n  interval: 1h\n",
+		"unknown claim":     "storage:\n  when_claimed: steal\n",
+		"fallback backups":  "storage:\n  when_claimed: read_only\nbackup:\n  interval: 1h\n",
+		"unknown embedder":  "embeddings:\n  provider: magic\n",
+		"http without url":  "embeddings:\n  provider: http\n  model: text-embedding-3-small\n",
+		"zero batch size":   "embeddings:\n  provider: hash\n  batch_size: 0\n",
+		"unknown summary":   "summaries:\n  provider: magic\n",
+		"summaries no url":  "summaries:\n  provider: http\n  model: gpt-4o-mini\n",
+		"zero summary size": "summaries:\n  provider: extractive\n  max_length: 0\n",
+		"negative ratio":    "analysis:\n  steady_active_ratio: -0.5\n",
+		"zero pack budget":  "context_packs:\n  budget: 0\n",
+		"broken template":   "context_packs:\n  templates:\n    brief: \"{{.Code\"\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, content))
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if name != "unknown key" && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("Expected ErrInvalidConfig, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+const ConversationsFile = "conversations.json"
+
+// ConversationsPath is where conversations for the store under basePath are
+// kept between runs, since the conversation manager itself is in memory only
+func ConversationsPath(basePath string) string {
+	return filepath.Join(basePath, storage.ContextDir, ConversationsFile)
+}
+
+func LoadConversations(path string, manager *context.ConversationManager) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read conversations: %w", err)
+	}
+
+	var threads []*context.ConversationThread
+	if err := json.Unmarshal(data, &threads); err != nil {
+		return fmt.Errorf("failed to parse conversations: %w", err)
+	}
+
+	manager.Restore(threads)
+	return nil
+}
+
+func SaveConversations(path string, manager *context.ConversationManager) error {
+	threads := manager.Snapshot()
+	if len(threads) == 0 {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+	}
+
+	data, err := json.MarshalIndent(threads, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,8 @@
+package server
+
+import "errors"
+
+var (
+	ErrInvalidConfig   = errors.New("invalid config")
+	ErrRestartRequired = errors.New("change requires a restart")
+)
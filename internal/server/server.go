@@ -0,0 +1,550 @@
+// Package server wires storage, the collaboration engine, auth and the HTTP
+// API into one process with a config file, reload and graceful shutdown.
+package server
+
+import (
+	gocontext "context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/jeremytregunna/contextdb/internal/api"
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/backup"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/embeddings"
+	"github.com/jeremytregunna/contextdb/internal/events"
+	"github.com/jeremytregunna/contextdb/internal/jobs"
+	"github.com/jeremytregunna/contextdb/internal/logging"
+	"github.com/jeremytregunna/contextdb/internal/replication"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+	"github.com/jeremytregunna/contextdb/internal/summaries"
+	"github.com/jeremytregunna/contextdb/internal/webhooks"
+)
+
+type Server struct {
+	config     Config
+	store      *storage.ContextStore
+	engine     *collaboration.CollaborationEngine
+	auth       *auth.AuthManager
+	api        *api.APIServer
+	webhooks   *webhooks.Manager
+	node       *replication.Node
+	backups    *backup.Manager
+	embeddings *embeddings.Indexer
+	summaries  *summaries.Service
+	jobs       *jobs.Scheduler
+	httpServer *http.Server
+	cert       *tls.Certificate
+	logger     *logging.Logger
+	logOutput  io.Closer
+	closeOnce  sync.Once
+	closeErr   error
+	mutex      sync.RWMutex
+}
+
+// New opens the store at config.Storage.Path and builds everything needed to
+// serve it. Callers that never Run the server must still Close it.
+func New(config Config) (_ *Server, err error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Logging is set up first, so everything below logs where it should
+	output, logOutput, err := config.Logging.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log output: %w", err)
+	}
+	logging.SetOutput(output)
+	config.Logging.Apply()
+	defer func() {
+		if err != nil {
+			closeLogOutput(logOutput)
+		}
+	}()
+
+	logger := logging.NewLogger("server")
+	store, err := openStore(config, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open context store: %w", err)
+	}
+
+	authManager, err := auth.NewAuthManager(config.Storage.Path)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to open auth config: %w", err)
+	}
+	authManager.Attempts().SetPolicy(config.Auth.Lockout.Policy())
+
+	engine := collaboration.NewCollaborationEngine(store)
+	engine.ContextAnalyzer().SetPatternThresholds(config.Analysis.Thresholds())
+	engine.SetWebSocketConfig(config.WebSocket.Engine())
+	engine.SetDocumentCacheConfig(config.DocumentCache.Engine())
+	if err := engine.SetContextPackConfig(config.ContextPacks.Engine()); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if err := engine.LoadIntentTaxonomy(gocontext.Background()); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to load intent taxonomy: %w", err)
+	}
+	if err := engine.LoadAuthorAliases(gocontext.Background()); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to load author aliases: %w", err)
+	}
+	if err := LoadConversations(ConversationsPath(config.Storage.Path), engine.ConversationManager()); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	webhookManager, err := webhooks.NewManager(config.Storage.Path)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	engine.Events().Subscribe(webhookManager.Handle)
+
+	node, err := replication.NewNode(config.Storage.Path, engine, store)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	s := &Server{
+		config:   config,
+		store:    store,
+		engine:   engine,
+		auth:     authManager,
+		webhooks: webhookManager,
+		node:     node,
+		logger:   logger,
+	}
+	s.logOutput = logOutput
+	s.backups = backup.NewManager(config.Storage.Path, store,
+		backup.WithGenerations(config.Backup.Generations),
+		// Conversations live in memory until saved, so save them to be backed up too
+		backup.WithBeforeBackup(func() error {
+			return SaveConversations(ConversationsPath(config.Storage.Path), engine.ConversationManager())
+		}),
+	)
+
+	// Jobs record their runs in the store, and most write to it besides
+	if !store.ReadOnly() {
+		if err := s.registerJobs(config); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+
+	// Other instances serving the store hear of this one's updates, and it of theirs
+	bus, err := config.EventBus.NewBus()
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if bus != nil {
+		if err := engine.SetEventBus(bus); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+
+	apiOptions := []api.ServerOption{
+		api.WithCORSOrigins(config.CORS.AllowedOrigins),
+		api.WithMaxContentSize(config.Operations.MaxContentSize),
+		api.WithMaxRequestSize(config.Operations.MaxRequestSize),
+		api.WithWebhooks(webhookManager),
+		api.WithReplication(node),
+		api.WithBackups(s.backups),
+		api.WithJobs(s.jobs),
+		api.WithTrustedForwardedFor(config.Auth.TrustForwardedFor),
+	}
+	if config.Compression.Enabled {
+		apiOptions = append(apiOptions, api.WithCompression(config.Compression.MinSize))
+	}
+	if config.AccessLog.Enabled {
+		apiOptions = append(apiOptions, api.WithAccessLog(config.AccessLog.Logger(), config.AccessLog.Sample))
+	}
+	if config.Embeddings.Enabled() {
+		s.embeddings = embeddings.NewIndexer(config.Embeddings.NewProvider(), store, engine.ConversationManager(),
+			embeddings.WithBatchSize(config.Embeddings.BatchSize))
+		// Messages added to existing threads publish no event and wait for the next interval
+		engine.Events().Subscribe(func(event events.Event) {
+			if event.Type == events.OperationCreated || event.Type == events.ConversationCreated {
+				s.embeddings.Notify()
+			}
+		})
+		apiOptions = append(apiOptions, api.WithEmbeddings(s.embeddings))
+	}
+	if config.Summaries.Enabled() {
+		s.summaries = summaries.NewService(config.Summaries.NewSummarizer(), engine.ConversationManager(),
+			summaries.WithMaxLength(config.Summaries.MaxLength))
+		s.summaries.Subscribe(engine.Events())
+		apiOptions = append(apiOptions, api.WithSummaries(s.summaries))
+	}
+
+	s.api = api.NewAPIServer(
+		engine,
+		store,
+		store,
+		engine.AddressResolver(),
+		engine.ConversationManager(),
+		engine.ContextAnalyzer(),
+		authManager,
+		apiOptions...,
+	)
+
+	if err := s.applyAuthMode(config.Auth.Mode); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	if config.TLS.Enabled() {
+		if err := s.loadCertificate(config.TLS); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// openStore opens the store as configured, claiming it unless read-only.
+// When another server holds the claim, config.Storage.WhenClaimed decides
+// whether to fail, share it, or fall back to read-only.
+func openStore(config Config, logger *logging.Logger) (*storage.ContextStore, error) {
+	switch {
+	case config.Storage.ReadOnly:
+		return storage.OpenContextStoreReadOnly(config.Storage.Path)
+	case config.Storage.WhenClaimed == ClaimShare:
+		return storage.NewContextStore(config.Storage.Path)
+	}
+
+	store, err := storage.ClaimContextStore(config.Storage.Path)
+	if errors.Is(err, storage.ErrStoreInUse) && config.Storage.WhenClaimed == ClaimReadOnly {
+		logger.Warn("Store is served by another instance, serving it read-only", map[string]interface{}{"error": err.Error()})
+		return storage.OpenContextStoreReadOnly(config.Storage.Path)
+	}
+	return store, err
+}
+
+// registerJobs schedules the server's periodic work. Backups with no
+// interval configured run only when triggered.
+func (s *Server) registerJobs(config Config) error {
+	s.jobs = jobs.NewScheduler(s.store)
+	if err := s.jobs.Register("backup", jobs.Every(config.Backup.Interval), func(ctx gocontext.Context) error {
+		_, err := s.backups.Create(ctx)
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := s.jobs.Register("retention", s.engine.RetentionInterval, func(ctx gocontext.Context) error {
+		_, err := s.engine.EnforceRetention(ctx, false)
+		return err
+	}); err != nil {
+		return err
+	}
+	// Only runs when started, over everything unless the API narrows it
+	return s.jobs.Register(api.ReanalysisJob, jobs.Every(0), func(ctx gocontext.Context) error {
+		_, err := s.engine.ReanalyzeIntents(ctx, collaboration.ReanalysisQuery{
+			Progress: func(done, total int) { jobs.ReportProgress(ctx, done, total) },
+		})
+		return err
+	})
+}
+
+func (s *Server) Store() *storage.ContextStore {
+	return s.store
+}
+
+func (s *Server) Engine() *collaboration.CollaborationEngine {
+	return s.engine
+}
+
+func (s *Server) Auth() *auth.AuthManager {
+	return s.auth
+}
+
+func (s *Server) Backups() *backup.Manager {
+	return s.backups
+}
+
+func (s *Server) Jobs() *jobs.Scheduler {
+	return s.jobs
+}
+
+// Node is the server's side of replication with other ContextDB nodes
+func (s *Server) Node() *replication.Node {
+	return s.node
+}
+
+func (s *Server) Handler() http.Handler {
+	return s.api
+}
+
+func (s *Server) Config() Config {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.config
+}
+
+// Run serves until ctx is cancelled, then shuts down gracefully within the
+// configured timeout and closes the server
+func (s *Server) Run(ctx gocontext.Context) error {
+	config := s.Config()
+
+	listener, err := net.Listen("tcp", config.Listen)
+	if err != nil {
+		s.Close()
+		return fmt.Errorf("failed to listen on %s: %w", config.Listen, err)
+	}
+
+	s.httpServer = &http.Server{Handler: s.api}
+	if config.TLS.Enabled() {
+		// Certificates are looked up per handshake so a reload can rotate them
+		listener = tls.NewListener(listener, &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				s.mutex.RLock()
+				defer s.mutex.RUnlock()
+				return s.cert, nil
+			},
+		})
+	}
+
+	// Gossip, scheduled jobs and embedding stop before the engine shuts
+	// down so no sync or backup is cut off midway
+	backgroundCtx, stopBackground := gocontext.WithCancel(ctx)
+	var background sync.WaitGroup
+	if config.Replication.Enabled() {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			s.gossip(config).Run(backgroundCtx)
+		}()
+	}
+	if s.jobs != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			s.jobs.Run(backgroundCtx)
+		}()
+	}
+	// A read-only server searches the embeddings already stored
+	if s.embeddings != nil && !s.store.ReadOnly() {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			s.embeddings.Run(backgroundCtx, config.Embeddings.Interval)
+		}()
+	}
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		s.auth.Run(backgroundCtx, auth.DefaultFlushInterval)
+	}()
+	// Resolving threads, and so summarizing them, needs a writable store
+	if s.summaries != nil && !s.store.ReadOnly() {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			s.summaries.Run(backgroundCtx)
+		}()
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.httpServer.Serve(listener)
+	}()
+	s.logger.Info("Server listening", map[string]interface{}{
+		"addr": listener.Addr().String(),
+		"tls":  config.TLS.Enabled(),
+	})
+
+	select {
+	case err := <-errs:
+		stopBackground()
+		background.Wait()
+		s.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("Shutting down")
+	stopBackground()
+	background.Wait()
+	shutdownCtx, cancel := s.shutdownContext()
+	defer cancel()
+
+	shutdownErr := s.httpServer.Shutdown(shutdownCtx)
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) && shutdownErr == nil {
+		shutdownErr = err
+	}
+
+	if err := s.shutdown(shutdownCtx); shutdownErr == nil {
+		shutdownErr = err
+	}
+	return shutdownErr
+}
+
+// Reload applies the settings that can change without restarting: CORS
+// origins, WebSocket settings for new connections, auth mode and lockouts, TLS
+// certificates and analysis thresholds. Changes to the listen address,
+// storage settings, operation limits, replication, backups, embeddings,
+// summaries or event bus are reported with ErrRestartRequired and otherwise
+// ignored.
+func (s *Server) Reload(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	current := s.Config()
+
+	if config.TLS.Enabled() != current.TLS.Enabled() {
+		return fmt.Errorf("%w: enabling or disabling tls", ErrRestartRequired)
+	}
+	if config.TLS.Enabled() {
+		if err := s.loadCertificate(config.TLS); err != nil {
+			return err
+		}
+	}
+
+	if err := s.applyAuthMode(config.Auth.Mode); err != nil {
+		return err
+	}
+	config.Logging.Apply()
+	s.auth.Attempts().SetPolicy(config.Auth.Lockout.Policy())
+	s.api.SetTrustForwardedFor(config.Auth.TrustForwardedFor)
+	s.api.SetCORSOrigins(config.CORS.AllowedOrigins)
+	s.engine.SetWebSocketConfig(config.WebSocket.Engine())
+	s.engine.SetDocumentCacheConfig(config.DocumentCache.Engine())
+	s.engine.ContextAnalyzer().SetPatternThresholds(config.Analysis.Thresholds())
+	if err := s.engine.SetContextPackConfig(config.ContextPacks.Engine()); err != nil {
+		return err
+	}
+
+	var restartErr error
+	if config.Listen != current.Listen || config.Storage != current.Storage ||
+		config.Operations != current.Operations || !reflect.DeepEqual(config.Replication, current.Replication) ||
+		config.Backup != current.Backup || config.Embeddings != current.Embeddings || config.Summaries != current.Summaries ||
+		config.EventBus != current.EventBus || config.Compression != current.Compression || !reflect.DeepEqual(config.AccessLog, current.AccessLog) ||
+		config.Logging.Output != current.Logging.Output || config.Logging.File != current.Logging.File || config.Logging.Syslog != current.Logging.Syslog {
+		restartErr = fmt.Errorf("%w: listen address, storage settings, operation limits, replication, backups, embeddings, summaries, event bus, compression, access log or log output", ErrRestartRequired)
+		config.Listen = current.Listen
+		config.Storage = current.Storage
+		config.Operations = current.Operations
+		config.Replication = current.Replication
+		config.Backup = current.Backup
+		config.Embeddings = current.Embeddings
+		config.Summaries = current.Summaries
+		config.EventBus = current.EventBus
+		config.Compression = current.Compression
+		config.AccessLog = current.AccessLog
+		config.Logging.Output = current.Logging.Output
+		config.Logging.File = current.Logging.File
+		config.Logging.Syslog = current.Logging.Syslog
+	}
+
+	s.mutex.Lock()
+	s.config = config
+	s.mutex.Unlock()
+
+	s.logger.Info("Configuration reloaded")
+	return restartErr
+}
+
+// gossip builds the loop that keeps this node in sync with its peers
+func (s *Server) gossip(config Config) *replication.Gossip {
+	opts := []replication.GossipOption{
+		replication.WithStaticPeers(config.Replication.Peers...),
+		replication.WithPeerAPIKey(config.Replication.APIKey),
+		replication.WithGossipInterval(config.Replication.Interval),
+	}
+	if config.Replication.MDNS {
+		// Validate made sure the listen address has a port
+		port, _ := listenPort(config.Listen)
+		opts = append(opts, replication.WithMDNS(replication.NewMDNS(replication.Advertisement{
+			NodeID: s.node.ID(),
+			Port:   port,
+			TLS:    config.TLS.Enabled(),
+			URL:    config.Replication.Advertise,
+		})))
+	}
+	return replication.NewGossip(s.node, opts...)
+}
+
+// Close drains the API and engine within the configured shutdown timeout,
+// saves conversations and closes the store. It is safe to call more than once.
+func (s *Server) Close() error {
+	ctx, cancel := s.shutdownContext()
+	defer cancel()
+
+	return s.shutdown(ctx)
+}
+
+// shutdownContext bounds shutdown by the configured timeout, zero waits for as long as it takes
+func (s *Server) shutdownContext() (gocontext.Context, gocontext.CancelFunc) {
+	if timeout := s.Config().ShutdownTimeout; timeout > 0 {
+		return gocontext.WithTimeout(gocontext.Background(), timeout)
+	}
+	return gocontext.WithCancel(gocontext.Background())
+}
+
+func (s *Server) shutdown(ctx gocontext.Context) error {
+	s.closeOnce.Do(func() {
+		// The engine closes the store once its operations and clients are done
+		shutdownErr := s.api.Shutdown(ctx)
+		var saveErr error
+		if !s.store.ReadOnly() {
+			saveErr = SaveConversations(ConversationsPath(s.config.Storage.Path), s.engine.ConversationManager())
+		}
+		// Requests are done, so nothing is metered after this
+		authErr := s.auth.Flush()
+		// Nothing publishes once the engine is down, so queued deliveries get the rest of ctx
+		webhooksErr := s.webhooks.Close(ctx)
+		s.closeErr = errors.Join(shutdownErr, saveErr, authErr, webhooksErr)
+		closeLogOutput(s.logOutput)
+	})
+	return s.closeErr
+}
+
+// closeLogOutput returns logging to the standard library's logger before
+// closing the output it was sent to, if that needs closing
+func closeLogOutput(output io.Closer) {
+	logging.SetOutput(nil)
+	if output != nil {
+		output.Close()
+	}
+}
+
+func (s *Server) applyAuthMode(mode AuthMode) error {
+	switch mode {
+	case AuthModeRequired:
+		if !s.auth.IsAuthRequired() {
+			return s.auth.EnableAuth()
+		}
+	case AuthModeOptional:
+		if s.auth.IsAuthRequired() {
+			return s.auth.DisableAuth()
+		}
+	}
+	return nil
+}
+
+func (s *Server) loadCertificate(config TLSConfig) error {
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+
+	s.mutex.Lock()
+	s.cert = &cert
+	s.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,546 @@
+package simulation
+
+import (
+	gocontext "context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/pkg/client"
+)
+
+const (
+	// eventBuffer is how many messages may be on their way to or from a
+	// client at once
+	eventBuffer = 1024
+	// maxErrors is how many of its errors a client keeps for the report
+	maxErrors = 10
+	// checkInterval is how often a client looks for having caught up
+	checkInterval = 10 * time.Millisecond
+)
+
+// virtualClient follows every document of a simulation through a connection
+// of its own, keeping a replica of each that it edits and applies the others'
+// operations to, as an editor would. Only its run loop touches its state;
+// the goroutines sending and receiving for it hand messages over channels.
+type virtualClient struct {
+	sim       *simulation
+	author    operations.AuthorID
+	api       *client.Client
+	rng       *rand.Rand
+	clock     *operations.HybridClock
+	allocator *operations.Allocator
+	replicas  map[string]*replica
+	made      int
+
+	// conn is nil while the client is away. What it receives waits in
+	// arrivals for the message's latency to pass, and what it sends waits
+	// in outbox.
+	conn        *client.Conn
+	token       string
+	connections int
+	events      chan event
+	arrivals    []event
+	outbox      chan outgoing
+	lastSent    time.Time
+	// pending are the operations sent and not yet acknowledged, in order
+	pending []*operations.Operation
+	// leaving is set once the client means to disconnect, and closing once
+	// it has asked the server to
+	leaving  bool
+	closing  bool
+	stopped  bool
+	settling bool
+
+	disconnects int
+	resumed     int
+	resynced    int
+	rejected    int
+	errors      []string
+
+	// edited is closed once the client has stopped editing and had its
+	// edits acknowledged, and settled once it has caught up with everyone's
+	edited      chan struct{}
+	settled     chan struct{}
+	editedOnce  sync.Once
+	settledOnce sync.Once
+	done        chan struct{}
+	io          sync.WaitGroup
+}
+
+// replica is a client's copy of a document
+type replica struct {
+	doc     *positioning.Document
+	applied map[operations.OperationID]bool
+	heads   []operations.OperationID
+	// syncing is set from subscribing until the document arrives. Operations
+	// received meanwhile are held, to apply on top of it.
+	syncing bool
+	held    []*operations.Operation
+}
+
+// event is a message received, or the connection ending with err, to be
+// handled at
+type event struct {
+	msg *client.Message
+	err error
+	at  time.Time
+}
+
+// outgoing is an operation to send, or a subscription to documentID when op
+// is nil, to be sent at
+type outgoing struct {
+	op         *operations.Operation
+	documentID string
+	at         time.Time
+}
+
+func (s *simulation) newClient(n int, baseURL string) (*virtualClient, error) {
+	author := operations.AuthorID(fmt.Sprintf("simulated-%d", n))
+	api, err := client.New(baseURL, client.WithAPIKey(string(author)))
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewPCG(s.config.Seed, uint64(n)))
+	c := &virtualClient{
+		sim:       s,
+		author:    author,
+		api:       api,
+		rng:       rng,
+		clock:     operations.NewHybridClock(),
+		allocator: operations.NewAllocator(rand.NewPCG(rng.Uint64(), rng.Uint64())),
+		replicas:  make(map[string]*replica, len(s.documents)),
+		events:    make(chan event, eventBuffer),
+		edited:    make(chan struct{}),
+		settled:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, documentID := range s.documents {
+		c.replicas[documentID] = &replica{
+			doc:     positioning.NewDocument(documentID),
+			applied: make(map[operations.OperationID]bool),
+		}
+	}
+	return c, nil
+}
+
+// run edits until the simulation stops the edits, then waits to be told to
+// stop once it has caught up
+func (c *virtualClient) run(ctx gocontext.Context) {
+	defer c.finish()
+	if err := c.connect(ctx); err != nil {
+		c.errorf("failed to connect: %v", err)
+		return
+	}
+
+	config := c.sim.config
+	edit := time.NewTimer(around(c.rng, config.EditInterval))
+	defer edit.Stop()
+	churn := time.NewTimer(0)
+	churn.Stop()
+	if config.Churn > 0 {
+		churn.Reset(around(c.rng, config.Churn))
+	}
+	defer churn.Stop()
+	rejoin := time.NewTimer(0)
+	rejoin.Stop()
+	defer rejoin.Stop()
+	arrival := time.NewTimer(0)
+	arrival.Stop()
+	defer arrival.Stop()
+	check := time.NewTicker(checkInterval)
+	defer check.Stop()
+
+	stop, settling := c.sim.stop, c.sim.settling
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-c.events:
+			c.arrivals = append(c.arrivals, ev)
+		case <-arrival.C:
+		case <-edit.C:
+			if !c.stopped {
+				c.edit()
+				edit.Reset(around(c.rng, config.EditInterval))
+			}
+		case <-churn.C:
+			if !c.stopped && c.conn != nil {
+				c.leaving = true
+			}
+		case <-rejoin.C:
+			if err := c.connect(ctx); err != nil {
+				c.errorf("failed to reconnect: %v", err)
+				return
+			}
+			if config.Churn > 0 && !c.stopped {
+				churn.Reset(around(c.rng, config.Churn))
+			}
+		case <-stop:
+			stop = nil
+			c.stopped = true
+			if c.conn == nil {
+				rejoin.Reset(0)
+			}
+		case <-settling:
+			settling = nil
+			c.settling = true
+		case <-check.C:
+		}
+
+		now := time.Now()
+		for len(c.arrivals) > 0 && !c.arrivals[0].at.After(now) {
+			ev := c.arrivals[0]
+			c.arrivals = c.arrivals[1:]
+			if c.handle(ev) {
+				// Back straight away once the edits are over, so as to
+				// catch up
+				if c.stopped {
+					rejoin.Reset(0)
+				} else {
+					rejoin.Reset(around(c.rng, config.Offline))
+				}
+			}
+		}
+		if len(c.arrivals) > 0 {
+			arrival.Reset(c.arrivals[0].at.Sub(now))
+		}
+
+		if c.stopped && !c.closing {
+			c.leaving = false
+		}
+		if c.leaving && !c.closing && len(c.pending) == 0 {
+			c.closing = true
+			if err := c.conn.Disconnect(); err != nil {
+				c.errorf("failed to disconnect: %v", err)
+			}
+		}
+		if c.stopped && len(c.pending) == 0 {
+			c.editedOnce.Do(func() { close(c.edited) })
+		}
+		if c.settling && c.caughtUp() {
+			c.settledOnce.Do(func() { close(c.settled) })
+		}
+	}
+}
+
+// connect opens a connection, resuming the last session when there was one.
+// Documents are subscribed to again unless the session was resumed.
+func (c *virtualClient) connect(ctx gocontext.Context) error {
+	var conn *client.Conn
+	var err error
+	if c.token != "" {
+		conn, err = c.api.Resume(ctx, c.token)
+	} else {
+		conn, err = c.api.Connect(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.conn, c.token = conn, conn.ResumeToken()
+	c.leaving, c.closing = false, false
+	c.outbox = make(chan outgoing, eventBuffer)
+	c.io.Add(2)
+	go c.receive(conn)
+	go c.send(conn, c.outbox)
+
+	c.connections++
+	if conn.Resumed() {
+		c.resumed++
+		return nil
+	}
+	if c.connections > 1 {
+		c.resynced++
+	}
+	for _, documentID := range c.sim.documents {
+		r := c.replicas[documentID]
+		r.syncing, r.held = true, nil
+		c.queue(outgoing{documentID: documentID})
+	}
+	return nil
+}
+
+// queue sends out after its latency, behind what was queued before
+func (c *virtualClient) queue(out outgoing) {
+	out.at = time.Now().Add(c.sim.delay())
+	if out.at.Before(c.lastSent) {
+		out.at = c.lastSent
+	}
+	c.lastSent = out.at
+	c.outbox <- out
+}
+
+// send writes what is queued on conn, each once its latency has passed. A
+// failed write ends the connection, which receive reports.
+func (c *virtualClient) send(conn *client.Conn, outbox <-chan outgoing) {
+	defer c.io.Done()
+	for out := range outbox {
+		timer := time.NewTimer(time.Until(out.at))
+		select {
+		case <-timer.C:
+		case <-c.done:
+			timer.Stop()
+			return
+		}
+		if out.op == nil {
+			conn.Subscribe(out.documentID, 0)
+		} else {
+			conn.SendOperation(out.op, out.documentID)
+		}
+	}
+}
+
+// receive hands what conn receives to the run loop, stamped with when its
+// latency will have passed, until the connection ends
+func (c *virtualClient) receive(conn *client.Conn) {
+	defer c.io.Done()
+	var last time.Time
+	for {
+		msg, err := conn.Receive()
+		at := time.Now().Add(c.sim.delay())
+		if at.Before(last) {
+			at = last
+		}
+		last = at
+		select {
+		case c.events <- event{msg: msg, err: err, at: at}:
+		case <-c.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handle applies a received message, reporting true when it was the
+// connection ending
+func (c *virtualClient) handle(ev event) bool {
+	if ev.err != nil {
+		c.disconnected(ev.err)
+		return true
+	}
+
+	switch ev.msg.Type {
+	case client.MsgOperation:
+		var payload client.OperationPayload
+		if err := client.DecodePayload(ev.msg, &payload); err != nil {
+			c.errorf("failed to decode operation: %v", err)
+			return false
+		}
+		r, ok := c.replicas[payload.DocumentID]
+		if !ok || payload.Operation == nil {
+			return false
+		}
+		if r.syncing {
+			r.held = append(r.held, payload.Operation)
+			return false
+		}
+		c.apply(r, payload.Operation, ev.at)
+
+	case client.MsgAcknowledgment:
+		var ack client.AckPayload
+		if err := client.DecodePayload(ev.msg, &ack); err != nil {
+			c.errorf("failed to decode acknowledgment: %v", err)
+			return false
+		}
+		if len(c.pending) == 0 {
+			c.errorf("acknowledgment of nothing sent: %+v", ack)
+			return false
+		}
+		// The server handles a connection's messages in order
+		op := c.pending[0]
+		c.pending = c.pending[1:]
+		if !ack.Success {
+			c.rejected++
+			c.errorf("operation %s rejected: %s", op.ID, ack.Error)
+			return false
+		}
+		if ack.OperationID != op.ID {
+			c.errorf("operation %s acknowledged as %s", op.ID, ack.OperationID)
+		}
+		c.sim.accept(op.Metadata.DocumentID, op.ID, ev.at)
+
+	case client.MsgSync:
+		var payload client.SyncPayload
+		if err := client.DecodePayload(ev.msg, &payload); err != nil {
+			c.errorf("failed to decode sync: %v", err)
+			return false
+		}
+		if r, ok := c.replicas[payload.DocumentID]; ok {
+			c.synced(r, &payload, ev.at)
+		}
+
+	case client.MsgError:
+		var payload client.ErrorPayload
+		client.DecodePayload(ev.msg, &payload)
+		c.errorf("server error %s: %s", payload.Code, payload.Message)
+	}
+	return false
+}
+
+// disconnected closes a connection that has ended. Ending without the client
+// asking means operations still pending may have been lost.
+func (c *virtualClient) disconnected(err error) {
+	c.conn.Close()
+	close(c.outbox)
+	c.conn = nil
+	c.disconnects++
+	if !c.closing {
+		c.errorf("connection dropped with %d operations unacknowledged: %v", len(c.pending), err)
+	}
+	c.pending = nil
+	c.leaving, c.closing = false, false
+}
+
+// edit makes an operation on a random document: usually an insert between
+// two neighbouring constructs, otherwise, as often as DeleteRatio says, a
+// delete of one
+func (c *virtualClient) edit() {
+	if c.conn == nil || c.leaving {
+		return
+	}
+	documentID := c.sim.documents[c.rng.IntN(len(c.sim.documents))]
+	r := c.replicas[documentID]
+	if r.syncing {
+		return
+	}
+
+	c.made++
+	positions := r.doc.Positions()
+	op := &operations.Operation{
+		Author:    c.author,
+		Timestamp: time.Now(),
+		Clock:     c.clock.Now(),
+		Parents:   slices.Clone(r.heads),
+		Metadata:  operations.OperationMeta{DocumentID: documentID},
+	}
+	if len(positions) > 0 && c.rng.Float64() < c.sim.config.DeleteRatio {
+		op.Type = operations.OpDelete
+		op.Position = positions[c.rng.IntN(len(positions))]
+	} else {
+		var left, right operations.LogootPosition
+		gap := c.rng.IntN(len(positions) + 1)
+		if gap > 0 {
+			left = positions[gap-1]
+		}
+		if gap < len(positions) {
+			right = positions[gap]
+		}
+		op.Type = operations.OpInsert
+		op.Position = c.allocator.Between(left, right, c.author)
+		op.Content = fmt.Sprintf("%s made edit %d\n", c.author, c.made)
+	}
+	op.ID = operations.ComputeID(op)
+
+	if err := r.doc.ApplyOperation(op); err != nil {
+		c.errorf("failed to apply own operation %s: %v", op.ID, err)
+		return
+	}
+	r.applied[op.ID] = true
+	r.advance(op)
+	c.sim.sent(op.ID, op.Timestamp)
+	c.pending = append(c.pending, op)
+	// The sender fills in what the client leaves out, so it's given a copy
+	sent := *op
+	c.queue(outgoing{op: &sent, documentID: documentID})
+
+	if ops := c.sim.config.Operations; ops > 0 && c.made >= ops {
+		c.stopped = true
+	}
+}
+
+// apply applies another client's operation to r
+func (c *virtualClient) apply(r *replica, op *operations.Operation, at time.Time) {
+	if r.applied[op.ID] {
+		return
+	}
+	if err := c.clock.Observe(op.Clock); err != nil {
+		c.errorf("failed to observe clock of %s: %v", op.ID, err)
+	}
+	if err := r.doc.ApplyOperation(op); err != nil {
+		c.errorf("failed to apply %s: %v", op.ID, err)
+		return
+	}
+	r.applied[op.ID] = true
+	r.advance(op)
+	c.sim.received(op.ID, at)
+}
+
+// synced replaces r with the document a sync brought, then applies what
+// arrived while waiting for it. Operations are only known to follow the
+// document's last operation.
+func (c *virtualClient) synced(r *replica, payload *client.SyncPayload, at time.Time) {
+	doc := payload.CurrentState
+	if doc == nil {
+		doc = positioning.NewDocument(payload.DocumentID)
+	}
+	r.doc = doc
+	r.applied = make(map[operations.OperationID]bool)
+	for _, id := range doc.Snapshot().AppliedOps {
+		r.applied[id] = true
+	}
+	r.heads = nil
+	if doc.LastOperation != "" {
+		r.heads = []operations.OperationID{doc.LastOperation}
+	}
+
+	r.syncing = false
+	held := r.held
+	r.held = nil
+	for _, op := range append(payload.Operations, held...) {
+		c.apply(r, op, at)
+	}
+}
+
+// advance makes op the head of the operations r has seen, in place of its
+// parents
+func (r *replica) advance(op *operations.Operation) {
+	heads := r.heads[:0]
+	for _, head := range r.heads {
+		if !slices.Contains(op.Parents, head) {
+			heads = append(heads, head)
+		}
+	}
+	r.heads = append(heads, op.ID)
+}
+
+// caughtUp reports whether the client is connected with nothing
+// outstanding, having applied every operation the engine accepted
+func (c *virtualClient) caughtUp() bool {
+	if c.conn == nil || c.leaving || len(c.pending) > 0 {
+		return false
+	}
+	for _, documentID := range c.sim.documents {
+		r := c.replicas[documentID]
+		if r.syncing || c.sim.missing(documentID, r.applied) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// finish closes the connection and waits for the goroutines using it. The
+// run is over, so the client counts as done with each stage.
+func (c *virtualClient) finish() {
+	close(c.done)
+	if c.conn != nil {
+		c.conn.Close()
+		close(c.outbox)
+	}
+	c.io.Wait()
+	c.editedOnce.Do(func() { close(c.edited) })
+	c.settledOnce.Do(func() { close(c.settled) })
+}
+
+func (c *virtualClient) errorf(format string, args ...interface{}) {
+	if len(c.errors) < maxErrors {
+		c.errors = append(c.errors, fmt.Sprintf("%s: ", c.author)+fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,159 @@
+package simulation
+
+import (
+	gocontext "context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// Report is what a simulation found
+type Report struct {
+	Config Config `json:"config"`
+	// Elapsed is how long the clients took to make their edits and have them
+	// acknowledged
+	Elapsed time.Duration `json:"elapsed_ns"`
+	// Operations counts the edits the engine accepted, Rejected those it
+	// refused
+	Operations int `json:"operations"`
+	Rejected   int `json:"rejected"`
+	// Disconnects counts clients leaving. Coming back, each either Resumed
+	// its session or synced its documents again, Resynced.
+	Disconnects int `json:"disconnects"`
+	Resumed     int `json:"resumed"`
+	Resynced    int `json:"resynced"`
+	// Acknowledged is how long edits took from being made to being
+	// acknowledged to their clients, Propagated to being applied by each
+	// other client. Edits another client only had from a sync aren't timed.
+	Acknowledged Latency          `json:"acknowledged"`
+	Propagated   Latency          `json:"propagated"`
+	Documents    []DocumentReport `json:"documents"`
+	// Errors are the first few things to go wrong for each client
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Latency summarizes how long something took across a simulation
+type Latency struct {
+	Samples int           `json:"samples"`
+	P50     time.Duration `json:"p50_ns"`
+	P99     time.Duration `json:"p99_ns"`
+	Max     time.Duration `json:"max_ns"`
+}
+
+// DocumentReport compares the clients' replicas of a document with the
+// engine's, whose rendering is Size bytes
+type DocumentReport struct {
+	DocumentID string       `json:"document_id"`
+	Size       int          `json:"size"`
+	Divergent  []Divergence `json:"divergent,omitempty"`
+}
+
+// Divergence is a client whose replica differs from the engine's document.
+// Missing counts the operations the engine accepted that the client never
+// applied, and Offset is where its rendering first differs, -1 when it
+// doesn't.
+type Divergence struct {
+	Client  operations.AuthorID `json:"client"`
+	Missing int                 `json:"missing"`
+	Offset  int                 `json:"offset"`
+}
+
+// Converged reports whether every client ended up with every document as the
+// engine has it
+func (r *Report) Converged() bool {
+	for _, document := range r.Documents {
+		if len(document.Divergent) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) String() string {
+	diverged := 0
+	for _, document := range r.Documents {
+		diverged += len(document.Divergent)
+	}
+	return fmt.Sprintf("%d operations (%d rejected) from %d clients in %v, %d disconnects (%d resumed, %d resynced); "+
+		"acknowledged p50 %v p99 %v, propagated p50 %v p99 %v; %d of %d replicas diverged",
+		r.Operations, r.Rejected, r.Config.Clients, r.Elapsed.Round(time.Millisecond), r.Disconnects, r.Resumed, r.Resynced,
+		r.Acknowledged.P50, r.Acknowledged.P99, r.Propagated.P50, r.Propagated.P99, diverged, r.Config.Clients*len(r.Documents))
+}
+
+// report compares the clients' replicas with the engine's documents once
+// their run loops are over
+func (s *simulation) report(ctx gocontext.Context, engine *collaboration.CollaborationEngine, clients []*virtualClient, elapsed time.Duration) (*Report, error) {
+	report := &Report{
+		Config:       s.config,
+		Elapsed:      elapsed,
+		Acknowledged: summarize(s.acknowledged),
+		Propagated:   summarize(s.propagated),
+	}
+	for _, accepted := range s.accepted {
+		report.Operations += len(accepted)
+	}
+	for _, c := range clients {
+		report.Rejected += c.rejected
+		report.Disconnects += c.disconnects
+		report.Resumed += c.resumed
+		report.Resynced += c.resynced
+		report.Errors = append(report.Errors, c.errors...)
+	}
+
+	for _, documentID := range s.documents {
+		doc, err := engine.GetDocumentState(ctx, documentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", documentID, err)
+		}
+		expected, err := doc.Render()
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", documentID, err)
+		}
+
+		document := DocumentReport{DocumentID: documentID, Size: len(expected)}
+		for _, c := range clients {
+			r := c.replicas[documentID]
+			rendered, err := r.doc.Render()
+			if err != nil {
+				return nil, fmt.Errorf("failed to render %s's %s: %w", c.author, documentID, err)
+			}
+			missing := s.missing(documentID, r.applied)
+			if offset := firstDifference(rendered, expected); offset >= 0 || missing > 0 {
+				document.Divergent = append(document.Divergent, Divergence{Client: c.author, Missing: missing, Offset: offset})
+			}
+		}
+		report.Documents = append(report.Documents, document)
+	}
+	return report, nil
+}
+
+func summarize(durations []time.Duration) Latency {
+	if len(durations) == 0 {
+		return Latency{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return Latency{
+		Samples: len(sorted),
+		P50:     sorted[len(sorted)/2],
+		P99:     sorted[len(sorted)*99/100],
+		Max:     sorted[len(sorted)-1],
+	}
+}
+
+// firstDifference is the first byte offset at which a and b differ, -1 when
+// they don't
+func firstDifference(a, b string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		return min(len(a), len(b))
+	}
+	return -1
+}
@@ -0,0 +1,247 @@
+// Package simulation runs synthetic collaborative workloads against a
+// collaboration engine. Virtual clients connect over WebSocket, in-process,
+// as editors do, and edit shared documents through a network with latency,
+// their connections dropping and coming back now and then. Once the edits
+// are in and the clients have caught up, each client's copy of each document
+// is compared with the engine's, and the report says whether they converged
+// and how long operations took to be acknowledged and to reach the others.
+package simulation
+
+import (
+	gocontext "context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+const (
+	DefaultEditInterval = 50 * time.Millisecond
+	DefaultSettle       = 30 * time.Second
+)
+
+// Config is one simulated workload. Zero values take the defaults noted.
+type Config struct {
+	// Clients each follow and edit all of the Documents, one by default
+	Clients   int `json:"clients"`
+	Documents int `json:"documents"`
+	// Each client makes an edit every EditInterval on average, until it has
+	// made Operations edits, or until Duration has passed when that is zero
+	EditInterval time.Duration `json:"edit_interval"`
+	Operations   int           `json:"operations,omitempty"`
+	Duration     time.Duration `json:"duration,omitempty"`
+	// DeleteRatio is the share of edits that delete text rather than insert it
+	DeleteRatio float64 `json:"delete_ratio"`
+	// Latency delays every message each way, and Jitter by up to that much
+	// more. Messages still arrive in the order they were sent.
+	Latency time.Duration `json:"latency"`
+	Jitter  time.Duration `json:"jitter"`
+	// Churn is how long a client stays connected on average before it
+	// disconnects, and Offline how long it stays away. Zero never disconnects.
+	Churn   time.Duration `json:"churn,omitempty"`
+	Offline time.Duration `json:"offline,omitempty"`
+	// Settle is how long clients may take to catch up once the edits end
+	Settle time.Duration `json:"settle"`
+	// Seed makes which edits the clients make repeatable, though not when
+	Seed uint64 `json:"seed"`
+}
+
+func (c Config) withDefaults() (Config, error) {
+	if c.Clients <= 0 {
+		return c, fmt.Errorf("a simulation needs at least one client")
+	}
+	if c.Operations <= 0 && c.Duration <= 0 {
+		return c, fmt.Errorf("a simulation needs a number of operations or a duration")
+	}
+	if c.DeleteRatio < 0 || c.DeleteRatio > 1 {
+		return c, fmt.Errorf("delete ratio %g is not between 0 and 1", c.DeleteRatio)
+	}
+	if c.Documents <= 0 {
+		c.Documents = 1
+	}
+	if c.EditInterval <= 0 {
+		c.EditInterval = DefaultEditInterval
+	}
+	if c.Settle <= 0 {
+		c.Settle = DefaultSettle
+	}
+	return c, nil
+}
+
+// DocumentID names the documents a simulation edits, from 0
+func DocumentID(n int) string {
+	return fmt.Sprintf("simulation/%d.txt", n)
+}
+
+// Run simulates config's workload against engine and reports on it. The
+// documents may already exist; clients start from what they hold. The
+// engine's WebSocket config applies, so its ResumeTTL decides whether
+// clients coming back resume their sessions or sync again.
+func Run(ctx gocontext.Context, engine *collaboration.CollaborationEngine, config Config) (*Report, error) {
+	config, err := config.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	server := &http.Server{Handler: connectHandler(engine)}
+	go server.Serve(listener)
+	defer server.Close()
+
+	s := newSimulation(config)
+	clients := make([]*virtualClient, config.Clients)
+	for i := range clients {
+		if clients[i], err = s.newClient(i, "http://"+listener.Addr().String()); err != nil {
+			return nil, err
+		}
+	}
+
+	runCtx, stop := gocontext.WithCancel(ctx)
+	defer stop()
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, c := range clients {
+		wg.Add(1)
+		go func(c *virtualClient) {
+			defer wg.Done()
+			c.run(runCtx)
+		}(c)
+	}
+
+	if config.Duration > 0 {
+		timer := time.AfterFunc(config.Duration, func() { close(s.stop) })
+		defer timer.Stop()
+	}
+	// Once every client has made its edits and had them acknowledged, the
+	// operations each has to catch up on are known
+	waitAll(ctx, clients, func(c *virtualClient) <-chan struct{} { return c.edited }, nil)
+	elapsed := time.Since(start)
+	close(s.settling)
+	settle := time.NewTimer(config.Settle)
+	defer settle.Stop()
+	waitAll(ctx, clients, func(c *virtualClient) <-chan struct{} { return c.settled }, settle.C)
+
+	stop()
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.report(ctx, engine, clients, elapsed)
+}
+
+// connectHandler serves collaboration over WebSocket. There are no API keys
+// to look up, so each client's key is taken as its author ID.
+func connectHandler(engine *collaboration.CollaborationEngine) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		author := operations.AuthorID(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		engine.Connect(w, r, author, r.URL.Query().Get("resume_token"))
+	})
+}
+
+// waitAll waits for the channel each client has, until ctx ends or timeout
+// fires
+func waitAll(ctx gocontext.Context, clients []*virtualClient, channel func(*virtualClient) <-chan struct{}, timeout <-chan time.Time) {
+	for _, c := range clients {
+		select {
+		case <-channel(c):
+		case <-ctx.Done():
+			return
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// simulation is what the clients of a run share
+type simulation struct {
+	config    Config
+	documents []string
+	// stop is closed once Duration has passed, and settling once every
+	// client has made its edits
+	stop     chan struct{}
+	settling chan struct{}
+
+	mutex sync.Mutex
+	// made is when each operation was made, for timing its way to others
+	made map[operations.OperationID]time.Time
+	// accepted holds the operations the engine acknowledged, by document
+	accepted     map[string]map[operations.OperationID]bool
+	acknowledged []time.Duration
+	propagated   []time.Duration
+}
+
+func newSimulation(config Config) *simulation {
+	s := &simulation{
+		config:   config,
+		stop:     make(chan struct{}),
+		settling: make(chan struct{}),
+		made:     make(map[operations.OperationID]time.Time),
+		accepted: make(map[string]map[operations.OperationID]bool),
+	}
+	for i := 0; i < config.Documents; i++ {
+		s.documents = append(s.documents, DocumentID(i))
+		s.accepted[DocumentID(i)] = make(map[operations.OperationID]bool)
+	}
+	return s
+}
+
+// delay is how long a message takes one way
+func (s *simulation) delay() time.Duration {
+	d := s.config.Latency
+	if s.config.Jitter > 0 {
+		d += rand.N(s.config.Jitter)
+	}
+	return d
+}
+
+// around is d give or take half of it, for spacing out events
+func around(rng *rand.Rand, d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rng.Int64N(int64(d))) + 1
+}
+
+func (s *simulation) sent(id operations.OperationID, at time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.made[id] = at
+}
+
+func (s *simulation) accept(documentID string, id operations.OperationID, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.accepted[documentID][id] = true
+	s.acknowledged = append(s.acknowledged, now.Sub(s.made[id]))
+}
+
+// received times an operation's way to another client
+func (s *simulation) received(id operations.OperationID, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if made, ok := s.made[id]; ok {
+		s.propagated = append(s.propagated, now.Sub(made))
+	}
+}
+
+// missing counts the operations accepted into documentID that aren't in applied
+func (s *simulation) missing(documentID string, applied map[operations.OperationID]bool) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	count := 0
+	for id := range s.accepted[documentID] {
+		if !applied[id] {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,87 @@
+package simulation
+
+import (
+	gocontext "context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		resumeTTL time.Duration
+	}{
+		{"resuming sessions", collaboration.DefaultResumeTTL},
+		{"syncing again", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := gocontext.Background()
+			store, err := storage.NewSQLiteStore(filepath.Join(t.TempDir(), "simulation.db"))
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			engine := collaboration.NewCollaborationEngine(store)
+			defer engine.Shutdown(ctx)
+			config := collaboration.DefaultWebSocketConfig()
+			config.ResumeTTL = tt.resumeTTL
+			engine.SetWebSocketConfig(config)
+
+			report, err := Run(ctx, engine, Config{
+				Clients:      4,
+				Documents:    2,
+				Operations:   30,
+				EditInterval: 5 * time.Millisecond,
+				DeleteRatio:  0.25,
+				Latency:      2 * time.Millisecond,
+				Jitter:       3 * time.Millisecond,
+				Churn:        100 * time.Millisecond,
+				Offline:      20 * time.Millisecond,
+				Settle:       10 * time.Second,
+				Seed:         1,
+			})
+			if err != nil {
+				t.Fatalf("Failed to run simulation: %v", err)
+			}
+			if !report.Converged() || len(report.Errors) > 0 {
+				t.Fatalf("Expected the replicas to converge, got %s: %+v, errors %q", report, report.Documents, report.Errors)
+			}
+			if report.Operations != 120 || report.Rejected != 0 {
+				t.Errorf("Expected 120 operations accepted, got %d and %d rejected", report.Operations, report.Rejected)
+			}
+			if report.Acknowledged.Samples != 120 || report.Acknowledged.P50 < 4*time.Millisecond {
+				t.Errorf("Expected every operation acknowledged after a round trip, got %+v", report.Acknowledged)
+			}
+			if report.Propagated.Samples == 0 || report.Propagated.P50 > report.Propagated.P99 {
+				t.Errorf("Expected operations timed reaching others, got %+v", report.Propagated)
+			}
+			if report.Resumed+report.Resynced != report.Disconnects {
+				t.Errorf("Expected each of %d disconnects to resume or sync, got %d and %d", report.Disconnects, report.Resumed, report.Resynced)
+			}
+			if tt.resumeTTL == 0 && report.Resumed != 0 {
+				t.Errorf("Expected no sessions kept to resume, got %d resumed", report.Resumed)
+			}
+		})
+	}
+}
+
+func TestConfig_Defaults(t *testing.T) {
+	if _, err := (Config{Clients: 2}).withDefaults(); err == nil {
+		t.Error("Expected a simulation without operations or a duration to be refused")
+	}
+	if _, err := (Config{Clients: 2, Operations: 1, DeleteRatio: 1.5}).withDefaults(); err == nil {
+		t.Error("Expected a delete ratio over 1 to be refused")
+	}
+	config, err := Config{Clients: 2, Duration: time.Second}.withDefaults()
+	if err != nil {
+		t.Fatalf("Failed to apply defaults: %v", err)
+	}
+	if config.Documents != 1 || config.EditInterval != DefaultEditInterval || config.Settle != DefaultSettle {
+		t.Errorf("Expected defaults filled in, got %+v", config)
+	}
+}
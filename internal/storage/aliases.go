@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// author_aliases maps each alias straight to the author ID it was merged
+// into, so an identity is one row per alias and never a chain
+const authorAliasesSchema = `
+CREATE TABLE IF NOT EXISTS author_aliases (
+	alias TEXT PRIMARY KEY,
+	author TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_author_aliases_author ON author_aliases(author);
+`
+
+func migrateAuthorAliases(db *sql.DB) error {
+	if _, err := db.Exec(authorAliasesSchema); err != nil {
+		return fmt.Errorf("failed to create author aliases table: %w", err)
+	}
+	return nil
+}
+
+// authorIdentity is a condition matching column against an author and every
+// ID merged with it, whichever of them it is given. Its arguments come from
+// identityArgs.
+func authorIdentity(column string) string {
+	return column + ` IN (
+		SELECT ?
+		UNION SELECT alias FROM author_aliases WHERE author = ?
+		UNION SELECT author FROM author_aliases WHERE alias = ?
+		UNION SELECT siblings.alias FROM author_aliases merged
+			JOIN author_aliases siblings ON siblings.author = merged.author
+			WHERE merged.alias = ?)`
+}
+
+func identityArgs(author operations.AuthorID) []interface{} {
+	return []interface{}{string(author), string(author), string(author), string(author)}
+}
+
+func (cs *ContextStore) AuthorAliases(ctx context.Context) (map[operations.AuthorID]operations.AuthorID, error) {
+	return authorAliases(ctx, cs.db)
+}
+
+func (cs *ContextStore) MergeAuthors(ctx context.Context, author operations.AuthorID, aliases []operations.AuthorID) error {
+	if cs.readOnly {
+		return ErrReadOnly
+	}
+	return mergeAuthors(ctx, cs.db, author, aliases)
+}
+
+func (cs *ContextStore) RemoveAuthorAlias(ctx context.Context, alias operations.AuthorID) error {
+	if cs.readOnly {
+		return ErrReadOnly
+	}
+	return removeAuthorAlias(ctx, cs.db, alias)
+}
+
+func (s *SQLiteStore) AuthorAliases(ctx context.Context) (map[operations.AuthorID]operations.AuthorID, error) {
+	return authorAliases(ctx, s.db)
+}
+
+func (s *SQLiteStore) MergeAuthors(ctx context.Context, author operations.AuthorID, aliases []operations.AuthorID) error {
+	return mergeAuthors(ctx, s.db, author, aliases)
+}
+
+func (s *SQLiteStore) RemoveAuthorAlias(ctx context.Context, alias operations.AuthorID) error {
+	return removeAuthorAlias(ctx, s.db, alias)
+}
+
+func authorAliases(ctx context.Context, db *sql.DB) (map[operations.AuthorID]operations.AuthorID, error) {
+	rows, err := db.QueryContext(ctx, `SELECT alias, author FROM author_aliases`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := make(map[operations.AuthorID]operations.AuthorID)
+	for rows.Next() {
+		var alias, author string
+		if err := rows.Scan(&alias, &author); err != nil {
+			return nil, err
+		}
+		aliases[operations.AuthorID(alias)] = operations.AuthorID(author)
+	}
+	return aliases, rows.Err()
+}
+
+// mergeAuthors makes aliases other names for author. An author that is itself
+// an alias merges into the ID it is an alias of, and aliases that others were
+// merged into bring those along.
+func mergeAuthors(ctx context.Context, db *sql.DB, author operations.AuthorID, aliases []operations.AuthorID) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	canonical := string(author)
+	err = tx.QueryRowContext(ctx, `SELECT author FROM author_aliases WHERE alias = ?`, canonical).Scan(&canonical)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	now := time.Now().UnixNano()
+	for _, alias := range aliases {
+		if string(alias) == canonical {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE author_aliases SET author = ? WHERE author = ?`, canonical, string(alias)); err != nil {
+			return fmt.Errorf("failed to merge aliases of %s: %w", alias, err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO author_aliases (alias, author, created_at) VALUES (?, ?, ?)
+			ON CONFLICT(alias) DO UPDATE SET author = excluded.author`,
+			string(alias), canonical, now); err != nil {
+			return fmt.Errorf("failed to merge %s: %w", alias, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func removeAuthorAlias(ctx context.Context, db *sql.DB, alias operations.AuthorID) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM author_aliases WHERE alias = ?`, string(alias))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAliasNotFound
+	}
+	return nil
+}
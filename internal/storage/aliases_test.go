@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestSQLiteStore_AuthorAliases(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	for i, author := range []string{"alice", "alice@laptop", "ajones", "bob"} {
+		op := &operations.Operation{
+			ID:   operations.NewOperationID([]byte("aliased " + author)),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(int64(i + 1)), AuthorID: operations.AuthorID(author)},
+			}),
+			Content:   author,
+			Author:    operations.AuthorID(author),
+			Timestamp: time.Now(),
+			Parents:   []operations.OperationID{},
+		}
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+	}
+
+	authorsOf := func(author operations.AuthorID) []string {
+		var byAuthor, matching []string
+		err := store.ForEachOperationByAuthor(ctx, author, func(op *operations.Operation) error {
+			byAuthor = append(byAuthor, string(op.Author))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to list operations by author: %v", err)
+		}
+		err = store.ForEachOperationMatching(ctx, OperationFilter{Author: author}, func(op *operations.Operation) error {
+			matching = append(matching, string(op.Author))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to list matching operations: %v", err)
+		}
+		slices.Sort(byAuthor)
+		slices.Sort(matching)
+		if !slices.Equal(byAuthor, matching) {
+			t.Fatalf("Expected both queries to agree for %s, got %v and %v", author, byAuthor, matching)
+		}
+		return byAuthor
+	}
+
+	if got := authorsOf("alice"); !slices.Equal(got, []string{"alice"}) {
+		t.Fatalf("Expected only alice's operation before merging, got %v", got)
+	}
+
+	if err := store.MergeAuthors(ctx, "alice", []operations.AuthorID{"alice@laptop"}); err != nil {
+		t.Fatalf("Failed to merge authors: %v", err)
+	}
+	// Merging alice into ajones brings alice@laptop along, and merging into an
+	// alias merges into its identity
+	if err := store.MergeAuthors(ctx, "ajones", []operations.AuthorID{"alice"}); err != nil {
+		t.Fatalf("Failed to merge authors: %v", err)
+	}
+	aliases, err := store.AuthorAliases(ctx)
+	if err != nil {
+		t.Fatalf("Failed to load aliases: %v", err)
+	}
+	if len(aliases) != 2 || aliases["alice"] != "ajones" || aliases["alice@laptop"] != "ajones" {
+		t.Fatalf("Expected both of alice's IDs to be aliases of ajones, got %v", aliases)
+	}
+
+	want := []string{"ajones", "alice", "alice@laptop"}
+	for _, author := range []operations.AuthorID{"ajones", "alice", "alice@laptop"} {
+		if got := authorsOf(author); !slices.Equal(got, want) {
+			t.Errorf("Expected %s to find %v, got %v", author, want, got)
+		}
+	}
+	if got := authorsOf("bob"); !slices.Equal(got, []string{"bob"}) {
+		t.Errorf("Expected bob to stay his own author, got %v", got)
+	}
+
+	if err := store.RemoveAuthorAlias(ctx, "alice@laptop"); err != nil {
+		t.Fatalf("Failed to remove alias: %v", err)
+	}
+	if got := authorsOf("alice@laptop"); !slices.Equal(got, []string{"alice@laptop"}) {
+		t.Errorf("Expected a removed alias to be its own author, got %v", got)
+	}
+	if err := store.RemoveAuthorAlias(ctx, "alice@laptop"); !errors.Is(err, ErrAliasNotFound) {
+		t.Errorf("Expected ErrAliasNotFound, got %v", err)
+	}
+}
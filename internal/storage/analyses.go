@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+const intentAnalysesSchema = `
+CREATE TABLE IF NOT EXISTS intent_analyses (
+	operation_id TEXT PRIMARY KEY,
+	document_id TEXT NOT NULL,
+	primary_intent TEXT NOT NULL,
+	category TEXT NOT NULL,
+	confidence REAL NOT NULL,
+	keywords TEXT NOT NULL,
+	analyzed_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_intent_analyses_document ON intent_analyses(document_id);
+`
+
+// IntentRecord is the stored result of analyzing an operation's intent. It is
+// kept apart from the operation, which is never changed once written.
+type IntentRecord struct {
+	OperationID   operations.OperationID `json:"operation_id"`
+	DocumentID    string                 `json:"document_id"`
+	PrimaryIntent string                 `json:"primary_intent"`
+	Category      string                 `json:"category"`
+	Confidence    float64                `json:"confidence"`
+	Keywords      []string               `json:"keywords"`
+	AnalyzedAt    time.Time              `json:"analyzed_at"`
+}
+
+func migrateIntentAnalyses(db *sql.DB) error {
+	if _, err := db.Exec(intentAnalysesSchema); err != nil {
+		return fmt.Errorf("failed to create intent analyses table: %w", err)
+	}
+	return nil
+}
+
+func (cs *ContextStore) StoreIntentRecords(ctx context.Context, records []IntentRecord) error {
+	return storeIntentRecords(ctx, cs.db, records)
+}
+
+func (cs *ContextStore) GetIntentRecord(ctx context.Context, id operations.OperationID) (*IntentRecord, error) {
+	return getIntentRecord(ctx, cs.db, id)
+}
+
+func (s *SQLiteStore) StoreIntentRecords(ctx context.Context, records []IntentRecord) error {
+	return storeIntentRecords(ctx, s.db, records)
+}
+
+func (s *SQLiteStore) GetIntentRecord(ctx context.Context, id operations.OperationID) (*IntentRecord, error) {
+	return getIntentRecord(ctx, s.db, id)
+}
+
+// storeIntentRecords replaces the analyses of the records' operations in one
+// transaction
+func storeIntentRecords(ctx context.Context, db *sql.DB, records []IntentRecord) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, record := range records {
+		keywords, err := json.Marshal(record.Keywords)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO intent_analyses
+			(operation_id, document_id, primary_intent, category, confidence, keywords, analyzed_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			string(record.OperationID), record.DocumentID, record.PrimaryIntent, record.Category,
+			record.Confidence, string(keywords), record.AnalyzedAt.UnixNano())
+		if err != nil {
+			return fmt.Errorf("failed to store intent analysis: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func getIntentRecord(ctx context.Context, db *sql.DB, id operations.OperationID) (*IntentRecord, error) {
+	var record IntentRecord
+	var operationID, keywords string
+	var analyzedAt int64
+	err := db.QueryRowContext(ctx, `
+		SELECT operation_id, document_id, primary_intent, category, confidence, keywords, analyzed_at
+		FROM intent_analyses WHERE operation_id = ?`, string(id)).
+		Scan(&operationID, &record.DocumentID, &record.PrimaryIntent, &record.Category, &record.Confidence, &keywords, &analyzedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAnalysisNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(keywords), &record.Keywords); err != nil {
+		return nil, ErrInvalidData
+	}
+	record.OperationID = operations.OperationID(operationID)
+	record.AnalyzedAt = time.Unix(0, analyzedAt)
+	return &record, nil
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore_IntentRecords(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	if _, err := store.GetIntentRecord(ctx, "op1"); !errors.Is(err, ErrAnalysisNotFound) {
+		t.Fatalf("Expected ErrAnalysisNotFound before analyzing, got %v", err)
+	}
+
+	analyzed := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	record := IntentRecord{OperationID: "op1", DocumentID: "main.go", PrimaryIntent: "bugfix", Category: "bugfix", Confidence: 0.5, Keywords: []string{"fix"}, AnalyzedAt: analyzed}
+	if err := store.StoreIntentRecords(ctx, []IntentRecord{record}); err != nil {
+		t.Fatalf("Failed to store intent records: %v", err)
+	}
+	// Analyzing again replaces the earlier result
+	record.Category, record.AnalyzedAt = "security", analyzed.Add(time.Hour)
+	if err := store.StoreIntentRecords(ctx, []IntentRecord{record}); err != nil {
+		t.Fatalf("Failed to store intent records: %v", err)
+	}
+
+	stored, err := store.GetIntentRecord(ctx, "op1")
+	if err != nil {
+		t.Fatalf("Failed to get intent record: %v", err)
+	}
+	if stored.Category != "security" || !stored.AnalyzedAt.Equal(record.AnalyzedAt) || !slices.Equal(stored.Keywords, []string{"fix"}) {
+		t.Errorf("Expected the latest analysis, got %+v", stored)
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// backupStepPages is how much of the database one backup step copies. Writers
+// can get in between steps; SQLite restarts the copy if one does so the
+// result is always a consistent snapshot.
+const backupStepPages = 1024
+
+// BackupTo writes a consistent copy of the database to destPath using
+// SQLite's online backup API, so the store stays usable while it runs.
+// destPath must not exist.
+func (cs *ContextStore) BackupTo(ctx context.Context, destPath string) error {
+	return backupDatabase(ctx, cs.db, destPath)
+}
+
+func (s *SQLiteStore) BackupTo(ctx context.Context, destPath string) error {
+	return backupDatabase(ctx, s.db, destPath)
+}
+
+func backupDatabase(ctx context.Context, db *sql.DB, destPath string) error {
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("backup destination %s already exists", destPath)
+	}
+
+	dest, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup destination: %w", err)
+	}
+	defer dest.Close()
+
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open backup destination: %w", err)
+	}
+	defer destConn.Close()
+
+	srcConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriver interface{}) error {
+		return srcConn.Raw(func(srcDriver interface{}) error {
+			destSQLite, ok := destDriver.(*sqlite3.SQLiteConn)
+			srcSQLite, srcOK := unwrapSQLiteConn(srcDriver)
+			if !ok || !srcOK {
+				return fmt.Errorf("backups need sqlite3 connections")
+			}
+
+			backup, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+
+			for {
+				done, err := backup.Step(backupStepPages)
+				if err != nil {
+					backup.Close()
+					return fmt.Errorf("backup failed: %w", err)
+				}
+				if done {
+					return backup.Finish()
+				}
+
+				// The source was busy or more pages remain, give writers a turn
+				select {
+				case <-ctx.Done():
+					backup.Close()
+					return ctx.Err()
+				case <-time.After(time.Millisecond):
+				}
+			}
+		})
+	})
+}
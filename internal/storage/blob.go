@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DefaultBlobThreshold is the content size in bytes at which an operation's
+// content moves out of the operations table into the blob table
+const DefaultBlobThreshold = 4096
+
+// operationColumns reads operation content from the blob table only for rows
+// that were externalized, so small operations never touch it
+const operationColumns = `id, type, position,
+		CASE WHEN blob_hash IS NULL THEN content
+		ELSE (SELECT b.content FROM blobs b WHERE b.hash = operations.blob_hash) END,
+		content_type, length, author, timestamp, parents, metadata, clock, utc_offset`
+
+const (
+	insertBlobQuery = `
+		INSERT OR IGNORE INTO blobs (hash, content, size, created_at)
+		VALUES (?, ?, ?, ?)
+	`
+	deleteOrphanBlobsQuery = `
+		DELETE FROM blobs
+		WHERE hash NOT IN (SELECT blob_hash FROM operations WHERE blob_hash IS NOT NULL)
+	`
+)
+
+type blob struct {
+	hash    string
+	content string
+}
+
+// newBlob returns nil when content is below threshold or threshold is disabled
+func newBlob(content string, threshold int) *blob {
+	if threshold <= 0 || len(content) < threshold {
+		return nil
+	}
+	return &blob{
+		hash:    fmt.Sprintf("%x", sha256.Sum256([]byte(content))),
+		content: content,
+	}
+}
+
+func (b *blob) args() []interface{} {
+	return []interface{}{b.hash, b.content, len(b.content), time.Now().Unix()}
+}
+
+// migrateBlobs adds the blob table and the operations.blob_hash column to
+// databases created before blobs existed
+func migrateBlobs(db *sql.DB) error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS blobs (
+		hash TEXT PRIMARY KEY,
+		content TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	rows, err := db.Query("PRAGMA table_info(operations)")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	hasBlobHash := false
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == "blob_hash" {
+			hasBlobHash = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if !hasBlobHash {
+		if _, err := db.Exec("ALTER TABLE operations ADD COLUMN blob_hash TEXT"); err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_operations_blob ON operations(blob_hash)")
+	return err
+}
+
+// storeOperationTx writes the blob, if any, and the operation in the same
+// transaction. insertOp must already be bound to tx.
+func storeOperationTx(ctx context.Context, tx *sql.Tx, insertOp *sql.Stmt, args []interface{}, b *blob) error {
+	if b != nil {
+		if _, err := tx.ExecContext(ctx, insertBlobQuery, b.args()...); err != nil {
+			return fmt.Errorf("failed to store blob: %w", err)
+		}
+	}
+
+	_, err := insertOp.ExecContext(ctx, args...)
+	return err
+}
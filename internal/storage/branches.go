@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+const branchesSchema = `
+CREATE TABLE IF NOT EXISTS branches (
+	name TEXT PRIMARY KEY,
+	base TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	created_by TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	merged_into TEXT NOT NULL DEFAULT '',
+	merged_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_branches_merged_into ON branches(merged_into);
+CREATE TABLE IF NOT EXISTS document_merges (
+	document_id TEXT NOT NULL,
+	branch TEXT NOT NULL,
+	merged_into TEXT NOT NULL,
+	through INTEGER NOT NULL,
+	merged_at INTEGER NOT NULL,
+	PRIMARY KEY (document_id, branch, merged_into)
+);
+`
+
+// Branch is a line of work apart from main. Operations tagged with it aren't
+// applied to the main documents until it is merged, which closes it.
+type Branch struct {
+	Name string `json:"name"`
+	// Base is the branch it was made from, whose documents it starts from
+	Base        string              `json:"base"`
+	Description string              `json:"description,omitempty"`
+	CreatedBy   operations.AuthorID `json:"created_by,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	MergedInto  string              `json:"merged_into,omitempty"`
+	MergedAt    *time.Time          `json:"merged_at,omitempty"`
+}
+
+// IsMerged reports whether the branch was merged and is closed
+func (b *Branch) IsMerged() bool {
+	return b.MergedInto != ""
+}
+
+// DocumentMerge records that one document's operations on a branch were
+// merged into another branch. The branch stays open, and its operations in
+// the document after Through aren't merged until it is merged again.
+type DocumentMerge struct {
+	DocumentID string `json:"document_id"`
+	Branch     string `json:"branch"`
+	MergedInto string `json:"merged_into"`
+	// Through is the timestamp of the latest operation merged
+	Through  time.Time `json:"through"`
+	MergedAt time.Time `json:"merged_at"`
+}
+
+func migrateBranches(db *sql.DB) error {
+	if _, err := db.Exec(branchesSchema); err != nil {
+		return fmt.Errorf("failed to create branches table: %w", err)
+	}
+	return nil
+}
+
+func (cs *ContextStore) CreateBranch(ctx context.Context, branch *Branch) error {
+	return createBranch(ctx, cs.db, branch)
+}
+
+func (cs *ContextStore) GetBranch(ctx context.Context, name string) (*Branch, error) {
+	return getBranch(ctx, cs.db, name)
+}
+
+func (cs *ContextStore) ListBranches(ctx context.Context) ([]*Branch, error) {
+	return listBranches(ctx, cs.db)
+}
+
+func (cs *ContextStore) MergeBranch(ctx context.Context, name, into string, at time.Time) error {
+	return mergeBranch(ctx, cs.db, name, into, at)
+}
+
+func (cs *ContextStore) RecordDocumentMerges(ctx context.Context, merges []DocumentMerge) error {
+	return recordDocumentMerges(ctx, cs.db, merges)
+}
+
+func (cs *ContextStore) ListDocumentMerges(ctx context.Context) ([]DocumentMerge, error) {
+	return listDocumentMerges(ctx, cs.db)
+}
+
+func (s *SQLiteStore) CreateBranch(ctx context.Context, branch *Branch) error {
+	return createBranch(ctx, s.db, branch)
+}
+
+func (s *SQLiteStore) GetBranch(ctx context.Context, name string) (*Branch, error) {
+	return getBranch(ctx, s.db, name)
+}
+
+func (s *SQLiteStore) ListBranches(ctx context.Context) ([]*Branch, error) {
+	return listBranches(ctx, s.db)
+}
+
+func (s *SQLiteStore) MergeBranch(ctx context.Context, name, into string, at time.Time) error {
+	return mergeBranch(ctx, s.db, name, into, at)
+}
+
+func (s *SQLiteStore) RecordDocumentMerges(ctx context.Context, merges []DocumentMerge) error {
+	return recordDocumentMerges(ctx, s.db, merges)
+}
+
+func (s *SQLiteStore) ListDocumentMerges(ctx context.Context) ([]DocumentMerge, error) {
+	return listDocumentMerges(ctx, s.db)
+}
+
+const branchColumns = "name, base, description, created_by, created_at, merged_into, merged_at"
+
+func createBranch(ctx context.Context, db *sql.DB, branch *Branch) error {
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO branches (name, base, description, created_by, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO NOTHING`,
+		branch.Name, branch.Base, branch.Description, string(branch.CreatedBy), branch.CreatedAt.UnixNano())
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrBranchExists, branch.Name)
+}
+
+func getBranch(ctx context.Context, db *sql.DB, name string) (*Branch, error) {
+	row := db.QueryRowContext(ctx, "SELECT "+branchColumns+" FROM branches WHERE name = ?", name)
+	branch, err := scanBranch(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrBranchNotFound, name)
+	}
+	return branch, err
+}
+
+// listBranches returns every branch, merged ones too, by name
+func listBranches(ctx context.Context, db *sql.DB) ([]*Branch, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+branchColumns+" FROM branches ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	branches := []*Branch{}
+	for rows.Next() {
+		branch, err := scanBranch(rows)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, branch)
+	}
+	return branches, rows.Err()
+}
+
+// mergeBranch records that name was merged into another branch. Only an
+// open branch can be merged, so of two merges racing one fails.
+func mergeBranch(ctx context.Context, db *sql.DB, name, into string, at time.Time) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE branches SET merged_into = ?, merged_at = ? WHERE name = ? AND merged_into = ''`,
+		into, at.UnixNano(), name)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+		return err
+	}
+
+	// Nothing changed, tell a missing branch from a merged one
+	if _, err := getBranch(ctx, db, name); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrBranchMerged, name)
+}
+
+// recordDocumentMerges stores merges together. Merging a branch's document
+// into the same branch again moves Through on.
+func recordDocumentMerges(ctx context.Context, db *sql.DB, merges []DocumentMerge) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, merge := range merges {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO document_merges (document_id, branch, merged_into, through, merged_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (document_id, branch, merged_into) DO UPDATE SET
+				through = max(through, excluded.through), merged_at = excluded.merged_at`,
+			merge.DocumentID, merge.Branch, merge.MergedInto, merge.Through.UnixNano(), merge.MergedAt.UnixNano())
+		if err != nil {
+			return fmt.Errorf("failed to record document merge: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// listDocumentMerges returns every document merge, oldest first
+func listDocumentMerges(ctx context.Context, db *sql.DB) ([]DocumentMerge, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT document_id, branch, merged_into, through, merged_at FROM document_merges
+		ORDER BY merged_at, document_id, branch`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var merges []DocumentMerge
+	for rows.Next() {
+		var merge DocumentMerge
+		var through, mergedAt int64
+		if err := rows.Scan(&merge.DocumentID, &merge.Branch, &merge.MergedInto, &through, &mergedAt); err != nil {
+			return nil, err
+		}
+		merge.Through = time.Unix(0, through)
+		merge.MergedAt = time.Unix(0, mergedAt)
+		merges = append(merges, merge)
+	}
+	return merges, rows.Err()
+}
+
+func scanBranch(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*Branch, error) {
+	var branch Branch
+	var createdBy string
+	var createdAt int64
+	var mergedAt sql.NullInt64
+	err := scanner.Scan(&branch.Name, &branch.Base, &branch.Description, &createdBy, &createdAt, &branch.MergedInto, &mergedAt)
+	if err != nil {
+		return nil, err
+	}
+	branch.CreatedBy = operations.AuthorID(createdBy)
+	branch.CreatedAt = time.Unix(0, createdAt)
+	if mergedAt.Valid {
+		at := time.Unix(0, mergedAt.Int64)
+		branch.MergedAt = &at
+	}
+	return &branch, nil
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// Inserts split into chunks are found by the first one's ID in their
+// metadata, through an index on the expressions below. Queries have to use
+// them as written for SQLite to use the index.
+const (
+	chunkOf    = `json_extract(metadata, '$.chunk.of')`
+	chunkIndex = `json_extract(metadata, '$.chunk.index')`
+)
+
+// migrateChunks indexes the operations that are chunks of a larger insert.
+// The index is partial, so other operations don't grow it.
+func migrateChunks(db *sql.DB) error {
+	_, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_operations_chunk ON operations(` + chunkOf + `, ` + chunkIndex + `)
+		WHERE ` + chunkOf + ` IS NOT NULL`)
+	return err
+}
+
+const selectChunksQuery = selectOperationColumns + " WHERE " + chunkOf + " = ? ORDER BY " + chunkIndex
+
+func (cs *ContextStore) ForEachOperationChunk(ctx context.Context, first operations.OperationID, fn OperationFunc) error {
+	rows, err := cs.db.QueryContext(ctx, selectChunksQuery, string(first))
+	if err != nil {
+		return err
+	}
+	return forEachOperationRow(rows, cs.scanOperation, fn)
+}
+
+func (s *SQLiteStore) ForEachOperationChunk(ctx context.Context, first operations.OperationID, fn OperationFunc) error {
+	rows, err := s.db.QueryContext(ctx, selectChunksQuery, string(first))
+	if err != nil {
+		return err
+	}
+	return forEachOperationRow(rows, s.scanOperation, fn)
+}
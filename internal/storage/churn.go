@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// Churn rollups count the operations on each document, and on each position
+// within it, per author and hour. Triggers keep them current as operations
+// are written, so churn over a window is read without scanning operations.
+// An operation stored again has its earlier counts taken back first. Rollups
+// are kept when operations are purged, so churn outlives retention.
+const churnSchema = `
+CREATE TABLE IF NOT EXISTS churn_documents (
+	document_id TEXT NOT NULL,
+	hour INTEGER NOT NULL,
+	author TEXT NOT NULL,
+	operations INTEGER NOT NULL DEFAULT 0,
+	inserts INTEGER NOT NULL DEFAULT 0,
+	deletes INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (document_id, hour, author)
+);
+CREATE INDEX IF NOT EXISTS idx_churn_documents_hour ON churn_documents(hour);
+
+CREATE TABLE IF NOT EXISTS churn_positions (
+	document_id TEXT NOT NULL,
+	position BLOB NOT NULL,
+	hour INTEGER NOT NULL,
+	author TEXT NOT NULL,
+	operations INTEGER NOT NULL DEFAULT 0,
+	inserts INTEGER NOT NULL DEFAULT 0,
+	deletes INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (document_id, position, hour, author)
+);
+CREATE INDEX IF NOT EXISTS idx_churn_positions_document ON churn_positions(document_id, hour);
+
+CREATE TRIGGER IF NOT EXISTS churn_operations_replace BEFORE INSERT ON operations
+WHEN EXISTS (SELECT 1 FROM operations WHERE id = NEW.id) AND ` + churnDocumentID + ` != '' BEGIN
+	UPDATE churn_documents SET ` + churnDecrement + `
+		WHERE document_id = ` + churnDocumentID + ` AND hour = ` + churnHour + ` AND author = NEW.author;
+	UPDATE churn_positions SET ` + churnDecrement + `
+		WHERE document_id = ` + churnDocumentID + ` AND position = NEW.position
+		AND hour = ` + churnHour + ` AND author = NEW.author;
+END;
+CREATE TRIGGER IF NOT EXISTS churn_operations_insert AFTER INSERT ON operations
+WHEN ` + churnDocumentID + ` != '' BEGIN
+	INSERT INTO churn_documents (document_id, hour, author, operations, inserts, deletes)
+		VALUES (` + churnDocumentID + `, ` + churnHour + `, NEW.author, 1, ` + churnInserts + `, ` + churnDeletes + `)
+		ON CONFLICT (document_id, hour, author) DO UPDATE SET ` + churnIncrement + `;
+	INSERT INTO churn_positions (document_id, position, hour, author, operations, inserts, deletes)
+		VALUES (` + churnDocumentID + `, NEW.position, ` + churnHour + `, NEW.author, 1, ` + churnInserts + `, ` + churnDeletes + `)
+		ON CONFLICT (document_id, position, hour, author) DO UPDATE SET ` + churnIncrement + `;
+END;
+`
+
+// Expressions over the NEW operation row shared by the churn triggers
+const (
+	churnDocumentID = `NEW.document_id`
+	churnHour       = `(NEW.timestamp / 1000000000 - NEW.timestamp / 1000000000 % 3600)`
+	churnInserts    = `(NEW.type = 'insert')`
+	churnDeletes    = `(NEW.type = 'delete')`
+	churnIncrement  = `operations = operations + 1, inserts = inserts + excluded.inserts, deletes = deletes + excluded.deletes`
+	churnDecrement  = `operations = operations - 1, inserts = inserts - ` + churnInserts + `, deletes = deletes - ` + churnDeletes
+)
+
+// DocumentChurn counts the operations on a document in a window
+type DocumentChurn struct {
+	DocumentID string
+	Operations int
+	Inserts    int
+	Deletes    int
+	Authors    int
+}
+
+// PositionChurn counts the operations at one position of a document in a
+// window, with the authors who made them
+type PositionChurn struct {
+	Position   operations.LogootPosition
+	Operations int
+	Inserts    int
+	Deletes    int
+	Authors    []operations.AuthorID
+}
+
+// migrateChurn creates the churn rollups, filling them from the operations
+// already stored the first time
+func migrateChurn(db *sql.DB) error {
+	existing, err := tableExists(db, "churn_documents")
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(churnSchema); err != nil {
+		return fmt.Errorf("failed to create churn rollups: %w", err)
+	}
+	if existing {
+		return nil
+	}
+
+	documentID := strings.ReplaceAll(churnDocumentID, "NEW.", "")
+	hour := strings.ReplaceAll(churnHour, "NEW.", "")
+	counts := `COUNT(*), SUM(type = 'insert'), SUM(type = 'delete')`
+	backfill := `
+	INSERT INTO churn_documents (document_id, hour, author, operations, inserts, deletes)
+		SELECT ` + documentID + `, ` + hour + `, author, ` + counts + ` FROM operations
+		WHERE ` + documentID + ` != '' GROUP BY 1, 2, 3;
+	INSERT INTO churn_positions (document_id, position, hour, author, operations, inserts, deletes)
+		SELECT ` + documentID + `, position, ` + hour + `, author, ` + counts + ` FROM operations
+		WHERE ` + documentID + ` != '' GROUP BY 1, 2, 3, 4;
+	`
+	if _, err := db.Exec(backfill); err != nil {
+		return fmt.Errorf("failed to fill churn rollups: %w", err)
+	}
+	return nil
+}
+
+func (cs *ContextStore) DocumentChurn(ctx context.Context, since, until time.Time) ([]DocumentChurn, error) {
+	return documentChurn(ctx, cs.db, since, until)
+}
+
+func (cs *ContextStore) PositionChurn(ctx context.Context, documentID string, since, until time.Time) ([]PositionChurn, error) {
+	return positionChurn(ctx, cs.db, documentID, since, until)
+}
+
+func (s *SQLiteStore) DocumentChurn(ctx context.Context, since, until time.Time) ([]DocumentChurn, error) {
+	return documentChurn(ctx, s.db, since, until)
+}
+
+func (s *SQLiteStore) PositionChurn(ctx context.Context, documentID string, since, until time.Time) ([]PositionChurn, error) {
+	return positionChurn(ctx, s.db, documentID, since, until)
+}
+
+// churnHours converts a window to the range of hours whose rollups cover it.
+// Rollups are hourly, so the window widens to whole hours.
+func churnHours(since, until time.Time) (int64, int64) {
+	first := since.Unix() - since.Unix()%3600
+	if since.IsZero() {
+		first = 0
+	}
+	last := until.Unix()
+	if until.IsZero() {
+		last = time.Now().Unix()
+	}
+	return first, last
+}
+
+func documentChurn(ctx context.Context, db *sql.DB, since, until time.Time) ([]DocumentChurn, error) {
+	first, last := churnHours(since, until)
+	rows, err := db.QueryContext(ctx, `
+		SELECT document_id, SUM(operations), SUM(inserts), SUM(deletes), COUNT(DISTINCT author)
+		FROM churn_documents
+		WHERE hour >= ? AND hour <= ? AND operations > 0
+		GROUP BY document_id
+		ORDER BY SUM(operations) DESC, document_id`, first, last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query churn: %w", err)
+	}
+	defer rows.Close()
+
+	churn := []DocumentChurn{}
+	for rows.Next() {
+		var c DocumentChurn
+		if err := rows.Scan(&c.DocumentID, &c.Operations, &c.Inserts, &c.Deletes, &c.Authors); err != nil {
+			return nil, err
+		}
+		churn = append(churn, c)
+	}
+	return churn, rows.Err()
+}
+
+func positionChurn(ctx context.Context, db *sql.DB, documentID string, since, until time.Time) ([]PositionChurn, error) {
+	first, last := churnHours(since, until)
+	rows, err := db.QueryContext(ctx, `
+		SELECT position, author, SUM(operations), SUM(inserts), SUM(deletes)
+		FROM churn_positions
+		WHERE document_id = ? AND hour >= ? AND hour <= ? AND operations > 0
+		GROUP BY position, author
+		ORDER BY position, author`, documentID, first, last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query churn: %w", err)
+	}
+	defer rows.Close()
+
+	churn := []PositionChurn{}
+	var previous []byte
+	for rows.Next() {
+		var (
+			encoded        []byte
+			author         string
+			ops, ins, dels int
+		)
+		if err := rows.Scan(&encoded, &author, &ops, &ins, &dels); err != nil {
+			return nil, err
+		}
+
+		// Rows come grouped by position, one per author, in document order
+		if len(churn) > 0 && bytes.Equal(encoded, previous) {
+			c := &churn[len(churn)-1]
+			c.Operations += ops
+			c.Inserts += ins
+			c.Deletes += dels
+			c.Authors = append(c.Authors, operations.AuthorID(author))
+			continue
+		}
+
+		previous = encoded
+		position, err := decodePosition(encoded)
+		if err != nil {
+			return nil, err
+		}
+		churn = append(churn, PositionChurn{
+			Position:   position,
+			Operations: ops,
+			Inserts:    ins,
+			Deletes:    dels,
+			Authors:    []operations.AuthorID{operations.AuthorID(author)},
+		})
+	}
+	return churn, rows.Err()
+}
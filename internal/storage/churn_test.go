@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestSQLiteStore_Churn(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	newOp := func(id string, opType operations.OperationType, author, document string, value int64, at time.Duration) *operations.Operation {
+		op := &operations.Operation{
+			ID:   operations.OperationID(id),
+			Type: opType,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: "alice"},
+			}),
+			Content:   id,
+			Author:    operations.AuthorID(author),
+			Timestamp: start.Add(at),
+			Parents:   []operations.OperationID{},
+			Metadata:  operations.OperationMeta{Context: map[string]string{"document_id": document}},
+		}
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+		return op
+	}
+
+	first := newOp("a1", operations.OpInsert, "alice", "main.go", 1, 0)
+	newOp("a2", operations.OpInsert, "alice", "main.go", 2, time.Minute)
+	newOp("b1", operations.OpDelete, "bob", "main.go", 1, 2*time.Minute)
+	newOp("c1", operations.OpInsert, "carol", "util.go", 1, time.Minute)
+	newOp("late", operations.OpInsert, "carol", "util.go", 2, 48*time.Hour)
+	// Storing an operation again doesn't count it twice
+	if err := store.StoreOperation(ctx, first); err != nil {
+		t.Fatalf("Failed to store operation again: %v", err)
+	}
+
+	churn, err := store.DocumentChurn(ctx, start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get document churn: %v", err)
+	}
+	expected := []DocumentChurn{
+		{DocumentID: "main.go", Operations: 3, Inserts: 2, Deletes: 1, Authors: 2},
+		{DocumentID: "util.go", Operations: 1, Inserts: 1, Authors: 1},
+	}
+	if len(churn) != len(expected) || churn[0] != expected[0] || churn[1] != expected[1] {
+		t.Fatalf("Expected %+v, got %+v", expected, churn)
+	}
+
+	positions, err := store.PositionChurn(ctx, "main.go", start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get position churn: %v", err)
+	}
+	if len(positions) != 2 {
+		t.Fatalf("Expected two positions, got %+v", positions)
+	}
+	for _, p := range positions {
+		if p.Position.Key() == first.Position.Key() && (p.Operations != 2 || p.Deletes != 1 || len(p.Authors) != 2) {
+			t.Errorf("Expected alice's insert and bob's delete at the first position, got %+v", p)
+		}
+	}
+
+	// Rollups built for a store that had none are filled from its operations
+	if _, err := store.db.Exec("DROP TABLE churn_documents; DROP TABLE churn_positions"); err != nil {
+		t.Fatalf("Failed to drop rollups: %v", err)
+	}
+	if err := migrateChurn(store.db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	churn, _ = store.DocumentChurn(ctx, time.Time{}, time.Time{})
+	if len(churn) != 2 || churn[0].Operations != 3 || churn[1].Operations != 2 {
+		t.Errorf("Expected the rollups filled from all operations, got %+v", churn)
+	}
+}
+
+func TestContextStore_ChurnAfterReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.Close()
+
+	// Stores opened again get the rollups too, and keep them current
+	store, err = NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	op := &operations.Operation{
+		ID:   "a1",
+		Type: operations.OpInsert,
+		Position: operations.NewLogootPosition([]operations.PositionSegment{
+			{Value: big.NewInt(1), AuthorID: "alice"},
+		}),
+		Content:   "a1",
+		Author:    "alice",
+		Timestamp: time.Now(),
+		Parents:   []operations.OperationID{},
+		Metadata:  operations.OperationMeta{Context: map[string]string{"document_id": "main.go"}},
+	}
+	if err := store.StoreOperation(ctx, op); err != nil {
+		t.Fatalf("Failed to store operation: %v", err)
+	}
+
+	churn, err := store.DocumentChurn(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get churn: %v", err)
+	}
+	if len(churn) != 1 || churn[0].DocumentID != "main.go" || churn[0].Operations != 1 {
+		t.Errorf("Expected churn on main.go, got %+v", churn)
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// Operations are ordered by their hybrid logical clock in the clock column.
+// The timestamp column keeps the wall time they were made, which time ranges
+// filter on.
+
+// migrateClock adds the clock column to databases created before it, giving
+// existing operations the earliest reading at their timestamp, and orders the
+// metadata indexes by it
+func migrateClock(db *sql.DB) error {
+	exists, err := columnExists(db, "operations", "clock")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := addClockColumn(db); err != nil {
+			return fmt.Errorf("failed to migrate operation clocks: %w", err)
+		}
+	}
+
+	statements := []string{"CREATE INDEX IF NOT EXISTS idx_operations_clock ON operations(clock, id)"}
+	for _, column := range metadataColumns {
+		statements = append(statements,
+			fmt.Sprintf("DROP INDEX IF EXISTS idx_operations_%s", column),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_operations_%s_clock ON operations(%s, clock)", column, column),
+		)
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addClockColumn(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"ALTER TABLE operations ADD COLUMN clock INTEGER NOT NULL DEFAULT 0",
+		// Timestamps are Unix nanoseconds, clocks milliseconds shifted past the counter
+		"UPDATE operations SET clock = (timestamp / 1000000) << 16",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// maxClock is the latest clock of any stored operation, zero with none, for
+// a node's clock to start past
+func maxClock(ctx context.Context, db *sql.DB) (operations.HLC, error) {
+	var clock operations.HLC
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(clock), 0) FROM operations").Scan(&clock)
+	return clock, err
+}
+
+func (cs *ContextStore) MaxClock(ctx context.Context) (operations.HLC, error) {
+	return maxClock(ctx, cs.db)
+}
+
+func (s *SQLiteStore) MaxClock(ctx context.Context) (operations.HLC, error) {
+	return maxClock(ctx, s.db)
+}
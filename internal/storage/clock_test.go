@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestOperationClock_MigrateAndOrder(t *testing.T) {
+	ctx := context.Background()
+	tmpFile, err := os.CreateTemp("", "contextdb_test_*.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	tmpFile.Close()
+	defer removeDatabaseFiles(tmpFile.Name())
+
+	// A store from before operations had clocks
+	db, err := sql.Open("sqlite3", tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	legacy := `
+	CREATE TABLE operations (id TEXT PRIMARY KEY, type TEXT NOT NULL, position_segments TEXT NOT NULL,
+		content TEXT NOT NULL, content_type TEXT DEFAULT 'text', length INTEGER, author TEXT NOT NULL,
+		timestamp INTEGER NOT NULL, parents TEXT, metadata TEXT);
+
+	INSERT INTO operations VALUES ('op1', 'insert', '[{"value":1,"author":"alice"}]', 'a', 'text', 1, 'alice', 100, '[]', '{}');
+	`
+	if _, err := db.Exec(legacy); err != nil {
+		t.Fatalf("Failed to create legacy store: %v", err)
+	}
+	db.Close()
+
+	store, err := NewSQLiteStore(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open legacy store: %v", err)
+	}
+	defer store.Close()
+
+	migrated, err := store.GetOperation(ctx, "op1")
+	if err != nil {
+		t.Fatalf("Failed to get operation: %v", err)
+	}
+	if !migrated.Timestamp.Equal(time.Unix(100, 0)) {
+		t.Errorf("Expected the migrated timestamp in nanoseconds, got %s", migrated.Timestamp)
+	}
+	if migrated.Clock != operations.HLCFromTime(time.Unix(100, 0)) {
+		t.Errorf("Expected the migrated clock at the timestamp, got %s", migrated.Clock.Time())
+	}
+
+	// The same second as each other, in the opposite order to their IDs
+	second := time.Unix(200, 0)
+	for _, op := range []*operations.Operation{
+		{ID: "op3", Clock: operations.HLCFromTime(second) + 1},
+		{ID: "op2", Clock: operations.HLCFromTime(second) + 2},
+	} {
+		op.Type = operations.OpInsert
+		op.Position = operations.NewLogootPosition(nil)
+		op.Author = "alice"
+		op.Timestamp = second
+		op.Parents = []operations.OperationID{}
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+	}
+
+	var ids []operations.OperationID
+	err = store.ForEachOperationSince(ctx, time.Time{}, func(op *operations.Operation) error {
+		ids = append(ids, op.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read operations: %v", err)
+	}
+	if len(ids) != 3 || ids[0] != "op1" || ids[1] != "op3" || ids[2] != "op2" {
+		t.Errorf("Expected operations in clock order, got %v", ids)
+	}
+
+	latest, err := store.MaxClock(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get the latest clock: %v", err)
+	}
+	if latest != operations.HLCFromTime(second)+2 {
+		t.Errorf("Expected the latest clock to be op2's, got %d", latest)
+	}
+}
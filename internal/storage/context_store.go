@@ -1,14 +1,18 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/jeremytregunna/contextdb/internal/filelock"
 	"github.com/jeremytregunna/contextdb/internal/operations"
 	"github.com/jeremytregunna/contextdb/internal/positioning"
 	_ "github.com/mattn/go-sqlite3"
@@ -25,7 +29,15 @@ const (
 type ContextStore struct {
 	basePath string
 	db       *sql.DB
-	manifest *Manifest
+	// manifest is replaced whole on each update, never modified, as other
+	// processes sharing the .context directory may update the file too.
+	// manifestVersion is what it was read from or saved as.
+	manifest        *Manifest
+	manifestVersion filelock.Version
+	readOnly        bool
+	// claim is held by stores opened with ClaimContextStore
+	claim *filelock.Lock
+	mutex sync.RWMutex
 }
 
 type Manifest struct {
@@ -36,6 +48,7 @@ type Manifest struct {
 	StorageType   string            `json:"storage_type"`
 	DatabaseFile  string            `json:"database_file"`
 	Metadata      map[string]string `json:"metadata"`
+	Retention     *RetentionPolicy  `json:"retention,omitempty"`
 }
 
 func NewContextStore(basePath string) (*ContextStore, error) {
@@ -53,18 +66,45 @@ func NewContextStore(basePath string) (*ContextStore, error) {
 	return createNewContextStore(contextPath)
 }
 
+// OpenContextStoreReadOnly opens the existing store under basePath without
+// writing to it, or to its manifest, ever. Writes fail with ErrReadOnly or
+// SQLite's own error. The store is not migrated, so it must have been opened
+// read-write by this version first, or it is refused with ErrNeedsMigration;
+// a copy replicated from such a store, kept current underneath, is read as
+// it changes.
+func OpenContextStoreReadOnly(basePath string) (*ContextStore, error) {
+	contextPath := filepath.Join(basePath, ContextDir)
+	if _, err := os.Stat(contextPath); err != nil {
+		return nil, fmt.Errorf("failed to access .context directory: %w", err)
+	}
+	return openContextStore(contextPath, true)
+}
+
 func createNewContextStore(contextPath string) (*ContextStore, error) {
 	// Create .context directory
 	if err := os.MkdirAll(contextPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create .context directory: %w", err)
 	}
 
+	// Another process may be creating the store at the same time, in which
+	// case whichever locks the manifest second opens what the first created
+	manifestPath := filepath.Join(contextPath, ManifestFile)
+	lock, err := filelock.Acquire(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock manifest: %w", err)
+	}
+	if _, err := os.Stat(manifestPath); err == nil {
+		lock.Unlock()
+		return openExistingContextStore(contextPath)
+	}
+	defer lock.Unlock()
+
 	// Create manifest
 	manifest := &Manifest{
 		Version:       CurrentVersion,
 		Created:       time.Now(),
 		LastModified:  time.Now(),
-		SchemaVersion: "1.0",
+		SchemaVersion: SchemaVersion,
 		StorageType:   "sqlite",
 		DatabaseFile:  DatabaseFile,
 		Metadata: map[string]string{
@@ -73,11 +113,6 @@ func createNewContextStore(contextPath string) (*ContextStore, error) {
 		},
 	}
 
-	manifestPath := filepath.Join(contextPath, ManifestFile)
-	if err := writeJSON(manifestPath, manifest); err != nil {
-		return nil, fmt.Errorf("failed to create manifest: %w", err)
-	}
-
 	// Initialize SQLite database
 	dbPath := filepath.Join(contextPath, DatabaseFile)
 	db, err := initSQLiteDB(dbPath)
@@ -85,14 +120,22 @@ func createNewContextStore(contextPath string) (*ContextStore, error) {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
-	return &ContextStore{
+	store := &ContextStore{
 		basePath: contextPath,
 		db:       db,
-		manifest: manifest,
-	}, nil
+	}
+	if err := store.saveManifest(manifest); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create manifest: %w", err)
+	}
+	return store, nil
 }
 
 func openExistingContextStore(contextPath string) (*ContextStore, error) {
+	return openContextStore(contextPath, false)
+}
+
+func openContextStore(contextPath string, readOnly bool) (*ContextStore, error) {
 	manifestPath := filepath.Join(contextPath, ManifestFile)
 
 	// Check if manifest exists
@@ -102,6 +145,10 @@ func openExistingContextStore(contextPath string) (*ContextStore, error) {
 
 	// Read and validate manifest
 	var manifest Manifest
+	manifestVersion, err := filelock.Stat(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
 	if err := readJSON(manifestPath, &manifest); err != nil {
 		return nil, fmt.Errorf("failed to read manifest: %w", err)
 	}
@@ -122,7 +169,14 @@ func openExistingContextStore(contextPath string) (*ContextStore, error) {
 		return nil, fmt.Errorf("database file %s not found", manifest.DatabaseFile)
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	dsn := dbPath
+	if readOnly {
+		var err error
+		if dsn, err = readOnlyDSN(dbPath); err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open(sqliteDriver, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -133,137 +187,125 @@ func openExistingContextStore(contextPath string) (*ContextStore, error) {
 		return nil, fmt.Errorf("database connection failed: %w", err)
 	}
 
+	if readOnly {
+		// Nothing is migrated, so queries would fail one by one on what an
+		// older store lacks
+		if err := checkMigrated(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return &ContextStore{
+			basePath:        contextPath,
+			db:              db,
+			manifest:        &manifest,
+			manifestVersion: manifestVersion,
+			readOnly:        true,
+		}, nil
+	}
+
+	// Stores created by older versions are brought up to date
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	store := &ContextStore{
+		basePath:        contextPath,
+		db:              db,
+		manifest:        &manifest,
+		manifestVersion: manifestVersion,
+	}
+
 	// Update last modified
-	manifest.LastModified = time.Now()
-	if err := writeJSON(manifestPath, &manifest); err != nil {
+	if err := store.updateManifest(func(*Manifest) {}); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to update manifest: %w", err)
 	}
 
-	return &ContextStore{
-		basePath: contextPath,
-		db:       db,
-		manifest: &manifest,
-	}, nil
+	// As are stores created before the search index existed
+	if err := initSearch(db, store); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return store, nil
+}
+
+// readOnlyDSN opens dbPath as a URI, the only way to ask SQLite for read-only
+func readOnlyDSN(dbPath string) (string, error) {
+	abs, err := filepath.Abs(dbPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve database path: %w", err)
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs), RawQuery: "mode=ro"}
+	return u.String(), nil
 }
 
 func initSQLiteDB(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open(sqliteDriver, dbPath)
 	if err != nil {
 		return nil, err
 	}
 
 	// Create schema
-	schema := `
-	CREATE TABLE IF NOT EXISTS operations (
-		id TEXT PRIMARY KEY,
-		type TEXT NOT NULL,
-		position_segments TEXT NOT NULL,
-		content TEXT NOT NULL,
-		content_type TEXT DEFAULT 'text',
-		length INTEGER,
-		author TEXT NOT NULL,
-		timestamp INTEGER NOT NULL,
-		parents TEXT,
-		metadata TEXT
-	);
-
-	CREATE TABLE IF NOT EXISTS documents (
-		file_path TEXT PRIMARY KEY,
-		version INTEGER NOT NULL,
-		content_hash TEXT NOT NULL,
-		last_operation TEXT,
-		created_at INTEGER NOT NULL,
-		updated_at INTEGER NOT NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS constructs (
-		id TEXT PRIMARY KEY,
-		document_path TEXT NOT NULL,
-		position_segments TEXT NOT NULL,
-		content TEXT NOT NULL,
-		type TEXT NOT NULL,
-		created_by TEXT NOT NULL,
-		modified_by TEXT NOT NULL,
-		metadata TEXT,
-		FOREIGN KEY (document_path) REFERENCES documents(file_path),
-		FOREIGN KEY (created_by) REFERENCES operations(id),
-		FOREIGN KEY (modified_by) REFERENCES operations(id)
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_operations_timestamp ON operations(timestamp);
-	CREATE INDEX IF NOT EXISTS idx_operations_author ON operations(author);
-	CREATE INDEX IF NOT EXISTS idx_operations_type ON operations(type);
-	CREATE INDEX IF NOT EXISTS idx_constructs_document ON constructs(document_path);
-	CREATE INDEX IF NOT EXISTS idx_constructs_type ON constructs(type);
-	CREATE INDEX IF NOT EXISTS idx_constructs_position ON constructs(position_segments);
-	`
-
-	_, err = db.Exec(schema)
+	_, err = db.Exec(baseSchema)
 	if err != nil {
 		db.Close()
 		return nil, err
 	}
 
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if _, err := migrateSearch(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return db, nil
 }
 
 // Implement the Store interface by embedding SQLite operations
 
-func (cs *ContextStore) StoreOperation(op *operations.Operation) error {
-	positionJSON, err := json.Marshal(op.Position.Segments)
+func (cs *ContextStore) StoreOperation(ctx context.Context, op *operations.Operation) error {
+	b := newBlob(op.Content, DefaultBlobThreshold)
+	args, err := operationArgs(op, b)
 	if err != nil {
-		return fmt.Errorf("failed to marshal position: %w", err)
+		return err
 	}
 
-	parentsJSON, err := json.Marshal(op.Parents)
+	tx, err := cs.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to marshal parents: %w", err)
+		return err
 	}
+	defer tx.Rollback()
 
-	metadataJSON, err := json.Marshal(op.Metadata)
+	insertOp, err := tx.PrepareContext(ctx, insertOperationQuery)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
-	}
-
-	contentType := op.ContentType
-	if contentType == "" {
-		contentType = "text" // Default for backwards compatibility
-	}
-
-	query := `
-		INSERT OR REPLACE INTO operations 
-		(id, type, position_segments, content, content_type, length, author, timestamp, parents, metadata)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
-	_, err = cs.db.Exec(query,
-		string(op.ID),
-		string(op.Type),
-		string(positionJSON),
-		op.Content,
-		contentType,
-		op.Length,
-		string(op.Author),
-		op.Timestamp.Unix(),
-		string(parentsJSON),
-		string(metadataJSON),
-	)
+		return err
+	}
+	defer insertOp.Close()
 
-	return err
+	if err := storeOperationTx(ctx, tx, insertOp, args, b); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (cs *ContextStore) GetOperation(id operations.OperationID) (*operations.Operation, error) {
-	query := `
-		SELECT id, type, position_segments, content, content_type, length, author, timestamp, parents, metadata
-		FROM operations WHERE id = ?
-	`
+func (cs *ContextStore) GetOperation(ctx context.Context, id operations.OperationID) (*operations.Operation, error) {
+	query := selectOperationColumns + " WHERE id = ?"
 
-	row := cs.db.QueryRow(query, string(id))
-	return cs.scanOperation(row)
+	row := cs.db.QueryRowContext(ctx, query, string(id))
+	op, err := cs.scanOperation(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrOperationNotFound
+	}
+	return op, err
 }
 
-func (cs *ContextStore) GetOperations(ids []operations.OperationID) ([]*operations.Operation, error) {
+func (cs *ContextStore) GetOperations(ctx context.Context, ids []operations.OperationID) ([]*operations.Operation, error) {
 	if len(ids) == 0 {
 		return nil, nil
 	}
@@ -276,12 +318,12 @@ func (cs *ContextStore) GetOperations(ids []operations.OperationID) ([]*operatio
 	}
 
 	query := fmt.Sprintf(`
-		SELECT id, type, position_segments, content, content_type, length, author, timestamp, parents, metadata
+		SELECT %s
 		FROM operations WHERE id IN (%s)
-		ORDER BY timestamp
-	`, strings.Join(placeholders, ","))
+		ORDER BY clock, id
+	`, operationColumns, strings.Join(placeholders, ","))
 
-	rows, err := cs.db.Query(query, args...)
+	rows, err := cs.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -299,285 +341,218 @@ func (cs *ContextStore) GetOperations(ids []operations.OperationID) ([]*operatio
 	return result, rows.Err()
 }
 
-func (cs *ContextStore) GetOperationsSince(timestamp time.Time) ([]*operations.Operation, error) {
-	query := `
-		SELECT id, type, position_segments, content, content_type, length, author, timestamp, parents, metadata
-		FROM operations WHERE timestamp >= ?
-		ORDER BY timestamp
-	`
-
-	rows, err := cs.db.Query(query, timestamp.Unix())
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var result []*operations.Operation
-	for rows.Next() {
-		op, err := cs.scanOperation(rows)
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, op)
-	}
+func (cs *ContextStore) GetOperationsSince(ctx context.Context, timestamp time.Time) ([]*operations.Operation, error) {
+	return collectOperations(func(fn OperationFunc) error {
+		return cs.ForEachOperationSince(ctx, timestamp, fn)
+	})
+}
 
-	return result, rows.Err()
+func (cs *ContextStore) GetOperationsByAuthor(ctx context.Context, authorID operations.AuthorID) ([]*operations.Operation, error) {
+	return collectOperations(func(fn OperationFunc) error {
+		return cs.ForEachOperationByAuthor(ctx, authorID, fn)
+	})
 }
 
-func (cs *ContextStore) GetOperationsByAuthor(authorID operations.AuthorID) ([]*operations.Operation, error) {
-	query := `
-		SELECT id, type, position_segments, content, content_type, length, author, timestamp, parents, metadata
-		FROM operations WHERE author = ?
-		ORDER BY timestamp
-	`
+func (cs *ContextStore) ForEachOperationSince(ctx context.Context, timestamp time.Time, fn OperationFunc) error {
+	query := selectOperationColumns + " WHERE timestamp >= ? ORDER BY clock, id"
 
-	rows, err := cs.db.Query(query, string(authorID))
+	rows, err := cs.db.QueryContext(ctx, query, timestamp.UnixNano())
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	return forEachOperationRow(rows, cs.scanOperation, fn)
+}
 
-	var result []*operations.Operation
-	for rows.Next() {
-		op, err := cs.scanOperation(rows)
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, op)
-	}
+func (cs *ContextStore) ForEachOperationByAuthor(ctx context.Context, authorID operations.AuthorID, fn OperationFunc) error {
+	query := selectOperationColumns + " WHERE " + authorIdentity("author") + " ORDER BY clock, id"
 
-	return result, rows.Err()
+	rows, err := cs.db.QueryContext(ctx, query, identityArgs(authorID)...)
+	if err != nil {
+		return err
+	}
+	return forEachOperationRow(rows, cs.scanOperation, fn)
 }
 
-func (cs *ContextStore) DeleteOperation(id operations.OperationID) error {
-	_, err := cs.db.Exec("DELETE FROM operations WHERE id = ?", string(id))
+func (cs *ContextStore) DeleteOperation(ctx context.Context, id operations.OperationID) error {
+	_, err := cs.db.ExecContext(ctx, "DELETE FROM operations WHERE id = ?", string(id))
 	return err
 }
 
-func (cs *ContextStore) StoreDocument(doc *positioning.Document) error {
-	tx, err := cs.db.Begin()
+func (cs *ContextStore) StoreDocument(ctx context.Context, doc *positioning.Document) error {
+	record, err := NewDocumentRecord(doc)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	now := time.Now().Unix()
-	docQuery := `
-		INSERT OR REPLACE INTO documents 
-		(file_path, version, content_hash, last_operation, created_at, updated_at)
-		VALUES (?, ?, ?, ?, COALESCE((SELECT created_at FROM documents WHERE file_path = ?), ?), ?)
-	`
-
-	_, err = tx.Exec(docQuery,
-		doc.FilePath,
-		doc.Version,
-		fmt.Sprintf("%x", doc.ContentHash),
-		string(doc.LastOperation),
-		doc.FilePath,
-		now,
-		now,
-	)
+	tx, err := cs.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// Clear existing constructs
-	_, err = tx.Exec("DELETE FROM constructs WHERE document_path = ?", doc.FilePath)
-	if err != nil {
+	if err := writeDocumentRecord(ctx, tx, record); err != nil {
 		return err
 	}
-
-	// Insert new constructs
-	constructQuery := `
-		INSERT INTO constructs 
-		(id, document_path, position_segments, content, type, created_by, modified_by, metadata)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
-	for _, construct := range doc.Constructs {
-		positionJSON, err := json.Marshal(construct.Position.Segments)
-		if err != nil {
-			return fmt.Errorf("failed to marshal position: %w", err)
-		}
-
-		metadataJSON, err := json.Marshal(construct.Metadata)
-		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %w", err)
-		}
-
-		_, err = tx.Exec(constructQuery,
-			string(construct.ID),
-			doc.FilePath,
-			string(positionJSON),
-			construct.Content,
-			string(construct.Type),
-			string(construct.CreatedBy),
-			string(construct.ModifiedBy),
-			string(metadataJSON),
-		)
-		if err != nil {
-			return err
-		}
+	if err := indexDocumentTx(ctx, tx, doc); err != nil {
+		return err
 	}
 
 	return tx.Commit()
 }
 
-func (cs *ContextStore) GetDocument(filePath string) (*positioning.Document, error) {
-	docQuery := `
-		SELECT file_path, version, content_hash, last_operation
-		FROM documents WHERE file_path = ?
-	`
-
-	var doc positioning.Document
-	var contentHashStr string
-	var lastOpStr string
+func (cs *ContextStore) GetDocument(ctx context.Context, filePath string) (*positioning.Document, error) {
+	return loadDocument(ctx, cs.db, filePath, false)
+}
 
-	err := cs.db.QueryRow(docQuery, filePath).Scan(
-		&doc.FilePath,
-		&doc.Version,
-		&contentHashStr,
-		&lastOpStr,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, ErrDocumentNotFound
-		}
-		return nil, err
+func (cs *ContextStore) GetRetentionPolicy() (RetentionPolicy, error) {
+	manifest := cs.currentManifest()
+	if manifest.Retention == nil {
+		return RetentionPolicy{}, nil
 	}
+	return *manifest.Retention, nil
+}
 
-	doc.Constructs = make(map[operations.PositionKey]*positioning.Construct)
-	doc.PositionIndex = make(map[operations.PositionKey]operations.LogootPosition)
-	doc.AppliedOps = make(map[operations.OperationID]bool)
-	doc.PositionIdx = make([]operations.LogootPosition, 0)
+func (cs *ContextStore) SetRetentionPolicy(policy RetentionPolicy) error {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
 
-	doc.LastOperation = operations.OperationID(lastOpStr)
+	if cs.readOnly {
+		return ErrReadOnly
+	}
 
-	// Load constructs
-	constructQuery := `
-		SELECT id, position_segments, content, type, created_by, modified_by, metadata
-		FROM constructs WHERE document_path = ?
-		ORDER BY position_segments
-	`
+	return cs.updateManifest(func(manifest *Manifest) {
+		manifest.Retention = &policy
+	})
+}
 
-	rows, err := cs.db.Query(constructQuery, filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// ReadOnly reports whether the store was opened with OpenContextStoreReadOnly
+func (cs *ContextStore) ReadOnly() bool {
+	return cs.readOnly
+}
 
-	for rows.Next() {
-		var construct positioning.Construct
-		var positionJSON string
-		var metadataJSON string
-		var createdByStr string
-		var modifiedByStr string
-
-		err := rows.Scan(
-			&construct.ID,
-			&positionJSON,
-			&construct.Content,
-			&construct.Type,
-			&createdByStr,
-			&modifiedByStr,
-			&metadataJSON,
-		)
-		if err != nil {
-			return nil, err
-		}
+func (cs *ContextStore) PurgeOperationsBefore(ctx context.Context, cutoff time.Time, dryRun bool) ([]operations.OperationID, error) {
+	return purgeOperationsBefore(ctx, cs.db, cutoff, dryRun)
+}
 
-		var segments []operations.PositionSegment
-		if err := json.Unmarshal([]byte(positionJSON), &segments); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal position: %w", err)
-		}
-		construct.Position = operations.NewLogootPosition(segments)
+func (cs *ContextStore) PurgedOperations(ctx context.Context, ids []operations.OperationID) ([]operations.OperationID, error) {
+	return purgedOperations(ctx, cs.db, ids)
+}
 
-		if err := json.Unmarshal([]byte(metadataJSON), &construct.Metadata); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
-		}
+func (cs *ContextStore) Close() error {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
 
-		construct.CreatedBy = operations.OperationID(createdByStr)
-		construct.ModifiedBy = operations.OperationID(modifiedByStr)
+	if cs.readOnly {
+		return cs.db.Close()
+	}
 
-		posKey := construct.Position.Key()
-		doc.Constructs[posKey] = &construct
-		doc.PositionIndex[posKey] = construct.Position
-		doc.PositionIdx = append(doc.PositionIdx, construct.Position)
+	// Update manifest one last time
+	if err := cs.updateManifest(func(*Manifest) {}); err != nil {
+		// Log but don't fail on manifest update
 	}
 
-	return &doc, rows.Err()
+	err := cs.db.Close()
+	if cs.claim != nil {
+		cs.claim.Unlock()
+	}
+	return err
 }
 
-func (cs *ContextStore) ListDocuments() ([]string, error) {
-	query := "SELECT file_path FROM documents ORDER BY file_path"
-	rows, err := cs.db.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// currentManifest returns the manifest, first rereading it if another process
+// has saved it since. Callers must not modify it.
+func (cs *ContextStore) currentManifest() *Manifest {
+	manifestPath := filepath.Join(cs.basePath, ManifestFile)
 
-	var documents []string
-	for rows.Next() {
-		var filePath string
-		if err := rows.Scan(&filePath); err != nil {
-			return nil, err
-		}
-		documents = append(documents, filePath)
+	cs.mutex.RLock()
+	manifest, changed := cs.manifest, cs.manifestVersion.Changed(manifestPath)
+	cs.mutex.RUnlock()
+	if !changed {
+		return manifest
 	}
 
-	return documents, rows.Err()
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	if cs.manifestVersion.Changed(manifestPath) {
+		cs.reloadManifest() // Keep the manifest already read if the file can't be
+	}
+	return cs.manifest
 }
 
-func (cs *ContextStore) DeleteDocument(filePath string) error {
-	tx, err := cs.db.Begin()
+// updateManifest applies change to a copy of the manifest and saves it,
+// holding the manifest's lock and rereading it first so a change another
+// process saved meanwhile is kept rather than overwritten. The caller holds
+// cs.mutex.
+func (cs *ContextStore) updateManifest(change func(manifest *Manifest)) error {
+	lock, err := filelock.Acquire(filepath.Join(cs.basePath, ManifestFile))
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to lock manifest: %w", err)
 	}
-	defer tx.Rollback()
+	defer lock.Unlock()
 
-	_, err = tx.Exec("DELETE FROM constructs WHERE document_path = ?", filePath)
-	if err != nil {
-		return err
+	if err := cs.reloadManifest(); err != nil {
+		return fmt.Errorf("failed to reread manifest: %w", err)
 	}
+	manifest := *cs.manifest
+	change(&manifest)
+	manifest.LastModified = time.Now()
+	return cs.saveManifest(&manifest)
+}
 
-	_, err = tx.Exec("DELETE FROM documents WHERE file_path = ?", filePath)
+// reloadManifest reads the manifest, noting its version first so a save
+// racing the read is caught by the next check rather than missed
+func (cs *ContextStore) reloadManifest() error {
+	manifestPath := filepath.Join(cs.basePath, ManifestFile)
+	version, err := filelock.Stat(manifestPath)
 	if err != nil {
 		return err
 	}
-
-	return tx.Commit()
+	var manifest Manifest
+	if err := readJSON(manifestPath, &manifest); err != nil {
+		return err
+	}
+	cs.manifest = &manifest
+	cs.manifestVersion = version
+	return nil
 }
 
-func (cs *ContextStore) Close() error {
-	// Update manifest one last time
-	cs.manifest.LastModified = time.Now()
+// saveManifest writes manifest and makes it the current one
+func (cs *ContextStore) saveManifest(manifest *Manifest) error {
 	manifestPath := filepath.Join(cs.basePath, ManifestFile)
-	if err := writeJSON(manifestPath, cs.manifest); err != nil {
-		// Log but don't fail on manifest update
+	if err := writeJSON(manifestPath, manifest); err != nil {
+		return err
 	}
-
-	return cs.db.Close()
+	version, err := filelock.Stat(manifestPath)
+	if err != nil {
+		return err
+	}
+	cs.manifest = manifest
+	cs.manifestVersion = version
+	return nil
 }
 
 func (cs *ContextStore) scanOperation(scanner interface {
 	Scan(dest ...interface{}) error
 }) (*operations.Operation, error) {
 	var op operations.Operation
-	var idStr, positionJSON, parentsJSON, metadataJSON string
+	var idStr, parentsJSON, metadataJSON string
+	var position []byte
 	var contentType string
-	var timestampUnix int64
+	var timestampNanos int64
+	var offset int
 
 	err := scanner.Scan(
 		&idStr,
 		&op.Type,
-		&positionJSON,
+		&position,
 		&op.Content,
 		&contentType,
 		&op.Length,
 		&op.Author,
-		&timestampUnix,
+		&timestampNanos,
 		&parentsJSON,
 		&metadataJSON,
+		&op.Clock,
+		&offset,
 	)
 	if err != nil {
 		return nil, err
@@ -585,13 +560,12 @@ func (cs *ContextStore) scanOperation(scanner interface {
 
 	op.ID = operations.OperationID(idStr)
 	op.ContentType = contentType
-	op.Timestamp = time.Unix(timestampUnix, 0)
+	op.Timestamp = operationTime(timestampNanos, offset)
 
-	var segments []operations.PositionSegment
-	if err := json.Unmarshal([]byte(positionJSON), &segments); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal position: %w", err)
+	op.Position, err = decodePosition(position)
+	if err != nil {
+		return nil, err
 	}
-	op.Position = operations.NewLogootPosition(segments)
 
 	if err := json.Unmarshal([]byte(parentsJSON), &op.Parents); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal parents: %w", err)
@@ -605,16 +579,13 @@ func (cs *ContextStore) scanOperation(scanner interface {
 }
 
 // Helper functions
+// writeJSON replaces filePath atomically, so no reader sees it half written
 func writeJSON(filePath string, data interface{}) error {
-	file, err := os.Create(filePath)
+	encoded, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(data)
+	return filelock.WriteFile(filePath, append(encoded, '\n'), 0644)
 }
 
 func readJSON(filePath string, data interface{}) error {
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestContextStore_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	if _, err := OpenContextStoreReadOnly(dir); err == nil {
+		t.Fatal("Expected no store to open read-only before one exists")
+	}
+
+	writer, err := NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer writer.Close()
+
+	newOp := func(content string) *operations.Operation {
+		return &operations.Operation{
+			ID:   operations.NewOperationID([]byte(content)),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(1), AuthorID: "alice"},
+			}),
+			Content:   content,
+			Author:    "alice",
+			Timestamp: time.Now(),
+			Metadata:  operations.OperationMeta{DocumentID: "main.go"},
+		}
+	}
+	first := newOp("first")
+	if err := writer.StoreOperation(ctx, first); err != nil {
+		t.Fatalf("Failed to store operation: %v", err)
+	}
+
+	manifestPath := filepath.Join(dir, ContextDir, ManifestFile)
+	before, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+
+	reader, err := OpenContextStoreReadOnly(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store read-only: %v", err)
+	}
+	if !reader.ReadOnly() || writer.ReadOnly() {
+		t.Error("Expected only the read-only store to say so")
+	}
+	if _, err := reader.GetOperation(ctx, first.ID); err != nil {
+		t.Fatalf("Failed to read operation: %v", err)
+	}
+	if err := reader.StoreOperation(ctx, newOp("refused")); err == nil {
+		t.Error("Expected storing through a read-only store to fail")
+	}
+	if err := reader.SetRetentionPolicy(RetentionPolicy{}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+
+	// Writes made elsewhere are read as they land
+	second := newOp("second")
+	if err := writer.StoreOperation(ctx, second); err != nil {
+		t.Fatalf("Failed to store operation: %v", err)
+	}
+	if _, err := reader.GetOperation(ctx, second.ID); err != nil {
+		t.Errorf("Expected the read-only store to see the new operation: %v", err)
+	}
+
+	if err := reader.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	after, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if string(after) != string(before) {
+		t.Error("Expected the manifest untouched by the read-only store")
+	}
+}
+
+// Two stores on one directory stand in for two processes sharing it
+func TestContextStore_SharedManifest(t *testing.T) {
+	dir := t.TempDir()
+	first, err := NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer first.Close()
+	second, err := NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	policy := RetentionPolicy{Operations: Duration(24 * time.Hour)}
+	if err := first.SetRetentionPolicy(policy); err != nil {
+		t.Fatalf("Failed to set retention policy: %v", err)
+	}
+	got, err := second.GetRetentionPolicy()
+	if err != nil {
+		t.Fatalf("Failed to get retention policy: %v", err)
+	}
+	if got.Operations != policy.Operations {
+		t.Errorf("Expected the other store's policy, got %+v", got)
+	}
+
+	// Closing rewrites the manifest, but mustn't undo the other store's policy
+	if err := second.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	var manifest Manifest
+	if err := readJSON(filepath.Join(dir, ContextDir, ManifestFile), &manifest); err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	if manifest.Retention == nil || manifest.Retention.Operations != policy.Operations {
+		t.Errorf("Expected the policy kept in the manifest, got %+v", manifest.Retention)
+	}
+}
+
+func TestContextStore_ReadOnlyNeedsMigration(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.Close()
+
+	// A store with only the schema every store starts from
+	dbPath := filepath.Join(dir, ContextDir, DatabaseFile)
+	removeDatabaseFiles(dbPath)
+	db, err := sql.Open(sqliteDriver, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.Exec(baseSchema); err != nil {
+		t.Fatalf("Failed to create baseline store: %v", err)
+	}
+	db.Close()
+
+	if _, err := OpenContextStoreReadOnly(dir); !errors.Is(err, ErrNeedsMigration) {
+		t.Fatalf("Expected ErrNeedsMigration opening a baseline store read-only, got %v", err)
+	}
+
+	// Opening it read-write once migrates it
+	store, err = NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to migrate store: %v", err)
+	}
+	store.Close()
+	reader, err := OpenContextStoreReadOnly(dir)
+	if err != nil {
+		t.Fatalf("Failed to open migrated store read-only: %v", err)
+	}
+	defer reader.Close()
+	if _, err := reader.GetOperation(ctx, "missing"); !errors.Is(err, ErrOperationNotFound) {
+		t.Errorf("Expected ErrOperationNotFound from a migrated store, got %v", err)
+	}
+}
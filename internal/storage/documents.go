@@ -0,0 +1,266 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+const constructColumns = "id, position, content, type, created_by, modified_by, metadata"
+
+// DocumentInfo describes a document without loading its constructs
+type DocumentInfo struct {
+	FilePath  string     `json:"file_path"`
+	Version   uint64     `json:"version"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+func (cs *ContextStore) ListDocumentInfo(ctx context.Context, prefix string, includeDeleted bool) ([]DocumentInfo, error) {
+	return listDocumentInfo(ctx, cs.db, prefix, includeDeleted)
+}
+
+func (s *SQLiteStore) ListDocumentInfo(ctx context.Context, prefix string, includeDeleted bool) ([]DocumentInfo, error) {
+	return listDocumentInfo(ctx, s.db, prefix, includeDeleted)
+}
+
+// DocumentStats sums up a document from aggregates over its constructs and
+// main branch operations, without loading either
+type DocumentStats struct {
+	// Constructs counts the document's constructs by type
+	Constructs map[positioning.ConstructType]int `json:"constructs"`
+	// Size is the length in bytes of the document rendered
+	Size         int64      `json:"size"`
+	Authors      int        `json:"authors"`
+	LastActivity *time.Time `json:"last_activity,omitempty"`
+}
+
+func (cs *ContextStore) GetDocumentStats(ctx context.Context, filePath string) (*DocumentStats, error) {
+	return getDocumentStats(ctx, cs.db, filePath)
+}
+
+func (s *SQLiteStore) GetDocumentStats(ctx context.Context, filePath string) (*DocumentStats, error) {
+	return getDocumentStats(ctx, s.db, filePath)
+}
+
+func (cs *ContextStore) ListDocumentStats(ctx context.Context, prefix string) (map[string]*DocumentStats, error) {
+	return documentStats(ctx, cs.db, pathPrefix(prefix))
+}
+
+func (s *SQLiteStore) ListDocumentStats(ctx context.Context, prefix string) (map[string]*DocumentStats, error) {
+	return documentStats(ctx, s.db, pathPrefix(prefix))
+}
+
+func (cs *ContextStore) GetConstructsInRange(ctx context.Context, filePath string, start, end operations.LogootPosition) ([]*positioning.Construct, error) {
+	return constructsInRange(ctx, cs.db, filePath, start, end)
+}
+
+func (s *SQLiteStore) GetConstructsInRange(ctx context.Context, filePath string, start, end operations.LogootPosition) ([]*positioning.Construct, error) {
+	return constructsInRange(ctx, s.db, filePath, start, end)
+}
+
+// constructsInRange reads a document's constructs from start to end
+// inclusive as a range over the (document_path, position) index. A start
+// without segments leaves the range open at the beginning, and an end
+// without segments at the end.
+func constructsInRange(ctx context.Context, db *sql.DB, filePath string, start, end operations.LogootPosition) ([]*positioning.Construct, error) {
+	var deletedAt sql.NullInt64
+	err := db.QueryRowContext(ctx, "SELECT deleted_at FROM documents WHERE file_path = ?", filePath).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		return nil, ErrDocumentDeleted
+	}
+
+	from, err := encodePosition(start)
+	if err != nil {
+		return nil, err
+	}
+	query := "SELECT " + constructColumns + " FROM constructs WHERE document_path = ? AND position >= ?"
+	args := []interface{}{filePath, from}
+	if len(end.Segments) > 0 {
+		to, err := encodePosition(end)
+		if err != nil {
+			return nil, err
+		}
+		query += " AND position <= ?"
+		args = append(args, to)
+	}
+	query += " ORDER BY position"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	constructs := []*positioning.Construct{}
+	for rows.Next() {
+		record, err := scanConstructRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		construct, err := record.Construct()
+		if err != nil {
+			return nil, err
+		}
+		constructs = append(constructs, construct)
+	}
+	return constructs, rows.Err()
+}
+
+// listDocumentInfo reads the documents whose paths start with prefix, in path
+// order. The prefix is matched as a range on the primary key rather than with
+// LIKE, so it needs no escaping and uses the index.
+func listDocumentInfo(ctx context.Context, db *sql.DB, prefix string, includeDeleted bool) ([]DocumentInfo, error) {
+	query := "SELECT file_path, version, updated_at, deleted_at FROM documents WHERE file_path >= ?"
+	args := []interface{}{prefix}
+	if end, ok := prefixEnd(prefix); ok {
+		query += " AND file_path < ?"
+		args = append(args, end)
+	}
+	if !includeDeleted {
+		query += " AND deleted_at IS NULL"
+	}
+	query += " ORDER BY file_path"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []DocumentInfo
+	for rows.Next() {
+		var info DocumentInfo
+		var updatedAt int64
+		var deletedAt sql.NullInt64
+		if err := rows.Scan(&info.FilePath, &info.Version, &updatedAt, &deletedAt); err != nil {
+			return nil, err
+		}
+		info.UpdatedAt = time.Unix(updatedAt, 0).UTC()
+		if deletedAt.Valid {
+			at := time.Unix(deletedAt.Int64, 0).UTC()
+			info.DeletedAt = &at
+		}
+		documents = append(documents, info)
+	}
+
+	return documents, rows.Err()
+}
+
+func getDocumentStats(ctx context.Context, db *sql.DB, filePath string) (*DocumentStats, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM documents WHERE file_path = ?)", filePath).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrDocumentNotFound
+	}
+
+	stats, err := documentStats(ctx, db, func(column string) (string, []interface{}) {
+		return column + " = ?", []interface{}{filePath}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if document, exists := stats[filePath]; exists {
+		return document, nil
+	}
+	return &DocumentStats{Constructs: make(map[positioning.ConstructType]int)}, nil
+}
+
+// pathPrefix matches a path column against prefix as a range, as
+// listDocumentInfo does
+func pathPrefix(prefix string) func(column string) (string, []interface{}) {
+	return func(column string) (string, []interface{}) {
+		condition, args := column+" >= ?", []interface{}{prefix}
+		if end, ok := prefixEnd(prefix); ok {
+			condition += " AND " + column + " < ?"
+			args = append(args, end)
+		}
+		return condition, args
+	}
+}
+
+// documentStats aggregates the constructs and main branch operations of the
+// documents whose path column paths picks, by path. Authors count once
+// however many of their merged IDs wrote to the document. A document with
+// neither constructs nor operations is left out.
+func documentStats(ctx context.Context, db *sql.DB, paths func(column string) (string, []interface{})) (map[string]*DocumentStats, error) {
+	stats := make(map[string]*DocumentStats)
+	statsFor := func(filePath string) *DocumentStats {
+		if _, exists := stats[filePath]; !exists {
+			stats[filePath] = &DocumentStats{Constructs: make(map[positioning.ConstructType]int)}
+		}
+		return stats[filePath]
+	}
+
+	condition, args := paths("document_path")
+	rows, err := db.QueryContext(ctx, `SELECT document_path, type, COUNT(*), SUM(LENGTH(CAST(content AS BLOB)))
+		FROM constructs WHERE `+condition+` GROUP BY document_path, type`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var filePath string
+		var constructType positioning.ConstructType
+		var count int
+		var size sql.NullInt64
+		if err := rows.Scan(&filePath, &constructType, &count, &size); err != nil {
+			return nil, err
+		}
+		document := statsFor(filePath)
+		document.Constructs[constructType] = count
+		document.Size += size.Int64
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	condition, args = paths("operations.document_id")
+	rows, err = db.QueryContext(ctx, `SELECT operations.document_id,
+			COUNT(DISTINCT COALESCE(author_aliases.author, operations.author)), MAX(operations.timestamp)
+		FROM operations LEFT JOIN author_aliases ON author_aliases.alias = operations.author
+		WHERE `+condition+` AND operations.branch IN ('', ?)
+		GROUP BY operations.document_id`, append(args, operations.MainBranch)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var filePath string
+		var authors int
+		var latest int64
+		if err := rows.Scan(&filePath, &authors, &latest); err != nil {
+			return nil, err
+		}
+		document := statsFor(filePath)
+		document.Authors = authors
+		at := time.Unix(0, latest).UTC()
+		document.LastActivity = &at
+	}
+	return stats, rows.Err()
+}
+
+// prefixEnd is the smallest string greater than every string starting with
+// prefix. There is none when prefix is empty or all 0xff bytes.
+func prefixEnd(prefix string) (string, bool) {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1]), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+func TestSQLiteStore_ListDocumentInfo(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	for _, filePath := range []string{"src/a.go", "src/net/dial.go", "src0.go", "srcs/b.go", "main.go"} {
+		doc := positioning.NewDocument(filePath)
+		doc.Version = 2
+		if err := store.StoreDocument(ctx, doc); err != nil {
+			t.Fatalf("Failed to store document: %v", err)
+		}
+	}
+	if err := store.DeleteDocument(ctx, "src/a.go"); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+
+	infos, err := store.ListDocumentInfo(ctx, "src/", true)
+	if err != nil {
+		t.Fatalf("Failed to list documents: %v", err)
+	}
+	if len(infos) != 2 || infos[0].FilePath != "src/a.go" || infos[1].FilePath != "src/net/dial.go" {
+		t.Fatalf("Expected only the documents under src/, got %+v", infos)
+	}
+	if infos[0].DeletedAt == nil || infos[1].DeletedAt != nil || infos[1].Version != 2 || infos[1].UpdatedAt.IsZero() {
+		t.Errorf("Unexpected document info %+v", infos)
+	}
+
+	if infos, _ := store.ListDocumentInfo(ctx, "src/", false); len(infos) != 1 {
+		t.Errorf("Expected deleted documents to be left out, got %+v", infos)
+	}
+	if infos, _ := store.ListDocumentInfo(ctx, "", false); len(infos) != 4 {
+		t.Errorf("Expected every live document with no prefix, got %+v", infos)
+	}
+}
+
+func TestSQLiteStore_GetConstructsInRange(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	position := func(values ...int64) operations.LogootPosition {
+		segments := make([]operations.PositionSegment, len(values))
+		for i, value := range values {
+			segments[i] = operations.PositionSegment{Value: big.NewInt(value), AuthorID: "alice"}
+		}
+		return operations.NewLogootPosition(segments)
+	}
+
+	doc := positioning.NewDocument("main.go")
+	for i, pos := range []operations.LogootPosition{position(-5), position(1), position(1, 7), position(2), position(300)} {
+		doc.InsertConstruct(&positioning.Construct{
+			ID:       positioning.ConstructID(fmt.Sprintf("c%d", i)),
+			Content:  pos.String(),
+			Type:     positioning.ConstructContent,
+			Position: pos,
+		})
+	}
+	if err := store.StoreDocument(ctx, doc); err != nil {
+		t.Fatalf("Failed to store document: %v", err)
+	}
+
+	ranges := []struct {
+		start, end operations.LogootPosition
+		expected   []string
+	}{
+		{position(1), position(2), []string{"1:alice", "1:alice.7:alice", "2:alice"}},
+		{position(-10), position(0), []string{"-5:alice"}},
+		{position(2), operations.LogootPosition{}, []string{"2:alice", "300:alice"}},
+		{position(3), position(299), nil},
+	}
+	for _, r := range ranges {
+		constructs, err := store.GetConstructsInRange(ctx, "main.go", r.start, r.end)
+		if err != nil {
+			t.Fatalf("Failed to get constructs in range: %v", err)
+		}
+		var contents []string
+		for _, construct := range constructs {
+			contents = append(contents, construct.Content)
+		}
+		if fmt.Sprint(contents) != fmt.Sprint(r.expected) {
+			t.Errorf("Expected %v between %s and %s, got %v", r.expected, r.start, r.end, contents)
+		}
+	}
+
+	if _, err := store.GetConstructsInRange(ctx, "missing.go", position(1), position(2)); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+func TestSQLiteStore_DocumentStats(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	doc := positioning.NewDocument("src/main.go")
+	for i, construct := range []struct {
+		content string
+		kind    positioning.ConstructType
+	}{{"package main\n", positioning.ConstructContent}, {"// héllo\n", positioning.ConstructDocumentation}, {"func main() {}\n", positioning.ConstructContent}} {
+		doc.InsertConstruct(&positioning.Construct{
+			ID:       positioning.ConstructID(fmt.Sprintf("c%d", i)),
+			Content:  construct.content,
+			Type:     construct.kind,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{{Value: big.NewInt(int64(i)), AuthorID: "alice"}}),
+		})
+	}
+	for _, d := range []*positioning.Document{doc, positioning.NewDocument("src/empty.go"), positioning.NewDocument("srcs/other.go")} {
+		if err := store.StoreDocument(ctx, d); err != nil {
+			t.Fatalf("Failed to store document: %v", err)
+		}
+	}
+
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, op := range []struct {
+		author operations.AuthorID
+		branch string
+	}{{"alice", ""}, {"alice@laptop", ""}, {"bob", "main"}, {"carol", "feature"}} {
+		stored := &operations.Operation{
+			ID:        operations.OperationID(fmt.Sprintf("op%d", i)),
+			Type:      operations.OpInsert,
+			Position:  operations.NewLogootPosition(nil),
+			Author:    op.author,
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Parents:   []operations.OperationID{},
+			Metadata:  operations.OperationMeta{DocumentID: "src/main.go", Branch: op.branch},
+		}
+		if err := store.StoreOperation(ctx, stored); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+	}
+	if err := store.MergeAuthors(ctx, "alice", []operations.AuthorID{"alice@laptop"}); err != nil {
+		t.Fatalf("Failed to merge authors: %v", err)
+	}
+
+	stats, err := store.GetDocumentStats(ctx, "src/main.go")
+	if err != nil {
+		t.Fatalf("Failed to get document stats: %v", err)
+	}
+	if stats.Constructs[positioning.ConstructContent] != 2 || stats.Constructs[positioning.ConstructDocumentation] != 1 {
+		t.Errorf("Expected constructs counted by type, got %v", stats.Constructs)
+	}
+	if rendered, _ := doc.Render(); stats.Size != int64(len(rendered)) {
+		t.Errorf("Expected the rendered size %d, got %d", len(rendered), stats.Size)
+	}
+	// alice's merged IDs count once, and carol's branch not at all
+	if stats.Authors != 2 {
+		t.Errorf("Expected 2 authors, got %d", stats.Authors)
+	}
+	if stats.LastActivity == nil || !stats.LastActivity.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("Expected the last main branch operation's time, got %v", stats.LastActivity)
+	}
+
+	empty, err := store.GetDocumentStats(ctx, "src/empty.go")
+	if err != nil {
+		t.Fatalf("Failed to get document stats: %v", err)
+	}
+	if len(empty.Constructs) != 0 || empty.Size != 0 || empty.Authors != 0 || empty.LastActivity != nil {
+		t.Errorf("Expected empty stats, got %+v", empty)
+	}
+	if _, err := store.GetDocumentStats(ctx, "missing.go"); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound, got %v", err)
+	}
+
+	listed, err := store.ListDocumentStats(ctx, "src/")
+	if err != nil {
+		t.Fatalf("Failed to list document stats: %v", err)
+	}
+	if len(listed) != 1 || listed["src/main.go"] == nil || listed["src/main.go"].Authors != 2 {
+		t.Errorf("Expected stats for only src/main.go, got %v", listed)
+	}
+}
@@ -7,4 +7,41 @@ var (
 	ErrDocumentNotFound  = errors.New("document not found")
 	ErrStoreClosed       = errors.New("store is closed")
 	ErrInvalidData       = errors.New("invalid data format")
+	ErrVectorNotFound    = errors.New("vector not found")
+	// ErrDocumentDeleted is also ErrDocumentNotFound, so callers that skip
+	// missing documents skip deleted ones too
+	ErrDocumentDeleted    error = documentDeletedError{}
+	ErrDocumentNotDeleted       = errors.New("document is not deleted")
+	// ErrUnsupportedRecordVersion is returned for documents stored in a
+	// layout newer than this build reads
+	ErrUnsupportedRecordVersion = errors.New("unsupported document record version")
+	ErrBranchNotFound           = errors.New("branch not found")
+	ErrBranchExists             = errors.New("branch already exists")
+	// ErrBranchMerged is returned for changes to a branch that was merged,
+	// which closes it
+	ErrBranchMerged = errors.New("branch is merged")
+	// ErrSettingNotFound is returned for settings that were never saved
+	ErrSettingNotFound = errors.New("setting not found")
+	// ErrAnalysisNotFound is returned for operations whose intent was never
+	// analyzed by a batch job
+	ErrAnalysisNotFound = errors.New("analysis not found")
+	// ErrReadOnly is returned for changes to a store opened read-only
+	ErrReadOnly = errors.New("store is read-only")
+	// ErrAliasNotFound is returned for author IDs that aren't an alias
+	ErrAliasNotFound = errors.New("author alias not found")
+	// ErrStoreInUse is returned for claims on a store another process has
+	// claimed to serve
+	ErrStoreInUse = errors.New("store is in use by another instance")
+	// ErrNeedsMigration is returned for a store opened read-only before this
+	// version has migrated it
+	ErrNeedsMigration = errors.New("store needs migrating, open it read-write once")
 )
+
+type documentDeletedError struct{}
+
+func (documentDeletedError) Error() string { return "document is deleted" }
+
+func (documentDeletedError) Is(target error) bool { return target == ErrDocumentNotFound }
+
+// ErrStopIteration can be returned from an iteration callback to end it early without error
+var ErrStopIteration = errors.New("stop iteration")
@@ -0,0 +1,467 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+// SchemaVersion is the version of the database schema recorded in the manifest
+const SchemaVersion = "1.0"
+
+type ProblemKind string
+
+const (
+	// ProblemIntegrity is corruption SQLite itself found
+	ProblemIntegrity ProblemKind = "integrity"
+	// ProblemSchema is a table or column the store needs that is missing
+	ProblemSchema ProblemKind = "schema"
+	// ProblemManifest is a manifest that doesn't describe the database
+	ProblemManifest ProblemKind = "manifest"
+	// ProblemMissingParent is an operation whose parent is not in the store
+	ProblemMissingParent ProblemKind = "missing_parent"
+	// ProblemMissingBlob is an operation whose externalized content is gone
+	ProblemMissingBlob ProblemKind = "missing_blob"
+	// ProblemOrphanedBlob is content no operation refers to
+	ProblemOrphanedBlob ProblemKind = "orphaned_blob"
+	// ProblemOrphanedConstruct is a construct of a document that doesn't exist
+	ProblemOrphanedConstruct ProblemKind = "orphaned_construct"
+	// ProblemDanglingConstruct is a construct created or modified by an operation not in the store
+	ProblemDanglingConstruct ProblemKind = "dangling_construct"
+	// ProblemDanglingDocument is a document whose last operation is not in the store
+	ProblemDanglingDocument ProblemKind = "dangling_document"
+	// ProblemContentHash is a document whose stored hash doesn't match its rendered content
+	ProblemContentHash ProblemKind = "content_hash"
+)
+
+// Problem is one inconsistency found by a check
+type Problem struct {
+	Kind ProblemKind `json:"kind"`
+	// Subject is the operation ID, document path, construct ID, blob hash or
+	// file the problem was found in
+	Subject  string `json:"subject"`
+	Message  string `json:"message"`
+	Repaired bool   `json:"repaired,omitempty"`
+}
+
+// CheckReport is the result of verifying a store
+type CheckReport struct {
+	Operations int       `json:"operations"`
+	Documents  int       `json:"documents"`
+	Constructs int       `json:"constructs"`
+	Problems   []Problem `json:"problems"`
+}
+
+// Clean reports whether every problem found, if any, was repaired
+func (r *CheckReport) Clean() bool {
+	for _, problem := range r.Problems {
+		if !problem.Repaired {
+			return false
+		}
+	}
+	return true
+}
+
+// add records a problem and returns its index, to mark it repaired later
+func (r *CheckReport) add(kind ProblemKind, subject, format string, args ...interface{}) int {
+	r.Problems = append(r.Problems, Problem{Kind: kind, Subject: subject, Message: fmt.Sprintf(format, args...)})
+	return len(r.Problems) - 1
+}
+
+func (r *CheckReport) repaired(problems []int) {
+	for _, i := range problems {
+		r.Problems[i].Repaired = true
+	}
+}
+
+// Check verifies the manifest and database of the store. With repair set,
+// orphans are removed: parent references to missing operations, constructs
+// of missing documents and unreferenced blobs. Other problems are only reported.
+func (cs *ContextStore) Check(ctx context.Context, repair bool) (*CheckReport, error) {
+	report := &CheckReport{Problems: []Problem{}}
+	cs.checkManifest(report)
+	if err := checkDatabase(ctx, cs.db, cs.GetDocument, repair, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (s *SQLiteStore) Check(ctx context.Context, repair bool) (*CheckReport, error) {
+	report := &CheckReport{Problems: []Problem{}}
+	if err := checkDatabase(ctx, s.db, s.GetDocument, repair, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// checkManifest compares the manifest on disk with what the store was opened with
+func (cs *ContextStore) checkManifest(report *CheckReport) {
+	var manifest Manifest
+	if err := readJSON(filepath.Join(cs.basePath, ManifestFile), &manifest); err != nil {
+		report.add(ProblemManifest, ManifestFile, "failed to read manifest: %v", err)
+		return
+	}
+
+	if !isCompatibleVersion(manifest.Version) {
+		report.add(ProblemManifest, ManifestFile, "version %s is not %s", manifest.Version, CompatibleVersions)
+	}
+	if manifest.SchemaVersion != SchemaVersion {
+		report.add(ProblemManifest, ManifestFile, "schema version %s is not %s", manifest.SchemaVersion, SchemaVersion)
+	}
+	if manifest.StorageType != "sqlite" {
+		report.add(ProblemManifest, ManifestFile, "storage type %q is not sqlite", manifest.StorageType)
+	}
+	if _, err := os.Stat(filepath.Join(cs.basePath, manifest.DatabaseFile)); manifest.DatabaseFile == "" || err != nil {
+		report.add(ProblemManifest, ManifestFile, "database file %q does not exist", manifest.DatabaseFile)
+	}
+}
+
+// schemaColumns are the columns every query relies on, by table
+var schemaColumns = map[string][]string{
+	"operations": {"id", "type", "position_segments", "position", "content", "content_type", "length", "author", "timestamp", "parents", "metadata", "blob_hash", "document_id", "branch", "tool", "ticket", "language", "clock", "utc_offset"},
+	"documents":  {"file_path", "version", "content_hash", "last_operation", "record_version", "created_at", "updated_at"},
+	"constructs": {"id", "document_path", "position_segments", "position", "content", "type", "created_by", "modified_by", "metadata"},
+	"blobs":      {"hash", "content", "size", "created_at"},
+}
+
+func checkDatabase(ctx context.Context, db *sql.DB, getDocument func(context.Context, string) (*positioning.Document, error), repair bool, report *CheckReport) error {
+	if err := checkIntegrity(ctx, db, report); err != nil {
+		return err
+	}
+
+	// The remaining checks query these tables, so a broken schema ends the check here
+	schemaOK, err := checkSchema(ctx, db, report)
+	if err != nil || !schemaOK {
+		return err
+	}
+
+	if err := checkParents(ctx, db, repair, report); err != nil {
+		return err
+	}
+	if err := checkBlobs(ctx, db, repair, report); err != nil {
+		return err
+	}
+	if err := checkConstructs(ctx, db, repair, report); err != nil {
+		return err
+	}
+	return checkDocuments(ctx, db, getDocument, report)
+}
+
+func checkIntegrity(ctx context.Context, db *sql.DB, report *CheckReport) error {
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return err
+		}
+		if result != "ok" {
+			report.add(ProblemIntegrity, DatabaseFile, "%s", result)
+		}
+	}
+	return rows.Err()
+}
+
+func checkSchema(ctx context.Context, db *sql.DB, report *CheckReport) (bool, error) {
+	ok := true
+	for _, table := range []string{"operations", "documents", "constructs", "blobs"} {
+		columns, err := tableColumns(ctx, db, table)
+		if err != nil {
+			return false, err
+		}
+		if len(columns) == 0 {
+			report.add(ProblemSchema, table, "table %s is missing", table)
+			ok = false
+			continue
+		}
+		for _, column := range schemaColumns[table] {
+			if !columns[column] {
+				report.add(ProblemSchema, table, "column %s.%s is missing", table, column)
+				ok = false
+			}
+		}
+	}
+	return ok, nil
+}
+
+func tableColumns(ctx context.Context, db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+func checkParents(ctx context.Context, db *sql.DB, repair bool, report *CheckReport) error {
+	rows, err := db.QueryContext(ctx, "SELECT id, parents FROM operations ORDER BY clock, id")
+	if err != nil {
+		return err
+	}
+
+	parents := make(map[string][]operations.OperationID)
+	var order []string
+	for rows.Next() {
+		var id string
+		var parentsJSON sql.NullString
+		if err := rows.Scan(&id, &parentsJSON); err != nil {
+			rows.Close()
+			return err
+		}
+		order = append(order, id)
+
+		var ids []operations.OperationID
+		if parentsJSON.Valid && parentsJSON.String != "" {
+			if err := json.Unmarshal([]byte(parentsJSON.String), &ids); err != nil {
+				report.add(ProblemMissingParent, id, "parents are not valid JSON: %v", err)
+				continue
+			}
+		}
+		parents[id] = ids
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	report.Operations = len(order)
+
+	// Parents removed by retention are gone on purpose
+	purged, err := queryStrings(ctx, db, "SELECT id FROM purged_operations")
+	if err != nil {
+		return err
+	}
+	for _, id := range purged {
+		if _, ok := parents[id]; !ok {
+			parents[id] = nil
+		}
+	}
+
+	for _, id := range order {
+		var kept []operations.OperationID
+		var missing []int
+		for _, parent := range parents[id] {
+			if _, ok := parents[string(parent)]; ok {
+				kept = append(kept, parent)
+				continue
+			}
+			missing = append(missing, report.add(ProblemMissingParent, id, "parent %s is not in the store", parent))
+		}
+		if !repair || len(missing) == 0 {
+			continue
+		}
+
+		// Dropping the reference keeps the operation and the rest of its history
+		parentsJSON, err := json.Marshal(kept)
+		if err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "UPDATE operations SET parents = ? WHERE id = ?", string(parentsJSON), id); err != nil {
+			return fmt.Errorf("failed to repair parents of %s: %w", id, err)
+		}
+		report.repaired(missing)
+	}
+	return nil
+}
+
+func checkBlobs(ctx context.Context, db *sql.DB, repair bool, report *CheckReport) error {
+	missing, err := queryStrings(ctx, db, `
+		SELECT id FROM operations
+		WHERE blob_hash IS NOT NULL AND blob_hash NOT IN (SELECT hash FROM blobs)
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+	for _, id := range missing {
+		report.add(ProblemMissingBlob, id, "content blob is not in the store")
+	}
+
+	orphaned, err := queryStrings(ctx, db, `
+		SELECT hash FROM blobs
+		WHERE hash NOT IN (SELECT blob_hash FROM operations WHERE blob_hash IS NOT NULL)
+		ORDER BY hash
+	`)
+	if err != nil {
+		return err
+	}
+	if len(orphaned) == 0 {
+		return nil
+	}
+
+	var problems []int
+	for _, hash := range orphaned {
+		problems = append(problems, report.add(ProblemOrphanedBlob, hash, "no operation refers to this content"))
+	}
+	if repair {
+		if _, err := db.ExecContext(ctx, deleteOrphanBlobsQuery); err != nil {
+			return fmt.Errorf("failed to delete orphaned blobs: %w", err)
+		}
+		report.repaired(problems)
+	}
+	return nil
+}
+
+func checkConstructs(ctx context.Context, db *sql.DB, repair bool, report *CheckReport) error {
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM constructs").Scan(&report.Constructs); err != nil {
+		return err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.id, c.document_path, c.created_by, c.modified_by,
+			d.file_path IS NOT NULL,
+			c.created_by IN (SELECT id FROM operations),
+			c.modified_by IN (SELECT id FROM operations)
+		FROM constructs c LEFT JOIN documents d ON d.file_path = c.document_path
+		WHERE d.file_path IS NULL
+			OR c.created_by NOT IN (SELECT id FROM operations)
+			OR c.modified_by NOT IN (SELECT id FROM operations)
+		ORDER BY c.document_path, c.id
+	`)
+	if err != nil {
+		return err
+	}
+
+	var orphaned []string
+	var orphanProblems []int
+	type dangling struct{ id, field, opID string }
+	var danglingRefs []dangling
+	for rows.Next() {
+		var id, documentPath, createdBy, modifiedBy string
+		var hasDocument, hasCreatedBy, hasModifiedBy bool
+		if err := rows.Scan(&id, &documentPath, &createdBy, &modifiedBy, &hasDocument, &hasCreatedBy, &hasModifiedBy); err != nil {
+			rows.Close()
+			return err
+		}
+
+		// A construct without its document is never loaded, so dangling
+		// references inside it don't matter
+		if !hasDocument {
+			orphaned = append(orphaned, id)
+			orphanProblems = append(orphanProblems, report.add(ProblemOrphanedConstruct, id, "document %s does not exist", documentPath))
+			continue
+		}
+		if !hasCreatedBy {
+			danglingRefs = append(danglingRefs, dangling{id, "created_by", createdBy})
+		}
+		if !hasModifiedBy && modifiedBy != createdBy {
+			danglingRefs = append(danglingRefs, dangling{id, "modified_by", modifiedBy})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, ref := range danglingRefs {
+		report.add(ProblemDanglingConstruct, ref.id, "%s operation %s is not in the store", ref.field, ref.opID)
+	}
+
+	if !repair {
+		return nil
+	}
+	for _, id := range orphaned {
+		if _, err := db.ExecContext(ctx, "DELETE FROM constructs WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete orphaned construct %s: %w", id, err)
+		}
+		report.Constructs--
+	}
+	report.repaired(orphanProblems)
+	return nil
+}
+
+func checkDocuments(ctx context.Context, db *sql.DB, getDocument func(context.Context, string) (*positioning.Document, error), report *CheckReport) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT file_path, content_hash,
+			COALESCE(last_operation, '') = '' OR last_operation IN (SELECT id FROM operations)
+		FROM documents ORDER BY file_path
+	`)
+	if err != nil {
+		return err
+	}
+
+	type storedDocument struct {
+		path, hash    string
+		lastOperation bool
+	}
+	var documents []storedDocument
+	for rows.Next() {
+		var doc storedDocument
+		if err := rows.Scan(&doc.path, &doc.hash, &doc.lastOperation); err != nil {
+			rows.Close()
+			return err
+		}
+		documents = append(documents, doc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	report.Documents = len(documents)
+
+	for _, stored := range documents {
+		if !stored.lastOperation {
+			report.add(ProblemDanglingDocument, stored.path, "last operation is not in the store")
+		}
+
+		doc, err := getDocument(ctx, stored.path)
+		if err != nil {
+			return fmt.Errorf("failed to load document %s: %w", stored.path, err)
+		}
+		if hash := renderedHash(doc); hash != stored.hash {
+			report.add(ProblemContentHash, stored.path, "stored hash %s does not match rendered content %s", stored.hash, hash)
+		}
+	}
+	return nil
+}
+
+// renderedHash hashes the document's content in position order, as the
+// document does when an operation is applied
+func renderedHash(doc *positioning.Document) string {
+	var content strings.Builder
+	doc.ForEachConstruct(func(construct *positioning.Construct) bool {
+		content.WriteString(construct.Content)
+		return true
+	})
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content.String())))
+}
+
+func queryStrings(ctx context.Context, db *sql.DB, query string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
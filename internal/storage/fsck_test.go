@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+func TestContextStore_Check(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewContextStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	newOp := func(content string, value int64, parents ...operations.OperationID) *operations.Operation {
+		op := &operations.Operation{
+			ID:   operations.NewOperationID([]byte(content)),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: "alice"},
+			}),
+			Content:   content,
+			Author:    "alice",
+			Timestamp: time.Now(),
+			Parents:   parents,
+		}
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+		return op
+	}
+
+	first := newOp("package main\n", 1)
+	second := newOp("func main() {}\n", 2, first.ID)
+	doc := positioning.NewDocument("main.go")
+	for _, op := range []*operations.Operation{first, second} {
+		if err := doc.ApplyOperation(op); err != nil {
+			t.Fatalf("Failed to apply operation: %v", err)
+		}
+	}
+	if err := store.StoreDocument(ctx, doc); err != nil {
+		t.Fatalf("Failed to store document: %v", err)
+	}
+
+	report, err := store.Check(ctx, false)
+	if err != nil {
+		t.Fatalf("Failed to check store: %v", err)
+	}
+	if !report.Clean() || len(report.Problems) != 0 {
+		t.Fatalf("Expected a clean store, got %+v", report.Problems)
+	}
+	if report.Operations != 2 || report.Documents != 1 || report.Constructs != 2 {
+		t.Errorf("Expected 2 operations, 1 document and 2 constructs, got %+v", report)
+	}
+
+	// Break the store in every way the check knows about
+	newOp("// orphan\n", 3, "op_missing")
+	if err := store.DeleteOperation(ctx, first.ID); err != nil {
+		t.Fatalf("Failed to delete operation: %v", err)
+	}
+	statements := []string{
+		"UPDATE documents SET content_hash = 'bad'",
+		`INSERT INTO constructs (id, document_path, position_segments, content, type, created_by, modified_by, metadata)
+			VALUES ('stray', 'gone.go', '[]', 'x', 'content', '` + string(second.ID) + `', '` + string(second.ID) + `', '{}')`,
+		"INSERT INTO blobs (hash, content, size, created_at) VALUES ('unused', 'x', 1, 0)",
+	}
+	for _, statement := range statements {
+		if _, err := store.db.Exec(statement); err != nil {
+			t.Fatalf("Failed to corrupt store: %v", err)
+		}
+	}
+
+	report, err = store.Check(ctx, true)
+	if err != nil {
+		t.Fatalf("Failed to repair store: %v", err)
+	}
+	found := make(map[ProblemKind]Problem)
+	for _, problem := range report.Problems {
+		found[problem.Kind] = problem
+	}
+	expected := map[ProblemKind]bool{
+		// Both the orphan and second lost a parent
+		ProblemMissingParent:     true,
+		ProblemOrphanedConstruct: true,
+		ProblemOrphanedBlob:      true,
+		ProblemDanglingConstruct: false,
+		ProblemContentHash:       false,
+	}
+	for kind, repaired := range expected {
+		problem, ok := found[kind]
+		if !ok {
+			t.Errorf("Expected a %s problem, got %+v", kind, report.Problems)
+			continue
+		}
+		if problem.Repaired != repaired {
+			t.Errorf("Expected %s repaired to be %v, got %+v", kind, repaired, problem)
+		}
+	}
+	if report.Clean() {
+		t.Error("Expected unrepaired problems to remain")
+	}
+
+	// Repairs stick; what can't be repaired is still reported
+	report, err = store.Check(ctx, false)
+	if err != nil {
+		t.Fatalf("Failed to check store: %v", err)
+	}
+	for _, problem := range report.Problems {
+		if problem.Kind != ProblemDanglingConstruct && problem.Kind != ProblemContentHash {
+			t.Errorf("Expected only unrepairable problems, got %+v", problem)
+		}
+	}
+	repaired, err := store.GetOperation(ctx, second.ID)
+	if err != nil || len(repaired.Parents) != 0 {
+		t.Errorf("Expected the missing parent to be dropped, got %v, %v", repaired, err)
+	}
+}
+
+func TestContextStore_CheckManifest(t *testing.T) {
+	store, err := NewContextStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.manifest.SchemaVersion = "0.9"
+	if err := writeJSON(filepath.Join(store.basePath, ManifestFile), store.manifest); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	report, err := store.Check(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Failed to check store: %v", err)
+	}
+	if len(report.Problems) != 1 || report.Problems[0].Kind != ProblemManifest ||
+		!strings.Contains(report.Problems[0].Message, "schema version 0.9") {
+		t.Errorf("Expected a schema version problem, got %+v", report.Problems)
+	}
+}
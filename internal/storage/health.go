@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StoreHealth is what a probe of the database found. WALBacklog is the bytes
+// of the write-ahead log that couldn't be checkpointed into the database,
+// usually because readers are holding on to older snapshots. It's zero when
+// the database isn't in WAL mode.
+type StoreHealth struct {
+	Latency     time.Duration `json:"latency"`
+	JournalMode string        `json:"journal_mode"`
+	WALBacklog  int64         `json:"wal_backlog"`
+}
+
+func (cs *ContextStore) Health(ctx context.Context) (*StoreHealth, error) {
+	return probeDatabase(ctx, cs.db)
+}
+
+func (s *SQLiteStore) Health(ctx context.Context) (*StoreHealth, error) {
+	return probeDatabase(ctx, s.db)
+}
+
+// probeDatabase runs a query to check the database answers, then measures
+// the WAL backlog with a passive checkpoint, which never waits on readers or
+// writers and only does what SQLite would do on its own at the next commit
+func probeDatabase(ctx context.Context, db *sql.DB) (*StoreHealth, error) {
+	start := time.Now()
+	var one int
+	if err := db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return nil, fmt.Errorf("database is unreachable: %w", err)
+	}
+	health := &StoreHealth{Latency: time.Since(start)}
+
+	if err := db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&health.JournalMode); err != nil {
+		return nil, fmt.Errorf("failed to read journal mode: %w", err)
+	}
+	health.JournalMode = strings.ToLower(health.JournalMode)
+	if health.JournalMode != "wal" {
+		return health, nil
+	}
+
+	var busy, frames, checkpointed, pageSize int64
+	if err := db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(PASSIVE)").Scan(&busy, &frames, &checkpointed); err != nil {
+		return nil, fmt.Errorf("failed to checkpoint write-ahead log: %w", err)
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return nil, fmt.Errorf("failed to read page size: %w", err)
+	}
+	health.WALBacklog = max(frames-checkpointed, 0) * pageSize
+	return health, nil
+}
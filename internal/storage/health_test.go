@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSQLiteStore_Health(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	health, err := store.Health(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to probe store: %v", err)
+	}
+	if health.JournalMode != "wal" || health.WALBacklog != 0 || health.Latency <= 0 {
+		t.Errorf("Expected a checkpointed WAL store, got %+v", health)
+	}
+
+	store.Close()
+	if _, err := store.Health(context.Background()); err == nil {
+		t.Errorf("Expected a closed store to fail its probe")
+	}
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/filelock"
+)
+
+// InstanceFile is locked, as InstanceFile.lock, by the process serving the
+// store for as long as it does, and records which process that is
+const InstanceFile = "instance"
+
+// Instance is the process that claimed a store
+type Instance struct {
+	PID       int       `json:"pid"`
+	Hostname  string    `json:"hostname"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// ClaimContextStore opens the store under basePath like NewContextStore, but
+// only if no other process has claimed it, and holds the claim until Close.
+// While another process holds it, it fails with ErrStoreInUse naming that
+// process. The claim is an OS lock, released however its process ends, so a
+// claim left by a process that died doesn't block the next.
+func ClaimContextStore(basePath string) (*ContextStore, error) {
+	contextPath := filepath.Join(basePath, ContextDir)
+	_, statErr := os.Stat(contextPath)
+	if statErr != nil && !os.IsNotExist(statErr) {
+		return nil, fmt.Errorf("failed to access .context directory: %w", statErr)
+	}
+	if err := os.MkdirAll(contextPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create .context directory: %w", err)
+	}
+
+	claim, err := claimInstance(contextPath)
+	if err != nil {
+		return nil, err
+	}
+
+	open := openExistingContextStore
+	if os.IsNotExist(statErr) {
+		open = createNewContextStore
+	}
+	store, err := open(contextPath)
+	if err != nil {
+		claim.Unlock()
+		return nil, err
+	}
+	store.claim = claim
+	return store, nil
+}
+
+// ClaimStore claims the store under basePath without opening it, for work
+// that needs no process serving it, such as restoring a backup. The claim is
+// held until the lock is unlocked.
+func ClaimStore(basePath string) (*filelock.Lock, error) {
+	return claimInstance(filepath.Join(basePath, ContextDir))
+}
+
+func claimInstance(contextPath string) (*filelock.Lock, error) {
+	path := filepath.Join(contextPath, InstanceFile)
+	claim, err := filelock.TryAcquire(path)
+	if errors.Is(err, filelock.ErrLocked) {
+		var holder Instance
+		if data, err := filelock.Owner(path); err == nil && json.Unmarshal(data, &holder) == nil {
+			return nil, fmt.Errorf("%w: pid %d on %s has served it since %s", ErrStoreInUse,
+				holder.PID, holder.Hostname, holder.StartedAt.Format(time.RFC3339))
+		}
+		return nil, ErrStoreInUse
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim store: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	owner, err := json.Marshal(Instance{PID: os.Getpid(), Hostname: hostname, StartedAt: time.Now()})
+	if err == nil {
+		err = claim.SetOwner(owner)
+	}
+	if err != nil {
+		claim.Unlock()
+		return nil, fmt.Errorf("failed to claim store: %w", err)
+	}
+	return claim, nil
+}
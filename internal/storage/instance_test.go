@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestClaimContextStore(t *testing.T) {
+	dir := t.TempDir()
+	first, err := ClaimContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to claim store: %v", err)
+	}
+
+	// A second claim, even from this process, is refused and told who holds it
+	_, err = ClaimContextStore(dir)
+	if !errors.Is(err, ErrStoreInUse) || !strings.Contains(err.Error(), fmt.Sprintf("pid %d", os.Getpid())) {
+		t.Fatalf("Expected ErrStoreInUse naming this process, got %v", err)
+	}
+	if _, err := ClaimStore(dir); !errors.Is(err, ErrStoreInUse) {
+		t.Errorf("Expected ErrStoreInUse claiming without opening, got %v", err)
+	}
+
+	// Stores opened without a claim aren't refused
+	unclaimed, err := NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	unclaimed.Close()
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	second, err := ClaimContextStore(dir)
+	if err != nil {
+		t.Fatalf("Expected the claim released on close, got %v", err)
+	}
+	second.Close()
+}
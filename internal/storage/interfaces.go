@@ -1,30 +1,162 @@
 package storage
 
 import (
+	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/jeremytregunna/contextdb/internal/operations"
 	"github.com/jeremytregunna/contextdb/internal/positioning"
 )
 
+// All store methods honor ctx cancellation and deadlines for the underlying queries.
+
 type OperationStore interface {
-	StoreOperation(op *operations.Operation) error
-	GetOperation(id operations.OperationID) (*operations.Operation, error)
-	GetOperations(ids []operations.OperationID) ([]*operations.Operation, error)
-	GetOperationsSince(timestamp time.Time) ([]*operations.Operation, error)
-	GetOperationsByAuthor(authorID operations.AuthorID) ([]*operations.Operation, error)
-	DeleteOperation(id operations.OperationID) error
+	StoreOperation(ctx context.Context, op *operations.Operation) error
+	GetOperation(ctx context.Context, id operations.OperationID) (*operations.Operation, error)
+	GetOperations(ctx context.Context, ids []operations.OperationID) ([]*operations.Operation, error)
+	GetOperationsSince(ctx context.Context, timestamp time.Time) ([]*operations.Operation, error)
+	GetOperationsByAuthor(ctx context.Context, authorID operations.AuthorID) ([]*operations.Operation, error)
+	// The ForEach variants stream results in clock order instead of loading them all
+	ForEachOperationSince(ctx context.Context, timestamp time.Time, fn OperationFunc) error
+	ForEachOperationByAuthor(ctx context.Context, authorID operations.AuthorID, fn OperationFunc) error
+	// ForEachOperationMatching streams the operations filter matches in clock order
+	ForEachOperationMatching(ctx context.Context, filter OperationFilter, fn OperationFunc) error
+	CountOperationsMatching(ctx context.Context, filter OperationFilter) (int, error)
+	// ForEachOperationChunk streams the chunks of the insert split into them
+	// whose first chunk is first, in order
+	ForEachOperationChunk(ctx context.Context, first operations.OperationID, fn OperationFunc) error
+	DeleteOperation(ctx context.Context, id operations.OperationID) error
 }
 
 type DocumentStore interface {
-	StoreDocument(doc *positioning.Document) error
-	GetDocument(filePath string) (*positioning.Document, error)
-	ListDocuments() ([]string, error)
-	DeleteDocument(filePath string) error
+	StoreDocument(ctx context.Context, doc *positioning.Document) error
+	GetDocument(ctx context.Context, filePath string) (*positioning.Document, error)
+	// GetConstructsInRange reads a document's constructs from start to end
+	// inclusive, in position order, without loading the rest of it
+	GetConstructsInRange(ctx context.Context, filePath string, start, end operations.LogootPosition) ([]*positioning.Construct, error)
+	// ListDocuments lists document paths in order, with deleted ones too when includeDeleted is set
+	ListDocuments(ctx context.Context, includeDeleted bool) ([]string, error)
+	// ListDocumentInfo describes the documents whose paths start with prefix, in path order
+	ListDocumentInfo(ctx context.Context, prefix string, includeDeleted bool) ([]DocumentInfo, error)
+	// GetDocumentStats sums up a document with SQL aggregates rather than loading it
+	GetDocumentStats(ctx context.Context, filePath string) (*DocumentStats, error)
+	// ListDocumentStats sums up the documents whose paths start with prefix, by path
+	ListDocumentStats(ctx context.Context, prefix string) (map[string]*DocumentStats, error)
+	// DeleteDocument marks a document deleted, keeping its constructs and
+	// operations so RestoreDocument can bring it back. GetDocument fails with
+	// ErrDocumentDeleted in the meantime.
+	DeleteDocument(ctx context.Context, filePath string) error
+	RestoreDocument(ctx context.Context, filePath string) error
+}
+
+type RetentionStore interface {
+	GetRetentionPolicy() (RetentionPolicy, error)
+	SetRetentionPolicy(policy RetentionPolicy) error
+	PurgeOperationsBefore(ctx context.Context, cutoff time.Time, dryRun bool) ([]operations.OperationID, error)
+	// PurgedOperations returns those of ids that PurgeOperationsBefore removed
+	PurgedOperations(ctx context.Context, ids []operations.OperationID) ([]operations.OperationID, error)
+}
+
+// IntegrityStore verifies, and optionally repairs, the consistency of a store
+type IntegrityStore interface {
+	Check(ctx context.Context, repair bool) (*CheckReport, error)
+}
+
+// SearchStore ranks full text matches across operations, documents and
+// conversations. Conversations aren't stored here, so they are indexed from
+// outside with IndexConversations.
+type SearchStore interface {
+	Search(ctx context.Context, query SearchQuery) ([]SearchHit, error)
+	IndexConversations(ctx context.Context, entries []ConversationEntry) error
+}
+
+// VectorStore keeps embeddings of operations and messages for similarity search
+type VectorStore interface {
+	StoreVectors(ctx context.Context, vectors []Vector) error
+	GetVector(ctx context.Context, kind VectorKind, ref, model string) (*Vector, error)
+	SimilarVectors(ctx context.Context, query VectorQuery) ([]VectorMatch, error)
+	VectorHashes(ctx context.Context, kind VectorKind, model string) (map[string]string, error)
+	DeleteVectors(ctx context.Context, kind VectorKind, model string, refs []string) error
+	// OperationsWithoutVectors returns up to limit of the oldest operations
+	// with text content that model hasn't embedded
+	OperationsWithoutVectors(ctx context.Context, model string, limit int) ([]*operations.Operation, error)
+}
+
+// ChurnStore reads the churn rollups kept as operations are written. Windows
+// are widened to whole hours.
+type ChurnStore interface {
+	// DocumentChurn counts operations per document, most first. Zero times leave the window open.
+	DocumentChurn(ctx context.Context, since, until time.Time) ([]DocumentChurn, error)
+	// PositionChurn counts operations per position of one document
+	PositionChurn(ctx context.Context, documentID string, since, until time.Time) ([]PositionChurn, error)
+}
+
+// JobStore keeps the history of background job runs
+type JobStore interface {
+	RecordJobRun(ctx context.Context, run JobRun) error
+	// JobRuns returns up to limit of the latest runs of job, newest first
+	JobRuns(ctx context.Context, job string, limit int) ([]JobRun, error)
+}
+
+// BranchStore keeps the branches operations are made on
+type BranchStore interface {
+	CreateBranch(ctx context.Context, branch *Branch) error
+	GetBranch(ctx context.Context, name string) (*Branch, error)
+	// ListBranches returns every branch, merged ones too, by name
+	ListBranches(ctx context.Context) ([]*Branch, error)
+	// MergeBranch closes an open branch, recording what it was merged into
+	MergeBranch(ctx context.Context, name, into string, at time.Time) error
+	// RecordDocumentMerges records single documents merged between branches
+	RecordDocumentMerges(ctx context.Context, merges []DocumentMerge) error
+	ListDocumentMerges(ctx context.Context) ([]DocumentMerge, error)
+}
+
+// HealthStore probes the database behind a store for health checks and
+// statistics
+type HealthStore interface {
+	Health(ctx context.Context) (*StoreHealth, error)
+	Stats(ctx context.Context) (*StoreStats, error)
+}
+
+// AnalysisStore keeps the results of analyzing operations after they were
+// written, apart from the operations themselves
+type AnalysisStore interface {
+	// StoreIntentRecords replaces the analyses of the records' operations
+	StoreIntentRecords(ctx context.Context, records []IntentRecord) error
+	GetIntentRecord(ctx context.Context, id operations.OperationID) (*IntentRecord, error)
+}
+
+// SettingsStore keeps repository-wide settings, such as the intent taxonomy,
+// as JSON documents by key
+type SettingsStore interface {
+	GetSetting(ctx context.Context, key string) (json.RawMessage, error)
+	PutSetting(ctx context.Context, key string, value json.RawMessage) error
+}
+
+// AliasStore keeps the author IDs merged into one identity. Queries that
+// filter by author match every ID of the author's identity.
+type AliasStore interface {
+	// AuthorAliases maps each alias to the author ID it was merged into
+	AuthorAliases(ctx context.Context) (map[operations.AuthorID]operations.AuthorID, error)
+	MergeAuthors(ctx context.Context, author operations.AuthorID, aliases []operations.AuthorID) error
+	// RemoveAuthorAlias splits an alias back out of its identity
+	RemoveAuthorAlias(ctx context.Context, alias operations.AuthorID) error
 }
 
 type Store interface {
 	OperationStore
 	DocumentStore
+	RetentionStore
+	IntegrityStore
+	SearchStore
+	VectorStore
+	ChurnStore
+	JobStore
+	BranchStore
+	HealthStore
+	SettingsStore
+	AnalysisStore
+	AliasStore
 	Close() error
 }
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// OperationFunc is called once per operation during iteration. Returning
+// ErrStopIteration ends the iteration cleanly, any other error aborts it and
+// is returned to the caller.
+type OperationFunc func(op *operations.Operation) error
+
+// forEachOperationRow streams rows into fn one at a time, so only the current
+// operation is held in memory regardless of how large the result set is
+func forEachOperationRow(rows *sql.Rows, scan func(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*operations.Operation, error), fn OperationFunc) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		op, err := scan(rows)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(op); err != nil {
+			if errors.Is(err, ErrStopIteration) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func collectOperations(iterate func(fn OperationFunc) error) ([]*operations.Operation, error) {
+	var result []*operations.Operation
+	err := iterate(func(op *operations.Operation) error {
+		result = append(result, op)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
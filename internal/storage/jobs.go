@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/ids"
+)
+
+// JobRunHistory is how many runs of each background job are kept
+const JobRunHistory = 50
+
+// job_runs orders runs by id, in the order they were recorded. Each run is
+// known outside the store by run_id.
+const jobRunsSchema = `
+CREATE TABLE IF NOT EXISTS job_runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id TEXT NOT NULL DEFAULT '',
+	job TEXT NOT NULL,
+	trigger TEXT NOT NULL,
+	started_at INTEGER NOT NULL,
+	finished_at INTEGER NOT NULL,
+	error TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_job_runs_job ON job_runs(job, id);
+`
+
+// JobRun is one run of a background job. Trigger says whether it ran on
+// schedule or was started by hand; Error is empty when it succeeded. Runs
+// recorded without an ID are given one from ids.New.
+type JobRun struct {
+	ID         string    `json:"id"`
+	Job        string    `json:"job"`
+	Trigger    string    `json:"trigger"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func migrateJobRuns(db *sql.DB) error {
+	if _, err := db.Exec(jobRunsSchema); err != nil {
+		return fmt.Errorf("failed to create job runs table: %w", err)
+	}
+
+	// Runs recorded before run_id keep their sequence number as their ID
+	exists, err := columnExists(db, "job_runs", "run_id")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := db.Exec("ALTER TABLE job_runs ADD COLUMN run_id TEXT NOT NULL DEFAULT ''"); err != nil {
+			return fmt.Errorf("failed to migrate job runs: %w", err)
+		}
+		if _, err := db.Exec("UPDATE job_runs SET run_id = CAST(id AS TEXT) WHERE run_id = ''"); err != nil {
+			return fmt.Errorf("failed to migrate job runs: %w", err)
+		}
+	}
+	return nil
+}
+
+func (cs *ContextStore) RecordJobRun(ctx context.Context, run JobRun) error {
+	return recordJobRun(ctx, cs.db, run)
+}
+
+func (cs *ContextStore) JobRuns(ctx context.Context, job string, limit int) ([]JobRun, error) {
+	return jobRuns(ctx, cs.db, job, limit)
+}
+
+func (s *SQLiteStore) RecordJobRun(ctx context.Context, run JobRun) error {
+	return recordJobRun(ctx, s.db, run)
+}
+
+func (s *SQLiteStore) JobRuns(ctx context.Context, job string, limit int) ([]JobRun, error) {
+	return jobRuns(ctx, s.db, job, limit)
+}
+
+// recordJobRun stores run and drops the job's runs beyond JobRunHistory
+func recordJobRun(ctx context.Context, db *sql.DB, run JobRun) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if run.ID == "" {
+		run.ID = ids.NewWithPrefix("run")
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO job_runs (run_id, job, trigger, started_at, finished_at, error) VALUES (?, ?, ?, ?, ?, ?)`,
+		run.ID, run.Job, run.Trigger, run.StartedAt.UnixNano(), run.FinishedAt.UnixNano(), run.Error)
+	if err != nil {
+		return fmt.Errorf("failed to record job run: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM job_runs WHERE job = ? AND id NOT IN (
+			SELECT id FROM job_runs WHERE job = ? ORDER BY id DESC LIMIT ?)`,
+		run.Job, run.Job, JobRunHistory)
+	if err != nil {
+		return fmt.Errorf("failed to prune job runs: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// jobRuns returns up to limit of the latest runs of job, newest first
+func jobRuns(ctx context.Context, db *sql.DB, job string, limit int) ([]JobRun, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT run_id, job, trigger, started_at, finished_at, error FROM job_runs
+		WHERE job = ? ORDER BY id DESC LIMIT ?`, job, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []JobRun{}
+	for rows.Next() {
+		var run JobRun
+		var startedAt, finishedAt int64
+		if err := rows.Scan(&run.ID, &run.Job, &run.Trigger, &startedAt, &finishedAt, &run.Error); err != nil {
+			return nil, err
+		}
+		run.StartedAt = time.Unix(0, startedAt)
+		run.FinishedAt = time.Unix(0, finishedAt)
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore_JobRuns(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < JobRunHistory+5; i++ {
+		run := JobRun{
+			Job:        "backup",
+			Trigger:    "schedule",
+			StartedAt:  start.Add(time.Duration(i) * time.Hour),
+			FinishedAt: start.Add(time.Duration(i)*time.Hour + time.Second),
+		}
+		if i == JobRunHistory+4 {
+			run.Error = "disk full"
+		}
+		if err := store.RecordJobRun(ctx, run); err != nil {
+			t.Fatalf("Failed to record job run: %v", err)
+		}
+	}
+	if err := store.RecordJobRun(ctx, JobRun{Job: "retention", Trigger: "manual", StartedAt: start, FinishedAt: start}); err != nil {
+		t.Fatalf("Failed to record job run: %v", err)
+	}
+
+	runs, err := store.JobRuns(ctx, "backup", 100)
+	if err != nil {
+		t.Fatalf("Failed to load job runs: %v", err)
+	}
+	if len(runs) != JobRunHistory {
+		t.Fatalf("Expected %d runs kept, got %d", JobRunHistory, len(runs))
+	}
+	newest := start.Add(time.Duration(JobRunHistory+4) * time.Hour)
+	if !runs[0].StartedAt.Equal(newest) || runs[0].Error != "disk full" || runs[0].FinishedAt.Sub(runs[0].StartedAt) != time.Second {
+		t.Errorf("Expected the newest run first, got %+v", runs[0])
+	}
+	if oldest := start.Add(5 * time.Hour); !runs[len(runs)-1].StartedAt.Equal(oldest) {
+		t.Errorf("Expected the oldest runs to be dropped, got %v last", runs[len(runs)-1].StartedAt)
+	}
+	seen := make(map[string]bool)
+	for _, run := range runs {
+		if !strings.HasPrefix(run.ID, "run_") || seen[run.ID] {
+			t.Errorf("Expected each run to get its own run ID, got %q", run.ID)
+		}
+		seen[run.ID] = true
+	}
+
+	runs, err = store.JobRuns(ctx, "retention", 1)
+	if err != nil {
+		t.Fatalf("Failed to load job runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Trigger != "manual" {
+		t.Errorf("Expected the retention run, got %+v", runs)
+	}
+}
+
+func TestContextStore_JobRunsAfterReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.Close()
+
+	store, err = NewContextStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now()
+	if err := store.RecordJobRun(ctx, JobRun{Job: "backup", Trigger: "schedule", StartedAt: now, FinishedAt: now}); err != nil {
+		t.Fatalf("Failed to record job run: %v", err)
+	}
+	runs, err := store.JobRuns(ctx, "backup", 10)
+	if err != nil || len(runs) != 1 {
+		t.Errorf("Expected the recorded run, got %+v, %v", runs, err)
+	}
+}
+
+func TestJobRuns_Migrate(t *testing.T) {
+	ctx := context.Background()
+	tmpFile, err := os.CreateTemp("", "contextdb_test_*.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	tmpFile.Close()
+	defer removeDatabaseFiles(tmpFile.Name())
+
+	// A store from before runs had run IDs
+	db, err := sql.Open("sqlite3", tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	legacy := `
+	CREATE TABLE job_runs (id INTEGER PRIMARY KEY AUTOINCREMENT, job TEXT NOT NULL, trigger TEXT NOT NULL,
+		started_at INTEGER NOT NULL, finished_at INTEGER NOT NULL, error TEXT NOT NULL DEFAULT '');
+
+	INSERT INTO job_runs (job, trigger, started_at, finished_at) VALUES ('backup', 'schedule', 100, 200);
+	`
+	if _, err := db.Exec(legacy); err != nil {
+		t.Fatalf("Failed to create legacy store: %v", err)
+	}
+	if err := migrateJobRuns(db); err != nil {
+		t.Fatalf("Failed to migrate job runs: %v", err)
+	}
+
+	now := time.Now()
+	if err := recordJobRun(ctx, db, JobRun{Job: "backup", Trigger: "manual", StartedAt: now, FinishedAt: now}); err != nil {
+		t.Fatalf("Failed to record job run: %v", err)
+	}
+	runs, err := jobRuns(ctx, db, "backup", 10)
+	if err != nil {
+		t.Fatalf("Failed to load job runs: %v", err)
+	}
+	if len(runs) != 2 || !strings.HasPrefix(runs[0].ID, "run_") || runs[1].ID != "1" {
+		t.Errorf("Expected the new run first and the old run known by its sequence number, got %+v", runs)
+	}
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// Operations keep their document, branch, tool, ticket and file language in
+// columns of their own alongside the metadata JSON, so they are filtered on
+// through indexes.
+var metadataColumns = []string{"document_id", "branch", "tool", "ticket", "language"}
+
+// migrateOperationMetadata adds the metadata columns to databases created
+// before them, filling them from the context map the values were kept in. It
+// must run before migrateChurn, which recreates the churn triggers it drops
+// to key them by the document column.
+func migrateOperationMetadata(db *sql.DB) error {
+	exists, err := columnExists(db, "operations", "document_id")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := addMetadataColumns(db); err != nil {
+			return fmt.Errorf("failed to migrate operation metadata: %w", err)
+		}
+	}
+	// migrateClock indexes the columns, by clock
+	return nil
+}
+
+func addMetadataColumns(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"DROP TRIGGER IF EXISTS churn_operations_replace",
+		"DROP TRIGGER IF EXISTS churn_operations_insert",
+	}
+	for _, column := range metadataColumns {
+		statements = append(statements,
+			fmt.Sprintf("ALTER TABLE operations ADD COLUMN %s TEXT NOT NULL DEFAULT ''", column),
+			fmt.Sprintf("UPDATE operations SET %s = COALESCE(json_extract(metadata, '$.context.%s'), json_extract(metadata, '$.%s'), '')", column, column, column),
+		)
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// OperationFilter picks operations out of the history. Empty fields match
+// every operation. Author matches every ID merged with the author when read
+// from the store, but only the author's own ID in Matches.
+type OperationFilter struct {
+	// Since and Until bound the operations' timestamps, Until exclusively
+	Since      time.Time
+	Until      time.Time
+	Author     operations.AuthorID
+	DocumentID string
+	Branch     string
+	Tool       string
+	Ticket     string
+	Language   string
+}
+
+// IsZero reports whether the filter matches every operation
+func (f OperationFilter) IsZero() bool {
+	return f == OperationFilter{}
+}
+
+// Matches reports whether op passes the filter, for operations that weren't
+// read through it
+func (f OperationFilter) Matches(op *operations.Operation) bool {
+	if !f.Since.IsZero() && op.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !op.Timestamp.Before(f.Until) {
+		return false
+	}
+	return (f.Author == "" || op.Author == f.Author) &&
+		(f.DocumentID == "" || op.Metadata.DocumentID == f.DocumentID) &&
+		(f.Branch == "" || op.Metadata.Branch == f.Branch) &&
+		(f.Tool == "" || op.Metadata.Tool == f.Tool) &&
+		(f.Ticket == "" || op.Metadata.Ticket == f.Ticket) &&
+		(f.Language == "" || op.Metadata.Language == f.Language)
+}
+
+// where builds the WHERE clause for the filter, with its arguments
+func (f OperationFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, f.Since.UnixNano())
+	}
+	if !f.Until.IsZero() {
+		conditions = append(conditions, "timestamp < ?")
+		args = append(args, f.Until.UnixNano())
+	}
+	if f.Author != "" {
+		conditions = append(conditions, authorIdentity("author"))
+		args = append(args, identityArgs(f.Author)...)
+	}
+	for _, field := range []struct{ column, value string }{
+		{"document_id", f.DocumentID},
+		{"branch", f.Branch},
+		{"tool", f.Tool},
+		{"ticket", f.Ticket},
+		{"language", f.Language},
+	} {
+		if field.value != "" {
+			conditions = append(conditions, field.column+" = ?")
+			args = append(args, field.value)
+		}
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (cs *ContextStore) ForEachOperationMatching(ctx context.Context, filter OperationFilter, fn OperationFunc) error {
+	where, args := filter.where()
+	rows, err := cs.db.QueryContext(ctx, selectOperationColumns+where+" ORDER BY clock, id", args...)
+	if err != nil {
+		return err
+	}
+	return forEachOperationRow(rows, cs.scanOperation, fn)
+}
+
+func (cs *ContextStore) CountOperationsMatching(ctx context.Context, filter OperationFilter) (int, error) {
+	return countOperationsMatching(ctx, cs.db, filter)
+}
+
+func (s *SQLiteStore) CountOperationsMatching(ctx context.Context, filter OperationFilter) (int, error) {
+	return countOperationsMatching(ctx, s.db, filter)
+}
+
+func countOperationsMatching(ctx context.Context, db *sql.DB, filter OperationFilter) (int, error) {
+	where, args := filter.where()
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM operations"+where, args...).Scan(&count)
+	return count, err
+}
+
+func (s *SQLiteStore) ForEachOperationMatching(ctx context.Context, filter OperationFilter, fn OperationFunc) error {
+	where, args := filter.where()
+	rows, err := s.db.QueryContext(ctx, selectOperationColumns+where+" ORDER BY clock, id", args...)
+	if err != nil {
+		return err
+	}
+	return forEachOperationRow(rows, s.scanOperation, fn)
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestOperationMetadata_MigrateAndFilter(t *testing.T) {
+	ctx := context.Background()
+	tmpFile, err := os.CreateTemp("", "contextdb_test_*.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	tmpFile.Close()
+	defer removeDatabaseFiles(tmpFile.Name())
+
+	// A store from before metadata had columns, keeping it all in the context
+	db, err := sql.Open("sqlite3", tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	legacy := `
+	CREATE TABLE operations (id TEXT PRIMARY KEY, type TEXT NOT NULL, position_segments TEXT NOT NULL,
+		content TEXT NOT NULL, content_type TEXT DEFAULT 'text', length INTEGER, author TEXT NOT NULL,
+		timestamp INTEGER NOT NULL, parents TEXT, metadata TEXT);
+
+	INSERT INTO operations VALUES ('op1', 'insert', '[{"value":1,"author":"alice"}]', 'a', 'text', 1, 'alice', 100, '[]',
+		'{"context":{"document_id":"main.go","branch":"feature","ticket":"ENG-1","reviewer":"bob"}}');
+	INSERT INTO operations VALUES ('op2', 'insert', '[{"value":2,"author":"bob"}]', 'b', 'text', 1, 'bob', 200, '[]',
+		'{"context":{"document_id":"util.go","branch":"feature"}}');
+	`
+	if _, err := db.Exec(legacy); err != nil {
+		t.Fatalf("Failed to create legacy store: %v", err)
+	}
+	db.Close()
+
+	store, err := NewSQLiteStore(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open legacy store: %v", err)
+	}
+	defer store.Close()
+
+	stored := &operations.Operation{
+		ID: "op3", Type: operations.OpInsert, Position: operations.NewLogootPosition(nil), Content: "c",
+		Author: "alice", Timestamp: time.Unix(300, 0), Parents: []operations.OperationID{},
+		Metadata: operations.OperationMeta{DocumentID: "main.go", Branch: "main", Tool: "vim", Language: "go"},
+	}
+	if err := store.StoreOperation(ctx, stored); err != nil {
+		t.Fatalf("Failed to store operation: %v", err)
+	}
+
+	match := func(filter OperationFilter) []operations.OperationID {
+		var ids []operations.OperationID
+		err := store.ForEachOperationMatching(ctx, filter, func(op *operations.Operation) error {
+			ids = append(ids, op.ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to filter operations: %v", err)
+		}
+		return ids
+	}
+	var all []*operations.Operation
+	if err := store.ForEachOperationMatching(ctx, OperationFilter{}, func(op *operations.Operation) error {
+		all = append(all, op)
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to read operations: %v", err)
+	}
+	for name, test := range map[string]struct {
+		filter   OperationFilter
+		expected []operations.OperationID
+	}{
+		"everything": {OperationFilter{}, []operations.OperationID{"op1", "op2", "op3"}},
+		"document":   {OperationFilter{DocumentID: "main.go"}, []operations.OperationID{"op1", "op3"}},
+		"branch":     {OperationFilter{Branch: "feature"}, []operations.OperationID{"op1", "op2"}},
+		"combined":   {OperationFilter{Branch: "feature", Author: "bob"}, []operations.OperationID{"op2"}},
+		"since":      {OperationFilter{Since: time.Unix(200, 0), DocumentID: "main.go"}, []operations.OperationID{"op3"}},
+		"until":      {OperationFilter{Until: time.Unix(200, 0)}, []operations.OperationID{"op1"}},
+		"ticket":     {OperationFilter{Ticket: "ENG-1"}, []operations.OperationID{"op1"}},
+		"tool":       {OperationFilter{Tool: "vim", Language: "go"}, []operations.OperationID{"op3"}},
+		"no match":   {OperationFilter{Language: "rust"}, nil},
+	} {
+		if ids := match(test.filter); !slices.Equal(ids, test.expected) {
+			t.Errorf("Expected %s to match %v, got %v", name, test.expected, ids)
+		}
+		if count, err := store.CountOperationsMatching(ctx, test.filter); err != nil || count != len(test.expected) {
+			t.Errorf("Expected %s to count %d, got %d %v", name, len(test.expected), count, err)
+		}
+		// Matching in memory agrees with the query
+		var matched []operations.OperationID
+		for _, op := range all {
+			if test.filter.Matches(op) {
+				matched = append(matched, op.ID)
+			}
+		}
+		if !slices.Equal(matched, test.expected) {
+			t.Errorf("Expected %s to match %v in memory, got %v", name, test.expected, matched)
+		}
+	}
+
+	// Legacy operations read back with their metadata in the typed fields
+	op, err := store.GetOperation(ctx, "op1")
+	if err != nil {
+		t.Fatalf("Failed to get operation: %v", err)
+	}
+	if op.Metadata.DocumentID != "main.go" || op.Metadata.Branch != "feature" || op.Metadata.Ticket != "ENG-1" {
+		t.Errorf("Expected typed metadata from the context, got %+v", op.Metadata)
+	}
+	if len(op.Metadata.Context) != 1 || op.Metadata.Context["reviewer"] != "bob" {
+		t.Errorf("Expected only the reviewer left in the context, got %v", op.Metadata.Context)
+	}
+}
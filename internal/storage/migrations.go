@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// baseSchema is the schema every store starts from, which migrations bring
+// up to date
+const baseSchema = `
+	CREATE TABLE IF NOT EXISTS operations (
+		id TEXT PRIMARY KEY,
+		type TEXT NOT NULL,
+		position_segments TEXT NOT NULL,
+		content TEXT NOT NULL,
+		content_type TEXT DEFAULT 'text',
+		length INTEGER,
+		author TEXT NOT NULL,
+		timestamp INTEGER NOT NULL,
+		parents TEXT,
+		metadata TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS documents (
+		file_path TEXT PRIMARY KEY,
+		version INTEGER NOT NULL,
+		content_hash TEXT NOT NULL,
+		last_operation TEXT,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS constructs (
+		id TEXT PRIMARY KEY,
+		document_path TEXT NOT NULL,
+		position_segments TEXT NOT NULL,
+		content TEXT NOT NULL,
+		type TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		modified_by TEXT NOT NULL,
+		metadata TEXT,
+		FOREIGN KEY (document_path) REFERENCES documents(file_path),
+		FOREIGN KEY (created_by) REFERENCES operations(id),
+		FOREIGN KEY (modified_by) REFERENCES operations(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_operations_timestamp ON operations(timestamp);
+	CREATE INDEX IF NOT EXISTS idx_operations_author ON operations(author);
+	CREATE INDEX IF NOT EXISTS idx_operations_type ON operations(type);
+	CREATE INDEX IF NOT EXISTS idx_constructs_document ON constructs(document_path);
+	CREATE INDEX IF NOT EXISTS idx_constructs_type ON constructs(type);
+	`
+
+// migrations bring a database created with the base schema, or by an older
+// version, up to the current one. Each does nothing once its change is made.
+// They run in this order, which some depend on: migrateChurn reads the
+// positions migratePositions adds, and migrateClock the timestamps
+// migrateTimestamps converts. New migrations go at the end.
+var migrations = []func(*sql.DB) error{
+	migrateBlobs,
+	migrateVectors,
+	migrateDocumentTombstones,
+	migratePositions,
+	migrateDocumentRecords,
+	migrateOperationMetadata,
+	migrateTimestamps,
+	migrateChurn,
+	migrateJobRuns,
+	migrateBranches,
+	migrateSettings,
+	migrateIntentAnalyses,
+	migrateAuthorAliases,
+	migrateClock,
+	migrateChunks,
+	migratePurgedOperations,
+}
+
+// migrate runs every migration on db in order
+func migrate(db *sql.DB) error {
+	for _, migration := range migrations {
+		if err := migration(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaMarkers are what the newest migrations add, a table or a column of
+// one. Migrations run in order, so a store that has them has run every
+// migration. A migration that adds to the schema adds what it adds here.
+var schemaMarkers = []struct {
+	table  string
+	column string
+}{
+	{table: "job_runs", column: "run_id"},
+	{table: "purged_operations"},
+	{table: "search_conversation_messages"},
+}
+
+// checkMigrated fails with ErrNeedsMigration if db hasn't run every migration,
+// for stores opened without migrating them
+func checkMigrated(db *sql.DB) error {
+	for _, marker := range schemaMarkers {
+		var exists bool
+		var err error
+		name := marker.table
+		if marker.column == "" {
+			exists, err = tableExists(db, marker.table)
+		} else {
+			exists, err = columnExists(db, marker.table, marker.column)
+			name += "." + marker.column
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check schema: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s is missing", ErrNeedsMigration, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// Positions are stored in the position column in their binary encoding,
+// which sorts the way positions do, so documents are read in order and
+// ranges of positions are index lookups. position_segments held them as
+// JSON before and is left empty.
+
+// migratePositions adds the position column to databases created before it,
+// moving the JSON positions over, including those the churn rollups are
+// keyed by. It must run before migrateChurn, which recreates the churn
+// triggers it drops.
+func migratePositions(db *sql.DB) error {
+	exists, err := columnExists(db, "operations", "position")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := movePositions(db); err != nil {
+			return fmt.Errorf("failed to migrate positions: %w", err)
+		}
+	}
+
+	indexes := `
+	CREATE INDEX IF NOT EXISTS idx_operations_position ON operations(position);
+	CREATE INDEX IF NOT EXISTS idx_constructs_document_position ON constructs(document_path, position);
+	`
+	_, err = db.Exec(indexes)
+	return err
+}
+
+func movePositions(db *sql.DB) error {
+	churn, err := tableExists(db, "churn_positions")
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"ALTER TABLE operations ADD COLUMN position BLOB",
+		"ALTER TABLE constructs ADD COLUMN position BLOB",
+		"DROP INDEX IF EXISTS idx_constructs_position",
+	}
+	if churn {
+		statements = append(statements,
+			"DROP TRIGGER IF EXISTS churn_operations_replace",
+			"DROP TRIGGER IF EXISTS churn_operations_insert",
+			"ALTER TABLE churn_positions RENAME COLUMN position_segments TO position",
+		)
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	if err := encodePositions(tx, "operations", "id", "position_segments",
+		"UPDATE operations SET position = ?, position_segments = '' WHERE id = ?"); err != nil {
+		return err
+	}
+	if err := encodePositions(tx, "constructs", "id", "position_segments",
+		"UPDATE constructs SET position = ?, position_segments = '' WHERE id = ?"); err != nil {
+		return err
+	}
+	if churn {
+		if err := encodePositions(tx, "churn_positions", "rowid", "position",
+			"UPDATE churn_positions SET position = ? WHERE rowid = ?"); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// encodePositions rewrites the JSON position in column of every row in table
+// with update, which takes the encoded position and the row's key
+func encodePositions(tx *sql.Tx, table, key, column, update string) error {
+	rows, err := tx.Query(fmt.Sprintf("SELECT %s, %s FROM %s", key, column, table))
+	if err != nil {
+		return err
+	}
+	type row struct {
+		key      interface{}
+		position []byte
+	}
+	var encoded []row
+	for rows.Next() {
+		var r row
+		var positionJSON string
+		if err := rows.Scan(&r.key, &positionJSON); err != nil {
+			rows.Close()
+			return err
+		}
+		var segments []operations.PositionSegment
+		if err := json.Unmarshal([]byte(positionJSON), &segments); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to unmarshal position in %s: %w", table, err)
+		}
+		if r.position, err = operations.NewLogootPosition(segments).MarshalBinary(); err != nil {
+			rows.Close()
+			return err
+		}
+		encoded = append(encoded, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(update)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, r := range encoded {
+		if _, err := stmt.Exec(r.position, r.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodePosition is the value stored in a position column
+func encodePosition(position operations.LogootPosition) ([]byte, error) {
+	encoded, err := position.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode position: %w", err)
+	}
+	// An empty position is stored as an empty blob rather than NULL
+	if encoded == nil {
+		encoded = []byte{}
+	}
+	return encoded, nil
+}
+
+func decodePosition(encoded []byte) (operations.LogootPosition, error) {
+	var position operations.LogootPosition
+	if err := position.UnmarshalBinary(encoded); err != nil {
+		return position, fmt.Errorf("failed to decode position: %w", err)
+	}
+	return position, nil
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestMigratePositions(t *testing.T) {
+	ctx := context.Background()
+	tmpFile, err := os.CreateTemp("", "contextdb_test_*.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	tmpFile.Close()
+	defer removeDatabaseFiles(tmpFile.Name())
+
+	// A store from before positions were binary, with churn rolled up by JSON
+	db, err := sql.Open("sqlite3", tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	hour := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC).Unix()
+	legacy := `
+	CREATE TABLE operations (id TEXT PRIMARY KEY, type TEXT NOT NULL, position_segments TEXT NOT NULL,
+		content TEXT NOT NULL, content_type TEXT DEFAULT 'text', length INTEGER, author TEXT NOT NULL,
+		timestamp INTEGER NOT NULL, parents TEXT, metadata TEXT);
+	CREATE TABLE documents (file_path TEXT PRIMARY KEY, version INTEGER NOT NULL, content_hash TEXT NOT NULL,
+		last_operation TEXT, created_at INTEGER NOT NULL, updated_at INTEGER NOT NULL);
+	CREATE TABLE constructs (id TEXT PRIMARY KEY, document_path TEXT NOT NULL, position_segments TEXT NOT NULL,
+		content TEXT NOT NULL, type TEXT NOT NULL, created_by TEXT NOT NULL, modified_by TEXT NOT NULL, metadata TEXT);
+	CREATE INDEX idx_constructs_position ON constructs(position_segments);
+	CREATE TABLE churn_documents (document_id TEXT NOT NULL, hour INTEGER NOT NULL, author TEXT NOT NULL,
+		operations INTEGER NOT NULL DEFAULT 0, inserts INTEGER NOT NULL DEFAULT 0, deletes INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (document_id, hour, author));
+	CREATE TABLE churn_positions (document_id TEXT NOT NULL, position_segments TEXT NOT NULL, hour INTEGER NOT NULL,
+		author TEXT NOT NULL, operations INTEGER NOT NULL DEFAULT 0, inserts INTEGER NOT NULL DEFAULT 0,
+		deletes INTEGER NOT NULL DEFAULT 0, PRIMARY KEY (document_id, position_segments, hour, author));
+
+	INSERT INTO operations VALUES ('op1', 'insert', '[{"value":10,"author":"alice"}]', 'b', 'text', 1, 'alice', 0, '[]',
+		'{"context":{"document_id":"main.go"}}');
+	INSERT INTO operations VALUES ('op2', 'insert', '[{"value":-3,"author":"bob"}]', 'a', 'text', 1, 'bob', 0, '["op1"]',
+		'{"context":{"document_id":"main.go"}}');
+	INSERT INTO documents VALUES ('main.go', 2, 'hash', 'op2', 0, 0);
+	INSERT INTO constructs VALUES ('c1', 'main.go', '[{"value":10,"author":"alice"}]', 'b', 'content', 'op1', 'op1', '{}');
+	INSERT INTO constructs VALUES ('c2', 'main.go', '[{"value":-3,"author":"bob"}]', 'a', 'content', 'op2', 'op2', '{}');
+	INSERT INTO churn_documents VALUES ('main.go', ?, 'alice', 4, 4, 0);
+	INSERT INTO churn_positions VALUES ('main.go', '[{"value":10,"author":"alice"}]', ?, 'alice', 4, 4, 0);
+	`
+	if _, err := db.Exec(legacy, hour, hour); err != nil {
+		t.Fatalf("Failed to create legacy store: %v", err)
+	}
+	db.Close()
+
+	store, err := NewSQLiteStore(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to open legacy store: %v", err)
+	}
+	defer store.Close()
+
+	op, err := store.GetOperation(ctx, "op2")
+	if err != nil {
+		t.Fatalf("Failed to get operation: %v", err)
+	}
+	if op.Position.String() != "-3:bob" {
+		t.Errorf("Expected position -3:bob, got %s", op.Position)
+	}
+
+	// Constructs now come back in position order from the database
+	doc, err := store.GetDocument(ctx, "main.go")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if len(doc.Positions()) != 2 || doc.Positions()[0].String() != "-3:bob" || doc.Positions()[1].String() != "10:alice" {
+		t.Errorf("Expected positions -3:bob and 10:alice in order, got %v", doc.Positions())
+	}
+
+	// Rolled up churn keeps counting at the same position
+	since := time.Unix(hour, 0)
+	stored := &operations.Operation{
+		ID: "op3", Type: operations.OpDelete, Position: doc.Positions()[1], Content: "b",
+		Author: "alice", Timestamp: since, Parents: []operations.OperationID{},
+		Metadata: operations.OperationMeta{Context: map[string]string{"document_id": "main.go"}},
+	}
+	if err := store.StoreOperation(ctx, stored); err != nil {
+		t.Fatalf("Failed to store operation: %v", err)
+	}
+	churn, err := store.PositionChurn(ctx, "main.go", since, since)
+	if err != nil {
+		t.Fatalf("Failed to get position churn: %v", err)
+	}
+	if len(churn) != 1 || churn[0].Operations != 5 || churn[0].Deletes != 1 || churn[0].Position.String() != "10:alice" {
+		t.Errorf("Expected five operations at 10:alice, got %+v", churn)
+	}
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+// DocumentRecordVersion is the version of the layout documents are written
+// in. Rows written before record versions were kept read as version 1.
+const DocumentRecordVersion = 1
+
+// DocumentRecord is a document as the stores lay it out: a documents row and
+// a constructs row for each construct. It only changes along with
+// DocumentRecordVersion, so positioning.Document can change without breaking
+// what is already stored.
+type DocumentRecord struct {
+	RecordVersion int
+	FilePath      string
+	Version       uint64
+	ContentHash   string // hex encoded
+	LastOperation string
+	Constructs    []ConstructRecord
+}
+
+// ConstructRecord is a row of the constructs table
+type ConstructRecord struct {
+	ID         string
+	Position   []byte // the position's binary encoding
+	Content    string
+	Type       string
+	CreatedBy  string
+	ModifiedBy string
+	Metadata   string // JSON
+}
+
+// NewDocumentRecord lays out doc for storing at DocumentRecordVersion
+func NewDocumentRecord(doc *positioning.Document) (DocumentRecord, error) {
+	snapshot := doc.Snapshot()
+	record := DocumentRecord{
+		RecordVersion: DocumentRecordVersion,
+		FilePath:      snapshot.FilePath,
+		Version:       snapshot.Version,
+		ContentHash:   hex.EncodeToString(snapshot.ContentHash[:]),
+		LastOperation: string(snapshot.LastOperation),
+		Constructs:    make([]ConstructRecord, 0, len(snapshot.Constructs)),
+	}
+	for _, construct := range snapshot.Constructs {
+		constructRecord, err := NewConstructRecord(construct)
+		if err != nil {
+			return DocumentRecord{}, err
+		}
+		record.Constructs = append(record.Constructs, constructRecord)
+	}
+	return record, nil
+}
+
+func NewConstructRecord(construct *positioning.Construct) (ConstructRecord, error) {
+	position, err := encodePosition(construct.Position)
+	if err != nil {
+		return ConstructRecord{}, err
+	}
+	metadataJSON, err := json.Marshal(construct.Metadata)
+	if err != nil {
+		return ConstructRecord{}, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return ConstructRecord{
+		ID:         string(construct.ID),
+		Position:   position,
+		Content:    construct.Content,
+		Type:       string(construct.Type),
+		CreatedBy:  string(construct.CreatedBy),
+		ModifiedBy: string(construct.ModifiedBy),
+		Metadata:   string(metadataJSON),
+	}, nil
+}
+
+// Document rebuilds the document a record holds. Records of a version newer
+// than DocumentRecordVersion were written by a later release and are refused
+// rather than misread.
+func (r DocumentRecord) Document() (*positioning.Document, error) {
+	if r.RecordVersion < 1 || r.RecordVersion > DocumentRecordVersion {
+		return nil, fmt.Errorf("%w: document %s has record version %d", ErrUnsupportedRecordVersion, r.FilePath, r.RecordVersion)
+	}
+
+	snapshot := positioning.DocumentSnapshot{
+		FilePath:      r.FilePath,
+		Version:       r.Version,
+		LastOperation: operations.OperationID(r.LastOperation),
+		Constructs:    make([]*positioning.Construct, 0, len(r.Constructs)),
+	}
+	// A malformed hash is left zero for fsck to report rather than failing the read
+	if hash, err := hex.DecodeString(r.ContentHash); err == nil && len(hash) == len(snapshot.ContentHash) {
+		copy(snapshot.ContentHash[:], hash)
+	}
+
+	for _, constructRecord := range r.Constructs {
+		construct, err := constructRecord.Construct()
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Constructs = append(snapshot.Constructs, construct)
+	}
+	return positioning.RestoreDocument(snapshot)
+}
+
+func (r ConstructRecord) Construct() (*positioning.Construct, error) {
+	position, err := decodePosition(r.Position)
+	if err != nil {
+		return nil, err
+	}
+	construct := &positioning.Construct{
+		ID:         positioning.ConstructID(r.ID),
+		Content:    r.Content,
+		Type:       positioning.ConstructType(r.Type),
+		Position:   position,
+		CreatedBy:  operations.OperationID(r.CreatedBy),
+		ModifiedBy: operations.OperationID(r.ModifiedBy),
+	}
+	if err := json.Unmarshal([]byte(r.Metadata), &construct.Metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+	return construct, nil
+}
+
+// migrateDocumentRecords adds documents.record_version to databases created
+// before it. Their rows are all in the version 1 layout.
+func migrateDocumentRecords(db *sql.DB) error {
+	exists, err := columnExists(db, "documents", "record_version")
+	if err != nil || exists {
+		return err
+	}
+	if _, err := db.Exec("ALTER TABLE documents ADD COLUMN record_version INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return fmt.Errorf("failed to add document record versions: %w", err)
+	}
+	return nil
+}
+
+// writeDocumentRecord replaces the stored document with record
+func writeDocumentRecord(ctx context.Context, tx *sql.Tx, record DocumentRecord) error {
+	now := time.Now().Unix()
+	docQuery := `
+		INSERT OR REPLACE INTO documents
+		(file_path, version, content_hash, last_operation, record_version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, COALESCE((SELECT created_at FROM documents WHERE file_path = ?), ?), ?)
+	`
+	_, err := tx.ExecContext(ctx, docQuery,
+		record.FilePath,
+		record.Version,
+		record.ContentHash,
+		record.LastOperation,
+		record.RecordVersion,
+		record.FilePath,
+		now,
+		now,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM constructs WHERE document_path = ?", record.FilePath); err != nil {
+		return err
+	}
+
+	constructQuery := `
+		INSERT INTO constructs
+		(id, document_path, position_segments, position, content, type, created_by, modified_by, metadata)
+		VALUES (?, ?, '', ?, ?, ?, ?, ?, ?)
+	`
+	for _, construct := range record.Constructs {
+		_, err := tx.ExecContext(ctx, constructQuery,
+			construct.ID,
+			record.FilePath,
+			construct.Position,
+			construct.Content,
+			construct.Type,
+			construct.CreatedBy,
+			construct.ModifiedBy,
+			construct.Metadata,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDocumentRecord reads a document and its constructs. Deleted documents
+// fail with ErrDocumentDeleted unless includeDeleted is set.
+func readDocumentRecord(ctx context.Context, db *sql.DB, filePath string, includeDeleted bool) (DocumentRecord, error) {
+	docQuery := `
+		SELECT file_path, version, content_hash, last_operation, record_version, deleted_at
+		FROM documents WHERE file_path = ?
+	`
+
+	var record DocumentRecord
+	var deletedAt sql.NullInt64
+	err := db.QueryRowContext(ctx, docQuery, filePath).Scan(
+		&record.FilePath,
+		&record.Version,
+		&record.ContentHash,
+		&record.LastOperation,
+		&record.RecordVersion,
+		&deletedAt,
+	)
+	if err == sql.ErrNoRows {
+		return record, ErrDocumentNotFound
+	}
+	if err != nil {
+		return record, err
+	}
+	if deletedAt.Valid && !includeDeleted {
+		return record, ErrDocumentDeleted
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT "+constructColumns+" FROM constructs WHERE document_path = ? ORDER BY position", filePath)
+	if err != nil {
+		return record, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		construct, err := scanConstructRecord(rows)
+		if err != nil {
+			return record, err
+		}
+		record.Constructs = append(record.Constructs, construct)
+	}
+	return record, rows.Err()
+}
+
+// loadDocument reads a document through its record
+func loadDocument(ctx context.Context, db *sql.DB, filePath string, includeDeleted bool) (*positioning.Document, error) {
+	record, err := readDocumentRecord(ctx, db, filePath, includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	return record.Document()
+}
+
+// scanConstructRecord reads a row of constructColumns
+func scanConstructRecord(scanner interface {
+	Scan(dest ...interface{}) error
+}) (ConstructRecord, error) {
+	var record ConstructRecord
+	err := scanner.Scan(
+		&record.ID,
+		&record.Position,
+		&record.Content,
+		&record.Type,
+		&record.CreatedBy,
+		&record.ModifiedBy,
+		&record.Metadata,
+	)
+	return record, err
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+func TestDocumentRecord_RoundTrip(t *testing.T) {
+	doc := positioning.NewDocument("main.go")
+	for i, content := range []string{"package main\n", "func main() {}\n"} {
+		op := &operations.Operation{
+			ID:   operations.NewOperationID([]byte(content)),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(int64(i + 1)), AuthorID: "alice"},
+			}),
+			Content:   content,
+			Author:    "alice",
+			Timestamp: time.Now(),
+		}
+		if err := doc.ApplyOperation(op); err != nil {
+			t.Fatalf("Failed to apply operation: %v", err)
+		}
+	}
+
+	record, err := NewDocumentRecord(doc)
+	if err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+	if record.RecordVersion != DocumentRecordVersion || len(record.Constructs) != 2 {
+		t.Fatalf("Expected a version %d record with 2 constructs, got %+v", DocumentRecordVersion, record)
+	}
+
+	restored, err := record.Document()
+	if err != nil {
+		t.Fatalf("Failed to restore document: %v", err)
+	}
+	content, _ := restored.Render()
+	if content != "package main\nfunc main() {}\n" || restored.Version != doc.Version ||
+		restored.ContentHash != doc.ContentHash || restored.LastOperation != doc.LastOperation {
+		t.Errorf("Expected the document back, got %q at version %d", content, restored.Version)
+	}
+
+	record.RecordVersion = DocumentRecordVersion + 1
+	if _, err := record.Document(); !errors.Is(err, ErrUnsupportedRecordVersion) {
+		t.Errorf("Expected ErrUnsupportedRecordVersion, got %v", err)
+	}
+}
+
+func TestDocumentRecord_StoredLayouts(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// A version 1 record as written by hand, to catch layout changes that
+	// would misread what is already stored
+	position, _ := operations.NewLogootPosition([]operations.PositionSegment{
+		{Value: big.NewInt(7), AuthorID: "bob"},
+	}).MarshalBinary()
+	rows := `
+	INSERT INTO documents (file_path, version, content_hash, last_operation, record_version, created_at, updated_at)
+		VALUES ('v1.go', 4, 'c0535e4be2b79ffd93291305436bf889314e4a3faec05ecffcbb7df31ad9e51a', 'op1', 1, 0, 0);
+	INSERT INTO constructs (id, document_path, position_segments, position, content, type, created_by, modified_by, metadata)
+		VALUES ('op1', 'v1.go', '', ?, 'x', 'content', 'op1', 'op1', '{"semantic":"fix","content_type":"text"}');
+	INSERT INTO documents (file_path, version, content_hash, last_operation, record_version, created_at, updated_at)
+		VALUES ('future.go', 1, '', '', 99, 0, 0);
+	`
+	if _, err := store.db.Exec(rows, position); err != nil {
+		t.Fatalf("Failed to write records: %v", err)
+	}
+
+	doc, err := store.GetDocument(ctx, "v1.go")
+	if err != nil {
+		t.Fatalf("Failed to read version 1 record: %v", err)
+	}
+	construct, err := doc.GetConstruct(operations.NewLogootPosition([]operations.PositionSegment{
+		{Value: big.NewInt(7), AuthorID: "bob"},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to get construct: %v", err)
+	}
+	if doc.Version != 4 || doc.LastOperation != "op1" || construct.Content != "x" || construct.Metadata.Semantic != "fix" {
+		t.Errorf("Expected the version 1 record as written, got version %d and construct %+v", doc.Version, construct)
+	}
+
+	if _, err := store.GetDocument(ctx, "future.go"); !errors.Is(err, ErrUnsupportedRecordVersion) {
+		t.Errorf("Expected ErrUnsupportedRecordVersion for a newer record, got %v", err)
+	}
+}
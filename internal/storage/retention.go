@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// RetentionPolicy controls how long data is kept. A zero duration keeps data forever.
+type RetentionPolicy struct {
+	Operations            Duration `json:"operations,omitempty"`
+	Presence              Duration `json:"presence,omitempty"`
+	ResolvedConversations Duration `json:"resolved_conversations,omitempty"`
+	EnforceInterval       Duration `json:"enforce_interval,omitempty"`
+}
+
+func (p RetentionPolicy) IsEmpty() bool {
+	return p.Operations == 0 && p.Presence == 0 && p.ResolvedConversations == 0
+}
+
+// Duration serializes as a Go duration string ("720h") so policies stay readable in the manifest
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return ErrInvalidData
+	}
+	if str == "" {
+		*d = 0
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(str)
+	if err != nil || parsed < 0 {
+		return ErrInvalidData
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// purged_operations remembers the operations retention removed. Operations
+// kept after them still name them as parents, and are no less valid for it.
+const purgedOperationsSchema = `
+CREATE TABLE IF NOT EXISTS purged_operations (
+	id TEXT PRIMARY KEY,
+	purged_at INTEGER NOT NULL
+);
+`
+
+func migratePurgedOperations(db *sql.DB) error {
+	if _, err := db.Exec(purgedOperationsSchema); err != nil {
+		return fmt.Errorf("failed to create purged operations table: %w", err)
+	}
+	return nil
+}
+
+// purgeOperationsBefore removes operations older than cutoff that no construct
+// still references, recording them in purged_operations
+func purgeOperationsBefore(ctx context.Context, db *sql.DB, cutoff time.Time, dryRun bool) ([]operations.OperationID, error) {
+	query := `
+		SELECT id FROM operations
+		WHERE timestamp < ?
+		AND id NOT IN (SELECT created_by FROM constructs)
+		AND id NOT IN (SELECT modified_by FROM constructs)
+		ORDER BY clock, id
+	`
+
+	rows, err := db.QueryContext(ctx, query, cutoff.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []operations.OperationID
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		purged = append(purged, operations.OperationID(id))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if dryRun || len(purged) == 0 {
+		return purged, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixNano()
+	for _, id := range purged {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM operations WHERE id = ?", string(id)); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO purged_operations (id, purged_at) VALUES (?, ?)", string(id), now); err != nil {
+			return nil, fmt.Errorf("failed to record purged operation: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM intent_analyses WHERE operation_id NOT IN (SELECT id FROM operations)"); err != nil {
+		return nil, fmt.Errorf("failed to delete orphaned analyses: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, deleteOrphanBlobsQuery); err != nil {
+		return nil, fmt.Errorf("failed to delete orphaned blobs: %w", err)
+	}
+
+	return purged, tx.Commit()
+}
+
+// purgedOperations returns those of ids that retention removed
+func purgedOperations(ctx context.Context, db *sql.DB, ids []operations.OperationID) ([]operations.OperationID, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(ids))
+	placeholders := make([]string, len(ids))
+	for i, id := range ids {
+		args[i] = string(id)
+		placeholders[i] = "?"
+	}
+	rows, err := db.QueryContext(ctx, "SELECT id FROM purged_operations WHERE id IN ("+strings.Join(placeholders, ",")+") ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var purged []operations.OperationID
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		purged = append(purged, operations.OperationID(id))
+	}
+	return purged, rows.Err()
+}
@@ -0,0 +1,737 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+// The search index is a set of FTS4 tables beside the data they cover.
+// Operations are indexed by triggers, documents when they are stored and
+// conversations, which live outside the database, through IndexConversations.
+// FTS4 has no ranking function, so hits are ranked with BM25 computed from
+// matchinfo.
+const searchSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS search_operations USING fts4(author, body, tokenize=unicode61);
+
+CREATE TABLE IF NOT EXISTS search_documents (
+	id INTEGER PRIMARY KEY,
+	file_path TEXT NOT NULL,
+	content_type TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_search_documents_path ON search_documents(file_path);
+CREATE VIRTUAL TABLE IF NOT EXISTS search_documents_fts USING fts4(path, body, tokenize=unicode61);
+
+CREATE TABLE IF NOT EXISTS search_conversations (
+	id INTEGER PRIMARY KEY,
+	thread_id TEXT NOT NULL UNIQUE,
+	status TEXT NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_search_conversations_status ON search_conversations(status);
+CREATE TABLE IF NOT EXISTS search_conversation_terms (
+	thread_id TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	value TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_search_conversation_terms ON search_conversation_terms(kind, value);
+CREATE INDEX IF NOT EXISTS idx_search_conversation_terms_thread ON search_conversation_terms(thread_id);
+CREATE TABLE IF NOT EXISTS search_conversation_messages (
+	thread_id TEXT NOT NULL,
+	message_type TEXT NOT NULL,
+	PRIMARY KEY (thread_id, message_type)
+);
+CREATE INDEX IF NOT EXISTS idx_search_conversation_messages_type ON search_conversation_messages(message_type);
+CREATE VIRTUAL TABLE IF NOT EXISTS search_conversations_fts USING fts4(title, body, tokenize=unicode61);
+
+CREATE TRIGGER IF NOT EXISTS search_operations_replace BEFORE INSERT ON operations BEGIN
+	DELETE FROM search_operations WHERE docid = (SELECT rowid FROM operations WHERE id = NEW.id);
+END;
+CREATE TRIGGER IF NOT EXISTS search_operations_insert AFTER INSERT ON operations BEGIN
+	INSERT INTO search_operations (docid, author, body) VALUES (NEW.rowid, NEW.author, ` + indexedOperationContent + `);
+END;
+CREATE TRIGGER IF NOT EXISTS search_operations_delete AFTER DELETE ON operations BEGIN
+	DELETE FROM search_operations WHERE docid = OLD.rowid;
+END;
+`
+
+// indexedOperationContent is the text indexed for an operation row. Base64
+// payloads would match arbitrary queries, so binary operations only match on author.
+const indexedOperationContent = `CASE WHEN NEW.content_type = 'binary' THEN ''
+		WHEN NEW.blob_hash IS NULL THEN NEW.content
+		ELSE (SELECT content FROM blobs WHERE hash = NEW.blob_hash) END`
+
+// Conversation terms are the exact values conversations are filtered on
+const (
+	termParticipant = "participant"
+	termTag         = "tag"
+	termLabel       = "label"
+)
+
+// Ranking parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	// MaxSnippets is how many snippets a hit carries at most
+	MaxSnippets = 3
+	// maxSnippetLength is the longest a snippet grows around its matches, in bytes
+	maxSnippetLength = 240
+)
+
+type SearchKind string
+
+const (
+	SearchOperation    SearchKind = "operation"
+	SearchCode         SearchKind = "code"
+	SearchConversation SearchKind = "conversation"
+)
+
+// SearchQuery selects what one kind of search matches. Every word of Text
+// must match the start of a word in the hit.
+type SearchQuery struct {
+	Text string
+	Kind SearchKind
+	// Author is an operation's author or a conversation's participant
+	Author string
+	// ContentType restricts operations and code, which otherwise leave out binary content
+	ContentType string
+	// Tags and Labels must all be on a conversation
+	Tags   []string
+	Labels []string
+	// Status and MessageType restrict conversations to those with that
+	// status, or holding at least one message of that type
+	Status      string
+	MessageType string
+	// DocumentID, Branch, Tool, Ticket and Language restrict operations to
+	// those with that metadata
+	DocumentID string
+	Branch     string
+	Tool       string
+	Ticket     string
+	Language   string
+	Limit      int
+}
+
+// MatchRange is the byte range [Start, End) of a match
+type MatchRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// SearchSnippet is an excerpt around one or more matches
+type SearchSnippet struct {
+	Text string `json:"text"`
+	// Line is the 1-based line Text starts on
+	Line int `json:"line"`
+	// Highlights are byte offsets into Text
+	Highlights []MatchRange `json:"highlights"`
+}
+
+// SearchHit is a ranked match. Ref is an operation ID, document path or
+// thread ID depending on Kind. Snippets are best first.
+type SearchHit struct {
+	Kind     SearchKind      `json:"kind"`
+	Ref      string          `json:"ref"`
+	Score    float64         `json:"score"`
+	Snippets []SearchSnippet `json:"snippets"`
+}
+
+// ConversationEntry is a conversation as the search index sees it
+type ConversationEntry struct {
+	ThreadID     string
+	Title        string
+	Body         string
+	Status       string
+	Participants []string
+	Tags         []string
+	Labels       []string
+	MessageTypes []string
+	UpdatedAt    time.Time
+}
+
+// migrateSearch creates the search index and fills it with existing
+// operations. It reports whether the index is new, in which case documents
+// still need indexing with indexAllDocuments.
+func migrateSearch(db *sql.DB) (bool, error) {
+	existing, err := tableExists(db, "search_operations")
+	if err != nil {
+		return false, err
+	}
+	indexesMessageTypes, err := tableExists(db, "search_conversation_messages")
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := db.Exec(searchSchema); err != nil {
+		return false, fmt.Errorf("failed to create search index: %w", err)
+	}
+	if existing {
+		// Conversations indexed before message types were get indexed again on the next sync
+		if !indexesMessageTypes {
+			if _, err := db.Exec("UPDATE search_conversations SET updated_at = 0"); err != nil {
+				return false, fmt.Errorf("failed to migrate search index: %w", err)
+			}
+		}
+		return false, nil
+	}
+
+	backfill := `INSERT INTO search_operations (docid, author, body)
+		SELECT rowid, author, ` + strings.ReplaceAll(indexedOperationContent, "NEW.", "operations.") + ` FROM operations`
+	if _, err := db.Exec(backfill); err != nil {
+		return false, fmt.Errorf("failed to index operations: %w", err)
+	}
+	return true, nil
+}
+
+func (cs *ContextStore) Search(ctx context.Context, query SearchQuery) ([]SearchHit, error) {
+	return search(ctx, cs.db, query)
+}
+
+func (cs *ContextStore) IndexConversations(ctx context.Context, entries []ConversationEntry) error {
+	return indexConversations(ctx, cs.db, entries)
+}
+
+func (s *SQLiteStore) Search(ctx context.Context, query SearchQuery) ([]SearchHit, error) {
+	return search(ctx, s.db, query)
+}
+
+func (s *SQLiteStore) IndexConversations(ctx context.Context, entries []ConversationEntry) error {
+	return indexConversations(ctx, s.db, entries)
+}
+
+func tableExists(db *sql.DB, name string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count)
+	return count > 0, err
+}
+
+// initSearch migrates the search index and indexes the documents already in
+// a store the first time it is opened with one
+func initSearch(db *sql.DB, documents DocumentStore) error {
+	created, err := migrateSearch(db)
+	if err != nil || !created {
+		return err
+	}
+	return indexAllDocuments(context.Background(), db, documents)
+}
+
+// indexAllDocuments adds every stored document to the search index
+func indexAllDocuments(ctx context.Context, db *sql.DB, documents DocumentStore) error {
+	paths, err := documents.ListDocuments(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		doc, err := documents.GetDocument(ctx, path)
+		if err != nil {
+			return fmt.Errorf("failed to load %s for indexing: %w", path, err)
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := indexDocumentTx(ctx, tx, doc); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexDocumentTx replaces a document's index entries, one per content type
+// it holds. Binary constructs are not indexed.
+func indexDocumentTx(ctx context.Context, tx *sql.Tx, doc *positioning.Document) error {
+	if err := unindexDocumentTx(ctx, tx, doc.FilePath); err != nil {
+		return err
+	}
+
+	for _, contentType := range []string{operations.ContentTypeText, operations.ContentTypeJSON} {
+		body, err := doc.RenderContentTypes(contentType)
+		if err != nil {
+			return err
+		}
+		if body == "" {
+			continue
+		}
+
+		result, err := tx.ExecContext(ctx, "INSERT INTO search_documents (file_path, content_type) VALUES (?, ?)", doc.FilePath, contentType)
+		if err != nil {
+			return fmt.Errorf("failed to index document: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO search_documents_fts (docid, path, body) VALUES (?, ?, ?)", id, doc.FilePath, body); err != nil {
+			return fmt.Errorf("failed to index document: %w", err)
+		}
+	}
+	return nil
+}
+
+func unindexDocumentTx(ctx context.Context, tx *sql.Tx, path string) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM search_documents_fts WHERE docid IN (SELECT id FROM search_documents WHERE file_path = ?)", path)
+	if err != nil {
+		return fmt.Errorf("failed to unindex document: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM search_documents WHERE file_path = ?", path)
+	return err
+}
+
+// indexConversations brings the conversation index in line with entries.
+// Conversations whose UpdatedAt hasn't changed are left alone and those not
+// in entries are removed, so callers pass every conversation each time.
+func indexConversations(ctx context.Context, db *sql.DB, entries []ConversationEntry) error {
+	rows, err := db.QueryContext(ctx, "SELECT thread_id, updated_at FROM search_conversations")
+	if err != nil {
+		return err
+	}
+	indexed := make(map[string]int64)
+	for rows.Next() {
+		var threadID string
+		var updatedAt int64
+		if err := rows.Scan(&threadID, &updatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		indexed[threadID] = updatedAt
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var changed []ConversationEntry
+	for _, entry := range entries {
+		updatedAt, exists := indexed[entry.ThreadID]
+		if !exists || updatedAt != entry.UpdatedAt.UnixNano() {
+			changed = append(changed, entry)
+		}
+		delete(indexed, entry.ThreadID)
+	}
+	if len(changed) == 0 && len(indexed) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for threadID := range indexed {
+		if err := unindexConversationTx(ctx, tx, threadID); err != nil {
+			return err
+		}
+	}
+	for _, entry := range changed {
+		if err := indexConversationTx(ctx, tx, entry); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func indexConversationTx(ctx context.Context, tx *sql.Tx, entry ConversationEntry) error {
+	if err := unindexConversationTx(ctx, tx, entry.ThreadID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO search_conversations (thread_id, status, updated_at) VALUES (?, ?, ?)",
+		entry.ThreadID, entry.Status, entry.UpdatedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to index conversation: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO search_conversations_fts (docid, title, body) VALUES (?, ?, ?)", id, entry.Title, entry.Body); err != nil {
+		return fmt.Errorf("failed to index conversation: %w", err)
+	}
+
+	for _, messageType := range entry.MessageTypes {
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO search_conversation_messages (thread_id, message_type) VALUES (?, ?)", entry.ThreadID, messageType)
+		if err != nil {
+			return fmt.Errorf("failed to index conversation: %w", err)
+		}
+	}
+
+	terms := map[string][]string{termParticipant: entry.Participants, termTag: entry.Tags, termLabel: entry.Labels}
+	for kind, values := range terms {
+		for _, value := range values {
+			_, err := tx.ExecContext(ctx, "INSERT INTO search_conversation_terms (thread_id, kind, value) VALUES (?, ?, ?)", entry.ThreadID, kind, value)
+			if err != nil {
+				return fmt.Errorf("failed to index conversation: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func unindexConversationTx(ctx context.Context, tx *sql.Tx, threadID string) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM search_conversations_fts WHERE docid IN (SELECT id FROM search_conversations WHERE thread_id = ?)", threadID)
+	if err != nil {
+		return fmt.Errorf("failed to unindex conversation: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM search_conversations WHERE thread_id = ?", threadID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM search_conversation_messages WHERE thread_id = ?", threadID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM search_conversation_terms WHERE thread_id = ?", threadID)
+	return err
+}
+
+// searchSpec describes how one kind of hit is found. join brings in the table
+// holding ref and the filter columns.
+type searchSpec struct {
+	fts  string
+	join string
+	ref  string
+	// weights scale BM25 per FTS column, the second column is always the body
+	weights []float64
+	// contextLines around a match are included in its snippet
+	contextLines int
+	filters      func(q SearchQuery) ([]string, []interface{})
+}
+
+var searchSpecs = map[SearchKind]searchSpec{
+	SearchOperation: {
+		fts:     "search_operations",
+		join:    "JOIN operations o ON o.rowid = search_operations.docid",
+		ref:     "o.id",
+		weights: []float64{0.5, 1.0},
+		filters: func(q SearchQuery) (conditions []string, args []interface{}) {
+			if q.Author != "" {
+				conditions = append(conditions, authorIdentity("o.author"))
+				args = append(args, identityArgs(operations.AuthorID(q.Author))...)
+			}
+			if q.ContentType != "" {
+				conditions = append(conditions, "COALESCE(NULLIF(o.content_type, ''), 'text') = ?")
+				args = append(args, operations.NormalizeContentType(q.ContentType))
+			}
+			for _, field := range []struct{ column, value string }{
+				{"o.document_id", q.DocumentID},
+				{"o.branch", q.Branch},
+				{"o.tool", q.Tool},
+				{"o.ticket", q.Ticket},
+				{"o.language", q.Language},
+			} {
+				if field.value != "" {
+					conditions = append(conditions, field.column+" = ?")
+					args = append(args, field.value)
+				}
+			}
+			return conditions, args
+		},
+	},
+	SearchCode: {
+		fts:          "search_documents_fts",
+		join:         "JOIN search_documents d ON d.id = search_documents_fts.docid",
+		ref:          "d.file_path",
+		weights:      []float64{1.5, 1.0},
+		contextLines: 1,
+		filters: func(q SearchQuery) (conditions []string, args []interface{}) {
+			if q.ContentType != "" {
+				conditions = append(conditions, "d.content_type = ?")
+				args = append(args, operations.NormalizeContentType(q.ContentType))
+			}
+			return conditions, args
+		},
+	},
+	SearchConversation: {
+		fts:     "search_conversations_fts",
+		join:    "JOIN search_conversations c ON c.id = search_conversations_fts.docid",
+		ref:     "c.thread_id",
+		weights: []float64{2.0, 1.0},
+		filters: func(q SearchQuery) (conditions []string, args []interface{}) {
+			term := func(kind, value string) {
+				conditions = append(conditions, "c.thread_id IN (SELECT thread_id FROM search_conversation_terms WHERE kind = ? AND value = ?)")
+				args = append(args, kind, value)
+			}
+			if q.Author != "" {
+				conditions = append(conditions, "c.thread_id IN (SELECT thread_id FROM search_conversation_terms WHERE kind = ? AND "+authorIdentity("value")+")")
+				args = append(args, termParticipant)
+				args = append(args, identityArgs(operations.AuthorID(q.Author))...)
+			}
+			if q.Status != "" {
+				conditions = append(conditions, "c.status = ?")
+				args = append(args, q.Status)
+			}
+			if q.MessageType != "" {
+				conditions = append(conditions, "c.thread_id IN (SELECT thread_id FROM search_conversation_messages WHERE message_type = ?)")
+				args = append(args, q.MessageType)
+			}
+			for _, tag := range q.Tags {
+				term(termTag, tag)
+			}
+			for _, label := range q.Labels {
+				term(termLabel, label)
+			}
+			return conditions, args
+		},
+	},
+}
+
+// search ranks every match of q with BM25 and returns the best q.Limit with snippets
+func search(ctx context.Context, db *sql.DB, q SearchQuery) ([]SearchHit, error) {
+	spec, ok := searchSpecs[q.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown search kind %q", q.Kind)
+	}
+	match := ftsQuery(q.Text)
+	if match == "" {
+		return []SearchHit{}, nil
+	}
+
+	conditions, args := spec.filters(q)
+	// FTS tables can't be aliased in a MATCH, so they are always named in full
+	where := " WHERE " + spec.fts + " MATCH ?"
+	for _, condition := range conditions {
+		where += " AND " + condition
+	}
+	args = append([]interface{}{match}, args...)
+
+	rows, err := db.QueryContext(ctx, "SELECT "+spec.fts+".docid, "+spec.ref+", matchinfo("+spec.fts+", 'pcnalx') FROM "+spec.fts+" "+spec.join+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	// Code has an entry per content type, a document ranks by its best one
+	type ranked struct {
+		docid int64
+		ref   string
+		score float64
+	}
+	best := make(map[string]ranked)
+	for rows.Next() {
+		var r ranked
+		var info []byte
+		if err := rows.Scan(&r.docid, &r.ref, &info); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		r.score = bm25(info, spec.weights)
+		if existing, seen := best[r.ref]; !seen || r.score > existing.score {
+			best[r.ref] = r
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ranking := make([]ranked, 0, len(best))
+	for _, r := range best {
+		ranking = append(ranking, r)
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].score != ranking[j].score {
+			return ranking[i].score > ranking[j].score
+		}
+		return ranking[i].ref < ranking[j].ref
+	})
+	if q.Limit > 0 && len(ranking) > q.Limit {
+		ranking = ranking[:q.Limit]
+	}
+
+	// Only the hits returned need their text and match offsets
+	hits := make([]SearchHit, 0, len(ranking))
+	detail := "SELECT body, offsets(" + spec.fts + ") FROM " + spec.fts + " WHERE " + spec.fts + " MATCH ? AND docid = ?"
+	for _, r := range ranking {
+		var body, offsets string
+		if err := db.QueryRowContext(ctx, detail, match, r.docid).Scan(&body, &offsets); err != nil {
+			return nil, fmt.Errorf("failed to load search hit: %w", err)
+		}
+		hits = append(hits, SearchHit{
+			Kind:     q.Kind,
+			Ref:      r.ref,
+			Score:    r.score,
+			Snippets: buildSnippets(body, bodyMatches(offsets), spec.contextLines),
+		})
+	}
+	return hits, nil
+}
+
+// ftsQuery turns free text into an FTS query matching every word as a
+// prefix. Punctuation separates words, as it does for the tokenizer.
+func ftsQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + "*"
+	}
+	return strings.Join(words, " ")
+}
+
+// bm25 scores a row from its matchinfo 'pcnalx' blob, summing the weighted
+// score of every phrase in every column
+func bm25(info []byte, weights []float64) float64 {
+	values := make([]uint32, len(info)/4)
+	for i := range values {
+		values[i] = binary.NativeEndian.Uint32(info[i*4:])
+	}
+	if len(values) < 3 {
+		return 0
+	}
+
+	phrases, columns, rows := int(values[0]), int(values[1]), float64(values[2])
+	avgLength := values[3 : 3+columns]
+	length := values[3+columns : 3+2*columns]
+	hits := values[3+2*columns:]
+
+	score := 0.0
+	for phrase := 0; phrase < phrases; phrase++ {
+		for column := 0; column < columns && column < len(weights); column++ {
+			x := hits[3*(phrase*columns+column):]
+			frequency, documents := float64(x[0]), float64(x[2])
+			if frequency == 0 {
+				continue
+			}
+
+			idf := math.Log(1 + (rows-documents+0.5)/(documents+0.5))
+			norm := 1 - bm25B
+			if avgLength[column] > 0 {
+				norm += bm25B * float64(length[column]) / float64(avgLength[column])
+			}
+			score += weights[column] * idf * frequency * (bm25K1 + 1) / (frequency + bm25K1*norm)
+		}
+	}
+	return score
+}
+
+// bodyMatches reads the matches in the body column, the second, out of an
+// FTS offsets() string of column, term, byte offset and size quadruples.
+// The matches are sorted and don't overlap.
+func bodyMatches(offsets string) []MatchRange {
+	fields := strings.Fields(offsets)
+	var matches []MatchRange
+	for i := 0; i+3 < len(fields); i += 4 {
+		column, _ := strconv.Atoi(fields[i])
+		start, _ := strconv.Atoi(fields[i+2])
+		size, _ := strconv.Atoi(fields[i+3])
+		if column == 1 {
+			matches = append(matches, MatchRange{Start: start, End: start + size})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+
+	// A word matching several query terms is reported once per term
+	merged := matches[:0]
+	for _, match := range matches {
+		if n := len(merged); n > 0 && match.Start < merged[n-1].End {
+			merged[n-1].End = max(merged[n-1].End, match.End)
+			continue
+		}
+		merged = append(merged, match)
+	}
+	return merged
+}
+
+// buildSnippets cuts text into excerpts around matches, which must be sorted.
+// An excerpt is the lines holding its matches plus contextLines either side,
+// trimmed around the matches when that is longer than maxSnippetLength.
+// Matches close together share an excerpt. Excerpts with the most matches
+// come first and at most MaxSnippets are returned.
+func buildSnippets(text string, matches []MatchRange, contextLines int) []SearchSnippet {
+	type window struct {
+		start, end int
+		matches    []MatchRange
+	}
+
+	var windows []window
+	for _, match := range matches {
+		if match.Start < 0 || match.End > len(text) || match.Start >= match.End {
+			continue
+		}
+
+		start, end := expandLines(text, match.Start, match.End, contextLines)
+		if end-start > maxSnippetLength {
+			start, end = trimAround(text, start, end, match)
+		}
+
+		if n := len(windows); n > 0 && start <= windows[n-1].end && end-windows[n-1].start <= 2*maxSnippetLength {
+			windows[n-1].end = max(windows[n-1].end, end)
+			windows[n-1].matches = append(windows[n-1].matches, match)
+			continue
+		}
+		windows = append(windows, window{start: start, end: end, matches: []MatchRange{match}})
+	}
+
+	sort.SliceStable(windows, func(i, j int) bool { return len(windows[i].matches) > len(windows[j].matches) })
+	if len(windows) > MaxSnippets {
+		windows = windows[:MaxSnippets]
+	}
+
+	snippets := make([]SearchSnippet, 0, len(windows))
+	for _, w := range windows {
+		snippet := SearchSnippet{
+			Text: text[w.start:w.end],
+			Line: strings.Count(text[:w.start], "\n") + 1,
+		}
+		for _, match := range w.matches {
+			snippet.Highlights = append(snippet.Highlights, MatchRange{
+				Start: max(match.Start, w.start) - w.start,
+				End:   min(match.End, w.end) - w.start,
+			})
+		}
+		snippets = append(snippets, snippet)
+	}
+	return snippets
+}
+
+// expandLines widens [start, end) to whole lines plus context lines either side,
+// leaving out the newlines at the edges
+func expandLines(text string, start, end, contextLines int) (int, int) {
+	for i := 0; i <= contextLines; i++ {
+		if i > 0 && start > 0 {
+			start--
+		}
+		start = strings.LastIndexByte(text[:start], '\n') + 1
+	}
+	for i := 0; i <= contextLines; i++ {
+		if i > 0 && end < len(text) {
+			end++
+		}
+		if next := strings.IndexByte(text[end:], '\n'); next >= 0 {
+			end += next
+		} else {
+			end = len(text)
+		}
+	}
+	return start, end
+}
+
+// trimAround narrows [start, end) to maxSnippetLength bytes centred on match,
+// without splitting a UTF-8 sequence
+func trimAround(text string, start, end int, match MatchRange) (int, int) {
+	margin := max(0, (maxSnippetLength-(match.End-match.Start))/2)
+	newStart, newEnd := max(start, match.Start-margin), min(end, match.End+margin)
+	for newStart > start && !utf8.RuneStart(text[newStart]) {
+		newStart--
+	}
+	for newEnd < end && !utf8.RuneStart(text[newEnd]) {
+		newEnd++
+	}
+	return newStart, newEnd
+}
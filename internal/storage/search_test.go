@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+func TestSQLiteStore_Search(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	newOp := func(content, author string, value int64) *operations.Operation {
+		op := &operations.Operation{
+			ID:   operations.NewOperationID([]byte(content + author)),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(value), AuthorID: operations.AuthorID(author)},
+			}),
+			Content:   content,
+			Author:    operations.AuthorID(author),
+			Timestamp: time.Now(),
+			Parents:   []operations.OperationID{},
+		}
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+		return op
+	}
+
+	once := newOp("func calculateTotal(items []Item) (total int) {\n", "alice", 1)
+	twice := newOp("// total is the running total\nvar total int\n", "bob", 2)
+	newOp("func main() {}\n", "alice", 3)
+	newOp(strings.Repeat("total ", DefaultBlobThreshold/5), "carol", 4)
+
+	hits, err := store.Search(ctx, SearchQuery{Text: "total", Kind: SearchOperation, Author: "bob"})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Ref != string(twice.ID) {
+		t.Fatalf("Expected only bob's operation, got %+v", hits)
+	}
+	if snippets := hits[0].Snippets; len(snippets) != 2 || snippets[0].Line != 1 || len(snippets[0].Highlights) != 2 || snippets[1].Line != 2 {
+		t.Fatalf("Expected a snippet per line with line 1's two matches first, got %+v", snippets)
+	}
+	for _, snippet := range hits[0].Snippets {
+		for _, h := range snippet.Highlights {
+			if word := snippet.Text[h.Start:h.End]; word != "total" {
+				t.Errorf("Expected highlight on total, got %q", word)
+			}
+		}
+	}
+
+	// Prefix matching, and content stored as a blob is indexed
+	hits, err = store.Search(ctx, SearchQuery{Text: "TOT", Kind: SearchOperation})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(hits) != 3 {
+		t.Fatalf("Expected 3 operations matching tot, got %d", len(hits))
+	}
+	if hits[2].Ref != string(once.ID) {
+		t.Errorf("Expected the operation mentioning total once to rank last, got %+v", hits)
+	}
+	for i := 1; i < len(hits); i++ {
+		if hits[i].Score > hits[i-1].Score {
+			t.Errorf("Expected hits ordered by score, got %v after %v", hits[i].Score, hits[i-1].Score)
+		}
+	}
+
+	if hits, _ := store.Search(ctx, SearchQuery{Text: "total", Kind: SearchOperation, Limit: 1}); len(hits) != 1 {
+		t.Errorf("Expected the limit to apply, got %d hits", len(hits))
+	}
+	if hits, _ := store.Search(ctx, SearchQuery{Text: "  ", Kind: SearchOperation}); len(hits) != 0 {
+		t.Errorf("Expected no hits for an empty query, got %d", len(hits))
+	}
+
+	if err := store.DeleteOperation(ctx, twice.ID); err != nil {
+		t.Fatalf("Failed to delete operation: %v", err)
+	}
+	if hits, _ := store.Search(ctx, SearchQuery{Text: "running", Kind: SearchOperation}); len(hits) != 0 {
+		t.Errorf("Expected deleted operations to leave the index, got %+v", hits)
+	}
+
+	doc := positioning.NewDocument("billing/total.go")
+	if err := doc.ApplyOperation(once); err != nil {
+		t.Fatalf("Failed to apply operation: %v", err)
+	}
+	if err := store.StoreDocument(ctx, doc); err != nil {
+		t.Fatalf("Failed to store document: %v", err)
+	}
+
+	hits, err = store.Search(ctx, SearchQuery{Text: "calculatetotal items", Kind: SearchCode})
+	if err != nil {
+		t.Fatalf("Failed to search code: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Ref != "billing/total.go" || len(hits[0].Snippets) != 1 {
+		t.Fatalf("Expected one snippet from billing/total.go, got %+v", hits)
+	}
+	if hits, _ := store.Search(ctx, SearchQuery{Text: "billing", Kind: SearchCode}); len(hits) != 1 {
+		t.Errorf("Expected code to match on its path, got %d hits", len(hits))
+	}
+
+	if err := store.DeleteDocument(ctx, doc.FilePath); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+	if hits, _ := store.Search(ctx, SearchQuery{Text: "calculatetotal", Kind: SearchCode}); len(hits) != 0 {
+		t.Errorf("Expected deleted documents to leave the index, got %+v", hits)
+	}
+}
+
+func TestSQLiteStore_IndexConversations(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	now := time.Now()
+	entries := []ConversationEntry{
+		{ThreadID: "t1", Title: "Cache invalidation", Body: "When do we flush the cache?", Status: "open",
+			Participants: []string{"alice"}, Tags: []string{"perf"}, MessageTypes: []string{"question"}, UpdatedAt: now},
+		{ThreadID: "t2", Title: "Naming", Body: "The cache type needs a better name", Status: "resolved",
+			Participants: []string{"bob"}, Labels: []string{"team:core"}, MessageTypes: []string{"comment", "decision"}, UpdatedAt: now},
+	}
+	if err := store.IndexConversations(ctx, entries); err != nil {
+		t.Fatalf("Failed to index conversations: %v", err)
+	}
+
+	hits, err := store.Search(ctx, SearchQuery{Text: "cache", Kind: SearchConversation})
+	if err != nil {
+		t.Fatalf("Failed to search conversations: %v", err)
+	}
+	if len(hits) != 2 || hits[0].Ref != "t1" {
+		t.Fatalf("Expected both threads with the title match first, got %+v", hits)
+	}
+
+	filters := []SearchQuery{
+		{Text: "cache", Kind: SearchConversation, Author: "bob"},
+		{Text: "cache", Kind: SearchConversation, Labels: []string{"team:core"}},
+		{Text: "cache", Kind: SearchConversation, Status: "resolved"},
+		{Text: "cache", Kind: SearchConversation, MessageType: "decision"},
+		{Text: "cache", Kind: SearchConversation, Status: "resolved", MessageType: "comment"},
+	}
+	for _, query := range filters {
+		if hits, _ := store.Search(ctx, query); len(hits) != 1 || hits[0].Ref != "t2" {
+			t.Errorf("Expected only t2 for %+v, got %+v", query, hits)
+		}
+	}
+
+	if hits, _ := store.Search(ctx, SearchQuery{Text: "cache", Kind: SearchConversation, Status: "open", MessageType: "decision"}); len(hits) != 0 {
+		t.Errorf("Expected no open conversation with a decision, got %+v", hits)
+	}
+
+	// Unchanged entries are skipped, changed ones reindexed and missing ones dropped
+	entries = entries[:1]
+	entries[0].Body = "Flush on write"
+	if err := store.IndexConversations(ctx, entries); err != nil {
+		t.Fatalf("Failed to index conversations: %v", err)
+	}
+	if hits, _ := store.Search(ctx, SearchQuery{Text: "write", Kind: SearchConversation}); len(hits) != 0 {
+		t.Errorf("Expected an entry with the same UpdatedAt to be left alone, got %+v", hits)
+	}
+	if hits, _ := store.Search(ctx, SearchQuery{Text: "name", Kind: SearchConversation}); len(hits) != 0 {
+		t.Errorf("Expected a missing entry to be dropped, got %+v", hits)
+	}
+
+	entries[0].UpdatedAt = now.Add(time.Second)
+	if err := store.IndexConversations(ctx, entries); err != nil {
+		t.Fatalf("Failed to index conversations: %v", err)
+	}
+	if hits, _ := store.Search(ctx, SearchQuery{Text: "write", Kind: SearchConversation}); len(hits) != 1 {
+		t.Errorf("Expected the updated entry to be reindexed, got %+v", hits)
+	}
+}
+
+func TestBuildSnippets(t *testing.T) {
+	text := "line one\nline two match\nline three\nline four\nline five match\n"
+	first := strings.Index(text, "match")
+	second := strings.LastIndex(text, "match")
+	matches := []MatchRange{{first, first + 5}, {second, second + 5}}
+
+	snippets := buildSnippets(text, matches, 1)
+	if len(snippets) != 2 {
+		t.Fatalf("Expected 2 snippets, got %+v", snippets)
+	}
+	if snippets[0].Text != "line one\nline two match\nline three" || snippets[0].Line != 1 {
+		t.Errorf("Expected the first match with a line either side, got %+v", snippets[0])
+	}
+	if snippets[1].Line != 4 {
+		t.Errorf("Expected the second snippet to start on line 4, got %d", snippets[1].Line)
+	}
+	for _, s := range snippets {
+		if h := s.Highlights[0]; s.Text[h.Start:h.End] != "match" {
+			t.Errorf("Expected highlight on match, got %q", s.Text[h.Start:h.End])
+		}
+	}
+
+	// Matches within each other's context share a snippet, which ranks first
+	text = "a\nmatch b\nmatch c\nd\ne\nf\nmatch g\n"
+	var all []MatchRange
+	for i := 0; i < len(text); {
+		j := strings.Index(text[i:], "match")
+		if j < 0 {
+			break
+		}
+		all = append(all, MatchRange{i + j, i + j + 5})
+		i += j + 5
+	}
+	snippets = buildSnippets(text, all, 1)
+	if len(snippets) != 2 || len(snippets[0].Highlights) != 2 || snippets[0].Line != 1 {
+		t.Errorf("Expected the first two matches to share a leading snippet, got %+v", snippets)
+	}
+
+	// Long lines are cut down around the match
+	long := strings.Repeat("x", 1000) + " match " + strings.Repeat("y", 1000)
+	at := strings.Index(long, "match")
+	snippets = buildSnippets(long, []MatchRange{{at, at + 5}}, 0)
+	if len(snippets) != 1 || len(snippets[0].Text) > maxSnippetLength || !strings.Contains(snippets[0].Text, "match") {
+		t.Errorf("Expected a trimmed snippet around the match, got %d bytes", len(snippets[0].Text))
+	}
+}
+
+func TestMigrateSearch_MessageTypes(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	entry := ConversationEntry{ThreadID: "t1", Title: "Schema", Body: "Use a join table", Status: "resolved",
+		MessageTypes: []string{"decision"}, UpdatedAt: time.Now()}
+	if err := store.IndexConversations(ctx, []ConversationEntry{entry}); err != nil {
+		t.Fatalf("Failed to index conversations: %v", err)
+	}
+
+	// An index built before message types were indexed is rebuilt on the next sync
+	if _, err := store.db.Exec("DROP TABLE search_conversation_messages"); err != nil {
+		t.Fatalf("Failed to drop table: %v", err)
+	}
+	if _, err := migrateSearch(store.db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if err := store.IndexConversations(ctx, []ConversationEntry{entry}); err != nil {
+		t.Fatalf("Failed to index conversations: %v", err)
+	}
+	if hits, _ := store.Search(ctx, SearchQuery{Text: "join", Kind: SearchConversation, MessageType: "decision"}); len(hits) != 1 {
+		t.Errorf("Expected the conversation to be reindexed with its message types, got %+v", hits)
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const settingsSchema = `
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+`
+
+func migrateSettings(db *sql.DB) error {
+	if _, err := db.Exec(settingsSchema); err != nil {
+		return fmt.Errorf("failed to create settings table: %w", err)
+	}
+	return nil
+}
+
+func (cs *ContextStore) GetSetting(ctx context.Context, key string) (json.RawMessage, error) {
+	return getSetting(ctx, cs.db, key)
+}
+
+func (cs *ContextStore) PutSetting(ctx context.Context, key string, value json.RawMessage) error {
+	return putSetting(ctx, cs.db, key, value)
+}
+
+func (s *SQLiteStore) GetSetting(ctx context.Context, key string) (json.RawMessage, error) {
+	return getSetting(ctx, s.db, key)
+}
+
+func (s *SQLiteStore) PutSetting(ctx context.Context, key string, value json.RawMessage) error {
+	return putSetting(ctx, s.db, key, value)
+}
+
+func getSetting(ctx context.Context, db *sql.DB, key string) (json.RawMessage, error) {
+	var value string
+	err := db.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSettingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(value), nil
+}
+
+// putSetting replaces the setting's value, which must be valid JSON
+func putSetting(ctx context.Context, db *sql.DB, key string, value json.RawMessage) error {
+	if !json.Valid(value) {
+		return ErrInvalidData
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		key, string(value), time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to save setting %s: %w", key, err)
+	}
+	return nil
+}
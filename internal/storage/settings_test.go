@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSQLiteStore_Settings(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	if _, err := store.GetSetting(ctx, "intent_taxonomy"); !errors.Is(err, ErrSettingNotFound) {
+		t.Fatalf("Expected ErrSettingNotFound before saving, got %v", err)
+	}
+	for _, value := range []string{`{"strict":false}`, `{"strict":true}`} {
+		if err := store.PutSetting(ctx, "intent_taxonomy", json.RawMessage(value)); err != nil {
+			t.Fatalf("Failed to save setting: %v", err)
+		}
+	}
+	value, err := store.GetSetting(ctx, "intent_taxonomy")
+	if err != nil {
+		t.Fatalf("Failed to load setting: %v", err)
+	}
+	if string(value) != `{"strict":true}` {
+		t.Errorf("Expected the latest value, got %s", value)
+	}
+	if err := store.PutSetting(ctx, "broken", json.RawMessage(`{`)); !errors.Is(err, ErrInvalidData) {
+		t.Errorf("Expected ErrInvalidData for malformed JSON, got %v", err)
+	}
+}
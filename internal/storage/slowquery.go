@@ -0,0 +1,291 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/logging"
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is the name stores open their databases with: sqlite3, with
+// every statement timed so slow ones can be logged
+const sqliteDriver = "contextdb-sqlite3"
+
+func init() {
+	sql.Register(sqliteDriver, timedDriver{&sqlite3.SQLiteDriver{}})
+}
+
+// DefaultSlowQueryThreshold is how long a statement may take before it is
+// logged, long enough that only something like a missing index reaches it
+const DefaultSlowQueryThreshold = 100 * time.Millisecond
+
+var (
+	slowQueryThreshold atomic.Int64
+	slowQueryLogger    = logging.NewLogger("storage")
+)
+
+func init() {
+	slowQueryThreshold.Store(int64(DefaultSlowQueryThreshold))
+}
+
+// SetSlowQueryThreshold logs every statement that takes threshold or longer
+// in SQLite, with its SQL, the rows it read or changed and the store method
+// that ran it. Zero logs none. It applies to every store in the process.
+func SetSlowQueryThreshold(threshold time.Duration) {
+	slowQueryThreshold.Store(int64(threshold))
+}
+
+var (
+	whitespace = regexp.MustCompile(`\s+`)
+	// placeholderList matches the runs of placeholders IN clauses are built
+	// from, which differ in length with each call
+	placeholderList = regexp.MustCompile(`\?(\s*,\s*\?)+`)
+)
+
+// queryShape is a statement's SQL on one line, with lists of placeholders
+// shortened, so the same statement looks the same however it was called
+func queryShape(query string) string {
+	shape := whitespace.ReplaceAllString(strings.TrimSpace(query), " ")
+	return placeholderList.ReplaceAllString(shape, "?, ...")
+}
+
+// storeMethod names the outermost storage method on the stack, the one
+// called from outside the package that ran the statement, or the outermost
+// function when no method did
+func storeMethod() string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	const prefix = "github.com/jeremytregunna/contextdb/internal/storage."
+
+	method, function := "", ""
+	for {
+		frame, more := frames.Next()
+		name := strings.TrimPrefix(frame.Function, prefix)
+		switch {
+		case name == frame.Function:
+			// Outside the package, still in database/sql or already past it
+			if method != "" || function != "" && !strings.HasPrefix(name, "database/sql.") {
+				more = false
+			}
+		case strings.HasPrefix(name, "(*timed"):
+		case strings.HasPrefix(name, "("):
+			method = name
+		default:
+			function = name
+		}
+		if !more {
+			break
+		}
+	}
+	if method != "" {
+		return method
+	}
+	return function
+}
+
+// observeQuery logs query when it took at least the threshold
+func observeQuery(query string, elapsed time.Duration, rows int64) {
+	threshold := time.Duration(slowQueryThreshold.Load())
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+	slowQueryLogger.Warn("Slow query", map[string]interface{}{
+		"query":       queryShape(query),
+		"duration_ms": float64(elapsed.Microseconds()) / 1000,
+		"rows":        rows,
+		"method":      storeMethod(),
+	})
+}
+
+type timedDriver struct {
+	driver *sqlite3.SQLiteDriver
+}
+
+func (d timedDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.driver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &timedConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+// timedConn times what runs on a connection. Its SQLiteConn is reached
+// through Unwrap, for the features only sqlite3 connections have.
+type timedConn struct {
+	conn *sqlite3.SQLiteConn
+}
+
+func (c *timedConn) Unwrap() *sqlite3.SQLiteConn {
+	return c.conn
+}
+
+// unwrapSQLiteConn returns the SQLiteConn a driver connection from Conn.Raw
+// is or wraps
+func unwrapSQLiteConn(driverConn interface{}) (*sqlite3.SQLiteConn, bool) {
+	if timed, ok := driverConn.(*timedConn); ok {
+		return timed.Unwrap(), true
+	}
+	conn, ok := driverConn.(*sqlite3.SQLiteConn)
+	return conn, ok
+}
+
+func (c *timedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.conn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &timedStmt{stmt: stmt.(*sqlite3.SQLiteStmt), query: query}, nil
+}
+
+func (c *timedConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *timedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.conn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return timedTx{tx}, nil
+}
+
+func (c *timedConn) Ping(ctx context.Context) error {
+	return c.conn.Ping(ctx)
+}
+
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	result, err := c.conn.ExecContext(ctx, query, args)
+	if err == nil {
+		observeQuery(query, time.Since(start), rowsAffected(result))
+	}
+	return result, err
+}
+
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.conn.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &timedRows{rows: rows, query: query, elapsed: time.Since(start)}, nil
+}
+
+func rowsAffected(result driver.Result) int64 {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return affected
+}
+
+// timedTx times commits, which wait on the disk
+type timedTx struct {
+	tx driver.Tx
+}
+
+func (t timedTx) Commit() error {
+	start := time.Now()
+	err := t.tx.Commit()
+	if err == nil {
+		observeQuery("COMMIT", time.Since(start), 0)
+	}
+	return err
+}
+
+func (t timedTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+type timedStmt struct {
+	stmt  *sqlite3.SQLiteStmt
+	query string
+}
+
+func (s *timedStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *timedStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *timedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	start := time.Now()
+	result, err := s.stmt.Exec(args)
+	if err == nil {
+		observeQuery(s.query, time.Since(start), rowsAffected(result))
+	}
+	return result, err
+}
+
+func (s *timedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.stmt.Query(args)
+	if err != nil {
+		return nil, err
+	}
+	return &timedRows{rows: rows, query: s.query, elapsed: time.Since(start)}, nil
+}
+
+func (s *timedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	result, err := s.stmt.ExecContext(ctx, args)
+	if err == nil {
+		observeQuery(s.query, time.Since(start), rowsAffected(result))
+	}
+	return result, err
+}
+
+func (s *timedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.stmt.QueryContext(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return &timedRows{rows: rows, query: s.query, elapsed: time.Since(start)}, nil
+}
+
+// timedRows adds up the time spent in SQLite reading rows, not the time the
+// caller spends between them, and observes the total once they're closed
+type timedRows struct {
+	rows    driver.Rows
+	query   string
+	elapsed time.Duration
+	count   int64
+}
+
+func (r *timedRows) Columns() []string {
+	return r.rows.Columns()
+}
+
+func (r *timedRows) Next(dest []driver.Value) error {
+	start := time.Now()
+	err := r.rows.Next(dest)
+	r.elapsed += time.Since(start)
+	if err == nil {
+		r.count++
+	}
+	return err
+}
+
+func (r *timedRows) Close() error {
+	err := r.rows.Close()
+	observeQuery(r.query, r.elapsed, r.count)
+	return err
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/logging"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestQueryShape(t *testing.T) {
+	query := `
+		SELECT id FROM operations
+		WHERE author IN (?, ?,?)  AND  id = ?`
+	expected := "SELECT id FROM operations WHERE author IN (?, ...) AND id = ?"
+	if shape := queryShape(query); shape != expected {
+		t.Errorf("Expected shape %q, got %q", expected, shape)
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	var lines []string
+	logging.SetOutput(outputFunc(func(level logging.LogLevel, line string) error {
+		lines = append(lines, line)
+		return nil
+	}))
+	defer logging.SetOutput(nil)
+	defer SetSlowQueryThreshold(DefaultSlowQueryThreshold)
+
+	// Nothing SQLite does here takes an hour
+	SetSlowQueryThreshold(time.Hour)
+	if _, err := store.GetOperation(ctx, operations.NewOperationID([]byte("missing"))); err == nil {
+		t.Fatalf("Expected a missing operation to fail")
+	}
+	if len(lines) != 0 {
+		t.Fatalf("Expected no slow queries, got %q", lines)
+	}
+
+	SetSlowQueryThreshold(time.Nanosecond)
+	if _, err := store.GetOperation(ctx, operations.NewOperationID([]byte("missing"))); err == nil {
+		t.Fatalf("Expected a missing operation to fail")
+	}
+	if len(lines) == 0 {
+		t.Fatalf("Expected the query to be logged as slow")
+	}
+	for _, field := range []string{"[WARN] storage: Slow query", `"method":"(*SQLiteStore).GetOperation"`, `"rows":0`, `"query":"SELECT `} {
+		if !strings.Contains(lines[0], field) {
+			t.Errorf("Expected %s in %q", field, lines[0])
+		}
+	}
+
+	SetSlowQueryThreshold(0)
+	lines = nil
+	if _, err := store.GetOperation(ctx, operations.NewOperationID([]byte("missing"))); err == nil {
+		t.Fatalf("Expected a missing operation to fail")
+	}
+	if len(lines) != 0 {
+		t.Errorf("Expected a zero threshold to log nothing, got %q", lines)
+	}
+}
+
+type outputFunc func(level logging.LogLevel, line string) error
+
+func (f outputFunc) Write(level logging.LogLevel, line string) error {
+	return f(level, line)
+}
@@ -1,10 +1,12 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jeremytregunna/contextdb/internal/operations"
@@ -12,128 +14,228 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+const (
+	insertOperationQuery = `
+		INSERT OR REPLACE INTO operations
+		(id, type, position_segments, position, content, content_type, length, author, timestamp, parents, metadata, blob_hash,
+		document_id, branch, tool, ticket, language, clock, utc_offset)
+		VALUES (?, ?, '', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	selectOperationColumns = "SELECT " + operationColumns + " FROM operations"
+)
+
+type SQLiteOptions struct {
+	// JournalMode is passed to PRAGMA journal_mode, WAL lets readers proceed during writes
+	JournalMode string
+	// BusyTimeout is how long a connection waits on a locked database before failing
+	BusyTimeout time.Duration
+	// AsyncWrites routes StoreOperation through a queue that commits operations in batches
+	AsyncWrites bool
+	// BatchSize caps how many queued operations share one transaction
+	BatchSize int
+	// FlushInterval is the longest a queued operation waits for its batch to fill
+	FlushInterval time.Duration
+	// BlobThreshold is the content size at which content is stored deduplicated
+	// in the blob table, zero keeps all content inline
+	BlobThreshold int
+}
+
+func DefaultSQLiteOptions() SQLiteOptions {
+	return SQLiteOptions{
+		JournalMode:   "WAL",
+		BusyTimeout:   5 * time.Second,
+		BatchSize:     256,
+		FlushInterval: 5 * time.Millisecond,
+		BlobThreshold: DefaultBlobThreshold,
+	}
+}
+
 type SQLiteStore struct {
-	db *sql.DB
+	db        *sql.DB
+	retention RetentionPolicy
+	mutex     sync.RWMutex
+
+	stmts         map[string]*sql.Stmt
+	stmtMutex     sync.Mutex
+	writes        *writeQueue
+	blobThreshold int
 }
 
 func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	return NewSQLiteStoreWithOptions(dbPath, DefaultSQLiteOptions())
+}
+
+func NewSQLiteStoreWithOptions(dbPath string, opts SQLiteOptions) (*SQLiteStore, error) {
+	db, err := sql.Open(sqliteDriver, sqliteDSN(dbPath, opts))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	store := &SQLiteStore{db: db}
+	store := &SQLiteStore{
+		db:            db,
+		stmts:         make(map[string]*sql.Stmt),
+		blobThreshold: opts.BlobThreshold,
+	}
 	if err := store.initSchema(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
+	if opts.AsyncWrites {
+		store.writes = newWriteQueue(store, opts.BatchSize, opts.FlushInterval)
+	}
+
 	return store, nil
 }
 
+// sqliteDSN applies pragmas through the connection string so every pooled
+// connection gets them, not just the one that happened to run a PRAGMA
+func sqliteDSN(dbPath string, opts SQLiteOptions) string {
+	var params []string
+	if opts.JournalMode != "" {
+		params = append(params, "_journal_mode="+opts.JournalMode)
+	}
+	if opts.BusyTimeout > 0 {
+		params = append(params, fmt.Sprintf("_busy_timeout=%d", opts.BusyTimeout.Milliseconds()))
+	}
+	if len(params) == 0 {
+		return dbPath
+	}
+
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	return dbPath + separator + strings.Join(params, "&")
+}
+
 func (s *SQLiteStore) initSchema() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS operations (
-		id TEXT PRIMARY KEY,
-		type TEXT NOT NULL,
-		position_segments TEXT NOT NULL,
-		content TEXT NOT NULL,
-		content_type TEXT DEFAULT 'text',
-		length INTEGER,
-		author TEXT NOT NULL,
-		timestamp INTEGER NOT NULL,
-		parents TEXT,
-		metadata TEXT
-	);
-
-	CREATE TABLE IF NOT EXISTS documents (
-		file_path TEXT PRIMARY KEY,
-		version INTEGER NOT NULL,
-		content_hash TEXT NOT NULL,
-		last_operation TEXT,
-		created_at INTEGER NOT NULL,
-		updated_at INTEGER NOT NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS constructs (
-		id TEXT PRIMARY KEY,
-		document_path TEXT NOT NULL,
-		position_segments TEXT NOT NULL,
-		content TEXT NOT NULL,
-		type TEXT NOT NULL,
-		created_by TEXT NOT NULL,
-		modified_by TEXT NOT NULL,
-		metadata TEXT,
-		FOREIGN KEY (document_path) REFERENCES documents(file_path),
-		FOREIGN KEY (created_by) REFERENCES operations(id),
-		FOREIGN KEY (modified_by) REFERENCES operations(id)
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_operations_timestamp ON operations(timestamp);
-	CREATE INDEX IF NOT EXISTS idx_operations_author ON operations(author);
-	CREATE INDEX IF NOT EXISTS idx_operations_type ON operations(type);
-	CREATE INDEX IF NOT EXISTS idx_constructs_document ON constructs(document_path);
-	CREATE INDEX IF NOT EXISTS idx_constructs_type ON constructs(type);
-	CREATE INDEX IF NOT EXISTS idx_constructs_position ON constructs(position_segments);
-	`
+	if _, err := s.db.Exec(baseSchema); err != nil {
+		return err
+	}
+	if err := migrate(s.db); err != nil {
+		return err
+	}
+	return initSearch(s.db, s)
+}
 
-	_, err := s.db.Exec(schema)
-	return err
+func (s *SQLiteStore) StoreOperation(ctx context.Context, op *operations.Operation) error {
+	b := newBlob(op.Content, s.blobThreshold)
+	args, err := operationArgs(op, b)
+	if err != nil {
+		return err
+	}
+
+	if s.writes != nil {
+		return s.writes.enqueue(ctx, args, b)
+	}
+
+	stmt, err := s.prepare(ctx, insertOperationQuery)
+	if err != nil {
+		return err
+	}
+
+	if b == nil {
+		_, err = stmt.ExecContext(ctx, args...)
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := storeOperationTx(ctx, tx, tx.StmtContext(ctx, stmt), args, b); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (s *SQLiteStore) StoreOperation(op *operations.Operation) error {
-	positionJSON, err := json.Marshal(op.Position.Segments)
+// operationArgs builds the insert arguments for op. When b is set the content
+// column is left empty and the operation points at the blob instead.
+func operationArgs(op *operations.Operation, b *blob) ([]interface{}, error) {
+	position, err := encodePosition(op.Position)
 	if err != nil {
-		return fmt.Errorf("failed to marshal position: %w", err)
+		return nil, err
 	}
 
 	parentsJSON, err := json.Marshal(op.Parents)
 	if err != nil {
-		return fmt.Errorf("failed to marshal parents: %w", err)
+		return nil, fmt.Errorf("failed to marshal parents: %w", err)
 	}
 
+	op.Metadata.Normalize()
 	metadataJSON, err := json.Marshal(op.Metadata)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	query := `
-		INSERT OR REPLACE INTO operations
-		(id, type, position_segments, content, content_type, length, author, timestamp, parents, metadata)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
 	contentType := op.ContentType
 	if contentType == "" {
 		contentType = "text" // Default for backwards compatibility
 	}
 
-	_, err = s.db.Exec(query,
+	content := op.Content
+	var blobHash interface{}
+	if b != nil {
+		content = ""
+		blobHash = b.hash
+	}
+
+	return []interface{}{
 		string(op.ID),
 		string(op.Type),
-		string(positionJSON),
-		op.Content,
+		position,
+		content,
 		contentType,
 		op.Length,
 		string(op.Author),
-		op.Timestamp.Unix(),
+		op.Timestamp.UnixNano(),
 		string(parentsJSON),
 		string(metadataJSON),
-	)
+		blobHash,
+		op.Metadata.DocumentID,
+		op.Metadata.Branch,
+		op.Metadata.Tool,
+		op.Metadata.Ticket,
+		op.Metadata.Language,
+		op.Order(),
+		utcOffset(op.Timestamp),
+	}, nil
+}
 
-	return err
+// prepare returns a cached prepared statement for query, preparing it on first use
+func (s *SQLiteStore) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	s.stmtMutex.Lock()
+	defer s.stmtMutex.Unlock()
+
+	if stmt, exists := s.stmts[query]; exists {
+		return stmt, nil
+	}
+
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	s.stmts[query] = stmt
+	return stmt, nil
 }
 
-func (s *SQLiteStore) GetOperation(id operations.OperationID) (*operations.Operation, error) {
-	query := `
-		SELECT id, type, position_segments, content, content_type, length, author, timestamp, parents, metadata
-		FROM operations WHERE id = ?
-	`
+func (s *SQLiteStore) GetOperation(ctx context.Context, id operations.OperationID) (*operations.Operation, error) {
+	stmt, err := s.prepare(ctx, selectOperationColumns+" WHERE id = ?")
+	if err != nil {
+		return nil, err
+	}
 
-	row := s.db.QueryRow(query, string(id))
-	return s.scanOperation(row)
+	op, err := s.scanOperation(stmt.QueryRowContext(ctx, string(id)))
+	if err == sql.ErrNoRows {
+		return nil, ErrOperationNotFound
+	}
+	return op, err
 }
 
-func (s *SQLiteStore) GetOperations(ids []operations.OperationID) ([]*operations.Operation, error) {
+func (s *SQLiteStore) GetOperations(ctx context.Context, ids []operations.OperationID) ([]*operations.Operation, error) {
 	if len(ids) == 0 {
 		return nil, nil
 	}
@@ -146,12 +248,12 @@ func (s *SQLiteStore) GetOperations(ids []operations.OperationID) ([]*operations
 	}
 
 	query := fmt.Sprintf(`
-		SELECT id, type, position_segments, content, content_type, length, author, timestamp, parents, metadata
+		SELECT %s
 		FROM operations WHERE id IN (%s)
-		ORDER BY timestamp
-	`, strings.Join(placeholders, ","))
+		ORDER BY clock, id
+	`, operationColumns, strings.Join(placeholders, ","))
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -169,254 +271,112 @@ func (s *SQLiteStore) GetOperations(ids []operations.OperationID) ([]*operations
 	return operations, rows.Err()
 }
 
-func (s *SQLiteStore) GetOperationsSince(timestamp time.Time) ([]*operations.Operation, error) {
-	query := `
-		SELECT id, type, position_segments, content, content_type, length, author, timestamp, parents, metadata
-		FROM operations WHERE timestamp >= ?
-		ORDER BY timestamp
-	`
+func (s *SQLiteStore) GetOperationsSince(ctx context.Context, timestamp time.Time) ([]*operations.Operation, error) {
+	return collectOperations(func(fn OperationFunc) error {
+		return s.ForEachOperationSince(ctx, timestamp, fn)
+	})
+}
+
+func (s *SQLiteStore) GetOperationsByAuthor(ctx context.Context, authorID operations.AuthorID) ([]*operations.Operation, error) {
+	return collectOperations(func(fn OperationFunc) error {
+		return s.ForEachOperationByAuthor(ctx, authorID, fn)
+	})
+}
 
-	rows, err := s.db.Query(query, timestamp.Unix())
+func (s *SQLiteStore) ForEachOperationSince(ctx context.Context, timestamp time.Time, fn OperationFunc) error {
+	stmt, err := s.prepare(ctx, selectOperationColumns+" WHERE timestamp >= ? ORDER BY clock, id")
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
 
-	var operations []*operations.Operation
-	for rows.Next() {
-		op, err := s.scanOperation(rows)
-		if err != nil {
-			return nil, err
-		}
-		operations = append(operations, op)
+	rows, err := stmt.QueryContext(ctx, timestamp.UnixNano())
+	if err != nil {
+		return err
 	}
-
-	return operations, rows.Err()
+	return forEachOperationRow(rows, s.scanOperation, fn)
 }
 
-func (s *SQLiteStore) GetOperationsByAuthor(authorID operations.AuthorID) ([]*operations.Operation, error) {
-	query := `
-		SELECT id, type, position_segments, content, content_type, length, author, timestamp, parents, metadata
-		FROM operations WHERE author = ?
-		ORDER BY timestamp
-	`
-
-	rows, err := s.db.Query(query, string(authorID))
+func (s *SQLiteStore) ForEachOperationByAuthor(ctx context.Context, authorID operations.AuthorID, fn OperationFunc) error {
+	stmt, err := s.prepare(ctx, selectOperationColumns+" WHERE "+authorIdentity("author")+" ORDER BY clock, id")
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
 
-	var operations []*operations.Operation
-	for rows.Next() {
-		op, err := s.scanOperation(rows)
-		if err != nil {
-			return nil, err
-		}
-		operations = append(operations, op)
+	rows, err := stmt.QueryContext(ctx, identityArgs(authorID)...)
+	if err != nil {
+		return err
 	}
-
-	return operations, rows.Err()
+	return forEachOperationRow(rows, s.scanOperation, fn)
 }
 
-func (s *SQLiteStore) DeleteOperation(id operations.OperationID) error {
-	_, err := s.db.Exec("DELETE FROM operations WHERE id = ?", string(id))
+func (s *SQLiteStore) DeleteOperation(ctx context.Context, id operations.OperationID) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM operations WHERE id = ?", string(id))
 	return err
 }
 
-func (s *SQLiteStore) StoreDocument(doc *positioning.Document) error {
-	tx, err := s.db.Begin()
+func (s *SQLiteStore) StoreDocument(ctx context.Context, doc *positioning.Document) error {
+	record, err := NewDocumentRecord(doc)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	now := time.Now().Unix()
-	docQuery := `
-		INSERT OR REPLACE INTO documents
-		(file_path, version, content_hash, last_operation, created_at, updated_at)
-		VALUES (?, ?, ?, ?, COALESCE((SELECT created_at FROM documents WHERE file_path = ?), ?), ?)
-	`
-
-	_, err = tx.Exec(docQuery,
-		doc.FilePath,
-		doc.Version,
-		fmt.Sprintf("%x", doc.ContentHash),
-		string(doc.LastOperation),
-		doc.FilePath,
-		now,
-		now,
-	)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	_, err = tx.Exec("DELETE FROM constructs WHERE document_path = ?", doc.FilePath)
-	if err != nil {
+	if err := writeDocumentRecord(ctx, tx, record); err != nil {
 		return err
 	}
-
-	constructQuery := `
-		INSERT INTO constructs
-		(id, document_path, position_segments, content, type, created_by, modified_by, metadata)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`
-
-	for posKey, construct := range doc.Constructs {
-		_ = posKey // We have the position in construct.Position
-		positionJSON, err := json.Marshal(construct.Position.Segments)
-		if err != nil {
-			return fmt.Errorf("failed to marshal position: %w", err)
-		}
-
-		metadataJSON, err := json.Marshal(construct.Metadata)
-		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %w", err)
-		}
-
-		_, err = tx.Exec(constructQuery,
-			string(construct.ID),
-			doc.FilePath,
-			string(positionJSON),
-			construct.Content,
-			string(construct.Type),
-			string(construct.CreatedBy),
-			string(construct.ModifiedBy),
-			string(metadataJSON),
-		)
-		if err != nil {
-			return err
-		}
+	if err := indexDocumentTx(ctx, tx, doc); err != nil {
+		return err
 	}
 
 	return tx.Commit()
 }
 
-func (s *SQLiteStore) GetDocument(filePath string) (*positioning.Document, error) {
-	docQuery := `
-		SELECT file_path, version, content_hash, last_operation
-		FROM documents WHERE file_path = ?
-	`
-
-	var doc positioning.Document
-	var contentHashStr string
-	var lastOpStr string
-
-	err := s.db.QueryRow(docQuery, filePath).Scan(
-		&doc.FilePath,
-		&doc.Version,
-		&contentHashStr,
-		&lastOpStr,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, ErrDocumentNotFound
-		}
-		return nil, err
-	}
-
-	doc.Constructs = make(map[operations.PositionKey]*positioning.Construct)
-	doc.PositionIndex = make(map[operations.PositionKey]operations.LogootPosition)
-	doc.AppliedOps = make(map[operations.OperationID]bool)
-	doc.PositionIdx = make([]operations.LogootPosition, 0)
-
-	doc.LastOperation = operations.OperationID(lastOpStr)
-
-	constructQuery := `
-		SELECT id, position_segments, content, type, created_by, modified_by, metadata
-		FROM constructs WHERE document_path = ?
-		ORDER BY position_segments
-	`
-
-	rows, err := s.db.Query(constructQuery, filePath)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var construct positioning.Construct
-		var positionJSON string
-		var metadataJSON string
-		var createdByStr string
-		var modifiedByStr string
-
-		err := rows.Scan(
-			&construct.ID,
-			&positionJSON,
-			&construct.Content,
-			&construct.Type,
-			&createdByStr,
-			&modifiedByStr,
-			&metadataJSON,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		var segments []operations.PositionSegment
-		if err := json.Unmarshal([]byte(positionJSON), &segments); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal position: %w", err)
-		}
-		construct.Position = operations.NewLogootPosition(segments)
-
-		if err := json.Unmarshal([]byte(metadataJSON), &construct.Metadata); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
-		}
+func (s *SQLiteStore) GetDocument(ctx context.Context, filePath string) (*positioning.Document, error) {
+	return loadDocument(ctx, s.db, filePath, false)
+}
 
-		construct.CreatedBy = operations.OperationID(createdByStr)
-		construct.ModifiedBy = operations.OperationID(modifiedByStr)
+// SQLiteStore has no manifest, so its retention policy only lives for the process lifetime
+func (s *SQLiteStore) GetRetentionPolicy() (RetentionPolicy, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
-		posKey := construct.Position.Key()
-		doc.Constructs[posKey] = &construct
-		doc.PositionIndex[posKey] = construct.Position
-		doc.PositionIdx = append(doc.PositionIdx, construct.Position)
-	}
-
-	return &doc, rows.Err()
+	return s.retention, nil
 }
 
-func (s *SQLiteStore) ListDocuments() ([]string, error) {
-	query := "SELECT file_path FROM documents ORDER BY file_path"
-	rows, err := s.db.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+func (s *SQLiteStore) SetRetentionPolicy(policy RetentionPolicy) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	var documents []string
-	for rows.Next() {
-		var filePath string
-		if err := rows.Scan(&filePath); err != nil {
-			return nil, err
-		}
-		documents = append(documents, filePath)
-	}
+	s.retention = policy
+	return nil
+}
 
-	return documents, rows.Err()
+func (s *SQLiteStore) PurgeOperationsBefore(ctx context.Context, cutoff time.Time, dryRun bool) ([]operations.OperationID, error) {
+	return purgeOperationsBefore(ctx, s.db, cutoff, dryRun)
 }
 
-func (s *SQLiteStore) DeleteDocument(filePath string) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+func (s *SQLiteStore) PurgedOperations(ctx context.Context, ids []operations.OperationID) ([]operations.OperationID, error) {
+	return purgedOperations(ctx, s.db, ids)
+}
 
-	_, err = tx.Exec("DELETE FROM constructs WHERE document_path = ?", filePath)
-	if err != nil {
-		return err
+func (s *SQLiteStore) Close() error {
+	// Drain queued writes before the statements they use go away
+	if s.writes != nil {
+		s.writes.close()
 	}
 
-	_, err = tx.Exec("DELETE FROM documents WHERE file_path = ?", filePath)
-	if err != nil {
-		return err
+	s.stmtMutex.Lock()
+	for query, stmt := range s.stmts {
+		stmt.Close()
+		delete(s.stmts, query)
 	}
+	s.stmtMutex.Unlock()
 
-	return tx.Commit()
-}
-
-func (s *SQLiteStore) Close() error {
 	return s.db.Close()
 }
 
@@ -424,21 +384,25 @@ func (s *SQLiteStore) scanOperation(scanner interface {
 	Scan(dest ...interface{}) error
 }) (*operations.Operation, error) {
 	var op operations.Operation
-	var idStr, positionJSON, parentsJSON, metadataJSON string
+	var idStr, parentsJSON, metadataJSON string
+	var position []byte
 	var contentType string
-	var timestampUnix int64
+	var timestampNanos int64
+	var offset int
 
 	err := scanner.Scan(
 		&idStr,
 		&op.Type,
-		&positionJSON,
+		&position,
 		&op.Content,
 		&contentType,
 		&op.Length,
 		&op.Author,
-		&timestampUnix,
+		&timestampNanos,
 		&parentsJSON,
 		&metadataJSON,
+		&op.Clock,
+		&offset,
 	)
 	if err != nil {
 		return nil, err
@@ -446,13 +410,12 @@ func (s *SQLiteStore) scanOperation(scanner interface {
 
 	op.ID = operations.OperationID(idStr)
 	op.ContentType = contentType
-	op.Timestamp = time.Unix(timestampUnix, 0)
+	op.Timestamp = operationTime(timestampNanos, offset)
 
-	var segments []operations.PositionSegment
-	if err := json.Unmarshal([]byte(positionJSON), &segments); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal position: %w", err)
+	op.Position, err = decodePosition(position)
+	if err != nil {
+		return nil, err
 	}
-	op.Position = operations.NewLogootPosition(segments)
 
 	if err := json.Unmarshal([]byte(parentsJSON), &op.Parents); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal parents: %w", err)
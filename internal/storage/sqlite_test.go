@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"context"
+	"fmt"
 	"math/big"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -11,6 +15,7 @@ import (
 )
 
 func TestSQLiteStore_OperationCRUD(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -33,12 +38,12 @@ func TestSQLiteStore_OperationCRUD(t *testing.T) {
 		},
 	}
 
-	err := store.StoreOperation(op)
+	err := store.StoreOperation(ctx, op)
 	if err != nil {
 		t.Fatalf("Failed to store operation: %v", err)
 	}
 
-	retrieved, err := store.GetOperation(op.ID)
+	retrieved, err := store.GetOperation(ctx, op.ID)
 	if err != nil {
 		t.Fatalf("Failed to retrieve operation: %v", err)
 	}
@@ -57,6 +62,7 @@ func TestSQLiteStore_OperationCRUD(t *testing.T) {
 }
 
 func TestSQLiteStore_GetOperationsByAuthor(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -91,10 +97,10 @@ func TestSQLiteStore_GetOperationsByAuthor(t *testing.T) {
 	}
 
 	for _, op := range ops {
-		store.StoreOperation(op)
+		store.StoreOperation(ctx, op)
 	}
 
-	author1Ops, err := store.GetOperationsByAuthor(author1)
+	author1Ops, err := store.GetOperationsByAuthor(ctx, author1)
 	if err != nil {
 		t.Fatalf("Failed to get operations by author1: %v", err)
 	}
@@ -103,7 +109,7 @@ func TestSQLiteStore_GetOperationsByAuthor(t *testing.T) {
 		t.Errorf("Expected 2 operations for author1, got %d", len(author1Ops))
 	}
 
-	author2Ops, err := store.GetOperationsByAuthor(author2)
+	author2Ops, err := store.GetOperationsByAuthor(ctx, author2)
 	if err != nil {
 		t.Fatalf("Failed to get operations by author2: %v", err)
 	}
@@ -114,6 +120,7 @@ func TestSQLiteStore_GetOperationsByAuthor(t *testing.T) {
 }
 
 func TestSQLiteStore_DocumentCRUD(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -148,12 +155,12 @@ func TestSQLiteStore_DocumentCRUD(t *testing.T) {
 	doc.InsertConstruct(construct2)
 	doc.Version = 1
 
-	err := store.StoreDocument(doc)
+	err := store.StoreDocument(ctx, doc)
 	if err != nil {
 		t.Fatalf("Failed to store document: %v", err)
 	}
 
-	retrieved, err := store.GetDocument("test.go")
+	retrieved, err := store.GetDocument(ctx, "test.go")
 	if err != nil {
 		t.Fatalf("Failed to retrieve document: %v", err)
 	}
@@ -162,12 +169,12 @@ func TestSQLiteStore_DocumentCRUD(t *testing.T) {
 		t.Errorf("Expected version %d, got %d", doc.Version, retrieved.Version)
 	}
 
-	if len(retrieved.Constructs) != 2 {
-		t.Errorf("Expected 2 constructs, got %d", len(retrieved.Constructs))
+	if retrieved.ConstructCount() != 2 {
+		t.Errorf("Expected 2 constructs, got %d", retrieved.ConstructCount())
 	}
 
-	construct1Retrieved, exists := retrieved.Constructs[pos1.Key()]
-	if !exists {
+	construct1Retrieved, err := retrieved.GetConstruct(pos1)
+	if err != nil {
 		t.Error("Expected construct1 to exist")
 	} else if construct1Retrieved.Content != "package main" {
 		t.Errorf("Expected content 'package main', got %q", construct1Retrieved.Content)
@@ -175,6 +182,7 @@ func TestSQLiteStore_DocumentCRUD(t *testing.T) {
 }
 
 func TestSQLiteStore_ListDocuments(t *testing.T) {
+	ctx := context.Background()
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
@@ -183,10 +191,10 @@ func TestSQLiteStore_ListDocuments(t *testing.T) {
 	for _, filePath := range docs {
 		doc := positioning.NewDocument(filePath)
 		doc.Version = 1
-		store.StoreDocument(doc)
+		store.StoreDocument(ctx, doc)
 	}
 
-	retrieved, err := store.ListDocuments()
+	retrieved, err := store.ListDocuments(ctx, false)
 	if err != nil {
 		t.Fatalf("Failed to list documents: %v", err)
 	}
@@ -217,6 +225,299 @@ func setupTestStore(t *testing.T) (*SQLiteStore, func()) {
 
 	return store, func() {
 		store.Close()
-		os.Remove(tmpFile.Name())
+		removeDatabaseFiles(tmpFile.Name())
+	}
+}
+
+func removeDatabaseFiles(path string) {
+	os.Remove(path)
+	os.Remove(path + "-wal")
+	os.Remove(path + "-shm")
+}
+
+func TestSQLiteStore_PurgeOperationsBefore(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	pos := operations.NewLogootPosition([]operations.PositionSegment{
+		{Value: big.NewInt(1), AuthorID: "author1"},
+	})
+
+	oldOp := &operations.Operation{
+		ID:        operations.NewOperationID([]byte("old")),
+		Type:      operations.OpInsert,
+		Position:  pos,
+		Content:   "old",
+		Author:    "author1",
+		Timestamp: time.Now().Add(-48 * time.Hour),
+		Parents:   []operations.OperationID{},
+	}
+	referencedOp := &operations.Operation{
+		ID:        operations.NewOperationID([]byte("referenced")),
+		Type:      operations.OpInsert,
+		Position:  pos,
+		Content:   "referenced",
+		Author:    "author1",
+		Timestamp: time.Now().Add(-48 * time.Hour),
+		Parents:   []operations.OperationID{},
+	}
+	newOp := &operations.Operation{
+		ID:        operations.NewOperationID([]byte("new")),
+		Type:      operations.OpInsert,
+		Position:  pos,
+		Content:   "new",
+		Author:    "author1",
+		Timestamp: time.Now(),
+		Parents:   []operations.OperationID{},
+	}
+
+	for _, op := range []*operations.Operation{oldOp, referencedOp, newOp} {
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+	}
+
+	doc := positioning.NewDocument("test.go")
+	doc.ApplyOperation(referencedOp)
+	if err := store.StoreDocument(ctx, doc); err != nil {
+		t.Fatalf("Failed to store document: %v", err)
+	}
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+	preview, err := store.PurgeOperationsBefore(ctx, cutoff, true)
+	if err != nil {
+		t.Fatalf("Failed to preview purge: %v", err)
+	}
+	if len(preview) != 1 || preview[0] != oldOp.ID {
+		t.Fatalf("Expected dry run to report only the unreferenced old operation, got %v", preview)
+	}
+	if _, err := store.GetOperation(ctx, oldOp.ID); err != nil {
+		t.Fatalf("Dry run should not delete operations: %v", err)
+	}
+
+	if _, err := store.PurgeOperationsBefore(ctx, cutoff, false); err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if _, err := store.GetOperation(ctx, oldOp.ID); err == nil {
+		t.Error("Expected old operation to be purged")
+	}
+	if _, err := store.GetOperation(ctx, referencedOp.ID); err != nil {
+		t.Errorf("Referenced operation should be kept: %v", err)
+	}
+	if _, err := store.GetOperation(ctx, newOp.ID); err != nil {
+		t.Errorf("Recent operation should be kept: %v", err)
+	}
+}
+
+func TestSQLiteStore_CancelledContext(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := store.GetOperationsSince(ctx, time.Time{}); err == nil {
+		t.Error("Expected query with cancelled context to fail")
+	}
+
+	if _, err := store.ListDocuments(ctx, false); err == nil {
+		t.Error("Expected listing with cancelled context to fail")
+	}
+}
+
+func TestSQLiteStore_AsyncWrites(t *testing.T) {
+	ctx := context.Background()
+	tmpFile, err := os.CreateTemp("", "contextdb_async_*.db")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	tmpFile.Close()
+	defer removeDatabaseFiles(tmpFile.Name())
+
+	opts := DefaultSQLiteOptions()
+	opts.AsyncWrites = true
+	opts.BatchSize = 8
+
+	store, err := NewSQLiteStoreWithOptions(tmpFile.Name(), opts)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	const writers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			op := &operations.Operation{
+				ID:   operations.NewOperationID([]byte(fmt.Sprintf("async-%d", i))),
+				Type: operations.OpInsert,
+				Position: operations.NewLogootPosition([]operations.PositionSegment{
+					{Value: big.NewInt(int64(i + 1)), AuthorID: "author1"},
+				}),
+				Content:   fmt.Sprintf("line %d", i),
+				Author:    "author1",
+				Timestamp: time.Now(),
+				Parents:   []operations.OperationID{},
+			}
+			errs <- store.StoreOperation(ctx, op)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Async store failed: %v", err)
+		}
+	}
+
+	// Stores only return once committed, so every write is visible now
+	ops, err := store.GetOperationsByAuthor(ctx, "author1")
+	if err != nil {
+		t.Fatalf("Failed to read operations: %v", err)
+	}
+	if len(ops) != writers {
+		t.Errorf("Expected %d operations, got %d", writers, len(ops))
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	if err := store.StoreOperation(ctx, ops[0]); err != ErrStoreClosed {
+		t.Errorf("Expected ErrStoreClosed after close, got %v", err)
+	}
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	opts := SQLiteOptions{JournalMode: "WAL", BusyTimeout: 2 * time.Second}
+
+	if dsn := sqliteDSN("test.db", opts); dsn != "test.db?_journal_mode=WAL&_busy_timeout=2000" {
+		t.Errorf("Unexpected DSN: %s", dsn)
+	}
+
+	if dsn := sqliteDSN("file:test.db?cache=shared", opts); dsn != "file:test.db?cache=shared&_journal_mode=WAL&_busy_timeout=2000" {
+		t.Errorf("Unexpected DSN with existing params: %s", dsn)
+	}
+
+	if dsn := sqliteDSN("test.db", SQLiteOptions{}); dsn != "test.db" {
+		t.Errorf("Expected bare path without options, got %s", dsn)
+	}
+}
+
+func TestSQLiteStore_ForEachOperationSince(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		op := &operations.Operation{
+			ID:   operations.NewOperationID([]byte(fmt.Sprintf("iter-%d", i))),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(int64(i + 1)), AuthorID: "author1"},
+			}),
+			Content:   fmt.Sprintf("line %d", i),
+			Author:    "author1",
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+			Parents:   []operations.OperationID{},
+		}
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+	}
+
+	var seen []string
+	err := store.ForEachOperationSince(ctx, base, func(op *operations.Operation) error {
+		seen = append(seen, op.Content)
+		if len(seen) == 3 {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected stop iteration to end cleanly, got %v", err)
+	}
+	if len(seen) != 3 || seen[0] != "line 0" || seen[2] != "line 2" {
+		t.Errorf("Expected first three operations in timestamp order, got %v", seen)
+	}
+
+	boom := fmt.Errorf("boom")
+	err = store.ForEachOperationByAuthor(ctx, "author1", func(op *operations.Operation) error {
+		return boom
+	})
+	if err != boom {
+		t.Errorf("Expected callback error to propagate, got %v", err)
+	}
+}
+
+func TestSQLiteStore_BlobStorage(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	large := strings.Repeat("package main\n", DefaultBlobThreshold/10)
+	old := time.Now().Add(-48 * time.Hour)
+
+	var ids []operations.OperationID
+	for i := 0; i < 2; i++ {
+		op := &operations.Operation{
+			ID:   operations.NewOperationID([]byte(fmt.Sprintf("blob-%d", i))),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(int64(i + 1)), AuthorID: "author1"},
+			}),
+			Content:   large,
+			Author:    "author1",
+			Timestamp: old,
+			Parents:   []operations.OperationID{},
+		}
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+		ids = append(ids, op.ID)
+	}
+
+	var blobCount int
+	if err := store.db.QueryRow("SELECT COUNT(*) FROM blobs").Scan(&blobCount); err != nil {
+		t.Fatalf("Failed to count blobs: %v", err)
+	}
+	if blobCount != 1 {
+		t.Errorf("Expected identical content to share one blob, got %d", blobCount)
+	}
+
+	var inline string
+	if err := store.db.QueryRow("SELECT content FROM operations WHERE id = ?", string(ids[0])).Scan(&inline); err != nil {
+		t.Fatalf("Failed to read raw operation: %v", err)
+	}
+	if inline != "" {
+		t.Error("Expected large content to be stored outside the operations table")
+	}
+
+	retrieved, err := store.GetOperation(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("Failed to retrieve operation: %v", err)
+	}
+	if retrieved.Content != large {
+		t.Error("Expected blob content to be hydrated on read")
+	}
+
+	ops, err := store.GetOperations(ctx, ids)
+	if err != nil || len(ops) != 2 || ops[1].Content != large {
+		t.Errorf("Expected batch read to hydrate blobs, got %d ops, err %v", len(ops), err)
+	}
+
+	if _, err := store.PurgeOperationsBefore(ctx, time.Now().Add(-24*time.Hour), false); err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if err := store.db.QueryRow("SELECT COUNT(*) FROM blobs").Scan(&blobCount); err != nil {
+		t.Fatalf("Failed to count blobs: %v", err)
+	}
+	if blobCount != 0 {
+		t.Errorf("Expected purge to remove orphaned blobs, %d left", blobCount)
 	}
 }
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+// StoreStats sizes a store. Counts use indexes or b-tree counts and sizes
+// come from SQLite's page counts, so gathering them doesn't read row data.
+// Documents counts live documents only. The operation times are nil when
+// there are no operations.
+type StoreStats struct {
+	Operations       int64        `json:"operations"`
+	Documents        int64        `json:"documents"`
+	DeletedDocuments int64        `json:"deleted_documents"`
+	OldestOperation  *time.Time   `json:"oldest_operation,omitempty"`
+	NewestOperation  *time.Time   `json:"newest_operation,omitempty"`
+	DatabaseBytes    int64        `json:"database_bytes"`
+	FreeBytes        int64        `json:"free_bytes"`
+	WALBytes         int64        `json:"wal_bytes"`
+	Indexes          []IndexStats `json:"indexes"`
+}
+
+// IndexStats counts the entries of one of the indexes kept beside operations
+type IndexStats struct {
+	Name    string `json:"name"`
+	Entries int64  `json:"entries"`
+}
+
+// statsIndexes are the tables kept up to date beside operations for search,
+// similarity and churn
+var statsIndexes = []string{
+	"search_operations",
+	"search_documents",
+	"search_conversations",
+	"vectors",
+	"churn_documents",
+	"churn_positions",
+}
+
+func (cs *ContextStore) Stats(ctx context.Context) (*StoreStats, error) {
+	return storeStats(ctx, cs.db)
+}
+
+func (s *SQLiteStore) Stats(ctx context.Context) (*StoreStats, error) {
+	return storeStats(ctx, s.db)
+}
+
+func storeStats(ctx context.Context, db *sql.DB) (*StoreStats, error) {
+	stats := &StoreStats{Indexes: []IndexStats{}}
+
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM operations").Scan(&stats.Operations); err != nil {
+		return nil, fmt.Errorf("failed to count operations: %w", err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) - COUNT(deleted_at), COUNT(deleted_at) FROM documents").Scan(&stats.Documents, &stats.DeletedDocuments); err != nil {
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	// Both ends come straight off the timestamp index
+	var oldest, newest sql.NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT MIN(timestamp), MAX(timestamp) FROM operations").Scan(&oldest, &newest); err != nil {
+		return nil, fmt.Errorf("failed to read operation times: %w", err)
+	}
+	if oldest.Valid {
+		first, last := time.Unix(0, oldest.Int64), time.Unix(0, newest.Int64)
+		stats.OldestOperation, stats.NewestOperation = &first, &last
+	}
+
+	var pages, freePages, pageSize int64
+	if err := db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages); err != nil {
+		return nil, fmt.Errorf("failed to read page count: %w", err)
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&freePages); err != nil {
+		return nil, fmt.Errorf("failed to read free pages: %w", err)
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return nil, fmt.Errorf("failed to read page size: %w", err)
+	}
+	stats.DatabaseBytes = pages * pageSize
+	stats.FreeBytes = freePages * pageSize
+
+	var seq int
+	var name, file string
+	if err := db.QueryRowContext(ctx, "PRAGMA database_list").Scan(&seq, &name, &file); err != nil {
+		return nil, fmt.Errorf("failed to locate database file: %w", err)
+	}
+	if file != "" {
+		if info, err := os.Stat(file + "-wal"); err == nil {
+			stats.WALBytes = info.Size()
+		}
+	}
+
+	for _, table := range statsIndexes {
+		entries := IndexStats{Name: table}
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&entries.Entries); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", table, err)
+		}
+		stats.Indexes = append(stats.Indexes, entries)
+	}
+	return stats, nil
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestSQLiteStore_Stats(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.Operations != 0 || stats.OldestOperation != nil || stats.DatabaseBytes == 0 {
+		t.Errorf("Expected stats for an empty store, got %+v", stats)
+	}
+
+	start := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a1", "a2", "a3"} {
+		op := &operations.Operation{
+			ID:   operations.OperationID(id),
+			Type: operations.OpInsert,
+			Position: operations.NewLogootPosition([]operations.PositionSegment{
+				{Value: big.NewInt(int64(i + 1)), AuthorID: "alice"},
+			}),
+			Content:   "func " + id + "() {}",
+			Author:    "alice",
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+			Parents:   []operations.OperationID{},
+			Metadata:  operations.OperationMeta{Context: map[string]string{"document_id": "main.go"}},
+		}
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+	}
+
+	stats, err = store.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.Operations != 3 || !stats.OldestOperation.Equal(start) || !stats.NewestOperation.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("Expected three operations over two hours, got %+v", stats)
+	}
+	entries := map[string]int64{}
+	for _, index := range stats.Indexes {
+		entries[index.Name] = index.Entries
+	}
+	if entries["search_operations"] != 3 || entries["churn_documents"] != 3 || entries["vectors"] != 0 {
+		t.Errorf("Expected the search and churn indexes to cover the operations, got %+v", stats.Indexes)
+	}
+}
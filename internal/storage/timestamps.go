@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// An operation's timestamp is stored as nanoseconds since the Unix epoch in
+// the timestamp column, which time ranges compare against, and its offset
+// from UTC in seconds in utc_offset, so it reads back as the instant it was
+// made in the zone it was made in. Zone names aren't kept, as they aren't in
+// JSON either. Nanoseconds cover the years 1678 to 2262.
+
+// operationTime rebuilds a timestamp from its columns
+func operationTime(nanos int64, offset int) time.Time {
+	t := time.Unix(0, nanos)
+	if offset == 0 {
+		return t.UTC()
+	}
+	return t.In(time.FixedZone("", offset))
+}
+
+// utcOffset is the offset of t's zone from UTC in seconds
+func utcOffset(t time.Time) int {
+	_, offset := t.Zone()
+	return offset
+}
+
+// migrateTimestamps moves databases created when timestamps were whole Unix
+// seconds to nanoseconds, with existing operations in UTC. The churn
+// triggers bucket timestamps by hour, so they are dropped for migrateChurn
+// to create again for nanoseconds.
+func migrateTimestamps(db *sql.DB) error {
+	exists, err := columnExists(db, "operations", "utc_offset")
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"ALTER TABLE operations ADD COLUMN utc_offset INTEGER NOT NULL DEFAULT 0",
+		"UPDATE operations SET timestamp = timestamp * 1000000000",
+		"DROP TRIGGER IF EXISTS churn_operations_replace",
+		"DROP TRIGGER IF EXISTS churn_operations_insert",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to migrate operation timestamps: %w", err)
+		}
+	}
+	return tx.Commit()
+}
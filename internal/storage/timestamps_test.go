@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+func TestOperationTimestamps(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	// Within the same second, in a zone other than UTC
+	zone := time.FixedZone("", -5*60*60)
+	first := time.Date(2025, 1, 13, 4, 0, 0, 123456789, zone)
+	second := first.Add(time.Millisecond)
+	for _, op := range []*operations.Operation{
+		{ID: "first", Timestamp: first},
+		{ID: "second", Timestamp: second},
+		{ID: "utc", Timestamp: second.Add(time.Second).UTC()},
+	} {
+		op.Type = operations.OpInsert
+		op.Position = operations.NewLogootPosition(nil)
+		op.Author = "alice"
+		op.Parents = []operations.OperationID{}
+		if err := store.StoreOperation(ctx, op); err != nil {
+			t.Fatalf("Failed to store operation: %v", err)
+		}
+	}
+
+	stored, err := store.GetOperation(ctx, "first")
+	if err != nil {
+		t.Fatalf("Failed to get operation: %v", err)
+	}
+	if !stored.Timestamp.Equal(first) || stored.Timestamp.Format(time.RFC3339Nano) != first.Format(time.RFC3339Nano) {
+		t.Errorf("Expected timestamp %s, got %s", first.Format(time.RFC3339Nano), stored.Timestamp.Format(time.RFC3339Nano))
+	}
+	utc, err := store.GetOperation(ctx, "utc")
+	if err != nil {
+		t.Fatalf("Failed to get operation: %v", err)
+	}
+	if utc.Timestamp.Location() != time.UTC {
+		t.Errorf("Expected a UTC timestamp to read back in UTC, got %s", utc.Timestamp.Location())
+	}
+
+	// Ranges bound timestamps to the nanosecond
+	var since []operations.OperationID
+	err = store.ForEachOperationSince(ctx, second, func(op *operations.Operation) error {
+		since = append(since, op.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read operations: %v", err)
+	}
+	if len(since) != 2 || since[0] != "second" || since[1] != "utc" {
+		t.Errorf("Expected second and utc, got %v", since)
+	}
+
+	filter := OperationFilter{Since: first.Add(time.Nanosecond), Until: second.Add(time.Nanosecond)}
+	count, err := store.CountOperationsMatching(ctx, filter)
+	if err != nil {
+		t.Fatalf("Failed to count operations: %v", err)
+	}
+	if count != 1 || filter.Matches(stored) || !filter.Matches(&operations.Operation{Timestamp: second}) {
+		t.Errorf("Expected only second to match, got %d", count)
+	}
+
+	purged, err := store.PurgeOperationsBefore(ctx, second, true)
+	if err != nil {
+		t.Fatalf("Failed to purge operations: %v", err)
+	}
+	if len(purged) != 1 || purged[0] != "first" {
+		t.Errorf("Expected only first to be older than second, got %v", purged)
+	}
+}
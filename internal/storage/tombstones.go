@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+// Deleting a document only marks it with deleted_at. Its constructs and the
+// operations behind them are kept, so it can be restored with its history.
+
+// migrateDocumentTombstones adds documents.deleted_at to databases created
+// before documents could be restored
+func migrateDocumentTombstones(db *sql.DB) error {
+	exists, err := columnExists(db, "documents", "deleted_at")
+	if err != nil || exists {
+		return err
+	}
+	if _, err := db.Exec("ALTER TABLE documents ADD COLUMN deleted_at INTEGER"); err != nil {
+		return fmt.Errorf("failed to add document tombstones: %w", err)
+	}
+	return nil
+}
+
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	return count > 0, err
+}
+
+func (cs *ContextStore) ListDocuments(ctx context.Context, includeDeleted bool) ([]string, error) {
+	return listDocuments(ctx, cs.db, includeDeleted)
+}
+
+func (cs *ContextStore) DeleteDocument(ctx context.Context, filePath string) error {
+	return deleteDocument(ctx, cs.db, filePath)
+}
+
+func (cs *ContextStore) RestoreDocument(ctx context.Context, filePath string) error {
+	doc, err := loadDocument(ctx, cs.db, filePath, true)
+	if err != nil {
+		return err
+	}
+	return restoreDocument(ctx, cs.db, doc)
+}
+
+func (s *SQLiteStore) ListDocuments(ctx context.Context, includeDeleted bool) ([]string, error) {
+	return listDocuments(ctx, s.db, includeDeleted)
+}
+
+func (s *SQLiteStore) DeleteDocument(ctx context.Context, filePath string) error {
+	return deleteDocument(ctx, s.db, filePath)
+}
+
+func (s *SQLiteStore) RestoreDocument(ctx context.Context, filePath string) error {
+	doc, err := loadDocument(ctx, s.db, filePath, true)
+	if err != nil {
+		return err
+	}
+	return restoreDocument(ctx, s.db, doc)
+}
+
+func listDocuments(ctx context.Context, db *sql.DB, includeDeleted bool) ([]string, error) {
+	query := "SELECT file_path FROM documents WHERE deleted_at IS NULL ORDER BY file_path"
+	if includeDeleted {
+		query = "SELECT file_path FROM documents ORDER BY file_path"
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []string
+	for rows.Next() {
+		var filePath string
+		if err := rows.Scan(&filePath); err != nil {
+			return nil, err
+		}
+		documents = append(documents, filePath)
+	}
+
+	return documents, rows.Err()
+}
+
+// deleteDocument marks a document deleted and takes it out of search
+func deleteDocument(ctx context.Context, db *sql.DB, filePath string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE documents SET deleted_at = ? WHERE file_path = ? AND deleted_at IS NULL",
+		time.Now().Unix(), filePath)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		return ErrDocumentNotFound
+	}
+
+	if err := unindexDocumentTx(ctx, tx, filePath); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// restoreDocument clears a deleted document's tombstone and puts it back in search
+func restoreDocument(ctx context.Context, db *sql.DB, doc *positioning.Document) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE documents SET deleted_at = NULL WHERE file_path = ? AND deleted_at IS NOT NULL", doc.FilePath)
+	if err != nil {
+		return err
+	}
+	if restored, err := result.RowsAffected(); err != nil {
+		return err
+	} else if restored == 0 {
+		return ErrDocumentNotDeleted
+	}
+
+	if err := indexDocumentTx(ctx, tx, doc); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
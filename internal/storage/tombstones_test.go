@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+func TestSQLiteStore_DeleteAndRestoreDocument(t *testing.T) {
+	ctx := context.Background()
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	doc := positioning.NewDocument("billing/total.go")
+	doc.InsertConstruct(&positioning.Construct{
+		ID:       "c1",
+		Content:  "func calculateTotal() {}",
+		Type:     positioning.ConstructContent,
+		Position: operations.NewLogootPosition([]operations.PositionSegment{{Value: big.NewInt(1), AuthorID: "alice"}}),
+	})
+	doc.Version = 3
+	if err := store.StoreDocument(ctx, doc); err != nil {
+		t.Fatalf("Failed to store document: %v", err)
+	}
+	if err := store.StoreDocument(ctx, positioning.NewDocument("main.go")); err != nil {
+		t.Fatalf("Failed to store document: %v", err)
+	}
+
+	if err := store.DeleteDocument(ctx, doc.FilePath); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+	if err := store.DeleteDocument(ctx, doc.FilePath); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound deleting twice, got %v", err)
+	}
+
+	_, err := store.GetDocument(ctx, doc.FilePath)
+	if !errors.Is(err, ErrDocumentDeleted) || !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentDeleted, got %v", err)
+	}
+	if hits, _ := store.Search(ctx, SearchQuery{Text: "calculatetotal", Kind: SearchCode}); len(hits) != 0 {
+		t.Errorf("Expected deleted documents to leave the index, got %+v", hits)
+	}
+
+	live, err := store.ListDocuments(ctx, false)
+	if err != nil {
+		t.Fatalf("Failed to list documents: %v", err)
+	}
+	if len(live) != 1 || live[0] != "main.go" {
+		t.Errorf("Expected only main.go, got %v", live)
+	}
+	all, err := store.ListDocuments(ctx, true)
+	if err != nil {
+		t.Fatalf("Failed to list documents: %v", err)
+	}
+	if len(all) != 2 || all[0] != "billing/total.go" {
+		t.Errorf("Expected both documents, got %v", all)
+	}
+
+	stats, err := store.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.Documents != 1 || stats.DeletedDocuments != 1 {
+		t.Errorf("Expected one live and one deleted document, got %d and %d", stats.Documents, stats.DeletedDocuments)
+	}
+
+	if err := store.RestoreDocument(ctx, doc.FilePath); err != nil {
+		t.Fatalf("Failed to restore document: %v", err)
+	}
+	if err := store.RestoreDocument(ctx, doc.FilePath); !errors.Is(err, ErrDocumentNotDeleted) {
+		t.Errorf("Expected ErrDocumentNotDeleted restoring twice, got %v", err)
+	}
+	if err := store.RestoreDocument(ctx, "missing.go"); !errors.Is(err, ErrDocumentNotFound) {
+		t.Errorf("Expected ErrDocumentNotFound restoring a missing document, got %v", err)
+	}
+
+	restored, err := store.GetDocument(ctx, doc.FilePath)
+	if err != nil {
+		t.Fatalf("Failed to get restored document: %v", err)
+	}
+	if restored.Version != 3 || restored.ConstructCount() != 1 {
+		t.Errorf("Expected the document back with its constructs, got version %d with %d constructs", restored.Version, restored.ConstructCount())
+	}
+	if hits, _ := store.Search(ctx, SearchQuery{Text: "calculatetotal", Kind: SearchCode}); len(hits) != 1 {
+		t.Errorf("Expected the restored document back in the index, got %+v", hits)
+	}
+}
@@ -0,0 +1,314 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// Vectors are embeddings of operation content and conversation messages.
+// They are keyed by the model that produced them, since vectors from
+// different models can't be compared.
+const vectorsSchema = `
+CREATE TABLE IF NOT EXISTS vectors (
+	kind TEXT NOT NULL,
+	ref TEXT NOT NULL,
+	model TEXT NOT NULL,
+	parent TEXT NOT NULL DEFAULT '',
+	content_hash TEXT NOT NULL,
+	dimensions INTEGER NOT NULL,
+	vector BLOB NOT NULL,
+	created_at INTEGER NOT NULL,
+	PRIMARY KEY (kind, ref, model)
+);
+CREATE INDEX IF NOT EXISTS idx_vectors_model ON vectors(model, kind);
+
+CREATE TRIGGER IF NOT EXISTS vectors_operation_delete AFTER DELETE ON operations BEGIN
+	DELETE FROM vectors WHERE kind = 'operation' AND ref = OLD.id;
+END;
+`
+
+type VectorKind string
+
+const (
+	VectorOperation VectorKind = "operation"
+	VectorMessage   VectorKind = "message"
+)
+
+// Vector is the embedding of one operation or message. Parent is the thread
+// of a message. ContentHash identifies the text that was embedded, so a
+// changed message can be embedded again.
+type Vector struct {
+	Kind        VectorKind
+	Ref         string
+	Model       string
+	Parent      string
+	ContentHash string
+	Values      []float32
+	CreatedAt   time.Time
+}
+
+// VectorQuery finds the vectors of Model closest to Values. Exclude leaves out
+// one ref, usually the vector the query came from.
+type VectorQuery struct {
+	Model   string
+	Values  []float32
+	Kinds   []VectorKind
+	Exclude string
+	Limit   int
+}
+
+// VectorMatch is a vector found by a query, with its cosine similarity to it
+type VectorMatch struct {
+	Kind       VectorKind `json:"kind"`
+	Ref        string     `json:"ref"`
+	Parent     string     `json:"parent,omitempty"`
+	Similarity float64    `json:"similarity"`
+}
+
+func migrateVectors(db *sql.DB) error {
+	if _, err := db.Exec(vectorsSchema); err != nil {
+		return fmt.Errorf("failed to create vectors table: %w", err)
+	}
+	return nil
+}
+
+func (cs *ContextStore) StoreVectors(ctx context.Context, vectors []Vector) error {
+	return storeVectors(ctx, cs.db, vectors)
+}
+
+func (cs *ContextStore) GetVector(ctx context.Context, kind VectorKind, ref, model string) (*Vector, error) {
+	return getVector(ctx, cs.db, kind, ref, model)
+}
+
+func (cs *ContextStore) SimilarVectors(ctx context.Context, query VectorQuery) ([]VectorMatch, error) {
+	return similarVectors(ctx, cs.db, query)
+}
+
+func (cs *ContextStore) VectorHashes(ctx context.Context, kind VectorKind, model string) (map[string]string, error) {
+	return vectorHashes(ctx, cs.db, kind, model)
+}
+
+func (cs *ContextStore) DeleteVectors(ctx context.Context, kind VectorKind, model string, refs []string) error {
+	return deleteVectors(ctx, cs.db, kind, model, refs)
+}
+
+func (cs *ContextStore) OperationsWithoutVectors(ctx context.Context, model string, limit int) ([]*operations.Operation, error) {
+	rows, err := cs.db.QueryContext(ctx, operationsWithoutVectorsQuery, model, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectOperations(func(fn OperationFunc) error {
+		return forEachOperationRow(rows, cs.scanOperation, fn)
+	})
+}
+
+func (s *SQLiteStore) StoreVectors(ctx context.Context, vectors []Vector) error {
+	return storeVectors(ctx, s.db, vectors)
+}
+
+func (s *SQLiteStore) GetVector(ctx context.Context, kind VectorKind, ref, model string) (*Vector, error) {
+	return getVector(ctx, s.db, kind, ref, model)
+}
+
+func (s *SQLiteStore) SimilarVectors(ctx context.Context, query VectorQuery) ([]VectorMatch, error) {
+	return similarVectors(ctx, s.db, query)
+}
+
+func (s *SQLiteStore) VectorHashes(ctx context.Context, kind VectorKind, model string) (map[string]string, error) {
+	return vectorHashes(ctx, s.db, kind, model)
+}
+
+func (s *SQLiteStore) DeleteVectors(ctx context.Context, kind VectorKind, model string, refs []string) error {
+	return deleteVectors(ctx, s.db, kind, model, refs)
+}
+
+func (s *SQLiteStore) OperationsWithoutVectors(ctx context.Context, model string, limit int) ([]*operations.Operation, error) {
+	rows, err := s.db.QueryContext(ctx, operationsWithoutVectorsQuery, model, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectOperations(func(fn OperationFunc) error {
+		return forEachOperationRow(rows, s.scanOperation, fn)
+	})
+}
+
+// operationsWithoutVectorsQuery finds the oldest operations with text content
+// and no vector from a model. Binary content isn't embedded.
+const operationsWithoutVectorsQuery = selectOperationColumns + `
+	WHERE COALESCE(content_type, 'text') != 'binary'
+	AND (content != '' OR blob_hash IS NOT NULL)
+	AND NOT EXISTS (SELECT 1 FROM vectors v WHERE v.kind = 'operation' AND v.ref = operations.id AND v.model = ?)
+	ORDER BY clock, id LIMIT ?`
+
+func storeVectors(ctx context.Context, db *sql.DB, vectors []Vector) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT OR REPLACE INTO vectors (kind, ref, model, parent, content_hash, dimensions, vector, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	for _, v := range vectors {
+		createdAt := v.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+		_, err := tx.ExecContext(ctx, query, string(v.Kind), v.Ref, v.Model, v.Parent, v.ContentHash,
+			len(v.Values), encodeVector(v.Values), createdAt.Unix())
+		if err != nil {
+			return fmt.Errorf("failed to store vector: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func getVector(ctx context.Context, db *sql.DB, kind VectorKind, ref, model string) (*Vector, error) {
+	v := Vector{Kind: kind, Ref: ref, Model: model}
+	var encoded []byte
+	var createdAt int64
+	err := db.QueryRowContext(ctx, "SELECT parent, content_hash, vector, created_at FROM vectors WHERE kind = ? AND ref = ? AND model = ?",
+		string(kind), ref, model).Scan(&v.Parent, &v.ContentHash, &encoded, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVectorNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	v.Values = decodeVector(encoded)
+	v.CreatedAt = time.Unix(createdAt, 0)
+	return &v, nil
+}
+
+// similarVectors compares the query with every vector of its model. Stores
+// hold at most a few hundred thousand vectors, which a scan handles well
+// enough without an approximate index.
+func similarVectors(ctx context.Context, db *sql.DB, query VectorQuery) ([]VectorMatch, error) {
+	queryNorm := norm(query.Values)
+	if queryNorm == 0 {
+		return []VectorMatch{}, nil
+	}
+
+	sqlQuery := "SELECT kind, ref, parent, vector FROM vectors WHERE model = ? AND ref != ?"
+	args := []interface{}{query.Model, query.Exclude}
+	if len(query.Kinds) > 0 {
+		sqlQuery += " AND kind IN (?" + strings.Repeat(", ?", len(query.Kinds)-1) + ")"
+		for _, kind := range query.Kinds {
+			args = append(args, string(kind))
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []VectorMatch{}
+	for rows.Next() {
+		var match VectorMatch
+		var encoded []byte
+		if err := rows.Scan(&match.Kind, &match.Ref, &match.Parent, &encoded); err != nil {
+			return nil, err
+		}
+
+		values := decodeVector(encoded)
+		if len(values) != len(query.Values) {
+			continue
+		}
+		valuesNorm := norm(values)
+		if valuesNorm == 0 {
+			continue
+		}
+
+		dot := 0.0
+		for i := range values {
+			dot += float64(values[i]) * float64(query.Values[i])
+		}
+		match.Similarity = dot / (queryNorm * valuesNorm)
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].Ref < matches[j].Ref
+	})
+	if query.Limit > 0 && len(matches) > query.Limit {
+		matches = matches[:query.Limit]
+	}
+	return matches, nil
+}
+
+// vectorHashes maps the refs with vectors of kind from model to the hash of the content embedded
+func vectorHashes(ctx context.Context, db *sql.DB, kind VectorKind, model string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT ref, content_hash FROM vectors WHERE kind = ? AND model = ?", string(kind), model)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]string)
+	for rows.Next() {
+		var ref, hash string
+		if err := rows.Scan(&ref, &hash); err != nil {
+			return nil, err
+		}
+		hashes[ref] = hash
+	}
+	return hashes, rows.Err()
+}
+
+func deleteVectors(ctx context.Context, db *sql.DB, kind VectorKind, model string, refs []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, ref := range refs {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM vectors WHERE kind = ? AND ref = ? AND model = ?", string(kind), ref, model); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Vectors are stored as little endian float32s
+func encodeVector(values []float32) []byte {
+	encoded := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(encoded[4*i:], math.Float32bits(value))
+	}
+	return encoded
+}
+
+func decodeVector(encoded []byte) []float32 {
+	values := make([]float32, len(encoded)/4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(encoded[4*i:]))
+	}
+	return values
+}
+
+func norm(values []float32) float64 {
+	sum := 0.0
+	for _, value := range values {
+		sum += float64(value) * float64(value)
+	}
+	return math.Sqrt(sum)
+}
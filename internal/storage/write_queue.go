@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type pendingWrite struct {
+	args []interface{}
+	blob *blob
+	done chan error
+}
+
+// writeQueue group-commits operation inserts: concurrent StoreOperation calls
+// are collected into one transaction, and each caller blocks until the batch
+// holding its write has committed, so reads after a successful store still
+// see the operation.
+type writeQueue struct {
+	store         *SQLiteStore
+	pending       chan pendingWrite
+	batchSize     int
+	flushInterval time.Duration
+	stopped       chan struct{}
+	closed        bool
+	mutex         sync.RWMutex
+}
+
+func newWriteQueue(store *SQLiteStore, batchSize int, flushInterval time.Duration) *writeQueue {
+	if batchSize <= 0 {
+		batchSize = DefaultSQLiteOptions().BatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultSQLiteOptions().FlushInterval
+	}
+
+	wq := &writeQueue{
+		store:         store,
+		pending:       make(chan pendingWrite, batchSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		stopped:       make(chan struct{}),
+	}
+	go wq.run()
+	return wq
+}
+
+// enqueue hands the write to the batcher and waits for its outcome. If ctx
+// ends first the write may still be committed later.
+func (wq *writeQueue) enqueue(ctx context.Context, args []interface{}, b *blob) error {
+	write := pendingWrite{args: args, blob: b, done: make(chan error, 1)}
+
+	wq.mutex.RLock()
+	if wq.closed {
+		wq.mutex.RUnlock()
+		return ErrStoreClosed
+	}
+	select {
+	case wq.pending <- write:
+		wq.mutex.RUnlock()
+	case <-ctx.Done():
+		wq.mutex.RUnlock()
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-write.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (wq *writeQueue) run() {
+	defer close(wq.stopped)
+
+	for first := range wq.pending {
+		batch := []pendingWrite{first}
+		timer := time.NewTimer(wq.flushInterval)
+
+	collect:
+		for len(batch) < wq.batchSize {
+			select {
+			case write, ok := <-wq.pending:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, write)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		wq.commit(batch)
+	}
+}
+
+func (wq *writeQueue) commit(batch []pendingWrite) {
+	ctx := context.Background()
+	errs := make([]error, len(batch))
+
+	err := func() error {
+		stmt, err := wq.store.prepare(ctx, insertOperationQuery)
+		if err != nil {
+			return err
+		}
+
+		tx, err := wq.store.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		txStmt := tx.StmtContext(ctx, stmt)
+		for i, write := range batch {
+			// A failed insert only fails that statement, the rest of the batch still commits
+			errs[i] = storeOperationTx(ctx, tx, txStmt, write.args, write.blob)
+		}
+
+		return tx.Commit()
+	}()
+
+	for i, write := range batch {
+		if err != nil {
+			write.done <- err
+		} else {
+			write.done <- errs[i]
+		}
+	}
+}
+
+// close stops accepting writes and waits for everything already queued to commit
+func (wq *writeQueue) close() {
+	wq.mutex.Lock()
+	if wq.closed {
+		wq.mutex.Unlock()
+		return
+	}
+	wq.closed = true
+	close(wq.pending)
+	wq.mutex.Unlock()
+
+	<-wq.stopped
+}
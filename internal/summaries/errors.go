@@ -0,0 +1,8 @@
+package summaries
+
+import "errors"
+
+var (
+	ErrSummarizerFailed   = errors.New("summarizer failed")
+	ErrNothingToSummarize = errors.New("nothing to summarize")
+)
@@ -0,0 +1,195 @@
+package summaries
+
+import (
+	gocontext "context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+)
+
+// DefaultSentences is how many sentences ExtractiveSummarizer picks when not configured
+const DefaultSentences = 3
+
+// messageWeights favor the sentences of messages where a thread's outcome
+// usually is. Other messages weigh 1.
+var messageWeights = map[context.MessageType]float64{
+	context.MsgDecision:       2,
+	context.MsgAnswer:         1.5,
+	context.MsgSuggestion:     1.2,
+	context.MsgRequestChanges: 1.2,
+}
+
+// stopWords carry no meaning of their own and don't count towards a sentence's score
+var stopWords = map[string]bool{
+	"about": true, "after": true, "all": true, "also": true, "and": true, "any": true, "are": true, "but": true,
+	"can": true, "could": true, "did": true, "does": true, "for": true, "from": true, "had": true, "has": true,
+	"have": true, "how": true, "its": true, "just": true, "like": true, "more": true, "not": true, "now": true,
+	"one": true, "only": true, "our": true, "out": true, "should": true, "some": true, "than": true, "that": true,
+	"the": true, "their": true, "them": true, "then": true, "there": true, "these": true, "they": true, "this": true,
+	"was": true, "were": true, "what": true, "when": true, "which": true, "who": true, "why": true, "will": true,
+	"with": true, "would": true, "yes": true, "you": true, "your": true,
+}
+
+// ExtractiveSummarizer sums up a thread locally by picking its most telling
+// sentences as they were written. A sentence scores by how often the thread
+// repeats its words, more so words of the title, and weighs more in
+// decisions and answers and less once superseded. It needs no model or
+// network, but it can only quote, not paraphrase.
+type ExtractiveSummarizer struct {
+	sentences int
+}
+
+func NewExtractiveSummarizer(sentences int) *ExtractiveSummarizer {
+	if sentences <= 0 {
+		sentences = DefaultSentences
+	}
+	return &ExtractiveSummarizer{sentences: sentences}
+}
+
+func (e *ExtractiveSummarizer) Name() string {
+	return fmt.Sprintf("extractive-%d", e.sentences)
+}
+
+type sentence struct {
+	text   string
+	words  []string
+	weight float64
+	score  float64
+}
+
+func (e *ExtractiveSummarizer) Summarize(ctx gocontext.Context, thread *context.ConversationThread) (string, error) {
+	var candidates []sentence
+	frequency := make(map[string]float64)
+	for i, message := range Transcript(thread) {
+		weight, ok := messageWeights[message.MessageType]
+		if !ok {
+			weight = 1
+		}
+		if message.SupersededBy != "" {
+			weight /= 2
+		}
+
+		for j, text := range splitSentences(message.Content) {
+			words := contentWords(text)
+			if len(words) == 0 {
+				continue
+			}
+			for _, word := range words {
+				frequency[word]++
+			}
+			candidate := sentence{text: text, words: words, weight: weight}
+			// The opening sentence usually says what the thread is about
+			if i == 0 && j == 0 {
+				candidate.weight *= 1.5
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("%w: thread %s has no sentences", ErrNothingToSummarize, thread.ID)
+	}
+	for _, word := range contentWords(thread.Title) {
+		frequency[word] += 2
+	}
+
+	for i := range candidates {
+		total := 0.0
+		for _, word := range candidates[i].words {
+			total += frequency[word]
+		}
+		// Dividing by the square root rather than the count keeps long
+		// sentences from winning on length alone without sinking them
+		candidates[i].score = candidates[i].weight * total / math.Sqrt(float64(len(candidates[i].words)))
+	}
+
+	ranked := make([]int, len(candidates))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return candidates[ranked[a]].score > candidates[ranked[b]].score
+	})
+	picked := ranked[:min(e.sentences, len(ranked))]
+	sort.Ints(picked)
+
+	texts := make([]string, len(picked))
+	for i, index := range picked {
+		texts[i] = candidates[index].text
+	}
+	return strings.Join(texts, " "), nil
+}
+
+// splitSentences breaks message content into sentences. Lines run together
+// into paragraphs, broken by blank lines and list items, and code blocks are
+// left out. Each sentence ends in punctuation.
+func splitSentences(content string) []string {
+	var sentences, paragraph []string
+	add := func(text string) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return
+		}
+		if !strings.ContainsAny(text[len(text)-1:], ".!?") {
+			text += "."
+		}
+		sentences = append(sentences, text)
+	}
+	flush := func() {
+		text := strings.Join(paragraph, " ")
+		paragraph = paragraph[:0]
+		start := 0
+		for i := 0; i < len(text); i++ {
+			if strings.IndexByte(".!?", text[i]) >= 0 && (i+1 == len(text) || text[i+1] == ' ') {
+				add(text[start : i+1])
+				start = i + 1
+			}
+		}
+		add(text[start:])
+	}
+
+	inCode := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "```") {
+			flush()
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		if line == "" {
+			flush()
+			continue
+		}
+		// A list item or heading marker is followed by a space, unlike emphasis
+		if item := strings.TrimLeft(line, "-*+># "); strings.Contains(line[:len(line)-len(item)], " ") {
+			flush()
+			line = item
+		}
+		paragraph = append(paragraph, line)
+	}
+	flush()
+	return sentences
+}
+
+// contentWords are the distinct lower case words of text that mean something
+// on their own
+func contentWords(text string) []string {
+	var words []string
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) < 3 || stopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	return words
+}
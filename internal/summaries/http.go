@@ -0,0 +1,177 @@
+package summaries
+
+import (
+	"bytes"
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+)
+
+// DefaultPrompt is what HTTPSummarizer asks of the model when not configured
+const DefaultPrompt = "Summarize this discussion between software developers in at most three sentences. " +
+	"Say what was asked or proposed, what was decided and any open questions. " +
+	"Answer with the summary alone."
+
+// MaxTranscriptLength caps the bytes of thread sent to the model. Longer
+// threads keep their opening message and as many of the latest as fit.
+const MaxTranscriptLength = 32 << 10
+
+// responseOverhead allows for what a chat completion carries besides its
+// summary: ids, roles, usage counts and the like.
+const responseOverhead = 16 << 10
+
+// HTTPSummarizer asks a language model to summarize threads through a chat
+// completions endpoint that speaks the widely used OpenAI request format: a
+// POST of {"model", "messages"} answered with {"choices": [{"message":
+// {"content"}}]}. Most hosted and self-hosted model servers accept it.
+type HTTPSummarizer struct {
+	url    string
+	model  string
+	apiKey string
+	prompt string
+	http   *http.Client
+
+	// name and the most bytes of response read, set once at construction
+	name        string
+	maxResponse int64
+}
+
+// NewHTTPSummarizer calls url with model. An empty prompt asks with
+// DefaultPrompt. Responses are read up to a little over twice maxLength, the
+// longest summary kept, or DefaultMaxLength when it is not positive.
+func NewHTTPSummarizer(endpoint, model, apiKey, prompt string, maxLength int) *HTTPSummarizer {
+	if prompt == "" {
+		prompt = DefaultPrompt
+	}
+	if maxLength <= 0 {
+		maxLength = DefaultMaxLength
+	}
+	return &HTTPSummarizer{
+		url:         endpoint,
+		model:       model,
+		apiKey:      apiKey,
+		prompt:      prompt,
+		http:        &http.Client{Timeout: 2 * time.Minute},
+		name:        httpSummarizerName(endpoint, model, prompt),
+		maxResponse: int64(2*maxLength + responseOverhead),
+	}
+}
+
+// Name is the model, the host it is served from and a hash of the prompt, as
+// in small-model@models.example.com#1a2b3c4d, so summaries made with another
+// prompt or another deployment of the same model can be told apart.
+func (s *HTTPSummarizer) Name() string {
+	return s.name
+}
+
+func httpSummarizerName(endpoint, model, prompt string) string {
+	host := endpoint
+	if parsed, err := url.Parse(endpoint); err == nil && parsed.Host != "" {
+		host = parsed.Host
+	}
+	sum := sha256.Sum256([]byte(prompt))
+	return fmt.Sprintf("%s@%s#%s", model, host, hex.EncodeToString(sum[:4]))
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+func (s *HTTPSummarizer) Summarize(ctx gocontext.Context, thread *context.ConversationThread) (string, error) {
+	body, err := json.Marshal(chatRequest{
+		Model: s.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: s.prompt},
+			{Role: "user", Content: transcriptText(thread)},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("%s returned %s: %s", s.url, resp.Status, bytes.TrimSpace(detail))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, s.maxResponse+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read summary: %w", err)
+	}
+	if int64(len(data)) > s.maxResponse {
+		return "", fmt.Errorf("%s returned more than %d bytes", s.url, s.maxResponse)
+	}
+
+	var decoded chatResponse
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return "", fmt.Errorf("failed to decode summary: %w", err)
+	}
+	if len(decoded.Choices) == 0 {
+		return "", fmt.Errorf("no summary returned")
+	}
+	return decoded.Choices[0].Message.Content, nil
+}
+
+// transcriptText writes a thread out for a model to read, one message after
+// another under its title. Messages after the first are dropped, oldest
+// first, until it fits in MaxTranscriptLength, and cut short if it still doesn't.
+func transcriptText(thread *context.ConversationThread) string {
+	messages := Transcript(thread)
+	entries := make([]string, len(messages))
+	for i, message := range messages {
+		entries[i] = fmt.Sprintf("%s (%s): %s", message.AuthorID, message.MessageType, strings.TrimSpace(message.Content))
+	}
+
+	header := "Title: " + thread.Title
+	length := len(header)
+	for _, entry := range entries {
+		length += len(entry) + 2
+	}
+	dropped := 0
+	for len(entries) > 1 && length > MaxTranscriptLength {
+		length -= len(entries[1]) + 2
+		entries = append(entries[:1], entries[2:]...)
+		dropped++
+	}
+	if dropped > 0 {
+		entries = slices.Insert(entries, 1, fmt.Sprintf("[%d messages left out]", dropped))
+	}
+	return truncate(header+"\n\n"+strings.Join(entries, "\n\n"), MaxTranscriptLength)
+}
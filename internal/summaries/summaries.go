@@ -0,0 +1,193 @@
+// Package summaries sums up conversation threads with a pluggable summarizer
+// and keeps the summary on the thread, where it is shown with the thread and
+// searched along with its messages. Threads are summarized as they are
+// resolved, or whenever someone asks. Summarizing is optional and off unless
+// a summarizer is configured.
+package summaries
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/events"
+	"github.com/jeremytregunna/contextdb/internal/logging"
+)
+
+// DefaultMaxLength caps the bytes of a summary when not configured
+const DefaultMaxLength = 600
+
+// Summarizer sums up a thread in a few sentences. Name identifies the
+// summarizer and its settings, and is recorded with each summary it makes.
+type Summarizer interface {
+	Name() string
+	Summarize(ctx gocontext.Context, thread *context.ConversationThread) (string, error)
+}
+
+// Service summarizes threads with its summarizer and stores the summaries on
+// them. Threads queued with Enqueue are summarized in the background by Run.
+type Service struct {
+	summarizer    Summarizer
+	conversations *context.ConversationManager
+	maxLength     int
+	wake          chan struct{}
+	logger        *logging.Logger
+	// mutex guards pending, the threads waiting for Run in the order queued
+	mutex   sync.Mutex
+	pending []context.ThreadID
+}
+
+type Option func(*Service)
+
+// WithMaxLength cuts summaries down to at most n bytes
+func WithMaxLength(n int) Option {
+	return func(s *Service) {
+		if n > 0 {
+			s.maxLength = n
+		}
+	}
+}
+
+func NewService(summarizer Summarizer, conversations *context.ConversationManager, opts ...Option) *Service {
+	s := &Service{
+		summarizer:    summarizer,
+		conversations: conversations,
+		maxLength:     DefaultMaxLength,
+		wake:          make(chan struct{}, 1),
+		logger:        logging.NewLogger("summaries"),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Service) Name() string {
+	return s.summarizer.Name()
+}
+
+// Subscribe queues every thread resolved on bus for summarizing and returns a
+// function that stops it
+func (s *Service) Subscribe(bus *events.Bus) func() {
+	return bus.Subscribe(func(event events.Event) {
+		if event.Type != events.ConversationResolved {
+			return
+		}
+		if thread, ok := event.Data.(*context.ConversationThread); ok {
+			s.Enqueue(thread.ID)
+		}
+	})
+}
+
+// Enqueue asks a running service to summarize a thread. It never blocks, and
+// a thread already waiting is summarized once.
+func (s *Service) Enqueue(threadID context.ThreadID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, pending := range s.pending {
+		if pending == threadID {
+			return
+		}
+	}
+	s.pending = append(s.pending, threadID)
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run summarizes queued threads until ctx is cancelled. A thread that fails
+// to summarize is logged and left without a new summary.
+func (s *Service) Run(ctx gocontext.Context) {
+	for {
+		for _, threadID := range s.take() {
+			if _, err := s.Summarize(ctx, threadID); err != nil && ctx.Err() == nil {
+				s.logger.Error("Summarizing failed", map[string]interface{}{"thread_id": string(threadID), "error": err.Error()})
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		}
+	}
+}
+
+func (s *Service) take() []context.ThreadID {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pending := s.pending
+	s.pending = nil
+	return pending
+}
+
+// Summarize sums up a thread as it stands now, replacing any summary it had,
+// and returns the updated thread
+func (s *Service) Summarize(ctx gocontext.Context, threadID context.ThreadID) (*context.ConversationThread, error) {
+	thread, err := s.conversations.GetConversation(threadID)
+	if err != nil {
+		return nil, err
+	}
+	if len(Transcript(thread)) == 0 {
+		return nil, fmt.Errorf("%w: thread %s has no messages with content", ErrNothingToSummarize, threadID)
+	}
+
+	text, err := s.summarizer.Summarize(ctx, thread)
+	if errors.Is(err, ErrNothingToSummarize) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSummarizerFailed, err)
+	}
+	text = truncate(strings.TrimSpace(text), s.maxLength)
+	if text == "" {
+		return nil, fmt.Errorf("%w: empty summary of thread %s", ErrSummarizerFailed, threadID)
+	}
+
+	return s.conversations.SetSummary(threadID, context.ThreadSummary{
+		Text:       text,
+		Summarizer: s.Name(),
+		Messages:   len(thread.Messages),
+		CreatedAt:  time.Now(),
+	})
+}
+
+// Transcript is the messages of a thread worth summarizing: those with
+// content, leaving out the note ResolveConversation adds
+func Transcript(thread *context.ConversationThread) []context.Message {
+	var messages []context.Message
+	for _, message := range thread.Messages {
+		content := strings.TrimSpace(message.Content)
+		if content == "" || (message.MessageType == context.MsgDecision && content == context.ResolutionMessage) {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// truncate cuts text to at most n bytes at a word boundary where there is
+// one, without splitting a UTF-8 sequence, marking the cut with an ellipsis
+func truncate(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	const ellipsis = "…"
+	end := max(n-len(ellipsis), 0)
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	if space := strings.LastIndexAny(text[:end], " \n\t"); space > end/2 {
+		end = space
+	}
+	return strings.TrimSpace(text[:end]) + ellipsis
+}
@@ -0,0 +1,232 @@
+package summaries
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/events"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// retryThread is a resolved discussion whose outcome is its decision
+func retryThread(t *testing.T, cm *context.ConversationManager) *context.ConversationThread {
+	thread, err := cm.CreateConversation(addressing.StableAddress{}, "alice", "Retry policy for uploads",
+		"Uploads fail when the storage node restarts. Should uploads retry on their own?")
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	messages := []struct {
+		author  string
+		content string
+		kind    context.MessageType
+	}{
+		{"bob", "Thanks for raising it!", context.MsgComment},
+		{"carol", "Retrying uploads is safe because the upload IDs make them idempotent.", context.MsgAnswer},
+		{"bob", "Something like this:\n\n```go\nfor i := 0; i < 3; i++ { upload() }\n```", context.MsgSuggestion},
+		{"alice", "We will retry uploads three times with exponential backoff.", context.MsgDecision},
+		{"carol", "+1", context.MsgComment},
+	}
+	for _, m := range messages {
+		if _, err := cm.AddMessage(thread.ID, operations.AuthorID(m.author), m.content, m.kind); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	if err := cm.ResolveConversation(thread.ID, "alice"); err != nil {
+		t.Fatalf("Failed to resolve conversation: %v", err)
+	}
+	thread, err = cm.GetConversation(thread.ID)
+	if err != nil {
+		t.Fatalf("Failed to get conversation: %v", err)
+	}
+	return thread
+}
+
+func TestSplitSentences(t *testing.T) {
+	got := splitSentences("First point. Second one\nwraps here!\n\n- a list item\n**bold** start\n```\ncode. here\n```\nDone")
+	expected := []string{"First point.", "Second one wraps here!", "a list item **bold** start.", "Done."}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestExtractiveSummarizer(t *testing.T) {
+	thread := retryThread(t, context.NewConversationManager())
+
+	summary, err := NewExtractiveSummarizer(2).Summarize(gocontext.Background(), thread)
+	if err != nil {
+		t.Fatalf("Failed to summarize: %v", err)
+	}
+	expected := "Retrying uploads is safe because the upload IDs make them idempotent. We will retry uploads three times with exponential backoff."
+	if summary != expected {
+		t.Errorf("Expected the answer and decision in order, got %q", summary)
+	}
+
+	empty := &context.ConversationThread{ID: "empty", Messages: []context.Message{{Content: "```\ncode\n```"}}}
+	if _, err := NewExtractiveSummarizer(0).Summarize(gocontext.Background(), empty); !errors.Is(err, ErrNothingToSummarize) {
+		t.Errorf("Expected a thread without sentences to have nothing to summarize, got %v", err)
+	}
+}
+
+type failingSummarizer struct{}
+
+func (failingSummarizer) Name() string { return "failing" }
+
+func (failingSummarizer) Summarize(gocontext.Context, *context.ConversationThread) (string, error) {
+	return "", errors.New("model unavailable")
+}
+
+func TestService(t *testing.T) {
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+	cm := context.NewConversationManager()
+	bus := events.NewBus()
+	cm.SetEventBus(bus)
+
+	service := NewService(NewExtractiveSummarizer(1), cm, WithMaxLength(40))
+	defer service.Subscribe(bus)()
+	go service.Run(ctx)
+
+	// Resolving a thread summarizes it in the background
+	thread := retryThread(t, cm)
+	deadline := time.Now().Add(5 * time.Second)
+	for thread.Summary == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		thread, _ = cm.GetConversation(thread.ID)
+	}
+	summary := thread.Summary
+	if summary == nil {
+		t.Fatal("Expected the resolved thread summarized")
+	}
+	if summary.Text != "We will retry uploads three times…" || summary.Summarizer != "extractive-1" {
+		t.Errorf("Expected the decision cut down to 40 bytes, got %+v", summary)
+	}
+	if summary.Messages != len(thread.Messages) || thread.SummaryStale() {
+		t.Errorf("Expected the summary to cover all %d messages, got %d", len(thread.Messages), summary.Messages)
+	}
+
+	if _, err := cm.AddMessage(thread.ID, "dave", "Reopening: backoff should be capped.", context.MsgComment); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	if thread, _ = cm.GetConversation(thread.ID); !thread.SummaryStale() {
+		t.Error("Expected a summary older than the latest message to be stale")
+	}
+
+	// Summarizing on demand replaces the summary
+	updated, err := service.Summarize(ctx, thread.ID)
+	if err != nil {
+		t.Fatalf("Failed to summarize: %v", err)
+	}
+	if updated.SummaryStale() || !updated.Summary.CreatedAt.After(summary.CreatedAt) {
+		t.Errorf("Expected a new summary covering every message, got %+v", updated.Summary)
+	}
+
+	if _, err := service.Summarize(ctx, "missing"); !errors.Is(err, context.ErrConversationNotFound) {
+		t.Errorf("Expected ErrConversationNotFound, got %v", err)
+	}
+	failing := NewService(failingSummarizer{}, cm)
+	if _, err := failing.Summarize(ctx, thread.ID); !errors.Is(err, ErrSummarizerFailed) {
+		t.Errorf("Expected ErrSummarizerFailed, got %v", err)
+	}
+	if thread, _ := cm.GetConversation(thread.ID); thread.Summary.Summarizer != "extractive-1" {
+		t.Errorf("Expected a failed summary to leave the last one, got %+v", thread.Summary)
+	}
+}
+
+func TestHTTPSummarizer(t *testing.T) {
+	var received chatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": " Uploads retry three times. "}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	thread := retryThread(t, context.NewConversationManager())
+	summary, err := NewHTTPSummarizer(server.URL, "small-model", "secret", "", 0).Summarize(gocontext.Background(), thread)
+	if err != nil {
+		t.Fatalf("Failed to summarize: %v", err)
+	}
+	if summary != " Uploads retry three times. " {
+		t.Errorf("Expected the model's answer, got %q", summary)
+	}
+	if received.Model != "small-model" || len(received.Messages) != 2 || received.Messages[0].Content != DefaultPrompt {
+		t.Fatalf("Expected the default prompt sent to small-model, got %+v", received)
+	}
+	transcript := received.Messages[1].Content
+	if !strings.HasPrefix(transcript, "Title: Retry policy for uploads\n\n") || strings.Contains(transcript, context.ResolutionMessage) {
+		t.Errorf("Expected the titled transcript without the resolution note, got %q", transcript)
+	}
+
+	if _, err := NewHTTPSummarizer(server.URL, "small-model", "wrong", "", 0).Summarize(gocontext.Background(), thread); err == nil {
+		t.Error("Expected a refused request to fail")
+	}
+}
+
+func TestHTTPSummarizer_Name(t *testing.T) {
+	name := NewHTTPSummarizer("https://models.example.com/v1/chat/completions", "small-model", "", "", 0).Name()
+	if !strings.HasPrefix(name, "small-model@models.example.com#") {
+		t.Errorf("Expected the model and host in the name, got %q", name)
+	}
+	if other := NewHTTPSummarizer("https://models.example.com/v1/chat/completions", "small-model", "", "Summarize briefly.", 0).Name(); other == name {
+		t.Errorf("Expected another prompt to give another name, both were %q", name)
+	}
+	if other := NewHTTPSummarizer("http://localhost:8080/v1/chat/completions", "small-model", "", "", 0).Name(); other == name {
+		t.Errorf("Expected another host to give another name, both were %q", name)
+	}
+}
+
+func TestHTTPSummarizer_LongResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": strings.Repeat("retry ", 10000)}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	thread := retryThread(t, context.NewConversationManager())
+	if _, err := NewHTTPSummarizer(server.URL, "small-model", "", "", 100).Summarize(gocontext.Background(), thread); err == nil {
+		t.Error("Expected a response far longer than a summary to fail")
+	}
+	if _, err := NewHTTPSummarizer(server.URL, "small-model", "", "", 40000).Summarize(gocontext.Background(), thread); err != nil {
+		t.Errorf("Expected a response within the limit to be read, got %v", err)
+	}
+}
+
+func TestTranscriptText_Long(t *testing.T) {
+	thread := &context.ConversationThread{Title: "Long", Messages: []context.Message{
+		{AuthorID: "alice", MessageType: context.MsgQuestion, Content: "Where do we start?"},
+	}}
+	for i := 0; i < 100; i++ {
+		thread.Messages = append(thread.Messages, context.Message{AuthorID: "bob", MessageType: context.MsgComment, Content: strings.Repeat("word ", 200)})
+	}
+	thread.Messages = append(thread.Messages, context.Message{AuthorID: "carol", MessageType: context.MsgDecision, Content: "Start here."})
+
+	transcript := transcriptText(thread)
+	if len(transcript) > MaxTranscriptLength {
+		t.Errorf("Expected at most %d bytes, got %d", MaxTranscriptLength, len(transcript))
+	}
+	if !strings.Contains(transcript, "alice (question): Where do we start?\n\n[") || !strings.HasSuffix(transcript, "carol (decision): Start here.") {
+		t.Errorf("Expected the opening and latest messages kept around a note of those left out, got %q", transcript[:200])
+	}
+}
@@ -0,0 +1,149 @@
+package telemetry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+// Operations replays the journal's edits to file against doc, the document
+// as it stood before them, and returns the operations that make the same
+// changes in order. doc is left as it is.
+//
+// An edit that cuts into a construct replaces it: the construct is deleted
+// and what the edit leaves of it is inserted again on either side of the new
+// text. Edits that fall between constructs only insert and delete.
+func (j *Journal) Operations(file string, doc *positioning.Document) ([]*operations.Operation, error) {
+	working, err := positioning.RestoreDocument(doc.Snapshot())
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []*operations.Operation
+	for i, event := range j.Events {
+		if event.File != file {
+			continue
+		}
+		template := j.template(event)
+		for k, change := range event.Changes {
+			changed, err := replay(working, change, template)
+			if err != nil {
+				return nil, fmt.Errorf("events[%d].changes[%d]: %w", i, k, err)
+			}
+			ops = append(ops, changed...)
+		}
+	}
+	return ops, nil
+}
+
+// span is a construct with the byte range its content takes in the text
+type span struct {
+	construct  *positioning.Construct
+	start, end int
+}
+
+// replay makes the operations for one change and applies them to doc
+func replay(doc *positioning.Document, change ContentChange, template operations.Operation) ([]*operations.Operation, error) {
+	var spans []span
+	var text strings.Builder
+	doc.ForEachConstruct(func(construct *positioning.Construct) bool {
+		spans = append(spans, span{construct, text.Len(), text.Len() + len(construct.Content)})
+		text.WriteString(construct.Content)
+		return true
+	})
+
+	start, ok := byteOffset(text.String(), change.RangeOffset)
+	if !ok {
+		return nil, fmt.Errorf("%w: offset %d in %s", ErrOffsetOutOfRange, change.RangeOffset, template.Metadata.DocumentID)
+	}
+	length, ok := byteOffset(text.String()[start:], change.RangeLength)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d characters from offset %d in %s", ErrOffsetOutOfRange, change.RangeLength, change.RangeOffset, template.Metadata.DocumentID)
+	}
+	end := start + length
+
+	// The constructs the change overlaps or cuts into, and their neighbours
+	first := sort.Search(len(spans), func(i int) bool { return spans[i].end > start })
+	last := first
+	for last < len(spans) && spans[last].start < end {
+		last++
+	}
+	var left, right operations.LogootPosition
+	if first > 0 {
+		left = spans[first-1].construct.Position
+	}
+	if last < len(spans) {
+		right = spans[last].construct.Position
+	}
+
+	var ops []*operations.Operation
+	add := func(opType operations.OperationType, pos operations.LogootPosition, content string) error {
+		op := template
+		op.Type, op.Position = opType, pos
+		if opType == operations.OpDelete {
+			op.Length = strings.Count(content, "\n") + 1
+		} else {
+			op.Content = content
+		}
+		op.ID = operations.ComputeID(&op)
+		if err := doc.ApplyOperation(&op); err != nil {
+			return err
+		}
+		ops = append(ops, &op)
+		return nil
+	}
+
+	var before, after string
+	for _, s := range spans[first:last] {
+		if operations.NormalizeContentType(s.construct.Metadata.ContentType) != operations.ContentTypeText {
+			return nil, fmt.Errorf("%w: %s at offset %d", ErrNotText, template.Metadata.DocumentID, change.RangeOffset)
+		}
+		if s.start < start {
+			before = s.construct.Content[:start-s.start]
+		}
+		if s.end > end {
+			after = s.construct.Content[end-s.start:]
+		}
+		if err := add(operations.OpDelete, s.construct.Position, s.construct.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	// Each piece goes after the one before it, all before right
+	for _, content := range []string{before, change.Text, after} {
+		if content == "" {
+			continue
+		}
+		pos := operations.GeneratePosition(left, right, template.Author)
+		if err := add(operations.OpInsert, pos, content); err != nil {
+			return nil, err
+		}
+		left = pos
+	}
+	return ops, nil
+}
+
+// byteOffset converts an offset in UTF-16 code units into text to one in
+// bytes, false when it is past the end or splits a character
+func byteOffset(text string, units int) (int, bool) {
+	counted := 0
+	for i, r := range text {
+		if counted == units {
+			return i, true
+		}
+		if counted > units {
+			return 0, false
+		}
+		// Invalid UTF-8 reaches the editor as U+FFFD, one unit
+		if n := utf16.RuneLen(r); n > 0 {
+			counted += n
+		} else {
+			counted++
+		}
+	}
+	return len(text), counted == units
+}
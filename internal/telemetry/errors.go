@@ -0,0 +1,27 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidJournal   = errors.New("invalid edit journal")
+	ErrOffsetOutOfRange = errors.New("edit offset out of range")
+	ErrNotText          = errors.New("edit touches content that isn't text")
+)
+
+// FieldError is a problem with one field of a journal, named by its JSON
+// path such as "events[2].file". It matches ErrInvalidJournal.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%v: %s %s", ErrInvalidJournal, e.Field, e.Message)
+}
+
+func (e *FieldError) Is(target error) bool {
+	return target == ErrInvalidJournal
+}
@@ -0,0 +1,142 @@
+// Package telemetry reads the edit journals editor extensions keep while
+// offline and turns them into operations. A journal records each change as
+// the editor reported it, an offset into the file's text with the length it
+// replaced, so replaying it needs the document as it stood before the first
+// edit.
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// FormatVSCode names the journal format of the VS Code extension
+const FormatVSCode = "vscode-edit-journal"
+
+// FormatVersion is the version of the journal format this package reads
+const FormatVersion = 1
+
+// Tool is recorded in the metadata of every imported operation
+const Tool = "vscode"
+
+// Journal is a batch of edits one author made in an editor, in the order
+// they were made
+type Journal struct {
+	Format  string              `json:"format"`
+	Version int                 `json:"version"`
+	Author  operations.AuthorID `json:"author"`
+	// SessionID is the editor session of events that don't name their own
+	SessionID string `json:"session_id,omitempty"`
+	// Branch is the branch the edits were made on, main when empty
+	Branch string      `json:"branch,omitempty"`
+	Events []EditEvent `json:"events"`
+}
+
+// EditEvent is one change event from the editor. Its changes are applied in
+// order, each against the text the one before it left, which is how VS Code
+// orders the changes of a multi-cursor edit.
+type EditEvent struct {
+	// File is the document edited, its path relative to the workspace
+	File      string    `json:"file"`
+	Timestamp time.Time `json:"timestamp"`
+	SessionID string    `json:"session_id,omitempty"`
+	// LanguageID is the editor's language of the file, such as "go"
+	LanguageID string          `json:"language_id,omitempty"`
+	Changes    []ContentChange `json:"changes"`
+}
+
+// ContentChange replaces RangeLength characters at RangeOffset with Text.
+// Like VS Code's, offsets and lengths count UTF-16 code units.
+type ContentChange struct {
+	RangeOffset int    `json:"range_offset"`
+	RangeLength int    `json:"range_length"`
+	Text        string `json:"text"`
+}
+
+// ParseJournal decodes and validates a journal
+func ParseJournal(r io.Reader) (*Journal, error) {
+	var journal Journal
+	if err := json.NewDecoder(r).Decode(&journal); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJournal, err)
+	}
+	if err := journal.Validate(); err != nil {
+		return nil, err
+	}
+	return &journal, nil
+}
+
+// Validate returns a *FieldError for the first problem found
+func (j *Journal) Validate() error {
+	invalid := func(field, format string, args ...interface{}) error {
+		return &FieldError{Field: field, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if j.Format != FormatVSCode {
+		return invalid("format", "must be %s", FormatVSCode)
+	}
+	if j.Version != FormatVersion {
+		return invalid("version", "must be %d", FormatVersion)
+	}
+	if j.Author == "" {
+		return invalid("author", "is required")
+	}
+	if len(j.Events) == 0 {
+		return invalid("events", "is required")
+	}
+	for i, event := range j.Events {
+		field := fmt.Sprintf("events[%d]", i)
+		switch {
+		case event.File == "":
+			return invalid(field+".file", "is required")
+		case event.Timestamp.IsZero():
+			return invalid(field+".timestamp", "is required")
+		case len(event.Changes) == 0:
+			return invalid(field+".changes", "is required")
+		}
+		for k, change := range event.Changes {
+			if change.RangeOffset < 0 || change.RangeLength < 0 {
+				return invalid(fmt.Sprintf("%s.changes[%d]", field, k), "offsets must not be negative")
+			}
+			if change.RangeLength == 0 && change.Text == "" {
+				return invalid(fmt.Sprintf("%s.changes[%d]", field, k), "changes nothing")
+			}
+		}
+	}
+	return nil
+}
+
+// Files lists the documents the journal edits, in the order first edited
+func (j *Journal) Files() []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, event := range j.Events {
+		if !seen[event.File] {
+			seen[event.File] = true
+			files = append(files, event.File)
+		}
+	}
+	return files
+}
+
+// template is what every operation made for event shares
+func (j *Journal) template(event EditEvent) operations.Operation {
+	session := event.SessionID
+	if session == "" {
+		session = j.SessionID
+	}
+	return operations.Operation{
+		Author:    j.Author,
+		Timestamp: event.Timestamp,
+		Metadata: operations.OperationMeta{
+			SessionID:  session,
+			DocumentID: event.File,
+			Branch:     j.Branch,
+			Tool:       Tool,
+			Language:   event.LanguageID,
+		},
+	}
+}
@@ -0,0 +1,80 @@
+package telemetry
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+)
+
+func TestParseJournal(t *testing.T) {
+	journal, err := ParseJournal(strings.NewReader(`{
+		"format": "vscode-edit-journal", "version": 1, "author": "alice", "session_id": "s1",
+		"events": [{"file": "main.go", "timestamp": "2025-01-01T12:00:00Z", "changes": [{"range_offset": 0, "range_length": 0, "text": "package main\n"}]}]
+	}`))
+	if err != nil {
+		t.Fatalf("Failed to parse journal: %v", err)
+	}
+	if len(journal.Events) != 1 || journal.Events[0].Changes[0].Text != "package main\n" {
+		t.Errorf("Expected one event inserting a package clause, got %+v", journal.Events)
+	}
+
+	_, err = ParseJournal(strings.NewReader(`{"format": "vscode-edit-journal", "version": 1, "author": "alice", "events": [{"timestamp": "2025-01-01T12:00:00Z"}]}`))
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "events[0].file" || !errors.Is(err, ErrInvalidJournal) {
+		t.Errorf("Expected the missing file reported, got %v", err)
+	}
+	if _, err := ParseJournal(strings.NewReader(`{"format": "sublime", "version": 1}`)); !errors.Is(err, ErrInvalidJournal) {
+		t.Errorf("Expected an unknown format refused, got %v", err)
+	}
+}
+
+func TestJournal_Operations(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	journal := &Journal{Format: FormatVSCode, Version: FormatVersion, Author: "alice", SessionID: "s1"}
+	edit := func(file string, changes ...ContentChange) {
+		journal.Events = append(journal.Events, EditEvent{File: file, Timestamp: at, LanguageID: "go", Changes: changes})
+		at = at.Add(time.Second)
+	}
+
+	edit("main.go", ContentChange{Text: "func main() {}\n"})
+	edit("other.go", ContentChange{Text: "package other\n"})
+	// Cuts into the first insert, then one change of a multi-cursor edit per line
+	edit("main.go", ContentChange{RangeOffset: 12, RangeLength: 2, Text: "{\n\trun()\n}"})
+	edit("main.go", ContentChange{RangeOffset: 0, Text: "// 🚀\n"})
+	edit("main.go", ContentChange{RangeOffset: 3, RangeLength: 2, Text: "go"})
+
+	doc := positioning.NewDocument("main.go")
+	ops, err := journal.Operations("main.go", doc)
+	if err != nil {
+		t.Fatalf("Failed to replay journal: %v", err)
+	}
+	if doc.ConstructCount() != 0 {
+		t.Errorf("Expected the document given to be left alone")
+	}
+
+	replayed := positioning.NewDocument("main.go")
+	for _, op := range ops {
+		if op.Metadata.DocumentID != "main.go" || op.Metadata.SessionID != "s1" || op.Metadata.Tool != Tool || op.Metadata.Language != "go" {
+			t.Fatalf("Expected the journal's metadata on every operation, got %+v", op.Metadata)
+		}
+		if err := replayed.ApplyOperation(op); err != nil {
+			t.Fatalf("Failed to apply operation: %v", err)
+		}
+	}
+	content, _ := replayed.Render()
+	if want := "// go\nfunc main() {\n\trun()\n}\n"; content != want {
+		t.Errorf("Expected %q, got %q", want, content)
+	}
+
+	journal.Events = append(journal.Events, EditEvent{File: "main.go", Timestamp: at, Changes: []ContentChange{{RangeOffset: 100, Text: "x"}}})
+	if _, err := journal.Operations("main.go", doc); !errors.Is(err, ErrOffsetOutOfRange) {
+		t.Errorf("Expected an offset past the end refused, got %v", err)
+	}
+	// Offsets count UTF-16 units, so one can't land inside a surrogate pair
+	if _, ok := byteOffset("🚀", 1); ok {
+		t.Errorf("Expected an offset inside a surrogate pair refused")
+	}
+}
@@ -0,0 +1,188 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/events"
+)
+
+// Headers sent with every delivery
+const (
+	EventHeader     = "X-ContextDB-Event"
+	DeliveryHeader  = "X-ContextDB-Delivery"
+	AttemptHeader   = "X-ContextDB-Attempt"
+	SignatureHeader = "X-ContextDB-Signature"
+)
+
+const signaturePrefix = "sha256="
+
+// Delivery records one attempt to deliver an event to a webhook. Retries of
+// the same event share an ID.
+type Delivery struct {
+	ID          string      `json:"id"`
+	WebhookID   string      `json:"webhook_id"`
+	EventID     string      `json:"event_id"`
+	EventType   events.Type `json:"event_type"`
+	Attempt     int         `json:"attempt"`
+	StatusCode  int         `json:"status_code,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	Success     bool        `json:"success"`
+	DurationMs  int64       `json:"duration_ms"`
+	Timestamp   time.Time   `json:"timestamp"`
+	NextRetryAt *time.Time  `json:"next_retry_at,omitempty"`
+}
+
+// delivery is a queued attempt
+type delivery struct {
+	id        string
+	webhookID string
+	eventID   string
+	eventType events.Type
+	body      []byte
+	attempt   int
+}
+
+// Sign returns the value of the signature header for body: the hex encoded
+// HMAC-SHA256 of the body keyed by the webhook secret, prefixed with "sha256="
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body, for receivers
+// written in Go
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+func (m *Manager) work() {
+	defer m.workers.Done()
+
+	for d := range m.queue {
+		m.deliver(d)
+	}
+}
+
+func (m *Manager) deliver(d delivery) {
+	m.mutex.RLock()
+	webhook, exists := m.webhooks[d.webhookID]
+	var target, secret string
+	if exists {
+		target, secret = webhook.URL, webhook.Secret
+	}
+	m.mutex.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	start := time.Now()
+	statusCode, err := m.post(target, secret, d)
+	result := Delivery{
+		StatusCode: statusCode,
+		Success:    err == nil && statusCode >= 200 && statusCode < 300,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	} else if !result.Success {
+		result.Error = http.StatusText(statusCode)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// The webhook may have been deleted while the request was in flight
+	if _, exists := m.webhooks[d.webhookID]; !exists {
+		return
+	}
+
+	if !result.Success && retryable(statusCode, err) && d.attempt < m.retry.MaxAttempts && !m.closed {
+		delay := m.retry.backoff(d.attempt)
+		next := time.Now().Add(delay)
+		result.NextRetryAt = &next
+		m.scheduleRetry(d, delay)
+	}
+	m.record(d, result)
+
+	if !result.Success {
+		m.logger.Warn("Webhook delivery failed", map[string]interface{}{
+			"webhook_id":  d.webhookID,
+			"delivery_id": d.id,
+			"attempt":     d.attempt,
+			"status_code": statusCode,
+			"error":       result.Error,
+		})
+	}
+}
+
+func (m *Manager) post(target, secret string, d delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(d.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "contextdb-webhooks")
+	req.Header.Set(EventHeader, string(d.eventType))
+	req.Header.Set(DeliveryHeader, d.id)
+	req.Header.Set(AttemptHeader, strconv.Itoa(d.attempt))
+	req.Header.Set(SignatureHeader, Sign(secret, d.body))
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// retryable reports whether a failed attempt might succeed later. Other
+// client errors mean the receiver rejected the event and retrying won't help.
+func retryable(statusCode int, err error) bool {
+	return err != nil || statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// scheduleRetry must be called with the mutex held
+func (m *Manager) scheduleRetry(d delivery, delay time.Duration) {
+	d.attempt++
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		if m.retries == nil {
+			return
+		}
+		delete(m.retries, timer)
+		m.enqueue(d)
+	})
+	m.retries[timer] = struct{}{}
+}
+
+// record must be called with the mutex held
+func (m *Manager) record(d delivery, result Delivery) {
+	result.ID = d.id
+	result.WebhookID = d.webhookID
+	result.EventID = d.eventID
+	result.EventType = d.eventType
+	result.Attempt = d.attempt
+	result.Timestamp = time.Now()
+
+	log := append([]Delivery{result}, m.deliveries[d.webhookID]...)
+	if len(log) > maxDeliveries {
+		log = log[:maxDeliveries]
+	}
+	m.deliveries[d.webhookID] = log
+}
@@ -0,0 +1,9 @@
+package webhooks
+
+import "errors"
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidURL      = errors.New("webhook url must be an absolute http or https url")
+	ErrUnknownEvent    = errors.New("unknown event type")
+)
@@ -0,0 +1,410 @@
+// Package webhooks delivers engine events to external HTTP endpoints. Each
+// delivery is signed with the webhook's secret and retried with exponential
+// backoff, and recent attempts are kept so they can be inspected over the API.
+package webhooks
+
+import (
+	gocontext "context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/events"
+	"github.com/jeremytregunna/contextdb/internal/ids"
+	"github.com/jeremytregunna/contextdb/internal/logging"
+)
+
+const (
+	// maxDeliveries is how many delivery attempts are remembered per webhook
+	maxDeliveries = 100
+	queueSize     = 1024
+	workerCount   = 4
+)
+
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Secret signs every delivery. It is only returned when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+	// Events filters what is delivered, empty means every event
+	Events    []events.Type `json:"events"`
+	Active    bool          `json:"active"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+func (w Webhook) Subscribes(eventType events.Type) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, t := range w.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted returns a copy that is safe to hand out after creation
+func (w Webhook) redacted() Webhook {
+	w.Secret = ""
+	w.Events = append([]events.Type(nil), w.Events...)
+	return w
+}
+
+// RetryPolicy controls how failed deliveries are retried. The delay doubles
+// after every attempt, starting at BaseDelay and never exceeding MaxDelay.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+	}
+}
+
+// backoff returns how long to wait before the attempt after the given one
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+type Manager struct {
+	configPath string
+	webhooks   map[string]*Webhook
+	deliveries map[string][]Delivery // Webhook ID -> attempts, newest first
+	client     *http.Client
+	retry      RetryPolicy
+	queue      chan delivery
+	retries    map[*time.Timer]struct{}
+	workers    sync.WaitGroup
+	closed     bool
+	logger     *logging.Logger
+	mutex      sync.RWMutex
+}
+
+type ManagerOption func(*Manager)
+
+func WithHTTPClient(client *http.Client) ManagerOption {
+	return func(m *Manager) {
+		m.client = client
+	}
+}
+
+func WithRetryPolicy(policy RetryPolicy) ManagerOption {
+	return func(m *Manager) {
+		m.retry = policy
+	}
+}
+
+// NewManager loads the webhooks configured in basePath/.context/webhooks.json
+// and starts the delivery workers. Close stops them.
+func NewManager(basePath string, opts ...ManagerOption) (*Manager, error) {
+	m := &Manager{
+		configPath: filepath.Join(basePath, ".context", "webhooks.json"),
+		webhooks:   make(map[string]*Webhook),
+		deliveries: make(map[string][]Delivery),
+		client:     &http.Client{Timeout: 10 * time.Second},
+		retry:      DefaultRetryPolicy(),
+		queue:      make(chan delivery, queueSize),
+		retries:    make(map[*time.Timer]struct{}),
+		logger:     logging.NewLogger("webhooks"),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < workerCount; i++ {
+		m.workers.Add(1)
+		go m.work()
+	}
+
+	return m, nil
+}
+
+// Create registers a webhook. An empty secret is replaced by a random one,
+// which is returned in the result and never shown again.
+func (m *Manager) Create(rawURL, secret string, eventTypes []events.Type) (Webhook, error) {
+	if err := validateURL(rawURL); err != nil {
+		return Webhook{}, err
+	}
+	for _, t := range eventTypes {
+		if !events.IsValidType(t) {
+			return Webhook{}, fmt.Errorf("%w: %q", ErrUnknownEvent, t)
+		}
+	}
+
+	if secret == "" {
+		generated, err := generateSecret()
+		if err != nil {
+			return Webhook{}, err
+		}
+		secret = generated
+	}
+
+	webhook := &Webhook{
+		ID:        ids.NewWithPrefix("whk"),
+		URL:       rawURL,
+		Secret:    secret,
+		Events:    append([]events.Type(nil), eventTypes...),
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.webhooks[webhook.ID] = webhook
+	if err := m.save(); err != nil {
+		delete(m.webhooks, webhook.ID)
+		return Webhook{}, err
+	}
+
+	return *webhook, nil
+}
+
+// List returns every webhook, oldest first, without secrets
+func (m *Manager) List() []Webhook {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	webhooks := make([]Webhook, 0, len(m.webhooks))
+	for _, webhook := range m.webhooks {
+		webhooks = append(webhooks, webhook.redacted())
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks
+}
+
+// Get returns the webhook without its secret
+func (m *Manager) Get(id string) (Webhook, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	webhook, exists := m.webhooks[id]
+	if !exists {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	return webhook.redacted(), nil
+}
+
+// SetActive pauses or resumes deliveries to a webhook
+func (m *Manager) SetActive(id string, active bool) (Webhook, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	webhook, exists := m.webhooks[id]
+	if !exists {
+		return Webhook{}, ErrWebhookNotFound
+	}
+
+	previous := webhook.Active
+	webhook.Active = active
+	if err := m.save(); err != nil {
+		webhook.Active = previous
+		return Webhook{}, err
+	}
+	return webhook.redacted(), nil
+}
+
+// Delete removes a webhook and its delivery log. Deliveries already queued
+// for it are dropped.
+func (m *Manager) Delete(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	webhook, exists := m.webhooks[id]
+	if !exists {
+		return ErrWebhookNotFound
+	}
+
+	delete(m.webhooks, id)
+	if err := m.save(); err != nil {
+		m.webhooks[id] = webhook
+		return err
+	}
+	delete(m.deliveries, id)
+	return nil
+}
+
+// Deliveries returns up to limit of the most recent delivery attempts for a
+// webhook, newest first. A limit of zero or less returns all that are kept.
+func (m *Manager) Deliveries(id string, limit int) ([]Delivery, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if _, exists := m.webhooks[id]; !exists {
+		return nil, ErrWebhookNotFound
+	}
+
+	deliveries := m.deliveries[id]
+	if limit > 0 && limit < len(deliveries) {
+		deliveries = deliveries[:limit]
+	}
+	return append([]Delivery{}, deliveries...), nil
+}
+
+// Handle queues an event for every active webhook subscribed to it. It never
+// blocks, so it can be subscribed directly to an events.Bus.
+func (m *Manager) Handle(event events.Event) {
+	// Encode now, the event data may change once the publisher moves on
+	body, err := json.Marshal(event)
+	if err != nil {
+		m.logger.Error("Failed to encode event", map[string]interface{}{
+			"event_id": event.ID,
+			"type":     string(event.Type),
+			"error":    err.Error(),
+		})
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, webhook := range m.webhooks {
+		if !webhook.Active || !webhook.Subscribes(event.Type) {
+			continue
+		}
+		m.enqueue(delivery{
+			id:        ids.NewWithPrefix("dlv"),
+			webhookID: webhook.ID,
+			eventID:   event.ID,
+			eventType: event.Type,
+			body:      body,
+			attempt:   1,
+		})
+	}
+}
+
+// Close stops accepting events, cancels pending retries and waits for
+// deliveries already queued to finish or for ctx to end
+func (m *Manager) Close(ctx gocontext.Context) error {
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return nil
+	}
+	m.closed = true
+	for timer := range m.retries {
+		timer.Stop()
+	}
+	m.retries = nil
+	close(m.queue)
+	m.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue must be called with the mutex held
+func (m *Manager) enqueue(d delivery) {
+	if m.closed {
+		return
+	}
+
+	select {
+	case m.queue <- d:
+	default:
+		m.record(d, Delivery{Error: "delivery queue is full"})
+	}
+}
+
+func (m *Manager) load() error {
+	if _, err := os.Stat(m.configPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	file, err := os.Open(m.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	defer file.Close()
+
+	var stored []*Webhook
+	if err := json.NewDecoder(file).Decode(&stored); err != nil {
+		return fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	for _, webhook := range stored {
+		m.webhooks[webhook.ID] = webhook
+	}
+	return nil
+}
+
+// save must be called with the mutex held
+func (m *Manager) save() error {
+	stored := make([]*Webhook, 0, len(m.webhooks))
+	for _, webhook := range m.webhooks {
+		stored = append(stored, webhook)
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].CreatedAt.Before(stored[j].CreatedAt)
+	})
+
+	if err := os.MkdirAll(filepath.Dir(m.configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create webhook directory: %w", err)
+	}
+
+	// The file holds signing secrets, so keep it private to the owner
+	file, err := os.OpenFile(m.configPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to save webhooks: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(stored); err != nil {
+		return fmt.Errorf("failed to save webhooks: %w", err)
+	}
+	return nil
+}
+
+func validateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("%w: %q", ErrInvalidURL, rawURL)
+	}
+	return nil
+}
+
+func generateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,193 @@
+package webhooks
+
+import (
+	gocontext "context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/events"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+
+	manager, err := NewManager(t.TempDir(), WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   10 * time.Millisecond,
+		MaxDelay:    50 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	t.Cleanup(func() { manager.Close(gocontext.Background()) })
+	return manager
+}
+
+func waitForDeliveries(t *testing.T, manager *Manager, id string, count int) []Delivery {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries, err := manager.Deliveries(id, 0)
+		if err != nil {
+			t.Fatalf("Failed to get deliveries: %v", err)
+		}
+		if len(deliveries) >= count {
+			return deliveries
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d deliveries", count)
+	return nil
+}
+
+func TestManager_DeliversSignedEvents(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer receiver.Close()
+
+	manager := newTestManager(t)
+	webhook, err := manager.Create(receiver.URL, "secret", []events.Type{events.OperationCreated})
+	if err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+
+	bus := events.NewBus()
+	bus.Subscribe(manager.Handle)
+	// Filtered out by the webhook's event list
+	bus.Publish(events.ConversationCreated, map[string]string{"id": "ignored"})
+	bus.Publish(events.OperationCreated, map[string]string{"id": "op-1"})
+
+	r := <-received
+	body := <-bodies
+	if r.Header.Get(EventHeader) != string(events.OperationCreated) {
+		t.Errorf("Expected event header %s, got %s", events.OperationCreated, r.Header.Get(EventHeader))
+	}
+	if !Verify("secret", body, r.Header.Get(SignatureHeader)) {
+		t.Errorf("Signature %q does not match body", r.Header.Get(SignatureHeader))
+	}
+
+	deliveries := waitForDeliveries(t, manager, webhook.ID, 1)
+	if len(deliveries) != 1 || !deliveries[0].Success || deliveries[0].StatusCode != http.StatusOK {
+		t.Errorf("Expected one successful delivery, got %+v", deliveries)
+	}
+}
+
+func TestManager_RetriesFailedDeliveries(t *testing.T) {
+	var calls int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	manager := newTestManager(t)
+	webhook, err := manager.Create(receiver.URL, "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+	if webhook.Secret == "" {
+		t.Error("Expected a generated secret")
+	}
+
+	manager.Handle(events.Event{ID: "evt-1", Type: events.DocumentUpdated})
+
+	deliveries := waitForDeliveries(t, manager, webhook.ID, 3)
+	if !deliveries[0].Success || deliveries[0].Attempt != 3 {
+		t.Errorf("Expected third attempt to succeed, got %+v", deliveries[0])
+	}
+	for _, d := range deliveries[1:] {
+		if d.Success || d.NextRetryAt == nil || d.ID != deliveries[0].ID {
+			t.Errorf("Expected a failed attempt of the same delivery with a retry scheduled, got %+v", d)
+		}
+	}
+}
+
+func TestManager_DoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer receiver.Close()
+
+	manager := newTestManager(t)
+	webhook, err := manager.Create(receiver.URL, "secret", nil)
+	if err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+
+	manager.Handle(events.Event{ID: "evt-1", Type: events.DocumentUpdated})
+	deliveries := waitForDeliveries(t, manager, webhook.ID, 1)
+
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("Expected 1 attempt, got %d", got)
+	}
+	if deliveries[0].Success || deliveries[0].NextRetryAt != nil {
+		t.Errorf("Expected a failed delivery without retry, got %+v", deliveries[0])
+	}
+}
+
+func TestManager_Persistence(t *testing.T) {
+	basePath := t.TempDir()
+
+	manager, err := NewManager(basePath)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	webhook, err := manager.Create("https://example.com/hook", "secret", []events.Type{events.AddressInvalidated})
+	if err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+	manager.Close(gocontext.Background())
+
+	reopened, err := NewManager(basePath)
+	if err != nil {
+		t.Fatalf("Failed to reopen manager: %v", err)
+	}
+	defer reopened.Close(gocontext.Background())
+
+	loaded, err := reopened.Get(webhook.ID)
+	if err != nil {
+		t.Fatalf("Failed to get webhook: %v", err)
+	}
+	if loaded.URL != webhook.URL || !loaded.Subscribes(events.AddressInvalidated) || loaded.Subscribes(events.OperationCreated) {
+		t.Errorf("Unexpected webhook after reload: %+v", loaded)
+	}
+	if loaded.Secret != "" {
+		t.Error("Expected Get to redact the secret")
+	}
+
+	if err := reopened.Delete(webhook.ID); err != nil {
+		t.Fatalf("Failed to delete webhook: %v", err)
+	}
+	if _, err := reopened.Get(webhook.ID); !errors.Is(err, ErrWebhookNotFound) {
+		t.Errorf("Expected ErrWebhookNotFound, got %v", err)
+	}
+}
+
+func TestManager_CreateValidation(t *testing.T) {
+	manager := newTestManager(t)
+
+	for _, rawURL := range []string{"", "example.com/hook", "ftp://example.com", "http://"} {
+		if _, err := manager.Create(rawURL, "", nil); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("Expected ErrInvalidURL for %q, got %v", rawURL, err)
+		}
+	}
+
+	if _, err := manager.Create("https://example.com", "", []events.Type{"operation.deleted"}); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Expected ErrUnknownEvent, got %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package client
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/api"
+)
+
+// Authentication
+
+// CreateAPIKey returns the new key, which the server won't show again
+func (c *Client) CreateAPIKey(ctx gocontext.Context, req CreateAPIKeyRequest) (string, error) {
+	var created api.CreatedAPIKey
+	if err := c.call(ctx, http.MethodPost, endpoint("auth", "keys"), req, &created); err != nil {
+		return "", err
+	}
+	return created.APIKey, nil
+}
+
+func (c *Client) ListAPIKeys(ctx gocontext.Context) ([]APIKeySummary, error) {
+	var keys []APIKeySummary
+	if _, err := c.get(ctx, endpoint("auth", "keys"), nil, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RotateAPIKey replaces the secret of any author's key. The old secret keeps
+// working for grace, and stops at once when it's zero.
+func (c *Client) RotateAPIKey(ctx gocontext.Context, id string, grace time.Duration) (*RotatedAPIKey, error) {
+	return c.rotateAPIKey(ctx, endpoint("auth", "keys", id, "rotate"), grace)
+}
+
+func (c *Client) rotateAPIKey(ctx gocontext.Context, path string, grace time.Duration) (*RotatedAPIKey, error) {
+	var rotated RotatedAPIKey
+	req := RotateAPIKeyRequest{GracePeriod: Duration(grace)}
+	if err := c.call(ctx, http.MethodPost, path, req, &rotated); err != nil {
+		return nil, err
+	}
+	return &rotated, nil
+}
+
+func (c *Client) RevokeAPIKey(ctx gocontext.Context, id string) error {
+	return c.call(ctx, http.MethodDelete, endpoint("auth", "keys", id), nil, nil)
+}
+
+// CreateOwnAPIKey returns a new key for this client's author, which the
+// server won't show again
+func (c *Client) CreateOwnAPIKey(ctx gocontext.Context, req CreateOwnAPIKeyRequest) (string, error) {
+	var created api.CreatedAPIKey
+	if err := c.call(ctx, http.MethodPost, endpoint("auth", "me", "keys"), req, &created); err != nil {
+		return "", err
+	}
+	return created.APIKey, nil
+}
+
+// ListOwnAPIKeys lists the keys of this client's author
+func (c *Client) ListOwnAPIKeys(ctx gocontext.Context) ([]APIKeySummary, error) {
+	var keys []APIKeySummary
+	if _, err := c.get(ctx, endpoint("auth", "me", "keys"), nil, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RotateOwnAPIKey replaces the secret of one of this client's author's keys.
+// The old secret keeps working for grace, and stops at once when it's zero.
+func (c *Client) RotateOwnAPIKey(ctx gocontext.Context, id string, grace time.Duration) (*RotatedAPIKey, error) {
+	return c.rotateAPIKey(ctx, endpoint("auth", "me", "keys", id, "rotate"), grace)
+}
+
+func (c *Client) RevokeOwnAPIKey(ctx gocontext.Context, id string) error {
+	return c.call(ctx, http.MethodDelete, endpoint("auth", "me", "keys", id), nil, nil)
+}
+
+// OwnSessions lists the WebSocket clients connected as this client's author
+func (c *Client) OwnSessions(ctx gocontext.Context) ([]ClientInfo, error) {
+	var sessions []ClientInfo
+	if _, err := c.get(ctx, endpoint("auth", "me", "sessions"), nil, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// AuthStatus describes how the server sees this client's key
+func (c *Client) AuthStatus(ctx gocontext.Context) (*AuthStatus, error) {
+	var status AuthStatus
+	if _, err := c.get(ctx, endpoint("auth", "status"), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (c *Client) EnableAuth(ctx gocontext.Context) error {
+	return c.call(ctx, http.MethodPost, endpoint("auth", "enable"), nil, nil)
+}
+
+func (c *Client) DisableAuth(ctx gocontext.Context) error {
+	return c.call(ctx, http.MethodPost, endpoint("auth", "disable"), nil, nil)
+}
+
+// Administration, which needs a key with the admin permission
+
+// Stats counts what the server holds and sizes its store
+func (c *Client) Stats(ctx gocontext.Context) (*Stats, error) {
+	var stats Stats
+	if _, err := c.get(ctx, endpoint("admin", "stats"), nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// LogLevels returns the levels the server's components log at
+func (c *Client) LogLevels(ctx gocontext.Context) (*LogLevels, error) {
+	var levels LogLevels
+	if _, err := c.get(ctx, endpoint("admin", "log-levels"), nil, &levels); err != nil {
+		return nil, err
+	}
+	return &levels, nil
+}
+
+// SetLogLevels changes log levels until the server restarts or reloads its
+// config, returning them as they are now
+func (c *Client) SetLogLevels(ctx gocontext.Context, req SetLogLevelsRequest) (*LogLevels, error) {
+	var levels LogLevels
+	if err := c.call(ctx, http.MethodPut, endpoint("admin", "log-levels"), req, &levels); err != nil {
+		return nil, err
+	}
+	return &levels, nil
+}
+
+func usageQuery(since time.Time) url.Values {
+	query := url.Values{}
+	if !since.IsZero() {
+		query.Set("since", since.Format("2006-01-02"))
+	}
+	return query
+}
+
+// ListUsage returns usage per key from since, or all recorded usage when since is zero
+func (c *Client) ListUsage(ctx gocontext.Context, since time.Time) ([]KeyUsage, error) {
+	var usage []KeyUsage
+	if _, err := c.get(ctx, endpoint("admin", "usage"), usageQuery(since), &usage); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+func (c *Client) GetKeyUsage(ctx gocontext.Context, keyID string, since time.Time) (*KeyUsage, error) {
+	var usage KeyUsage
+	if _, err := c.get(ctx, endpoint("admin", "usage", keyID), usageQuery(since), &usage); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+func (c *Client) GetRetentionPolicy(ctx gocontext.Context) (*RetentionPolicy, error) {
+	var policy RetentionPolicy
+	if _, err := c.get(ctx, endpoint("admin", "retention"), nil, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (c *Client) SetRetentionPolicy(ctx gocontext.Context, policy RetentionPolicy) (*RetentionPolicy, error) {
+	var saved RetentionPolicy
+	if err := c.call(ctx, http.MethodPut, endpoint("admin", "retention"), policy, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// PreviewRetention reports what EnforceRetention would remove without removing it
+func (c *Client) PreviewRetention(ctx gocontext.Context) (*RetentionReport, error) {
+	var report RetentionReport
+	if _, err := c.get(ctx, endpoint("admin", "retention", "preview"), nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (c *Client) EnforceRetention(ctx gocontext.Context) (*RetentionReport, error) {
+	var report RetentionReport
+	if err := c.call(ctx, http.MethodPost, endpoint("admin", "retention", "enforce"), nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// SetIntentTaxonomy replaces the categories operations are classified into
+// and returns them as saved
+func (c *Client) SetIntentTaxonomy(ctx gocontext.Context, taxonomy IntentTaxonomy) (*IntentTaxonomy, error) {
+	var saved IntentTaxonomy
+	if err := c.call(ctx, http.MethodPut, endpoint("admin", "intents"), taxonomy, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// Reanalyze starts analyzing the intent of the operations query selects again
+// with the current taxonomy. It runs on the server as the reanalysis job;
+// GetJob shows its progress.
+func (c *Client) Reanalyze(ctx gocontext.Context, query ReanalysisQuery) (*JobStatus, error) {
+	var status JobStatus
+	if err := c.call(ctx, http.MethodPost, endpoint("admin", "analysis", "reanalyze"), query, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// CreateWebhook registers a webhook. The returned webhook carries its signing
+// secret, which the server won't show again.
+func (c *Client) CreateWebhook(ctx gocontext.Context, req CreateWebhookRequest) (*Webhook, error) {
+	var webhook Webhook
+	if err := c.call(ctx, http.MethodPost, endpoint("admin", "webhooks"), req, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (c *Client) ListWebhooks(ctx gocontext.Context) ([]Webhook, error) {
+	var hooks []Webhook
+	if _, err := c.get(ctx, endpoint("admin", "webhooks"), nil, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (c *Client) GetWebhook(ctx gocontext.Context, id string) (*Webhook, error) {
+	var webhook Webhook
+	if _, err := c.get(ctx, endpoint("admin", "webhooks", id), nil, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// SetWebhookActive pauses or resumes deliveries to a webhook
+func (c *Client) SetWebhookActive(ctx gocontext.Context, id string, active bool) (*Webhook, error) {
+	var webhook Webhook
+	req := api.UpdateWebhookRequest{Active: &active}
+	if err := c.call(ctx, http.MethodPatch, endpoint("admin", "webhooks", id), req, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (c *Client) DeleteWebhook(ctx gocontext.Context, id string) error {
+	return c.call(ctx, http.MethodDelete, endpoint("admin", "webhooks", id), nil, nil)
+}
+
+// ListWebhookDeliveries returns a webhook's most recent deliveries, 50 when limit is zero
+func (c *Client) ListWebhookDeliveries(ctx gocontext.Context, id string, limit int) ([]WebhookDelivery, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var deliveries []WebhookDelivery
+	if _, err := c.get(ctx, endpoint("admin", "webhooks", id, "deliveries"), query, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ListJobs returns the server's background jobs with their last runs
+func (c *Client) ListJobs(ctx gocontext.Context) ([]JobStatus, error) {
+	var statuses []JobStatus
+	if _, err := c.get(ctx, endpoint("admin", "jobs"), nil, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// GetJob returns a background job with its latest runs, newest first
+func (c *Client) GetJob(ctx gocontext.Context, name string) (*JobStatus, error) {
+	var status JobStatus
+	if _, err := c.get(ctx, endpoint("admin", "jobs", name), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// RunJob starts a background job now. It runs on the server after RunJob
+// returns; GetJob shows how it went.
+func (c *Client) RunJob(ctx gocontext.Context, name string) (*JobStatus, error) {
+	var status JobStatus
+	if err := c.call(ctx, http.MethodPost, endpoint("admin", "jobs", name, "run"), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
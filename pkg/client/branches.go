@@ -0,0 +1,82 @@
+package client
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/url"
+
+	"github.com/jeremytregunna/contextdb/internal/api"
+)
+
+// ListBranches returns the open branches by name, and merged ones too when
+// includeMerged is set
+func (c *Client) ListBranches(ctx gocontext.Context, includeMerged bool) ([]*Branch, error) {
+	query := url.Values{}
+	if includeMerged {
+		query.Set("include_merged", "true")
+	}
+
+	var branches []*Branch
+	if _, err := c.get(ctx, endpoint("branches"), query, &branches); err != nil {
+		return nil, err
+	}
+	return branches, nil
+}
+
+// CreateBranch starts a line of work apart from main. Operations whose
+// metadata names the branch render only on it until it is merged.
+func (c *Client) CreateBranch(ctx gocontext.Context, req CreateBranchRequest) (*Branch, error) {
+	var branch Branch
+	if err := c.call(ctx, http.MethodPost, endpoint("branches"), req, &branch); err != nil {
+		return nil, err
+	}
+	return &branch, nil
+}
+
+func (c *Client) GetBranch(ctx gocontext.Context, name string) (*Branch, error) {
+	var branch Branch
+	if _, err := c.get(ctx, endpoint("branches", name), nil, &branch); err != nil {
+		return nil, err
+	}
+	return &branch, nil
+}
+
+// MergeBranch merges a branch into into, or its base when into is empty,
+// and closes it
+func (c *Client) MergeBranch(ctx gocontext.Context, name, into string) (*BranchMerge, error) {
+	var merge BranchMerge
+	req := api.MergeBranchRequest{Into: into}
+	if err := c.call(ctx, http.MethodPost, endpoint("branches", name, "merge"), req, &merge); err != nil {
+		return nil, err
+	}
+	return &merge, nil
+}
+
+// GetBranchDocument returns a document as it is on a branch
+func (c *Client) GetBranchDocument(ctx gocontext.Context, path, branch string) (*Document, error) {
+	var doc Document
+	query := url.Values{"branch": {branch}}
+	if _, err := c.get(ctx, endpoint("documents", path), query, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// MergeDocument merges one document from branch from into branch to, main
+// when to is empty, and reports the regions both branches changed. A dry run
+// reports without merging.
+func (c *Client) MergeDocument(ctx gocontext.Context, path, from, to string, dryRun bool) (*MergeReport, error) {
+	query := url.Values{"from": {from}}
+	if to != "" {
+		query.Set("to", to)
+	}
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+
+	var report MergeReport
+	if _, err := c.do(ctx, http.MethodPost, endpoint("documents", path, "merge"), query, nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	gocontext "context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ListChangeSetsOptions filters ListChangeSets. Since and Until keep the
+// change sets overlapping the period.
+type ListChangeSetsOptions struct {
+	Author   AuthorID
+	Document string
+	Since    time.Time
+	Until    time.Time
+	Offset   int
+	Limit    int
+}
+
+func (o ListChangeSetsOptions) query() url.Values {
+	query := url.Values{}
+	if o.Author != "" {
+		query.Set("author", string(o.Author))
+	}
+	if o.Document != "" {
+		query.Set("document", o.Document)
+	}
+	if !o.Since.IsZero() {
+		query.Set("since", o.Since.Format(time.RFC3339))
+	}
+	if !o.Until.IsZero() {
+		query.Set("until", o.Until.Format(time.RFC3339))
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return query
+}
+
+// ListChangeSets returns a page of change sets, oldest first
+func (c *Client) ListChangeSets(ctx gocontext.Context, opts ListChangeSetsOptions) ([]*ChangeSet, *ResponseMeta, error) {
+	var changeSets []*ChangeSet
+	meta, err := c.get(ctx, endpoint("changesets"), opts.query(), &changeSets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return changeSets, meta, nil
+}
+
+// GetChangeSet returns a change set with the conversations anchored to it
+func (c *Client) GetChangeSet(ctx gocontext.Context, id ChangeSetID) (*ChangeSetDetails, error) {
+	var details ChangeSetDetails
+	if _, err := c.get(ctx, endpoint("changesets", string(id)), nil, &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
@@ -0,0 +1,251 @@
+// Package client is a Go client for the ContextDB HTTP API. It speaks
+// /api/v2, unwrapping the response envelope and turning error envelopes into
+// *Error, retries with backoff when the server is briefly unavailable, and
+// joins live collaboration over WebSocket.
+package client
+
+import (
+	"bytes"
+	gocontext "context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is where `contextdb serve` listens unless told otherwise
+const DefaultBaseURL = "http://localhost:8080"
+
+const apiPrefix = "/api/v2"
+
+// maxErrorBody bounds how much of a response that isn't an envelope is kept
+const maxErrorBody = 4096
+
+// Client calls one ContextDB server. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	apiKey     string
+	httpClient *http.Client
+	retry      RetryPolicy
+	userAgent  string
+	signingKey ed25519.PrivateKey
+}
+
+type Option func(*Client)
+
+// WithAPIKey authenticates every request, needed once the server has auth enabled
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithHTTPClient replaces the default client, which times out after 30 seconds
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// New returns a client for the server at baseURL, e.g. DefaultBaseURL
+func New(baseURL string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBaseURL, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: %q must be an http or https url", ErrInvalidBaseURL, baseURL)
+	}
+
+	c := &Client{
+		baseURL:    parsed,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retry:      DefaultRetryPolicy,
+		userAgent:  "contextdb-go",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c, nil
+}
+
+// envelope is the v2 response body, with data left for the caller to decode
+type envelope struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+	Error   *ErrorResponse  `json:"error,omitempty"`
+	Meta    *ResponseMeta   `json:"meta,omitempty"`
+}
+
+// endpoint joins path segments under the v2 prefix, escaping each one so
+// values like document paths survive as a single segment
+func endpoint(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+	return apiPrefix + "/" + strings.Join(escaped, "/")
+}
+
+func (c *Client) url(path string, query url.Values) string {
+	u := *c.baseURL
+	u.RawPath = c.baseURL.EscapedPath() + path
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// do sends a request, retrying as the policy allows, and decodes the
+// envelope's data into out when out is non-nil
+func (c *Client) do(ctx gocontext.Context, method, path string, query url.Values, body, out interface{}) (*envelope, error) {
+	var payload []byte
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case rawBody:
+		payload, contentType = b.data, b.contentType
+	default:
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	for attempt := 1; ; attempt++ {
+		resp, err = c.send(ctx, method, c.url(path, query), payload, contentType)
+		if attempt >= c.retry.MaxAttempts || ctx.Err() != nil || !retryable(method, resp) {
+			break
+		}
+
+		wait := c.retry.wait(attempt, resp)
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return decodeResponse(resp, out)
+}
+
+// rawBody is a request body sent as it is instead of encoded as JSON
+type rawBody struct {
+	contentType string
+	data        []byte
+}
+
+func (c *Client) send(ctx gocontext.Context, method, target string, payload []byte, contentType string) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req.Header)
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp, nil
+}
+
+func (c *Client) setHeaders(header http.Header) {
+	if c.apiKey != "" {
+		header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	header.Set("User-Agent", c.userAgent)
+}
+
+func decodeResponse(resp *http.Response, out interface{}) (*envelope, error) {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		if resp.StatusCode >= http.StatusBadRequest {
+			return nil, &Error{StatusCode: resp.StatusCode, ErrorResponse: ErrorResponse{
+				Status:  resp.StatusCode,
+				Message: strings.TrimSpace(string(data[:min(len(data), maxErrorBody)])),
+			}}
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUnexpectedResponse, err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest || !env.Success {
+		if env.Error == nil {
+			return nil, &Error{StatusCode: resp.StatusCode, ErrorResponse: ErrorResponse{
+				Status:  resp.StatusCode,
+				Message: http.StatusText(resp.StatusCode),
+			}}
+		}
+		return nil, &Error{StatusCode: resp.StatusCode, ErrorResponse: *env.Error}
+	}
+
+	if out != nil && len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnexpectedResponse, err)
+		}
+	}
+	return &env, nil
+}
+
+// get and call cover the common shapes of request
+
+func (c *Client) get(ctx gocontext.Context, path string, query url.Values, out interface{}) (*ResponseMeta, error) {
+	env, err := c.do(ctx, http.MethodGet, path, query, nil, out)
+	if err != nil {
+		return nil, err
+	}
+	return env.Meta, nil
+}
+
+func (c *Client) call(ctx gocontext.Context, method, path string, body, out interface{}) error {
+	_, err := c.do(ctx, method, path, nil, body, out)
+	return err
+}
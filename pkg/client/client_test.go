@@ -0,0 +1,823 @@
+package client
+
+import (
+	gocontext "context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/server"
+)
+
+func startServer(t *testing.T) *Client {
+	t.Helper()
+	return startServerWith(t, server.DefaultConfig())
+}
+
+func startServerWith(t *testing.T, config server.Config) *Client {
+	t.Helper()
+
+	config.Storage.Path = t.TempDir()
+	srv, err := server.New(config)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	httpServer := httptest.NewServer(srv.Handler())
+	t.Cleanup(httpServer.Close)
+
+	c, err := New(httpServer.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return c
+}
+
+func insertRequest(content, documentID string) CreateOperationRequest {
+	return CreateOperationRequest{
+		Type: OpInsert,
+		Position: NewLogootPosition([]PositionSegment{
+			{Value: big.NewInt(time.Now().UnixNano()), AuthorID: "alice"},
+		}),
+		Content:    content,
+		Author:     "alice",
+		DocumentID: documentID,
+	}
+}
+
+func TestClient_Operations(t *testing.T) {
+	c := startServer(t)
+	ctx := gocontext.Background()
+
+	health, err := c.Health(ctx)
+	if err != nil || health.Status != "healthy" {
+		t.Fatalf("Expected a healthy server, got %+v, %v", health, err)
+	}
+
+	created, err := c.CreateOperation(ctx, insertRequest("func retry() {}", "src/main.go"))
+	if err != nil {
+		t.Fatalf("Failed to create operation: %v", err)
+	}
+
+	op, err := c.GetOperation(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get operation: %v", err)
+	}
+	if op.Content != "func retry() {}" || op.Position.Segments[0].Value.Cmp(created.Position.Segments[0].Value) != 0 {
+		t.Errorf("Unexpected operation %+v", op)
+	}
+
+	ops, meta, err := c.ListOperations(ctx, ListOperationsOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("Failed to list operations: %v", err)
+	}
+	if len(ops) != 1 || meta == nil || meta.Total != 1 || meta.Limit != 10 {
+		t.Errorf("Expected one operation with paging details, got %d and %+v", len(ops), meta)
+	}
+	ops, _, err = c.ListOperations(ctx, ListOperationsOptions{Fields: []string{"id", "metadata.document_id"}})
+	if err != nil {
+		t.Fatalf("Failed to list operations with fields: %v", err)
+	}
+	if len(ops) != 1 || ops[0].ID != created.ID || ops[0].Metadata.DocumentID != "src/main.go" || ops[0].Content != "" || ops[0].Author != "" {
+		t.Errorf("Expected only the ID and document, got %+v", ops)
+	}
+	var streamed []OperationID
+	err = c.ForEachOperation(ctx, ListOperationsOptions{DocumentID: "src/main.go"}, func(op *Operation) error {
+		streamed = append(streamed, op.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream operations: %v", err)
+	}
+	if len(streamed) != 1 || streamed[0] != created.ID {
+		t.Errorf("Expected the operation streamed, got %v", streamed)
+	}
+
+	// Paths with slashes are one segment
+	doc, err := c.GetDocument(ctx, "src/main.go")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if doc.FilePath != "src/main.go" {
+		t.Errorf("Expected src/main.go, got %q", doc.FilePath)
+	}
+	tree, err := c.DocumentTree(ctx, DocumentTreeOptions{Depth: -1})
+	if err != nil {
+		t.Fatalf("Failed to list document tree: %v", err)
+	}
+	if tree.Files != 1 || len(tree.Nodes) != 1 || len(tree.Nodes[0].Children) != 1 || tree.Nodes[0].Children[0].Path != "src/main.go" {
+		t.Errorf("Expected src/ holding main.go, got %+v", tree)
+	}
+	withStats, docStats, err := c.GetDocumentWithStats(ctx, "src/main.go")
+	if err != nil {
+		t.Fatalf("Failed to get document stats: %v", err)
+	}
+	if withStats.FilePath != "src/main.go" || docStats == nil || docStats.Authors != 1 || docStats.Size != int64(len("func retry() {}")) || docStats.LastActivity == nil {
+		t.Errorf("Unexpected document stats %+v", docStats)
+	}
+	tree, err = c.DocumentTree(ctx, DocumentTreeOptions{Depth: -1, Stats: true})
+	if err != nil {
+		t.Fatalf("Failed to list document tree: %v", err)
+	}
+	if file := tree.Nodes[0].Children[0]; file.Stats == nil || file.Stats.Authors != 1 || tree.Nodes[0].Stats != nil {
+		t.Errorf("Expected stats on the file only, got %+v", tree.Nodes[0])
+	}
+	ownership, err := c.GetDocumentOwnership(ctx, "src/main.go", 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to get document ownership: %v", err)
+	}
+	if len(ownership.Authors) != 1 || ownership.Authors[0].Author != "alice" || ownership.Authors[0].Wrote != 1 {
+		t.Errorf("Expected alice to own the document, got %+v", ownership.Authors)
+	}
+	constructs, err := c.GetConstructs(ctx, "src/main.go", created.Position, created.Position)
+	if err != nil {
+		t.Fatalf("Failed to get constructs: %v", err)
+	}
+	if len(constructs) != 1 || constructs[0].Content != "func retry() {}" {
+		t.Errorf("Expected the inserted construct, got %+v", constructs)
+	}
+	pack, err := c.ContextPack(ctx, ContextPackRequest{DocumentID: "src/main.go"})
+	if err != nil {
+		t.Fatalf("Failed to build context pack: %v", err)
+	}
+	if pack.Code != "func retry() {}" || len(pack.History) != 1 || pack.Tokens > pack.Budget {
+		t.Errorf("Expected the document packed within budget, got %+v", pack)
+	}
+
+	results, err := c.Search(ctx, "retry", SearchOptions{Type: "operation"})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if results.Total != 1 || results.Results[0].Content != created.Content {
+		t.Errorf("Expected the operation to be found, got %+v", results)
+	}
+
+	changeSets, _, err := c.ListChangeSets(ctx, ListChangeSetsOptions{Author: "alice"})
+	if err != nil {
+		t.Fatalf("Failed to list change sets: %v", err)
+	}
+	if len(changeSets) != 1 || changeSets[0].Operations[0] != created.ID {
+		t.Fatalf("Expected the operation in one change set, got %+v", changeSets)
+	}
+	thread, err := c.CreateConversation(ctx, CreateConversationRequest{AuthorID: "bob", Title: "Retry", Content: "Why?", ChangeSetID: changeSets[0].ID})
+	if err != nil {
+		t.Fatalf("Failed to create conversation: %v", err)
+	}
+	if thread.Visibility != VisibilityPublic {
+		t.Errorf("Expected conversations to be public by default, got %q", thread.Visibility)
+	}
+	if restricted, err := c.SetConversationVisibility(ctx, thread.ID, VisibilityParticipants, "carol"); err != nil || len(restricted.Participants) != 2 {
+		t.Errorf("Expected carol added to a participants-only thread, got %+v, %v", restricted, err)
+	}
+	details, err := c.GetChangeSet(ctx, changeSets[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to get change set: %v", err)
+	}
+	if len(details.Conversations) != 1 || details.Conversations[0].ID != thread.ID {
+		t.Errorf("Expected the conversation on the change set, got %+v", details.Conversations)
+	}
+
+	report, err := c.ActivityReport(ctx, ActivityReportOptions{Author: "alice"})
+	if err != nil {
+		t.Fatalf("Failed to get activity report: %v", err)
+	}
+	if len(report.Authors) != 1 || report.Authors[0].AuthorID != "alice" || report.Summary.TotalOperations != 1 {
+		t.Errorf("Expected a report on alice's operation, got %+v", report)
+	}
+
+	churn, err := c.Churn(ctx, ChurnOptions{Sort: ChurnByAuthors})
+	if err != nil {
+		t.Fatalf("Failed to get churn: %v", err)
+	}
+	if len(churn.Documents) != 1 || churn.Documents[0].DocumentID != "src/main.go" || churn.Sort != ChurnByAuthors {
+		t.Errorf("Expected churn on src/main.go, got %+v", churn)
+	}
+
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.Operations != 1 || stats.NewestOperation == nil || stats.DatabaseBytes == 0 || len(stats.Indexes) == 0 {
+		t.Errorf("Expected stats on the one operation, got %+v", stats)
+	}
+
+	levels, err := c.SetLogLevels(ctx, SetLogLevelsRequest{Components: map[string]string{"api": "debug"}})
+	if err != nil {
+		t.Fatalf("Failed to set log levels: %v", err)
+	}
+	if levels.Components["api"] != "debug" || !slices.Contains(levels.KnownComponents, "api") {
+		t.Errorf("Expected api logging at debug among the known components, got %+v", levels)
+	}
+	if _, err := c.SetLogLevels(ctx, SetLogLevelsRequest{Components: map[string]string{"websocket": "loud"}}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrValidation for an unknown level, got %v", err)
+	}
+	if _, err := c.SetLogLevels(ctx, SetLogLevelsRequest{Components: map[string]string{"api": ""}}); err != nil {
+		t.Fatalf("Failed to reset log level: %v", err)
+	}
+	if levels, err := c.LogLevels(ctx); err != nil || len(levels.Components) != 0 {
+		t.Errorf("Expected api back at the global level, got %+v, %v", levels, err)
+	}
+
+	jobs, err := c.ListJobs(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(jobs) != 3 || jobs[0].Name != "backup" || jobs[1].Name != "reanalysis" || jobs[2].Name != "retention" || jobs[2].Interval != "1h0m0s" {
+		t.Errorf("Expected the backup, reanalysis and retention jobs, got %+v", jobs)
+	}
+	if _, err := c.GetJob(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown job, got %v", err)
+	}
+
+	// Deleted documents refuse reads and writes until restored
+	if err := c.DeleteDocument(ctx, "src/main.go"); err != nil {
+		t.Fatalf("Failed to delete document: %v", err)
+	}
+	if _, err := c.GetDocument(ctx, "src/main.go"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted document, got %v", err)
+	}
+	if _, err := c.CreateOperation(ctx, insertRequest("func backoff() {}", "src/main.go")); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict writing to a deleted document, got %v", err)
+	}
+	restored, err := c.RestoreDocument(ctx, "src/main.go")
+	if err != nil {
+		t.Fatalf("Failed to restore document: %v", err)
+	}
+	if restored.FilePath != "src/main.go" || restored.Version != doc.Version {
+		t.Errorf("Expected the document back as it was, got %+v", restored)
+	}
+	if _, err := c.RestoreDocument(ctx, "src/main.go"); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict restoring a live document, got %v", err)
+	}
+
+	if _, err := c.OpenAPI(ctx); err != nil {
+		t.Errorf("Failed to fetch the OpenAPI document: %v", err)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	c := startServer(t)
+	ctx := gocontext.Background()
+
+	_, err := c.GetOperation(ctx, "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "not_found" {
+		t.Errorf("Expected the error envelope, got %+v", apiErr)
+	}
+
+	_, err = c.Search(ctx, "", SearchOptions{})
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &apiErr) || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "q" {
+		t.Errorf("Expected a validation error on q, got %v", err)
+	}
+
+	req := insertRequest("package main\n", "main.go")
+	now := time.Now()
+	req.ID, req.Timestamp = "chosen-by-client", &now
+	_, err = c.CreateOperation(ctx, req)
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &apiErr) || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "id" {
+		t.Errorf("Expected a validation error on id, got %v", err)
+	}
+
+	// A strict taxonomy only takes the intents it defines
+	taxonomy, err := c.SetIntentTaxonomy(ctx, IntentTaxonomy{Categories: []IntentDefinition{{Name: "Chore", Keywords: []string{"bump"}}}, Strict: true})
+	if err != nil {
+		t.Fatalf("Failed to set intent taxonomy: %v", err)
+	}
+	if current, err := c.IntentTaxonomy(ctx); err != nil || len(current.Categories) != 1 || current.Categories[0].Name != taxonomy.Categories[0].Name {
+		t.Errorf("Expected the saved taxonomy, got %v %+v", err, current)
+	}
+	req = insertRequest("package main\n", "main.go")
+	req.Metadata.Intent = "because"
+	_, err = c.CreateOperation(ctx, req)
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &apiErr) || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "metadata.intent" {
+		t.Errorf("Expected a validation error on metadata.intent, got %v", err)
+	}
+	req.Metadata.Intent = "bump"
+	if _, err := c.CreateOperation(ctx, req); err != nil {
+		t.Errorf("Failed to create operation with a known intent: %v", err)
+	}
+
+	if _, err := c.GetOperationAnalysis(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an operation never reanalyzed, got %v", err)
+	}
+	_, err = c.Reanalyze(ctx, ReanalysisQuery{Since: now, Until: now.Add(-time.Hour)})
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &apiErr) || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "until" {
+		t.Errorf("Expected a validation error on until, got %v", err)
+	}
+
+	if _, err := New("localhost:8080"); !errors.Is(err, ErrInvalidBaseURL) {
+		t.Errorf("Expected ErrInvalidBaseURL, got %v", err)
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	var attempts, status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(int(status.Load()))
+			w.Write([]byte(`{"success":false,"error":{"code":"unavailable","message":"busy","status":503}}`))
+			return
+		}
+		w.Write([]byte(`{"success":true,"data":{"status":"healthy","version":"test"}}`))
+	}))
+	defer httpServer.Close()
+
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	c, err := New(httpServer.URL, WithRetryPolicy(policy))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	ctx := gocontext.Background()
+
+	// The server turned the POST away before handling it, so it is safe to resend
+	if _, err := c.CreateConversation(ctx, CreateConversationRequest{Title: "retry"}); err != nil {
+		t.Fatalf("Expected the request to succeed on the third attempt: %v", err)
+	}
+	if attempts.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts.Load())
+	}
+
+	// A bad gateway may have reached the server, so only idempotent requests retry
+	status.Store(http.StatusBadGateway)
+	attempts.Store(0)
+	if _, err := c.CreateConversation(ctx, CreateConversationRequest{Title: "once"}); err == nil {
+		t.Error("Expected the POST to fail without retrying")
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attempts.Load())
+	}
+
+	attempts.Store(0)
+	if _, err := c.Health(ctx); err != nil || attempts.Load() != 3 {
+		t.Errorf("Expected GET to be retried to success, got %v after %d attempts", err, attempts.Load())
+	}
+}
+
+func TestClient_CausalHistory(t *testing.T) {
+	c := startServer(t)
+	ctx := gocontext.Background()
+
+	root, err := c.CreateOperation(ctx, insertRequest("package main\n", "main.go"))
+	if err != nil {
+		t.Fatalf("Failed to create operation: %v", err)
+	}
+	left, err := c.CreateOperation(ctx, insertRequest("func left() {}\n", "main.go"))
+	if err != nil {
+		t.Fatalf("Failed to create operation: %v", err)
+	}
+	if len(left.Parents) != 1 || left.Parents[0] != root.ID {
+		t.Fatalf("Expected the operation to follow on from %s, got %v", root.ID, left.Parents)
+	}
+
+	// A branch off root, then a merge of both heads
+	req := insertRequest("func right() {}\n", "main.go")
+	req.Parents = []OperationID{root.ID}
+	right, err := c.CreateOperation(ctx, req)
+	if err != nil {
+		t.Fatalf("Failed to create operation: %v", err)
+	}
+	merge, err := c.CreateOperation(ctx, insertRequest("func main() {}\n", "main.go"))
+	if err != nil {
+		t.Fatalf("Failed to create operation: %v", err)
+	}
+	if len(merge.Parents) != 2 {
+		t.Fatalf("Expected the operation to merge both heads, got %v", merge.Parents)
+	}
+
+	bases, err := c.MergeBase(ctx, left.ID, right.ID)
+	if err != nil {
+		t.Fatalf("Failed to get merge base: %v", err)
+	}
+	if len(bases) != 1 || bases[0].ID != root.ID {
+		t.Errorf("Expected %s as the merge base, got %v", root.ID, bases)
+	}
+
+	if isAncestor, err := c.IsAncestor(ctx, right.ID, merge.ID); err != nil || !isAncestor {
+		t.Errorf("Expected the branch to be an ancestor of the merge, got %v, %v", isAncestor, err)
+	}
+	if isAncestor, err := c.IsAncestor(ctx, left.ID, right.ID); err != nil || isAncestor {
+		t.Errorf("Expected concurrent operations not to be ancestors, got %v, %v", isAncestor, err)
+	}
+	if _, err := c.IsAncestor(ctx, root.ID, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing operation, got %v", err)
+	}
+
+	ordered, meta, err := c.CausalOrder(ctx, "main.go", 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to get causal order: %v", err)
+	}
+	if meta.Total != 4 || len(ordered) != 4 || ordered[0].ID != root.ID || ordered[3].ID != merge.ID {
+		t.Errorf("Expected root first and the merge last, got %v", ordered)
+	}
+
+	// Operations descending from the branch, and the merge's history
+	result, err := c.QueryOperations(ctx, OperationQuery{Where: OperationPredicate{DocumentID: "main.go", DescendsFrom: right.ID}})
+	if err != nil {
+		t.Fatalf("Failed to query operations: %v", err)
+	}
+	if len(result.Operations) != 2 || result.Operations[0].Operation.ID != right.ID || result.Operations[1].Operation.ID != merge.ID {
+		t.Errorf("Expected the branch and the merge, got %+v", result.Operations)
+	}
+	if result, err = c.QueryOperations(ctx, OperationQuery{Where: OperationPredicate{AncestorOf: merge.ID}, Depth: 1}); err != nil || len(result.Operations) != 3 {
+		t.Errorf("Expected the merge and its parents, got %+v, %v", result, err)
+	}
+	_, err = c.QueryOperations(ctx, OperationQuery{Where: OperationPredicate{DescendsFrom: root.ID}, MaxCost: 2})
+	var apiErr *Error
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &apiErr) || apiErr.Fields[0].Field != "where" {
+		t.Errorf("Expected a too expensive query refused, got %v", err)
+	}
+}
+
+func TestClient_WebSocket(t *testing.T) {
+	c := startServer(t)
+	ctx := gocontext.Background()
+
+	first, err := c.CreateOperation(ctx, insertRequest("package main\n", "main.go"))
+	if err != nil {
+		t.Fatalf("Failed to create operation: %v", err)
+	}
+
+	sender, err := c.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer sender.Close()
+	watcher, err := c.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer watcher.Close()
+
+	for _, conn := range []*Conn{sender, watcher} {
+		if _, err := conn.Subscribe("main.go", 0); err != nil {
+			t.Fatalf("Failed to subscribe: %v", err)
+		}
+		msg, err := conn.Receive()
+		if err != nil || msg.Type != MsgSync {
+			t.Fatalf("Expected a sync message, got %+v, %v", msg, err)
+		}
+		var sync SyncPayload
+		if err := DecodePayload(msg, &sync); err != nil || sync.CurrentState == nil || sync.CurrentState.FilePath != "main.go" {
+			t.Fatalf("Expected the document state, got %+v, %v", sync, err)
+		}
+	}
+
+	op := &Operation{
+		Type:     OpInsert,
+		Position: NewLogootPosition([]PositionSegment{{Value: big.NewInt(time.Now().UnixNano()), AuthorID: "bob"}}),
+		Content:  "func main() {}\n",
+		Author:   "bob",
+	}
+	messageID, err := sender.SendOperation(op, "main.go")
+	if err != nil {
+		t.Fatalf("Failed to send operation: %v", err)
+	}
+
+	msg, err := sender.Receive()
+	if err != nil || msg.Type != MsgAcknowledgment {
+		t.Fatalf("Expected an ack, got %+v, %v", msg, err)
+	}
+	var ack AckPayload
+	if err := DecodePayload(msg, &ack); err != nil || !ack.Success || ack.MessageID != messageID {
+		t.Errorf("Expected a successful ack of %s, got %+v", messageID, ack)
+	}
+	if len(ack.Parents) != 1 || ack.Parents[0] != first.ID {
+		t.Errorf("Expected the operation to follow on from %s, got %v", first.ID, ack.Parents)
+	}
+
+	msg, err = watcher.Receive()
+	if err != nil || msg.Type != MsgOperation {
+		t.Fatalf("Expected the operation to be broadcast, got %+v, %v", msg, err)
+	}
+	var broadcast OperationPayload
+	if err := DecodePayload(msg, &broadcast); err != nil || broadcast.Operation.ID != ack.OperationID || broadcast.DocumentID != "main.go" {
+		t.Errorf("Expected operation %s, got %+v", ack.OperationID, broadcast.Operation)
+	}
+
+	if _, err := c.GetOperation(ctx, ack.OperationID); err != nil {
+		t.Errorf("Expected the operation to be stored: %v", err)
+	}
+}
+
+func TestClient_WebSocketLargeSync(t *testing.T) {
+	config := server.DefaultConfig()
+	config.WebSocket.CompressionThreshold = 1024
+	config.WebSocket.SyncChunkSize = 512
+	c := startServerWith(t, config)
+	ctx := gocontext.Background()
+
+	// Hashes barely compress, so the state still takes several chunks gzipped
+	var lines strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&lines, "// %x\n", sha256.Sum256([]byte{byte(i)}))
+	}
+	content := lines.String()
+	if _, err := c.CreateOperation(ctx, insertRequest(content, "main.go")); err != nil {
+		t.Fatalf("Failed to create operation: %v", err)
+	}
+
+	conn, err := c.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Subscribe("main.go", 0); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	// Returned as one sync
+	msg, err := conn.Receive()
+	if err != nil || msg.Type != MsgSync {
+		t.Fatalf("Expected a sync message, got %+v, %v", msg, err)
+	}
+	var sync SyncPayload
+	if err := DecodePayload(msg, &sync); err != nil || sync.Encoding != "" || sync.CurrentState == nil || sync.CurrentState.FilePath != "main.go" {
+		t.Fatalf("Expected the decoded document state, got %+v, %v", sync, err)
+	}
+	if got, err := sync.CurrentState.Render(); err != nil || got != content {
+		t.Errorf("Expected the document content whole, got %d bytes, %v", len(got), err)
+	}
+}
+
+func TestClient_WebSocketResume(t *testing.T) {
+	c := startServer(t)
+	ctx := gocontext.Background()
+
+	if _, err := c.CreateOperation(ctx, insertRequest("package main\n", "main.go")); err != nil {
+		t.Fatalf("Failed to create operation: %v", err)
+	}
+
+	conn, err := c.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	token := conn.ResumeToken()
+	if token == "" || conn.Resumed() {
+		t.Fatalf("Expected a new session with a resume token, got %q", token)
+	}
+	if _, err := conn.Subscribe("main.go", 0); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if msg, err := conn.Receive(); err != nil || msg.Type != MsgSync {
+		t.Fatalf("Expected a sync message, got %+v, %v", msg, err)
+	}
+	conn.Close()
+
+	// The session is kept once the server sees the connection go
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats, err := c.Stats(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.ParkedSessions == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the session to be parked, got %d", stats.ParkedSessions)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	missed, err := c.CreateOperation(ctx, insertRequest("func main() {}\n", "main.go"))
+	if err != nil {
+		t.Fatalf("Failed to create operation: %v", err)
+	}
+
+	resumed, err := c.Resume(ctx, token)
+	if err != nil {
+		t.Fatalf("Failed to resume: %v", err)
+	}
+	defer resumed.Close()
+	if !resumed.Resumed() || resumed.ResumeToken() == "" || resumed.ResumeToken() == token {
+		t.Fatalf("Expected the session resumed with a new token, got %q", resumed.ResumeToken())
+	}
+	msg, err := resumed.Receive()
+	if err != nil || msg.Type != MsgOperation {
+		t.Fatalf("Expected the missed operation replayed, got %+v, %v", msg, err)
+	}
+	var replayed OperationPayload
+	if err := DecodePayload(msg, &replayed); err != nil || replayed.Operation.ID != missed.ID {
+		t.Errorf("Expected operation %s, got %+v", missed.ID, replayed.Operation)
+	}
+
+	// Still following the document without subscribing again
+	next, err := c.CreateOperation(ctx, insertRequest("// done\n", "main.go"))
+	if err != nil {
+		t.Fatalf("Failed to create operation: %v", err)
+	}
+	if msg, err = resumed.Receive(); err != nil || DecodePayload(msg, &replayed) != nil || replayed.Operation.ID != next.ID {
+		t.Errorf("Expected operation %s broadcast, got %+v, %v", next.ID, msg, err)
+	}
+
+	// A token only resumes once
+	again, err := c.Resume(ctx, token)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer again.Close()
+	if again.Resumed() {
+		t.Error("Expected a used token to start a new session")
+	}
+}
+
+func TestClient_SignedOperations(t *testing.T) {
+	c := startServer(t)
+	ctx := gocontext.Background()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err := c.RegisterSigningKey(ctx, "laptop", "alice", publicKey); err != nil {
+		t.Fatalf("Failed to register signing key: %v", err)
+	}
+	if err := c.RequireSignatures(ctx); err != nil {
+		t.Fatalf("Failed to require signatures: %v", err)
+	}
+	signer, err := New(c.baseURL.String(), WithSigningKey(privateKey))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if _, err := c.CreateOperation(ctx, insertRequest("unsigned\n", "main.go")); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("Expected an unsigned operation to be refused, got %v", err)
+	}
+
+	req := insertRequest("signed\n", "main.go")
+	now := time.Now()
+	req.Timestamp = &now
+	op, err := signer.CreateOperation(ctx, req)
+	if err != nil {
+		t.Fatalf("Failed to create signed operation: %v", err)
+	}
+	if op.Metadata.Signature == "" {
+		t.Error("Expected the stored operation to keep its signature")
+	}
+	if _, err := signer.CreateOperation(ctx, req); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected a replayed operation to conflict, got %v", err)
+	}
+
+	// alice's key can't write as bob
+	spoofed := insertRequest("spoofed\n", "main.go")
+	spoofed.Author = "bob"
+	if _, err := signer.CreateOperation(ctx, spoofed); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected an operation signed by another author's key to be refused, got %v", err)
+	}
+
+	conn, err := signer.Connect(ctx)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	messageID, err := conn.SendOperation(&Operation{
+		Type:     OpInsert,
+		Position: NewLogootPosition([]PositionSegment{{Value: big.NewInt(time.Now().UnixNano()), AuthorID: "alice"}}),
+		Content:  "live\n",
+		Author:   "alice",
+	}, "main.go")
+	if err != nil {
+		t.Fatalf("Failed to send operation: %v", err)
+	}
+	msg, err := conn.Receive()
+	if err != nil || msg.Type != MsgAcknowledgment {
+		t.Fatalf("Expected an ack, got %+v, %v", msg, err)
+	}
+	var ack AckPayload
+	if err := DecodePayload(msg, &ack); err != nil || !ack.Success || ack.MessageID != messageID {
+		t.Errorf("Expected the signed operation to be accepted, got %+v", ack)
+	}
+}
+
+func TestClient_Imports(t *testing.T) {
+	c := startServer(t)
+	ctx := gocontext.Background()
+
+	source := "package retry\n\nfunc Do() {\n\tcall()\n}\n"
+	imported, err := c.ImportFile(ctx, "src/retry.go", "alice", []byte(source), ImportFileOptions{Chunking: ChunkBlocks, Language: "go"})
+	if err != nil {
+		t.Fatalf("Failed to import file: %v", err)
+	}
+	if imported.Operations != 2 || imported.Version != 2 || imported.SessionID == "" {
+		t.Errorf("Expected two blocks imported under one session, got %+v", imported)
+	}
+	if _, err := c.ImportFile(ctx, "src/retry.go", "alice", []byte(source), ImportFileOptions{}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict importing over content, got %v", err)
+	}
+
+	// The call is 13 units into the second block, which the edit splits
+	journal, err := c.ImportEditJournal(ctx, EditJournal{
+		Author: "alice",
+		Events: []EditEvent{{File: "src/retry.go", Timestamp: time.Now(), Changes: []ContentChange{{RangeOffset: 28, RangeLength: 4, Text: "attempt"}}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to import edit journal: %v", err)
+	}
+	if journal.Operations != 4 || journal.Documents[0].Version != 6 {
+		t.Errorf("Expected the block replaced around the edit, got %+v", journal)
+	}
+
+	doc, err := c.GetDocument(ctx, "src/retry.go")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if content, _ := doc.Render(); content != "package retry\n\nfunc Do() {\n\tattempt()\n}\n" {
+		t.Errorf("Expected the imported file with the journal's edit, got %q", content)
+	}
+}
+
+func TestClient_LargeInserts(t *testing.T) {
+	config := server.DefaultConfig()
+	config.Operations.MaxContentSize = 16
+	config.Operations.MaxRequestSize = 4096
+	c := startServerWith(t, config)
+	ctx := gocontext.Background()
+
+	first, err := c.CreateOperation(ctx, insertRequest("first\n", "main.go"))
+	if err != nil {
+		t.Fatalf("Failed to create operation: %v", err)
+	}
+	if _, err := c.CreateOperation(ctx, insertRequest("last\n", "main.go")); err != nil {
+		t.Fatalf("Failed to create operation: %v", err)
+	}
+
+	// Between the two, so the chunks have to fit before the last line
+	var content strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&content, "line %d\n", i)
+	}
+	req := insertRequest(content.String(), "main.go")
+	req.Position = NewLogootPosition([]PositionSegment{
+		{Value: new(big.Int).Add(first.Position.Segments[0].Value, big.NewInt(1)), AuthorID: "alice"},
+	})
+	created, err := c.CreateOperation(ctx, req)
+	if err != nil {
+		t.Fatalf("Failed to create a large insert: %v", err)
+	}
+	chunk := created.Metadata.Chunk
+	if chunk == nil || chunk.Of != created.ID || chunk.Index != 0 || chunk.Count != 5 || created.Content != "line 0\nline 1\n" {
+		t.Fatalf("Expected the first of 5 chunks, got %q %+v", created.Content, chunk)
+	}
+	if len(created.Parents) != 1 {
+		t.Errorf("Expected the first chunk to follow the document's head, got %v", created.Parents)
+	}
+
+	body, err := c.OperationContent(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get operation content: %v", err)
+	}
+	reassembled, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || string(reassembled) != content.String() {
+		t.Errorf("Expected the whole insert back, got %q, %v", reassembled, err)
+	}
+
+	doc, err := c.GetDocument(ctx, "main.go")
+	if err != nil {
+		t.Fatalf("Failed to get document: %v", err)
+	}
+	if rendered, _ := doc.Render(); rendered != "first\n"+content.String()+"last\n" {
+		t.Errorf("Expected the chunks in order between the lines, got %q", rendered)
+	}
+
+	var apiErr *Error
+	req = insertRequest(strings.Repeat("x", 4096), "main.go")
+	if _, err := c.CreateOperation(ctx, req); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a request over the limit to be refused with 413, got %v", err)
+	}
+
+	// A client's ID covers all the content, so it can't be split
+	req = insertRequest(content.String(), "main.go")
+	now := time.Now()
+	req.Timestamp = &now
+	req.ID = operations.ComputeID(&Operation{
+		Type: req.Type, Position: req.Position, Content: req.Content, Author: req.Author, Timestamp: now,
+		Metadata: OperationMeta{DocumentID: "main.go"},
+	})
+	if _, err := c.CreateOperation(ctx, req); !errors.As(err, &apiErr) || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "content" {
+		t.Errorf("Expected a validation error on content, got %v", err)
+	}
+
+	req = insertRequest("chunk\n", "main.go")
+	req.Metadata.Chunk = &Chunk{Of: created.ID, Index: 5, Count: 6}
+	if _, err := c.CreateOperation(ctx, req); !errors.As(err, &apiErr) || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "metadata.chunk" {
+		t.Errorf("Expected a validation error on metadata.chunk, got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/jeremytregunna/contextdb/internal/api"
+)
+
+// CreateConversation starts a conversation anchored to the code at req.AnchorAddress
+func (c *Client) CreateConversation(ctx gocontext.Context, req CreateConversationRequest) (*ConversationThread, error) {
+	var thread ConversationThread
+	if err := c.call(ctx, http.MethodPost, endpoint("conversations"), req, &thread); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+func (c *Client) GetConversation(ctx gocontext.Context, id ThreadID) (*ConversationThread, error) {
+	var thread ConversationThread
+	if _, err := c.get(ctx, endpoint("conversations", string(id)), nil, &thread); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// GetConversationMessages returns a conversation with only the latest limit
+// messages older than before, oldest first. An empty before pages back from
+// the newest message.
+func (c *Client) GetConversationMessages(ctx gocontext.Context, id ThreadID, limit int, before MessageID) (*ConversationThread, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if before != "" {
+		query.Set("before", string(before))
+	}
+	var thread ConversationThread
+	if _, err := c.get(ctx, endpoint("conversations", string(id)), query, &thread); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+func (c *Client) AddMessage(ctx gocontext.Context, id ThreadID, req AddMessageRequest) (*ConversationMessage, error) {
+	var message ConversationMessage
+	if err := c.call(ctx, http.MethodPost, endpoint("conversations", string(id), "messages"), req, &message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// ResolveConversation marks a conversation resolved by author and returns it
+func (c *Client) ResolveConversation(ctx gocontext.Context, id ThreadID, author AuthorID) (*ConversationThread, error) {
+	var thread ConversationThread
+	req := api.ResolveConversationRequest{AuthorID: author}
+	if err := c.call(ctx, http.MethodPost, endpoint("conversations", string(id), "resolve"), req, &thread); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// SummarizeConversation sums up a conversation as it stands, replacing its
+// summary, and returns it. Servers without a summaries provider refuse it.
+func (c *Client) SummarizeConversation(ctx gocontext.Context, id ThreadID) (*ConversationThread, error) {
+	var thread ConversationThread
+	if err := c.call(ctx, http.MethodPost, endpoint("conversations", string(id), "summarize"), nil, &thread); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// SetConversationVisibility changes who may read a conversation. Participants
+// are added to it, so they can read a participants-only thread.
+func (c *Client) SetConversationVisibility(ctx gocontext.Context, id ThreadID, visibility Visibility, participants ...AuthorID) (*ConversationThread, error) {
+	var thread ConversationThread
+	req := api.SetVisibilityRequest{Visibility: visibility, Participants: participants}
+	if err := c.call(ctx, http.MethodPost, endpoint("conversations", string(id), "visibility"), req, &thread); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// ListConversationsOptions filters ListConversations. A conversation must
+// carry every tag and label given.
+type ListConversationsOptions struct {
+	Tags   []string
+	Labels []string
+	Status ThreadStatus
+	// ChangeSet lists the conversations anchored to a change set
+	ChangeSet ChangeSetID
+	// Branch lists the conversations about a branch
+	Branch string
+	Offset int
+	Limit  int
+}
+
+func (o ListConversationsOptions) query() url.Values {
+	query := url.Values{"tag": o.Tags, "label": o.Labels}
+	if o.Status != "" {
+		query.Set("status", string(o.Status))
+	}
+	if o.ChangeSet != "" {
+		query.Set("changeset", string(o.ChangeSet))
+	}
+	if o.Branch != "" {
+		query.Set("branch", o.Branch)
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return query
+}
+
+// ListConversations returns a page of conversations, most recently updated first
+func (c *Client) ListConversations(ctx gocontext.Context, opts ListConversationsOptions) ([]*ConversationThread, *ResponseMeta, error) {
+	var threads []*ConversationThread
+	meta, err := c.get(ctx, endpoint("conversations"), opts.query(), &threads)
+	if err != nil {
+		return nil, nil, err
+	}
+	return threads, meta, nil
+}
+
+// AddTags tags a conversation and returns it. Tags are stored lowercase.
+func (c *Client) AddTags(ctx gocontext.Context, id ThreadID, tags ...string) (*ConversationThread, error) {
+	var thread ConversationThread
+	req := api.TagsRequest{Tags: tags}
+	if err := c.call(ctx, http.MethodPost, endpoint("conversations", string(id), "tags"), req, &thread); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+func (c *Client) RemoveTag(ctx gocontext.Context, id ThreadID, tag string) (*ConversationThread, error) {
+	var thread ConversationThread
+	if err := c.call(ctx, http.MethodDelete, endpoint("conversations", string(id), "tags", tag), nil, &thread); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// AddLabels labels a conversation and returns it. Labels are stored lowercase.
+func (c *Client) AddLabels(ctx gocontext.Context, id ThreadID, labels ...string) (*ConversationThread, error) {
+	var thread ConversationThread
+	req := api.LabelsRequest{Labels: labels}
+	if err := c.call(ctx, http.MethodPost, endpoint("conversations", string(id), "labels"), req, &thread); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+func (c *Client) RemoveLabel(ctx gocontext.Context, id ThreadID, label string) (*ConversationThread, error) {
+	var thread ConversationThread
+	if err := c.call(ctx, http.MethodDelete, endpoint("conversations", string(id), "labels", label), nil, &thread); err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// ListTags returns every tag in use, most used first
+func (c *Client) ListTags(ctx gocontext.Context) ([]TagCount, error) {
+	var tags []TagCount
+	if _, err := c.get(ctx, endpoint("tags"), nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// ListLabels returns every label in use, most used first
+func (c *Client) ListLabels(ctx gocontext.Context) ([]TagCount, error) {
+	var labels []TagCount
+	if _, err := c.get(ctx, endpoint("labels"), nil, &labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// GetReferenceGraph returns what is connected to root, a conversation ID,
+// operation ID or JSON encoded stable address, within depth edges. A depth of
+// zero uses the server's default.
+func (c *Client) GetReferenceGraph(ctx gocontext.Context, root string, depth int) (*ReferenceGraph, error) {
+	query := url.Values{"root": {root}}
+	if depth > 0 {
+		query.Set("depth", strconv.Itoa(depth))
+	}
+
+	var graph ReferenceGraph
+	if _, err := c.get(ctx, endpoint("graph"), query, &graph); err != nil {
+		return nil, err
+	}
+	return &graph, nil
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/jeremytregunna/contextdb/internal/api"
+)
+
+// ListDecisionsOptions filters ListDecisions
+type ListDecisionsOptions struct {
+	Document string
+	Thread   ThreadID
+	Author   AuthorID
+	// Current leaves out superseded decisions
+	Current bool
+	Offset  int
+	Limit   int
+}
+
+func (o ListDecisionsOptions) query() url.Values {
+	query := url.Values{}
+	if o.Document != "" {
+		query.Set("document", o.Document)
+	}
+	if o.Thread != "" {
+		query.Set("thread", string(o.Thread))
+	}
+	if o.Author != "" {
+		query.Set("author", string(o.Author))
+	}
+	if o.Current {
+		query.Set("current", "true")
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return query
+}
+
+// ListDecisions returns a page of decisions, newest first
+func (c *Client) ListDecisions(ctx gocontext.Context, opts ListDecisionsOptions) ([]*Decision, *ResponseMeta, error) {
+	var decisions []*Decision
+	meta, err := c.get(ctx, endpoint("decisions"), opts.query(), &decisions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decisions, meta, nil
+}
+
+// CreateDecision records a decision anchored to the code at req.AnchorAddress
+func (c *Client) CreateDecision(ctx gocontext.Context, req CreateDecisionRequest) (*Decision, error) {
+	var decision Decision
+	if err := c.call(ctx, http.MethodPost, endpoint("decisions"), req, &decision); err != nil {
+		return nil, err
+	}
+	return &decision, nil
+}
+
+func (c *Client) GetDecision(ctx gocontext.Context, id MessageID) (*Decision, error) {
+	var decision Decision
+	if _, err := c.get(ctx, endpoint("decisions", string(id)), nil, &decision); err != nil {
+		return nil, err
+	}
+	return &decision, nil
+}
+
+// SupersedeDecision marks decision id as replaced by decision by and returns it
+func (c *Client) SupersedeDecision(ctx gocontext.Context, id, by MessageID) (*Decision, error) {
+	var decision Decision
+	req := api.SupersedeDecisionRequest{SupersededBy: by}
+	if err := c.call(ctx, http.MethodPost, endpoint("decisions", string(id), "supersede"), req, &decision); err != nil {
+		return nil, err
+	}
+	return &decision, nil
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jeremytregunna/contextdb/internal/api"
+)
+
+var (
+	ErrInvalidBaseURL     = errors.New("invalid base url")
+	ErrUnexpectedResponse = errors.New("unexpected response")
+	ErrConnectionClosed   = errors.New("connection closed")
+
+	// Compare an *Error against these with errors.Is
+	ErrBadRequest   = errors.New("bad request")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnavailable  = errors.New("unavailable")
+)
+
+// Error is a request the server refused, as it described the failure
+type Error struct {
+	StatusCode int
+	ErrorResponse
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("contextdb: %s (%d %s)", e.Message, e.StatusCode, e.Code)
+	for _, field := range e.Fields {
+		msg += fmt.Sprintf(", %s %s", field.Field, field.Message)
+	}
+	return msg
+}
+
+// Is matches the sentinel for the error's code, or its status when the
+// response didn't come from ContextDB (e.g. a proxy)
+func (e *Error) Is(target error) bool {
+	switch e.Code {
+	case api.ErrCodeBadRequest:
+		return target == ErrBadRequest
+	case api.ErrCodeValidationFailed:
+		return target == ErrValidation
+	case api.ErrCodeUnauthorized:
+		return target == ErrUnauthorized
+	case api.ErrCodeForbidden:
+		return target == ErrForbidden
+	case api.ErrCodeNotFound:
+		return target == ErrNotFound
+	case api.ErrCodeConflict, api.ErrCodeVersionConflict:
+		return target == ErrConflict
+	case api.ErrCodeUnavailable:
+		return target == ErrUnavailable
+	case "":
+		switch e.StatusCode {
+		case http.StatusBadRequest:
+			return target == ErrBadRequest
+		case http.StatusUnauthorized:
+			return target == ErrUnauthorized
+		case http.StatusForbidden:
+			return target == ErrForbidden
+		case http.StatusNotFound:
+			return target == ErrNotFound
+		case http.StatusConflict:
+			return target == ErrConflict
+		case http.StatusServiceUnavailable:
+			return target == ErrUnavailable
+		}
+	}
+	return false
+}
+
+// VersionConflict returns what a write with an expected version missed, when
+// that is why it failed
+func (e *Error) VersionConflict() (*VersionConflictDetails, bool) {
+	if e.Code != api.ErrCodeVersionConflict || e.Details == nil {
+		return nil, false
+	}
+
+	data, err := json.Marshal(e.Details)
+	if err != nil {
+		return nil, false
+	}
+	var details VersionConflictDetails
+	if err := json.Unmarshal(data, &details); err != nil {
+		return nil, false
+	}
+	return &details, true
+}
+
+// ErrorID returns the ID the server logged an internal error under, for
+// reporting it, or "" for any other failure
+func (e *Error) ErrorID() string {
+	if e.Code != api.ErrCodeInternal || e.Details == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(e.Details)
+	if err != nil {
+		return ""
+	}
+	var details api.InternalErrorDetails
+	if err := json.Unmarshal(data, &details); err != nil {
+		return ""
+	}
+	return details.ErrorID
+}
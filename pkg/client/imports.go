@@ -0,0 +1,55 @@
+package client
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/url"
+)
+
+// ImportEditJournal uploads an editor's journal of offset edits, which the
+// server replays as operations. Format and Version default to the ones the
+// server reads.
+func (c *Client) ImportEditJournal(ctx gocontext.Context, journal EditJournal) (*JournalImport, error) {
+	if journal.Format == "" {
+		journal.Format = EditJournalFormat
+	}
+	if journal.Version == 0 {
+		journal.Version = EditJournalVersion
+	}
+
+	var imported JournalImport
+	if err := c.call(ctx, http.MethodPost, endpoint("import", "editor"), journal, &imported); err != nil {
+		return nil, err
+	}
+	return &imported, nil
+}
+
+// ImportFileOptions shape how ImportFile splits a file into constructs
+type ImportFileOptions struct {
+	// Chunking is ChunkLines, the default, or ChunkBlocks
+	Chunking ChunkMode
+	Language string
+	Branch   string
+}
+
+// ImportFile brings an existing file's content in as the document at path,
+// which must not have any content yet
+func (c *Client) ImportFile(ctx gocontext.Context, path string, author AuthorID, content []byte, opts ImportFileOptions) (*FileImport, error) {
+	query := url.Values{"author": {string(author)}}
+	if opts.Chunking != "" {
+		query.Set("chunking", string(opts.Chunking))
+	}
+	if opts.Language != "" {
+		query.Set("language", opts.Language)
+	}
+	if opts.Branch != "" {
+		query.Set("branch", opts.Branch)
+	}
+
+	var imported FileImport
+	body := rawBody{contentType: "text/plain; charset=utf-8", data: content}
+	if _, err := c.do(ctx, http.MethodPost, endpoint("documents", path, "import"), query, body, &imported); err != nil {
+		return nil, err
+	}
+	return &imported, nil
+}
@@ -0,0 +1,561 @@
+package client
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/api"
+)
+
+// ListOperationsOptions filters ListOperations. With no filter set the
+// server lists the last 24 hours. Fields names the only fields the server
+// should send, with dots for fields within fields, leaving the rest zero.
+type ListOperationsOptions struct {
+	Since      time.Time
+	Author     AuthorID
+	DocumentID string
+	Branch     string
+	Tool       string
+	Ticket     string
+	Language   string
+	Offset     int
+	Limit      int
+	Fields     []string
+}
+
+func (o ListOperationsOptions) query() url.Values {
+	query := url.Values{}
+	if !o.Since.IsZero() {
+		query.Set("since", o.Since.Format(time.RFC3339))
+	}
+	if o.Author != "" {
+		query.Set("author", string(o.Author))
+	}
+	setMetadataFilters(query, o.DocumentID, o.Branch, o.Tool, o.Ticket, o.Language)
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if len(o.Fields) > 0 {
+		query.Set("fields", strings.Join(o.Fields, ","))
+	}
+	return query
+}
+
+// setMetadataFilters adds the operation metadata filters that are set
+func setMetadataFilters(query url.Values, documentID, branch, tool, ticket, language string) {
+	for name, value := range map[string]string{
+		"document_id": documentID,
+		"branch":      branch,
+		"tool":        tool,
+		"ticket":      ticket,
+		"language":    language,
+	} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+}
+
+// ListOperations returns a page of operations and the paging details
+func (c *Client) ListOperations(ctx gocontext.Context, opts ListOperationsOptions) ([]*Operation, *ResponseMeta, error) {
+	var ops []*Operation
+	meta, err := c.get(ctx, endpoint("operations"), opts.query(), &ops)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ops, meta, nil
+}
+
+// ForEachOperation calls fn with each operation ListOperations would list,
+// as the server reads them, so they are never all held at once. There is no
+// total. An error from fn stops the stream and is returned.
+func (c *Client) ForEachOperation(ctx gocontext.Context, opts ListOperationsOptions, fn func(op *Operation) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(endpoint("operations"), opts.query()), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req.Header)
+	req.Header.Set("Accept", "application/x-ndjson")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, err := decodeResponse(resp, nil)
+		if err == nil {
+			err = fmt.Errorf("%w: status %d", ErrUnexpectedResponse, resp.StatusCode)
+		}
+		return err
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var op Operation
+		if err := decoder.Decode(&op); err == io.EOF {
+			return nil
+		} else if err != nil {
+			// Including a stream the server cut off
+			return fmt.Errorf("%w: %v", ErrUnexpectedResponse, err)
+		}
+		if err := fn(&op); err != nil {
+			return err
+		}
+	}
+}
+
+// CreateOperation applies an operation. Set ExpectedVersion to apply it only
+// if the document hasn't moved on; a conflict's details are available from
+// the *Error's VersionConflict. With a signing key it is signed first.
+func (c *Client) CreateOperation(ctx gocontext.Context, req CreateOperationRequest) (*Operation, error) {
+	if c.signingKey != nil {
+		if err := signRequest(&req, c.signingKey); err != nil {
+			return nil, err
+		}
+	}
+
+	var op Operation
+	if err := c.call(ctx, http.MethodPost, endpoint("operations"), req, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+func (c *Client) GetOperation(ctx gocontext.Context, id OperationID) (*Operation, error) {
+	var op Operation
+	if _, err := c.get(ctx, endpoint("operations", string(id)), nil, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// OperationContent streams an operation's content, binary content decoded.
+// The content of an insert the server split into chunks, which carry
+// metadata.chunk, is read back whole. The caller closes it.
+func (c *Client) OperationContent(ctx gocontext.Context, id OperationID) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, http.MethodGet, c.url(endpoint("operations", string(id), "content"), nil), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		_, err := decodeResponse(resp, nil)
+		if err == nil {
+			err = fmt.Errorf("%w: status %d", ErrUnexpectedResponse, resp.StatusCode)
+		}
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// GetOperationContext returns the operation with its inferred intent. It is
+// also served at /analysis/context/{operation_id}.
+func (c *Client) GetOperationContext(ctx gocontext.Context, id OperationID) (*OperationContext, error) {
+	var opContext OperationContext
+	if _, err := c.get(ctx, endpoint("operations", string(id), "context"), nil, &opContext); err != nil {
+		return nil, err
+	}
+	return &opContext, nil
+}
+
+func (c *Client) GetOperationIntent(ctx gocontext.Context, id OperationID) (*OperationIntent, error) {
+	var intent OperationIntent
+	if _, err := c.get(ctx, endpoint("operations", string(id), "intent"), nil, &intent); err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// AnalyzeBatchIntent analyzes stored operations together
+func (c *Client) AnalyzeBatchIntent(ctx gocontext.Context, ids []OperationID) (*BatchIntentAnalysis, error) {
+	var analysis BatchIntentAnalysis
+	req := api.BatchIntentRequest{Operations: ids}
+	if err := c.call(ctx, http.MethodPost, endpoint("analyze", "intent"), req, &analysis); err != nil {
+		return nil, err
+	}
+	return &analysis, nil
+}
+
+// AnalyzeIntent analyzes operations that need not be stored
+func (c *Client) AnalyzeIntent(ctx gocontext.Context, ops []*Operation) (*IntentAnalysis, error) {
+	var analysis IntentAnalysis
+	req := api.AnalyzeIntentRequest{Operations: ops}
+	if err := c.call(ctx, http.MethodPost, endpoint("analysis", "intent"), req, &analysis); err != nil {
+		return nil, err
+	}
+	return &analysis, nil
+}
+
+// IntentTaxonomy returns the categories the server classifies operations into
+func (c *Client) IntentTaxonomy(ctx gocontext.Context) (*IntentTaxonomy, error) {
+	var taxonomy IntentTaxonomy
+	if _, err := c.get(ctx, endpoint("analysis", "intents"), nil, &taxonomy); err != nil {
+		return nil, err
+	}
+	return &taxonomy, nil
+}
+
+// GetOperationAnalysis returns the stored result of the last reanalysis of an
+// operation's intent
+func (c *Client) GetOperationAnalysis(ctx gocontext.Context, id OperationID) (*IntentRecord, error) {
+	var record IntentRecord
+	if _, err := c.get(ctx, endpoint("operations", string(id), "analysis"), nil, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ContextPack gathers the code req picks with its history, conversations,
+// decisions and intent, rendered to fit a token budget
+func (c *Client) ContextPack(ctx gocontext.Context, req ContextPackRequest) (*ContextPack, error) {
+	var pack ContextPack
+	if err := c.call(ctx, http.MethodPost, endpoint("analysis", "context-pack"), req, &pack); err != nil {
+		return nil, err
+	}
+	return &pack, nil
+}
+
+// CausalOrder returns a page of a document's operations with each after its
+// parents
+func (c *Client) CausalOrder(ctx gocontext.Context, documentID string, offset, limit int) ([]*Operation, *ResponseMeta, error) {
+	query := url.Values{"document": {documentID}}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var ops []*Operation
+	meta, err := c.get(ctx, endpoint("dag", "order"), query, &ops)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ops, meta, nil
+}
+
+// MergeBase returns the best common ancestors of two operations
+func (c *Client) MergeBase(ctx gocontext.Context, a, b OperationID) ([]*Operation, error) {
+	var base MergeBase
+	query := url.Values{"a": {string(a)}, "b": {string(b)}}
+	if _, err := c.get(ctx, endpoint("dag", "merge-base"), query, &base); err != nil {
+		return nil, err
+	}
+	return base.Bases, nil
+}
+
+// IsAncestor reports whether ancestor is in descendant's causal history
+func (c *Client) IsAncestor(ctx gocontext.Context, ancestor, descendant OperationID) (bool, error) {
+	var ancestry Ancestry
+	query := url.Values{"ancestor": {string(ancestor)}, "descendant": {string(descendant)}}
+	if _, err := c.get(ctx, endpoint("dag", "is-ancestor"), query, &ancestry); err != nil {
+		return false, err
+	}
+	return ancestry.IsAncestor, nil
+}
+
+// QueryOperations finds operations by their metadata, causal history and the
+// conversations about them. The server refuses queries that would read too
+// much with ErrValidation.
+func (c *Client) QueryOperations(ctx gocontext.Context, query OperationQuery) (*QueryResult, error) {
+	var result QueryResult
+	if err := c.call(ctx, http.MethodPost, endpoint("query"), query, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) GetDocument(ctx gocontext.Context, path string) (*Document, error) {
+	var doc Document
+	if _, err := c.get(ctx, endpoint("documents", path), nil, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// GetDocumentWithStats gets a document from main with its stats, which the
+// server works out with aggregates rather than from the document
+func (c *Client) GetDocumentWithStats(ctx gocontext.Context, path string) (*Document, *DocumentStats, error) {
+	var data json.RawMessage
+	if _, err := c.get(ctx, endpoint("documents", path), url.Values{"stats": {"true"}}, &data); err != nil {
+		return nil, nil, err
+	}
+
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	var withStats struct {
+		Stats *DocumentStats `json:"stats"`
+	}
+	if err := json.Unmarshal(data, &withStats); err != nil {
+		return nil, nil, err
+	}
+	return &doc, withStats.Stats, nil
+}
+
+// DocumentTreeOptions picks the directory a document tree starts at and how
+// many levels it shows. Depth defaults to 1; a negative Depth shows every level.
+// Stats sums up each document in it.
+type DocumentTreeOptions struct {
+	Prefix         string
+	Depth          int
+	IncludeDeleted bool
+	Stats          bool
+}
+
+func (o DocumentTreeOptions) query() url.Values {
+	query := url.Values{}
+	if o.Prefix != "" {
+		query.Set("prefix", o.Prefix)
+	}
+	if o.Depth < 0 {
+		query.Set("depth", "0")
+	} else if o.Depth > 0 {
+		query.Set("depth", strconv.Itoa(o.Depth))
+	}
+	if o.IncludeDeleted {
+		query.Set("include_deleted", "true")
+	}
+	if o.Stats {
+		query.Set("stats", "true")
+	}
+	return query
+}
+
+// DocumentTree lists documents as a tree of directories, with when each was
+// last modified and how many open conversations it has
+func (c *Client) DocumentTree(ctx gocontext.Context, opts DocumentTreeOptions) (*DocumentTree, error) {
+	var tree DocumentTree
+	if _, err := c.get(ctx, endpoint("documents"), opts.query(), &tree); err != nil {
+		return nil, err
+	}
+	return &tree, nil
+}
+
+// DeleteDocument deletes a document, keeping its history so RestoreDocument
+// can bring it back
+func (c *Client) DeleteDocument(ctx gocontext.Context, path string) error {
+	return c.call(ctx, http.MethodDelete, endpoint("documents", path), nil, nil)
+}
+
+func (c *Client) RestoreDocument(ctx gocontext.Context, path string) (*Document, error) {
+	var doc Document
+	if err := c.call(ctx, http.MethodPost, endpoint("documents", path, "restore"), nil, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (c *Client) GetDocumentHistory(ctx gocontext.Context, path string) (*DocumentHistory, error) {
+	var history DocumentHistory
+	if _, err := c.get(ctx, endpoint("documents", path, "history"), nil, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// GetDocumentOwnership attributes the lines first to last of a document to
+// the authors who wrote and last touched them. Zero leaves either end open.
+func (c *Client) GetDocumentOwnership(ctx gocontext.Context, path string, first, last int) (*Ownership, error) {
+	query := url.Values{}
+	if first > 0 {
+		query.Set("first_line", strconv.Itoa(first))
+	}
+	if last > 0 {
+		query.Set("last_line", strconv.Itoa(last))
+	}
+
+	var ownership Ownership
+	if _, err := c.get(ctx, endpoint("documents", path, "ownership"), query, &ownership); err != nil {
+		return nil, err
+	}
+	return &ownership, nil
+}
+
+// GetConstructs lists the constructs of a document from start to end
+// inclusive, in position order. A position without segments leaves that end
+// of the range open.
+func (c *Client) GetConstructs(ctx gocontext.Context, path string, start, end LogootPosition) ([]*Construct, error) {
+	query := url.Values{}
+	if len(start.Segments) > 0 {
+		query.Set("start", start.String())
+	}
+	if len(end.Segments) > 0 {
+		query.Set("end", end.String())
+	}
+
+	var constructs []*Construct
+	if _, err := c.get(ctx, endpoint("documents", path, "constructs"), query, &constructs); err != nil {
+		return nil, err
+	}
+	return constructs, nil
+}
+
+// ExportConversations gathers the conversations anchored in a document, each
+// with the code it is anchored to
+func (c *Client) ExportConversations(ctx gocontext.Context, path string) (*ConversationTranscript, error) {
+	var transcript ConversationTranscript
+	if _, err := c.get(ctx, endpoint("documents", path, "conversations", "export"), nil, &transcript); err != nil {
+		return nil, err
+	}
+	return &transcript, nil
+}
+
+func (c *Client) ResolveAddress(ctx gocontext.Context, addr StableAddress) (*ResolvedAddress, error) {
+	var resolved ResolvedAddress
+	req := api.ResolveAddressRequest{Address: addr}
+	if err := c.call(ctx, http.MethodPost, endpoint("addresses", "resolve"), req, &resolved); err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
+
+func (c *Client) GetAddressHistory(ctx gocontext.Context, addr StableAddress) ([]MovementRecord, error) {
+	encoded, err := json.Marshal(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode address: %w", err)
+	}
+
+	var history []MovementRecord
+	if _, err := c.get(ctx, endpoint("addresses", string(encoded), "history"), nil, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// GetPermalink returns the operation a permalink points at
+func (c *Client) GetPermalink(ctx gocontext.Context, id OperationID) (*Permalink, error) {
+	var permalink Permalink
+	if _, err := c.get(ctx, endpoint("permalink", string(id)), nil, &permalink); err != nil {
+		return nil, err
+	}
+	return &permalink, nil
+}
+
+// SearchOptions narrows Search. Type is "conversation", "operation" or
+// "code"; empty searches all three. Tags, Labels, Status and MessageType only
+// match conversations, and DocumentID, Branch, Tool, Ticket and Language only
+// match operations.
+type SearchOptions struct {
+	Type        string
+	Author      string
+	ContentType string
+	Tags        []string
+	Labels      []string
+	Status      ThreadStatus
+	MessageType ConversationMessageType
+	DocumentID  string
+	Branch      string
+	Tool        string
+	Ticket      string
+	Language    string
+	Limit       int
+}
+
+func (c *Client) Search(ctx gocontext.Context, query string, opts SearchOptions) (*SearchResults, error) {
+	params := url.Values{"q": {query}}
+	if opts.Type != "" {
+		params.Set("type", opts.Type)
+	}
+	if opts.Author != "" {
+		params.Set("author", opts.Author)
+	}
+	if opts.ContentType != "" {
+		params.Set("content_type", opts.ContentType)
+	}
+	params["tag"] = opts.Tags
+	params["label"] = opts.Labels
+	if opts.Status != "" {
+		params.Set("status", string(opts.Status))
+	}
+	if opts.MessageType != "" {
+		params.Set("message_type", string(opts.MessageType))
+	}
+	setMetadataFilters(params, opts.DocumentID, opts.Branch, opts.Tool, opts.Ticket, opts.Language)
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	var results SearchResults
+	if _, err := c.get(ctx, endpoint("search"), params, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// SimilarOptions pick what Similar compares with and finds. Exactly one of
+// OperationID and MessageID must be set.
+type SimilarOptions struct {
+	OperationID OperationID
+	MessageID   MessageID
+	// Kind is "operation" or "message", the server defaults to operation
+	Kind  string
+	Limit int
+}
+
+// Similar finds operations or messages close in meaning to one operation or
+// message. Servers without embeddings configured answer with ErrUnavailable.
+func (c *Client) Similar(ctx gocontext.Context, opts SimilarOptions) (*SimilarResults, error) {
+	params := url.Values{}
+	if opts.OperationID != "" {
+		params.Set("operation_id", string(opts.OperationID))
+	}
+	if opts.MessageID != "" {
+		params.Set("message_id", string(opts.MessageID))
+	}
+	if opts.Kind != "" {
+		params.Set("kind", opts.Kind)
+	}
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	var results SimilarResults
+	if _, err := c.get(ctx, endpoint("search", "similar"), params, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+func (c *Client) Health(ctx gocontext.Context) (*HealthStatus, error) {
+	var health HealthStatus
+	if _, err := c.get(ctx, endpoint("health"), nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// OpenAPI returns the server's OpenAPI document
+func (c *Client) OpenAPI(ctx gocontext.Context) (json.RawMessage, error) {
+	resp, err := c.send(ctx, http.MethodGet, c.url(endpoint("openapi.json"), nil), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, err := decodeResponse(resp, nil)
+		if err == nil {
+			err = fmt.Errorf("%w: status %d", ErrUnexpectedResponse, resp.StatusCode)
+		}
+		return nil, err
+	}
+
+	var spec json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnexpectedResponse, err)
+	}
+	return spec, nil
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	gocontext "context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ActivityReportOptions picks the period and author to report on. The period
+// defaults to the week before Until, which defaults to now.
+type ActivityReportOptions struct {
+	Author AuthorID
+	Since  time.Time
+	Until  time.Time
+}
+
+func (o ActivityReportOptions) query() url.Values {
+	query := url.Values{}
+	if o.Author != "" {
+		query.Set("author", string(o.Author))
+	}
+	if !o.Since.IsZero() {
+		query.Set("since", o.Since.Format(time.RFC3339))
+	}
+	if !o.Until.IsZero() {
+		query.Set("until", o.Until.Format(time.RFC3339))
+	}
+	return query
+}
+
+// ActivityReport summarizes activity in a period, for the repository and per author
+func (c *Client) ActivityReport(ctx gocontext.Context, opts ActivityReportOptions) (*ActivityReport, error) {
+	var report ActivityReport
+	if _, err := c.get(ctx, endpoint("reports", "activity"), opts.query(), &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ChurnOptions picks the window churn is counted over and how it's ranked.
+// The window defaults to the week before Until, which defaults to now.
+type ChurnOptions struct {
+	Since time.Time
+	Until time.Time
+	Sort  ChurnSort
+	Limit int
+}
+
+func (o ChurnOptions) query() url.Values {
+	query := url.Values{}
+	if !o.Since.IsZero() {
+		query.Set("since", o.Since.Format(time.RFC3339))
+	}
+	if !o.Until.IsZero() {
+		query.Set("until", o.Until.Format(time.RFC3339))
+	}
+	if o.Sort != "" {
+		query.Set("sort", string(o.Sort))
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return query
+}
+
+// Churn ranks the documents and ranges of lines that changed the most
+func (c *Client) Churn(ctx gocontext.Context, opts ChurnOptions) (*ChurnReport, error) {
+	var report ChurnReport
+	if _, err := c.get(ctx, endpoint("analytics", "churn"), opts.query(), &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides how failed requests are retried. Requests are retried
+// when the server is unavailable or the connection fails, but requests that
+// may have changed something, like POST, are only retried when the server
+// refused them before doing any work.
+type RetryPolicy struct {
+	// MaxAttempts includes the first attempt, 1 disables retries
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// backoff is the wait before retry number attempt (from 1), exponential with
+// full jitter so clients that failed together don't retry together
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.InitialBackoff << (attempt - 1)
+	if ceiling <= 0 || ceiling > p.MaxBackoff {
+		ceiling = p.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling))) + 1
+}
+
+// wait is how long to back off before retry number attempt, honouring the
+// server's Retry-After up to MaxBackoff
+func (p RetryPolicy) wait(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			wait := time.Duration(seconds) * time.Second
+			if wait > p.MaxBackoff {
+				wait = p.MaxBackoff
+			}
+			return wait
+		}
+	}
+	return p.backoff(attempt)
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a request that got resp, or failed with a
+// transport error, is worth sending again
+func retryable(method string, resp *http.Response) bool {
+	if resp == nil {
+		return idempotent(method)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusServiceUnavailable:
+		// The server turns requests away with 503 before handling them
+		return true
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent(method)
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/jeremytregunna/contextdb/internal/api"
+)
+
+// ListReviewsOptions filters ListReviews
+type ListReviewsOptions struct {
+	Document  string
+	ChangeSet ChangeSetID
+	Author    AuthorID
+	Reviewer  AuthorID
+	Status    ReviewStatus
+	// Open leaves out resolved and archived reviews
+	Open   bool
+	Offset int
+	Limit  int
+}
+
+func (o ListReviewsOptions) query() url.Values {
+	query := url.Values{}
+	if o.Document != "" {
+		query.Set("document", o.Document)
+	}
+	if o.ChangeSet != "" {
+		query.Set("changeset", string(o.ChangeSet))
+	}
+	if o.Author != "" {
+		query.Set("author", string(o.Author))
+	}
+	if o.Reviewer != "" {
+		query.Set("reviewer", string(o.Reviewer))
+	}
+	if o.Status != "" {
+		query.Set("status", string(o.Status))
+	}
+	if o.Open {
+		query.Set("open", "true")
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return query
+}
+
+// ListReviews returns a page of reviews, most recently updated first
+func (c *Client) ListReviews(ctx gocontext.Context, opts ListReviewsOptions) ([]*Review, *ResponseMeta, error) {
+	var reviews []*Review
+	meta, err := c.get(ctx, endpoint("reviews"), opts.query(), &reviews)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reviews, meta, nil
+}
+
+// CreateReview asks req.Reviewers to review a change set or range of code
+func (c *Client) CreateReview(ctx gocontext.Context, req CreateReviewRequest) (*Review, error) {
+	var review Review
+	if err := c.call(ctx, http.MethodPost, endpoint("reviews"), req, &review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (c *Client) GetReview(ctx gocontext.Context, id ThreadID) (*Review, error) {
+	var review Review
+	if _, err := c.get(ctx, endpoint("reviews", string(id)), nil, &review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// RequestReviewers asks more reviewers for their verdict on review id
+func (c *Client) RequestReviewers(ctx gocontext.Context, id ThreadID, reviewers ...AuthorID) (*Review, error) {
+	var review Review
+	req := api.RequestReviewersRequest{Reviewers: reviewers}
+	if err := c.call(ctx, http.MethodPost, endpoint("reviews", string(id), "reviewers"), req, &review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// ApproveReview records reviewer approving review id, with an optional comment
+func (c *Client) ApproveReview(ctx gocontext.Context, id ThreadID, reviewer AuthorID, content string) (*Review, error) {
+	return c.submitVerdict(ctx, id, "approve", reviewer, content)
+}
+
+// RequestReviewChanges records reviewer asking for changes on review id
+func (c *Client) RequestReviewChanges(ctx gocontext.Context, id ThreadID, reviewer AuthorID, content string) (*Review, error) {
+	return c.submitVerdict(ctx, id, "request-changes", reviewer, content)
+}
+
+func (c *Client) submitVerdict(ctx gocontext.Context, id ThreadID, verdict string, reviewer AuthorID, content string) (*Review, error) {
+	var review Review
+	req := api.ReviewVerdictRequest{AuthorID: reviewer, Content: content}
+	if err := c.call(ctx, http.MethodPost, endpoint("reviews", string(id), verdict), req, &review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	gocontext "context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// WithSigningKey signs every operation the client creates, over HTTP or a
+// WebSocket, as its author. Register the key's public half for that author
+// with RegisterSigningKey first.
+func WithSigningKey(key ed25519.PrivateKey) Option {
+	return func(c *Client) {
+		c.signingKey = key
+	}
+}
+
+// signRequest signs the operation req describes as the server will build it
+func signRequest(req *CreateOperationRequest, key ed25519.PrivateKey) error {
+	if req.Timestamp == nil {
+		now := time.Now()
+		req.Timestamp = &now
+	}
+	if req.DocumentID != "" {
+		req.Metadata.DocumentID = req.DocumentID
+	}
+
+	op := &Operation{
+		Type:        req.Type,
+		Position:    req.Position,
+		Content:     req.Content,
+		ContentType: req.ContentType,
+		Length:      req.Length,
+		Author:      req.Author,
+		Timestamp:   *req.Timestamp,
+		Parents:     req.Parents,
+		Metadata:    req.Metadata,
+	}
+	if err := operations.Sign(op, key); err != nil {
+		return err
+	}
+	req.Metadata.Signature = op.Metadata.Signature
+	return nil
+}
+
+// RegisterSigningKey lets publicKey sign operations as author, or as this
+// client's author when that is empty. Only admins register keys for
+// other authors.
+func (c *Client) RegisterSigningKey(ctx gocontext.Context, name string, author AuthorID, publicKey ed25519.PublicKey) (*SigningKey, error) {
+	req := RegisterSigningKeyRequest{
+		Name:      name,
+		AuthorID:  author,
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+	}
+	var key SigningKey
+	if err := c.call(ctx, http.MethodPost, endpoint("auth", "signing-keys"), req, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListSigningKeys lists the registered keys, only author's unless it is empty
+func (c *Client) ListSigningKeys(ctx gocontext.Context, author AuthorID) ([]SigningKey, error) {
+	query := url.Values{}
+	if author != "" {
+		query.Set("author", string(author))
+	}
+	var keys []SigningKey
+	if _, err := c.get(ctx, endpoint("auth", "signing-keys"), query, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (c *Client) RevokeSigningKey(ctx gocontext.Context, id string) error {
+	return c.call(ctx, http.MethodDelete, endpoint("auth", "signing-keys", id), nil, nil)
+}
+
+// RequireSignatures has the server refuse operations not signed by their author
+func (c *Client) RequireSignatures(ctx gocontext.Context) error {
+	return c.call(ctx, http.MethodPost, endpoint("auth", "signatures", "require"), nil, nil)
+}
+
+func (c *Client) AllowUnsignedOperations(ctx gocontext.Context) error {
+	return c.call(ctx, http.MethodPost, endpoint("auth", "signatures", "allow-unsigned"), nil, nil)
+}
@@ -0,0 +1,265 @@
+package client
+
+import (
+	"github.com/jeremytregunna/contextdb/internal/addressing"
+	"github.com/jeremytregunna/contextdb/internal/api"
+	"github.com/jeremytregunna/contextdb/internal/auth"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/context"
+	"github.com/jeremytregunna/contextdb/internal/events"
+	"github.com/jeremytregunna/contextdb/internal/jobs"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+	"github.com/jeremytregunna/contextdb/internal/positioning"
+	"github.com/jeremytregunna/contextdb/internal/storage"
+	"github.com/jeremytregunna/contextdb/internal/telemetry"
+	"github.com/jeremytregunna/contextdb/internal/webhooks"
+)
+
+// The client speaks in the server's own types, aliased here so programs
+// outside this module can name them. A field added on the server is a field
+// added here.
+
+// Operations and positions
+type (
+	Operation       = operations.Operation
+	OperationID     = operations.OperationID
+	OperationType   = operations.OperationType
+	OperationMeta   = operations.OperationMeta
+	AuthorID        = operations.AuthorID
+	LogootPosition  = operations.LogootPosition
+	PositionSegment = operations.PositionSegment
+	HLC             = operations.HLC
+	Chunk           = operations.Chunk
+	Document        = positioning.Document
+	Construct       = positioning.Construct
+	ChunkMode       = positioning.ChunkMode
+)
+
+const (
+	OpInsert = operations.OpInsert
+	OpDelete = operations.OpDelete
+	OpMove   = operations.OpMove
+	OpPatch  = operations.OpPatch
+
+	ChunkLines  = positioning.ChunkLines
+	ChunkBlocks = positioning.ChunkBlocks
+)
+
+// NewLogootPosition builds a position from its segments
+func NewLogootPosition(segments []PositionSegment) LogootPosition {
+	return operations.NewLogootPosition(segments)
+}
+
+// Addresses
+type (
+	RepositoryID    = addressing.RepositoryID
+	StableAddress   = addressing.StableAddress
+	PositionRange   = addressing.PositionRange
+	ResolvedAddress = addressing.ResolvedAddress
+	MovementRecord  = addressing.MovementRecord
+)
+
+// Conversations
+type (
+	ThreadID                = context.ThreadID
+	ConversationThread      = context.ConversationThread
+	ConversationMessage     = context.Message
+	ConversationMessageType = context.MessageType
+	ThreadStatus            = context.ThreadStatus
+	Visibility              = context.Visibility
+	TagCount                = context.TagCount
+	MessageID               = context.MessageID
+	Decision                = context.Decision
+	ChangeSetID             = context.ChangeSetID
+	ChangeSet               = context.ChangeSet
+	Review                  = context.Review
+	ReviewStatus            = context.ReviewStatus
+	ReviewerVerdict         = context.ReviewerVerdict
+	ActivityReport          = context.ActivityReport
+	ThreadSummary           = context.ThreadSummary
+)
+
+const (
+	MessageComment    = context.MsgComment
+	MessageQuestion   = context.MsgQuestion
+	MessageAnswer     = context.MsgAnswer
+	MessageDecision   = context.MsgDecision
+	MessageSuggestion = context.MsgSuggestion
+	MessageReview     = context.MsgReview
+	// MessageApprove and MessageRequestChanges are verdicts on a review
+	MessageApprove        = context.MsgApprove
+	MessageRequestChanges = context.MsgRequestChanges
+
+	ReviewPending          = context.ReviewPending
+	ReviewApproved         = context.ReviewApproved
+	ReviewChangesRequested = context.ReviewChangesRequested
+
+	StatusOpen     = context.StatusOpen
+	StatusResolved = context.StatusResolved
+	StatusArchived = context.StatusArchived
+	StatusPinned   = context.StatusPinned
+
+	VisibilityPublic       = context.VisibilityPublic
+	VisibilityParticipants = context.VisibilityParticipants
+	VisibilityPrivate      = context.VisibilityPrivate
+)
+
+// Reference graphs
+type (
+	ReferenceGraph = collaboration.ReferenceGraph
+	GraphNode      = collaboration.GraphNode
+	GraphEdge      = collaboration.GraphEdge
+)
+
+// Operation queries
+type (
+	OperationQuery     = collaboration.OperationQuery
+	OperationPredicate = collaboration.OperationPredicate
+	QueryResult        = collaboration.QueryResult
+	QueryMatch         = collaboration.QueryMatch
+)
+
+const (
+	QueryIncludeConversations = collaboration.QueryIncludeConversations
+	QueryIncludeAddresses     = collaboration.QueryIncludeAddresses
+)
+
+// Document trees
+type (
+	DocumentTree = collaboration.DocumentTree
+	TreeNode     = collaboration.TreeNode
+	TreeNodeType = collaboration.TreeNodeType
+)
+
+// DocumentStats sums up a document for GetDocumentWithStats and DocumentTree
+type DocumentStats = collaboration.DocumentStats
+
+const (
+	TreeDirectory = collaboration.TreeDirectory
+	TreeFile      = collaboration.TreeFile
+)
+
+// Branches
+type (
+	Branch         = storage.Branch
+	BranchMerge    = collaboration.BranchMerge
+	MergedDocument = collaboration.MergedDocument
+	MergeReport    = collaboration.MergeReport
+	MergeConflict  = collaboration.MergeConflict
+)
+
+// Editor edit journals
+type (
+	EditJournal      = telemetry.Journal
+	EditEvent        = telemetry.EditEvent
+	ContentChange    = telemetry.ContentChange
+	JournalImport    = collaboration.JournalImport
+	ImportedDocument = collaboration.ImportedDocument
+	FileImport       = collaboration.FileImport
+)
+
+// EditJournalFormat and EditJournalVersion identify the journals the server reads
+const (
+	EditJournalFormat  = telemetry.FormatVSCode
+	EditJournalVersion = telemetry.FormatVersion
+)
+
+// Conversation transcripts
+type (
+	ConversationTranscript = collaboration.ConversationTranscript
+	TranscriptEntry        = collaboration.TranscriptEntry
+	AnchoredConversation   = collaboration.AnchoredConversation
+)
+
+// Context packs
+type (
+	ContextPack   = collaboration.ContextPack
+	PackOperation = collaboration.PackOperation
+	PackOmissions = collaboration.PackOmissions
+)
+
+// Ownership
+type (
+	Ownership       = collaboration.Ownership
+	AuthorOwnership = collaboration.AuthorOwnership
+	OwnershipRange  = collaboration.OwnershipRange
+)
+
+// Churn
+type (
+	ChurnSort     = collaboration.ChurnSort
+	ChurnReport   = collaboration.ChurnReport
+	DocumentChurn = collaboration.DocumentChurn
+	RangeChurn    = collaboration.RangeChurn
+)
+
+const (
+	ChurnByOperations  = collaboration.ChurnByOperations
+	ChurnByAuthors     = collaboration.ChurnByAuthors
+	ChurnByDeleteRatio = collaboration.ChurnByDeleteRatio
+)
+
+// Authentication and administration
+type (
+	Permission       = auth.Permission
+	APIKeySummary    = auth.APIKeySummary
+	KeyRotation      = auth.KeyRotation
+	ClientInfo       = collaboration.ClientInfo
+	SigningKey       = auth.SigningKey
+	KeyUsage         = auth.KeyUsage
+	DailyUsage       = auth.DailyUsage
+	RetentionPolicy  = storage.RetentionPolicy
+	Duration         = storage.Duration
+	RetentionReport  = collaboration.RetentionReport
+	IntentTaxonomy   = context.IntentTaxonomy
+	IntentDefinition = context.IntentDefinition
+	ReanalysisQuery  = collaboration.ReanalysisQuery
+	IntentRecord     = storage.IntentRecord
+	Webhook          = webhooks.Webhook
+	WebhookDelivery  = webhooks.Delivery
+	EventType        = events.Type
+	JobStatus        = jobs.Status
+	JobRun           = storage.JobRun
+	JobProgress      = jobs.Progress
+	Stats            = api.ServerStats
+	IndexStats       = storage.IndexStats
+	LogLevels        = api.LogLevels
+)
+
+// Request and response bodies
+type (
+	CreateOperationRequest    = api.CreateOperationRequest
+	CreateConversationRequest = api.CreateConversationRequest
+	CreateDecisionRequest     = api.CreateDecisionRequest
+	CreateBranchRequest       = api.CreateBranchRequest
+	CreateReviewRequest       = api.CreateReviewRequest
+	ContextPackRequest        = api.ContextPackRequest
+	AddMessageRequest         = api.AddMessageRequest
+	CreateAPIKeyRequest       = api.CreateAPIKeyRequest
+	CreateOwnAPIKeyRequest    = api.CreateOwnAPIKeyRequest
+	RotateAPIKeyRequest       = api.RotateAPIKeyRequest
+	RotatedAPIKey             = api.RotatedAPIKey
+	RegisterSigningKeyRequest = api.RegisterSigningKeyRequest
+	CreateWebhookRequest      = api.CreateWebhookRequest
+	DocumentHistory           = api.DocumentHistory
+	OperationContext          = api.OperationContext
+	MergeBase                 = api.MergeBase
+	Ancestry                  = api.Ancestry
+	OperationIntent           = api.OperationIntent
+	IntentAnalysis            = api.IntentAnalysis
+	BatchIntentAnalysis       = api.BatchIntentAnalysis
+	AuthStatus                = api.AuthStatus
+	SearchResults             = api.SearchResults
+	SearchResult              = api.SearchResult
+	SimilarResults            = api.SimilarResults
+	SimilarResult             = api.SimilarResult
+	ChangeSetDetails          = api.ChangeSetDetails
+	HealthStatus              = api.HealthStatus
+	Permalink                 = api.Permalink
+	ResponseMeta              = api.ResponseMeta
+	ErrorResponse             = api.ErrorResponse
+	ErrorCode                 = api.ErrorCode
+	FieldError                = api.FieldError
+	VersionConflictDetails    = api.VersionConflictDetails
+	SetLogLevelsRequest       = api.SetLogLevelsRequest
+)
@@ -0,0 +1,307 @@
+package client
+
+import (
+	gocontext "context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jeremytregunna/contextdb/internal/collaboration"
+	"github.com/jeremytregunna/contextdb/internal/ids"
+	"github.com/jeremytregunna/contextdb/internal/operations"
+)
+
+// Messages exchanged over a collaboration connection
+type (
+	Message          = collaboration.Message
+	MessageType      = collaboration.MessageType
+	OperationPayload = collaboration.OperationPayload
+	PresencePayload  = collaboration.PresencePayload
+	SyncPayload      = collaboration.SyncPayload
+	AckPayload       = collaboration.AckPayload
+	ErrorPayload     = collaboration.ErrorPayload
+	ClosePayload     = collaboration.ClosePayload
+	SessionPayload   = collaboration.SessionPayload
+)
+
+const (
+	MsgOperation      = collaboration.MsgOperation
+	MsgPresence       = collaboration.MsgPresence
+	MsgSync           = collaboration.MsgSync
+	MsgAcknowledgment = collaboration.MsgAcknowledgment
+	MsgError          = collaboration.MsgError
+	MsgSession        = collaboration.MsgSession
+)
+
+const writeTimeout = 10 * time.Second
+
+// Conn is a live collaboration session. Receive must be called from one
+// goroutine at a time, sends are safe from any.
+type Conn struct {
+	ws         *websocket.Conn
+	signingKey ed25519.PrivateKey
+	writeMutex sync.Mutex
+	// pending holds messages that arrived between the chunks of a sync, for
+	// Receive to return after it
+	pending []*Message
+	session SessionPayload
+}
+
+// Connect opens a collaboration session, retrying the handshake as the
+// client's retry policy allows
+func (c *Client) Connect(ctx gocontext.Context) (*Conn, error) {
+	return c.dial(ctx, "")
+}
+
+// Resume reconnects to the session a dropped Conn's ResumeToken names. When
+// the server still kept it, the new Conn follows the same documents and
+// Receive returns what was missed first. Otherwise the Conn is a new session,
+// with Resumed false, and documents need subscribing to again.
+func (c *Client) Resume(ctx gocontext.Context, resumeToken string) (*Conn, error) {
+	return c.dial(ctx, resumeToken)
+}
+
+func (c *Client) dial(ctx gocontext.Context, resumeToken string) (*Conn, error) {
+	target := *c.baseURL
+	target.Scheme = "ws"
+	if c.baseURL.Scheme == "https" {
+		target.Scheme = "wss"
+	}
+	target.Path = c.baseURL.Path + endpoint("ws")
+	if resumeToken != "" {
+		target.RawQuery = url.Values{"resume_token": {resumeToken}}.Encode()
+	}
+
+	header := http.Header{}
+	c.setHeaders(header)
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 30 * time.Second,
+	}
+	if transport, ok := c.httpClient.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = transport.TLSClientConfig
+	}
+
+	for attempt := 1; ; attempt++ {
+		ws, resp, err := dialer.DialContext(ctx, target.String(), header)
+		if err == nil {
+			conn := &Conn{ws: ws, signingKey: c.signingKey}
+			if err := conn.readSession(ctx, dialer.HandshakeTimeout); err != nil {
+				ws.Close()
+				return nil, err
+			}
+			return conn, nil
+		}
+		if attempt >= c.retry.MaxAttempts || ctx.Err() != nil || !retryable(http.MethodGet, resp) {
+			if resp != nil && errors.Is(err, websocket.ErrBadHandshake) {
+				defer resp.Body.Close()
+				if _, decodeErr := decodeResponse(resp, nil); decodeErr != nil {
+					return nil, decodeErr
+				}
+			}
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+
+		timer := time.NewTimer(c.retry.wait(attempt, resp))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// readSession reads the session message the server begins with
+func (conn *Conn) readSession(ctx gocontext.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.ws.SetReadDeadline(deadline)
+	defer conn.ws.SetReadDeadline(time.Time{})
+
+	msg, err := conn.read()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+	if msg.Type != MsgSession {
+		return fmt.Errorf("%w: %s message before the session", ErrUnexpectedResponse, msg.Type)
+	}
+	return DecodePayload(msg, &conn.session)
+}
+
+// ResumeToken is what Resume needs to pick this session up after the
+// connection drops, empty when the server doesn't keep sessions
+func (conn *Conn) ResumeToken() string {
+	return conn.session.ResumeToken
+}
+
+// Resumed reports whether Resume picked up where a dropped session left off
+func (conn *Conn) Resumed() bool {
+	return conn.session.Resumed
+}
+
+// Subscribe follows a document. The server answers with a sync message
+// holding the document and the operations after sinceVersion, then forwards
+// operations and presence in it. Large answers may come compressed and in
+// chunks, which Receive puts back together.
+func (conn *Conn) Subscribe(documentID string, sinceVersion uint64) (string, error) {
+	return conn.send(MsgSync, SyncPayload{
+		DocumentID:     documentID,
+		SinceVersion:   sinceVersion,
+		AcceptEncoding: collaboration.SyncEncodingGzip,
+		Chunked:        true,
+	})
+}
+
+// SendOperation applies op to a document. The server acknowledges it with an
+// ack message carrying the returned message ID, along with the ID op was
+// stored under and its parents, which the server fills in with the
+// document's heads when op has none and isn't signed. op is given a
+// timestamp if it has none and is signed when the client has a signing key.
+func (conn *Conn) SendOperation(op *Operation, documentID string) (string, error) {
+	if op.Timestamp.IsZero() {
+		op.Timestamp = time.Now()
+	}
+	// The server files the operation under documentID, and its ID and
+	// signature cover that
+	if documentID != "" {
+		op.Metadata.DocumentID = documentID
+	}
+	if conn.signingKey != nil {
+		if err := operations.Sign(op, conn.signingKey); err != nil {
+			return "", err
+		}
+	}
+	return conn.send(MsgOperation, OperationPayload{Operation: op, DocumentID: documentID})
+}
+
+// UpdatePresence shares a cursor or selection with others in presence.DocumentID
+func (conn *Conn) UpdatePresence(presence PresencePayload) (string, error) {
+	return conn.send(MsgPresence, presence)
+}
+
+func (conn *Conn) send(msgType MessageType, payload interface{}) (string, error) {
+	msg := &Message{
+		Type:      msgType,
+		Payload:   payload,
+		MessageID: ids.NewWithPrefix("msg"),
+		Timestamp: time.Now(),
+	}
+
+	conn.writeMutex.Lock()
+	defer conn.writeMutex.Unlock()
+
+	conn.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := conn.ws.WriteJSON(msg); err != nil {
+		return "", fmt.Errorf("failed to send %s: %w", msgType, err)
+	}
+	return msg.MessageID, nil
+}
+
+// Receive waits for the next message. Use DecodePayload to read its payload.
+// Syncs are returned whole and decoded, however the server sent them. Once
+// the server closes the session, it returns ErrConnectionClosed with the
+// server's reason.
+func (conn *Conn) Receive() (*Message, error) {
+	if len(conn.pending) > 0 {
+		msg := conn.pending[0]
+		conn.pending = conn.pending[1:]
+		return msg, nil
+	}
+
+	msg, err := conn.read()
+	if err != nil || msg.Type != MsgSync {
+		return msg, err
+	}
+	return conn.assembleSync(msg)
+}
+
+func (conn *Conn) read() (*Message, error) {
+	var msg Message
+	if err := conn.ws.ReadJSON(&msg); err != nil {
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			return nil, fmt.Errorf("%w: %s", ErrConnectionClosed, closeErr.Text)
+		}
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// assembleSync reads the rest of a sync sent in chunks and decodes its state.
+// Other messages sent meanwhile are kept for Receive.
+func (conn *Conn) assembleSync(msg *Message) (*Message, error) {
+	var payload SyncPayload
+	if err := DecodePayload(msg, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Encoding == "" {
+		return msg, nil
+	}
+
+	for payload.Chunk != nil && payload.Chunk.More {
+		next, err := conn.read()
+		if err != nil {
+			return nil, err
+		}
+		var piece SyncPayload
+		if next.Type != MsgSync || DecodePayload(next, &piece) != nil || piece.Chunk == nil || piece.Chunk.SyncID != payload.Chunk.SyncID {
+			conn.pending = append(conn.pending, next)
+			continue
+		}
+		if piece.Chunk.Index != payload.Chunk.Index+1 {
+			return nil, fmt.Errorf("%w: sync chunk %d after %d", ErrUnexpectedResponse, piece.Chunk.Index, payload.Chunk.Index)
+		}
+		payload.Data = append(payload.Data, piece.Data...)
+		payload.Chunk = piece.Chunk
+	}
+
+	if err := payload.Decode(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnexpectedResponse, err)
+	}
+	msg.Payload = payload
+	return msg, nil
+}
+
+// Disconnect asks the server to end the session without dropping what it
+// already sent: Receive goes on returning that, then ErrConnectionClosed once
+// the server agrees, and the session can be resumed from there. Close the
+// Conn after that.
+func (conn *Conn) Disconnect() error {
+	conn.writeMutex.Lock()
+	defer conn.writeMutex.Unlock()
+
+	return conn.ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeTimeout))
+}
+
+// Close ends the session
+func (conn *Conn) Close() error {
+	conn.writeMutex.Lock()
+	conn.ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	conn.writeMutex.Unlock()
+
+	return conn.ws.Close()
+}
+
+// DecodePayload reads a received message's payload into the type its
+// message type calls for, e.g. *OperationPayload for MsgOperation
+func DecodePayload(msg *Message, payload interface{}) error {
+	data, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, payload); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnexpectedResponse, err)
+	}
+	return nil
+}